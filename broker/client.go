@@ -155,6 +155,23 @@ func generateRequestId() string {
 	return string(reqIdB)
 }
 
+// Disable HTTP/2 on a cloned transport; the underlying TCP connection of an
+// HTTP/2 session can't be hijacked.  The clone may have inherited the "h2"
+// ALPN protocol from the shared transport, so it must be removed as well.
+func disableHTTP2(tr *http.Transport) {
+	tr.ForceAttemptHTTP2 = false
+	tr.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	if tr.TLSClientConfig != nil {
+		nextProtos := make([]string, 0, len(tr.TLSClientConfig.NextProtos))
+		for _, proto := range tr.TLSClientConfig.NextProtos {
+			if proto != "h2" {
+				nextProtos = append(nextProtos, proto)
+			}
+		}
+		tr.TLSClientConfig.NextProtos = nextProtos
+	}
+}
+
 // Given an origin's broker URL, return a connected socket to the origin
 func ConnectToOrigin(ctx context.Context, brokerUrl, prefix, originName string) (conn net.Conn, err error) {
 
@@ -226,7 +243,7 @@ func ConnectToOrigin(ctx context.Context, brokerUrl, prefix, originName string)
 	// be hijacked which we will need to do below).  The clone ensures that we're
 	// not going to be reusing TCP connections.
 	tr := config.GetTransport().Clone()
-	tr.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	disableHTTP2(tr)
 	client := &http.Client{Transport: tr}
 
 	resp, err := client.Do(req)
@@ -443,6 +460,9 @@ func doCallback(ctx context.Context, brokerResp reversalRequest) (listener net.L
 		hijackConnMutex.Unlock()
 		return hj, nil
 	}
+	// Ensure TLS connections are layered over the hijackable TCP connection
+	tr.DialTLSContext = nil
+	disableHTTP2(tr)
 
 	// Cleanup any connections.  If we decide to steal one of them,
 	// we will set hj.realConn to nil.
@@ -25,6 +25,7 @@ import (
 	"strings"
 
 	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/metrics"
 	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/server_structs"
 	"github.com/pelicanplatform/pelican/utils"
@@ -48,6 +49,13 @@ func (server *CacheServer) CreateAdvertisement(name, originUrl, originWebUrl str
 		WebURL:         originWebUrl,
 		Namespaces:     server.GetNamespaceAds(),
 	}
+	if param.Cache_EnableWriteBack.GetBool() {
+		ad.Caps.WriteBack = true
+		ad.WriteBack = &server_structs.WriteBackPolicy{
+			Consistency: server_structs.WriteBackConsistencyEventual,
+			RetryLimit:  int64(param.Cache_WriteBackRetryLimit.GetDuration().Seconds()),
+		}
+	}
 
 	return &ad, nil
 }
@@ -180,6 +188,13 @@ func (server *CacheServer) GetNamespaceAdsFromDirector() error {
 
 	server.SetNamespaceAds(respNS)
 
+	namespaces := make([]string, 0, len(respNS))
+	for _, nsAd := range respNS {
+		namespaces = append(namespaces, nsAd.Path)
+	}
+	metrics.SetCacheNamespaces(namespaces)
+	metrics.SetBandwidthNamespaces(namespaces)
+
 	return nil
 }
 
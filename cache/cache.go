@@ -20,11 +20,20 @@ package cache
 
 import (
 	"context"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/pelicanplatform/pelican/metrics"
 	"github.com/pelicanplatform/pelican/server_utils"
+	"github.com/pelicanplatform/pelican/web_ui"
+)
+
+type (
+	namespaceStatsRes struct {
+		Namespaces []metrics.CacheNamespaceStats `json:"namespaces"`
+	}
 )
 
 var (
@@ -38,5 +47,11 @@ func RegisterCacheAPI(router *gin.Engine, ctx context.Context, egrp *errgroup.Gr
 	group := router.Group("/api/v1.0/cache")
 	{
 		group.POST("/directorTest", func(ginCtx *gin.Context) { server_utils.HandleDirectorTestResponse(ginCtx, notificationChan) })
+		group.GET("/namespace_stats", web_ui.AuthHandler, web_ui.AdminAuthHandler, handleNamespaceStats)
 	}
 }
+
+// GET /api/v1.0/cache/namespace_stats
+func handleNamespaceStats(ginCtx *gin.Context) {
+	ginCtx.JSON(http.StatusOK, namespaceStatsRes{Namespaces: metrics.GetCacheNamespaceStats()})
+}
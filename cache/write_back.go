@@ -0,0 +1,673 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+	"github.com/pelicanplatform/pelican/token"
+	"github.com/pelicanplatform/pelican/token_scopes"
+)
+
+type (
+	// An upload the cache acknowledged and has yet to write to the origin.  Its data is
+	// in <ID>.data next to the <ID>.json holding this struct, so pending uploads survive
+	// restarts of the cache.
+	writeBackUpload struct {
+		ID          string    `json:"id"`
+		Path        string    `json:"path"`
+		Token       string    `json:"token"`
+		Length      int64     `json:"length"`
+		Created     time.Time `json:"created"`
+		Attempts    int       `json:"attempts"`
+		NextAttempt time.Time `json:"next_attempt"`
+		LastError   string    `json:"last_error,omitempty"`
+	}
+
+	// The cache's write-back API.  Uploads are journaled to disk and acknowledged right
+	// away, then written to the origin the director selects with the client's token, so
+	// the origin remains the authority on who may write where.
+	writeBackCache struct {
+		dir        string
+		retryLimit time.Duration
+		// The largest upload accepted, in bytes; 0 for no limit
+		maxUploadSize int64
+		namespaces    func() []server_structs.NamespaceAdV2
+		// Parse the token, checking it's valid and signed by one of the issuers
+		parseToken func(tok string, issuers map[string]bool) (jwt.Token, error)
+		// Find the URL at the origin the object is written to
+		originUrl func(ctx context.Context, objectPath string) (string, error)
+		client    *http.Client
+		// Signalled when an upload is journaled, so it's written without waiting for the next scan
+		wake chan struct{}
+	}
+
+	// The keys of the namespaces' token issuers, fetched when first needed
+	issuerKeys struct {
+		keys   *ttlcache.Cache[string, jwk.Set]
+		loader ttlcache.Loader[string, jwk.Set]
+	}
+)
+
+const (
+	// How often the journal is scanned for uploads due to be written to the origin
+	writeBackScanInterval = 30 * time.Second
+	// The delay before retrying a failed write, doubled after each further failure
+	writeBackMinBackoff = 30 * time.Second
+	writeBackMaxBackoff = 30 * time.Minute
+
+	// How long the keys of a token issuer are cached
+	issuerKeysTTL = 15 * time.Minute
+	// How long a failure to get the keys of a token issuer is cached
+	issuerKeysFailureTTL = time.Minute
+
+	// How long the state of an upload the cache is done with stays available to its client
+	writeBackStatusRetention = 7 * 24 * time.Hour
+)
+
+var writeBackIdRegex = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+func newIssuerKeys() *issuerKeys {
+	ik := &issuerKeys{
+		keys: ttlcache.New(
+			ttlcache.WithTTL[string, jwk.Set](issuerKeysTTL),
+			ttlcache.WithDisableTouchOnHit[string, jwk.Set](),
+		),
+	}
+	ik.loader = ttlcache.NewSuppressedLoader[string, jwk.Set](ttlcache.LoaderFunc[string, jwk.Set](
+		func(c *ttlcache.Cache[string, jwk.Set], issuer string) *ttlcache.Item[string, jwk.Set] {
+			keys, err := token.GetJWKSFromIssUrl(issuer)
+			if err != nil {
+				log.Warningf("Failed to get the keys of issuer %s; its tokens can't be used for write-back uploads: %v", issuer, err)
+				// Remember the failure for a while, so requests don't each retry
+				return c.Set(issuer, jwk.NewSet(), issuerKeysFailureTTL)
+			}
+			return c.Set(issuer, *keys, ttlcache.DefaultTTL)
+		}), new(singleflight.Group))
+	return ik
+}
+
+// Parse the token, checking it's valid and signed by one of the issuers
+func (ik *issuerKeys) parse(tok string, issuers map[string]bool) (jwt.Token, error) {
+	unverified, err := jwt.ParseString(tok, jwt.WithVerify(false), jwt.WithValidate(false))
+	if err != nil {
+		return nil, err
+	}
+	if !issuers[unverified.Issuer()] {
+		return nil, errors.Errorf("the token issuer %s is not trusted for the namespace", unverified.Issuer())
+	}
+	item := ik.keys.Get(unverified.Issuer(), ttlcache.WithLoader[string, jwk.Set](ik.loader))
+	if item == nil {
+		return nil, errors.Errorf("the keys of token issuer %s are unavailable", unverified.Issuer())
+	}
+	return jwt.ParseString(tok, jwt.WithKeySet(item.Value()), jwt.WithValidate(true))
+}
+
+// Ask the director which origin an object is written to, returning the object's URL there
+func getDirectorWriteUrl(ctx context.Context, objectPath string) (string, error) {
+	fedInfo, err := config.GetFederation(ctx)
+	if err != nil {
+		return "", err
+	}
+	if fedInfo.DirectorEndpoint == "" {
+		return "", errors.New("no director specified; give the federation name (-f)")
+	}
+	reqUrl, err := url.JoinPath(fedInfo.DirectorEndpoint, "api", "v1.0", "director", "origin", objectPath)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, reqUrl, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "pelican-cache/"+config.GetVersion())
+	client := &http.Client{
+		Transport: config.GetTransport(),
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to query the director")
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusTemporaryRedirect {
+		return "", errors.Errorf("the director responded with status code %d instead of a redirect to an origin", resp.StatusCode)
+	}
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return "", errors.New("the director's redirect has no location")
+	}
+	return location, nil
+}
+
+func newWriteBackCache(dir string, retryLimit time.Duration, namespaces func() []server_structs.NamespaceAdV2, parseToken func(string, map[string]bool) (jwt.Token, error)) (*writeBackCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "failed to create the write-back journal directory")
+	}
+	wb := &writeBackCache{
+		dir:        dir,
+		retryLimit: retryLimit,
+		namespaces: namespaces,
+		parseToken: parseToken,
+		originUrl:  getDirectorWriteUrl,
+		client:     &http.Client{Transport: config.GetTransport()},
+		wake:       make(chan struct{}, 1),
+	}
+	wb.removeIncomplete()
+	return wb, nil
+}
+
+func (wb *writeBackCache) uploadFile(id string) string {
+	return filepath.Join(wb.dir, id+".json")
+}
+
+func (wb *writeBackCache) dataFile(id string) string {
+	return filepath.Join(wb.dir, id+".data")
+}
+
+// The state of an upload the cache is done with, kept for writeBackStatusRetention
+func (wb *writeBackCache) statusFile(id string) string {
+	return filepath.Join(wb.dir, id+".status")
+}
+
+// Remove what was left of the uploads the cache was receiving when it stopped; as they
+// were never acknowledged, their clients know to retry them
+func (wb *writeBackCache) removeIncomplete() {
+	entries, err := os.ReadDir(wb.dir)
+	if err != nil {
+		log.Warningln("Failed to list the write-back journal directory:", err)
+		return
+	}
+	for _, entry := range entries {
+		id, isData := strings.CutSuffix(entry.Name(), ".data")
+		if !strings.HasSuffix(entry.Name(), ".tmp") && !isData {
+			continue
+		}
+		if isData {
+			if _, err := os.Stat(wb.uploadFile(id)); err == nil {
+				continue
+			}
+		}
+		if err := os.Remove(filepath.Join(wb.dir, entry.Name())); err != nil {
+			log.Warningf("Failed to remove the incomplete upload %s: %v", entry.Name(), err)
+		}
+	}
+}
+
+// Write the upload to the journal, replacing its previous entry atomically
+func (wb *writeBackCache) journal(upload *writeBackUpload) error {
+	return wb.writeRecord(wb.uploadFile(upload.ID), upload)
+}
+
+// Write a record of the journal directory as JSON, replacing the previous record atomically
+func (wb *writeBackCache) writeRecord(name string, record any) error {
+	contents, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	tmpName := name + ".tmp"
+	file, err := os.OpenFile(tmpName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(contents)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpName, name)
+	}
+	if err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	// Persist the rename too; not all platforms can sync a directory, so failures are ignored
+	if dir, err := os.Open(wb.dir); err == nil {
+		_ = dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
+
+// Write the upload's data to disk, returning its length.  At most one byte more than
+// the maximum upload size is read, so larger uploads can be told apart.
+func (wb *writeBackCache) receive(id string, body io.Reader) (int64, error) {
+	file, err := os.OpenFile(wb.dataFile(id), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return 0, err
+	}
+	if wb.maxUploadSize > 0 {
+		body = io.LimitReader(body, wb.maxUploadSize+1)
+	}
+	length, err := io.Copy(file, body)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return length, err
+}
+
+// List the journaled uploads, oldest first
+func (wb *writeBackCache) list() []writeBackUpload {
+	entries, err := os.ReadDir(wb.dir)
+	if err != nil {
+		log.Warningln("Failed to list the write-back journal directory:", err)
+		return nil
+	}
+	uploads := make([]writeBackUpload, 0, len(entries)/2)
+	for _, entry := range entries {
+		id, isUpload := strings.CutSuffix(entry.Name(), ".json")
+		if !isUpload || !writeBackIdRegex.MatchString(id) {
+			continue
+		}
+		contents, err := os.ReadFile(wb.uploadFile(id))
+		if err != nil {
+			log.Warningf("Failed to read write-back upload %s: %v", id, err)
+			continue
+		}
+		upload := writeBackUpload{}
+		if err = json.Unmarshal(contents, &upload); err != nil {
+			log.Warningf("Failed to parse write-back upload %s: %v", id, err)
+			continue
+		}
+		uploads = append(uploads, upload)
+	}
+	// The IDs are random, so order by age to write the uploads in the order they arrived
+	sort.Slice(uploads, func(left, right int) bool {
+		return uploads[left].Created.Before(uploads[right].Created)
+	})
+	return uploads
+}
+
+func (wb *writeBackCache) remove(upload *writeBackUpload) {
+	if err := os.Remove(wb.uploadFile(upload.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warningf("Failed to remove write-back upload %s: %v", upload.ID, err)
+	}
+	if err := os.Remove(wb.dataFile(upload.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warningf("Failed to remove the data of write-back upload %s: %v", upload.ID, err)
+	}
+	metrics.PelicanCacheWriteBackPending.Dec()
+	metrics.PelicanCacheWriteBackPendingBytes.Sub(float64(upload.Length))
+}
+
+// Remove the upload from the journal, keeping its final state for its client
+func (wb *writeBackCache) finish(upload *writeBackUpload, status string) {
+	record := upload.status(status)
+	record.Finished = time.Now()
+	if err := wb.writeRecord(wb.statusFile(upload.ID), &record); err != nil {
+		log.Warningf("Failed to record the state of write-back upload %s: %v", upload.ID, err)
+	}
+	wb.remove(upload)
+}
+
+func (upload *writeBackUpload) status(status string) server_structs.WriteBackStatus {
+	return server_structs.WriteBackStatus{
+		ID:        upload.ID,
+		Path:      upload.Path,
+		Status:    status,
+		Attempts:  upload.Attempts,
+		LastError: upload.LastError,
+		Created:   upload.Created,
+	}
+}
+
+// Remove the states of the uploads the cache was done with more than writeBackStatusRetention ago
+func (wb *writeBackCache) pruneStatuses(now time.Time) {
+	entries, err := os.ReadDir(wb.dir)
+	if err != nil {
+		log.Warningln("Failed to list the write-back journal directory:", err)
+		return
+	}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".status") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || now.Sub(info.ModTime()) < writeBackStatusRetention {
+			continue
+		}
+		if err := os.Remove(filepath.Join(wb.dir, entry.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Warningf("Failed to remove the state of write-back upload %s: %v", entry.Name(), err)
+		}
+	}
+}
+
+// Report the state of an upload to its client, which got the upload's ID when the cache
+// accepted it.  The IDs are random, so knowing one is what entitles a client to its state.
+func (wb *writeBackCache) getStatus(ctx *gin.Context) {
+	id := ctx.Param("id")
+	if !writeBackIdRegex.MatchString(id) {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{Status: server_structs.RespFailed, Msg: "Invalid upload ID"})
+		return
+	}
+	if contents, err := os.ReadFile(wb.uploadFile(id)); err == nil {
+		upload := writeBackUpload{}
+		if err = json.Unmarshal(contents, &upload); err == nil {
+			ctx.JSON(http.StatusOK, upload.status(server_structs.WriteBackStatusPending))
+			return
+		}
+	}
+	contents, err := os.ReadFile(wb.statusFile(id))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{Status: server_structs.RespFailed, Msg: "No upload with this ID is known to the cache"})
+		return
+	}
+	status := server_structs.WriteBackStatus{}
+	if err = json.Unmarshal(contents, &status); err != nil {
+		log.Warningf("Failed to parse the state of write-back upload %s: %v", id, err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{Status: server_structs.RespFailed, Msg: "Failed to read the state of the upload"})
+		return
+	}
+	ctx.JSON(http.StatusOK, status)
+}
+
+// Find the namespace holding the object, preferring the longest matching prefix
+func (wb *writeBackCache) findNamespace(objectPath string) *server_structs.NamespaceAdV2 {
+	return server_utils.FindLongestPrefix(wb.namespaces(), func(ns server_structs.NamespaceAdV2) string { return ns.Path }, objectPath)
+}
+
+func getRequestToken(req *http.Request) string {
+	if authz := req.Header.Get("Authorization"); authz != "" {
+		return strings.TrimSpace(strings.TrimPrefix(authz, "Bearer "))
+	}
+	query := req.URL.Query()
+	if authz := query.Get("authz"); authz != "" {
+		return strings.TrimSpace(strings.TrimPrefix(authz, "Bearer "))
+	}
+	return query.Get("access_token")
+}
+
+// Check the request's token allows writing the object, returning the token.  Token
+// scopes are relative to the base paths of the issuer; scopes for the full object
+// path are accepted too.  The origin checks the token again when the upload is
+// written to it.
+func (wb *writeBackCache) authorize(req *http.Request, objectPath string) (string, int, error) {
+	ns := wb.findNamespace(objectPath)
+	if ns == nil {
+		return "", http.StatusNotFound, errors.Errorf("no namespace served by the cache holds %s", objectPath)
+	}
+	if !ns.Caps.Writes {
+		return "", http.StatusForbidden, errors.Errorf("the namespace %s does not allow writes", ns.Path)
+	}
+	tokStr := getRequestToken(req)
+	if tokStr == "" {
+		return "", http.StatusUnauthorized, errors.New("uploads require a token")
+	}
+	issuers := make(map[string]bool, len(ns.Issuer))
+	for _, issuer := range ns.Issuer {
+		issuers[issuer.IssuerUrl.String()] = true
+	}
+	tok, err := wb.parseToken(tokStr, issuers)
+	if err != nil {
+		return "", http.StatusForbidden, errors.Wrap(err, "the token is not valid")
+	}
+	// The token is used for every attempt to write the upload to the origin, so it must
+	// outlive them; otherwise an acknowledged upload could be rejected by the origin
+	if expiry := tok.Expiration(); !expiry.IsZero() && expiry.Before(time.Now().Add(wb.retryLimit)) {
+		return "", http.StatusForbidden, errors.Errorf("the token expires at %s, before the cache stops retrying to write the upload to the origin; "+
+			"write-back uploads need a token valid for at least %s", expiry.UTC().Format(time.RFC3339), wb.retryLimit)
+	}
+
+	resources := []string{objectPath}
+	for _, issuer := range ns.Issuer {
+		if issuer.IssuerUrl.String() != tok.Issuer() {
+			continue
+		}
+		for _, basePath := range append(issuer.BasePaths, ns.Path) {
+			prefix := strings.TrimSuffix(basePath, "/")
+			if objectPath == prefix || strings.HasPrefix(objectPath, prefix+"/") {
+				resources = append(resources, strings.TrimPrefix(objectPath, prefix))
+			}
+		}
+	}
+	for _, scope := range token_scopes.ParseResourceScopeString(tok) {
+		for _, authz := range []token_scopes.TokenScope{token_scopes.Storage_Create, token_scopes.Storage_Modify} {
+			for _, resource := range resources {
+				if scope.Contains(token_scopes.NewResourceScope(authz, resource)) {
+					return tokStr, http.StatusOK, nil
+				}
+			}
+		}
+	}
+	return "", http.StatusForbidden, errors.Errorf("the token does not allow writing %s", objectPath)
+}
+
+// Accept an upload, acknowledging it once it's journaled
+func (wb *writeBackCache) put(ctx *gin.Context) {
+	objectPath := path.Clean("/" + ctx.Param("path"))
+	tok, status, err := wb.authorize(ctx.Request, objectPath)
+	if err != nil {
+		ctx.JSON(status, server_structs.SimpleApiResp{Status: server_structs.RespFailed, Msg: err.Error()})
+		return
+	}
+	tooLarge := server_structs.SimpleApiResp{
+		Status: server_structs.RespFailed,
+		Msg:    fmt.Sprintf("Write-back uploads are limited to %d bytes", wb.maxUploadSize),
+	}
+	if wb.maxUploadSize > 0 && ctx.Request.ContentLength > wb.maxUploadSize {
+		ctx.JSON(http.StatusRequestEntityTooLarge, tooLarge)
+		return
+	}
+
+	idBytes := make([]byte, 16)
+	if _, err = rand.Read(idBytes); err != nil {
+		log.Errorln("Failed to generate a write-back upload ID:", err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{Status: server_structs.RespFailed, Msg: "Failed to accept the upload"})
+		return
+	}
+	upload := writeBackUpload{ID: hex.EncodeToString(idBytes), Path: objectPath, Token: tok, Created: time.Now()}
+	upload.NextAttempt = upload.Created
+	upload.Length, err = wb.receive(upload.ID, ctx.Request.Body)
+	if err == nil && wb.maxUploadSize > 0 && upload.Length > wb.maxUploadSize {
+		if err := os.Remove(wb.dataFile(upload.ID)); err != nil {
+			log.Warningf("Failed to remove the data of write-back upload %s: %v", upload.ID, err)
+		}
+		ctx.JSON(http.StatusRequestEntityTooLarge, tooLarge)
+		return
+	}
+	if err == nil && ctx.Request.ContentLength >= 0 && upload.Length != ctx.Request.ContentLength {
+		err = errors.Errorf("received %d of the %d bytes", upload.Length, ctx.Request.ContentLength)
+	}
+	if err == nil {
+		err = wb.journal(&upload)
+	}
+	if err != nil {
+		log.Warningf("Failed to accept the upload of %s: %v", objectPath, err)
+		if err := os.Remove(wb.dataFile(upload.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Warningf("Failed to remove the data of write-back upload %s: %v", upload.ID, err)
+		}
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{Status: server_structs.RespFailed, Msg: "Failed to accept the upload"})
+		return
+	}
+	log.Debugf("Accepted write-back upload %s of %d bytes to %s", upload.ID, upload.Length, objectPath)
+	metrics.PelicanCacheWriteBackUploads.WithLabelValues("accepted").Inc()
+	metrics.PelicanCacheWriteBackPending.Inc()
+	metrics.PelicanCacheWriteBackPendingBytes.Add(float64(upload.Length))
+	select {
+	case wb.wake <- struct{}{}:
+	default:
+	}
+	statusPath := server_structs.WriteBackStatusPath + "/" + upload.ID
+	ctx.Header(server_structs.WriteBackStatusHeader, statusPath)
+	ctx.JSON(http.StatusOK, server_structs.SimpleApiResp{
+		Status: server_structs.RespOK,
+		Msg:    "The upload was accepted and will be written to the origin asynchronously; its progress is reported at " + statusPath,
+	})
+}
+
+// Write the upload to the origin with the client's token.  Returns whether a failure is
+// permanent, i.e. the origin rejected the upload so retrying is pointless.
+func (wb *writeBackCache) write(ctx context.Context, upload *writeBackUpload) (permanent bool, err error) {
+	objectUrl, err := wb.originUrl(ctx, upload.Path)
+	if err != nil {
+		return false, err
+	}
+	file, err := os.Open(wb.dataFile(upload.ID))
+	if err != nil {
+		return true, errors.Wrap(err, "failed to open the journaled upload")
+	}
+	defer file.Close()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, objectUrl, file)
+	if err != nil {
+		return true, err
+	}
+	req.ContentLength = upload.Length
+	if upload.Length == 0 {
+		req.Body = http.NoBody
+	}
+	req.Header.Set("Authorization", "Bearer "+upload.Token)
+	req.Header.Set("User-Agent", "pelican-cache/"+config.GetVersion())
+	resp, err := wb.client.Do(req)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to write the object to the origin")
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	permanent = resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests
+	return permanent, errors.Errorf("the origin rejected the object with status code %d", resp.StatusCode)
+}
+
+func writeBackBackoff(attempts int) time.Duration {
+	backoff := writeBackMinBackoff
+	for idx := 1; idx < attempts && backoff < writeBackMaxBackoff; idx++ {
+		backoff *= 2
+	}
+	return min(backoff, writeBackMaxBackoff)
+}
+
+// Write the uploads due for an attempt to the origin, rescheduling those that fail
+func (wb *writeBackCache) writeDue(ctx context.Context) {
+	for _, upload := range wb.list() {
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Now().Before(upload.NextAttempt) {
+			continue
+		}
+		upload := upload
+		permanent, err := wb.write(ctx, &upload)
+		if err == nil {
+			log.Debugf("Wrote write-back upload %s of %d bytes to %s", upload.ID, upload.Length, upload.Path)
+			metrics.PelicanCacheWriteBackAttempts.WithLabelValues("success").Inc()
+			metrics.PelicanCacheWriteBackUploads.WithLabelValues("written").Inc()
+			wb.finish(&upload, server_structs.WriteBackStatusWritten)
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		metrics.PelicanCacheWriteBackAttempts.WithLabelValues("failure").Inc()
+		upload.Attempts++
+		upload.LastError = err.Error()
+		if permanent || time.Since(upload.Created) >= wb.retryLimit {
+			log.Errorf("Giving up on writing the upload of %s to the origin after %d attempts: %v", upload.Path, upload.Attempts, err)
+			metrics.PelicanCacheWriteBackUploads.WithLabelValues("failed").Inc()
+			wb.finish(&upload, server_structs.WriteBackStatusFailed)
+			continue
+		}
+		backoff := writeBackBackoff(upload.Attempts)
+		log.Warningf("Failed to write the upload of %s to the origin; retrying in %s: %v", upload.Path, backoff, err)
+		upload.NextAttempt = time.Now().Add(backoff)
+		if err = wb.journal(&upload); err != nil {
+			log.Errorf("Failed to journal the retry of write-back upload %s: %v", upload.ID, err)
+		}
+	}
+}
+
+// Write the journaled uploads to the origins until the context is cancelled
+func (wb *writeBackCache) run(ctx context.Context) {
+	ticker := time.NewTicker(writeBackScanInterval)
+	defer ticker.Stop()
+	for {
+		wb.writeDue(ctx)
+		wb.pruneStatuses(time.Now())
+		select {
+		case <-ticker.C:
+		case <-wb.wake:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Register the write-back API and launch the writing of the accepted uploads to the
+// origins, resuming those journaled before the cache restarted.  Uploads are accepted
+// for the namespaces returned by namespaces, with tokens from their issuers.
+func RegisterWriteBack(ctx context.Context, egrp *errgroup.Group, engine *gin.Engine, namespaces func() []server_structs.NamespaceAdV2) error {
+	wb, err := newWriteBackCache(param.Cache_WriteBackLocation.GetString(), param.Cache_WriteBackRetryLimit.GetDuration(), namespaces, newIssuerKeys().parse)
+	if err != nil {
+		return err
+	}
+	wb.maxUploadSize = int64(param.Cache_WriteBackMaxUploadSize.GetInt())
+	pending := wb.list()
+	var pendingBytes int64
+	for _, upload := range pending {
+		pendingBytes += upload.Length
+	}
+	metrics.PelicanCacheWriteBackPending.Set(float64(len(pending)))
+	metrics.PelicanCacheWriteBackPendingBytes.Set(float64(pendingBytes))
+
+	engine.PUT(server_structs.WriteBackPath+"/*path", wb.put)
+	engine.GET(server_structs.WriteBackStatusPath+"/:id", wb.getStatus)
+	egrp.Go(func() error {
+		wb.run(ctx)
+		return nil
+	})
+	log.Infof("Accepting write-back uploads journaled in %s; %d are pending", param.Cache_WriteBackLocation.GetString(), len(pending))
+	return nil
+}
@@ -0,0 +1,261 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/token"
+	"github.com/pelicanplatform/pelican/token_scopes"
+)
+
+func TestWriteBack(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("IssuerKey", filepath.Join(t.TempDir(), "issuer.jwk"))
+	keys, err := config.GetIssuerPublicJWKS()
+	require.NoError(t, err)
+
+	const issuer = "https://origin.example.com:8444"
+	newToken := func(t *testing.T, lifetime time.Duration, scopes ...token_scopes.ResourceScope) string {
+		tokenCfg := token.NewWLCGToken()
+		tokenCfg.Lifetime = lifetime
+		tokenCfg.Issuer = issuer
+		tokenCfg.Subject = "uploader"
+		tokenCfg.AddAudienceAny()
+		tokenCfg.AddResourceScopes(scopes...)
+		tok, err := tokenCfg.CreateToken()
+		require.NoError(t, err)
+		return tok
+	}
+	// The tokens must outlive the cache's retry limit of an hour
+	writeToken := newToken(t, 2*time.Hour, token_scopes.NewResourceScope(token_scopes.Storage_Create, "/data"))
+	readToken := newToken(t, 2*time.Hour, token_scopes.NewResourceScope(token_scopes.Storage_Read, "/"))
+	shortToken := newToken(t, time.Minute, token_scopes.NewResourceScope(token_scopes.Storage_Create, "/data"))
+
+	issuerUrl, err := url.Parse(issuer)
+	require.NoError(t, err)
+	namespaces := []server_structs.NamespaceAdV2{
+		{
+			Path:   "/first",
+			Caps:   server_structs.Capabilities{Reads: true, Writes: true},
+			Issuer: []server_structs.TokenIssuer{{BasePaths: []string{"/first"}, IssuerUrl: *issuerUrl}},
+		},
+		{
+			Path:   "/readonly",
+			Caps:   server_structs.Capabilities{Reads: true},
+			Issuer: []server_structs.TokenIssuer{{BasePaths: []string{"/readonly"}, IssuerUrl: *issuerUrl}},
+		},
+	}
+	parseToken := func(tok string, issuers map[string]bool) (jwt.Token, error) {
+		if !issuers[issuer] {
+			return nil, errors.New("untrusted issuer")
+		}
+		return jwt.ParseString(tok, jwt.WithKeySet(keys), jwt.WithValidate(true))
+	}
+
+	// The origin; it records the objects written to it
+	var originLock sync.Mutex
+	stored := map[string][]byte{}
+	originStatus := http.StatusCreated
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		originLock.Lock()
+		defer originLock.Unlock()
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "Bearer "+writeToken, r.Header.Get("Authorization"))
+		if originStatus == http.StatusCreated {
+			stored[r.URL.Path], _ = io.ReadAll(r.Body)
+		}
+		w.WriteHeader(originStatus)
+	}))
+	t.Cleanup(origin.Close)
+	setOriginStatus := func(status int) {
+		originLock.Lock()
+		defer originLock.Unlock()
+		originStatus = status
+	}
+
+	journalDir := t.TempDir()
+	newCache := func(t *testing.T) *writeBackCache {
+		wb, err := newWriteBackCache(journalDir, time.Hour, func() []server_structs.NamespaceAdV2 { return namespaces }, parseToken)
+		require.NoError(t, err)
+		wb.client = http.DefaultClient
+		wb.originUrl = func(_ context.Context, objectPath string) (string, error) { return origin.URL + objectPath, nil }
+		return wb
+	}
+	wb := newCache(t)
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.PUT(server_structs.WriteBackPath+"/*path", wb.put)
+	engine.GET(server_structs.WriteBackStatusPath+"/:id", wb.getStatus)
+	server := httptest.NewServer(engine)
+	t.Cleanup(server.Close)
+
+	put := func(t *testing.T, objectPath, tok string, body []byte) *http.Response {
+		req, err := http.NewRequest(http.MethodPut, server.URL+server_structs.WriteBackPath+objectPath, bytes.NewReader(body))
+		require.NoError(t, err)
+		if tok != "" {
+			req.Header.Set("Authorization", "Bearer "+tok)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+	getStatus := func(t *testing.T, resp *http.Response) server_structs.WriteBackStatus {
+		statusPath := resp.Header.Get(server_structs.WriteBackStatusHeader)
+		require.NotEmpty(t, statusPath)
+		statusResp, err := http.Get(server.URL + statusPath)
+		require.NoError(t, err)
+		defer statusResp.Body.Close()
+		require.Equal(t, http.StatusOK, statusResp.StatusCode)
+		status := server_structs.WriteBackStatus{}
+		require.NoError(t, json.NewDecoder(statusResp.Body).Decode(&status))
+		return status
+	}
+	contents := []byte("0123456789abcdefghij")
+
+	t.Run("rejects-unauthorized-uploads", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, put(t, "/first/data/object.txt", "", contents).StatusCode)
+		assert.Equal(t, http.StatusForbidden, put(t, "/first/data/object.txt", readToken, contents).StatusCode)
+		assert.Equal(t, http.StatusForbidden, put(t, "/first/other/object.txt", writeToken, contents).StatusCode)
+		assert.Equal(t, http.StatusForbidden, put(t, "/readonly/data/object.txt", writeToken, contents).StatusCode)
+		assert.Equal(t, http.StatusNotFound, put(t, "/unknown/object.txt", writeToken, contents).StatusCode)
+		// The token would expire while the cache may still be retrying the write
+		assert.Equal(t, http.StatusForbidden, put(t, "/first/data/object.txt", shortToken, contents).StatusCode)
+		assert.Empty(t, wb.list())
+	})
+
+	t.Run("rejects-oversized-uploads", func(t *testing.T) {
+		wb.maxUploadSize = int64(len(contents)) - 1
+		t.Cleanup(func() { wb.maxUploadSize = 0 })
+		assert.Equal(t, http.StatusRequestEntityTooLarge, put(t, "/first/data/object.txt", writeToken, contents).StatusCode)
+
+		// Without a content length, the upload is cut off once it's too large
+		req, err := http.NewRequest(http.MethodPut, server.URL+server_structs.WriteBackPath+"/first/data/object.txt", io.MultiReader(bytes.NewReader(contents)))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+writeToken)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+		assert.Empty(t, wb.list())
+		entries, err := os.ReadDir(journalDir)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("writes-back-accepted-uploads", func(t *testing.T) {
+		resp := put(t, "/first/data/object.txt", writeToken, contents)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, server_structs.WriteBackStatusPending, getStatus(t, resp).Status)
+		uploads := wb.list()
+		require.Len(t, uploads, 1)
+		assert.Equal(t, "/first/data/object.txt", uploads[0].Path)
+		assert.Equal(t, int64(len(contents)), uploads[0].Length)
+
+		wb.writeDue(context.Background())
+		assert.Equal(t, contents, stored["/first/data/object.txt"])
+		assert.Empty(t, wb.list())
+		assert.Equal(t, server_structs.WriteBackStatusWritten, getStatus(t, resp).Status)
+
+		// Only the state of the upload is left, until it's pruned
+		entries, err := os.ReadDir(journalDir)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		wb.pruneStatuses(time.Now().Add(writeBackStatusRetention))
+		entries, err = os.ReadDir(journalDir)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("retries-failed-writes-after-restart", func(t *testing.T) {
+		setOriginStatus(http.StatusServiceUnavailable)
+		assert.Equal(t, http.StatusOK, put(t, "/first/data/retried.txt", writeToken, contents).StatusCode)
+		wb.writeDue(context.Background())
+		uploads := wb.list()
+		require.Len(t, uploads, 1)
+		assert.Equal(t, 1, uploads[0].Attempts)
+		assert.True(t, uploads[0].NextAttempt.After(time.Now()))
+		assert.Contains(t, uploads[0].LastError, "503")
+
+		// The upload isn't due yet
+		setOriginStatus(http.StatusCreated)
+		wb.writeDue(context.Background())
+		assert.NotContains(t, stored, "/first/data/retried.txt")
+
+		// A restarted cache resumes it from the journal
+		restarted := newCache(t)
+		uploads[0].NextAttempt = time.Now()
+		require.NoError(t, restarted.journal(&uploads[0]))
+		restarted.writeDue(context.Background())
+		assert.Equal(t, contents, stored["/first/data/retried.txt"])
+		assert.Empty(t, restarted.list())
+	})
+
+	t.Run("gives-up-on-rejected-writes", func(t *testing.T) {
+		setOriginStatus(http.StatusForbidden)
+		t.Cleanup(func() { setOriginStatus(http.StatusCreated) })
+		resp := put(t, "/first/data/rejected.txt", writeToken, contents)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		wb.writeDue(context.Background())
+		assert.Empty(t, wb.list())
+		assert.NotContains(t, stored, "/first/data/rejected.txt")
+		status := getStatus(t, resp)
+		assert.Equal(t, server_structs.WriteBackStatusFailed, status.Status)
+		assert.Contains(t, status.LastError, "403")
+		wb.pruneStatuses(time.Now().Add(writeBackStatusRetention))
+	})
+
+	t.Run("removes-incomplete-uploads", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(journalDir, "0123456789abcdef0123456789abcdef.data"), contents, 0600))
+		require.NoError(t, os.WriteFile(filepath.Join(journalDir, "0123456789abcdef0123456789abcdef.json.tmp"), nil, 0600))
+		newCache(t)
+		entries, err := os.ReadDir(journalDir)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
+
+func TestWriteBackBackoff(t *testing.T) {
+	assert.Equal(t, writeBackMinBackoff, writeBackBackoff(1))
+	assert.Equal(t, 2*writeBackMinBackoff, writeBackBackoff(2))
+	assert.Equal(t, writeBackMaxBackoff, writeBackBackoff(100))
+}
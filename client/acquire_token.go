@@ -144,11 +144,61 @@ func RegisterClient(namespace namespaces.Namespace) (*config.PrefixEntry, error)
 	return &newEntry, nil
 }
 
+// Apply the issuer hint of a federation URL, e.g. pelican://fed/prefix/file?issuer=https://idp.example,
+// to the token generation of its namespace.  The hint names the issuer to get a token from
+// when the director didn't name one, or picks one of the issuers the director lists for the
+// namespace.  Other hints are ignored, as the director's metadata takes precedence.
+// Returns whether the hint was applied.
+func applyIssuerHint(destination *url.URL, namespace *namespaces.Namespace) bool {
+	hint := destination.Query().Get("issuer")
+	if hint == "" {
+		return false
+	}
+	credentialGen := namespaces.CredentialGeneration{}
+	if namespace.CredentialGen != nil {
+		credentialGen = *namespace.CredentialGen
+	}
+	if credentialGen.Issuer != nil && *credentialGen.Issuer != "" && *credentialGen.Issuer != hint {
+		listed := false
+		for _, issuer := range namespace.Issuer {
+			if strings.TrimSuffix(issuer, "/") == strings.TrimSuffix(hint, "/") {
+				listed = true
+				break
+			}
+		}
+		if !listed {
+			log.Warningf("Ignoring the issuer %s in the URL as it's not an issuer of %s; using %s instead", hint, namespace.Path, *credentialGen.Issuer)
+			return false
+		}
+	}
+	log.Debugf("Using the issuer %s from the URL for %s", hint, namespace.Path)
+	credentialGen.Issuer = &hint
+	if credentialGen.Strategy == nil || *credentialGen.Strategy == "" {
+		strategy := "OAuth2"
+		credentialGen.Strategy = &strategy
+	}
+	// The namespace is a copy, but the credential generation is shared with the caller
+	namespace.CredentialGen = &credentialGen
+	return true
+}
+
+// The issuer of a token, or the empty string if it can't be parsed
+func tokenIssuer(jwtSerialized string) string {
+	parser := jwt.Parser{SkipClaimsValidation: true}
+	token, _, err := parser.ParseUnverified(jwtSerialized, &jwt.MapClaims{})
+	if err != nil {
+		return ""
+	}
+	issuer, _ := (*token.Claims.(*jwt.MapClaims))["iss"].(string)
+	return issuer
+}
+
 // Given a URL and a piece of the namespace, attempt to acquire a valid
 // token for that URL.
 func AcquireToken(destination *url.URL, namespace namespaces.Namespace, opts config.TokenGenerationOpts) (string, error) {
 	log.Debugln("Acquiring a token from configuration and OAuth2")
 
+	hinted := applyIssuerHint(destination, &namespace)
 	if namespace.CredentialGen == nil || namespace.CredentialGen.Strategy == nil {
 		return "", fmt.Errorf("Credential generation scheme unknown for prefix %s", namespace.Path)
 	}
@@ -215,6 +265,10 @@ func AcquireToken(destination *url.URL, namespace namespaces.Namespace, opts con
 		if !TokenIsAcceptable(token.AccessToken, destination.Path, namespace, opts) {
 			continue
 		}
+		// Tokens of other issuers may be cached for the prefix, e.g. during a migration
+		if hinted && tokenIssuer(token.AccessToken) != issuer {
+			continue
+		}
 		if acceptableToken == nil {
 			acceptableToken = &prefixEntry.Tokens[idx]
 		} else if acceptableUnexpiredToken != "" {
@@ -0,0 +1,158 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+// When the director's announcements were last fetched, saved at Client.AnnouncementsLocation
+type announcementState struct {
+	DirectorUrl string    `json:"director_url"`
+	Fetched     time.Time `json:"fetched"`
+}
+
+const (
+	// How often the director's announcements are fetched and shown
+	announcementInterval = 24 * time.Hour
+
+	// The announcements are fetched before transfers, so they mustn't hold them up
+	announcementFetchTimeout = 5 * time.Second
+)
+
+// Fetch the announcements in effect from the director
+func fetchAnnouncements(ctx context.Context, directorUrl string) ([]server_structs.Announcement, error) {
+	announcementsUrl, err := url.JoinPath(directorUrl, "api", "v1.0", "director", "announcements")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate the URL of the director's announcements")
+	}
+	ctx, cancel := context.WithTimeout(ctx, announcementFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, announcementsUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", getUserAgent(""))
+	resp, err := (&http.Client{Transport: config.GetTransport()}).Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch the director's announcements")
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the director's announcements")
+	}
+	// Directors predating announcements don't have the endpoint
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("the director responded to the announcements request with status code %d", resp.StatusCode)
+	}
+	announcements := []server_structs.Announcement{}
+	if err = json.Unmarshal(body, &announcements); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the director's announcements")
+	}
+	return announcements, nil
+}
+
+// Print the announcements, one per paragraph
+func printAnnouncements(out io.Writer, announcements []server_structs.Announcement) {
+	for _, announcement := range announcements {
+		severity := strings.ToUpper(string(announcement.Severity))
+		if severity == "" {
+			severity = strings.ToUpper(string(server_structs.AnnouncementInfo))
+		}
+		fmt.Fprintf(out, "Federation announcement [%s]: %s\n", severity, strings.TrimSpace(announcement.Message))
+	}
+	if len(announcements) > 0 {
+		fmt.Fprintln(out)
+	}
+}
+
+func readAnnouncementState(stateFile string) announcementState {
+	state := announcementState{}
+	if contents, err := os.ReadFile(stateFile); err == nil {
+		if err = json.Unmarshal(contents, &state); err != nil {
+			log.Debugf("Ignoring the unreadable announcement state in %s: %v", stateFile, err)
+		}
+	}
+	return state
+}
+
+func writeAnnouncementState(stateFile string, state announcementState) error {
+	contents, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(stateFile), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(stateFile, contents, 0600)
+}
+
+// Show the announcements of the federation's director, unless Client.DisableAnnouncements
+// is set or they were already fetched in the last day from the same director.  Failing to
+// fetch them is only logged, and the next attempt is a day later too, so an unreachable
+// director doesn't slow every command down.
+func ShowAnnouncements(ctx context.Context, out io.Writer) {
+	if param.Client_DisableAnnouncements.GetBool() {
+		return
+	}
+	fedInfo, err := config.GetFederation(ctx)
+	if err != nil || fedInfo.DirectorEndpoint == "" {
+		return
+	}
+	stateFile := param.Client_AnnouncementsLocation.GetString()
+	if stateFile == "" {
+		return
+	}
+	state := readAnnouncementState(stateFile)
+	now := time.Now()
+	if state.DirectorUrl == fedInfo.DirectorEndpoint && now.Sub(state.Fetched) < announcementInterval && !now.Before(state.Fetched) {
+		return
+	}
+
+	announcements, err := fetchAnnouncements(ctx, fedInfo.DirectorEndpoint)
+	if err != nil {
+		log.Debugln("Failed to get the federation's announcements:", err)
+	} else {
+		printAnnouncements(out, announcements)
+	}
+	state = announcementState{DirectorUrl: fedInfo.DirectorEndpoint, Fetched: now}
+	if err = writeAnnouncementState(stateFile, state); err != nil {
+		log.Debugf("Failed to save the announcement state to %s: %v", stateFile, err)
+	}
+}
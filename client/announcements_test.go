@@ -0,0 +1,104 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+func TestShowAnnouncements(t *testing.T) {
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1.0/director/announcements" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fetches.Add(1)
+		require.NoError(t, json.NewEncoder(w).Encode([]server_structs.Announcement{
+			{ID: "maintenance", Message: "The origins will be down on Monday.", Severity: server_structs.AnnouncementWarning},
+		}))
+	}))
+	t.Cleanup(server.Close)
+
+	viper.Reset()
+	t.Cleanup(func() {
+		viper.Reset()
+		config.ResetFederationForTest()
+	})
+	stateFile := filepath.Join(t.TempDir(), "announcements.json")
+	viper.Set("Client.AnnouncementsLocation", stateFile)
+	config.SetFederation(config.FederationDiscovery{DirectorEndpoint: server.URL})
+	ctx := context.Background()
+
+	out := &bytes.Buffer{}
+	ShowAnnouncements(ctx, out)
+	assert.Equal(t, "Federation announcement [WARNING]: The origins will be down on Monday.\n\n", out.String())
+	assert.Equal(t, int32(1), fetches.Load())
+
+	t.Run("shown-once-a-day", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		ShowAnnouncements(ctx, out)
+		assert.Empty(t, out.String())
+		assert.Equal(t, int32(1), fetches.Load())
+
+		require.NoError(t, writeAnnouncementState(stateFile, announcementState{
+			DirectorUrl: server.URL,
+			Fetched:     time.Now().Add(-25 * time.Hour),
+		}))
+		ShowAnnouncements(ctx, out)
+		assert.Contains(t, out.String(), "The origins will be down on Monday.")
+		assert.Equal(t, int32(2), fetches.Load())
+	})
+
+	t.Run("fetched-again-for-another-director", func(t *testing.T) {
+		require.NoError(t, writeAnnouncementState(stateFile, announcementState{
+			DirectorUrl: "https://director.example.com",
+			Fetched:     time.Now(),
+		}))
+		out := &bytes.Buffer{}
+		ShowAnnouncements(ctx, out)
+		assert.NotEmpty(t, out.String())
+		assert.Equal(t, int32(3), fetches.Load())
+	})
+
+	t.Run("suppressed", func(t *testing.T) {
+		viper.Set("Client.DisableAnnouncements", true)
+		t.Cleanup(func() { viper.Set("Client.DisableAnnouncements", false) })
+		require.NoError(t, writeAnnouncementState(stateFile, announcementState{}))
+		out := &bytes.Buffer{}
+		ShowAnnouncements(ctx, out)
+		assert.Empty(t, out.String())
+		assert.Equal(t, int32(3), fetches.Load())
+	})
+}
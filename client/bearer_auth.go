@@ -48,6 +48,9 @@ func (b *bearerAuth) AddAuthenticator(key string, fn gowebdav.AuthFactory) {
 
 // Authorize the current request
 func (b *bearerAuthenticator) Authorize(c *http.Client, rq *http.Request, path string) error {
+	if err := checkServerAllowed(rq.Context(), rq.URL); err != nil {
+		return err
+	}
 	rq.Header.Add("Authorization", "Bearer "+b.token) //set the header with the token
 	return nil
 }
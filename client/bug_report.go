@@ -0,0 +1,350 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+
+	"github.com/pelicanplatform/pelican/config"
+)
+
+type (
+	// The contents of a bug report bundle beyond what's captured automatically
+	BugReportOptions struct {
+		// The error that prompted the report, if any
+		Error error
+		// A log file whose most recent contents are included; defaults to Logging.LogLocation
+		LogFile string
+		// The command line of the failing invocation
+		Args []string
+	}
+
+	// A director response observed while the bug report capture is active
+	DirectorResponse struct {
+		Time       time.Time           `json:"time"`
+		Method     string              `json:"method"`
+		URL        string              `json:"url"`
+		StatusCode int                 `json:"status_code"`
+		Headers    map[string][]string `json:"headers"`
+		Body       string              `json:"body"`
+	}
+
+	// A logrus hook keeping the most recent log entries, at every level, in memory
+	debugTraceHook struct {
+		lock    sync.Mutex
+		entries []string
+		next    int // The position of the oldest entry once the buffer is full
+	}
+
+	// Drops the entries above the configured log level so the trace may be recorded at
+	// debug level without changing what's printed
+	levelFilterFormatter struct {
+		log.Formatter
+		level log.Level
+	}
+)
+
+const (
+	// The maximum number of log entries kept for the debug trace
+	maxTraceEntries = 10000
+	// The maximum number of director responses kept
+	maxDirectorResponses = 50
+	// The maximum amount of the log file included in a bug report
+	maxBugReportLogBytes = 1024 * 1024
+)
+
+var (
+	bugReportCapture   atomic.Bool
+	traceHook          *debugTraceHook
+	directorRespLock   sync.Mutex
+	directorResponses  []DirectorResponse
+	sensitiveKeyFields = []string{"token", "secret", "password", "passwd", "credential", "privatekey"}
+)
+
+func (h *debugTraceHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (h *debugTraceHook) Fire(entry *log.Entry) error {
+	line, err := (&log.TextFormatter{DisableColors: true, FullTimestamp: true}).Format(entry)
+	if err != nil {
+		return err
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if len(h.entries) < maxTraceEntries {
+		h.entries = append(h.entries, string(line))
+		return nil
+	}
+	h.entries[h.next] = string(line)
+	h.next = (h.next + 1) % maxTraceEntries
+	return nil
+}
+
+// Returns the captured entries, oldest first
+func (h *debugTraceHook) contents() string {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	buf := strings.Builder{}
+	for _, line := range h.entries[h.next:] {
+		buf.WriteString(line)
+	}
+	for _, line := range h.entries[:h.next] {
+		buf.WriteString(line)
+	}
+	return buf.String()
+}
+
+func (f *levelFilterFormatter) Format(entry *log.Entry) ([]byte, error) {
+	if entry.Level > f.level {
+		return nil, nil
+	}
+	return f.Formatter.Format(entry)
+}
+
+// Start capturing the debug trace and the director responses of this process so they
+// can be included in a bug report.  The logger is raised to debug level to record the
+// trace, but only the messages at the configured level are printed.
+//
+// Must be called after the logging is configured (e.g., by config.InitClient)
+func StartBugReportCapture() {
+	if bugReportCapture.Swap(true) {
+		return
+	}
+	logger := log.StandardLogger()
+	if level := logger.GetLevel(); level < log.DebugLevel {
+		logger.SetFormatter(&levelFilterFormatter{Formatter: logger.Formatter, level: level})
+		logger.SetLevel(log.DebugLevel)
+	}
+	traceHook = &debugTraceHook{}
+	logger.AddHook(traceHook)
+}
+
+func recordDirectorResponse(method, url string, resp *http.Response, body []byte) {
+	if !bugReportCapture.Load() || resp == nil {
+		return
+	}
+	directorRespLock.Lock()
+	defer directorRespLock.Unlock()
+	directorResponses = append(directorResponses, DirectorResponse{
+		Time:       time.Now(),
+		Method:     method,
+		URL:        url,
+		StatusCode: resp.StatusCode,
+		Headers:    resp.Header.Clone(),
+		Body:       string(body),
+	})
+	if len(directorResponses) > maxDirectorResponses {
+		directorResponses = directorResponses[len(directorResponses)-maxDirectorResponses:]
+	}
+}
+
+// Reports whether a configuration key may hold a credential
+func isSensitiveKey(key string) bool {
+	lower := strings.ToLower(key)
+	for _, field := range sensitiveKeyFields {
+		if strings.Contains(lower, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// Replace the values of the credential-like keys in the configuration
+func redactConfig(settings map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(settings))
+	for key, value := range settings {
+		if nested, ok := value.(map[string]interface{}); ok {
+			result[key] = redactConfig(nested)
+		} else if isSensitiveKey(key) && value != nil && value != "" {
+			result[key] = "REDACTED"
+		} else if str, ok := value.(string); ok {
+			result[key] = config.RedactTokens(str)
+		} else {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// Returns the Pelican-related environment, with credentials redacted
+func getBugReportEnv() string {
+	lines := []string{}
+	for _, env := range os.Environ() {
+		name, value, _ := strings.Cut(env, "=")
+		upper := strings.ToUpper(name)
+		if !strings.HasPrefix(upper, "PELICAN_") && !strings.HasPrefix(upper, "OSDF_") && !strings.HasPrefix(upper, "STASH_") &&
+			!strings.HasPrefix(upper, "BEARER_TOKEN") && !strings.HasPrefix(upper, "X509_") {
+			continue
+		}
+		if isSensitiveKey(name) {
+			value = "REDACTED"
+		}
+		lines = append(lines, name+"="+config.RedactTokens(value))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Returns up to the last maxBytes of a file
+func tailFile(name string, maxBytes int64) ([]byte, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxBytes {
+		if _, err := file.Seek(-maxBytes, io.SeekEnd); err != nil {
+			return nil, err
+		}
+	}
+	return io.ReadAll(file)
+}
+
+func addTarFile(tw *tar.Writer, name string, contents []byte) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(contents)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(contents)
+	return err
+}
+
+// Write a gzip-compressed tarball with the client's version, redacted configuration and
+// environment, recent logs, and, if the capture was started, the debug trace and the
+// director responses
+func WriteBugReport(w io.Writer, opts BugReportOptions) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	add := func(name string, contents []byte) error {
+		return errors.Wrapf(addTarFile(tw, name, contents), "failed to add %s to the bug report", name)
+	}
+
+	version := fmt.Sprintf("Pelican version: %s\nBuild commit: %s\nGo version: %s\nOS/Arch: %s/%s\nGenerated: %s\n",
+		config.GetVersion(), config.GetBuiltCommit(), runtime.Version(), runtime.GOOS, runtime.GOARCH, time.Now().Format(time.RFC3339))
+	if len(opts.Args) > 0 {
+		version += "Command: " + config.RedactTokens(strings.Join(opts.Args, " ")) + "\n"
+	}
+	if err := add("version.txt", []byte(version)); err != nil {
+		return err
+	}
+
+	if opts.Error != nil {
+		errMsg := opts.Error.Error()
+		var te *TransferErrors
+		if errors.As(opts.Error, &te) {
+			errMsg = te.UserError() + "\n\n" + errMsg
+		}
+		if err := add("error.txt", []byte(config.RedactTokens(errMsg)+"\n")); err != nil {
+			return err
+		}
+	}
+
+	cfg, err := yaml.Marshal(redactConfig(viper.AllSettings()))
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal the client configuration")
+	}
+	if err := add("config.yaml", cfg); err != nil {
+		return err
+	}
+	if err := add("environment.txt", []byte(getBugReportEnv())); err != nil {
+		return err
+	}
+
+	logFile := opts.LogFile
+	if logFile == "" {
+		logFile = viper.GetString("Logging.LogLocation")
+	}
+	if logFile != "" {
+		if contents, err := tailFile(logFile, maxBugReportLogBytes); err != nil {
+			log.Warningf("Unable to include the log file %s in the bug report: %v", logFile, err)
+		} else if err := add("pelican.log", []byte(config.RedactTokens(string(contents)))); err != nil {
+			return err
+		}
+	}
+
+	if bugReportCapture.Load() && traceHook != nil {
+		if err := add("debug-trace.log", []byte(config.RedactTokens(traceHook.contents()))); err != nil {
+			return err
+		}
+	}
+
+	responses := bytes.Buffer{}
+	encoder := json.NewEncoder(&responses)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	directorRespLock.Lock()
+	err = encoder.Encode(directorResponses)
+	directorRespLock.Unlock()
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal the director responses")
+	}
+	if err := add("director-responses.json", []byte(config.RedactTokens(responses.String()))); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Write the bug report to a new file; if path is empty, a timestamped name in the
+// current directory is used.  Returns the name of the file written.
+func CreateBugReport(path string, opts BugReportOptions) (string, error) {
+	if path == "" {
+		path = "pelican-bug-report-" + time.Now().Format("20060102-150405") + ".tar.gz"
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create the bug report")
+	}
+	if err := WriteBugReport(file, opts); err != nil {
+		file.Close()
+		return "", err
+	}
+	return path, errors.Wrap(file.Close(), "failed to write the bug report")
+}
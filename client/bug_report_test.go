@@ -0,0 +1,135 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// Returns the contents of each file in a bug report
+func readBugReport(t *testing.T, report io.Reader) map[string]string {
+	gz, err := gzip.NewReader(report)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+	files := map[string]string{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		contents, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[hdr.Name] = string(contents)
+	}
+	return files
+}
+
+func TestDebugTraceHook(t *testing.T) {
+	hook := &debugTraceHook{}
+	for i := 0; i < maxTraceEntries+5; i++ {
+		require.NoError(t, hook.Fire(&log.Entry{Logger: log.StandardLogger(), Level: log.DebugLevel, Message: "entry " + strconv.Itoa(i)}))
+	}
+	contents := hook.contents()
+	// The oldest entries were dropped and the rest are in order
+	assert.NotContains(t, contents, "msg=\"entry 4\"")
+	assert.Less(t, bytes.Index([]byte(contents), []byte("msg=\"entry 5\"")), bytes.Index([]byte(contents), []byte("msg=\"entry 6\"")))
+	assert.Contains(t, contents, "msg=\"entry "+strconv.Itoa(maxTraceEntries+4)+"\"")
+}
+
+func TestLevelFilterFormatter(t *testing.T) {
+	formatter := &levelFilterFormatter{Formatter: &log.TextFormatter{}, level: log.WarnLevel}
+	out, err := formatter.Format(&log.Entry{Logger: log.StandardLogger(), Level: log.DebugLevel, Message: "hidden"})
+	require.NoError(t, err)
+	assert.Empty(t, out)
+	out, err = formatter.Format(&log.Entry{Logger: log.StandardLogger(), Level: log.ErrorLevel, Message: "shown"})
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "shown")
+}
+
+func TestWriteBugReport(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(func() {
+		viper.Reset()
+		bugReportCapture.Store(false)
+		directorResponses = nil
+	})
+	jwt := "eyJhbGciOiJFUzI1NiIsInR5cCI6IkpXVCJ9.eyJzdWIiOiJ0ZXN0LXVzZXItbmFtZSJ9." + string(bytes.Repeat([]byte("a"), 86))
+	viper.Set("Federation.DiscoveryUrl", "https://fed.example.com")
+	viper.Set("Server.UIPassword", "hunter2")
+	viper.Set("Client.MaximumDownloadSpeed", 100)
+	t.Setenv("BEARER_TOKEN", "secret-token")
+	t.Setenv("PELICAN_FEDERATION_DISCOVERYURL", "https://fed.example.com")
+
+	logFile := filepath.Join(t.TempDir(), "pelican.log")
+	require.NoError(t, os.WriteFile(logFile, []byte("Using token "+jwt+"\n"), 0644))
+
+	bugReportCapture.Store(true)
+	resp := &http.Response{StatusCode: http.StatusTemporaryRedirect, Header: http.Header{"Location": []string{"https://cache.example.com/foo?a=1&b=2"}}}
+	recordDirectorResponse("GET", "https://director.example.com/foo", resp, []byte("redirecting"))
+
+	buf := bytes.Buffer{}
+	require.NoError(t, WriteBugReport(&buf, BugReportOptions{
+		Error:   errors.New("transfer failed"),
+		LogFile: logFile,
+		Args:    []string{"pelican", "object", "get", "/foo", "bar"},
+	}))
+	files := readBugReport(t, &buf)
+
+	assert.Contains(t, files["version.txt"], "Pelican version:")
+	assert.Contains(t, files["version.txt"], "Command: pelican object get /foo bar")
+	assert.Equal(t, "transfer failed\n", files["error.txt"])
+
+	cfg := map[string]map[string]interface{}{}
+	require.NoError(t, yaml.Unmarshal([]byte(files["config.yaml"]), &cfg))
+	assert.Equal(t, "https://fed.example.com", cfg["federation"]["discoveryurl"])
+	assert.Equal(t, "REDACTED", cfg["server"]["uipassword"])
+	assert.Equal(t, 100, cfg["client"]["maximumdownloadspeed"])
+
+	assert.Contains(t, files["environment.txt"], "BEARER_TOKEN=REDACTED")
+	assert.Contains(t, files["environment.txt"], "PELICAN_FEDERATION_DISCOVERYURL=https://fed.example.com")
+
+	// Token signatures are removed from the logs
+	assert.NotContains(t, files["pelican.log"], jwt)
+	assert.Contains(t, files["pelican.log"], ".REDACTED")
+
+	responses := []DirectorResponse{}
+	require.NoError(t, json.Unmarshal([]byte(files["director-responses.json"]), &responses))
+	require.Len(t, responses, 1)
+	assert.Equal(t, http.StatusTemporaryRedirect, responses[0].StatusCode)
+	assert.Equal(t, "https://cache.example.com/foo?a=1&b=2", responses[0].Headers["Location"][0])
+	assert.Equal(t, "redirecting", responses[0].Body)
+	assert.Contains(t, files["director-responses.json"], "a=1&b=2")
+}
@@ -51,6 +51,8 @@ func CreateNsFromDirectorResp(dirResp *http.Response) (namespace namespaces.Name
 	namespace.UseTokenOnRead, _ = strconv.ParseBool(xPelicanNamespace["require-token"])
 	namespace.ReadHTTPS, _ = strconv.ParseBool(xPelicanNamespace["readhttps"])
 	namespace.DirListHost = xPelicanNamespace["collections-url"]
+	namespace.TapeBacked, _ = strconv.ParseBool(xPelicanNamespace["tape-backed"])
+	namespace.StageUrl = dirResp.Header.Get(server_structs.TapeStageUrlHeader)
 
 	xPelicanAuthorization := []string{} // map of header to x - single entry - want to create an array for issuer
 	if len(dirResp.Header.Values("X-Pelican-Authorization")) > 0 {
@@ -133,6 +135,10 @@ func queryDirector(ctx context.Context, verb, sourcePath, directorUrl string) (r
 	// if it supports the version, and provide an error message in the case that it
 	// cannot.
 	req.Header.Set("User-Agent", getUserAgent(""))
+	// Tell the director which features we support so it doesn't send us to servers we can't use.
+	// The client unpacks packed downloads itself but, using net/http, can't speak HTTP/3.
+	clientCapabilities := server_structs.ClientCapabilities{Version: config.GetVersion(), Features: server_structs.FeaturePack}
+	req.Header.Set(server_structs.ClientCapabilitiesHeader, clientCapabilities.String())
 
 	// Perform the HTTP request
 	resp, err = client.Do(req)
@@ -146,6 +152,7 @@ func queryDirector(ctx context.Context, verb, sourcePath, directorUrl string) (r
 	log.Tracef("Director's response: %#v\n", resp)
 	// Check HTTP response -- should be 307 (redirect), else something went wrong
 	body, _ := io.ReadAll(resp.Body)
+	recordDirectorResponse(verb, resourceUrl, resp, body)
 
 	// If we get a 404, the director will hopefully tell us why. It might be that the namespace doesn't exist
 	if resp.StatusCode == 404 && verb == "PROPFIND" {
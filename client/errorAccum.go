@@ -148,6 +148,10 @@ func IsRetryable(err error) bool {
 	if errors.Is(err, &StoppedTransferError{}) {
 		return true
 	}
+	if errors.Is(err, &StagingError{}) {
+		// The object will be online once the origin finishes staging it from tape
+		return true
+	}
 	if errors.Is(err, &allocateMemoryError{}) {
 		return true
 	}
@@ -156,6 +160,10 @@ func IsRetryable(err error) bool {
 		// that enables dirlistings or the admin must enable dirlistings on the origin/namespace
 		return false
 	}
+	if errors.Is(err, &untrustedServerError{}) {
+		// false because retrying cannot add the server to the federation's server list
+		return false
+	}
 	var cse *ConnectionSetupError
 	if errors.As(err, &cse) {
 		if sce, ok := cse.Unwrap().(grab.StatusCodeError); ok {
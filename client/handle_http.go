@@ -56,6 +56,7 @@ import (
 	"github.com/pelicanplatform/pelican/error_codes"
 	"github.com/pelicanplatform/pelican/namespaces"
 	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
 )
 
 var (
@@ -123,6 +124,18 @@ type (
 
 	HeaderTimeoutError struct{}
 
+	// DeadlineExceededError is returned when a transfer didn't complete within its wall-clock
+	// deadline, set per transfer by WithTransferTimeout or for a batch of transfers by WithDeadline
+	DeadlineExceededError struct {
+		Timeout  time.Duration // The per-transfer timeout; zero if the batch deadline passed
+		Deadline time.Time     // The batch deadline; zero if the per-transfer timeout passed
+	}
+
+	// The origin is staging the object from tape and asked the client to retry later
+	StagingError struct {
+		RetryAfter time.Duration
+	}
+
 	allocateMemoryError struct {
 		Err error
 	}
@@ -168,6 +181,7 @@ type (
 		CacheAge          time.Duration // age of the data reported by the cache
 		Endpoint          string        // which origin did it use
 		ServerVersion     string        // version of the server
+		SlowAbandoned     bool          // whether the attempt was abandoned for transferring below Client.MinimumDownloadSpeed
 		Error             error         // what error the attempt returned (if any)
 	}
 
@@ -211,6 +225,11 @@ type (
 		attempts   []transferAttemptDetails
 		project    string
 		err        error
+		// Set if the object was discovered via a directory listing and its
+		// size is at or below Client.SmallFileThreshold
+		smallObject bool
+		// Size of the object from the directory listing (only valid if smallObject is set)
+		listedSize int64
 	}
 
 	// A representation of a "transfer job".  The job
@@ -238,8 +257,22 @@ type (
 		directorUrl   string
 		tokenLocation string
 		token         string
+		resume        bool
+		writeBack     bool
+		cacheOnly     bool
+		originOnly    bool
+		xferTimeout   time.Duration
+		deadline      time.Time
+		includes      []string
+		excludes      []string
+		filter        *transferFilter
 		project       string
 		namespace     namespaces.Namespace
+		// Cache ordering shared by all the small objects in the job; computed
+		// once by the first small object to be downloaded, even if the probe fails.
+		smallAttemptsLock   sync.Mutex
+		smallAttemptsSorted bool
+		smallAttempts       []transferAttemptDetails
 	}
 
 	// A TransferJob associated with a client's request
@@ -287,9 +320,17 @@ type (
 		cancel        context.CancelFunc
 		callback      TransferCallbackFunc
 		engine        *TransferEngine
-		skipAcquire   bool   // Enable/disable the token acquisition logic.  Defaults to acquiring a token
-		tokenLocation string // Location of a token file to use for transfers
-		token         string // Token that should be used for transfers
+		skipAcquire   bool          // Enable/disable the token acquisition logic.  Defaults to acquiring a token
+		tokenLocation string        // Location of a token file to use for transfers
+		token         string        // Token that should be used for transfers
+		resume        bool          // Resume interrupted chunked uploads found in the upload journal
+		writeBack     bool          // Upload to a nearby cache, which writes the objects to the origin later
+		cacheOnly     bool          // Download only from caches, never falling back to the origin
+		originOnly    bool          // Download directly from the origin, bypassing the caches
+		xferTimeout   time.Duration // Wall-clock limit of each transfer, including its retries
+		deadline      time.Time     // Wall-clock deadline of each job's transfers
+		includes      []string      // Patterns of the files recursive transfers include
+		excludes      []string      // Patterns of the files recursive transfers exclude
 		work          chan *TransferJob
 		closed        bool
 		caches        []*url.URL
@@ -304,6 +345,14 @@ type (
 	identTransferOptionTokenLocation struct{}
 	identTransferOptionAcquireToken  struct{}
 	identTransferOptionToken         struct{}
+	identTransferOptionResume        struct{}
+	identTransferOptionWriteBack     struct{}
+	identTransferOptionCacheOnly     struct{}
+	identTransferOptionOriginOnly    struct{}
+	identTransferOptionTimeout       struct{}
+	identTransferOptionDeadline      struct{}
+	identTransferOptionInclude       struct{}
+	identTransferOptionExclude       struct{}
 
 	transferDetailsOptions struct {
 		NeedsToken bool
@@ -337,6 +386,53 @@ func (e *HeaderTimeoutError) Error() string {
 	return "timeout waiting for HTTP response (TCP connection successful)"
 }
 
+func (e *DeadlineExceededError) Error() string {
+	if e.Timeout > 0 {
+		return "transfer did not complete within its timeout of " + e.Timeout.String()
+	}
+	return "transfers did not complete by their deadline of " + e.Deadline.Format(time.RFC3339)
+}
+
+func (e *DeadlineExceededError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+func (e *DeadlineExceededError) Is(target error) bool {
+	_, ok := target.(*DeadlineExceededError)
+	return ok
+}
+
+// If the context was cancelled because a transfer deadline passed, replace the error
+// it caused with the dedicated timeout error
+func getDeadlineError(ctx context.Context, err error) error {
+	var deadlineErr *DeadlineExceededError
+	if err != nil && errors.As(context.Cause(ctx), &deadlineErr) {
+		return error_codes.NewTransfer_TimedOutError(deadlineErr)
+	}
+	return err
+}
+
+func (e *StagingError) Error() string {
+	if e.RetryAfter <= 0 {
+		return "the object is being staged from tape"
+	}
+	return "the object is being staged from tape; retry after " + e.RetryAfter.String()
+}
+
+func (e *StagingError) Is(target error) bool {
+	_, ok := target.(*StagingError)
+	return ok
+}
+
+// Parse the Retry-After header of a response, given in seconds; HTTP dates are not
+// used by origins and fall back to the default
+func parseRetryAfter(header string, defaultDelay time.Duration) time.Duration {
+	if seconds, err := strconv.Atoi(strings.TrimSpace(header)); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultDelay
+}
+
 func (e *StoppedTransferError) Error() (errMsg string) {
 	if e.StoppedTime > 0 {
 		errMsg = "no progress for more than " + e.StoppedTime.Truncate(time.Millisecond).String()
@@ -729,6 +825,96 @@ func WithAcquireToken(enable bool) TransferOption {
 	return option.New(identTransferOptionAcquireToken{}, enable)
 }
 
+// Create an option to resume interrupted uploads
+//
+// Large files are uploaded in chunks to origins supporting resumable
+// uploads.  With this option, an upload interrupted in a previous run
+// continues from the last chunk the origin acknowledged instead of
+// starting over.
+func WithResume(enable bool) TransferOption {
+	return option.New(identTransferOptionResume{}, enable)
+}
+
+// Create an option to upload via a write-back cache
+//
+// The director redirects the upload to the nearest cache accepting
+// write-back uploads, which acknowledges the upload once it's on the
+// cache's disk and writes it to the origin later.  The upload is only
+// eventually visible at the origin; if no such cache is available,
+// the upload goes directly to the origin.
+func WithWriteBack(enable bool) TransferOption {
+	return option.New(identTransferOptionWriteBack{}, enable)
+}
+
+// Create an option to only download from caches
+//
+// When the director finds no cache for an object, it normally sends
+// the client to an origin allowing direct reads.  With this option,
+// the download fails instead, protecting origins that can't take the
+// load of direct client reads.  It can't be combined with WithOriginOnly
+// and is ignored by uploads.
+func WithCacheOnly(enable bool) TransferOption {
+	return option.New(identTransferOptionCacheOnly{}, enable)
+}
+
+// Create an option to download directly from the origin
+//
+// The download bypasses the caches, which may serve a stale copy of an
+// object that changed at the origin, as with the `?directread` query.
+// The namespace's origins must allow direct reads.  It requires a
+// director, can't be combined with WithCacheOnly or WithCaches, and is
+// ignored by uploads.
+func WithOriginOnly(enable bool) TransferOption {
+	return option.New(identTransferOptionOriginOnly{}, enable)
+}
+
+// Create an option to limit the wall-clock time of each transfer
+//
+// The limit covers all the attempts to transfer an object, from when
+// a worker starts it; once it passes, the in-flight requests are
+// cancelled and the transfer fails with a DeadlineExceededError.
+// Unlike the idle and slow-transfer timeouts, it applies even if the
+// transfer is progressing.  A zero timeout disables the limit.
+func WithTransferTimeout(timeout time.Duration) TransferOption {
+	return option.New(identTransferOptionTimeout{}, timeout)
+}
+
+// Create an option to set a wall-clock deadline for a batch of transfers
+//
+// The deadline covers everything a job does, from looking up its
+// namespace to transferring every object it contains; the transfers
+// still in progress or queued when it passes fail with a
+// DeadlineExceededError.  Jobs given the same deadline share it, so
+// it bounds the whole batch.  A zero time disables the deadline.
+func WithDeadline(deadline time.Time) TransferOption {
+	return option.New(identTransferOptionDeadline{}, deadline)
+}
+
+// Create an option to only transfer the files of a recursive transfer
+// matching one of the glob patterns
+//
+// Patterns are matched rsync-style against the paths relative to the
+// directory being transferred: a pattern without a '/' (e.g., `*.root`)
+// matches the file name, a pattern with a '/' matches the end of the
+// path, or all of it if it starts with '/', and '**' matches any number
+// of directories.  A pattern ending in '/' matches directories and
+// includes all their contents.  Files matching an include pattern are
+// transferred even if they match an exclude pattern.
+func WithIncludes(patterns ...string) TransferOption {
+	return option.New(identTransferOptionInclude{}, patterns)
+}
+
+// Create an option to skip the files of a recursive transfer matching
+// one of the glob patterns
+//
+// Patterns are matched as with WithIncludes; a pattern ending in '/'
+// skips the matching directories without listing them.  For downloads,
+// the patterns are evaluated against the remote directory listings, so
+// the skipped objects are never requested.
+func WithExcludes(patterns ...string) TransferOption {
+	return option.New(identTransferOptionExclude{}, patterns)
+}
+
 // Create a new client to work with an engine
 func (te *TransferEngine) NewClient(options ...TransferOption) (client *TransferClient, err error) {
 	log.Debugln("Making new clients")
@@ -757,6 +943,22 @@ func (te *TransferEngine) NewClient(options ...TransferOption) (client *Transfer
 			client.skipAcquire = !option.Value().(bool)
 		case identTransferOptionToken{}:
 			client.token = option.Value().(string)
+		case identTransferOptionResume{}:
+			client.resume = option.Value().(bool)
+		case identTransferOptionWriteBack{}:
+			client.writeBack = option.Value().(bool)
+		case identTransferOptionCacheOnly{}:
+			client.cacheOnly = option.Value().(bool)
+		case identTransferOptionOriginOnly{}:
+			client.originOnly = option.Value().(bool)
+		case identTransferOptionTimeout{}:
+			client.xferTimeout = option.Value().(time.Duration)
+		case identTransferOptionDeadline{}:
+			client.deadline = option.Value().(time.Time)
+		case identTransferOptionInclude{}:
+			client.includes = option.Value().([]string)
+		case identTransferOptionExclude{}:
+			client.excludes = option.Value().([]string)
 		}
 	}
 	func() {
@@ -1055,11 +1257,11 @@ func (te *TransferEngine) runJobHandler() error {
 				close(te.files)
 				return nil
 			}
-			if job.job.ctx.Err() == context.Canceled {
-				job.job.lookupErr = job.job.ctx.Err()
+			if job.job.ctx.Err() != nil {
+				job.job.lookupErr = getDeadlineError(job.job.ctx, job.job.ctx.Err())
 			} else {
 				err := te.createTransferFiles(job)
-				job.job.lookupErr = err
+				job.job.lookupErr = getDeadlineError(job.job.ctx, err)
 			}
 			te.jobLookupDone <- job
 		}
@@ -1098,6 +1300,14 @@ func (tc *TransferClient) NewTransferJob(ctx context.Context, remoteUrl *url.URL
 		upload:        upload,
 		uuid:          id,
 		token:         tc.token,
+		resume:        tc.resume,
+		writeBack:     tc.writeBack,
+		cacheOnly:     tc.cacheOnly,
+		originOnly:    tc.originOnly,
+		xferTimeout:   tc.xferTimeout,
+		deadline:      tc.deadline,
+		includes:      tc.includes,
+		excludes:      tc.excludes,
 		project:       project,
 	}
 
@@ -1126,6 +1336,37 @@ func (tc *TransferClient) NewTransferJob(ctx context.Context, remoteUrl *url.URL
 			tj.skipAcquire = !option.Value().(bool)
 		case identTransferOptionToken{}:
 			tj.token = option.Value().(string)
+		case identTransferOptionResume{}:
+			tj.resume = option.Value().(bool)
+		case identTransferOptionWriteBack{}:
+			tj.writeBack = option.Value().(bool)
+		case identTransferOptionCacheOnly{}:
+			tj.cacheOnly = option.Value().(bool)
+		case identTransferOptionOriginOnly{}:
+			tj.originOnly = option.Value().(bool)
+		case identTransferOptionTimeout{}:
+			tj.xferTimeout = option.Value().(time.Duration)
+		case identTransferOptionDeadline{}:
+			tj.deadline = option.Value().(time.Time)
+		case identTransferOptionInclude{}:
+			tj.includes = option.Value().([]string)
+		case identTransferOptionExclude{}:
+			tj.excludes = option.Value().([]string)
+		}
+	}
+
+	if tj.filter, err = newTransferFilter(tj.includes, tj.excludes); err != nil {
+		tj.cancel()
+		return nil, err
+	}
+
+	if !tj.deadline.IsZero() {
+		cancelMerged := tj.cancel
+		var cancelDeadline context.CancelFunc
+		tj.ctx, cancelDeadline = context.WithDeadlineCause(tj.ctx, tj.deadline, &DeadlineExceededError{Deadline: tj.deadline})
+		tj.cancel = func() {
+			cancelDeadline()
+			cancelMerged()
 		}
 	}
 
@@ -1133,10 +1374,26 @@ func (tc *TransferClient) NewTransferJob(ctx context.Context, remoteUrl *url.URL
 		tj.useDirector = true
 		tj.directorUrl = pelicanURL.directorUrl
 	}
-	ns, err := getNamespaceInfo(tj.ctx, remoteUrl.Path, pelicanURL.directorUrl, upload, remoteUrl.RawQuery)
+	if !upload {
+		if err = tj.checkSourceMode(remoteUrl); err != nil {
+			tj.cancel()
+			return nil, err
+		}
+	}
+	query := remoteUrl.RawQuery
+	if upload && tj.writeBack && !remoteUrl.Query().Has("writeback") {
+		query = strings.TrimPrefix(query+"&writeback", "&")
+	}
+	// Tell the director which sources the download may use
+	if !upload && tj.originOnly && !remoteUrl.Query().Has("directread") {
+		query = strings.TrimPrefix(query+"&directread", "&")
+	} else if !upload && tj.cacheOnly {
+		query = strings.TrimPrefix(query+"&cacheonly", "&")
+	}
+	ns, err := getNamespaceInfo(tj.ctx, remoteUrl.Path, pelicanURL.directorUrl, upload, query)
 	if err != nil {
 		log.Errorln(err)
-		err = errors.Wrapf(err, "failed to get namespace information for remote URL %s", remoteUrl.String())
+		err = getDeadlineError(tj.ctx, errors.Wrapf(err, "failed to get namespace information for remote URL %s", remoteUrl.String()))
 		return
 	}
 	tj.namespace = ns
@@ -1199,6 +1456,26 @@ func (tc *TransferClient) NewTransferJob(ctx context.Context, remoteUrl *url.URL
 	return
 }
 
+// Check the job's cache-only and origin-only modes are consistent with each other and
+// with the other ways of picking the sources of a download
+func (tj *TransferJob) checkSourceMode(remoteUrl *url.URL) error {
+	if tj.cacheOnly && tj.originOnly {
+		return errors.New("a download can't be both cache-only and origin-only")
+	}
+	if tj.cacheOnly && remoteUrl.Query().Has("directread") {
+		return errors.New("a cache-only download can't use the directread query")
+	}
+	if tj.originOnly {
+		if len(tj.caches) > 0 {
+			return errors.New("an origin-only download can't use preferred caches")
+		}
+		if !tj.useDirector {
+			return errors.New("an origin-only download requires a federation with a director")
+		}
+	}
+	return nil
+}
+
 // Returns the status of the transfer job-to-file(s) lookup
 //
 // ok is true if the lookup has completed.
@@ -1530,12 +1807,12 @@ func runTransferWorker(ctx context.Context, workChan <-chan *clientTransferFile,
 					return nil
 				}
 			}
-			if file.file.ctx.Err() == context.Canceled {
+			if file.file.ctx.Err() != nil {
 				results <- &clientTransferResults{
 					id: file.uuid,
 					results: TransferResults{
 						jobId: file.jobId,
-						Error: file.file.ctx.Err(),
+						Error: getDeadlineError(file.file.ctx, file.file.ctx.Err()),
 					},
 				}
 				break
@@ -1550,6 +1827,21 @@ func runTransferWorker(ctx context.Context, workChan <-chan *clientTransferFile,
 				}
 				break
 			}
+			if err := runPreTransferHooks(file.file); err != nil {
+				results <- &clientTransferResults{
+					id: file.uuid,
+					results: TransferResults{
+						jobId: file.jobId,
+						Error: err,
+					},
+				}
+				break
+			}
+			cancelTimeout := func() {}
+			if file.file.job != nil && file.file.job.xferTimeout > 0 {
+				timeout := file.file.job.xferTimeout
+				file.file.ctx, cancelTimeout = context.WithTimeoutCause(file.file.ctx, timeout, &DeadlineExceededError{Timeout: timeout})
+			}
 			var err error
 			var transferResults TransferResults
 			if file.file.upload {
@@ -1565,6 +1857,9 @@ func runTransferWorker(ctx context.Context, workChan <-chan *clientTransferFile,
 				transferResults.Scheme = file.file.remoteURL.Scheme
 				transferResults.Error = err
 			}
+			transferResults.Error = getDeadlineError(file.file.ctx, transferResults.Error)
+			cancelTimeout()
+			runPostTransferHooks(file.file, transferResults)
 			results <- &clientTransferResults{id: file.uuid, results: transferResults}
 		}
 	}
@@ -1720,6 +2015,28 @@ func sortAttempts(ctx context.Context, path string, attempts []transferAttemptDe
 	return
 }
 
+// Determine the order in which the caches should be attempted for a transfer
+//
+// For most objects, this probes all the candidate caches (see sortAttempts).  When
+// a recursive job consists of many small objects, probing every cache before each
+// object can take longer than the transfer itself; instead, the first small object
+// in the job performs the probe and the resulting ordering is reused for the remainder.
+// Connections to the chosen cache are then reused (or multiplexed, if HTTP/2 is
+// negotiated) by the shared transport.
+func (tj *TransferJob) sortAttemptsForFile(transfer *transferFile) (size int64, attempts []transferAttemptDetails) {
+	if !transfer.smallObject {
+		return sortAttempts(tj.ctx, transfer.remoteURL.Path, transfer.attempts)
+	}
+
+	tj.smallAttemptsLock.Lock()
+	defer tj.smallAttemptsLock.Unlock()
+	if !tj.smallAttemptsSorted {
+		_, tj.smallAttempts = sortAttempts(tj.ctx, transfer.remoteURL.Path, transfer.attempts)
+		tj.smallAttemptsSorted = true
+	}
+	return transfer.listedSize, tj.smallAttempts
+}
+
 // Download the object specified in the transfer to the local filesystem
 //
 // transferResults contains the summary of the multiple attempts.
@@ -1734,18 +2051,64 @@ func downloadObject(transfer *transferFile) (transferResults TransferResults, er
 		return
 	}
 
-	size, attempts := sortAttempts(transfer.job.ctx, transfer.remoteURL.Path, transfer.attempts)
+	size, attempts := transfer.job.sortAttemptsForFile(transfer)
 
 	transferResults = newTransferResults(transfer.job)
+	if stageErr := stageFromTape(transfer); stageErr != nil {
+		transferResults.Error = stageErr
+		return
+	}
 	xferErrors := NewTransferErrors()
 	success := false
 	// transferStartTime is the start time of the last transfer attempt
 	// we create a var here and update it in the loop
 	var transferStartTime time.Time
+
+	// For large objects available from several caches, try fetching ranges from
+	// multiple caches at once before falling back to one cache at a time.
+	if sources := getMultiSourceAttempts(transfer, size, attempts); sources != nil {
+		transferStartTime = time.Now()
+		msDownloaded, sourceHealth, msErr := downloadMultiSource(transfer.ctx, transfer.callback, sources,
+			transfer.remoteURL.Path, transfer.localPath, size, transfer.token, transfer.project)
+		endTime := time.Now()
+		for idx, source := range sourceHealth {
+			attempt := TransferResult{
+				Number:            idx,
+				CacheAge:          -1,
+				Endpoint:          source.attempt.Url.Host,
+				TransferFileBytes: source.bytes.Load(),
+				TimeToFirstByte:   source.firstByte,
+				TransferEndTime:   endTime,
+				TransferTime:      endTime.Sub(transferStartTime),
+			}
+			if source.attempt.CacheQuery {
+				attempt.CacheAge = source.attempt.CacheAge
+			}
+			if source.lastErr != nil {
+				attempt.Error = newTransferAttemptError(attempt.Endpoint, "", false, false, source.lastErr)
+				xferErrors.AddPastError(attempt.Error, endTime)
+			}
+			transferResults.Attempts = append(transferResults.Attempts, attempt)
+		}
+		downloaded += msDownloaded
+		if msErr == nil {
+			log.Debugln("Downloaded bytes:", downloaded)
+			transferResults.TransferStartTime = transferStartTime
+			transferResults.TransferredBytes = downloaded
+			return
+		}
+		log.Warningln("Multi-source download failed; falling back to downloading from one cache at a time:", msErr)
+		// The partially-written object would otherwise look complete to the resuming downloader
+		if rmErr := os.Remove(transfer.localPath); rmErr != nil && !errors.Is(rmErr, os.ErrNotExist) {
+			err = errors.Wrap(rmErr, "failed to remove partial multi-source download")
+			return
+		}
+	}
+
 	for idx, transferEndpoint := range attempts { // For each transfer attempt (usually 3), try to download via HTTP
 		var attempt TransferResult
 		attempt.CacheAge = -1
-		attempt.Number = idx // Start with 0
+		attempt.Number = len(transferResults.Attempts) // Start with 0
 		attempt.Endpoint = transferEndpoint.Url.Host
 		if transferEndpoint.CacheQuery {
 			attempt.CacheAge = transferEndpoint.CacheAge
@@ -1759,6 +2122,10 @@ func downloadObject(transfer *transferFile) (transferResults TransferResults, er
 		attemptDownloaded, timeToFirstByte, cacheAge, serverVersion, err := downloadHTTP(
 			transfer.ctx, transfer.engine, transfer.callback, transferEndpoint, transfer.localPath, size, transfer.token, transfer.project,
 		)
+		// Wait for objects being staged from tape and retry the same server
+		if stagingErr := (&StagingError{}); errors.As(err, &stagingErr) {
+			attemptDownloaded, timeToFirstByte, cacheAge, serverVersion, err = waitForStaging(transfer, transferEndpoint, size, stagingErr)
+		}
 		endTime := time.Now()
 		if cacheAge >= 0 {
 			attempt.CacheAge = cacheAge
@@ -1772,6 +2139,14 @@ func downloadObject(transfer *transferFile) (transferResults TransferResults, er
 
 		if err != nil {
 			log.Debugln("Failed to download from", transferEndpoint.Url, ":", err)
+			// A source that stays too slow for too long is given up on like a failed one, so the
+			// transfer continues from the next source instead of crawling along on a degraded cache
+			if errors.Is(err, &SlowTransferError{}) {
+				attempt.SlowAbandoned = true
+				if idx+1 < len(attempts) {
+					log.Warningf("Abandoning the download from %s because it's too slow; trying the next source", attempt.Endpoint)
+				}
+			}
 			var ope *net.OpError
 			var cse *ConnectionSetupError
 			proxyStr, _ := os.LookupEnv("http_proxy")
@@ -1821,6 +2196,40 @@ func downloadObject(transfer *transferFile) (transferResults TransferResults, er
 	return
 }
 
+// Retry a download while the origin stages the object from tape, waiting as long as the
+// origin asks each time, until the object is online or Client.TapeStageTimeout passes
+func waitForStaging(transfer *transferFile, transferEndpoint transferAttemptDetails, size int64, stagingErr *StagingError) (downloaded int64, timeToFirstByte time.Duration, cacheAge time.Duration, serverVersion string, err error) {
+	if !param.Client_WaitForTapeStaging.GetBool() {
+		err = error_codes.NewTransfer_StagingError(stagingErr)
+		return
+	}
+	deadline := time.Now().Add(param.Client_TapeStageTimeout.GetDuration())
+	err = stagingErr
+	for errors.As(err, &stagingErr) {
+		delay := stagingErr.RetryAfter
+		if delay < time.Second {
+			delay = time.Second
+		}
+		if remaining := time.Until(deadline); remaining <= 0 {
+			err = error_codes.NewTransfer_StagingError(errors.Wrapf(err, "the object was not staged from tape within %s", param.Client_TapeStageTimeout.GetDuration()))
+			return
+		} else if delay > remaining {
+			delay = remaining
+		}
+		log.Infof("%s is being staged from tape; retrying in %s", transfer.remoteURL.Path, delay)
+		select {
+		case <-transfer.ctx.Done():
+			err = transfer.ctx.Err()
+			return
+		case <-time.After(delay):
+		}
+		downloaded, timeToFirstByte, cacheAge, serverVersion, err = downloadHTTP(
+			transfer.ctx, transfer.engine, transfer.callback, transferEndpoint, transfer.localPath, size, transfer.token, transfer.project,
+		)
+	}
+	return
+}
+
 func parseTransferStatus(status string) (int, string) {
 	parts := strings.SplitN(status, ": ", 2)
 	if len(parts) != 2 {
@@ -1930,6 +2339,9 @@ func downloadHTTP(ctx context.Context, te *TransferEngine, callback TransferCall
 	}
 
 	if token != "" {
+		if err = checkServerAllowed(ctx, transfer.Url); err != nil {
+			return 0, 0, -1, "", err
+		}
 		req.HTTPRequest.Header.Set("Authorization", "Bearer "+token)
 	}
 	// Set the headers
@@ -1976,6 +2388,18 @@ func downloadHTTP(ctx context.Context, te *TransferEngine, callback TransferCall
 			return
 		}
 	}
+	// An origin staging the object from tape asks the client to come back later
+	if resp.HTTPResponse.StatusCode == http.StatusAccepted {
+		cancel()
+		<-resp.Done
+		if resp.Filename != "" && transfer.PackOption == "" {
+			if rmErr := os.Remove(resp.Filename); rmErr != nil && !errors.Is(rmErr, os.ErrNotExist) {
+				log.Warningln("Failed to remove the response of the staging origin:", rmErr)
+			}
+		}
+		err = &StagingError{RetryAfter: parseRetryAfter(resp.HTTPResponse.Header.Get("Retry-After"), time.Minute)}
+		return
+	}
 	serverVersion = resp.HTTPResponse.Header.Get("Server")
 
 	if ageStr := resp.HTTPResponse.Header.Get("Age"); ageStr != "" {
@@ -2214,6 +2638,9 @@ func (pr *ProgressReader) Size() int64 {
 // Upload a single object to the origin
 func uploadObject(transfer *transferFile) (transferResult TransferResults, err error) {
 	log.Debugln("Uploading file to destination", transfer.remoteURL)
+	if fileInfo, err := os.Stat(transfer.localPath); err == nil && useResumableUpload(transfer, fileInfo) {
+		return uploadResumable(transfer, fileInfo)
+	}
 	xferErrors := NewTransferErrors()
 	transferResult.job = transfer.job
 
@@ -2275,10 +2702,11 @@ func uploadObject(transfer *transferFile) (transferResult TransferResults, err e
 	// Parse the writeback host as a URL
 	writebackhostUrl := transfer.attempts[0].Url
 
+	// Write-back caches accept uploads under their write-back API
 	dest := &url.URL{
 		Host:   writebackhostUrl.Host,
 		Scheme: "https",
-		Path:   transfer.remoteURL.Path,
+		Path:   writebackhostUrl.Path + transfer.remoteURL.Path,
 	}
 	attempt.Endpoint = dest.Host
 	// Create the wrapped reader and send it to the request
@@ -2302,6 +2730,10 @@ func uploadObject(transfer *transferFile) (transferResult TransferResults, err e
 		transferResult.Error = err
 		return transferResult, err
 	}
+	if err = checkServerAllowed(putContext, dest); err != nil {
+		transferResult.Error = err
+		return transferResult, err
+	}
 	// Set the authorization header as well as other headers
 	request.Header.Set("Authorization", "Bearer "+transfer.token)
 	request.Header.Set("User-Agent", getUserAgent(transfer.project))
@@ -2365,6 +2797,11 @@ Loop:
 					response.StatusCode)}
 				break Loop
 			}
+			// A write-back cache acknowledges the upload before it reaches the origin
+			if statusPath := response.Header.Get(server_structs.WriteBackStatusHeader); statusPath != "" {
+				log.Infof("The cache accepted the upload of %s and will write it to the origin asynchronously; check https://%s%s to confirm it was written",
+					transfer.remoteURL.Path, dest.Host, statusPath)
+			}
 			break Loop
 
 		case err := <-errorChan:
@@ -2480,8 +2917,13 @@ func (te *TransferEngine) walkDirDownloadHelper(job *clientTransferJob, transfer
 		return errors.Wrap(err, "failed to read remote directory")
 	}
 	localBase := strings.TrimPrefix(remotePath, job.job.remoteURL.Path)
+	smallThreshold := int64(param.Client_SmallFileThreshold.GetInt())
 	for _, info := range infos {
 		newPath := remotePath + "/" + info.Name()
+		if job.job.filter.skip(path.Join(localBase, info.Name()), info.IsDir()) {
+			log.Debugln("Skipping", newPath, "excluded by the transfer's filters")
+			continue
+		}
 		if info.IsDir() {
 			err := te.walkDirDownloadHelper(job, transfers, files, newPath, client)
 			if err != nil {
@@ -2496,16 +2938,18 @@ func (te *TransferEngine) walkDirDownloadHelper(job *clientTransferJob, transfer
 				uuid:  job.uuid,
 				jobId: job.job.uuid,
 				file: &transferFile{
-					ctx:        job.job.ctx,
-					callback:   job.job.callback,
-					job:        job.job,
-					engine:     te,
-					remoteURL:  &url.URL{Path: newPath},
-					packOption: transfers[0].PackOption,
-					localPath:  path.Join(job.job.localPath, localBase, info.Name()),
-					upload:     job.job.upload,
-					token:      job.job.token,
-					attempts:   transfers,
+					ctx:         job.job.ctx,
+					callback:    job.job.callback,
+					job:         job.job,
+					engine:      te,
+					remoteURL:   &url.URL{Path: newPath},
+					packOption:  transfers[0].PackOption,
+					localPath:   path.Join(job.job.localPath, localBase, info.Name()),
+					upload:      job.job.upload,
+					token:       job.job.token,
+					attempts:    transfers,
+					smallObject: smallThreshold > 0 && info.Size() <= smallThreshold,
+					listedSize:  info.Size(),
 				},
 			}:
 			}
@@ -2528,6 +2972,10 @@ func (te *TransferEngine) walkDirUpload(job *clientTransferJob, transfers []tran
 
 	for _, info := range infos {
 		newPath := localPath + "/" + info.Name()
+		if job.job.filter.skip(strings.TrimPrefix(newPath, job.job.localPath), info.IsDir()) {
+			log.Debugln("Skipping", newPath, "excluded by the transfer's filters")
+			continue
+		}
 		if info.IsDir() {
 			// Recursively call this function to create any nested dir's as well as list their files
 			err := te.walkDirUpload(job, transfers, files, newPath)
@@ -2636,6 +3084,10 @@ func statHttp(ctx context.Context, dest *url.URL, namespace namespaces.Namespace
 				}
 
 				if token != "" {
+					if err = checkServerAllowed(ctx, endpoint); err != nil {
+						resultsChan <- statResults{0, err}
+						return
+					}
 					req.Header.Set("Authorization", "Bearer "+token)
 				}
 
@@ -2703,6 +3155,12 @@ func statHttp(ctx context.Context, dest *url.URL, namespace namespaces.Namespace
 	return
 }
 
+// Return the GlobalJobId of the HTCondor job the client runs in, or an empty string if it
+// doesn't run in a job
+func GetJobId() string {
+	return searchJobAd(jobId)
+}
+
 // This function searches the condor job ad for a specific classad and returns the value of that classad
 func searchJobAd(classad classAd) string {
 
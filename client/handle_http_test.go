@@ -24,6 +24,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io/fs"
 	"net"
 	"net/http"
@@ -34,6 +35,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -244,6 +246,66 @@ func TestSlowTransfers(t *testing.T) {
 	}
 }
 
+// A download that stays too slow is abandoned for the next source
+func TestSlowTransferSwitchesSource(t *testing.T) {
+	test_utils.InitClient(t, map[string]any{
+		"Client.SlowTransferWindow":     "1s",
+		"Client.SlowTransferRampupTime": "1s",
+	})
+
+	slowSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1024000")
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodHead {
+			return
+		}
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(500 * time.Millisecond):
+			}
+			_, _ = w.Write(make([]byte, 1024))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer slowSvr.Close()
+	fastSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1024000")
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodHead {
+			return
+		}
+		_, _ = w.Write(make([]byte, 1024000))
+	}))
+	defer fastSvr.Close()
+	slowUrl, err := url.Parse(slowSvr.URL)
+	require.NoError(t, err)
+	fastUrl, err := url.Parse(fastSvr.URL)
+	require.NoError(t, err)
+
+	localPath := filepath.Join(t.TempDir(), "test.txt")
+	transfer := &transferFile{
+		ctx:       context.Background(),
+		job:       &TransferJob{ctx: context.Background()},
+		localPath: localPath,
+		remoteURL: &url.URL{Path: "/test.txt"},
+		attempts:  []transferAttemptDetails{{Url: slowUrl}, {Url: fastUrl}},
+	}
+	transferResult, err := downloadObject(transfer)
+	require.NoError(t, err)
+	require.NoError(t, transferResult.Error)
+	require.Len(t, transferResult.Attempts, 2)
+	assert.Equal(t, slowUrl.Host, transferResult.Attempts[0].Endpoint)
+	assert.True(t, transferResult.Attempts[0].SlowAbandoned)
+	assert.True(t, errors.Is(transferResult.Attempts[0].Error, &SlowTransferError{}))
+	assert.False(t, transferResult.Attempts[1].SlowAbandoned)
+	assert.NoError(t, transferResult.Attempts[1].Error)
+	info, err := os.Stat(localPath)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1024000), info.Size())
+}
+
 // Test stopped transfer
 func TestStoppedTransfer(t *testing.T) {
 	os.Setenv("http_proxy", "http://proxy.edu:3128")
@@ -500,6 +562,76 @@ func TestSortAttempts(t *testing.T) {
 	assert.Equal(t, svr3.URL, results[1].Url.String())
 }
 
+// Test that small objects in a job only probe the caches once
+func TestSortAttemptsForSmallFiles(t *testing.T) {
+	ctx, cancel, _ := test_utils.TestContext(context.Background(), t)
+	defer cancel()
+
+	var probes atomic.Int64
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+		w.Header().Set("Content-Length", "42")
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte("A"))
+		require.NoError(t, err)
+	})
+	svr1 := httptest.NewServer(handler)
+	defer svr1.Close()
+	url1, err := url.Parse(svr1.URL)
+	require.NoError(t, err)
+	svr2 := httptest.NewServer(handler)
+	defer svr2.Close()
+	url2, err := url.Parse(svr2.URL)
+	require.NoError(t, err)
+	attempts := []transferAttemptDetails{{Url: url1}, {Url: url2}}
+
+	job := &TransferJob{ctx: ctx}
+	for idx := 0; idx < 5; idx++ {
+		transfer := &transferFile{
+			job:         job,
+			remoteURL:   &url.URL{Path: fmt.Sprintf("/path/%d", idx)},
+			attempts:    attempts,
+			smallObject: true,
+			listedSize:  10,
+		}
+		size, results := job.sortAttemptsForFile(transfer)
+		assert.Equal(t, int64(10), size)
+		require.Len(t, results, 2)
+	}
+	// Only the first small object should have queried the caches; the first cache
+	// responding causes the remaining probes to be canceled so allow for either count.
+	assert.LessOrEqual(t, probes.Load(), int64(2))
+	assert.GreaterOrEqual(t, probes.Load(), int64(1))
+
+	// Objects that aren't small always probe
+	probes.Store(0)
+	size, _ := job.sortAttemptsForFile(&transferFile{job: job, remoteURL: &url.URL{Path: "/path/big"}, attempts: attempts})
+	assert.Equal(t, int64(42), size)
+	assert.GreaterOrEqual(t, probes.Load(), int64(1))
+
+	// A probe that fails isn't repeated by the job's other small objects either
+	var failedProbes atomic.Int64
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failedProbes.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	failingUrl, err := url.Parse(failing.URL)
+	require.NoError(t, err)
+	failingAttempts := []transferAttemptDetails{{Url: failingUrl}, {Url: failingUrl}}
+	job = &TransferJob{ctx: ctx}
+	for idx := 0; idx < 5; idx++ {
+		_, results := job.sortAttemptsForFile(&transferFile{
+			job:         job,
+			remoteURL:   &url.URL{Path: fmt.Sprintf("/path/%d", idx)},
+			attempts:    failingAttempts,
+			smallObject: true,
+		})
+		require.Len(t, results, 2)
+	}
+	assert.Equal(t, int64(2), failedProbes.Load())
+}
+
 func TestTimeoutHeaderSetForDownload(t *testing.T) {
 	test_utils.InitClient(t, map[string]any{
 		"Transport.ResponseHeaderTimeout": 10 * time.Second,
@@ -949,6 +1081,155 @@ func TestGatewayTimeout(t *testing.T) {
 	}
 }
 
+// Test that downloads of objects being staged from tape are retried until they're online
+func TestTapeStaging(t *testing.T) {
+	test_utils.InitClient(t, map[string]any{
+		"Logging.Level":           "debug",
+		"Client.TapeStageTimeout": "10s",
+	})
+
+	requests := atomic.Int32{}
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		_, _ = w.Write([]byte("staged"))
+	}))
+	defer svr.Close()
+	svrURL, err := url.Parse(svr.URL)
+	require.NoError(t, err)
+
+	localPath := filepath.Join(t.TempDir(), "test.txt")
+	transfer := &transferFile{
+		ctx:       context.Background(),
+		job:       &TransferJob{},
+		localPath: localPath,
+		remoteURL: svrURL,
+		attempts: []transferAttemptDetails{
+			{
+				Url: svrURL,
+			},
+		},
+	}
+	transferResult, err := downloadObject(transfer)
+	require.NoError(t, err)
+	require.NoError(t, transferResult.Error)
+	assert.Equal(t, int32(3), requests.Load())
+	contents, err := os.ReadFile(localPath)
+	require.NoError(t, err)
+	assert.Equal(t, "staged", string(contents))
+}
+
+// A transfer still progressing when its deadline passes fails with the dedicated timeout error
+func TestTransferDeadline(t *testing.T) {
+	test_utils.InitClient(t, map[string]any{
+		"Logging.Level": "debug",
+	})
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1000000")
+		w.WriteHeader(http.StatusOK)
+		for i := 0; i < 1000; i++ {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(50 * time.Millisecond):
+			}
+			_, _ = w.Write(bytes.Repeat([]byte("a"), 1000))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer svr.Close()
+	svrURL, err := url.Parse(svr.URL)
+	require.NoError(t, err)
+
+	runWorker := func(job *TransferJob, ctx context.Context) TransferResults {
+		work := make(chan *clientTransferFile, 1)
+		results := make(chan *clientTransferResults, 1)
+		work <- &clientTransferFile{file: &transferFile{
+			ctx:       ctx,
+			job:       job,
+			localPath: filepath.Join(t.TempDir(), "test.txt"),
+			remoteURL: svrURL,
+			attempts:  []transferAttemptDetails{{Url: svrURL}},
+		}}
+		workerCtx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() { _ = runTransferWorker(workerCtx, work, results) }()
+		select {
+		case result := <-results:
+			return result.results
+		case <-time.After(10 * time.Second):
+			require.Fail(t, "the transfer didn't stop at its deadline")
+			return TransferResults{}
+		}
+	}
+
+	t.Run("transfer-timeout", func(t *testing.T) {
+		start := time.Now()
+		result := runWorker(&TransferJob{xferTimeout: 300 * time.Millisecond}, context.Background())
+		assert.Less(t, time.Since(start), 5*time.Second)
+		require.Error(t, result.Error)
+		assert.True(t, errors.Is(result.Error, &DeadlineExceededError{}))
+		assert.True(t, errors.Is(result.Error, context.DeadlineExceeded))
+		var pe *error_codes.PelicanError
+		require.True(t, errors.As(result.Error, &pe))
+		assert.Equal(t, 13, pe.ExitCode())
+		assert.Contains(t, result.Error.Error(), "did not complete within its timeout of 300ms")
+	})
+
+	t.Run("batch-deadline", func(t *testing.T) {
+		deadline := time.Now().Add(-time.Second)
+		ctx, cancel := context.WithDeadlineCause(context.Background(), deadline, &DeadlineExceededError{Deadline: deadline})
+		defer cancel()
+		result := runWorker(&TransferJob{deadline: deadline}, ctx)
+		require.Error(t, result.Error)
+		assert.True(t, errors.Is(result.Error, &DeadlineExceededError{}))
+		assert.Contains(t, result.Error.Error(), "did not complete by their deadline")
+	})
+}
+
+func TestCheckSourceMode(t *testing.T) {
+	cacheUrl, err := url.Parse("https://cache.example.com")
+	require.NoError(t, err)
+	tests := []struct {
+		name   string
+		job    *TransferJob
+		query  string
+		errMsg string
+	}{
+		{name: "default", job: &TransferJob{useDirector: true}},
+		{name: "cache-only", job: &TransferJob{cacheOnly: true, useDirector: true}},
+		{name: "cache-only-without-director", job: &TransferJob{cacheOnly: true}},
+		{name: "origin-only", job: &TransferJob{originOnly: true, useDirector: true}},
+		{name: "origin-only-with-directread", job: &TransferJob{originOnly: true, useDirector: true}, query: "directread"},
+		{name: "both", job: &TransferJob{cacheOnly: true, originOnly: true, useDirector: true}, errMsg: "both cache-only and origin-only"},
+		{name: "cache-only-with-directread", job: &TransferJob{cacheOnly: true, useDirector: true}, query: "directread", errMsg: "directread"},
+		{name: "origin-only-with-caches", job: &TransferJob{originOnly: true, useDirector: true, caches: []*url.URL{cacheUrl}}, errMsg: "preferred caches"},
+		{name: "origin-only-without-director", job: &TransferJob{originOnly: true}, errMsg: "requires a federation with a director"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.job.checkSourceMode(&url.URL{Path: "/foo/bar", RawQuery: test.query})
+			if test.errMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errMsg)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	assert.Equal(t, 30*time.Second, parseRetryAfter("30", time.Minute))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("0", time.Minute))
+	assert.Equal(t, time.Minute, parseRetryAfter("", time.Minute))
+	assert.Equal(t, time.Minute, parseRetryAfter("Wed, 21 Oct 2015 07:28:00 GMT", time.Minute))
+}
+
 // Test failed connection setup error message for downloads
 func TestFailedConnectionSetupError(t *testing.T) {
 	test_utils.InitClient(t, map[string]any{
@@ -39,6 +39,7 @@ import (
 
 	"github.com/pelicanplatform/pelican/config"
 	"github.com/pelicanplatform/pelican/namespaces"
+	"github.com/pelicanplatform/pelican/server_structs"
 	"github.com/pelicanplatform/pelican/utils"
 )
 
@@ -466,6 +467,11 @@ func getNamespaceInfo(ctx context.Context, resourcePath, OSDFDirectorUrl string,
 				return
 			}
 			ns.WriteBackHost = "https://" + writeBackUrl.Host
+			ns.UploadUrl = dirResp.Header.Get(server_structs.ResumableUploadUrlHeader)
+			if writeBack := dirResp.Header.Get(server_structs.WriteBackHeader); writeBack != "" {
+				log.Infoln("Uploading via a write-back cache; the object reaches the origin asynchronously:", writeBack)
+				ns.WriteBackHost += server_structs.WriteBackPath
+			}
 		}
 		return
 	} else {
@@ -771,6 +777,18 @@ func DoCopy(ctx context.Context, sourceFile string, destination string, recursiv
 		log.Debugln("Detected object write to remote federation object", destURL.Path)
 		localPath = sourceFile
 		remoteURL = destURL
+
+		// Ingest from a third-party WebDAV server by staging its objects locally first
+		if isWebDAVSource(sourceScheme) {
+			var stagingDir string
+			if stagingDir, err = os.MkdirTemp("", "pelican-webdav-"); err != nil {
+				return nil, errors.Wrap(err, "failed to create a staging directory for the WebDAV source")
+			}
+			defer os.RemoveAll(stagingDir)
+			if localPath, err = stageWebDAVSource(ctx, sourceURL, recursive, stagingDir); err != nil {
+				return nil, err
+			}
+		}
 	} else {
 		// Verify valid scheme
 		if err = schemeUnderstood(sourceScheme); err != nil {
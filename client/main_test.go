@@ -459,3 +459,50 @@ func TestSchemeUnderstood(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestApplyIssuerHint(t *testing.T) {
+	hinted, err := url.Parse("pelican://fed.example/prefix/file?issuer=https://new-idp.example")
+	require.NoError(t, err)
+
+	t.Run("no-hint", func(t *testing.T) {
+		plain, err := url.Parse("pelican://fed.example/prefix/file")
+		require.NoError(t, err)
+		ns := namespaces.Namespace{Path: "/prefix"}
+		assert.False(t, applyIssuerHint(plain, &ns))
+		assert.Nil(t, ns.CredentialGen)
+	})
+
+	t.Run("no-director-metadata", func(t *testing.T) {
+		ns := namespaces.Namespace{Path: "/prefix"}
+		require.True(t, applyIssuerHint(hinted, &ns))
+		require.NotNil(t, ns.CredentialGen)
+		assert.Equal(t, "https://new-idp.example", *ns.CredentialGen.Issuer)
+		assert.Equal(t, "OAuth2", *ns.CredentialGen.Strategy)
+	})
+
+	t.Run("listed-issuer", func(t *testing.T) {
+		oldIssuer := "https://old-idp.example"
+		strategy := "OAuth2"
+		credentialGen := &namespaces.CredentialGeneration{Issuer: &oldIssuer, Strategy: &strategy}
+		ns := namespaces.Namespace{
+			Path:          "/prefix",
+			Issuer:        []string{oldIssuer, "https://new-idp.example/"},
+			CredentialGen: credentialGen,
+		}
+		require.True(t, applyIssuerHint(hinted, &ns))
+		assert.Equal(t, "https://new-idp.example", *ns.CredentialGen.Issuer)
+		// The director's metadata is left alone
+		assert.Equal(t, oldIssuer, *credentialGen.Issuer)
+	})
+
+	t.Run("unlisted-issuer", func(t *testing.T) {
+		oldIssuer := "https://old-idp.example"
+		ns := namespaces.Namespace{
+			Path:          "/prefix",
+			Issuer:        []string{oldIssuer},
+			CredentialGen: &namespaces.CredentialGeneration{Issuer: &oldIssuer},
+		}
+		assert.False(t, applyIssuerHint(hinted, &ns))
+		assert.Equal(t, oldIssuer, *ns.CredentialGen.Issuer)
+	})
+}
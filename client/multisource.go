@@ -0,0 +1,307 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+)
+
+type (
+	// The health of a single source (cache) during a multi-source download
+	sourceHealth struct {
+		attempt   transferAttemptDetails
+		chunks    int           // Number of ranges successfully downloaded from the source
+		bytes     atomic.Int64  // Number of bytes downloaded from the source
+		failures  int           // Number of ranges the source failed to serve
+		lastErr   error         // The most recent error from the source
+		firstByte time.Duration // Time until the first byte was received from the source
+		disabled  bool          // Set once the source has failed too many times
+	}
+
+	// A range of bytes in an object; the end offset is inclusive
+	byteRange struct {
+		start int64
+		end   int64
+	}
+)
+
+const (
+	// Number of failed ranges after which a source is no longer used
+	multiSourceMaxFailures = 2
+)
+
+// Determine which of the (sorted) transfer attempts should be used for a
+// multi-source download of an object of the given size.
+//
+// Returns nil if the object should be downloaded from a single source.
+func getMultiSourceAttempts(transfer *transferFile, size int64, attempts []transferAttemptDetails) []transferAttemptDetails {
+	if !param.Client_EnableMultiSourceDownload.GetBool() || transfer.packOption != "" {
+		return nil
+	}
+	// The rate limit applies to a single stream; don't circumvent it with parallel ranges
+	if param.Client_MaximumDownloadSpeed.GetInt() > 0 {
+		return nil
+	}
+	if size <= 0 || size < int64(param.Client_MultiSourceMinimumSize.GetInt()) {
+		return nil
+	}
+	maxSources := param.Client_MultiSourceMaxSources.GetInt()
+	sources := make([]transferAttemptDetails, 0, maxSources)
+	for _, attempt := range attempts {
+		if len(sources) >= maxSources {
+			break
+		}
+		// Local caches are always preferred and serve a single stream
+		if attempt.Url.Scheme == "unix" {
+			return nil
+		}
+		if attempt.PackOption != "" {
+			continue
+		}
+		sources = append(sources, attempt)
+	}
+	if len(sources) < 2 {
+		return nil
+	}
+	return sources
+}
+
+// Split an object of the given size into ranges of at most chunkSize bytes
+func splitRanges(size, chunkSize int64) []byteRange {
+	if chunkSize <= 0 {
+		chunkSize = size
+	}
+	ranges := make([]byteRange, 0, (size+chunkSize-1)/chunkSize)
+	for start := int64(0); start < size; start += chunkSize {
+		end := start + chunkSize - 1
+		if end >= size {
+			end = size - 1
+		}
+		ranges = append(ranges, byteRange{start: start, end: end})
+	}
+	return ranges
+}
+
+// A writer which resets a watchdog timer each time data is written
+type progressWriter struct {
+	w        io.Writer
+	progress func(n int)
+}
+
+func (pw *progressWriter) Write(p []byte) (n int, err error) {
+	n, err = pw.w.Write(p)
+	if n > 0 {
+		pw.progress(n)
+	}
+	return
+}
+
+// Download a single byte range from a source into the destination file
+func downloadRange(ctx context.Context, client *http.Client, source *sourceHealth, remotePath string, fp *os.File, rng byteRange, token, project string, start time.Time, stoppedTransferTimeout time.Duration, downloaded *atomic.Int64) (err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Cancel the range if the source stops sending data
+	var watchdog *time.Timer
+	if stoppedTransferTimeout > 0 {
+		watchdog = time.AfterFunc(stoppedTransferTimeout, cancel)
+		defer watchdog.Stop()
+	}
+
+	rangeUrl := *source.attempt.Url
+	rangeUrl.Path = remotePath
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rangeUrl.String(), nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", rng.start, rng.end))
+	req.Header.Set("User-Agent", getUserAgent(project))
+	if token != "" {
+		if err = checkServerAllowed(ctx, &rangeUrl); err != nil {
+			return
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if searchJobAd(jobId) != "" {
+		req.Header.Set("X-Pelican-JobId", searchJobAd(jobId))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return &ConnectionSetupError{URL: rangeUrl.String(), Err: err}
+	}
+	defer resp.Body.Close()
+	expected := rng.end - rng.start + 1
+	if resp.StatusCode == http.StatusOK && (rng.start != 0 || resp.ContentLength != expected) {
+		return errors.Errorf("server at %s does not support range requests", rangeUrl.Host)
+	} else if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return &HttpErrResp{resp.StatusCode, fmt.Sprintf("Range request failed (HTTP status %d)", resp.StatusCode)}
+	}
+
+	writer := &progressWriter{
+		w: io.NewOffsetWriter(fp, rng.start),
+		progress: func(n int) {
+			if watchdog != nil {
+				watchdog.Reset(stoppedTransferTimeout)
+			}
+			if source.bytes.Add(int64(n)) == int64(n) {
+				source.firstByte = time.Since(start)
+			}
+			downloaded.Add(int64(n))
+		},
+	}
+	copied, err := io.Copy(writer, io.LimitReader(resp.Body, expected))
+	if err == nil && copied != expected {
+		err = errors.Errorf("short read of range %d-%d from %s: got %d of %d bytes", rng.start, rng.end, rangeUrl.Host, copied, expected)
+	}
+	if err != nil {
+		// Don't count the partial range as downloaded; it will be fetched again
+		source.bytes.Add(-copied)
+		downloaded.Add(-copied)
+		if errors.Is(ctx.Err(), context.Canceled) && stoppedTransferTimeout > 0 {
+			err = &StoppedTransferError{BytesTransferred: copied, StoppedTime: stoppedTransferTimeout}
+		}
+	}
+	return
+}
+
+// Download an object by splitting it into byte ranges fetched simultaneously
+// from several sources.
+//
+// Each source pulls ranges from a shared queue.  When a source fails to serve a
+// range, the range is returned to the queue for another source; a source that
+// fails repeatedly is disabled for the remainder of the object.  Returns the
+// per-source health so the caller can report each source as a transfer attempt.
+func downloadMultiSource(ctx context.Context, callback TransferCallbackFunc, sourceAttempts []transferAttemptDetails, remotePath, dest string, size int64, token, project string) (downloaded int64, sources []*sourceHealth, err error) {
+	sources = make([]*sourceHealth, len(sourceAttempts))
+	for idx, attempt := range sourceAttempts {
+		sources[idx] = &sourceHealth{attempt: attempt}
+	}
+
+	fp, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return
+	}
+	defer fp.Close()
+	if err = fp.Truncate(size); err != nil {
+		return
+	}
+
+	ranges := splitRanges(size, int64(param.Client_MultiSourceChunkSize.GetInt()))
+	pending := make(chan byteRange, len(ranges))
+	for _, rng := range ranges {
+		pending <- rng
+	}
+	var remaining atomic.Int64
+	remaining.Store(int64(len(ranges)))
+
+	log.Debugf("Downloading %s in %d ranges from %d sources", remotePath, len(ranges), len(sources))
+	dlCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var totalDownloaded atomic.Int64
+	if callback != nil {
+		callback(dest, 0, size, false)
+		defer func() {
+			callback(dest, totalDownloaded.Load(), size, true)
+		}()
+		go func() {
+			ticker := time.NewTicker(100 * time.Millisecond)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-dlCtx.Done():
+					return
+				case <-ticker.C:
+					callback(dest, totalDownloaded.Load(), size, false)
+				}
+			}
+		}()
+	}
+
+	stoppedTransferTimeout := compatToDuration(param.Client_StoppedTransferTimeout.GetDuration(), "Client.StoppedTransferTimeout")
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, source := range sources {
+		wg.Add(1)
+		go func(source *sourceHealth) {
+			defer wg.Done()
+			transport := config.GetTransport()
+			if !source.attempt.Proxy {
+				transport = transport.Clone()
+				transport.Proxy = nil
+			}
+			client := &http.Client{Transport: transport}
+			for {
+				var rng byteRange
+				select {
+				case <-dlCtx.Done():
+					return
+				case rng = <-pending:
+				}
+				rangeErr := downloadRange(dlCtx, client, source, remotePath, fp, rng, token, project, start, stoppedTransferTimeout, &totalDownloaded)
+				if rangeErr == nil {
+					source.chunks++
+					if remaining.Add(-1) == 0 {
+						cancel()
+					}
+					continue
+				}
+				// Hand the range to another source
+				pending <- rng
+				if dlCtx.Err() != nil {
+					return
+				}
+				source.failures++
+				source.lastErr = rangeErr
+				log.Debugf("Source %s failed to serve range %d-%d of %s (failure %d): %v",
+					source.attempt.Url.Host, rng.start, rng.end, remotePath, source.failures, rangeErr)
+				if source.failures >= multiSourceMaxFailures {
+					log.Warningf("Disabling source %s for the download of %s after %d failures", source.attempt.Url.Host, remotePath, source.failures)
+					source.disabled = true
+					return
+				}
+			}
+		}(source)
+	}
+	wg.Wait()
+
+	downloaded = totalDownloaded.Load()
+	if remaining.Load() != 0 {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		} else {
+			err = errors.Errorf("all %d sources failed during multi-source download of %s", len(sources), remotePath)
+		}
+	}
+	return
+}
@@ -0,0 +1,166 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, University of Nebraska-Lincoln
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/config"
+)
+
+func TestSplitRanges(t *testing.T) {
+	assert.Equal(t, []byteRange{{0, 9}, {10, 19}, {20, 24}}, splitRanges(25, 10))
+	assert.Equal(t, []byteRange{{0, 9}, {10, 19}}, splitRanges(20, 10))
+	assert.Equal(t, []byteRange{{0, 4}}, splitRanges(5, 10))
+	assert.Equal(t, []byteRange{{0, 4}}, splitRanges(5, 0))
+}
+
+func TestGetMultiSourceAttempts(t *testing.T) {
+	t.Cleanup(func() {
+		viper.Reset()
+		require.NoError(t, config.InitClient())
+	})
+	viper.Reset()
+	require.NoError(t, config.InitClient())
+	viper.Set("Client.MultiSourceMinimumSize", 100)
+	viper.Set("Client.MultiSourceMaxSources", 2)
+
+	attempts := []transferAttemptDetails{
+		{Url: &url.URL{Scheme: "https", Host: "cache1.example.com"}},
+		{Url: &url.URL{Scheme: "https", Host: "cache2.example.com"}},
+		{Url: &url.URL{Scheme: "https", Host: "cache3.example.com"}},
+	}
+	transfer := &transferFile{}
+
+	// Disabled by default
+	assert.Nil(t, getMultiSourceAttempts(transfer, 1000, attempts))
+
+	viper.Set("Client.EnableMultiSourceDownload", true)
+	sources := getMultiSourceAttempts(transfer, 1000, attempts)
+	require.Len(t, sources, 2)
+	assert.Equal(t, "cache1.example.com", sources[0].Url.Host)
+	assert.Equal(t, "cache2.example.com", sources[1].Url.Host)
+
+	// Small, unknown-size, and packed objects are downloaded from a single source
+	assert.Nil(t, getMultiSourceAttempts(transfer, 99, attempts))
+	assert.Nil(t, getMultiSourceAttempts(transfer, -1, attempts))
+	assert.Nil(t, getMultiSourceAttempts(&transferFile{packOption: "tar"}, 1000, attempts))
+	assert.Nil(t, getMultiSourceAttempts(transfer, 1000, attempts[:1]))
+
+	// Local caches are always used alone
+	local := append([]transferAttemptDetails{{Url: &url.URL{Scheme: "unix", Host: "localhost"}}}, attempts...)
+	assert.Nil(t, getMultiSourceAttempts(transfer, 1000, local))
+}
+
+func TestDownloadMultiSource(t *testing.T) {
+	t.Cleanup(func() {
+		viper.Reset()
+		require.NoError(t, config.InitClient())
+	})
+	viper.Reset()
+	require.NoError(t, config.InitClient())
+	viper.Set("Client.MultiSourceChunkSize", 1000)
+
+	content := make([]byte, 10500)
+	_, err := rand.Read(content)
+	require.NoError(t, err)
+
+	newSource := func(handler http.Handler) transferAttemptDetails {
+		svr := httptest.NewServer(handler)
+		t.Cleanup(svr.Close)
+		svrUrl, err := url.Parse(svr.URL)
+		require.NoError(t, err)
+		return transferAttemptDetails{Url: svrUrl}
+	}
+	var goodRequests atomic.Int64
+	good := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		goodRequests.Add(1)
+		assert.Equal(t, "/foo/bar.txt", r.URL.Path)
+		assert.Equal(t, "Bearer sometoken", r.Header.Get("Authorization"))
+		http.ServeContent(w, r, "bar.txt", time.Time{}, bytes.NewReader(content))
+	})
+	var badRequests atomic.Int64
+	bad := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		badRequests.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	t.Run("unhealthy-source-disabled", func(t *testing.T) {
+		dest := filepath.Join(t.TempDir(), "bar.txt")
+		var progress atomic.Int64
+		callback := func(_ string, downloaded int64, _ int64, _ bool) { progress.Store(downloaded) }
+		downloaded, sources, err := downloadMultiSource(context.Background(), callback,
+			[]transferAttemptDetails{newSource(bad), newSource(good), newSource(good)},
+			"/foo/bar.txt", dest, int64(len(content)), "sometoken", "")
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(content)), downloaded)
+		assert.Equal(t, int64(len(content)), progress.Load())
+
+		result, err := os.ReadFile(dest)
+		require.NoError(t, err)
+		assert.Equal(t, content, result)
+
+		require.Len(t, sources, 3)
+		assert.True(t, sources[0].disabled)
+		assert.Equal(t, multiSourceMaxFailures, sources[0].failures)
+		assert.Error(t, sources[0].lastErr)
+		assert.Equal(t, int64(0), sources[0].bytes.Load())
+		assert.Equal(t, int64(multiSourceMaxFailures), badRequests.Load())
+		assert.Equal(t, int64(len(content)), sources[1].bytes.Load()+sources[2].bytes.Load())
+		assert.Equal(t, int64(11), goodRequests.Load())
+		assert.Equal(t, 11, sources[1].chunks+sources[2].chunks)
+	})
+
+	t.Run("all-sources-fail", func(t *testing.T) {
+		dest := filepath.Join(t.TempDir(), "bar.txt")
+		_, sources, err := downloadMultiSource(context.Background(), nil,
+			[]transferAttemptDetails{newSource(bad), newSource(bad)},
+			"/foo/bar.txt", dest, int64(len(content)), "sometoken", "")
+		require.Error(t, err)
+		for _, source := range sources {
+			assert.True(t, source.disabled)
+		}
+	})
+
+	t.Run("ranges-not-supported", func(t *testing.T) {
+		noRanges := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, err := w.Write(content)
+			assert.NoError(t, err)
+		})
+		dest := filepath.Join(t.TempDir(), "bar.txt")
+		_, _, err := downloadMultiSource(context.Background(), nil,
+			[]transferAttemptDetails{newSource(noRanges), newSource(noRanges)},
+			"/foo/bar.txt", dest, int64(len(content)), "", "")
+		require.Error(t, err)
+	})
+}
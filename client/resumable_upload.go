@@ -0,0 +1,419 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// An entry of the upload journal, recording a chunked upload until it completes
+	uploadJournalEntry struct {
+		UploadUrl string    `json:"upload_url"`
+		RemoteUrl string    `json:"remote_url"`
+		LocalPath string    `json:"local_path"`
+		Size      int64     `json:"size"`
+		ModTime   time.Time `json:"mod_time"`
+		Offset    int64     `json:"offset"` // The bytes the origin acknowledged
+		Updated   time.Time `json:"updated"`
+	}
+
+	// Counts the bytes of a chunk read by the HTTP client
+	chunkReader struct {
+		reader   io.Reader
+		read     atomic.Int64
+		lastRead atomic.Int64 // Unix nanoseconds
+	}
+)
+
+// How many times in a row a chunk is retried before the upload fails
+const maxUploadChunkRetries = 3
+
+var errUploadNotFound = errors.New("the origin no longer holds the upload")
+
+func (cr *chunkReader) Read(p []byte) (n int, err error) {
+	n, err = cr.reader.Read(p)
+	if n > 0 {
+		cr.read.Add(int64(n))
+		cr.lastRead.Store(time.Now().UnixNano())
+	}
+	return
+}
+
+// Uploads are journaled by local file and destination
+func uploadJournalFile(localPath, remoteUrl string) string {
+	if absPath, err := filepath.Abs(localPath); err == nil {
+		localPath = absPath
+	}
+	key := sha256.Sum256([]byte(localPath + "\n" + remoteUrl))
+	return filepath.Join(param.Client_UploadJournalLocation.GetString(), hex.EncodeToString(key[:])+".json")
+}
+
+func loadUploadJournal(localPath, remoteUrl string) *uploadJournalEntry {
+	contents, err := os.ReadFile(uploadJournalFile(localPath, remoteUrl))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Warningln("Failed to read the upload journal:", err)
+		}
+		return nil
+	}
+	entry := &uploadJournalEntry{}
+	if err = json.Unmarshal(contents, entry); err != nil {
+		log.Warningln("Ignoring an invalid upload journal entry:", err)
+		return nil
+	}
+	return entry
+}
+
+func (entry *uploadJournalEntry) save() error {
+	entry.Updated = time.Now()
+	contents, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	filename := uploadJournalFile(entry.LocalPath, entry.RemoteUrl)
+	if err = os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return errors.Wrap(err, "failed to create the upload journal directory")
+	}
+	tmpFile := filename + ".tmp"
+	if err = os.WriteFile(tmpFile, contents, 0600); err != nil {
+		return errors.Wrap(err, "failed to write the upload journal")
+	}
+	return errors.Wrap(os.Rename(tmpFile, filename), "failed to write the upload journal")
+}
+
+func (entry *uploadJournalEntry) remove() {
+	if err := os.Remove(uploadJournalFile(entry.LocalPath, entry.RemoteUrl)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warningln("Failed to remove the upload journal entry:", err)
+	}
+}
+
+// Whether the file is uploaded in chunks: it must be larger than a chunk and the
+// origin must support resumable uploads
+func useResumableUpload(transfer *transferFile, fileInfo fs.FileInfo) bool {
+	chunkSize := param.Client_UploadChunkSize.GetInt()
+	return transfer.packOption == "" && transfer.job != nil && transfer.job.namespace.UploadUrl != "" &&
+		chunkSize > 0 && fileInfo.Mode().IsRegular() && fileInfo.Size() > int64(chunkSize)
+}
+
+func newUploadRequest(ctx context.Context, method, reqUrl, token, project string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, reqUrl, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(server_structs.TusResumableHeader, server_structs.TusVersion)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("User-Agent", getUserAgent(project))
+	return req, nil
+}
+
+func uploadStatusError(resp *http.Response, action string) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return &HttpErrResp{resp.StatusCode, fmt.Sprintf("Failed to %s (HTTP status %d): %s", action, resp.StatusCode, string(msg))}
+}
+
+// Start an upload at the origin, returning its URL
+func createUpload(ctx context.Context, client *http.Client, uploadUrl *url.URL, objectPath string, size int64, token, project string) (*url.URL, error) {
+	req, err := newUploadRequest(ctx, http.MethodPost, uploadUrl.String(), token, project, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(server_structs.TusUploadLength, strconv.FormatInt(size, 10))
+	req.Header.Set(server_structs.TusUploadMetadata, "path "+base64.StdEncoding.EncodeToString([]byte(objectPath)))
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return nil, uploadStatusError(resp, "start the upload")
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.Header.Get("Location") == "" {
+		return nil, errors.New("the origin did not return the location of the upload")
+	}
+	return uploadUrl.ResolveReference(location), nil
+}
+
+// Get the number of bytes of the upload the origin holds
+func getUploadOffset(ctx context.Context, client *http.Client, sessionUrl *url.URL, token, project string) (int64, error) {
+	req, err := newUploadRequest(ctx, http.MethodHead, sessionUrl.String(), token, project, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		return 0, errUploadNotFound
+	} else if resp.StatusCode != http.StatusOK {
+		return 0, &HttpErrResp{resp.StatusCode, fmt.Sprintf("Failed to get the upload's offset (HTTP status %d)", resp.StatusCode)}
+	}
+	return strconv.ParseInt(resp.Header.Get(server_structs.TusUploadOffset), 10, 64)
+}
+
+// Send the chunk of the file at offset, returning the offset the origin acknowledged.
+// The chunk reaching the end of the file completes the upload.
+func sendChunk(ctx context.Context, client *http.Client, sessionUrl *url.URL, token, project string, file *os.File, offset, length int64, progress func(int64)) (int64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	reader := &chunkReader{reader: io.NewSectionReader(file, offset, length)}
+	reader.lastRead.Store(time.Now().UnixNano())
+	req, err := newUploadRequest(ctx, http.MethodPatch, sessionUrl.String(), token, project, reader)
+	if err != nil {
+		return offset, err
+	}
+	req.ContentLength = length
+	if length == 0 {
+		req.Body = http.NoBody
+	}
+	req.Header.Set("Content-Type", server_structs.TusPatchContentType)
+	req.Header.Set(server_structs.TusUploadOffset, strconv.FormatInt(offset, 10))
+
+	// Abort the chunk if it stops being sent; once it's sent, the origin may take a
+	// while to write the completed object
+	stoppedTransferTimeout := compatToDuration(param.Client_StoppedTransferTimeout.GetDuration(), "Client.StoppedTransferTimeout")
+	var stopped atomic.Pointer[StoppedTransferError]
+	go func() {
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				read := reader.read.Load()
+				progress(offset + read)
+				sinceRead := time.Since(time.Unix(0, reader.lastRead.Load()))
+				if read < length && sinceRead > stoppedTransferTimeout {
+					stopped.Store(&StoppedTransferError{BytesTransferred: offset + read, StoppedTime: sinceRead, Upload: true})
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if stoppedErr := stopped.Load(); stoppedErr != nil {
+			return offset, stoppedErr
+		}
+		return offset, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return offset, uploadStatusError(resp, "send the chunk")
+	}
+	return strconv.ParseInt(resp.Header.Get(server_structs.TusUploadOffset), 10, 64)
+}
+
+// Whether resending a chunk from the origin's offset may succeed
+func isChunkRetryable(err error) bool {
+	var hep *HttpErrResp
+	if errors.As(err, &hep) {
+		return hep.Code >= 500 || hep.Code == http.StatusConflict || hep.Code == http.StatusLocked
+	}
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, &untrustedServerError{})
+}
+
+// Upload a file in chunks through the origin's resumable upload API.  The journal
+// records the upload and the bytes the origin acknowledged, so a later run with
+// the resume option continues it after the last acknowledged chunk.
+func uploadResumable(transfer *transferFile, fileInfo fs.FileInfo) (transferResult TransferResults, err error) {
+	transferResult.job = transfer.job
+	transferResult.Scheme = transfer.remoteURL.Scheme
+	size := fileInfo.Size()
+	chunkSize := int64(param.Client_UploadChunkSize.GetInt())
+	ctx := transfer.ctx
+	project := transfer.project
+
+	var uploaded int64
+	progress := func(bytes int64) {
+		uploaded = bytes
+		if transfer.callback != nil {
+			transfer.callback(transfer.localPath, bytes, size, false)
+		}
+	}
+	if transfer.callback != nil {
+		transfer.callback(transfer.localPath, 0, size, false)
+		defer func() {
+			transfer.callback(transfer.localPath, uploaded, size, true)
+		}()
+	}
+
+	var attempt TransferResult
+	transferStartTime := time.Now()
+	fail := func(err error) (TransferResults, error) {
+		transferEndTime := time.Now()
+		xferErrors := NewTransferErrors()
+		xferErrors.AddPastError(newTransferAttemptError(attempt.Endpoint, "", false, true, err), transferEndTime)
+		transferResult.Error = xferErrors
+		transferResult.TransferredBytes = uploaded
+		attempt.Error = err
+		attempt.TransferFileBytes = uploaded
+		attempt.TransferEndTime = transferEndTime
+		attempt.TransferTime = transferEndTime.Sub(transferStartTime)
+		transferResult.Attempts = append(transferResult.Attempts, attempt)
+		return transferResult, nil
+	}
+
+	uploadUrl, err := url.Parse(transfer.job.namespace.UploadUrl)
+	if err != nil {
+		return fail(errors.Wrap(err, "the director returned an invalid upload URL"))
+	}
+	attempt.Endpoint = uploadUrl.Host
+	if err = checkServerAllowed(ctx, uploadUrl); err != nil {
+		return fail(err)
+	}
+	file, err := os.Open(transfer.localPath)
+	if err != nil {
+		transferResult.Error = err
+		return transferResult, err
+	}
+	defer file.Close()
+	client := &http.Client{Transport: config.GetTransport()}
+	remoteUrl := transfer.remoteURL.String()
+
+	// Pick up where a previous run stopped, if the file hasn't changed since
+	var sessionUrl *url.URL
+	var offset int64
+	entry := loadUploadJournal(transfer.localPath, remoteUrl)
+	if entry != nil && transfer.job.resume && entry.Size == size && entry.ModTime.Equal(fileInfo.ModTime()) {
+		if sessionUrl, err = url.Parse(entry.UploadUrl); err == nil {
+			err = checkServerAllowed(ctx, sessionUrl)
+		}
+		if err == nil {
+			offset, err = getUploadOffset(ctx, client, sessionUrl, transfer.token, project)
+		}
+		if errors.Is(err, errUploadNotFound) {
+			log.Infof("The origin no longer holds the interrupted upload of %s; starting over", transfer.localPath)
+			sessionUrl = nil
+		} else if err != nil {
+			return fail(errors.Wrap(err, "failed to resume the upload"))
+		} else {
+			log.Infof("Resuming the upload of %s after %d of %d bytes", transfer.localPath, offset, size)
+		}
+	} else if entry != nil {
+		if transfer.job.resume {
+			log.Infof("%s changed since its upload was interrupted; starting over", transfer.localPath)
+		}
+		// Let the origin free the space of the abandoned upload
+		if staleUrl, err := url.Parse(entry.UploadUrl); err == nil && checkServerAllowed(ctx, staleUrl) == nil {
+			if req, err := newUploadRequest(ctx, http.MethodDelete, staleUrl.String(), transfer.token, project, nil); err == nil {
+				if resp, err := client.Do(req); err == nil {
+					resp.Body.Close()
+				}
+			}
+		}
+	}
+	if sessionUrl == nil {
+		if sessionUrl, err = createUpload(ctx, client, uploadUrl, transfer.remoteURL.Path, size, transfer.token, project); err != nil {
+			return fail(err)
+		}
+		if err = checkServerAllowed(ctx, sessionUrl); err != nil {
+			return fail(err)
+		}
+		entry = &uploadJournalEntry{
+			UploadUrl: sessionUrl.String(),
+			RemoteUrl: remoteUrl,
+			LocalPath: transfer.localPath,
+			Size:      size,
+			ModTime:   fileInfo.ModTime(),
+		}
+	}
+	entry.Offset = offset
+	if err = entry.save(); err != nil {
+		log.Warningln("The upload can't be resumed if interrupted:", err)
+	}
+	progress(offset)
+
+	retries := 0
+	for {
+		length := min(chunkSize, size-offset)
+		newOffset, err := sendChunk(ctx, client, sessionUrl, transfer.token, project, file, offset, length, progress)
+		if err == nil {
+			if attempt.TimeToFirstByte == 0 {
+				attempt.TimeToFirstByte = time.Since(transferStartTime)
+			}
+			offset = newOffset
+			retries = 0
+			progress(offset)
+			if offset >= size {
+				break
+			}
+			entry.Offset = offset
+			if err = entry.save(); err != nil {
+				log.Warningln("Failed to journal the upload's progress:", err)
+			}
+			continue
+		}
+
+		retries++
+		if !isChunkRetryable(err) || retries > maxUploadChunkRetries {
+			return fail(errors.Wrapf(err, "upload stopped after %d of %d bytes; run the upload again with --resume to continue it", offset, size))
+		}
+		log.Warningf("Failed to send a chunk of %s (attempt %d of %d): %v", transfer.localPath, retries, maxUploadChunkRetries+1, err)
+		select {
+		case <-ctx.Done():
+			return fail(ctx.Err())
+		case <-time.After(time.Duration(retries) * time.Second):
+		}
+		// Resend from wherever the origin stopped receiving
+		if serverOffset, headErr := getUploadOffset(ctx, client, sessionUrl, transfer.token, project); headErr == nil {
+			offset = serverOffset
+		} else if errors.Is(headErr, errUploadNotFound) {
+			entry.remove()
+			return fail(headErr)
+		}
+	}
+	entry.remove()
+
+	transferEndTime := time.Now()
+	log.Debugf("Successful chunked upload of %d bytes", size)
+	transferResult.TransferredBytes = size
+	attempt.TransferFileBytes = size
+	attempt.TransferEndTime = transferEndTime
+	attempt.TransferTime = transferEndTime.Sub(transferStartTime)
+	transferResult.Attempts = append(transferResult.Attempts, attempt)
+	return transferResult, nil
+}
@@ -0,0 +1,200 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+// A minimal resumable upload server holding a single upload; failChunk decides,
+// from the offset of each chunk, the status to fail it with (or 0 to accept it)
+type fakeUploadServer struct {
+	mutex     sync.Mutex
+	data      []byte
+	length    int64
+	created   int
+	completed bool
+	failChunk func(offset int64) int
+}
+
+func (s *fakeUploadServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if r.Header.Get("Authorization") != "Bearer upload-token" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		s.length, _ = strconv.ParseInt(r.Header.Get(server_structs.TusUploadLength), 10, 64)
+		s.data = nil
+		s.created++
+		w.Header().Set("Location", server_structs.ResumableUploadPath+"/upload")
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodHead:
+		w.Header().Set(server_structs.TusUploadOffset, strconv.Itoa(len(s.data)))
+	case http.MethodPatch:
+		offset, _ := strconv.ParseInt(r.Header.Get(server_structs.TusUploadOffset), 10, 64)
+		if offset != int64(len(s.data)) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		if status := s.failChunk(offset); status != 0 {
+			w.WriteHeader(status)
+			return
+		}
+		chunk, _ := io.ReadAll(r.Body)
+		s.data = append(s.data, chunk...)
+		s.completed = int64(len(s.data)) == s.length
+		w.Header().Set(server_structs.TusUploadOffset, strconv.Itoa(len(s.data)))
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestResumableUpload(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	journalDir := t.TempDir()
+	viper.Set("Client.UploadJournalLocation", journalDir)
+	viper.Set("Client.UploadChunkSize", 10)
+	viper.Set("Client.StoppedTransferTimeout", "10s")
+
+	contents := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	localPath := filepath.Join(t.TempDir(), "object.txt")
+	require.NoError(t, os.WriteFile(localPath, contents, 0644))
+	fileInfo, err := os.Stat(localPath)
+	require.NoError(t, err)
+
+	newTransfer := func(server *httptest.Server, resume bool) *transferFile {
+		job := &TransferJob{resume: resume}
+		job.namespace.UploadUrl = server.URL + server_structs.ResumableUploadPath
+		return &transferFile{
+			ctx:       context.Background(),
+			job:       job,
+			remoteURL: &url.URL{Scheme: "pelican", Host: "federation.example.com", Path: "/first/object.txt"},
+			localPath: localPath,
+			token:     "upload-token",
+		}
+	}
+
+	t.Run("retries-failed-chunks", func(t *testing.T) {
+		failures := 0
+		fake := &fakeUploadServer{failChunk: func(offset int64) int {
+			if offset == 20 && failures < 2 {
+				failures++
+				return http.StatusServiceUnavailable
+			}
+			return 0
+		}}
+		server := httptest.NewServer(fake)
+		t.Cleanup(server.Close)
+
+		transfer := newTransfer(server, false)
+		require.True(t, useResumableUpload(transfer, fileInfo))
+		result, err := uploadResumable(transfer, fileInfo)
+		require.NoError(t, err)
+		require.NoError(t, result.Error)
+		assert.Equal(t, int64(len(contents)), result.TransferredBytes)
+		assert.True(t, fake.completed)
+		assert.Equal(t, contents, fake.data)
+		assert.Nil(t, loadUploadJournal(localPath, transfer.remoteURL.String()))
+	})
+
+	t.Run("resume-after-interruption", func(t *testing.T) {
+		interrupted := true
+		fake := &fakeUploadServer{failChunk: func(offset int64) int {
+			if offset == 20 && interrupted {
+				return http.StatusForbidden
+			}
+			return 0
+		}}
+		server := httptest.NewServer(fake)
+		t.Cleanup(server.Close)
+
+		transfer := newTransfer(server, false)
+		result, err := uploadResumable(transfer, fileInfo)
+		require.NoError(t, err)
+		require.Error(t, result.Error)
+		assert.Contains(t, result.Error.Error(), "--resume")
+		entry := loadUploadJournal(localPath, transfer.remoteURL.String())
+		require.NotNil(t, entry)
+		assert.Equal(t, int64(20), entry.Offset)
+
+		// Resuming continues the existing upload instead of creating a new one
+		interrupted = false
+		result, err = uploadResumable(newTransfer(server, true), fileInfo)
+		require.NoError(t, err)
+		require.NoError(t, result.Error)
+		assert.Equal(t, 1, fake.created)
+		assert.True(t, fake.completed)
+		assert.Equal(t, contents, fake.data)
+		assert.Nil(t, loadUploadJournal(localPath, transfer.remoteURL.String()))
+	})
+
+	t.Run("restart-without-resume", func(t *testing.T) {
+		fake := &fakeUploadServer{failChunk: func(offset int64) int {
+			if offset == 10 {
+				return http.StatusForbidden
+			}
+			return 0
+		}}
+		server := httptest.NewServer(fake)
+		t.Cleanup(server.Close)
+
+		result, err := uploadResumable(newTransfer(server, false), fileInfo)
+		require.NoError(t, err)
+		require.Error(t, result.Error)
+
+		fake.failChunk = func(int64) int { return 0 }
+		result, err = uploadResumable(newTransfer(server, false), fileInfo)
+		require.NoError(t, err)
+		require.NoError(t, result.Error)
+		assert.Equal(t, 2, fake.created)
+		assert.Equal(t, contents, fake.data)
+	})
+
+	t.Run("small-files-use-single-put", func(t *testing.T) {
+		viper.Set("Client.UploadChunkSize", len(contents))
+		t.Cleanup(func() { viper.Set("Client.UploadChunkSize", 10) })
+		server := httptest.NewServer(http.NotFoundHandler())
+		t.Cleanup(server.Close)
+		transfer := newTransfer(server, false)
+		assert.False(t, useResumableUpload(transfer, fileInfo))
+		transfer.job.namespace.UploadUrl = ""
+		viper.Set("Client.UploadChunkSize", 10)
+		assert.False(t, useResumableUpload(transfer, fileInfo))
+	})
+}
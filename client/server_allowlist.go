@@ -0,0 +1,218 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// The federation's signed list of server hosts, as last fetched from the director
+	serverAllowlist struct {
+		mutex       sync.Mutex
+		fetchGroup  singleflight.Group
+		directorUrl string
+		hosts       map[string]bool
+		fetched     time.Time
+		expiry      time.Time
+	}
+
+	// Error returned when a token would be sent to a server missing from the
+	// federation's signed server list
+	untrustedServerError struct {
+		Host string
+	}
+)
+
+// When a server is missing from the cached list, the list is fetched again (in case
+// the server recently joined the federation) at most this often
+const serverAllowlistMinRefresh = time.Minute
+
+var trustedServers = &serverAllowlist{}
+
+func (e *untrustedServerError) Error() string {
+	return fmt.Sprintf("refusing to send a token to %s: the server is not in the federation's signed server list", e.Host)
+}
+
+func (e *untrustedServerError) Is(target error) bool {
+	_, ok := target.(*untrustedServerError)
+	return ok
+}
+
+// Return the host of a server URL as listed by the director; the default HTTPS
+// port is dropped so both forms of a host compare equal
+func allowlistHost(serverUrl *url.URL) string {
+	if serverUrl.Scheme == "https" || serverUrl.Scheme == "" {
+		return strings.TrimSuffix(serverUrl.Host, ":443")
+	}
+	return serverUrl.Host
+}
+
+// Fetch the signed server list from the director, verifying it was signed by one
+// of the keys the federation publishes for the director
+func fetchServerAllowlist(ctx context.Context, fedInfo config.FederationDiscovery) (hosts map[string]bool, expiry time.Time, err error) {
+	listUrl, err := url.JoinPath(fedInfo.DirectorEndpoint, "api", "v1.0", "director", "serverList")
+	if err != nil {
+		return nil, expiry, errors.Wrap(err, "failed to generate the URL of the director's server list")
+	}
+	if fedInfo.JwksUri == "" {
+		return nil, expiry, errors.New("the federation does not publish the director's public keys")
+	}
+	client := &http.Client{Transport: config.GetTransport()}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, listUrl, nil)
+	if err != nil {
+		return nil, expiry, err
+	}
+	req.Header.Set("User-Agent", getUserAgent(""))
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, expiry, errors.Wrap(err, "failed to fetch the director's server list")
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4*1024*1024))
+	if err != nil {
+		return nil, expiry, errors.Wrap(err, "failed to read the director's server list")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, expiry, errors.Errorf("the director responded to the server list request with status code %d", resp.StatusCode)
+	}
+	listRes := server_structs.SignedServerListRes{}
+	if err = json.Unmarshal(body, &listRes); err != nil {
+		return nil, expiry, errors.Wrap(err, "failed to parse the director's server list")
+	}
+
+	// Prefer the director keys of the federation's trust bundle, which are pinned once
+	// fetched; directors predating the trust bundle only publish their keys at jwks_uri
+	var keys jwk.Set
+	if bundle, bundleErr := config.GetTrustBundle(ctx); bundleErr == nil {
+		keys, err = bundle.GetDirectorKeys()
+	} else {
+		log.Debugln("Using the director's public keys at jwks_uri as the federation trust bundle is unavailable:", bundleErr)
+		keys, err = jwk.Fetch(ctx, fedInfo.JwksUri, jwk.WithHTTPClient(client))
+		err = errors.Wrapf(err, "failed to fetch the director's public keys from %s", fedInfo.JwksUri)
+	}
+	if err != nil {
+		return nil, expiry, err
+	}
+	tok, err := jwt.Parse([]byte(listRes.Token), jwt.WithKeySet(keys), jwt.WithValidate(true))
+	if err != nil {
+		return nil, expiry, errors.Wrap(err, "failed to verify the director's server list")
+	}
+	claim, ok := tok.Get(server_structs.ServerListClaim)
+	if !ok {
+		return nil, expiry, errors.Errorf("the director's server list has no %s claim", server_structs.ServerListClaim)
+	}
+	hostList, ok := claim.([]interface{})
+	if !ok {
+		return nil, expiry, errors.Errorf("the %s claim of the director's server list is not a list", server_structs.ServerListClaim)
+	}
+	hosts = make(map[string]bool, len(hostList))
+	for _, host := range hostList {
+		if hostStr, ok := host.(string); ok {
+			hosts[hostStr] = true
+		}
+	}
+	return hosts, tok.Expiration(), nil
+}
+
+// Check whether the server at `serverUrl` is in the federation's signed server list,
+// fetching (and caching) the list from the director as needed
+func (sa *serverAllowlist) contains(ctx context.Context, fedInfo config.FederationDiscovery, serverUrl *url.URL) (bool, error) {
+	host := allowlistHost(serverUrl)
+	if directorUrl, err := url.Parse(fedInfo.DirectorEndpoint); err == nil && allowlistHost(directorUrl) == host {
+		return true, nil
+	}
+
+	sa.mutex.Lock()
+	now := time.Now()
+	stale := sa.directorUrl != fedInfo.DirectorEndpoint || now.After(sa.expiry)
+	listed := sa.hosts[host]
+	recent := now.Sub(sa.fetched) < serverAllowlistMinRefresh
+	sa.mutex.Unlock()
+	if !stale && listed {
+		return true, nil
+	}
+	if !stale && recent {
+		return false, nil
+	}
+
+	// The list is fetched without holding the mutex, so checks against the cached list
+	// aren't held up by a slow director; concurrent checks share a single fetch
+	result, err, _ := sa.fetchGroup.Do(fedInfo.DirectorEndpoint, func() (interface{}, error) {
+		hosts, expiry, err := fetchServerAllowlist(ctx, fedInfo)
+		if err != nil {
+			return nil, err
+		}
+		log.Debugf("Fetched the federation's signed list of %d servers", len(hosts))
+		sa.mutex.Lock()
+		defer sa.mutex.Unlock()
+		sa.directorUrl = fedInfo.DirectorEndpoint
+		sa.hosts = hosts
+		sa.fetched = time.Now()
+		sa.expiry = expiry
+		return hosts, nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return result.(map[string]bool)[host], nil
+}
+
+// If Client.VerifyServerIdentity is set, check that a token may be sent to the server
+// at `serverUrl`, i.e. that the server is in the federation's signed server list.
+// Transfers via the local cache's Unix socket never leave the host and aren't checked.
+func checkServerAllowed(ctx context.Context, serverUrl *url.URL) error {
+	if !param.Client_VerifyServerIdentity.GetBool() || serverUrl == nil || serverUrl.Scheme == "unix" {
+		return nil
+	}
+	fedInfo, err := config.GetFederation(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to verify the server identity")
+	}
+	if fedInfo.DirectorEndpoint == "" {
+		return errors.New("unable to verify the server identity: the federation has no director")
+	}
+	ok, err := trustedServers.contains(ctx, fedInfo, serverUrl)
+	if err != nil {
+		return errors.Wrap(err, "unable to verify the server identity")
+	}
+	if !ok {
+		return &untrustedServerError{Host: serverUrl.Host}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+func newSigningKey(t *testing.T) jwk.Key {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	key, err := jwk.FromRaw(ecKey)
+	require.NoError(t, err)
+	require.NoError(t, jwk.AssignKeyID(key))
+	require.NoError(t, key.Set(jwk.AlgorithmKey, jwa.ES256))
+	return key
+}
+
+func TestServerAllowlist(t *testing.T) {
+	directorKey := newSigningKey(t)
+	otherKey := newSigningKey(t)
+	signingKey := directorKey
+	hosts := []string{"origin.example.com:8443", "cache.example.com"}
+	var fetches atomic.Int32
+	var fetchDelay time.Duration
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1.0/director/serverList":
+			fetches.Add(1)
+			time.Sleep(fetchDelay)
+			tok, err := jwt.NewBuilder().
+				Expiration(time.Now().Add(10*time.Minute)).
+				Claim(server_structs.ServerListClaim, hosts).
+				Build()
+			require.NoError(t, err)
+			signed, err := jwt.Sign(tok, jwt.WithKey(jwa.ES256, signingKey))
+			require.NoError(t, err)
+			require.NoError(t, json.NewEncoder(w).Encode(server_structs.SignedServerListRes{Token: string(signed)}))
+		case "/.well-known/issuer.jwks":
+			publicKey, err := directorKey.PublicKey()
+			require.NoError(t, err)
+			keys := jwk.NewSet()
+			require.NoError(t, keys.AddKey(publicKey))
+			require.NoError(t, json.NewEncoder(w).Encode(keys))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	fedInfo := config.FederationDiscovery{
+		DirectorEndpoint: server.URL,
+		JwksUri:          server.URL + "/.well-known/issuer.jwks",
+	}
+	ctx := context.Background()
+
+	check := func(sa *serverAllowlist, rawUrl string) (bool, error) {
+		serverUrl, err := url.Parse(rawUrl)
+		require.NoError(t, err)
+		return sa.contains(ctx, fedInfo, serverUrl)
+	}
+
+	t.Run("listed-servers-are-allowed", func(t *testing.T) {
+		sa := &serverAllowlist{}
+		for _, rawUrl := range []string{
+			"https://origin.example.com:8443/foo",
+			"https://cache.example.com:443/foo",
+			"https://cache.example.com/foo",
+			server.URL + "/api/v1.0/director/origin/foo", // The director itself
+		} {
+			ok, err := check(sa, rawUrl)
+			require.NoError(t, err)
+			assert.True(t, ok, rawUrl)
+		}
+		// The list is only fetched once
+		assert.Equal(t, int32(1), fetches.Load())
+
+		ok, err := check(sa, "https://evil.example.com/foo")
+		require.NoError(t, err)
+		assert.False(t, ok)
+		ok, err = check(sa, "https://origin.example.com/foo")
+		require.NoError(t, err)
+		assert.False(t, ok)
+		// Unlisted servers don't trigger a refetch right away
+		assert.Equal(t, int32(1), fetches.Load())
+
+		// Once the list is old enough, a server that just joined is picked up
+		hosts = append(hosts, "new.example.com")
+		t.Cleanup(func() { hosts = hosts[:2] })
+		sa.fetched = time.Now().Add(-2 * serverAllowlistMinRefresh)
+		ok, err = check(sa, "https://new.example.com/foo")
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, int32(2), fetches.Load())
+	})
+
+	t.Run("concurrent-checks-share-a-fetch", func(t *testing.T) {
+		fetchDelay = 200 * time.Millisecond
+		t.Cleanup(func() { fetchDelay = 0 })
+		fetches.Store(0)
+		sa := &serverAllowlist{}
+		var wg sync.WaitGroup
+		for idx := 0; idx < 10; idx++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ok, err := check(sa, "https://origin.example.com:8443/foo")
+				assert.NoError(t, err)
+				assert.True(t, ok)
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, int32(1), fetches.Load())
+	})
+
+	t.Run("list-signed-by-other-key-is-rejected", func(t *testing.T) {
+		signingKey = otherKey
+		t.Cleanup(func() { signingKey = directorKey })
+		_, err := check(&serverAllowlist{}, "https://origin.example.com:8443/foo")
+		assert.Error(t, err)
+	})
+
+	t.Run("check-only-when-enabled", func(t *testing.T) {
+		viper.Reset()
+		t.Cleanup(viper.Reset)
+		assert.NoError(t, checkServerAllowed(ctx, &url.URL{Scheme: "https", Host: "evil.example.com"}))
+
+		viper.Set("Client.VerifyServerIdentity", true)
+		assert.NoError(t, checkServerAllowed(ctx, &url.URL{Scheme: "unix", Path: "/tmp/cache.sock"}))
+		err := (&untrustedServerError{Host: "evil.example.com"})
+		assert.True(t, errors.Is(err, &untrustedServerError{}))
+		assert.False(t, IsRetryable(err))
+	})
+}
@@ -0,0 +1,180 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/error_codes"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+// The longest the client waits between checks of a stage request
+const tapeStagePollMaxDelay = time.Minute
+
+func newStageRequest(ctx context.Context, method, reqUrl, token, project string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, reqUrl, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("User-Agent", getUserAgent(project))
+	return req, nil
+}
+
+func stageStatusError(resp *http.Response, action string) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return &HttpErrResp{resp.StatusCode, fmt.Sprintf("Failed to %s (HTTP status %d): %s", action, resp.StatusCode, string(msg))}
+}
+
+// Ask the origin to stage the object from tape, returning the ID of the stage request
+func submitTapeStage(ctx context.Context, client *http.Client, stageUrl *url.URL, objectPath, token, project string) (string, error) {
+	body, err := json.Marshal(map[string][]string{"paths": {objectPath}})
+	if err != nil {
+		return "", err
+	}
+	req, err := newStageRequest(ctx, http.MethodPost, stageUrl.String(), token, project, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return "", stageStatusError(resp, "submit the stage request")
+	}
+	result := struct {
+		ID string `json:"id"`
+	}{}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil || result.ID == "" {
+		return "", errors.New("the origin did not return the ID of the stage request")
+	}
+	return result.ID, nil
+}
+
+// Get the status of a stage request from the origin
+func getTapeStageStatus(ctx context.Context, client *http.Client, statusUrl *url.URL, token, project string) (*server_structs.TapeStageStatus, error) {
+	req, err := newStageRequest(ctx, http.MethodGet, statusUrl.String(), token, project, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, stageStatusError(resp, "get the status of the stage request")
+	}
+	status := &server_structs.TapeStageStatus{}
+	if err = json.NewDecoder(resp.Body).Decode(status); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the status of the stage request")
+	}
+	return status, nil
+}
+
+// Whether all the objects of the stage request are online, or the error staging one of them
+func tapeStageOnline(status *server_structs.TapeStageStatus) (bool, error) {
+	online := len(status.Files) > 0
+	for _, file := range status.Files {
+		if file.Error != "" {
+			return false, errors.Errorf("failed to stage %s from tape: %s", file.Path, file.Error)
+		}
+		online = online && file.OnDisk
+	}
+	return online, nil
+}
+
+// For objects of tape-backed namespaces, ask the origin to stage the object and wait, backing
+// off between checks, until it's online or Client.TapeStageTimeout passes.  If the stage API
+// can't be used (e.g. there's no token), the download proceeds and waits on the origin's 202s.
+func stageFromTape(transfer *transferFile) error {
+	if transfer.job == nil || !transfer.job.namespace.TapeBacked || transfer.job.namespace.StageUrl == "" || transfer.token == "" {
+		return nil
+	}
+	ctx := transfer.ctx
+	objectPath := transfer.remoteURL.Path
+	stageUrl, err := url.Parse(transfer.job.namespace.StageUrl)
+	if err != nil {
+		log.Warningln("The director returned an invalid stage URL; downloading without staging first:", err)
+		return nil
+	}
+	if err = checkServerAllowed(ctx, stageUrl); err != nil {
+		return err
+	}
+	client := &http.Client{Transport: config.GetTransport()}
+	id, err := submitTapeStage(ctx, client, stageUrl, objectPath, transfer.token, transfer.project)
+	if err != nil {
+		log.Warningf("Failed to ask the origin to stage %s from tape; downloading without staging first: %v", objectPath, err)
+		return nil
+	}
+	log.Debugf("Submitted stage request %s for %s", id, objectPath)
+	if !param.Client_WaitForTapeStaging.GetBool() {
+		return error_codes.NewTransfer_StagingError(&StagingError{})
+	}
+
+	statusUrl := stageUrl.JoinPath(id)
+	timeout := param.Client_TapeStageTimeout.GetDuration()
+	deadline := time.Now().Add(timeout)
+	delay := time.Second
+	for {
+		status, err := getTapeStageStatus(ctx, client, statusUrl, transfer.token, transfer.project)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Warningf("Failed to check the stage request for %s; downloading without waiting for it: %v", objectPath, err)
+			return nil
+		}
+		online, err := tapeStageOnline(status)
+		if err != nil {
+			return err
+		} else if online {
+			log.Debugf("%s is online", objectPath)
+			return nil
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return error_codes.NewTransfer_StagingError(errors.Wrapf(&StagingError{}, "the object was not staged from tape within %s", timeout))
+		}
+		delay = min(delay, remaining)
+		log.Infof("%s is being staged from tape; checking again in %s", objectPath, delay)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, tapeStagePollMaxDelay)
+	}
+}
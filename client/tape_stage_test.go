@@ -0,0 +1,136 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+// A stage API whose single stage request is online after onlineAfter status checks
+type fakeStageServer struct {
+	mutex       sync.Mutex
+	staged      []string
+	checks      int
+	onlineAfter int
+	stageError  string
+}
+
+func (s *fakeStageServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if r.Header.Get("Authorization") != "Bearer stage-token" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		body := struct {
+			Paths []string `json:"paths"`
+		}{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		s.staged = append(s.staged, body.Paths...)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]string{"id": "req-1"})
+	case http.MethodGet:
+		if r.URL.Path != server_structs.TapeStagePath+"/req-1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		s.checks++
+		file := server_structs.TapeStageFile{Path: "/data/object.txt", OnDisk: s.checks > s.onlineAfter, Error: s.stageError}
+		_ = json.NewEncoder(w).Encode(server_structs.TapeStageStatus{ID: "req-1", Files: []server_structs.TapeStageFile{file}})
+	}
+}
+
+func TestStageFromTape(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("Client.TapeStageTimeout", "10s")
+	viper.Set("Client.WaitForTapeStaging", true)
+
+	newTransfer := func(server *httptest.Server, token string) *transferFile {
+		job := &TransferJob{}
+		job.namespace.TapeBacked = true
+		job.namespace.StageUrl = server.URL + server_structs.TapeStagePath
+		return &transferFile{
+			ctx:       context.Background(),
+			job:       job,
+			remoteURL: &url.URL{Scheme: "pelican", Host: "federation.example.com", Path: "/tape/object.txt"},
+			token:     token,
+		}
+	}
+
+	t.Run("waits-until-online", func(t *testing.T) {
+		fake := &fakeStageServer{onlineAfter: 1}
+		server := httptest.NewServer(fake)
+		t.Cleanup(server.Close)
+
+		require.NoError(t, stageFromTape(newTransfer(server, "stage-token")))
+		assert.Equal(t, []string{"/tape/object.txt"}, fake.staged)
+		assert.Equal(t, 2, fake.checks)
+	})
+
+	t.Run("no-wait", func(t *testing.T) {
+		viper.Set("Client.WaitForTapeStaging", false)
+		t.Cleanup(func() { viper.Set("Client.WaitForTapeStaging", true) })
+		fake := &fakeStageServer{onlineAfter: 1}
+		server := httptest.NewServer(fake)
+		t.Cleanup(server.Close)
+
+		err := stageFromTape(newTransfer(server, "stage-token"))
+		require.Error(t, err)
+		assert.ErrorIs(t, err, &StagingError{})
+		assert.True(t, IsRetryable(err))
+		assert.Equal(t, []string{"/tape/object.txt"}, fake.staged)
+		assert.Zero(t, fake.checks)
+	})
+
+	t.Run("stage-failed", func(t *testing.T) {
+		fake := &fakeStageServer{stageError: "tape unavailable"}
+		server := httptest.NewServer(fake)
+		t.Cleanup(server.Close)
+
+		err := stageFromTape(newTransfer(server, "stage-token"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "tape unavailable")
+	})
+
+	// Without a usable stage API, the download proceeds and relies on the origin's 202s
+	t.Run("stage-api-rejected", func(t *testing.T) {
+		fake := &fakeStageServer{}
+		server := httptest.NewServer(fake)
+		t.Cleanup(server.Close)
+
+		assert.NoError(t, stageFromTape(newTransfer(server, "other-token")))
+		assert.NoError(t, stageFromTape(newTransfer(server, "")))
+		assert.Empty(t, fake.staged)
+	})
+}
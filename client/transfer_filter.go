@@ -0,0 +1,164 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type (
+	// A glob pattern of an include or exclude filter, matched rsync-style:
+	//   - a pattern without a '/' matches the last component of a path,
+	//   - a pattern with a '/' matches the end of a path, or all of it if
+	//     the pattern starts with '/',
+	//   - '**' matches any number of path components, and
+	//   - a pattern ending in '/' only matches directories.
+	filterPattern struct {
+		segments []string
+		anchored bool
+		basename bool
+		dirOnly  bool
+	}
+
+	// The include and exclude filters of a recursive transfer.  A path is
+	// skipped if it matches an exclude pattern and no include pattern; if
+	// there are only include patterns, the files matching none are skipped.
+	// An include pattern ending in '/' includes the contents of the
+	// directories it matches.
+	transferFilter struct {
+		includes []filterPattern
+		excludes []filterPattern
+	}
+)
+
+func newFilterPattern(pattern string) (fp filterPattern, err error) {
+	fp.dirOnly = strings.HasSuffix(pattern, "/")
+	fp.anchored = strings.HasPrefix(pattern, "/")
+	trimmed := strings.Trim(pattern, "/")
+	if trimmed == "" {
+		return fp, errors.Errorf("invalid filter pattern %q", pattern)
+	}
+	fp.basename = !fp.anchored && !strings.Contains(trimmed, "/")
+	fp.segments = strings.Split(trimmed, "/")
+	for _, segment := range fp.segments {
+		if _, err = path.Match(segment, ""); err != nil {
+			return fp, errors.Wrapf(err, "invalid filter pattern %q", pattern)
+		}
+	}
+	return
+}
+
+// Match the segments of a pattern against the segments of a path
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for idx := 0; idx <= len(segments); idx++ {
+			if matchSegments(pattern[1:], segments[idx:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if matched, _ := path.Match(pattern[0], segments[0]); !matched {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
+
+// Whether the pattern matches a path relative to the root of the transfer
+func (fp filterPattern) match(relPath string, isDir bool) bool {
+	if fp.dirOnly && !isDir {
+		return false
+	}
+	segments := strings.Split(strings.Trim(relPath, "/"), "/")
+	if fp.basename {
+		return matchSegments(fp.segments, segments[len(segments)-1:])
+	}
+	if fp.anchored {
+		return matchSegments(fp.segments, segments)
+	}
+	for idx := range segments {
+		if matchSegments(fp.segments, segments[idx:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func newTransferFilter(includes, excludes []string) (*transferFilter, error) {
+	if len(includes) == 0 && len(excludes) == 0 {
+		return nil, nil
+	}
+	filter := &transferFilter{}
+	for _, pattern := range includes {
+		fp, err := newFilterPattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		filter.includes = append(filter.includes, fp)
+	}
+	for _, pattern := range excludes {
+		fp, err := newFilterPattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		filter.excludes = append(filter.excludes, fp)
+	}
+	return filter, nil
+}
+
+// Whether a file or directory, given by its path relative to the root of
+// the transfer, is skipped by the filter.  Directories are only skipped by
+// the patterns ending in '/'; otherwise they're walked to find the files
+// within them.
+func (filter *transferFilter) skip(relPath string, isDir bool) bool {
+	if filter == nil {
+		return false
+	}
+	for _, fp := range filter.includes {
+		if fp.match(relPath, isDir) {
+			return false
+		}
+		// A directory pattern includes everything within the directories it matches
+		if fp.dirOnly {
+			for dir := path.Dir(strings.Trim(relPath, "/")); dir != "."; dir = path.Dir(dir) {
+				if fp.match(dir, true) {
+					return false
+				}
+			}
+		}
+	}
+	for _, fp := range filter.excludes {
+		if fp.match(relPath, isDir) && (fp.dirOnly || !isDir) {
+			return true
+		}
+	}
+	if isDir {
+		return false
+	}
+	return len(filter.excludes) == 0 && len(filter.includes) > 0
+}
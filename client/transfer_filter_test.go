@@ -0,0 +1,154 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferFilter(t *testing.T) {
+	type check struct {
+		path  string
+		isDir bool
+		skip  bool
+	}
+	tests := []struct {
+		name     string
+		includes []string
+		excludes []string
+		checks   []check
+	}{
+		{
+			name:     "only-includes",
+			includes: []string{"*.root"},
+			checks: []check{
+				{path: "a.root"},
+				{path: "run1/deep/b.root"},
+				{path: "run1/c.txt", skip: true},
+				// Directories are walked to find the matching files
+				{path: "run1", isDir: true},
+			},
+		},
+		{
+			name:     "include-then-exclude-all",
+			includes: []string{"*.root"},
+			excludes: []string{"*"},
+			checks: []check{
+				{path: "run1/b.root"},
+				{path: "run1/c.txt", skip: true},
+				{path: "run1", isDir: true},
+			},
+		},
+		{
+			name:     "only-excludes",
+			excludes: []string{"*.tmp", "logs/"},
+			checks: []check{
+				{path: "a.root"},
+				{path: "run1/a.tmp", skip: true},
+				{path: "run1/logs", isDir: true, skip: true},
+				// Only directories match a pattern ending in '/'
+				{path: "run1/logs"},
+			},
+		},
+		{
+			name:     "patterns-with-slashes",
+			excludes: []string{"/top.txt", "run*/raw/*.dat", "calib/**/*.json"},
+			checks: []check{
+				{path: "top.txt", skip: true},
+				{path: "sub/top.txt"},
+				{path: "run1/raw/a.dat", skip: true},
+				{path: "data/run1/raw/a.dat", skip: true},
+				{path: "run1/raw/more/a.dat"},
+				{path: "calib/a.json", skip: true},
+				{path: "calib/x/y/a.json", skip: true},
+				{path: "calib/x/y/a.txt"},
+			},
+		},
+		{
+			name:     "include-directory-contents",
+			includes: []string{"run2/"},
+			checks: []check{
+				{path: "run2/a.dat"},
+				{path: "run2/sub/b.dat"},
+				{path: "run1/a.dat", skip: true},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filter, err := newTransferFilter(test.includes, test.excludes)
+			require.NoError(t, err)
+			for _, c := range test.checks {
+				assert.Equal(t, c.skip, filter.skip(c.path, c.isDir), "path %s (directory: %v)", c.path, c.isDir)
+			}
+		})
+	}
+
+	t.Run("no-filter", func(t *testing.T) {
+		filter, err := newTransferFilter(nil, nil)
+		require.NoError(t, err)
+		assert.Nil(t, filter)
+		assert.False(t, filter.skip("a.root", false))
+	})
+
+	t.Run("invalid-patterns", func(t *testing.T) {
+		_, err := newTransferFilter([]string{"[a-"}, nil)
+		assert.Error(t, err)
+		_, err = newTransferFilter(nil, []string{"/"})
+		assert.Error(t, err)
+	})
+}
+
+func TestWalkDirUploadFilter(t *testing.T) {
+	localDir := t.TempDir()
+	for _, name := range []string{"a.root", "b.txt", "run1/c.root", "run1/d.txt", "logs/e.root"} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(localDir, name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(localDir, name), []byte("data"), 0644))
+	}
+
+	filter, err := newTransferFilter([]string{"*.root"}, []string{"logs/", "*"})
+	require.NoError(t, err)
+	job := &clientTransferJob{job: &TransferJob{
+		ctx:       context.Background(),
+		localPath: localDir,
+		remoteURL: &url.URL{Path: "/test/dataset"},
+		upload:    true,
+		filter:    filter,
+	}}
+	files := make(chan *clientTransferFile, 10)
+	te := &TransferEngine{}
+	require.NoError(t, te.walkDirUpload(job, []transferAttemptDetails{{}}, files, localDir))
+	close(files)
+
+	var remotePaths []string
+	for file := range files {
+		remotePaths = append(remotePaths, file.file.remoteURL.Path)
+	}
+	sort.Strings(remotePaths)
+	assert.Equal(t, []string{"/test/dataset/a.root", "/test/dataset/run1/c.root"}, remotePaths)
+	assert.Equal(t, int64(2), job.job.activeXfer.Load())
+}
@@ -0,0 +1,190 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+)
+
+type (
+	// The description of a transfer given to the pre- and post-transfer hooks,
+	// as environment variables to commands and as JSON to webhooks
+	transferHookEvent struct {
+		Stage     string `json:"stage"`
+		Direction string `json:"direction"`
+		Source    string `json:"source"`
+		Dest      string `json:"dest"`
+		Result    string `json:"result,omitempty"`
+		Error     string `json:"error,omitempty"`
+		Bytes     *int64 `json:"bytes,omitempty"`
+		Checksum  string `json:"checksum,omitempty"`
+	}
+)
+
+const (
+	hookStagePre  = "pre"
+	hookStagePost = "post"
+)
+
+// Describe a transfer for the hooks of the given stage
+func newTransferHookEvent(transfer *transferFile, stage string) transferHookEvent {
+	// Don't pass on any authorization in the query of the URL
+	remote := *transfer.remoteURL
+	remote.RawQuery = ""
+	event := transferHookEvent{Stage: stage, Direction: "download", Source: remote.String(), Dest: transfer.localPath}
+	if transfer.upload {
+		event.Direction = "upload"
+		event.Source, event.Dest = transfer.localPath, remote.String()
+	}
+	return event
+}
+
+func (event transferHookEvent) environ() []string {
+	env := []string{
+		"PELICAN_HOOK_STAGE=" + event.Stage,
+		"PELICAN_TRANSFER_DIRECTION=" + event.Direction,
+		"PELICAN_TRANSFER_SOURCE=" + event.Source,
+		"PELICAN_TRANSFER_DEST=" + event.Dest,
+	}
+	if event.Stage == hookStagePost {
+		env = append(env, "PELICAN_TRANSFER_RESULT="+event.Result, "PELICAN_TRANSFER_ERROR="+event.Error,
+			"PELICAN_TRANSFER_CHECKSUM="+event.Checksum)
+		if event.Bytes != nil {
+			env = append(env, "PELICAN_TRANSFER_BYTES="+strconv.FormatInt(*event.Bytes, 10))
+		}
+	}
+	return env
+}
+
+func runHookCommand(ctx context.Context, command []string, event transferHookEvent) error {
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Env = append(os.Environ(), event.environ()...)
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return errors.Errorf("%s-transfer command %s timed out after %s", event.Stage, command[0], param.Client_TransferHookTimeout.GetDuration())
+	}
+	if err != nil {
+		return errors.Wrapf(err, "%s-transfer command %s failed (output: %s)", event.Stage, command[0], strings.TrimSpace(string(output)))
+	}
+	log.Debugf("The %s-transfer command %s succeeded: %s", event.Stage, command[0], strings.TrimSpace(string(output)))
+	return nil
+}
+
+func callHookWebhook(ctx context.Context, webhookUrl string, event transferHookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode the transfer for the webhook")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookUrl, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrapf(err, "failed to create the %s-transfer webhook request", event.Stage)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", getUserAgent(""))
+	client := &http.Client{Transport: config.GetTransport()}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "%s-transfer webhook %s failed", event.Stage, webhookUrl)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("%s-transfer webhook %s responded with status %d: %s", event.Stage, webhookUrl, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+// Run the configured command and webhook of the event's stage, returning the first error
+func runTransferHooks(ctx context.Context, event transferHookEvent) error {
+	command := param.Client_PreTransferCommand.GetStringSlice()
+	webhookUrl := param.Client_PreTransferWebhook.GetString()
+	if event.Stage == hookStagePost {
+		command = param.Client_PostTransferCommand.GetStringSlice()
+		webhookUrl = param.Client_PostTransferWebhook.GetString()
+	}
+	if len(command) == 0 && webhookUrl == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, param.Client_TransferHookTimeout.GetDuration())
+	defer cancel()
+	if len(command) > 0 {
+		if err := runHookCommand(ctx, command, event); err != nil {
+			return err
+		}
+	}
+	if webhookUrl != "" {
+		if err := callHookWebhook(ctx, webhookUrl, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasPostTransferHooks() bool {
+	return len(param.Client_PostTransferCommand.GetStringSlice()) > 0 || param.Client_PostTransferWebhook.GetString() != ""
+}
+
+// Run the pre-transfer hooks; if they fail, the object must not be transferred
+func runPreTransferHooks(transfer *transferFile) error {
+	if err := runTransferHooks(transfer.ctx, newTransferHookEvent(transfer, hookStagePre)); err != nil {
+		return errors.Wrapf(err, "not transferring %s", transfer.remoteURL.Path)
+	}
+	return nil
+}
+
+// Run the post-transfer hooks with the result of the transfer.  Their failures
+// are logged rather than failing the transfer, which has already happened.
+func runPostTransferHooks(transfer *transferFile, results TransferResults) {
+	if !hasPostTransferHooks() {
+		return
+	}
+	event := newTransferHookEvent(transfer, hookStagePost)
+	event.Result = "success"
+	if results.Error != nil {
+		event.Result = "failure"
+		event.Error = results.Error.Error()
+	}
+	event.Bytes = &results.TransferredBytes
+	if results.Error == nil && transfer.localPath != "" {
+		if checksum, err := checksumFile(transfer.localPath); err == nil {
+			event.Checksum = "sha256:" + checksum
+		} else {
+			log.Debugf("Failed to checksum %s for the post-transfer hooks: %v", transfer.localPath, err)
+		}
+	}
+	// The transfer's context may be done (e.g., the transfer was cancelled) but the
+	// hooks should still learn about it
+	if err := runTransferHooks(context.WithoutCancel(transfer.ctx), event); err != nil {
+		log.Warningf("Post-transfer hook for %s failed: %v", transfer.remoteURL.Path, err)
+	}
+}
@@ -0,0 +1,145 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The hook commands are shell scripts")
+	}
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("Client.TransferHookTimeout", "10s")
+
+	localPath := filepath.Join(t.TempDir(), "object.txt")
+	require.NoError(t, os.WriteFile(localPath, []byte("hello"), 0644))
+	transfer := &transferFile{
+		ctx:       context.Background(),
+		remoteURL: &url.URL{Scheme: "pelican", Host: "federation.example.com", Path: "/first/object.txt", RawQuery: "authz=secret"},
+		localPath: localPath,
+	}
+
+	var eventsLock sync.Mutex
+	events := []transferHookEvent{}
+	webhookStatus := http.StatusOK
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		eventsLock.Lock()
+		defer eventsLock.Unlock()
+		event := transferHookEvent{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		events = append(events, event)
+		w.WriteHeader(webhookStatus)
+	}))
+	t.Cleanup(webhook.Close)
+
+	t.Run("pre-transfer-command", func(t *testing.T) {
+		envFile := filepath.Join(t.TempDir(), "env")
+		viper.Set("Client.PreTransferCommand", []string{"sh", "-c", "env | grep ^PELICAN_ > " + envFile})
+		t.Cleanup(func() { viper.Set("Client.PreTransferCommand", nil) })
+
+		require.NoError(t, runPreTransferHooks(transfer))
+		env, err := os.ReadFile(envFile)
+		require.NoError(t, err)
+		assert.Contains(t, string(env), "PELICAN_HOOK_STAGE=pre\n")
+		assert.Contains(t, string(env), "PELICAN_TRANSFER_DIRECTION=download\n")
+		assert.Contains(t, string(env), "PELICAN_TRANSFER_SOURCE=pelican://federation.example.com/first/object.txt\n")
+		assert.Contains(t, string(env), "PELICAN_TRANSFER_DEST="+localPath+"\n")
+		assert.NotContains(t, string(env), "secret")
+		assert.NotContains(t, string(env), "PELICAN_TRANSFER_RESULT")
+	})
+
+	t.Run("failed-pre-transfer-command", func(t *testing.T) {
+		viper.Set("Client.PreTransferCommand", []string{"sh", "-c", "echo catalogue is down; exit 3"})
+		t.Cleanup(func() { viper.Set("Client.PreTransferCommand", nil) })
+
+		err := runPreTransferHooks(transfer)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "catalogue is down")
+	})
+
+	t.Run("pre-transfer-webhook", func(t *testing.T) {
+		viper.Set("Client.PreTransferWebhook", webhook.URL)
+		t.Cleanup(func() { viper.Set("Client.PreTransferWebhook", "") })
+
+		require.NoError(t, runPreTransferHooks(transfer))
+		webhookStatus = http.StatusServiceUnavailable
+		t.Cleanup(func() { webhookStatus = http.StatusOK })
+		assert.Error(t, runPreTransferHooks(transfer))
+	})
+
+	t.Run("post-transfer-hooks", func(t *testing.T) {
+		eventsLock.Lock()
+		events = nil
+		eventsLock.Unlock()
+		envFile := filepath.Join(t.TempDir(), "env")
+		viper.Set("Client.PostTransferCommand", []string{"sh", "-c", "env | grep ^PELICAN_ >> " + envFile})
+		viper.Set("Client.PostTransferWebhook", webhook.URL)
+		t.Cleanup(func() {
+			viper.Set("Client.PostTransferCommand", nil)
+			viper.Set("Client.PostTransferWebhook", "")
+		})
+
+		upload := *transfer
+		upload.upload = true
+		runPostTransferHooks(&upload, TransferResults{TransferredBytes: 5})
+		runPostTransferHooks(transfer, TransferResults{Error: errors.New("no caches available")})
+
+		env, err := os.ReadFile(envFile)
+		require.NoError(t, err)
+		runs := strings.Split(string(env), "PELICAN_HOOK_STAGE=")
+		require.Len(t, runs, 3)
+		assert.Contains(t, runs[1], "PELICAN_TRANSFER_DIRECTION=upload\n")
+		assert.Contains(t, runs[1], "PELICAN_TRANSFER_RESULT=success\n")
+		assert.Contains(t, runs[1], "PELICAN_TRANSFER_BYTES=5\n")
+		// The SHA-256 checksum of "hello"
+		assert.Contains(t, runs[1], "PELICAN_TRANSFER_CHECKSUM=sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824\n")
+		assert.Contains(t, runs[2], "PELICAN_TRANSFER_RESULT=failure\n")
+		assert.Contains(t, runs[2], "PELICAN_TRANSFER_ERROR=no caches available\n")
+
+		eventsLock.Lock()
+		defer eventsLock.Unlock()
+		require.Len(t, events, 2)
+		assert.Equal(t, "post", events[0].Stage)
+		assert.Equal(t, localPath, events[0].Source)
+		assert.Equal(t, "pelican://federation.example.com/first/object.txt", events[0].Dest)
+		assert.Equal(t, "success", events[0].Result)
+		require.NotNil(t, events[0].Bytes)
+		assert.Equal(t, int64(5), *events[0].Bytes)
+		assert.Equal(t, "failure", events[1].Result)
+		assert.Empty(t, events[1].Checksum)
+	})
+}
@@ -0,0 +1,358 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+type (
+	// Configuration of a directory watcher; see WatchDirectory
+	WatchConfig struct {
+		// How often the directory is scanned for new or changed files
+		Interval time.Duration
+		// Path of the journal of completed uploads.  Defaults to
+		// DefaultWatchJournalName inside the watched directory.
+		JournalPath string
+		// How many times a failed upload is retried before the file is skipped
+		// until it changes again.  Zero means retry forever.
+		MaxRetries int
+		// Scan the directory once, wait for the uploads, and return
+		Once bool
+		// Options passed to each upload
+		Options []TransferOption
+	}
+
+	// A completed upload, recorded as one JSON line in the journal
+	WatchJournalEntry struct {
+		Path       string    `json:"path"` // Path relative to the watched directory
+		Remote     string    `json:"remote"`
+		Size       int64     `json:"size"`
+		ModTime    time.Time `json:"modTime"`
+		Checksum   string    `json:"sha256"`
+		UploadedAt time.Time `json:"uploadedAt"`
+	}
+
+	// The observed state of a file that hasn't been uploaded yet
+	watchedFile struct {
+		size        int64
+		modTime     time.Time
+		stable      bool // Unchanged between two consecutive scans
+		failures    int
+		nextAttempt time.Time
+		gaveUp      bool
+	}
+
+	directoryWatcher struct {
+		localDir  string
+		remoteUrl string
+		config    WatchConfig
+		journal   map[string]WatchJournalEntry
+		pending   map[string]*watchedFile
+
+		// Uploads a single file; replaced in unit tests
+		upload func(ctx context.Context, localPath, remoteUrl string, options ...TransferOption) error
+	}
+)
+
+const (
+	DefaultWatchJournalName = ".pelican-watch-journal"
+
+	maxWatchRetryDelay = 10 * time.Minute
+)
+
+func uploadWatchedFile(ctx context.Context, localPath, remoteUrl string, options ...TransferOption) error {
+	_, err := DoPut(ctx, localPath, remoteUrl, false, options...)
+	return err
+}
+
+func newDirectoryWatcher(localDir, remoteUrl string, config WatchConfig) (*directoryWatcher, error) {
+	info, err := os.Stat(localDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to access the watched directory")
+	}
+	if !info.IsDir() {
+		return nil, errors.Errorf("%s is not a directory", localDir)
+	}
+	if config.Interval <= 0 {
+		config.Interval = 10 * time.Second
+	}
+	if config.JournalPath == "" {
+		config.JournalPath = filepath.Join(localDir, DefaultWatchJournalName)
+	}
+	watcher := &directoryWatcher{
+		localDir:  localDir,
+		remoteUrl: strings.TrimSuffix(remoteUrl, "/"),
+		config:    config,
+		journal:   make(map[string]WatchJournalEntry),
+		pending:   make(map[string]*watchedFile),
+		upload:    uploadWatchedFile,
+	}
+	if err := watcher.loadJournal(); err != nil {
+		return nil, err
+	}
+	return watcher, nil
+}
+
+// Read the journal of completed uploads; later entries for a path replace earlier ones
+func (w *directoryWatcher) loadJournal() error {
+	file, err := os.Open(w.config.JournalPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "failed to open the upload journal")
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		entry := WatchJournalEntry{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			// A partially-written last line is expected if the watcher was killed mid-write
+			log.Warningf("Ignoring invalid entry on line %d of the upload journal %s: %v", lineNum, w.config.JournalPath, err)
+			continue
+		}
+		w.journal[entry.Path] = entry
+	}
+	return errors.Wrap(scanner.Err(), "failed to read the upload journal")
+}
+
+func (w *directoryWatcher) appendJournal(entry WatchJournalEntry) error {
+	file, err := os.OpenFile(w.config.JournalPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Wrap(err, "failed to open the upload journal")
+	}
+	defer file.Close()
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return errors.Wrap(err, "failed to write to the upload journal")
+	}
+	w.journal[entry.Path] = entry
+	return nil
+}
+
+func checksumFile(localPath string) (string, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Returns the files that have not changed since the previous scan and are not yet uploaded
+func (w *directoryWatcher) scan() ([]string, error) {
+	journalPath, _ := filepath.Abs(w.config.JournalPath)
+	seen := make(map[string]bool)
+	ready := []string{}
+	err := filepath.WalkDir(w.localDir, func(localPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			log.Warningf("Unable to scan %s: %v", localPath, err)
+			return nil
+		}
+		// Hidden files and directories are skipped; instruments commonly write
+		// to a hidden temporary file and rename it once complete
+		if localPath != w.localDir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if absPath, _ := filepath.Abs(localPath); absPath == journalPath {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		relPath, err := filepath.Rel(w.localDir, localPath)
+		if err != nil {
+			return nil
+		}
+		relPath = filepath.ToSlash(relPath)
+		seen[relPath] = true
+
+		if entry, ok := w.journal[relPath]; ok && entry.Size == info.Size() && entry.ModTime.Equal(info.ModTime()) {
+			delete(w.pending, relPath)
+			return nil
+		}
+		state, ok := w.pending[relPath]
+		if !ok || state.size != info.Size() || !state.modTime.Equal(info.ModTime()) {
+			// New or still being written; wait for it to settle
+			w.pending[relPath] = &watchedFile{size: info.Size(), modTime: info.ModTime()}
+			if !w.config.Once {
+				return nil
+			}
+			state = w.pending[relPath]
+		}
+		state.stable = true
+		if !state.gaveUp && !time.Now().Before(state.nextAttempt) {
+			ready = append(ready, relPath)
+		}
+		return nil
+	})
+	// Forget files that were removed before being uploaded
+	for relPath := range w.pending {
+		if !seen[relPath] {
+			delete(w.pending, relPath)
+		}
+	}
+	return ready, err
+}
+
+// Upload a file that has settled, unless the journal shows identical contents were already uploaded
+func (w *directoryWatcher) processFile(ctx context.Context, relPath string) error {
+	localPath := filepath.Join(w.localDir, filepath.FromSlash(relPath))
+	state := w.pending[relPath]
+	checksum, err := checksumFile(localPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to checksum %s", localPath)
+	}
+	entry := WatchJournalEntry{
+		Path:     relPath,
+		Remote:   w.remoteUrl + "/" + path.Clean(relPath),
+		Size:     state.size,
+		ModTime:  state.modTime,
+		Checksum: checksum,
+	}
+
+	if prev, ok := w.journal[relPath]; ok && prev.Checksum == checksum {
+		// Touched but unchanged; record the new timestamp so it isn't checksummed again
+		log.Debugf("Skipping %s: its contents were already uploaded to %s", localPath, prev.Remote)
+		entry.UploadedAt = prev.UploadedAt
+	} else {
+		log.Infof("Uploading %s to %s", localPath, entry.Remote)
+		if err := w.upload(ctx, localPath, entry.Remote, w.config.Options...); err != nil {
+			return err
+		}
+		entry.UploadedAt = time.Now()
+	}
+	delete(w.pending, relPath)
+	return w.appendJournal(entry)
+}
+
+// Scan the directory once and upload the files that are ready
+func (w *directoryWatcher) runOnce(ctx context.Context) (failed int, err error) {
+	ready, err := w.scan()
+	if err != nil {
+		return 0, err
+	}
+	for _, relPath := range ready {
+		if ctx.Err() != nil {
+			return failed, ctx.Err()
+		}
+		if err := w.processFile(ctx, relPath); err != nil {
+			failed++
+			state, ok := w.pending[relPath]
+			if !ok {
+				continue
+			}
+			state.failures++
+			if w.config.MaxRetries > 0 && state.failures > w.config.MaxRetries {
+				state.gaveUp = true
+				log.Errorf("Giving up on %s after %d failed attempts; it will be retried if it changes: %v", relPath, state.failures, err)
+				continue
+			}
+			delay := w.config.Interval << state.failures
+			if delay > maxWatchRetryDelay || delay <= 0 {
+				delay = maxWatchRetryDelay
+			}
+			state.nextAttempt = time.Now().Add(delay)
+			log.Warningf("Failed to upload %s (attempt %d); retrying in %s: %v", relPath, state.failures, delay.String(), err)
+		}
+	}
+	return failed, nil
+}
+
+// Watch a local directory and upload new or changed files to the remote URL,
+// preserving the directory structure beneath it.
+//
+// A file is uploaded once it has stopped changing between two consecutive scans.
+// Completed uploads are recorded in a journal, so files aren't uploaded again when
+// the watcher restarts, and files whose contents are unchanged (by SHA-256 checksum)
+// are not re-uploaded.  Failed uploads are retried with an exponential backoff.
+//
+// Runs until the context is cancelled, or after one scan if config.Once is set.
+func WatchDirectory(ctx context.Context, localDir, remoteUrl string, config WatchConfig) error {
+	watcher, err := newDirectoryWatcher(localDir, remoteUrl, config)
+	if err != nil {
+		return err
+	}
+	return watcher.run(ctx)
+}
+
+func (w *directoryWatcher) run(ctx context.Context) error {
+	if w.config.Once {
+		failed, err := w.runOnce(ctx)
+		if err != nil {
+			return err
+		}
+		if failed > 0 {
+			return errors.Errorf("%d file(s) failed to upload", failed)
+		}
+		return nil
+	}
+
+	log.Infof("Watching %s for new files to upload to %s", w.localDir, w.remoteUrl)
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := w.runOnce(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Warningln("Failed to scan the watched directory:", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
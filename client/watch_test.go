@@ -0,0 +1,118 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A watcher whose uploads are recorded instead of sent to a federation
+func newTestWatcher(t *testing.T, dir string, config WatchConfig) (*directoryWatcher, *[]string) {
+	watcher, err := newDirectoryWatcher(dir, "pelican://example.org/data/", config)
+	require.NoError(t, err)
+	uploads := []string{}
+	watcher.upload = func(ctx context.Context, localPath, remoteUrl string, options ...TransferOption) error {
+		uploads = append(uploads, remoteUrl)
+		return nil
+	}
+	return watcher, &uploads
+}
+
+func TestWatchDirectory(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("uploads-settled-files", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "run1"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "run1", "a.dat"), []byte("a"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ".partial.dat"), []byte("tmp"), 0644))
+		watcher, uploads := newTestWatcher(t, dir, WatchConfig{})
+
+		// The first scan only observes the file
+		_, err := watcher.runOnce(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, *uploads)
+
+		_, err = watcher.runOnce(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"pelican://example.org/data/run1/a.dat"}, *uploads)
+
+		// Already uploaded; the journal isn't uploaded either
+		_, err = watcher.runOnce(ctx)
+		require.NoError(t, err)
+		_, err = watcher.runOnce(ctx)
+		require.NoError(t, err)
+		assert.Len(t, *uploads, 1)
+	})
+
+	t.Run("journal-survives-restart-and-dedups", func(t *testing.T) {
+		dir := t.TempDir()
+		filename := filepath.Join(dir, "a.dat")
+		require.NoError(t, os.WriteFile(filename, []byte("a"), 0644))
+		watcher, uploads := newTestWatcher(t, dir, WatchConfig{Once: true})
+		require.NoError(t, watcher.run(ctx))
+		assert.Len(t, *uploads, 1)
+
+		// Touch the file without changing it, then restart the watcher
+		later := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(filename, later, later))
+		restarted, restartedUploads := newTestWatcher(t, dir, WatchConfig{Once: true})
+		require.NoError(t, restarted.run(ctx))
+		assert.Empty(t, *restartedUploads)
+		assert.True(t, restarted.journal["a.dat"].ModTime.Equal(later))
+
+		// Changed contents are uploaded again
+		require.NoError(t, os.WriteFile(filename, []byte("b"), 0644))
+		require.NoError(t, restarted.run(ctx))
+		assert.Len(t, *restartedUploads, 1)
+	})
+
+	t.Run("failed-uploads-are-retried", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "a.dat"), []byte("a"), 0644))
+		watcher, err := newDirectoryWatcher(dir, "pelican://example.org/data", WatchConfig{Once: true, Interval: time.Millisecond, MaxRetries: 2})
+		require.NoError(t, err)
+		attempts := 0
+		watcher.upload = func(ctx context.Context, localPath, remoteUrl string, options ...TransferOption) error {
+			attempts++
+			return errors.New("upload failed")
+		}
+
+		// One attempt plus two retries, then the file is skipped
+		for i := 0; i < 5; i++ {
+			if i < 3 {
+				assert.Error(t, watcher.run(ctx))
+			} else {
+				assert.NoError(t, watcher.run(ctx))
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert.Equal(t, 3, attempts)
+		assert.True(t, watcher.pending["a.dat"].gaveUp)
+		assert.Empty(t, watcher.journal)
+	})
+}
@@ -0,0 +1,152 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/studio-b12/gowebdav"
+
+	"github.com/pelicanplatform/pelican/config"
+)
+
+// Whether the source of a copy is an HTTP(S) endpoint outside of the federation, like an
+// institutional WebDAV server, rather than a local file
+func isWebDAVSource(scheme string) bool {
+	return scheme == "https" || scheme == "http"
+}
+
+// Create a WebDAV client for the server of a third-party URL.  Credentials in the URL's
+// user info are sent with HTTP basic authentication.
+func newWebDAVSourceClient(source *url.URL) *gowebdav.Client {
+	root := url.URL{Scheme: source.Scheme, Host: source.Host}
+	var client *gowebdav.Client
+	if source.User != nil {
+		password, _ := source.User.Password()
+		client = gowebdav.NewClient(root.String(), source.User.Username(), password)
+	} else {
+		client = gowebdav.NewClient(root.String(), "", "")
+	}
+	client.SetHeader("User-Agent", getUserAgent(""))
+	client.SetTransport(config.GetTransport())
+	return client
+}
+
+// List the objects beneath a directory of a WebDAV server, returning their paths on
+// the server
+func listWebDAVObjects(ctx context.Context, client *gowebdav.Client, remotePath string) ([]string, error) {
+	// The client does not respect the context, so check for cancelation between listings
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	infos, err := client.ReadDir(remotePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list %s", remotePath)
+	}
+	objects := make([]string, 0, len(infos))
+	for _, info := range infos {
+		objectPath := path.Join(remotePath, info.Name())
+		if info.IsDir() {
+			children, err := listWebDAVObjects(ctx, client, objectPath)
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, children...)
+		} else {
+			objects = append(objects, objectPath)
+		}
+	}
+	return objects, nil
+}
+
+// Download an object of a WebDAV server to a local file
+func downloadWebDAVObject(client *gowebdav.Client, remotePath, localPath string) error {
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return errors.Wrapf(err, "failed to create the directory of %s", localPath)
+	}
+	reader, err := client.ReadStream(remotePath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to download %s", remotePath)
+	}
+	defer reader.Close()
+	file, err := os.OpenFile(localPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(file, reader); err != nil {
+		file.Close()
+		return errors.Wrapf(err, "failed to download %s", remotePath)
+	}
+	return file.Close()
+}
+
+// Download the object or, for recursive copies, the directory at a WebDAV URL into the
+// staging directory so it can be uploaded into the federation.  Returns the local path
+// of the staged object or directory.
+func stageWebDAVSource(ctx context.Context, source *url.URL, recursive bool, stagingDir string) (string, error) {
+	client := newWebDAVSourceClient(source)
+	remotePath := path.Clean("/" + source.Path)
+	localRoot := filepath.Join(stagingDir, path.Base(remotePath))
+	if remotePath == "/" {
+		localRoot = filepath.Join(stagingDir, source.Hostname())
+	}
+
+	info, err := client.Stat(remotePath)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to stat %s", source.Redacted())
+	}
+	if !info.IsDir() {
+		return localRoot, downloadWebDAVObject(client, remotePath, localRoot)
+	}
+	if !recursive {
+		return "", errors.Errorf("%s is a directory; use a recursive copy to ingest it", source.Redacted())
+	}
+
+	objects, err := listWebDAVObjects(ctx, client, remotePath)
+	if err != nil {
+		return "", err
+	}
+	log.Debugf("Staging %d objects from %s for upload", len(objects), source.Redacted())
+	if err = os.MkdirAll(localRoot, 0755); err != nil {
+		return "", err
+	}
+	prefix := strings.TrimSuffix(remotePath, "/") + "/"
+	for _, object := range objects {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		// Guard against listings naming objects outside of the directory
+		if !strings.HasPrefix(object, prefix) {
+			return "", errors.Errorf("the listing of %s has an object outside of it: %s", source.Redacted(), object)
+		}
+		relative := strings.TrimPrefix(object, prefix)
+		if err = downloadWebDAVObject(client, object, filepath.Join(localRoot, filepath.FromSlash(relative))); err != nil {
+			return "", err
+		}
+	}
+	return localRoot, nil
+}
@@ -0,0 +1,94 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/webdav"
+)
+
+func TestStageWebDAVSource(t *testing.T) {
+	ctx := context.Background()
+	fs := webdav.NewMemFS()
+	require.NoError(t, fs.Mkdir(ctx, "/data", 0755))
+	require.NoError(t, fs.Mkdir(ctx, "/data/sub", 0755))
+	for name, contents := range map[string]string{"/data/a.txt": "alpha", "/data/sub/b.txt": "bravo"} {
+		file, err := fs.OpenFile(ctx, name, os.O_CREATE|os.O_WRONLY, 0644)
+		require.NoError(t, err)
+		_, err = file.Write([]byte(contents))
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+	}
+	requireAuth := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); requireAuth && (!ok || user != "alice" || password != "secret") {
+			w.Header().Set("WWW-Authenticate", `Basic realm="webdav"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		(&webdav.Handler{FileSystem: fs, LockSystem: webdav.NewMemLS()}).ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	t.Run("stages-a-directory", func(t *testing.T) {
+		source := *serverURL
+		source.Path = "/data"
+		source.User = url.UserPassword("alice", "secret")
+		requireAuth = true
+		t.Cleanup(func() { requireAuth = false })
+		localPath, err := stageWebDAVSource(ctx, &source, true, t.TempDir())
+		require.NoError(t, err)
+		assert.Equal(t, "data", filepath.Base(localPath))
+
+		contents, err := os.ReadFile(filepath.Join(localPath, "a.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "alpha", string(contents))
+		contents, err = os.ReadFile(filepath.Join(localPath, "sub", "b.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "bravo", string(contents))
+	})
+
+	t.Run("stages-an-object", func(t *testing.T) {
+		source := *serverURL
+		source.Path = "/data/sub/b.txt"
+		localPath, err := stageWebDAVSource(ctx, &source, false, t.TempDir())
+		require.NoError(t, err)
+		contents, err := os.ReadFile(localPath)
+		require.NoError(t, err)
+		assert.Equal(t, "bravo", string(contents))
+	})
+
+	t.Run("requires-recursive-for-directories", func(t *testing.T) {
+		source := *serverURL
+		source.Path = "/data"
+		_, err := stageWebDAVSource(ctx, &source, false, t.TempDir())
+		assert.ErrorContains(t, err, "recursive")
+	})
+}
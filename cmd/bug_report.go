@@ -0,0 +1,103 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/pelicanplatform/pelican/client"
+	"github.com/pelicanplatform/pelican/config"
+)
+
+var (
+	bugReportCmd = &cobra.Command{
+		Use:   "bug-report [object URL ...]",
+		Short: "Collect the client's configuration and logs into a bundle for a support ticket",
+		Long: `Collect the client's version, configuration and environment (with credentials
+redacted), and recent logs into a gzip-compressed tarball to attach to a
+support ticket.  If object URLs are given, their metadata is looked up and
+the resulting debug trace and director responses are included as well.`,
+		RunE: bugReportMain,
+	}
+)
+
+func init() {
+	flagSet := bugReportCmd.Flags()
+	flagSet.StringP("output", "o", "", "The file to write the bug report to; defaults to a timestamped file in the current directory")
+	flagSet.String("log-file", "", "A log file to include in the bug report; defaults to Logging.LogLocation")
+	flagSet.StringP("token", "t", "", "Token file to use when looking up the objects")
+}
+
+// Add the flag enabling the bug report on fatal transfer errors to a transfer command
+func addBugReportFlag(flagSet *pflag.FlagSet) {
+	flagSet.Bool("bug-report", false, "On a fatal error, write a bug report bundle with the transfer's debug trace to the current directory")
+}
+
+// Start capturing the debug trace if the bug report on failure was requested
+func startFailureBugReport(cmd *cobra.Command) {
+	if enabled, _ := cmd.Flags().GetBool("bug-report"); enabled {
+		client.StartBugReportCapture()
+	}
+}
+
+// Write the bug report for a fatal transfer error if it was requested
+func writeFailureBugReport(cmd *cobra.Command, transferErr error) {
+	if enabled, _ := cmd.Flags().GetBool("bug-report"); !enabled {
+		return
+	}
+	if path, err := client.CreateBugReport("", client.BugReportOptions{Error: transferErr, Args: os.Args}); err != nil {
+		log.Errorln("Failed to write the bug report:", err)
+	} else {
+		log.Errorf("A bug report was written to %s; please attach it to your support ticket", path)
+	}
+}
+
+func bugReportMain(cmd *cobra.Command, args []string) error {
+	if err := config.InitClient(); err != nil {
+		return errors.Wrap(err, "Failed to initialize the client")
+	}
+	client.StartBugReportCapture()
+
+	tokenLocation, _ := cmd.Flags().GetString("token")
+	var lookupErr error
+	for _, object := range args {
+		log.Infoln("Looking up", object)
+		if _, err := client.DoStat(cmd.Context(), object, client.WithTokenLocation(tokenLocation)); err != nil {
+			log.Errorf("Failed to look up %s: %v", object, err)
+			if lookupErr == nil {
+				lookupErr = errors.Wrapf(err, "failed to look up %s", object)
+			}
+		}
+	}
+
+	output, _ := cmd.Flags().GetString("output")
+	logFile, _ := cmd.Flags().GetString("log-file")
+	path, err := client.CreateBugReport(output, client.BugReportOptions{Error: lookupErr, LogFile: logFile, Args: os.Args})
+	if err != nil {
+		return err
+	}
+	fmt.Println("The bug report was written to", path)
+	return nil
+}
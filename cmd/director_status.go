@@ -0,0 +1,129 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/director"
+)
+
+var (
+	directorStatusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Show the version, configuration, and state of a director",
+		Long: `Show the support information of a director: its version and build commit,
+uptime, enabled features, key (non-secret) configuration values, and the number of
+servers, downtimes, and other entries it holds in memory.
+
+The director is given with --server, or else found from the federation.  The
+request is authorized by a token signed with the director's own issuer key, so it
+must be run with access to that key (for example, on the director host or with
+--privkey).`,
+		RunE:         showDirectorStatus,
+		SilenceUsage: true,
+	}
+
+	directorStatusServer  string
+	directorStatusJson    bool
+	directorStatusPrivkey string
+)
+
+// Print the keys of a map with their values, sorted by key
+func printSortedValues[V any](w io.Writer, values map[string]V) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, "  %s\t%v\n", key, values[key])
+	}
+}
+
+func printSupportInfo(out io.Writer, directorUrl string, info *director.SupportInfo) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Director:\t%s\n", directorUrl)
+	fmt.Fprintf(w, "Version:\t%s (commit %s)\n", info.Version, info.Commit)
+	fmt.Fprintf(w, "Started:\t%s (up %s)\n", info.StartTime.Format(time.RFC3339), info.Uptime)
+	fmt.Fprintln(w, "Features:\t")
+	printSortedValues(w, info.Features)
+	fmt.Fprintln(w, "Configuration:\t")
+	printSortedValues(w, info.Config)
+	fmt.Fprintln(w, "In memory:\t")
+	fmt.Fprintf(w, "  Origins\t%d\n", info.Counts.Origins)
+	fmt.Fprintf(w, "  Caches\t%d\n", info.Counts.Caches)
+	fmt.Fprintf(w, "  Filtered servers\t%d\n", info.Counts.FilteredServers)
+	fmt.Fprintf(w, "  Downtimes\t%d\n", info.Counts.Downtimes)
+	fmt.Fprintf(w, "  Namespace keys\t%d\n", info.Counts.NamespaceKeys)
+	fmt.Fprintf(w, "  Health tests\t%d\n", info.Counts.HealthTests)
+	fmt.Fprintf(w, "  Redirect decisions\t%d\n", info.Counts.RedirectDecisions)
+	w.Flush()
+}
+
+func showDirectorStatus(cmd *cobra.Command, args []string) error {
+	// Set the key here rather than binding the flag, which other commands bind too
+	if directorStatusPrivkey != "" {
+		viper.Set("IssuerKey", directorStatusPrivkey)
+	}
+	if err := config.InitClient(); err != nil {
+		return errors.Wrap(err, "failed to initialize the client")
+	}
+	directorUrl := directorStatusServer
+	if directorUrl == "" {
+		var err error
+		if directorUrl, err = getDirectorUrl(cmd.Context()); err != nil {
+			return err
+		}
+	} else if !strings.Contains(directorUrl, "://") {
+		directorUrl = "https://" + directorUrl
+	}
+
+	info, err := director.GetSupportInfo(directorUrl)
+	if err != nil {
+		return err
+	}
+	if directorStatusJson {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(info)
+	}
+	printSupportInfo(os.Stdout, directorUrl, info)
+	return nil
+}
+
+func init() {
+	directorStatusCmd.Flags().StringVar(&directorStatusServer, "server", "", "URL of the director; defaults to the federation's director")
+	directorStatusCmd.Flags().BoolVar(&directorStatusJson, "json", false, "Print the support information as JSON")
+	directorStatusCmd.Flags().StringVar(&directorStatusPrivkey, "privkey", "", "Path to the director's private key")
+
+	directorCmd.AddCommand(directorStatusCmd)
+}
@@ -0,0 +1,413 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/director"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// A downtime window in a downtime file.  A window may list several servers,
+	// in which case one downtime is created per server.  Fields left empty are
+	// taken from the defaults of the file.
+	downtimeTemplate struct {
+		Server      string   `yaml:"server"`
+		Servers     []string `yaml:"servers"`
+		Description string   `yaml:"description"`
+		Start       string   `yaml:"start"`
+		End         string   `yaml:"end"`
+		Duration    string   `yaml:"duration"`
+	}
+
+	// The YAML (or JSON) file accepted by `pelican downtime create --file`
+	downtimeFile struct {
+		Defaults  downtimeTemplate   `yaml:"defaults"`
+		Downtimes []downtimeTemplate `yaml:"downtimes"`
+	}
+)
+
+var (
+	downtimeCmd = &cobra.Command{
+		Use:   "downtime",
+		Short: "Manage scheduled server downtimes at the director",
+		Long: `Manage scheduled downtimes of origins and caches at the director.
+
+While a downtime is active, the director does not redirect clients to the server.
+Creating and removing downtimes is an administrative operation: the request is
+authorized by a token signed with the director's own issuer key, so it must be run
+with access to that key (for example, on the director host or with --privkey).`,
+	}
+
+	downtimeListCmd = &cobra.Command{
+		Use:          "list",
+		Short:        "List the scheduled downtimes",
+		RunE:         listDowntimes,
+		SilenceUsage: true,
+	}
+
+	downtimeCreateCmd = &cobra.Command{
+		Use:   "create",
+		Short: "Schedule one or more downtimes",
+		Long: `Schedule a downtime for a single server with --server, --start and --end (or
+--duration), or schedule many downtimes at once with --file.
+
+The file is YAML or JSON and describes downtime windows across multiple servers, for
+example for coordinated site maintenance.  Fields omitted from a window are taken
+from "defaults":
+
+    defaults:
+      description: Site maintenance
+      start: 2024-06-01T08:00:00Z
+      duration: 4h
+    downtimes:
+      - servers: [cache1.example.org, cache2.example.org]
+      - server: origin.example.org
+        start: 2024-06-01T10:00:00Z
+        end: 2024-06-01T11:00:00Z
+
+The downtimes are applied transactionally: if any of them is invalid or overlaps an
+existing downtime of the same server, none are created.  Use --dry-run to validate
+the downtimes without creating them.`,
+		RunE:         createDowntimes,
+		SilenceUsage: true,
+	}
+
+	downtimeDeleteCmd = &cobra.Command{
+		Use:   "delete [id...]",
+		Short: "Remove scheduled downtimes",
+		Long: `Remove the scheduled downtimes with the given IDs, or all downtimes matching
+the --filter options.  Filters are given as key=value, may be repeated, and must all
+match.  The supported keys are:
+
+    server       A glob pattern matched against the server name, e.g. "cache*.example.org"
+    description  A substring of the downtime description
+    createdBy    The identity that created the downtime
+    status       "active" or "upcoming"
+
+Use --all to remove every scheduled downtime.`,
+		RunE:         deleteDowntimes,
+		SilenceUsage: true,
+	}
+
+	downtimeFilters     []string
+	downtimeFilePath    string
+	downtimeServer      string
+	downtimeDescription string
+	downtimeStart       string
+	downtimeEnd         string
+	downtimeDuration    string
+	downtimeDryRun      bool
+	downtimeDeleteAll   bool
+)
+
+func getDirectorUrl(ctx context.Context) (string, error) {
+	fedInfo, err := config.GetFederation(ctx)
+	if err != nil {
+		return "", err
+	}
+	if fedInfo.DirectorEndpoint == "" {
+		return "", errors.New("No director specified; either give the federation name (-f) or specify the director URL directly (e.g., --director-url=https://director.example.org)")
+	}
+	return fedInfo.DirectorEndpoint, nil
+}
+
+// Parse a time given as RFC 3339, or the special value "now"
+func parseDowntimeTime(value string, now time.Time) (time.Time, error) {
+	if value == "now" {
+		return now, nil
+	}
+	result, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "invalid time %q; expected RFC 3339 format such as 2006-01-02T15:04:05Z", value)
+	}
+	return result, nil
+}
+
+// Convert a downtime window into downtimes, one per server
+func (tmpl downtimeTemplate) expand(defaults downtimeTemplate, now time.Time) ([]server_structs.Downtime, error) {
+	servers := tmpl.Servers
+	if tmpl.Server != "" {
+		servers = append([]string{tmpl.Server}, servers...)
+	}
+	if len(servers) == 0 {
+		servers = defaults.Servers
+		if defaults.Server != "" {
+			servers = append([]string{defaults.Server}, servers...)
+		}
+	}
+	if len(servers) == 0 {
+		return nil, errors.New("no server given")
+	}
+	if tmpl.Description == "" {
+		tmpl.Description = defaults.Description
+	}
+	if tmpl.Start == "" {
+		tmpl.Start = defaults.Start
+	}
+	// An explicit end or duration in the window overrides both defaults
+	if tmpl.End == "" && tmpl.Duration == "" {
+		tmpl.End = defaults.End
+		tmpl.Duration = defaults.Duration
+	}
+
+	if tmpl.Start == "" {
+		return nil, errors.New("no start time given")
+	}
+	start, err := parseDowntimeTime(tmpl.Start, now)
+	if err != nil {
+		return nil, err
+	}
+	var end time.Time
+	if tmpl.End != "" && tmpl.Duration != "" {
+		return nil, errors.New("only one of end and duration may be given")
+	} else if tmpl.End != "" {
+		if end, err = parseDowntimeTime(tmpl.End, now); err != nil {
+			return nil, err
+		}
+	} else if tmpl.Duration != "" {
+		duration, err := time.ParseDuration(tmpl.Duration)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid duration %q", tmpl.Duration)
+		}
+		end = start.Add(duration)
+	} else {
+		return nil, errors.New("no end time or duration given")
+	}
+
+	result := make([]server_structs.Downtime, 0, len(servers))
+	for _, server := range servers {
+		result = append(result, server_structs.Downtime{
+			ServerName:  server,
+			Description: tmpl.Description,
+			StartTime:   start,
+			EndTime:     end,
+		})
+	}
+	return result, nil
+}
+
+// Read the downtimes described by a YAML or JSON downtime file
+func readDowntimeFile(filename string, now time.Time) ([]server_structs.Downtime, error) {
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	file := downtimeFile{}
+	decoder := yaml.NewDecoder(strings.NewReader(string(contents)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse downtime file %s", filename)
+	}
+	if len(file.Downtimes) == 0 {
+		return nil, errors.Errorf("downtime file %s contains no downtimes", filename)
+	}
+	result := make([]server_structs.Downtime, 0, len(file.Downtimes))
+	for idx, tmpl := range file.Downtimes {
+		expanded, err := tmpl.expand(file.Defaults, now)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid downtime %d in %s", idx, filename)
+		}
+		result = append(result, expanded...)
+	}
+	return result, nil
+}
+
+// Convert the key=value filters given on the command line into query parameters
+func parseDowntimeFilters(filters []string) (url.Values, error) {
+	result := url.Values{}
+	for _, filter := range filters {
+		key, value, found := strings.Cut(filter, "=")
+		if !found || key == "" {
+			return nil, errors.Errorf("invalid filter %q; expected key=value", filter)
+		}
+		switch key {
+		case "server", "description", "createdBy", "status":
+		default:
+			return nil, errors.Errorf("unknown filter key %q", key)
+		}
+		result.Set(key, value)
+	}
+	return result, nil
+}
+
+func printDowntimes(downtimes []server_structs.Downtime) {
+	if len(downtimes) == 0 {
+		fmt.Println("No downtimes")
+		return
+	}
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSERVER\tSTART\tEND\tSTATUS\tDESCRIPTION")
+	for _, dt := range downtimes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", dt.ID, dt.ServerName, dt.StartTime.Format(time.RFC3339),
+			dt.EndTime.Format(time.RFC3339), dt.Status(now), dt.Description)
+	}
+	w.Flush()
+}
+
+func listDowntimes(cmd *cobra.Command, args []string) error {
+	if err := config.InitClient(); err != nil {
+		return errors.Wrap(err, "failed to initialize the client")
+	}
+	directorUrl, err := getDirectorUrl(cmd.Context())
+	if err != nil {
+		return err
+	}
+	filter, err := parseDowntimeFilters(downtimeFilters)
+	if err != nil {
+		return err
+	}
+	downtimes, err := director.ListDowntimes(directorUrl, filter)
+	if err != nil {
+		return err
+	}
+	printDowntimes(downtimes)
+	return nil
+}
+
+func createDowntimes(cmd *cobra.Command, args []string) error {
+	now := time.Now()
+	var downtimes []server_structs.Downtime
+	if downtimeFilePath != "" {
+		if downtimeServer != "" || downtimeStart != "" || downtimeEnd != "" || downtimeDuration != "" {
+			return errors.New("--file can't be combined with --server, --start, --end, or --duration")
+		}
+		var err error
+		if downtimes, err = readDowntimeFile(downtimeFilePath, now); err != nil {
+			return err
+		}
+	} else {
+		if downtimeStart == "" {
+			downtimeStart = "now"
+		}
+		tmpl := downtimeTemplate{
+			Server:      downtimeServer,
+			Description: downtimeDescription,
+			Start:       downtimeStart,
+			End:         downtimeEnd,
+			Duration:    downtimeDuration,
+		}
+		var err error
+		if downtimes, err = tmpl.expand(downtimeTemplate{}, now); err != nil {
+			return errors.Wrap(err, "invalid downtime")
+		}
+	}
+
+	if err := config.InitClient(); err != nil {
+		return errors.Wrap(err, "failed to initialize the client")
+	}
+	directorUrl, err := getDirectorUrl(cmd.Context())
+	if err != nil {
+		return err
+	}
+	created, err := director.CreateDowntimes(directorUrl, downtimes, downtimeDryRun)
+	if err != nil {
+		return errors.Wrap(err, "failed to create the downtimes; no downtimes were created")
+	}
+	if downtimeDryRun {
+		fmt.Printf("Dry run: %d downtime(s) would be created\n", len(created))
+	} else {
+		fmt.Printf("Created %d downtime(s)\n", len(created))
+	}
+	printDowntimes(created)
+	return nil
+}
+
+func deleteDowntimes(cmd *cobra.Command, args []string) error {
+	if len(args) > 0 && (len(downtimeFilters) > 0 || downtimeDeleteAll) {
+		return errors.New("downtime IDs can't be combined with --filter or --all")
+	}
+	if len(args) == 0 && len(downtimeFilters) == 0 && !downtimeDeleteAll {
+		return errors.New("give the IDs of the downtimes to remove, --filter, or --all")
+	}
+	filter, err := parseDowntimeFilters(downtimeFilters)
+	if err != nil {
+		return err
+	}
+	if downtimeDeleteAll {
+		filter.Set("all", "true")
+	}
+
+	if err := config.InitClient(); err != nil {
+		return errors.Wrap(err, "failed to initialize the client")
+	}
+	directorUrl, err := getDirectorUrl(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	var removed []server_structs.Downtime
+	if len(args) > 0 {
+		for _, id := range args {
+			result, err := director.DeleteDowntime(directorUrl, id)
+			if err != nil {
+				return errors.Wrapf(err, "failed to remove downtime %s", id)
+			}
+			removed = append(removed, result...)
+		}
+	} else if removed, err = director.DeleteDowntimes(directorUrl, filter); err != nil {
+		return errors.Wrap(err, "failed to remove the downtimes")
+	}
+	fmt.Printf("Removed %d downtime(s)\n", len(removed))
+	printDowntimes(removed)
+	return nil
+}
+
+func init() {
+	downtimeListCmd.Flags().StringArrayVar(&downtimeFilters, "filter", nil, "Only list downtimes matching the key=value filter; may be repeated")
+
+	downtimeCreateCmd.Flags().StringVar(&downtimeFilePath, "file", "", "YAML or JSON file describing the downtimes to create")
+	downtimeCreateCmd.Flags().StringVar(&downtimeServer, "server", "", "Name of the server to put in downtime")
+	downtimeCreateCmd.Flags().StringVar(&downtimeDescription, "description", "", "Description of the downtime")
+	downtimeCreateCmd.Flags().StringVar(&downtimeStart, "start", "", "Start of the downtime in RFC 3339 format (default \"now\")")
+	downtimeCreateCmd.Flags().StringVar(&downtimeEnd, "end", "", "End of the downtime in RFC 3339 format")
+	downtimeCreateCmd.Flags().StringVar(&downtimeDuration, "duration", "", "Duration of the downtime, e.g. \"2h\"")
+	downtimeCreateCmd.Flags().BoolVar(&downtimeDryRun, "dry-run", false, "Validate the downtimes without creating them")
+
+	downtimeDeleteCmd.Flags().StringArrayVar(&downtimeFilters, "filter", nil, "Remove downtimes matching the key=value filter; may be repeated")
+	downtimeDeleteCmd.Flags().BoolVar(&downtimeDeleteAll, "all", false, "Remove all scheduled downtimes")
+
+	downtimeCmd.PersistentFlags().String("director-url", "", "URL of the director")
+	if err := viper.BindPFlag("Federation.DirectorUrl", downtimeCmd.PersistentFlags().Lookup("director-url")); err != nil {
+		panic(err)
+	}
+	downtimeCmd.PersistentFlags().String("privkey", "", "Path to the director's private key")
+	if err := viper.BindPFlag("IssuerKey", downtimeCmd.PersistentFlags().Lookup("privkey")); err != nil {
+		panic(err)
+	}
+
+	downtimeCmd.AddCommand(downtimeListCmd)
+	downtimeCmd.AddCommand(downtimeCreateCmd)
+	downtimeCmd.AddCommand(downtimeDeleteCmd)
+}
@@ -0,0 +1,106 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadDowntimeFile(t *testing.T) {
+	now := time.Now()
+	start := time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC)
+
+	writeFile := func(t *testing.T, contents string) string {
+		filename := filepath.Join(t.TempDir(), "downtimes.yaml")
+		require.NoError(t, os.WriteFile(filename, []byte(contents), 0644))
+		return filename
+	}
+
+	t.Run("defaults-and-expansion", func(t *testing.T) {
+		filename := writeFile(t, `
+defaults:
+  description: Site maintenance
+  start: 2024-06-01T08:00:00Z
+  duration: 4h
+downtimes:
+  - servers: [cache1.example.org, cache2.example.org]
+  - server: origin.example.org
+    start: 2024-06-01T10:00:00Z
+    end: 2024-06-01T11:00:00Z
+    description: Kernel upgrade
+`)
+		result, err := readDowntimeFile(filename, now)
+		require.NoError(t, err)
+		require.Len(t, result, 3)
+
+		assert.Equal(t, "cache1.example.org", result[0].ServerName)
+		assert.Equal(t, "cache2.example.org", result[1].ServerName)
+		for _, dt := range result[:2] {
+			assert.Equal(t, "Site maintenance", dt.Description)
+			assert.True(t, start.Equal(dt.StartTime))
+			assert.True(t, start.Add(4*time.Hour).Equal(dt.EndTime))
+		}
+
+		assert.Equal(t, "origin.example.org", result[2].ServerName)
+		assert.Equal(t, "Kernel upgrade", result[2].Description)
+		assert.True(t, start.Add(2*time.Hour).Equal(result[2].StartTime))
+		assert.True(t, start.Add(3*time.Hour).Equal(result[2].EndTime))
+	})
+
+	t.Run("json-is-accepted", func(t *testing.T) {
+		filename := writeFile(t, `{"downtimes": [{"server": "cache1", "start": "now", "duration": "1h"}]}`)
+		result, err := readDowntimeFile(filename, now)
+		require.NoError(t, err)
+		require.Len(t, result, 1)
+		assert.True(t, now.Equal(result[0].StartTime))
+		assert.True(t, now.Add(time.Hour).Equal(result[0].EndTime))
+	})
+
+	t.Run("invalid-files", func(t *testing.T) {
+		for name, contents := range map[string]string{
+			"no-downtimes":     "defaults:\n  duration: 1h\n",
+			"no-server":        "downtimes:\n  - start: now\n    duration: 1h\n",
+			"no-end":           "downtimes:\n  - server: cache1\n    start: now\n",
+			"end-and-duration": "downtimes:\n  - server: cache1\n    start: now\n    end: 2024-06-01T10:00:00Z\n    duration: 1h\n",
+			"bad-time":         "downtimes:\n  - server: cache1\n    start: tomorrow\n    duration: 1h\n",
+			"unknown-field":    "downtimes:\n  - server: cache1\n    start: now\n    duration: 1h\n    reason: typo\n",
+		} {
+			_, err := readDowntimeFile(writeFile(t, contents), now)
+			assert.Error(t, err, name)
+		}
+	})
+}
+
+func TestParseDowntimeFilters(t *testing.T) {
+	filter, err := parseDowntimeFilters([]string{"server=cache*", "status=active"})
+	require.NoError(t, err)
+	assert.Equal(t, "cache*", filter.Get("server"))
+	assert.Equal(t, "active", filter.Get("status"))
+
+	_, err = parseDowntimeFilters([]string{"server"})
+	assert.Error(t, err)
+	_, err = parseDowntimeFilters([]string{"color=blue"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE transfers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    operation TEXT NOT NULL,
+    source TEXT NOT NULL,
+    destination TEXT NOT NULL,
+    recursive BOOLEAN NOT NULL DEFAULT FALSE,
+    token_location TEXT NOT NULL DEFAULT '',
+    preferred_cache TEXT NOT NULL DEFAULT '',
+    working_dir TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    objects INTEGER NOT NULL DEFAULT 0,
+    bytes INTEGER NOT NULL DEFAULT 0,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    servers TEXT NOT NULL DEFAULT '',
+    rerun_of INTEGER,
+    started_at DATETIME NOT NULL,
+    ended_at DATETIME NOT NULL
+);
+CREATE INDEX idx_transfers_started_at ON transfers(started_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS transfers;
+-- +goose StatementEnd
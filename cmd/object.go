@@ -19,7 +19,13 @@
 package main
 
 import (
+	"time"
+
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	"github.com/pelicanplatform/pelican/client"
 )
 
 var (
@@ -28,3 +34,63 @@ var (
 		Short: "Interact with objects in the federation",
 	}
 )
+
+func init() {
+	objectCmd.PersistentFlags().Bool("no-announcements", false, "Don't fetch or show the federation's announcements")
+	if err := viper.BindPFlag("Client.DisableAnnouncements", objectCmd.PersistentFlags().Lookup("no-announcements")); err != nil {
+		panic(err)
+	}
+}
+
+func addTimeoutFlags(flagSet *pflag.FlagSet) {
+	flagSet.Duration("timeout", 0, "Wall-clock deadline for the whole command, across all its objects and retries (e.g. 2h); 0 for none")
+	flagSet.Duration("transfer-timeout", 0, "Wall-clock limit for each object, across all its retries (e.g. 10m); 0 for none")
+}
+
+// Get the transfer options for the deadlines set by the flags of addTimeoutFlags.  The
+// deadline of --timeout starts when this is called and is shared by all the transfers.
+func getTimeoutOptions(cmd *cobra.Command) (options []client.TransferOption) {
+	if timeout, _ := cmd.Flags().GetDuration("timeout"); timeout > 0 {
+		options = append(options, client.WithDeadline(time.Now().Add(timeout)))
+	}
+	if timeout, _ := cmd.Flags().GetDuration("transfer-timeout"); timeout > 0 {
+		options = append(options, client.WithTransferTimeout(timeout))
+	}
+	return
+}
+
+func addFilterFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringArray("include", nil, "For recursive transfers, only transfer the files matching this glob pattern (e.g. '*.root'); may be repeated")
+	flagSet.StringArray("exclude", nil, "For recursive transfers, skip the files matching this glob pattern (e.g. '*.tmp', or 'logs/' for a directory); may be repeated")
+}
+
+// Get the transfer options for the filters set by the flags of addFilterFlags, which
+// only apply to recursive transfers (via --recursive or the ?recursive query)
+func getFilterOptions(cmd *cobra.Command) (options []client.TransferOption) {
+	includes, _ := cmd.Flags().GetStringArray("include")
+	excludes, _ := cmd.Flags().GetStringArray("exclude")
+	if len(includes) > 0 {
+		options = append(options, client.WithIncludes(includes...))
+	}
+	if len(excludes) > 0 {
+		options = append(options, client.WithExcludes(excludes...))
+	}
+	return
+}
+
+func addSourceFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("cache-only", false, "Only download from caches, failing rather than falling back to the origin")
+	cmd.Flags().Bool("origin-only", false, "Download directly from the origin, bypassing the caches for the freshest copy")
+	cmd.MarkFlagsMutuallyExclusive("cache-only", "origin-only")
+}
+
+// Get the transfer options for the download sources set by the flags of addSourceFlags
+func getSourceOptions(cmd *cobra.Command) (options []client.TransferOption) {
+	if cacheOnly, _ := cmd.Flags().GetBool("cache-only"); cacheOnly {
+		options = append(options, client.WithCacheOnly(true))
+	}
+	if originOnly, _ := cmd.Flags().GetBool("origin-only"); originOnly {
+		options = append(options, client.WithOriginOnly(true))
+	}
+	return
+}
@@ -27,6 +27,7 @@ import (
 
 	"github.com/pelicanplatform/pelican/client"
 	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/error_codes"
 	"github.com/pelicanplatform/pelican/namespaces"
 	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/utils"
@@ -57,6 +58,11 @@ func init() {
 	flagSet.BoolP("recursive", "r", false, "Recursively copy a directory.  Forces methods to only be http to get the freshest directory contents")
 	flagSet.StringP("cache-list-name", "n", "xroot", "(Deprecated) Cache list to use, currently either xroot or xroots; may be ignored")
 	flagSet.Lookup("cache-list-name").Hidden = true
+	addBugReportFlag(flagSet)
+	addTimeoutFlags(flagSet)
+	addFilterFlags(flagSet)
+	addSourceFlags(copyCmd)
+
 	// All the deprecated or hidden flags that are only relevant if we are in historical "stashcp mode"
 	if strings.HasPrefix(execName, "stashcp") {
 		copyCmd.Use = "stashcp {source ...} {destination}"
@@ -112,6 +118,9 @@ func copyMain(cmd *cobra.Command, args []string) {
 	pb := newProgressBar()
 	defer pb.shutdown()
 
+	startFailureBugReport(cmd)
+	client.ShowAnnouncements(ctx, os.Stderr)
+
 	tokenLocation, _ := cmd.Flags().GetString("token")
 
 	// Check if the program was executed from a terminal and does not specify a log location
@@ -197,12 +206,18 @@ func copyMain(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	options := append([]client.TransferOption{client.WithCallback(pb.callback), client.WithTokenLocation(tokenLocation), client.WithCaches(caches...)}, getTimeoutOptions(cmd)...)
+	options = append(options, getFilterOptions(cmd)...)
+	options = append(options, getSourceOptions(cmd)...)
 	var result error
 	lastSrc := ""
 
 	for _, src := range source {
 		isRecursive, _ := cmd.Flags().GetBool("recursive")
-		_, result = client.DoCopy(ctx, src, dest, isRecursive, client.WithCallback(pb.callback), client.WithTokenLocation(tokenLocation), client.WithCaches(caches...))
+		record := newTransferRecord("copy", src, dest, isRecursive, tokenLocation, preferredCache)
+		var results []client.TransferResults
+		results, result = client.DoCopy(ctx, src, dest, isRecursive, options...)
+		recordTransfer(record, results, result)
 		if result != nil {
 			lastSrc = src
 			break
@@ -211,6 +226,8 @@ func copyMain(cmd *cobra.Command, args []string) {
 
 	// Exit with failure
 	if result != nil {
+		writeFailureBugReport(cmd, result)
+
 		// Print the list of errors
 		errMsg := result.Error()
 		var te *client.TransferErrors
@@ -218,6 +235,10 @@ func copyMain(cmd *cobra.Command, args []string) {
 			errMsg = te.UserError()
 		}
 		log.Errorln("Failure transferring " + lastSrc + ": " + errMsg)
+		if errors.Is(result, &client.StagingError{}) {
+			log.Errorln("The object is being staged from tape; retry later")
+			os.Exit(error_codes.NewTransfer_StagingError(nil).ExitCode())
+		}
 		if client.ShouldRetry(err) {
 			log.Errorln("Errors are retryable")
 			os.Exit(11)
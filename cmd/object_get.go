@@ -48,6 +48,10 @@ func init() {
 	flagSet.StringP("cache-list-name", "n", "xroot", "(Deprecated) Cache list to use, currently either xroot or xroots; may be ignored")
 	flagSet.Lookup("cache-list-name").Hidden = true
 	flagSet.String("caches", "", "A JSON file containing the list of caches")
+	addBugReportFlag(flagSet)
+	addTimeoutFlags(flagSet)
+	addFilterFlags(flagSet)
+	addSourceFlags(getCmd)
 	objectCmd.AddCommand(getCmd)
 }
 
@@ -66,6 +70,9 @@ func getMain(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	startFailureBugReport(cmd)
+	client.ShowAnnouncements(ctx, os.Stderr)
+
 	tokenLocation, _ := cmd.Flags().GetString("token")
 
 	pb := newProgressBar()
@@ -116,12 +123,18 @@ func getMain(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	options := append([]client.TransferOption{client.WithCallback(pb.callback), client.WithTokenLocation(tokenLocation), client.WithCaches(caches...)}, getTimeoutOptions(cmd)...)
+	options = append(options, getFilterOptions(cmd)...)
+	options = append(options, getSourceOptions(cmd)...)
 	var result error
 	lastSrc := ""
 
 	for _, src := range source {
 		isRecursive, _ := cmd.Flags().GetBool("recursive")
-		_, result = client.DoGet(ctx, src, dest, isRecursive, client.WithCallback(pb.callback), client.WithTokenLocation(tokenLocation), client.WithCaches(caches...))
+		record := newTransferRecord("get", src, dest, isRecursive, tokenLocation, preferredCache)
+		var results []client.TransferResults
+		results, result = client.DoGet(ctx, src, dest, isRecursive, options...)
+		recordTransfer(record, results, result)
 		if result != nil {
 			lastSrc = src
 			break
@@ -130,6 +143,8 @@ func getMain(cmd *cobra.Command, args []string) {
 
 	// Exit with failure
 	if result != nil {
+		writeFailureBugReport(cmd, result)
+
 		// Print the list of errors
 		errMsg := result.Error()
 		var pe error_codes.PelicanError
@@ -141,6 +156,10 @@ func getMain(cmd *cobra.Command, args []string) {
 			errMsg = pe.Error()
 			log.Errorln("Failure getting " + lastSrc + ": " + errMsg)
 			os.Exit(pe.ExitCode())
+		} else if errors.Is(result, &client.StagingError{}) {
+			log.Errorln("Failure getting " + lastSrc + ": " + errMsg)
+			log.Errorln("The object is being staged from tape; retry later")
+			os.Exit(error_codes.NewTransfer_StagingError(nil).ExitCode())
 		} else { // For now, keeping this else here to catch any errors that are not classified PelicanErrors
 			log.Errorln("Failure getting " + lastSrc + ": " + errMsg)
 			if client.ShouldRetry(result) {
@@ -41,6 +41,11 @@ func init() {
 	flagSet := putCmd.Flags()
 	flagSet.StringP("token", "t", "", "Token file to use for transfer")
 	flagSet.BoolP("recursive", "r", false, "Recursively upload a directory.  Forces methods to only be http to get the freshest directory contents")
+	flagSet.Bool("resume", false, "Resume interrupted uploads of large files from the last chunk the origin received")
+	flagSet.Bool("writeback", false, "Upload to a nearby cache, which writes the objects to the origin later; they are only eventually visible at the origin")
+	addBugReportFlag(flagSet)
+	addTimeoutFlags(flagSet)
+	addFilterFlags(flagSet)
 	objectCmd.AddCommand(putCmd)
 }
 
@@ -58,8 +63,13 @@ func putMain(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	startFailureBugReport(cmd)
+	client.ShowAnnouncements(ctx, os.Stderr)
+
 	// Set the progress bars to the command line option
 	tokenLocation, _ := cmd.Flags().GetString("token")
+	resume, _ := cmd.Flags().GetBool("resume")
+	writeBack, _ := cmd.Flags().GetBool("writeback")
 
 	pb := newProgressBar()
 	defer pb.shutdown()
@@ -85,12 +95,17 @@ func putMain(cmd *cobra.Command, args []string) {
 	log.Debugln("Sources:", source)
 	log.Debugln("Destination:", dest)
 
+	options := append([]client.TransferOption{client.WithCallback(pb.callback), client.WithTokenLocation(tokenLocation), client.WithResume(resume), client.WithWriteBack(writeBack)}, getTimeoutOptions(cmd)...)
+	options = append(options, getFilterOptions(cmd)...)
 	var result error
 	lastSrc := ""
 
 	for _, src := range source {
 		isRecursive, _ := cmd.Flags().GetBool("recursive")
-		_, result = client.DoPut(ctx, src, dest, isRecursive, client.WithCallback(pb.callback), client.WithTokenLocation(tokenLocation))
+		record := newTransferRecord("put", src, dest, isRecursive, tokenLocation, "")
+		var results []client.TransferResults
+		results, result = client.DoPut(ctx, src, dest, isRecursive, options...)
+		recordTransfer(record, results, result)
 		if result != nil {
 			lastSrc = src
 			break
@@ -99,6 +114,8 @@ func putMain(cmd *cobra.Command, args []string) {
 
 	// Exit with failure
 	if result != nil {
+		writeFailureBugReport(cmd, result)
+
 		// Print the list of errors
 		errMsg := result.Error()
 		var te *client.TransferErrors
@@ -0,0 +1,96 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/pelicanplatform/pelican/client"
+	"github.com/pelicanplatform/pelican/config"
+)
+
+var (
+	watchCmd = &cobra.Command{
+		Use:   "watch {local directory} {destination}",
+		Short: "Upload new files from a local directory as they appear",
+		Long: `Watch a local directory and upload new or changed files to the destination,
+preserving the directory structure beneath it.  This is intended for "drop folders"
+where instruments continuously produce data.
+
+A file is uploaded once it has stopped changing between two consecutive scans of the
+directory.  Hidden files and directories (names starting with ".") are ignored, so
+writers can use a hidden temporary name and rename the file once it's complete.
+
+Completed uploads are recorded in a journal, by default ` + client.DefaultWatchJournalName + `
+in the watched directory, so files aren't uploaded again after a restart and files
+whose contents are unchanged are skipped.  Failed uploads are retried with an
+exponential backoff.`,
+		Args: cobra.ExactArgs(2),
+		Run:  watchMain,
+	}
+)
+
+func init() {
+	flagSet := watchCmd.Flags()
+	flagSet.StringP("token", "t", "", "Token file to use for transfer")
+	flagSet.Duration("interval", 0, "How often to scan the directory for new files (default 10s)")
+	flagSet.String("journal", "", "Path of the journal of completed uploads (default <local directory>/"+client.DefaultWatchJournalName+")")
+	flagSet.Int("max-retries", 5, "How many times to retry a failed upload before skipping the file until it changes; 0 retries forever")
+	flagSet.Bool("once", false, "Scan the directory once, upload the files found, and exit")
+	objectCmd.AddCommand(watchCmd)
+}
+
+func watchMain(cmd *cobra.Command, args []string) {
+	ctx, cancel := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	err := config.InitClient()
+	if err != nil {
+		log.Errorln(err)
+		if client.IsRetryable(err) {
+			log.Errorln("Errors are retryable")
+			os.Exit(11)
+		} else {
+			os.Exit(1)
+		}
+	}
+
+	tokenLocation, _ := cmd.Flags().GetString("token")
+	interval, _ := cmd.Flags().GetDuration("interval")
+	journal, _ := cmd.Flags().GetString("journal")
+	maxRetries, _ := cmd.Flags().GetInt("max-retries")
+	once, _ := cmd.Flags().GetBool("once")
+
+	watchConfig := client.WatchConfig{
+		Interval:    interval,
+		JournalPath: journal,
+		MaxRetries:  maxRetries,
+		Once:        once,
+		Options:     []client.TransferOption{client.WithTokenLocation(tokenLocation)},
+	}
+	if err := client.WatchDirectory(ctx, args[0], args[1], watchConfig); err != nil && ctx.Err() == nil {
+		log.Errorln("Failure watching "+args[0]+":", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,105 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/origin"
+)
+
+var (
+	originBackupCmd = &cobra.Command{
+		Use:   "backup",
+		Short: "Snapshot the origin's exports and restore them",
+	}
+
+	originBackupSnapshotCmd = &cobra.Command{
+		Use:   "snapshot",
+		Short: "Snapshot the origin's exports now",
+		Long: `Snapshot the metadata of the origin's exports into Origin.BackupLocation and, if
+Origin.BackupDestination is set, copy the objects that changed since the previous snapshot
+there, as the origin does every Origin.BackupInterval when Origin.EnableBackups is set.`,
+		RunE:         takeBackupSnapshot,
+		SilenceUsage: true,
+	}
+
+	originBackupRestoreCmd = &cobra.Command{
+		Use:   "restore",
+		Short: "Restore the objects of a snapshot",
+		Long: `Restore the objects of a snapshot from the destination they were copied to, or from
+--source, into the directory given by --target.  With --export, only the objects of that
+export are restored, directly under the target; otherwise each export is restored under the
+target at its federation prefix.  Each restored object is verified against its checksum in the
+snapshot, and objects already in the target with the right checksum are skipped.`,
+		RunE:         restoreBackupSnapshot,
+		SilenceUsage: true,
+	}
+
+	backupSnapshotFile string
+	backupExport       string
+	backupSource       string
+	backupTarget       string
+)
+
+func takeBackupSnapshot(cmd *cobra.Command, args []string) error {
+	if err := config.InitServer(cmd.Context(), config.OriginType); err != nil {
+		return errors.Wrap(err, "failed to initialize the origin's configuration")
+	}
+	snapshotFile, err := origin.TakeBackupSnapshot(cmd.Context())
+	if err != nil {
+		return err
+	}
+	fmt.Println("Saved the snapshot", snapshotFile)
+	return nil
+}
+
+func restoreBackupSnapshot(cmd *cobra.Command, args []string) error {
+	if backupSnapshotFile == "" {
+		return errors.New("a snapshot must be given with --snapshot")
+	}
+	if backupTarget == "" {
+		return errors.New("a directory to restore into must be given with --target")
+	}
+	if err := config.InitServer(cmd.Context(), config.OriginType); err != nil {
+		return errors.Wrap(err, "failed to initialize the origin's configuration")
+	}
+	snapshot, err := origin.ReadBackupSnapshot(backupSnapshotFile)
+	if err != nil {
+		return err
+	}
+	restored, err := origin.RestoreBackupSnapshot(cmd.Context(), snapshot, backupExport, backupSource, backupTarget)
+	fmt.Printf("Restored %d objects into %s\n", restored, backupTarget)
+	return err
+}
+
+func init() {
+	originBackupRestoreCmd.Flags().StringVar(&backupSnapshotFile, "snapshot", "", "The manifest of the snapshot to restore")
+	originBackupRestoreCmd.Flags().StringVar(&backupExport, "export", "", "The federation prefix of the only export to restore")
+	originBackupRestoreCmd.Flags().StringVar(&backupSource, "source", "", "Where to restore the objects from, if not the snapshot's destination")
+	originBackupRestoreCmd.Flags().StringVar(&backupTarget, "target", "", "The directory to restore the objects into")
+
+	originBackupCmd.AddCommand(originBackupSnapshotCmd)
+	originBackupCmd.AddCommand(originBackupRestoreCmd)
+	originCmd.AddCommand(originBackupCmd)
+}
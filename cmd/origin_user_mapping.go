@@ -0,0 +1,153 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/origin"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+var (
+	originUserMappingCmd = &cobra.Command{
+		Use:   "user-mapping",
+		Short: "Inspect the mapping of tokens to local users",
+	}
+
+	originUserMappingTestCmd = &cobra.Command{
+		Use:   "test",
+		Short: "Show the local user a token is mapped to, without writing anything",
+		Long: `Show the local user, and the resulting file ownership, that the origin maps a token to
+according to Origin.UserMapping, Origin.ScitokensNameMapFile, and the Origin.Scitokens*
+defaults.  The token is given as a file or as the token itself and is not verified.
+
+With --path, the rules are evaluated for a write to that federation path, and the owner
+and group of a new file at the corresponding location in a POSIX export are shown,
+taking directories with the setgid bit into account.`,
+		RunE:         testUserMapping,
+		SilenceUsage: true,
+	}
+
+	userMappingToken string
+	userMappingPath  string
+)
+
+// Find the local location of a federation path in the origin's POSIX exports
+func getExportLocalPath(exports []server_utils.OriginExport, objectPath string) (string, bool) {
+	var found *server_utils.OriginExport
+	for idx, export := range exports {
+		prefix := strings.TrimSuffix(export.FederationPrefix, "/")
+		if objectPath != prefix && !strings.HasPrefix(objectPath, prefix+"/") {
+			continue
+		}
+		if found == nil || len(export.FederationPrefix) > len(found.FederationPrefix) {
+			found = &exports[idx]
+		}
+	}
+	if found == nil {
+		return "", false
+	}
+	relPath := strings.TrimPrefix(objectPath, strings.TrimSuffix(found.FederationPrefix, "/"))
+	return filepath.Join(found.StoragePrefix, filepath.FromSlash(relPath)), true
+}
+
+func printUserMapping(out io.Writer, tok jwt.Token, objectPath string) error {
+	rules, err := origin.GetUserMappingRules()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Token subject: %s\n", tok.Subject())
+	if groups, ok := tok.Get("wlcg.groups"); ok {
+		fmt.Fprintf(out, "Token groups:  %v\n", groups)
+	}
+	mapping, err := origin.MapTokenToUser(tok, objectPath, rules)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Local user:    %s (from %s)\n", mapping.User, mapping.Source)
+
+	localPath := ""
+	if objectPath != "" && param.Origin_StorageType.GetString() == string(server_utils.OriginStoragePosix) {
+		exports, err := server_utils.GetOriginExports()
+		if err != nil {
+			return errors.Wrap(err, "failed to get the origin's exports")
+		}
+		var ok bool
+		if localPath, ok = getExportLocalPath(exports, objectPath); !ok {
+			return errors.Errorf("%s is not in any of the origin's exports", objectPath)
+		}
+		fmt.Fprintf(out, "Local path:    %s\n", localPath)
+	}
+
+	ownership, err := origin.GetNewFileOwnership(mapping.User, localPath)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "File owner:    uid %d, gid %d", ownership.Uid, ownership.Gid)
+	if ownership.SetgidDir != "" {
+		fmt.Fprintf(out, " (group inherited from the setgid directory %s)", ownership.SetgidDir)
+	}
+	fmt.Fprintln(out)
+	if !param.Origin_Multiuser.GetBool() {
+		fmt.Fprintln(out, "Warning: Origin.Multiuser is not set, so files are actually written as the XRootD user")
+	}
+	return nil
+}
+
+func testUserMapping(cmd *cobra.Command, args []string) error {
+	if err := config.InitServer(cmd.Context(), config.OriginType); err != nil {
+		return errors.Wrap(err, "failed to initialize the origin's configuration")
+	}
+	if userMappingToken == "" {
+		return errors.New("a token must be given with --token")
+	}
+	tokenStr := userMappingToken
+	if contents, err := os.ReadFile(userMappingToken); err == nil {
+		tokenStr = strings.TrimSpace(string(contents))
+	}
+	tok, err := jwt.ParseInsecure([]byte(tokenStr))
+	if err != nil {
+		return errors.Wrap(err, "failed to parse the token")
+	}
+	objectPath := ""
+	if userMappingPath != "" {
+		objectPath = path.Clean("/" + userMappingPath)
+	}
+	return printUserMapping(os.Stdout, tok, objectPath)
+}
+
+func init() {
+	originUserMappingTestCmd.Flags().StringVar(&userMappingToken, "token", "", "The token, or a file containing it")
+	originUserMappingTestCmd.Flags().StringVar(&userMappingPath, "path", "", "The federation path of a write to check the mapping for")
+
+	originUserMappingCmd.AddCommand(originUserMappingTestCmd)
+	originCmd.AddCommand(originUserMappingCmd)
+}
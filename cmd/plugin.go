@@ -238,25 +238,28 @@ func stashPluginMain(args []string) {
 			log.Errorln("Failed to read in from stdin:", err)
 			os.Exit(1)
 		}
-		workChan = make(chan PluginTransfer, len(transfers))
-		for _, transfer := range transfers {
-			workChan <- transfer
-		}
 	} else if len(args) > 1 {
 		source = args[:len(args)-1]
 		dest = args[len(args)-1]
-		workChan = make(chan PluginTransfer, len(args)-1)
 		for _, src := range source {
 			srcUrl, err := url.Parse(src)
 			if err != nil {
 				log.Errorf("Failed to parse input URL (%s): %s", src, err)
 			}
-			workChan <- PluginTransfer{url: srcUrl, localFile: dest}
+			transfers = append(transfers, PluginTransfer{url: srcUrl, localFile: dest})
 		}
 	} else {
 		log.Errorln("Must provide both source and destination as argument")
 		os.Exit(1)
 	}
+
+	// Skip the transfers a previous run of the plugin for the same job completed
+	currentPluginJournal = openPluginJournal(upload)
+	transfers, resumedAds := currentPluginJournal.skipCompleted(transfers, upload)
+	workChan = make(chan PluginTransfer, len(transfers))
+	for _, transfer := range transfers {
+		workChan <- transfer
+	}
 	close(workChan)
 
 	// NOTE: HTCondor 23.3.0 and before would reuse the outfile names for multiple
@@ -291,13 +294,16 @@ func stashPluginMain(args []string) {
 
 	results := make(chan *classads.ClassAd, 5)
 
-	egrp.Go(func() error {
-		return runPluginWorker(ctx, upload, workChan, results)
-	})
+	// All the transfers may have been completed by a previous run
+	done := len(transfers) == 0
+	if !done {
+		egrp.Go(func() error {
+			return runPluginWorker(ctx, upload, workChan, results)
+		})
+	}
 
 	success := true
-	var resultAds []*classads.ClassAd
-	done := false
+	resultAds := resumedAds
 	for !done {
 		select {
 		case <-ctx.Done():
@@ -343,6 +349,7 @@ func stashPluginMain(args []string) {
 	success = tmpSuccess && success
 
 	if success {
+		currentPluginJournal.remove()
 		os.Exit(0)
 	} else if retryable {
 		os.Exit(Retryable)
@@ -423,6 +430,7 @@ func runPluginWorker(ctx context.Context, upload bool, workChan <-chan PluginTra
 	defer close(results)
 
 	jobMap := make(map[string]PluginTransfer)
+	requestedFiles := make(map[string]string) // The local files given to the plugin, by job
 	var recursive bool
 	var tj *client.TransferJob
 
@@ -450,6 +458,7 @@ func runPluginWorker(ctx context.Context, upload bool, workChan <-chan PluginTra
 				recursive = false
 			}
 
+			requestedFile := transfer.localFile
 			if upload {
 				log.Debugln("Uploading:", transfer.localFile, "to", transfer.url)
 			} else {
@@ -470,6 +479,7 @@ func runPluginWorker(ctx context.Context, upload bool, workChan <-chan PluginTra
 				return errors.Wrap(err, "Failed to create new transfer job")
 			}
 			jobMap[tj.ID()] = transfer
+			requestedFiles[tj.ID()] = requestedFile
 
 			if err = tc.Submit(tj); err != nil {
 				failTransfer(transfer.url.String(), transfer.localFile, results, upload, err)
@@ -502,6 +512,9 @@ func runPluginWorker(ctx context.Context, upload bool, workChan <-chan PluginTra
 				if attempt.CacheAge >= 0 {
 					developerData[fmt.Sprintf("DataAge%d", attempt.Number)] = attempt.CacheAge.Round(time.Millisecond).Seconds()
 				}
+				if attempt.SlowAbandoned {
+					developerData[fmt.Sprintf("SlowAbandoned%d", attempt.Number)] = true
+				}
 				if attempt.Error != nil {
 					developerData[fmt.Sprintf("TransferError%d", attempt.Number)] = attempt.Error.Error()
 				}
@@ -527,6 +540,7 @@ func runPluginWorker(ctx context.Context, upload bool, workChan <-chan PluginTra
 				resultAd.Set("TransferSuccess", true)
 				resultAd.Set("TransferFileBytes", result.Attempts[len(result.Attempts)-1].TransferFileBytes)
 				resultAd.Set("TransferTotalBytes", result.Attempts[len(result.Attempts)-1].TransferFileBytes)
+				currentPluginJournal.record(transfer, requestedFiles[result.ID()])
 			} else {
 				resultAd.Set("TransferSuccess", false)
 				var te *client.TransferErrors
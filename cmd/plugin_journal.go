@@ -0,0 +1,243 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, University of Nebraska-Lincoln
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/classads"
+	"github.com/pelicanplatform/pelican/client"
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+)
+
+type (
+	// The journal of the transfers of an HTCondor job the plugin completed.  When the
+	// plugin is killed mid-batch, e.g. because the job was evicted, and HTCondor runs it
+	// again for the same job, the transfers in the journal are not redone.  The journal
+	// is removed once the whole batch succeeds.
+	pluginJournal struct {
+		JobId     string               `json:"job_id"`
+		Upload    bool                 `json:"upload"`
+		Completed []pluginJournalEntry `json:"completed"`
+		filename  string
+		entries   map[string]int // Index of the entries in Completed by key
+		mutex     sync.Mutex
+	}
+
+	// A completed transfer.  It is only skipped if the local file still has the size and
+	// modification time it had when the transfer completed.
+	pluginJournalEntry struct {
+		Url       string    `json:"url"`        // The URL given to the plugin
+		LocalFile string    `json:"local_file"` // The local file given to the plugin
+		Path      string    `json:"path"`       // The local file that was transferred
+		Bytes     int64     `json:"bytes"`
+		ModTime   time.Time `json:"mod_time"`
+		Completed time.Time `json:"completed"`
+	}
+)
+
+// The journal of the running plugin; nil if the plugin doesn't run in an HTCondor job
+var currentPluginJournal *pluginJournal
+
+func pluginJournalKey(url, localFile string) string {
+	return url + "\n" + localFile
+}
+
+// The journals are keyed by the job's GlobalJobId and the direction of the transfers, as
+// HTCondor runs the plugin once for the job's inputs and once for its outputs
+func pluginJournalFile(jobId string, upload bool) string {
+	direction := "download"
+	if upload {
+		direction = "upload"
+	}
+	key := sha256.Sum256([]byte(jobId + "\n" + direction))
+	dir := param.Plugin_JournalLocation.GetString()
+	if dir == "" {
+		// The job's scratch directory, which HTCondor keeps when it retries the transfers
+		dir = "."
+	}
+	return filepath.Join(dir, ".pelican-plugin-"+hex.EncodeToString(key[:8])+".json")
+}
+
+// Open the journal of the job the plugin runs in, if any
+func openPluginJournal(upload bool) *pluginJournal {
+	jobId := client.GetJobId()
+	if jobId == "" {
+		return nil
+	}
+	journal := &pluginJournal{
+		JobId:    jobId,
+		Upload:   upload,
+		filename: pluginJournalFile(jobId, upload),
+	}
+	contents, err := os.ReadFile(journal.filename)
+	if err == nil {
+		if err = json.Unmarshal(contents, journal); err != nil {
+			log.Warningln("Ignoring an invalid plugin journal:", err)
+			journal.Completed = nil
+		} else if journal.JobId != jobId || journal.Upload != upload {
+			log.Warningf("Ignoring the plugin journal at %s of another job", journal.filename)
+			journal.JobId, journal.Upload, journal.Completed = jobId, upload, nil
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		log.Warningln("Failed to read the plugin journal:", err)
+	}
+	journal.entries = make(map[string]int, len(journal.Completed))
+	for idx, entry := range journal.Completed {
+		journal.entries[pluginJournalKey(entry.Url, entry.LocalFile)] = idx
+	}
+	if len(journal.Completed) > 0 {
+		log.Infof("Found %d transfers completed by a previous run of the plugin for job %s", len(journal.Completed), jobId)
+	}
+	return journal
+}
+
+// Return the journal entry of the transfer if it was completed by a previous run and
+// its local file hasn't changed since
+func (journal *pluginJournal) completed(transfer PluginTransfer) *pluginJournalEntry {
+	if journal == nil || transfer.url == nil {
+		return nil
+	}
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	idx, ok := journal.entries[pluginJournalKey(transfer.url.String(), transfer.localFile)]
+	if !ok {
+		return nil
+	}
+	entry := journal.Completed[idx]
+	info, err := os.Stat(entry.Path)
+	if err != nil || info.Size() != entry.Bytes || !info.ModTime().Equal(entry.ModTime) {
+		log.Debugf("The local file %s changed since %s was transferred; transferring it again", entry.Path, entry.Url)
+		return nil
+	}
+	return &entry
+}
+
+// Record a completed transfer; requestedFile is the local file given to the plugin, and
+// transfer.localFile the file that was transferred
+func (journal *pluginJournal) record(transfer PluginTransfer, requestedFile string) {
+	if journal == nil {
+		return
+	}
+	info, err := os.Stat(transfer.localFile)
+	if err != nil || !info.Mode().IsRegular() {
+		// Only single files are journaled, not recursive or unpacked transfers
+		return
+	}
+	entry := pluginJournalEntry{
+		Url:       transfer.url.String(),
+		LocalFile: requestedFile,
+		Path:      transfer.localFile,
+		Bytes:     info.Size(),
+		ModTime:   info.ModTime(),
+		Completed: time.Now(),
+	}
+
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	key := pluginJournalKey(entry.Url, entry.LocalFile)
+	if idx, ok := journal.entries[key]; ok {
+		journal.Completed[idx] = entry
+	} else {
+		journal.entries[key] = len(journal.Completed)
+		journal.Completed = append(journal.Completed, entry)
+	}
+	if err := journal.save(); err != nil {
+		log.Warningln("Failed to record the transfer in the plugin journal:", err)
+	}
+}
+
+func (journal *pluginJournal) save() error {
+	contents, err := json.Marshal(journal)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(journal.filename), 0700); err != nil {
+		return errors.Wrap(err, "failed to create the plugin journal directory")
+	}
+	tmpFile := journal.filename + ".tmp"
+	if err = os.WriteFile(tmpFile, contents, 0600); err != nil {
+		return errors.Wrap(err, "failed to write the plugin journal")
+	}
+	return errors.Wrap(os.Rename(tmpFile, journal.filename), "failed to write the plugin journal")
+}
+
+// Remove the journal once all the job's transfers succeeded
+func (journal *pluginJournal) remove() {
+	if journal == nil {
+		return
+	}
+	if err := os.Remove(journal.filename); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warningln("Failed to remove the plugin journal:", err)
+	}
+}
+
+// Split the transfers into those to run and the result ads of those a previous run of
+// the plugin already completed
+func (journal *pluginJournal) skipCompleted(transfers []PluginTransfer, upload bool) (remaining []PluginTransfer, resultAds []*classads.ClassAd) {
+	for _, transfer := range transfers {
+		entry := journal.completed(transfer)
+		if entry == nil {
+			remaining = append(remaining, transfer)
+			continue
+		}
+		log.Infof("Skipping %s, which was transferred by a previous run of the plugin", transfer.url)
+		resultAds = append(resultAds, journaledResultAd(transfer, entry, upload))
+	}
+	return
+}
+
+// The result ad of a transfer completed by a previous run of the plugin
+func journaledResultAd(transfer PluginTransfer, entry *pluginJournalEntry, upload bool) *classads.ClassAd {
+	resultAd := classads.NewClassAd()
+	resultAd.Set("DeveloperData", map[string]interface{}{
+		"PelicanClientVersion": config.GetVersion(),
+		"Attempts":             0,
+		"ResumedFromJournal":   true,
+	})
+	now := time.Now().Unix()
+	resultAd.Set("TransferStartTime", now)
+	resultAd.Set("TransferEndTime", now)
+	hostname, _ := os.Hostname()
+	resultAd.Set("TransferLocalMachineName", hostname)
+	resultAd.Set("TransferProtocol", transfer.url.Scheme)
+	resultAd.Set("TransferUrl", transfer.url.String())
+	if upload {
+		resultAd.Set("TransferType", "upload")
+		resultAd.Set("TransferFileName", path.Base(transfer.localFile))
+	} else {
+		resultAd.Set("TransferType", "download")
+		resultAd.Set("TransferFileName", path.Base(transfer.url.String()))
+	}
+	resultAd.Set("TransferSuccess", true)
+	resultAd.Set("TransferFileBytes", entry.Bytes)
+	resultAd.Set("TransferTotalBytes", entry.Bytes)
+	return resultAd
+}
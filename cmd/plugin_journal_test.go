@@ -0,0 +1,116 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, University of Nebraska-Lincoln
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package main
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPluginJournal(t *testing.T) {
+	tmpDir := t.TempDir()
+	journalDir := filepath.Join(tmpDir, "journal")
+	viper.Reset()
+	viper.Set("Plugin.JournalLocation", journalDir)
+	t.Cleanup(viper.Reset)
+
+	jobAd := filepath.Join(tmpDir, ".job.ad")
+	require.NoError(t, os.WriteFile(jobAd, []byte("GlobalJobId = \"submit.example.org#1234.0#1700000000\"\n"), 0600))
+	t.Setenv("_CONDOR_JOB_AD", jobAd)
+
+	newTransfer := func(object, localFile string) PluginTransfer {
+		objectUrl, err := url.Parse("pelican://federation.example.org/test/" + object)
+		require.NoError(t, err)
+		return PluginTransfer{url: objectUrl, localFile: localFile}
+	}
+	fooFile := filepath.Join(tmpDir, "foo.txt")
+	barFile := filepath.Join(tmpDir, "bar.txt")
+	require.NoError(t, os.WriteFile(fooFile, []byte("foo"), 0600))
+	require.NoError(t, os.WriteFile(barFile, []byte("bar"), 0600))
+	transfers := []PluginTransfer{
+		newTransfer("foo.txt", fooFile),
+		newTransfer("bar.txt", barFile),
+		newTransfer("baz.txt", filepath.Join(tmpDir, "baz.txt")),
+	}
+
+	// The first run completes foo and bar before being killed
+	journal := openPluginJournal(false)
+	require.NotNil(t, journal)
+	remaining, resultAds := journal.skipCompleted(transfers, false)
+	assert.Len(t, remaining, 3)
+	assert.Empty(t, resultAds)
+	journal.record(transfers[0], transfers[0].localFile)
+	journal.record(transfers[1], transfers[1].localFile)
+	// Directories aren't journaled
+	journal.record(newTransfer("dir", tmpDir), tmpDir)
+
+	// bar changes before the plugin runs again, so only foo is skipped
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(barFile, later, later))
+	journal = openPluginJournal(false)
+	remaining, resultAds = journal.skipCompleted(transfers, false)
+	require.Len(t, resultAds, 1)
+	assert.Equal(t, []PluginTransfer{transfers[1], transfers[2]}, remaining)
+	success, err := resultAds[0].Get("TransferSuccess")
+	require.NoError(t, err)
+	assert.Equal(t, true, success)
+	transferUrl, err := resultAds[0].Get("TransferUrl")
+	require.NoError(t, err)
+	assert.Equal(t, transfers[0].url.String(), transferUrl)
+	fileBytes, err := resultAds[0].Get("TransferFileBytes")
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, fileBytes)
+
+	// The journal of the downloads doesn't apply to the uploads
+	remaining, resultAds = openPluginJournal(true).skipCompleted(transfers, true)
+	assert.Len(t, remaining, 3)
+	assert.Empty(t, resultAds)
+
+	// Nor to another job
+	require.NoError(t, os.WriteFile(jobAd, []byte("GlobalJobId = \"submit.example.org#1235.0#1700000000\"\n"), 0600))
+	remaining, _ = openPluginJournal(false).skipCompleted(transfers, false)
+	assert.Len(t, remaining, 3)
+	require.NoError(t, os.WriteFile(jobAd, []byte("GlobalJobId = \"submit.example.org#1234.0#1700000000\"\n"), 0600))
+
+	// The journal is removed once the batch succeeds
+	journal.remove()
+	entries, err := os.ReadDir(journalDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	remaining, _ = openPluginJournal(false).skipCompleted(transfers, false)
+	assert.Len(t, remaining, 3)
+
+	t.Run("outside-of-a-job", func(t *testing.T) {
+		t.Setenv("_CONDOR_JOB_AD", filepath.Join(tmpDir, "missing.ad"))
+		journal := openPluginJournal(false)
+		assert.Nil(t, journal)
+		remaining, resultAds := journal.skipCompleted(transfers, false)
+		assert.Len(t, remaining, 3)
+		assert.Empty(t, resultAds)
+		journal.record(transfers[0], transfers[0].localFile)
+		journal.remove()
+	})
+}
@@ -31,6 +31,7 @@ import (
 	"context"
 	"net/url"
 	"os"
+	"strings"
 
 	"github.com/lestrrat-go/jwx/v2/jwk"
 	"github.com/pelicanplatform/pelican/config"
@@ -47,6 +48,11 @@ import (
 var withIdentity bool
 var prefix string
 var pubkeyPath string
+var newPrefix string
+var newOwner string
+var includeSubspaces bool
+var withAlias bool
+var dryRun bool
 
 func getNamespaceEndpoint(ctx context.Context) (string, error) {
 	fedInfo, err := config.GetFederation(ctx)
@@ -184,6 +190,78 @@ func listAllNamespaces(cmd *cobra.Command, args []string) {
 	}
 }
 
+func transferANamespace(cmd *cobra.Command, args []string) {
+	err := config.InitClient()
+	if err != nil {
+		log.Errorln("Failed to initialize the client: ", err)
+		os.Exit(1)
+	}
+
+	namespaceEndpoint, err := getNamespaceEndpoint(cmd.Context())
+	if err != nil {
+		log.Errorln("Failed to get RegistryUrl from config: ", err)
+		os.Exit(1)
+	}
+
+	transferEndpointURL, err := url.JoinPath(namespaceEndpoint, "api", "v1.0", "registry", "namespaces", "transfer")
+	if err != nil {
+		log.Errorf("Failed to construction transfer endpoint URL: %v", err)
+	}
+	if prefix == "" {
+		log.Error("Error: prefix is required")
+		os.Exit(1)
+	}
+	if pubkeyPath == "" && newOwner == "" {
+		log.Error("Error: at least one of --pubkey or --owner is required")
+		os.Exit(1)
+	}
+
+	pubkey := ""
+	if pubkeyPath != "" {
+		pubkeyBytes, err := os.ReadFile(pubkeyPath)
+		if err != nil {
+			log.Errorf("Failed to read the public key at %s: %v", pubkeyPath, err)
+			os.Exit(1)
+		}
+		pubkey = strings.TrimSpace(string(pubkeyBytes))
+	}
+
+	err = registry.NamespaceTransfer(namespaceEndpoint, transferEndpointURL, prefix, pubkey, newOwner, includeSubspaces, dryRun)
+	if err != nil {
+		log.Errorf("Failed to transfer prefix %s: %v", prefix, err)
+		os.Exit(1)
+	}
+}
+
+func renameANamespace(cmd *cobra.Command, args []string) {
+	err := config.InitClient()
+	if err != nil {
+		log.Errorln("Failed to initialize the client: ", err)
+		os.Exit(1)
+	}
+
+	namespaceEndpoint, err := getNamespaceEndpoint(cmd.Context())
+	if err != nil {
+		log.Errorln("Failed to get RegistryUrl from config: ", err)
+		os.Exit(1)
+	}
+
+	renameEndpointURL, err := url.JoinPath(namespaceEndpoint, "api", "v1.0", "registry", "namespaces", "rename")
+	if err != nil {
+		log.Errorf("Failed to construction rename endpoint URL: %v", err)
+	}
+	if prefix == "" || newPrefix == "" {
+		log.Error("Error: both --prefix and --new-prefix are required")
+		os.Exit(1)
+	}
+
+	err = registry.NamespaceRename(namespaceEndpoint, renameEndpointURL, prefix, newPrefix, withAlias, dryRun)
+	if err != nil {
+		log.Errorf("Failed to rename prefix %s to %s: %v", prefix, newPrefix, err)
+		os.Exit(1)
+	}
+}
+
 // Commenting until we're ready to use -- JH
 
 // func getNamespace(cmd *cobra.Command, args []string) {
@@ -235,6 +313,32 @@ var listCmd = &cobra.Command{
 	Run:   listAllNamespaces,
 }
 
+var transferCmd = &cobra.Command{
+	Use:   "transfer",
+	Short: "Transfer the ownership of a namespace to a different key or owner",
+	Long: `Transfer the ownership of a namespace to a different public key (--pubkey)
+and/or registry user (--owner).
+
+This is an administrative operation: the request is authorized by a token signed with
+the registry's own issuer key, so it must be run with access to that key (for example,
+on the registry host or with --privkey).  Use --dry-run to review the changes first.`,
+	Run: transferANamespace,
+}
+
+var renameCmd = &cobra.Command{
+	Use:   "rename",
+	Short: "Rename a namespace, or merge it into an existing namespace",
+	Long: `Rename the namespace --prefix to --new-prefix, moving any namespaces registered
+beneath it.  If --new-prefix is already registered, the namespace is merged into it:
+the old registration is removed and the namespaces beneath it are moved.  With --alias,
+the registry continues to serve the public key for the old prefix.
+
+This is an administrative operation: the request is authorized by a token signed with
+the registry's own issuer key, so it must be run with access to that key (for example,
+on the registry host or with --privkey).  Use --dry-run to review the changes first.`,
+	Run: renameANamespace,
+}
+
 // Commenting until we use -- JH
 // var getCmd = &cobra.Command{
 // 	Use:   "get",
@@ -248,6 +352,14 @@ func init() {
 	//getCmd.Flags().StringVar(&prefix, "prefix", "", "prefix for get namespace")
 	//getCmd.Flags().BoolVar(&jwks, "jwks", false, "Get the jwks of the namespace")
 	deleteCmd.Flags().StringVar(&prefix, "prefix", "", "prefix for delete namespace")
+	transferCmd.Flags().StringVar(&prefix, "prefix", "", "prefix of the namespace to transfer")
+	transferCmd.Flags().StringVar(&newOwner, "owner", "", "user ID of the new owner in the registry")
+	transferCmd.Flags().BoolVar(&includeSubspaces, "include-subspaces", false, "Also transfer the namespaces beneath the prefix that are registered with the same key")
+	transferCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the changes without applying them")
+	renameCmd.Flags().StringVar(&prefix, "prefix", "", "prefix of the namespace to rename")
+	renameCmd.Flags().StringVar(&newPrefix, "new-prefix", "", "new prefix of the namespace")
+	renameCmd.Flags().BoolVar(&withAlias, "alias", false, "Keep the old prefix as an alias of the new prefix")
+	renameCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the changes without applying them")
 
 	namespaceCmd.PersistentFlags().String("namespace-url", "", "Endpoint for the namespace registry")
 	// Don't override Federation.RegistryUrl if the flag value is empty
@@ -269,6 +381,8 @@ func init() {
 	namespaceCmd.AddCommand(registerCmd)
 	namespaceCmd.AddCommand(deleteCmd)
 	namespaceCmd.AddCommand(listCmd)
+	namespaceCmd.AddCommand(transferCmd)
+	namespaceCmd.AddCommand(renameCmd)
 	// Commenting until we use -- JH
 	//namespaceCmd.AddCommand(getCmd)
 }
@@ -124,8 +124,11 @@ func restartProgram() error {
 func init() {
 	cobra.OnInitialize(config.InitConfig)
 	rootCmd.AddCommand(objectCmd)
+	rootCmd.AddCommand(bugReportCmd)
+	rootCmd.AddCommand(transferJournalCmd)
 	objectCmd.CompletionOptions.DisableDefaultCmd = true
 	rootCmd.AddCommand(directorCmd)
+	rootCmd.AddCommand(downtimeCmd)
 	rootCmd.AddCommand(registryCmd)
 	rootCmd.AddCommand(originCmd)
 	rootCmd.AddCommand(cacheCmd)
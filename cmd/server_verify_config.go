@@ -0,0 +1,409 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/go-version"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/origin"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+type (
+	verifyStatus string
+
+	// The result of a single check of `verify-config`
+	verifyCheck struct {
+		Name   string
+		Status verifyStatus
+		Msg    string
+	}
+
+	verifyReport struct {
+		Checks []verifyCheck
+	}
+
+	verifyPort struct {
+		Name string
+		Port int
+	}
+)
+
+const (
+	verifyPass verifyStatus = "PASS"
+	verifyWarn verifyStatus = "WARN"
+	verifyFail verifyStatus = "FAIL"
+
+	// The oldest XRootD release known to work with this version of Pelican
+	minXrootdVersion = "5.6.0"
+
+	// Certificates expiring sooner than this are reported
+	certExpiryWarning = 14 * 24 * time.Hour
+
+	verifyRequestTimeout = 10 * time.Second
+)
+
+var (
+	originVerifyConfigCmd = &cobra.Command{
+		Use:   "verify-config",
+		Short: "Check the origin's configuration without starting it",
+		Long: `Check the origin's configuration, as read from the configuration file and the
+environment, without starting the origin: the TLS certificate and the hostnames it
+covers, the availability of the ports, the federation's and the origin issuer's keys,
+the permissions of the exported directories, the reachability of the storage backend,
+and the installed XRootD.  The command exits with an error if any check fails, so it
+can be run before restarting the service.`,
+		RunE:         verifyOriginConfig,
+		SilenceUsage: true,
+	}
+
+	cacheVerifyConfigCmd = &cobra.Command{
+		Use:   "verify-config",
+		Short: "Check the cache's configuration without starting it",
+		Long: `Check the cache's configuration, as read from the configuration file and the
+environment, without starting the cache: the TLS certificate and the hostnames it
+covers, the availability of the ports, the federation's keys, the cache's storage
+directory, and the installed XRootD.  The command exits with an error if any check
+fails, so it can be run before restarting the service.`,
+		RunE:         verifyCacheConfig,
+		SilenceUsage: true,
+	}
+
+	xrootdVersionRegexp = regexp.MustCompile(`v?(\d+\.\d+(\.\d+)?)`)
+)
+
+func (r *verifyReport) add(name string, err error, passMsg string) {
+	if err != nil {
+		r.Checks = append(r.Checks, verifyCheck{Name: name, Status: verifyFail, Msg: err.Error()})
+	} else {
+		r.Checks = append(r.Checks, verifyCheck{Name: name, Status: verifyPass, Msg: passMsg})
+	}
+}
+
+func (r *verifyReport) warn(name, msg string) {
+	r.Checks = append(r.Checks, verifyCheck{Name: name, Status: verifyWarn, Msg: msg})
+}
+
+func (r *verifyReport) failures() (count int) {
+	for _, check := range r.Checks {
+		if check.Status == verifyFail {
+			count++
+		}
+	}
+	return
+}
+
+func (r *verifyReport) print(out io.Writer) {
+	for _, check := range r.Checks {
+		fmt.Fprintf(out, "[%s] %s: %s\n", check.Status, check.Name, check.Msg)
+	}
+}
+
+// Check the server's TLS certificate is valid now and covers the hostnames clients use
+func verifyCertificate(report *verifyReport, hostUrls ...string) {
+	certFile := param.Server_TLSCertificate.GetString()
+	cert, err := config.LoadCertficate(certFile)
+	if err != nil {
+		report.add("TLS certificate", errors.Wrapf(err, "failed to load %s", certFile), "")
+		return
+	}
+	now := time.Now()
+	if now.Before(cert.NotBefore) {
+		report.add("TLS certificate", errors.Errorf("%s is not valid until %s", certFile, cert.NotBefore.Format(time.RFC3339)), "")
+		return
+	}
+	if now.After(cert.NotAfter) {
+		report.add("TLS certificate", errors.Errorf("%s expired at %s", certFile, cert.NotAfter.Format(time.RFC3339)), "")
+		return
+	}
+	if cert.NotAfter.Sub(now) < certExpiryWarning {
+		report.warn("TLS certificate", fmt.Sprintf("%s expires soon, at %s", certFile, cert.NotAfter.Format(time.RFC3339)))
+	} else {
+		report.add("TLS certificate", nil, fmt.Sprintf("%s is valid until %s", certFile, cert.NotAfter.Format(time.RFC3339)))
+	}
+
+	checked := map[string]bool{}
+	for _, hostUrl := range hostUrls {
+		if hostUrl == "" {
+			continue
+		}
+		parsed, err := url.Parse(hostUrl)
+		if err != nil || parsed.Hostname() == "" {
+			report.add("TLS certificate hostnames", errors.Errorf("invalid server URL %q", hostUrl), "")
+			continue
+		}
+		host := parsed.Hostname()
+		if checked[host] {
+			continue
+		}
+		checked[host] = true
+		report.add("TLS certificate hostnames", verifyCertHostname(cert, host), fmt.Sprintf("%s is covered by the certificate", host))
+	}
+}
+
+func verifyCertHostname(cert *x509.Certificate, host string) error {
+	if err := cert.VerifyHostname(host); err != nil {
+		return errors.Errorf("the certificate doesn't cover %s; its names are %v", host, append(cert.DNSNames, ipStrings(cert.IPAddresses)...))
+	}
+	return nil
+}
+
+func ipStrings(ips []net.IP) (result []string) {
+	for _, ip := range ips {
+		result = append(result, ip.String())
+	}
+	return
+}
+
+// Check the ports the server listens on are free
+func verifyPorts(report *verifyReport, ports ...verifyPort) {
+	for _, port := range ports {
+		if port.Port == 0 {
+			continue
+		}
+		listener, err := net.Listen("tcp", ":"+strconv.Itoa(port.Port))
+		if err != nil {
+			report.add("port "+port.Name, errors.Wrapf(err, "port %d is unavailable; is the server already running?", port.Port), "")
+			continue
+		}
+		listener.Close()
+		report.add("port "+port.Name, nil, fmt.Sprintf("port %d is available", port.Port))
+	}
+}
+
+func fetchJwks(ctx context.Context, jwksUrl string) (jwk.Set, error) {
+	ctx, cancel := context.WithTimeout(ctx, verifyRequestTimeout)
+	defer cancel()
+	client := &http.Client{Transport: config.GetTransport()}
+	return jwk.Fetch(ctx, jwksUrl, jwk.WithHTTPClient(client))
+}
+
+// Check the federation's services can be discovered and its keys fetched
+func verifyFederation(ctx context.Context, report *verifyReport) {
+	fedInfo, err := config.GetFederation(ctx)
+	if err != nil {
+		report.add("federation discovery", err, "")
+		return
+	}
+	if fedInfo.DirectorEndpoint == "" {
+		report.add("federation discovery", errors.New("no director was found; set Federation.DiscoveryUrl or Federation.DirectorUrl"), "")
+		return
+	}
+	report.add("federation discovery", nil, "the director is at "+fedInfo.DirectorEndpoint)
+	if fedInfo.JwksUri == "" {
+		report.add("federation keys", errors.New("the federation doesn't advertise a JWKS URL"), "")
+		return
+	}
+	keys, err := fetchJwks(ctx, fedInfo.JwksUri)
+	if err != nil {
+		report.add("federation keys", errors.Wrapf(err, "failed to fetch %s", fedInfo.JwksUri), "")
+	} else {
+		report.add("federation keys", nil, fmt.Sprintf("fetched %d key(s) from %s", keys.Len(), fedInfo.JwksUri))
+	}
+}
+
+// Check the keys of the issuer of the origin's tokens can be fetched, unless the origin
+// issues them itself
+func verifyIssuer(report *verifyReport) {
+	issuerUrl, err := config.GetServerIssuerURL()
+	if err != nil {
+		report.add("issuer", err, "")
+		return
+	}
+	if param.Origin_EnableIssuer.GetBool() || issuerUrl == param.Server_ExternalWebUrl.GetString() || issuerUrl == param.Origin_Url.GetString() {
+		report.add("issuer", nil, fmt.Sprintf("the origin is its own issuer (%s); its keys are served once it starts", issuerUrl))
+		return
+	}
+	keys, err := server_utils.GetJWKSFromIssUrl(issuerUrl)
+	if err != nil {
+		report.add("issuer", errors.Wrapf(err, "failed to fetch the keys of issuer %s", issuerUrl), "")
+	} else {
+		report.add("issuer", nil, fmt.Sprintf("fetched %d key(s) of issuer %s", (*keys).Len(), issuerUrl))
+	}
+}
+
+// Check the origin's exports and the directories or backend behind them
+func verifyExports(ctx context.Context, report *verifyReport) {
+	exports, err := server_utils.GetOriginExports()
+	if err != nil {
+		report.add("exports", err, "")
+		return
+	}
+	storageType := server_utils.OriginStorageType(param.Origin_StorageType.GetString())
+	if storageType == server_utils.OriginStoragePosix {
+		for _, export := range exports {
+			name := "export " + export.FederationPrefix
+			if critical, err := origin.CheckPosixExport(export); err != nil && !critical {
+				report.warn(name, err.Error())
+			} else {
+				report.add(name, err, fmt.Sprintf("%s is accessible to the daemon user", export.StoragePrefix))
+			}
+		}
+		return
+	}
+	report.add("exports", nil, fmt.Sprintf("%d export(s) configured", len(exports)))
+
+	var backendUrl string
+	switch storageType {
+	case server_utils.OriginStorageS3:
+		backendUrl = param.Origin_S3ServiceUrl.GetString()
+	case server_utils.OriginStorageHTTPS:
+		backendUrl = param.Origin_HttpServiceUrl.GetString()
+	case server_utils.OriginStorageXRoot:
+		backendUrl = param.Origin_XRootServiceUrl.GetString()
+	default:
+		report.warn("storage backend", fmt.Sprintf("the reachability of %s backends is not checked", storageType))
+		return
+	}
+	report.add("storage backend", verifyBackendReachable(ctx, backendUrl), backendUrl+" is reachable")
+}
+
+// Check a connection can be made to the storage backend
+func verifyBackendReachable(ctx context.Context, backendUrl string) error {
+	if backendUrl == "" {
+		return errors.New("the backend's URL is not configured")
+	}
+	parsed, err := url.Parse(backendUrl)
+	if err != nil {
+		return errors.Wrapf(err, "invalid backend URL %q", backendUrl)
+	}
+	host := parsed.Host
+	if parsed.Port() == "" {
+		port := "443"
+		switch parsed.Scheme {
+		case "http":
+			port = "80"
+		case "root", "xroot":
+			port = "1094"
+		}
+		host = net.JoinHostPort(parsed.Hostname(), port)
+	}
+	dialer := net.Dialer{Timeout: verifyRequestTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return errors.Wrapf(err, "failed to connect to %s", host)
+	}
+	conn.Close()
+	return nil
+}
+
+// Check the cache's storage directory exists
+func verifyCacheStorage(report *verifyReport) {
+	localRoot := param.Cache_LocalRoot.GetString()
+	info, err := os.Stat(localRoot)
+	if err == nil && !info.IsDir() {
+		err = errors.Errorf("%s is not a directory", localRoot)
+	}
+	report.add("cache storage", err, localRoot+" exists")
+}
+
+// Check the installed XRootD is recent enough
+func verifyXrootd(ctx context.Context, report *verifyReport) {
+	xrootdPath, err := exec.LookPath("xrootd")
+	if err != nil {
+		report.add("XRootD", errors.New("the xrootd binary was not found in the PATH"), "")
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, verifyRequestTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, xrootdPath, "-v").CombinedOutput()
+	if err != nil {
+		report.add("XRootD", errors.Wrapf(err, "failed to run %s -v: %s", xrootdPath, output), "")
+		return
+	}
+	report.add("XRootD", checkXrootdVersion(string(output)), fmt.Sprintf("%s is version %s", xrootdPath, xrootdVersionRegexp.FindString(string(output))))
+}
+
+func checkXrootdVersion(output string) error {
+	match := xrootdVersionRegexp.FindStringSubmatch(output)
+	if match == nil {
+		return errors.Errorf("unable to determine the XRootD version from %q", output)
+	}
+	installed, err := version.NewVersion(match[1])
+	if err != nil {
+		return errors.Wrapf(err, "invalid XRootD version %q", match[1])
+	}
+	if installed.LessThan(version.Must(version.NewVersion(minXrootdVersion))) {
+		return errors.Errorf("XRootD %s is installed but Pelican requires %s or newer", installed, minXrootdVersion)
+	}
+	return nil
+}
+
+func runVerifyConfig(cmd *cobra.Command, serverType config.ServerType) error {
+	ctx := cmd.Context()
+	report := &verifyReport{}
+	if err := config.InitServer(ctx, serverType); err != nil {
+		report.add("configuration", err, "")
+		report.print(cmd.OutOrStdout())
+		return errors.New("the configuration is invalid")
+	}
+	report.add("configuration", nil, "the configuration was loaded")
+
+	if serverType == config.OriginType {
+		verifyCertificate(report, param.Server_ExternalWebUrl.GetString(), param.Origin_Url.GetString())
+		verifyPorts(report, verifyPort{"Server.WebPort", param.Server_WebPort.GetInt()}, verifyPort{"Origin.Port", param.Origin_Port.GetInt()})
+		verifyFederation(ctx, report)
+		verifyIssuer(report)
+		verifyExports(ctx, report)
+	} else {
+		verifyCertificate(report, param.Server_ExternalWebUrl.GetString(), param.Cache_Url.GetString())
+		verifyPorts(report, verifyPort{"Server.WebPort", param.Server_WebPort.GetInt()}, verifyPort{"Cache.Port", param.Cache_Port.GetInt()})
+		verifyFederation(ctx, report)
+		verifyCacheStorage(report)
+	}
+	verifyXrootd(ctx, report)
+
+	report.print(cmd.OutOrStdout())
+	if failures := report.failures(); failures > 0 {
+		return errors.Errorf("%d check(s) failed", failures)
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), "All checks passed")
+	return nil
+}
+
+func verifyOriginConfig(cmd *cobra.Command, args []string) error {
+	return runVerifyConfig(cmd, config.OriginType)
+}
+
+func verifyCacheConfig(cmd *cobra.Command, args []string) error {
+	return runVerifyConfig(cmd, config.CacheType)
+}
+
+func init() {
+	originCmd.AddCommand(originVerifyConfigCmd)
+	cacheCmd.AddCommand(cacheVerifyConfigCmd)
+}
@@ -0,0 +1,113 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestCertificate(t *testing.T, notAfter time.Time, dnsNames ...string) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		DNSNames:     dnsNames,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	require.NoError(t, err)
+	certFile := filepath.Join(t.TempDir(), "tls.crt")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644))
+	return certFile
+}
+
+func TestVerifyCertificate(t *testing.T) {
+	t.Cleanup(viper.Reset)
+
+	t.Run("valid-and-covering", func(t *testing.T) {
+		viper.Set("Server.TLSCertificate", writeTestCertificate(t, time.Now().Add(365*24*time.Hour), "origin.example.org"))
+		report := &verifyReport{}
+		verifyCertificate(report, "https://origin.example.org:8444", "https://origin.example.org:8443", "https://other.example.org")
+		require.Len(t, report.Checks, 3)
+		assert.Equal(t, verifyPass, report.Checks[0].Status)
+		assert.Equal(t, verifyPass, report.Checks[1].Status)
+		assert.Equal(t, verifyFail, report.Checks[2].Status)
+		assert.Contains(t, report.Checks[2].Msg, "other.example.org")
+	})
+
+	t.Run("expiring-soon", func(t *testing.T) {
+		viper.Set("Server.TLSCertificate", writeTestCertificate(t, time.Now().Add(24*time.Hour), "origin.example.org"))
+		report := &verifyReport{}
+		verifyCertificate(report)
+		require.Len(t, report.Checks, 1)
+		assert.Equal(t, verifyWarn, report.Checks[0].Status)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		viper.Set("Server.TLSCertificate", writeTestCertificate(t, time.Now().Add(-time.Minute), "origin.example.org"))
+		report := &verifyReport{}
+		verifyCertificate(report, "https://origin.example.org")
+		require.Len(t, report.Checks, 1)
+		assert.Equal(t, verifyFail, report.Checks[0].Status)
+		assert.Equal(t, 1, report.failures())
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		viper.Set("Server.TLSCertificate", filepath.Join(t.TempDir(), "missing.crt"))
+		report := &verifyReport{}
+		verifyCertificate(report)
+		assert.Equal(t, 1, report.failures())
+	})
+}
+
+func TestVerifyPorts(t *testing.T) {
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	defer listener.Close()
+	inUse := listener.Addr().(*net.TCPAddr).Port
+
+	report := &verifyReport{}
+	verifyPorts(report, verifyPort{"in-use", inUse}, verifyPort{"unset", 0})
+	require.Len(t, report.Checks, 1)
+	assert.Equal(t, verifyFail, report.Checks[0].Status)
+}
+
+func TestCheckXrootdVersion(t *testing.T) {
+	assert.NoError(t, checkXrootdVersion("v5.7.1\n"))
+	assert.NoError(t, checkXrootdVersion("v5.6.0"))
+	assert.ErrorContains(t, checkXrootdVersion("v5.5.5"), "requires 5.6.0")
+	assert.Error(t, checkXrootdVersion("unknown"))
+}
@@ -0,0 +1,309 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+
+	"github.com/pelicanplatform/pelican/client"
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/utils"
+)
+
+var (
+	transferJournalCmd = &cobra.Command{
+		Use:   "transfer",
+		Short: "Inspect and re-run the transfers recorded in the transfer journal",
+		Long: `The object get, put, and copy commands record each transfer in a local
+transfer journal (see Client.TransferJournalLocation): its source and
+destination, the number of objects and bytes transferred, how long it took,
+whether it succeeded, and the caches or origins used.  These commands list the
+recorded transfers and re-run the failed ones.`,
+	}
+
+	transferHistoryCmd = &cobra.Command{
+		Use:          "history",
+		Short:        "List the recorded transfers, newest first",
+		RunE:         listTransferHistory,
+		SilenceUsage: true,
+	}
+
+	transferShowCmd = &cobra.Command{
+		Use:          "show {id}",
+		Short:        "Show the details of a recorded transfer",
+		Args:         cobra.ExactArgs(1),
+		RunE:         showTransfer,
+		SilenceUsage: true,
+	}
+
+	transferRerunCmd = &cobra.Command{
+		Use:   "rerun [id...]",
+		Short: "Re-run recorded transfers",
+		Long: `Re-run the recorded transfers with the given IDs, using the same source,
+destination, and options as the original transfer.  With --failed, every failed
+transfer that hasn't since succeeded is re-run.  The re-runs are recorded in the
+transfer journal as well.`,
+		RunE:         rerunTransfers,
+		SilenceUsage: true,
+	}
+
+	transferHistoryLimit  int
+	transferHistoryFailed bool
+	transferRerunFailed   bool
+)
+
+func init() {
+	transferHistoryCmd.Flags().IntVar(&transferHistoryLimit, "limit", 20, "The maximum number of transfers to list; 0 lists all")
+	transferHistoryCmd.Flags().BoolVar(&transferHistoryFailed, "failed", false, "Only list the failed transfers")
+	transferRerunCmd.Flags().BoolVar(&transferRerunFailed, "failed", false, "Re-run every failed transfer that hasn't since succeeded")
+
+	transferJournalCmd.AddCommand(transferHistoryCmd)
+	transferJournalCmd.AddCommand(transferShowCmd)
+	transferJournalCmd.AddCommand(transferRerunCmd)
+}
+
+// Initialize the client and open its transfer journal
+func initTransferJournal() (*gorm.DB, error) {
+	if err := config.InitClient(); err != nil {
+		return nil, errors.Wrap(err, "failed to initialize the client")
+	}
+	return openTransferJournal()
+}
+
+func getTransferRecord(db *gorm.DB, idStr string) (*transferRecord, error) {
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return nil, errors.Errorf("invalid transfer ID %q", idStr)
+	}
+	record := &transferRecord{}
+	result := db.Limit(1).Find(record, id)
+	if result.Error != nil {
+		return nil, errors.Wrapf(result.Error, "failed to look up transfer %d", id)
+	} else if result.RowsAffected == 0 {
+		return nil, errors.Errorf("there is no transfer %d in the transfer journal", id)
+	}
+	return record, nil
+}
+
+// Returns the failed transfers, oldest first, skipping those whose source and
+// destination were transferred successfully since
+func getUnresolvedFailures(db *gorm.DB) ([]transferRecord, error) {
+	failures := []transferRecord{}
+	if err := db.Where("status = ?", transferFailed).Order("id ASC").Find(&failures).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to look up the failed transfers")
+	}
+	unresolved := []transferRecord{}
+	seen := map[string]bool{}
+	for idx := len(failures) - 1; idx >= 0; idx-- {
+		record := failures[idx]
+		key := record.Operation + "\x00" + record.Source + "\x00" + record.Destination + "\x00" + record.WorkingDir
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		var succeeded int64
+		err := db.Model(&transferRecord{}).
+			Where("operation = ? AND source = ? AND destination = ? AND working_dir = ? AND status = ? AND id > ?",
+				record.Operation, record.Source, record.Destination, record.WorkingDir, transferSucceeded, record.ID).
+			Count(&succeeded).Error
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to look up the later transfers")
+		}
+		if succeeded == 0 {
+			unresolved = append([]transferRecord{record}, unresolved...)
+		}
+	}
+	return unresolved, nil
+}
+
+func printTransferJSON(value any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+func listTransferHistory(cmd *cobra.Command, args []string) error {
+	db, err := initTransferJournal()
+	if err != nil {
+		return err
+	}
+	query := db.Order("id DESC")
+	if transferHistoryFailed {
+		query = query.Where("status = ?", transferFailed)
+	}
+	if transferHistoryLimit > 0 {
+		query = query.Limit(transferHistoryLimit)
+	}
+	records := []transferRecord{}
+	if err := query.Find(&records).Error; err != nil {
+		return errors.Wrap(err, "failed to list the transfers")
+	}
+
+	if outputJSON {
+		return printTransferJSON(records)
+	}
+	if len(records) == 0 {
+		fmt.Println("No transfers")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTARTED\tOPERATION\tSTATUS\tSIZE\tDURATION\tSOURCE\tDESTINATION")
+	for _, record := range records {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", record.ID, record.StartedAt.Local().Format(time.RFC3339),
+			record.Operation, record.Status, client.ByteCountSI(record.Bytes), record.Duration().Round(time.Millisecond),
+			record.Source, record.Destination)
+	}
+	return w.Flush()
+}
+
+func showTransfer(cmd *cobra.Command, args []string) error {
+	db, err := initTransferJournal()
+	if err != nil {
+		return err
+	}
+	record, err := getTransferRecord(db, args[0])
+	if err != nil {
+		return err
+	}
+	if outputJSON {
+		return printTransferJSON(record)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ID:\t%d\n", record.ID)
+	if record.RerunOf != nil {
+		fmt.Fprintf(w, "Re-run of:\t%d\n", *record.RerunOf)
+	}
+	fmt.Fprintf(w, "Operation:\t%s\n", record.Operation)
+	fmt.Fprintf(w, "Source:\t%s\n", record.Source)
+	fmt.Fprintf(w, "Destination:\t%s\n", record.Destination)
+	fmt.Fprintf(w, "Recursive:\t%t\n", record.Recursive)
+	fmt.Fprintf(w, "Working directory:\t%s\n", record.WorkingDir)
+	if record.TokenLocation != "" {
+		fmt.Fprintf(w, "Token file:\t%s\n", record.TokenLocation)
+	}
+	if record.PreferredCache != "" {
+		fmt.Fprintf(w, "Preferred cache:\t%s\n", record.PreferredCache)
+	}
+	fmt.Fprintf(w, "Started:\t%s\n", record.StartedAt.Local().Format(time.RFC3339))
+	fmt.Fprintf(w, "Duration:\t%s\n", record.Duration().Round(time.Millisecond))
+	fmt.Fprintf(w, "Status:\t%s\n", record.Status)
+	if record.Error != "" {
+		fmt.Fprintf(w, "Error:\t%s\n", record.Error)
+	}
+	fmt.Fprintf(w, "Objects:\t%d\n", record.Objects)
+	fmt.Fprintf(w, "Size:\t%s\n", client.ByteCountSI(record.Bytes))
+	fmt.Fprintf(w, "Attempts:\t%d\n", record.Attempts)
+	fmt.Fprintf(w, "Servers:\t%s\n", record.Servers)
+	return w.Flush()
+}
+
+// Make a recorded transfer again, recording the outcome as a new transfer
+func rerunTransfer(ctx context.Context, pb *progressBars, original *transferRecord) error {
+	if original.WorkingDir != "" {
+		if err := os.Chdir(original.WorkingDir); err != nil {
+			return errors.Wrapf(err, "failed to change to the working directory of transfer %d", original.ID)
+		}
+	}
+	options := []client.TransferOption{client.WithCallback(pb.callback), client.WithTokenLocation(original.TokenLocation)}
+	if original.Operation != "put" {
+		caches, err := utils.GetPreferredCaches(original.PreferredCache)
+		if err != nil {
+			return err
+		}
+		options = append(options, client.WithCaches(caches...))
+	}
+
+	record := newTransferRecord(original.Operation, original.Source, original.Destination, original.Recursive,
+		original.TokenLocation, original.PreferredCache)
+	record.RerunOf = &original.ID
+	var results []client.TransferResults
+	var err error
+	switch original.Operation {
+	case "get":
+		results, err = client.DoGet(ctx, original.Source, original.Destination, original.Recursive, options...)
+	case "put":
+		results, err = client.DoPut(ctx, original.Source, original.Destination, original.Recursive, options...)
+	case "copy":
+		results, err = client.DoCopy(ctx, original.Source, original.Destination, original.Recursive, options...)
+	default:
+		return errors.Errorf("transfer %d has an unknown operation %q", original.ID, original.Operation)
+	}
+	recordTransfer(record, results, err)
+	return err
+}
+
+func rerunTransfers(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 && !transferRerunFailed {
+		return errors.New("either give the IDs of the transfers to re-run or use --failed")
+	}
+	db, err := initTransferJournal()
+	if err != nil {
+		return err
+	}
+	records := []transferRecord{}
+	if transferRerunFailed {
+		if records, err = getUnresolvedFailures(db); err != nil {
+			return err
+		}
+	}
+	for _, id := range args {
+		record, err := getTransferRecord(db, id)
+		if err != nil {
+			return err
+		}
+		records = append(records, *record)
+	}
+	if len(records) == 0 {
+		fmt.Println("No transfers to re-run")
+		return nil
+	}
+
+	pb := newProgressBar()
+	defer pb.shutdown()
+	if fileInfo, _ := os.Stdout.Stat(); (fileInfo.Mode()&os.ModeCharDevice) != 0 && param.Logging_LogLocation.GetString() == "" && !param.Logging_DisableProgressBars.GetBool() {
+		pb.launchDisplay(cmd.Context())
+	}
+
+	failed := 0
+	for idx := range records {
+		record := &records[idx]
+		log.Debugf("Re-running transfer %d of %s to %s", record.ID, record.Source, record.Destination)
+		if err := rerunTransfer(cmd.Context(), pb, record); err != nil {
+			failed++
+			log.Errorf("Failure re-running transfer %d of %s: %s", record.ID, record.Source, getTransferErrorMessage(err))
+		}
+	}
+	if failed > 0 {
+		return errors.Errorf("%d of %d transfers failed again", failed, len(records))
+	}
+	return nil
+}
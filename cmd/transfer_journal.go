@@ -0,0 +1,195 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package main
+
+import (
+	"embed"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pressly/goose/v3"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/pelicanplatform/pelican/client"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+// A transfer made by the object get, put, or copy commands, as recorded in the
+// transfer journal.  One transfer is recorded per source given on the command line.
+type transferRecord struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	Operation      string    `json:"operation"` // "get", "put", or "copy"
+	Source         string    `json:"source"`
+	Destination    string    `json:"destination"`
+	Recursive      bool      `json:"recursive"`
+	TokenLocation  string    `json:"tokenLocation,omitempty"`
+	PreferredCache string    `json:"preferredCache,omitempty"`
+	WorkingDir     string    `json:"workingDir"` // Relative local paths are resolved against this directory
+	Status         string    `json:"status"`
+	Error          string    `json:"error,omitempty"`
+	Objects        int       `json:"objects"`
+	Bytes          int64     `json:"bytes"`
+	Attempts       int       `json:"attempts"`
+	Servers        string    `json:"servers"` // Comma-separated hosts of the caches or origins used
+	RerunOf        *uint     `json:"rerunOf,omitempty"`
+	StartedAt      time.Time `json:"startedAt"`
+	EndedAt        time.Time `json:"endedAt"`
+}
+
+const (
+	transferSucceeded = "succeeded"
+	transferFailed    = "failed"
+)
+
+var (
+	//go:embed migrations/*.sql
+	journalMigrations embed.FS
+
+	journalDB   *gorm.DB
+	journalErr  error
+	journalOnce sync.Once
+)
+
+func (transferRecord) TableName() string {
+	return "transfers"
+}
+
+func (record *transferRecord) Duration() time.Duration {
+	return record.EndedAt.Sub(record.StartedAt)
+}
+
+// Open the transfer journal at Client.TransferJournalLocation, creating it if needed
+func openTransferJournal() (*gorm.DB, error) {
+	journalOnce.Do(func() {
+		location := param.Client_TransferJournalLocation.GetString()
+		db, err := server_utils.InitSQLiteDB(location)
+		if err != nil {
+			journalErr = errors.Wrap(err, "failed to open the transfer journal")
+			return
+		}
+		sqldb, err := db.DB()
+		if err != nil {
+			journalErr = errors.Wrapf(err, "failed to get sql.DB from gorm DB: %s", location)
+			return
+		}
+		// The migration messages would otherwise end up in the client's output
+		goose.SetLogger(goose.NopLogger())
+		if err := server_utils.MigrateDB(sqldb, journalMigrations); err != nil {
+			journalErr = errors.Wrap(err, "failed to migrate the transfer journal")
+			return
+		}
+		journalDB = db
+	})
+	return journalDB, journalErr
+}
+
+// Start the record of a transfer about to be made
+func newTransferRecord(operation, source, dest string, recursive bool, tokenLocation, preferredCache string) *transferRecord {
+	wd, err := os.Getwd()
+	if err != nil {
+		log.Debugln("Failed to determine the working directory of the transfer:", err)
+	}
+	return &transferRecord{
+		Operation:      operation,
+		Source:         source,
+		Destination:    dest,
+		Recursive:      recursive,
+		TokenLocation:  tokenLocation,
+		PreferredCache: preferredCache,
+		WorkingDir:     wd,
+		StartedAt:      time.Now(),
+	}
+}
+
+// Returns the message shown to users for a failed transfer
+func getTransferErrorMessage(err error) string {
+	var te *client.TransferErrors
+	if errors.As(err, &te) {
+		return te.UserError()
+	}
+	return err.Error()
+}
+
+// Fill in the outcome of a transfer from its results
+func (record *transferRecord) complete(results []client.TransferResults, transferErr error) {
+	record.EndedAt = time.Now()
+	servers := []string{}
+	seen := map[string]bool{}
+	for _, result := range results {
+		record.Objects++
+		record.Bytes += result.TransferredBytes
+		record.Attempts += len(result.Attempts)
+		for _, attempt := range result.Attempts {
+			if attempt.Endpoint != "" && !seen[attempt.Endpoint] {
+				seen[attempt.Endpoint] = true
+				servers = append(servers, attempt.Endpoint)
+			}
+		}
+	}
+	record.Servers = strings.Join(servers, ",")
+	if transferErr != nil {
+		record.Status = transferFailed
+		record.Error = getTransferErrorMessage(transferErr)
+	} else {
+		record.Status = transferSucceeded
+	}
+}
+
+// Remove the transfers older than Client.TransferJournalRetention and those beyond
+// the newest Client.TransferJournalMaxEntries
+func pruneTransferJournal(db *gorm.DB, now time.Time) error {
+	if retention := param.Client_TransferJournalRetention.GetDuration(); retention > 0 {
+		if err := db.Where("started_at < ?", now.Add(-retention)).Delete(&transferRecord{}).Error; err != nil {
+			return errors.Wrap(err, "failed to prune the expired transfers")
+		}
+	}
+	if maxEntries := param.Client_TransferJournalMaxEntries.GetInt(); maxEntries > 0 {
+		newest := db.Model(&transferRecord{}).Select("id").Order("id DESC").Limit(maxEntries)
+		if err := db.Where("id NOT IN (?)", newest).Delete(&transferRecord{}).Error; err != nil {
+			return errors.Wrap(err, "failed to prune the oldest transfers")
+		}
+	}
+	return nil
+}
+
+// Record the outcome of a transfer in the transfer journal.  Failing to record a
+// transfer doesn't affect the transfer itself, so errors are only logged.
+func recordTransfer(record *transferRecord, results []client.TransferResults, transferErr error) {
+	record.complete(results, transferErr)
+	if param.Client_DisableTransferJournal.GetBool() {
+		return
+	}
+	db, err := openTransferJournal()
+	if err != nil {
+		log.Warningln("Failed to record the transfer:", err)
+		return
+	}
+	if err := db.Create(record).Error; err != nil {
+		log.Warningln("Failed to record the transfer in the transfer journal:", err)
+		return
+	}
+	if err := pruneTransferJournal(db, time.Now()); err != nil {
+		log.Warningln("Failed to prune the transfer journal:", err)
+	}
+}
@@ -0,0 +1,136 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package main
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/pelicanplatform/pelican/client"
+)
+
+func setupTransferJournal(t *testing.T) *gorm.DB {
+	viper.Reset()
+	viper.Set("Client.TransferJournalLocation", filepath.Join(t.TempDir(), "transfer-journal.sqlite"))
+	resetJournal := func() {
+		if journalDB != nil {
+			if sqldb, err := journalDB.DB(); err == nil {
+				_ = sqldb.Close()
+			}
+		}
+		journalDB, journalErr, journalOnce = nil, nil, sync.Once{}
+	}
+	resetJournal()
+	t.Cleanup(func() {
+		resetJournal()
+		viper.Reset()
+	})
+	db, err := openTransferJournal()
+	require.NoError(t, err)
+	return db
+}
+
+func TestRecordTransfer(t *testing.T) {
+	db := setupTransferJournal(t)
+
+	record := newTransferRecord("get", "pelican://example.com/foo", "/tmp/foo", false, "", "https://cache.example.com")
+	results := []client.TransferResults{
+		{TransferredBytes: 100, Attempts: []client.TransferResult{{Endpoint: "cache1.example.com"}, {Endpoint: "cache2.example.com"}}},
+		{TransferredBytes: 50, Attempts: []client.TransferResult{{Endpoint: "cache1.example.com"}}},
+	}
+	recordTransfer(record, results, nil)
+
+	failed := newTransferRecord("put", "/tmp/bar", "pelican://example.com/bar", false, "/tmp/token", "")
+	recordTransfer(failed, nil, errors.New("no space left"))
+
+	stored := []transferRecord{}
+	require.NoError(t, db.Order("id ASC").Find(&stored).Error)
+	require.Len(t, stored, 2)
+	assert.Equal(t, "get", stored[0].Operation)
+	assert.Equal(t, transferSucceeded, stored[0].Status)
+	assert.Equal(t, 2, stored[0].Objects)
+	assert.Equal(t, int64(150), stored[0].Bytes)
+	assert.Equal(t, 3, stored[0].Attempts)
+	assert.Equal(t, "cache1.example.com,cache2.example.com", stored[0].Servers)
+	assert.Equal(t, "https://cache.example.com", stored[0].PreferredCache)
+	assert.NotEmpty(t, stored[0].WorkingDir)
+
+	assert.Equal(t, transferFailed, stored[1].Status)
+	assert.Equal(t, "no space left", stored[1].Error)
+	assert.Equal(t, "/tmp/token", stored[1].TokenLocation)
+
+	// Nothing is recorded once the journal is disabled
+	viper.Set("Client.DisableTransferJournal", true)
+	recordTransfer(newTransferRecord("get", "pelican://example.com/baz", "/tmp/baz", false, "", ""), nil, nil)
+	var count int64
+	require.NoError(t, db.Model(&transferRecord{}).Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestPruneTransferJournal(t *testing.T) {
+	db := setupTransferJournal(t)
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		started := now.Add(-time.Duration(5-i) * time.Hour)
+		require.NoError(t, db.Create(&transferRecord{Operation: "get", Status: transferSucceeded, StartedAt: started, EndedAt: started}).Error)
+	}
+
+	remaining := func() (ids []uint) {
+		require.NoError(t, db.Model(&transferRecord{}).Order("id ASC").Pluck("id", &ids).Error)
+		return
+	}
+
+	viper.Set("Client.TransferJournalRetention", "3h30m")
+	viper.Set("Client.TransferJournalMaxEntries", 0)
+	require.NoError(t, pruneTransferJournal(db, now))
+	assert.Equal(t, []uint{3, 4, 5}, remaining())
+
+	viper.Set("Client.TransferJournalRetention", 0)
+	viper.Set("Client.TransferJournalMaxEntries", 2)
+	require.NoError(t, pruneTransferJournal(db, now))
+	assert.Equal(t, []uint{4, 5}, remaining())
+}
+
+func TestGetUnresolvedFailures(t *testing.T) {
+	db := setupTransferJournal(t)
+	add := func(source, status string) {
+		require.NoError(t, db.Create(&transferRecord{Operation: "get", Source: source, Destination: "/tmp", Status: status}).Error)
+	}
+	add("pelican://example.com/fixed", transferFailed)
+	add("pelican://example.com/broken", transferFailed)
+	add("pelican://example.com/fixed", transferSucceeded)
+	add("pelican://example.com/broken", transferFailed)
+	add("pelican://example.com/other", transferFailed)
+
+	failures, err := getUnresolvedFailures(db)
+	require.NoError(t, err)
+	// Only the latest failure of each transfer is re-run, oldest first
+	require.Len(t, failures, 2)
+	assert.Equal(t, uint(4), failures[0].ID)
+	assert.Equal(t, "pelican://example.com/broken", failures[0].Source)
+	assert.Equal(t, uint(5), failures[1].ID)
+}
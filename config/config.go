@@ -450,7 +450,33 @@ func GetAllPrefixes() []ConfigPrefix {
 
 // This function is for discovering federations as specified by a url during a pelican:// transfer.
 // this does not populate global fields and is more temporary per url
+//
+// If the federation's well-known metadata can't be fetched, the endpoints are looked up
+// in the DNS records of the federation's domain instead (see discoverDNSFederation).
 func DiscoverUrlFederation(ctx context.Context, federationDiscoveryUrl string) (metadata FederationDiscovery, err error) {
+	metadata, err = discoverWellKnownFederation(ctx, federationDiscoveryUrl)
+	if err != nil {
+		dnsMetadata, dnsErr := discoverDNSFederation(ctx, federationDiscoveryUrl)
+		if dnsErr != nil {
+			if !errors.Is(dnsErr, errNoDNSDiscovery) {
+				log.Debugf("DNS-based federation discovery for %s failed: %v", federationDiscoveryUrl, dnsErr)
+			}
+			return FederationDiscovery{}, err
+		}
+		log.Warningf("Federation metadata discovery at %s failed (%v); using the endpoints from its DNS records", federationDiscoveryUrl, err)
+		metadata, err = dnsMetadata, nil
+	}
+
+	log.Debugln("Federation service discovery resulted in director URL", metadata.DirectorEndpoint)
+	log.Debugln("Federation service discovery resulted in registry URL", metadata.NamespaceRegistrationEndpoint)
+	log.Debugln("Federation service discovery resulted in JWKS URL", metadata.JwksUri)
+	log.Debugln("Federation service discovery resulted in broker URL", metadata.BrokerEndpoint)
+
+	return metadata, nil
+}
+
+// Fetch the federation metadata from the discovery URL's /.well-known/pelican-configuration
+func discoverWellKnownFederation(ctx context.Context, federationDiscoveryUrl string) (metadata FederationDiscovery, err error) {
 	log.Debugln("Performing federation service discovery for specified url against endpoint", federationDiscoveryUrl)
 	federationUrl, err := url.Parse(federationDiscoveryUrl)
 	if err != nil {
@@ -521,11 +547,6 @@ func DiscoverUrlFederation(ctx context.Context, federationDiscoveryUrl string) (
 		return FederationDiscovery{}, errors.Wrapf(err, "Failure when parsing federation metadata at %s", discoveryUrl)
 	}
 
-	log.Debugln("Federation service discovery resulted in director URL", metadata.DirectorEndpoint)
-	log.Debugln("Federation service discovery resulted in registry URL", metadata.NamespaceRegistrationEndpoint)
-	log.Debugln("Federation service discovery resulted in JWKS URL", metadata.JwksUri)
-	log.Debugln("Federation service discovery resulted in broker URL", metadata.BrokerEndpoint)
-
 	return metadata, nil
 }
 
@@ -688,19 +709,26 @@ func setupTransport() {
 
 	transportDialerTimeout := param.Transport_DialerTimeout.GetDuration()
 	transportKeepAlive := param.Transport_DialerKeepAlive.GetDuration()
+	maxIdleConnsPerHost := param.Transport_MaxIdleConnsPerHost.GetInt()
+
+	dialer := &net.Dialer{
+		Timeout:   transportDialerTimeout,
+		KeepAlive: transportKeepAlive,
+	}
 
 	//Set up the transport
 	transport = &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout:   transportDialerTimeout,
-			KeepAlive: transportKeepAlive,
-		}).DialContext,
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
 		MaxIdleConns:          maxIdleConns,
+		MaxIdleConnsPerHost:   maxIdleConnsPerHost,
 		IdleConnTimeout:       idleConnTimeout,
 		TLSHandshakeTimeout:   transportTLSHandshakeTimeout,
 		ExpectContinueTimeout: expectContinueTimeout,
 		ResponseHeaderTimeout: responseHeaderTimeout,
+		// Since we provide a custom dialer and TLS config, Go will not negotiate
+		// HTTP/2 unless explicitly asked to do so.
+		ForceAttemptHTTP2: param.Transport_EnableHTTP2.GetBool(),
 	}
 	if param.TLSSkipVerify.GetBool() {
 		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
@@ -717,6 +745,20 @@ func setupTransport() {
 			}
 		}
 	}
+
+	var basePool *x509.CertPool
+	if transport.TLSClientConfig != nil && transport.TLSClientConfig.RootCAs != nil {
+		basePool = transport.TLSClientConfig.RootCAs
+	} else if systemPool, err := x509.SystemCertPool(); err == nil {
+		basePool = systemPool
+	} else {
+		basePool = x509.NewCertPool()
+	}
+	if dests, err := getTLSDestinations(basePool); err != nil {
+		log.Errorln("Failed to configure per-destination TLS settings:", err)
+	} else {
+		applyTLSDestinations(transport, dests, basePool, dialer)
+	}
 }
 
 // Return an audience string appropriate for the current server
@@ -1180,21 +1222,39 @@ func InitServer(ctx context.Context, currentServers ServerType) error {
 
 		viper.SetDefault("Origin.Multiuser", true)
 		viper.SetDefault(param.Origin_DbLocation.GetName(), "/var/lib/pelican/origin.sqlite")
+		viper.SetDefault(param.Origin_BackupLocation.GetName(), "/var/lib/pelican/origin-backups")
 		viper.SetDefault("Director.GeoIPLocation", "/var/cache/pelican/maxmind/GeoLite2-City.mmdb")
+		viper.SetDefault(param.Director_DbLocation.GetName(), "/var/lib/pelican/director.sqlite")
 		viper.SetDefault("Registry.DbLocation", "/var/lib/pelican/registry.sqlite")
 		// The lotman db will actually take this path and create the lot at /path/.lot/lotman_cpp.sqlite
 		viper.SetDefault("Lotman.DbLocation", "/var/lib/pelican")
 		viper.SetDefault("Monitoring.DataLocation", "/var/lib/pelican/monitoring/data")
+		viper.SetDefault(param.Monitoring_HistoryDbLocation.GetName(), "/var/lib/pelican/monitoring/history.sqlite")
+		viper.SetDefault(param.Monitoring_PacketSpoolLocation.GetName(), "/var/lib/pelican/monitoring/packet-spool")
+		viper.SetDefault(param.Server_UIAuthDbLocation.GetName(), "/var/lib/pelican/web-ui-auth.sqlite")
+		viper.SetDefault(param.Server_NotificationDbLocation.GetName(), "/var/lib/pelican/notifications.sqlite")
+		viper.SetDefault(param.Federation_TrustBundleLocation.GetName(), "/var/lib/pelican/federation-trust-bundle.jwt")
+		viper.SetDefault(param.Origin_UploadStagingLocation.GetName(), "/var/lib/pelican/upload-staging")
+		viper.SetDefault(param.Cache_WriteBackLocation.GetName(), "/var/lib/pelican/cache-writeback")
 		viper.SetDefault("Shoveler.QueueDirectory", "/var/spool/pelican/shoveler/queue")
 		viper.SetDefault("Shoveler.AMQPTokenLocation", "/etc/pelican/shoveler-token")
 		viper.SetDefault(param.Origin_GlobusConfigLocation.GetName(), filepath.Join("/run", "pelican", "xrootd", "origin", "globus"))
 	} else {
 		viper.SetDefault(param.Origin_DbLocation.GetName(), filepath.Join(configDir, "origin.sqlite"))
+		viper.SetDefault(param.Origin_BackupLocation.GetName(), filepath.Join(configDir, "origin-backups"))
 		viper.SetDefault("Director.GeoIPLocation", filepath.Join(configDir, "maxmind", "GeoLite2-City.mmdb"))
+		viper.SetDefault(param.Director_DbLocation.GetName(), filepath.Join(configDir, "director.sqlite"))
 		viper.SetDefault("Registry.DbLocation", filepath.Join(configDir, "ns-registry.sqlite"))
 		// Lotdb will live at <configDir>/.lot/lotman_cpp.sqlite
 		viper.SetDefault("Lotman.DbLocation", configDir)
 		viper.SetDefault("Monitoring.DataLocation", filepath.Join(configDir, "monitoring/data"))
+		viper.SetDefault(param.Monitoring_HistoryDbLocation.GetName(), filepath.Join(configDir, "monitoring", "history.sqlite"))
+		viper.SetDefault(param.Monitoring_PacketSpoolLocation.GetName(), filepath.Join(configDir, "monitoring", "packet-spool"))
+		viper.SetDefault(param.Server_UIAuthDbLocation.GetName(), filepath.Join(configDir, "web-ui-auth.sqlite"))
+		viper.SetDefault(param.Server_NotificationDbLocation.GetName(), filepath.Join(configDir, "notifications.sqlite"))
+		viper.SetDefault(param.Federation_TrustBundleLocation.GetName(), filepath.Join(configDir, "federation-trust-bundle.jwt"))
+		viper.SetDefault(param.Origin_UploadStagingLocation.GetName(), filepath.Join(configDir, "upload-staging"))
+		viper.SetDefault(param.Cache_WriteBackLocation.GetName(), filepath.Join(configDir, "cache-writeback"))
 		viper.SetDefault("Shoveler.QueueDirectory", filepath.Join(configDir, "shoveler/queue"))
 		viper.SetDefault("Shoveler.AMQPTokenLocation", filepath.Join(configDir, "shoveler-token"))
 
@@ -1547,6 +1607,11 @@ func InitClient() error {
 
 	configDir := viper.GetString("ConfigDir")
 	viper.SetDefault("IssuerKey", filepath.Join(configDir, "issuer.jwk"))
+	viper.SetDefault("Client.TransferJournalLocation", filepath.Join(configDir, "transfer-journal.sqlite"))
+	viper.SetDefault("Client.AnnouncementsLocation", filepath.Join(configDir, "announcements.json"))
+	viper.SetDefault("Client.UploadJournalLocation", filepath.Join(configDir, "upload-journal"))
+	viper.SetDefault("Federation.TrustBundleLocation", filepath.Join(configDir, "federation-trust-bundle.jwt"))
+	viper.SetDefault("Client.ManagedConfigLocation", filepath.Join(configDir, "managed-client-config.jwt"))
 
 	upper_prefix := GetPreferredPrefix()
 
@@ -1612,6 +1677,10 @@ func InitClient() error {
 		}
 	}
 
+	// Layer the admin-managed configuration beneath the user's, now that the user's
+	// configuration tells where to find it
+	initManagedClientConfig(context.Background())
+
 	// Check the environment variable STASHCP_MINIMUM_DOWNLOAD_SPEED (and all the prefix variants)
 	var downloadLimit int64 = 1024 * 100
 	var prefixes_with_cp []ConfigPrefix
@@ -44,7 +44,6 @@ func findFieldByTag(t reflect.Type, tagKey, tagValue string) (reflect.StructFiel
 
 // validateConfigKeys checks keys in the Viper config against fields in the Config struct
 func validateConfigKeys() []string {
-	possibleCfg := param.Config{}
 	unknownKeys := []string{}
 	// Get all currently-configured keys from Viper. This is a collection of default
 	// configurations (both set internally and in defaults.yaml) and user-provided config.
@@ -65,38 +64,43 @@ func validateConfigKeys() []string {
 		}
 	}
 
+	for _, key := range keys {
+		if !isKnownConfigKey(key) {
+			unknownKeys = append(unknownKeys, key)
+		}
+	}
+
+	return unknownKeys
+}
+
+// Check whether a (case-insensitive, dot-separated) key is a Pelican configuration parameter
+func isKnownConfigKey(key string) bool {
+	possibleCfg := param.Config{}
 	// Convert the config struct to a map
 	configValue := reflect.ValueOf(possibleCfg)
 	if configValue.Kind() == reflect.Ptr {
 		configValue = configValue.Elem()
 	}
-	configType := configValue.Type()
-
-	// Iterate over all keys
-	for _, key := range keys {
-		parts := strings.Split(key, ".")
-		// Start with the top-level struct
-		currentType := configType
+	// Start with the top-level struct
+	currentType := configValue.Type()
 
-		for idx, part := range parts {
-			// Check if the part exists in the current struct
-			if idx == 0 && part == "config" { // A special case for the top-level config struct
-				continue
-			}
-			field, present := findFieldByTag(currentType, "mapstructure", part)
-			if !present {
-				unknownKeys = append(unknownKeys, key)
-				break
-			}
+	parts := strings.Split(strings.ToLower(key), ".")
+	for idx, part := range parts {
+		// Check if the part exists in the current struct
+		if idx == 0 && part == "config" { // A special case for the top-level config struct
+			continue
+		}
+		field, present := findFieldByTag(currentType, "mapstructure", part)
+		if !present {
+			return false
+		}
 
-			// If the field is a struct, descend into it
-			if field.Type.Kind() == reflect.Struct {
-				currentType = field.Type
-			} else {
-				break
-			}
+		// If the field is a struct, descend into it
+		if field.Type.Kind() == reflect.Struct {
+			currentType = field.Type
+		} else {
+			break
 		}
 	}
-
-	return unknownKeys
+	return true
 }
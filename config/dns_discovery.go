@@ -0,0 +1,220 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/param"
+)
+
+type (
+	// The DNS lookups used for federation discovery; replaced in tests
+	dnsDiscoveryResolver interface {
+		LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+		LookupTXT(ctx context.Context, name string) ([]string, error)
+	}
+
+	dnsDiscoveryEntry struct {
+		metadata FederationDiscovery
+		err      error
+		expiry   time.Time
+	}
+)
+
+const (
+	// How long the endpoints found in a federation's DNS records are reused
+	dnsDiscoveryCacheLifetime = 15 * time.Minute
+	// How long a failed lookup is remembered, so clients that can't reach the federation
+	// don't query the DNS for every transfer
+	dnsDiscoveryNegativeCacheLifetime = time.Minute
+)
+
+var (
+	dnsResolver dnsDiscoveryResolver = net.DefaultResolver
+
+	dnsDiscoveryCache      = make(map[string]dnsDiscoveryEntry)
+	dnsDiscoveryCacheMutex sync.Mutex
+
+	// Returned when the DNS isn't consulted for a federation
+	errNoDNSDiscovery = errors.New("DNS-based federation discovery is not used")
+)
+
+// Check the endpoints advertised in a federation's DNS records are absolute http(s) URLs
+func validateFederationDiscovery(metadata FederationDiscovery) error {
+	endpoints := []struct{ name, value string }{
+		{"director_endpoint", metadata.DirectorEndpoint},
+		{"namespace_registration_endpoint", metadata.NamespaceRegistrationEndpoint},
+		{"jwks_uri", metadata.JwksUri},
+		{"broker_endpoint", metadata.BrokerEndpoint},
+	}
+	for _, endpoint := range endpoints {
+		if endpoint.value == "" {
+			continue
+		}
+		endpointUrl, err := url.Parse(endpoint.value)
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s %q", endpoint.name, endpoint.value)
+		}
+		if (endpointUrl.Scheme != "https" && endpointUrl.Scheme != "http") || endpointUrl.Host == "" {
+			return errors.Errorf("invalid %s %q: must be an absolute http(s) URL", endpoint.name, endpoint.value)
+		}
+	}
+	return nil
+}
+
+// Get the domain whose DNS records describe the federation, or "" if the
+// federation discovery URL can't have any
+func getDNSDiscoveryDomain(federationDiscoveryUrl string) string {
+	if !strings.Contains(federationDiscoveryUrl, "://") {
+		// A bare hostname such as "osg-htc.org"
+		federationDiscoveryUrl = "https://" + federationDiscoveryUrl
+	}
+	federationUrl, err := url.Parse(federationDiscoveryUrl)
+	if err != nil {
+		return ""
+	}
+	host := strings.TrimSuffix(strings.ToLower(federationUrl.Hostname()), ".")
+	if host == "" || host == "localhost" || net.ParseIP(host) != nil {
+		return ""
+	}
+	return host
+}
+
+// Look up the federation endpoints in the DNS records of the federation's domain:
+//
+//   - TXT records at _pelican.<domain> of space-separated key=value pairs, where the keys are
+//     director, registry, jwks, and broker and the values are the endpoint URLs
+//   - SRV records at _pelican-director._tcp.<domain> and _pelican-registry._tcp.<domain>,
+//     used for the director and registry if there's no TXT record for them
+//
+// The records must name a director; if they don't name a JWKS, the director's is used.
+// Results are cached, so the DNS isn't queried for every transfer.
+func discoverDNSFederation(ctx context.Context, federationDiscoveryUrl string) (FederationDiscovery, error) {
+	if !param.Federation_EnableDNSDiscovery.GetBool() {
+		return FederationDiscovery{}, errNoDNSDiscovery
+	}
+	domain := getDNSDiscoveryDomain(federationDiscoveryUrl)
+	if domain == "" || ctx.Err() != nil {
+		return FederationDiscovery{}, errNoDNSDiscovery
+	}
+
+	now := time.Now()
+	dnsDiscoveryCacheMutex.Lock()
+	entry, ok := dnsDiscoveryCache[domain]
+	dnsDiscoveryCacheMutex.Unlock()
+	if ok && now.Before(entry.expiry) {
+		return entry.metadata, entry.err
+	}
+
+	log.Debugln("Performing DNS-based federation service discovery for domain", domain)
+	metadata, err := lookupDNSFederation(ctx, domain)
+	if ctx.Err() != nil {
+		// Don't remember a lookup cut short by the caller
+		return FederationDiscovery{}, err
+	}
+	entry = dnsDiscoveryEntry{metadata: metadata, err: err, expiry: now.Add(dnsDiscoveryCacheLifetime)}
+	if err != nil {
+		entry.expiry = now.Add(dnsDiscoveryNegativeCacheLifetime)
+	}
+	dnsDiscoveryCacheMutex.Lock()
+	dnsDiscoveryCache[domain] = entry
+	dnsDiscoveryCacheMutex.Unlock()
+	return metadata, err
+}
+
+func lookupDNSFederation(ctx context.Context, domain string) (metadata FederationDiscovery, err error) {
+	txtName := "_pelican." + domain
+	records, txtErr := dnsResolver.LookupTXT(ctx, txtName)
+	if txtErr != nil {
+		log.Debugf("No federation TXT records at %s: %v", txtName, txtErr)
+	}
+	for _, record := range records {
+		for _, field := range strings.Fields(record) {
+			key, value, found := strings.Cut(field, "=")
+			if !found {
+				continue
+			}
+			switch strings.ToLower(key) {
+			case "director":
+				metadata.DirectorEndpoint = value
+			case "registry":
+				metadata.NamespaceRegistrationEndpoint = value
+			case "jwks":
+				metadata.JwksUri = value
+			case "broker":
+				metadata.BrokerEndpoint = value
+			}
+		}
+	}
+
+	if metadata.DirectorEndpoint == "" {
+		metadata.DirectorEndpoint = lookupDNSEndpoint(ctx, "pelican-director", domain)
+	}
+	if metadata.NamespaceRegistrationEndpoint == "" {
+		metadata.NamespaceRegistrationEndpoint = lookupDNSEndpoint(ctx, "pelican-registry", domain)
+	}
+	if metadata.DirectorEndpoint == "" {
+		return FederationDiscovery{}, errors.Errorf("no director is advertised in the DNS records of %s", domain)
+	}
+	if metadata.JwksUri == "" {
+		metadata.JwksUri = strings.TrimSuffix(metadata.DirectorEndpoint, "/") + "/.well-known/issuer.jwks"
+	}
+	if err = validateFederationDiscovery(metadata); err != nil {
+		return FederationDiscovery{}, errors.Wrapf(err, "invalid federation DNS records for %s", domain)
+	}
+	return metadata, nil
+}
+
+// Get the https URL of the most preferred target of a service's SRV records, or "" if there's none
+func lookupDNSEndpoint(ctx context.Context, service, domain string) string {
+	_, addrs, err := dnsResolver.LookupSRV(ctx, service, "tcp", domain)
+	if err != nil {
+		log.Debugf("No SRV records for the %s service of %s: %v", service, domain, err)
+		return ""
+	}
+	// The records are sorted by priority and randomized by weight
+	for _, addr := range addrs {
+		target := strings.TrimSuffix(addr.Target, ".")
+		if target == "" {
+			continue
+		}
+		if addr.Port == 443 {
+			return "https://" + target
+		}
+		return fmt.Sprintf("https://%s:%d", target, addr.Port)
+	}
+	return ""
+}
+
+// Forget the endpoints found in the DNS; for unit tests
+func resetDNSDiscoveryCache() {
+	dnsDiscoveryCacheMutex.Lock()
+	defer dnsDiscoveryCacheMutex.Unlock()
+	dnsDiscoveryCache = make(map[string]dnsDiscoveryEntry)
+}
@@ -0,0 +1,177 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package config
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockDNSResolver struct {
+	srv     map[string][]*net.SRV
+	txt     map[string][]string
+	lookups int
+}
+
+func (r *mockDNSResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	r.lookups++
+	addrs, ok := r.srv["_"+service+"._"+proto+"."+name]
+	if !ok {
+		return "", nil, errors.New("no such host")
+	}
+	return "", addrs, nil
+}
+
+func (r *mockDNSResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	r.lookups++
+	records, ok := r.txt[name]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return records, nil
+}
+
+func setMockDNSResolver(t *testing.T, resolver *mockDNSResolver) {
+	oldResolver := dnsResolver
+	dnsResolver = resolver
+	resetDNSDiscoveryCache()
+	viper.Set("Federation.EnableDNSDiscovery", true)
+	t.Cleanup(func() {
+		dnsResolver = oldResolver
+		resetDNSDiscoveryCache()
+		viper.Reset()
+	})
+}
+
+func TestGetDNSDiscoveryDomain(t *testing.T) {
+	assert.Equal(t, "example.org", getDNSDiscoveryDomain("https://example.org"))
+	assert.Equal(t, "example.org", getDNSDiscoveryDomain("https://Example.org.:8444"))
+	assert.Equal(t, "example.org", getDNSDiscoveryDomain("example.org"))
+	assert.Equal(t, "example.org", getDNSDiscoveryDomain("example.org:8444"))
+	assert.Equal(t, "", getDNSDiscoveryDomain("https://127.0.0.1:8444"))
+	assert.Equal(t, "", getDNSDiscoveryDomain("https://localhost:8444"))
+	assert.Equal(t, "", getDNSDiscoveryDomain(""))
+}
+
+func TestDiscoverDNSFederation(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("txt-records", func(t *testing.T) {
+		setMockDNSResolver(t, &mockDNSResolver{txt: map[string][]string{
+			"_pelican.example.org": {"director=https://director.example.org registry=https://registry.example.org", "broker=https://broker.example.org"},
+		}})
+		metadata, err := discoverDNSFederation(ctx, "https://example.org")
+		require.NoError(t, err)
+		assert.Equal(t, FederationDiscovery{
+			DirectorEndpoint:              "https://director.example.org",
+			NamespaceRegistrationEndpoint: "https://registry.example.org",
+			JwksUri:                       "https://director.example.org/.well-known/issuer.jwks",
+			BrokerEndpoint:                "https://broker.example.org",
+		}, metadata)
+	})
+
+	t.Run("srv-records", func(t *testing.T) {
+		setMockDNSResolver(t, &mockDNSResolver{srv: map[string][]*net.SRV{
+			"_pelican-director._tcp.example.org": {{Target: "director.example.org.", Port: 443}},
+			"_pelican-registry._tcp.example.org": {{Target: "registry.example.org.", Port: 8444}},
+		}})
+		metadata, err := discoverDNSFederation(ctx, "example.org")
+		require.NoError(t, err)
+		assert.Equal(t, "https://director.example.org", metadata.DirectorEndpoint)
+		assert.Equal(t, "https://registry.example.org:8444", metadata.NamespaceRegistrationEndpoint)
+	})
+
+	t.Run("txt-overrides-srv", func(t *testing.T) {
+		setMockDNSResolver(t, &mockDNSResolver{
+			txt: map[string][]string{"_pelican.example.org": {"director=https://txt.example.org jwks=https://keys.example.org/jwks"}},
+			srv: map[string][]*net.SRV{"_pelican-director._tcp.example.org": {{Target: "srv.example.org.", Port: 443}}},
+		})
+		metadata, err := discoverDNSFederation(ctx, "example.org")
+		require.NoError(t, err)
+		assert.Equal(t, "https://txt.example.org", metadata.DirectorEndpoint)
+		assert.Equal(t, "https://keys.example.org/jwks", metadata.JwksUri)
+	})
+
+	t.Run("no-director", func(t *testing.T) {
+		setMockDNSResolver(t, &mockDNSResolver{txt: map[string][]string{"_pelican.example.org": {"registry=https://registry.example.org"}}})
+		_, err := discoverDNSFederation(ctx, "example.org")
+		assert.ErrorContains(t, err, "no director")
+	})
+
+	t.Run("invalid-endpoint", func(t *testing.T) {
+		setMockDNSResolver(t, &mockDNSResolver{txt: map[string][]string{"_pelican.example.org": {"director=director.example.org"}}})
+		_, err := discoverDNSFederation(ctx, "example.org")
+		assert.ErrorContains(t, err, "invalid director_endpoint")
+	})
+
+	t.Run("results-are-cached", func(t *testing.T) {
+		resolver := &mockDNSResolver{txt: map[string][]string{"_pelican.example.org": {"director=https://director.example.org registry=https://registry.example.org"}}}
+		setMockDNSResolver(t, resolver)
+		_, err := discoverDNSFederation(ctx, "example.org")
+		require.NoError(t, err)
+		lookups := resolver.lookups
+		_, err = discoverDNSFederation(ctx, "https://example.org")
+		require.NoError(t, err)
+		assert.Equal(t, lookups, resolver.lookups)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		resolver := &mockDNSResolver{txt: map[string][]string{"_pelican.example.org": {"director=https://director.example.org"}}}
+		setMockDNSResolver(t, resolver)
+		viper.Set("Federation.EnableDNSDiscovery", false)
+		_, err := discoverDNSFederation(ctx, "example.org")
+		assert.ErrorIs(t, err, errNoDNSDiscovery)
+		assert.Zero(t, resolver.lookups)
+	})
+}
+
+func TestDiscoverUrlFederationDNSFallback(t *testing.T) {
+	setMockDNSResolver(t, &mockDNSResolver{txt: map[string][]string{
+		"_pelican.example.org": {"director=https://director.example.org registry=https://registry.example.org"},
+	}})
+	// Block the HTTPS connections to the discovery host
+	tr := GetTransport()
+	oldDial := tr.DialContext
+	tr.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, errors.New("connection blocked")
+	}
+	t.Cleanup(func() { tr.DialContext = oldDial })
+
+	metadata, err := DiscoverUrlFederation(context.Background(), "https://example.org")
+	require.NoError(t, err)
+	assert.Equal(t, "https://director.example.org", metadata.DirectorEndpoint)
+	assert.Equal(t, "https://registry.example.org", metadata.NamespaceRegistrationEndpoint)
+
+	// Without DNS records, the original error is reported
+	_, err = DiscoverUrlFederation(context.Background(), "https://other.example.org")
+	assert.ErrorContains(t, err, "connection blocked")
+}
+
+func TestValidateFederationDiscovery(t *testing.T) {
+	assert.NoError(t, validateFederationDiscovery(FederationDiscovery{DirectorEndpoint: "https://director.example.org"}))
+	assert.NoError(t, validateFederationDiscovery(FederationDiscovery{}))
+	assert.Error(t, validateFederationDiscovery(FederationDiscovery{JwksUri: "/.well-known/issuer.jwks"}))
+	assert.Error(t, validateFederationDiscovery(FederationDiscovery{BrokerEndpoint: "ftp://broker.example.org"}))
+}
@@ -174,3 +174,11 @@ func SetLogging(logLevel log.Level) {
 	log.SetFormatter(&textFormatter)
 	log.SetLevel(logLevel)
 }
+
+// Apply the log censoring (e.g., removing token signatures) to arbitrary text
+func RedactTokens(contents string) string {
+	for _, replace := range globalTransform.replacements {
+		contents = replace.regex.ReplaceAllString(contents, replace.template)
+	}
+	return contents
+}
@@ -0,0 +1,217 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+
+	"github.com/pelicanplatform/pelican/param"
+)
+
+type (
+	// A client configuration managed by the admins of a shared install, e.g. a cluster,
+	// published at Client.ManagedConfigUrl as a JWT signed with one of the keys in
+	// Client.ManagedConfigKeys.  Its settings sit beneath the user's configuration, except
+	// for the locked ones, which users can't override.
+	ManagedClientConfig struct {
+		// The configuration parameters, by their full name (e.g. "Client.WorkerCount")
+		Settings map[string]interface{} `json:"settings"`
+		// The parameters users aren't permitted to override
+		Locked []string `json:"locked,omitempty"`
+
+		// The validity of the signed configuration
+		Expiry time.Time `json:"-"`
+	}
+)
+
+const (
+	ManagedClientConfigClaim = "pelican_client_config"
+
+	managedClientConfigTimeout = 10 * time.Second
+)
+
+// Parse a signed managed client configuration, verifying it with the given keys
+func ParseManagedClientConfig(signed []byte, keys jwk.Set) (*ManagedClientConfig, error) {
+	tok, err := jwt.Parse(signed, jwt.WithKeySet(keys), jwt.WithValidate(true))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to verify the managed client configuration")
+	}
+	claim, ok := tok.Get(ManagedClientConfigClaim)
+	if !ok {
+		return nil, errors.Errorf("the managed client configuration has no %s claim", ManagedClientConfigClaim)
+	}
+	claimJson, err := json.Marshal(claim)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the managed client configuration")
+	}
+	managed := &ManagedClientConfig{}
+	if err = json.Unmarshal(claimJson, managed); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the managed client configuration")
+	}
+	managed.Expiry = tok.Expiration()
+	return managed, nil
+}
+
+// Load the public keys trusted to sign the managed client configuration
+func loadManagedClientConfigKeys() (jwk.Set, error) {
+	keysFile := param.Client_ManagedConfigKeys.GetString()
+	if keysFile == "" {
+		return nil, errors.Errorf("%s is set but %s is not; the managed client configuration can't be verified",
+			param.Client_ManagedConfigUrl.GetName(), param.Client_ManagedConfigKeys.GetName())
+	}
+	keys, err := jwk.ReadFile(keysFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read the keys of the managed client configuration from %s", keysFile)
+	}
+	if keys.Len() == 0 {
+		return nil, errors.Errorf("%s holds no keys to verify the managed client configuration", keysFile)
+	}
+	return keys, nil
+}
+
+// Save a verified configuration at Client.ManagedConfigLocation so it's still applied
+// when Client.ManagedConfigUrl can't be reached
+func saveManagedClientConfig(signed []byte) error {
+	location := param.Client_ManagedConfigLocation.GetString()
+	if location == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(location), 0755); err != nil {
+		return errors.Wrap(err, "failed to create the directory of the managed client configuration")
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(location), filepath.Base(location))
+	if err != nil {
+		return errors.Wrap(err, "failed to cache the managed client configuration")
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err = tmpFile.Write(signed); err != nil {
+		tmpFile.Close()
+		return errors.Wrap(err, "failed to cache the managed client configuration")
+	}
+	if err = tmpFile.Close(); err != nil {
+		return errors.Wrap(err, "failed to cache the managed client configuration")
+	}
+	if err = os.Chmod(tmpFile.Name(), 0644); err != nil {
+		return errors.Wrap(err, "failed to cache the managed client configuration")
+	}
+	return errors.Wrap(os.Rename(tmpFile.Name(), location), "failed to cache the managed client configuration")
+}
+
+// Get the managed client configuration from Client.ManagedConfigUrl, falling back to
+// the copy cached at Client.ManagedConfigLocation while it's valid
+func getManagedClientConfig(ctx context.Context) (*ManagedClientConfig, error) {
+	keys, err := loadManagedClientConfigKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	configUrl := param.Client_ManagedConfigUrl.GetString()
+	fetchCtx, cancel := context.WithTimeout(ctx, managedClientConfigTimeout)
+	defer cancel()
+	client := &http.Client{Transport: http.DefaultTransport}
+	signed, fetchErr := fetchURL(fetchCtx, client, configUrl)
+	if fetchErr == nil {
+		managed, err := ParseManagedClientConfig(signed, keys)
+		if err == nil {
+			if err = saveManagedClientConfig(signed); err != nil {
+				log.Warningln(err)
+			}
+			return managed, nil
+		}
+		fetchErr = err
+	}
+
+	location := param.Client_ManagedConfigLocation.GetString()
+	if location == "" {
+		return nil, errors.Wrapf(fetchErr, "failed to get the managed client configuration from %s", configUrl)
+	}
+	cached, err := os.ReadFile(location)
+	if err != nil {
+		return nil, errors.Wrapf(fetchErr, "failed to get the managed client configuration from %s", configUrl)
+	}
+	managed, err := ParseManagedClientConfig(cached, keys)
+	if err != nil {
+		return nil, errors.Wrapf(fetchErr, "failed to get the managed client configuration from %s and the cached copy is unusable (%v)", configUrl, err)
+	}
+	log.Warningf("Failed to get the managed client configuration from %s; using the copy cached at %s: %v", configUrl, location, fetchErr)
+	return managed, nil
+}
+
+// Apply a managed client configuration: its settings become the defaults beneath the
+// user's configuration, while the locked ones override it.  Settings that aren't
+// configuration parameters, and the parameters that point at the managed configuration
+// itself, are ignored.
+func applyManagedClientConfig(managed *ManagedClientConfig) {
+	locked := make(map[string]bool, len(managed.Locked))
+	for _, key := range managed.Locked {
+		locked[strings.ToLower(key)] = true
+	}
+
+	keys := make([]string, 0, len(managed.Settings))
+	for key := range managed.Settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		lowerKey := strings.ToLower(key)
+		if strings.HasPrefix(lowerKey, "client.managedconfig") {
+			log.Warningf("Ignoring %s in the managed client configuration; it can't configure itself", key)
+			continue
+		}
+		if !isKnownConfigKey(lowerKey) {
+			log.Warningf("Ignoring unknown configuration key %s in the managed client configuration", key)
+			continue
+		}
+		if locked[lowerKey] {
+			viper.Set(key, managed.Settings[key])
+		} else {
+			viper.SetDefault(key, managed.Settings[key])
+		}
+	}
+}
+
+// Fetch, verify, and apply the managed client configuration if Client.ManagedConfigUrl is set.
+// A configuration that can't be fetched or verified is skipped with a warning so clients
+// keep working with their own configuration.
+func initManagedClientConfig(ctx context.Context) {
+	if param.Client_ManagedConfigUrl.GetString() == "" {
+		return
+	}
+	managed, err := getManagedClientConfig(ctx)
+	if err != nil {
+		log.Warningln("Ignoring the managed client configuration:", err)
+		return
+	}
+	applyManagedClientConfig(managed)
+	log.Debugf("Applied the managed client configuration from %s, valid until %s",
+		param.Client_ManagedConfigUrl.GetString(), managed.Expiry.Format(time.RFC3339))
+}
@@ -0,0 +1,158 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func signManagedClientConfig(t *testing.T, signer jwk.Key, managed ManagedClientConfig, lifetime time.Duration) []byte {
+	tok, err := jwt.NewBuilder().
+		IssuedAt(time.Now()).
+		Expiration(time.Now().Add(lifetime)).
+		Claim(ManagedClientConfigClaim, managed).
+		Build()
+	require.NoError(t, err)
+	signed, err := jwt.Sign(tok, jwt.WithKey(jwa.ES256, signer))
+	require.NoError(t, err)
+	return signed
+}
+
+func writeManagedClientConfigKeys(t *testing.T, dir string, key jwk.Key) string {
+	pub, err := key.PublicKey()
+	require.NoError(t, err)
+	keys := jwk.NewSet()
+	require.NoError(t, keys.AddKey(pub))
+	keysJson, err := json.Marshal(keys)
+	require.NoError(t, err)
+	keysFile := filepath.Join(dir, "managed-config-keys.jwks")
+	require.NoError(t, os.WriteFile(keysFile, keysJson, 0644))
+	return keysFile
+}
+
+func TestManagedClientConfig(t *testing.T) {
+	t.Cleanup(viper.Reset)
+
+	signer := newTrustBundleKey(t)
+	managed := ManagedClientConfig{
+		Settings: map[string]interface{}{
+			"Client.WorkerCount":          10,
+			"Client.MaximumDownloadSpeed": 1000,
+			"Client.SmallFileThreshold":   2048,
+			"Client.NotAParameter":        true,
+			"Client.ManagedConfigUrl":     "https://elsewhere.example.com",
+		},
+		Locked: []string{"Client.MaximumDownloadSpeed"},
+	}
+
+	var mutex sync.Mutex
+	served := signManagedClientConfig(t, signer, managed, time.Hour)
+	up := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if !up {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write(served)
+	}))
+	t.Cleanup(server.Close)
+	serve := func(signed []byte, isUp bool) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		served = signed
+		up = isUp
+	}
+
+	setup := func(dir string) {
+		viper.Reset()
+		viper.SetConfigType("yaml")
+		// The user's own configuration
+		require.NoError(t, viper.MergeConfig(strings.NewReader("Client:\n  WorkerCount: 3\n  MaximumDownloadSpeed: 5\n")))
+		viper.Set("Client.ManagedConfigUrl", server.URL)
+		viper.Set("Client.ManagedConfigKeys", writeManagedClientConfigKeys(t, dir, signer))
+		viper.Set("Client.ManagedConfigLocation", filepath.Join(dir, "managed-client-config.jwt"))
+	}
+
+	t.Run("merged-beneath-user-config", func(t *testing.T) {
+		setup(t.TempDir())
+		serve(signManagedClientConfig(t, signer, managed, time.Hour), true)
+		initManagedClientConfig(context.Background())
+
+		// The user's value wins unless the setting is locked
+		assert.Equal(t, 3, viper.GetInt("Client.WorkerCount"))
+		assert.Equal(t, 1000, viper.GetInt("Client.MaximumDownloadSpeed"))
+		assert.Equal(t, 2048, viper.GetInt("Client.SmallFileThreshold"))
+		assert.False(t, viper.IsSet("Client.NotAParameter"))
+		assert.Equal(t, server.URL, viper.GetString("Client.ManagedConfigUrl"))
+	})
+
+	t.Run("cached-copy-used-when-unreachable", func(t *testing.T) {
+		dir := t.TempDir()
+		setup(dir)
+		serve(signManagedClientConfig(t, signer, managed, time.Hour), true)
+		initManagedClientConfig(context.Background())
+		assert.FileExists(t, filepath.Join(dir, "managed-client-config.jwt"))
+
+		setup(dir)
+		serve(nil, false)
+		initManagedClientConfig(context.Background())
+		assert.Equal(t, 1000, viper.GetInt("Client.MaximumDownloadSpeed"))
+	})
+
+	t.Run("unverified-config-is-ignored", func(t *testing.T) {
+		setup(t.TempDir())
+		serve(signManagedClientConfig(t, newTrustBundleKey(t), managed, time.Hour), true)
+		initManagedClientConfig(context.Background())
+		assert.Equal(t, 5, viper.GetInt("Client.MaximumDownloadSpeed"))
+		assert.False(t, viper.IsSet("Client.SmallFileThreshold"))
+	})
+
+	t.Run("expired-config-is-ignored", func(t *testing.T) {
+		setup(t.TempDir())
+		serve(signManagedClientConfig(t, signer, managed, -time.Minute), true)
+		initManagedClientConfig(context.Background())
+		assert.Equal(t, 5, viper.GetInt("Client.MaximumDownloadSpeed"))
+	})
+
+	t.Run("missing-keys", func(t *testing.T) {
+		setup(t.TempDir())
+		viper.Set("Client.ManagedConfigKeys", "")
+		serve(signManagedClientConfig(t, signer, managed, time.Hour), true)
+		initManagedClientConfig(context.Background())
+		assert.Equal(t, 5, viper.GetInt("Client.MaximumDownloadSpeed"))
+	})
+}
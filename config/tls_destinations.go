@@ -0,0 +1,298 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package config
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/param"
+)
+
+type (
+	// TLSDestination is the configuration of additional TLS trust and client
+	// credentials for a set of remote hosts (see the Transport.TLSDestinations parameter)
+	TLSDestination struct {
+		Hosts                 []string `mapstructure:"Hosts"`
+		CACertificateFile     string   `mapstructure:"CACertificateFile"`
+		ClientCertificateFile string   `mapstructure:"ClientCertificateFile"`
+		ClientKeyFile         string   `mapstructure:"ClientKeyFile"`
+	}
+
+	// The loaded form of a TLSDestination
+	tlsDestination struct {
+		hosts      []string
+		caCerts    []*x509.Certificate // Additional CAs trusted for the destination
+		rootCAs    *x509.CertPool      // The base pool plus caCerts
+		clientCert *tls.Certificate
+	}
+)
+
+// Returns true if the hostname matches the pattern.  Patterns are either an exact
+// hostname or a wildcard of the form "*.example.com", which matches any subdomain
+// of example.com (but not example.com itself).
+func matchTLSDestinationHost(pattern, host string) bool {
+	pattern = strings.ToLower(pattern)
+	host = strings.ToLower(host)
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return pattern == host
+}
+
+// Find the destination configuration for a given hostname, if any.
+// The first matching entry wins.
+func findTLSDestination(dests []tlsDestination, host string) *tlsDestination {
+	for idx := range dests {
+		for _, pattern := range dests[idx].hosts {
+			if matchTLSDestinationHost(pattern, host) {
+				return &dests[idx]
+			}
+		}
+	}
+	return nil
+}
+
+// Load the certificates for a configured destination; the additional CAs are
+// appended to a copy of the provided base pool.
+func loadTLSDestination(dest TLSDestination, basePool *x509.CertPool) (result tlsDestination, err error) {
+	if len(dest.Hosts) == 0 {
+		err = errors.New("TLS destination must specify at least one host")
+		return
+	}
+	for _, host := range dest.Hosts {
+		if strings.Contains(host, "/") || strings.Contains(host, ":") {
+			err = errors.Errorf("invalid host %q in TLS destination; only hostnames or wildcards of the form *.example.com are permitted", host)
+			return
+		}
+	}
+	result.hosts = dest.Hosts
+
+	if dest.CACertificateFile != "" {
+		var caBytes []byte
+		if caBytes, err = os.ReadFile(dest.CACertificateFile); err != nil {
+			err = errors.Wrapf(err, "failed to read CA bundle for TLS destination %s", strings.Join(dest.Hosts, ","))
+			return
+		}
+		result.rootCAs = basePool.Clone()
+		for block, rest := pem.Decode(caBytes); block != nil; block, rest = pem.Decode(rest) {
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			var caCert *x509.Certificate
+			if caCert, err = x509.ParseCertificate(block.Bytes); err != nil {
+				err = errors.Wrapf(err, "invalid certificate in %s", dest.CACertificateFile)
+				return
+			}
+			result.caCerts = append(result.caCerts, caCert)
+			result.rootCAs.AddCert(caCert)
+		}
+		if len(result.caCerts) == 0 {
+			err = errors.Errorf("no valid PEM-encoded certificates found in %s", dest.CACertificateFile)
+			return
+		}
+	}
+
+	if dest.ClientCertificateFile != "" || dest.ClientKeyFile != "" {
+		if dest.ClientCertificateFile == "" || dest.ClientKeyFile == "" {
+			err = errors.Errorf("TLS destination %s must specify both a client certificate and key", strings.Join(dest.Hosts, ","))
+			return
+		}
+		var cert tls.Certificate
+		if cert, err = tls.LoadX509KeyPair(dest.ClientCertificateFile, dest.ClientKeyFile); err != nil {
+			err = errors.Wrapf(err, "failed to load client certificate for TLS destination %s", strings.Join(dest.Hosts, ","))
+			return
+		}
+		result.clientCert = &cert
+	}
+	return
+}
+
+// Build the TLS destination for the OIDC provider from the OIDC.TLS* parameters.
+//
+// The hosts are derived from the configured OIDC issuer and endpoints.
+func getOIDCTLSDestination() (dest TLSDestination, ok bool) {
+	dest.CACertificateFile = param.OIDC_TLSCACertificateFile.GetString()
+	dest.ClientCertificateFile = param.OIDC_TLSClientCertificateFile.GetString()
+	dest.ClientKeyFile = param.OIDC_TLSClientKeyFile.GetString()
+	if dest.CACertificateFile == "" && dest.ClientCertificateFile == "" && dest.ClientKeyFile == "" {
+		return
+	}
+	endpoints := []string{
+		param.OIDC_Issuer.GetString(),
+		param.OIDC_AuthorizationEndpoint.GetString(),
+		param.OIDC_DeviceAuthEndpoint.GetString(),
+		param.OIDC_TokenEndpoint.GetString(),
+		param.OIDC_UserInfoEndpoint.GetString(),
+	}
+	for _, endpoint := range endpoints {
+		if endpoint == "" {
+			continue
+		}
+		endpointUrl, err := url.Parse(endpoint)
+		if err != nil || endpointUrl.Hostname() == "" {
+			log.Warningf("Ignoring OIDC endpoint %s when configuring TLS for the OIDC provider", endpoint)
+			continue
+		}
+		if !matchesAnyHost(dest.Hosts, endpointUrl.Hostname()) {
+			dest.Hosts = append(dest.Hosts, endpointUrl.Hostname())
+		}
+	}
+	ok = len(dest.Hosts) > 0
+	if !ok {
+		log.Warningln("OIDC TLS settings are configured but no OIDC issuer or endpoint is known; they will be ignored")
+	}
+	return
+}
+
+func matchesAnyHost(hosts []string, host string) bool {
+	for _, existing := range hosts {
+		if strings.EqualFold(existing, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// Load all the per-destination TLS configurations, including the one for the OIDC provider
+func getTLSDestinations(basePool *x509.CertPool) (dests []tlsDestination, err error) {
+	configured := []TLSDestination{}
+	if param.Transport_TLSDestinations.IsSet() {
+		if err = param.Transport_TLSDestinations.Unmarshal(&configured); err != nil {
+			err = errors.Wrap(err, "failed to parse Transport.TLSDestinations")
+			return
+		}
+	}
+	// The OIDC destination goes first so it takes precedence over any wildcards
+	if oidcDest, ok := getOIDCTLSDestination(); ok {
+		configured = append([]TLSDestination{oidcDest}, configured...)
+	}
+	for _, dest := range configured {
+		var loaded tlsDestination
+		if loaded, err = loadTLSDestination(dest, basePool); err != nil {
+			return
+		}
+		dests = append(dests, loaded)
+	}
+	return
+}
+
+// Configure the transport to use the per-destination TLS settings.
+//
+// The TLS library verifies the server against the union of all the trusted CAs
+// (so hostname checks, including for IP addresses, are unchanged); a VerifyConnection
+// callback then rejects chains that are only trusted because of a CA belonging to a
+// different destination.  Both are preserved when the transport is cloned or a proxy
+// is used.  The TLS library does not tell the client certificate callback which
+// server it is talking to, so destinations with client certificates require a
+// custom TLS dialer.
+func applyTLSDestinations(transport *http.Transport, dests []tlsDestination, basePool *x509.CertPool, dialer *net.Dialer) {
+	if len(dests) == 0 {
+		return
+	}
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	baseConfig := transport.TLSClientConfig
+	if baseConfig.InsecureSkipVerify {
+		log.Warningln("TLSSkipVerify is set; per-destination CA bundles will not be used")
+	} else {
+		unionPool := basePool.Clone()
+		for _, dest := range dests {
+			for _, caCert := range dest.caCerts {
+				unionPool.AddCert(caCert)
+			}
+		}
+		baseConfig.RootCAs = unionPool
+		baseConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifyTLSDestination(dests, basePool, cs)
+		}
+	}
+
+	needsDialer := false
+	for _, dest := range dests {
+		if dest.clientCert != nil {
+			needsDialer = true
+			break
+		}
+	}
+	if !needsDialer {
+		return
+	}
+	forceHTTP2 := transport.ForceAttemptHTTP2
+	transport.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		tlsConfig := baseConfig.Clone()
+		tlsConfig.ServerName = host
+		if forceHTTP2 && len(tlsConfig.NextProtos) == 0 {
+			tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+		}
+		if dest := findTLSDestination(dests, host); dest != nil && dest.clientCert != nil {
+			tlsConfig.Certificates = []tls.Certificate{*dest.clientCert}
+		}
+		rawConn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		tlsConn := tls.Client(rawConn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			rawConn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+}
+
+// Ensure the server's certificate chains to a CA trusted for this particular destination.
+//
+// By the time this is invoked, the TLS library has already verified the chain (and hostname)
+// against the union of all trusted CAs; this only needs to check the chain's root.
+func verifyTLSDestination(dests []tlsDestination, basePool *x509.CertPool, cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("server did not present a TLS certificate")
+	}
+	roots := basePool
+	if dest := findTLSDestination(dests, cs.ServerName); dest != nil && dest.rootCAs != nil {
+		roots = dest.rootCAs
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	if _, err := cs.PeerCertificates[0].Verify(opts); err != nil {
+		return errors.Wrapf(err, "server certificate is not trusted for destination %q", cs.ServerName)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchTLSDestinationHost(t *testing.T) {
+	assert.True(t, matchTLSDestinationHost("origin.example.com", "origin.example.com"))
+	assert.True(t, matchTLSDestinationHost("Origin.Example.com", "origin.example.COM"))
+	assert.False(t, matchTLSDestinationHost("origin.example.com", "cache.example.com"))
+	assert.True(t, matchTLSDestinationHost("*.example.com", "origin.example.com"))
+	assert.True(t, matchTLSDestinationHost("*.example.com", "a.b.example.com"))
+	assert.False(t, matchTLSDestinationHost("*.example.com", "example.com"))
+	assert.False(t, matchTLSDestinationHost("*.example.com", "badexample.com"))
+}
+
+// Generate a self-signed certificate valid for localhost that can be used
+// both as a server and client certificate
+func generateTestCert(t *testing.T) tls.Certificate {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: priv}
+}
+
+// Write out the certificate and key to PEM files
+func writeTestCreds(t *testing.T, cert tls.Certificate) (certFile, keyFile string) {
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0600))
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	require.NoError(t, err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes})
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0600))
+	return
+}
+
+func TestTLSDestinations(t *testing.T) {
+	t.Cleanup(func() {
+		viper.Set("TLSSkipVerify", true)
+		viper.Set("Transport.TLSDestinations", nil)
+		setupTransport()
+	})
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			w.Header().Set("X-Client-Cert", "true")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	cert := generateTestCert(t)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequestClientCert, Certificates: []tls.Certificate{cert}}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	certFile, keyFile := writeTestCreds(t, cert)
+	srvUrl := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+
+	viper.Set("TLSSkipVerify", false)
+	viper.Set("Server.TLSCACertificateFile", filepath.Join(t.TempDir(), "missing.pem"))
+
+	doGet := func() (*http.Response, error) {
+		setupTransport()
+		client := &http.Client{Transport: GetTransport()}
+		resp, err := client.Get(srvUrl)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	t.Run("untrusted-without-destination", func(t *testing.T) {
+		viper.Set("Transport.TLSDestinations", nil)
+		_, err := doGet()
+		require.Error(t, err)
+	})
+
+	t.Run("untrusted-for-other-host", func(t *testing.T) {
+		viper.Set("Transport.TLSDestinations", []map[string]any{
+			{"Hosts": []string{"other.example.com"}, "CACertificateFile": certFile},
+		})
+		_, err := doGet()
+		require.Error(t, err)
+	})
+
+	t.Run("trusted-for-destination", func(t *testing.T) {
+		viper.Set("Transport.TLSDestinations", []map[string]any{
+			{"Hosts": []string{"localhost"}, "CACertificateFile": certFile},
+		})
+		resp, err := doGet()
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("X-Client-Cert"))
+	})
+
+	t.Run("client-cert-for-destination", func(t *testing.T) {
+		viper.Set("Transport.TLSDestinations", []map[string]any{
+			{
+				"Hosts":                 []string{"localhost"},
+				"CACertificateFile":     certFile,
+				"ClientCertificateFile": certFile,
+				"ClientKeyFile":         keyFile,
+			},
+		})
+		resp, err := doGet()
+		require.NoError(t, err)
+		assert.Equal(t, "true", resp.Header.Get("X-Client-Cert"))
+	})
+
+	t.Run("ip-address-not-matched", func(t *testing.T) {
+		// The TLS library does not report a server name for IP addresses; ensure
+		// that the destination CA is not trusted in that case.
+		viper.Set("Transport.TLSDestinations", []map[string]any{
+			{"Hosts": []string{"localhost"}, "CACertificateFile": certFile},
+		})
+		setupTransport()
+		client := &http.Client{Transport: GetTransport()}
+		_, err := client.Get(srv.URL)
+		require.Error(t, err)
+	})
+
+	t.Run("invalid-destination", func(t *testing.T) {
+		_, err := loadTLSDestination(TLSDestination{Hosts: []string{"localhost"}, ClientCertificateFile: certFile}, x509.NewCertPool())
+		require.Error(t, err)
+		_, err = loadTLSDestination(TLSDestination{Hosts: []string{"https://example.com"}}, x509.NewCertPool())
+		require.Error(t, err)
+	})
+}
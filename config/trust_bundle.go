@@ -0,0 +1,355 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package config
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/param"
+)
+
+type (
+	// The trust material of a federation, published by the director at TrustBundlePath
+	// as a JWT signed with the director's key.  A new bundle must be signed by one of the
+	// director keys of the previously trusted bundle, so keys are rotated by publishing
+	// the new key alongside the old one until every bundle issued with only the old key
+	// has expired.
+	TrustBundle struct {
+		DirectorKeys json.RawMessage     `json:"director_keys"`
+		RegistryKeys json.RawMessage     `json:"registry_keys,omitempty"`
+		Issuers      []TrustBundleIssuer `json:"issuers"`
+		ServerCAs    []string            `json:"server_cas,omitempty"` // PEM-encoded CAs approved for federation servers
+
+		// The validity of the signed bundle
+		NotBefore time.Time `json:"-"`
+		Expiry    time.Time `json:"-"`
+	}
+
+	// A token issuer of the federation and the namespace paths it's trusted for
+	TrustBundleIssuer struct {
+		Issuer    string   `json:"issuer"`
+		BasePaths []string `json:"base_paths"`
+	}
+)
+
+const (
+	TrustBundlePath  = "/.well-known/pelican-trust-bundle"
+	TrustBundleClaim = "pelican_trust_bundle"
+
+	trustBundleRefreshInterval = time.Hour
+)
+
+var (
+	trustBundleLock sync.Mutex
+	// The most recently verified bundle; it stays the anchor used to verify the next
+	// bundle even after it expires
+	trustBundle *TrustBundle
+)
+
+// Parse a signed trust bundle, verifying it with the given keys
+func ParseTrustBundle(signed []byte, keys jwk.Set) (*TrustBundle, error) {
+	tok, err := jwt.Parse(signed, jwt.WithKeySet(keys), jwt.WithValidate(true))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to verify the federation trust bundle")
+	}
+	claim, ok := tok.Get(TrustBundleClaim)
+	if !ok {
+		return nil, errors.Errorf("the federation trust bundle has no %s claim", TrustBundleClaim)
+	}
+	claimJson, err := json.Marshal(claim)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the federation trust bundle")
+	}
+	bundle := &TrustBundle{}
+	if err = json.Unmarshal(claimJson, bundle); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the federation trust bundle")
+	}
+	if _, err = bundle.GetDirectorKeys(); err != nil {
+		return nil, err
+	}
+	bundle.NotBefore = tok.NotBefore()
+	bundle.Expiry = tok.Expiration()
+	return bundle, nil
+}
+
+// Get the director's public keys listed in the bundle
+func (tb *TrustBundle) GetDirectorKeys() (jwk.Set, error) {
+	keys, err := jwk.Parse(tb.DirectorKeys)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse the director keys of the federation trust bundle")
+	}
+	if keys.Len() == 0 {
+		return nil, errors.New("the federation trust bundle lists no director keys")
+	}
+	return keys, nil
+}
+
+// Get the CAs the bundle approves for federation servers, skipping any that don't parse
+func (tb *TrustBundle) GetServerCAs() (certs []*x509.Certificate) {
+	for _, caPem := range tb.ServerCAs {
+		rest := []byte(caPem)
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+				certs = append(certs, cert)
+			} else {
+				log.Warningln("Ignoring an invalid CA of the federation trust bundle:", err)
+			}
+		}
+	}
+	return
+}
+
+// Whether the bundle is within its validity period
+func (tb *TrustBundle) isValid(now time.Time) bool {
+	return !now.Before(tb.NotBefore) && now.Before(tb.Expiry)
+}
+
+// Bundles are refreshed halfway through their validity, so a new bundle is in hand
+// well before the current one expires
+func (tb *TrustBundle) needsRefresh(now time.Time) bool {
+	return !now.Before(tb.NotBefore.Add(tb.Expiry.Sub(tb.NotBefore) / 2))
+}
+
+func fetchURL(ctx context.Context, client *http.Client, reqUrl string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "pelican/"+GetVersion())
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4*1024*1024))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("%s responded with status code %d", reqUrl, resp.StatusCode)
+	}
+	return body, nil
+}
+
+// Load the bundle cached at Federation.TrustBundleLocation, verified with its own keys;
+// it was verified against the previous anchor when it was fetched
+func loadCachedTrustBundle() (*TrustBundle, error) {
+	location := param.Federation_TrustBundleLocation.GetString()
+	if location == "" {
+		return nil, nil
+	}
+	signed, err := os.ReadFile(location)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to read the cached federation trust bundle")
+	}
+	// Parse once without validation to learn the keys it lists; an expired bundle is
+	// still a valid anchor for its successor
+	tok, err := jwt.Parse(signed, jwt.WithVerify(false), jwt.WithValidate(false))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse the cached federation trust bundle")
+	}
+	claim, _ := tok.Get(TrustBundleClaim)
+	claimJson, _ := json.Marshal(claim)
+	unverified := &TrustBundle{}
+	if err = json.Unmarshal(claimJson, unverified); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the cached federation trust bundle")
+	}
+	keys, err := unverified.GetDirectorKeys()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = jwt.Parse(signed, jwt.WithKeySet(keys), jwt.WithValidate(false)); err != nil {
+		return nil, errors.Wrap(err, "the cached federation trust bundle is not signed by its own keys")
+	}
+	unverified.NotBefore = tok.NotBefore()
+	unverified.Expiry = tok.Expiration()
+	return unverified, nil
+}
+
+func saveTrustBundle(signed []byte) error {
+	location := param.Federation_TrustBundleLocation.GetString()
+	if location == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(location), 0755); err != nil {
+		return errors.Wrap(err, "failed to create the directory of the federation trust bundle")
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(location), filepath.Base(location))
+	if err != nil {
+		return errors.Wrap(err, "failed to cache the federation trust bundle")
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err = tmpFile.Write(signed); err != nil {
+		tmpFile.Close()
+		return errors.Wrap(err, "failed to cache the federation trust bundle")
+	}
+	if err = tmpFile.Close(); err != nil {
+		return errors.Wrap(err, "failed to cache the federation trust bundle")
+	}
+	if err = os.Chmod(tmpFile.Name(), 0644); err != nil {
+		return errors.Wrap(err, "failed to cache the federation trust bundle")
+	}
+	return errors.Wrap(os.Rename(tmpFile.Name(), location), "failed to cache the federation trust bundle")
+}
+
+// Fetch the federation's trust bundle from the director.  The bundle is verified with
+// the director keys of `anchor`, the previously trusted bundle; without one, the trust
+// is bootstrapped from the director keys published at the federation's jwks_uri.
+func fetchTrustBundle(ctx context.Context, anchor *TrustBundle) (*TrustBundle, []byte, error) {
+	fedInfo, err := GetFederation(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if fedInfo.DirectorEndpoint == "" {
+		return nil, nil, errors.New("the federation has no director to fetch the trust bundle from")
+	}
+	bundleUrl, err := url.JoinPath(fedInfo.DirectorEndpoint, TrustBundlePath)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to generate the URL of the federation trust bundle")
+	}
+	client := &http.Client{Transport: GetTransport()}
+
+	var keys jwk.Set
+	if anchor != nil {
+		if keys, err = anchor.GetDirectorKeys(); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		if fedInfo.JwksUri == "" {
+			return nil, nil, errors.New("the federation does not publish the director's public keys")
+		}
+		if keys, err = jwk.Fetch(ctx, fedInfo.JwksUri, jwk.WithHTTPClient(client)); err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to fetch the director's public keys from %s", fedInfo.JwksUri)
+		}
+	}
+
+	signed, err := fetchURL(ctx, client, bundleUrl)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to fetch the federation trust bundle")
+	}
+	bundle, err := ParseTrustBundle(signed, keys)
+	if err != nil {
+		if anchor != nil {
+			return nil, nil, errors.Wrapf(err, "the federation trust bundle is not signed by a director key of the trusted bundle; "+
+				"if the director's keys were replaced without overlap, remove %s to trust the new keys", param.Federation_TrustBundleLocation.GetString())
+		}
+		return nil, nil, err
+	}
+	return bundle, signed, nil
+}
+
+// Get the federation's trust bundle, fetching a new one from the director when the
+// trusted bundle is halfway through its validity.  If the director can't be reached,
+// the trusted bundle is returned for as long as it's valid.
+func GetTrustBundle(ctx context.Context) (*TrustBundle, error) {
+	trustBundleLock.Lock()
+	defer trustBundleLock.Unlock()
+
+	if trustBundle == nil {
+		cached, err := loadCachedTrustBundle()
+		if err != nil {
+			log.Warningln("Ignoring the cached federation trust bundle:", err)
+		}
+		trustBundle = cached
+	}
+	now := time.Now()
+	if trustBundle != nil && !trustBundle.needsRefresh(now) {
+		return trustBundle, nil
+	}
+
+	bundle, signed, err := fetchTrustBundle(ctx, trustBundle)
+	if err != nil {
+		if trustBundle != nil && trustBundle.isValid(now) {
+			log.Warningln("Failed to refresh the federation trust bundle; using the trusted bundle until it expires:", err)
+			return trustBundle, nil
+		}
+		return nil, err
+	}
+	if err = saveTrustBundle(signed); err != nil {
+		log.Warningln(err)
+	}
+	log.Debugf("Fetched a federation trust bundle valid until %s", bundle.Expiry.Format(time.RFC3339))
+	trustBundle = bundle
+	return trustBundle, nil
+}
+
+// Get the trusted federation trust bundle without contacting the director, or nil if
+// there's no valid bundle in memory
+func GetCachedTrustBundle() *TrustBundle {
+	trustBundleLock.Lock()
+	defer trustBundleLock.Unlock()
+	if trustBundle == nil || !trustBundle.isValid(time.Now()) {
+		return nil
+	}
+	return trustBundle
+}
+
+// Reset the in-memory trust bundle; only meant for unit tests
+func ResetTrustBundleForTest() {
+	trustBundleLock.Lock()
+	defer trustBundleLock.Unlock()
+	trustBundle = nil
+}
+
+// Fetch the federation's trust bundle and keep it fresh in the background
+func LaunchTrustBundleRefresh(ctx context.Context, egrp *errgroup.Group) {
+	if _, err := GetTrustBundle(ctx); err != nil {
+		log.Warningln("Failed to fetch the federation trust bundle:", err)
+	}
+	egrp.Go(func() error {
+		ticker := time.NewTicker(trustBundleRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := GetTrustBundle(ctx); err != nil {
+					log.Warningln("Failed to refresh the federation trust bundle:", err)
+				}
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	})
+}
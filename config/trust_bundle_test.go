@@ -0,0 +1,217 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package config
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTrustBundleKey(t *testing.T) jwk.Key {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	key, err := jwk.FromRaw(ecKey)
+	require.NoError(t, err)
+	require.NoError(t, jwk.AssignKeyID(key))
+	require.NoError(t, key.Set(jwk.AlgorithmKey, jwa.ES256))
+	return key
+}
+
+// Sign a bundle listing `listed` as the director keys with `signer`
+func signTrustBundle(t *testing.T, signer jwk.Key, listed []jwk.Key, notBefore time.Time, lifetime time.Duration) []byte {
+	keys := jwk.NewSet()
+	for _, key := range listed {
+		pub, err := key.PublicKey()
+		require.NoError(t, err)
+		require.NoError(t, keys.AddKey(pub))
+	}
+	keysJson, err := json.Marshal(keys)
+	require.NoError(t, err)
+	tok, err := jwt.NewBuilder().
+		NotBefore(notBefore).
+		Expiration(notBefore.Add(lifetime)).
+		Claim(TrustBundleClaim, TrustBundle{
+			DirectorKeys: keysJson,
+			Issuers:      []TrustBundleIssuer{{Issuer: "https://origin.example.com", BasePaths: []string{"/foo"}}},
+		}).
+		Build()
+	require.NoError(t, err)
+	signed, err := jwt.Sign(tok, jwt.WithKey(jwa.ES256, signer))
+	require.NoError(t, err)
+	return signed
+}
+
+type fakeTrustBundleDirector struct {
+	mutex  sync.Mutex
+	jwks   jwk.Set
+	bundle []byte
+}
+
+func (d *fakeTrustBundleDirector) set(jwksKey jwk.Key, bundle []byte) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.jwks = jwk.NewSet()
+	pub, _ := jwksKey.PublicKey()
+	_ = d.jwks.AddKey(pub)
+	d.bundle = bundle
+}
+
+func (d *fakeTrustBundleDirector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	switch r.URL.Path {
+	case "/.well-known/issuer.jwks":
+		_ = json.NewEncoder(w).Encode(d.jwks)
+	case TrustBundlePath:
+		if d.bundle == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(d.bundle)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestTrustBundle(t *testing.T) {
+	director := &fakeTrustBundleDirector{}
+	server := httptest.NewServer(director)
+	t.Cleanup(server.Close)
+
+	setup := func(t *testing.T) string {
+		viper.Reset()
+		ResetFederationForTest()
+		ResetTrustBundleForTest()
+		t.Cleanup(func() {
+			viper.Reset()
+			ResetFederationForTest()
+			ResetTrustBundleForTest()
+		})
+		location := filepath.Join(t.TempDir(), "federation-trust-bundle.jwt")
+		viper.Set("Federation.TrustBundleLocation", location)
+		SetFederation(FederationDiscovery{
+			DirectorEndpoint: server.URL,
+			JwksUri:          server.URL + "/.well-known/issuer.jwks",
+		})
+		return location
+	}
+	ctx := context.Background()
+	now := time.Now().Add(-time.Minute)
+
+	t.Run("bootstrap-and-rotate", func(t *testing.T) {
+		setup(t)
+		oldKey := newTrustBundleKey(t)
+		newKey := newTrustBundleKey(t)
+
+		// The first bundle is trusted based on the keys at jwks_uri
+		director.set(oldKey, signTrustBundle(t, oldKey, []jwk.Key{oldKey, newKey}, now, time.Hour))
+		bundle, err := GetTrustBundle(ctx)
+		require.NoError(t, err)
+		keys, err := bundle.GetDirectorKeys()
+		require.NoError(t, err)
+		assert.Equal(t, 2, keys.Len())
+		assert.Equal(t, []TrustBundleIssuer{{Issuer: "https://origin.example.com", BasePaths: []string{"/foo"}}}, bundle.Issuers)
+		assert.Equal(t, bundle, GetCachedTrustBundle())
+
+		// Halfway through its validity, the bundle is replaced by one signed with the
+		// new key, which the trusted bundle lists
+		trustBundle.NotBefore = time.Now().Add(-time.Hour)
+		director.set(newKey, signTrustBundle(t, newKey, []jwk.Key{newKey}, now, time.Hour))
+		bundle, err = GetTrustBundle(ctx)
+		require.NoError(t, err)
+		keys, err = bundle.GetDirectorKeys()
+		require.NoError(t, err)
+		assert.Equal(t, 1, keys.Len())
+		_, found := keys.LookupKeyID(newKey.KeyID())
+		assert.True(t, found)
+	})
+
+	t.Run("unknown-signer-rejected", func(t *testing.T) {
+		setup(t)
+		trustedKey := newTrustBundleKey(t)
+		director.set(trustedKey, signTrustBundle(t, trustedKey, []jwk.Key{trustedKey}, now, time.Hour))
+		_, err := GetTrustBundle(ctx)
+		require.NoError(t, err)
+
+		// Even though jwks_uri now publishes the rogue key, the bundle must be signed
+		// by a key the trusted bundle lists
+		rogueKey := newTrustBundleKey(t)
+		director.set(rogueKey, signTrustBundle(t, rogueKey, []jwk.Key{rogueKey}, now, time.Hour))
+		ResetTrustBundleForTest()
+		trusted, err := loadCachedTrustBundle()
+		require.NoError(t, err)
+		trusted.NotBefore = time.Now().Add(-time.Hour)
+		_, _, err = fetchTrustBundle(ctx, trusted)
+		assert.Error(t, err)
+
+		// The trusted bundle is still used while it's valid
+		trustBundle = trusted
+		bundle, err := GetTrustBundle(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, trusted, bundle)
+	})
+
+	t.Run("cached-on-disk", func(t *testing.T) {
+		location := setup(t)
+		key := newTrustBundleKey(t)
+		director.set(key, signTrustBundle(t, key, []jwk.Key{key}, now, time.Hour))
+		fetched, err := GetTrustBundle(ctx)
+		require.NoError(t, err)
+		assert.FileExists(t, location)
+
+		// A restarted process uses the cached bundle without contacting the director
+		ResetTrustBundleForTest()
+		director.set(key, nil)
+		bundle, err := GetTrustBundle(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, fetched.Issuers, bundle.Issuers)
+		assert.WithinDuration(t, fetched.Expiry, bundle.Expiry, time.Second)
+	})
+
+	t.Run("expired-bundle-not-used", func(t *testing.T) {
+		setup(t)
+		key := newTrustBundleKey(t)
+		director.set(key, signTrustBundle(t, key, []jwk.Key{key}, now, time.Hour))
+		_, err := GetTrustBundle(ctx)
+		require.NoError(t, err)
+
+		director.set(key, nil)
+		trustBundle.NotBefore = time.Now().Add(-2 * time.Hour)
+		trustBundle.Expiry = time.Now().Add(-time.Hour)
+		assert.Nil(t, GetCachedTrustBundle())
+		_, err = GetTrustBundle(ctx)
+		assert.Error(t, err)
+	})
+}
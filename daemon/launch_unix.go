@@ -63,6 +63,8 @@ func checkPIDExists(pid int) bool {
 
 func ForwardCommandToLogger(ctx context.Context, daemonName string, cmdStdout io.ReadCloser, cmdStderr io.ReadCloser) {
 	cmd_logger := log.WithFields(log.Fields{"daemon": daemonName})
+	// Only XRootD reports the auth failures of the origin/cache data transfers
+	handleAuthFailures := strings.HasPrefix(daemonName, "xrootd")
 	stdout_scanner := bufio.NewScanner(cmdStdout)
 	stdout_lines := make(chan string, 10)
 
@@ -85,12 +87,18 @@ func ForwardCommandToLogger(ctx context.Context, daemonName string, cmdStdout io
 		case stdout_line, ok := <-stdout_lines:
 			if ok {
 				cmd_logger.Info(stdout_line)
+				if handleAuthFailures {
+					metrics.HandleXrootdLogLine(daemonName, stdout_line)
+				}
 			} else {
 				stdout_lines = nil
 			}
 		case stderr_line, ok := <-stderr_lines:
 			if ok {
 				cmd_logger.Info(stderr_line)
+				if handleAuthFailures {
+					metrics.HandleXrootdLogLine(daemonName, stderr_line)
+				}
 			} else {
 				stderr_lines = nil
 			}
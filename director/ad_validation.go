@@ -0,0 +1,344 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+// How long the director waits for a server being validated to answer at each of its URLs
+const adProbeTimeout = 5 * time.Second
+
+var (
+	// Per-client rate limiters for the validation endpoints
+	adValidationLimiters = ttlcache.New(
+		ttlcache.WithTTL[string, *rate.Limiter](10 * time.Minute),
+	)
+
+	// The transport the probes of a server's URLs are based on; replaced in tests
+	adProbeTransport = func() *http.Transport { return config.GetTransport().Clone() }
+
+	errAdProbeForbidden = errors.New("the address is not public")
+)
+
+// The checks of an advertisement being validated, in the order they were made
+type adValidation struct {
+	checks []server_structs.AdCheck
+}
+
+func (v *adValidation) add(name, target string, status server_structs.AdCheckStatus, format string, args ...any) {
+	v.checks = append(v.checks, server_structs.AdCheck{
+		Name:    name,
+		Target:  target,
+		Status:  status,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *adValidation) report() server_structs.AdValidationReport {
+	report := server_structs.AdValidationReport{Valid: true, Checks: v.checks}
+	for _, check := range v.checks {
+		if check.Status == server_structs.AdCheckFailed {
+			report.Valid = false
+		}
+	}
+	return report
+}
+
+// Check an advertisement the way registerServeAd would, without registering the server,
+// and report the result of every check instead of stopping at the first failure.  This
+// lets the admins of a new origin or cache find all the problems with their setup before
+// joining the federation.
+func validateServeAd(engineCtx context.Context, ctx *gin.Context, sType server_structs.ServerType) {
+	token := strings.TrimPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+	if token == "" {
+		ctx.JSON(http.StatusUnauthorized, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Bearer token not present in the 'Authorization' header",
+		})
+		return
+	}
+	adV2, err := bindServerAd(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("Invalid %s advertisement: %v", sType, err),
+		})
+		return
+	}
+
+	// Only servers holding a registered key may have the director probe their URLs
+	v := &adValidation{}
+	if !checkAdTrust(engineCtx, v, sType, adV2, token) {
+		ctx.JSON(http.StatusForbidden, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("The token isn't signed by a key registered for the %s or its namespaces", strings.ToLower(string(sType))),
+		})
+		return
+	}
+	checkAdVersions(ctx, v, adV2)
+	checkAdUrls(ctx.Request.Context(), v, sType, adV2)
+	checkAdCapabilities(v, sType, adV2)
+
+	ctx.JSON(http.StatusOK, v.report())
+}
+
+// Middleware limiting the rate of validation requests per client IP to
+// Director.AdValidationRateLimit per minute
+func adValidationRateLimitHandler(ctx *gin.Context) {
+	limit := param.Director_AdValidationRateLimit.GetInt()
+	if limit <= 0 {
+		ctx.Next()
+		return
+	}
+	item, _ := adValidationLimiters.GetOrSet(ctx.ClientIP(), rate.NewLimiter(rate.Every(time.Minute/time.Duration(limit)), limit))
+	if !item.Value().Allow() {
+		ctx.AbortWithStatusJSON(http.StatusTooManyRequests, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Too many validation requests; try again later",
+		})
+		return
+	}
+	ctx.Next()
+}
+
+// Check the server's version against the director's requirements
+func checkAdVersions(ctx *gin.Context, v *adValidation, ad server_structs.OriginAdvertiseV2) {
+	if err := versionCompatCheck(ctx); err != nil {
+		v.add("versions", "", server_structs.AdCheckFailed, "Incompatible versions detected: %v", err)
+		return
+	}
+	if ad.Versions == nil {
+		v.add("versions", "", server_structs.AdCheckWarning, "The advertisement doesn't report the server's protocol versions")
+		return
+	}
+	if err := server_structs.GetProtocolVersions().CheckCompatible(*ad.Versions); err != nil {
+		v.add("versions", "", server_structs.AdCheckFailed, "Incompatible versions detected: %v", err)
+		return
+	}
+	v.add("versions", "", server_structs.AdCheckOK, "")
+}
+
+// Check that the server's URLs are valid and that the director can reach them
+func checkAdUrls(ctx context.Context, v *adValidation, sType server_structs.ServerType, ad server_structs.OriginAdvertiseV2) {
+	parse := func(name, urlStr string, required bool) *url.URL {
+		if urlStr == "" {
+			if required {
+				v.add(name, "", server_structs.AdCheckFailed, "The URL is missing")
+			}
+			return nil
+		}
+		parsed, err := url.Parse(urlStr)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			v.add(name, urlStr, server_structs.AdCheckFailed, "%q is not a valid URL", urlStr)
+			return nil
+		}
+		if parsed.Scheme != "https" {
+			v.add(name, urlStr, server_structs.AdCheckFailed, "The URL must use https")
+			return nil
+		}
+		return parsed
+	}
+	dataUrl := parse("data-url", ad.DataURL, true)
+	webUrl := parse("web-url", ad.WebURL, false)
+	if ad.WebURL == "" {
+		v.add("web-url", "", server_structs.AdCheckWarning, "The advertisement has no web URL, so the director can't monitor the %s", strings.ToLower(string(sType)))
+	}
+	if ad.BrokerURL != "" {
+		parse("broker-url", ad.BrokerURL, false)
+	}
+
+	for _, target := range []struct {
+		name string
+		url  *url.URL
+	}{{"data-url", dataUrl}, {"web-url", webUrl}} {
+		if target.url == nil {
+			continue
+		}
+		// The error isn't returned, lest the endpoint reveal what's on the director's network
+		if err := probeAdUrl(ctx, target.url); err != nil {
+			log.Debugf("Failed to probe %s while validating an advertisement: %v", target.url, err)
+			v.add(target.name, target.url.String(), server_structs.AdCheckFailed, "The director can't reach the URL")
+		} else {
+			v.add(target.name, target.url.String(), server_structs.AdCheckOK, "")
+		}
+	}
+}
+
+// Whether the director may probe an address, refusing the non-public addresses unless
+// Director.AdValidationAllowPrivateAddresses is set
+func adProbeAllowed(addr netip.Addr) bool {
+	if param.Director_AdValidationAllowPrivateAddresses.GetBool() {
+		return true
+	}
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate()
+}
+
+// Send a HEAD request to the URL; any response means the server is reachable.  The
+// addresses are checked as they're dialed, after name resolution, and redirects
+// aren't followed, so the request can't be steered to a forbidden address.
+func probeAdUrl(ctx context.Context, target *url.URL) error {
+	ctx, cancel := context.WithTimeout(ctx, adProbeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, target.String(), nil)
+	if err != nil {
+		return err
+	}
+	transport := adProbeTransport()
+	transport.Proxy = nil
+	dialer := &net.Dialer{
+		Control: func(network, address string, conn syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !adProbeAllowed(addrPort.Addr()) {
+				return errAdProbeForbidden
+			}
+			return nil
+		},
+	}
+	transport.DialContext = dialer.DialContext
+	transport.DialTLSContext = nil
+	client := http.Client{
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Check that the server and, for origins, each of its namespaces are registered and
+// approved, and that the token in the request is signed by their registered keys.
+// Returns whether the token is signed by the key of at least one of them.
+func checkAdTrust(engineCtx context.Context, v *adValidation, sType server_structs.ServerType, ad server_structs.OriginAdvertiseV2, token string) (trusted bool) {
+	prefixes := []string{}
+	if registryPrefix, ok := serverRegistryPrefix(sType, ad); ok {
+		prefixes = append(prefixes, registryPrefix)
+	} else {
+		v.add("registration", "", server_structs.AdCheckWarning,
+			"The advertisement has no registry prefix, so the origin itself can't be verified; origins >= 7.9.0 register themselves")
+	}
+	if sType == server_structs.OriginType {
+		for _, namespace := range ad.Namespaces {
+			prefixes = append(prefixes, namespace.Path)
+		}
+	}
+
+	fedInfo, err := config.GetFederation(engineCtx)
+	if err != nil {
+		v.add("registration", "", server_structs.AdCheckFailed, "The director can't look up the federation's registry: %v", err)
+		return
+	}
+	for _, prefix := range prefixes {
+		approved, err := checkNamespaceStatus(prefix, fedInfo.NamespaceRegistrationEndpoint)
+		if err != nil {
+			v.add("registration", prefix, server_structs.AdCheckFailed, "Failed to check the registration: %v", err)
+			continue
+		}
+		if !approved {
+			v.add("registration", prefix, server_structs.AdCheckFailed, "%s was not approved by an administrator", prefix)
+			continue
+		}
+		v.add("registration", prefix, server_structs.AdCheckOK, "")
+
+		ok, err := verifyAdvertiseToken(engineCtx, token, prefix)
+		if err != nil {
+			v.add("key-trust", prefix, server_structs.AdCheckFailed, "The token isn't signed by the key registered for %s: %v", prefix, err)
+		} else if !ok {
+			v.add("key-trust", prefix, server_structs.AdCheckFailed, "The token is missing the required scope")
+		} else {
+			v.add("key-trust", prefix, server_structs.AdCheckOK, "")
+			trusted = true
+		}
+	}
+	return
+}
+
+// Check that the server's capabilities make sense together
+func checkAdCapabilities(v *adValidation, sType server_structs.ServerType, ad server_structs.OriginAdvertiseV2) {
+	before := len(v.checks)
+	caps := ad.Caps
+	if sType == server_structs.CacheType {
+		if caps.DirectReads {
+			v.add("capabilities", "", server_structs.AdCheckWarning, "Caches don't serve direct reads; the capability is ignored")
+		}
+		if caps.Writes && ad.WriteBack == nil {
+			v.add("capabilities", "", server_structs.AdCheckWarning, "The cache accepts writes but doesn't advertise a write-back policy")
+		}
+	} else {
+		if len(ad.Namespaces) == 0 {
+			v.add("capabilities", "", server_structs.AdCheckFailed, "The origin doesn't export any namespace")
+		}
+		if ad.ResumableUploads && !caps.Writes {
+			v.add("capabilities", "", server_structs.AdCheckWarning, "The origin accepts resumable uploads but not writes")
+		}
+		if caps.Listings && !caps.Reads && !caps.PublicReads {
+			v.add("capabilities", "", server_structs.AdCheckWarning, "The origin allows listings but no reads")
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, namespace := range ad.Namespaces {
+		if namespace.Path == "" || !strings.HasPrefix(namespace.Path, "/") || path.Clean(namespace.Path) != namespace.Path {
+			v.add("capabilities", namespace.Path, server_structs.AdCheckFailed, "The namespace path must be a clean absolute path")
+			continue
+		}
+		if seen[namespace.Path] {
+			v.add("capabilities", namespace.Path, server_structs.AdCheckFailed, "The namespace is advertised more than once")
+			continue
+		}
+		seen[namespace.Path] = true
+		if sType != server_structs.OriginType {
+			continue
+		}
+		if namespace.Caps.Writes && !caps.Writes {
+			v.add("capabilities", namespace.Path, server_structs.AdCheckWarning, "The namespace allows writes but the origin doesn't")
+		}
+		if !namespace.Caps.PublicReads && !namespace.PublicRead && len(namespace.Issuer) == 0 {
+			v.add("capabilities", namespace.Path, server_structs.AdCheckWarning, "The namespace isn't public and has no token issuer, so clients can't read it")
+		}
+	}
+	if len(v.checks) == before {
+		v.add("capabilities", "", server_structs.AdCheckOK, "")
+	}
+}
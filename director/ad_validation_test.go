@@ -0,0 +1,233 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/token_scopes"
+)
+
+func TestValidateServeAd(t *testing.T) {
+	viper.Reset()
+	config.ResetFederationForTest()
+	t.Cleanup(func() {
+		viper.Reset()
+		config.ResetFederationForTest()
+		namespaceKeys.DeleteAll()
+		serverAds.DeleteAll()
+	})
+
+	// Mock registry approving all the prefixes but /bar
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost || req.URL.Path != "/api/v1.0/registry/checkNamespaceStatus" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		reqJson := server_structs.CheckNamespaceStatusReq{}
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&reqJson))
+		require.NoError(t, json.NewEncoder(w).Encode(server_structs.CheckNamespaceStatusRes{Approved: reqJson.Prefix != "/bar"}))
+	}))
+	defer registry.Close()
+	viper.Set("Federation.RegistryUrl", registry.URL)
+
+	// The server being validated, on the loopback address the director refuses to probe by default
+	var probes atomic.Int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) { probes.Add(1) }))
+	defer server.Close()
+	unreachable := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	unreachable.Close()
+	viper.Set("Director.AdValidationAllowPrivateAddresses", true)
+	oldTransport := adProbeTransport
+	adProbeTransport = func() *http.Transport { return server.Client().Transport.(*http.Transport).Clone() }
+	t.Cleanup(func() { adProbeTransport = oldTransport })
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	key, err := jwk.FromRaw(privateKey)
+	require.NoError(t, err)
+	require.NoError(t, jwk.AssignKeyID(key))
+	require.NoError(t, key.Set(jwk.AlgorithmKey, jwa.ES256))
+	publicKey, err := jwk.PublicKeyOf(key)
+	require.NoError(t, err)
+	for _, prefix := range []string{"/caches/test", "/origins/test", "/foo"} {
+		jwks := jwk.NewSet()
+		require.NoError(t, jwks.AddKey(publicKey))
+		namespaceKeys.Set(registry.URL+"/api/v1.0/registry"+prefix+"/.well-known/issuer.jwks", jwks, ttlcache.DefaultTTL)
+	}
+	tok, err := jwt.NewBuilder().Issuer(registry.URL).Claim("scope", token_scopes.Pelican_Advertise.String()).Subject("test").Build()
+	require.NoError(t, err)
+	signedBytes, err := jwt.Sign(tok, jwt.WithKey(jwa.ES256, key))
+	require.NoError(t, err)
+	signed := string(signedBytes)
+
+	post := func(t *testing.T, sType server_structs.ServerType, ad server_structs.OriginAdvertiseV2, token string) *httptest.ResponseRecorder {
+		body, err := json.Marshal(ad)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		_, r := gin.CreateTestContext(w)
+		r.POST("/", adValidationRateLimitHandler, func(gctx *gin.Context) { validateServeAd(context.Background(), gctx, sType) })
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "pelican-origin/7.0.0")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+	validate := func(t *testing.T, sType server_structs.ServerType, ad server_structs.OriginAdvertiseV2, token string) server_structs.AdValidationReport {
+		w := post(t, sType, ad, token)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		report := server_structs.AdValidationReport{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		return report
+	}
+	statuses := func(report server_structs.AdValidationReport) map[string]server_structs.AdCheckStatus {
+		result := map[string]server_structs.AdCheckStatus{}
+		for _, check := range report.Checks {
+			name := check.Name
+			if check.Target != "" {
+				name += ":" + check.Target
+			}
+			// Keep the worst status of repeated checks
+			if result[name] != server_structs.AdCheckFailed {
+				result[name] = check.Status
+			}
+		}
+		return result
+	}
+
+	t.Run("valid-cache", func(t *testing.T) {
+		versions := server_structs.GetProtocolVersions()
+		report := validate(t, server_structs.CacheType, server_structs.OriginAdvertiseV2{
+			Name:           "test",
+			RegistryPrefix: "/caches/test",
+			DataURL:        server.URL,
+			WebURL:         server.URL,
+			Namespaces:     []server_structs.NamespaceAdV2{{Path: "/foo"}},
+			Versions:       &versions,
+		}, signed)
+		assert.True(t, report.Valid, report.Checks)
+		assert.Equal(t, map[string]server_structs.AdCheckStatus{
+			"versions":                  server_structs.AdCheckOK,
+			"data-url:" + server.URL:    server_structs.AdCheckOK,
+			"web-url:" + server.URL:     server_structs.AdCheckOK,
+			"registration:/caches/test": server_structs.AdCheckOK,
+			"key-trust:/caches/test":    server_structs.AdCheckOK,
+			"capabilities":              server_structs.AdCheckOK,
+		}, statuses(report))
+		assert.Zero(t, serverAds.Len())
+	})
+
+	t.Run("origin-with-problems", func(t *testing.T) {
+		report := validate(t, server_structs.OriginType, server_structs.OriginAdvertiseV2{
+			Name:           "test",
+			RegistryPrefix: "/origins/test",
+			DataURL:        unreachable.URL,
+			Caps:           server_structs.Capabilities{Reads: true},
+			Namespaces: []server_structs.NamespaceAdV2{
+				{Path: "/foo", Caps: server_structs.Capabilities{PublicReads: true}},
+				{Path: "/bar", Caps: server_structs.Capabilities{Writes: true}},
+			},
+		}, signed)
+		assert.False(t, report.Valid)
+		result := statuses(report)
+		assert.Equal(t, server_structs.AdCheckWarning, result["versions"])
+		assert.Equal(t, server_structs.AdCheckFailed, result["data-url:"+unreachable.URL])
+		assert.Equal(t, server_structs.AdCheckWarning, result["web-url"])
+		assert.Equal(t, server_structs.AdCheckOK, result["key-trust:/origins/test"])
+		assert.Equal(t, server_structs.AdCheckOK, result["key-trust:/foo"])
+		assert.Equal(t, server_structs.AdCheckFailed, result["registration:/bar"])
+		assert.NotContains(t, result, "key-trust:/bar")
+		assert.Equal(t, server_structs.AdCheckWarning, result["capabilities:/bar"])
+		assert.NotContains(t, result, "capabilities:/foo")
+		assert.Zero(t, serverAds.Len())
+	})
+
+	t.Run("rejects-untrusted-tokens-before-probing", func(t *testing.T) {
+		ad := server_structs.OriginAdvertiseV2{
+			Name:           "test",
+			RegistryPrefix: "/caches/test",
+			DataURL:        server.URL,
+			WebURL:         server.URL,
+		}
+		before := probes.Load()
+		w := post(t, server_structs.CacheType, ad, "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		forged, err := jwt.Sign(tok, jwt.WithKey(jwa.ES256, otherKey))
+		require.NoError(t, err)
+		w = post(t, server_structs.CacheType, ad, string(forged))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, before, probes.Load())
+	})
+
+	t.Run("restricts-probed-urls", func(t *testing.T) {
+		viper.Set("Director.AdValidationAllowPrivateAddresses", false)
+		t.Cleanup(func() { viper.Set("Director.AdValidationAllowPrivateAddresses", true) })
+		before := probes.Load()
+		report := validate(t, server_structs.CacheType, server_structs.OriginAdvertiseV2{
+			Name:           "test",
+			RegistryPrefix: "/caches/test",
+			DataURL:        server.URL,
+			WebURL:         "http://localhost:8080",
+		}, signed)
+		assert.False(t, report.Valid)
+		for _, check := range report.Checks {
+			if check.Name == "data-url" {
+				assert.Equal(t, server_structs.AdCheckFailed, check.Status)
+				assert.Equal(t, "The director can't reach the URL", check.Message)
+			}
+		}
+		assert.Equal(t, server_structs.AdCheckFailed, statuses(report)["web-url:http://localhost:8080"])
+		assert.Equal(t, before, probes.Load())
+	})
+
+	t.Run("rate-limits-clients", func(t *testing.T) {
+		viper.Set("Director.AdValidationRateLimit", 1)
+		adValidationLimiters.DeleteAll()
+		t.Cleanup(func() { adValidationLimiters.DeleteAll() })
+		ad := server_structs.OriginAdvertiseV2{Name: "test", RegistryPrefix: "/caches/test", DataURL: server.URL}
+		assert.Equal(t, http.StatusOK, post(t, server_structs.CacheType, ad, signed).Code)
+		assert.Equal(t, http.StatusTooManyRequests, post(t, server_structs.CacheType, ad, signed).Code)
+	})
+}
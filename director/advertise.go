@@ -24,7 +24,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/pelicanplatform/pelican/param"
@@ -144,15 +143,10 @@ func updateDowntimeFromTopology(excludedNss, includedNss *utils.TopologyNamespac
 
 // Populate internal cache with origin/cache ads
 func AdvertiseOSDF(ctx context.Context) error {
-	namespaces, err := utils.GetTopologyJSON(ctx, false)
+	// The namespaces of all the topology sources, once without and once with the servers in downtime
+	namespaces, includedNss, err := getTopologyFromSources(ctx)
 	if err != nil {
-		return errors.Wrapf(err, "Failed to get topology JSON")
-	}
-
-	// Second call to fetch all servers (including servers in downtime)
-	includedNss, err := utils.GetTopologyJSON(ctx, true)
-	if err != nil {
-		return errors.Wrapf(err, "Failed to get topology JSON with server in downtime included (include_downed)")
+		return err
 	}
 
 	updateDowntimeFromTopology(namespaces, includedNss)
@@ -244,13 +238,18 @@ func AdvertiseOSDF(ctx context.Context) error {
 		}
 	}
 
+	topoAds := make([]server_structs.ServerAd, 0, len(originAdMap)+len(cacheAdMap))
 	for originAd, namespacesSlice := range originAdMap {
 		recordAd(ctx, originAd, &namespacesSlice)
+		topoAds = append(topoAds, originAd)
 	}
 
 	for cacheAd, namespacesSlice := range cacheAdMap {
 		recordAd(ctx, cacheAd, &namespacesSlice)
+		topoAds = append(topoAds, cacheAd)
 	}
+	// Sites may publish several endpoints, e.g. an IPv4 address and a hostname, for a server
+	setServerEndpoints(topoAds)
 
 	return nil
 }
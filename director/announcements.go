@@ -0,0 +1,141 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+// An announcement of Director.Announcements
+type announcementConfig struct {
+	ID       string `mapstructure:"ID"`
+	Message  string `mapstructure:"Message"`
+	Severity string `mapstructure:"Severity"`
+	Start    string `mapstructure:"Start"` // RFC 3339
+	End      string `mapstructure:"End"`
+}
+
+// The longest message of an announcement; announcements are meant to be short notices
+const maxAnnouncementLength = 1024
+
+var (
+	announcementsOnce sync.Once
+	announcements     []server_structs.Announcement
+)
+
+// Check an announcement of Director.Announcements.  Announcements without an ID are
+// identified by their message, so clients show them again if the message changes.
+func parseAnnouncement(config announcementConfig) (server_structs.Announcement, error) {
+	announcement := server_structs.Announcement{
+		ID:       config.ID,
+		Message:  config.Message,
+		Severity: server_structs.AnnouncementSeverity(config.Severity),
+	}
+	if announcement.Message == "" {
+		return announcement, errors.New("the announcement has no message")
+	}
+	if len(announcement.Message) > maxAnnouncementLength {
+		return announcement, errors.Errorf("the message of the announcement is longer than %d characters", maxAnnouncementLength)
+	}
+	switch announcement.Severity {
+	case "":
+		announcement.Severity = server_structs.AnnouncementInfo
+	case server_structs.AnnouncementInfo, server_structs.AnnouncementWarning, server_structs.AnnouncementCritical:
+	default:
+		return announcement, errors.Errorf("invalid severity %q; must be %q, %q or %q", config.Severity,
+			server_structs.AnnouncementInfo, server_structs.AnnouncementWarning, server_structs.AnnouncementCritical)
+	}
+	for _, bound := range []struct {
+		value string
+		dest  **time.Time
+	}{{config.Start, &announcement.Start}, {config.End, &announcement.End}} {
+		if bound.value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, bound.value)
+		if err != nil {
+			return announcement, errors.Wrapf(err, "invalid time %q", bound.value)
+		}
+		*bound.dest = &parsed
+	}
+	if announcement.Start != nil && announcement.End != nil && !announcement.End.After(*announcement.Start) {
+		return announcement, errors.New("the announcement ends before it starts")
+	}
+	if announcement.ID == "" {
+		sum := sha256.Sum256([]byte(announcement.Message))
+		announcement.ID = hex.EncodeToString(sum[:8])
+	}
+	return announcement, nil
+}
+
+// Get the announcements of Director.Announcements, which are read once
+func getAnnouncements() []server_structs.Announcement {
+	announcementsOnce.Do(func() {
+		configs := []announcementConfig{}
+		if err := param.Director_Announcements.Unmarshal(&configs); err != nil {
+			log.Errorln("Failed to parse Director.Announcements; no announcements are published:", err)
+			return
+		}
+		for idx, config := range configs {
+			announcement, err := parseAnnouncement(config)
+			if err != nil {
+				log.Errorf("Ignoring announcement %d of Director.Announcements: %v", idx+1, err)
+				continue
+			}
+			announcements = append(announcements, announcement)
+		}
+	})
+	return announcements
+}
+
+// Forget the loaded announcements so they're reloaded from the configuration; for tests
+func resetAnnouncements() {
+	announcementsOnce = sync.Once{}
+	announcements = nil
+}
+
+// Get the announcements in effect at the given time
+func getActiveAnnouncements(now time.Time) []server_structs.Announcement {
+	active := []server_structs.Announcement{}
+	for _, announcement := range getAnnouncements() {
+		if announcement.Start != nil && now.Before(*announcement.Start) {
+			continue
+		}
+		if announcement.End != nil && !now.Before(*announcement.End) {
+			continue
+		}
+		active = append(active, announcement)
+	}
+	return active
+}
+
+func listAnnouncements(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, getActiveAnnouncements(time.Now()))
+}
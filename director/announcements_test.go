@@ -0,0 +1,97 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+func TestParseAnnouncement(t *testing.T) {
+	announcement, err := parseAnnouncement(announcementConfig{Message: "Maintenance on Monday"})
+	require.NoError(t, err)
+	assert.Equal(t, server_structs.AnnouncementInfo, announcement.Severity)
+	assert.NotEmpty(t, announcement.ID)
+	assert.Nil(t, announcement.Start)
+
+	other, err := parseAnnouncement(announcementConfig{Message: "Maintenance on Tuesday"})
+	require.NoError(t, err)
+	assert.NotEqual(t, announcement.ID, other.ID)
+
+	announcement, err = parseAnnouncement(announcementConfig{
+		ID: "outage", Message: "Outage", Severity: "critical", Start: "2024-03-01T00:00:00Z", End: "2024-03-02T00:00:00Z",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "outage", announcement.ID)
+	require.NotNil(t, announcement.End)
+	assert.Equal(t, time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), *announcement.End)
+
+	for name, config := range map[string]announcementConfig{
+		"no-message":        {Severity: "info"},
+		"invalid-severity":  {Message: "Outage", Severity: "urgent"},
+		"invalid-start":     {Message: "Outage", Start: "tomorrow"},
+		"ends-before-start": {Message: "Outage", Start: "2024-03-02T00:00:00Z", End: "2024-03-01T00:00:00Z"},
+	} {
+		_, err := parseAnnouncement(config)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestListAnnouncements(t *testing.T) {
+	viper.Reset()
+	resetAnnouncements()
+	t.Cleanup(func() {
+		viper.Reset()
+		resetAnnouncements()
+	})
+	now := time.Now()
+	viper.Set("Director.Announcements", []map[string]any{
+		{"ID": "current", "Message": "Maintenance today", "Severity": "warning"},
+		{"ID": "past", "Message": "Maintenance yesterday", "End": now.Add(-time.Hour).Format(time.RFC3339)},
+		{"ID": "future", "Message": "Maintenance next week", "Start": now.Add(time.Hour).Format(time.RFC3339)},
+		{"ID": "invalid", "Message": "Maintenance", "Severity": "urgent"},
+	})
+
+	router := gin.New()
+	router.GET("/announcements", listAnnouncements)
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodGet, "/announcements", nil)
+	require.NoError(t, err)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	announcements := []server_structs.Announcement{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &announcements))
+	require.Len(t, announcements, 1)
+	assert.Equal(t, "current", announcements[0].ID)
+	assert.Equal(t, server_structs.AnnouncementWarning, announcements[0].Severity)
+
+	assert.Len(t, getActiveAnnouncements(now.Add(2*time.Hour)), 2)
+	assert.Len(t, getActiveAnnouncements(now.Add(-2*time.Hour)), 2)
+}
@@ -0,0 +1,130 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/jellydator/ttlcache/v3"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+const (
+	// The number of the caches closest to the client that are asked whether they have an object
+	availabilityProbeCaches = 3
+)
+
+var (
+	// The availability estimates of objects, by availabilityKey
+	objectAvailability = ttlcache.New[string, server_structs.ObjectAvailability](
+		ttlcache.WithDisableTouchOnHit[string, server_structs.ObjectAvailability](),
+	)
+
+	// The keys of the objects being probed, so concurrent redirects don't probe the same object
+	availabilityProbes sync.Map
+
+	// Replaced in tests
+	availabilityStat = newAvailabilityStat()
+)
+
+// Create the stat instance used for availability probes, whose HEAD requests to caches
+// don't make the caches fetch the object
+func newAvailabilityStat() *ObjectStat {
+	stat := NewObjectStat()
+	stat.ReqHandler = stat.sendCachedHeadReq
+	return stat
+}
+
+// An object is "cached nearby" relative to a set of caches, so the estimate is per object and caches
+func availabilityKey(reqPath string, cacheAds []server_structs.ServerAd) string {
+	key := reqPath
+	for _, ad := range cacheAds {
+		key += "|" + ad.URL.String()
+	}
+	return key
+}
+
+// Select the caches (not origins serving direct reads) closest to the client to probe
+func getAvailabilityProbeCaches(sortedCacheAds []server_structs.ServerAd) []server_structs.ServerAd {
+	caches := make([]server_structs.ServerAd, 0, availabilityProbeCaches)
+	for _, ad := range sortedCacheAds {
+		if ad.Type != server_structs.CacheType {
+			continue
+		}
+		caches = append(caches, ad)
+		if len(caches) == availabilityProbeCaches {
+			break
+		}
+	}
+	return caches
+}
+
+// Ask the nearby caches, and then the origins, whether they have the object
+func probeObjectAvailability(ctx context.Context, reqPath string, cacheAds, originAds []server_structs.ServerAd) server_structs.ObjectAvailability {
+	if len(cacheAds) > 0 {
+		qr := availabilityStat.Query(ctx, reqPath, config.CacheType, 1, 1, withCacheAds(cacheAds))
+		if qr.Status == querySuccessful {
+			return server_structs.AvailabilityCached
+		}
+		log.Debugf("None of the caches near the client have %s: %s", reqPath, qr.Msg)
+	}
+	if len(originAds) > 0 {
+		qr := availabilityStat.Query(ctx, reqPath, config.OriginType, 1, 1, withOriginAds(originAds))
+		if qr.Status == querySuccessful {
+			return server_structs.AvailabilityOrigin
+		}
+		log.Debugf("Unable to find %s at its origins: %s", reqPath, qr.Msg)
+	}
+	return server_structs.AvailabilityUnknown
+}
+
+// Get the estimated availability of an object for a redirect.  If there's no estimate yet, this
+// starts probing the servers in the background and reports the object as unknown, so
+// redirects are never delayed by the probes.
+func getObjectAvailability(reqPath string, namespaceAd server_structs.NamespaceAdV2, sortedCacheAds, originAds []server_structs.ServerAd) server_structs.ObjectAvailability {
+	// Only the availability of public objects is probed: the director has no token of its
+	// own for the others and it mustn't reveal the result of a probe with one client's
+	// token to other clients
+	if !param.Director_EstimateObjectAvailability.GetBool() || !namespaceAd.Caps.PublicReads || strings.HasSuffix(reqPath, "/") {
+		return server_structs.AvailabilityUnknown
+	}
+	cacheAds := getAvailabilityProbeCaches(sortedCacheAds)
+	key := availabilityKey(reqPath, cacheAds)
+	if item := objectAvailability.Get(key); item != nil {
+		return item.Value()
+	}
+	if _, probing := availabilityProbes.LoadOrStore(key, struct{}{}); probing {
+		return server_structs.AvailabilityUnknown
+	}
+	go func() {
+		defer availabilityProbes.Delete(key)
+		// The cache and origin queries each wait up to Director.StatTimeout for the servers
+		ctx, cancel := context.WithTimeout(context.Background(), 3*param.Director_StatTimeout.GetDuration())
+		defer cancel()
+		availability := probeObjectAvailability(ctx, reqPath, cacheAds, originAds)
+		objectAvailability.Set(key, availability, param.Director_ObjectAvailabilityTTL.GetDuration())
+	}()
+	return server_structs.AvailabilityUnknown
+}
@@ -0,0 +1,127 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+func TestGetObjectAvailability(t *testing.T) {
+	viper.Reset()
+	viper.Set("Director.EstimateObjectAvailability", true)
+	viper.Set("Director.ObjectAvailabilityTTL", time.Minute)
+	viper.Set("Director.StatTimeout", time.Second)
+
+	// The cache only has /foo/cached.txt and must not be asked to fetch anything;
+	// the origin has every object but /foo/missing.txt
+	cacheServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Cache-Control") != "only-if-cached" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.URL.Path != "/foo/cached.txt" {
+			w.WriteHeader(http.StatusGatewayTimeout)
+			return
+		}
+		w.Header().Set("Content-Length", "5")
+	}))
+	t.Cleanup(cacheServer.Close)
+	originServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/foo/missing.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", "5")
+	}))
+	t.Cleanup(originServer.Close)
+
+	cacheUrl, err := url.Parse(cacheServer.URL)
+	require.NoError(t, err)
+	originUrl, err := url.Parse(originServer.URL)
+	require.NoError(t, err)
+	cacheAd := server_structs.ServerAd{Name: "cache", URL: *cacheUrl, Type: server_structs.CacheType}
+	originAd := server_structs.ServerAd{Name: "origin", URL: *originUrl, Type: server_structs.OriginType}
+	// An origin serving direct reads can be in the list of caches but must not be probed as one
+	directOriginAd := server_structs.ServerAd{Name: "direct", URL: url.URL{Scheme: "http", Host: "direct.example.com"}, Type: server_structs.OriginType}
+
+	statUtilsMutex.Lock()
+	statUtils[cacheAd.URL.String()] = newServerStatUtil(context.Background())
+	statUtils[originAd.URL.String()] = newServerStatUtil(context.Background())
+	statUtilsMutex.Unlock()
+	t.Cleanup(func() {
+		statUtilsMutex.Lock()
+		delete(statUtils, cacheAd.URL.String())
+		delete(statUtils, originAd.URL.String())
+		statUtilsMutex.Unlock()
+		objectAvailability.DeleteAll()
+		viper.Reset()
+	})
+
+	publicNs := server_structs.NamespaceAdV2{Path: "/foo", Caps: server_structs.Capabilities{PublicReads: true}}
+	cacheAds := []server_structs.ServerAd{directOriginAd, cacheAd}
+	originAds := []server_structs.ServerAd{originAd}
+
+	eventually := func(t *testing.T, reqPath string, expected server_structs.ObjectAvailability) {
+		// The first redirect starts the probe without waiting for it
+		assert.Equal(t, server_structs.AvailabilityUnknown, getObjectAvailability(reqPath, publicNs, cacheAds, originAds))
+		require.Eventually(t, func() bool {
+			return getObjectAvailability(reqPath, publicNs, cacheAds, originAds) == expected
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+
+	t.Run("cached-nearby", func(t *testing.T) {
+		eventually(t, "/foo/cached.txt", server_structs.AvailabilityCached)
+	})
+
+	t.Run("origin-only", func(t *testing.T) {
+		eventually(t, "/foo/uncached.txt", server_structs.AvailabilityOrigin)
+	})
+
+	t.Run("not-found", func(t *testing.T) {
+		assert.Equal(t, server_structs.AvailabilityUnknown, getObjectAvailability("/foo/missing.txt", publicNs, cacheAds, originAds))
+		require.Eventually(t, func() bool {
+			return objectAvailability.Has(availabilityKey("/foo/missing.txt", []server_structs.ServerAd{cacheAd}))
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(t, server_structs.AvailabilityUnknown, getObjectAvailability("/foo/missing.txt", publicNs, cacheAds, originAds))
+	})
+
+	t.Run("private-namespaces-are-not-probed", func(t *testing.T) {
+		privateNs := server_structs.NamespaceAdV2{Path: "/foo"}
+		assert.Equal(t, server_structs.AvailabilityUnknown, getObjectAvailability("/foo/private.txt", privateNs, cacheAds, originAds))
+		_, probing := availabilityProbes.Load(availabilityKey("/foo/private.txt", []server_structs.ServerAd{cacheAd}))
+		assert.False(t, probing)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		viper.Set("Director.EstimateObjectAvailability", false)
+		defer viper.Set("Director.EstimateObjectAvailability", true)
+		assert.Equal(t, server_structs.AvailabilityUnknown, getObjectAvailability("/foo/cached.txt", publicNs, cacheAds, originAds))
+	})
+}
@@ -0,0 +1,140 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"path"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+// The servers for the objects of one namespace, sorted for the client once per batch
+type resolvedNamespace struct {
+	namespaceAd server_structs.NamespaceAdV2
+	originAds   []server_structs.ServerAd
+	cacheAds    []server_structs.ServerAd
+	err         string
+}
+
+// Sort the servers of a namespace for the client as redirectToCache does, including
+// falling back to an origin serving direct reads when there are no caches
+func resolveNamespace(ipAddr netip.Addr, rules *redirectRuleEvaluator, namespaceAd server_structs.NamespaceAdV2, originAds, cacheAds []server_structs.ServerAd) resolvedNamespace {
+	originAds = rules.exclude(namespaceAd, originAds)
+	cacheAds = rules.exclude(namespaceAd, cacheAds)
+	resolved := resolvedNamespace{namespaceAd: namespaceAd, originAds: originAds}
+	if len(cacheAds) == 0 {
+		for _, originAd := range originAds {
+			if originAd.DirectReads {
+				resolved.cacheAds = []server_structs.ServerAd{originAd}
+				return resolved
+			}
+		}
+		resolved.err = "No cache found for path"
+		return resolved
+	}
+	sorted, err := sortServerAdsByIP(ipAddr, cacheAds)
+	if err != nil {
+		log.Errorln("Error determining server ordering for cacheAds:", err)
+		resolved.err = "Failed to determine server ordering"
+		return resolved
+	}
+	resolved.cacheAds = rules.prefer(namespaceAd, sorted)
+	return resolved
+}
+
+// Resolve many objects in one request: for each object, return the servers a redirect
+// would offer the client, in order, and the estimated availability of the object.
+// This spares workflow systems planning many jobs one director request per object.
+func batchResolveObjects(ginCtx *gin.Context) {
+	req := server_structs.BatchResolveRequest{}
+	if err := ginCtx.ShouldBindJSON(&req); err != nil {
+		ginCtx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Invalid request body: " + err.Error(),
+		})
+		return
+	}
+	if maxPaths := param.Director_MaxBatchResolvePaths.GetInt(); len(req.Paths) > maxPaths {
+		ginCtx.JSON(http.StatusRequestEntityTooLarge, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("Too many paths in the request: %d requested, at most %d are allowed", len(req.Paths), maxPaths),
+		})
+		return
+	}
+	ipAddr, err := getRealIP(ginCtx)
+	if err != nil {
+		log.Errorln("Error in getRealIP:", err)
+		ginCtx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Internal error: Unable to determine client IP",
+		})
+		return
+	}
+	clientCapabilities := getClientCapabilities(ginCtx.Request)
+	rules := newRedirectRuleEvaluator(ginCtx.Request, ipAddr)
+
+	namespaces := map[string]resolvedNamespace{}
+	resp := server_structs.BatchResolveResponse{Objects: make([]server_structs.ResolvedObject, 0, len(req.Paths))}
+	for _, rawPath := range req.Paths {
+		reqPath := path.Clean("/" + rawPath)
+		obj := server_structs.ResolvedObject{Path: reqPath, Servers: []string{}, Availability: server_structs.AvailabilityUnknown}
+
+		namespaceAd, originAds, cacheAds := getAdsForPath(reqPath)
+		if namespaceAd.Path == "" {
+			obj.Error = "No namespace found for path"
+			resp.Objects = append(resp.Objects, obj)
+			continue
+		}
+		obj.Namespace = namespaceAd.Path
+		obj.RequireToken = !namespaceAd.Caps.PublicReads
+		obj.TapeBacked = namespaceAd.Caps.TapeBacked
+
+		resolved, ok := namespaces[namespaceAd.Path]
+		if !ok {
+			originAds = filterAdsByClientCapabilities(originAds, clientCapabilities)
+			cacheAds = filterAdsByClientCapabilities(cacheAds, clientCapabilities)
+			resolved = resolveNamespace(ipAddr, rules, namespaceAd, originAds, cacheAds)
+			namespaces[namespaceAd.Path] = resolved
+		}
+		if resolved.err != "" {
+			obj.Error = resolved.err
+			resp.Objects = append(resp.Objects, obj)
+			continue
+		}
+
+		serverCount := len(resolved.cacheAds)
+		if serverCount > cachesToSend {
+			serverCount = cachesToSend
+		}
+		for _, ad := range resolved.cacheAds[:serverCount] {
+			serverUrl := getRedirectURL(reqPath, ad, obj.RequireToken)
+			obj.Servers = append(obj.Servers, serverUrl.String())
+		}
+		obj.Availability = getObjectAvailability(reqPath, resolved.namespaceAd, resolved.cacheAds, resolved.originAds)
+		resp.Objects = append(resp.Objects, obj)
+	}
+	ginCtx.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,108 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+func TestBatchResolveObjects(t *testing.T) {
+	viper.Reset()
+	serverAds.DeleteAll()
+	t.Cleanup(func() {
+		viper.Reset()
+		serverAds.DeleteAll()
+	})
+	viper.Set("Director.MaxBatchResolvePaths", 4)
+	viper.Set("Director.CacheSortMethod", "random")
+
+	fooNs := server_structs.NamespaceAdV2{Path: "/foo", Caps: server_structs.Capabilities{PublicReads: true}}
+	directNs := server_structs.NamespaceAdV2{Path: "/direct", Caps: server_structs.Capabilities{DirectReads: true}}
+	fooOrigin := server_structs.ServerAd{Name: "foo-origin", Type: server_structs.OriginType, URL: url.URL{Scheme: "https", Host: "foo-origin.example.org:8443"}}
+	fooCache := server_structs.ServerAd{Name: "foo-cache", Type: server_structs.CacheType, URL: url.URL{Scheme: "https", Host: "foo-cache.example.org:8443"}}
+	directOrigin := server_structs.ServerAd{Name: "direct-origin", Type: server_structs.OriginType, DirectReads: true,
+		URL: url.URL{Scheme: "https", Host: "direct-origin.example.org:8443"}, AuthURL: url.URL{Scheme: "https", Host: "direct-origin.example.org:8444"}}
+	serverAds.Set(fooOrigin.URL.String(), &server_structs.Advertisement{ServerAd: fooOrigin, NamespaceAds: []server_structs.NamespaceAdV2{fooNs}}, 0)
+	serverAds.Set(fooCache.URL.String(), &server_structs.Advertisement{ServerAd: fooCache, NamespaceAds: []server_structs.NamespaceAdV2{fooNs}}, 0)
+	serverAds.Set(directOrigin.URL.String(), &server_structs.Advertisement{ServerAd: directOrigin, NamespaceAds: []server_structs.NamespaceAdV2{directNs}}, 0)
+
+	router := gin.New()
+	router.POST("/api/v1.0/director/resolve", batchResolveObjects)
+	resolve := func(t *testing.T, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1.0/director/resolve", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "pelican-v7.999.999")
+		req.Header.Set("X-Real-Ip", "128.104.153.60")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("resolves-each-path-in-order", func(t *testing.T) {
+		reqBody, err := json.Marshal(server_structs.BatchResolveRequest{Paths: []string{"/foo/a.txt", "/missing/b.txt", "direct/c.txt", "/foo/d.txt"}})
+		require.NoError(t, err)
+		w := resolve(t, string(reqBody))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		resp := server_structs.BatchResolveResponse{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		require.Len(t, resp.Objects, 4)
+
+		assert.Equal(t, server_structs.ResolvedObject{
+			Path: "/foo/a.txt", Namespace: "/foo", Servers: []string{"https://foo-cache.example.org:8443/foo/a.txt"},
+			Availability: server_structs.AvailabilityUnknown,
+		}, resp.Objects[0])
+
+		assert.Equal(t, "/missing/b.txt", resp.Objects[1].Path)
+		assert.Equal(t, "No namespace found for path", resp.Objects[1].Error)
+		assert.Empty(t, resp.Objects[1].Servers)
+
+		// Without caches, the origin serving direct reads is offered, at its auth URL for protected objects
+		assert.Equal(t, "/direct/c.txt", resp.Objects[2].Path)
+		assert.True(t, resp.Objects[2].RequireToken)
+		assert.Equal(t, []string{"https://direct-origin.example.org:8444/direct/c.txt"}, resp.Objects[2].Servers)
+
+		assert.Equal(t, []string{"https://foo-cache.example.org:8443/foo/d.txt"}, resp.Objects[3].Servers)
+	})
+
+	t.Run("too-many-paths", func(t *testing.T) {
+		reqBody, err := json.Marshal(server_structs.BatchResolveRequest{Paths: []string{"/foo/1", "/foo/2", "/foo/3", "/foo/4", "/foo/5"}})
+		require.NoError(t, err)
+		w := resolve(t, string(reqBody))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+
+	t.Run("invalid-body", func(t *testing.T) {
+		w := resolve(t, string(bytes.Repeat([]byte("{"), 3)))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -33,6 +33,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/pelicanplatform/pelican/metrics"
 	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/server_structs"
 )
@@ -53,6 +54,10 @@ var (
 	// The map should be idenpendent of serverAds as we want to persist this change in-memory, regardless of the presence of the serverAd
 	filteredServers      = map[string]filterType{}
 	filteredServersMutex = sync.RWMutex{}
+	// When each server last advertised, with the key being ServerAd.URL.String().
+	// Reading an ad extends its TTL, so its expiration doesn't tell when it was received.
+	adRefreshTimes      = map[string]time.Time{}
+	adRefreshTimesMutex = sync.Mutex{}
 )
 
 func (f filterType) String() string {
@@ -65,6 +70,8 @@ func (f filterType) String() string {
 		return "Disabled via the Topology policy"
 	case tempAllowed:
 		return "Temporarily enabled via the admin website"
+	case scheduledFiltered:
+		return "Disabled by a scheduled downtime"
 	case "": // Here is to simplify the empty value at the UI side
 		return ""
 	default:
@@ -107,6 +114,7 @@ func recordAd(ctx context.Context, ad server_structs.ServerAd, namespaceAds *[]s
 		existing = serverAds.Get(rawURL)
 	}
 
+	hadAd := existing != nil
 	// There's an existing ad in the cache
 	if existing != nil {
 		if ad.FromTopology && !existing.Value().FromTopology {
@@ -127,26 +135,22 @@ func recordAd(ctx context.Context, ad server_structs.ServerAd, namespaceAds *[]s
 	} else {
 		serverAds.Set(ad.URL.String(), &server_structs.Advertisement{ServerAd: ad, NamespaceAds: *namespaceAds}, customTTL)
 	}
+	adRefreshTimesMutex.Lock()
+	adRefreshTimes[ad.URL.String()] = time.Now()
+	adRefreshTimesMutex.Unlock()
+
+	// Test a server returning from a downtime or restart right away instead of keeping it on
+	// probation until its next scheduled health test
+	if !ad.FromTopology && checkReadmission(ad, hadAd, time.Now()) {
+		triggerHealthTest(ad.URL.String())
+	}
 
 	// Prepare `stat` call utilities for all servers regardless of its source (topology or Pelican)
 	statUtilsMutex.Lock()
 	defer statUtilsMutex.Unlock()
 	statUtil, ok := statUtils[ad.URL.String()]
-	if !ok || statUtil.Errgroup == nil {
-		baseCtx, cancel := context.WithCancel(ctx)
-		concLimit := param.Director_StatConcurrencyLimit.GetInt()
-		// If the value is not set, set to -1 to remove the limit
-		if concLimit == 0 {
-			concLimit = -1
-		}
-		statErrGrp := errgroup.Group{}
-		statErrGrp.SetLimit(concLimit)
-		newUtil := serverStatUtil{
-			Errgroup: &statErrGrp,
-			Cancel:   cancel,
-			Context:  baseCtx,
-		}
-		statUtils[ad.URL.String()] = newUtil
+	if !ok || statUtil.Queue == nil {
+		statUtils[ad.URL.String()] = newServerStatUtil(ctx)
 	}
 
 	// Prepare and launch the director file transfer tests to the origins/caches if it's not from the topology AND it's not already been registered
@@ -218,6 +222,35 @@ func recordAd(ctx context.Context, ad server_structs.ServerAd, namespaceAds *[]s
 	return ad
 }
 
+// Get the age of the latest advertisement of each server in the cache, forgetting
+// the servers whose ads expired
+func getAdAges() []metrics.DirectorAdAge {
+	now := time.Now()
+	items := serverAds.Items()
+	adRefreshTimesMutex.Lock()
+	defer adRefreshTimesMutex.Unlock()
+	ages := make([]metrics.DirectorAdAge, 0, len(items))
+	for key := range adRefreshTimes {
+		if _, ok := items[key]; !ok {
+			delete(adRefreshTimes, key)
+		}
+	}
+	for key, item := range items {
+		refreshed, ok := adRefreshTimes[key]
+		if !ok {
+			continue
+		}
+		ad := item.Value().ServerAd
+		ages = append(ages, metrics.DirectorAdAge{
+			ServerName: ad.Name,
+			ServerType: string(ad.Type),
+			ServerURL:  key,
+			Age:        now.Sub(refreshed),
+		})
+	}
+	return ages
+}
+
 func updateLatLong(ad *server_structs.ServerAd) error {
 	if ad == nil {
 		return errors.New("Cannot provide a nil ad to UpdateLatLong")
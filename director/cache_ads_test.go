@@ -25,11 +25,15 @@ import (
 	"time"
 
 	"github.com/jellydator/ttlcache/v3"
-	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/server_structs"
 )
 
 func hasServerAdWithName(serverAds []server_structs.ServerAd, name string) bool {
@@ -315,6 +319,64 @@ func TestLaunchTTLCache(t *testing.T) {
 			require.False(t, true)
 		}
 	})
+
+	t.Run("slow-cleanup-does-not-block-eviction", func(t *testing.T) {
+		shutdownCtx, shutdownCancel := context.WithCancel(context.Background())
+		egrp, ctx := errgroup.WithContext(shutdownCtx)
+		LaunchTTLCache(ctx, egrp)
+		release := make(chan struct{})
+		defer func() {
+			close(release)
+			shutdownCancel()
+			assert.NoError(t, egrp.Wait())
+		}()
+
+		// A health test that doesn't exit when it's cancelled
+		errgrp, errgrpCtx := errgroup.WithContext(shutdownCtx)
+		errgrp.Go(func() error {
+			<-release
+			return nil
+		})
+		serverUrl := mockPelicanOriginServerAd.URL.String()
+		serverAds.DeleteAll()
+		serverAds.Set(serverUrl, &server_structs.Advertisement{
+			ServerAd:     mockPelicanOriginServerAd,
+			NamespaceAds: []server_structs.NamespaceAdV2{mockNamespaceAd},
+		}, ttlcache.DefaultTTL)
+		func() {
+			healthTestUtilsMutex.Lock()
+			defer healthTestUtilsMutex.Unlock()
+			healthTestUtils = make(map[string]*healthTestUtil)
+			healthTestUtils[serverUrl] = &healthTestUtil{
+				Cancel:        func() {},
+				ErrGrp:        errgrp,
+				ErrGrpContext: errgrpCtx,
+			}
+		}()
+		func() {
+			statUtilsMutex.Lock()
+			defer statUtilsMutex.Unlock()
+			statUtils[serverUrl] = newServerStatUtil(ctx)
+		}()
+
+		callbacksBefore := evictionCallbackCount(t, "serverAds")
+		serverAds.Delete(serverUrl)
+
+		// The callback finishes while the health test is still running
+		require.Eventually(t, func() bool {
+			return evictionCallbackCount(t, "serverAds") > callbacksBefore
+		}, 3*time.Second, 10*time.Millisecond)
+		statUtilsMutex.RLock()
+		_, exists := statUtils[serverUrl]
+		statUtilsMutex.RUnlock()
+		assert.False(t, exists)
+	})
+}
+
+func evictionCallbackCount(t *testing.T, cacheName string) uint64 {
+	metric := &dto.Metric{}
+	require.NoError(t, metrics.PelicanDirectorTTLCacheEvictionDuration.WithLabelValues(cacheName).(prometheus.Metric).Write(metric))
+	return metric.GetHistogram().GetSampleCount()
 }
 
 func TestServerAdsCacheEviction(t *testing.T) {
@@ -464,3 +526,42 @@ func TestRecordAd(t *testing.T) {
 		assert.True(t, ok)
 	})
 }
+
+func TestGetAdAges(t *testing.T) {
+	serverAds.DeleteAll()
+	t.Cleanup(func() {
+		serverAds.DeleteAll()
+		adRefreshTimesMutex.Lock()
+		adRefreshTimes = map[string]time.Time{}
+		adRefreshTimesMutex.Unlock()
+	})
+
+	ad := server_structs.ServerAd{Name: "TOPO_CACHE", Type: server_structs.CacheType, URL: url.URL{Scheme: "http", Host: "cache.example.org:8000"}, FromTopology: true}
+	recordAd(context.Background(), ad, &[]server_structs.NamespaceAdV2{})
+
+	// Pretend the ad arrived a minute ago; reading it must not make it look fresher
+	adRefreshTimesMutex.Lock()
+	adRefreshTimes[ad.URL.String()] = time.Now().Add(-time.Minute)
+	adRefreshTimesMutex.Unlock()
+	require.NotNil(t, serverAds.Get(ad.URL.String()))
+
+	ages := getAdAges()
+	require.Len(t, ages, 1)
+	assert.Equal(t, "TOPO_CACHE", ages[0].ServerName)
+	assert.Equal(t, string(server_structs.CacheType), ages[0].ServerType)
+	assert.Equal(t, ad.URL.String(), ages[0].ServerURL)
+	assert.GreaterOrEqual(t, ages[0].Age, time.Minute)
+
+	// A new advertisement resets the age
+	recordAd(context.Background(), ad, &[]server_structs.NamespaceAdV2{})
+	ages = getAdAges()
+	require.Len(t, ages, 1)
+	assert.Less(t, ages[0].Age, time.Minute)
+
+	// The servers whose ads are gone are no longer reported
+	serverAds.Delete(ad.URL.String())
+	assert.Empty(t, getAdAges())
+	adRefreshTimesMutex.Lock()
+	assert.Empty(t, adRefreshTimes)
+	adRefreshTimesMutex.Unlock()
+}
@@ -0,0 +1,87 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// The objects of a namespace, as counted by its largest copy among the origins
+	catalogNamespace struct {
+		Path      string    `json:"path"`
+		Objects   int64     `json:"objects"`
+		Bytes     int64     `json:"bytes"`
+		Origins   []string  `json:"origins"`   // The names of the origins reporting counts for the namespace
+		UpdatedAt time.Time `json:"updatedAt"` // When the largest copy was counted
+	}
+
+	catalogRes struct {
+		Objects    int64              `json:"objects"`
+		Bytes      int64              `json:"bytes"`
+		Namespaces []catalogNamespace `json:"namespaces"`
+	}
+)
+
+// Sum the object counts the origins advertise for their namespaces.  A namespace exported
+// by several origins, such as a mirror group, is counted once, by its largest copy.
+func getFederationCatalog(ads []server_structs.Advertisement) catalogRes {
+	byPath := make(map[string]*catalogNamespace)
+	for _, ad := range ads {
+		if ad.Type != server_structs.OriginType {
+			continue
+		}
+		for _, nsAd := range ad.NamespaceAds {
+			if nsAd.Catalog == nil {
+				continue
+			}
+			ns, ok := byPath[nsAd.Path]
+			if !ok {
+				ns = &catalogNamespace{Path: nsAd.Path}
+				byPath[nsAd.Path] = ns
+			}
+			ns.Origins = append(ns.Origins, ad.Name)
+			if len(ns.Origins) == 1 || nsAd.Catalog.Bytes > ns.Bytes || (nsAd.Catalog.Bytes == ns.Bytes && nsAd.Catalog.Objects > ns.Objects) {
+				ns.Objects = nsAd.Catalog.Objects
+				ns.Bytes = nsAd.Catalog.Bytes
+				ns.UpdatedAt = nsAd.Catalog.UpdatedAt
+			}
+		}
+	}
+
+	res := catalogRes{Namespaces: make([]catalogNamespace, 0, len(byPath))}
+	for _, ns := range byPath {
+		sort.Strings(ns.Origins)
+		res.Objects += ns.Objects
+		res.Bytes += ns.Bytes
+		res.Namespaces = append(res.Namespaces, *ns)
+	}
+	sort.Slice(res.Namespaces, func(i, j int) bool { return res.Namespaces[i].Path < res.Namespaces[j].Path })
+	return res
+}
+
+func handleGetCatalog(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, getFederationCatalog(listAdvertisement([]server_structs.ServerType{server_structs.OriginType})))
+}
@@ -0,0 +1,68 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+func TestGetFederationCatalog(t *testing.T) {
+	counted := time.Date(2024, 11, 5, 12, 0, 0, 0, time.UTC)
+	originAd := func(name string, namespaces ...server_structs.NamespaceAdV2) server_structs.Advertisement {
+		return server_structs.Advertisement{
+			ServerAd:     server_structs.ServerAd{Name: name, Type: server_structs.OriginType},
+			NamespaceAds: namespaces,
+		}
+	}
+	catalog := func(objects, bytes int64, updatedAt time.Time) *server_structs.CatalogStats {
+		return &server_structs.CatalogStats{Objects: objects, Bytes: bytes, UpdatedAt: updatedAt}
+	}
+
+	t.Run("mirrors-counted-once", func(t *testing.T) {
+		res := getFederationCatalog([]server_structs.Advertisement{
+			originAd("primary", server_structs.NamespaceAdV2{Path: "/mirrored", Catalog: catalog(10, 1000, counted)}),
+			originAd("secondary", server_structs.NamespaceAdV2{Path: "/mirrored", Catalog: catalog(9, 900, counted.Add(time.Hour))}),
+			originAd("standalone",
+				server_structs.NamespaceAdV2{Path: "/alone", Catalog: catalog(5, 50, counted)},
+				server_structs.NamespaceAdV2{Path: "/uncounted"},
+			),
+			{ServerAd: server_structs.ServerAd{Name: "cache", Type: server_structs.CacheType},
+				NamespaceAds: []server_structs.NamespaceAdV2{{Path: "/cached", Catalog: catalog(1, 1, counted)}}},
+		})
+		assert.Equal(t, int64(15), res.Objects)
+		assert.Equal(t, int64(1050), res.Bytes)
+		require.Len(t, res.Namespaces, 2)
+		assert.Equal(t, catalogNamespace{Path: "/alone", Objects: 5, Bytes: 50, Origins: []string{"standalone"}, UpdatedAt: counted}, res.Namespaces[0])
+		assert.Equal(t, catalogNamespace{Path: "/mirrored", Objects: 10, Bytes: 1000, Origins: []string{"primary", "secondary"}, UpdatedAt: counted}, res.Namespaces[1])
+	})
+
+	t.Run("no-counts", func(t *testing.T) {
+		res := getFederationCatalog([]server_structs.Advertisement{originAd("origin", server_structs.NamespaceAdV2{Path: "/foo"})})
+		assert.Zero(t, res.Objects)
+		assert.Zero(t, res.Bytes)
+		assert.NotNil(t, res.Namespaces)
+		assert.Empty(t, res.Namespaces)
+	})
+}
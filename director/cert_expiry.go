@@ -0,0 +1,142 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/web_ui"
+)
+
+const (
+	// The factor the sorting weight of a server whose certificate is about to expire
+	// is scaled by, when Director.DeprioritizeExpiringCerts is set
+	expiringCertWeightFactor = 0.5
+)
+
+// Get when the TLS certificate a server presents at its URL expires
+func getServerCertExpiry(ctx context.Context, serverUrl url.URL) (time.Time, error) {
+	if serverUrl.Scheme != "https" {
+		return time.Time{}, errors.Errorf("server URL %s doesn't use TLS", serverUrl.String())
+	}
+	addr := serverUrl.Host
+	if serverUrl.Port() == "" {
+		addr = net.JoinHostPort(serverUrl.Hostname(), "443")
+	}
+	// The certificate isn't verified: only its expiry is read, and an expired
+	// certificate would otherwise fail the handshake before it can be reported
+	dialer := tls.Dialer{Config: &tls.Config{
+		ServerName:         serverUrl.Hostname(),
+		InsecureSkipVerify: true,
+	}}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "failed to connect to %s", addr)
+	}
+	defer conn.Close()
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return time.Time{}, errors.Errorf("server at %s presented no certificate", addr)
+	}
+	return certs[0].NotAfter, nil
+}
+
+// Check a server's certificate expiry as part of a health test, recording it for
+// the server list, the metrics, and sorting
+func updateServerCertExpiry(ctx context.Context, serverAd server_structs.ServerAd) {
+	expiry, err := getServerCertExpiry(ctx, serverAd.URL)
+	if err != nil {
+		log.Debugf("Failed to get the certificate expiry of %s server %s: %v", serverAd.Type, serverAd.Name, err)
+		return
+	}
+	func() {
+		healthTestUtilsMutex.Lock()
+		defer healthTestUtilsMutex.Unlock()
+		if existingUtil, ok := healthTestUtils[serverAd.URL.String()]; ok {
+			existingUtil.CertExpiry = expiry
+		}
+	}()
+	metrics.PelicanDirectorServerCertExpiry.With(
+		prometheus.Labels{
+			"server_name": serverAd.Name, "server_web_url": serverAd.WebURL.String(), "server_type": string(serverAd.Type),
+		}).Set(float64(expiry.Unix()))
+
+	notifyServerCertExpiry(serverAd, expiry, time.Now())
+}
+
+// Notify the director admins of a server whose certificate expires within
+// Director.CertExpiryWarningWindow, until the server renews it
+func notifyServerCertExpiry(serverAd server_structs.ServerAd, expiry time.Time, now time.Time) {
+	key := "cert-expiry/" + serverAd.URL.String()
+	window := param.Director_CertExpiryWarningWindow.GetDuration()
+	if window <= 0 || expiry.Sub(now) >= window {
+		web_ui.ResolveAdminNotification(key)
+		return
+	}
+	if expiry.Before(now) {
+		web_ui.NotifyAdmins("director", key, web_ui.NotificationCritical,
+			fmt.Sprintf("The certificate of %s server %s expired", serverAd.Type, serverAd.Name),
+			fmt.Sprintf("The TLS certificate of %s expired at %s", serverAd.URL.String(), expiry.Format(time.RFC3339)))
+		return
+	}
+	web_ui.NotifyAdmins("director", key, web_ui.NotificationWarning,
+		fmt.Sprintf("The certificate of %s server %s is about to expire", serverAd.Type, serverAd.Name),
+		fmt.Sprintf("The TLS certificate of %s expires at %s", serverAd.URL.String(), expiry.Format(time.RFC3339)))
+}
+
+// Get when a server's certificate expires, or the zero time if the director hasn't seen it
+func getServerCertExpiryTime(serverUrl string) time.Time {
+	healthTestUtilsMutex.RLock()
+	defer healthTestUtilsMutex.RUnlock()
+	if existingUtil, ok := healthTestUtils[serverUrl]; ok {
+		return existingUtil.CertExpiry
+	}
+	return time.Time{}
+}
+
+// Get the factor, between 0 and 1, by which to scale a server's sorting weight because
+// its certificate expires within Director.CertExpiryWarningWindow
+func getCertExpiryFactor(ad server_structs.ServerAd, now time.Time) float64 {
+	window := param.Director_CertExpiryWarningWindow.GetDuration()
+	expiry := getServerCertExpiryTime(ad.URL.String())
+	if window <= 0 || expiry.IsZero() || expiry.Sub(now) >= window {
+		return 1
+	}
+	if expiry.Before(now) {
+		log.Debugf("Sorting %s server %s, whose certificate expired at %s", ad.Type, ad.Name, expiry.Format(time.RFC3339))
+	} else {
+		log.Debugf("Sorting %s server %s, whose certificate expires in %s", ad.Type, ad.Name, expiry.Sub(now).Truncate(time.Second))
+	}
+	if param.Director_DeprioritizeExpiringCerts.GetBool() {
+		return expiringCertWeightFactor
+	}
+	return 1
+}
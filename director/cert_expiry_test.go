@@ -0,0 +1,104 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+func TestServerCertExpiry(t *testing.T) {
+	t.Cleanup(func() {
+		viper.Reset()
+	})
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+	serverUrl, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	ad := server_structs.ServerAd{
+		Name: "expiring",
+		URL:  *serverUrl,
+		Type: server_structs.CacheType,
+	}
+
+	t.Run("get-expiry", func(t *testing.T) {
+		expiry, err := getServerCertExpiry(context.Background(), ad.URL)
+		require.NoError(t, err)
+		assert.Equal(t, server.Certificate().NotAfter, expiry)
+
+		_, err = getServerCertExpiry(context.Background(), url.URL{Scheme: "http", Host: serverUrl.Host})
+		assert.Error(t, err)
+	})
+
+	t.Run("record-expiry", func(t *testing.T) {
+		healthTestUtilsMutex.Lock()
+		healthTestUtils[ad.URL.String()] = &healthTestUtil{Status: HealthStatusOK}
+		healthTestUtilsMutex.Unlock()
+		t.Cleanup(func() {
+			healthTestUtilsMutex.Lock()
+			delete(healthTestUtils, ad.URL.String())
+			healthTestUtilsMutex.Unlock()
+		})
+
+		updateServerCertExpiry(context.Background(), ad)
+		assert.Equal(t, server.Certificate().NotAfter, getServerCertExpiryTime(ad.URL.String()))
+	})
+
+	t.Run("expiry-factor", func(t *testing.T) {
+		healthTestUtilsMutex.Lock()
+		healthTestUtils[ad.URL.String()] = &healthTestUtil{Status: HealthStatusOK, CertExpiry: time.Now().Add(time.Hour)}
+		healthTestUtilsMutex.Unlock()
+		t.Cleanup(func() {
+			healthTestUtilsMutex.Lock()
+			delete(healthTestUtils, ad.URL.String())
+			healthTestUtilsMutex.Unlock()
+		})
+		now := time.Now()
+
+		// Servers about to expire are only de-prioritized when configured to be
+		viper.Set("Director.CertExpiryWarningWindow", "24h")
+		viper.Set("Director.DeprioritizeExpiringCerts", false)
+		assert.Equal(t, 1.0, getCertExpiryFactor(ad, now))
+
+		viper.Set("Director.DeprioritizeExpiringCerts", true)
+		assert.Equal(t, expiringCertWeightFactor, getCertExpiryFactor(ad, now))
+
+		// Certificates expiring after the window don't affect sorting
+		viper.Set("Director.CertExpiryWarningWindow", "30m")
+		assert.Equal(t, 1.0, getCertExpiryFactor(ad, now))
+
+		// Neither do servers whose certificates haven't been checked
+		viper.Set("Director.CertExpiryWarningWindow", "24h")
+		unchecked := ad
+		unchecked.URL.Host = "unchecked.example.com"
+		assert.Equal(t, 1.0, getCertExpiryFactor(unchecked, now))
+	})
+}
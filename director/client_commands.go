@@ -0,0 +1,168 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"os/user"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/token"
+	"github.com/pelicanplatform/pelican/token_scopes"
+	"github.com/pelicanplatform/pelican/utils"
+)
+
+// Create a token authorizing an administrative operation at the director.
+//
+// The token is signed with the local issuer key, so this must be invoked with the
+// director's own key (e.g., on the director host).  The directorUrl is the director's
+// external web URL, which the director expects as the token issuer.
+func createDirectorAdminToken(directorUrl string, scope token_scopes.TokenScope) (string, error) {
+	adminTokenCfg := token.NewWLCGToken()
+	adminTokenCfg.Lifetime = time.Minute
+	adminTokenCfg.Issuer = directorUrl
+	adminTokenCfg.AddAudiences(directorUrl)
+	adminTokenCfg.Subject = "cli"
+	if currentUser, err := user.Current(); err == nil {
+		adminTokenCfg.Subject = "cli:" + currentUser.Username
+	}
+	adminTokenCfg.AddScopes(scope)
+
+	tok, err := adminTokenCfg.CreateToken()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create director administration token with scope %s", scope)
+	}
+	return tok, nil
+}
+
+// Wrap the error of a failed request to the director, including the message of
+// the director's response if it has one
+func wrapRequestError(respData []byte, err error) error {
+	respErr := server_structs.SimpleApiResp{}
+	if unmarshalErr := json.Unmarshal(respData, &respErr); unmarshalErr == nil && respErr.Msg != "" {
+		return errors.Wrapf(err, "Server responded with an error: %s", respErr.Msg)
+	}
+	return errors.Wrap(err, "Failed to make request")
+}
+
+// Send a request to the director's downtime API and decode the affected downtimes
+func downtimeRequest(directorUrl, method, endpoint string, payload map[string]interface{}, authorize bool) ([]server_structs.Downtime, error) {
+	var headers map[string]string
+	if authorize {
+		tok, err := createDirectorAdminToken(directorUrl, token_scopes.Director_ManageDowntime)
+		if err != nil {
+			return nil, err
+		}
+		headers = map[string]string{"Authorization": "Bearer " + tok}
+	}
+
+	respData, err := utils.MakeRequest(context.Background(), endpoint, method, payload, headers)
+	if err != nil {
+		return nil, wrapRequestError(respData, err)
+	}
+	res := downtimeRes{}
+	if err := json.Unmarshal(respData, &res); err != nil {
+		return nil, errors.Wrapf(err, "Failed to parse the response from the director. Raw response is %s", respData)
+	}
+	return res.Downtimes, nil
+}
+
+func getDowntimeEndpoint(directorUrl string, elems ...string) (string, error) {
+	elems = append([]string{"api", "v1.0", "director_ui", "downtime"}, elems...)
+	endpoint, err := url.JoinPath(directorUrl, elems...)
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to construct the downtime endpoint URL")
+	}
+	return endpoint, nil
+}
+
+// Get the list of scheduled downtimes from the director.  The filter keys are the
+// query parameters of the downtime API, e.g. "server" or "status".
+func ListDowntimes(directorUrl string, filter url.Values) ([]server_structs.Downtime, error) {
+	endpoint, err := getDowntimeEndpoint(directorUrl)
+	if err != nil {
+		return nil, err
+	}
+	if len(filter) > 0 {
+		endpoint += "?" + filter.Encode()
+	}
+	return downtimeRequest(directorUrl, "GET", endpoint, nil, false)
+}
+
+// Schedule a batch of downtimes at the director.  The director applies the
+// batch atomically: either all of the downtimes are created or none are.
+func CreateDowntimes(directorUrl string, downtimes []server_structs.Downtime, dryRun bool) ([]server_structs.Downtime, error) {
+	endpoint, err := getDowntimeEndpoint(directorUrl)
+	if err != nil {
+		return nil, err
+	}
+	payload := map[string]interface{}{
+		"downtimes": downtimes,
+		"dryRun":    dryRun,
+	}
+	return downtimeRequest(directorUrl, "POST", endpoint, payload, true)
+}
+
+// Remove a scheduled downtime by its ID
+func DeleteDowntime(directorUrl string, id string) ([]server_structs.Downtime, error) {
+	endpoint, err := getDowntimeEndpoint(directorUrl, id)
+	if err != nil {
+		return nil, err
+	}
+	return downtimeRequest(directorUrl, "DELETE", endpoint, nil, true)
+}
+
+// Remove all of the scheduled downtimes matching the filter
+func DeleteDowntimes(directorUrl string, filter url.Values) ([]server_structs.Downtime, error) {
+	endpoint, err := getDowntimeEndpoint(directorUrl)
+	if err != nil {
+		return nil, err
+	}
+	if len(filter) > 0 {
+		endpoint += "?" + filter.Encode()
+	}
+	return downtimeRequest(directorUrl, "DELETE", endpoint, nil, true)
+}
+
+// Get the support information of the director, authorized by a token signed with
+// the local issuer key
+func GetSupportInfo(directorUrl string) (*SupportInfo, error) {
+	endpoint, err := url.JoinPath(directorUrl, "api", "v1.0", "director_ui", "support_info")
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to construct the support info endpoint URL")
+	}
+	tok, err := createDirectorAdminToken(directorUrl, token_scopes.Director_ReadSupportInfo)
+	if err != nil {
+		return nil, err
+	}
+	respData, err := utils.MakeRequest(context.Background(), endpoint, "GET", nil, map[string]string{"Authorization": "Bearer " + tok})
+	if err != nil {
+		return nil, wrapRequestError(respData, err)
+	}
+	info := SupportInfo{}
+	if err := json.Unmarshal(respData, &info); err != nil {
+		return nil, errors.Wrapf(err, "Failed to parse the response from the director. Raw response is %s", respData)
+	}
+	return &info, nil
+}
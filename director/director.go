@@ -26,9 +26,11 @@ import (
 	"net/url"
 	"path"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
@@ -40,6 +42,7 @@ import (
 
 	"github.com/pelicanplatform/pelican/config"
 	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/openapi"
 	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/server_structs"
 	"github.com/pelicanplatform/pelican/server_utils"
@@ -51,6 +54,11 @@ type (
 	// status of director-based health tests to origins and caches
 	HealthTestStatus string
 
+	// The path parameter of the redirection routes
+	objectPathParam struct {
+		Path string `uri:"any" description:"The path of the object in the federation's namespace"`
+	}
+
 	// Prometheus HTTP discovery endpoint struct, used by director
 	// to dynamically return available origin/cache servers for Prometheus to scrape
 	PromDiscoveryItem struct {
@@ -64,15 +72,22 @@ type (
 		ErrGrpContext context.Context
 		Cancel        context.CancelFunc
 		Status        HealthTestStatus
+		// When the server's TLS certificate expires, as seen by the last health test
+		CertExpiry time.Time
 	}
 	// Utility struct to keep track of the `stat` call the director made to the origin/cache servers
 	serverStatUtil struct {
-		Context  context.Context
-		Cancel   context.CancelFunc
-		Errgroup *errgroup.Group
+		// Runs the stat requests to the server, bounded by Director.StatConcurrencyLimit
+		Queue *server_utils.WorkQueue
 	}
 )
 
+const (
+	directorV1BasePath  = "/api/v1.0/director"
+	directorV2BasePath  = "/api/v2.0/director"
+	directorWebBasePath = "/api/v1.0/director_ui"
+)
+
 const (
 	HealthStatusUnknown HealthTestStatus = "Unknown"
 	HealthStatusInit    HealthTestStatus = "Initializing"
@@ -246,7 +261,55 @@ func versionCompatCheck(ginCtx *gin.Context) error {
 	return nil
 }
 
+// Determine the features of the client making the request.  Newer clients send them in the
+// X-Pelican-Client-Capabilities header; older Pelican clients, which only identify themselves
+// in the User-Agent, all support packed downloads; anything else (e.g. curl) is assumed to
+// support none of the features.
+func getClientCapabilities(req *http.Request) server_structs.ClientCapabilities {
+	if header := req.Header.Get(server_structs.ClientCapabilitiesHeader); header != "" {
+		return server_structs.ParseClientCapabilities(header)
+	}
+	userAgent := req.Header.Get("User-Agent")
+	if strings.HasPrefix(userAgent, "pelican-client/") {
+		clientVersion, _, _ := strings.Cut(strings.TrimPrefix(userAgent, "pelican-client/"), " ")
+		return server_structs.ClientCapabilities{Version: clientVersion, Features: server_structs.FeaturePack}
+	}
+	return server_structs.ClientCapabilities{}
+}
+
+// Remove the servers the client can't use because it lacks one of their required features
+func filterAdsByClientCapabilities(ads []server_structs.ServerAd, capabilities server_structs.ClientCapabilities) []server_structs.ServerAd {
+	filtered := make([]server_structs.ServerAd, 0, len(ads))
+	for _, ad := range ads {
+		if capabilities.Supports(ad.RequiredFeatures) {
+			filtered = append(filtered, ad)
+		} else {
+			log.Debugf("Skipping server %s, which requires client features the client (%s) lacks: %v",
+				ad.Name, capabilities, ad.RequiredFeatures.Names())
+		}
+	}
+	return filtered
+}
+
+// Point clients reading from a tape-backed namespace to the stage API of the first origin
+// offering one, so they can bring objects online before reading them
+func setTapeStageUrl(ginCtx *gin.Context, originAds []server_structs.ServerAd) {
+	for _, ad := range originAds {
+		if ad.Caps.TapeBacked && ad.WebURL.String() != "" {
+			ginCtx.Header(server_structs.TapeStageUrlHeader, ad.WebURL.JoinPath(server_structs.TapeStagePath).String())
+			return
+		}
+	}
+}
+
 func redirectToCache(ginCtx *gin.Context) {
+	// The director serves the objects under /.pelican/info itself
+	if name, ok := getPelicanInfoObject(strings.TrimPrefix(ginCtx.Request.URL.Path, "/api/v1.0/director/object")); ok {
+		servePelicanInfo(ginCtx, name)
+		return
+	}
+	defer recordRedirectDecision(ginCtx, "cache", time.Now())
+
 	err := versionCompatCheck(ginCtx)
 	if err != nil {
 		log.Warningf("A version incompatibility was encountered while redirecting to a cache and no response was served: %v", err)
@@ -272,6 +335,9 @@ func redirectToCache(ginCtx *gin.Context) {
 	reqParams := getRequestParameters(ginCtx.Request)
 
 	namespaceAd, originAds, cacheAds := getAdsForPath(reqPath)
+	clientCapabilities := getClientCapabilities(ginCtx.Request)
+	originAds = filterAdsByClientCapabilities(originAds, clientCapabilities)
+	cacheAds = filterAdsByClientCapabilities(cacheAds, clientCapabilities)
 	// if GetAdsForPath doesn't find any ads because the prefix doesn't exist, we should
 	// report the lack of path first -- this is most important for the user because it tells them
 	// they're trying to get an object that simply doesn't exist
@@ -282,6 +348,9 @@ func redirectToCache(ginCtx *gin.Context) {
 		})
 		return
 	}
+	rules := newRedirectRuleEvaluator(ginCtx.Request, ipAddr)
+	originAds = rules.exclude(namespaceAd, originAds)
+	cacheAds = rules.exclude(namespaceAd, cacheAds)
 	// if err != nil, depth == 0, which is the default value for depth
 	// so we can use it as the value for the header even with err
 	depth, err := getLinkDepth(reqPath, namespaceAd.Path)
@@ -290,10 +359,13 @@ func redirectToCache(ginCtx *gin.Context) {
 	}
 	// If the namespace prefix DOES exist, then it makes sense to say we couldn't find a valid cache.
 	if len(cacheAds) == 0 {
-		for _, originAd := range originAds {
-			if originAd.DirectReads {
-				cacheAds = append(cacheAds, originAd)
-				break
+		// Clients asking for a cache-only download would rather fail than read from the origin
+		if !ginCtx.Request.URL.Query().Has("cacheonly") {
+			for _, originAd := range originAds {
+				if originAd.DirectReads {
+					cacheAds = append(cacheAds, originAd)
+					break
+				}
 			}
 		}
 		if len(cacheAds) == 0 {
@@ -313,21 +385,25 @@ func redirectToCache(ginCtx *gin.Context) {
 			})
 			return
 		}
+		cacheAds = rules.prefer(namespaceAd, cacheAds)
 	}
 	redirectURL := getRedirectURL(reqPath, cacheAds[0], !namespaceAd.Caps.PublicReads)
 
 	linkHeader := ""
 	first := true
-	if numCAds := len(cacheAds); numCAds < cachesToSend {
-		cachesToSend = numCAds
+	numToSend := cachesToSend
+	if numCAds := len(cacheAds); numCAds < numToSend {
+		numToSend = numCAds
 	}
-	for idx, ad := range cacheAds[:cachesToSend] {
+	linkURLs := make([]url.URL, 0, numToSend)
+	for idx, ad := range cacheAds[:numToSend] {
 		if first {
 			first = false
 		} else {
 			linkHeader += ", "
 		}
 		redirectURL := getRedirectURL(reqPath, ad, !namespaceAd.Caps.PublicReads)
+		linkURLs = append(linkURLs, redirectURL)
 		linkHeader += fmt.Sprintf(`<%s>; rel="duplicate"; pri=%d; depth=%d`, redirectURL.String(), idx+1, depth)
 	}
 	ginCtx.Writer.Header()["Link"] = []string{linkHeader}
@@ -375,7 +451,16 @@ func redirectToCache(ginCtx *gin.Context) {
 	if colUrl != "" {
 		xPelicanNamespace += fmt.Sprintf(", collections-url=%s", colUrl)
 	}
+	if namespaceAd.Caps.TapeBacked {
+		xPelicanNamespace += ", tape-backed=true"
+		setTapeStageUrl(ginCtx, originAds)
+	}
 	ginCtx.Writer.Header()["X-Pelican-Namespace"] = []string{xPelicanNamespace}
+	ginCtx.Header(server_structs.ObjectAvailabilityHeader, string(getObjectAvailability(reqPath, namespaceAd, cacheAds, originAds)))
+	if format := getMetalinkFormat(ginCtx.Request); format != metalinkNone {
+		writeMetalink(ginCtx, format, reqPath, linkURLs, statObjectForMetalink(reqPath, reqParams.Get("authz"), originAds))
+		return
+	}
 	// Note we only append the `authz` query parameter in the case of the redirect response and not the
 	// duplicate link metadata above.  This is purposeful: the Link header might get too long if we repeat
 	// the token 20 times for 20 caches.  This means a "normal HTTP client" will correctly redirect but
@@ -384,6 +469,13 @@ func redirectToCache(ginCtx *gin.Context) {
 }
 
 func redirectToOrigin(ginCtx *gin.Context) {
+	// The director serves the objects under /.pelican/info itself
+	if name, ok := getPelicanInfoObject(strings.TrimPrefix(ginCtx.Request.URL.Path, "/api/v1.0/director/origin")); ok {
+		servePelicanInfo(ginCtx, name)
+		return
+	}
+	defer recordRedirectDecision(ginCtx, "origin", time.Now())
+
 	err := versionCompatCheck(ginCtx)
 	if err != nil {
 		log.Warningf("A version incompatibility was encountered while redirecting to an origin and no response was served: %v", err)
@@ -416,7 +508,7 @@ func redirectToOrigin(ginCtx *gin.Context) {
 
 	reqParams := getRequestParameters(ginCtx.Request)
 
-	namespaceAd, originAds, _ := getAdsForPath(reqPath)
+	namespaceAd, originAds, cacheAds := getAdsForPath(reqPath)
 	// if GetAdsForPath doesn't find any ads because the prefix doesn't exist, we should
 	// report the lack of path first -- this is most important for the user because it tells them
 	// they're trying to get an object that simply doesn't exist
@@ -435,8 +527,25 @@ func redirectToOrigin(ginCtx *gin.Context) {
 		})
 		return
 	}
+	clientCapabilities := getClientCapabilities(ginCtx.Request)
+	if originAds = filterAdsByClientCapabilities(originAds, clientCapabilities); len(originAds) == 0 {
+		ginCtx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "None of the origins exporting the provided namespace prefix support this client; they require features it lacks",
+		})
+		return
+	}
+	rules := newRedirectRuleEvaluator(ginCtx.Request, ipAddr)
+	if originAds = rules.exclude(namespaceAd, originAds); len(originAds) == 0 {
+		ginCtx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "All of the origins exporting the provided namespace prefix are excluded by the director's redirect rules",
+		})
+		return
+	}
 
 	availableOriginAds := []server_structs.ServerAd{}
+	var objectMeta *objectMetadata
 	// Skip stat query for PUT (upload), PROPFIND (listing) or skipStat query flag is on
 	if ginCtx.Request.Method == "PUT" || ginCtx.Request.Method == "PROPFIND" || skipStat {
 		availableOriginAds = originAds
@@ -450,6 +559,9 @@ func redirectToOrigin(ginCtx *gin.Context) {
 		// For successful response, we got a list of URL to access the object.
 		// We will use the host of the object url to match the URL field in originAds
 		if qr.Status == querySuccessful {
+			if len(qr.Objects) > 0 {
+				objectMeta = qr.Objects[0]
+			}
 			for _, obj := range qr.Objects {
 				serverHost := obj.URL.Host
 				for _, oAd := range originAds {
@@ -512,9 +624,18 @@ func redirectToOrigin(ginCtx *gin.Context) {
 		})
 		return
 	}
+	// Reads fail over between the mirrors of a replicated namespace in their priority order
+	if ginCtx.Request.Method != "PUT" {
+		availableOriginAds = sortOriginsByMirrorPriority(namespaceAd, availableOriginAds)
+		if ginCtx.Request.Method != "PROPFIND" {
+			recordMirrorSample(namespaceAd, availableOriginAds, reqPath)
+		}
+	}
+	availableOriginAds = rules.prefer(namespaceAd, availableOriginAds)
 
 	linkHeader := ""
 	first := true
+	linkURLs := make([]url.URL, 0, len(availableOriginAds))
 	for idx, ad := range availableOriginAds {
 		if first {
 			first = false
@@ -522,6 +643,7 @@ func redirectToOrigin(ginCtx *gin.Context) {
 			linkHeader += ", "
 		}
 		redirectURL := getRedirectURL(reqPath, ad, !namespaceAd.Caps.PublicReads)
+		linkURLs = append(linkURLs, redirectURL)
 		linkHeader += fmt.Sprintf(`<%s>; rel="duplicate"; pri=%d; depth=%d`, redirectURL.String(), idx+1, depth)
 	}
 	ginCtx.Writer.Header()["Link"] = []string{linkHeader}
@@ -537,8 +659,13 @@ func redirectToOrigin(ginCtx *gin.Context) {
 			colUrl = availableOriginAds[0].URL.String()
 		}
 	}
-	ginCtx.Writer.Header()["X-Pelican-Namespace"] = []string{fmt.Sprintf("namespace=%s, require-token=%v, collections-url=%s",
-		namespaceAd.Path, !namespaceAd.Caps.PublicReads, colUrl)}
+	xPelicanNamespace := fmt.Sprintf("namespace=%s, require-token=%v, collections-url=%s",
+		namespaceAd.Path, !namespaceAd.Caps.PublicReads, colUrl)
+	if namespaceAd.Caps.TapeBacked {
+		xPelicanNamespace += ", tape-backed=true"
+		setTapeStageUrl(ginCtx, availableOriginAds)
+	}
+	ginCtx.Writer.Header()["X-Pelican-Namespace"] = []string{xPelicanNamespace}
 
 	var redirectURL url.URL
 
@@ -585,22 +712,42 @@ func redirectToOrigin(ginCtx *gin.Context) {
 
 	// If we are doing a PUT, check to see if any origins are writeable
 	if ginCtx.Request.Method == "PUT" {
-		for idx, ad := range availableOriginAds {
-			if ad.Writes {
-				redirectURL = getRedirectURL(reqPath, availableOriginAds[idx], !namespaceAd.PublicRead)
-				if brokerUrl := availableOriginAds[idx].BrokerURL; brokerUrl.String() != "" {
-					ginCtx.Header("X-Pelican-Broker", brokerUrl.String())
-				}
+		// Clients asking for a write-back upload are sent to the nearest cache accepting them,
+		// which writes the object to an origin later; without such a cache, they upload directly
+		if ginCtx.Request.URL.Query().Has("writeback") && slices.ContainsFunc(availableOriginAds, func(ad server_structs.ServerAd) bool { return ad.Writes }) {
+			if cacheAd, ok := selectWriteBackCache(ipAddr, namespaceAd, cacheAds, clientCapabilities, rules); ok {
+				ginCtx.Header(server_structs.WriteBackHeader, writeBackHeaderValue(cacheAd))
+				redirectURL = *cacheAd.WebURL.JoinPath(server_structs.WriteBackPath, reqPath)
 				ginCtx.Redirect(http.StatusTemporaryRedirect, getFinalRedirectURL(redirectURL, reqParams))
 				return
 			}
 		}
+		if ad, writers, ok := selectWriteOrigin(availableOriginAds, namespaceAd.Path); ok {
+			redirectURL = getRedirectURL(reqPath, ad, !namespaceAd.PublicRead)
+			if brokerUrl := ad.BrokerURL; brokerUrl.String() != "" {
+				ginCtx.Header("X-Pelican-Broker", brokerUrl.String())
+			}
+			if ad.ResumableUploads && ad.WebURL.String() != "" {
+				ginCtx.Header(server_structs.ResumableUploadUrlHeader, ad.WebURL.JoinPath(server_structs.ResumableUploadPath).String())
+			}
+			// Writes to one origin aren't visible via the namespace's other origins until they propagate
+			if writers > 1 {
+				warnMultipleWriters(namespaceAd.Path, writers)
+				ginCtx.Header(writeConsistencyHeader, fmt.Sprintf("eventual; origins=%d", writers))
+			}
+			ginCtx.Redirect(http.StatusTemporaryRedirect, getFinalRedirectURL(redirectURL, reqParams))
+			return
+		}
 		ginCtx.JSON(http.StatusMethodNotAllowed, server_structs.SimpleApiResp{
 			Status: server_structs.RespFailed,
 			Msg:    "No origins on specified endpoint have direct reads enabled",
 		})
 		return
 	} else { // Otherwise, we are doing a GET
+		if format := getMetalinkFormat(ginCtx.Request); format != metalinkNone {
+			writeMetalink(ginCtx, format, reqPath, linkURLs, objectMeta)
+			return
+		}
 		redirectURL := getRedirectURL(reqPath, availableOriginAds[0], !namespaceAd.PublicRead)
 		if brokerUrl := availableOriginAds[0].BrokerURL; brokerUrl.String() != "" {
 			ginCtx.Header("X-Pelican-Broker", brokerUrl.String())
@@ -714,6 +861,35 @@ func ShortcutMiddleware(defaultResponse string) gin.HandlerFunc {
 	}
 }
 
+// Read the advertisement in the request body, which may be an OriginAdvertiseV1 from
+// older servers or an OriginAdvertiseV2
+func bindServerAd(ctx *gin.Context) (server_structs.OriginAdvertiseV2, error) {
+	ad := server_structs.OriginAdvertiseV1{}
+	if err := ctx.ShouldBindBodyWith(&ad, binding.JSON); err == nil {
+		// If the OriginAdvertisement is a V1 type, convert to a V2 type
+		return server_structs.ConvertOriginAdV1ToV2(ad), nil
+	}
+	// Failed binding to a V1 type, so should now check to see if it's a V2 type
+	adV2 := server_structs.OriginAdvertiseV2{}
+	err := ctx.ShouldBindBodyWith(&adV2, binding.JSON)
+	return adV2, err
+}
+
+// The prefix the server is registered under in the registry; false if the server
+// predates the registration of servers, so it can't be verified
+func serverRegistryPrefix(sType server_structs.ServerType, ad server_structs.OriginAdvertiseV2) (string, bool) {
+	if ad.RegistryPrefix != "" {
+		return ad.RegistryPrefix, true
+	}
+	if sType == server_structs.OriginType {
+		// For origins < 7.9.0, they are not registered, and we skip the verification
+		return "", false
+	}
+	// For caches <= 7.8.1, they don't have RegistryPrefix
+	// so we fall back to Name
+	return server_structs.GetCacheNS(ad.Name), true
+}
+
 func registerServeAd(engineCtx context.Context, ctx *gin.Context, sType server_structs.ServerType) {
 	ctx.Set("serverType", string(sType))
 	tokens, present := ctx.Request.Header["Authorization"]
@@ -735,29 +911,32 @@ func registerServeAd(engineCtx context.Context, ctx *gin.Context, sType server_s
 		return
 	}
 
-	ad := server_structs.OriginAdvertiseV1{}
-	adV2 := server_structs.OriginAdvertiseV2{}
-	err = ctx.ShouldBindBodyWith(&ad, binding.JSON)
+	adV2, err := bindServerAd(ctx)
 	if err != nil {
-		// Failed binding to a V1 type, so should now check to see if it's a V2 type
-		adV2 = server_structs.OriginAdvertiseV2{}
-		err = ctx.ShouldBindBodyWith(&adV2, binding.JSON)
-		if err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("Invalid %s registration", sType),
+		})
+		return
+	}
+
+	// Set to ctx for metrics handler downstream
+	ctx.Set("serverName", adV2.Name)
+	ctx.Set("serverWebUrl", adV2.WebURL)
+
+	var adVersions server_structs.ProtocolVersions
+	if adV2.Versions != nil {
+		adVersions = *adV2.Versions
+		if err := server_structs.GetProtocolVersions().CheckCompatible(adVersions); err != nil {
+			log.Warningf("Rejecting the advertisement of %s %s with incompatible protocol versions: %v", sType, adV2.Name, err)
 			ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
 				Status: server_structs.RespFailed,
-				Msg:    fmt.Sprintf("Invalid %s registration", sType),
+				Msg:    "Incompatible versions detected: " + err.Error(),
 			})
 			return
 		}
-	} else {
-		// If the OriginAdvertisement is a V1 type, convert to a V2 type
-		adV2 = server_structs.ConvertOriginAdV1ToV2(ad)
 	}
 
-	// Set to ctx for metrics handler downstream
-	ctx.Set("serverName", adV2.Name)
-	ctx.Set("serverWebUrl", adV2.WebURL)
-
 	adUrl, err := url.Parse(adV2.DataURL)
 	if err != nil {
 		log.Warningf("Failed to parse %s URL %v: %v\n", sType, adV2.DataURL, err)
@@ -790,18 +969,7 @@ func registerServeAd(engineCtx context.Context, ctx *gin.Context, sType server_s
 	// Verify server registration
 	token := strings.TrimPrefix(tokens[0], "Bearer ")
 
-	registryPrefix := adV2.RegistryPrefix
-	verifyServer := true
-	if registryPrefix == "" {
-		if sType == server_structs.OriginType {
-			// For origins < 7.9.0, they are not registered, and we skip the verification
-			verifyServer = false
-		} else {
-			// For caches <= 7.8.1, they don't have RegistryPrefix
-			// so we fall back to Name
-			registryPrefix = server_structs.GetCacheNS(adV2.Name)
-		}
-	}
+	registryPrefix, verifyServer := serverRegistryPrefix(sType, adV2)
 
 	approvalErrMsg := "You may find more information on " + param.Server_ExternalWebUrl.GetString()
 	// Prepare the admin approval error message
@@ -818,7 +986,7 @@ func registerServeAd(engineCtx context.Context, ctx *gin.Context, sType server_s
 		if err != nil {
 			if err == adminApprovalErr {
 				log.Warningf("Failed to verify token. %s %q was not approved", string(sType), adV2.Name)
-				ctx.JSON(http.StatusForbidden, gin.H{"approval_error": true, "error": fmt.Sprintf("%s %q was not approved by an administrator. %s", string(sType), ad.Name, approvalErrMsg)})
+				ctx.JSON(http.StatusForbidden, gin.H{"approval_error": true, "error": fmt.Sprintf("%s %q was not approved by an administrator. %s", string(sType), adV2.Name, approvalErrMsg)})
 				return
 			} else {
 				log.Warningln("Failed to verify token:", err)
@@ -881,6 +1049,12 @@ func registerServeAd(engineCtx context.Context, ctx *gin.Context, sType server_s
 		Writes:      adV2.Caps.Writes,
 		DirectReads: adV2.Caps.DirectReads,
 		Listings:    adV2.Caps.Listings,
+
+		RequiredFeatures: adV2.RequiredFeatures,
+		ResumableUploads: adV2.ResumableUploads,
+		WriteBack:        adV2.WriteBack,
+		Versions:         adVersions,
+		IOCapacity:       adV2.IOCapacity,
 	}
 
 	recordAd(engineCtx, sAd, &adV2.Namespaces)
@@ -1042,35 +1216,177 @@ func getHealthTestFile(ctx *gin.Context) {
 	}
 }
 
+// The routes of the v1 director API.  The server registration handlers keep the
+// registered servers until ctx is cancelled.
+func directorV1Routes(ctx context.Context) []openapi.Route {
+	return []openapi.Route{
+		// The routes used for cache/origin redirection
+		{
+			Method: http.MethodGet, Path: "/object/*any", OperationID: "redirectToCache", Tag: "redirection",
+			Summary:    "Redirect the client to the best cache serving the object",
+			PathParams: objectPathParam{}, Status: http.StatusTemporaryRedirect,
+			Handlers: []gin.HandlerFunc{redirectToCache},
+		},
+		{
+			Method: http.MethodHead, Path: "/object/*any", OperationID: "redirectToCacheHead", Tag: "redirection",
+			Summary:    "Redirect the client to the best cache serving the object",
+			PathParams: objectPathParam{}, Status: http.StatusTemporaryRedirect,
+			Handlers: []gin.HandlerFunc{redirectToCache},
+		},
+		{
+			Method: http.MethodGet, Path: "/origin/*any", OperationID: "redirectToOrigin", Tag: "redirection",
+			Summary:    "Redirect the client to an origin exporting the object",
+			PathParams: objectPathParam{}, Status: http.StatusTemporaryRedirect,
+			Handlers: []gin.HandlerFunc{redirectToOrigin},
+		},
+		{
+			Method: http.MethodHead, Path: "/origin/*any", OperationID: "redirectToOriginHead", Tag: "redirection",
+			Summary:    "Redirect the client to an origin exporting the object",
+			PathParams: objectPathParam{}, Status: http.StatusTemporaryRedirect,
+			Handlers: []gin.HandlerFunc{redirectToOrigin},
+		},
+		{
+			Method: http.MethodPut, Path: "/origin/*any", OperationID: "redirectUploadToOrigin", Tag: "redirection",
+			Summary:    "Redirect an upload to an origin accepting writes to the object's namespace",
+			PathParams: objectPathParam{}, Status: http.StatusTemporaryRedirect,
+			Handlers: []gin.HandlerFunc{redirectToOrigin},
+		},
+		{
+			Method: http.MethodPost, Path: "/registerOrigin", OperationID: "registerOrigin", Tag: "servers",
+			Summary:  "Advertise an origin and the namespaces it exports to the director",
+			Security: []string{"serverToken"}, Request: server_structs.OriginAdvertiseV2{}, Response: server_structs.SimpleApiResp{},
+			Handlers: []gin.HandlerFunc{serverAdMetricMiddleware, func(gctx *gin.Context) { registerServeAd(ctx, gctx, server_structs.OriginType) }},
+		},
+		{
+			Method: http.MethodPost, Path: "/registerCache", OperationID: "registerCache", Tag: "servers",
+			Summary:  "Advertise a cache and the namespaces it serves to the director",
+			Security: []string{"serverToken"}, Request: server_structs.OriginAdvertiseV2{}, Response: server_structs.SimpleApiResp{},
+			Handlers: []gin.HandlerFunc{serverAdMetricMiddleware, func(gctx *gin.Context) { registerServeAd(ctx, gctx, server_structs.CacheType) }},
+		},
+		{
+			Method: http.MethodPost, Path: "/validateOrigin", OperationID: "validateOrigin", Tag: "servers",
+			Summary:  "Check an origin's advertisement, registration and reachability without registering it",
+			Security: []string{"serverToken"}, Request: server_structs.OriginAdvertiseV2{}, Response: server_structs.AdValidationReport{},
+			Handlers: []gin.HandlerFunc{adValidationRateLimitHandler, func(gctx *gin.Context) { validateServeAd(ctx, gctx, server_structs.OriginType) }},
+		},
+		{
+			Method: http.MethodPost, Path: "/validateCache", OperationID: "validateCache", Tag: "servers",
+			Summary:  "Check a cache's advertisement, registration and reachability without registering it",
+			Security: []string{"serverToken"}, Request: server_structs.OriginAdvertiseV2{}, Response: server_structs.AdValidationReport{},
+			Handlers: []gin.HandlerFunc{adValidationRateLimitHandler, func(gctx *gin.Context) { validateServeAd(ctx, gctx, server_structs.CacheType) }},
+		},
+		{
+			Method: http.MethodGet, Path: "/listNamespaces", OperationID: "listNamespacesV1", Tag: "namespaces",
+			Summary:  "List the namespaces advertised to the director",
+			Response: []server_structs.NamespaceAdV1{},
+			Handlers: []gin.HandlerFunc{listNamespacesV1},
+		},
+		{
+			Method: http.MethodGet, Path: "/namespaces/prefix/*path", OperationID: "getPrefixByPath", Tag: "namespaces",
+			Summary:  "Get the namespace prefix an object path belongs to",
+			Response: server_structs.GetPrefixByPathRes{},
+			Handlers: []gin.HandlerFunc{getPrefixByPath},
+		},
+		{
+			Method: http.MethodGet, Path: "/healthTest/*path", OperationID: "getHealthTestFile", Tag: "servers",
+			Summary:     "Get a director-generated file for the caches' health tests",
+			ContentType: "text/plain",
+			Handlers:    []gin.HandlerFunc{getHealthTestFile},
+		},
+		{
+			Method: http.MethodHead, Path: "/healthTest/*path", OperationID: "getHealthTestFileHead", Tag: "servers",
+			Summary:     "Get a director-generated file for the caches' health tests",
+			ContentType: "text/plain",
+			Handlers:    []gin.HandlerFunc{getHealthTestFile},
+		},
+		// In the foreseeable feature, director will scrape all servers in Pelican ecosystem (including registry)
+		// so that director can be our point of contact for collecting system-level metrics.
+		// Rename the endpoint to reflect such plan.
+		{
+			Method: http.MethodGet, Path: "/discoverServers", OperationID: "discoverServers", Tag: "servers",
+			Summary:  "List the servers of the federation as Prometheus HTTP service discovery targets",
+			Security: []string{"adminToken"}, Response: []PromDiscoveryItem{},
+			Handlers: []gin.HandlerFunc{discoverOriginCache},
+		},
+		{
+			Method: http.MethodGet, Path: "/serverList", OperationID: "getServerList", Tag: "servers",
+			Summary:  "Get the list of servers of the federation, signed by the director",
+			Response: server_structs.SignedServerListRes{},
+			Handlers: []gin.HandlerFunc{getSignedServerList},
+		},
+		{
+			Method: http.MethodPost, Path: "/resolve", OperationID: "resolveObjects", Tag: "redirection",
+			Summary: "Resolve the caches and origins serving a batch of object paths",
+			Request: server_structs.BatchResolveRequest{}, Response: server_structs.BatchResolveResponse{},
+			Handlers: []gin.HandlerFunc{batchResolveObjects},
+		},
+		{
+			Method: http.MethodGet, Path: "/announcements", OperationID: "listAnnouncements", Tag: "federation",
+			Summary:  "List the announcements the director publishes to the federation's clients",
+			Response: []server_structs.Announcement{},
+			Handlers: []gin.HandlerFunc{listAnnouncements},
+		},
+	}
+}
+
+var directorV2Routes = []openapi.Route{
+	{
+		Method: http.MethodGet, Path: "/listNamespaces", OperationID: "listNamespacesV2", Tag: "namespaces",
+		Summary:  "List the namespaces advertised to the director",
+		Response: []server_structs.NamespaceAdV2{},
+		Handlers: []gin.HandlerFunc{listNamespacesV2},
+	},
+}
+
+// Build the OpenAPI document describing the director API, including its web UI API
+func getDirectorOpenAPIDoc(v1Routes []openapi.Route) *openapi.Document {
+	doc := openapi.NewDocument(openapi.Info{
+		Title:       "Pelican Director API",
+		Description: "Object redirection, server registration and federation status for a Pelican federation",
+		Version:     "1.0",
+	})
+	doc.AddSecurityScheme("loginCookie", openapi.SecurityScheme{
+		Type:        "apiKey",
+		In:          "cookie",
+		Name:        "login",
+		Description: "The director web UI login cookie",
+	})
+	doc.AddSecurityScheme("adminToken", openapi.SecurityScheme{
+		Type:         "http",
+		Scheme:       "bearer",
+		BearerFormat: "JWT",
+		Description:  "A token signed by the director's issuer key with the scope required by the operation",
+	})
+	doc.AddSecurityScheme("serverToken", openapi.SecurityScheme{
+		Type:         "http",
+		Scheme:       "bearer",
+		BearerFormat: "JWT",
+		Description:  "A token signed by the advertising server's key registered in the registry",
+	})
+	if webUrl := param.Server_ExternalWebUrl.GetString(); webUrl != "" {
+		doc.AddServer(webUrl)
+	}
+	doc.AddRoutes(directorV1BasePath, v1Routes)
+	doc.AddRoutes(directorV2BasePath, directorV2Routes)
+	doc.AddRoutes(directorWebBasePath, directorWebRoutes)
+	return doc
+}
+
 func RegisterDirectorAPI(ctx context.Context, router *gin.RouterGroup) {
-	directorAPIV1 := router.Group("/api/v1.0/director")
+	v1Routes := directorV1Routes(ctx)
+	directorAPIV1 := router.Group(directorV1BasePath)
 	{
-		// Establish the routes used for cache/origin redirection
-		directorAPIV1.GET("/object/*any", redirectToCache)
-		directorAPIV1.HEAD("/object/*any", redirectToCache)
-		directorAPIV1.GET("/origin/*any", redirectToOrigin)
-		directorAPIV1.HEAD("/origin/*any", redirectToOrigin)
-		directorAPIV1.PUT("/origin/*any", redirectToOrigin)
-		directorAPIV1.POST("/registerOrigin", serverAdMetricMiddleware, func(gctx *gin.Context) { registerServeAd(ctx, gctx, server_structs.OriginType) })
-		directorAPIV1.POST("/registerCache", serverAdMetricMiddleware, func(gctx *gin.Context) { registerServeAd(ctx, gctx, server_structs.CacheType) })
-		directorAPIV1.GET("/listNamespaces", listNamespacesV1)
-		directorAPIV1.GET("/namespaces/prefix/*path", getPrefixByPath)
-		directorAPIV1.GET("/healthTest/*path", getHealthTestFile)
-		directorAPIV1.HEAD("/healthTest/*path", getHealthTestFile)
+		openapi.RegisterRoutes(directorAPIV1, v1Routes)
 		directorAPIV1.Any("/origin", func(gctx *gin.Context) { // Need to do this for PROPFIND since gin does not support it
 			if gctx.Request.Method == "PROPFIND" {
 				redirectToOrigin(gctx)
 			}
 		})
-
-		// In the foreseeable feature, director will scrape all servers in Pelican ecosystem (including registry)
-		// so that director can be our point of contact for collecting system-level metrics.
-		// Rename the endpoint to reflect such plan.
-		directorAPIV1.GET("/discoverServers", discoverOriginCache)
+		directorAPIV1.GET("/openapi.json", openapi.ServeDocument(func() *openapi.Document { return getDirectorOpenAPIDoc(v1Routes) }))
 	}
 
-	directorAPIV2 := router.Group("/api/v2.0/director")
+	directorAPIV2 := router.Group(directorV2BasePath)
 	{
-		directorAPIV2.GET("/listNamespaces", listNamespacesV2)
+		openapi.RegisterRoutes(directorAPIV2, directorV2Routes)
 	}
 }
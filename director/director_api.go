@@ -20,7 +20,8 @@ package director
 
 import (
 	"context"
-	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/jellydator/ttlcache/v3"
@@ -31,6 +32,14 @@ import (
 	"github.com/pelicanplatform/pelican/metrics"
 	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+const (
+	// The number of evicted servers cleaned up at once, and the number waiting for cleanup
+	// before further ones are cleaned up in goroutines of their own
+	evictionCleanupWorkers  = 4
+	evictionCleanupCapacity = 1000
 )
 
 // List all namespaces from origins registered at the director
@@ -46,6 +55,60 @@ func listNamespacesFromOrigins() []server_structs.NamespaceAdV2 {
 	return namespaces
 }
 
+// List the namespaces of the origins registered at the director, like listNamespacesFromOrigins,
+// but once per namespace with the names of the origins exporting it and the caches serving it.
+// The namespaces are sorted by path.
+func listNamespaceServers() []namespaceListing {
+	byPath := map[string]*namespaceListing{}
+	var cacheAds []*server_structs.Advertisement
+	for _, item := range serverAds.Items() {
+		ad := item.Value()
+		if ad.Type == server_structs.CacheType {
+			cacheAds = append(cacheAds, ad)
+			continue
+		}
+		if ad.Type != server_structs.OriginType {
+			continue
+		}
+		for _, nsAd := range ad.NamespaceAds {
+			listing, ok := byPath[nsAd.Path]
+			if !ok {
+				listing = &namespaceListing{Path: nsAd.Path, FromTopology: nsAd.FromTopology, Origins: []string{}, Caches: []string{}}
+				byPath[nsAd.Path] = listing
+			}
+			// A namespace exported by several origins has the capabilities of any of them
+			listing.Caps.PublicReads = listing.Caps.PublicReads || nsAd.Caps.PublicReads || nsAd.PublicRead
+			listing.Caps.Reads = listing.Caps.Reads || nsAd.Caps.Reads
+			listing.Caps.Writes = listing.Caps.Writes || nsAd.Caps.Writes
+			listing.Caps.Listings = listing.Caps.Listings || nsAd.Caps.Listings
+			listing.Caps.DirectReads = listing.Caps.DirectReads || nsAd.Caps.DirectReads
+			listing.Caps.TapeBacked = listing.Caps.TapeBacked || nsAd.Caps.TapeBacked
+			listing.FromTopology = listing.FromTopology && nsAd.FromTopology
+			if !slices.Contains(listing.Origins, ad.Name) {
+				listing.Origins = append(listing.Origins, ad.Name)
+			}
+		}
+	}
+	for _, ad := range cacheAds {
+		for _, nsAd := range ad.NamespaceAds {
+			if listing, ok := byPath[nsAd.Path]; ok && !slices.Contains(listing.Caches, ad.Name) {
+				listing.Caches = append(listing.Caches, ad.Name)
+			}
+		}
+	}
+
+	namespaces := make([]namespaceListing, 0, len(byPath))
+	for _, listing := range byPath {
+		slices.Sort(listing.Origins)
+		slices.Sort(listing.Caches)
+		namespaces = append(namespaces, *listing)
+	}
+	slices.SortFunc(namespaces, func(a, b namespaceListing) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return namespaces
+}
+
 // List all advertisements in the TTL cache that match the serverType array
 func listAdvertisement(serverTypes []server_structs.ServerType) []server_structs.Advertisement {
 	ads := make([]server_structs.Advertisement, 0)
@@ -61,7 +124,8 @@ func listAdvertisement(serverTypes []server_structs.ServerType) []server_structs
 }
 
 // Check if a server is filtered from "production" servers by
-// checking if a serverName is in the filteredServers map
+// checking if a serverName is in the filteredServers map or
+// is in an active scheduled downtime
 func checkFilter(serverName string) (bool, filterType) {
 	filteredServersMutex.RLock()
 	defer filteredServersMutex.RUnlock()
@@ -69,6 +133,9 @@ func checkFilter(serverName string) (bool, filterType) {
 	status, exists := filteredServers[serverName]
 	// No filter entry
 	if !exists {
+		if _, inDowntime := getActiveDowntime(serverName, time.Now()); inDowntime {
+			return true, scheduledFiltered
+		}
 		return false, ""
 	} else {
 		// Has filter entry
@@ -88,6 +155,29 @@ func checkFilter(serverName string) (bool, filterType) {
 	}
 }
 
+// Record how long an eviction callback of a TTL cache took and, for expired items, how
+// long after their expiry the callback started.  Deferred at the start of the callback.
+func observeEviction(cacheName string, reason ttlcache.EvictionReason, expiresAt, start time.Time) {
+	if reason == ttlcache.EvictionReasonExpired && !expiresAt.IsZero() {
+		metrics.PelicanDirectorTTLCacheEvictionLatency.WithLabelValues(cacheName).Observe(start.Sub(expiresAt).Seconds())
+	}
+	metrics.PelicanDirectorTTLCacheEvictionDuration.WithLabelValues(cacheName).Observe(time.Since(start).Seconds())
+}
+
+// Run the slow part of cleaning up after an evicted item on the cleanup queue so the
+// eviction callback returns right away.  If the queue is full or stopped, as when the
+// director shuts down, the cleanup runs in a goroutine of its own instead.
+func queueEvictionCleanup(queue *server_utils.WorkQueue, cleanup func()) {
+	err := queue.TrySubmit(context.Background(), func(context.Context) error {
+		cleanup()
+		return nil
+	})
+	if err != nil {
+		log.Debugln("Cleaning up an evicted item outside of the cleanup queue:", err)
+		go cleanup()
+	}
+}
+
 // Configure TTL caches to enable cache eviction and other additional cache events handling logic
 //
 // The `ctx` is the context for listening to server shutdown event in order to cleanup internal cache eviction goroutine
@@ -95,22 +185,40 @@ func LaunchTTLCache(ctx context.Context, egrp *errgroup.Group) {
 	// Start automatic expired item deletion
 	go serverAds.Start()
 	go namespaceKeys.Start()
+	go rttProbeResults.Start()
+	go objectAvailability.Start()
+
+	// The cleanup of evicted servers waits for their health tests and stat calls to
+	// exit, so it runs on a queue of its own rather than in the eviction callbacks
+	cleanupQueue := server_utils.NewWorkQueue(ctx, server_utils.WorkQueueConfig{
+		Name:     "director_eviction_cleanup",
+		Workers:  evictionCleanupWorkers,
+		Capacity: evictionCleanupCapacity,
+	})
 
 	serverAds.OnEviction(func(ctx context.Context, er ttlcache.EvictionReason, i *ttlcache.Item[string, *server_structs.Advertisement]) {
-		healthTestUtilsMutex.RLock()
-		defer healthTestUtilsMutex.RUnlock()
+		defer observeEviction("serverAds", er, i.ExpiresAt(), time.Now())
 		serverAd := i.Value().ServerAd
 		serverUrl := i.Key()
+		endReadmission(serverUrl)
 
-		if util, exists := healthTestUtils[serverUrl]; exists {
+		// Don't hold the lock while waiting for the health tests to exit, as they
+		// take it to record their results
+		healthTestUtilsMutex.RLock()
+		util, exists := healthTestUtils[serverUrl]
+		healthTestUtilsMutex.RUnlock()
+		if exists {
 			util.Cancel()
 			if util.ErrGrp != nil {
-				err := util.ErrGrp.Wait()
-				if err != nil {
-					log.Debugf("Error from errgroup when evict the registration from TTL cache for %s %s %s", string(serverAd.Type), serverAd.Name, err.Error())
-				} else {
-					log.Debugf("Errgroup successfully emptied at TTL cache eviction for %s %s", string(serverAd.Type), serverAd.Name)
-				}
+				errGrp := util.ErrGrp
+				queueEvictionCleanup(cleanupQueue, func() {
+					err := errGrp.Wait()
+					if err != nil {
+						log.Debugf("Error from errgroup when evict the registration from TTL cache for %s %s %s", string(serverAd.Type), serverAd.Name, err.Error())
+					} else {
+						log.Debugf("Errgroup successfully emptied at TTL cache eviction for %s %s", string(serverAd.Type), serverAd.Name)
+					}
+				})
 			} else {
 				log.Debugf("errgroup is nil when evict the registration from TTL cache for %s %s", string(serverAd.Type), serverAd.Name)
 			}
@@ -120,15 +228,14 @@ func LaunchTTLCache(ctx context.Context, egrp *errgroup.Group) {
 
 		if serverAd.Type == server_structs.OriginType {
 			statUtilsMutex.Lock()
-			defer statUtilsMutex.Unlock()
 			statUtil, ok := statUtils[serverUrl]
 			if ok {
-				statUtil.Cancel()
-				if err := statUtil.Errgroup.Wait(); err != nil {
-					log.Info(fmt.Sprintf("Error happened when stopping origin %q stat goroutine group: %v", serverAd.Name, err))
-				}
 				delete(statUtils, serverUrl)
 			}
+			statUtilsMutex.Unlock()
+			if ok {
+				queueEvictionCleanup(cleanupQueue, statUtil.Queue.Stop)
+			}
 		}
 	})
 
@@ -140,6 +247,10 @@ func LaunchTTLCache(ctx context.Context, egrp *errgroup.Group) {
 		serverAds.Stop()
 		namespaceKeys.DeleteAll()
 		namespaceKeys.Stop()
+		rttProbeResults.DeleteAll()
+		rttProbeResults.Stop()
+		objectAvailability.DeleteAll()
+		objectAvailability.Stop()
 		log.Info("Director TTL cache eviction has been stopped")
 		return nil
 	})
@@ -148,6 +259,9 @@ func LaunchTTLCache(ctx context.Context, egrp *errgroup.Group) {
 
 // Launch a goroutine to scrape metrics from various TTL caches and maps in the director
 func LaunchMapMetrics(ctx context.Context, egrp *errgroup.Group) {
+	// Ad ages are computed at each scrape so they keep growing between advertisements
+	metrics.PelicanDirectorAdAgeSeconds.SetSource(getAdAges)
+
 	// Scrape TTL cache and map metrics for Prometheus
 	egrp.Go(func() error {
 		ticker := time.NewTicker(10 * time.Second)
@@ -182,16 +296,49 @@ func LaunchMapMetrics(ctx context.Context, egrp *errgroup.Group) {
 	})
 }
 
-// Populate internal filteredServers map by Director.FilteredServers
+// Populate internal filteredServers map by Director.FilteredServers, then restore the
+// servers filtered or allowed through the web UI and the scheduled downtimes saved in the
+// director's database
 func ConfigFilterdServers() {
 	filteredServersMutex.Lock()
 	defer filteredServersMutex.Unlock()
 
-	if !param.Director_FilteredServers.IsSet() {
-		return
+	if param.Director_FilteredServers.IsSet() {
+		for _, sn := range param.Director_FilteredServers.GetStringSlice() {
+			filteredServers[sn] = permFiltered
+		}
 	}
 
-	for _, sn := range param.Director_FilteredServers.GetStringSlice() {
-		filteredServers[sn] = permFiltered
+	filters, err := loadServerFilters()
+	if err != nil {
+		log.Errorln("Failed to load the filtered servers from the director's database:", err)
+	}
+	for _, filter := range filters {
+		switch {
+		case filter.FilterType == tempFiltered && filteredServers[filter.ServerName] == "":
+			filteredServers[filter.ServerName] = tempFiltered
+		case filter.FilterType == tempAllowed && filteredServers[filter.ServerName] == permFiltered:
+			filteredServers[filter.ServerName] = tempAllowed
+		default:
+			// The server was since added to, or removed from, Director.FilteredServers
+			log.Infof("Dropping the saved %s filter of server %s, which no longer applies", filter.FilterType, filter.ServerName)
+			if err := saveServerFilter(filter.ServerName, "", ""); err != nil {
+				log.Warningf("Failed to remove the filter of server %s from the director's database: %v", filter.ServerName, err)
+			}
+		}
+	}
+
+	dts, err := loadDowntimes(time.Now())
+	if err != nil {
+		log.Errorln("Failed to load the scheduled downtimes from the director's database:", err)
+		return
+	}
+	downtimesMutex.Lock()
+	defer downtimesMutex.Unlock()
+	for _, dt := range dts {
+		downtimes[dt.ID] = dt
+	}
+	if len(filters) > 0 || len(dts) > 0 {
+		log.Infof("Restored %d server filter(s) and %d scheduled downtime(s) from the director's database", len(filters), len(dts))
 	}
 }
@@ -0,0 +1,170 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+// The director keeps the state its admins change at runtime, the servers filtered or
+// allowed through the web UI and the scheduled downtimes, in a SQLite database at
+// Director.DbLocation so the state survives restarts.  The database is only a copy of the
+// in-memory state: it's written when the state changes and read by ConfigFilterdServers.
+
+import (
+	"embed"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+type (
+	// A server filtered or allowed through the web UI
+	serverFilter struct {
+		ServerName string     `gorm:"primaryKey"`
+		FilterType filterType `gorm:"not null"`
+		UpdatedBy  string     `gorm:"not null;default:''"`
+		UpdatedAt  time.Time  `gorm:"not null"`
+	}
+
+	// A scheduled downtime, as stored in the database
+	downtimeRecord struct {
+		ID          string    `gorm:"primaryKey"`
+		ServerName  string    `gorm:"not null"`
+		Description string    `gorm:"not null;default:''"`
+		StartTime   time.Time `gorm:"not null"`
+		EndTime     time.Time `gorm:"not null"`
+		CreatedBy   string    `gorm:"not null;default:''"`
+		CreatedAt   time.Time `gorm:"not null"`
+	}
+)
+
+// The director's database; nil if it isn't initialized, in which case the state the
+// admins change is only kept in memory.  The web handlers use it concurrently with
+// its shutdown, hence the atomic pointer.
+var directorDB atomic.Pointer[gorm.DB]
+
+//go:embed migrations/*.sql
+var embedMigrations embed.FS
+
+func (downtimeRecord) TableName() string {
+	return "downtimes"
+}
+
+func newDowntimeRecord(dt server_structs.Downtime) downtimeRecord {
+	return downtimeRecord(dt)
+}
+
+func (record downtimeRecord) downtime() server_structs.Downtime {
+	return server_structs.Downtime(record)
+}
+
+// Open the director's database at Director.DbLocation, creating it if needed
+func InitializeDirectorDB() error {
+	dbPath := param.Director_DbLocation.GetString()
+	tdb, err := server_utils.InitSQLiteDB(dbPath)
+	if err != nil {
+		return err
+	}
+	sqldb, err := tdb.DB()
+	if err != nil {
+		return errors.Wrapf(err, "failed to get sql.DB from gorm DB: %s", dbPath)
+	}
+	if err := server_utils.MigrateDB(sqldb, embedMigrations); err != nil {
+		return err
+	}
+	directorDB.Store(tdb)
+	return nil
+}
+
+func ShutdownDirectorDB() error {
+	db := directorDB.Swap(nil)
+	if db == nil {
+		return nil
+	}
+	return server_utils.ShutdownDB(db)
+}
+
+// Save the filter the web UI set on a server; an empty filter type removes it
+func saveServerFilter(serverName string, ft filterType, actor string) error {
+	db := directorDB.Load()
+	if db == nil {
+		return nil
+	}
+	if ft == "" {
+		return db.Delete(&serverFilter{}, "server_name = ?", serverName).Error
+	}
+	filter := serverFilter{ServerName: serverName, FilterType: ft, UpdatedBy: actor, UpdatedAt: time.Now().UTC()}
+	return db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&filter).Error
+}
+
+func loadServerFilters() ([]serverFilter, error) {
+	filters := []serverFilter{}
+	db := directorDB.Load()
+	if db == nil {
+		return filters, nil
+	}
+	err := db.Find(&filters).Error
+	return filters, err
+}
+
+// Save scheduled downtimes, all or none of them
+func saveDowntimes(dts []server_structs.Downtime) error {
+	db := directorDB.Load()
+	if db == nil || len(dts) == 0 {
+		return nil
+	}
+	records := make([]downtimeRecord, 0, len(dts))
+	for _, dt := range dts {
+		records = append(records, newDowntimeRecord(dt))
+	}
+	return db.Create(&records).Error
+}
+
+func deleteSavedDowntimes(ids []string) error {
+	db := directorDB.Load()
+	if db == nil || len(ids) == 0 {
+		return nil
+	}
+	return db.Delete(&downtimeRecord{}, "id IN ?", ids).Error
+}
+
+// Load the downtimes that haven't ended, removing the others from the database
+func loadDowntimes(now time.Time) ([]server_structs.Downtime, error) {
+	dts := []server_structs.Downtime{}
+	db := directorDB.Load()
+	if db == nil {
+		return dts, nil
+	}
+	if err := db.Delete(&downtimeRecord{}, "end_time <= ?", now.UTC()).Error; err != nil {
+		log.Warningln("Failed to remove the ended downtimes from the director's database:", err)
+	}
+	records := []downtimeRecord{}
+	if err := db.Where("end_time > ?", now.UTC()).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		dts = append(dts, record.downtime())
+	}
+	return dts, nil
+}
@@ -0,0 +1,114 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+// Forget the in-memory filters and downtimes and reload them, as after a restart
+func restartDirectorState(t *testing.T) {
+	filteredServersMutex.Lock()
+	filteredServers = map[string]filterType{}
+	filteredServersMutex.Unlock()
+	downtimesMutex.Lock()
+	downtimes = map[string]server_structs.Downtime{}
+	downtimesMutex.Unlock()
+	ConfigFilterdServers()
+}
+
+func TestDirectorStatePersistence(t *testing.T) {
+	viper.Reset()
+	viper.Set("Director.DbLocation", filepath.Join(t.TempDir(), "director.sqlite"))
+	viper.Set("Director.FilteredServers", []string{"config-filtered", "config-allowed"})
+	require.NoError(t, InitializeDirectorDB())
+	resetDowntimes(t)
+	t.Cleanup(func() {
+		require.NoError(t, ShutdownDirectorDB())
+		filteredServersMutex.Lock()
+		filteredServers = map[string]filterType{}
+		filteredServersMutex.Unlock()
+		viper.Reset()
+	})
+	restartDirectorState(t)
+
+	router := gin.New()
+	router.PATCH("/servers/filter/*name", handleFilterServer)
+	router.PATCH("/servers/allow/*name", handleAllowServer)
+	request := func(path string) {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodPatch, path, nil)
+		require.NoError(t, err)
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+	request("/servers/filter/ui-filtered")
+	request("/servers/filter/ui-allowed")
+	request("/servers/allow/ui-allowed")
+	request("/servers/allow/config-allowed")
+
+	now := time.Now()
+	_, err := addDowntimes([]server_structs.Downtime{
+		{ServerName: "cache1", StartTime: now.Add(-time.Hour), EndTime: now.Add(time.Hour)},
+		{ServerName: "cache2", StartTime: now.Add(time.Hour), EndTime: now.Add(2 * time.Hour)},
+	}, "admin", now, false)
+	require.NoError(t, err)
+	removed := deleteDowntimes(downtimeFilter{Server: "cache2"}, now)
+	require.Len(t, removed, 1)
+
+	restartDirectorState(t)
+	filtered, ft := checkFilter("ui-filtered")
+	assert.True(t, filtered)
+	assert.Equal(t, tempFiltered, ft)
+	filtered, _ = checkFilter("ui-allowed")
+	assert.False(t, filtered)
+	filtered, ft = checkFilter("config-allowed")
+	assert.False(t, filtered)
+	assert.Equal(t, tempAllowed, ft)
+	filtered, ft = checkFilter("config-filtered")
+	assert.True(t, filtered)
+	assert.Equal(t, permFiltered, ft)
+	filtered, ft = checkFilter("cache1")
+	assert.True(t, filtered)
+	assert.Equal(t, scheduledFiltered, ft)
+	assert.Len(t, listDowntimes(downtimeFilter{}, now), 1)
+
+	t.Run("stale-allowances-are-dropped", func(t *testing.T) {
+		// The server was removed from Director.FilteredServers, so there's nothing to allow
+		viper.Set("Director.FilteredServers", []string{"config-filtered"})
+		restartDirectorState(t)
+		_, ft := checkFilter("config-allowed")
+		assert.Equal(t, filterType(""), ft)
+		filters, err := loadServerFilters()
+		require.NoError(t, err)
+		require.Len(t, filters, 1)
+		assert.Equal(t, "ui-filtered", filters[0].ServerName)
+	})
+}
@@ -25,6 +25,7 @@ import (
 	"crypto/elliptic"
 	"crypto/rand"
 	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
@@ -32,6 +33,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -45,6 +47,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/openapi"
 	"github.com/pelicanplatform/pelican/server_structs"
 	"github.com/pelicanplatform/pelican/server_utils"
 	"github.com/pelicanplatform/pelican/test_utils"
@@ -339,6 +342,42 @@ func TestDirectorRegistration(t *testing.T) {
 		teardown()
 	})
 
+	t.Run("incompatible-protocol-versions", func(t *testing.T) {
+		c, r, w := setupContext()
+		pKey, token, _ := generateToken()
+		publicKey, err := jwk.PublicKeyOf(pKey)
+		assert.NoError(t, err, "Error creating public key from private key")
+
+		setupJwksCache(t, "/foo/bar", publicKey)
+
+		isurl := url.URL{}
+		isurl.Path = ts.URL
+
+		versions := server_structs.GetProtocolVersions()
+		versions.Advertisement = server_structs.VersionRange{Min: "3", Max: "3"}
+		ad := server_structs.OriginAdvertiseV2{
+			DataURL: "https://or-url.org",
+			Name:    "test",
+			Namespaces: []server_structs.NamespaceAdV2{{
+				Path:   "/foo/bar",
+				Issuer: []server_structs.TokenIssuer{{IssuerUrl: isurl}},
+			}},
+			Versions: &versions,
+		}
+
+		jsonad, err := json.Marshal(ad)
+		assert.NoError(t, err, "Error marshalling OriginAdvertise")
+
+		setupRequest(c, r, jsonad, token, server_structs.OriginType)
+
+		r.ServeHTTP(w, c.Request)
+
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+		assert.Contains(t, w.Body.String(), "no common advertisement version")
+		assert.Empty(t, listNamespacesFromOrigins())
+		teardown()
+	})
+
 	// Now repeat the above test, but with an invalid token
 	t.Run("invalid-token-V1", func(t *testing.T) {
 		c, r, w := setupContext()
@@ -746,6 +785,44 @@ func TestGetRequestParameters(t *testing.T) {
 	assert.EqualValues(t, expected, escapedParam)
 }
 
+func TestGetClientCapabilities(t *testing.T) {
+	newRequest := func(headers map[string]string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1.0/director/object/foo/bar", nil)
+		for key, val := range headers {
+			req.Header.Set(key, val)
+		}
+		return req
+	}
+
+	capabilities := getClientCapabilities(newRequest(map[string]string{
+		"User-Agent":                    "pelican-client/7.11.0",
+		"X-Pelican-Client-Capabilities": "version=7.11.0; features=http3",
+	}))
+	assert.Equal(t, server_structs.ClientCapabilities{Version: "7.11.0", Features: server_structs.FeatureHTTP3}, capabilities)
+
+	// Older clients only send their version, but they can all handle packed downloads
+	capabilities = getClientCapabilities(newRequest(map[string]string{"User-Agent": "pelican-client/7.9.1 project/foo"}))
+	assert.Equal(t, server_structs.ClientCapabilities{Version: "7.9.1", Features: server_structs.FeaturePack}, capabilities)
+
+	assert.Equal(t, server_structs.ClientCapabilities{}, getClientCapabilities(newRequest(map[string]string{"User-Agent": "curl/8.4.0"})))
+
+	ads := []server_structs.ServerAd{
+		{Name: "plain"},
+		{Name: "h3-only", RequiredFeatures: server_structs.FeatureHTTP3},
+		{Name: "packed", RequiredFeatures: server_structs.FeaturePack},
+	}
+	names := func(ads []server_structs.ServerAd) (result []string) {
+		for _, ad := range ads {
+			result = append(result, ad.Name)
+		}
+		return
+	}
+	assert.Equal(t, []string{"plain"}, names(filterAdsByClientCapabilities(ads, server_structs.ClientCapabilities{})))
+	assert.Equal(t, []string{"plain", "packed"}, names(filterAdsByClientCapabilities(ads, server_structs.ClientCapabilities{Features: server_structs.FeaturePack})))
+	assert.Equal(t, []string{"plain", "h3-only", "packed"}, names(filterAdsByClientCapabilities(ads,
+		server_structs.ClientCapabilities{Features: server_structs.FeaturePack | server_structs.FeatureHTTP3})))
+}
+
 func TestDiscoverOriginCache(t *testing.T) {
 	mockPelicanOriginServerAd := server_structs.ServerAd{
 		Name: "1-test-origin-server",
@@ -1272,6 +1349,96 @@ func TestRedirects(t *testing.T) {
 		redirectToCache(c)
 		assert.NotContains(t, c.Writer.Header().Get("X-Pelican-Namespace"), "collections-url")
 	})
+
+	t.Run("cache-only-skips-origin-fallback", func(t *testing.T) {
+		viper.Reset()
+		serverAds.DeleteAll()
+		t.Cleanup(func() {
+			viper.Reset()
+			serverAds.DeleteAll()
+		})
+
+		// An origin allowing direct reads with no cache serving its namespace
+		originUrl := url.URL{Scheme: "https", Host: "fallback-origin.org"}
+		serverAds.Set(originUrl.String(), &server_structs.Advertisement{
+			ServerAd: server_structs.ServerAd{Name: "fallback-origin", Type: server_structs.OriginType, URL: originUrl, DirectReads: true},
+			NamespaceAds: []server_structs.NamespaceAdV2{{
+				Path: "/fallback",
+				Caps: server_structs.Capabilities{PublicReads: true, Reads: true, DirectReads: true},
+			}},
+		}, ttlcache.DefaultTTL)
+
+		redirect := func(target string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest("GET", target, nil)
+			req.Header.Add("User-Agent", "pelican-v7.999.999")
+			req.Header.Add("X-Real-Ip", "128.104.153.60")
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			c.Request = req
+			redirectToCache(c)
+			return recorder
+		}
+
+		recorder := redirect("/fallback/object")
+		assert.Equal(t, http.StatusTemporaryRedirect, recorder.Code)
+		assert.Contains(t, recorder.Header().Get("Location"), "fallback-origin.org")
+
+		recorder = redirect("/fallback/object?cacheonly")
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+		assert.Empty(t, recorder.Header().Get("Location"))
+	})
+
+	t.Run("metalink-response", func(t *testing.T) {
+		viper.Reset()
+		serverAds.DeleteAll()
+		t.Cleanup(func() {
+			viper.Reset()
+			serverAds.DeleteAll()
+		})
+
+		topoServer := httptest.NewServer(http.HandlerFunc(JSONHandler))
+		defer topoServer.Close()
+		viper.Set("Federation.TopologyNamespaceUrl", topoServer.URL)
+		viper.Set("Director.CacheSortMethod", "random")
+		err := AdvertiseOSDF(ctx)
+		require.NoError(t, err)
+
+		req, _ := http.NewRequest("GET", "/my/server?metalink", nil)
+		req.Header.Add("User-Agent", "pelican-v7.999.999")
+		req.Header.Add("X-Real-Ip", "128.104.153.60")
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = req
+		redirectToCache(c)
+
+		require.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "application/metalink4+xml", recorder.Header().Get("Content-Type"))
+		assert.NotEmpty(t, recorder.Header().Get("Link"))
+		metalink := Metalink{}
+		require.NoError(t, xml.Unmarshal(recorder.Body.Bytes(), &metalink))
+		require.Len(t, metalink.Files, 1)
+		assert.Equal(t, "server", metalink.Files[0].Name)
+		require.NotEmpty(t, metalink.Files[0].URLs)
+		assert.Equal(t, 1, metalink.Files[0].URLs[0].Priority)
+		assert.Contains(t, recorder.Header().Get("Link"), "<"+metalink.Files[0].URLs[0].URL+">")
+
+		// The JSON flavor is selected through the Accept header
+		req, _ = http.NewRequest("GET", "/my/server", nil)
+		req.Header.Add("User-Agent", "pelican-v7.999.999")
+		req.Header.Add("X-Real-Ip", "128.104.153.60")
+		req.Header.Add("Accept", "application/metalink4+json")
+		recorder = httptest.NewRecorder()
+		c, _ = gin.CreateTestContext(recorder)
+		c.Request = req
+		redirectToCache(c)
+
+		require.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "application/metalink4+json", recorder.Header().Get("Content-Type"))
+		metalink = Metalink{}
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &metalink))
+		require.Len(t, metalink.Files, 1)
+		assert.NotEmpty(t, metalink.Files[0].URLs)
+	})
 }
 
 func TestGetHealthTestFile(t *testing.T) {
@@ -1603,3 +1770,89 @@ func TestGetFinalRedirectURL(t *testing.T) {
 		assert.Equal(t, "https://example.org:8444?key1=val1&key2=val2&raw="+encodedVal, get)
 	})
 }
+
+func TestSetTapeStageUrl(t *testing.T) {
+	diskOrigin := server_structs.ServerAd{Name: "disk", WebURL: url.URL{Scheme: "https", Host: "disk.example.org:8444"}}
+	tapeOrigin := server_structs.ServerAd{Name: "tape", WebURL: url.URL{Scheme: "https", Host: "tape.example.org:8444"}}
+	tapeOrigin.Caps.TapeBacked = true
+
+	t.Run("tape-origin", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		setTapeStageUrl(c, []server_structs.ServerAd{diskOrigin, tapeOrigin})
+		assert.Equal(t, "https://tape.example.org:8444/api/v1.0/origin/stage", w.Header().Get(server_structs.TapeStageUrlHeader))
+	})
+
+	t.Run("no-tape-origin", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		setTapeStageUrl(c, []server_structs.ServerAd{diskOrigin})
+		assert.Empty(t, w.Header().Get(server_structs.TapeStageUrlHeader))
+	})
+}
+
+func TestDirectorOpenAPIDoc(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("Server.ExternalWebUrl", "https://mock-director.com")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	router := gin.New()
+	RegisterDirectorAPI(ctx, &router.RouterGroup)
+	RegisterDirectorWebAPI(&router.RouterGroup)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1.0/director/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	doc := openapi.Document{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	require.Len(t, doc.Servers, 1)
+	assert.Equal(t, "https://mock-director.com", doc.Servers[0].URL)
+
+	t.Run("every-route-is-described", func(t *testing.T) {
+		for _, route := range router.Routes() {
+			// PROPFIND is routed through gin's Any, and the document doesn't describe itself
+			if route.Path == "/api/v1.0/director/origin" || route.Path == "/api/v1.0/director/openapi.json" {
+				continue
+			}
+			path, _ := openapi.ConvertPath(route.Path)
+			require.Contains(t, doc.Paths, path)
+			assert.Contains(t, doc.Paths[path], strings.ToLower(route.Method), "%s %s is missing from the document", route.Method, route.Path)
+		}
+	})
+
+	t.Run("operation-ids-are-unique", func(t *testing.T) {
+		opIds := map[string]bool{}
+		for path, ops := range doc.Paths {
+			for method, op := range ops {
+				assert.False(t, opIds[op.OperationID], "duplicated operation ID %s of %s %s", op.OperationID, method, path)
+				opIds[op.OperationID] = true
+			}
+		}
+	})
+
+	t.Run("models", func(t *testing.T) {
+		op := doc.Paths["/api/v1.0/director_ui/downtime"]["post"]
+		require.NotNil(t, op)
+		require.NotNil(t, op.RequestBody)
+		assert.Equal(t, "#/components/schemas/CreateDowntimeReq", op.RequestBody.Content["application/json"].Schema.Ref)
+		assert.Len(t, op.Security, 2)
+
+		op = doc.Paths["/api/v1.0/director/object/{any}"]["get"]
+		require.NotNil(t, op)
+		assert.Contains(t, op.Responses, "307")
+		require.Len(t, op.Parameters, 1)
+		assert.Equal(t, "path", op.Parameters[0].In)
+		assert.NotEmpty(t, op.Parameters[0].Description)
+
+		op = doc.Paths["/api/v1.0/director_ui/redirects"]["get"]
+		require.NotNil(t, op)
+		params := map[string]string{}
+		for _, param := range op.Parameters {
+			params[param.Name] = param.Schema.Format
+		}
+		assert.Equal(t, "date-time", params["since"])
+		assert.Contains(t, params, "limit")
+	})
+}
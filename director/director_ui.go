@@ -21,14 +21,19 @@ package director
 import (
 	"fmt"
 	"net/http"
+	"net/netip"
 	"net/url"
 	"path"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/openapi"
 	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/token_scopes"
 	"github.com/pelicanplatform/pelican/web_ui"
 	log "github.com/sirupsen/logrus"
 )
@@ -39,20 +44,47 @@ type (
 	}
 
 	listServerResponse struct {
-		Name              string                      `json:"name"`
-		AuthURL           string                      `json:"authUrl"`
-		BrokerURL         string                      `json:"brokerUrl"`
-		URL               string                      `json:"url"`    // This is server's XRootD URL for file transfer
-		WebURL            string                      `json:"webUrl"` // This is server's Web interface and API
-		Type              server_structs.ServerType   `json:"type"`
-		Latitude          float64                     `json:"latitude"`
-		Longitude         float64                     `json:"longitude"`
-		Caps              server_structs.Capabilities `json:"capabilities"`
-		Filtered          bool                        `json:"filtered"`
-		FilteredType      string                      `json:"filteredType"`
-		FromTopology      bool                        `json:"fromTopology"`
-		HealthStatus      HealthTestStatus            `json:"healthStatus"`
-		NamespacePrefixes []string                    `json:"namespacePrefixes"`
+		Name              string                          `json:"name"`
+		AuthURL           string                          `json:"authUrl"`
+		BrokerURL         string                          `json:"brokerUrl"`
+		URL               string                          `json:"url"`    // This is server's XRootD URL for file transfer
+		WebURL            string                          `json:"webUrl"` // This is server's Web interface and API
+		Type              server_structs.ServerType       `json:"type"`
+		Latitude          float64                         `json:"latitude"`
+		Longitude         float64                         `json:"longitude"`
+		Caps              server_structs.Capabilities     `json:"capabilities"`
+		Filtered          bool                            `json:"filtered"`
+		FilteredType      string                          `json:"filteredType"`
+		FromTopology      bool                            `json:"fromTopology"`
+		HealthStatus      HealthTestStatus                `json:"healthStatus"`
+		CertExpiry        *time.Time                      `json:"certExpiry,omitempty"`
+		NamespacePrefixes []string                        `json:"namespacePrefixes"`
+		Versions          server_structs.ProtocolVersions `json:"versions"`
+	}
+
+	listNamespacesRequest struct {
+		Prefix      string `form:"prefix" description:"Only list the namespaces at or under this path"`
+		PublicReads *bool  `form:"public_reads" description:"Only list the namespaces that do, or don't, allow public reads"`
+		Writes      *bool  `form:"writes" description:"Only list the namespaces that do, or don't, accept writes"`
+		Server      string `form:"server" description:"Only list the namespaces served by the origin or cache with this name"`
+		Page        int    `form:"page" description:"The page of results, starting from 1"`
+		PageSize    int    `form:"page_size" description:"The number of namespaces per page; defaults to 100, at most 1000"`
+	}
+
+	// An advertised namespace, with the origins exporting it and the caches serving it
+	namespaceListing struct {
+		Path         string                      `json:"path"`
+		Caps         server_structs.Capabilities `json:"capabilities"`
+		FromTopology bool                        `json:"fromTopology"`
+		Origins      []string                    `json:"origins"`
+		Caches       []string                    `json:"caches"`
+	}
+
+	listNamespacesResponse struct {
+		Namespaces []namespaceListing `json:"namespaces"`
+		Total      int                `json:"total"` // The number of namespaces matching the filters, on all pages
+		Page       int                `json:"page"`
+		PageSize   int                `json:"pageSize"`
 	}
 
 	statRequest struct {
@@ -66,6 +98,11 @@ type (
 	}
 )
 
+const (
+	defaultNamespacePageSize = 100
+	maxNamespacePageSize     = 1000
+)
+
 func (req listServerRequest) ToInternalServerType() server_structs.ServerType {
 	if req.ServerType == "cache" {
 		return server_structs.CacheType
@@ -103,9 +140,14 @@ func listServers(ctx *gin.Context) {
 	resList := make([]listServerResponse, 0)
 	for _, server := range servers {
 		healthStatus := HealthStatusUnknown
+		var certExpiry *time.Time
 		healthUtil, ok := healthTestUtils[server.URL.String()]
 		if ok {
 			healthStatus = healthUtil.Status
+			if !healthUtil.CertExpiry.IsZero() {
+				expiry := healthUtil.CertExpiry
+				certExpiry = &expiry
+			}
 		} else {
 			log.Debugf("listServers: healthTestUtils not found for server at %s", server.URL.String())
 		}
@@ -130,6 +172,8 @@ func listServers(ctx *gin.Context) {
 			FilteredType: ft.String(),
 			FromTopology: server.FromTopology,
 			HealthStatus: healthStatus,
+			CertExpiry:   certExpiry,
+			Versions:     server.Versions,
 		}
 		for _, ns := range server.NamespaceAds {
 			res.NamespacePrefixes = append(res.NamespacePrefixes, ns.Path)
@@ -139,6 +183,69 @@ func listServers(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, resList)
 }
 
+// Whether a namespace listing matches the filters of a request
+func (req listNamespacesRequest) matches(ns namespaceListing) bool {
+	if req.Prefix != "" {
+		prefix := strings.TrimSuffix(req.Prefix, "/") + "/"
+		if !strings.HasPrefix(strings.TrimSuffix(ns.Path, "/")+"/", prefix) {
+			return false
+		}
+	}
+	if req.PublicReads != nil && ns.Caps.PublicReads != *req.PublicReads {
+		return false
+	}
+	if req.Writes != nil && ns.Caps.Writes != *req.Writes {
+		return false
+	}
+	if req.Server != "" && !slices.Contains(ns.Origins, req.Server) && !slices.Contains(ns.Caches, req.Server) {
+		return false
+	}
+	return true
+}
+
+// List the namespaces advertised by the origins, a page at a time, optionally filtered by
+// path prefix, capabilities, and the name of an origin or cache serving them
+func listNamespaces(ctx *gin.Context) {
+	queryParams := listNamespacesRequest{}
+	if err := ctx.ShouldBindQuery(&queryParams); err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprint("Invalid query parameters: ", err),
+		})
+		return
+	}
+	if queryParams.Page == 0 {
+		queryParams.Page = 1
+	}
+	if queryParams.PageSize == 0 {
+		queryParams.PageSize = defaultNamespacePageSize
+	}
+	if queryParams.Page < 1 || queryParams.PageSize < 1 || queryParams.PageSize > maxNamespacePageSize {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("Invalid page; page must be at least 1 and page_size between 1 and %d", maxNamespacePageSize),
+		})
+		return
+	}
+
+	matching := []namespaceListing{}
+	for _, ns := range listNamespaceServers() {
+		if queryParams.matches(ns) {
+			matching = append(matching, ns)
+		}
+	}
+	res := listNamespacesResponse{
+		Namespaces: []namespaceListing{},
+		Total:      len(matching),
+		Page:       queryParams.Page,
+		PageSize:   queryParams.PageSize,
+	}
+	if start := (queryParams.Page - 1) * queryParams.PageSize; start < len(matching) {
+		res.Namespaces = matching[start:min(start+queryParams.PageSize, len(matching))]
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
 // Issue a stat query to origins for an object and return which origins serve the object
 func queryOrigins(ctx *gin.Context) {
 	pathParam := ctx.Param("path")
@@ -215,6 +322,25 @@ func queryOrigins(ctx *gin.Context) {
 	}
 }
 
+// Save the filter type a request set on a server in the director's database, responding
+// with an error if it can't be saved.  Filters from Director.FilteredServers aren't saved,
+// so setting permFiltered removes the saved filter.
+func saveServerFilterForRequest(ctx *gin.Context, serverName string, ft filterType) bool {
+	saved := ft
+	if ft == permFiltered {
+		saved = ""
+	}
+	if err := saveServerFilter(serverName, saved, ctx.GetString("User")); err != nil {
+		log.Errorf("Failed to save the filter of server %s in the director's database: %v", serverName, err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Failed to save the filter of the server",
+		})
+		return false
+	}
+	return true
+}
+
 // A gin route handler that given a server hostname through path variable `name`,
 // checks and adds the server to a list of servers to be bypassed when the director redirects
 // object requests from the client
@@ -239,11 +365,14 @@ func handleFilterServer(ctx *gin.Context) {
 	defer filteredServersMutex.Unlock()
 
 	// If we previously temporarily allowed a server, we switch to permFiltered (reset)
+	newFilterType := tempFiltered
 	if filterType == tempAllowed {
-		filteredServers[sn] = permFiltered
-	} else {
-		filteredServers[sn] = tempFiltered
+		newFilterType = permFiltered
+	}
+	if !saveServerFilterForRequest(ctx, sn, newFilterType) {
+		return
 	}
+	filteredServers[sn] = newFilterType
 	ctx.JSON(http.StatusOK, server_structs.SimpleApiResp{Status: server_structs.RespOK, Msg: "success"})
 }
 
@@ -273,10 +402,22 @@ func handleAllowServer(ctx *gin.Context) {
 
 	if ft == tempFiltered {
 		// For temporarily filtered server, allowing them by removing the server from the map
+		if !saveServerFilterForRequest(ctx, sn, permFiltered) {
+			return
+		}
 		delete(filteredServers, sn)
 	} else if ft == permFiltered {
 		// For servers to filter from the config, temporarily allow the server
+		if !saveServerFilterForRequest(ctx, sn, tempAllowed) {
+			return
+		}
 		filteredServers[sn] = tempAllowed
+	} else if ft == scheduledFiltered {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("Can't allow server %s that is in a scheduled downtime. Remove the downtime instead.", sn),
+		})
+		return
 	} else if ft == topoFiltered {
 		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
 			Status: server_structs.RespFailed,
@@ -287,6 +428,54 @@ func handleAllowServer(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, server_structs.SimpleApiResp{Status: server_structs.RespOK, Msg: "success"})
 }
 
+// A gin route handler that returns the most recent redirect decisions made by the director,
+// newest first, optionally filtered by time range, redirect type, path prefix, client network
+// and server host name
+func listRedirectDecisions(ctx *gin.Context) {
+	queryParams := redirectLogRequest{}
+	if err := ctx.ShouldBindQuery(&queryParams); err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprint("Invalid query parameters: ", err),
+		})
+		return
+	}
+	if queryParams.Type != "" && queryParams.Type != "cache" && queryParams.Type != "origin" {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("Invalid type %q; must be either 'cache' or 'origin'", queryParams.Type),
+		})
+		return
+	}
+	clientNet := netip.Prefix{}
+	if queryParams.Client != "" {
+		var err error
+		if clientNet, err = netip.ParsePrefix(queryParams.Client); err != nil {
+			addr, addrErr := netip.ParseAddr(queryParams.Client)
+			if addrErr != nil {
+				ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+					Status: server_structs.RespFailed,
+					Msg:    fmt.Sprintf("Invalid client %q; must be an IP address or CIDR", queryParams.Client),
+				})
+				return
+			}
+			clientNet = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+		}
+		clientNet = clientNet.Masked()
+	}
+
+	result := []RedirectDecision{}
+	for _, decision := range redirectDecisions.Snapshot() {
+		if queryParams.Limit > 0 && len(result) >= queryParams.Limit {
+			break
+		}
+		if queryParams.matches(decision, clientNet) {
+			result = append(result, decision)
+		}
+	}
+	ctx.JSON(http.StatusOK, result)
+}
+
 // Endpoint for director support contact information
 func handleDirectorContact(ctx *gin.Context) {
 	email := param.Director_SupportContactEmail.GetString()
@@ -295,15 +484,95 @@ func handleDirectorContact(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, supportContactRes{Email: email, Url: url})
 }
 
+var directorWebRoutes = []openapi.Route{
+	{
+		Method: http.MethodGet, Path: "/servers", OperationID: "listServers", Tag: "servers",
+		Summary: "List the origins and caches known to the director",
+		Query:   listServerRequest{}, Response: []listServerResponse{},
+		Handlers: []gin.HandlerFunc{listServers},
+	},
+	{
+		Method: http.MethodPatch, Path: "/servers/filter/*name", OperationID: "filterServer", Tag: "servers",
+		Summary:  "Stop redirecting clients to a server",
+		Security: []string{"loginCookie"}, Response: server_structs.SimpleApiResp{},
+		Handlers: []gin.HandlerFunc{web_ui.AuthHandler, web_ui.AdminAuthHandler, handleFilterServer},
+	},
+	{
+		Method: http.MethodPatch, Path: "/servers/allow/*name", OperationID: "allowServer", Tag: "servers",
+		Summary:  "Resume redirecting clients to a filtered server",
+		Security: []string{"loginCookie"}, Response: server_structs.SimpleApiResp{},
+		Handlers: []gin.HandlerFunc{web_ui.AuthHandler, web_ui.AdminAuthHandler, handleAllowServer},
+	},
+	{
+		Method: http.MethodGet, Path: "/servers/origins/stat/*path", OperationID: "statObject", Tag: "servers",
+		Summary:  "Query the origins for the metadata of an object",
+		Security: []string{"loginCookie"}, Query: statRequest{}, Response: queryResult{},
+		Handlers: []gin.HandlerFunc{web_ui.AuthHandler, queryOrigins},
+	},
+	{
+		Method: http.MethodHead, Path: "/servers/origins/stat/*path", OperationID: "statObjectHead", Tag: "servers",
+		Summary:  "Query the origins for the metadata of an object",
+		Security: []string{"loginCookie"}, Query: statRequest{}, Response: queryResult{},
+		Handlers: []gin.HandlerFunc{web_ui.AuthHandler, queryOrigins},
+	},
+	{
+		Method: http.MethodGet, Path: "/namespaces", OperationID: "listNamespaces", Tag: "namespaces",
+		Summary: "List the namespaces advertised by the origins, a page at a time",
+		Query:   listNamespacesRequest{}, Response: listNamespacesResponse{},
+		Handlers: []gin.HandlerFunc{listNamespaces},
+	},
+	{
+		Method: http.MethodGet, Path: "/catalog", OperationID: "getCatalog", Tag: "namespaces",
+		Summary:  "Get the number of objects and bytes the origins advertise for their namespaces",
+		Response: catalogRes{},
+		Handlers: []gin.HandlerFunc{handleGetCatalog},
+	},
+	{
+		Method: http.MethodGet, Path: "/contact", OperationID: "getSupportContact", Tag: "federation",
+		Summary:  "Get the support contact of the federation",
+		Response: supportContactRes{},
+		Handlers: []gin.HandlerFunc{handleDirectorContact},
+	},
+	{
+		Method: http.MethodGet, Path: "/redirects", OperationID: "listRedirectDecisions", Tag: "redirection",
+		Summary:  "List the most recent redirect decisions of the director, newest first",
+		Security: []string{"loginCookie"}, Query: redirectLogRequest{}, Response: []RedirectDecision{},
+		Handlers: []gin.HandlerFunc{web_ui.AuthHandler, web_ui.AdminAuthHandler, listRedirectDecisions},
+	},
+	{
+		Method: http.MethodGet, Path: "/support_info", OperationID: "getSupportInfo", Tag: "federation",
+		Summary:  "Get a snapshot of the director's state for troubleshooting",
+		Security: []string{"loginCookie", "adminToken"}, Response: SupportInfo{},
+		Handlers: []gin.HandlerFunc{directorAdminHandler(token_scopes.Director_ReadSupportInfo), handleSupportInfo},
+	},
+	{
+		Method: http.MethodGet, Path: "/downtime", OperationID: "listDowntimes", Tag: "downtime",
+		Summary: "List the scheduled server downtimes",
+		Query:   downtimeFilter{}, Response: downtimeRes{},
+		Handlers: []gin.HandlerFunc{handleListDowntimes},
+	},
+	{
+		Method: http.MethodPost, Path: "/downtime", OperationID: "createDowntimes", Tag: "downtime",
+		Summary:  "Schedule server downtimes",
+		Security: []string{"loginCookie", "adminToken"}, Request: createDowntimeReq{}, Response: downtimeRes{},
+		Handlers: []gin.HandlerFunc{downtimeAdminHandler, handleCreateDowntimes},
+	},
+	{
+		Method: http.MethodDelete, Path: "/downtime", OperationID: "deleteDowntimes", Tag: "downtime",
+		Summary:  "Remove the scheduled server downtimes matching the filter",
+		Security: []string{"loginCookie", "adminToken"}, Query: downtimeFilter{}, Response: downtimeRes{},
+		Handlers: []gin.HandlerFunc{downtimeAdminHandler, handleDeleteDowntimes},
+	},
+	{
+		Method: http.MethodDelete, Path: "/downtime/:id", OperationID: "deleteDowntime", Tag: "downtime",
+		Summary:  "Remove a scheduled server downtime",
+		Security: []string{"loginCookie", "adminToken"}, Response: downtimeRes{},
+		Handlers: []gin.HandlerFunc{downtimeAdminHandler, handleDeleteDowntime},
+	},
+}
+
 func RegisterDirectorWebAPI(router *gin.RouterGroup) {
-	directorWebAPI := router.Group("/api/v1.0/director_ui")
+	directorWebAPI := router.Group(directorWebBasePath)
 	// Follow RESTful schema
-	{
-		directorWebAPI.GET("/servers", listServers)
-		directorWebAPI.PATCH("/servers/filter/*name", web_ui.AuthHandler, web_ui.AdminAuthHandler, handleFilterServer)
-		directorWebAPI.PATCH("/servers/allow/*name", web_ui.AuthHandler, web_ui.AdminAuthHandler, handleAllowServer)
-		directorWebAPI.GET("/servers/origins/stat/*path", web_ui.AuthHandler, queryOrigins)
-		directorWebAPI.HEAD("/servers/origins/stat/*path", web_ui.AuthHandler, queryOrigins)
-		directorWebAPI.GET("/contact", handleDirectorContact)
-	}
+	openapi.RegisterRoutes(directorWebAPI, directorWebRoutes)
 }
@@ -22,6 +22,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -168,3 +169,86 @@ func TestListServers(t *testing.T) {
 		require.Equal(t, 400, w.Code)
 	})
 }
+
+func TestListNamespacesAPI(t *testing.T) {
+	router := gin.Default()
+	router.GET("/namespaces", listNamespaces)
+
+	serverAds.DeleteAll()
+	t.Cleanup(serverAds.DeleteAll)
+	origin1 := server_structs.ServerAd{Name: "origin1", Type: server_structs.OriginType, URL: url.URL{Scheme: "https", Host: "origin1.org"}}
+	origin2 := server_structs.ServerAd{Name: "origin2", Type: server_structs.OriginType, URL: url.URL{Scheme: "https", Host: "origin2.org"}}
+	cache1 := server_structs.ServerAd{Name: "cache1", Type: server_structs.CacheType, URL: url.URL{Scheme: "https", Host: "cache1.org"}}
+	serverAds.Set(origin1.URL.String(), &server_structs.Advertisement{
+		ServerAd: origin1,
+		NamespaceAds: []server_structs.NamespaceAdV2{
+			{Path: "/data/public", Caps: server_structs.Capabilities{PublicReads: true, Reads: true}},
+			{Path: "/data/private", Caps: server_structs.Capabilities{Reads: true, Writes: true}},
+		},
+	}, ttlcache.DefaultTTL)
+	serverAds.Set(origin2.URL.String(), &server_structs.Advertisement{
+		ServerAd: origin2,
+		NamespaceAds: []server_structs.NamespaceAdV2{
+			{Path: "/data/public", Caps: server_structs.Capabilities{PublicReads: true, Reads: true}},
+			{Path: "/database", Caps: server_structs.Capabilities{Reads: true}},
+		},
+	}, ttlcache.DefaultTTL)
+	serverAds.Set(cache1.URL.String(), &server_structs.Advertisement{
+		ServerAd:     cache1,
+		NamespaceAds: []server_structs.NamespaceAdV2{{Path: "/data/public"}},
+	}, ttlcache.DefaultTTL)
+
+	list := func(t *testing.T, query string) listNamespacesResponse {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/namespaces"+query, nil)
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		res := listNamespacesResponse{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		return res
+	}
+	paths := func(res listNamespacesResponse) []string {
+		result := []string{}
+		for _, ns := range res.Namespaces {
+			result = append(result, ns.Path)
+		}
+		return result
+	}
+
+	t.Run("all", func(t *testing.T) {
+		res := list(t, "")
+		assert.Equal(t, 3, res.Total)
+		assert.Equal(t, []string{"/data/private", "/data/public", "/database"}, paths(res))
+		assert.Equal(t, []string{"origin1", "origin2"}, res.Namespaces[1].Origins)
+		assert.Equal(t, []string{"cache1"}, res.Namespaces[1].Caches)
+	})
+
+	t.Run("prefix", func(t *testing.T) {
+		assert.Equal(t, []string{"/data/private", "/data/public"}, paths(list(t, "?prefix=/data")))
+		assert.Equal(t, []string{"/data/public"}, paths(list(t, "?prefix=/data/public/")))
+	})
+
+	t.Run("capabilities", func(t *testing.T) {
+		assert.Equal(t, []string{"/data/public"}, paths(list(t, "?public_reads=true")))
+		assert.Equal(t, []string{"/data/public", "/database"}, paths(list(t, "?writes=false")))
+	})
+
+	t.Run("server", func(t *testing.T) {
+		assert.Equal(t, []string{"/data/public", "/database"}, paths(list(t, "?server=origin2")))
+		assert.Equal(t, []string{"/data/public"}, paths(list(t, "?server=cache1")))
+	})
+
+	t.Run("pages", func(t *testing.T) {
+		res := list(t, "?page=2&page_size=2")
+		assert.Equal(t, 3, res.Total)
+		assert.Equal(t, []string{"/database"}, paths(res))
+		assert.Empty(t, paths(list(t, "?page=3&page_size=2")))
+	})
+
+	t.Run("invalid-page", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/namespaces?page_size=5000", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -134,5 +134,6 @@ func federationDiscoveryHandler(ctx *gin.Context) {
 
 func RegisterDirectorOIDCAPI(router *gin.RouterGroup) {
 	router.GET(federationDiscoveryPath, federationDiscoveryHandler)
+	router.GET(config.TrustBundlePath, trustBundleHandler)
 	server_utils.RegisterOIDCAPI(router, true)
 }
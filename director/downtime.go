@@ -0,0 +1,433 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/token"
+	"github.com/pelicanplatform/pelican/token_scopes"
+	"github.com/pelicanplatform/pelican/web_ui"
+)
+
+type (
+	// Query parameters for selecting scheduled downtimes.  All of the set
+	// fields must match.
+	downtimeFilter struct {
+		Server      string `form:"server"`      // A glob pattern matched against the server name
+		Description string `form:"description"` // A substring of the description
+		CreatedBy   string `form:"createdBy"`
+		Status      string `form:"status"` // "active" | "upcoming"
+		All         bool   `form:"all"`    // Required to delete all downtimes without any other filter
+	}
+
+	createDowntimeReq struct {
+		Downtimes []server_structs.Downtime `json:"downtimes"`
+		DryRun    bool                      `json:"dryRun"`
+	}
+
+	downtimeRes struct {
+		Downtimes []server_structs.Downtime `json:"downtimes"`
+		DryRun    bool                      `json:"dryRun,omitempty"`
+	}
+)
+
+var (
+	// Scheduled downtimes, with the key being the downtime ID
+	downtimes      = map[string]server_structs.Downtime{}
+	downtimesMutex = sync.RWMutex{}
+)
+
+const (
+	// Scheduled downtimes are given the "scheduledDowntime" filter type while they're active
+	scheduledFiltered filterType = "scheduledDowntime"
+)
+
+func (f downtimeFilter) isEmpty() bool {
+	return f.Server == "" && f.Description == "" && f.CreatedBy == "" && f.Status == ""
+}
+
+func (f downtimeFilter) validate() error {
+	if f.Server != "" {
+		if _, err := path.Match(f.Server, ""); err != nil {
+			return errors.Wrapf(err, "invalid server pattern %q", f.Server)
+		}
+	}
+	if f.Status != "" && f.Status != string(server_structs.DowntimeActive) && f.Status != string(server_structs.DowntimeUpcoming) {
+		return errors.Errorf("invalid status %q; must be one of %q or %q", f.Status, server_structs.DowntimeActive, server_structs.DowntimeUpcoming)
+	}
+	return nil
+}
+
+func (f downtimeFilter) matches(dt server_structs.Downtime, now time.Time) bool {
+	if f.Server != "" {
+		if matched, _ := path.Match(f.Server, dt.ServerName); !matched {
+			return false
+		}
+	}
+	if f.Description != "" && !strings.Contains(dt.Description, f.Description) {
+		return false
+	}
+	if f.CreatedBy != "" && f.CreatedBy != dt.CreatedBy {
+		return false
+	}
+	if f.Status != "" && string(dt.Status(now)) != f.Status {
+		return false
+	}
+	return true
+}
+
+// Remove downtimes that have ended.  Must be called with downtimesMutex held for writing.
+func pruneEndedDowntimes(now time.Time) {
+	ended := []string{}
+	for id, dt := range downtimes {
+		if dt.Status(now) == server_structs.DowntimeEnded {
+			delete(downtimes, id)
+			ended = append(ended, id)
+		}
+	}
+	if err := deleteSavedDowntimes(ended); err != nil {
+		log.Warningln("Failed to remove the ended downtimes from the director's database:", err)
+	}
+}
+
+// Return the scheduled downtimes matching the filter, ordered by start time
+func listDowntimes(filter downtimeFilter, now time.Time) []server_structs.Downtime {
+	downtimesMutex.RLock()
+	defer downtimesMutex.RUnlock()
+	result := make([]server_structs.Downtime, 0)
+	for _, dt := range downtimes {
+		if dt.Status(now) != server_structs.DowntimeEnded && filter.matches(dt, now) {
+			result = append(result, dt)
+		}
+	}
+	sortDowntimes(result)
+	return result
+}
+
+func sortDowntimes(dts []server_structs.Downtime) {
+	sort.Slice(dts, func(i, j int) bool {
+		if dts[i].StartTime.Equal(dts[j].StartTime) {
+			return dts[i].ServerName < dts[j].ServerName
+		}
+		return dts[i].StartTime.Before(dts[j].StartTime)
+	})
+}
+
+func downtimesOverlap(a, b server_structs.Downtime) bool {
+	return a.ServerName == b.ServerName && a.StartTime.Before(b.EndTime) && b.StartTime.Before(a.EndTime)
+}
+
+// Add a batch of downtimes.  The batch is applied atomically: if any downtime is
+// invalid or overlaps an existing downtime (or another one in the batch) for the
+// same server, none of them are added.  With dryRun, the batch is validated but not added.
+func addDowntimes(reqs []server_structs.Downtime, actor string, now time.Time, dryRun bool) ([]server_structs.Downtime, error) {
+	if len(reqs) == 0 {
+		return nil, errors.New("no downtimes provided")
+	}
+
+	downtimesMutex.Lock()
+	defer downtimesMutex.Unlock()
+	pruneEndedDowntimes(now)
+
+	added := make([]server_structs.Downtime, 0, len(reqs))
+	for idx, req := range reqs {
+		req.ServerName = strings.TrimSpace(req.ServerName)
+		if req.ServerName == "" {
+			return nil, errors.Errorf("downtime %d: server name is required", idx)
+		}
+		if req.StartTime.IsZero() || req.EndTime.IsZero() {
+			return nil, errors.Errorf("downtime %d for %s: start and end times are required", idx, req.ServerName)
+		}
+		if !req.EndTime.After(req.StartTime) {
+			return nil, errors.Errorf("downtime %d for %s: end time %s is not after start time %s", idx, req.ServerName,
+				req.EndTime.Format(time.RFC3339), req.StartTime.Format(time.RFC3339))
+		}
+		if !req.EndTime.After(now) {
+			return nil, errors.Errorf("downtime %d for %s: end time %s is in the past", idx, req.ServerName, req.EndTime.Format(time.RFC3339))
+		}
+		dt := server_structs.Downtime{
+			ID:          uuid.NewString(),
+			ServerName:  req.ServerName,
+			Description: req.Description,
+			StartTime:   req.StartTime.UTC(),
+			EndTime:     req.EndTime.UTC(),
+			CreatedBy:   actor,
+			CreatedAt:   now.UTC(),
+		}
+		for _, existing := range downtimes {
+			if downtimesOverlap(dt, existing) {
+				return nil, errors.Errorf("downtime %d for %s overlaps existing downtime %s (%s to %s)", idx, dt.ServerName,
+					existing.ID, existing.StartTime.Format(time.RFC3339), existing.EndTime.Format(time.RFC3339))
+			}
+		}
+		for prevIdx, prev := range added {
+			if downtimesOverlap(dt, prev) {
+				return nil, errors.Errorf("downtime %d for %s overlaps downtime %d in the same request", idx, dt.ServerName, prevIdx)
+			}
+		}
+		added = append(added, dt)
+	}
+
+	if !dryRun {
+		if err := saveDowntimes(added); err != nil {
+			log.Errorln("Failed to save the downtimes in the director's database:", err)
+			return nil, errors.New("failed to save the downtimes")
+		}
+		for _, dt := range added {
+			downtimes[dt.ID] = dt
+		}
+		log.Infof("%d scheduled downtime(s) added by %s", len(added), actor)
+	}
+	sortDowntimes(added)
+	return added, nil
+}
+
+// Remove the downtimes matching the filter and return them
+func deleteDowntimes(filter downtimeFilter, now time.Time) []server_structs.Downtime {
+	downtimesMutex.Lock()
+	defer downtimesMutex.Unlock()
+	pruneEndedDowntimes(now)
+	removed := make([]server_structs.Downtime, 0)
+	removedIDs := []string{}
+	for id, dt := range downtimes {
+		if filter.matches(dt, now) {
+			removed = append(removed, dt)
+			removedIDs = append(removedIDs, id)
+			delete(downtimes, id)
+		}
+	}
+	if err := deleteSavedDowntimes(removedIDs); err != nil {
+		log.Warningln("Failed to remove the downtimes from the director's database:", err)
+	}
+	sortDowntimes(removed)
+	return removed
+}
+
+// Get the active scheduled downtime of a server, if any
+func getActiveDowntime(serverName string, now time.Time) (server_structs.Downtime, bool) {
+	downtimesMutex.RLock()
+	defer downtimesMutex.RUnlock()
+	for _, dt := range downtimes {
+		if dt.ServerName == serverName && dt.Status(now) == server_structs.DowntimeActive {
+			return dt, true
+		}
+	}
+	return server_structs.Downtime{}, false
+}
+
+// Get the factor, between 0 and 1, by which to scale a server's sorting weight so the
+// server drains before its next scheduled downtime.  The factor is 1 until the server is
+// within Director.DowntimePreDrainDuration of the downtime start, then decreases linearly
+// to 0 at the start.  Servers in an active downtime are filtered out by checkFilter instead.
+func getDowntimeDrainFactor(serverName string, now time.Time) float64 {
+	preDrain := param.Director_DowntimePreDrainDuration.GetDuration()
+	if preDrain <= 0 {
+		return 1
+	}
+	downtimesMutex.RLock()
+	defer downtimesMutex.RUnlock()
+	factor := 1.0
+	for _, dt := range downtimes {
+		if dt.ServerName != serverName || dt.Status(now) != server_structs.DowntimeUpcoming {
+			continue
+		}
+		untilStart := dt.StartTime.Sub(now)
+		if untilStart >= preDrain {
+			continue
+		}
+		if dtFactor := float64(untilStart) / float64(preDrain); dtFactor < factor {
+			factor = dtFactor
+		}
+	}
+	return factor
+}
+
+// A gin middleware for the downtime management APIs
+var downtimeAdminHandler = directorAdminHandler(token_scopes.Director_ManageDowntime)
+
+// Create a gin middleware for the director's administrative APIs.  Accepts either
+// an admin logged in to the director website or a token signed by the director's
+// own key with the given scope, such as one generated on the director host by
+// `pelican downtime create`.
+func directorAdminHandler(scope token_scopes.TokenScope) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		directorAdminAuth(ctx, scope)
+	}
+}
+
+func directorAdminAuth(ctx *gin.Context, scope token_scopes.TokenScope) {
+	if ctx.GetHeader("Authorization") == "" {
+		user, _, err := web_ui.GetUserGroups(ctx)
+		if user == "" {
+			if err != nil {
+				log.Errorln("Invalid user cookie or unable to parse user cookie:", err)
+			}
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Authentication required to perform this operation",
+			})
+			return
+		}
+		if isAdmin, msg := web_ui.CheckAdmin(user); !isAdmin {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    msg,
+			})
+			return
+		}
+		ctx.Set("User", user)
+		ctx.Next()
+		return
+	}
+
+	status, verified, err := token.Verify(ctx, token.AuthOption{
+		Sources: []token.TokenSource{token.Header},
+		Issuers: []token.TokenIssuer{token.LocalIssuer},
+		Scopes:  []token_scopes.TokenScope{scope},
+	})
+	if err != nil || !verified {
+		if status == http.StatusOK {
+			status = http.StatusInternalServerError
+		}
+		msg := "Unknown verification error"
+		if err != nil {
+			msg = err.Error()
+		}
+		ctx.AbortWithStatusJSON(status, server_structs.SimpleApiResp{Status: server_structs.RespFailed, Msg: msg})
+		return
+	}
+	actor := "director-admin-token"
+	tokStr := strings.TrimPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+	if tok, err := jwt.Parse([]byte(tokStr), jwt.WithVerify(false)); err == nil && tok.Subject() != "" {
+		actor = tok.Subject()
+	}
+	ctx.Set("User", actor)
+	ctx.Next()
+}
+
+// GET /downtime
+func handleListDowntimes(ctx *gin.Context) {
+	filter := downtimeFilter{}
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprint("Invalid query parameters: ", err),
+		})
+		return
+	}
+	if err := filter.validate(); err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, downtimeRes{Downtimes: listDowntimes(filter, time.Now())})
+}
+
+// POST /downtime
+func handleCreateDowntimes(ctx *gin.Context) {
+	req := createDowntimeReq{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprint("Invalid request body: ", err),
+		})
+		return
+	}
+	added, err := addDowntimes(req.Downtimes, ctx.GetString("User"), time.Now(), req.DryRun)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, downtimeRes{Downtimes: added, DryRun: req.DryRun})
+}
+
+// DELETE /downtime/:id
+func handleDeleteDowntime(ctx *gin.Context) {
+	id := ctx.Param("id")
+	downtimesMutex.Lock()
+	dt, ok := downtimes[id]
+	if ok {
+		delete(downtimes, id)
+		if err := deleteSavedDowntimes([]string{id}); err != nil {
+			log.Warningf("Failed to remove downtime %s from the director's database: %v", id, err)
+		}
+	}
+	downtimesMutex.Unlock()
+	if !ok {
+		ctx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("Downtime %s not found", id),
+		})
+		return
+	}
+	log.Infof("Scheduled downtime %s for %s removed by %s", dt.ID, dt.ServerName, ctx.GetString("User"))
+	ctx.JSON(http.StatusOK, downtimeRes{Downtimes: []server_structs.Downtime{dt}})
+}
+
+// DELETE /downtime
+//
+// Removes all downtimes matching the filter in the query parameters.  To avoid
+// accidentally removing every downtime, an empty filter requires all=true.
+func handleDeleteDowntimes(ctx *gin.Context) {
+	filter := downtimeFilter{}
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprint("Invalid query parameters: ", err),
+		})
+		return
+	}
+	if err := filter.validate(); err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    err.Error(),
+		})
+		return
+	}
+	if filter.isEmpty() && !filter.All {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "At least one filter is required; set all=true to remove all downtimes",
+		})
+		return
+	}
+	removed := deleteDowntimes(filter, time.Now())
+	log.Infof("%d scheduled downtime(s) removed by %s", len(removed), ctx.GetString("User"))
+	ctx.JSON(http.StatusOK, downtimeRes{Downtimes: removed})
+}
@@ -0,0 +1,265 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+func resetDowntimes(t *testing.T) {
+	downtimesMutex.Lock()
+	downtimes = map[string]server_structs.Downtime{}
+	downtimesMutex.Unlock()
+	t.Cleanup(func() {
+		downtimesMutex.Lock()
+		downtimes = map[string]server_structs.Downtime{}
+		downtimesMutex.Unlock()
+	})
+}
+
+func TestAddDowntimes(t *testing.T) {
+	now := time.Now()
+
+	t.Run("batch-is-added", func(t *testing.T) {
+		resetDowntimes(t)
+		added, err := addDowntimes([]server_structs.Downtime{
+			{ServerName: "cache2", StartTime: now.Add(time.Hour), EndTime: now.Add(2 * time.Hour)},
+			{ServerName: "cache1", StartTime: now.Add(-time.Hour), EndTime: now.Add(time.Hour), Description: "maintenance"},
+		}, "admin", now, false)
+		require.NoError(t, err)
+		require.Len(t, added, 2)
+		// Sorted by start time
+		assert.Equal(t, "cache1", added[0].ServerName)
+		assert.Equal(t, "admin", added[0].CreatedBy)
+		assert.NotEmpty(t, added[0].ID)
+		assert.Len(t, listDowntimes(downtimeFilter{}, now), 2)
+	})
+
+	t.Run("dry-run-does-not-add", func(t *testing.T) {
+		resetDowntimes(t)
+		added, err := addDowntimes([]server_structs.Downtime{
+			{ServerName: "cache1", StartTime: now, EndTime: now.Add(time.Hour)},
+		}, "admin", now, true)
+		require.NoError(t, err)
+		assert.Len(t, added, 1)
+		assert.Empty(t, listDowntimes(downtimeFilter{}, now))
+	})
+
+	t.Run("invalid-downtimes-rejected", func(t *testing.T) {
+		resetDowntimes(t)
+		for name, dt := range map[string]server_structs.Downtime{
+			"no-server":   {StartTime: now, EndTime: now.Add(time.Hour)},
+			"no-end":      {ServerName: "cache1", StartTime: now},
+			"end-first":   {ServerName: "cache1", StartTime: now.Add(time.Hour), EndTime: now},
+			"in-the-past": {ServerName: "cache1", StartTime: now.Add(-2 * time.Hour), EndTime: now.Add(-time.Hour)},
+		} {
+			_, err := addDowntimes([]server_structs.Downtime{dt}, "admin", now, false)
+			assert.Error(t, err, name)
+		}
+		assert.Empty(t, listDowntimes(downtimeFilter{}, now))
+	})
+
+	t.Run("overlap-rejects-whole-batch", func(t *testing.T) {
+		resetDowntimes(t)
+		_, err := addDowntimes([]server_structs.Downtime{
+			{ServerName: "cache1", StartTime: now, EndTime: now.Add(time.Hour)},
+		}, "admin", now, false)
+		require.NoError(t, err)
+
+		_, err = addDowntimes([]server_structs.Downtime{
+			{ServerName: "cache2", StartTime: now, EndTime: now.Add(time.Hour)},
+			{ServerName: "cache1", StartTime: now.Add(30 * time.Minute), EndTime: now.Add(2 * time.Hour)},
+		}, "admin", now, false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "overlaps existing downtime")
+		assert.Len(t, listDowntimes(downtimeFilter{}, now), 1)
+
+		// Overlaps within the same batch are also rejected
+		_, err = addDowntimes([]server_structs.Downtime{
+			{ServerName: "cache2", StartTime: now, EndTime: now.Add(time.Hour)},
+			{ServerName: "cache2", StartTime: now.Add(30 * time.Minute), EndTime: now.Add(2 * time.Hour)},
+		}, "admin", now, false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "in the same request")
+
+		// Back-to-back downtimes don't overlap
+		_, err = addDowntimes([]server_structs.Downtime{
+			{ServerName: "cache1", StartTime: now.Add(time.Hour), EndTime: now.Add(2 * time.Hour)},
+		}, "admin", now, false)
+		assert.NoError(t, err)
+	})
+}
+
+func TestDeleteDowntimesByFilter(t *testing.T) {
+	resetDowntimes(t)
+	now := time.Now()
+	_, err := addDowntimes([]server_structs.Downtime{
+		{ServerName: "cache1.example.org", StartTime: now, EndTime: now.Add(time.Hour), Description: "Site maintenance"},
+		{ServerName: "cache2.example.org", StartTime: now.Add(time.Hour), EndTime: now.Add(2 * time.Hour), Description: "Site maintenance"},
+		{ServerName: "origin.example.org", StartTime: now, EndTime: now.Add(time.Hour), Description: "Kernel upgrade"},
+	}, "admin", now, false)
+	require.NoError(t, err)
+
+	removed := deleteDowntimes(downtimeFilter{Server: "cache*.example.org", Status: "upcoming"}, now)
+	require.Len(t, removed, 1)
+	assert.Equal(t, "cache2.example.org", removed[0].ServerName)
+
+	removed = deleteDowntimes(downtimeFilter{Description: "maintenance"}, now)
+	require.Len(t, removed, 1)
+	assert.Equal(t, "cache1.example.org", removed[0].ServerName)
+
+	remaining := listDowntimes(downtimeFilter{}, now)
+	require.Len(t, remaining, 1)
+	assert.Equal(t, "origin.example.org", remaining[0].ServerName)
+}
+
+func TestCheckFilterScheduledDowntime(t *testing.T) {
+	resetDowntimes(t)
+	filteredServersMutex.Lock()
+	filteredServers = map[string]filterType{}
+	filteredServersMutex.Unlock()
+
+	now := time.Now()
+	_, err := addDowntimes([]server_structs.Downtime{
+		{ServerName: "active-cache", StartTime: now.Add(-time.Minute), EndTime: now.Add(time.Hour)},
+		{ServerName: "upcoming-cache", StartTime: now.Add(time.Hour), EndTime: now.Add(2 * time.Hour)},
+	}, "admin", now, false)
+	require.NoError(t, err)
+
+	filtered, ft := checkFilter("active-cache")
+	assert.True(t, filtered)
+	assert.Equal(t, scheduledFiltered, ft)
+
+	filtered, _ = checkFilter("upcoming-cache")
+	assert.False(t, filtered)
+}
+
+func TestDowntimeHandlers(t *testing.T) {
+	resetDowntimes(t)
+	router := gin.Default()
+	router.GET("/downtime", handleListDowntimes)
+	router.POST("/downtime", handleCreateDowntimes)
+	router.DELETE("/downtime", handleDeleteDowntimes)
+	router.DELETE("/downtime/:id", handleDeleteDowntime)
+
+	now := time.Now()
+	doRequest := func(method, target string, body interface{}) (*httptest.ResponseRecorder, downtimeRes) {
+		var reqBody bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&reqBody).Encode(body))
+		}
+		req := httptest.NewRequest(method, target, &reqBody)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		res := downtimeRes{}
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		}
+		return w, res
+	}
+
+	w, res := doRequest("POST", "/downtime", createDowntimeReq{Downtimes: []server_structs.Downtime{
+		{ServerName: "cache1", StartTime: now, EndTime: now.Add(time.Hour)},
+		{ServerName: "cache2", StartTime: now, EndTime: now.Add(time.Hour)},
+	}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Len(t, res.Downtimes, 2)
+	firstID := res.Downtimes[0].ID
+
+	w, res = doRequest("GET", "/downtime?server=cache2", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, res.Downtimes, 1)
+	assert.Equal(t, "cache2", res.Downtimes[0].ServerName)
+
+	w, _ = doRequest("GET", "/downtime?status=ended", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// An unfiltered delete requires all=true
+	w, _ = doRequest("DELETE", "/downtime", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, res = doRequest("DELETE", "/downtime/"+firstID, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, res.Downtimes, 1)
+	assert.Equal(t, firstID, res.Downtimes[0].ID)
+
+	w, _ = doRequest("DELETE", "/downtime/"+firstID, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w, res = doRequest("DELETE", "/downtime?all=true", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, res.Downtimes, 1)
+	assert.Empty(t, listDowntimes(downtimeFilter{}, now))
+}
+
+func TestDowntimeDrain(t *testing.T) {
+	resetDowntimes(t)
+	t.Cleanup(func() {
+		viper.Reset()
+	})
+	viper.Set("Director.DowntimePreDrainDuration", "1h")
+
+	now := time.Now()
+	_, err := addDowntimes([]server_structs.Downtime{
+		{ServerName: "draining-cache", StartTime: now.Add(30 * time.Minute), EndTime: now.Add(2 * time.Hour)},
+		{ServerName: "later-cache", StartTime: now.Add(3 * time.Hour), EndTime: now.Add(4 * time.Hour)},
+		{ServerName: "almost-down-cache", StartTime: now.Add(time.Second), EndTime: now.Add(time.Hour)},
+	}, "admin", now, false)
+	require.NoError(t, err)
+
+	t.Run("drain-factor", func(t *testing.T) {
+		assert.InDelta(t, 0.5, getDowntimeDrainFactor("draining-cache", now), 0.001)
+		assert.Equal(t, 1.0, getDowntimeDrainFactor("later-cache", now))
+		assert.Equal(t, 1.0, getDowntimeDrainFactor("other-cache", now))
+
+		viper.Set("Director.DowntimePreDrainDuration", "0s")
+		assert.Equal(t, 1.0, getDowntimeDrainFactor("draining-cache", now))
+		viper.Set("Director.DowntimePreDrainDuration", "1h")
+	})
+
+	t.Run("apply-drain-factor", func(t *testing.T) {
+		assert.Equal(t, 0.8, applyDrainFactor(0.8, 1))
+		assert.InDelta(t, 0.4, applyDrainFactor(0.8, 0.5), 0.001)
+		assert.InDelta(t, -0.75, applyDrainFactor(-0.25, 0.5), 0.001)
+	})
+
+	t.Run("draining-server-sorted-last", func(t *testing.T) {
+		viper.Set("Director.CacheSortMethod", "random")
+		ads := []server_structs.ServerAd{{Name: "almost-down-cache"}, {Name: "cache1"}, {Name: "cache2"}}
+		for i := 0; i < 10; i++ {
+			sorted, err := sortServerAdsByIP(netip.MustParseAddr("192.0.2.1"), ads)
+			require.NoError(t, err)
+			require.Len(t, sorted, 3)
+			assert.Equal(t, "almost-down-cache", sorted[2].Name)
+		}
+	})
+}
@@ -0,0 +1,242 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/param"
+)
+
+type (
+	// The method of the client geolocation fallback chain that located a client
+	clientGeoMethod string
+
+	ClientLocationMapping struct {
+		CIDR       string     `mapstructure:"CIDR"`
+		Coordinate Coordinate `mapstructure:"Coordinate"`
+	}
+
+	clientLocationPrefix struct {
+		prefix     netip.Prefix
+		coordinate Coordinate
+	}
+
+	// A cached answer of the RTT probe service; failures are cached too so a
+	// client the service can't locate doesn't delay each of its redirects
+	rttProbeResult struct {
+		coordinate Coordinate
+		ok         bool
+	}
+
+	rttProbeRes struct {
+		Lat  *float64 `json:"lat"`
+		Long *float64 `json:"long"`
+	}
+)
+
+const (
+	clientGeoOverride    clientGeoMethod = "override"
+	clientGeoGeoIP       clientGeoMethod = "geoip"
+	clientGeoRTTProbe    clientGeoMethod = "rtt_probe"
+	clientGeoLocationMap clientGeoMethod = "location_map"
+	clientGeoUnresolved  clientGeoMethod = "unresolved"
+
+	rttProbeSuccessTTL = time.Hour
+	rttProbeFailureTTL = 5 * time.Minute
+)
+
+var (
+	rttProbeResults = ttlcache.New[netip.Addr, rttProbeResult](
+		ttlcache.WithDisableTouchOnHit[netip.Addr, rttProbeResult](),
+	)
+
+	// Director.ClientLocationMap, parsed on first use
+	clientLocationMap atomic.Pointer[[]clientLocationPrefix]
+)
+
+func validCoordinate(coord Coordinate) bool {
+	return !(coord.Lat == 0 && coord.Long == 0) &&
+		coord.Lat >= -90 && coord.Lat <= 90 && coord.Long >= -180 && coord.Long <= 180
+}
+
+// Locate a client with the GeoIP database, rejecting the null location and, if
+// Director.GeoIPMaxAccuracyRadius is set, results that are too coarse to sort by
+func getClientGeoIPLocation(addr netip.Addr) (coord Coordinate, ok bool) {
+	record, err := lookupGeoIP(addr)
+	if err != nil {
+		log.Debugf("GeoIP lookup of the client address %s failed: %v", addr, err)
+		return
+	}
+	coord = Coordinate{Lat: record.Location.Latitude, Long: record.Location.Longitude}
+	if !validCoordinate(coord) {
+		log.Debugf("GeoIP resolution of the client address %s resulted in the null lat/long", addr)
+		return Coordinate{}, false
+	}
+	maxRadius := param.Director_GeoIPMaxAccuracyRadius.GetInt()
+	if maxRadius > 0 && int(record.Location.AccuracyRadius) > maxRadius {
+		log.Debugf("GeoIP resolution of the client address %s has an accuracy radius of %d km, more than the %d km of Director.GeoIPMaxAccuracyRadius",
+			addr, record.Location.AccuracyRadius, maxRadius)
+		return Coordinate{}, false
+	}
+	return coord, true
+}
+
+// Ask the service at Director.RTTProbeUrl to locate a client
+func queryRTTProbe(ctx context.Context, probeUrl string, addr netip.Addr) (coord Coordinate, err error) {
+	reqUrl, err := url.Parse(probeUrl)
+	if err != nil {
+		return coord, errors.Wrap(err, "failed to parse Director.RTTProbeUrl")
+	}
+	query := reqUrl.Query()
+	query.Set("ip", addr.String())
+	reqUrl.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqUrl.String(), nil)
+	if err != nil {
+		return coord, err
+	}
+	req.Header.Set("User-Agent", "pelican-director/"+config.GetVersion())
+	client := http.Client{Transport: config.GetTransport()}
+	resp, err := client.Do(req)
+	if err != nil {
+		return coord, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return coord, errors.Wrap(err, "failed to read the response of the RTT probe service")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return coord, errors.Errorf("the RTT probe service responded with status code %d", resp.StatusCode)
+	}
+	res := rttProbeRes{}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return coord, errors.Wrap(err, "failed to parse the response of the RTT probe service")
+	}
+	if res.Lat == nil || res.Long == nil {
+		return coord, errors.New("the RTT probe service did not return a location")
+	}
+	coord = Coordinate{Lat: *res.Lat, Long: *res.Long}
+	if !validCoordinate(coord) {
+		return Coordinate{}, errors.Errorf("the RTT probe service returned an invalid location %f:%f", coord.Lat, coord.Long)
+	}
+	return coord, nil
+}
+
+// Locate a client with the RTT probe service, if configured, caching the result
+func getClientRTTProbeLocation(addr netip.Addr) (coord Coordinate, ok bool) {
+	probeUrl := param.Director_RTTProbeUrl.GetString()
+	if probeUrl == "" {
+		return
+	}
+	if item := rttProbeResults.Get(addr); item != nil {
+		return item.Value().coordinate, item.Value().ok
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), param.Director_RTTProbeTimeout.GetDuration())
+	defer cancel()
+	coord, err := queryRTTProbe(ctx, probeUrl, addr)
+	if err != nil {
+		log.Debugf("The RTT probe service failed to locate the client address %s: %v", addr, err)
+		rttProbeResults.Set(addr, rttProbeResult{}, rttProbeFailureTTL)
+		return Coordinate{}, false
+	}
+	rttProbeResults.Set(addr, rttProbeResult{coordinate: coord, ok: true}, rttProbeSuccessTTL)
+	return coord, true
+}
+
+func loadClientLocationMap() []clientLocationPrefix {
+	if prefixes := clientLocationMap.Load(); prefixes != nil {
+		return *prefixes
+	}
+	mappings := []ClientLocationMapping{}
+	if err := param.Director_ClientLocationMap.Unmarshal(&mappings); err != nil {
+		log.Warningf("Error while unmarshaling Director.ClientLocationMap: %v", err)
+	}
+	prefixes := make([]clientLocationPrefix, 0, len(mappings))
+	for _, mapping := range mappings {
+		prefix, err := netip.ParsePrefix(mapping.CIDR)
+		if err != nil {
+			log.Warningf("Failed to parse the Director.ClientLocationMap network %q: %v. Unable to use it for client geolocation!", mapping.CIDR, err)
+			continue
+		}
+		prefixes = append(prefixes, clientLocationPrefix{prefix: prefix.Masked(), coordinate: mapping.Coordinate})
+	}
+	clientLocationMap.Store(&prefixes)
+	return prefixes
+}
+
+// Locate a client with the most specific network of Director.ClientLocationMap containing it
+func getClientMappedLocation(addr netip.Addr) (coord Coordinate, ok bool) {
+	addr = addr.Unmap()
+	bestBits := -1
+	for _, entry := range loadClientLocationMap() {
+		if entry.prefix.Contains(addr) && entry.prefix.Bits() > bestBits {
+			bestBits = entry.prefix.Bits()
+			coord = entry.coordinate
+			ok = true
+		}
+	}
+	return
+}
+
+// Locate a client for sorting servers by distance.  The configured GeoIPOverrides
+// win; otherwise GeoIP is tried, then the RTT probe service, and finally the
+// Director.ClientLocationMap.  The method that located the client is counted in
+// the pelican_director_client_geolocations_total metric.
+func getClientLatLong(addr netip.Addr) (coord Coordinate, ok bool) {
+	method := clientGeoUnresolved
+	defer func() {
+		metrics.PelicanDirectorClientGeolocations.WithLabelValues(string(method)).Inc()
+	}()
+
+	if override := checkOverrides(net.IP(addr.AsSlice())); override != nil {
+		method = clientGeoOverride
+		return *override, true
+	}
+	if coord, ok = getClientGeoIPLocation(addr); ok {
+		method = clientGeoGeoIP
+		return
+	}
+	if coord, ok = getClientRTTProbeLocation(addr); ok {
+		method = clientGeoRTTProbe
+		return
+	}
+	if coord, ok = getClientMappedLocation(addr); ok {
+		method = clientGeoLocationMap
+		return
+	}
+	log.Warningf("Failed to resolve the lat/long of the client address %s with any geolocation method", addr)
+	return
+}
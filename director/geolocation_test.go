@@ -0,0 +1,131 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/metrics"
+)
+
+func setClientLocationMap(t *testing.T, mappings []map[string]interface{}) {
+	viper.Set("Director.ClientLocationMap", mappings)
+	clientLocationMap.Store(nil)
+	t.Cleanup(func() { clientLocationMap.Store(nil) })
+}
+
+func TestGetClientMappedLocation(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	setClientLocationMap(t, []map[string]interface{}{
+		{"CIDR": "10.0.0.0/8", "Coordinate": map[string]float64{"Lat": 1, "Long": 1}},
+		{"CIDR": "10.1.0.0/16", "Coordinate": map[string]float64{"Lat": 2, "Long": 2}},
+		{"CIDR": "not-a-network", "Coordinate": map[string]float64{"Lat": 3, "Long": 3}},
+		{"CIDR": "fd00::/8", "Coordinate": map[string]float64{"Lat": 4, "Long": 4}},
+	})
+
+	for addr, expected := range map[string]Coordinate{
+		"10.2.3.4":        {Lat: 1, Long: 1},
+		"10.1.3.4":        {Lat: 2, Long: 2}, // The most specific network wins
+		"::ffff:10.1.3.4": {Lat: 2, Long: 2},
+		"fd12::1":         {Lat: 4, Long: 4},
+	} {
+		coord, ok := getClientMappedLocation(netip.MustParseAddr(addr))
+		require.True(t, ok, addr)
+		assert.Equal(t, expected, coord, addr)
+	}
+	_, ok := getClientMappedLocation(netip.MustParseAddr("192.168.1.1"))
+	assert.False(t, ok)
+}
+
+func TestClientGeolocationFallback(t *testing.T) {
+	viper.Reset()
+	reader := maxMindReader.Swap(nil)
+	overrides := geoIPOverrides
+	geoIPOverrides = nil
+	t.Cleanup(func() {
+		viper.Reset()
+		maxMindReader.Store(reader)
+		geoIPOverrides = overrides
+		rttProbeResults.DeleteAll()
+	})
+
+	var probes atomic.Int32
+	probeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+		switch r.URL.Query().Get("ip") {
+		case "10.0.0.1":
+			_, _ = w.Write([]byte(`{"lat": 43.07, "long": -89.38}`))
+		case "10.0.0.2":
+			// The null location is as good as no location
+			_, _ = w.Write([]byte(`{"lat": 0, "long": 0}`))
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(probeServer.Close)
+	viper.Set("Director.RTTProbeUrl", probeServer.URL+"/locate")
+	viper.Set("Director.RTTProbeTimeout", "5s")
+	setClientLocationMap(t, []map[string]interface{}{
+		{"CIDR": "10.0.0.0/24", "Coordinate": map[string]float64{"Lat": 51.51, "Long": -0.12}},
+	})
+
+	count := func(method clientGeoMethod) float64 {
+		return testutil.ToFloat64(metrics.PelicanDirectorClientGeolocations.WithLabelValues(string(method)))
+	}
+	probed, mapped, unresolved := count(clientGeoRTTProbe), count(clientGeoLocationMap), count(clientGeoUnresolved)
+
+	// Without a GeoIP database, the probe service locates the client
+	coord, ok := getClientLatLong(netip.MustParseAddr("10.0.0.1"))
+	require.True(t, ok)
+	assert.Equal(t, Coordinate{Lat: 43.07, Long: -89.38}, coord)
+	assert.Equal(t, probed+1, count(clientGeoRTTProbe))
+
+	// The probe result is cached
+	_, ok = getClientLatLong(netip.MustParseAddr("10.0.0.1"))
+	require.True(t, ok)
+	assert.Equal(t, int32(1), probes.Load())
+
+	// When the probe can't locate the client, the location map is used
+	coord, ok = getClientLatLong(netip.MustParseAddr("10.0.0.2"))
+	require.True(t, ok)
+	assert.Equal(t, Coordinate{Lat: 51.51, Long: -0.12}, coord)
+	coord, ok = getClientLatLong(netip.MustParseAddr("10.0.0.3"))
+	require.True(t, ok)
+	assert.Equal(t, Coordinate{Lat: 51.51, Long: -0.12}, coord)
+	assert.Equal(t, mapped+2, count(clientGeoLocationMap))
+
+	// Failed probes are cached too
+	_, ok = getClientLatLong(netip.MustParseAddr("10.0.0.3"))
+	require.True(t, ok)
+	assert.Equal(t, int32(3), probes.Load())
+
+	_, ok = getClientLatLong(netip.MustParseAddr("192.168.0.1"))
+	assert.False(t, ok)
+	assert.Equal(t, unresolved+1, count(clientGeoUnresolved))
+}
@@ -0,0 +1,110 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"math"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/web_ui"
+)
+
+const (
+	// The rate of data, in bytes per second, each origin and cache transferred over the
+	// last minute, as scraped by the director's Prometheus
+	serverIOLoadQuery = `sum by (server_url) (rate(xrootd_server_bytes[1m]))`
+
+	// The load assumed for servers whose load isn't known, between idle and saturated
+	unknownServerLoad = 0.5
+)
+
+// The latest IO load of each server, in bytes per second, by server URL
+var serverIOLoads atomic.Pointer[map[string]float64]
+
+// Query the director's Prometheus for the IO load of the servers
+func queryServerIOLoads(ctx context.Context) error {
+	vector, err := web_ui.QueryPrometheus(ctx, serverIOLoadQuery, time.Now())
+	if err != nil {
+		return err
+	}
+	loads := make(map[string]float64, len(vector))
+	for _, sample := range vector {
+		if serverUrl := sample.Metric.Get("server_url"); serverUrl != "" && !math.IsNaN(sample.F) {
+			loads[serverUrl] = sample.F
+		}
+	}
+	serverIOLoads.Store(&loads)
+	return nil
+}
+
+// Periodically query the IO load of the servers, used to sort them by load
+func LaunchServerIOQuery(ctx context.Context, egrp *errgroup.Group) {
+	interval := param.Director_IOLoadQueryInterval.GetDuration()
+	if interval <= 0 {
+		log.Debugln("Querying the IO load of the servers is disabled")
+		return
+	}
+	egrp.Go(func() error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+				if err := queryServerIOLoads(ctx); err != nil {
+					log.Debugln("Failed to query the IO load of the servers:", err)
+				}
+			}
+		}
+	})
+}
+
+// Get the load of a server between 0, idle, and 1, saturated: the rate of data it
+// transfers relative to its advertised capacity.  Returns false if either is unknown.
+func getServerLoad(ad server_structs.ServerAd) (float64, bool) {
+	loads := serverIOLoads.Load()
+	if loads == nil || ad.IOCapacity <= 0 {
+		return 0, false
+	}
+	bytesPerSec, ok := (*loads)[ad.URL.String()]
+	if !ok {
+		return 0, false
+	}
+	capacityBytesPerSec := float64(ad.IOCapacity) * 1e6 / 8
+	return math.Max(0, math.Min(1, bytesPerSec/capacityBytesPerSec)), true
+}
+
+// Get the weights of distance and load in sorting by both, from Director.SortDistanceWeight
+// and Director.SortLoadWeight, normalized to sum to 1
+func getDistanceAndLoadWeights() (distanceWeight float64, loadWeight float64) {
+	distance := math.Max(0, float64(param.Director_SortDistanceWeight.GetInt()))
+	load := math.Max(0, float64(param.Director_SortLoadWeight.GetInt()))
+	if distance+load == 0 {
+		return 1.0 / 3.0, 2.0 / 3.0
+	}
+	return distance / (distance + load), load / (distance + load)
+}
@@ -0,0 +1,173 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// A Metalink document describing the replicas of an object
+	//
+	// Ref: https://www.rfc-editor.org/rfc/rfc5854.html
+	Metalink struct {
+		XMLName   xml.Name       `xml:"urn:ietf:params:xml:ns:metalink metalink" json:"-"`
+		Generator string         `xml:"generator" json:"generator"`
+		Published time.Time      `xml:"published" json:"published"`
+		Files     []MetalinkFile `xml:"file" json:"files"`
+	}
+
+	MetalinkFile struct {
+		Name   string         `xml:"name,attr" json:"name"`
+		Size   int            `xml:"size,omitempty" json:"size,omitempty"`
+		Hashes []MetalinkHash `xml:"hash,omitempty" json:"hashes,omitempty"`
+		URLs   []MetalinkURL  `xml:"url" json:"urls"`
+	}
+
+	MetalinkHash struct {
+		Type  string `xml:"type,attr" json:"type"`
+		Value string `xml:",chardata" json:"value"`
+	}
+
+	MetalinkURL struct {
+		Priority int    `xml:"priority,attr" json:"priority"` // Lower values are preferred
+		URL      string `xml:",chardata" json:"url"`
+	}
+
+	metalinkFormat string
+)
+
+const (
+	metalinkNone metalinkFormat = ""
+	metalinkXML  metalinkFormat = "xml"
+	metalinkJSON metalinkFormat = "json"
+
+	metalinkXMLContentType  = "application/metalink4+xml"
+	metalinkJSONContentType = "application/metalink4+json"
+)
+
+// Determine if the client asked for a Metalink document instead of a redirect.
+//
+// Clients opt in either through the `metalink` query parameter (optionally
+// set to `json`) or by accepting the Metalink media type.
+func getMetalinkFormat(req *http.Request) metalinkFormat {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return metalinkNone
+	}
+	if req.URL.Query().Has("metalink") {
+		if strings.EqualFold(req.URL.Query().Get("metalink"), "json") {
+			return metalinkJSON
+		}
+		return metalinkXML
+	}
+	for _, accept := range req.Header.Values("Accept") {
+		for _, mediaType := range strings.Split(accept, ",") {
+			mediaType, _, _ = strings.Cut(mediaType, ";")
+			switch strings.TrimSpace(mediaType) {
+			case metalinkXMLContentType:
+				return metalinkXML
+			case metalinkJSONContentType:
+				return metalinkJSON
+			}
+		}
+	}
+	return metalinkNone
+}
+
+// Convert the value of a Digest header (RFC 3230), such as `crc32c=f1a2b3c4`,
+// into Metalink hashes
+func getMetalinkHashes(digest string) (hashes []MetalinkHash) {
+	for _, instance := range strings.Split(digest, ",") {
+		hashType, value, found := strings.Cut(strings.TrimSpace(instance), "=")
+		if !found || hashType == "" || value == "" {
+			continue
+		}
+		hashes = append(hashes, MetalinkHash{Type: strings.ToLower(hashType), Value: value})
+	}
+	return
+}
+
+// Build the Metalink document for an object given the ordered list of
+// servers that can serve it and, if known, the object's metadata
+func newMetalink(reqPath string, urls []url.URL, meta *objectMetadata) Metalink {
+	file := MetalinkFile{
+		Name: path.Base(reqPath),
+		URLs: make([]MetalinkURL, 0, len(urls)),
+	}
+	for idx, serverUrl := range urls {
+		file.URLs = append(file.URLs, MetalinkURL{Priority: idx + 1, URL: serverUrl.String()})
+	}
+	if meta != nil {
+		file.Size = meta.ContentLength
+		file.Hashes = getMetalinkHashes(meta.Checksum)
+	}
+	return Metalink{
+		Generator: "Pelican/" + config.GetVersion(),
+		Published: time.Now().UTC().Truncate(time.Second),
+		Files:     []MetalinkFile{file},
+	}
+}
+
+// Look up the size and checksum of an object from its origins.  Caches don't
+// report checksums, so this is used to fill in the Metalink for cache redirects.
+func statObjectForMetalink(reqPath, token string, originAds []server_structs.ServerAd) *objectMetadata {
+	if len(originAds) == 0 {
+		return nil
+	}
+	q := NewObjectStat()
+	qr := q.Query(context.Background(), reqPath, config.OriginType, 1, 1,
+		withOriginAds(originAds), WithToken(token))
+	if qr.Status != querySuccessful || len(qr.Objects) == 0 {
+		log.Debugf("Unable to determine object metadata for the Metalink of %s: %s", reqPath, qr.Msg)
+		return nil
+	}
+	return qr.Objects[0]
+}
+
+// Respond with a Metalink document in place of a redirect
+func writeMetalink(ginCtx *gin.Context, format metalinkFormat, reqPath string, urls []url.URL, meta *objectMetadata) {
+	metalink := newMetalink(reqPath, urls, meta)
+	if format == metalinkJSON {
+		ginCtx.Header("Content-Type", metalinkJSONContentType)
+		ginCtx.JSON(http.StatusOK, metalink)
+		return
+	}
+	body, err := xml.MarshalIndent(metalink, "", "  ")
+	if err != nil {
+		log.Errorln("Failed to generate Metalink document:", err)
+		ginCtx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Failed to generate Metalink document",
+		})
+		return
+	}
+	ginCtx.Data(http.StatusOK, metalinkXMLContentType, append([]byte(xml.Header), body...))
+}
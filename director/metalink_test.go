@@ -0,0 +1,89 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetMetalinkFormat(t *testing.T) {
+	tests := []struct {
+		method string
+		target string
+		accept string
+		format metalinkFormat
+	}{
+		{method: http.MethodGet, target: "/foo/bar", format: metalinkNone},
+		{method: http.MethodGet, target: "/foo/bar?metalink", format: metalinkXML},
+		{method: http.MethodGet, target: "/foo/bar?metalink=JSON", format: metalinkJSON},
+		{method: http.MethodHead, target: "/foo/bar?metalink=xml", format: metalinkXML},
+		{method: http.MethodGet, target: "/foo/bar", accept: "text/html, application/metalink4+xml;q=0.9", format: metalinkXML},
+		{method: http.MethodGet, target: "/foo/bar", accept: "application/metalink4+json", format: metalinkJSON},
+		{method: http.MethodGet, target: "/foo/bar", accept: "application/json", format: metalinkNone},
+		{method: http.MethodPut, target: "/foo/bar?metalink", format: metalinkNone},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.target, nil)
+		if test.accept != "" {
+			req.Header.Set("Accept", test.accept)
+		}
+		assert.Equal(t, test.format, getMetalinkFormat(req), "%s %s (Accept: %s)", test.method, test.target, test.accept)
+	}
+}
+
+func TestNewMetalink(t *testing.T) {
+	assert.Equal(t, []MetalinkHash{{Type: "crc32c", Value: "f1a2b3c4"}, {Type: "md5", Value: "abc=="}},
+		getMetalinkHashes("crc32c=f1a2b3c4, MD5=abc=="))
+	assert.Empty(t, getMetalinkHashes(""))
+	assert.Empty(t, getMetalinkHashes("garbage"))
+
+	urls := []url.URL{
+		{Scheme: "https", Host: "cache1.example.com:8443", Path: "/foo/bar/baz.txt"},
+		{Scheme: "https", Host: "cache2.example.com", Path: "/foo/bar/baz.txt"},
+	}
+	metalink := newMetalink("/foo/bar/baz.txt", urls, &objectMetadata{ContentLength: 42, Checksum: "crc32c=f1a2b3c4"})
+	require.Len(t, metalink.Files, 1)
+	file := metalink.Files[0]
+	assert.Equal(t, "baz.txt", file.Name)
+	assert.Equal(t, 42, file.Size)
+	assert.Equal(t, []MetalinkHash{{Type: "crc32c", Value: "f1a2b3c4"}}, file.Hashes)
+	assert.Equal(t, []MetalinkURL{
+		{Priority: 1, URL: "https://cache1.example.com:8443/foo/bar/baz.txt"},
+		{Priority: 2, URL: "https://cache2.example.com/foo/bar/baz.txt"},
+	}, file.URLs)
+
+	body, err := xml.Marshal(metalink)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `<metalink xmlns="urn:ietf:params:xml:ns:metalink">`)
+	assert.Contains(t, string(body), `<file name="baz.txt"><size>42</size><hash type="crc32c">f1a2b3c4</hash>`)
+	assert.Contains(t, string(body), `<url priority="2">https://cache2.example.com/foo/bar/baz.txt</url>`)
+
+	// Without object metadata, size and hashes are omitted
+	body, err = xml.Marshal(newMetalink("/foo/bar/baz.txt", urls[:1], nil))
+	require.NoError(t, err)
+	assert.NotContains(t, string(body), "<size>")
+	assert.NotContains(t, string(body), "<hash")
+}
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE server_filters (
+    server_name TEXT PRIMARY KEY NOT NULL,
+    filter_type TEXT NOT NULL,
+    updated_by TEXT NOT NULL DEFAULT '',
+    updated_at DATETIME NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE downtimes (
+    id TEXT PRIMARY KEY NOT NULL,
+    server_name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    start_time DATETIME NOT NULL,
+    end_time DATETIME NOT NULL,
+    created_by TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS server_filters;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS downtimes;
+-- +goose StatementEnd
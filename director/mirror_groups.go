@@ -0,0 +1,279 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// A mirror group is scoped to the namespace its origins export
+	mirrorGroupKey struct {
+		namespace string
+		group     string
+	}
+
+	// An origin of a mirror group, with the priority of its copy
+	mirrorOrigin struct {
+		ad       server_structs.ServerAd
+		priority int
+	}
+)
+
+var (
+	// The objects recently read from each mirror group, most recent last; these are the
+	// objects spot checked across the mirrors of the group
+	mirrorSamples      = map[mirrorGroupKey][]string{}
+	mirrorSamplesMutex = sync.Mutex{}
+)
+
+// Get the namespace ad an origin advertised for the namespace prefix, which carries the
+// mirror group of the origin's copy
+func getOriginNamespaceAd(ad server_structs.ServerAd, prefix string) (server_structs.NamespaceAdV2, bool) {
+	item := serverAds.Get(ad.URL.String())
+	if item == nil {
+		return server_structs.NamespaceAdV2{}, false
+	}
+	for _, ns := range item.Value().NamespaceAds {
+		if ns.Path == prefix {
+			return ns, true
+		}
+	}
+	return server_structs.NamespaceAdV2{}, false
+}
+
+// Order the origins of each mirror group of the namespace by priority.  The mirrors of a
+// group keep the positions the group's origins had in the sorted ads, so the other origins
+// and the other groups aren't moved, and mirrors with equal priorities keep their order.
+func sortOriginsByMirrorPriority(namespaceAd server_structs.NamespaceAdV2, ads []server_structs.ServerAd) []server_structs.ServerAd {
+	groups := map[string][]int{}
+	priorities := make([]int, len(ads))
+	for idx, ad := range ads {
+		ns, ok := getOriginNamespaceAd(ad, namespaceAd.Path)
+		if !ok || ns.MirrorGroup == "" {
+			continue
+		}
+		groups[ns.MirrorGroup] = append(groups[ns.MirrorGroup], idx)
+		priorities[idx] = ns.MirrorPriority
+	}
+	if len(groups) == 0 {
+		return ads
+	}
+
+	result := make([]server_structs.ServerAd, len(ads))
+	copy(result, ads)
+	for _, positions := range groups {
+		members := make([]int, len(positions))
+		copy(members, positions)
+		sort.SliceStable(members, func(i, j int) bool { return priorities[members[i]] < priorities[members[j]] })
+		for idx, pos := range positions {
+			result[pos] = ads[members[idx]]
+		}
+	}
+	return result
+}
+
+// Remember an object read from the namespace as a sample of each of the mirror groups
+// serving it, keeping the Director.MirrorCheckSampleSize most recent distinct objects
+func recordMirrorSample(namespaceAd server_structs.NamespaceAdV2, ads []server_structs.ServerAd, objectPath string) {
+	sampleSize := param.Director_MirrorCheckSampleSize.GetInt()
+	if sampleSize <= 0 || param.Director_MirrorCheckInterval.GetDuration() <= 0 {
+		return
+	}
+	keys := map[mirrorGroupKey]bool{}
+	for _, ad := range ads {
+		if ns, ok := getOriginNamespaceAd(ad, namespaceAd.Path); ok && ns.MirrorGroup != "" {
+			keys[mirrorGroupKey{namespace: namespaceAd.Path, group: ns.MirrorGroup}] = true
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+
+	mirrorSamplesMutex.Lock()
+	defer mirrorSamplesMutex.Unlock()
+	for key := range keys {
+		samples := mirrorSamples[key]
+		for idx, sample := range samples {
+			if sample == objectPath {
+				samples = append(samples[:idx], samples[idx+1:]...)
+				break
+			}
+		}
+		samples = append(samples, objectPath)
+		if len(samples) > sampleSize {
+			samples = samples[len(samples)-sampleSize:]
+		}
+		mirrorSamples[key] = samples
+	}
+}
+
+// Get the origins currently advertising each mirror group, in order of priority, along with
+// whether the namespace of the group can be read without a token
+func getMirrorGroups() (map[mirrorGroupKey][]mirrorOrigin, map[mirrorGroupKey]bool) {
+	groups := map[mirrorGroupKey][]mirrorOrigin{}
+	public := map[mirrorGroupKey]bool{}
+	for _, item := range serverAds.Items() {
+		ad := item.Value()
+		if ad.Type != server_structs.OriginType {
+			continue
+		}
+		if filtered, _ := checkFilter(ad.Name); filtered {
+			continue
+		}
+		for _, ns := range ad.NamespaceAds {
+			if ns.MirrorGroup == "" {
+				continue
+			}
+			key := mirrorGroupKey{namespace: ns.Path, group: ns.MirrorGroup}
+			groups[key] = append(groups[key], mirrorOrigin{ad: ad.ServerAd, priority: ns.MirrorPriority})
+			public[key] = ns.Caps.PublicReads
+		}
+	}
+	for _, mirrors := range groups {
+		sort.SliceStable(mirrors, func(i, j int) bool {
+			if mirrors[i].priority != mirrors[j].priority {
+				return mirrors[i].priority < mirrors[j].priority
+			}
+			return mirrors[i].ad.URL.String() < mirrors[j].ad.URL.String()
+		})
+	}
+	return groups, public
+}
+
+// Compare an object across the mirrors of a group, returning why it diverges, if it does.
+// Mirrors that can't be queried are skipped, as they may be down rather than out of sync.
+func checkMirrorObject(ctx context.Context, objectPath string, mirrors []mirrorOrigin) (string, bool) {
+	timeout := param.Director_StatTimeout.GetDuration()
+	var reference *objectMetadata
+	var referenceName string
+	missing := []string{}
+	for _, mirror := range mirrors {
+		meta, err := headObject(ctx, objectPath, mirror.ad.URL, false, "", timeout, false)
+		if err != nil {
+			if _, ok := err.(headReqNotFoundErr); ok {
+				missing = append(missing, mirror.ad.Name)
+			} else {
+				log.Debugf("Failed to query mirror %s for %s: %v", mirror.ad.Name, objectPath, err)
+			}
+			continue
+		}
+		if reference == nil {
+			reference, referenceName = meta, mirror.ad.Name
+			continue
+		}
+		if meta.ContentLength != reference.ContentLength {
+			return fmt.Sprintf("its size is %d bytes on %s but %d bytes on %s", reference.ContentLength, referenceName, meta.ContentLength, mirror.ad.Name), true
+		}
+		if !meta.LastModified.IsZero() && !reference.LastModified.IsZero() && !meta.LastModified.Equal(reference.LastModified) {
+			return fmt.Sprintf("it was modified at %s on %s but at %s on %s", reference.LastModified.Format(time.RFC3339), referenceName,
+				meta.LastModified.Format(time.RFC3339), mirror.ad.Name), true
+		}
+	}
+	if reference != nil && len(missing) > 0 {
+		return fmt.Sprintf("it exists on %s but is missing from %s", referenceName, strings.Join(missing, ", ")), true
+	}
+	return "", false
+}
+
+// Spot check the recently read objects of each mirror group across its mirrors, reporting
+// the groups whose copies diverge
+func checkMirrorDivergence(ctx context.Context) {
+	groups, public := getMirrorGroups()
+
+	mirrorSamplesMutex.Lock()
+	samples := map[mirrorGroupKey][]string{}
+	for key, objects := range mirrorSamples {
+		if _, ok := groups[key]; !ok {
+			// The group is no longer advertised
+			delete(mirrorSamples, key)
+			continue
+		}
+		samples[key] = append([]string{}, objects...)
+	}
+	mirrorSamplesMutex.Unlock()
+
+	metrics.PelicanDirectorMirrorDivergentObjects.Reset()
+	diverged := []string{}
+	for key, mirrors := range groups {
+		if len(mirrors) < 2 || len(samples[key]) == 0 {
+			continue
+		}
+		if !public[key] {
+			log.Debugf("Skipping the sync check of mirror group %q of namespace %s: the namespace requires a token to read", key.group, key.namespace)
+			continue
+		}
+		divergent := 0
+		for _, objectPath := range samples[key] {
+			if ctx.Err() != nil {
+				return
+			}
+			if reason, ok := checkMirrorObject(ctx, objectPath, mirrors); ok {
+				divergent++
+				log.Warningf("Object %s is out of sync between the mirrors of group %q of namespace %s: %s", objectPath, key.group, key.namespace, reason)
+			}
+		}
+		metrics.PelicanDirectorMirrorDivergentObjects.WithLabelValues(key.namespace, key.group).Set(float64(divergent))
+		if divergent > 0 {
+			diverged = append(diverged, fmt.Sprintf("%s (%s)", key.group, key.namespace))
+		}
+	}
+
+	if len(diverged) > 0 {
+		sort.Strings(diverged)
+		metrics.SetComponentHealthStatus(metrics.Director_MirrorSync, metrics.StatusWarning,
+			"The mirrors of the following groups are out of sync: "+strings.Join(diverged, ", "))
+	} else {
+		metrics.SetComponentHealthStatus(metrics.Director_MirrorSync, metrics.StatusOK, "")
+	}
+}
+
+// Periodically check that the origins of each mirror group stay in sync, unless
+// Director.MirrorCheckInterval is 0
+func LaunchMirrorDivergenceChecks(ctx context.Context, egrp *errgroup.Group) {
+	interval := param.Director_MirrorCheckInterval.GetDuration()
+	if interval <= 0 {
+		log.Debugln("Checking the sync of mirror groups is disabled")
+		return
+	}
+	egrp.Go(func() error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+				checkMirrorDivergence(ctx)
+			}
+		}
+	})
+}
@@ -0,0 +1,180 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+// Advertise an origin exporting /mirrored as part of a mirror group
+func setMirrorOriginAd(name string, serverUrl url.URL, group string, priority int) server_structs.ServerAd {
+	ad := server_structs.ServerAd{Name: name, Type: server_structs.OriginType, URL: serverUrl}
+	serverAds.Set(serverUrl.String(), &server_structs.Advertisement{
+		ServerAd: ad,
+		NamespaceAds: []server_structs.NamespaceAdV2{{
+			Path:           "/mirrored",
+			Caps:           server_structs.Capabilities{PublicReads: true, Reads: true},
+			MirrorGroup:    group,
+			MirrorPriority: priority,
+		}},
+	}, ttlcache.DefaultTTL)
+	return ad
+}
+
+func TestSortOriginsByMirrorPriority(t *testing.T) {
+	t.Cleanup(func() { serverAds.DeleteAll() })
+	namespaceAd := server_structs.NamespaceAdV2{Path: "/mirrored"}
+
+	primary := setMirrorOriginAd("primary", url.URL{Scheme: "https", Host: "primary.org"}, "replicas", 0)
+	secondary := setMirrorOriginAd("secondary", url.URL{Scheme: "https", Host: "secondary.org"}, "replicas", 1)
+	tertiary := setMirrorOriginAd("tertiary", url.URL{Scheme: "https", Host: "tertiary.org"}, "replicas", 2)
+	standalone := setMirrorOriginAd("standalone", url.URL{Scheme: "https", Host: "standalone.org"}, "", 0)
+
+	t.Run("mirrors-keep-the-group-positions", func(t *testing.T) {
+		sorted := sortOriginsByMirrorPriority(namespaceAd, []server_structs.ServerAd{tertiary, standalone, secondary, primary})
+		assert.Equal(t, []server_structs.ServerAd{primary, standalone, secondary, tertiary}, sorted)
+	})
+
+	t.Run("no-mirrors", func(t *testing.T) {
+		ads := []server_structs.ServerAd{standalone}
+		assert.Equal(t, ads, sortOriginsByMirrorPriority(namespaceAd, ads))
+	})
+
+	t.Run("other-namespace", func(t *testing.T) {
+		ads := []server_structs.ServerAd{tertiary, primary}
+		assert.Equal(t, ads, sortOriginsByMirrorPriority(server_structs.NamespaceAdV2{Path: "/other"}, ads))
+	})
+}
+
+func TestRecordMirrorSample(t *testing.T) {
+	viper.Reset()
+	viper.Set("Director.MirrorCheckInterval", "10m")
+	viper.Set("Director.MirrorCheckSampleSize", 2)
+	t.Cleanup(func() {
+		viper.Reset()
+		serverAds.DeleteAll()
+		mirrorSamplesMutex.Lock()
+		mirrorSamples = map[mirrorGroupKey][]string{}
+		mirrorSamplesMutex.Unlock()
+	})
+	namespaceAd := server_structs.NamespaceAdV2{Path: "/mirrored"}
+	primary := setMirrorOriginAd("primary", url.URL{Scheme: "https", Host: "primary.org"}, "replicas", 0)
+	standalone := setMirrorOriginAd("standalone", url.URL{Scheme: "https", Host: "standalone.org"}, "", 0)
+
+	for _, object := range []string{"/mirrored/a", "/mirrored/b", "/mirrored/a", "/mirrored/c"} {
+		recordMirrorSample(namespaceAd, []server_structs.ServerAd{primary, standalone}, object)
+	}
+	recordMirrorSample(namespaceAd, []server_structs.ServerAd{standalone}, "/mirrored/d")
+
+	mirrorSamplesMutex.Lock()
+	defer mirrorSamplesMutex.Unlock()
+	// The most recent distinct objects are kept, and only for mirror groups
+	assert.Equal(t, map[mirrorGroupKey][]string{
+		{namespace: "/mirrored", group: "replicas"}: {"/mirrored/a", "/mirrored/c"},
+	}, mirrorSamples)
+}
+
+func TestCheckMirrorDivergence(t *testing.T) {
+	viper.Reset()
+	viper.Set("Director.MirrorCheckInterval", "10m")
+	viper.Set("Director.MirrorCheckSampleSize", 10)
+	viper.Set("Director.StatTimeout", "5s")
+	t.Cleanup(func() {
+		viper.Reset()
+		serverAds.DeleteAll()
+		mirrorSamplesMutex.Lock()
+		mirrorSamples = map[mirrorGroupKey][]string{}
+		mirrorSamplesMutex.Unlock()
+		metrics.PelicanDirectorMirrorDivergentObjects.Reset()
+	})
+
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	// Each mirror serves objects of the given sizes and modification times
+	newMirror := func(objects map[string]int, mtimes map[string]time.Time) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			size, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			mtime := modified
+			if other, ok := mtimes[r.URL.Path]; ok {
+				mtime = other
+			}
+			w.Header().Set("Last-Modified", mtime.Format(http.TimeFormat))
+			w.Header().Set("Content-Length", strconv.Itoa(size))
+			w.WriteHeader(http.StatusOK)
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	primary := newMirror(map[string]int{"/mirrored/same": 1, "/mirrored/size": 2, "/mirrored/mtime": 3, "/mirrored/missing": 4}, nil)
+	secondary := newMirror(map[string]int{"/mirrored/same": 1, "/mirrored/size": 5, "/mirrored/mtime": 3},
+		map[string]time.Time{"/mirrored/mtime": modified.Add(time.Hour)})
+	primaryUrl, err := url.Parse(primary.URL)
+	require.NoError(t, err)
+	secondaryUrl, err := url.Parse(secondary.URL)
+	require.NoError(t, err)
+	primaryAd := setMirrorOriginAd("primary", *primaryUrl, "replicas", 0)
+	setMirrorOriginAd("secondary", *secondaryUrl, "replicas", 1)
+	namespaceAd := server_structs.NamespaceAdV2{Path: "/mirrored"}
+
+	t.Run("in-sync", func(t *testing.T) {
+		recordMirrorSample(namespaceAd, []server_structs.ServerAd{primaryAd}, "/mirrored/same")
+		checkMirrorDivergence(context.Background())
+		assert.Equal(t, 0.0, testutil.ToFloat64(metrics.PelicanDirectorMirrorDivergentObjects.WithLabelValues("/mirrored", "replicas")))
+		status, err := metrics.GetComponentStatus(metrics.Director_MirrorSync)
+		require.NoError(t, err)
+		assert.Equal(t, metrics.StatusOK.String(), status)
+	})
+
+	t.Run("diverged", func(t *testing.T) {
+		for _, object := range []string{"/mirrored/size", "/mirrored/mtime", "/mirrored/missing"} {
+			recordMirrorSample(namespaceAd, []server_structs.ServerAd{primaryAd}, object)
+		}
+		checkMirrorDivergence(context.Background())
+		assert.Equal(t, 3.0, testutil.ToFloat64(metrics.PelicanDirectorMirrorDivergentObjects.WithLabelValues("/mirrored", "replicas")))
+		status, err := metrics.GetComponentStatus(metrics.Director_MirrorSync)
+		require.NoError(t, err)
+		assert.Equal(t, metrics.StatusWarning.String(), status)
+	})
+
+	t.Run("retired-groups-are-forgotten", func(t *testing.T) {
+		serverAds.DeleteAll()
+		checkMirrorDivergence(context.Background())
+		mirrorSamplesMutex.Lock()
+		defer mirrorSamplesMutex.Unlock()
+		assert.Empty(t, mirrorSamples)
+	})
+}
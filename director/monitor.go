@@ -132,9 +132,53 @@ func reportStatusToServer(ctx context.Context, serverWebUrl string, status strin
 	return nil
 }
 
+// The bounds of the interval between the health tests of a server, which backs off while
+// the server keeps passing its tests and shrinks once it fails one
+type healthTestIntervals struct {
+	base     time.Duration // Director.OriginCacheHealthTestInterval
+	shortest time.Duration // Director.HealthTestMinInterval, for failing servers
+	longest  time.Duration // Director.HealthTestMaxInterval, for healthy servers
+}
+
+func getHealthTestIntervals() healthTestIntervals {
+	intervals := healthTestIntervals{
+		base:     param.Director_OriginCacheHealthTestInterval.GetDuration(),
+		shortest: param.Director_HealthTestMinInterval.GetDuration(),
+		longest:  param.Director_HealthTestMaxInterval.GetDuration(),
+	}
+	if intervals.base < 15*time.Second {
+		log.Warningf("You set Director.OriginCacheHealthTestInterval to a very small number %s, which will cause high traffic volume to xrootd servers.", intervals.base.String())
+	}
+	if intervals.base <= 0 {
+		intervals.base = 15 * time.Second
+		log.Error("Invalid config value: Director.OriginCacheHealthTestInterval is 0. Fallback to 15s.")
+	}
+	if intervals.shortest <= 0 || intervals.shortest > intervals.base {
+		intervals.shortest = intervals.base
+	}
+	if intervals.longest < intervals.base {
+		intervals.longest = intervals.base
+	}
+	return intervals
+}
+
+// Get the interval until the next health test of a server given the current interval and
+// whether the server passed its last test
+func (intervals healthTestIntervals) next(current time.Duration, passed bool) time.Duration {
+	if !passed {
+		return intervals.shortest
+	}
+	// A recovered server is tested at the base interval before backing off again
+	if current < intervals.base {
+		return intervals.base
+	}
+	return min(2*current, intervals.longest)
+}
+
 // Run a periodic test file transfer against an origin to ensure
 // it's talking to the director
 func LaunchPeriodicDirectorTest(ctx context.Context, serverAd server_structs.ServerAd) {
+	defer metrics.TrackGoroutine("director-health-test")()
 	serverName := serverAd.Name
 	serverUrl := serverAd.URL.String()
 	serverWebUrl := serverAd.WebURL.String()
@@ -151,20 +195,18 @@ func LaunchPeriodicDirectorTest(ctx context.Context, serverAd server_structs.Ser
 			"server_name": serverName, "server_web_url": serverWebUrl, "server_type": string(serverAd.Type),
 		}).Inc()
 
-	customInterval := param.Director_OriginCacheHealthTestInterval.GetDuration()
-	if customInterval < 15*time.Second {
-		log.Warningf("You set Director.OriginCacheHealthTestInterval to a very small number %s, which will cause high traffic volume to xrootd servers.", customInterval.String())
-	}
-	if customInterval == 0 {
-		customInterval = 15 * time.Second
-		log.Error("Invalid config value: Director.OriginCacheHealthTestInterval is 0. Fallback to 15s.")
-	}
-	ticker := time.NewTicker(customInterval)
+	intervals := getHealthTestIntervals()
+	interval := intervals.base
+	ticker := time.NewTicker(interval)
+	trigger := getHealthTestTrigger(serverUrl)
 
 	defer ticker.Stop()
 
 	for {
 		select {
+		case <-trigger:
+			// Run the next test cycle right away, e.g. for a readmitted server
+			ticker.Reset(time.Millisecond)
 		case <-ctx.Done():
 			log.Debug(fmt.Sprintf("End director test suite for %s server %s at %s", serverAd.Type, serverName, serverUrl))
 
@@ -172,10 +214,16 @@ func LaunchPeriodicDirectorTest(ctx context.Context, serverAd server_structs.Ser
 				prometheus.Labels{
 					"server_name": serverName, "server_web_url": serverWebUrl, "server_type": string(serverAd.Type),
 				}).Dec()
+			metrics.PelicanDirectorServerCertExpiry.Delete(
+				prometheus.Labels{
+					"server_name": serverName, "server_web_url": serverWebUrl, "server_type": string(serverAd.Type),
+				})
 
 			return
 		case <-ticker.C:
+			ticker.Reset(interval)
 			log.Debug(fmt.Sprintf("Starting a director test cycle for %s server %s at %s", serverAd.Type, serverName, serverUrl))
+			updateServerCertExpiry(ctx, serverAd)
 			ok := true
 			var err error
 			if serverAd.Type == server_structs.OriginType {
@@ -185,8 +233,10 @@ func LaunchPeriodicDirectorTest(ctx context.Context, serverAd server_structs.Ser
 				err = runCacheTest(ctx, serverAd.URL)
 			}
 
+			passed := ok && err == nil
+
 			// Successfully run a test, no error
-			if ok && err == nil {
+			if passed {
 				log.Debugf("Director file transfer test cycle succeeded at %s for %s server with URL at %s", time.Now().Format(time.RFC3339), serverAd.Type, serverUrl)
 				func() {
 					healthTestUtilsMutex.Lock()
@@ -197,6 +247,7 @@ func LaunchPeriodicDirectorTest(ctx context.Context, serverAd server_structs.Ser
 						log.Debugln("HealthTestUtil missing for ", serverAd.Type, " server: ", serverUrl, " Failed to update internal status")
 					}
 				}()
+				endReadmission(serverUrl)
 
 				// Report error back to origin/server
 				if err := reportStatusToServer(
@@ -345,6 +396,11 @@ func LaunchPeriodicDirectorTest(ctx context.Context, serverAd server_structs.Ser
 				}
 			}
 
+			if next := intervals.next(interval, passed); next != interval {
+				log.Debugf("Next director test cycle for %s server %s in %s", serverAd.Type, serverUrl, next)
+				interval = next
+				ticker.Reset(interval)
+			}
 		}
 	}
 }
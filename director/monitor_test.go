@@ -0,0 +1,60 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthTestIntervals(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("Director.OriginCacheHealthTestInterval", "15s")
+	viper.Set("Director.HealthTestMinInterval", "5s")
+	viper.Set("Director.HealthTestMaxInterval", "1m")
+	intervals := getHealthTestIntervals()
+
+	// A healthy server backs off up to the longest interval
+	interval := intervals.base
+	for _, expected := range []time.Duration{30 * time.Second, time.Minute, time.Minute} {
+		interval = intervals.next(interval, true)
+		assert.Equal(t, expected, interval)
+	}
+	// A failing server is probed more often until it recovers
+	interval = intervals.next(interval, false)
+	assert.Equal(t, 5*time.Second, interval)
+	interval = intervals.next(interval, false)
+	assert.Equal(t, 5*time.Second, interval)
+	interval = intervals.next(interval, true)
+	assert.Equal(t, 15*time.Second, interval)
+
+	t.Run("bounds-are-clamped-to-the-base-interval", func(t *testing.T) {
+		viper.Set("Director.HealthTestMinInterval", "1m")
+		viper.Set("Director.HealthTestMaxInterval", "5s")
+		intervals := getHealthTestIntervals()
+		assert.Equal(t, 15*time.Second, intervals.shortest)
+		assert.Equal(t, 15*time.Second, intervals.longest)
+		assert.Equal(t, 15*time.Second, intervals.next(intervals.base, true))
+		assert.Equal(t, 15*time.Second, intervals.next(intervals.base, false))
+	})
+}
@@ -0,0 +1,259 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/utils"
+)
+
+type bulkKeyEntry struct {
+	etag string
+	keys jwk.Set
+}
+
+var (
+	// The namespace keys from the registry's bulk listing, keyed by jwks URL like namespaceKeys
+	bulkKeys     = map[string]bulkKeyEntry{}
+	bulkKeysETag string
+	bulkKeysLock sync.Mutex
+
+	errBulkKeysUnsupported = errors.New("the registry doesn't support listing the namespace keys in bulk")
+
+	// Concurrent lookups of the same uncached keys share a single fetch
+	namespaceKeysLoader = ttlcache.NewSuppressedLoader[string, jwk.Set](ttlcache.LoaderFunc[string, jwk.Set](loadNamespaceKeys), new(singleflight.Group))
+
+	// The errors of recently failed key fetches, so verifications against an unreachable
+	// issuer fail fast rather than each retrying the fetch
+	namespaceKeysFailures = ttlcache.New(
+		ttlcache.WithTTL[string, error](namespaceKeysFailureTTL),
+		ttlcache.WithDisableTouchOnHit[string, error](),
+	)
+
+	// The keys looked up since they were last fetched; only these are renewed
+	namespaceKeysUsed sync.Map
+)
+
+const (
+	// How long a failed key fetch is remembered
+	namespaceKeysFailureTTL = time.Minute
+	// The deadline of fetching the keys of one issuer
+	namespaceKeysFetchTimeout = 30 * time.Second
+)
+
+// Returns how long the director trusts the namespace keys it fetched
+func namespaceKeysTTL() time.Duration {
+	if ttl := param.Director_AdvertisementTTL.GetDuration(); ttl > 0 {
+		return ttl
+	}
+	return 15 * time.Minute
+}
+
+// Fetch the keys at keyLoc into namespaceKeys; called through namespaceKeysLoader
+func loadNamespaceKeys(c *ttlcache.Cache[string, jwk.Set], keyLoc string) *ttlcache.Item[string, jwk.Set] {
+	if item := namespaceKeysFailures.Get(keyLoc); item != nil {
+		return nil
+	}
+	// Don't tie the shared fetch to the context of whichever request started it
+	ctx, cancel := context.WithTimeout(context.Background(), namespaceKeysFetchTimeout)
+	defer cancel()
+	keyset, err := utils.GetJwks(ctx, keyLoc)
+	if err != nil {
+		namespaceKeysFailures.Set(keyLoc, errors.Wrapf(err, "failed to get jwks at %s", keyLoc), ttlcache.DefaultTTL)
+		return nil
+	}
+	namespaceKeysFailures.Delete(keyLoc)
+	return c.Set(keyLoc, keyset, namespaceKeysTTL())
+}
+
+// Get the keys at keyLoc, fetching them if they aren't cached
+func getNamespaceKeys(keyLoc string) (jwk.Set, error) {
+	namespaceKeysUsed.Store(keyLoc, true)
+	if item := namespaceKeys.Get(keyLoc, ttlcache.WithLoader[string, jwk.Set](namespaceKeysLoader)); item != nil {
+		return item.Value(), nil
+	}
+	if item := namespaceKeysFailures.Get(keyLoc); item != nil {
+		return nil, item.Value()
+	}
+	return nil, errors.Errorf("failed to get jwks at %s", keyLoc)
+}
+
+// Re-fetch the keys in use that expire within the window, so verifications don't
+// stall on fetching them once they expire
+func renewNamespaceKeys(window time.Duration) {
+	for keyLoc, item := range namespaceKeys.Items() {
+		if time.Until(item.ExpiresAt()) > window {
+			continue
+		}
+		if _, used := namespaceKeysUsed.LoadAndDelete(keyLoc); !used {
+			continue
+		}
+		log.Debugln("Renewing the namespace keys at", keyLoc)
+		// A failed renewal leaves the current keys in place until they expire
+		namespaceKeysFailures.Delete(keyLoc)
+		namespaceKeysLoader.Load(namespaceKeys, keyLoc)
+	}
+}
+
+// Fetch the keys of all the namespaces from the registry in a single request and
+// store them in namespaceKeys.  The previous listing's ETag is sent so an unchanged
+// listing costs the registry nothing more than a 304, and only the keys whose
+// per-entry ETag changed are parsed again.
+func refreshNamespaceKeys(ctx context.Context) error {
+	fedInfo, err := config.GetFederation(ctx)
+	if err != nil {
+		return err
+	}
+	if fedInfo.NamespaceRegistrationEndpoint == "" {
+		return errors.New("federation registry URL is not set and was not discovered")
+	}
+	reqUrl, err := url.JoinPath(fedInfo.NamespaceRegistrationEndpoint, "api", "v1.0", "registry", ".well-known", "namespace-keys")
+	if err != nil {
+		return errors.Wrap(err, "failed to construct the namespace key listing URL")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqUrl, nil)
+	if err != nil {
+		return err
+	}
+
+	bulkKeysLock.Lock()
+	defer bulkKeysLock.Unlock()
+	if bulkKeysETag != "" {
+		req.Header.Set("If-None-Match", bulkKeysETag)
+	}
+
+	client := http.Client{Transport: config.GetTransport()}
+	res, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to list the namespace keys")
+	}
+	defer res.Body.Close()
+
+	ttl := namespaceKeysTTL()
+	switch res.StatusCode {
+	case http.StatusNotModified:
+		for jwksUrl, entry := range bulkKeys {
+			namespaceKeys.Set(jwksUrl, entry.keys, ttl)
+		}
+		log.Debugf("The namespace keys of %d namespaces are unchanged", len(bulkKeys))
+		return nil
+	case http.StatusOK:
+	case http.StatusNotFound:
+		// Registries predating the listing treat the path as an unknown namespace
+		return errBulkKeysUnsupported
+	default:
+		body, _ := io.ReadAll(res.Body)
+		return errors.New(fmt.Sprintf("registry returned status %d when listing the namespace keys: %s", res.StatusCode, string(body)))
+	}
+
+	listing := server_structs.NamespaceKeysRes{}
+	if err := json.NewDecoder(res.Body).Decode(&listing); err != nil {
+		return errors.Wrap(err, "failed to parse the namespace key listing")
+	}
+	newKeys := make(map[string]bulkKeyEntry, len(listing.Namespaces))
+	for _, ns := range listing.Namespaces {
+		if entry, ok := bulkKeys[ns.JwksUri]; ok && entry.etag == ns.ETag {
+			newKeys[ns.JwksUri] = entry
+			continue
+		}
+		keys, err := jwk.Parse(ns.Jwks)
+		if err != nil {
+			log.Warningf("Ignoring the invalid keys of namespace %s from the registry: %v", ns.Prefix, err)
+			continue
+		}
+		newKeys[ns.JwksUri] = bulkKeyEntry{etag: ns.ETag, keys: keys}
+	}
+	// Keys the registry no longer serves, e.g. of deleted namespaces, are dropped right away
+	for jwksUrl := range bulkKeys {
+		if _, ok := newKeys[jwksUrl]; !ok {
+			namespaceKeys.Delete(jwksUrl)
+		}
+	}
+	for jwksUrl, entry := range newKeys {
+		namespaceKeys.Set(jwksUrl, entry.keys, ttl)
+	}
+	bulkKeys = newKeys
+	bulkKeysETag = res.Header.Get("ETag")
+	log.Debugf("Fetched the namespace keys of %d namespaces from the registry", len(newKeys))
+	return nil
+}
+
+// Keep the namespace keys cache populated from the registry's bulk listing, refreshing
+// it twice per key TTL, and renew the other keys in use before they expire.  Keys
+// missing from the cache are still fetched individually when an advertisement
+// needs them.
+func LaunchNamespaceKeysRefresh(ctx context.Context, egrp *errgroup.Group) {
+	go namespaceKeysFailures.Start()
+	namespaceKeys.OnEviction(func(ctx context.Context, er ttlcache.EvictionReason, i *ttlcache.Item[string, jwk.Set]) {
+		defer observeEviction("jwks", er, i.ExpiresAt(), time.Now())
+		namespaceKeysUsed.Delete(i.Key())
+	})
+
+	egrp.Go(func() error {
+		ticker := time.NewTicker(namespaceKeysTTL() / 2)
+		defer ticker.Stop()
+		for {
+			if err := refreshNamespaceKeys(ctx); errors.Is(err, errBulkKeysUnsupported) {
+				log.Infoln("The registry doesn't support listing the namespace keys in bulk; they will be fetched individually")
+				return nil
+			} else if err != nil {
+				log.Warningln("Failed to refresh the namespace keys:", err)
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	})
+
+	egrp.Go(func() error {
+		defer namespaceKeysFailures.Stop()
+		// Check often enough that every key is seen at least twice within its renewal window
+		window := namespaceKeysTTL() / 4
+		ticker := time.NewTicker(window / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+				renewNamespaceKeys(window)
+			}
+		}
+	})
+}
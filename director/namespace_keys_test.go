@@ -0,0 +1,187 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+func TestRefreshNamespaceKeys(t *testing.T) {
+	viper.Reset()
+	config.ResetFederationForTest()
+	t.Cleanup(func() {
+		viper.Reset()
+		config.ResetFederationForTest()
+		namespaceKeys.DeleteAll()
+		bulkKeys = map[string]bulkKeyEntry{}
+		bulkKeysETag = ""
+	})
+
+	lock := sync.Mutex{}
+	listing := server_structs.NamespaceKeysRes{Namespaces: []server_structs.NamespaceKeys{
+		{Prefix: "/foo", JwksUri: "https://registry/foo.jwks", Jwks: json.RawMessage(`{"keys":[{"kty":"oct","k":"Zm9v","kid":"foo"}]}`), ETag: `"foo-1"`},
+		{Prefix: "/bar", JwksUri: "https://registry/bar.jwks", Jwks: json.RawMessage(`{"keys":[{"kty":"oct","k":"YmFy","kid":"bar"}]}`), ETag: `"bar-1"`},
+	}}
+	etag := `"listing-1"`
+	requests, notModified := 0, 0
+	unsupported := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		requests++
+		if unsupported || req.URL.Path != "/api/v1.0/registry/.well-known/namespace-keys" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		if req.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(listing))
+	}))
+	defer ts.Close()
+	viper.Set("Federation.RegistryUrl", ts.URL)
+
+	ctx := context.Background()
+	require.NoError(t, refreshNamespaceKeys(ctx))
+	item := namespaceKeys.Get("https://registry/foo.jwks")
+	require.NotNil(t, item)
+	fooKey, ok := item.Value().LookupKeyID("foo")
+	require.True(t, ok)
+	require.NotNil(t, namespaceKeys.Get("https://registry/bar.jwks"))
+
+	// An unchanged listing is revalidated rather than downloaded
+	namespaceKeys.DeleteAll()
+	require.NoError(t, refreshNamespaceKeys(ctx))
+	assert.Equal(t, 1, notModified)
+	require.NotNil(t, namespaceKeys.Get("https://registry/foo.jwks"))
+
+	// Only the changed entries are replaced, and removed ones are dropped
+	lock.Lock()
+	etag = `"listing-2"`
+	listing.Namespaces = []server_structs.NamespaceKeys{
+		listing.Namespaces[0],
+		{Prefix: "/baz", JwksUri: "https://registry/baz.jwks", Jwks: json.RawMessage(`{"keys":[{"kty":"oct","k":"YmF6","kid":"baz"}]}`), ETag: `"baz-1"`},
+	}
+	lock.Unlock()
+	require.NoError(t, refreshNamespaceKeys(ctx))
+	assert.Nil(t, namespaceKeys.Get("https://registry/bar.jwks"))
+	require.NotNil(t, namespaceKeys.Get("https://registry/baz.jwks"))
+	key, ok := namespaceKeys.Get("https://registry/foo.jwks").Value().LookupKeyID("foo")
+	require.True(t, ok)
+	assert.Same(t, fooKey, key)
+	assert.Equal(t, 3, requests)
+
+	// Old registries don't have the listing
+	lock.Lock()
+	unsupported = true
+	lock.Unlock()
+	assert.ErrorIs(t, refreshNamespaceKeys(ctx), errBulkKeysUnsupported)
+}
+
+func TestGetNamespaceKeys(t *testing.T) {
+	t.Cleanup(func() {
+		namespaceKeys.DeleteAll()
+		namespaceKeysFailures.DeleteAll()
+		namespaceKeysUsed = sync.Map{}
+	})
+
+	fetches := atomic.Int32{}
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fetches.Add(1)
+		if req.URL.Path == "/slow.jwks" {
+			<-release
+		}
+		if req.URL.Path == "/broken.jwks" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`{"keys":[{"kty":"oct","k":"Zm9v","kid":"foo"}]}`))
+	}))
+	defer ts.Close()
+
+	t.Run("concurrent-lookups-share-a-fetch", func(t *testing.T) {
+		fetches.Store(0)
+		wg := sync.WaitGroup{}
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				keys, err := getNamespaceKeys(ts.URL + "/slow.jwks")
+				assert.NoError(t, err)
+				assert.Equal(t, 1, keys.Len())
+			}()
+		}
+		// Give the lookups a chance to pile up behind the first fetch
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		assert.Equal(t, int32(1), fetches.Load())
+	})
+
+	t.Run("failures-are-cached", func(t *testing.T) {
+		fetches.Store(0)
+		_, err := getNamespaceKeys(ts.URL + "/broken.jwks")
+		assert.ErrorContains(t, err, "response code 500")
+		_, err = getNamespaceKeys(ts.URL + "/broken.jwks")
+		assert.ErrorContains(t, err, "response code 500")
+		assert.Equal(t, int32(1), fetches.Load())
+	})
+
+	t.Run("renew-keys-in-use", func(t *testing.T) {
+		used := ts.URL + "/used.jwks"
+		unused := ts.URL + "/unused.jwks"
+		_, err := getNamespaceKeys(used)
+		require.NoError(t, err)
+		_, err = getNamespaceKeys(unused)
+		require.NoError(t, err)
+		namespaceKeysUsed.Delete(unused)
+		keys := namespaceKeys.Get(used).Value()
+		namespaceKeys.Set(used, keys, time.Second)
+		namespaceKeys.Set(unused, keys, time.Second)
+
+		fetches.Store(0)
+		renewNamespaceKeys(time.Minute)
+		assert.Equal(t, int32(1), fetches.Load())
+		assert.Greater(t, time.Until(namespaceKeys.Get(used).ExpiresAt()), time.Minute)
+		assert.Less(t, time.Until(namespaceKeys.Get(unused).ExpiresAt()), time.Second)
+
+		// The keys aren't renewed again unless they're used again
+		namespaceKeys.Set(used, keys, time.Second)
+		renewNamespaceKeys(time.Minute)
+		assert.Equal(t, int32(1), fetches.Load())
+	})
+}
@@ -36,18 +36,20 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/pelicanplatform/pelican/config"
-	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/server_structs"
 	"github.com/pelicanplatform/pelican/server_utils"
 	"github.com/pelicanplatform/pelican/token_scopes"
-	"github.com/pelicanplatform/pelican/utils"
 )
 
 var (
 	// namespaceKeys caches jwks from various origins.
 	// The cache key is jwks endpoint (e.g. https://example.com/path/to/issuer.jwks).
-	// TTL cache is thread-safe
-	namespaceKeys = ttlcache.New(ttlcache.WithTTL[string, jwk.Set](15 * time.Minute))
+	// TTL cache is thread-safe.  Hits don't extend the TTL so rotated keys are
+	// picked up; keys in use are renewed in the background instead.
+	namespaceKeys = ttlcache.New(
+		ttlcache.WithTTL[string, jwk.Set](15*time.Minute),
+		ttlcache.WithDisableTouchOnHit[string, jwk.Set](),
+	)
 
 	adminApprovalErr error
 )
@@ -134,26 +136,9 @@ func verifyAdvertiseToken(ctx context.Context, token, namespace string) (bool, e
 	}
 
 	log.Debugln("Attempting to fetch keys from ", keyLoc)
-	var keyset jwk.Set
-
-	item := namespaceKeys.Get(keyLoc)
-	if item != nil {
-		if !item.IsExpired() {
-			keyset = item.Value()
-		}
-	}
-
-	if keyset == nil {
-		keyset, err = utils.GetJwks(ctx, keyLoc)
-		if err != nil {
-			return false, errors.Wrapf(err, "failed to get jwks at %s", keyLoc)
-		}
-		customTTL := param.Director_AdvertisementTTL.GetDuration()
-		if customTTL == 0 {
-			namespaceKeys.Set(keyLoc, keyset, ttlcache.DefaultTTL)
-		} else {
-			namespaceKeys.Set(keyLoc, keyset, customTTL)
-		}
+	keyset, err := getNamespaceKeys(keyLoc)
+	if err != nil {
+		return false, err
 	}
 
 	tok, err := jwt.Parse([]byte(token), jwt.WithKeySet(keyset), jwt.WithValidate(true))
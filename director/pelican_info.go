@@ -0,0 +1,188 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+// The reserved prefix of the objects the director generates itself.  They hold the
+// federation's metadata as plain text, one "key=value" or space-separated record per
+// line, so clients limited to downloading objects (e.g. with wget) can bootstrap from
+// them without the JSON APIs.
+const pelicanInfoPrefix = "/.pelican/info"
+
+// The director-generated objects under pelicanInfoPrefix, by name
+var pelicanInfoObjects = map[string]func(ginCtx *gin.Context) (string, error){
+	"federation": getFederationInfo,
+	"caches":     getCachesInfo,
+	"issuers":    getIssuersInfo,
+	"geo":        getGeoInfo,
+}
+
+// Returns the name of the director-generated object at the path, which has the API
+// prefix removed; the name is empty for the listing of the objects
+func getPelicanInfoObject(reqPath string) (name string, ok bool) {
+	reqPath = path.Clean("/" + reqPath)
+	if reqPath == pelicanInfoPrefix {
+		return "", true
+	}
+	if name, ok = strings.CutPrefix(reqPath, pelicanInfoPrefix+"/"); !ok {
+		return "", false
+	}
+	return name, true
+}
+
+// Serve a director-generated object under pelicanInfoPrefix
+func servePelicanInfo(ginCtx *gin.Context, name string) {
+	if ginCtx.Request.Method != http.MethodGet && ginCtx.Request.Method != http.MethodHead {
+		ginCtx.JSON(http.StatusMethodNotAllowed, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "The objects under " + pelicanInfoPrefix + " are read-only",
+		})
+		return
+	}
+	var body string
+	if name == "" {
+		names := make([]string, 0, len(pelicanInfoObjects))
+		for objName := range pelicanInfoObjects {
+			names = append(names, objName)
+		}
+		sort.Strings(names)
+		body = strings.Join(names, "\n") + "\n"
+	} else if generate, ok := pelicanInfoObjects[name]; ok {
+		var err error
+		if body, err = generate(ginCtx); err != nil {
+			log.Errorf("Failed to generate %s/%s: %v", pelicanInfoPrefix, name, err)
+			ginCtx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Failed to generate " + pelicanInfoPrefix + "/" + name,
+			})
+			return
+		}
+	} else {
+		ginCtx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "No object " + name + " under " + pelicanInfoPrefix,
+		})
+		return
+	}
+
+	ginCtx.Header("Cache-Control", "no-store")
+	if ginCtx.Request.Method == http.MethodHead {
+		ginCtx.Header("Content-Length", strconv.Itoa(len(body)))
+		ginCtx.Status(http.StatusOK)
+		return
+	}
+	ginCtx.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(body))
+}
+
+// The federation's central services, as in the federation discovery document
+func getFederationInfo(ginCtx *gin.Context) (string, error) {
+	fedInfo, err := config.GetFederation(ginCtx)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "discovery_url=%s\n", param.Federation_DiscoveryUrl.GetString())
+	fmt.Fprintf(&sb, "director_endpoint=%s\n", fedInfo.DirectorEndpoint)
+	fmt.Fprintf(&sb, "namespace_registration_endpoint=%s\n", fedInfo.NamespaceRegistrationEndpoint)
+	fmt.Fprintf(&sb, "jwks_uri=%s\n", fedInfo.JwksUri)
+	fmt.Fprintf(&sb, "broker_endpoint=%s\n", fedInfo.BrokerEndpoint)
+	return sb.String(), nil
+}
+
+// The caches of the federation, nearest to the client first, as "<name> <data URL> <web URL>"
+func getCachesInfo(ginCtx *gin.Context) (string, error) {
+	cacheAds := []server_structs.ServerAd{}
+	for _, ad := range listAdvertisement([]server_structs.ServerType{server_structs.CacheType}) {
+		if filtered, _ := checkFilter(ad.Name); !filtered {
+			cacheAds = append(cacheAds, ad.ServerAd)
+		}
+	}
+	if ipAddr, err := getRealIP(ginCtx); err == nil {
+		if sorted, err := sortServerAdsByIP(ipAddr, cacheAds); err == nil {
+			cacheAds = sorted
+		} else {
+			log.Warningln("Failed to sort the caches for", pelicanInfoPrefix+"/caches:", err)
+		}
+	}
+	var sb strings.Builder
+	for _, ad := range cacheAds {
+		webUrl := ad.WebURL.String()
+		if webUrl == "" {
+			webUrl = "-"
+		}
+		fmt.Fprintf(&sb, "%s %s %s\n", ad.Name, ad.URL.String(), webUrl)
+	}
+	return sb.String(), nil
+}
+
+// The token issuers of the federation's namespaces, as "<namespace prefix> <issuer URL>"
+func getIssuersInfo(ginCtx *gin.Context) (string, error) {
+	issuers := map[string]map[string]bool{}
+	for _, ns := range listNamespacesFromOrigins() {
+		for _, issuer := range ns.Issuer {
+			if issuers[ns.Path] == nil {
+				issuers[ns.Path] = map[string]bool{}
+			}
+			issuers[ns.Path][issuer.IssuerUrl.String()] = true
+		}
+	}
+	lines := []string{}
+	for prefix, urls := range issuers {
+		for issuerUrl := range urls {
+			lines = append(lines, prefix+" "+issuerUrl)
+		}
+	}
+	sort.Strings(lines)
+	if len(lines) == 0 {
+		return "", nil
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// Where the director locates the client; the caches are sorted by their distance to it
+func getGeoInfo(ginCtx *gin.Context) (string, error) {
+	ipAddr, err := getRealIP(ginCtx)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "ip=%s\n", ipAddr.String())
+	if coord, ok := getClientLatLong(ipAddr); ok {
+		fmt.Fprintf(&sb, "located=true\nlatitude=%g\nlongitude=%g\n", coord.Lat, coord.Long)
+	} else {
+		sb.WriteString("located=false\n")
+	}
+	fmt.Fprintf(&sb, "cache_sort_method=%s\n", param.Director_CacheSortMethod.GetString())
+	return sb.String(), nil
+}
@@ -0,0 +1,132 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+func TestGetPelicanInfoObject(t *testing.T) {
+	for _, tc := range []struct {
+		reqPath string
+		name    string
+		ok      bool
+	}{
+		{"/.pelican/info", "", true},
+		{"/.pelican/info/", "", true},
+		{"/.pelican/info/caches", "caches", true},
+		{"/foo/../.pelican/info/geo", "geo", true},
+		{"/.pelican/infos", "", false},
+		{"/foo/.pelican/info/caches", "", false},
+	} {
+		name, ok := getPelicanInfoObject(tc.reqPath)
+		assert.Equal(t, tc.ok, ok, tc.reqPath)
+		assert.Equal(t, tc.name, name, tc.reqPath)
+	}
+}
+
+func TestServePelicanInfo(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Cleanup(serverAds.DeleteAll)
+	serverAds.DeleteAll()
+
+	cacheAd := server_structs.ServerAd{
+		Name:   "test-cache",
+		URL:    url.URL{Scheme: "https", Host: "cache.example.com:8443"},
+		WebURL: url.URL{Scheme: "https", Host: "cache.example.com:8444"},
+		Type:   server_structs.CacheType,
+	}
+	originAd := server_structs.ServerAd{
+		Name: "test-origin",
+		URL:  url.URL{Scheme: "https", Host: "origin.example.com:8443"},
+		Type: server_structs.OriginType,
+	}
+	serverAds.Set(cacheAd.URL.String(), &server_structs.Advertisement{ServerAd: cacheAd}, ttlcache.DefaultTTL)
+	serverAds.Set(originAd.URL.String(), &server_structs.Advertisement{
+		ServerAd: originAd,
+		NamespaceAds: []server_structs.NamespaceAdV2{{
+			Path:   "/foo",
+			Issuer: []server_structs.TokenIssuer{{IssuerUrl: url.URL{Scheme: "https", Host: "issuer.example.com"}}},
+		}},
+	}, ttlcache.DefaultTTL)
+
+	router := gin.New()
+	router.Any("/api/v1.0/director/object/*any", redirectToCache)
+	router.Any("/api/v1.0/director/origin/*any", redirectToOrigin)
+
+	serve := func(method, reqPath string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, reqPath, nil)
+		req.Header.Set("X-Real-Ip", "192.0.2.1")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("index", func(t *testing.T) {
+		w := serve(http.MethodGet, "/api/v1.0/director/object/.pelican/info")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "caches\nfederation\ngeo\nissuers\n", w.Body.String())
+	})
+
+	t.Run("caches", func(t *testing.T) {
+		w := serve(http.MethodGet, "/api/v1.0/director/object/.pelican/info/caches")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, "test-cache https://cache.example.com:8443 https://cache.example.com:8444\n", w.Body.String())
+	})
+
+	t.Run("issuers-from-origin-endpoint", func(t *testing.T) {
+		w := serve(http.MethodGet, "/api/v1.0/director/origin/.pelican/info/issuers")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "/foo https://issuer.example.com\n", w.Body.String())
+	})
+
+	t.Run("geo", func(t *testing.T) {
+		w := serve(http.MethodGet, "/api/v1.0/director/object/.pelican/info/geo")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "ip=192.0.2.1\n")
+	})
+
+	t.Run("head", func(t *testing.T) {
+		w := serve(http.MethodHead, "/api/v1.0/director/object/.pelican/info/caches")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "73", w.Header().Get("Content-Length"))
+		assert.Empty(t, w.Body.String())
+	})
+
+	t.Run("unknown-object", func(t *testing.T) {
+		w := serve(http.MethodGet, "/api/v1.0/director/object/.pelican/info/nothing")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("read-only", func(t *testing.T) {
+		w := serve(http.MethodPut, "/api/v1.0/director/origin/.pelican/info/caches")
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	})
+}
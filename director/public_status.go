@@ -0,0 +1,87 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"time"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/web_ui"
+)
+
+type (
+	// A scheduled downtime as shown on the public status, without who created it
+	PublicDowntime struct {
+		ServerName  string                        `json:"serverName"`
+		Description string                        `json:"description"`
+		StartTime   time.Time                     `json:"startTime"`
+		EndTime     time.Time                     `json:"endTime"`
+		Status      server_structs.DowntimeStatus `json:"status"`
+	}
+
+	// The director's summary of the federation for the public status
+	FederationStatus struct {
+		Origins int `json:"origins"`
+		Caches  int `json:"caches"`
+		// The number of servers by the status of their director health tests
+		HealthTests map[HealthTestStatus]int `json:"healthTests"`
+		Downtimes   []PublicDowntime         `json:"downtimes"`
+	}
+)
+
+func getFederationStatus(now time.Time) FederationStatus {
+	status := FederationStatus{HealthTests: map[HealthTestStatus]int{}}
+	for _, item := range serverAds.Items() {
+		if item.Value().Type == server_structs.OriginType {
+			status.Origins++
+		} else {
+			status.Caches++
+		}
+	}
+
+	healthTestUtilsMutex.RLock()
+	for _, util := range healthTestUtils {
+		testStatus := util.Status
+		if testStatus == "" {
+			testStatus = HealthStatusUnknown
+		}
+		status.HealthTests[testStatus]++
+	}
+	healthTestUtilsMutex.RUnlock()
+
+	dts := listDowntimes(downtimeFilter{}, now)
+	status.Downtimes = make([]PublicDowntime, 0, len(dts))
+	for _, dt := range dts {
+		status.Downtimes = append(status.Downtimes, PublicDowntime{
+			ServerName:  dt.ServerName,
+			Description: dt.Description,
+			StartTime:   dt.StartTime,
+			EndTime:     dt.EndTime,
+			Status:      dt.Status(now),
+		})
+	}
+	return status
+}
+
+// Add the director's summary of the federation to the server's public status
+func RegisterFederationStatus() {
+	web_ui.SetFederationStatusProvider(func() interface{} {
+		return getFederationStatus(time.Now())
+	})
+}
@@ -0,0 +1,81 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+func TestGetFederationStatus(t *testing.T) {
+	resetDowntimes(t)
+	serverAds.DeleteAll()
+	t.Cleanup(serverAds.DeleteAll)
+
+	for idx, serverType := range []server_structs.ServerType{server_structs.OriginType, server_structs.CacheType, server_structs.CacheType} {
+		serverUrl := url.URL{Scheme: "https", Host: "server" + string(rune('a'+idx)) + ".example.com"}
+		serverAds.Set(serverUrl.String(), &server_structs.Advertisement{
+			ServerAd: server_structs.ServerAd{URL: serverUrl, Type: serverType},
+		}, ttlcache.DefaultTTL)
+	}
+
+	healthTestUtilsMutex.Lock()
+	oldUtils := healthTestUtils
+	healthTestUtils = map[string]*healthTestUtil{
+		"https://servera.example.com": {Status: HealthStatusOK},
+		"https://serverb.example.com": {Status: HealthStatusOK},
+		"https://serverc.example.com": {Status: HealthStatusError},
+	}
+	healthTestUtilsMutex.Unlock()
+	t.Cleanup(func() {
+		healthTestUtilsMutex.Lock()
+		healthTestUtils = oldUtils
+		healthTestUtilsMutex.Unlock()
+	})
+
+	now := time.Now()
+	_, err := addDowntimes([]server_structs.Downtime{
+		{ServerName: "servera", Description: "Disk replacement", StartTime: now.Add(-time.Hour), EndTime: now.Add(time.Hour)},
+		{ServerName: "serverb", StartTime: now.Add(2 * time.Hour), EndTime: now.Add(3 * time.Hour)},
+	}, "admin@example.com", now, false)
+	require.NoError(t, err)
+
+	status := getFederationStatus(now)
+	assert.Equal(t, 1, status.Origins)
+	assert.Equal(t, 2, status.Caches)
+	assert.Equal(t, map[HealthTestStatus]int{HealthStatusOK: 2, HealthStatusError: 1}, status.HealthTests)
+	require.Len(t, status.Downtimes, 2)
+	assert.Equal(t, "servera", status.Downtimes[0].ServerName)
+	assert.Equal(t, "Disk replacement", status.Downtimes[0].Description)
+	assert.Equal(t, server_structs.DowntimeActive, status.Downtimes[0].Status)
+	assert.Equal(t, server_structs.DowntimeUpcoming, status.Downtimes[1].Status)
+
+	// The public status must not reveal who scheduled the downtimes
+	body, err := json.Marshal(status)
+	require.NoError(t, err)
+	assert.NotContains(t, string(body), "admin@example.com")
+}
@@ -0,0 +1,114 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// What the director knew about a server at its last advertisement
+	serverPresence struct {
+		lastAd time.Time
+		// Whether the server was filtered, e.g. in a downtime, at its last advertisement
+		wasFiltered bool
+	}
+)
+
+const (
+	// The factor a readmitted server's sorting weight is scaled by until it passes a health test
+	readmissionWeightFactor = 0.5
+)
+
+var (
+	// The servers that have advertised since the director started, by URL
+	serverPresences = map[string]serverPresence{}
+	// The servers readmitted after a downtime or an expired advertisement that haven't passed
+	// a health test since, by URL, with the time of their readmission
+	readmissions     = map[string]time.Time{}
+	readmissionMutex = sync.Mutex{}
+
+	// Channels for requesting an immediate health test of a server, by URL
+	healthTestTriggers sync.Map
+)
+
+// Record the advertisement of a server and determine whether the server is returning to
+// rotation: it's advertised before, isn't filtered now, and either its previous advertisement
+// expired (e.g. the server restarted) or it was filtered (e.g. in a downtime) at the time.
+// A returning server is put on probation until it passes a health test.
+func checkReadmission(ad server_structs.ServerAd, hadAd bool, now time.Time) bool {
+	filtered, _ := checkFilter(ad.Name)
+	serverUrl := ad.URL.String()
+
+	readmissionMutex.Lock()
+	defer readmissionMutex.Unlock()
+	prev, seen := serverPresences[serverUrl]
+	serverPresences[serverUrl] = serverPresence{lastAd: now, wasFiltered: filtered}
+	if !seen || filtered || (hadAd && !prev.wasFiltered) {
+		return false
+	}
+	if _, onProbation := readmissions[serverUrl]; !onProbation {
+		log.Infof("%s server %s is back after %s; testing it right away", ad.Type, ad.Name, now.Sub(prev.lastAd).Truncate(time.Second))
+		readmissions[serverUrl] = now
+	}
+	return true
+}
+
+// End the probation of a readmitted server, e.g. once it passes a health test, restoring
+// its full sorting weight
+func endReadmission(serverUrl string) {
+	readmissionMutex.Lock()
+	defer readmissionMutex.Unlock()
+	if since, ok := readmissions[serverUrl]; ok {
+		log.Debugf("Ending the probation of server %s, readmitted %s ago", serverUrl, time.Since(since).Truncate(time.Millisecond))
+		delete(readmissions, serverUrl)
+	}
+}
+
+// Get the factor, between 0 and 1, by which to scale a server's sorting weight while
+// it's on probation after being readmitted
+func getReadmissionFactor(serverUrl string) float64 {
+	readmissionMutex.Lock()
+	defer readmissionMutex.Unlock()
+	if _, ok := readmissions[serverUrl]; ok {
+		return readmissionWeightFactor
+	}
+	return 1
+}
+
+// Get the channel the health test suite of a server listens on for immediate tests
+func getHealthTestTrigger(serverUrl string) chan struct{} {
+	trigger, _ := healthTestTriggers.LoadOrStore(serverUrl, make(chan struct{}, 1))
+	return trigger.(chan struct{})
+}
+
+// Ask the health test suite of a server to run a test now rather than at its next interval.
+// If the suite hasn't started yet, it runs the test as soon as it does.
+func triggerHealthTest(serverUrl string) {
+	select {
+	case getHealthTestTrigger(serverUrl) <- struct{}{}:
+	default:
+		// A test is already pending
+	}
+}
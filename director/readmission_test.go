@@ -0,0 +1,110 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+func resetReadmissions(t *testing.T) {
+	reset := func() {
+		readmissionMutex.Lock()
+		serverPresences = map[string]serverPresence{}
+		readmissions = map[string]time.Time{}
+		readmissionMutex.Unlock()
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+func TestCheckReadmission(t *testing.T) {
+	resetReadmissions(t)
+	resetDowntimes(t)
+
+	newAd := func(name string) server_structs.ServerAd {
+		return server_structs.ServerAd{
+			Name: name,
+			URL:  url.URL{Scheme: "https", Host: name + ".example.com"},
+			Type: server_structs.CacheType,
+		}
+	}
+	now := time.Now()
+
+	t.Run("restarted-server", func(t *testing.T) {
+		ad := newAd("restarted")
+		// Neither the first advertisement nor the regular re-advertisements are readmissions
+		assert.False(t, checkReadmission(ad, false, now))
+		assert.False(t, checkReadmission(ad, true, now.Add(time.Minute)))
+		assert.Equal(t, 1.0, getReadmissionFactor(ad.URL.String()))
+
+		// The advertisement expired, so the server is back after e.g. a restart
+		assert.True(t, checkReadmission(ad, false, now.Add(time.Hour)))
+		assert.Equal(t, readmissionWeightFactor, getReadmissionFactor(ad.URL.String()))
+
+		// Passing a health test restores the full weight
+		endReadmission(ad.URL.String())
+		assert.Equal(t, 1.0, getReadmissionFactor(ad.URL.String()))
+	})
+
+	t.Run("downtime-ended", func(t *testing.T) {
+		ad := newAd("maintained")
+		assert.False(t, checkReadmission(ad, false, now))
+
+		added, err := addDowntimes([]server_structs.Downtime{{ServerName: ad.Name, StartTime: now.Add(-time.Hour), EndTime: now.Add(time.Hour)}}, "admin", now, false)
+		require.NoError(t, err)
+		// Advertising during the downtime doesn't readmit the server
+		assert.False(t, checkReadmission(ad, true, now))
+		assert.Equal(t, 1.0, getReadmissionFactor(ad.URL.String()))
+
+		deleteDowntimes(downtimeFilter{Server: added[0].ServerName}, now)
+		// The first advertisement after the downtime does, even though the old one hasn't expired
+		assert.True(t, checkReadmission(ad, true, now.Add(time.Minute)))
+		assert.Equal(t, readmissionWeightFactor, getReadmissionFactor(ad.URL.String()))
+		// Later advertisements don't
+		endReadmission(ad.URL.String())
+		assert.False(t, checkReadmission(ad, true, now.Add(2*time.Minute)))
+	})
+}
+
+func TestTriggerHealthTest(t *testing.T) {
+	serverUrl := "https://trigger.example.com"
+	t.Cleanup(func() { healthTestTriggers.Delete(serverUrl) })
+
+	// Triggering before the suite starts leaves a single pending test, and never blocks
+	triggerHealthTest(serverUrl)
+	triggerHealthTest(serverUrl)
+	trigger := getHealthTestTrigger(serverUrl)
+	select {
+	case <-trigger:
+	default:
+		t.Fatal("The health test wasn't triggered")
+	}
+	select {
+	case <-trigger:
+		t.Fatal("More than one health test was pending")
+	default:
+	}
+}
@@ -0,0 +1,188 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"net/netip"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/utils"
+)
+
+type (
+	// A record of a single redirect decision made by the director
+	RedirectDecision struct {
+		Time      time.Time    `json:"time"`
+		Type      string       `json:"type"` // "cache" | "origin"
+		Method    string       `json:"method"`
+		ClientNet netip.Prefix `json:"clientNet"`
+		Path      string       `json:"path"`
+		Namespace string       `json:"namespace"` // The namespace prefix matched for the path; empty if none
+		Status    int          `json:"status"`
+		Redirect  string       `json:"redirect,omitempty"` // The host the client was redirected to
+		Servers   []string     `json:"servers"`            // The hosts advertised to the client, in order of preference
+		LatencyMs float64      `json:"latencyMs"`
+	}
+
+	// Query parameters for filtering the recorded redirect decisions
+	redirectLogRequest struct {
+		Since  time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+		Until  time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+		Type   string    `form:"type"`
+		Prefix string    `form:"prefix"`
+		Client string    `form:"client"` // An IP address or CIDR
+		Server string    `form:"server"`
+		Limit  int       `form:"limit"`
+	}
+)
+
+const (
+	// Only the network of the client is recorded, not its full address
+	redirectLogIPv4Bits = 24
+	redirectLogIPv6Bits = 64
+)
+
+var (
+	// The most recent redirect decisions
+	redirectDecisions = &utils.Ring[RedirectDecision]{}
+
+	linkURLRegex = regexp.MustCompile(`<([^>]+)>`)
+)
+
+// Mask the client address down to the network recorded in the log
+func getClientNet(addr netip.Addr) netip.Prefix {
+	addr = addr.Unmap()
+	bits := redirectLogIPv6Bits
+	if addr.Is4() {
+		bits = redirectLogIPv4Bits
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return netip.Prefix{}
+	}
+	return prefix
+}
+
+// Parse the host names out of a Link header generated by the director
+func getLinkHosts(linkHeader string) (hosts []string) {
+	hosts = []string{}
+	for _, match := range linkURLRegex.FindAllStringSubmatch(linkHeader, -1) {
+		if linkUrl, err := url.Parse(match[1]); err == nil {
+			hosts = append(hosts, linkUrl.Host)
+		}
+	}
+	return
+}
+
+// Parse the namespace prefix out of the X-Pelican-Namespace header
+func getNamespaceFromHeader(nsHeader string) string {
+	for _, field := range strings.Split(nsHeader, ",") {
+		if ns, ok := strings.CutPrefix(strings.TrimSpace(field), "namespace="); ok {
+			return ns
+		}
+	}
+	return ""
+}
+
+// Record the outcome of a redirect request.  This is meant to be deferred at the
+// start of the redirect handler so that the decision is recorded regardless of the
+// code path taken; the decision is reconstructed from the response the handler wrote.
+func recordRedirectDecision(ginCtx *gin.Context, redirectType string, start time.Time) {
+	size := param.Director_RedirectLogSize.GetInt()
+	if size <= 0 {
+		return
+	}
+
+	reqPath := path.Clean("/" + ginCtx.Request.URL.Path)
+	if redirectType == "cache" {
+		reqPath = strings.TrimPrefix(reqPath, "/api/v1.0/director/object")
+	} else {
+		reqPath = strings.TrimPrefix(reqPath, "/api/v1.0/director/origin")
+	}
+	// The director's own health tests would otherwise quickly crowd out client requests
+	if strings.HasPrefix(reqPath, "/pelican/monitoring/") {
+		return
+	}
+
+	header := ginCtx.Writer.Header()
+	decision := RedirectDecision{
+		Time:      start,
+		Type:      redirectType,
+		Method:    ginCtx.Request.Method,
+		Path:      reqPath,
+		Namespace: getNamespaceFromHeader(header.Get("X-Pelican-Namespace")),
+		Status:    ginCtx.Writer.Status(),
+		Servers:   getLinkHosts(header.Get("Link")),
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if ipAddr, err := getRealIP(ginCtx); err == nil {
+		decision.ClientNet = getClientNet(ipAddr)
+	}
+	if location := header.Get("Location"); location != "" {
+		if locUrl, err := url.Parse(location); err == nil {
+			decision.Redirect = locUrl.Host
+		}
+	}
+	redirectDecisions.Add(decision, size)
+}
+
+// Returns true if the path is equal to or inside of the given prefix
+func hasPathPrefix(reqPath, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return reqPath == prefix || strings.HasPrefix(reqPath, prefix+"/")
+}
+
+// Returns true if the decision matches all the filters in the request
+func (req redirectLogRequest) matches(decision RedirectDecision, clientNet netip.Prefix) bool {
+	if !req.Since.IsZero() && decision.Time.Before(req.Since) {
+		return false
+	}
+	if !req.Until.IsZero() && decision.Time.After(req.Until) {
+		return false
+	}
+	if req.Type != "" && req.Type != decision.Type {
+		return false
+	}
+	if req.Prefix != "" && !hasPathPrefix(decision.Path, req.Prefix) && !hasPathPrefix(decision.Namespace, req.Prefix) {
+		return false
+	}
+	if clientNet.IsValid() && (!decision.ClientNet.IsValid() || !clientNet.Overlaps(decision.ClientNet)) {
+		return false
+	}
+	if req.Server != "" {
+		found := strings.Contains(strings.ToLower(decision.Redirect), strings.ToLower(req.Server))
+		for _, server := range decision.Servers {
+			if found {
+				break
+			}
+			found = strings.Contains(strings.ToLower(server), strings.ToLower(req.Server))
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
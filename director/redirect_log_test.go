@@ -0,0 +1,162 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedirectLogHeaderParsing(t *testing.T) {
+	link := `<https://cache1.example.com:8443/foo/bar>; rel="duplicate"; pri=1; depth=2, <https://cache2.example.com/foo/bar>; rel="duplicate"; pri=2; depth=2`
+	assert.Equal(t, []string{"cache1.example.com:8443", "cache2.example.com"}, getLinkHosts(link))
+	assert.Empty(t, getLinkHosts(""))
+
+	assert.Equal(t, "/foo", getNamespaceFromHeader("namespace=/foo, require-token=true, collections-url=https://origin.example.com"))
+	assert.Equal(t, "", getNamespaceFromHeader(""))
+
+	assert.Equal(t, "192.0.2.0/24", getClientNet(netip.MustParseAddr("192.0.2.17")).String())
+	assert.Equal(t, "192.0.2.0/24", getClientNet(netip.MustParseAddr("::ffff:192.0.2.17")).String())
+	assert.Equal(t, "2001:db8:1:2::/64", getClientNet(netip.MustParseAddr("2001:db8:1:2:3:4:5:6")).String())
+}
+
+func TestListRedirectDecisions(t *testing.T) {
+	t.Cleanup(func() {
+		viper.Reset()
+		redirectDecisions.Reset()
+	})
+	viper.Reset()
+	viper.Set("Director.RedirectLogSize", 10)
+	redirectDecisions.Reset()
+
+	// A stand-in for the redirect handlers that records its decision the same way
+	mockRedirect := func(ctx *gin.Context) {
+		defer recordRedirectDecision(ctx, "cache", time.Now())
+		if ctx.Param("any") == "/missing/file" {
+			ctx.JSON(http.StatusNotFound, gin.H{})
+			return
+		}
+		ctx.Header("X-Pelican-Namespace", "namespace=/foo, require-token=false")
+		ctx.Header("Link", `<https://cache1.example.com/foo/bar>; rel="duplicate"; pri=1; depth=1, <https://cache2.example.com/foo/bar>; rel="duplicate"; pri=2; depth=1`)
+		ctx.Redirect(http.StatusTemporaryRedirect, "https://cache1.example.com/foo/bar")
+	}
+
+	router := gin.New()
+	router.GET("/api/v1.0/director/object/*any", mockRedirect)
+	router.GET("/redirects", listRedirectDecisions)
+
+	doRedirect := func(reqPath, clientIP string) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1.0/director/object"+reqPath, nil)
+		req.Header.Set("X-Real-Ip", clientIP)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	start := time.Now()
+	doRedirect("/foo/bar", "192.0.2.10")
+	doRedirect("/missing/file", "198.51.100.4")
+	doRedirect("/pelican/monitoring/test", "192.0.2.10")
+
+	listRedirects := func(query string) (decisions []RedirectDecision, code int) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/redirects"+query, nil)
+		router.ServeHTTP(w, req)
+		code = w.Code
+		if code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &decisions))
+		}
+		return
+	}
+
+	t.Run("all-decisions", func(t *testing.T) {
+		decisions, code := listRedirects("")
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, decisions, 2)
+
+		assert.Equal(t, "/missing/file", decisions[0].Path)
+		assert.Equal(t, http.StatusNotFound, decisions[0].Status)
+		assert.Empty(t, decisions[0].Namespace)
+		assert.Empty(t, decisions[0].Servers)
+
+		assert.Equal(t, "/foo/bar", decisions[1].Path)
+		assert.Equal(t, "cache", decisions[1].Type)
+		assert.Equal(t, http.MethodGet, decisions[1].Method)
+		assert.Equal(t, "/foo", decisions[1].Namespace)
+		assert.Equal(t, http.StatusTemporaryRedirect, decisions[1].Status)
+		assert.Equal(t, "cache1.example.com", decisions[1].Redirect)
+		assert.Equal(t, []string{"cache1.example.com", "cache2.example.com"}, decisions[1].Servers)
+		assert.Equal(t, "192.0.2.0/24", decisions[1].ClientNet.String())
+		assert.False(t, decisions[1].Time.Before(start.Truncate(time.Second)))
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		decisions, code := listRedirects("?prefix=/foo")
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, decisions, 1)
+		assert.Equal(t, "/foo/bar", decisions[0].Path)
+
+		decisions, _ = listRedirects("?client=198.51.100.4")
+		require.Len(t, decisions, 1)
+		assert.Equal(t, "/missing/file", decisions[0].Path)
+
+		decisions, _ = listRedirects("?client=192.0.0.0/8")
+		require.Len(t, decisions, 1)
+		assert.Equal(t, "/foo/bar", decisions[0].Path)
+
+		decisions, _ = listRedirects("?server=CACHE2")
+		require.Len(t, decisions, 1)
+
+		decisions, _ = listRedirects("?type=origin")
+		assert.Empty(t, decisions)
+
+		decisions, _ = listRedirects("?limit=1")
+		require.Len(t, decisions, 1)
+		assert.Equal(t, "/missing/file", decisions[0].Path)
+
+		future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		decisions, _ = listRedirects("?since=" + future)
+		assert.Empty(t, decisions)
+		decisions, _ = listRedirects("?until=" + future)
+		assert.Len(t, decisions, 2)
+	})
+
+	t.Run("invalid-filters", func(t *testing.T) {
+		_, code := listRedirects("?client=not-an-ip")
+		assert.Equal(t, http.StatusBadRequest, code)
+		_, code = listRedirects("?type=registry")
+		assert.Equal(t, http.StatusBadRequest, code)
+		_, code = listRedirects("?since=yesterday")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		viper.Set("Director.RedirectLogSize", 0)
+		redirectDecisions.Reset()
+		doRedirect("/foo/bar", "192.0.2.10")
+		decisions, _ := listRedirects("")
+		assert.Empty(t, decisions)
+	})
+}
@@ -0,0 +1,252 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"net/http"
+	"net/netip"
+	"sort"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// A redirect rule of Director.RedirectRules.  If is evaluated once per request over the
+	// client and the namespace (ns); Exclude and Prefer are evaluated for each server.
+	redirectRule struct {
+		Name    string `mapstructure:"Name"`
+		If      string `mapstructure:"If"`
+		Exclude string `mapstructure:"Exclude"`
+		Prefer  string `mapstructure:"Prefer"`
+	}
+
+	compiledRedirectRule struct {
+		name    string
+		cond    *ruleProgram // nil if the rule applies to every request
+		exclude *ruleProgram
+		prefer  *ruleProgram
+	}
+
+	// Applies the redirect rules to the servers offered to one client
+	redirectRuleEvaluator struct {
+		rules  []*compiledRedirectRule
+		client clientRuleVars
+	}
+)
+
+const (
+	redirectRuleActionExclude = "exclude"
+	redirectRuleActionPrefer  = "prefer"
+)
+
+var (
+	// The compiled rules, loaded from the configuration on first use
+	redirectRulesOnce sync.Once
+	redirectRules     []*compiledRedirectRule
+)
+
+// Compile the configured redirect rules, skipping (and logging) the invalid ones
+func compileRedirectRules(rules []redirectRule) (compiled []*compiledRedirectRule) {
+	for idx, rule := range rules {
+		if rule.Name == "" {
+			log.Errorf("Redirect rule #%d of Director.RedirectRules has no name; ignoring it", idx+1)
+			continue
+		}
+		if rule.Exclude == "" && rule.Prefer == "" {
+			log.Errorf("Redirect rule %s has neither an Exclude nor a Prefer expression; ignoring it", rule.Name)
+			continue
+		}
+		compiledRule := &compiledRedirectRule{name: rule.Name}
+		var err error
+		for _, expr := range []struct {
+			source string
+			prog   **ruleProgram
+			vars   []string
+		}{
+			{rule.If, &compiledRule.cond, []string{"client", "ns"}},
+			{rule.Exclude, &compiledRule.exclude, []string{"client", "ns", "server"}},
+			{rule.Prefer, &compiledRule.prefer, []string{"client", "ns", "server"}},
+		} {
+			if expr.source == "" {
+				continue
+			}
+			if *expr.prog, err = compileRuleExpr(expr.source, expr.vars...); err != nil {
+				log.Errorf("Failed to compile %q of redirect rule %s; ignoring the rule: %v", expr.source, rule.Name, err)
+				break
+			}
+		}
+		if err == nil {
+			compiled = append(compiled, compiledRule)
+		}
+	}
+	return
+}
+
+func getRedirectRules() []*compiledRedirectRule {
+	redirectRulesOnce.Do(func() {
+		rules := []redirectRule{}
+		if err := param.Director_RedirectRules.Unmarshal(&rules); err != nil {
+			log.Errorln("Failed to parse Director.RedirectRules; no redirect rules are applied:", err)
+			return
+		}
+		redirectRules = compileRedirectRules(rules)
+		if len(redirectRules) > 0 {
+			log.Infof("Loaded %d redirect rule(s)", len(redirectRules))
+		}
+	})
+	return redirectRules
+}
+
+// Forget the loaded rules so they're reloaded from the configuration; for tests
+func resetRedirectRules() {
+	redirectRulesOnce = sync.Once{}
+	redirectRules = nil
+}
+
+// Create the evaluator of the redirect rules for the client making the request
+func newRedirectRuleEvaluator(req *http.Request, addr netip.Addr) *redirectRuleEvaluator {
+	evaluator := &redirectRuleEvaluator{rules: getRedirectRules()}
+	if len(evaluator.rules) == 0 {
+		return evaluator
+	}
+	country := ""
+	if record, err := lookupGeoIP(addr); err == nil {
+		country = record.Country.IsoCode
+	}
+	evaluator.client = clientRuleVars{
+		IP:        addr.String(),
+		Country:   country,
+		Version:   getClientCapabilities(req).Version,
+		UserAgent: req.Header.Get("User-Agent"),
+	}
+	return evaluator
+}
+
+func newNamespaceRuleVars(namespaceAd server_structs.NamespaceAdV2) namespaceRuleVars {
+	return namespaceRuleVars{
+		Path:        namespaceAd.Path,
+		PublicReads: namespaceAd.Caps.PublicReads,
+	}
+}
+
+func newServerRuleVars(ad server_structs.ServerAd) serverRuleVars {
+	return serverRuleVars{
+		Name:         ad.Name,
+		Type:         string(ad.Type),
+		Host:         ad.URL.Hostname(),
+		URL:          ad.URL.String(),
+		Latitude:     ad.Latitude,
+		Longitude:    ad.Longitude,
+		FromTopology: ad.FromTopology,
+	}
+}
+
+func (rule *compiledRedirectRule) eval(prog *ruleProgram, vars map[string]any) bool {
+	result, err := prog.evalBool(vars)
+	if err != nil {
+		log.Debugf("Failed to evaluate %q of redirect rule %s: %v", prog.source, rule.name, err)
+		metrics.PelicanDirectorRedirectRuleErrors.WithLabelValues(rule.name).Inc()
+		return false
+	}
+	return result
+}
+
+// The rules with an expression for the action applying to the request
+func (evaluator *redirectRuleEvaluator) applicableRules(namespaceAd server_structs.NamespaceAdV2, action string) ([]*compiledRedirectRule, map[string]any) {
+	vars := map[string]any{"client": evaluator.client, "ns": newNamespaceRuleVars(namespaceAd)}
+	rules := []*compiledRedirectRule{}
+	for _, rule := range evaluator.rules {
+		if (action == redirectRuleActionExclude && rule.exclude == nil) || (action == redirectRuleActionPrefer && rule.prefer == nil) {
+			continue
+		}
+		if rule.cond == nil || rule.eval(rule.cond, vars) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, vars
+}
+
+// Remove the servers excluded by any applicable rule
+func (evaluator *redirectRuleEvaluator) exclude(namespaceAd server_structs.NamespaceAdV2, ads []server_structs.ServerAd) []server_structs.ServerAd {
+	if len(evaluator.rules) == 0 || len(ads) == 0 {
+		return ads
+	}
+	rules, vars := evaluator.applicableRules(namespaceAd, redirectRuleActionExclude)
+	if len(rules) == 0 {
+		return ads
+	}
+	kept := make([]server_structs.ServerAd, 0, len(ads))
+	for _, ad := range ads {
+		vars["server"] = newServerRuleVars(ad)
+		excluded := false
+		for _, rule := range rules {
+			if rule.eval(rule.exclude, vars) {
+				log.Debugf("Redirect rule %s excluded server %s", rule.name, ad.Name)
+				metrics.PelicanDirectorRedirectRuleHits.WithLabelValues(rule.name, redirectRuleActionExclude).Inc()
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			kept = append(kept, ad)
+		}
+	}
+	return kept
+}
+
+// Move the servers preferred by an applicable rule to the front, keeping their order
+// otherwise.  Servers preferred by earlier rules come first.
+func (evaluator *redirectRuleEvaluator) prefer(namespaceAd server_structs.NamespaceAdV2, ads []server_structs.ServerAd) []server_structs.ServerAd {
+	if len(evaluator.rules) == 0 || len(ads) < 2 {
+		return ads
+	}
+	rules, vars := evaluator.applicableRules(namespaceAd, redirectRuleActionPrefer)
+	if len(rules) == 0 {
+		return ads
+	}
+	ranks := make(map[int]int, len(ads))
+	for idx, ad := range ads {
+		vars["server"] = newServerRuleVars(ad)
+		ranks[idx] = len(rules)
+		for ruleIdx, rule := range rules {
+			if rule.eval(rule.prefer, vars) {
+				metrics.PelicanDirectorRedirectRuleHits.WithLabelValues(rule.name, redirectRuleActionPrefer).Inc()
+				ranks[idx] = ruleIdx
+				break
+			}
+		}
+	}
+	order := make([]int, len(ads))
+	for idx := range order {
+		order[idx] = idx
+	}
+	sort.SliceStable(order, func(left, right int) bool {
+		return ranks[order[left]] < ranks[order[right]]
+	})
+	preferred := make([]server_structs.ServerAd, len(ads))
+	for idx, adIdx := range order {
+		preferred[idx] = ads[adIdx]
+	}
+	return preferred
+}
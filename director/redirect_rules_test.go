@@ -0,0 +1,119 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+func TestRedirectRules(t *testing.T) {
+	t.Cleanup(func() {
+		viper.Reset()
+		resetRedirectRules()
+	})
+
+	newAd := func(name string, serverType server_structs.ServerType) server_structs.ServerAd {
+		return server_structs.ServerAd{Name: name, Type: serverType, URL: url.URL{Scheme: "https", Host: name + ".example.org:8443"}}
+	}
+	euCache := newAd("eu-cache", server_structs.CacheType)
+	usCache := newAd("us-cache", server_structs.CacheType)
+	campusCache := newAd("campus-cache", server_structs.CacheType)
+	origin := newAd("origin", server_structs.OriginType)
+	ads := []server_structs.ServerAd{euCache, usCache, campusCache, origin}
+	namespaceAd := server_structs.NamespaceAdV2{Path: "/foo"}
+
+	evaluatorFor := func(t *testing.T, rules []map[string]any, clientIP string) *redirectRuleEvaluator {
+		viper.Reset()
+		resetRedirectRules()
+		viper.Set("Director.RedirectRules", rules)
+		req := httptest.NewRequest("GET", "/api/v1.0/director/object/foo/bar", nil)
+		req.Header.Set("User-Agent", "pelican-client/7.10.0")
+		return newRedirectRuleEvaluator(req, netip.MustParseAddr(clientIP))
+	}
+
+	t.Run("no-rules", func(t *testing.T) {
+		evaluator := evaluatorFor(t, nil, "192.0.2.1")
+		assert.Equal(t, ads, evaluator.exclude(namespaceAd, ads))
+		assert.Equal(t, ads, evaluator.prefer(namespaceAd, ads))
+	})
+
+	t.Run("exclude-for-matching-clients", func(t *testing.T) {
+		rules := []map[string]any{{
+			"Name":    "campus-no-eu",
+			"If":      `cidr("192.0.2.0/24").containsIP(client.ip) && ns.path == "/foo"`,
+			"Exclude": `server.type == "Cache" && server.name.startsWith("eu-")`,
+		}}
+		before := testutil.ToFloat64(metrics.PelicanDirectorRedirectRuleHits.WithLabelValues("campus-no-eu", "exclude"))
+
+		evaluator := evaluatorFor(t, rules, "192.0.2.1")
+		assert.Equal(t, []server_structs.ServerAd{usCache, campusCache, origin}, evaluator.exclude(namespaceAd, ads))
+		assert.Equal(t, 1.0, testutil.ToFloat64(metrics.PelicanDirectorRedirectRuleHits.WithLabelValues("campus-no-eu", "exclude"))-before)
+
+		// Other clients and namespaces aren't affected
+		evaluator = evaluatorFor(t, rules, "198.51.100.1")
+		assert.Equal(t, ads, evaluator.exclude(namespaceAd, ads))
+		evaluator = evaluatorFor(t, rules, "192.0.2.1")
+		assert.Equal(t, ads, evaluator.exclude(server_structs.NamespaceAdV2{Path: "/bar"}, ads))
+	})
+
+	t.Run("prefer", func(t *testing.T) {
+		rules := []map[string]any{
+			{"Name": "campus-first", "Prefer": `server.host == "campus-cache.example.org"`},
+			{"Name": "us-second", "Prefer": `server.name.startsWith("us-") || server.name.startsWith("campus-")`},
+		}
+		evaluator := evaluatorFor(t, rules, "192.0.2.1")
+		assert.Equal(t, []server_structs.ServerAd{campusCache, usCache, euCache, origin}, evaluator.prefer(namespaceAd, ads))
+	})
+
+	t.Run("client-attributes", func(t *testing.T) {
+		rules := []map[string]any{{
+			"Name":    "old-clients-use-origins",
+			"If":      `client.version.startsWith("7.") && client.user_agent.contains("pelican-client")`,
+			"Exclude": `server.type == "Cache"`,
+		}}
+		evaluator := evaluatorFor(t, rules, "192.0.2.1")
+		assert.Equal(t, []server_structs.ServerAd{origin}, evaluator.exclude(namespaceAd, ads))
+	})
+
+	t.Run("invalid-and-failing-rules", func(t *testing.T) {
+		rules := []map[string]any{
+			{"Name": "does-not-compile", "Exclude": `server.name ==`},
+			{"Name": "unknown-field", "Exclude": `server.missing == 1`},
+			{"Name": "no-action", "If": `true`},
+			{"Exclude": `true`},
+			{"Name": "fails", "Exclude": `server.name.matches(server.type + "[")`},
+		}
+		before := testutil.ToFloat64(metrics.PelicanDirectorRedirectRuleErrors.WithLabelValues("fails"))
+		evaluator := evaluatorFor(t, rules, "192.0.2.1")
+		require.Len(t, evaluator.rules, 1)
+		assert.Equal(t, ads, evaluator.exclude(namespaceAd, ads))
+		assert.Equal(t, float64(len(ads)), testutil.ToFloat64(metrics.PelicanDirectorRedirectRuleErrors.WithLabelValues("fails"))-before)
+	})
+}
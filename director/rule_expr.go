@@ -0,0 +1,246 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+// The redirect rules of the director are CEL expressions (https://github.com/google/cel-spec)
+// over the client, the namespace and each server.  They're type-checked against an
+// environment declaring the fields of each, plus the cidr() and containsIP() functions, so
+// mistakes like unknown fields are caught when the rules are loaded.
+
+import (
+	"net/netip"
+	"reflect"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/ext"
+	"github.com/pkg/errors"
+)
+
+type (
+	// The client of a redirect, as seen by rule expressions
+	clientRuleVars struct {
+		IP        string `cel:"ip"`
+		Country   string `cel:"country"`
+		Version   string `cel:"version"`
+		UserAgent string `cel:"user_agent"`
+	}
+
+	// The namespace of a redirect, as seen by rule expressions
+	namespaceRuleVars struct {
+		Path        string `cel:"path"`
+		PublicReads bool   `cel:"public_reads"`
+	}
+
+	// A server a client may be redirected to, as seen by rule expressions
+	serverRuleVars struct {
+		Name         string  `cel:"name"`
+		Type         string  `cel:"type"`
+		Host         string  `cel:"host"`
+		URL          string  `cel:"url"`
+		Latitude     float64 `cel:"latitude"`
+		Longitude    float64 `cel:"longitude"`
+		FromTopology bool    `cel:"from_topology"`
+	}
+
+	// The value of cidr(), a network clients' addresses can be checked against
+	cidrValue struct {
+		prefix netip.Prefix
+	}
+
+	// Rejects the cidr() calls whose literal argument isn't a network
+	cidrLiteralValidator struct{}
+
+	// A compiled rule expression
+	ruleProgram struct {
+		source  string
+		program cel.Program
+	}
+)
+
+// The largest cost, as estimated by CEL, of evaluating a rule for one server
+const ruleCostLimit = 100000
+
+var (
+	cidrType = cel.OpaqueType("net.CIDR")
+
+	// The types of the variables rule expressions may reference
+	ruleVarTypes = map[string]reflect.Type{
+		"client": reflect.TypeOf(clientRuleVars{}),
+		"ns":     reflect.TypeOf(namespaceRuleVars{}), // "namespace" is reserved by CEL
+		"server": reflect.TypeOf(serverRuleVars{}),
+	}
+
+	ruleEnvOnce sync.Once
+	ruleEnv     *cel.Env
+	ruleEnvErr  error
+)
+
+func (val cidrValue) ConvertToNative(typeDesc reflect.Type) (any, error) {
+	if reflect.TypeOf(val.prefix).AssignableTo(typeDesc) {
+		return val.prefix, nil
+	}
+	return nil, errors.Errorf("can't convert a network to %v", typeDesc)
+}
+
+func (val cidrValue) ConvertToType(typeVal ref.Type) ref.Val {
+	switch typeVal {
+	case types.StringType:
+		return types.String(val.prefix.String())
+	case types.TypeType:
+		return cidrType
+	}
+	return types.NewErr("can't convert a network to %s", typeVal.TypeName())
+}
+
+func (val cidrValue) Equal(other ref.Val) ref.Val {
+	otherCidr, ok := other.(cidrValue)
+	return types.Bool(ok && otherCidr.prefix == val.prefix)
+}
+
+func (val cidrValue) Type() ref.Type {
+	return cidrType
+}
+
+func (val cidrValue) Value() any {
+	return val.prefix
+}
+
+func (cidrLiteralValidator) Name() string {
+	return "pelican.director.validate.cidr"
+}
+
+func (cidrLiteralValidator) Validate(_ *cel.Env, _ cel.ValidatorConfig, checked *ast.AST, iss *cel.Issues) {
+	root := ast.NavigateAST(checked)
+	for _, call := range ast.MatchDescendants(root, ast.FunctionMatcher("cidr")) {
+		args := call.AsCall().Args()
+		if len(args) != 1 || args[0].Kind() != ast.LiteralKind {
+			continue
+		}
+		network, ok := args[0].AsLiteral().Value().(string)
+		if !ok {
+			continue
+		}
+		if _, err := netip.ParsePrefix(network); err != nil {
+			iss.ReportErrorAtID(args[0].ID(), "invalid network %q: %v", network, err)
+		}
+	}
+}
+
+func parseCidr(arg ref.Val) ref.Val {
+	network, ok := arg.(types.String)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(arg)
+	}
+	prefix, err := netip.ParsePrefix(string(network))
+	if err != nil {
+		return types.NewErr("invalid network %q: %v", string(network), err)
+	}
+	return cidrValue{prefix: prefix.Masked()}
+}
+
+func cidrContainsIP(lhs, rhs ref.Val) ref.Val {
+	network, ok := lhs.(cidrValue)
+	ip, isStr := rhs.(types.String)
+	if !ok || !isStr {
+		return types.MaybeNoSuchOverloadErr(rhs)
+	}
+	addr, err := netip.ParseAddr(string(ip))
+	if err != nil {
+		return types.False
+	}
+	return types.Bool(network.prefix.Contains(addr.Unmap()))
+}
+
+// The environment shared by all rule expressions, declaring their types and functions
+// but none of their variables
+func getRuleEnv() (*cel.Env, error) {
+	ruleEnvOnce.Do(func() {
+		ruleEnv, ruleEnvErr = cel.NewEnv(
+			ext.NativeTypes(
+				ext.ParseStructTags(true),
+				ruleVarTypes["client"],
+				ruleVarTypes["ns"],
+				ruleVarTypes["server"],
+			),
+			cel.CrossTypeNumericComparisons(true),
+			cel.Function("cidr",
+				cel.Overload("cidr_string", []*cel.Type{cel.StringType}, cidrType, cel.UnaryBinding(parseCidr)),
+			),
+			cel.Function("containsIP",
+				cel.MemberOverload("cidr_containsIP_string", []*cel.Type{cidrType, cel.StringType}, cel.BoolType,
+					cel.BinaryBinding(cidrContainsIP)),
+			),
+			cel.ASTValidators(
+				cel.ValidateRegexLiterals(),
+				cel.ValidateHomogeneousAggregateLiterals(),
+				cidrLiteralValidator{},
+			),
+		)
+	})
+	return ruleEnv, ruleEnvErr
+}
+
+// Compile a rule expression that may reference the given variables
+func compileRuleExpr(source string, vars ...string) (*ruleProgram, error) {
+	env, err := getRuleEnv()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the environment of redirect rules")
+	}
+	decls := make([]cel.EnvOption, 0, len(vars))
+	for _, name := range vars {
+		varType, ok := ruleVarTypes[name]
+		if !ok {
+			return nil, errors.Errorf("unknown rule variable %s", name)
+		}
+		decls = append(decls, cel.Variable(name, cel.ObjectType("director."+varType.Name())))
+	}
+	if env, err = env.Extend(decls...); err != nil {
+		return nil, err
+	}
+
+	checked, iss := env.Compile(source)
+	if iss.Err() != nil {
+		return nil, iss.Err()
+	}
+	if !checked.OutputType().IsExactType(cel.BoolType) {
+		return nil, errors.Errorf("the expression results in a %s, not a bool", checked.OutputType())
+	}
+	program, err := env.Program(checked, cel.EvalOptions(cel.OptOptimize), cel.CostLimit(ruleCostLimit))
+	if err != nil {
+		return nil, err
+	}
+	return &ruleProgram{source: source, program: program}, nil
+}
+
+// Evaluate the expression over the variables it was compiled for
+func (prog *ruleProgram) evalBool(vars map[string]any) (bool, error) {
+	val, _, err := prog.program.Eval(vars)
+	if err != nil {
+		return false, err
+	}
+	result, ok := val.Value().(bool)
+	if !ok {
+		return false, errors.Errorf("the expression resulted in a %s, not a bool", val.Type().TypeName())
+	}
+	return result, nil
+}
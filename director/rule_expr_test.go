@@ -0,0 +1,100 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuleExpr(t *testing.T) {
+	vars := map[string]any{
+		"client": clientRuleVars{IP: "192.0.2.17", Country: "US", Version: "7.10.0"},
+		"ns":     namespaceRuleVars{Path: "/ligo/public", PublicReads: true},
+		"server": serverRuleVars{Name: "eu-cache-1", Type: "Cache", Host: "cache.example.eu", Latitude: 52.5},
+	}
+	for _, test := range []struct {
+		expr   string
+		result bool
+	}{
+		{`client.country == "US"`, true},
+		{`client.country != 'US'`, false},
+		{`ns.public_reads && server.name.startsWith("eu-")`, true},
+		{`ns.path == "/ligo" || server.type == "Origin"`, false},
+		{`!(server.type == "Origin")`, true},
+		{`server.latitude > 50 && server.latitude <= 52.5`, true},
+		{`server.longitude == 0.0`, true},
+		{`client.country in ["US", "CA"]`, true},
+		{`size(server.name) == 10 && size(["ssd", "10g"]) == 2`, true},
+		{`server.name.endsWith("-1") && server.name.contains("cache")`, true},
+		{`server.name.matches("^eu-cache-[0-9]+$")`, true},
+		{`cidr("192.0.2.0/24").containsIP(client.ip)`, true},
+		{`cidr("198.51.100.0/24").containsIP(client.ip)`, false},
+		{`cidr("192.0.2.0/24").containsIP("not-an-ip")`, false},
+		{`server.name + "." + client.country == "eu-cache-1.US"`, true},
+		{`client.country == "US" ? server.type == "Cache" : false`, true},
+	} {
+		t.Run(test.expr, func(t *testing.T) {
+			prog, err := compileRuleExpr(test.expr, "client", "ns", "server")
+			require.NoError(t, err)
+			result, err := prog.evalBool(vars)
+			require.NoError(t, err)
+			assert.Equal(t, test.result, result)
+		})
+	}
+}
+
+func TestRuleExprErrors(t *testing.T) {
+	t.Run("compile", func(t *testing.T) {
+		for _, expr := range []string{
+			`client.country ==`,
+			`client.country == "US`,
+			`(client.country == "US"`,
+			`server.type == "Cache"`, // server isn't declared
+			`client.missing == 1`,    // client has no such field
+			`client.country > 1`,
+			`client.country`, // not a bool
+			`client.country.unknown("x")`,
+			`client.country.startsWith(1)`,
+			`client.country.matches("[")`,
+			`cidr("not-a-network").containsIP(client.ip)`,
+			`client.country in ["US", 1]`,
+			`client.country == "US" "CA"`,
+			`client.country # "US"`,
+		} {
+			_, err := compileRuleExpr(expr, "client")
+			assert.Error(t, err, expr)
+		}
+	})
+
+	t.Run("eval", func(t *testing.T) {
+		vars := map[string]any{"client": clientRuleVars{Country: "US"}}
+		for _, expr := range []string{
+			`cidr(client.country).containsIP("192.0.2.1")`,
+			`client.country.matches(client.country + "[")`,
+		} {
+			prog, err := compileRuleExpr(expr, "client")
+			require.NoError(t, err, expr)
+			_, err = prog.evalBool(vars)
+			assert.Error(t, err, expr)
+		}
+	})
+}
@@ -0,0 +1,203 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+const (
+	// How often the endpoints of servers that publish more than one are checked
+	serverEndpointCheckInterval = time.Minute
+	serverEndpointCheckTimeout  = 5 * time.Second
+)
+
+var (
+	// The endpoints (ad URLs) of the topology servers that publish more than one, e.g. an
+	// IPv4 address and a hostname, by logical server.  Each endpoint has its own ad, but
+	// redirects go to one healthy endpoint of a logical server at a time.
+	serverEndpoints = map[string][]string{}
+	// Whether each of those endpoints passed its last check; unchecked endpoints are healthy
+	endpointHealth       = map[string]bool{}
+	serverEndpointsMutex = sync.RWMutex{}
+
+	// The round-robin counters of the logical servers, by logical server
+	endpointRoundRobin sync.Map
+)
+
+// Topology servers are identified by their resource name, which every endpoint shares
+func logicalServerKey(ad server_structs.ServerAd) string {
+	return string(ad.Type) + "/" + ad.Name
+}
+
+// Record the endpoints of the topology servers, keeping the logical servers with more than one
+func setServerEndpoints(ads []server_structs.ServerAd) {
+	groups := map[string][]string{}
+	for _, ad := range ads {
+		if !ad.FromTopology || ad.Name == "" {
+			continue
+		}
+		key := logicalServerKey(ad)
+		groups[key] = append(groups[key], ad.URL.String())
+	}
+
+	serverEndpointsMutex.Lock()
+	defer serverEndpointsMutex.Unlock()
+	serverEndpoints = map[string][]string{}
+	current := map[string]bool{}
+	for key, urls := range groups {
+		if len(urls) < 2 {
+			continue
+		}
+		sort.Strings(urls)
+		serverEndpoints[key] = urls
+		for _, serverUrl := range urls {
+			current[serverUrl] = true
+		}
+	}
+	for serverUrl := range endpointHealth {
+		if !current[serverUrl] {
+			delete(endpointHealth, serverUrl)
+		}
+	}
+}
+
+// Check whether each endpoint of the logical servers accepts connections
+func checkServerEndpoints(ctx context.Context) {
+	serverEndpointsMutex.RLock()
+	ads := []server_structs.ServerAd{}
+	for _, urls := range serverEndpoints {
+		for _, serverUrl := range urls {
+			if item := serverAds.Get(serverUrl); item != nil {
+				ads = append(ads, item.Value().ServerAd)
+			}
+		}
+	}
+	serverEndpointsMutex.RUnlock()
+
+	results := make([]bool, len(ads))
+	var wg sync.WaitGroup
+	for idx, ad := range ads {
+		wg.Add(1)
+		go func(idx int, ad server_structs.ServerAd) {
+			defer wg.Done()
+			host := ad.URL.Host
+			if ad.URL.Port() == "" {
+				port := "443"
+				if ad.URL.Scheme == "http" {
+					port = "80"
+				}
+				host = net.JoinHostPort(ad.URL.Hostname(), port)
+			}
+			dialer := net.Dialer{Timeout: serverEndpointCheckTimeout}
+			conn, err := dialer.DialContext(ctx, "tcp", host)
+			if err != nil {
+				log.Debugf("Endpoint %s of %s server %s failed its check: %v", ad.URL.String(), ad.Type, ad.Name, err)
+				return
+			}
+			conn.Close()
+			results[idx] = true
+		}(idx, ad)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return
+	}
+
+	serverEndpointsMutex.Lock()
+	defer serverEndpointsMutex.Unlock()
+	for idx, ad := range ads {
+		serverUrl := ad.URL.String()
+		if healthy, checked := endpointHealth[serverUrl]; checked && healthy != results[idx] {
+			log.Infof("Endpoint %s of %s server %s is now healthy=%t", serverUrl, ad.Type, ad.Name, results[idx])
+		}
+		endpointHealth[serverUrl] = results[idx]
+	}
+}
+
+// Periodically check the endpoints of the servers that publish more than one
+func LaunchServerEndpointChecks(ctx context.Context, egrp *errgroup.Group) {
+	egrp.Go(func() error {
+		ticker := time.NewTicker(serverEndpointCheckInterval)
+		defer ticker.Stop()
+		for {
+			checkServerEndpoints(ctx)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+// Replace the endpoints of each logical server in the sorted ads by a single endpoint, at
+// the position of the best-sorted one.  The endpoint is picked round-robin among the
+// healthy endpoints of the server in the list, or among all of them if none is healthy.
+func collapseServerEndpoints(ads []server_structs.ServerAd) []server_structs.ServerAd {
+	serverEndpointsMutex.RLock()
+	defer serverEndpointsMutex.RUnlock()
+	if len(serverEndpoints) == 0 {
+		return ads
+	}
+
+	result := make([]server_structs.ServerAd, 0, len(ads))
+	picked := map[string]bool{}
+	for _, ad := range ads {
+		key := logicalServerKey(ad)
+		if !ad.FromTopology || len(serverEndpoints[key]) < 2 {
+			result = append(result, ad)
+			continue
+		}
+		if picked[key] {
+			continue
+		}
+		picked[key] = true
+
+		candidates := []server_structs.ServerAd{}
+		healthy := []server_structs.ServerAd{}
+		for _, other := range ads {
+			if !other.FromTopology || logicalServerKey(other) != key {
+				continue
+			}
+			candidates = append(candidates, other)
+			if isHealthy, checked := endpointHealth[other.URL.String()]; !checked || isHealthy {
+				healthy = append(healthy, other)
+			}
+		}
+		if len(healthy) == 0 {
+			healthy = candidates
+		}
+		sort.Slice(healthy, func(i, j int) bool { return healthy[i].URL.String() < healthy[j].URL.String() })
+		counter, _ := endpointRoundRobin.LoadOrStore(key, &atomic.Uint64{})
+		next := counter.(*atomic.Uint64).Add(1) - 1
+		result = append(result, healthy[next%uint64(len(healthy))])
+	}
+	return result
+}
@@ -0,0 +1,108 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+func TestCollapseServerEndpoints(t *testing.T) {
+	t.Cleanup(func() {
+		setServerEndpoints(nil)
+		endpointRoundRobin.Delete(string(server_structs.CacheType) + "/SITE_CACHE")
+	})
+
+	siteIP := server_structs.ServerAd{Name: "SITE_CACHE", Type: server_structs.CacheType, FromTopology: true, URL: url.URL{Scheme: "http", Host: "192.0.2.1:8000"}}
+	siteHost := server_structs.ServerAd{Name: "SITE_CACHE", Type: server_structs.CacheType, FromTopology: true, URL: url.URL{Scheme: "http", Host: "cache.site.org:8000"}}
+	other := server_structs.ServerAd{Name: "OTHER_CACHE", Type: server_structs.CacheType, FromTopology: true, URL: url.URL{Scheme: "http", Host: "cache.other.org:8000"}}
+	pelican := server_structs.ServerAd{Name: "SITE_CACHE", Type: server_structs.CacheType, URL: url.URL{Scheme: "https", Host: "pelican.site.org:8443"}}
+	setServerEndpoints([]server_structs.ServerAd{siteIP, siteHost, other})
+
+	t.Run("round-robin", func(t *testing.T) {
+		ads := []server_structs.ServerAd{other, siteHost, pelican, siteIP}
+		first := collapseServerEndpoints(ads)
+		second := collapseServerEndpoints(ads)
+		require.Len(t, first, 3)
+		require.Len(t, second, 3)
+		// The logical server keeps the position of its best-sorted endpoint
+		assert.Equal(t, other, first[0])
+		assert.Equal(t, pelican, first[2])
+		assert.ElementsMatch(t, []server_structs.ServerAd{siteIP, siteHost}, []server_structs.ServerAd{first[1], second[1]})
+	})
+
+	t.Run("unhealthy-endpoints-are-skipped", func(t *testing.T) {
+		serverEndpointsMutex.Lock()
+		endpointHealth[siteIP.URL.String()] = false
+		serverEndpointsMutex.Unlock()
+		for i := 0; i < 3; i++ {
+			result := collapseServerEndpoints([]server_structs.ServerAd{siteIP, siteHost})
+			require.Len(t, result, 1)
+			assert.Equal(t, siteHost, result[0])
+		}
+	})
+
+	t.Run("all-unhealthy", func(t *testing.T) {
+		serverEndpointsMutex.Lock()
+		endpointHealth[siteHost.URL.String()] = false
+		serverEndpointsMutex.Unlock()
+		assert.Len(t, collapseServerEndpoints([]server_structs.ServerAd{siteIP, siteHost}), 1)
+	})
+
+	t.Run("single-endpoint-servers-are-untouched", func(t *testing.T) {
+		setServerEndpoints([]server_structs.ServerAd{siteIP, other})
+		ads := []server_structs.ServerAd{siteIP, siteHost, other}
+		assert.Equal(t, ads, collapseServerEndpoints(ads))
+	})
+}
+
+func TestCheckServerEndpoints(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	up := server_structs.ServerAd{Name: "SITE_ORIGIN", Type: server_structs.OriginType, FromTopology: true, URL: url.URL{Scheme: "http", Host: listener.Addr().String()}}
+	down := server_structs.ServerAd{Name: "SITE_ORIGIN", Type: server_structs.OriginType, FromTopology: true, URL: url.URL{Scheme: "http", Host: closedAddr}}
+	for _, ad := range []server_structs.ServerAd{up, down} {
+		serverAds.Set(ad.URL.String(), &server_structs.Advertisement{ServerAd: ad}, ttlcache.DefaultTTL)
+	}
+	setServerEndpoints([]server_structs.ServerAd{up, down})
+	t.Cleanup(func() {
+		serverAds.DeleteAll()
+		setServerEndpoints(nil)
+	})
+
+	checkServerEndpoints(context.Background())
+	serverEndpointsMutex.RLock()
+	defer serverEndpointsMutex.RUnlock()
+	assert.True(t, endpointHealth[up.URL.String()])
+	assert.False(t, endpointHealth[down.URL.String()])
+}
@@ -0,0 +1,110 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+// How long a signed server list is valid for.  Clients cache the list until it
+// expires, so this bounds how long a removed server remains trusted.
+const serverListLifetime = 10 * time.Minute
+
+// Return the host of a server URL as listed in the signed server list; the
+// default HTTPS port is dropped so both forms of a host compare equal
+func serverListHost(serverUrl url.URL) string {
+	if serverUrl.Scheme == "https" || serverUrl.Scheme == "" {
+		return strings.TrimSuffix(serverUrl.Host, ":443")
+	}
+	return serverUrl.Host
+}
+
+// List the hosts of all the origins and caches known to the director, sorted
+func listServerHosts() []string {
+	hosts := []string{}
+	for _, item := range serverAds.Items() {
+		ad := item.Value()
+		for _, serverUrl := range []url.URL{ad.URL, ad.AuthURL} {
+			if host := serverListHost(serverUrl); host != "" && !slices.Contains(hosts, host) {
+				hosts = append(hosts, host)
+			}
+		}
+	}
+	slices.Sort(hosts)
+	return hosts
+}
+
+// Create a JWT, signed with the director's issuer key, listing the hosts of the
+// federation's origins and caches
+func createSignedServerList() (string, error) {
+	key, err := config.GetIssuerPrivateJWK()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to load the director's private key")
+	}
+	if err = jwk.AssignKeyID(key); err != nil {
+		return "", errors.Wrap(err, "failed to assign kid to the server list")
+	}
+
+	now := time.Now()
+	tok, err := jwt.NewBuilder().
+		Issuer(param.Server_ExternalWebUrl.GetString()).
+		IssuedAt(now).
+		NotBefore(now).
+		Expiration(now.Add(serverListLifetime)).
+		Claim(server_structs.ServerListClaim, listServerHosts()).
+		Build()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to build the server list")
+	}
+	signed, err := jwt.Sign(tok, jwt.WithKey(jwa.ES256, key))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to sign the server list")
+	}
+	return string(signed), nil
+}
+
+// Serve the signed list of the federation's servers.  Clients that verify server
+// identity only send their tokens to the hosts in this list.
+func getSignedServerList(ctx *gin.Context) {
+	signed, err := createSignedServerList()
+	if err != nil {
+		log.Errorln("Failed to create the signed server list:", err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Failed to create the signed server list",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, server_structs.SignedServerListRes{Token: signed})
+}
@@ -0,0 +1,79 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+func TestSignedServerList(t *testing.T) {
+	viper.Reset()
+	serverAds.DeleteAll()
+	t.Cleanup(func() {
+		viper.Reset()
+		serverAds.DeleteAll()
+	})
+	viper.Set("Server.ExternalWebUrl", "https://fake-director.org:8888")
+	viper.Set("IssuerKey", filepath.Join(t.TempDir(), "testKey"))
+
+	for _, ad := range []server_structs.ServerAd{
+		{
+			Name:    "origin",
+			Type:    server_structs.OriginType,
+			URL:     url.URL{Scheme: "https", Host: "origin.example.com:8443"},
+			AuthURL: url.URL{Scheme: "https", Host: "origin-auth.example.com:8444"},
+		},
+		{
+			Name: "cache",
+			Type: server_structs.CacheType,
+			URL:  url.URL{Scheme: "https", Host: "cache.example.com:443"},
+		},
+		{
+			Name: "topology-origin",
+			Type: server_structs.OriginType,
+			URL:  url.URL{Scheme: "http", Host: "topology.example.com:1094"},
+		},
+	} {
+		serverAds.Set(ad.URL.String(), &server_structs.Advertisement{ServerAd: ad}, 0)
+	}
+
+	expected := []string{"cache.example.com", "origin-auth.example.com:8444", "origin.example.com:8443", "topology.example.com:1094"}
+	assert.Equal(t, expected, listServerHosts())
+
+	signed, err := createSignedServerList()
+	require.NoError(t, err)
+	keys, err := config.GetIssuerPublicJWKS()
+	require.NoError(t, err)
+	tok, err := jwt.Parse([]byte(signed), jwt.WithKeySet(keys), jwt.WithValidate(true))
+	require.NoError(t, err)
+	assert.Equal(t, "https://fake-director.org:8888", tok.Issuer())
+	hosts, ok := tok.Get(server_structs.ServerListClaim)
+	require.True(t, ok)
+	assert.ElementsMatch(t, expected, hosts)
+}
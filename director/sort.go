@@ -25,7 +25,7 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"math/rand"
+	"math"
 	"net"
 	"net/http"
 	"net/netip"
@@ -49,6 +49,8 @@ import (
 
 const (
 	maxMindURL string = "https://download.maxmind.com/app/geoip_download?edition_id=GeoLite2-City&license_key=%s&suffix=tar.gz"
+
+	earthRadiusKm float64 = 6371
 )
 
 var (
@@ -74,6 +76,11 @@ type GeoIPOverride struct {
 	Coordinate Coordinate `mapstructure:"Coordinate"`
 }
 
+type CacheRegion struct {
+	Name    string   `mapstructure:"Name"`
+	Servers []string `mapstructure:"Servers"`
+}
+
 var invalidOverrideLogOnce = map[string]bool{}
 var geoIPOverrides []GeoIPOverride
 
@@ -147,12 +154,7 @@ func getLatLong(addr netip.Addr) (lat float64, long float64, err error) {
 		return override.Lat, override.Long, nil
 	}
 
-	reader := maxMindReader.Load()
-	if reader == nil {
-		err = errors.New("No GeoIP database is available")
-		return
-	}
-	record, err := reader.City(ip)
+	record, err := lookupGeoIP(addr)
 	if err != nil {
 		return
 	}
@@ -165,53 +167,45 @@ func getLatLong(addr netip.Addr) (lat float64, long float64, err error) {
 	return
 }
 
-func getClientLatLong(addr netip.Addr) (coord Coordinate, ok bool) {
-	var err error
-	coord.Lat, coord.Long, err = getLatLong(addr)
-	ok = (err == nil && !(coord.Lat == 0 && coord.Long == 0))
-	if err != nil {
-		log.Warningf("failed to resolve lat/long for address %s: %v", addr, err)
+func lookupGeoIP(addr netip.Addr) (*geoip2.City, error) {
+	reader := maxMindReader.Load()
+	if reader == nil {
+		return nil, errors.New("No GeoIP database is available")
 	}
-	return
+	return reader.City(net.IP(addr.AsSlice()))
 }
 
-// Sort serverAds based on the IP address of the client with shorter distance between
-// server IP and client having higher priority
+// Sort serverAds for the client at the given IP address with the method selected by
+// Director.CacheSortMethod, by default with shorter distance between server and client
+// having higher priority
 func sortServerAdsByIP(addr netip.Addr, ads []server_structs.ServerAd) ([]server_structs.ServerAd, error) {
+	method, err := getSortMethod()
+	if err != nil {
+		return nil, err
+	}
+
 	// Each entry in weights will map a priority to an index in the original ads slice.
 	// A larger weight is a higher priority.
+	req := &SortRequest{ClientAddr: addr}
+	methodWeights := method.Weigh(req, ads)
+	if len(methodWeights) != len(ads) {
+		return nil, errors.Errorf("sort method '%s' weighed %d of %d servers",
+			param.Director_CacheSortMethod.GetString(), len(methodWeights), len(ads))
+	}
 	weights := make(SwapMaps, len(ads))
-	sortMethod := param.Director_CacheSortMethod.GetString()
+	for idx, weight := range methodWeights {
+		weights[idx] = SwapMap{weight, idx}
+	}
 
-	// For each ad, we apply the configured sort method to determine a priority weight.
-	for idx, ad := range ads {
-		switch sortMethod {
-		case "distance":
-			clientCoord, ok := getClientLatLong(addr)
-			if !ok {
-				// Unable to compute distances for this server; just do random distances.
-				// Below we sort weights in descending order, so we assign negative value here,
-				// causing them to always be at the end of the sorted list.
-				weights[idx] = SwapMap{0 - rand.Float64(), idx}
-			} else {
-				weights[idx] = SwapMap{distanceWeight(clientCoord, ad),
-					idx}
-			}
-		case "distanceAndLoad":
-			clientCoord, ok := getClientLatLong(addr)
-			if !ok {
-				weights[idx] = SwapMap{0 - rand.Float64(), idx}
-			} else {
-				// Each server ad will have a load value that we can use for sorting
-				weights[idx] = SwapMap{distanceAndLoadWeight(clientCoord, ad),
-					idx}
-			}
-		case "random":
-			weights[idx] = SwapMap{rand.Float64(), idx}
-		default:
-			return nil, errors.Errorf("Invalid sort method '%s' set in Director.CacheSortMethod. Valid methods are 'distance',"+
-				"'distanceAndLoad', and 'random.'", param.Director_CacheSortMethod.GetString())
-		}
+	// Servers approaching a scheduled downtime are drained by lowering their weight, as are
+	// servers readmitted after a downtime or restart until they pass a health test and,
+	// optionally, servers whose certificates are about to expire
+	now := time.Now()
+	for idx := range weights {
+		ad := ads[weights[idx].Index]
+		factor := getDowntimeDrainFactor(ad.Name, now) * getReadmissionFactor(ad.URL.String()) *
+			getCertExpiryFactor(ad, now)
+		weights[idx].Weight = applyDrainFactor(weights[idx].Weight, factor)
 	}
 
 	// Larger weight = higher priority, so we reverse the sort (which would otherwise default to ascending)
@@ -220,9 +214,85 @@ func sortServerAdsByIP(addr netip.Addr, ads []server_structs.ServerAd) ([]server
 	for idx, weight := range weights {
 		resultAds[idx] = ads[weight.Index]
 	}
+	resultAds = collapseServerEndpoints(resultAds)
+	if reorderer, ok := method.(SortReorderer); ok {
+		resultAds = reorderer.Reorder(req, resultAds)
+	}
 	return resultAds, nil
 }
 
+// Map each of the sorted ads to a region: the configured region from Director.CacheRegions
+// if the server is listed there, and otherwise a geographic region centered on the first
+// (i.e. nearest) unlisted server within Director.CacheRegionRadius.
+func getServerAdRegions(ads []server_structs.ServerAd) []string {
+	configured := make(map[string]string)
+	if param.Director_CacheRegions.IsSet() {
+		regions := []CacheRegion{}
+		if err := param.Director_CacheRegions.Unmarshal(&regions); err != nil {
+			log.Warningf("Error while unmarshaling Director.CacheRegions: %v", err)
+		}
+		for _, region := range regions {
+			for _, server := range region.Servers {
+				configured[server] = region.Name
+			}
+		}
+	}
+
+	radius := float64(param.Director_CacheRegionRadius.GetInt())
+	geoCenters := []server_structs.ServerAd{}
+	result := make([]string, len(ads))
+	for idx, ad := range ads {
+		if region, ok := configured[ad.Name]; ok {
+			result[idx] = "configured:" + region
+			continue
+		}
+		if ad.URL.Host != "" {
+			if region, ok := configured[ad.URL.Hostname()]; ok {
+				result[idx] = "configured:" + region
+				continue
+			}
+		}
+		// Servers without a known location can't be grouped
+		if ad.Latitude == 0 && ad.Longitude == 0 {
+			result[idx] = "unknown:" + ad.Name
+			continue
+		}
+		for centerIdx, center := range geoCenters {
+			if distanceOnSphere(center.Latitude, center.Longitude, ad.Latitude, ad.Longitude)*math.Pi*earthRadiusKm <= radius {
+				result[idx] = fmt.Sprintf("geo:%d", centerIdx)
+				break
+			}
+		}
+		if result[idx] == "" {
+			result[idx] = fmt.Sprintf("geo:%d", len(geoCenters))
+			geoCenters = append(geoCenters, ad)
+		}
+	}
+	return result
+}
+
+// Reorder sorted ads so the best ad from each of the first numRegions distinct regions
+// comes first, followed by the remaining ads in their original order.  If the client's
+// nearest region is having problems, its fallback is then in a different region.
+func sortServerAdsByRegion(ads []server_structs.ServerAd, numRegions int) []server_structs.ServerAd {
+	if numRegions <= 0 {
+		return ads
+	}
+	regions := getServerAdRegions(ads)
+	seen := make(map[string]bool)
+	leaders := make([]server_structs.ServerAd, 0, numRegions)
+	rest := make([]server_structs.ServerAd, 0, len(ads))
+	for idx, ad := range ads {
+		if len(leaders) < numRegions && !seen[regions[idx]] {
+			seen[regions[idx]] = true
+			leaders = append(leaders, ad)
+		} else {
+			rest = append(rest, ad)
+		}
+	}
+	return append(leaders, rest...)
+}
+
 // Sort a list of ServerAds with the following rule:
 // * if a ServerAds has FromTopology = true, then it will be moved to the end of the list
 // * if two ServerAds has the SAME FromTopology value (both true or false), then
@@ -68,17 +68,29 @@ func distanceWeight(coord Coordinate, ad server_structs.ServerAd) float64 {
 }
 
 // Create a weight between [0,1] that indicates a priority. The returned weight is directly correlated
-// with priority (higher weight is higher priority)
+// with priority (higher weight is higher priority), combining the distance and the load of the
+// server by Director.SortDistanceWeight and Director.SortLoadWeight
 func distanceAndLoadWeight(coord Coordinate, sAd server_structs.ServerAd) float64 {
 	distance := distanceOnSphere(coord.Lat, coord.Long, sAd.Latitude, sAd.Longitude)
-
-	// For now, load is always 0.5. Eventually we'll pull this value from the server ad. Furthermore,
-	// assume a server's load has more impact on its performance than its distance does. As long as load is
-	// hard coded, this function should act exactly like distanceWeight.
-	// TODO: Come up with a better function once we have an actual load value and know how it works
-	load := 0.5
-	a1 := 1.0 / 3.0
-	a2 := 2.0 / 3.0
+	load, ok := getServerLoad(sAd)
+	if !ok {
+		load = unknownServerLoad
+	}
+	a1, a2 := getDistanceAndLoadWeights()
 
 	return 1 - a1*distance - a2*load
 }
+
+// Scale a sorting weight by a drain factor between [0,1], where 1 leaves the weight
+// unchanged and smaller factors lower the priority.  Weights may be negative (e.g., the
+// random weights given to servers without a known location), so rather than scaling
+// those toward 0, they're shifted further down.
+func applyDrainFactor(weight float64, factor float64) float64 {
+	if factor >= 1 {
+		return weight
+	}
+	if weight >= 0 {
+		return weight * factor
+	}
+	return weight - (1 - factor)
+}
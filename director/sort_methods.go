@@ -0,0 +1,214 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+// The methods of sorting the servers the director redirects clients to are pluggable:
+// Director.CacheSortMethod names one registered with RegisterSortMethod.  A method
+// weighs each server for the client; the director then lowers the weights of the
+// servers being drained (see applyDrainFactor) and orders the servers by weight.
+//
+// A deployment building its own director can add a method from an init function:
+//
+//	func init() {
+//		if err := director.RegisterSortMethod("byName", byNameSortMethod{}); err != nil {
+//			panic(err)
+//		}
+//	}
+//
+// The "random" and "round-robin" methods below are written as examples of such plugins.
+
+import (
+	"cmp"
+	"math/rand"
+	"net/netip"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// A method of sorting the origins and caches the director redirects a client to
+	SortMethod interface {
+		// Weigh each of the servers for the client of the request; servers with larger
+		// weights are preferred.  Returns one weight per server, in the order of ads.
+		Weigh(req *SortRequest, ads []server_structs.ServerAd) []float64
+	}
+
+	// A sort method that reorders the servers after they're sorted by weight, e.g. to
+	// spread the first few servers over regions
+	SortReorderer interface {
+		SortMethod
+		Reorder(req *SortRequest, ads []server_structs.ServerAd) []server_structs.ServerAd
+	}
+
+	// The client a sort method weighs servers for
+	SortRequest struct {
+		ClientAddr netip.Addr
+
+		located bool
+		coord   Coordinate
+		found   bool
+	}
+
+	distanceSortMethod         struct{}
+	distanceAndLoadSortMethod  struct{}
+	nearestPerRegionSortMethod struct{}
+
+	// Sorts servers randomly, spreading the clients evenly over them regardless of
+	// where they are
+	randomSortMethod struct{}
+
+	// Sorts servers so each request starts from the next server in turn, always
+	// falling back in the same order.  The servers are ordered by URL, and the turn is
+	// shared by all clients.
+	roundRobinSortMethod struct {
+		turn atomic.Uint64
+	}
+)
+
+var (
+	sortMethodsMutex sync.RWMutex
+	sortMethods      = map[string]SortMethod{
+		"distance":         distanceSortMethod{},
+		"distanceAndLoad":  distanceAndLoadSortMethod{},
+		"nearestPerRegion": nearestPerRegionSortMethod{},
+		"random":           randomSortMethod{},
+		"round-robin":      &roundRobinSortMethod{},
+	}
+)
+
+// Register a method of sorting servers, selectable by its name in Director.CacheSortMethod.
+// Methods must be registered before the director starts, e.g. from an init function.
+func RegisterSortMethod(name string, method SortMethod) error {
+	if name == "" {
+		return errors.New("sort methods must have a name")
+	}
+	if method == nil {
+		return errors.Errorf("sort method %s is nil", name)
+	}
+	sortMethodsMutex.Lock()
+	defer sortMethodsMutex.Unlock()
+	if _, exists := sortMethods[name]; exists {
+		return errors.Errorf("a sort method named %s is already registered", name)
+	}
+	sortMethods[name] = method
+	return nil
+}
+
+// Get the sort method selected by Director.CacheSortMethod
+func getSortMethod() (SortMethod, error) {
+	name := param.Director_CacheSortMethod.GetString()
+	sortMethodsMutex.RLock()
+	defer sortMethodsMutex.RUnlock()
+	if method, ok := sortMethods[name]; ok {
+		return method, nil
+	}
+	names := make([]string, 0, len(sortMethods))
+	for registered := range sortMethods {
+		names = append(names, "'"+registered+"'")
+	}
+	sort.Strings(names)
+	return nil, errors.Errorf("Invalid sort method '%s' set in Director.CacheSortMethod. Valid methods are %s",
+		name, strings.Join(names, ", "))
+}
+
+// Locate the client of the request, returning false if it can't be located.  The client
+// is located once, on the first call.
+func (req *SortRequest) ClientLocation() (Coordinate, bool) {
+	if !req.located {
+		req.coord, req.found = getClientLatLong(req.ClientAddr)
+		req.located = true
+	}
+	return req.coord, req.found
+}
+
+// Weigh servers by a function of the client's location.  If the client can't be
+// located, the servers get random negative weights, keeping them behind the servers
+// whose weights the drain factors lowered.
+func weighByLocation(req *SortRequest, ads []server_structs.ServerAd, weigh func(Coordinate, server_structs.ServerAd) float64) []float64 {
+	weights := make([]float64, len(ads))
+	coord, ok := req.ClientLocation()
+	for idx, ad := range ads {
+		if !ok {
+			weights[idx] = 0 - rand.Float64()
+		} else {
+			weights[idx] = weigh(coord, ad)
+		}
+	}
+	return weights
+}
+
+func (distanceSortMethod) Weigh(req *SortRequest, ads []server_structs.ServerAd) []float64 {
+	return weighByLocation(req, ads, distanceWeight)
+}
+
+func (distanceAndLoadSortMethod) Weigh(req *SortRequest, ads []server_structs.ServerAd) []float64 {
+	return weighByLocation(req, ads, distanceAndLoadWeight)
+}
+
+// Sorted by distance; the nearest cache of each region is moved up by Reorder
+func (nearestPerRegionSortMethod) Weigh(req *SortRequest, ads []server_structs.ServerAd) []float64 {
+	return weighByLocation(req, ads, distanceWeight)
+}
+
+func (nearestPerRegionSortMethod) Reorder(_ *SortRequest, ads []server_structs.ServerAd) []server_structs.ServerAd {
+	return sortServerAdsByRegion(ads, param.Director_CacheRegionCount.GetInt())
+}
+
+func (randomSortMethod) Weigh(_ *SortRequest, ads []server_structs.ServerAd) []float64 {
+	weights := make([]float64, len(ads))
+	for idx := range weights {
+		weights[idx] = rand.Float64()
+	}
+	return weights
+}
+
+// The weights fall from 1 for the server whose turn it is to just above 0 for the server
+// before it, so servers lowered by drain factors still go behind the others
+func (method *roundRobinSortMethod) Weigh(_ *SortRequest, ads []server_structs.ServerAd) []float64 {
+	weights := make([]float64, len(ads))
+	if len(ads) == 0 {
+		return weights
+	}
+	order := make([]int, len(ads))
+	for idx := range order {
+		order[idx] = idx
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		if byURL := cmp.Compare(ads[a].URL.String(), ads[b].URL.String()); byURL != 0 {
+			return byURL
+		}
+		return cmp.Compare(ads[a].Name, ads[b].Name)
+	})
+
+	count := uint64(len(ads))
+	first := (method.turn.Add(1) - 1) % count
+	for rank, idx := range order {
+		position := (uint64(rank) + count - first) % count
+		weights[idx] = 1 - float64(position)/float64(count)
+	}
+	return weights
+}
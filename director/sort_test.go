@@ -24,7 +24,9 @@ import (
 	"math/rand"
 	"net"
 	"net/netip"
+	"net/url"
 	"reflect"
+	"slices"
 	"strings"
 	"testing"
 
@@ -280,3 +282,177 @@ func TestSortServerAdsByIP(t *testing.T) {
 		assert.NotEqualValues(t, notExpected, sorted)
 	})
 }
+
+func TestSortServerAdsByRegion(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(func() {
+		viper.Reset()
+	})
+
+	// The client resolves to Madison through the geo-ip override in yamlMockup
+	clientIP := netip.MustParseAddr("128.104.153.60")
+	viper.SetConfigType("yaml")
+	err := viper.ReadConfig(strings.NewReader(yamlMockup))
+	require.NoError(t, err)
+	viper.Set("Director.CacheSortMethod", "nearestPerRegion")
+	viper.Set("Director.CacheRegionCount", 3)
+	viper.Set("Director.CacheRegionRadius", 1000)
+
+	// Listed in order of increasing distance from the client
+	madisonServer := server_structs.ServerAd{Name: "madison", Latitude: 43.0753, Longitude: -89.4114}
+	chicagoServer := server_structs.ServerAd{Name: "chicago", Latitude: 41.8781, Longitude: -87.6298}
+	sdscServer := server_structs.ServerAd{Name: "sdsc", Latitude: 32.8761, Longitude: -117.2318}
+	bigBenServer := server_structs.ServerAd{Name: "london", Latitude: 51.5103, Longitude: -0.1167}
+	kremlinServer := server_structs.ServerAd{Name: "moscow", Latitude: 55.752121, Longitude: 37.617664}
+	unknownServer := server_structs.ServerAd{Name: "unknown"}
+
+	ads := []server_structs.ServerAd{kremlinServer, sdscServer, madisonServer, bigBenServer, chicagoServer}
+
+	t.Run("geographic-regions", func(t *testing.T) {
+		// Chicago is in the same region as Madison, so it falls behind the nearest caches
+		// of the next two regions
+		expected := []server_structs.ServerAd{madisonServer, sdscServer, bigBenServer, chicagoServer, kremlinServer}
+		sorted, err := sortServerAdsByIP(clientIP, ads)
+		require.NoError(t, err)
+		assert.EqualValues(t, expected, sorted)
+	})
+
+	t.Run("configured-regions", func(t *testing.T) {
+		viper.Set("Director.CacheRegions", []map[string]interface{}{
+			{"Name": "us", "Servers": []string{"madison", "sdsc"}},
+			{"Name": "europe", "Servers": []string{"london", "moscow"}},
+		})
+		t.Cleanup(func() { viper.Set("Director.CacheRegions", nil) })
+
+		expected := []server_structs.ServerAd{madisonServer, chicagoServer, bigBenServer, sdscServer, kremlinServer}
+		sorted, err := sortServerAdsByIP(clientIP, ads)
+		require.NoError(t, err)
+		assert.EqualValues(t, expected, sorted)
+	})
+
+	t.Run("region-count", func(t *testing.T) {
+		viper.Set("Director.CacheRegionCount", 2)
+		t.Cleanup(func() { viper.Set("Director.CacheRegionCount", 3) })
+
+		expected := []server_structs.ServerAd{madisonServer, sdscServer, chicagoServer, bigBenServer, kremlinServer}
+		sorted, err := sortServerAdsByIP(clientIP, ads)
+		require.NoError(t, err)
+		assert.EqualValues(t, expected, sorted)
+	})
+
+	t.Run("unknown-locations", func(t *testing.T) {
+		// A server without a known location is its own region
+		sorted := sortServerAdsByRegion([]server_structs.ServerAd{madisonServer, chicagoServer, unknownServer}, 3)
+		assert.EqualValues(t, []server_structs.ServerAd{madisonServer, unknownServer, chicagoServer}, sorted)
+	})
+}
+
+// Sorts servers by name, for testing sort plugins
+type byNameSortMethod struct{}
+
+func (byNameSortMethod) Weigh(_ *SortRequest, ads []server_structs.ServerAd) []float64 {
+	weights := make([]float64, len(ads))
+	for idx, ad := range ads {
+		weights[idx] = -float64(ad.Name[0])
+	}
+	return weights
+}
+
+func TestSortMethods(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(func() {
+		viper.Reset()
+	})
+	clientIP := netip.MustParseAddr("128.104.153.60")
+	alpha := server_structs.ServerAd{Name: "alpha", URL: url.URL{Scheme: "https", Host: "alpha.example.org"}}
+	bravo := server_structs.ServerAd{Name: "bravo", URL: url.URL{Scheme: "https", Host: "bravo.example.org"}}
+	charlie := server_structs.ServerAd{Name: "charlie", URL: url.URL{Scheme: "https", Host: "charlie.example.org"}}
+	ads := []server_structs.ServerAd{charlie, alpha, bravo}
+
+	t.Run("round-robin", func(t *testing.T) {
+		viper.Set("Director.CacheSortMethod", "round-robin")
+		firsts := map[string]int{}
+		for i := 0; i < 6; i++ {
+			sorted, err := sortServerAdsByIP(clientIP, ads)
+			require.NoError(t, err)
+			require.Len(t, sorted, 3)
+			firsts[sorted[0].Name]++
+			// The servers after the first one follow in turn
+			idx := slices.IndexFunc([]string{"alpha", "bravo", "charlie"}, func(name string) bool { return name == sorted[0].Name })
+			assert.Equal(t, []string{"alpha", "bravo", "charlie"}[(idx+1)%3], sorted[1].Name)
+		}
+		assert.Equal(t, map[string]int{"alpha": 2, "bravo": 2, "charlie": 2}, firsts)
+	})
+
+	t.Run("registered-method", func(t *testing.T) {
+		require.NoError(t, RegisterSortMethod("byName", byNameSortMethod{}))
+		t.Cleanup(func() {
+			sortMethodsMutex.Lock()
+			defer sortMethodsMutex.Unlock()
+			delete(sortMethods, "byName")
+		})
+		assert.Error(t, RegisterSortMethod("byName", byNameSortMethod{}))
+		assert.Error(t, RegisterSortMethod("", byNameSortMethod{}))
+
+		viper.Set("Director.CacheSortMethod", "byName")
+		sorted, err := sortServerAdsByIP(clientIP, ads)
+		require.NoError(t, err)
+		assert.EqualValues(t, []server_structs.ServerAd{alpha, bravo, charlie}, sorted)
+	})
+
+	t.Run("unknown-method", func(t *testing.T) {
+		viper.Set("Director.CacheSortMethod", "fastest")
+		_, err := sortServerAdsByIP(clientIP, ads)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "'round-robin'")
+	})
+}
+
+func TestSortServerAdsByLoad(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(func() {
+		viper.Reset()
+		serverIOLoads.Store(nil)
+	})
+
+	// The client resolves to Madison through the geo-ip override in yamlMockup
+	clientIP := netip.MustParseAddr("128.104.153.60")
+	viper.SetConfigType("yaml")
+	require.NoError(t, viper.ReadConfig(strings.NewReader(yamlMockup)))
+	viper.Set("Director.CacheSortMethod", "distanceAndLoad")
+	viper.Set("Director.SortDistanceWeight", 1)
+	viper.Set("Director.SortLoadWeight", 2)
+
+	madisonServer := server_structs.ServerAd{Name: "madison", URL: url.URL{Scheme: "https", Host: "madison.example.org"},
+		Latitude: 43.0753, Longitude: -89.4114, IOCapacity: 1000}
+	sdscServer := server_structs.ServerAd{Name: "sdsc", URL: url.URL{Scheme: "https", Host: "sdsc.example.org"},
+		Latitude: 32.8761, Longitude: -117.2318, IOCapacity: 1000}
+	bigBenServer := server_structs.ServerAd{Name: "london", URL: url.URL{Scheme: "https", Host: "london.example.org"},
+		Latitude: 51.5103, Longitude: -0.1167}
+	ads := []server_structs.ServerAd{bigBenServer, sdscServer, madisonServer}
+
+	t.Run("unknown-loads", func(t *testing.T) {
+		serverIOLoads.Store(nil)
+		sorted, err := sortServerAdsByIP(clientIP, ads)
+		require.NoError(t, err)
+		assert.EqualValues(t, []server_structs.ServerAd{madisonServer, sdscServer, bigBenServer}, sorted)
+	})
+
+	t.Run("busy-nearest-server", func(t *testing.T) {
+		// Madison moves data at its full 1000 Mb/s while SDSC is idle
+		serverIOLoads.Store(&map[string]float64{
+			madisonServer.URL.String(): 125e6,
+			sdscServer.URL.String():    0,
+		})
+		sorted, err := sortServerAdsByIP(clientIP, ads)
+		require.NoError(t, err)
+		assert.EqualValues(t, []server_structs.ServerAd{sdscServer, bigBenServer, madisonServer}, sorted)
+	})
+
+	t.Run("distance-only", func(t *testing.T) {
+		viper.Set("Director.SortLoadWeight", 0)
+		sorted, err := sortServerAdsByIP(clientIP, ads)
+		require.NoError(t, err)
+		assert.EqualValues(t, []server_structs.ServerAd{madisonServer, sdscServer, bigBenServer}, sorted)
+	})
+}
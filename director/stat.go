@@ -34,6 +34,7 @@ import (
 	"github.com/pelicanplatform/pelican/config"
 	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
 )
 
 type (
@@ -50,6 +51,8 @@ type (
 		URL           url.URL `json:"url"` // The URL to the object
 		Checksum      string  `json:"checksum"`
 		ContentLength int     `json:"contentLength"`
+		// When the object was last modified, if the server reported it
+		LastModified time.Time `json:"lastModified"`
 	}
 
 	queryStatus    string
@@ -172,6 +175,16 @@ func NewObjectStat() *ObjectStat {
 
 // Implementation of sending a HEAD request to an origin for an object
 func (stat *ObjectStat) sendHeadReq(ctx context.Context, objectName string, dataUrl url.URL, digest bool, token string, timeout time.Duration) (*objectMetadata, error) {
+	return headObject(ctx, objectName, dataUrl, digest, token, timeout, false)
+}
+
+// Send a HEAD request to a cache that only succeeds if the cache already has the object,
+// rather than having the cache fetch it from the origin
+func (stat *ObjectStat) sendCachedHeadReq(ctx context.Context, objectName string, dataUrl url.URL, digest bool, token string, timeout time.Duration) (*objectMetadata, error) {
+	return headObject(ctx, objectName, dataUrl, digest, token, timeout, true)
+}
+
+func headObject(ctx context.Context, objectName string, dataUrl url.URL, digest bool, token string, timeout time.Duration, onlyIfCached bool) (*objectMetadata, error) {
 	client := http.Client{Transport: config.GetTransport(), Timeout: timeout}
 	reqUrl := dataUrl.JoinPath(objectName)
 	req, err := http.NewRequestWithContext(ctx, http.MethodHead, reqUrl.String(), nil)
@@ -181,6 +194,9 @@ func (stat *ObjectStat) sendHeadReq(ctx context.Context, objectName string, data
 	if token != "" {
 		req.Header.Set("Authorization", "Bearer "+token)
 	}
+	if onlyIfCached {
+		req.Header.Set("Cache-Control", "only-if-cached")
+	}
 	if digest {
 		// Request checksum
 		req.Header.Set("Want-Digest", "crc32c")
@@ -201,7 +217,7 @@ func (stat *ObjectStat) sendHeadReq(ctx context.Context, objectName string, data
 			return nil, errors.Wrap(err, "unknown request error")
 		}
 	}
-	if res.StatusCode == 404 {
+	if res.StatusCode == 404 || (onlyIfCached && res.StatusCode == http.StatusGatewayTimeout) {
 		return nil, headReqNotFoundErr{"file not found on the server " + dataUrl.String()}
 	} else if res.StatusCode == 403 {
 		return nil, headReqForbiddenErr{fmt.Sprintf("authorization failed for origin %s. Token is required", dataUrl.String()), ""}
@@ -218,7 +234,29 @@ func (stat *ObjectStat) sendHeadReq(ctx context.Context, objectName string, data
 		if err != nil {
 			return nil, errors.New(fmt.Sprintf("error parsing content-length header from response. Header was: %s", cLenStr))
 		}
-		return &objectMetadata{ContentLength: clen, Checksum: checksumStr, URL: *dataUrl.JoinPath(objectName)}, nil
+		meta := &objectMetadata{ContentLength: clen, Checksum: checksumStr, URL: *dataUrl.JoinPath(objectName)}
+		if lastModified, err := http.ParseTime(res.Header.Get("Last-Modified")); err == nil {
+			meta.LastModified = lastModified
+		}
+		return meta, nil
+	}
+}
+
+// Create the utilities for stat calls to a server, stopped when ctx is cancelled
+func newServerStatUtil(ctx context.Context) serverStatUtil {
+	return serverStatUtil{
+		Queue: server_utils.NewWorkQueue(ctx, server_utils.WorkQueueConfig{
+			Name:    "director_stat",
+			Workers: param.Director_StatConcurrencyLimit.GetInt(),
+		}),
+	}
+}
+
+// Send a stat result to the query waiting for it, unless the query has returned
+func sendStatResult[T any](ctx context.Context, resultChan chan<- T, result T) {
+	select {
+	case resultChan <- result:
+	case <-ctx.Done():
 	}
 }
 
@@ -319,46 +357,52 @@ func (stat *ObjectStat) queryServersForObject(cancelContext context.Context, obj
 			log.Debugf("Origin %q is missing data for stat call, skip querying...", sAD.Name)
 			continue
 		}
-		// Use an anonymous func to pass variable safely to the goroutine
+		// Use an anonymous func to pass variable safely to the task
 		func(sAdInt server_structs.ServerAd) {
-			statUtil.Errgroup.Go(func() error {
-				metadata, err := stat.ReqHandler(maxCancelCtx, objectName, sAdInt.URL, true, cfg.token, timeout)
+			err := statUtil.Queue.Submit(maxCancelCtx, func(ctx context.Context) error {
+				metadata, err := stat.ReqHandler(ctx, objectName, sAdInt.URL, true, cfg.token, timeout)
 
 				if err != nil {
 					// If the request returns 403 or 500, it could be because we request a digest and xrootd
 					// either not has this turned on, or had trouble calculating the checksum
 					// Retry without digest
-					metadata, err = stat.ReqHandler(maxCancelCtx, objectName, sAdInt.URL, false, cfg.token, timeout)
+					metadata, err = stat.ReqHandler(ctx, objectName, sAdInt.URL, false, cfg.token, timeout)
 				}
 
+				// The query may have returned already, in which case nobody is listening
 				if err != nil {
 					switch e := err.(type) {
 					case headReqTimeoutErr:
 						log.Debugf("Timeout querying %s server %s for object %s after %s: %s", sAdInt.Type, sAdInt.URL.String(), objectName, timeout.String(), e.Message)
-						negativeReqChan <- err
+						sendStatResult(ctx, negativeReqChan, err)
 						return nil
 					case headReqNotFoundErr:
 						log.Debugf("Object %s not found at %s server %s: %s", objectName, sAdInt.Type, sAdInt.URL.String(), e.Message)
-						negativeReqChan <- err
+						sendStatResult(ctx, negativeReqChan, err)
 						return nil
 					case headReqForbiddenErr:
 						fErr := err.(headReqForbiddenErr)
-						fErr.IssuerUrl = sAD.AuthURL.String()
+						fErr.IssuerUrl = sAdInt.AuthURL.String()
 						log.Debugf("Access denied for object %s at %s server %s: %s", objectName, sAdInt.Type, sAdInt.URL.String(), e.Message)
-						deniedReqChan <- fErr
+						sendStatResult(ctx, deniedReqChan, fErr)
 						return nil
 					case headReqCancelledErr:
 						// Don't send to negativeReqChan as cancellation won't count towards total requests
 						return nil
 					default:
-						negativeReqChan <- err
+						sendStatResult(ctx, negativeReqChan, err)
 						return err
 					}
 				} else {
-					positiveReqChan <- metadata
+					sendStatResult(ctx, positiveReqChan, metadata)
 				}
 				return nil
 			})
+			if err != nil {
+				// The query was cancelled or the server was evicted while waiting to queue the request
+				numTotalReq += 1
+				log.Debugf("Failed to queue the stat request to %s server %s: %v", sAdInt.Type, sAdInt.URL.String(), err)
+			}
 		}(sAD)
 	}
 
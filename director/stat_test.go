@@ -32,7 +32,6 @@ import (
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"golang.org/x/sync/errgroup"
 
 	"github.com/pelicanplatform/pelican/config"
 	"github.com/pelicanplatform/pelican/server_structs"
@@ -131,12 +130,7 @@ func TestQueryServersForObject(t *testing.T) {
 		defer statUtilsMutex.Unlock()
 
 		for _, key := range serverAds.Keys() {
-			ctx, cancel := context.WithCancel(context.Background())
-			statUtils[key] = serverStatUtil{
-				Context:  ctx,
-				Cancel:   cancel,
-				Errgroup: &errgroup.Group{},
-			}
+			statUtils[key] = newServerStatUtil(context.Background())
 		}
 	}
 
@@ -314,11 +308,7 @@ func TestQueryServersForObject(t *testing.T) {
 		mockCacheServer := []server_structs.ServerAd{{Name: "cache-overwrite", URL: url.URL{Host: "cache-overwrites.com", Scheme: "https"}}}
 
 		statUtilsMutex.Lock()
-		statUtils[mockCacheServer[0].URL.String()] = serverStatUtil{
-			Context:  ctx,
-			Cancel:   cancel,
-			Errgroup: &errgroup.Group{},
-		}
+		statUtils[mockCacheServer[0].URL.String()] = newServerStatUtil(ctx)
 		statUtilsMutex.Unlock()
 
 		result := stat.queryServersForObject(ctx, "/overwrites/test.txt", config.CacheType, 0, 0, withCacheAds(mockCacheServer))
@@ -348,11 +338,7 @@ func TestQueryServersForObject(t *testing.T) {
 		mockOrigin := []server_structs.ServerAd{{Name: "origin-overwrite", URL: url.URL{Host: "origin-overwrites.com", Scheme: "https"}}}
 
 		statUtilsMutex.Lock()
-		statUtils[mockOrigin[0].URL.String()] = serverStatUtil{
-			Context:  ctx,
-			Cancel:   cancel,
-			Errgroup: &errgroup.Group{},
-		}
+		statUtils[mockOrigin[0].URL.String()] = newServerStatUtil(ctx)
 		statUtilsMutex.Unlock()
 
 		result := stat.queryServersForObject(ctx, "/overwrites/test.txt", config.OriginType, 0, 0, withOriginAds(mockOrigin))
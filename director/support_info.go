@@ -0,0 +1,123 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// The number of entries in the director's in-memory state
+	SupportInfoCounts struct {
+		Origins           int `json:"origins"`
+		Caches            int `json:"caches"`
+		FilteredServers   int `json:"filteredServers"`
+		Downtimes         int `json:"downtimes"`
+		NamespaceKeys     int `json:"namespaceKeys"`
+		HealthTests       int `json:"healthTests"`
+		RedirectDecisions int `json:"redirectDecisions"`
+	}
+
+	// A summary of the director's build, configuration, and state for support
+	// requests and federation-wide version audits.  It includes no secrets.
+	SupportInfo struct {
+		Version   string                 `json:"version"`
+		Commit    string                 `json:"commit"`
+		StartTime time.Time              `json:"startTime"`
+		Uptime    string                 `json:"uptime"`
+		Features  map[string]bool        `json:"features"`
+		Config    map[string]interface{} `json:"config"`
+		Counts    SupportInfoCounts      `json:"counts"`
+	}
+)
+
+// The time the director process started
+var directorStartTime = time.Now()
+
+func getSupportInfo(now time.Time) SupportInfo {
+	counts := SupportInfoCounts{
+		NamespaceKeys:     namespaceKeys.Len(),
+		RedirectDecisions: redirectDecisions.Len(),
+	}
+	for _, item := range serverAds.Items() {
+		if item.Value().Type == server_structs.OriginType {
+			counts.Origins++
+		} else {
+			counts.Caches++
+		}
+	}
+	filteredServersMutex.RLock()
+	counts.FilteredServers = len(filteredServers)
+	filteredServersMutex.RUnlock()
+	downtimesMutex.RLock()
+	counts.Downtimes = len(downtimes)
+	downtimesMutex.RUnlock()
+	healthTestUtilsMutex.RLock()
+	counts.HealthTests = len(healthTestUtils)
+	healthTestUtilsMutex.RUnlock()
+
+	return SupportInfo{
+		Version:   config.GetVersion(),
+		Commit:    config.GetBuiltCommit(),
+		StartTime: directorStartTime.UTC(),
+		Uptime:    now.Sub(directorStartTime).Truncate(time.Second).String(),
+		Features: map[string]bool{
+			"Director.EnableBroker": param.Director_EnableBroker.GetBool(),
+			"Director.EnableOIDC":   param.Director_EnableOIDC.GetBool(),
+			"GeoIPDatabase":         maxMindReader.Load() != nil,
+			"RTTProbe":              param.Director_RTTProbeUrl.GetString() != "",
+			"ClientLocationMap":     param.Director_ClientLocationMap.IsSet(),
+			"CacheRegions":          param.Director_CacheRegions.IsSet(),
+		},
+		Config: map[string]interface{}{
+			"Server.ExternalWebUrl":                  param.Server_ExternalWebUrl.GetString(),
+			"Federation.DiscoveryUrl":                param.Federation_DiscoveryUrl.GetString(),
+			"Federation.TopologyUrl":                 param.Federation_TopologyUrl.GetString(),
+			"Director.DefaultResponse":               param.Director_DefaultResponse.GetString(),
+			"Director.CacheSortMethod":               param.Director_CacheSortMethod.GetString(),
+			"Director.TopologyPrecedence":            param.Director_TopologyPrecedence.GetString(),
+			"Director.FilteredServers":               param.Director_FilteredServers.GetStringSlice(),
+			"Director.MinStatResponse":               param.Director_MinStatResponse.GetInt(),
+			"Director.MaxStatResponse":               param.Director_MaxStatResponse.GetInt(),
+			"Director.StatConcurrencyLimit":          param.Director_StatConcurrencyLimit.GetInt(),
+			"Director.StatTimeout":                   param.Director_StatTimeout.GetDuration().String(),
+			"Director.AdvertisementTTL":              param.Director_AdvertisementTTL.GetDuration().String(),
+			"Director.OriginCacheHealthTestInterval": param.Director_OriginCacheHealthTestInterval.GetDuration().String(),
+			"Director.HealthTestMinInterval":         param.Director_HealthTestMinInterval.GetDuration().String(),
+			"Director.HealthTestMaxInterval":         param.Director_HealthTestMaxInterval.GetDuration().String(),
+			"Director.DowntimePreDrainDuration":      param.Director_DowntimePreDrainDuration.GetDuration().String(),
+			"Director.RedirectLogSize":               param.Director_RedirectLogSize.GetInt(),
+			"Director.SupportContactEmail":           param.Director_SupportContactEmail.GetString(),
+			"Director.SupportContactUrl":             param.Director_SupportContactUrl.GetString(),
+		},
+		Counts: counts,
+	}
+}
+
+// GET /support_info
+func handleSupportInfo(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, getSupportInfo(time.Now()))
+}
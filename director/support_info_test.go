@@ -0,0 +1,89 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/token_scopes"
+)
+
+func TestSupportInfo(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	resetDowntimes(t)
+	serverAds.DeleteAll()
+	t.Cleanup(serverAds.DeleteAll)
+	viper.Set("Director.EnableBroker", true)
+	viper.Set("Director.StatTimeout", "300ms")
+	viper.Set("Federation.DiscoveryUrl", "https://federation.example.com")
+
+	oldVersion := config.GetVersion()
+	config.SetVersion("7.10.0")
+	t.Cleanup(func() { config.SetVersion(oldVersion) })
+
+	for idx, serverType := range []server_structs.ServerType{server_structs.OriginType, server_structs.CacheType, server_structs.CacheType} {
+		serverUrl := url.URL{Scheme: "https", Host: "server" + string(rune('a'+idx)) + ".example.com"}
+		serverAds.Set(serverUrl.String(), &server_structs.Advertisement{
+			ServerAd: server_structs.ServerAd{URL: serverUrl, Type: serverType},
+		}, ttlcache.DefaultTTL)
+	}
+	now := time.Now()
+	_, err := addDowntimes([]server_structs.Downtime{{ServerName: "servera", StartTime: now, EndTime: now.Add(time.Hour)}}, "admin", now, false)
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.GET("/support_info", directorAdminHandler(token_scopes.Director_ReadSupportInfo), handleSupportInfo)
+	router.GET("/support_info_open", handleSupportInfo)
+
+	// Without a login cookie or token, the support info is refused
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/support_info", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/support_info_open", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	info := SupportInfo{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &info))
+
+	assert.Equal(t, "7.10.0", info.Version)
+	assert.False(t, info.StartTime.After(time.Now()))
+	assert.NotEmpty(t, info.Uptime)
+	assert.True(t, info.Features["Director.EnableBroker"])
+	assert.False(t, info.Features["GeoIPDatabase"])
+	assert.Equal(t, "300ms", info.Config["Director.StatTimeout"])
+	assert.Equal(t, "https://federation.example.com", info.Config["Federation.DiscoveryUrl"])
+	assert.Equal(t, 1, info.Counts.Origins)
+	assert.Equal(t, 2, info.Counts.Caches)
+	assert.Equal(t, 1, info.Counts.Downtimes)
+}
@@ -0,0 +1,163 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/utils"
+)
+
+type topologyPrecedence string
+
+const (
+	topologyPrecedenceFirst topologyPrecedence = "first"
+	topologyPrecedenceLast  topologyPrecedence = "last"
+	topologyPrecedenceMerge topologyPrecedence = "merge"
+)
+
+// Get the policy, set in Director.TopologyPrecedence, for namespaces found in several topology sources
+func getTopologyPrecedence() (topologyPrecedence, error) {
+	precedence := topologyPrecedence(param.Director_TopologyPrecedence.GetString())
+	switch precedence {
+	case "":
+		return topologyPrecedenceFirst, nil
+	case topologyPrecedenceFirst, topologyPrecedenceLast, topologyPrecedenceMerge:
+		return precedence, nil
+	}
+	return "", errors.Errorf("Invalid policy '%s' set in Director.TopologyPrecedence. Valid policies are %s, %s, and %s",
+		precedence, topologyPrecedenceFirst, topologyPrecedenceLast, topologyPrecedenceMerge)
+}
+
+// Get the namespace.json URLs of the director's topology sources, in order of precedence
+func getTopologySources() []string {
+	sources := []string{}
+	if topoNamespaceUrl := param.Federation_TopologyNamespaceUrl.GetString(); topoNamespaceUrl != "" {
+		sources = append(sources, topoNamespaceUrl)
+	}
+	for _, source := range param.Director_TopologyNamespaceUrls.GetStringSlice() {
+		if source != "" && !slices.Contains(sources, source) {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+// Append the servers missing from servers
+func mergeTopologyServers(servers []utils.Server, others []utils.Server) []utils.Server {
+	for _, other := range others {
+		if !slices.Contains(servers, other) {
+			servers = append(servers, other)
+		}
+	}
+	return servers
+}
+
+// Merge the namespaces and caches of several topology sources, given in order of precedence
+func mergeTopologyJSON(sources []*utils.TopologyNamespacesJSON, precedence topologyPrecedence) *utils.TopologyNamespacesJSON {
+	merged := &utils.TopologyNamespacesJSON{Caches: []utils.Server{}, Namespaces: []utils.Namespace{}}
+	// Index of each namespace path in merged.Namespaces
+	paths := make(map[string]int)
+	for _, source := range sources {
+		merged.Caches = mergeTopologyServers(merged.Caches, source.Caches)
+		for _, ns := range source.Namespaces {
+			idx, found := paths[ns.Path]
+			if !found {
+				paths[ns.Path] = len(merged.Namespaces)
+				merged.Namespaces = append(merged.Namespaces, ns)
+				continue
+			}
+			switch precedence {
+			case topologyPrecedenceLast:
+				merged.Namespaces[idx] = ns
+			case topologyPrecedenceMerge:
+				existing := &merged.Namespaces[idx]
+				existing.Origins = mergeTopologyServers(slices.Clone(existing.Origins), ns.Origins)
+				existing.Caches = mergeTopologyServers(slices.Clone(existing.Caches), ns.Caches)
+			default:
+				log.Debugf("Ignoring namespace %s from a later topology source; Director.TopologyPrecedence is %s", ns.Path, precedence)
+			}
+		}
+	}
+	return merged
+}
+
+// Get the namespaces and caches from all the director's topology sources, once excluding
+// and once including the servers in downtime.
+//
+// A source that fails to load is skipped; an error is returned only if none of them load.
+func getTopologyFromSources(ctx context.Context) (namespaces, includedNss *utils.TopologyNamespacesJSON, err error) {
+	precedence, err := getTopologyPrecedence()
+	if err != nil {
+		return
+	}
+	sources := getTopologySources()
+	if len(sources) == 0 {
+		metrics.SetComponentHealthStatus(metrics.DirectorRegistry_Topology, metrics.StatusCritical, "Neither Federation.TopologyNamespaceUrl nor Director.TopologyNamespaceUrls is set")
+		err = errors.New("Neither Federation.TopologyNamespaceUrl nor Director.TopologyNamespaceUrls is set")
+		return
+	}
+
+	excluded := make([]*utils.TopologyNamespacesJSON, 0, len(sources))
+	included := make([]*utils.TopologyNamespacesJSON, 0, len(sources))
+	failed := []string{}
+	var lastErr error
+	for _, source := range sources {
+		// Both lists must come from the same source, or all of its servers would look downed
+		sourceNss, err := utils.GetTopologyJSONFromUrl(ctx, source, false)
+		if err != nil {
+			log.Warningf("Failed to get topology JSON from %s: %v", source, err)
+			failed = append(failed, source)
+			lastErr = err
+			continue
+		}
+		sourceIncludedNss, err := utils.GetTopologyJSONFromUrl(ctx, source, true)
+		if err != nil {
+			log.Warningf("Failed to get topology JSON with server in downtime included (include_downed) from %s: %v", source, err)
+			failed = append(failed, source)
+			lastErr = err
+			continue
+		}
+		excluded = append(excluded, sourceNss)
+		included = append(included, sourceIncludedNss)
+	}
+	if len(excluded) == 0 {
+		metrics.SetComponentHealthStatus(metrics.DirectorRegistry_Topology, metrics.StatusCritical, "Failed to get topology JSON from any of the topology sources: "+lastErr.Error())
+		err = errors.Wrap(lastErr, "Failed to get topology JSON from any of the topology sources")
+		return
+	}
+	if len(failed) > 0 {
+		metrics.SetComponentHealthStatus(metrics.DirectorRegistry_Topology, metrics.StatusWarning,
+			fmt.Sprintf("Failed to get topology JSON from %s", strings.Join(failed, ", ")))
+	} else {
+		metrics.SetComponentHealthStatus(metrics.DirectorRegistry_Topology, metrics.StatusOK, "")
+	}
+
+	namespaces = mergeTopologyJSON(excluded, precedence)
+	includedNss = mergeTopologyJSON(included, precedence)
+	return
+}
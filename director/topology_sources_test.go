@@ -0,0 +1,125 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/utils"
+)
+
+func TestMergeTopologyJSON(t *testing.T) {
+	originA := utils.Server{Endpoint: "origin-a.org:8000", Resource: "ORIGIN_A"}
+	originB := utils.Server{Endpoint: "origin-b.org:8000", Resource: "ORIGIN_B"}
+	cacheA := utils.Server{Endpoint: "cache-a.org:8000", Resource: "CACHE_A"}
+	cacheB := utils.Server{Endpoint: "cache-b.org:8000", Resource: "CACHE_B"}
+	osdf := &utils.TopologyNamespacesJSON{
+		Caches: []utils.Server{cacheA},
+		Namespaces: []utils.Namespace{
+			{Path: "/shared", Origins: []utils.Server{originA}, Caches: []utils.Server{cacheA}, UseTokenOnRead: true},
+			{Path: "/osdf", Origins: []utils.Server{originA}},
+		},
+	}
+	regional := &utils.TopologyNamespacesJSON{
+		Caches: []utils.Server{cacheA, cacheB},
+		Namespaces: []utils.Namespace{
+			{Path: "/shared", Origins: []utils.Server{originB}, Caches: []utils.Server{cacheA, cacheB}},
+			{Path: "/regional", Origins: []utils.Server{originB}},
+		},
+	}
+	sources := []*utils.TopologyNamespacesJSON{osdf, regional}
+
+	t.Run("first", func(t *testing.T) {
+		merged := mergeTopologyJSON(sources, topologyPrecedenceFirst)
+		assert.Equal(t, []utils.Server{cacheA, cacheB}, merged.Caches)
+		require.Len(t, merged.Namespaces, 3)
+		assert.Equal(t, osdf.Namespaces[0], merged.Namespaces[0])
+		assert.Equal(t, "/osdf", merged.Namespaces[1].Path)
+		assert.Equal(t, "/regional", merged.Namespaces[2].Path)
+	})
+
+	t.Run("last", func(t *testing.T) {
+		merged := mergeTopologyJSON(sources, topologyPrecedenceLast)
+		require.Len(t, merged.Namespaces, 3)
+		assert.Equal(t, regional.Namespaces[0], merged.Namespaces[0])
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		merged := mergeTopologyJSON(sources, topologyPrecedenceMerge)
+		require.Len(t, merged.Namespaces, 3)
+		shared := merged.Namespaces[0]
+		assert.Equal(t, []utils.Server{originA, originB}, shared.Origins)
+		assert.Equal(t, []utils.Server{cacheA, cacheB}, shared.Caches)
+		assert.True(t, shared.UseTokenOnRead)
+		// The sources themselves are left alone
+		assert.Len(t, osdf.Namespaces[0].Origins, 1)
+	})
+}
+
+func TestGetTopologyFromSources(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	topology := func(namespaces ...string) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			resp := utils.TopologyNamespacesJSON{}
+			for _, ns := range namespaces {
+				resp.Namespaces = append(resp.Namespaces, utils.Namespace{Path: ns})
+			}
+			require.NoError(t, json.NewEncoder(w).Encode(resp))
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(failing.Close)
+
+	viper.Set("Federation.TopologyNamespaceUrl", topology("/osdf").URL)
+	viper.Set("Director.TopologyNamespaceUrls", []string{failing.URL, topology("/regional").URL})
+	namespaces, includedNss, err := getTopologyFromSources(context.Background())
+	require.NoError(t, err)
+	paths := []string{}
+	for _, ns := range namespaces.Namespaces {
+		paths = append(paths, ns.Path)
+	}
+	assert.Equal(t, []string{"/osdf", "/regional"}, paths)
+	assert.Len(t, includedNss.Namespaces, 2)
+
+	t.Run("all-sources-fail", func(t *testing.T) {
+		viper.Set("Federation.TopologyNamespaceUrl", failing.URL)
+		viper.Set("Director.TopologyNamespaceUrls", []string{})
+		_, _, err := getTopologyFromSources(context.Background())
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid-precedence", func(t *testing.T) {
+		viper.Set("Director.TopologyPrecedence", "newest")
+		_, _, err := getTopologyFromSources(context.Background())
+		assert.ErrorContains(t, err, "Director.TopologyPrecedence")
+	})
+}
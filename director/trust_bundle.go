@@ -0,0 +1,197 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+)
+
+// How long the registry's public keys are reused in the trust bundle before they're refetched
+const registryKeysLifetime = 15 * time.Minute
+
+var registryKeys struct {
+	mutex   sync.Mutex
+	keys    json.RawMessage
+	fetched time.Time
+}
+
+// Get the registry's public keys, refetched every registryKeysLifetime.  If the registry
+// can't be reached, the last keys fetched are reused.
+func getRegistryKeys(ctx context.Context) (json.RawMessage, error) {
+	registryKeys.mutex.Lock()
+	defer registryKeys.mutex.Unlock()
+	if registryKeys.keys != nil && time.Since(registryKeys.fetched) < registryKeysLifetime {
+		return registryKeys.keys, nil
+	}
+
+	fedInfo, err := config.GetFederation(ctx)
+	if err != nil {
+		return registryKeys.keys, err
+	}
+	if fedInfo.NamespaceRegistrationEndpoint == "" {
+		return nil, errors.New("the federation has no registry")
+	}
+	jwksUrl, err := url.JoinPath(fedInfo.NamespaceRegistrationEndpoint, directorJWKSPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate the URL of the registry's public keys")
+	}
+	keys, err := jwk.Fetch(ctx, jwksUrl, jwk.WithHTTPClient(&http.Client{Transport: config.GetTransport()}))
+	if err != nil {
+		return registryKeys.keys, errors.Wrapf(err, "failed to fetch the registry's public keys from %s", jwksUrl)
+	}
+	keysJson, err := json.Marshal(keys)
+	if err != nil {
+		return registryKeys.keys, errors.Wrap(err, "failed to marshal the registry's public keys")
+	}
+	registryKeys.keys = keysJson
+	registryKeys.fetched = time.Now()
+	return registryKeys.keys, nil
+}
+
+// List the token issuers of the namespaces advertised to the director, merging the
+// base paths of an issuer advertised by several origins
+func listTrustBundleIssuers() []config.TrustBundleIssuer {
+	basePaths := map[string][]string{}
+	for _, item := range serverAds.Items() {
+		for _, nsAd := range item.Value().NamespaceAds {
+			for _, issuer := range nsAd.Issuer {
+				issuerUrl := issuer.IssuerUrl.String()
+				if issuerUrl == "" {
+					continue
+				}
+				paths := basePaths[issuerUrl]
+				for _, basePath := range issuer.BasePaths {
+					if !slices.Contains(paths, basePath) {
+						paths = append(paths, basePath)
+					}
+				}
+				basePaths[issuerUrl] = paths
+			}
+		}
+	}
+	issuers := make([]config.TrustBundleIssuer, 0, len(basePaths))
+	for issuer, paths := range basePaths {
+		slices.Sort(paths)
+		issuers = append(issuers, config.TrustBundleIssuer{Issuer: issuer, BasePaths: paths})
+	}
+	slices.SortFunc(issuers, func(a, b config.TrustBundleIssuer) int {
+		if a.Issuer < b.Issuer {
+			return -1
+		} else if a.Issuer > b.Issuer {
+			return 1
+		}
+		return 0
+	})
+	return issuers
+}
+
+// Assemble the federation's trust bundle
+func createTrustBundle(ctx context.Context) (*config.TrustBundle, error) {
+	directorKeys, err := config.GetIssuerPublicJWKS()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load the director's public keys")
+	}
+	directorKeysJson, err := json.Marshal(directorKeys)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal the director's public keys")
+	}
+	bundle := &config.TrustBundle{
+		DirectorKeys: directorKeysJson,
+		Issuers:      listTrustBundleIssuers(),
+	}
+
+	// The registry's keys are useful but not essential; a bundle without them is still
+	// worth publishing
+	if bundle.RegistryKeys, err = getRegistryKeys(ctx); err != nil {
+		log.Warningln("The federation trust bundle may lack the registry's public keys:", err)
+	}
+
+	if caFile := param.Director_TrustBundleCAFile.GetString(); caFile != "" {
+		caPem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read the approved server CAs from %s", caFile)
+		}
+		bundle.ServerCAs = []string{string(caPem)}
+		if len(bundle.GetServerCAs()) == 0 {
+			return nil, errors.Errorf("%s contains no CA certificates", caFile)
+		}
+	}
+	return bundle, nil
+}
+
+// Create the federation's trust bundle as a JWT signed with the director's issuer key
+func createSignedTrustBundle(ctx context.Context) (string, error) {
+	bundle, err := createTrustBundle(ctx)
+	if err != nil {
+		return "", err
+	}
+	key, err := config.GetIssuerPrivateJWK()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to load the director's private key")
+	}
+	if err = jwk.AssignKeyID(key); err != nil {
+		return "", errors.Wrap(err, "failed to assign kid to the trust bundle")
+	}
+
+	now := time.Now()
+	tok, err := jwt.NewBuilder().
+		Issuer(param.Server_ExternalWebUrl.GetString()).
+		IssuedAt(now).
+		NotBefore(now).
+		Expiration(now.Add(param.Director_TrustBundleLifetime.GetDuration())).
+		Claim(config.TrustBundleClaim, bundle).
+		Build()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to build the trust bundle")
+	}
+	signed, err := jwt.Sign(tok, jwt.WithKey(jwa.ES256, key))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to sign the trust bundle")
+	}
+	return string(signed), nil
+}
+
+// Serve the federation's signed trust bundle at config.TrustBundlePath
+func trustBundleHandler(ctx *gin.Context) {
+	signed, err := createSignedTrustBundle(ctx)
+	if err != nil {
+		log.Errorln("Failed to create the federation trust bundle:", err)
+		ctx.String(http.StatusInternalServerError, "Failed to create the federation trust bundle")
+		return
+	}
+	ctx.Header("Cache-Control", "max-age=300")
+	ctx.Data(http.StatusOK, "application/jwt", []byte(signed))
+}
@@ -0,0 +1,131 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+func TestSignedTrustBundle(t *testing.T) {
+	viper.Reset()
+	serverAds.DeleteAll()
+	config.ResetFederationForTest()
+	t.Cleanup(func() {
+		viper.Reset()
+		serverAds.DeleteAll()
+		config.ResetFederationForTest()
+		registryKeys.keys = nil
+	})
+	viper.Set("Server.ExternalWebUrl", "https://fake-director.org:8888")
+	viper.Set("IssuerKey", filepath.Join(t.TempDir(), "testKey"))
+	viper.Set("Director.TrustBundleLifetime", "1h")
+
+	// The registry's keys, served at its well-known location
+	registryKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	registryJwk, err := jwk.FromRaw(registryKey.Public())
+	require.NoError(t, err)
+	require.NoError(t, jwk.AssignKeyID(registryJwk))
+	registryJwks := jwk.NewSet()
+	require.NoError(t, registryJwks.AddKey(registryJwk))
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != directorJWKSPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(registryJwks)
+	}))
+	t.Cleanup(registry.Close)
+	config.SetFederation(config.FederationDiscovery{NamespaceRegistrationEndpoint: registry.URL})
+
+	// An approved CA for the federation's servers
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Federation Server CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, template, template, registryKey.Public(), registryKey)
+	require.NoError(t, err)
+	caFile := filepath.Join(t.TempDir(), "server-cas.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDer}), 0644))
+	viper.Set("Director.TrustBundleCAFile", caFile)
+
+	// Two origins exporting namespaces from the same issuer
+	issuer := url.URL{Scheme: "https", Host: "issuer.example.com"}
+	for _, prefix := range []string{"/second", "/first"} {
+		ad := server_structs.ServerAd{
+			Name: prefix,
+			Type: server_structs.OriginType,
+			URL:  url.URL{Scheme: "https", Host: "origin" + prefix[1:] + ".example.com"},
+		}
+		serverAds.Set(ad.URL.String(), &server_structs.Advertisement{
+			ServerAd: ad,
+			NamespaceAds: []server_structs.NamespaceAdV2{{
+				Path:   prefix,
+				Issuer: []server_structs.TokenIssuer{{IssuerUrl: issuer, BasePaths: []string{prefix}}},
+			}},
+		}, 0)
+	}
+
+	signed, err := createSignedTrustBundle(context.Background())
+	require.NoError(t, err)
+	directorKeys, err := config.GetIssuerPublicJWKS()
+	require.NoError(t, err)
+	bundle, err := config.ParseTrustBundle([]byte(signed), directorKeys)
+	require.NoError(t, err)
+
+	assert.WithinDuration(t, time.Now().Add(time.Hour), bundle.Expiry, time.Minute)
+	keys, err := bundle.GetDirectorKeys()
+	require.NoError(t, err)
+	assert.Equal(t, directorKeys.Len(), keys.Len())
+	bundleRegistryKeys, err := jwk.Parse(bundle.RegistryKeys)
+	require.NoError(t, err)
+	_, found := bundleRegistryKeys.LookupKeyID(registryJwk.KeyID())
+	assert.True(t, found)
+	assert.Equal(t, []config.TrustBundleIssuer{{Issuer: "https://issuer.example.com", BasePaths: []string{"/first", "/second"}}}, bundle.Issuers)
+	cas := bundle.GetServerCAs()
+	require.Len(t, cas, 1)
+	assert.Equal(t, "Federation Server CA", cas[0].Subject.CommonName)
+}
@@ -0,0 +1,55 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"fmt"
+	"net/netip"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+// Select the nearest cache accepting write-back uploads of the namespace, among those
+// supporting the client and not excluded by the redirect rules
+func selectWriteBackCache(ipAddr netip.Addr, namespaceAd server_structs.NamespaceAdV2, cacheAds []server_structs.ServerAd, capabilities server_structs.ClientCapabilities, rules *redirectRuleEvaluator) (server_structs.ServerAd, bool) {
+	candidates := make([]server_structs.ServerAd, 0, len(cacheAds))
+	for _, ad := range cacheAds {
+		if ad.Caps.WriteBack && ad.WriteBack != nil && ad.WebURL.String() != "" {
+			candidates = append(candidates, ad)
+		}
+	}
+	candidates = rules.exclude(namespaceAd, filterAdsByClientCapabilities(candidates, capabilities))
+	if len(candidates) == 0 {
+		return server_structs.ServerAd{}, false
+	}
+	sorted, err := sortServerAdsByIP(ipAddr, candidates)
+	if err != nil {
+		log.Warningln("Failed to sort the write-back caches; using them in their original order:", err)
+		sorted = candidates
+	}
+	return rules.prefer(namespaceAd, sorted)[0], true
+}
+
+// The value of the WriteBackHeader, telling the client what the cache's acknowledgement
+// of an upload guarantees
+func writeBackHeaderValue(ad server_structs.ServerAd) string {
+	return fmt.Sprintf("%s; cache=%s; retry-limit=%ds", ad.WriteBack.Consistency, ad.Name, ad.WriteBack.RetryLimit)
+}
@@ -0,0 +1,87 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+func TestSelectWriteBackCache(t *testing.T) {
+	t.Cleanup(func() {
+		viper.Reset()
+		resetRedirectRules()
+	})
+
+	policy := &server_structs.WriteBackPolicy{Consistency: server_structs.WriteBackConsistencyEventual, RetryLimit: 3600}
+	newAd := func(name string, writeBack bool) server_structs.ServerAd {
+		ad := server_structs.ServerAd{
+			Name:   name,
+			Type:   server_structs.CacheType,
+			URL:    url.URL{Scheme: "https", Host: name + ".example.org:8443"},
+			WebURL: url.URL{Scheme: "https", Host: name + ".example.org:8444"},
+		}
+		if writeBack {
+			ad.Caps.WriteBack = true
+			ad.WriteBack = policy
+		}
+		return ad
+	}
+	readCache := newAd("read-cache", false)
+	euCache := newAd("eu-cache", true)
+	usCache := newAd("us-cache", true)
+	namespaceAd := server_structs.NamespaceAdV2{Path: "/foo"}
+
+	evaluatorFor := func(rules []map[string]any) *redirectRuleEvaluator {
+		viper.Reset()
+		resetRedirectRules()
+		viper.Set("Director.RedirectRules", rules)
+		req := httptest.NewRequest("PUT", "/api/v1.0/director/origin/foo/bar?writeback", nil)
+		return newRedirectRuleEvaluator(req, netip.MustParseAddr("192.0.2.1"))
+	}
+
+	t.Run("no-write-back-caches", func(t *testing.T) {
+		_, ok := selectWriteBackCache(netip.MustParseAddr("192.0.2.1"), namespaceAd, []server_structs.ServerAd{readCache}, server_structs.ClientCapabilities{}, evaluatorFor(nil))
+		assert.False(t, ok)
+	})
+
+	t.Run("honors-redirect-rules", func(t *testing.T) {
+		rules := []map[string]any{{"Name": "prefer-us", "Prefer": `server.name.startsWith("us-")`}}
+		selected, ok := selectWriteBackCache(netip.MustParseAddr("192.0.2.1"), namespaceAd, []server_structs.ServerAd{readCache, euCache, usCache}, server_structs.ClientCapabilities{}, evaluatorFor(rules))
+		require.True(t, ok)
+		assert.Equal(t, usCache, selected)
+
+		rules = []map[string]any{{"Name": "no-us", "Exclude": `server.name.startsWith("us-")`}}
+		selected, ok = selectWriteBackCache(netip.MustParseAddr("192.0.2.1"), namespaceAd, []server_structs.ServerAd{readCache, euCache, usCache}, server_structs.ClientCapabilities{}, evaluatorFor(rules))
+		require.True(t, ok)
+		assert.Equal(t, euCache, selected)
+	})
+
+	t.Run("header", func(t *testing.T) {
+		assert.Equal(t, "eventual; cache=eu-cache; retry-limit=3600s", writeBackHeaderValue(euCache))
+	})
+}
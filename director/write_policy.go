@@ -0,0 +1,199 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type writePolicy string
+
+// writeLoad is the recent number of write redirects to an origin, decayed exponentially
+type writeLoad struct {
+	value   float64
+	updated time.Time
+}
+
+const (
+	// Redirect writes to the nearest writable origin (the default)
+	writePolicyNearest writePolicy = "nearest"
+	// Redirect writes to the first writable origin in Director.PrimaryWriteOrigins, falling back
+	// to the nearest of the others when none of them is available
+	writePolicyPrimary writePolicy = "primary"
+	// Rotate writes to a namespace across its writable origins
+	writePolicyRoundRobin writePolicy = "round-robin"
+	// Redirect writes to the writable origin that received the fewest of them recently
+	writePolicyCapacity writePolicy = "capacity"
+
+	// How quickly the recent write load of an origin is forgotten
+	writeLoadHalfLife = time.Minute
+
+	// Response header telling clients that a write may not be visible via the namespace's other origins
+	writeConsistencyHeader = "X-Pelican-Write-Consistency"
+)
+
+var (
+	// The round-robin write counters, by namespace prefix
+	writeRoundRobin sync.Map
+
+	// The recent write load of each origin, by origin URL
+	writeLoads      = map[string]*writeLoad{}
+	writeLoadsMutex = sync.Mutex{}
+
+	// The namespaces for which the director already warned about multiple writable origins
+	multiWriterWarned sync.Map
+)
+
+func getWritePolicy() writePolicy {
+	policy := writePolicy(strings.ToLower(param.Director_OriginWritePolicy.GetString()))
+	switch policy {
+	case writePolicyNearest, writePolicyPrimary, writePolicyRoundRobin, writePolicyCapacity:
+		return policy
+	case "":
+		return writePolicyNearest
+	default:
+		log.Warningf("Unknown value %q for %s; falling back to %q", policy, param.Director_OriginWritePolicy.GetName(), writePolicyNearest)
+		return writePolicyNearest
+	}
+}
+
+// Get the decayed write load of the origin at the given time. Must be called with writeLoadsMutex held.
+func (load *writeLoad) at(now time.Time) float64 {
+	elapsed := now.Sub(load.updated)
+	if elapsed <= 0 {
+		return load.value
+	}
+	return load.value * math.Exp2(-float64(elapsed)/float64(writeLoadHalfLife))
+}
+
+// Record a write redirect to the origin
+func recordWriteRedirect(ad server_structs.ServerAd) {
+	now := time.Now()
+	writeLoadsMutex.Lock()
+	defer writeLoadsMutex.Unlock()
+	load, ok := writeLoads[ad.URL.String()]
+	if !ok {
+		writeLoads[ad.URL.String()] = &writeLoad{value: 1, updated: now}
+		return
+	}
+	load.value = load.at(now) + 1
+	load.updated = now
+}
+
+func resetWriteState() {
+	writeLoadsMutex.Lock()
+	writeLoads = map[string]*writeLoad{}
+	writeLoadsMutex.Unlock()
+	writeRoundRobin.Range(func(key, _ any) bool {
+		writeRoundRobin.Delete(key)
+		return true
+	})
+	multiWriterWarned.Range(func(key, _ any) bool {
+		multiWriterWarned.Delete(key)
+		return true
+	})
+}
+
+// Select the origin to redirect a write to the namespace to, according to
+// Director.OriginWritePolicy. The ads must be sorted by preference for the client;
+// the origins that don't accept writes are ignored. Also returns the number of writable origins.
+func selectWriteOrigin(ads []server_structs.ServerAd, namespace string) (selected server_structs.ServerAd, writers int, ok bool) {
+	writable := make([]server_structs.ServerAd, 0, len(ads))
+	for _, ad := range ads {
+		if ad.Writes {
+			writable = append(writable, ad)
+		}
+	}
+	if len(writable) == 0 {
+		return server_structs.ServerAd{}, 0, false
+	}
+
+	selected = writable[0]
+	if len(writable) > 1 {
+		switch getWritePolicy() {
+		case writePolicyPrimary:
+			selected = selectPrimaryOrigin(writable)
+		case writePolicyRoundRobin:
+			// The client-specific ordering is replaced by a stable one so the rotation is even
+			sorted := make([]server_structs.ServerAd, len(writable))
+			copy(sorted, writable)
+			sort.Slice(sorted, func(i, j int) bool {
+				return sorted[i].URL.String() < sorted[j].URL.String()
+			})
+			counter, _ := writeRoundRobin.LoadOrStore(namespace, &atomic.Uint64{})
+			next := counter.(*atomic.Uint64).Add(1) - 1
+			selected = sorted[next%uint64(len(sorted))]
+		case writePolicyCapacity:
+			selected = selectLeastLoadedOrigin(writable)
+		}
+	}
+	recordWriteRedirect(selected)
+	return selected, len(writable), true
+}
+
+// Select the first origin listed in Director.PrimaryWriteOrigins, or the nearest if none are
+func selectPrimaryOrigin(writable []server_structs.ServerAd) server_structs.ServerAd {
+	for _, primary := range param.Director_PrimaryWriteOrigins.GetStringSlice() {
+		for _, ad := range writable {
+			if ad.Name == primary || ad.URL.Hostname() == primary {
+				return ad
+			}
+		}
+	}
+	return writable[0]
+}
+
+// Select the origin with the least recent write load; ties go to the nearer origin
+func selectLeastLoadedOrigin(writable []server_structs.ServerAd) server_structs.ServerAd {
+	now := time.Now()
+	writeLoadsMutex.Lock()
+	defer writeLoadsMutex.Unlock()
+	selected := writable[0]
+	lowest := math.Inf(1)
+	for _, ad := range writable {
+		current := 0.0
+		if load, ok := writeLoads[ad.URL.String()]; ok {
+			current = load.at(now)
+		}
+		if current < lowest {
+			selected = ad
+			lowest = current
+		}
+	}
+	return selected
+}
+
+// Warn, once per namespace, that writes to one of its origins aren't visible via the others
+// until the data propagates
+func warnMultipleWriters(namespace string, writers int) {
+	if _, warned := multiWriterWarned.LoadOrStore(namespace, true); warned {
+		return
+	}
+	log.Warningf("Namespace %s has %d writable origins; objects written via one of them are not visible via the others until they propagate", namespace, writers)
+}
@@ -0,0 +1,115 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package director
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+func TestSelectWriteOrigin(t *testing.T) {
+	t.Cleanup(func() {
+		viper.Reset()
+		resetWriteState()
+	})
+
+	near := server_structs.ServerAd{Name: "near", Writes: true, URL: url.URL{Scheme: "https", Host: "near.example.org:8443"}}
+	far := server_structs.ServerAd{Name: "far", Writes: true, URL: url.URL{Scheme: "https", Host: "far.example.org:8443"}}
+	readOnly := server_structs.ServerAd{Name: "read-only", URL: url.URL{Scheme: "https", Host: "read-only.example.org:8443"}}
+	ads := []server_structs.ServerAd{readOnly, near, far}
+
+	t.Run("no-writable-origins", func(t *testing.T) {
+		viper.Reset()
+		resetWriteState()
+		_, writers, ok := selectWriteOrigin([]server_structs.ServerAd{readOnly}, "/foo")
+		assert.False(t, ok)
+		assert.Equal(t, 0, writers)
+	})
+
+	t.Run("nearest", func(t *testing.T) {
+		viper.Reset()
+		resetWriteState()
+		for i := 0; i < 3; i++ {
+			selected, writers, ok := selectWriteOrigin(ads, "/foo")
+			require.True(t, ok)
+			assert.Equal(t, 2, writers)
+			assert.Equal(t, near, selected)
+		}
+	})
+
+	t.Run("primary", func(t *testing.T) {
+		viper.Reset()
+		resetWriteState()
+		viper.Set("Director.OriginWritePolicy", "primary")
+		viper.Set("Director.PrimaryWriteOrigins", []string{"missing.example.org", "far.example.org"})
+		selected, _, ok := selectWriteOrigin(ads, "/foo")
+		require.True(t, ok)
+		assert.Equal(t, far, selected)
+
+		// The nearest origin is the backup when no primary is available
+		selected, _, ok = selectWriteOrigin([]server_structs.ServerAd{readOnly, near}, "/foo")
+		require.True(t, ok)
+		assert.Equal(t, near, selected)
+	})
+
+	t.Run("round-robin", func(t *testing.T) {
+		viper.Reset()
+		resetWriteState()
+		viper.Set("Director.OriginWritePolicy", "round-robin")
+		first, _, _ := selectWriteOrigin(ads, "/foo")
+		// The rotation doesn't depend on the client's ordering
+		second, _, _ := selectWriteOrigin([]server_structs.ServerAd{far, near}, "/foo")
+		third, _, _ := selectWriteOrigin(ads, "/foo")
+		assert.NotEqual(t, first, second)
+		assert.Equal(t, first, third)
+
+		// Each namespace has its own rotation
+		other, _, _ := selectWriteOrigin(ads, "/bar")
+		assert.Equal(t, first, other)
+	})
+
+	t.Run("capacity", func(t *testing.T) {
+		viper.Reset()
+		resetWriteState()
+		viper.Set("Director.OriginWritePolicy", "capacity")
+		counts := map[string]int{}
+		for i := 0; i < 10; i++ {
+			selected, _, ok := selectWriteOrigin(ads, "/foo")
+			require.True(t, ok)
+			counts[selected.Name]++
+		}
+		assert.Equal(t, 5, counts["near"])
+		assert.Equal(t, 5, counts["far"])
+	})
+
+	t.Run("unknown-policy-is-nearest", func(t *testing.T) {
+		viper.Reset()
+		resetWriteState()
+		viper.Set("Director.OriginWritePolicy", "bogus")
+		selected, _, ok := selectWriteOrigin(ads, "/foo")
+		require.True(t, ok)
+		assert.Equal(t, near, selected)
+	})
+}
@@ -171,6 +171,26 @@ func NewTransfer_SlowTransferError(err error) *PelicanError {
 	}
 }
 
+func NewTransfer_StagingError(err error) *PelicanError {
+	return &PelicanError{
+		errorType: "Transfer.Staging",
+		exitCode:  12,
+		code:      6003,
+		retryable: true,
+		err:       err,
+	}
+}
+
+func NewTransfer_TimedOutError(err error) *PelicanError {
+	return &PelicanError{
+		errorType: "Transfer.TimedOut",
+		exitCode:  13,
+		code:      6004,
+		retryable: true,
+		err:       err,
+	}
+}
+
 // function that maps the error to the exit code
 func (e *PelicanError) ExitCode() int {
 	return e.exitCode
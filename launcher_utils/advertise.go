@@ -31,6 +31,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -41,6 +42,7 @@ import (
 	"github.com/pelicanplatform/pelican/metrics"
 	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
 	"github.com/pelicanplatform/pelican/token"
 	"github.com/pelicanplatform/pelican/token_scopes"
 	"github.com/pelicanplatform/pelican/utils"
@@ -51,7 +53,16 @@ type directorResponse struct {
 	ApprovalError bool   `json:"approval_error"`
 }
 
-func doAdvertise(ctx context.Context, servers []server_structs.XRootDServer) {
+// How long a director found compatible is trusted before its versions are checked again
+const directorCompatibilityRecheck = time.Hour
+
+var (
+	// The directors whose protocol versions were found compatible, with the time of the check
+	compatibleDirectors      = map[string]time.Time{}
+	compatibleDirectorsMutex sync.Mutex
+)
+
+func doAdvertise(ctx context.Context, servers []server_structs.XRootDServer) error {
 	log.Debugf("About to advertise %d XRootD servers", len(servers))
 	err := Advertise(ctx, servers)
 	if err != nil {
@@ -60,34 +71,41 @@ func doAdvertise(ctx context.Context, servers []server_structs.XRootDServer) {
 	} else {
 		metrics.SetComponentHealthStatus(metrics.OriginCache_Federation, metrics.StatusOK, "")
 	}
+	return err
 }
 
 // Launch periodic advertise of xrootd servers (origin and cache) to the director, in the errogroup
 func LaunchPeriodicAdvertise(ctx context.Context, egrp *errgroup.Group, servers []server_structs.XRootDServer) error {
 	metrics.SetComponentHealthStatus(metrics.OriginCache_Federation, metrics.StatusWarning, "First attempt to advertise to the director...")
-	doAdvertise(ctx, servers)
 
-	ticker := time.NewTicker(1 * time.Minute)
-	egrp.Go(func() error {
+	// A single worker so advertisements never overlap; a failed advertisement is
+	// retried a couple of times before waiting for the next period
+	queue := server_utils.NewWorkQueue(ctx, server_utils.WorkQueueConfig{
+		Name:           "advertise",
+		Workers:        1,
+		AttemptTimeout: 30 * time.Second,
+		Retry: server_utils.RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: 5 * time.Second,
+			MaxBackoff:     10 * time.Second,
+		},
+	})
+	advertiseTask := func(ctx context.Context) error {
+		return doAdvertise(ctx, servers)
+	}
+	// The first advertisement is made before returning, as before
+	if err := queue.Do(ctx, advertiseTask); err != nil && ctx.Err() != nil {
+		queue.Stop()
+		return nil
+	}
 
-		for {
-			select {
-			case <-ticker.C:
-				err := Advertise(ctx, servers)
-				if err != nil {
-					log.Warningln("XRootD server advertise failed:", err)
-					metrics.SetComponentHealthStatus(metrics.OriginCache_Federation, metrics.StatusCritical, fmt.Sprintf("XRootD server failed to advertise to the director: %v", err))
-				} else {
-					metrics.SetComponentHealthStatus(metrics.OriginCache_Federation, metrics.StatusOK, "")
-				}
-			case <-ctx.Done():
-				log.Infoln("Periodic advertisement loop has been terminated")
-				return nil
-			}
-
-			doAdvertise(ctx, servers)
-		}
+	egrp.Go(func() error {
+		<-ctx.Done()
+		queue.Stop()
+		log.Infoln("Periodic advertisement loop has been terminated")
+		return nil
 	})
+	server_utils.LaunchPeriodicTask(ctx, egrp, queue, 1*time.Minute, advertiseTask)
 
 	return nil
 }
@@ -104,6 +122,46 @@ func Advertise(ctx context.Context, servers []server_structs.XRootDServer) error
 	return firstErr
 }
 
+// Check, before advertising, that the director supports a version of each protocol
+// this server speaks.  Directors that don't report their versions are assumed to be
+// compatible, as are directors whose health endpoint can't be reached; the
+// advertisement itself reports the latter.
+func checkDirectorCompatibility(ctx context.Context, directorUrl string) error {
+	compatibleDirectorsMutex.Lock()
+	checked, ok := compatibleDirectors[directorUrl]
+	compatibleDirectorsMutex.Unlock()
+	if ok && time.Since(checked) < directorCompatibilityRecheck {
+		return nil
+	}
+
+	healthUrl, err := url.JoinPath(directorUrl, "api", "v1.0", "health")
+	if err != nil {
+		return errors.Wrap(err, "failed to construct the director's health URL")
+	}
+	respData, err := utils.MakeRequest(ctx, healthUrl, http.MethodGet, nil, nil)
+	if err != nil {
+		log.Debugf("Failed to get the protocol versions of the director at %s: %v", directorUrl, err)
+		return nil
+	}
+	health := struct {
+		Versions *server_structs.ProtocolVersions `json:"versions"`
+	}{}
+	if err := json.Unmarshal(respData, &health); err != nil {
+		log.Debugf("Failed to parse the health response of the director at %s: %v", directorUrl, err)
+		return nil
+	}
+	if health.Versions != nil {
+		if err := server_structs.GetProtocolVersions().CheckCompatible(*health.Versions); err != nil {
+			return errors.Wrapf(err, "the director at %s is incompatible with this server", directorUrl)
+		}
+	}
+
+	compatibleDirectorsMutex.Lock()
+	compatibleDirectors[directorUrl] = time.Now()
+	compatibleDirectorsMutex.Unlock()
+	return nil
+}
+
 // Get the site name from the registry given a namespace prefix
 func getSitenameFromReg(ctx context.Context, prefix string) (sitename string, err error) {
 	fed, err := config.GetFederation(ctx)
@@ -181,6 +239,14 @@ func advertiseInternal(ctx context.Context, server server_structs.XRootDServer)
 	if err != nil {
 		return err
 	}
+	if ad.RequiredFeatures, err = server_structs.ParseClientFeatures(param.Server_RequiredClientFeatures.GetStringSlice()); err != nil {
+		return errors.Wrap(err, "invalid Server.RequiredClientFeatures")
+	}
+	versions := server_structs.GetProtocolVersions()
+	ad.Versions = &versions
+	if capacity := param.Server_IOCapacity.GetInt(); capacity > 0 {
+		ad.IOCapacity = int64(capacity)
+	}
 
 	body, err := json.Marshal(*ad)
 	if err != nil {
@@ -199,6 +265,9 @@ func advertiseInternal(ctx context.Context, server server_structs.XRootDServer)
 	if err != nil {
 		return errors.Wrap(err, "failed to parse Federation.DirectorURL")
 	}
+	if err := checkDirectorCompatibility(ctx, directorUrlStr); err != nil {
+		return err
+	}
 
 	directorUrl.Path = "/api/v1.0/director/register" + server.GetServerType().String()
 
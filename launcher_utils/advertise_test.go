@@ -25,6 +25,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
@@ -84,3 +85,52 @@ func TestGetSitenameFromReg(t *testing.T) {
 		assert.Equal(t, "bar", sitename)
 	})
 }
+
+func TestCheckDirectorCompatibility(t *testing.T) {
+	t.Cleanup(func() {
+		compatibleDirectorsMutex.Lock()
+		compatibleDirectors = map[string]time.Time{}
+		compatibleDirectorsMutex.Unlock()
+	})
+
+	newDirector := func(t *testing.T, versions *server_structs.ProtocolVersions) (*httptest.Server, *int) {
+		requests := 0
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			requests++
+			assert.Equal(t, "/api/v1.0/health", req.URL.Path)
+			body := map[string]interface{}{"message": "Web Engine Running"}
+			if versions != nil {
+				body["versions"] = versions
+			}
+			require.NoError(t, json.NewEncoder(w).Encode(body))
+		}))
+		t.Cleanup(ts.Close)
+		return ts, &requests
+	}
+
+	t.Run("compatible-director-is-remembered", func(t *testing.T) {
+		versions := server_structs.GetProtocolVersions()
+		ts, requests := newDirector(t, &versions)
+		require.NoError(t, checkDirectorCompatibility(context.Background(), ts.URL))
+		require.NoError(t, checkDirectorCompatibility(context.Background(), ts.URL))
+		assert.Equal(t, 1, *requests)
+	})
+
+	t.Run("director-without-versions", func(t *testing.T) {
+		ts, _ := newDirector(t, nil)
+		assert.NoError(t, checkDirectorCompatibility(context.Background(), ts.URL))
+	})
+
+	t.Run("incompatible-director", func(t *testing.T) {
+		versions := server_structs.GetProtocolVersions()
+		versions.Version = "9.0.0"
+		versions.Advertisement = server_structs.VersionRange{Min: "3", Max: "4"}
+		ts, requests := newDirector(t, &versions)
+		err := checkDirectorCompatibility(context.Background(), ts.URL)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no common advertisement version")
+		// Incompatible directors are checked again on the next advertisement
+		require.Error(t, checkDirectorCompatibility(context.Background(), ts.URL))
+		assert.Equal(t, 2, *requests)
+	})
+}
@@ -68,6 +68,12 @@ func CacheServe(ctx context.Context, engine *gin.Engine, egrp *errgroup.Group, m
 		return nil, err
 	}
 
+	if param.Cache_EnableWriteBack.GetBool() {
+		if err = cache.RegisterWriteBack(ctx, egrp, engine, cacheServer.GetNamespaceAds); err != nil {
+			return nil, err
+		}
+	}
+
 	// Register Lotman
 	if param.Cache_EnableLotman.GetBool() {
 		// Register the web endpoints
@@ -39,13 +39,27 @@ func DirectorServe(ctx context.Context, engine *gin.Engine, egrp *errgroup.Group
 	log.Info("Initializing Director GeoIP database...")
 	director.InitializeDB(ctx)
 
+	if err := director.InitializeDirectorDB(); err != nil {
+		return errors.Wrap(err, "failed to initialize the director's database")
+	}
+	egrp.Go(func() error {
+		<-ctx.Done()
+		return director.ShutdownDirectorDB()
+	})
+
 	director.ConfigFilterdServers()
 
 	director.LaunchTTLCache(ctx, egrp)
 
 	director.LaunchMapMetrics(ctx, egrp)
 
-	if config.GetPreferredPrefix() == config.OsdfPrefix {
+	director.LaunchServerIOQuery(ctx, egrp)
+
+	director.LaunchNamespaceKeysRefresh(ctx, egrp)
+
+	director.LaunchMirrorDivergenceChecks(ctx, egrp)
+
+	if config.GetPreferredPrefix() == config.OsdfPrefix || len(param.Director_TopologyNamespaceUrls.GetStringSlice()) > 0 {
 		metrics.SetComponentHealthStatus(metrics.DirectorRegistry_Topology, metrics.StatusWarning, "Start requesting from topology, status unknown")
 		log.Info("Generating/advertising server ads from OSG topology service...")
 
@@ -55,6 +69,7 @@ func DirectorServe(ctx context.Context, engine *gin.Engine, egrp *errgroup.Group
 			return err
 		}
 		go director.PeriodicCacheReload(ctx)
+		director.LaunchServerEndpointChecks(ctx, egrp)
 	}
 
 	// Configure the shortcut middleware to either redirect to a cache
@@ -74,6 +89,7 @@ func DirectorServe(ctx context.Context, engine *gin.Engine, egrp *errgroup.Group
 	rootGroup := engine.Group("/")
 	director.RegisterDirectorOIDCAPI(rootGroup)
 	director.RegisterDirectorWebAPI(rootGroup)
+	director.RegisterFederationStatus()
 	engine.Use(director.ShortcutMiddleware(defaultResponse))
 	director.RegisterDirectorAPI(ctx, rootGroup)
 
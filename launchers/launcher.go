@@ -38,6 +38,7 @@ import (
 	"github.com/pelicanplatform/pelican/config"
 	"github.com/pelicanplatform/pelican/launcher_utils"
 	"github.com/pelicanplatform/pelican/local_cache"
+	"github.com/pelicanplatform/pelican/metrics"
 	"github.com/pelicanplatform/pelican/origin"
 	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/server_structs"
@@ -97,6 +98,12 @@ func LaunchModules(ctx context.Context, modules config.ServerType) (servers []se
 		return
 	}
 
+	instanceName := fmt.Sprintf("%s:%d", param.Server_Hostname.GetString(), param.Server_WebPort.GetInt())
+	if err = metrics.ConfigureRuntimeMetrics(config.GetEnabledServerString(true), instanceName); err != nil {
+		err = errors.Wrap(err, "Failure when configuring the runtime metrics")
+		return
+	}
+
 	// Set up necessary APIs to support Web UI, including auth and metrics
 	if err = web_ui.ConfigureServerWebAPI(ctx, engine, egrp); err != nil {
 		return
@@ -221,6 +228,12 @@ func LaunchModules(ctx context.Context, modules config.ServerType) (servers []se
 		}
 	}
 
+	// Fetched once the web engine is up, as the director may be served by this process;
+	// the CA bundles written for XRootD pick up the bundle's server CAs on their next refresh
+	if modules.IsEnabled(config.OriginType) || modules.IsEnabled(config.CacheType) {
+		config.LaunchTrustBundleRefresh(ctx, egrp)
+	}
+
 	if modules.IsEnabled(config.OriginType) {
 		log.Debug("Finishing origin server configuration")
 		if err = OriginServeFinish(ctx, egrp); err != nil {
@@ -344,6 +357,10 @@ func LaunchModules(ctx context.Context, modules config.ServerType) (servers []se
 
 	}
 
+	if err = metrics.LaunchOTLPExport(ctx, egrp); err != nil {
+		return
+	}
+
 	if param.Server_EnableUI.GetBool() {
 		if err = web_ui.ConfigureEmbeddedPrometheus(ctx, engine); err != nil {
 			err = errors.Wrap(err, "Failed to configure embedded prometheus instance")
@@ -24,6 +24,7 @@ import (
 	"context"
 	_ "embed"
 	"net/url"
+	"os"
 	"strconv"
 	"time"
 
@@ -67,6 +68,11 @@ func OriginServe(ctx context.Context, engine *gin.Engine, egrp *errgroup.Group,
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to initialize origin exports")
 	}
+	exportPrefixes := make([]string, 0, len(originExports))
+	for _, export := range originExports {
+		exportPrefixes = append(exportPrefixes, export.FederationPrefix)
+	}
+	metrics.SetBandwidthNamespaces(exportPrefixes)
 
 	if param.Origin_StorageType.GetString() == string(server_utils.OriginStorageGlobus) {
 		if err := origin.InitGlobusBackend(originExports); err != nil {
@@ -75,11 +81,45 @@ func OriginServe(ctx context.Context, engine *gin.Engine, egrp *errgroup.Group,
 		origin.LaunchGlobusTokenRefresh(ctx, egrp)
 	}
 
+	if param.Origin_StorageType.GetString() == string(server_utils.OriginStorageS3) && param.Origin_S3EnableRequestTracking.GetBool() {
+		if err := origin.LaunchS3Gateway(ctx, egrp, originExports); err != nil {
+			return nil, errors.Wrap(err, "failed to launch the S3 request-tracking gateway")
+		}
+	}
+
+	if param.Origin_StorageType.GetString() == string(server_utils.OriginStorageHTTPS) && origin.HttpsGatewayNeeded() {
+		if err := origin.LaunchHttpsGateway(ctx, egrp); err != nil {
+			return nil, errors.Wrap(err, "failed to launch the HTTPS backend gateway")
+		}
+	}
+
+	if param.Origin_EnableDatasetStats.GetBool() {
+		origin.LaunchDatasetStats(ctx, egrp, originExports)
+	}
+
+	if param.Origin_AnonymousRateLimits.IsSet() || param.Origin_EnableContentTypes.GetBool() {
+		if err := origin.LaunchAnonymousLimitGateway(ctx, egrp, originExports, getOriginTrustedIssuers()); err != nil {
+			return nil, errors.Wrap(err, "failed to launch the origin's HTTP gateway")
+		}
+	}
+
 	// Set up the APIs unrelated to UI, which only contains director-based health test reporting endpoint for now
 	if err = origin.RegisterOriginAPI(engine, ctx, egrp); err != nil {
 		return nil, err
 	}
 
+	if param.Origin_EnableResumableUploads.GetBool() && param.Origin_EnableWrites.GetBool() {
+		if err = origin.RegisterResumableUploads(ctx, egrp, engine, originExports, getOriginTrustedIssuers()); err != nil {
+			return nil, errors.Wrap(err, "failed to set up resumable uploads")
+		}
+	}
+
+	if origin.IsTapeBacked() {
+		if err = origin.RegisterTapeStageApi(engine, originExports, getOriginTrustedIssuers()); err != nil {
+			return nil, errors.Wrap(err, "failed to set up the tape stage API")
+		}
+	}
+
 	// Set up the APIs for the origin UI
 	if err = origin.RegisterOriginWebAPI(engine); err != nil {
 		return nil, err
@@ -105,6 +145,22 @@ func OriginServe(ctx context.Context, engine *gin.Engine, egrp *errgroup.Group,
 		egrp.Go(func() error { return origin.PeriodicSelfTest(ctx) })
 	}
 
+	if param.Origin_ExportAudit.GetBool() {
+		egrp.Go(func() error { return origin.PeriodicExportAudit(ctx) })
+	}
+
+	if param.Origin_EnableCatalogStats.GetBool() {
+		egrp.Go(func() error { return origin.PeriodicCatalogStats(ctx) })
+	}
+
+	if param.Origin_EnableBackups.GetBool() {
+		egrp.Go(func() error { return origin.PeriodicBackups(ctx) })
+	}
+
+	if param.Origin_StorageWriteCheck.GetBool() && param.Origin_EnableWrites.GetBool() {
+		egrp.Go(func() error { return origin.PeriodicStorageWriteCheck(ctx) })
+	}
+
 	privileged := param.Origin_Multiuser.GetBool()
 	launchers, err := xrootd.ConfigureLaunchers(privileged, configPath, param.Origin_EnableCmsd.GetBool(), false)
 	if err != nil {
@@ -120,6 +176,12 @@ func OriginServe(ctx context.Context, engine *gin.Engine, egrp *errgroup.Group,
 	}
 
 	portStartCallback := func(port int) {
+		// Behind the anonymous limit gateway, clients keep using the gateway's port
+		if origin.IsAnonymousLimitGatewayActive() {
+			origin.SetXrootdInternalPort(port)
+			log.Debugln("XRootD is listening behind the anonymous limit gateway on port", port)
+			port = param.Origin_Port.GetInt()
+		}
 		viper.Set("Origin.Port", port)
 		if originUrl, err := url.Parse(param.Origin_Url.GetString()); err == nil {
 			originUrl.Host = originUrl.Hostname() + ":" + strconv.Itoa(port)
@@ -143,6 +205,27 @@ func OriginServe(ctx context.Context, engine *gin.Engine, egrp *errgroup.Group,
 	return originServer, nil
 }
 
+// Returns the issuers whose tokens XRootD accepts: the origin's own issuer and those
+// configured in the admin's scitokens configuration
+func getOriginTrustedIssuers() []string {
+	issuers := []string{}
+	if issuerUrl, err := config.GetServerIssuerURL(); err == nil {
+		issuers = append(issuers, issuerUrl)
+	} else {
+		log.Warningln("Failed to determine the origin's issuer:", err)
+	}
+	if scitokensCfg := param.Xrootd_ScitokensConfig.GetString(); scitokensCfg != "" {
+		if cfg, err := xrootd.LoadScitokensConfig(scitokensCfg); err == nil {
+			for issuer := range cfg.IssuerMap {
+				issuers = append(issuers, issuer)
+			}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			log.Warningf("Failed to load the scitokens configuration at %s: %v", scitokensCfg, err)
+		}
+	}
+	return issuers
+}
+
 // Finish configuration of the origin server.  To be invoked after the web UI components
 // have been launched.
 func OriginServeFinish(ctx context.Context, egrp *errgroup.Group) error {
@@ -64,6 +64,12 @@ func RegistryServe(ctx context.Context, engine *gin.Engine, egrp *errgroup.Group
 		go registry.PeriodicTopologyReload(ctx)
 	}
 
+	if param.Registry_MirrorOf.GetString() != "" {
+		metrics.SetComponentHealthStatus(metrics.Registry_Mirror, metrics.StatusWarning, "Start mirroring the primary registry, status unknown")
+		log.Infof("Mirroring the namespaces of the primary registry at %s", param.Registry_MirrorOf.GetString())
+		registry.LaunchNamespaceMirror(ctx, egrp)
+	}
+
 	rootRouterGroup := engine.Group("/")
 	// Register routes for server/Pelican client facing APIs
 	registry.RegisterRegistryAPI(rootRouterGroup)
@@ -71,6 +77,10 @@ func RegistryServe(ctx context.Context, engine *gin.Engine, egrp *errgroup.Group
 	if err := registry.RegisterRegistryWebAPI(rootRouterGroup); err != nil {
 		return err
 	}
+	// Register routes for the versioned REST API used by external portals
+	if err := registry.RegisterRegistryV2API(rootRouterGroup); err != nil {
+		return err
+	}
 
 	egrp.Go(func() error {
 		<-ctx.Done()
@@ -130,6 +130,8 @@ func (lc *LocalCache) LaunchListener(ctx context.Context, egrp *errgroup.Group)
 			}
 			return
 		}
+		obj, _ := reader.(*cachedObject)
+		setFreshnessHeaders(w.Header(), obj)
 		w.WriteHeader(http.StatusOK)
 		if r.Method == "HEAD" {
 			return
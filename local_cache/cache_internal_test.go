@@ -21,10 +21,16 @@
 package local_cache
 
 import (
+	"net/http"
 	"testing"
+	"time"
 
+	"github.com/pelicanplatform/pelican/client"
 	"github.com/pelicanplatform/pelican/token_scopes"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCalcResources(t *testing.T) {
@@ -80,3 +86,89 @@ func TestCalcResources(t *testing.T) {
 		assert.Equal(t, test.result, result)
 	}
 }
+
+func TestDecideRevalidation(t *testing.T) {
+	t.Cleanup(viper.Reset)
+
+	statCalled := false
+	statResult := func(size uint64, err error) func() (uint64, error) {
+		statCalled = false
+		return func() (uint64, error) {
+			statCalled = true
+			return size, err
+		}
+	}
+	unreachable := errors.New("connection refused")
+
+	// Revalidation disabled; objects are always fresh
+	action, err := decideRevalidation(24*time.Hour, 10, statResult(0, unreachable))
+	require.NoError(t, err)
+	assert.Equal(t, serveFresh, action)
+	assert.False(t, statCalled)
+
+	viper.Set("LocalCache.RevalidateInterval", time.Hour)
+
+	// Within the revalidation window, the origin is not contacted
+	action, err = decideRevalidation(time.Minute, 10, statResult(0, unreachable))
+	require.NoError(t, err)
+	assert.Equal(t, serveFresh, action)
+	assert.False(t, statCalled)
+
+	// Outside the window, the object is compared against the origin
+	action, err = decideRevalidation(2*time.Hour, 10, statResult(10, nil))
+	require.NoError(t, err)
+	assert.Equal(t, serveFresh, action)
+	assert.True(t, statCalled)
+
+	action, err = decideRevalidation(2*time.Hour, 10, statResult(11, nil))
+	require.NoError(t, err)
+	assert.Equal(t, refetchObject, action)
+
+	action, err = decideRevalidation(2*time.Hour, 10, statResult(0, &client.HttpErrResp{Code: http.StatusNotFound, Err: "not found"}))
+	assert.Error(t, err)
+	assert.Equal(t, refetchObject, action)
+
+	action, err = decideRevalidation(2*time.Hour, 10, statResult(0, &client.HttpErrResp{Code: http.StatusForbidden, Err: "denied"}))
+	assert.Error(t, err)
+	assert.Equal(t, failRequest, action)
+
+	// Without a max staleness, unreachable origins cause a failure
+	action, err = decideRevalidation(2*time.Hour, 10, statResult(0, unreachable))
+	assert.ErrorIs(t, err, unreachable)
+	assert.Equal(t, failRequest, action)
+
+	viper.Set("LocalCache.MaxStaleness", 2*time.Hour)
+
+	action, err = decideRevalidation(2*time.Hour, 10, statResult(0, unreachable))
+	assert.Error(t, err)
+	assert.Equal(t, serveStale, action)
+
+	action, err = decideRevalidation(2*time.Hour, 10, statResult(0, &client.HttpErrResp{Code: http.StatusBadGateway, Err: "bad gateway"}))
+	assert.Error(t, err)
+	assert.Equal(t, serveStale, action)
+
+	// Past the max staleness, the object is no longer served
+	action, err = decideRevalidation(4*time.Hour, 10, statResult(0, unreachable))
+	assert.Error(t, err)
+	assert.Equal(t, failRequest, action)
+}
+
+func TestSetFreshnessHeaders(t *testing.T) {
+	t.Cleanup(viper.Reset)
+
+	header := http.Header{}
+	setFreshnessHeaders(header, &cachedObject{age: time.Minute})
+	assert.Empty(t, header)
+
+	viper.Set("LocalCache.RevalidateInterval", time.Hour)
+	setFreshnessHeaders(header, nil)
+	assert.Equal(t, "max-age=3600", header.Get("Cache-Control"))
+	assert.Empty(t, header.Get("Age"))
+
+	viper.Set("LocalCache.MaxStaleness", 24*time.Hour)
+	header = http.Header{}
+	setFreshnessHeaders(header, &cachedObject{age: 90 * time.Minute, stale: true})
+	assert.Equal(t, "max-age=3600, stale-if-error=86400", header.Get("Cache-Control"))
+	assert.Equal(t, "5400", header.Get("Age"))
+	assert.Equal(t, `111 - "Revalidation Failed"`, header.Get("Warning"))
+}
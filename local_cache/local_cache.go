@@ -687,12 +687,17 @@ func (sc *LocalCache) Get(ctx context.Context, path, token string) (io.ReadClose
 	}
 
 	if fp := sc.getFromDisk(path); fp != nil {
-		finfo, err := fp.Stat()
-		if err != nil {
-			log.Warningf("Able to open %s in cache but unable to stat it: %v", path, err)
+		obj, err := sc.revalidate(ctx, path, token, fp)
+		if err != nil && obj == nil {
+			return nil, err
+		} else if obj != nil {
+			finfo, err := obj.Stat()
+			if err != nil {
+				log.Warningf("Able to open %s in cache but unable to stat it: %v", path, err)
+			}
+			sc.hitChan <- lruEntry{lastUse: time.Now(), path: path, size: finfo.Size()}
+			return obj, nil
 		}
-		sc.hitChan <- lruEntry{lastUse: time.Now(), path: path, size: finfo.Size()}
-		return fp, nil
 	}
 
 	return sc.newCacheReader(ctx, path, token)
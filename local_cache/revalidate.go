@@ -0,0 +1,167 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package local_cache
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelicanplatform/pelican/client"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+type (
+	// A handle to an object served directly from the on-disk cache,
+	// along with its freshness information
+	cachedObject struct {
+		*os.File
+		age   time.Duration
+		stale bool
+	}
+
+	revalidateAction int
+)
+
+const (
+	serveFresh    revalidateAction = iota // Object is fresh (or was successfully revalidated)
+	serveStale                            // Origin is unreachable but the object is within the max staleness
+	refetchObject                         // Object changed or disappeared upstream; discard the cached copy
+	failRequest                           // Object cannot be revalidated and is too stale to serve
+)
+
+// Decide what to do with a cached object of the given age and size.
+//
+// The stat function is only invoked if the object is outside its revalidation
+// window; it should return the size of the object at the origin.
+func decideRevalidation(age time.Duration, cachedSize uint64, stat func() (uint64, error)) (revalidateAction, error) {
+	revalidateInterval := param.LocalCache_RevalidateInterval.GetDuration()
+	if revalidateInterval <= 0 || age <= revalidateInterval {
+		return serveFresh, nil
+	}
+	remoteSize, err := stat()
+	if err == nil {
+		if remoteSize == cachedSize {
+			return serveFresh, nil
+		}
+		return refetchObject, nil
+	}
+
+	// The origin gave a definitive answer; don't mask it with stale data
+	var httpErr *client.HttpErrResp
+	if errors.As(err, &httpErr) && httpErr.Code < 500 {
+		if httpErr.Code == http.StatusNotFound {
+			return refetchObject, err
+		}
+		return failRequest, err
+	}
+
+	maxStaleness := param.LocalCache_MaxStaleness.GetDuration()
+	if maxStaleness > 0 && age <= revalidateInterval+maxStaleness {
+		return serveStale, err
+	}
+	return failRequest, errors.Wrap(err, "cached object needs revalidation but the origin is unreachable")
+}
+
+// Determine how long ago an object was placed in the cache, based on its
+// DONE sentinel file.
+func (sc *LocalCache) cachedAge(objPath string) time.Duration {
+	fi, err := os.Stat(filepath.Join(sc.basePath, path.Clean(objPath)) + ".DONE")
+	if err != nil {
+		return 0
+	}
+	return time.Since(fi.ModTime())
+}
+
+// Check a cached object against the federation if it's outside the revalidation window.
+//
+// Returns the cached object if it can be served, nil if the object needs to be
+// downloaded again, or an error if it cannot be served.
+func (sc *LocalCache) revalidate(ctx context.Context, objPath, token string, fp *os.File) (*cachedObject, error) {
+	obj := &cachedObject{File: fp, age: sc.cachedAge(objPath)}
+	finfo, err := fp.Stat()
+	if err != nil {
+		fp.Close()
+		return nil, errors.Wrapf(err, "unable to stat cached object %s", objPath)
+	}
+	action, err := decideRevalidation(obj.age, uint64(finfo.Size()), func() (uint64, error) {
+		dUrl := *sc.directorURL
+		dUrl.Path = objPath
+		dUrl.Scheme = "pelican"
+		return client.DoStat(ctx, dUrl.String(), client.WithToken(token))
+	})
+	doneFile := filepath.Join(sc.basePath, path.Clean(objPath)) + ".DONE"
+	switch action {
+	case serveFresh:
+		if obj.age > param.LocalCache_RevalidateInterval.GetDuration() && param.LocalCache_RevalidateInterval.GetDuration() > 0 {
+			// Successfully revalidated; restart the revalidation window
+			now := time.Now()
+			if err := os.Chtimes(doneFile, now, now); err != nil {
+				log.Debugf("Failed to update revalidation time of %s: %v", objPath, err)
+			}
+			obj.age = 0
+		}
+		return obj, nil
+	case serveStale:
+		log.Warningf("Serving stale copy of %s (age %s) as the origin is unreachable: %v", objPath, obj.age.Round(time.Second), err)
+		obj.stale = true
+		return obj, nil
+	case refetchObject:
+		fp.Close()
+		log.Debugf("Cached copy of %s is out of date; discarding it", objPath)
+		for _, fname := range []string{doneFile, strings.TrimSuffix(doneFile, ".DONE")} {
+			if rmErr := os.Remove(fname); rmErr != nil && !errors.Is(rmErr, os.ErrNotExist) {
+				log.Warningf("Failed to invalidate cached copy of %s: %v", objPath, rmErr)
+			}
+		}
+		return nil, err
+	default:
+		fp.Close()
+		return nil, err
+	}
+}
+
+// Advertise the freshness of a response from the cache via the standard
+// HTTP caching headers
+func setFreshnessHeaders(header http.Header, obj *cachedObject) {
+	revalidateInterval := param.LocalCache_RevalidateInterval.GetDuration()
+	if revalidateInterval <= 0 {
+		return
+	}
+	cacheControl := fmt.Sprintf("max-age=%d", int64(revalidateInterval.Seconds()))
+	if maxStaleness := param.LocalCache_MaxStaleness.GetDuration(); maxStaleness > 0 {
+		cacheControl += fmt.Sprintf(", stale-if-error=%d", int64(maxStaleness.Seconds()))
+	}
+	header.Set("Cache-Control", cacheControl)
+	if obj == nil {
+		return
+	}
+	header.Set("Age", strconv.FormatInt(int64(obj.age.Seconds()), 10))
+	if obj.stale {
+		header.Set("Warning", `111 - "Revalidation Failed"`)
+	}
+}
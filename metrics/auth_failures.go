@@ -0,0 +1,123 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/utils"
+)
+
+type (
+	AuthFailureReason string
+
+	// A single authentication/authorization failure observed by the server
+	AuthFailure struct {
+		Time    time.Time         `json:"time"`
+		Source  string            `json:"source"` // The daemon or service that rejected the request, e.g. "xrootd.origin"
+		Reason  AuthFailureReason `json:"reason"`
+		Message string            `json:"message"`
+	}
+)
+
+const (
+	AuthFailureExpiredToken     AuthFailureReason = "expired_token"
+	AuthFailureAudienceMismatch AuthFailureReason = "audience_mismatch"
+	AuthFailureScopeMismatch    AuthFailureReason = "scope_mismatch"
+	AuthFailureUnknownIssuer    AuthFailureReason = "unknown_issuer"
+	AuthFailureOther            AuthFailureReason = "other"
+
+	// Messages longer than this are truncated before being kept in memory
+	authFailureMaxMessageLen = 1024
+)
+
+var (
+	PelicanAuthFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_auth_failures_total",
+		Help: "The number of authentication/authorization failures observed by the server, by reason",
+	}, []string{"source", "reason"})
+
+	// The most recent auth failures
+	authFailures = &utils.Ring[AuthFailure]{}
+
+	// Log lines of the XRootD token plugins (e.g. "scitokens_Access: Failed to ...")
+	// that report a rejected token or a denied request
+	xrootdAuthFailureRegex = regexp.MustCompile(`(?i)\b(scitokens|sec[a-z]*|acc)_[a-z]+:.*(fail|den(ied|y)|reject|invalid|expired|not allowed|not authorized|not in|insufficient|unable to)`)
+
+	expiredTokenRegex     = regexp.MustCompile(`(?i)expired|\bexp\b.*(claim|past)|token is no longer valid`)
+	audienceMismatchRegex = regexp.MustCompile(`(?i)audience|\baud\b`)
+	unknownIssuerRegex    = regexp.MustCompile(`(?i)issuer.*(not (in|one of|allowed|trusted|within)|unknown|untrusted)|(unknown|untrusted) issuer`)
+	scopeMismatchRegex    = regexp.MustCompile(`(?i)scope|insufficient|not authorized|does not (grant|permit|authorize)|\bacls?\b|permission denied`)
+)
+
+// Classify the message of an auth failure by its most likely cause.  The checks
+// are ordered so that the more specific causes win: a token of an unknown issuer
+// commonly also fails to produce ACLs, for example.
+func ClassifyAuthFailure(message string) AuthFailureReason {
+	switch {
+	case expiredTokenRegex.MatchString(message):
+		return AuthFailureExpiredToken
+	case unknownIssuerRegex.MatchString(message):
+		return AuthFailureUnknownIssuer
+	case audienceMismatchRegex.MatchString(message):
+		return AuthFailureAudienceMismatch
+	case scopeMismatchRegex.MatchString(message):
+		return AuthFailureScopeMismatch
+	default:
+		return AuthFailureOther
+	}
+}
+
+// Record an auth failure observed by `source`, counting it in the
+// pelican_auth_failures_total metric and keeping it in the recent failures log
+func RecordAuthFailure(source string, message string) AuthFailureReason {
+	reason := ClassifyAuthFailure(message)
+	PelicanAuthFailures.WithLabelValues(source, string(reason)).Inc()
+	if len(message) > authFailureMaxMessageLen {
+		message = message[:authFailureMaxMessageLen]
+	}
+	authFailures.Add(AuthFailure{
+		Time:    time.Now(),
+		Source:  source,
+		Reason:  reason,
+		Message: message,
+	}, param.Monitoring_AuthFailureLogSize.GetInt())
+	return reason
+}
+
+// Check a line of the XRootD log for an auth failure reported by its token or
+// security plugins, recording it if found
+func HandleXrootdLogLine(source string, line string) bool {
+	if !xrootdAuthFailureRegex.MatchString(line) {
+		return false
+	}
+	RecordAuthFailure(source, strings.TrimSpace(line))
+	return true
+}
+
+// Return the recently recorded auth failures, newest first
+func GetRecentAuthFailures() []AuthFailure {
+	return authFailures.Snapshot()
+}
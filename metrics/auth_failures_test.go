@@ -0,0 +1,86 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyAuthFailure(t *testing.T) {
+	for message, expected := range map[string]AuthFailureReason{
+		"Failed to deserialize SciToken: token verification failed: token expired": AuthFailureExpiredToken,
+		"token is expired by 2h3m0s":                                                   AuthFailureExpiredToken,
+		"Failed to deserialize SciToken: 'aud' claim verification failed.":             AuthFailureAudienceMismatch,
+		"token audience https://other.example.com does not match":                      AuthFailureAudienceMismatch,
+		"Failed to deserialize SciToken: Issuer is not within list of allowed issuers": AuthFailureUnknownIssuer,
+		"token issuer https://evil.example.com is not one of the trusted issuers":      AuthFailureUnknownIssuer,
+		"Token does not grant access to /foo/bar; insufficient scope":                  AuthFailureScopeMismatch,
+		"Failed to generate ACLs for token":                                            AuthFailureScopeMismatch,
+		"Failed to deserialize SciToken: Unable to parse token":                        AuthFailureOther,
+	} {
+		assert.Equal(t, expected, ClassifyAuthFailure(message), message)
+	}
+}
+
+func TestHandleXrootdLogLine(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(func() {
+		viper.Reset()
+		authFailures.Reset()
+	})
+	viper.Set("Monitoring.AuthFailureLogSize", 2)
+	authFailures.Reset()
+
+	count := func(reason AuthFailureReason) float64 {
+		return testutil.ToFloat64(PelicanAuthFailures.WithLabelValues("xrootd.origin", string(reason)))
+	}
+	expired, issuer := count(AuthFailureExpiredToken), count(AuthFailureUnknownIssuer)
+
+	assert.False(t, HandleXrootdLogLine("xrootd.origin", "241015 12:00:00 1234 XrootdXeq: alice.1:27@host pub IPv4 login"))
+	assert.False(t, HandleXrootdLogLine("xrootd.origin", "241015 12:00:00 1234 scitokens_Access: Checking token for /foo"))
+	for idx := 0; idx < 2; idx++ {
+		assert.True(t, HandleXrootdLogLine("xrootd.origin",
+			fmt.Sprintf("241015 12:00:0%d 1234 scitokens_GenerateAcls: Failed to deserialize SciToken: token expired", idx)))
+	}
+	assert.True(t, HandleXrootdLogLine("xrootd.origin",
+		"241015 12:00:05 1234 scitokens_GenerateAcls: Failed to deserialize SciToken: Issuer is not within list of allowed issuers"))
+
+	assert.Equal(t, expired+2, count(AuthFailureExpiredToken))
+	assert.Equal(t, issuer+1, count(AuthFailureUnknownIssuer))
+
+	// Only the most recent failures are kept, newest first
+	recent := GetRecentAuthFailures()
+	require.Len(t, recent, 2)
+	assert.Equal(t, AuthFailureUnknownIssuer, recent[0].Reason)
+	assert.Equal(t, "xrootd.origin", recent[0].Source)
+	assert.Equal(t, AuthFailureExpiredToken, recent[1].Reason)
+	assert.Contains(t, recent[1].Message, "12:00:01")
+
+	// Failures are still counted when the log is disabled
+	viper.Set("Monitoring.AuthFailureLogSize", 0)
+	RecordAuthFailure("xrootd.origin", "token expired")
+	assert.Empty(t, GetRecentAuthFailures())
+	assert.Equal(t, expired+3, count(AuthFailureExpiredToken))
+}
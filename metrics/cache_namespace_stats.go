@@ -0,0 +1,142 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+type (
+	// The efficiency of a cache for a namespace since the cache started.  Hit, miss and
+	// bypass bytes come from the cache's (pfc) g-stream; served bytes and reads come
+	// from the transfer records of the f-stream.
+	CacheNamespaceStats struct {
+		Namespace   string `json:"namespace"`
+		HitBytes    int64  `json:"hitBytes"`    // Served from the cache
+		MissBytes   int64  `json:"missBytes"`   // Fetched from the origin to serve a request
+		BypassBytes int64  `json:"bypassBytes"` // Passed through from the origin without being cached
+		ServedBytes int64  `json:"servedBytes"`
+		Reads       int64  `json:"reads"`
+		// HitBytes over all the bytes accessed through the cache, or 0 before any access
+		HitRatio float64 `json:"hitRatio"`
+	}
+
+	cacheNamespaceStatsTracker struct {
+		lock sync.Mutex
+		// Namespaces known to the cache; nil if the cache hasn't set them
+		namespaces namespaceMatcher
+		enabled    bool
+		stats      map[string]*CacheNamespaceStats
+	}
+)
+
+var (
+	PelicanCacheNamespaceBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_cache_namespace_bytes_total",
+		Help: "The total number of bytes accessed through the cache, by namespace and whether they were a cache hit, fetched from the origin (miss), or bypassed the cache",
+	}, []string{"namespace", "source"})
+
+	PelicanCacheNamespaceServedBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_cache_namespace_served_bytes_total",
+		Help: "The total number of bytes the cache sent to clients, by namespace, from the transfer records of closed files",
+	}, []string{"namespace"})
+
+	PelicanCacheNamespaceHitRatio = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pelican_cache_namespace_hit_ratio",
+		Help: "The fraction of the bytes accessed through the cache since it started that were cache hits, by namespace",
+	}, []string{"namespace"})
+
+	cacheNamespaceStats = cacheNamespaceStatsTracker{stats: make(map[string]*CacheNamespaceStats)}
+)
+
+// Set the namespaces the cache's efficiency is tracked by, e.g. from the namespace ads of the
+// director, and start tracking.  Accesses outside of the namespaces are tracked by their
+// Monitoring.AggregatePrefixes prefix.
+func SetCacheNamespaces(namespaces []string) {
+	matcher := newNamespaceMatcher(namespaces)
+	cacheNamespaceStats.lock.Lock()
+	defer cacheNamespaceStats.lock.Unlock()
+	cacheNamespaceStats.namespaces = matcher
+	cacheNamespaceStats.enabled = true
+}
+
+// Get the stats of a namespace, creating them if needed; the caller must hold the lock
+func (tracker *cacheNamespaceStatsTracker) get(namespace string) *CacheNamespaceStats {
+	stats, ok := tracker.stats[namespace]
+	if !ok {
+		stats = &CacheNamespaceStats{Namespace: namespace}
+		tracker.stats[namespace] = stats
+	}
+	return stats
+}
+
+// Record the bytes accessed through the cache reported by a pfc g-stream record
+func (tracker *cacheNamespaceStatsTracker) recordAccess(lfn string, hit, miss, bypass int64) {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	if !tracker.enabled {
+		return
+	}
+	namespace := tracker.namespaces.lookup(lfn)
+	stats := tracker.get(namespace)
+	stats.HitBytes += hit
+	stats.MissBytes += miss
+	stats.BypassBytes += bypass
+	if total := stats.HitBytes + stats.MissBytes + stats.BypassBytes; total > 0 {
+		stats.HitRatio = float64(stats.HitBytes) / float64(total)
+	}
+
+	PelicanCacheNamespaceBytes.WithLabelValues(namespace, "hit").Add(float64(hit))
+	PelicanCacheNamespaceBytes.WithLabelValues(namespace, "miss").Add(float64(miss))
+	PelicanCacheNamespaceBytes.WithLabelValues(namespace, "bypass").Add(float64(bypass))
+	PelicanCacheNamespaceHitRatio.WithLabelValues(namespace).Set(stats.HitRatio)
+}
+
+// Record a file the cache closed, as reported by the f-stream; writes aren't reads of the cache
+func (tracker *cacheNamespaceStatsTracker) recordClosedFile(file ClosedFile) {
+	if file.WriteBytes > 0 {
+		return
+	}
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	if !tracker.enabled {
+		return
+	}
+	namespace := tracker.namespaces.lookup(file.LFN)
+	stats := tracker.get(namespace)
+	stats.ServedBytes += int64(file.ReadBytes)
+	stats.Reads++
+	PelicanCacheNamespaceServedBytes.WithLabelValues(namespace).Add(float64(file.ReadBytes))
+}
+
+// Get the efficiency of the cache for each namespace it served, ordered by namespace
+func GetCacheNamespaceStats() []CacheNamespaceStats {
+	cacheNamespaceStats.lock.Lock()
+	defer cacheNamespaceStats.lock.Unlock()
+	result := make([]CacheNamespaceStats, 0, len(cacheNamespaceStats.stats))
+	for _, stats := range cacheNamespaceStats.stats {
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Namespace < result[j].Namespace })
+	return result
+}
@@ -0,0 +1,90 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetCacheNamespaceStats(t *testing.T) {
+	reset := func() {
+		cacheNamespaceStats.lock.Lock()
+		cacheNamespaceStats.namespaces = nil
+		cacheNamespaceStats.enabled = false
+		cacheNamespaceStats.stats = make(map[string]*CacheNamespaceStats)
+		cacheNamespaceStats.lock.Unlock()
+		PelicanCacheNamespaceBytes.Reset()
+		PelicanCacheNamespaceServedBytes.Reset()
+		PelicanCacheNamespaceHitRatio.Reset()
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+// Build a pfc g-stream packet with the given JSON records
+func cacheGStreamPacket(records string) []byte {
+	packet := make([]byte, 24)
+	packet[0] = 'g'
+	binary.BigEndian.PutUint64(packet[16:24], uint64('C')<<XROOTD_MON_PIDSHFT)
+	packet = append(packet, []byte(records+"\x00")...)
+	binary.BigEndian.PutUint16(packet[2:4], uint16(len(packet)))
+	return packet
+}
+
+func TestCacheNamespaceStats(t *testing.T) {
+	resetCacheNamespaceStats(t)
+
+	t.Run("untracked-until-namespaces-are-set", func(t *testing.T) {
+		cacheNamespaceStats.recordAccess("/ospool/data/file", 10, 0, 0)
+		assert.Empty(t, GetCacheNamespaceStats())
+	})
+
+	SetCacheNamespaces([]string{"/ospool", "/ospool/private/", "/osg"})
+
+	t.Run("g-stream-and-transfer-records", func(t *testing.T) {
+		records := `{"event":"file_close","lfn":"/ospool/data/a","b_hit":300,"b_miss":100,"b_bypass":0}` + "\n" +
+			`{"event":"file_close","lfn":"/ospool/private/b","b_hit":0,"b_miss":50,"b_bypass":50}` + "\n" +
+			`{"event":"file_close","lfn":"/ospool/data/c","b_hit":100,"b_miss":0,"b_bypass":0}`
+		require.NoError(t, HandlePacket(cacheGStreamPacket(records)))
+		cacheNamespaceStats.recordClosedFile(ClosedFile{LFN: "/ospool/data/a", ReadBytes: 400})
+		cacheNamespaceStats.recordClosedFile(ClosedFile{LFN: "/ospool/data/c", ReadBytes: 100})
+		// Writes to the cache aren't counted as reads
+		cacheNamespaceStats.recordClosedFile(ClosedFile{LFN: "/ospool/data/d", WriteBytes: 10})
+		// Objects outside of the namespaces fall back to the aggregate prefix
+		cacheNamespaceStats.recordClosedFile(ClosedFile{LFN: "/unknown/file", ReadBytes: 5})
+
+		stats := GetCacheNamespaceStats()
+		require.Len(t, stats, 3)
+		assert.Equal(t, CacheNamespaceStats{Namespace: "/", ServedBytes: 5, Reads: 1}, stats[0])
+		assert.Equal(t, CacheNamespaceStats{Namespace: "/ospool", HitBytes: 400, MissBytes: 100, ServedBytes: 500, Reads: 2, HitRatio: 0.8}, stats[1])
+		assert.Equal(t, CacheNamespaceStats{Namespace: "/ospool/private", MissBytes: 50, BypassBytes: 50}, stats[2])
+
+		assert.Equal(t, 400.0, testutil.ToFloat64(PelicanCacheNamespaceBytes.WithLabelValues("/ospool", "hit")))
+		assert.Equal(t, 100.0, testutil.ToFloat64(PelicanCacheNamespaceBytes.WithLabelValues("/ospool", "miss")))
+		assert.Equal(t, 50.0, testutil.ToFloat64(PelicanCacheNamespaceBytes.WithLabelValues("/ospool/private", "bypass")))
+		assert.Equal(t, 500.0, testutil.ToFloat64(PelicanCacheNamespaceServedBytes.WithLabelValues("/ospool")))
+		assert.Equal(t, 0.8, testutil.ToFloat64(PelicanCacheNamespaceHitRatio.WithLabelValues("/ospool")))
+		assert.Equal(t, 0.0, testutil.ToFloat64(PelicanCacheNamespaceHitRatio.WithLabelValues("/ospool/private")))
+	})
+}
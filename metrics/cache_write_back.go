@@ -0,0 +1,46 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	PelicanCacheWriteBackPending = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "pelican_cache_writeback_pending",
+		Help: "The number of uploads the cache accepted and has yet to write back to the origin",
+	})
+
+	PelicanCacheWriteBackPendingBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "pelican_cache_writeback_pending_bytes",
+		Help: "The total size of the uploads the cache accepted and has yet to write back to the origin",
+	})
+
+	PelicanCacheWriteBackUploads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_cache_writeback_uploads_total",
+		Help: "The total number of write-back uploads, by whether the cache accepted them, wrote them to the origin, or gave up on them",
+	}, []string{"result"})
+
+	PelicanCacheWriteBackAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_cache_writeback_attempts_total",
+		Help: "The total number of attempts to write an upload back to the origin, by whether they succeeded or failed",
+	}, []string{"result"})
+)
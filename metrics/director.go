@@ -19,12 +19,31 @@
 package metrics
 
 import (
+	"sync"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
 type (
 	DirectorFTXTestStatus string
+
+	// The age of a server's latest advertisement to the director
+	DirectorAdAge struct {
+		ServerName string
+		ServerType string
+		ServerURL  string
+		Age        time.Duration
+	}
+
+	// Reports pelican_director_ad_age_seconds from the director's ads at scrape time, so the
+	// ages keep growing between advertisements and the series of expired ads disappear
+	directorAdAgeCollector struct {
+		desc   *prometheus.Desc
+		mutex  sync.RWMutex
+		source func() []DirectorAdAge
+	}
 )
 
 const (
@@ -62,4 +81,76 @@ var (
 		Name: "pelican_director_ttl_cache",
 		Help: "The statistics of various TTL caches",
 	}, []string{"name", "type"}) // name: serverAds, jwks; type: evictions, insersions, hits, misses, total
+
+	PelicanDirectorTTLCacheEvictionDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pelican_director_ttl_cache_eviction_duration_seconds",
+		Help:    "The time the eviction callbacks of the director's TTL caches took, by the name of the cache",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 4, 10),
+	}, []string{"name"}) // name: serverAds, jwks
+
+	PelicanDirectorTTLCacheEvictionLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pelican_director_ttl_cache_eviction_latency_seconds",
+		Help:    "The time between the expiry of an item in the director's TTL caches and the start of its eviction callback, by the name of the cache",
+		Buckets: prometheus.ExponentialBuckets(0.001, 4, 10),
+	}, []string{"name"}) // name: serverAds, jwks
+
+	PelicanDirectorClientGeolocations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_director_client_geolocations_total",
+		Help: "The number of client locations the director resolved for sorting servers by distance, by the method of the fallback chain that located the client",
+	}, []string{"method"}) // method: override, geoip, rtt_probe, location_map, unresolved
+
+	PelicanDirectorRedirectRuleHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_director_redirect_rule_hits_total",
+		Help: "The number of servers a redirect rule excluded from or preferred in the director's responses",
+	}, []string{"rule", "action"}) // action: exclude, prefer
+
+	PelicanDirectorRedirectRuleErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_director_redirect_rule_errors_total",
+		Help: "The number of times a redirect rule failed to evaluate; the rule is skipped when it fails",
+	}, []string{"rule"})
+
+	PelicanDirectorServerCertExpiry = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pelican_director_server_cert_expiry_timestamp",
+		Help: "When the TLS certificate of the origin or cache server expires, as UNIX time in seconds. Recorded by the director's health tests",
+	}, []string{"server_name", "server_web_url", "server_type"})
+
+	PelicanDirectorMirrorDivergentObjects = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pelican_director_mirror_divergent_objects",
+		Help: "The number of sampled objects whose size or modification time differed between the origins of a mirror group in the director's last check",
+	}, []string{"namespace", "mirror_group"})
+
+	PelicanDirectorAdAgeSeconds = &directorAdAgeCollector{
+		desc: prometheus.NewDesc(
+			"pelican_director_ad_age_seconds",
+			"The number of seconds since the director last received an advertisement from the server",
+			[]string{"server_name", "server_type", "server_url"}, nil,
+		),
+	}
 )
+
+func init() {
+	prometheus.MustRegister(PelicanDirectorAdAgeSeconds)
+}
+
+// Set the function reporting the ad ages at each scrape
+func (c *directorAdAgeCollector) SetSource(source func() []DirectorAdAge) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.source = source
+}
+
+func (c *directorAdAgeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *directorAdAgeCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.RLock()
+	source := c.source
+	c.mutex.RUnlock()
+	if source == nil {
+		return
+	}
+	for _, adAge := range source() {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, adAge.Age.Seconds(), adAge.ServerName, adAge.ServerType, adAge.ServerURL)
+	}
+}
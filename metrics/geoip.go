@@ -0,0 +1,139 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/oschwald/geoip2-golang"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/param"
+)
+
+const (
+	// How long a lookup of a client's hostname may hold up the processing of the
+	// monitoring packets
+	clientResolveTimeout = 250 * time.Millisecond
+)
+
+var (
+	// The MaxMind databases the client country and ASN are looked up in; nil
+	// unless Monitoring.GeoIPLabels is set
+	geoIPCountryReader atomic.Pointer[geoip2.Reader]
+	geoIPASNReader     atomic.Pointer[geoip2.Reader]
+
+	// The addresses of the client hosts XRootD reported by name
+	clientAddrs = ttlcache.New[string, netip.Addr](ttlcache.WithTTL[string, netip.Addr](time.Hour))
+)
+
+// Open the GeoIP databases for the country and ASN labels of the transfer metrics if
+// Monitoring.GeoIPLabels is set.  A database that fails to open is skipped, leaving
+// its label empty.
+func configureGeoIP() {
+	for _, reader := range []*atomic.Pointer[geoip2.Reader]{&geoIPCountryReader, &geoIPASNReader} {
+		if old := reader.Swap(nil); old != nil {
+			old.Close()
+		}
+	}
+	if !param.Monitoring_GeoIPLabels.GetBool() {
+		return
+	}
+
+	if location := param.Director_GeoIPLocation.GetString(); location != "" {
+		if reader, err := geoip2.Open(location); err != nil {
+			log.Warningf("Failed to open the GeoIP database at %s; transfer metrics won't be labeled by country: %v", location, err)
+		} else {
+			geoIPCountryReader.Store(reader)
+		}
+	}
+	if location := param.Monitoring_GeoIPASNLocation.GetString(); location != "" {
+		if reader, err := geoip2.Open(location); err != nil {
+			log.Warningf("Failed to open the GeoIP ASN database at %s; transfer metrics won't be labeled by ASN: %v", location, err)
+		} else {
+			geoIPASNReader.Store(reader)
+		}
+	}
+}
+
+// Get the address of a client host as XRootD reports it: an IP address, possibly in
+// brackets and IPv4-mapped, or a hostname, which is resolved
+func getClientAddr(host string) (netip.Addr, bool) {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if addr, err := netip.ParseAddr(host); err == nil {
+		// XRootD writes IPv4 addresses as IPv6 ones, e.g. [::192.0.2.1] or [::ffff:192.0.2.1]
+		if v4 := strings.TrimPrefix(strings.TrimPrefix(host, "::ffff:"), "::"); v4 != host {
+			if addr4, err := netip.ParseAddr(v4); err == nil && addr4.Is4() {
+				return addr4, true
+			}
+		}
+		return addr.Unmap(), true
+	}
+	if host == "" {
+		return netip.Addr{}, false
+	}
+
+	if item := clientAddrs.Get(host); item != nil {
+		return item.Value(), item.Value().IsValid()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), clientResolveTimeout)
+	defer cancel()
+	var addr netip.Addr
+	if addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host); err != nil {
+		log.Debugf("Failed to resolve the client host %s for its GeoIP labels: %v", host, err)
+	} else if len(addrs) > 0 {
+		addr = addrs[0].Unmap()
+	}
+	// Failed lookups are cached too, so an unresolvable host doesn't stall every login
+	clientAddrs.Set(host, addr, ttlcache.DefaultTTL)
+	return addr, addr.IsValid()
+}
+
+// Look up the country (ISO code) and autonomous system number of a client host; either
+// is empty if it's unknown or its database isn't configured
+func lookupClientGeo(host string) (country string, asn string) {
+	countryReader := geoIPCountryReader.Load()
+	asnReader := geoIPASNReader.Load()
+	if countryReader == nil && asnReader == nil {
+		return
+	}
+	addr, ok := getClientAddr(host)
+	if !ok {
+		return
+	}
+	ip := net.IP(addr.AsSlice())
+	if countryReader != nil {
+		if record, err := countryReader.Country(ip); err == nil {
+			country = record.Country.IsoCode
+		}
+	}
+	if asnReader != nil {
+		if record, err := asnReader.ASN(ip); err == nil && record.AutonomousSystemNumber != 0 {
+			asn = strconv.FormatUint(uint64(record.AutonomousSystemNumber), 10)
+		}
+	}
+	return
+}
@@ -0,0 +1,82 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"net/netip"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseXrdUserIdHost(t *testing.T) {
+	userId, err := ParseXrdUserId("https/unknown.1:143152967831384@[::ffff:192.0.2.1]")
+	require.NoError(t, err)
+	assert.Equal(t, "[::ffff:192.0.2.1]", userId.Host)
+}
+
+func TestGetClientAddr(t *testing.T) {
+	for host, expected := range map[string]string{
+		"[::ffff:192.0.2.1]": "192.0.2.1",
+		"[::192.0.2.1]":      "192.0.2.1",
+		"192.0.2.1":          "192.0.2.1",
+		"[2001:db8::1]":      "2001:db8::1",
+		"[::1]":              "::1",
+	} {
+		addr, ok := getClientAddr(host)
+		require.True(t, ok, host)
+		assert.Equal(t, netip.MustParseAddr(expected), addr, host)
+	}
+
+	_, ok := getClientAddr("")
+	assert.False(t, ok)
+
+	// Unresolvable hosts are remembered as such
+	t.Cleanup(clientAddrs.DeleteAll)
+	_, ok = getClientAddr("host.invalid")
+	assert.False(t, ok)
+	assert.NotNil(t, clientAddrs.Get("host.invalid"))
+}
+
+func TestLookupClientGeo(t *testing.T) {
+	t.Cleanup(func() {
+		viper.Reset()
+		configureGeoIP()
+	})
+
+	// Without databases, clients aren't located
+	configureGeoIP()
+	country, asn := lookupClientGeo("[::ffff:192.0.2.1]")
+	assert.Empty(t, country)
+	assert.Empty(t, asn)
+
+	// Databases that fail to open are skipped
+	viper.Set("Monitoring.GeoIPLabels", true)
+	viper.Set("Director.GeoIPLocation", filepath.Join(t.TempDir(), "missing.mmdb"))
+	viper.Set("Monitoring.GeoIPASNLocation", filepath.Join(t.TempDir(), "missing.mmdb"))
+	configureGeoIP()
+	assert.Nil(t, geoIPCountryReader.Load())
+	assert.Nil(t, geoIPASNReader.Load())
+	country, asn = lookupClientGeo("[::ffff:192.0.2.1]")
+	assert.Empty(t, country)
+	assert.Empty(t, asn)
+}
@@ -49,6 +49,10 @@ type (
 	HealthStatusEnum int
 
 	HealthStatusComponent string
+
+	// Called when the status of a component changes, with its previous status
+	// (StatusUnknown for a new component)
+	HealthStatusListener func(component HealthStatusComponent, previous, current HealthStatusEnum, msg string)
 )
 
 // HealthStatusEnum are stored as Prometheus values and internal struct
@@ -70,16 +74,24 @@ const statusIndexErrorMessage = "Error: status string index out of range"
 const (
 	OriginCache_XRootD        HealthStatusComponent = "xrootd"
 	OriginCache_CMSD          HealthStatusComponent = "cmsd"
-	OriginCache_Federation    HealthStatusComponent = "federation" // Advertise to the director
-	OriginCache_Director      HealthStatusComponent = "director"   // File transfer tests with director
-	OriginCache_Registry      HealthStatusComponent = "registry"   // Register namespace at the registry
-	DirectorRegistry_Topology HealthStatusComponent = "topology"   // Fetch data from OSDF topology
+	OriginCache_Federation    HealthStatusComponent = "federation"      // Advertise to the director
+	OriginCache_Director      HealthStatusComponent = "director"        // File transfer tests with director
+	OriginCache_Registry      HealthStatusComponent = "registry"        // Register namespace at the registry
+	DirectorRegistry_Topology HealthStatusComponent = "topology"        // Fetch data from OSDF topology
+	Origin_ExportAudit        HealthStatusComponent = "export-audit"    // Consistency between exports and the storage backend
+	Origin_S3Backend          HealthStatusComponent = "s3-backend"      // Throttling and request budget of the S3 service
+	Origin_StorageWrites      HealthStatusComponent = "storage-writes"  // Whether the storage backend accepts writes to writable exports
+	Registry_Mirror           HealthStatusComponent = "registry-mirror" // Mirror the namespaces of the primary registry
+	Director_MirrorSync       HealthStatusComponent = "mirror-sync"     // Consistency between the origins of each mirror group
 	Server_WebUI              HealthStatusComponent = "web-ui"
 )
 
 var (
 	healthStatus = sync.Map{} // In-memory map of component health status, key is HealthStatusComponent, value is componentStatusInternal
 
+	healthStatusListeners      []HealthStatusListener
+	healthStatusListenersMutex sync.RWMutex
+
 	PelicanHealthStatus = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "pelican_component_health_status",
 		Help: "The health status of various components",
@@ -113,7 +125,12 @@ func (component HealthStatusComponent) String() string {
 // use only, please try to avoid setting this as your component status
 func SetComponentHealthStatus(name HealthStatusComponent, state HealthStatusEnum, msg string) {
 	now := time.Now()
-	healthStatus.Store(name, componentStatusInternal{state, msg, now})
+	previous := StatusUnknown
+	if existing, loaded := healthStatus.Swap(name, componentStatusInternal{state, msg, now}); loaded {
+		if existingStatus, ok := existing.(componentStatusInternal); ok {
+			previous = existingStatus.Status
+		}
+	}
 
 	PelicanHealthStatus.With(
 		prometheus.Labels{"component": name.String()}).
@@ -121,6 +138,22 @@ func SetComponentHealthStatus(name HealthStatusComponent, state HealthStatusEnum
 
 	PelicanHealthLastUpdate.With(prometheus.Labels{"component": name.String()}).
 		SetToCurrentTime()
+
+	if previous != state {
+		healthStatusListenersMutex.RLock()
+		listeners := healthStatusListeners
+		healthStatusListenersMutex.RUnlock()
+		for _, listener := range listeners {
+			listener(name, previous, state, msg)
+		}
+	}
+}
+
+// Register a function called whenever the status of a component changes
+func AddHealthStatusListener(listener HealthStatusListener) {
+	healthStatusListenersMutex.Lock()
+	defer healthStatusListenersMutex.Unlock()
+	healthStatusListeners = append(healthStatusListeners, listener)
 }
 
 func DeleteComponentHealthStatus(name HealthStatusComponent) {
@@ -0,0 +1,263 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/pelicanplatform/pelican/param"
+)
+
+type (
+	// A rule of Monitoring.MetricRelabelRules, applied to the series of the metrics
+	// whose names match one of its patterns
+	metricRelabelRule struct {
+		Action      string            `mapstructure:"Action"`
+		Metrics     []string          `mapstructure:"Metrics"` // Glob patterns; defaults to xrootd_*
+		Label       string            `mapstructure:"Label"`
+		TargetLabel string            `mapstructure:"TargetLabel"` // The new name of the label, for rename
+		Regex       string            `mapstructure:"Regex"`       // The values of the series to drop, for drop
+		Values      map[string]string `mapstructure:"Values"`      // The replacement of each value, for map
+
+		regex *regexp.Regexp
+	}
+
+	// A gatherer applying Monitoring.MetricRelabelRules to the metrics of another
+	relabelGatherer struct {
+		gatherer prometheus.Gatherer
+	}
+)
+
+const (
+	metricRelabelRename = "rename"
+	metricRelabelDrop   = "drop"
+	metricRelabelMap    = "map"
+)
+
+var (
+	labelNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+	// The rules of Monitoring.MetricRelabelRules, applied in order
+	metricRelabelRules []metricRelabelRule
+)
+
+// Read and check the rules of Monitoring.MetricRelabelRules
+func configureMetricRelabeling() error {
+	rules := []metricRelabelRule{}
+	if err := param.Monitoring_MetricRelabelRules.Unmarshal(&rules); err != nil {
+		return errors.Wrap(err, "failed to parse Monitoring.MetricRelabelRules")
+	}
+	for idx := range rules {
+		rule := &rules[idx]
+		if rule.Label == "" {
+			return errors.Errorf("rule %d of Monitoring.MetricRelabelRules has no label", idx+1)
+		}
+		if len(rule.Metrics) == 0 {
+			rule.Metrics = []string{"xrootd_*"}
+		}
+		for _, pattern := range rule.Metrics {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return errors.Wrapf(err, "invalid metric pattern %q in rule %d of Monitoring.MetricRelabelRules", pattern, idx+1)
+			}
+		}
+		switch rule.Action {
+		case metricRelabelRename:
+			if !labelNameRegex.MatchString(rule.TargetLabel) {
+				return errors.Errorf("invalid target label %q in rule %d of Monitoring.MetricRelabelRules", rule.TargetLabel, idx+1)
+			}
+		case metricRelabelDrop:
+			regex, err := regexp.Compile("^(?:" + rule.Regex + ")$")
+			if err != nil {
+				return errors.Wrapf(err, "invalid regex in rule %d of Monitoring.MetricRelabelRules", idx+1)
+			}
+			rule.regex = regex
+		case metricRelabelMap:
+			if len(rule.Values) == 0 {
+				return errors.Errorf("rule %d of Monitoring.MetricRelabelRules maps no values", idx+1)
+			}
+		default:
+			return errors.Errorf("invalid action %q in rule %d of Monitoring.MetricRelabelRules; must be %q, %q or %q",
+				rule.Action, idx+1, metricRelabelRename, metricRelabelDrop, metricRelabelMap)
+		}
+	}
+	metricRelabelRules = rules
+	return nil
+}
+
+// Wrap a gatherer so the metrics it gathers are relabeled by Monitoring.MetricRelabelRules
+func RelabelingGatherer(gatherer prometheus.Gatherer) prometheus.Gatherer {
+	return &relabelGatherer{gatherer: gatherer}
+}
+
+func (gatherer *relabelGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := gatherer.gatherer.Gather()
+	rules := metricRelabelRules
+	if len(rules) == 0 {
+		return families, err
+	}
+	kept := families[:0]
+	for _, family := range families {
+		var matching []*metricRelabelRule
+		for idx := range rules {
+			if rules[idx].matches(family.GetName()) {
+				matching = append(matching, &rules[idx])
+			}
+		}
+		if len(matching) > 0 {
+			relabelFamily(family, matching)
+		}
+		// Leave out the families whose series were all dropped
+		if len(family.Metric) > 0 {
+			kept = append(kept, family)
+		}
+	}
+	return kept, err
+}
+
+// Whether the rule applies to the metric with the given name
+func (rule *metricRelabelRule) matches(name string) bool {
+	for _, pattern := range rule.Metrics {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply the rule to the labels of a series, returning false if the series is dropped
+func (rule *metricRelabelRule) apply(labels map[string]string) bool {
+	value, ok := labels[rule.Label]
+	switch rule.Action {
+	case metricRelabelRename:
+		if ok {
+			delete(labels, rule.Label)
+			labels[rule.TargetLabel] = value
+		}
+	case metricRelabelDrop:
+		// A missing label matches as an empty value, as in Prometheus
+		if rule.regex.MatchString(value) {
+			return false
+		}
+	case metricRelabelMap:
+		if replacement, found := rule.Values[value]; ok && found {
+			labels[rule.Label] = replacement
+		}
+	}
+	return true
+}
+
+// Relabel the series of a family, merging the series that end up with the same labels
+func relabelFamily(family *dto.MetricFamily, rules []*metricRelabelRule) {
+	merged := make([]*dto.Metric, 0, len(family.Metric))
+	bySignature := map[string]*dto.Metric{}
+	for _, metric := range family.Metric {
+		labels := make(map[string]string, len(metric.Label))
+		for _, pair := range metric.Label {
+			labels[pair.GetName()] = pair.GetValue()
+		}
+		kept := true
+		for _, rule := range rules {
+			if kept = rule.apply(labels); !kept {
+				break
+			}
+		}
+		if !kept {
+			continue
+		}
+
+		names := make([]string, 0, len(labels))
+		for name := range labels {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		metric.Label = make([]*dto.LabelPair, 0, len(names))
+		var signature strings.Builder
+		for _, name := range names {
+			name, value := name, labels[name]
+			metric.Label = append(metric.Label, &dto.LabelPair{Name: &name, Value: &value})
+			signature.WriteString(name)
+			signature.WriteByte(0)
+			signature.WriteString(labels[name])
+			signature.WriteByte(0)
+		}
+		if existing, ok := bySignature[signature.String()]; ok {
+			mergeMetric(existing, metric)
+			continue
+		}
+		bySignature[signature.String()] = metric
+		merged = append(merged, metric)
+	}
+	family.Metric = merged
+}
+
+// Add the values of a series to another with the same labels.  The values of gauges are
+// summed too, which is right for the gauges counting things, like open files, but not
+// for ratios; summaries lose their quantiles.
+func mergeMetric(into, from *dto.Metric) {
+	switch {
+	case into.Counter != nil && from.Counter != nil:
+		into.Counter.Value = addFloat(into.Counter.Value, from.Counter.Value)
+	case into.Gauge != nil && from.Gauge != nil:
+		into.Gauge.Value = addFloat(into.Gauge.Value, from.Gauge.Value)
+	case into.Untyped != nil && from.Untyped != nil:
+		into.Untyped.Value = addFloat(into.Untyped.Value, from.Untyped.Value)
+	case into.Histogram != nil && from.Histogram != nil:
+		into.Histogram.SampleCount = addUint(into.Histogram.SampleCount, from.Histogram.SampleCount)
+		into.Histogram.SampleSum = addFloat(into.Histogram.SampleSum, from.Histogram.SampleSum)
+		// The series of a histogram share its buckets
+		for idx, bucket := range into.Histogram.Bucket {
+			if idx < len(from.Histogram.Bucket) {
+				bucket.CumulativeCount = addUint(bucket.CumulativeCount, from.Histogram.Bucket[idx].CumulativeCount)
+			}
+		}
+	case into.Summary != nil && from.Summary != nil:
+		into.Summary.SampleCount = addUint(into.Summary.SampleCount, from.Summary.SampleCount)
+		into.Summary.SampleSum = addFloat(into.Summary.SampleSum, from.Summary.SampleSum)
+		into.Summary.Quantile = nil
+	}
+}
+
+func addFloat(lhs, rhs *float64) *float64 {
+	var sum float64
+	if lhs != nil {
+		sum += *lhs
+	}
+	if rhs != nil {
+		sum += *rhs
+	}
+	return &sum
+}
+
+func addUint(lhs, rhs *uint64) *uint64 {
+	var sum uint64
+	if lhs != nil {
+		sum += *lhs
+	}
+	if rhs != nil {
+		sum += *rhs
+	}
+	return &sum
+}
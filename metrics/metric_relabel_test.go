@@ -0,0 +1,102 @@
+/***************************************************************
+ *
+ * 	Copyright 2021 Derek Weitzel
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *	See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The labels and value of each series of a gathered counter family
+func counterSeries(family *dto.MetricFamily) map[string]float64 {
+	series := map[string]float64{}
+	for _, metric := range family.Metric {
+		key := ""
+		for _, pair := range metric.Label {
+			key += pair.GetName() + "=" + pair.GetValue() + ","
+		}
+		series[key] = metric.GetCounter().GetValue()
+	}
+	return series
+}
+
+func TestMetricRelabeling(t *testing.T) {
+	t.Cleanup(func() {
+		viper.Reset()
+		metricRelabelRules = nil
+	})
+
+	registry := prometheus.NewRegistry()
+	transfers := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "xrootd_test_transfers"}, []string{"org", "type"})
+	other := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "other_transfers"}, []string{"org"})
+	registry.MustRegister(transfers, other)
+	transfers.WithLabelValues("UW-Madison", "read").Add(1)
+	transfers.WithLabelValues("Morgridge", "read").Add(2)
+	transfers.WithLabelValues("CERN", "read").Add(4)
+	transfers.WithLabelValues("", "read").Add(8)
+	transfers.WithLabelValues("UW-Madison", "write").Add(16)
+	other.WithLabelValues("CERN").Add(1)
+
+	viper.Set("Monitoring.MetricRelabelRules", []map[string]any{
+		{"Action": "map", "Label": "org", "Values": map[string]string{"UW-Madison": "osg", "Morgridge": "osg", "CERN": "cms"}},
+		{"Action": "drop", "Label": "org", "Regex": ""},
+		{"Action": "drop", "Label": "type", "Regex": "wr.*"},
+		{"Action": "rename", "Label": "org", "TargetLabel": "vo"},
+	})
+	require.NoError(t, configureMetricRelabeling())
+
+	families, err := RelabelingGatherer(registry).Gather()
+	require.NoError(t, err)
+	require.Len(t, families, 2)
+	// The series mapped to the same VO are combined
+	assert.Equal(t, "other_transfers", families[0].GetName())
+	assert.Equal(t, map[string]float64{"org=CERN,": 1}, counterSeries(families[0]))
+	assert.Equal(t, "xrootd_test_transfers", families[1].GetName())
+	assert.Equal(t, map[string]float64{"type=read,vo=osg,": 3, "type=read,vo=cms,": 4}, counterSeries(families[1]))
+
+	t.Run("drop-all", func(t *testing.T) {
+		viper.Set("Monitoring.MetricRelabelRules", []map[string]any{
+			{"Action": "drop", "Label": "type", "Regex": ".*"},
+		})
+		require.NoError(t, configureMetricRelabeling())
+		families, err := RelabelingGatherer(registry).Gather()
+		require.NoError(t, err)
+		require.Len(t, families, 1)
+		assert.Equal(t, "other_transfers", families[0].GetName())
+	})
+
+	t.Run("invalid-rules", func(t *testing.T) {
+		for _, rule := range []map[string]any{
+			{"Action": "rename", "Label": "org", "TargetLabel": "not a label"},
+			{"Action": "drop", "Label": "org", "Regex": "("},
+			{"Action": "map", "Label": "org"},
+			{"Action": "hash", "Label": "org"},
+			{"Action": "drop", "Regex": ".*"},
+			{"Action": "drop", "Label": "org", "Metrics": []string{"xrootd_["}},
+		} {
+			viper.Set("Monitoring.MetricRelabelRules", []map[string]any{rule})
+			assert.Error(t, configureMetricRelabeling(), "rule %v", rule)
+		}
+	})
+}
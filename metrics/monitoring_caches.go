@@ -0,0 +1,122 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"sort"
+	"time"
+)
+
+type (
+	// A client session in the cache of the XRootD monitoring packets, for debugging
+	MonitoringSession struct {
+		Id                     uint32    `json:"id"`       // The dictid of the session's user login packet
+		Instance               string    `json:"instance"` // The XRootD daemon the client logged in to
+		Protocol               string    `json:"protocol"`
+		XrdUser                string    `json:"xrdUser"`
+		Pid                    int       `json:"pid"`
+		Sid                    int       `json:"sid"`
+		Host                   string    `json:"host"`
+		AuthenticationProtocol string    `json:"authenticationProtocol"`
+		User                   string    `json:"user"`
+		DN                     string    `json:"dn"`
+		Role                   string    `json:"role"`
+		Org                    string    `json:"org"`
+		Groups                 []string  `json:"groups"`
+		Project                string    `json:"project"`
+		Country                string    `json:"country"`
+		ASN                    string    `json:"asn"`
+		ExpiresAt              time.Time `json:"expiresAt"`
+	}
+
+	// An open file in the cache of the XRootD monitoring packets, for debugging
+	MonitoringTransfer struct {
+		Id         uint32    `json:"id"`       // The dictid of the file's open record
+		Instance   string    `json:"instance"` // The XRootD daemon that opened the file
+		UserId     uint32    `json:"userId"`   // The dictid of the session that opened the file
+		Path       string    `json:"path"`
+		LFN        string    `json:"lfn"`
+		ReadOps    uint32    `json:"readOps"`
+		ReadvOps   uint32    `json:"readvOps"`
+		WriteOps   uint32    `json:"writeOps"`
+		ReadvSegs  uint64    `json:"readvSegs"`
+		ReadBytes  uint64    `json:"readBytes"`
+		ReadvBytes uint64    `json:"readvBytes"`
+		WriteBytes uint64    `json:"writeBytes"`
+		OpenTime   time.Time `json:"openTime"`
+		ExpiresAt  time.Time `json:"expiresAt"`
+	}
+)
+
+// Return the client sessions cached from the XRootD monitoring packets, by ID
+func GetMonitoringSessions() []MonitoringSession {
+	items := sessions.Items()
+	result := make([]MonitoringSession, 0, len(items))
+	for id, item := range items {
+		record := item.Value()
+		result = append(result, MonitoringSession{
+			Id:                     id.Id,
+			Instance:               instanceLabel(int64(id.Stod)),
+			Protocol:               record.XrdUserId.Prot,
+			XrdUser:                record.XrdUserId.User,
+			Pid:                    record.XrdUserId.Pid,
+			Sid:                    record.XrdUserId.Sid,
+			Host:                   record.XrdUserId.Host,
+			AuthenticationProtocol: record.AuthenticationProtocol,
+			User:                   record.User,
+			DN:                     record.DN,
+			Role:                   record.Role,
+			Org:                    record.Org,
+			Groups:                 record.Groups,
+			Project:                record.Project,
+			Country:                record.Country,
+			ASN:                    record.ASN,
+			ExpiresAt:              item.ExpiresAt(),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Id < result[j].Id })
+	return result
+}
+
+// Return the open files cached from the XRootD monitoring packets, by ID
+func GetMonitoringTransfers() []MonitoringTransfer {
+	items := transfers.Items()
+	result := make([]MonitoringTransfer, 0, len(items))
+	for id, item := range items {
+		record := item.Value()
+		result = append(result, MonitoringTransfer{
+			Id:         id.Id,
+			Instance:   instanceLabel(int64(id.Stod)),
+			UserId:     record.UserId.Id,
+			Path:       record.Path,
+			LFN:        record.LFN,
+			ReadOps:    record.ReadOps,
+			ReadvOps:   record.ReadvOps,
+			WriteOps:   record.WriteOps,
+			ReadvSegs:  record.ReadvSegs,
+			ReadBytes:  record.ReadBytes,
+			ReadvBytes: record.ReadvBytes,
+			WriteBytes: record.WriteBytes,
+			OpenTime:   record.OpenTime,
+			ExpiresAt:  item.ExpiresAt(),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Id < result[j].Id })
+	return result
+}
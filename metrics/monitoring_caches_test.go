@@ -0,0 +1,67 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetMonitoringCaches(t *testing.T) {
+	sessions.DeleteAll()
+	transfers.DeleteAll()
+	t.Cleanup(func() {
+		sessions.DeleteAll()
+		transfers.DeleteAll()
+	})
+
+	xrdUserId := XrdUserId{Prot: "https", User: "unknown", Pid: 12, Sid: 34, Host: "client.example.com"}
+	sessions.Set(UserId{Id: 2}, UserRecord{AuthenticationProtocol: "ztn", DN: "client", Project: "osg", XrdUserId: xrdUserId}, ttlcache.DefaultTTL)
+	sessions.Set(UserId{Id: 1}, UserRecord{Project: "other"}, ttlcache.DefaultTTL)
+	openTime := time.Now().Truncate(time.Second)
+	transfers.Set(FileId{Id: 7, Stod: 1687524137}, FileRecord{UserId: UserId{Id: 2, Stod: 1687524137}, Path: "/foo", LFN: "/foo/bar", ReadOps: 3, ReadBytes: 300, OpenTime: openTime}, ttlcache.DefaultTTL)
+
+	result := GetMonitoringSessions()
+	require.Len(t, result, 2)
+	assert.Equal(t, uint32(1), result[0].Id)
+	assert.Equal(t, "other", result[0].Project)
+	assert.Equal(t, uint32(2), result[1].Id)
+	assert.Equal(t, "ztn", result[1].AuthenticationProtocol)
+	assert.Equal(t, "client.example.com", result[1].Host)
+	assert.Equal(t, 34, result[1].Sid)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), result[1].ExpiresAt, time.Minute)
+
+	transferList := GetMonitoringTransfers()
+	require.Len(t, transferList, 1)
+	assert.Equal(t, MonitoringTransfer{
+		Id:        7,
+		Instance:  "1687524137",
+		UserId:    2,
+		Path:      "/foo",
+		LFN:       "/foo/bar",
+		ReadOps:   3,
+		ReadBytes: 300,
+		OpenTime:  openTime,
+		ExpiresAt: transferList[0].ExpiresAt,
+	}, transferList[0])
+}
@@ -0,0 +1,154 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type (
+	// The bandwidth of a namespace averaged over the past minute
+	NamespaceBandwidth struct {
+		Namespace           string  `json:"namespace"`
+		ReadBytesPerSecond  float64 `json:"readBytesPerSecond"` // Including vector reads
+		WriteBytesPerSecond float64 `json:"writeBytesPerSecond"`
+	}
+
+	// The bytes transferred in one second
+	bandwidthBucket struct {
+		second int64 // Unix time
+		read   uint64
+		write  uint64
+	}
+
+	// Tracks the bytes transferred per namespace in a ring of one-second buckets covering
+	// the sliding window, from the transfer records of the f-stream, and exports their rate
+	// as a gauge computed at each scrape
+	namespaceBandwidthTracker struct {
+		lock sync.Mutex
+		// Namespaces exported or served by the server; nil if the server hasn't set them
+		namespaces namespaceMatcher
+		windows    map[string]*[bandwidthWindowSeconds]bandwidthBucket
+		desc       *prometheus.Desc
+	}
+)
+
+// The width of the sliding window in seconds
+const bandwidthWindowSeconds = 60
+
+var namespaceBandwidth = newNamespaceBandwidthTracker()
+
+func init() {
+	prometheus.MustRegister(namespaceBandwidth)
+}
+
+func newNamespaceBandwidthTracker() *namespaceBandwidthTracker {
+	return &namespaceBandwidthTracker{
+		windows: map[string]*[bandwidthWindowSeconds]bandwidthBucket{},
+		desc: prometheus.NewDesc(
+			"pelican_namespace_bandwidth_bytes_per_second",
+			"The bytes per second transferred to or from each namespace, averaged over the past minute, from XRootD's transfer records",
+			[]string{"namespace", "direction"}, nil,
+		),
+	}
+}
+
+// Set the namespaces whose bandwidth is tracked, i.e. the origin's exports or the namespaces
+// the cache serves.  Transfers outside of the namespaces are tracked by their
+// Monitoring.AggregatePrefixes prefix.
+func SetBandwidthNamespaces(namespaces []string) {
+	matcher := newNamespaceMatcher(namespaces)
+	namespaceBandwidth.lock.Lock()
+	defer namespaceBandwidth.lock.Unlock()
+	namespaceBandwidth.namespaces = matcher
+}
+
+// Record the bytes transferred to or from an object since its previous transfer record
+func (tracker *namespaceBandwidthTracker) record(lfn string, readBytes, writeBytes uint64, now time.Time) {
+	if readBytes == 0 && writeBytes == 0 {
+		return
+	}
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	namespace := tracker.namespaces.lookup(lfn)
+	window, ok := tracker.windows[namespace]
+	if !ok {
+		window = &[bandwidthWindowSeconds]bandwidthBucket{}
+		tracker.windows[namespace] = window
+	}
+	second := now.Unix()
+	bucket := &window[second%bandwidthWindowSeconds]
+	if bucket.second != second {
+		*bucket = bandwidthBucket{second: second}
+	}
+	bucket.read += readBytes
+	bucket.write += writeBytes
+}
+
+// Average the bandwidth of each namespace over the window ending at the given time,
+// dropping the namespaces without transfers in the window
+func (tracker *namespaceBandwidthTracker) rates(now time.Time) []NamespaceBandwidth {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	oldest := now.Unix() - bandwidthWindowSeconds + 1
+	result := make([]NamespaceBandwidth, 0, len(tracker.windows))
+	for namespace, window := range tracker.windows {
+		var read, write uint64
+		active := false
+		for _, bucket := range window {
+			if bucket.second < oldest || bucket.second > now.Unix() {
+				continue
+			}
+			read += bucket.read
+			write += bucket.write
+			active = true
+		}
+		if !active {
+			delete(tracker.windows, namespace)
+			continue
+		}
+		result = append(result, NamespaceBandwidth{
+			Namespace:           namespace,
+			ReadBytesPerSecond:  float64(read) / bandwidthWindowSeconds,
+			WriteBytesPerSecond: float64(write) / bandwidthWindowSeconds,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Namespace < result[j].Namespace })
+	return result
+}
+
+func (tracker *namespaceBandwidthTracker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- tracker.desc
+}
+
+func (tracker *namespaceBandwidthTracker) Collect(ch chan<- prometheus.Metric) {
+	for _, bandwidth := range tracker.rates(time.Now()) {
+		ch <- prometheus.MustNewConstMetric(tracker.desc, prometheus.GaugeValue, bandwidth.ReadBytesPerSecond, bandwidth.Namespace, "read")
+		ch <- prometheus.MustNewConstMetric(tracker.desc, prometheus.GaugeValue, bandwidth.WriteBytesPerSecond, bandwidth.Namespace, "write")
+	}
+}
+
+// Get the bandwidth of each namespace with transfers over the past minute, ordered by namespace
+func GetNamespaceBandwidth() []NamespaceBandwidth {
+	return namespaceBandwidth.rates(time.Now())
+}
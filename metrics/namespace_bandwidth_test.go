@@ -0,0 +1,69 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamespaceBandwidth(t *testing.T) {
+	now := time.Date(2024, 11, 5, 12, 30, 0, 0, time.UTC)
+
+	t.Run("averages-over-the-window", func(t *testing.T) {
+		tracker := newNamespaceBandwidthTracker()
+		tracker.namespaces = newNamespaceMatcher([]string{"/foo", "/foo/bar"})
+		tracker.record("/foo/bar/baz.txt", 600, 0, now.Add(-30*time.Second))
+		tracker.record("/foo/bar/baz.txt", 600, 120, now)
+		tracker.record("/foo/other.txt", 0, 60, now)
+		// Nothing transferred since the last record
+		tracker.record("/unknown/file", 0, 0, now)
+
+		rates := tracker.rates(now)
+		require.Len(t, rates, 2)
+		assert.Equal(t, NamespaceBandwidth{Namespace: "/foo", WriteBytesPerSecond: 1}, rates[0])
+		assert.Equal(t, NamespaceBandwidth{Namespace: "/foo/bar", ReadBytesPerSecond: 20, WriteBytesPerSecond: 2}, rates[1])
+	})
+
+	t.Run("drops-transfers-outside-the-window", func(t *testing.T) {
+		tracker := newNamespaceBandwidthTracker()
+		tracker.namespaces = newNamespaceMatcher([]string{"/foo"})
+		tracker.record("/foo/a", 6000, 0, now.Add(-2*time.Minute))
+		tracker.record("/foo/a", 60, 0, now.Add(-time.Second))
+
+		rates := tracker.rates(now)
+		require.Len(t, rates, 1)
+		assert.Equal(t, 1.0, rates[0].ReadBytesPerSecond)
+
+		// The namespace is forgotten once the window holds no transfers
+		assert.Empty(t, tracker.rates(now.Add(time.Minute)))
+		assert.Empty(t, tracker.windows)
+	})
+
+	t.Run("matches-whole-path-components", func(t *testing.T) {
+		matcher := newNamespaceMatcher([]string{"foo", "/foo/bar/"})
+		assert.Equal(t, "/foo", matcher.lookup("/foo/baz"))
+		assert.Equal(t, "/foo", matcher.lookup("foo"))
+		assert.Equal(t, "/foo/bar", matcher.lookup("/foo/bar/baz"))
+		assert.NotEqual(t, "/foo", matcher.lookup("/foobar/baz"))
+	})
+}
@@ -0,0 +1,51 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// The namespaces a server's per-namespace metrics are broken down by, longest first so
+// the most specific namespace of an object wins.  A nil matcher falls back to the
+// Monitoring.AggregatePrefixes prefixes.
+type namespaceMatcher []string
+
+func newNamespaceMatcher(namespaces []string) namespaceMatcher {
+	cleaned := make(namespaceMatcher, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		cleaned = append(cleaned, path.Clean("/"+namespace))
+	}
+	sort.Slice(cleaned, func(i, j int) bool { return len(cleaned[i]) > len(cleaned[j]) })
+	return cleaned
+}
+
+// Find the namespace of an object, or its Monitoring.AggregatePrefixes prefix if it's
+// outside of all the namespaces
+func (matcher namespaceMatcher) lookup(lfn string) string {
+	lfn = path.Clean("/" + lfn)
+	for _, namespace := range matcher {
+		if namespace == "/" || lfn == namespace || strings.HasPrefix(lfn, namespace+"/") {
+			return namespace
+		}
+	}
+	return computePrefix(lfn, monitorPaths)
+}
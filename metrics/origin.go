@@ -0,0 +1,61 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	PelicanOriginS3Requests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_origin_s3_requests_total",
+		Help: "The total number of S3 API calls the origin made to the S3 service, by export and HTTP method",
+	}, []string{"export", "method"})
+
+	PelicanOriginS3Throttled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_origin_s3_throttled_total",
+		Help: "The total number of S3 API calls the S3 service throttled (HTTP 503 SlowDown or 429), by export",
+	}, []string{"export"})
+
+	PelicanOriginS3MonthlyRequests = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pelican_origin_s3_monthly_requests",
+		Help: "The number of S3 API calls made by each export in the current calendar month (UTC), counted against Origin.S3MonthlyRequestBudget",
+	}, []string{"export"})
+
+	PelicanOriginS3ExportDegraded = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pelican_origin_s3_export_degraded",
+		Help: "Set to 1 while an export has exceeded its monthly S3 request budget and is served read-only from caches",
+	}, []string{"export"})
+
+	PelicanOriginExportReadOnly = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pelican_origin_export_read_only",
+		Help: "Set to 1 while the storage backend of a writable export rejects writes and the export is advertised read-only",
+	}, []string{"export"})
+
+	PelicanOriginAnonymousRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_origin_anonymous_requests_total",
+		Help: "The total number of anonymous requests against exports with Origin.AnonymousRateLimits, by export and whether they were allowed or throttled",
+	}, []string{"export", "result"})
+
+	PelicanOriginAnonymousBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_origin_anonymous_bytes_total",
+		Help: "The total number of bytes sent in response to anonymous requests against exports with Origin.AnonymousRateLimits, by export",
+	}, []string{"export"})
+)
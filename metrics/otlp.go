@@ -0,0 +1,254 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+)
+
+// The OpenTelemetry instrumentation scope of the exported metrics
+const otlpScopeName = "github.com/pelicanplatform/pelican/metrics"
+
+// Convert the metrics registered with Prometheus to OpenTelemetry metrics.
+//
+// Counters become cumulative monotonic sums starting at start, gauges and untyped
+// metrics become gauges, and histograms and summaries keep their type.  Labels become
+// data point attributes.
+func gatherOTLPMetrics(gatherer prometheus.Gatherer, start, now time.Time) (pmetric.Metrics, error) {
+	md := pmetric.NewMetrics()
+	families, err := gatherer.Gather()
+	if err != nil && len(families) == 0 {
+		return md, err
+	}
+	// Gather returns what it could collect along with the errors of the rest
+	if err != nil {
+		log.Debugln("Some metrics could not be gathered for the OTLP export:", err)
+	}
+
+	rm := md.ResourceMetrics().AppendEmpty()
+	resource := rm.Resource().Attributes()
+	resource.PutStr("service.name", "pelican")
+	resource.PutStr("service.version", config.GetVersion())
+	if hostname := param.Server_Hostname.GetString(); hostname != "" {
+		resource.PutStr("host.name", hostname)
+	}
+	sm := rm.ScopeMetrics().AppendEmpty()
+	sm.Scope().SetName(otlpScopeName)
+	sm.Scope().SetVersion(config.GetVersion())
+
+	startTs := pcommon.NewTimestampFromTime(start)
+	defaultTs := pcommon.NewTimestampFromTime(now)
+	for _, family := range families {
+		// Gauge histograms have no OpenTelemetry equivalent
+		if family.GetType() == dto.MetricType_GAUGE_HISTOGRAM {
+			continue
+		}
+		metric := sm.Metrics().AppendEmpty()
+		metric.SetName(family.GetName())
+		metric.SetDescription(family.GetHelp())
+
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			sum := metric.SetEmptySum()
+			sum.SetIsMonotonic(true)
+			sum.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+			for _, promMetric := range family.GetMetric() {
+				dp := sum.DataPoints().AppendEmpty()
+				setOTLPDataPoint(promMetric, dp.Attributes(), dp.SetStartTimestamp, dp.SetTimestamp, startTs, defaultTs)
+				dp.SetDoubleValue(promMetric.GetCounter().GetValue())
+			}
+		case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+			gauge := metric.SetEmptyGauge()
+			for _, promMetric := range family.GetMetric() {
+				dp := gauge.DataPoints().AppendEmpty()
+				setOTLPDataPoint(promMetric, dp.Attributes(), nil, dp.SetTimestamp, startTs, defaultTs)
+				if family.GetType() == dto.MetricType_GAUGE {
+					dp.SetDoubleValue(promMetric.GetGauge().GetValue())
+				} else {
+					dp.SetDoubleValue(promMetric.GetUntyped().GetValue())
+				}
+			}
+		case dto.MetricType_HISTOGRAM:
+			histogram := metric.SetEmptyHistogram()
+			histogram.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+			for _, promMetric := range family.GetMetric() {
+				dp := histogram.DataPoints().AppendEmpty()
+				setOTLPDataPoint(promMetric, dp.Attributes(), dp.SetStartTimestamp, dp.SetTimestamp, startTs, defaultTs)
+				promHist := promMetric.GetHistogram()
+				dp.SetCount(promHist.GetSampleCount())
+				dp.SetSum(promHist.GetSampleSum())
+				// Prometheus buckets are cumulative and may end with +Inf, while OpenTelemetry
+				// counts each bucket separately with an implicit overflow bucket
+				bounds := []float64{}
+				counts := []uint64{}
+				prevCount := uint64(0)
+				for _, bucket := range promHist.GetBucket() {
+					if math.IsInf(bucket.GetUpperBound(), 1) {
+						break
+					}
+					bounds = append(bounds, bucket.GetUpperBound())
+					counts = append(counts, bucket.GetCumulativeCount()-prevCount)
+					prevCount = bucket.GetCumulativeCount()
+				}
+				counts = append(counts, promHist.GetSampleCount()-prevCount)
+				dp.ExplicitBounds().FromRaw(bounds)
+				dp.BucketCounts().FromRaw(counts)
+			}
+		case dto.MetricType_SUMMARY:
+			summary := metric.SetEmptySummary()
+			for _, promMetric := range family.GetMetric() {
+				dp := summary.DataPoints().AppendEmpty()
+				setOTLPDataPoint(promMetric, dp.Attributes(), dp.SetStartTimestamp, dp.SetTimestamp, startTs, defaultTs)
+				promSummary := promMetric.GetSummary()
+				dp.SetCount(promSummary.GetSampleCount())
+				dp.SetSum(promSummary.GetSampleSum())
+				for _, quantile := range promSummary.GetQuantile() {
+					value := dp.QuantileValues().AppendEmpty()
+					value.SetQuantile(quantile.GetQuantile())
+					value.SetValue(quantile.GetValue())
+				}
+			}
+		}
+	}
+	return md, nil
+}
+
+// Set the attributes and timestamps of an OpenTelemetry data point from a Prometheus metric
+func setOTLPDataPoint(promMetric *dto.Metric, attrs pcommon.Map, setStart, setTime func(pcommon.Timestamp), start, now pcommon.Timestamp) {
+	for _, label := range promMetric.GetLabel() {
+		attrs.PutStr(label.GetName(), label.GetValue())
+	}
+	if setStart != nil {
+		setStart(start)
+	}
+	if promMetric.TimestampMs != nil {
+		setTime(pcommon.NewTimestampFromTime(time.UnixMilli(promMetric.GetTimestampMs())))
+	} else {
+		setTime(now)
+	}
+}
+
+// Push metrics to an OTLP/HTTP endpoint using the protobuf encoding
+func pushOTLPMetrics(ctx context.Context, client *http.Client, endpoint string, headers map[string]string, md pmetric.Metrics) error {
+	body, err := pmetricotlp.NewExportRequestFromMetrics(md).MarshalProto()
+	if err != nil {
+		return errors.Wrap(err, "failed to encode the metrics")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "pelican/"+config.GetVersion())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return errors.Wrap(err, "failed to read the collector's response")
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("the collector responded with status %d: %s", resp.StatusCode, string(respBody))
+	}
+	exportResp := pmetricotlp.NewExportResponse()
+	if len(respBody) > 0 && resp.Header.Get("Content-Type") == "application/x-protobuf" {
+		if err := exportResp.UnmarshalProto(respBody); err == nil && exportResp.PartialSuccess().RejectedDataPoints() > 0 {
+			log.Warningf("The OpenTelemetry collector rejected %d data points: %s",
+				exportResp.PartialSuccess().RejectedDataPoints(), exportResp.PartialSuccess().ErrorMessage())
+		}
+	}
+	return nil
+}
+
+// Start pushing the metrics registered with Prometheus to the OpenTelemetry
+// collector at Monitoring.OTLP.Endpoint, if configured.  The metrics are still
+// served to Prometheus as usual.
+func LaunchOTLPExport(ctx context.Context, egrp *errgroup.Group) error {
+	endpointStr := param.Monitoring_OTLP_Endpoint.GetString()
+	if endpointStr == "" {
+		return nil
+	}
+	endpoint, err := url.Parse(endpointStr)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse Monitoring.OTLP.Endpoint")
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return errors.Errorf("unsupported scheme %q in Monitoring.OTLP.Endpoint; metrics are pushed over OTLP/HTTP", endpoint.Scheme)
+	}
+	// Like the OpenTelemetry SDKs, treat an endpoint without a path as the collector's base URL
+	if endpoint.Path == "" || endpoint.Path == "/" {
+		endpoint.Path = "/v1/metrics"
+	}
+	interval := param.Monitoring_OTLP_Interval.GetDuration()
+	if interval <= 0 {
+		return errors.New("Monitoring.OTLP.Interval must be positive")
+	}
+	headers := map[string]string{}
+	if err := param.Monitoring_OTLP_Headers.Unmarshal(&headers); err != nil {
+		return errors.Wrap(err, "failed to parse Monitoring.OTLP.Headers")
+	}
+
+	client := &http.Client{Transport: config.GetTransport(), Timeout: interval}
+	start := time.Now()
+	egrp.Go(func() error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case now := <-ticker.C:
+				md, err := gatherOTLPMetrics(RelabelingGatherer(prometheus.DefaultGatherer), start, now)
+				if err != nil {
+					log.Warningln("Failed to gather the metrics for the OTLP export:", err)
+					continue
+				}
+				if err := pushOTLPMetrics(ctx, client, endpoint.String(), headers, md); err != nil && ctx.Err() == nil {
+					log.Warningln("Failed to push metrics to the OpenTelemetry collector:", err)
+				}
+			}
+		}
+	})
+	log.Infof("Pushing metrics to the OpenTelemetry collector at %s every %s", endpoint.Redacted(), interval)
+	return nil
+}
@@ -0,0 +1,187 @@
+/***************************************************************
+ *
+ * 	Copyright 2021 Derek Weitzel
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *	See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"golang.org/x/sync/errgroup"
+)
+
+// Find a metric by name in the first scope of OpenTelemetry metrics
+func findOTLPMetric(t *testing.T, md pmetric.Metrics, name string) pmetric.Metric {
+	metrics := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	for idx := 0; idx < metrics.Len(); idx++ {
+		if metrics.At(idx).Name() == name {
+			return metrics.At(idx)
+		}
+	}
+	require.Failf(t, "metric not found", "%s is missing from the OTLP metrics", name)
+	return pmetric.NewMetric()
+}
+
+func TestGatherOTLPMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_bytes_total", Help: "Bytes"}, []string{"path"})
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_connections", Help: "Connections"})
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_duration_seconds", Buckets: []float64{1, 10}})
+	summary := prometheus.NewSummary(prometheus.SummaryOpts{Name: "test_size_bytes", Objectives: map[float64]float64{0.5: 0.05}})
+	registry.MustRegister(counter, gauge, histogram, summary)
+
+	counter.WithLabelValues("/foo").Add(42)
+	gauge.Set(3)
+	for _, value := range []float64{0.5, 5, 5, 50} {
+		histogram.Observe(value)
+	}
+	summary.Observe(7)
+
+	start := time.Unix(1700000000, 0)
+	now := start.Add(time.Minute)
+	md, err := gatherOTLPMetrics(registry, start, now)
+	require.NoError(t, err)
+	require.Equal(t, 1, md.ResourceMetrics().Len())
+	serviceName, ok := md.ResourceMetrics().At(0).Resource().Attributes().Get("service.name")
+	require.True(t, ok)
+	assert.Equal(t, "pelican", serviceName.Str())
+	assert.Equal(t, 4, md.MetricCount())
+
+	sum := findOTLPMetric(t, md, "test_bytes_total")
+	require.Equal(t, pmetric.MetricTypeSum, sum.Type())
+	assert.True(t, sum.Sum().IsMonotonic())
+	assert.Equal(t, pmetric.AggregationTemporalityCumulative, sum.Sum().AggregationTemporality())
+	assert.Equal(t, "Bytes", sum.Description())
+	dp := sum.Sum().DataPoints().At(0)
+	assert.Equal(t, 42.0, dp.DoubleValue())
+	assert.Equal(t, start.UnixNano(), dp.StartTimestamp().AsTime().UnixNano())
+	assert.Equal(t, now.UnixNano(), dp.Timestamp().AsTime().UnixNano())
+	path, ok := dp.Attributes().Get("path")
+	require.True(t, ok)
+	assert.Equal(t, "/foo", path.Str())
+
+	gaugeMetric := findOTLPMetric(t, md, "test_connections")
+	require.Equal(t, pmetric.MetricTypeGauge, gaugeMetric.Type())
+	assert.Equal(t, 3.0, gaugeMetric.Gauge().DataPoints().At(0).DoubleValue())
+
+	histMetric := findOTLPMetric(t, md, "test_duration_seconds")
+	require.Equal(t, pmetric.MetricTypeHistogram, histMetric.Type())
+	histDp := histMetric.Histogram().DataPoints().At(0)
+	assert.Equal(t, uint64(4), histDp.Count())
+	assert.Equal(t, 60.5, histDp.Sum())
+	assert.Equal(t, []float64{1, 10}, histDp.ExplicitBounds().AsRaw())
+	assert.Equal(t, []uint64{1, 2, 1}, histDp.BucketCounts().AsRaw())
+
+	summaryMetric := findOTLPMetric(t, md, "test_size_bytes")
+	require.Equal(t, pmetric.MetricTypeSummary, summaryMetric.Type())
+	summaryDp := summaryMetric.Summary().DataPoints().At(0)
+	assert.Equal(t, uint64(1), summaryDp.Count())
+	require.Equal(t, 1, summaryDp.QuantileValues().Len())
+	assert.Equal(t, 0.5, summaryDp.QuantileValues().At(0).Quantile())
+	assert.Equal(t, 7.0, summaryDp.QuantileValues().At(0).Value())
+}
+
+func TestPushOTLPMetrics(t *testing.T) {
+	received := make(chan pmetricotlp.ExportRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/metrics", r.URL.Path)
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer abc", r.Header.Get("Authorization"))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		req := pmetricotlp.NewExportRequest()
+		require.NoError(t, req.UnmarshalProto(body))
+		received <- req
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	registry := prometheus.NewRegistry()
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_requests_total"})
+	registry.MustRegister(counter)
+	counter.Inc()
+	md, err := gatherOTLPMetrics(registry, time.Now(), time.Now())
+	require.NoError(t, err)
+
+	err = pushOTLPMetrics(context.Background(), server.Client(), server.URL+"/v1/metrics", map[string]string{"authorization": "Bearer abc"}, md)
+	require.NoError(t, err)
+	req := <-received
+	assert.Equal(t, 1, req.Metrics().MetricCount())
+
+	t.Run("collector-error", func(t *testing.T) {
+		errServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "bad request", http.StatusBadRequest)
+		}))
+		defer errServer.Close()
+		err := pushOTLPMetrics(context.Background(), errServer.Client(), errServer.URL, nil, md)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "400")
+	})
+}
+
+func TestLaunchOTLPExport(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	ctx, cancel := context.WithCancel(context.Background())
+	egrp, ctx := errgroup.WithContext(ctx)
+	defer func() { require.NoError(t, egrp.Wait()) }()
+	defer cancel()
+
+	t.Run("disabled-by-default", func(t *testing.T) {
+		viper.Reset()
+		assert.NoError(t, LaunchOTLPExport(ctx, egrp))
+	})
+
+	t.Run("grpc-endpoint-is-rejected", func(t *testing.T) {
+		viper.Reset()
+		viper.Set("Monitoring.OTLP.Endpoint", "grpc://collector.example.org:4317")
+		viper.Set("Monitoring.OTLP.Interval", time.Minute)
+		assert.ErrorContains(t, LaunchOTLPExport(ctx, egrp), "OTLP/HTTP")
+	})
+
+	t.Run("pushes-periodically", func(t *testing.T) {
+		pushed := make(chan struct{}, 10)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/metrics", r.URL.Path)
+			assert.Equal(t, "pelican", r.Header.Get("X-Tenant"))
+			pushed <- struct{}{}
+		}))
+		defer server.Close()
+
+		viper.Reset()
+		viper.Set("Monitoring.OTLP.Endpoint", server.URL)
+		viper.Set("Monitoring.OTLP.Interval", 10*time.Millisecond)
+		viper.Set("Monitoring.OTLP.Headers", map[string]string{"X-Tenant": "pelican"})
+		require.NoError(t, LaunchOTLPExport(ctx, egrp))
+		select {
+		case <-pushed:
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "metrics were not pushed to the collector")
+		}
+		cancel()
+	})
+}
@@ -0,0 +1,82 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"net"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/param"
+)
+
+type (
+	// Forwards the raw monitoring packets the server receives to upstream collectors
+	packetForwarder struct {
+		destinations []packetDestination
+	}
+
+	packetDestination struct {
+		address string
+		conn    net.Conn
+	}
+)
+
+// Connect to the destinations of Monitoring.ForwardDestinations; nil if there are none
+//
+// Destinations that can't be resolved are logged and skipped, so that a misconfigured
+// collector doesn't keep the server's own monitoring from starting.
+func openConfiguredPacketForwarder() *packetForwarder {
+	addresses := param.Monitoring_ForwardDestinations.GetStringSlice()
+	if len(addresses) == 0 {
+		return nil
+	}
+	forwarder := &packetForwarder{}
+	for _, address := range addresses {
+		conn, err := net.Dial("udp", address)
+		if err != nil {
+			log.Warningf("Unable to connect to the monitoring forward destination %s; will not forward packets to it: %v", address, err)
+			continue
+		}
+		log.Infoln("Forwarding monitoring packets to", address)
+		forwarder.destinations = append(forwarder.destinations, packetDestination{address: address, conn: conn})
+	}
+	if len(forwarder.destinations) == 0 {
+		return nil
+	}
+	return forwarder
+}
+
+// Send a packet to every destination
+func (forwarder *packetForwarder) Forward(packet []byte) {
+	for _, dest := range forwarder.destinations {
+		if _, err := dest.conn.Write(packet); err != nil {
+			PacketsForwardErrors.WithLabelValues(dest.address).Inc()
+			log.Debugf("Failed to forward a monitoring packet to %s: %v", dest.address, err)
+			continue
+		}
+		PacketsForwarded.WithLabelValues(dest.address).Inc()
+	}
+}
+
+func (forwarder *packetForwarder) Close() {
+	for _, dest := range forwarder.destinations {
+		dest.conn.Close()
+	}
+}
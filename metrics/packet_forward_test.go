@@ -0,0 +1,70 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPacketForwarder(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	t.Run("no-destinations", func(t *testing.T) {
+		assert.Nil(t, openConfiguredPacketForwarder())
+	})
+
+	t.Run("forward-to-collectors", func(t *testing.T) {
+		var collectors []*net.UDPConn
+		var destinations []string
+		for i := 0; i < 2; i++ {
+			conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+			require.NoError(t, err)
+			t.Cleanup(func() { conn.Close() })
+			collectors = append(collectors, conn)
+			destinations = append(destinations, conn.LocalAddr().String())
+		}
+		// An unresolvable destination is skipped
+		viper.Set("Monitoring.ForwardDestinations", append(destinations, "collector.invalid:9930"))
+
+		forwarder := openConfiguredPacketForwarder()
+		require.NotNil(t, forwarder)
+		defer forwarder.Close()
+		require.Len(t, forwarder.destinations, 2)
+
+		forwarded := testutil.ToFloat64(PacketsForwarded.WithLabelValues(destinations[0]))
+		packet := []byte("u\x01\x00\x10\x00\x00\x00\x01raw packet")
+		forwarder.Forward(packet)
+		for _, collector := range collectors {
+			require.NoError(t, collector.SetReadDeadline(time.Now().Add(5*time.Second)))
+			buf := make([]byte, 1024)
+			n, err := collector.Read(buf)
+			require.NoError(t, err)
+			assert.Equal(t, packet, buf[:n])
+		}
+		assert.Equal(t, forwarded+1, testutil.ToFloat64(PacketsForwarded.WithLabelValues(destinations[0])))
+	})
+}
@@ -0,0 +1,359 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/param"
+)
+
+// An on-disk ring buffer of the monitoring packets received but not yet handled.  The
+// collector appends each packet as it's received and removes it once it's handled, so
+// packets that arrive while the handler is busy, e.g. while the metrics are set up at
+// startup, or that were pending when the server stopped, are replayed instead of lost.
+//
+// The file starts with a header holding its capacity and the logical offsets of its
+// oldest record (tail) and of the end of its newest (head); the offsets only grow and
+// are mapped into the data region modulo the capacity.  Each record is the packet's
+// length, as a 4-byte big-endian integer, followed by the packet.  When a packet
+// doesn't fit, the oldest records are dropped to make room.
+type packetSpool struct {
+	mutex    sync.Mutex
+	file     *os.File
+	capacity uint64
+	head     uint64
+	tail     uint64
+	closed   bool
+}
+
+var (
+	// Serializes the handling of the packets, which are normally handled from the
+	// spool but are handled right away if they fail to be spooled
+	handlePacketMutex sync.Mutex
+)
+
+const (
+	packetSpoolMagic      = "PLCNSPL1"
+	packetSpoolHeaderSize = 32 // magic, capacity, head, tail
+	packetSpoolLenSize    = 4
+
+	// Number of packets held in memory when they can't be spooled
+	packetQueueSize = 1024
+)
+
+// Open the packet spool at the given location, creating it if needed.  Packets pending
+// in an existing spool of a different capacity are carried over to the new capacity.
+func openPacketSpool(location string, capacity uint64) (*packetSpool, error) {
+	if capacity <= packetSpoolLenSize {
+		return nil, errors.Errorf("packet spool capacity of %d bytes is too small", capacity)
+	}
+	if err := os.MkdirAll(filepath.Dir(location), 0750); err != nil {
+		return nil, errors.Wrapf(err, "failed to create the directory of the packet spool %s", location)
+	}
+	file, err := os.OpenFile(location, os.O_RDWR|os.O_CREATE, 0640)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open the packet spool %s", location)
+	}
+	spool := &packetSpool{file: file, capacity: capacity}
+
+	var header [packetSpoolHeaderSize]byte
+	if _, err := file.ReadAt(header[:], 0); err != nil && err != io.EOF {
+		file.Close()
+		return nil, errors.Wrapf(err, "failed to read the header of the packet spool %s", location)
+	} else if err == io.EOF || !bytes.Equal(header[:8], []byte(packetSpoolMagic)) {
+		// A new (or unrecognized) spool starts empty
+		if err := spool.reset(); err != nil {
+			file.Close()
+			return nil, err
+		}
+		return spool, nil
+	}
+
+	spool.capacity = binary.BigEndian.Uint64(header[8:16])
+	spool.head = binary.BigEndian.Uint64(header[16:24])
+	spool.tail = binary.BigEndian.Uint64(header[24:32])
+	if spool.tail > spool.head || spool.head-spool.tail > spool.capacity {
+		log.Warningf("The packet spool %s is corrupt; discarding its packets", location)
+		spool.capacity = capacity
+		if err := spool.reset(); err != nil {
+			file.Close()
+			return nil, err
+		}
+		return spool, nil
+	}
+	if spool.capacity == capacity {
+		return spool, nil
+	}
+
+	// Move the pending packets to a spool of the new capacity
+	var pending [][]byte
+	for {
+		packet, pos, err := spool.Peek()
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if packet == nil {
+			break
+		}
+		pending = append(pending, packet)
+		if err := spool.Pop(pos, len(packet)); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	spool.capacity = capacity
+	if err := spool.reset(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	for _, packet := range pending {
+		if err := spool.Append(packet); err != nil {
+			log.Warningln("Failed to move a pending packet to the resized packet spool:", err)
+		}
+	}
+	return spool, nil
+}
+
+// Empty the spool and resize its file to its capacity
+func (spool *packetSpool) reset() error {
+	spool.head, spool.tail = 0, 0
+	if err := spool.file.Truncate(int64(packetSpoolHeaderSize + spool.capacity)); err != nil {
+		return errors.Wrap(err, "failed to resize the packet spool")
+	}
+	var header [packetSpoolHeaderSize]byte
+	copy(header[:8], packetSpoolMagic)
+	binary.BigEndian.PutUint64(header[8:16], spool.capacity)
+	if _, err := spool.file.WriteAt(header[:], 0); err != nil {
+		return errors.Wrap(err, "failed to write the header of the packet spool")
+	}
+	return spool.writeOffsets()
+}
+
+func (spool *packetSpool) writeOffsets() error {
+	var offsets [16]byte
+	binary.BigEndian.PutUint64(offsets[:8], spool.head)
+	binary.BigEndian.PutUint64(offsets[8:], spool.tail)
+	if _, err := spool.file.WriteAt(offsets[:], 16); err != nil {
+		return errors.Wrap(err, "failed to update the packet spool")
+	}
+	return nil
+}
+
+// Read or write the data region at a logical offset, wrapping around its end
+func (spool *packetSpool) readAt(buf []byte, pos uint64) error {
+	off := pos % spool.capacity
+	first := min(uint64(len(buf)), spool.capacity-off)
+	if _, err := spool.file.ReadAt(buf[:first], int64(packetSpoolHeaderSize+off)); err != nil {
+		return err
+	}
+	if first < uint64(len(buf)) {
+		if _, err := spool.file.ReadAt(buf[first:], packetSpoolHeaderSize); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (spool *packetSpool) writeAt(buf []byte, pos uint64) error {
+	off := pos % spool.capacity
+	first := min(uint64(len(buf)), spool.capacity-off)
+	if _, err := spool.file.WriteAt(buf[:first], int64(packetSpoolHeaderSize+off)); err != nil {
+		return err
+	}
+	if first < uint64(len(buf)) {
+		if _, err := spool.file.WriteAt(buf[first:], packetSpoolHeaderSize); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get the length of the record at a logical offset
+func (spool *packetSpool) recordLen(pos uint64) (uint64, error) {
+	var length [packetSpoolLenSize]byte
+	if err := spool.readAt(length[:], pos); err != nil {
+		return 0, errors.Wrap(err, "failed to read from the packet spool")
+	}
+	return uint64(binary.BigEndian.Uint32(length[:])), nil
+}
+
+// Add a packet to the spool, dropping the oldest packets if it's full
+func (spool *packetSpool) Append(packet []byte) error {
+	size := uint64(packetSpoolLenSize + len(packet))
+	if size > spool.capacity {
+		return errors.Errorf("packet of %d bytes doesn't fit in the packet spool", len(packet))
+	}
+
+	spool.mutex.Lock()
+	defer spool.mutex.Unlock()
+	if spool.closed {
+		return errors.New("the packet spool is closed")
+	}
+	for spool.head-spool.tail+size > spool.capacity {
+		length, err := spool.recordLen(spool.tail)
+		if err != nil {
+			return err
+		}
+		spool.tail += packetSpoolLenSize + length
+		PacketsDropped.Inc()
+	}
+
+	record := make([]byte, size)
+	binary.BigEndian.PutUint32(record, uint32(len(packet)))
+	copy(record[packetSpoolLenSize:], packet)
+	if err := spool.writeAt(record, spool.head); err != nil {
+		return errors.Wrap(err, "failed to write to the packet spool")
+	}
+	spool.head += size
+	return spool.writeOffsets()
+}
+
+// Get the oldest packet in the spool and its position, to be passed to Pop once it's
+// handled; the packet is nil if the spool is empty (or closed)
+func (spool *packetSpool) Peek() ([]byte, uint64, error) {
+	spool.mutex.Lock()
+	defer spool.mutex.Unlock()
+	if spool.closed || spool.head == spool.tail {
+		return nil, 0, nil
+	}
+	length, err := spool.recordLen(spool.tail)
+	if err != nil {
+		return nil, 0, err
+	}
+	packet := make([]byte, length)
+	if err := spool.readAt(packet, spool.tail+packetSpoolLenSize); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to read from the packet spool")
+	}
+	return packet, spool.tail, nil
+}
+
+// Remove a packet returned by Peek from the spool.  Nothing is done if the packet was
+// dropped in the meantime to make room for newer ones.
+func (spool *packetSpool) Pop(pos uint64, length int) error {
+	spool.mutex.Lock()
+	defer spool.mutex.Unlock()
+	if spool.closed || spool.tail != pos {
+		return nil
+	}
+	spool.tail += uint64(packetSpoolLenSize + length)
+	return spool.writeOffsets()
+}
+
+// Get the number of bytes of packets pending in the spool
+func (spool *packetSpool) Pending() uint64 {
+	spool.mutex.Lock()
+	defer spool.mutex.Unlock()
+	return spool.head - spool.tail
+}
+
+func (spool *packetSpool) Close() error {
+	spool.mutex.Lock()
+	defer spool.mutex.Unlock()
+	if spool.closed {
+		return nil
+	}
+	spool.closed = true
+	return spool.file.Close()
+}
+
+// Open the spool at Monitoring.PacketSpoolLocation; nil if Monitoring.PacketSpoolSize is 0
+func openConfiguredPacketSpool() (*packetSpool, error) {
+	size := param.Monitoring_PacketSpoolSize.GetInt()
+	location := param.Monitoring_PacketSpoolLocation.GetString()
+	if size <= 0 || location == "" {
+		return nil, nil
+	}
+	return openPacketSpool(location, uint64(size))
+}
+
+// Handle the spooled packets, oldest first, whenever new ones are spooled
+func handleSpooledPackets(ctx context.Context, spool *packetSpool, spooled <-chan struct{}) {
+	defer TrackGoroutine("xrootd-monitoring")()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-spooled:
+		}
+		for ctx.Err() == nil {
+			packet, pos, err := spool.Peek()
+			if err != nil {
+				log.Errorln("Failed to read from the packet spool:", err)
+				break
+			}
+			if packet == nil {
+				break
+			}
+			handleReceivedPacket(packet)
+			if err = spool.Pop(pos, len(packet)); err != nil {
+				log.Errorln("Failed to remove a handled packet from the spool:", err)
+				break
+			}
+		}
+	}
+}
+
+// Handle the packets queued in memory because they weren't spooled
+func handleQueuedPackets(ctx context.Context, queued <-chan []byte) {
+	defer TrackGoroutine("xrootd-monitoring")()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case packet := <-queued:
+			handleReceivedPacket(packet)
+		}
+	}
+}
+
+func handleReceivedPacket(packet []byte) {
+	handlePacketMutex.Lock()
+	defer handlePacketMutex.Unlock()
+	if err := HandlePacket(packet); err != nil {
+		PacketParseErrors.WithLabelValues(packetStream(packet)).Inc()
+		log.Errorln("Failed to handle packet:", err)
+	}
+}
+
+// The stream a monitoring packet belongs to, for labeling metrics: its header code,
+// "summary" for summary packets, or "unknown"
+func packetStream(packet []byte) string {
+	if len(packet) == 0 {
+		return "unknown"
+	}
+	code := packet[0]
+	switch {
+	case code == '<':
+		return "summary"
+	case code >= 'a' && code <= 'z', code >= 'A' && code <= 'Z', code == '=':
+		return string(code)
+	}
+	return "unknown"
+}
@@ -0,0 +1,169 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Pop all the packets in the spool, in order
+func drainSpool(t *testing.T, spool *packetSpool) []string {
+	packets := []string{}
+	for {
+		packet, pos, err := spool.Peek()
+		require.NoError(t, err)
+		if packet == nil {
+			return packets
+		}
+		packets = append(packets, string(packet))
+		require.NoError(t, spool.Pop(pos, len(packet)))
+	}
+}
+
+func TestPacketSpool(t *testing.T) {
+	t.Run("append-and-pop", func(t *testing.T) {
+		spool, err := openPacketSpool(filepath.Join(t.TempDir(), "spool"), 1024)
+		require.NoError(t, err)
+		defer spool.Close()
+
+		packet, _, err := spool.Peek()
+		require.NoError(t, err)
+		assert.Nil(t, packet)
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, spool.Append([]byte(fmt.Sprintf("packet-%d", i))))
+		}
+		assert.Equal(t, uint64(3*(4+8)), spool.Pending())
+		assert.Equal(t, []string{"packet-0", "packet-1", "packet-2"}, drainSpool(t, spool))
+		assert.Zero(t, spool.Pending())
+	})
+
+	t.Run("survives-reopening", func(t *testing.T) {
+		location := filepath.Join(t.TempDir(), "spool")
+		spool, err := openPacketSpool(location, 1024)
+		require.NoError(t, err)
+		require.NoError(t, spool.Append([]byte("handled")))
+		require.NoError(t, spool.Append([]byte("pending")))
+		packet, pos, err := spool.Peek()
+		require.NoError(t, err)
+		require.NoError(t, spool.Pop(pos, len(packet)))
+		require.NoError(t, spool.Close())
+
+		spool, err = openPacketSpool(location, 1024)
+		require.NoError(t, err)
+		defer spool.Close()
+		assert.Equal(t, []string{"pending"}, drainSpool(t, spool))
+	})
+
+	t.Run("drops-oldest-when-full", func(t *testing.T) {
+		// Room for three 10-byte packets and their lengths, so the records wrap around
+		spool, err := openPacketSpool(filepath.Join(t.TempDir(), "spool"), 3*(4+10)+5)
+		require.NoError(t, err)
+		defer spool.Close()
+
+		dropped := testutil.ToFloat64(PacketsDropped)
+		for i := 0; i < 3; i++ {
+			require.NoError(t, spool.Append([]byte(fmt.Sprintf("packet-%03d", i))))
+		}
+		// The oldest packet is peeked but dropped before it's handled
+		packet, pos, err := spool.Peek()
+		require.NoError(t, err)
+		for i := 3; i < 5; i++ {
+			require.NoError(t, spool.Append([]byte(fmt.Sprintf("packet-%03d", i))))
+		}
+		require.NoError(t, spool.Pop(pos, len(packet)))
+		assert.Equal(t, dropped+2, testutil.ToFloat64(PacketsDropped))
+		assert.Equal(t, []string{"packet-002", "packet-003", "packet-004"}, drainSpool(t, spool))
+
+		assert.Error(t, spool.Append(make([]byte, 64)))
+	})
+
+	t.Run("resize-keeps-pending", func(t *testing.T) {
+		location := filepath.Join(t.TempDir(), "spool")
+		spool, err := openPacketSpool(location, 1024)
+		require.NoError(t, err)
+		require.NoError(t, spool.Append([]byte("first")))
+		require.NoError(t, spool.Append([]byte("second")))
+		require.NoError(t, spool.Close())
+
+		spool, err = openPacketSpool(location, 2048)
+		require.NoError(t, err)
+		defer spool.Close()
+		assert.Equal(t, uint64(2048), spool.capacity)
+		assert.Equal(t, []string{"first", "second"}, drainSpool(t, spool))
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		location := filepath.Join(t.TempDir(), "spool")
+		spool, err := openPacketSpool(location, 4096)
+		require.NoError(t, err)
+		summary := `<statistics ver="v5.6.0" pgm="xrootd"><stats id="sched"><threads>10</threads><idle>4</idle></stats></statistics>`
+		require.NoError(t, spool.Append([]byte(summary)))
+
+		Threads.Reset()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		spooled := make(chan struct{}, 1)
+		spooled <- struct{}{}
+		go handleSpooledPackets(ctx, spool, spooled)
+
+		assert.Eventually(t, func() bool { return spool.Pending() == 0 }, time.Second, 10*time.Millisecond)
+		assert.Equal(t, 4.0, testutil.ToFloat64(Threads.WithLabelValues("0", "idle")))
+		assert.Equal(t, 6.0, testutil.ToFloat64(Threads.WithLabelValues("0", "running")))
+		cancel()
+		require.NoError(t, spool.Close())
+	})
+}
+
+func TestHandleReceivedPacketParseErrors(t *testing.T) {
+	assert.Equal(t, "summary", packetStream([]byte("<statistics>")))
+	assert.Equal(t, "f", packetStream([]byte("f\x00\x00\x08")))
+	assert.Equal(t, "unknown", packetStream([]byte{0xff}))
+	assert.Equal(t, "unknown", packetStream(nil))
+
+	parseErrors := testutil.ToFloat64(PacketParseErrors.WithLabelValues("f"))
+	// An f-stream packet too short to hold its header
+	handleReceivedPacket([]byte("f\x00\x00\x08\x00\x00\x00\x00"))
+	assert.Equal(t, parseErrors+1, testutil.ToFloat64(PacketParseErrors.WithLabelValues("f")))
+
+	parseErrors = testutil.ToFloat64(PacketParseErrors.WithLabelValues("summary"))
+	handleReceivedPacket([]byte("<statistics"))
+	assert.Equal(t, parseErrors+1, testutil.ToFloat64(PacketParseErrors.WithLabelValues("summary")))
+}
+
+func TestHandleQueuedPackets(t *testing.T) {
+	Threads.Reset()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	queued := make(chan []byte, 1)
+	go handleQueuedPackets(ctx, queued)
+
+	queued <- []byte(`<statistics ver="v5.6.0" pgm="xrootd"><stats id="sched"><threads>8</threads><idle>3</idle></stats></statistics>`)
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(Threads.WithLabelValues("0", "idle")) == 3.0
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,92 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	PelicanModuleInfo = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pelican_module_info",
+		Help: "Set to 1 for each Pelican module the server runs",
+	}, []string{"module", "instance_name"})
+
+	PelicanGoroutines = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pelican_goroutines",
+		Help: "The number of long-running goroutines of a Pelican subsystem",
+	}, []string{"subsystem"})
+
+	// The runtime and process collectors registered by ConfigureRuntimeMetrics
+	runtimeCollectors      []prometheus.Collector
+	runtimeRegisterer      prometheus.Registerer
+	runtimeCollectorsMutex sync.Mutex
+)
+
+func newRuntimeCollectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	}
+}
+
+// Register Go runtime and process collectors labeled by the modules the process runs
+// and its instance name, so the processes of a multi-module deployment can be told apart
+// in dashboards.  Their metrics are prefixed with "pelican_" (e.g. pelican_go_goroutines)
+// as the unlabeled go_* and process_* metrics of the default collectors remain.
+func ConfigureRuntimeMetrics(modules []string, instanceName string) error {
+	runtimeCollectorsMutex.Lock()
+	defer runtimeCollectorsMutex.Unlock()
+
+	if runtimeRegisterer != nil {
+		for _, collector := range runtimeCollectors {
+			runtimeRegisterer.Unregister(collector)
+		}
+	}
+
+	PelicanModuleInfo.Reset()
+	for _, module := range modules {
+		PelicanModuleInfo.WithLabelValues(module, instanceName).Set(1)
+	}
+
+	runtimeRegisterer = prometheus.WrapRegistererWithPrefix("pelican_", prometheus.WrapRegistererWith(prometheus.Labels{
+		"modules":       strings.Join(modules, ","),
+		"instance_name": instanceName,
+	}, prometheus.DefaultRegisterer))
+	runtimeCollectors = newRuntimeCollectors()
+	for _, collector := range runtimeCollectors {
+		if err := runtimeRegisterer.Register(collector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Count a long-running goroutine of the subsystem in pelican_goroutines until the
+// returned function is called, e.g. `defer metrics.TrackGoroutine("registry-mirror")()`
+func TrackGoroutine(subsystem string) func() {
+	gauge := PelicanGoroutines.WithLabelValues(subsystem)
+	gauge.Inc()
+	return gauge.Dec
+}
@@ -0,0 +1,63 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigureRuntimeMetrics(t *testing.T) {
+	// Configuring again, as a restarted server does, replaces the labeled collectors
+	require.NoError(t, ConfigureRuntimeMetrics([]string{"director"}, "first.example.com:8444"))
+	require.NoError(t, ConfigureRuntimeMetrics([]string{"cache", "origin"}, "server.example.com:8444"))
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	var goroutines []map[string]string
+	for _, family := range families {
+		if family.GetName() != "pelican_go_goroutines" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			goroutines = append(goroutines, labels)
+		}
+	}
+	assert.Equal(t, []map[string]string{{"modules": "cache,origin", "instance_name": "server.example.com:8444"}}, goroutines)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(PelicanModuleInfo.WithLabelValues("cache", "server.example.com:8444")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(PelicanModuleInfo.WithLabelValues("origin", "server.example.com:8444")))
+	assert.Equal(t, 2, testutil.CollectAndCount(PelicanModuleInfo))
+}
+
+func TestTrackGoroutine(t *testing.T) {
+	gauge := PelicanGoroutines.WithLabelValues("test-subsystem")
+	done := TrackGoroutine("test-subsystem")
+	assert.Equal(t, 1.0, testutil.ToFloat64(gauge))
+	done()
+	assert.Equal(t, 0.0, testutil.ToFloat64(gauge))
+}
@@ -263,6 +263,7 @@ func LaunchShoveler(ctx context.Context, egrp *errgroup.Group, metricsPort int)
 	})
 
 	go func() {
+		defer TrackGoroutine("shoveler")()
 		var buf [65536]byte
 		for {
 			rlen, remote, err := conn.ReadFromUDP(buf[:])
@@ -0,0 +1,178 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type (
+	// The transfers of a user over a rolling window, from the files XRootD closed
+	TopUserUsage struct {
+		User       string `json:"user"`
+		DN         string `json:"dn"`
+		Org        string `json:"org,omitempty"`
+		ReadBytes  uint64 `json:"readBytes"` // Including the bytes of vector reads
+		WriteBytes uint64 `json:"writeBytes"`
+		TotalBytes uint64 `json:"totalBytes"`
+		Transfers  uint64 `json:"transfers"`
+	}
+
+	// Users are told apart by their name and DN; either may be empty
+	topUserKey struct {
+		user string
+		dn   string
+	}
+
+	topUsersBucket struct {
+		start time.Time
+		usage map[topUserKey]*TopUserUsage
+	}
+
+	// A ring of buckets covering a rolling window
+	topUsersWindow struct {
+		width   time.Duration
+		buckets []topUsersBucket
+	}
+
+	topUsersTracker struct {
+		lock    sync.Mutex
+		windows map[string]*topUsersWindow
+	}
+)
+
+const (
+	TopUsersHour = "hour"
+	TopUsersDay  = "day"
+
+	// The users counted in a bucket are capped to bound the memory used; the transfers
+	// of further users are counted under topUsersOtherUser
+	topUsersMaxPerBucket = 10000
+	topUsersOtherUser    = "(other)"
+)
+
+var topUsers = newTopUsersTracker()
+
+func newTopUsersTracker() *topUsersTracker {
+	return &topUsersTracker{windows: map[string]*topUsersWindow{
+		TopUsersHour: {width: time.Minute, buckets: make([]topUsersBucket, 60)},
+		TopUsersDay:  {width: time.Hour, buckets: make([]topUsersBucket, 24)},
+	}}
+}
+
+// Add a transfer to the bucket of the window covering its end
+func (window *topUsersWindow) add(user *UserRecord, readBytes, writeBytes uint64, end time.Time) {
+	start := end.Truncate(window.width)
+	bucket := &window.buckets[(start.Unix()/int64(window.width.Seconds()))%int64(len(window.buckets))]
+	if !bucket.start.Equal(start) {
+		bucket.start = start
+		bucket.usage = map[topUserKey]*TopUserUsage{}
+	}
+	key := topUserKey{}
+	org := ""
+	if user != nil {
+		key = topUserKey{user: user.User, dn: user.DN}
+		org = user.Org
+	}
+	usage, ok := bucket.usage[key]
+	if !ok {
+		if len(bucket.usage) >= topUsersMaxPerBucket {
+			key, org = topUserKey{user: topUsersOtherUser}, ""
+			usage = bucket.usage[key]
+		}
+		if usage == nil {
+			usage = &TopUserUsage{User: key.user, DN: key.dn, Org: org}
+			bucket.usage[key] = usage
+		}
+	}
+	usage.ReadBytes += readBytes
+	usage.WriteBytes += writeBytes
+	usage.TotalBytes += readBytes + writeBytes
+	usage.Transfers++
+}
+
+// Sum the usage of each user over the buckets still in the window at the given time
+func (window *topUsersWindow) sum(now time.Time) map[topUserKey]*TopUserUsage {
+	oldest := now.Truncate(window.width).Add(-window.width * time.Duration(len(window.buckets)-1))
+	totals := map[topUserKey]*TopUserUsage{}
+	for _, bucket := range window.buckets {
+		if bucket.start.Before(oldest) || bucket.start.After(now) {
+			continue
+		}
+		for key, usage := range bucket.usage {
+			total, ok := totals[key]
+			if !ok {
+				total = &TopUserUsage{User: usage.User, DN: usage.DN, Org: usage.Org}
+				totals[key] = total
+			}
+			total.ReadBytes += usage.ReadBytes
+			total.WriteBytes += usage.WriteBytes
+			total.TotalBytes += usage.TotalBytes
+			total.Transfers += usage.Transfers
+		}
+	}
+	return totals
+}
+
+// Record a closed file; user is nil if XRootD didn't report the user of the transfer
+func (tracker *topUsersTracker) record(user *UserRecord, readBytes, writeBytes uint64, end time.Time) {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	for _, window := range tracker.windows {
+		window.add(user, readBytes, writeBytes, end)
+	}
+}
+
+// Get the users with the most bytes transferred over the window, at most limit of them
+func (tracker *topUsersTracker) top(windowName string, limit int, now time.Time) ([]TopUserUsage, bool) {
+	tracker.lock.Lock()
+	window, ok := tracker.windows[windowName]
+	if !ok {
+		tracker.lock.Unlock()
+		return nil, false
+	}
+	totals := window.sum(now)
+	tracker.lock.Unlock()
+
+	result := make([]TopUserUsage, 0, len(totals))
+	for _, usage := range totals {
+		result = append(result, *usage)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].TotalBytes != result[j].TotalBytes {
+			return result[i].TotalBytes > result[j].TotalBytes
+		}
+		if result[i].User != result[j].User {
+			return result[i].User < result[j].User
+		}
+		return result[i].DN < result[j].DN
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result, true
+}
+
+// Get the users with the most bytes transferred over the past hour or day (TopUsersHour
+// or TopUsersDay), at most limit of them.  Returns false if the window is unknown.
+func GetTopUsers(window string, limit int) ([]TopUserUsage, bool) {
+	return topUsers.top(window, limit, time.Now())
+}
@@ -0,0 +1,104 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopUsers(t *testing.T) {
+	alice := &UserRecord{User: "alice", DN: "/CN=alice", Org: "example.org"}
+	bob := &UserRecord{User: "bob", DN: "/CN=bob"}
+	now := time.Date(2024, 11, 5, 12, 30, 0, 0, time.UTC)
+
+	t.Run("ranks-users-by-total-bytes", func(t *testing.T) {
+		tracker := newTopUsersTracker()
+		tracker.record(alice, 100, 0, now.Add(-10*time.Minute))
+		tracker.record(alice, 50, 25, now)
+		tracker.record(bob, 0, 500, now.Add(-time.Minute))
+		tracker.record(nil, 10, 0, now)
+
+		top, ok := tracker.top(TopUsersHour, 10, now)
+		require.True(t, ok)
+		require.Len(t, top, 3)
+		assert.Equal(t, TopUserUsage{User: "bob", DN: "/CN=bob", WriteBytes: 500, TotalBytes: 500, Transfers: 1}, top[0])
+		assert.Equal(t, TopUserUsage{User: "alice", DN: "/CN=alice", Org: "example.org", ReadBytes: 150, WriteBytes: 25, TotalBytes: 175, Transfers: 2}, top[1])
+		assert.Equal(t, TopUserUsage{ReadBytes: 10, TotalBytes: 10, Transfers: 1}, top[2])
+
+		top, ok = tracker.top(TopUsersHour, 1, now)
+		require.True(t, ok)
+		require.Len(t, top, 1)
+		assert.Equal(t, "bob", top[0].User)
+
+		_, ok = tracker.top("week", 10, now)
+		assert.False(t, ok)
+	})
+
+	t.Run("expires-old-buckets", func(t *testing.T) {
+		tracker := newTopUsersTracker()
+		tracker.record(alice, 100, 0, now.Add(-2*time.Hour))
+		tracker.record(bob, 10, 0, now.Add(-30*time.Minute))
+
+		top, ok := tracker.top(TopUsersHour, 10, now)
+		require.True(t, ok)
+		require.Len(t, top, 1)
+		assert.Equal(t, "bob", top[0].User)
+
+		top, ok = tracker.top(TopUsersDay, 10, now)
+		require.True(t, ok)
+		require.Len(t, top, 2)
+		assert.Equal(t, "alice", top[0].User)
+
+		// Both users have dropped out of the day a day later
+		top, ok = tracker.top(TopUsersDay, 10, now.Add(24*time.Hour))
+		require.True(t, ok)
+		assert.Empty(t, top)
+	})
+
+	t.Run("reuses-a-bucket-after-rollover", func(t *testing.T) {
+		tracker := newTopUsersTracker()
+		tracker.record(alice, 100, 0, now)
+		// The same slot of the ring an hour later
+		tracker.record(bob, 10, 0, now.Add(time.Hour))
+
+		top, ok := tracker.top(TopUsersHour, 10, now.Add(time.Hour))
+		require.True(t, ok)
+		require.Len(t, top, 1)
+		assert.Equal(t, "bob", top[0].User)
+	})
+
+	t.Run("overflows-into-other", func(t *testing.T) {
+		tracker := newTopUsersTracker()
+		for idx := 0; idx < topUsersMaxPerBucket; idx++ {
+			tracker.record(&UserRecord{User: fmt.Sprintf("user%d", idx)}, 1, 0, now)
+		}
+		tracker.record(alice, 100, 0, now)
+		tracker.record(bob, 200, 0, now)
+
+		top, ok := tracker.top(TopUsersHour, 1, now)
+		require.True(t, ok)
+		require.Len(t, top, 1)
+		assert.Equal(t, TopUserUsage{User: topUsersOtherUser, ReadBytes: 300, TotalBytes: 300, Transfers: 2}, top[0])
+	})
+}
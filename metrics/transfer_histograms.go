@@ -0,0 +1,132 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/pelicanplatform/pelican/param"
+)
+
+// The histograms of the transfers XRootD reports closing.  Their buckets come from the
+// configuration, so they are replaced by ConfigureMonitoring instead of being created
+// with promauto.
+type transferHistograms struct {
+	duration *prometheus.HistogramVec
+	size     *prometheus.HistogramVec
+}
+
+var (
+	transferHistogramsMutex   sync.RWMutex
+	currentTransferHistograms transferHistograms
+
+	defaultTransferDurationBuckets = []float64{1, 5, 10, 30, 60, 300, 900, 3600}
+	defaultTransferSizeBuckets     = prometheus.ExponentialBuckets(1024, 16, 7) // 1KiB to 16GiB
+)
+
+func init() {
+	if err := setTransferHistograms(defaultTransferDurationBuckets, defaultTransferSizeBuckets); err != nil {
+		panic(err)
+	}
+}
+
+func newTransferHistograms(durationBuckets, sizeBuckets []float64) transferHistograms {
+	return transferHistograms{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "xrootd_transfer_duration_seconds",
+			Help:    "The time between a file's open and close, with the resolution of XRootD's monitoring windows",
+			Buckets: durationBuckets,
+		}, []string{"instance", "path", "proj", "type"}),
+		size: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "xrootd_transfer_size_bytes",
+			Help:    "The number of bytes read from or written to a file between its open and close",
+			Buckets: sizeBuckets,
+		}, []string{"instance", "path", "proj", "type"}),
+	}
+}
+
+// Replace the transfer histograms with ones using the given buckets; the series
+// recorded so far are dropped
+func setTransferHistograms(durationBuckets, sizeBuckets []float64) error {
+	histograms := newTransferHistograms(durationBuckets, sizeBuckets)
+
+	transferHistogramsMutex.Lock()
+	defer transferHistogramsMutex.Unlock()
+	if currentTransferHistograms.duration != nil {
+		prometheus.Unregister(currentTransferHistograms.duration)
+		prometheus.Unregister(currentTransferHistograms.size)
+	}
+	if err := prometheus.Register(histograms.duration); err != nil {
+		return errors.Wrap(err, "failed to register the transfer duration histogram")
+	}
+	if err := prometheus.Register(histograms.size); err != nil {
+		prometheus.Unregister(histograms.duration)
+		return errors.Wrap(err, "failed to register the transfer size histogram")
+	}
+	currentTransferHistograms = histograms
+	return nil
+}
+
+// Set the transfer histograms' buckets from Monitoring.TransferDurationBuckets and
+// Monitoring.TransferSizeBuckets
+func configureTransferHistograms() error {
+	durationBuckets := make([]float64, 0, len(param.Monitoring_TransferDurationBuckets.GetStringSlice()))
+	for _, bucket := range param.Monitoring_TransferDurationBuckets.GetStringSlice() {
+		duration, err := time.ParseDuration(bucket)
+		if err != nil {
+			return errors.Wrapf(err, "invalid bucket %q in %s", bucket, "Monitoring.TransferDurationBuckets")
+		}
+		durationBuckets = append(durationBuckets, duration.Seconds())
+	}
+	sizeBuckets := make([]float64, 0, len(param.Monitoring_TransferSizeBuckets.GetStringSlice()))
+	for _, bucket := range param.Monitoring_TransferSizeBuckets.GetStringSlice() {
+		size, err := strconv.ParseUint(bucket, 10, 64)
+		if err != nil {
+			return errors.Wrapf(err, "invalid bucket %q in %s", bucket, "Monitoring.TransferSizeBuckets")
+		}
+		sizeBuckets = append(sizeBuckets, float64(size))
+	}
+	if len(durationBuckets) == 0 {
+		durationBuckets = defaultTransferDurationBuckets
+	}
+	if len(sizeBuckets) == 0 {
+		sizeBuckets = defaultTransferSizeBuckets
+	}
+	return setTransferHistograms(durationBuckets, sizeBuckets)
+}
+
+// Record a closed transfer in the histograms.  The type is "write" if any bytes were
+// written to the file and "read" otherwise.
+func observeTransfer(instance, path, project string, duration time.Duration, readBytes, writeBytes uint64) {
+	transferType, size := "read", readBytes
+	if writeBytes > 0 {
+		transferType, size = "write", writeBytes
+	}
+
+	transferHistogramsMutex.RLock()
+	histograms := currentTransferHistograms
+	transferHistogramsMutex.RUnlock()
+	histograms.duration.WithLabelValues(instance, path, project, transferType).Observe(duration.Seconds())
+	histograms.size.WithLabelValues(instance, path, project, transferType).Observe(float64(size))
+}
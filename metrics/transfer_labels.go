@@ -0,0 +1,91 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/pelicanplatform/pelican/param"
+)
+
+const (
+	transferLabelDrop = "drop"
+	transferLabelHash = "hash"
+)
+
+var (
+	// The labels of the xrootd_transfer_* metrics that Monitoring.TransferLabelActions
+	// may drop or hash; the operation type is always kept
+	relabeledTransferLabels = map[string]bool{
+		"path": true, "ap": true, "dn": true, "role": true, "org": true, "proj": true, "country": true, "asn": true,
+	}
+
+	// The action to take on each transfer label, from Monitoring.TransferLabelActions
+	transferLabelActions map[string]string
+)
+
+// Read the actions to take on the labels of the transfer metrics from
+// Monitoring.TransferLabelActions
+func configureTransferLabels() error {
+	actions := map[string]string{}
+	if err := param.Monitoring_TransferLabelActions.Unmarshal(&actions); err != nil {
+		return errors.Wrap(err, "failed to parse Monitoring.TransferLabelActions")
+	}
+	for label, action := range actions {
+		if !relabeledTransferLabels[label] {
+			return errors.Errorf("invalid label %q in Monitoring.TransferLabelActions", label)
+		}
+		if action != transferLabelDrop && action != transferLabelHash {
+			return errors.Errorf("invalid action %q for label %q in Monitoring.TransferLabelActions; must be %q or %q",
+				action, label, transferLabelDrop, transferLabelHash)
+		}
+	}
+	transferLabelActions = actions
+	return nil
+}
+
+// Drop or hash the transfer labels configured in Monitoring.TransferLabelActions.  A
+// dropped label is left empty, which Prometheus treats the same as a missing label, so
+// all the values collapse into one series.
+func relabelTransfer(labels prometheus.Labels) {
+	for label, action := range transferLabelActions {
+		value, ok := labels[label]
+		if !ok {
+			continue
+		}
+		switch action {
+		case transferLabelDrop:
+			labels[label] = ""
+		case transferLabelHash:
+			if value != "" {
+				labels[label] = hashLabelValue(value)
+			}
+		}
+	}
+}
+
+// The first 16 hex digits of the value's SHA-256 digest
+func hashLabelValue(value string) string {
+	digest := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(digest[:8])
+}
@@ -0,0 +1,290 @@
+/***************************************************************
+ *
+ * 	Copyright 2021 Derek Weitzel
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *	See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+)
+
+type (
+	// A record of a file transfer XRootD completed, published to the message bus
+	// configured by Monitoring.MessageBusUrl
+	TransferRecord struct {
+		Server       string   `json:"server"`
+		Path         string   `json:"path"`
+		Prefix       string   `json:"prefix"` // The aggregate prefix of the path, as in the transfer metrics
+		User         string   `json:"user,omitempty"`
+		AuthProtocol string   `json:"auth_protocol,omitempty"`
+		DN           string   `json:"dn,omitempty"`
+		Role         string   `json:"role,omitempty"`
+		Org          string   `json:"org,omitempty"`
+		Groups       []string `json:"groups,omitempty"`
+		Project      string   `json:"project,omitempty"`
+		ReadBytes    uint64   `json:"read_bytes"`
+		ReadvBytes   uint64   `json:"readv_bytes"`
+		WriteBytes   uint64   `json:"write_bytes"`
+		Start        int64    `json:"start,omitempty"` // Unix time the file was opened, if known
+		End          int64    `json:"end"`             // Unix time the file was closed
+		Duration     float64  `json:"duration_seconds,omitempty"`
+	}
+
+	// A connection to a message bus
+	messageBus interface {
+		Publish(ctx context.Context, body []byte) error
+		Close() error
+	}
+
+	amqpBus struct {
+		conn     *amqp.Connection
+		channel  *amqp.Channel
+		confirms chan amqp.Confirmation
+		exchange string
+		topic    string
+	}
+)
+
+var (
+	TransferRecordsPublished = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pelican_transfer_records_published_total",
+		Help: "The number of transfer records published to the message bus",
+	})
+
+	TransferRecordsDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pelican_transfer_records_dropped_total",
+		Help: "The number of transfer records dropped because the queue to the message bus was full",
+	})
+
+	// The queue of records to publish; nil when the export is disabled
+	transferRecords atomic.Pointer[chan TransferRecord]
+
+	// The delay before reconnecting to the message bus grows up to this value
+	transferRecordMaxRetryDelay = time.Minute
+	transferRecordMinRetryDelay = time.Second
+)
+
+// Build the transfer record of a closed file from its monitoring records; user is nil
+// if XRootD didn't report the user of the transfer
+func newTransferRecord(xfer FileRecord, user *UserRecord, readBytes, readvBytes, writeBytes uint64, end time.Time) TransferRecord {
+	record := TransferRecord{
+		Server:     param.Server_Hostname.GetString(),
+		Path:       xfer.LFN,
+		Prefix:     xfer.Path,
+		ReadBytes:  readBytes,
+		ReadvBytes: readvBytes,
+		WriteBytes: writeBytes,
+		End:        end.Unix(),
+	}
+	if !xfer.OpenTime.IsZero() {
+		record.Start = xfer.OpenTime.Unix()
+		record.Duration = end.Sub(xfer.OpenTime).Seconds()
+	}
+	if user != nil {
+		record.User = user.User
+		record.AuthProtocol = user.AuthenticationProtocol
+		record.DN = user.DN
+		record.Role = user.Role
+		record.Org = user.Org
+		record.Groups = user.Groups
+		record.Project = user.Project
+	}
+	return record
+}
+
+// Returns true if transfer records are exported to a message bus
+func transferRecordsEnabled() bool {
+	return transferRecords.Load() != nil
+}
+
+// Queue a transfer record for publishing without blocking the monitoring packet
+// handling; the record is dropped if the queue is full
+func queueTransferRecord(record TransferRecord) {
+	queue := transferRecords.Load()
+	if queue == nil {
+		return
+	}
+	select {
+	case *queue <- record:
+	default:
+		TransferRecordsDropped.Inc()
+	}
+}
+
+// Connect to the AMQP broker at Monitoring.MessageBusUrl.  The broker confirms each
+// published message, so a record is only counted as published once the broker has it.
+func dialAMQPBus(busUrl *url.URL) (messageBus, error) {
+	amqpUrl := *busUrl
+	if passwordFile := param.Monitoring_MessageBusPasswordFile.GetString(); passwordFile != "" {
+		password, err := os.ReadFile(passwordFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read Monitoring.MessageBusPasswordFile")
+		}
+		amqpUrl.User = url.UserPassword(amqpUrl.User.Username(), strings.TrimSpace(string(password)))
+	}
+	conn, err := amqp.DialConfig(amqpUrl.String(), amqp.Config{
+		TLSClientConfig: config.GetTransport().TLSClientConfig,
+		Heartbeat:       10 * time.Second,
+	})
+	if err != nil {
+		return nil, err
+	}
+	channel, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err = channel.Confirm(false); err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "broker does not support publisher confirms")
+	}
+	return &amqpBus{
+		conn:     conn,
+		channel:  channel,
+		confirms: channel.NotifyPublish(make(chan amqp.Confirmation, 1)),
+		exchange: param.Monitoring_MessageBusExchange.GetString(),
+		topic:    param.Monitoring_MessageBusTopic.GetString(),
+	}, nil
+}
+
+func (bus *amqpBus) Publish(ctx context.Context, body []byte) error {
+	err := bus.channel.Publish(bus.exchange, bus.topic, false, false, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    time.Now(),
+		Body:         body,
+	})
+	if err != nil {
+		return err
+	}
+	select {
+	case confirm, ok := <-bus.confirms:
+		if !ok {
+			return errors.New("connection to the message bus was closed")
+		} else if !confirm.Ack {
+			return errors.New("message bus rejected the transfer record")
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (bus *amqpBus) Close() error {
+	return bus.conn.Close()
+}
+
+// Publish the queued transfer records until the context is cancelled, (re)connecting
+// to the message bus as needed.  A record that fails to publish is retried on a new
+// connection, so records are only lost if the queue overflows.
+func exportTransferRecords(ctx context.Context, queue <-chan TransferRecord, connect func() (messageBus, error)) {
+	var bus messageBus
+	defer func() {
+		if bus != nil {
+			bus.Close()
+		}
+	}()
+	retryDelay := transferRecordMinRetryDelay
+	for {
+		var record TransferRecord
+		select {
+		case <-ctx.Done():
+			return
+		case record = <-queue:
+		}
+		body, err := json.Marshal(record)
+		if err != nil {
+			log.Errorln("Failed to encode the transfer record:", err)
+			continue
+		}
+		for {
+			if bus == nil {
+				if bus, err = connect(); err != nil {
+					bus = nil
+					log.Warningf("Failed to connect to the message bus; retrying in %s: %v", retryDelay, err)
+					select {
+					case <-ctx.Done():
+						return
+					case <-time.After(retryDelay):
+					}
+					retryDelay = min(2*retryDelay, transferRecordMaxRetryDelay)
+					continue
+				}
+				retryDelay = transferRecordMinRetryDelay
+			}
+			if err = bus.Publish(ctx, body); err == nil {
+				TransferRecordsPublished.Inc()
+				break
+			} else if ctx.Err() != nil {
+				return
+			}
+			log.Warningln("Failed to publish a transfer record to the message bus; reconnecting:", err)
+			bus.Close()
+			bus = nil
+		}
+	}
+}
+
+// Start exporting transfer records to the message bus at Monitoring.MessageBusUrl,
+// if configured
+func launchTransferRecordExport(ctx context.Context, egrp *errgroup.Group) error {
+	busUrlStr := param.Monitoring_MessageBusUrl.GetString()
+	if busUrlStr == "" {
+		return nil
+	}
+	busUrl, err := url.Parse(busUrlStr)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse Monitoring.MessageBusUrl")
+	}
+	var connect func() (messageBus, error)
+	switch busUrl.Scheme {
+	case "amqp", "amqps":
+		connect = func() (messageBus, error) { return dialAMQPBus(busUrl) }
+	default:
+		return errors.Errorf("unsupported message bus %q in Monitoring.MessageBusUrl; the scheme must be amqp or amqps", busUrl.Scheme)
+	}
+	queueSize := param.Monitoring_MessageBusQueueSize.GetInt()
+	if queueSize <= 0 {
+		return errors.New("Monitoring.MessageBusQueueSize must be positive")
+	}
+
+	queue := make(chan TransferRecord, queueSize)
+	transferRecords.Store(&queue)
+	egrp.Go(func() error {
+		defer transferRecords.Store(nil)
+		exportTransferRecords(ctx, queue, connect)
+		return nil
+	})
+	log.Infof("Exporting transfer records to the message bus at %s://%s", busUrl.Scheme, busUrl.Host)
+	return nil
+}
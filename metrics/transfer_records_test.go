@@ -0,0 +1,149 @@
+/***************************************************************
+ *
+ * 	Copyright 2021 Derek Weitzel
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing, software
+ *  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *	See the License for the specific language governing permissions and
+ *  limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMessageBus struct {
+	mutex     *sync.Mutex
+	published *[][]byte
+	failNext  *int
+}
+
+func (bus fakeMessageBus) Publish(ctx context.Context, body []byte) error {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	if *bus.failNext > 0 {
+		*bus.failNext--
+		return errors.New("publish failed")
+	}
+	*bus.published = append(*bus.published, body)
+	return nil
+}
+
+func (bus fakeMessageBus) Close() error {
+	return nil
+}
+
+func TestExportTransferRecords(t *testing.T) {
+	oldMin, oldMax := transferRecordMinRetryDelay, transferRecordMaxRetryDelay
+	transferRecordMinRetryDelay, transferRecordMaxRetryDelay = time.Millisecond, 5*time.Millisecond
+	t.Cleanup(func() { transferRecordMinRetryDelay, transferRecordMaxRetryDelay = oldMin, oldMax })
+
+	mutex := &sync.Mutex{}
+	published := [][]byte{}
+	failPublish := 1
+	connects := 0
+	failConnect := 2
+	connect := func() (messageBus, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		connects++
+		if failConnect > 0 {
+			failConnect--
+			return nil, errors.New("connection refused")
+		}
+		return fakeMessageBus{mutex: mutex, published: &published, failNext: &failPublish}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	queue := make(chan TransferRecord, 10)
+	done := make(chan struct{})
+	go func() {
+		exportTransferRecords(ctx, queue, connect)
+		close(done)
+	}()
+
+	queue <- TransferRecord{Path: "/foo/a", ReadBytes: 10}
+	queue <- TransferRecord{Path: "/foo/b", WriteBytes: 20}
+	require.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(published) == 2
+	}, 5*time.Second, time.Millisecond)
+	cancel()
+	<-done
+
+	// Two failed connections, one that failed to publish, and the one that succeeded
+	assert.Equal(t, 4, connects)
+	records := []TransferRecord{}
+	for _, body := range published {
+		record := TransferRecord{}
+		require.NoError(t, json.Unmarshal(body, &record))
+		records = append(records, record)
+	}
+	assert.Equal(t, "/foo/a", records[0].Path)
+	assert.Equal(t, uint64(10), records[0].ReadBytes)
+	assert.Equal(t, "/foo/b", records[1].Path)
+	assert.Equal(t, uint64(20), records[1].WriteBytes)
+}
+
+func TestTransferRecordFromClosePacket(t *testing.T) {
+	fileId := uint32(4242)
+	userId := uint32(77)
+	sid := int64(143152967831384)
+
+	queue := make(chan TransferRecord, 1)
+	transferRecords.Store(&queue)
+	t.Cleanup(func() { transferRecords.Store(nil) })
+
+	transfers.DeleteAll()
+	sessions.DeleteAll()
+	sessions.Set(UserId{Id: userId, Stod: mockStod}, UserRecord{AuthenticationProtocol: "ztn", User: "alice", Org: "osg", Project: "proj"}, 0)
+
+	openPacket, err := mockFileOpenPacket(0, fileId, userId, sid, "/foo/bar/file.txt")
+	require.NoError(t, err)
+	require.NoError(t, HandlePacket(openPacket))
+	closePacket, err := mockFileClosePacket(1, fileId, sid, mockStatOps(1, 1, 1, 1), 100, 50, 7)
+	require.NoError(t, err)
+	require.NoError(t, HandlePacket(closePacket))
+
+	require.Len(t, queue, 1)
+	record := <-queue
+	assert.Equal(t, "/foo/bar/file.txt", record.Path)
+	assert.Equal(t, "alice", record.User)
+	assert.Equal(t, "ztn", record.AuthProtocol)
+	assert.Equal(t, "osg", record.Org)
+	assert.Equal(t, "proj", record.Project)
+	assert.Equal(t, uint64(100), record.ReadBytes)
+	assert.Equal(t, uint64(50), record.ReadvBytes)
+	assert.Equal(t, uint64(7), record.WriteBytes)
+	assert.NotZero(t, record.Start)
+	assert.GreaterOrEqual(t, record.End, record.Start)
+
+	t.Run("full-queue-drops-records", func(t *testing.T) {
+		queue <- TransferRecord{}
+		droppedBefore := testutil.ToFloat64(TransferRecordsDropped)
+		queueTransferRecord(TransferRecord{Path: "/dropped"})
+		assert.Len(t, queue, 1)
+		assert.Equal(t, "", (<-queue).Path)
+		assert.Equal(t, 1.0, testutil.ToFloat64(TransferRecordsDropped)-droppedBefore)
+	})
+}
@@ -0,0 +1,52 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	PelicanWorkQueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pelican_work_queue_depth",
+		Help: "The number of tasks waiting in a work queue",
+	}, []string{"queue"})
+
+	PelicanWorkQueueActiveWorkers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pelican_work_queue_active_workers",
+		Help: "The number of workers of a work queue currently running a task",
+	}, []string{"queue"})
+
+	PelicanWorkQueueTasks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_work_queue_tasks_total",
+		Help: "The total number of tasks handled by a work queue, by result (success, failure, expired, or rejected)",
+	}, []string{"queue", "result"})
+
+	PelicanWorkQueueRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pelican_work_queue_retries_total",
+		Help: "The total number of times a work queue retried a failed task",
+	}, []string{"queue"})
+
+	PelicanWorkQueueTaskDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pelican_work_queue_task_duration_seconds",
+		Help:    "The time a work queue spent on each task, including retries",
+		Buckets: prometheus.ExponentialBuckets(0.01, 4, 8),
+	}, []string{"queue"})
+)
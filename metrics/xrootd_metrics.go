@@ -31,6 +31,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/jellydator/ttlcache/v3"
@@ -45,7 +46,8 @@ import (
 type (
 	SummaryStatType string
 	UserId          struct {
-		Id uint32
+		Id   uint32
+		Stod int32 // The start time of the XRootD daemon that assigned the ID
 	}
 
 	// userid as in XRootD message info field
@@ -55,6 +57,7 @@ type (
 		Pid  int
 		Sid  int
 		Host string
+		Stod int32 // The start time of the XRootD daemon the client logged in to; not part of the userid
 	}
 
 	UserRecord struct {
@@ -65,15 +68,20 @@ type (
 		Org                    string
 		Groups                 []string
 		Project                string
+		Country                string    // The ISO code of the client's country, if Monitoring.GeoIPLabels is set
+		ASN                    string    // The client's autonomous system number, if Monitoring.GeoIPLabels is set
+		XrdUserId              XrdUserId // The ID the client logged in with, from its user login packet
 	}
 
 	FileId struct {
-		Id uint32
+		Id   uint32
+		Stod int32 // The start time of the XRootD daemon that assigned the ID
 	}
 
 	FileRecord struct {
 		UserId     UserId
 		Path       string
+		LFN        string // The full path of the file, unlike Path, which is its aggregate prefix
 		ReadOps    uint32
 		ReadvOps   uint32
 		WriteOps   uint32
@@ -81,12 +89,21 @@ type (
 		ReadBytes  uint64
 		ReadvBytes uint64
 		WriteBytes uint64
+		OpenTime   time.Time // When the file was opened; zero if unknown
+		OpenTOD    int64     // The UNIX time of the start of the monitoring window the file was opened in; zero if unknown
 	}
 
 	PathList struct {
 		Paths []string
 	}
 
+	// A file closed by XRootD, as reported by the monitoring stream
+	ClosedFile struct {
+		LFN        string
+		ReadBytes  uint64 // Includes the bytes of vector reads
+		WriteBytes uint64
+	}
+
 	XrdXrootdMonHeader struct {
 		Code byte   // = | d | f | g | i | p | r | t | u | x
 		Pseq byte   // packet sequence
@@ -136,6 +153,10 @@ type (
 		Write int64 // Bytes written to file
 	}
 
+	XrdXrootdMonFileDSC struct {
+		Hdr XrdXrootdMonFileHdr // Header with recType == isDisc; its UserId is the disconnected session
+	}
+
 	XrdXrootdMonFileXFR struct {
 		Hdr XrdXrootdMonFileHdr // Header with recType == isXfr
 		Xfr XrdXrootdMonStatXFR
@@ -166,6 +187,14 @@ type (
 		// Ssq XrdXrootdMonStatSSQ // OPTIONAL, not implemented here yet
 	}
 
+	// An entry of an r-stream (redirect) packet
+	XrdXrootdMonRedir struct {
+		Type   byte   // Entry type in the high nibble; the redirected request's operation in the low nibble
+		Dent   byte   // Number of 8-byte words following the entry that hold its target
+		Port   uint16 // Port of the redirect target
+		Dictid uint32 // Dictionary ID of the redirected user
+	}
+
 	XrdXrootdMonGS struct {
 		Hdr  XrdXrootdMonHeader
 		TBeg int   // UNIX time of first entry
@@ -174,25 +203,41 @@ type (
 	}
 
 	CacheGS struct {
-		AccessCnt   uint32 `json:"access_cnt"`
-		AttachT     int64  `json:"attach_t"`
-		ByteBypass  int64  `json:"b_bypass"`
-		ByteHit     int64  `json:"b_hit"`
-		ByteMiss    int64  `json:"b_miss"`
-		BlkSize     int    `json:"blk_size"`
-		DetachT     int64  `json:"detach_t"`
-		Event       string `json:"event"`
-		Lfn         string `json:"lfn"`
-		NBlocks     int    `json:"n_blks"`
-		NBlocksDone int    `json:"n_blks_done"`
-		NCksErrs    int    `json:"n_cks_errs"`
-		Size        int64  `json:"size"`
+		AccessCnt    uint32 `json:"access_cnt"`
+		AttachT      int64  `json:"attach_t"`
+		ByteBypass   int64  `json:"b_bypass"`
+		ByteHit      int64  `json:"b_hit"`
+		ByteMiss     int64  `json:"b_miss"`
+		BytePrefetch int64  `json:"b_prefetch"`
+		ByteToDisk   int64  `json:"b_todisk"`
+		BlkSize      int    `json:"blk_size"`
+		DetachT      int64  `json:"detach_t"`
+		Event        string `json:"event"`
+		Lfn          string `json:"lfn"`
+		NBlocks      int    `json:"n_blks"`
+		NBlocksDone  int    `json:"n_blks_done"`
+		NCksErrs     int    `json:"n_cks_errs"`
+		Size         int64  `json:"size"`
+	}
+
+	// A cache_stats record of the cache's g-stream, with the totals of the whole cache
+	// since XRootD started
+	CacheStatsGS struct {
+		Event         string `json:"event"`
+		ByteHit       int64  `json:"b_hit"`
+		ByteMiss      int64  `json:"b_miss"`
+		BytePrefetch  int64  `json:"b_prefetch"`
+		NEvictions    int64  `json:"n_evict"` // Files purged from the cache to free up space
+		ByteEvictions int64  `json:"b_evict"`
 	}
 
 	CacheAccessStat struct {
-		Hit    int64
-		Miss   int64
-		Bypass int64
+		Hit      int64
+		Miss     int64
+		Bypass   int64
+		ToDisk   int64
+		Prefetch int64
+		CksErrs  int64
 	}
 
 	SummaryPathStat struct {
@@ -207,6 +252,20 @@ type (
 		Stats []SummaryPathStat `xml:"stats"`
 	}
 
+	SummaryOssSpaceStat struct {
+		Id    string `xml:"id,attr"`
+		Name  string `xml:"name"` // The name of the oss space, e.g. "public"
+		Free  int    `xml:"free"` // Kilobytes available
+		Total int    `xml:"tot"`  // Kilobytes allocated
+		Usage int    `xml:"usg"`  // Kilobytes used, or -1 if unknown
+		Quota int    `xml:"qta"`  // Kilobytes of quota, or -1 if there's none
+	}
+
+	SummaryOssSpace struct {
+		Idx   int                   `xml:",chardata"`
+		Stats []SummaryOssSpaceStat `xml:"stats"`
+	}
+
 	SummaryCacheStore struct {
 		Size int `xml:"size"`
 		Used int `xml:"used"`
@@ -220,6 +279,20 @@ type (
 		Wq   int `xml:"wq"`
 	}
 
+	SummaryXrootdLogins struct {
+		Num             int `xml:"num"` // Login attempts
+		AuthFailures    int `xml:"af"`  // Authentication failures
+		Authenticated   int `xml:"au"`  // Successful authenticated logins
+		Unauthenticated int `xml:"ua"`  // Successful unauthenticated logins
+	}
+
+	SummaryOfsTpc struct {
+		Granted int `xml:"grnt"` // Third-party copies allowed
+		Denied  int `xml:"deny"` // Third-party copies denied
+		Errors  int `xml:"err"`  // Third-party copies that failed
+		Expired int `xml:"exp"`  // Third-party copies whose authorization expired
+	}
+
 	SummaryStat struct {
 		Id      SummaryStatType    `xml:"id,attr"`
 		Total   int                `xml:"tot"`
@@ -228,14 +301,35 @@ type (
 		Threads int                `xml:"threads"`
 		Idle    int                `xml:"idle"`
 		Paths   SummaryPath        `xml:"paths"` // For Oss Summary Data
+		Space   SummaryOssSpace    `xml:"space"` // For Oss Summary Data
 		Store   SummaryCacheStore  `xml:"store"`
 		Memory  SummaryCacheMemory `xml:"mem"`
+		// For Xrootd and Ofs Summary Data; all of these are totals since start-up
+		Errors    int `xml:"err"`
+		Redirects int `xml:"rdr"`
+		Delays    int `xml:"dly"`
+		// For Xrootd Summary Data
+		Logins SummaryXrootdLogins `xml:"lgn"`
+		// For Ofs Summary Data
+		OpenRead  int           `xml:"opr"` // Files open for reading
+		OpenWrite int           `xml:"opw"` // Files open for writing
+		Handles   int           `xml:"han"` // Active file handles
+		Tpc       SummaryOfsTpc `xml:"tpc"`
 	}
 
 	SummaryStatistics struct {
-		Version string        `xml:"ver,attr"`
-		Program string        `xml:"pgm,attr"`
-		Stats   []SummaryStat `xml:"stats"`
+		Version   string        `xml:"ver,attr"`
+		Program   string        `xml:"pgm,attr"`
+		StartTime int64         `xml:"tos,attr"`
+		Stats     []SummaryStat `xml:"stats"`
+	}
+
+	// The totals an XRootD daemon reported in its previous summary and cache_stats record
+	instanceTotals struct {
+		link  SummaryStat
+		xrd   SummaryStat
+		ofs   SummaryStat
+		cache CacheStatsGS
 	}
 )
 
@@ -249,6 +343,16 @@ const (
 	isDisc
 )
 
+// XrdXrootdMonRedir entry types
+// Ref: https://github.com/xrootd/xrootd/blob/f3b2e86b9b80bb35f97dd4ad30c4cd5904902a4c/src/XrdXrootd/XrdXrootdMonData.hh#L228
+const (
+	XROOTD_MON_REDTIME  = byte(0x00) // Timing mark
+	XROOTD_MON_REDIRECT = byte(0x80) // Redirect to another server
+	XROOTD_MON_REDLOCAL = byte(0x90) // Redirect within the local cluster
+	XROOTD_MON_REDSID   = byte(0xf0) // Server identification
+	XROOTD_MON_REDTYPE  = byte(0xf0) // Mask of the entry type
+)
+
 const (
 	XROOTD_MON_PIDSHFT = int64(56)
 	XROOTD_MON_PIDMASK = int64(0xff)
@@ -256,10 +360,12 @@ const (
 
 // Summary data types
 const (
-	LinkStat  SummaryStatType = "link"  // https://xrootd.slac.stanford.edu/doc/dev55/xrd_monitoring.htm#_Toc99653739
-	SchedStat SummaryStatType = "sched" // https://xrootd.slac.stanford.edu/doc/dev55/xrd_monitoring.htm#_Toc99653745
-	OssStat   SummaryStatType = "oss"   // https://xrootd.slac.stanford.edu/doc/dev55/xrd_monitoring.htm#_Toc99653741
-	CacheStat SummaryStatType = "cache" // https://xrootd.slac.stanford.edu/doc/dev55/xrd_monitoring.htm#_Toc99653733
+	LinkStat  SummaryStatType = "link"   // https://xrootd.slac.stanford.edu/doc/dev55/xrd_monitoring.htm#_Toc99653739
+	SchedStat SummaryStatType = "sched"  // https://xrootd.slac.stanford.edu/doc/dev55/xrd_monitoring.htm#_Toc99653745
+	OssStat   SummaryStatType = "oss"    // https://xrootd.slac.stanford.edu/doc/dev55/xrd_monitoring.htm#_Toc99653741
+	CacheStat SummaryStatType = "cache"  // https://xrootd.slac.stanford.edu/doc/dev55/xrd_monitoring.htm#_Toc99653733
+	XrdStat   SummaryStatType = "xrootd" // https://xrootd.slac.stanford.edu/doc/dev55/xrd_monitoring.htm (the xrootd protocol)
+	OfsStat   SummaryStatType = "ofs"    // https://xrootd.slac.stanford.edu/doc/dev55/xrd_monitoring.htm (the file system layer)
 )
 
 var (
@@ -268,47 +374,159 @@ var (
 		Help: "The total number of monitoring UDP packets received",
 	})
 
+	PacketsDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "xrootd_monitoring_packets_dropped",
+		Help: "The total number of monitoring UDP packets dropped before being handled because the packet spool or queue was full",
+	})
+
+	PacketsForwarded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "xrootd_monitoring_packets_forwarded",
+		Help: "The total number of monitoring UDP packets forwarded to each destination of Monitoring.ForwardDestinations",
+	}, []string{"destination"})
+
+	PacketsForwardErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "xrootd_monitoring_packet_forward_errors",
+		Help: "The total number of monitoring UDP packets that failed to be forwarded to each destination of Monitoring.ForwardDestinations",
+	}, []string{"destination"})
+
+	SessionsClosed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "xrootd_sessions_closed_total",
+		Help: "The total number of client sessions XRootD reported as disconnected, by authentication protocol",
+	}, []string{"instance", "ap"})
+
+	PacketParseErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "xrootd_monitoring_packet_parse_errors",
+		Help: "The total number of monitoring UDP packets that failed to be parsed, by stream code",
+	}, []string{"stream"})
+
 	TransferReadvSegs = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "xrootd_transfer_readv_segments_count",
 		Help: "Number of segments in readv operations",
-	}, []string{"path", "ap", "dn", "role", "org", "proj"})
+	}, []string{"instance", "path", "ap", "dn", "role", "org", "proj", "country", "asn"})
 
 	TransferOps = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "xrootd_transfer_operations_count",
 		Help: "Number of transfer operations performed",
-	}, []string{"path", "ap", "dn", "role", "org", "proj", "type"})
+	}, []string{"instance", "path", "ap", "dn", "role", "org", "proj", "country", "asn", "type"})
 
 	TransferBytes = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "xrootd_transfer_bytes",
 		Help: "Bytes of transfers",
-	}, []string{"path", "ap", "dn", "role", "org", "proj", "type"})
+	}, []string{"instance", "path", "ap", "dn", "role", "org", "proj", "country", "asn", "type"})
 
 	Threads = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "xrootd_sched_thread_count",
 		Help: "Number of scheduler threads",
-	}, []string{"state"})
+	}, []string{"instance", "state"})
 
-	Connections = promauto.NewCounter(prometheus.CounterOpts{
+	Connections = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "xrootd_server_connection_count",
 		Help: "Aggregate number of server connections",
-	})
+	}, []string{"instance"})
 
 	BytesXfer = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "xrootd_server_bytes",
 		Help: "Number of bytes read into the server",
-	}, []string{"direction"})
+	}, []string{"instance", "direction"})
 
 	StorageVolume = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "xrootd_storage_volume_bytes",
 		Help: "Storage volume usage on the server",
-	}, []string{"ns", "type", "server_type"}) // type: total/free; server_type: origin/cache
+	}, []string{"instance", "ns", "type", "server_type"}) // type: total/free; server_type: origin/cache
+
+	CacheDiskSpace = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "xrootd_cache_disk_space_bytes",
+		Help: "Disk space of the cache and of the server's oss spaces",
+	}, []string{"instance", "space", "type"}) // type: total/free/reserved
 
 	CacheAccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "xrootd_cache_access_bytes",
 		Help: "Number of bytes the data requested is in the cache or not",
-	}, []string{"path", "type"}) // type: hit/miss/bypass
-
-	lastStats SummaryStat
+	}, []string{"instance", "path", "type"}) // type: hit/miss/bypass
+
+	CacheDiskWriteBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "xrootd_cache_disk_write_bytes_total",
+		Help: "Number of bytes the cache wrote to its disk",
+	}, []string{"instance", "path"})
+
+	CachePrefetchBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "xrootd_cache_prefetch_bytes_total",
+		Help: "Number of bytes the cache prefetched from the origin ahead of reads",
+	}, []string{"instance", "path"})
+
+	CacheHitRatio = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "xrootd_cache_hit_ratio",
+		Help: "Fraction of the bytes read through the cache that were already on its disk, between its last two cache_stats records",
+	}, []string{"instance"})
+
+	CacheServerPrefetchBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "xrootd_cache_server_prefetch_bytes_total",
+		Help: "Number of bytes the whole cache prefetched from the origins ahead of reads",
+	}, []string{"instance"})
+
+	CacheEvictions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "xrootd_cache_evictions_total",
+		Help: "Number of files the cache purged from its disk to free up space",
+	}, []string{"instance"})
+
+	CacheEvictedBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "xrootd_cache_evicted_bytes_total",
+		Help: "Number of bytes the cache purged from its disk to free up space",
+	}, []string{"instance"})
+
+	CachePgReadChecksumErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "xrootd_cache_pgread_checksum_errors_total",
+		Help: "Number of pages read by the cache whose checksum didn't match",
+	}, []string{"instance", "path"})
+
+	ActiveTransfers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "xrootd_active_transfers",
+		Help: "Number of files XRootD has open, by path prefix",
+	}, []string{"instance", "path"})
+
+	Redirects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "xrootd_redirects_total",
+		Help: "Number of requests XRootD redirected, by the redirect target",
+	}, []string{"instance", "host", "path"})
+
+	ServerErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "xrootd_server_errors_total",
+		Help: "Number of requests that ended with an error, by the XRootD layer reporting them",
+	}, []string{"instance", "layer"}) // layer: xrootd/ofs
+
+	ServerRedirects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "xrootd_server_redirects_total",
+		Help: "Number of requests that were redirected, by the XRootD layer reporting them",
+	}, []string{"instance", "layer"})
+
+	ServerDelays = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "xrootd_server_delays_total",
+		Help: "Number of requests the client was told to retry later, e.g. when throttled, by the XRootD layer reporting them",
+	}, []string{"instance", "layer"})
+
+	ServerLogins = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "xrootd_server_logins_total",
+		Help: "Number of client logins, by their result",
+	}, []string{"instance", "result"}) // result: authenticated/unauthenticated/auth_failed
+
+	OfsOpenFiles = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "xrootd_ofs_open_files",
+		Help: "Number of files the file system layer has open, by mode",
+	}, []string{"instance", "mode"}) // mode: read/write
+
+	OfsFileHandles = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "xrootd_ofs_file_handles",
+		Help: "Number of active file handles of the file system layer",
+	}, []string{"instance"})
+
+	OfsTpcRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "xrootd_ofs_tpc_requests_total",
+		Help: "Number of third-party copy requests, by their result",
+	}, []string{"instance", "result"}) // result: granted/denied/error/expired
+
+	// The totals of the previous summaries and cache_stats records of each XRootD daemon,
+	// by instance, to turn them into counter increments
+	lastTotals = map[string]*instanceTotals{}
 
 	// Maps the connection identifier with a user record
 	sessions = ttlcache.New[UserId, UserRecord](ttlcache.WithTTL[UserId, UserRecord](24 * time.Hour))
@@ -318,8 +536,74 @@ var (
 	// Maps a file identifier with a file record
 	transfers    = ttlcache.New[FileId, FileRecord](ttlcache.WithTTL[FileId, FileRecord](24 * time.Hour))
 	monitorPaths []PathList
+	// The token claim holding the project of a session, from Monitoring.ProjectClaim
+	projectClaim string
+
+	// The token claims XRootD reports in its token records, by the claim name
+	tokenRecordClaims = map[string]string{
+		"sub":         "s",
+		"iss":         "o",
+		"groups":      "g",
+		"wlcg.groups": "g",
+	}
+
+	fileCloseHandler atomic.Pointer[func(ClosedFile)]
 )
 
+// Count the open files in ActiveTransfers: a file record is inserted into the transfers
+// cache when the file is opened and removed when it's closed or its record expires
+func init() {
+	transfers.OnInsertion(func(_ context.Context, item *ttlcache.Item[FileId, FileRecord]) {
+		ActiveTransfers.WithLabelValues(instanceLabel(int64(item.Key().Stod)), getActiveTransferPath(item.Value())).Inc()
+	})
+	transfers.OnEviction(func(_ context.Context, _ ttlcache.EvictionReason, item *ttlcache.Item[FileId, FileRecord]) {
+		ActiveTransfers.WithLabelValues(instanceLabel(int64(item.Key().Stod)), getActiveTransferPath(item.Value())).Dec()
+	})
+}
+
+// The value of the instance label of the metrics of the XRootD daemon that started at the
+// given time.  XRootD reports its start time in the header of its monitoring packets (Stod)
+// and in its summaries (tos), which tells apart the daemons reporting to the same port,
+// like the origin and the cache of a server running both.
+func instanceLabel(startTime int64) string {
+	return strconv.FormatInt(startTime, 10)
+}
+
+// The totals the XRootD daemon of an instance reported before; zero if it hasn't
+func getInstanceTotals(instance string) *instanceTotals {
+	totals, ok := lastTotals[instance]
+	if !ok {
+		totals = &instanceTotals{}
+		lastTotals[instance] = totals
+	}
+	return totals
+}
+
+func getActiveTransferPath(record FileRecord) string {
+	if record.Path == "" {
+		return "/"
+	}
+	return record.Path
+}
+
+// Record the opening of a file.  A record left for the same file ID is replaced, as
+// XRootD reuses the IDs of closed files, so it leaves ActiveTransfers first.
+func setOpenedFile(fileId FileId, record FileRecord) {
+	transfers.Delete(fileId)
+	transfers.Set(fileId, record, ttlcache.DefaultTTL)
+}
+
+// Set the function called with each file XRootD closes whose path is known; nil
+// removes it.  The handler is called while processing the monitoring packets, so
+// it must not block.
+func SetFileCloseHandler(handler func(ClosedFile)) {
+	if handler == nil {
+		fileCloseHandler.Store(nil)
+		return
+	}
+	fileCloseHandler.Store(&handler)
+}
+
 // Set up listening and parsing xrootd monitoring UDP packets into prometheus
 //
 // The `ctx` is the context for listening to server shutdown event in order to cleanup internal cache eviction
@@ -328,6 +612,17 @@ func ConfigureMonitoring(ctx context.Context, egrp *errgroup.Group) (int, error)
 	for _, monpath := range param.Monitoring_AggregatePrefixes.GetStringSlice() {
 		monitorPaths = append(monitorPaths, PathList{Paths: strings.Split(path.Clean(monpath), "/")})
 	}
+	projectClaim = param.Monitoring_ProjectClaim.GetString()
+	configureGeoIP()
+	if err := configureTransferLabels(); err != nil {
+		return -1, err
+	}
+	if err := configureMetricRelabeling(); err != nil {
+		return -1, err
+	}
+	if err := configureTransferHistograms(); err != nil {
+		return -1, err
+	}
 
 	lower := param.Monitoring_PortLower.GetInt()
 	higher := param.Monitoring_PortHigher.GetInt()
@@ -355,6 +650,11 @@ func ConfigureMonitoring(ctx context.Context, egrp *errgroup.Group) (int, error)
 		return -1, err
 	}
 
+	if err := launchTransferRecordExport(ctx, egrp); err != nil {
+		conn.Close()
+		return -1, err
+	}
+
 	// Start ttl cache automatic eviction of expired items
 	go sessions.Start()
 	go userids.Start()
@@ -371,20 +671,62 @@ func ConfigureMonitoring(ctx context.Context, egrp *errgroup.Group) (int, error)
 		return nil
 	})
 
+	spool, err := openConfiguredPacketSpool()
+	if err != nil {
+		log.Errorln("Failed to open the monitoring packet spool; packets will be handled as they're received:", err)
+	}
+	var spooled chan struct{}
+	if spool != nil {
+		spooled = make(chan struct{}, 1)
+		if pending := spool.Pending(); pending > 0 {
+			log.Infof("Replaying %d bytes of monitoring packets spooled before the restart", pending)
+		}
+		spooled <- struct{}{}
+		go handleSpooledPackets(ctx, spool, spooled)
+	}
+
+	// Packets that aren't spooled are queued in memory so the handling doesn't hold up
+	// reading from the socket
+	queued := make(chan []byte, packetQueueSize)
+	go handleQueuedPackets(ctx, queued)
+
+	forwarder := openConfiguredPacketForwarder()
+
 	go func() {
+		defer TrackGoroutine("xrootd-monitoring")()
 		var buf [65536]byte
 		for {
-			// TODO: actually parse the UDP packets
 			plen, _, err := conn.ReadFromUDP(buf[:])
 			if errors.Is(err, net.ErrClosed) {
+				if spool != nil {
+					spool.Close()
+				}
+				if forwarder != nil {
+					forwarder.Close()
+				}
 				return
 			} else if err != nil {
 				log.Errorln("Failed to read from UDP connection", err)
 				continue
 			}
 			PacketsReceived.Inc()
-			if err = HandlePacket(buf[:plen]); err != nil {
-				log.Errorln("Failed to handle packet:", err)
+			if forwarder != nil {
+				forwarder.Forward(buf[:plen])
+			}
+			if spool != nil {
+				if err = spool.Append(buf[:plen]); err == nil {
+					select {
+					case spooled <- struct{}{}:
+					default:
+					}
+					continue
+				}
+				log.Errorln("Failed to spool packet:", err)
+			}
+			select {
+			case queued <- bytes.Clone(buf[:plen]):
+			default:
+				PacketsDropped.Inc()
 			}
 		}
 	}()
@@ -486,18 +828,39 @@ func ParseXrdUserId(userid string) (xrdUserId XrdUserId, err error) {
 	xrdUserId.User = protUserIdInfo[1][:lastIdx]
 	xrdUserId.Pid = pid
 	xrdUserId.Sid = sid
-	xrdUserId.Host = string(sidAtHostname[1])
+	xrdUserId.Host = sidAtHostnameInfo[1]
 	return
 }
 
+// Get the project from the fields of a token record, given the name of the claim holding
+// it.  Claims XRootD doesn't have a key for are looked up by their name, in case the
+// record was extended with them.  Of a list of groups, the first is the project.
+func getTokenRecordProject(fields map[string]string, claim string) string {
+	if claim == "" {
+		return ""
+	}
+	key, ok := tokenRecordClaims[claim]
+	if !ok {
+		key = claim
+	}
+	value := strings.TrimSpace(fields[key])
+	if key == "g" {
+		value, _, _ = strings.Cut(value, " ")
+		value = strings.TrimPrefix(value, "/")
+	}
+	return value
+}
+
 func ParseTokenAuth(tokenauth string) (userId UserId, record UserRecord, err error) {
 	record.AuthenticationProtocol = "ztn"
 	foundUc := false
+	fields := make(map[string]string)
 	for _, pair := range strings.Split(tokenauth, "&") {
 		keyVal := strings.SplitN(pair, "=", 2)
 		if len(keyVal) != 2 {
 			continue
 		}
+		fields[keyVal[0]] = keyVal[1]
 		switch keyVal[0] {
 		case "Uc":
 			var id int
@@ -528,9 +891,31 @@ func ParseTokenAuth(tokenauth string) (userId UserId, record UserRecord, err err
 		err = errors.New("The user ID was not provided in the token record")
 		return
 	}
+	record.Project = getTokenRecordProject(fields, projectClaim)
 	return
 }
 
+// Remove the session of a client XRootD reported as disconnected from the caches,
+// instead of leaving it until it expires
+func closeSession(stod int32, disc XrdXrootdMonFileDSC) {
+	userId := UserId{Id: disc.Hdr.UserId, Stod: stod}
+	ap := ""
+	if session := sessions.Get(userId); session != nil {
+		ap = session.Value().AuthenticationProtocol
+		sessions.Delete(userId)
+		// Sessions only known from a token record don't have the ID
+		if xrdUserId := session.Value().XrdUserId; xrdUserId != (XrdUserId{}) {
+			if item := userids.Get(xrdUserId); item != nil && item.Value() == userId {
+				userids.Delete(xrdUserId)
+			}
+		}
+	}
+	if disc.Hdr.RecFlag&0x01 == 0x01 { // XrdXrootdMonFileHdr::forced
+		log.Debugln("MonPacket: Session", userId.Id, "was disconnected by the server")
+	}
+	SessionsClosed.WithLabelValues(instanceLabel(int64(stod)), ap).Inc()
+}
+
 func ParseFileHeader(packet []byte) (XrdXrootdMonFileHdr, error) {
 	if len(packet) < 8 {
 		return XrdXrootdMonFileHdr{}, fmt.Errorf("Passed header of size %v which is below the minimum header size of 8 bytes", len(packet))
@@ -561,7 +946,8 @@ func HandlePacket(packet []byte) error {
 		return HandleSummaryPacket(packet)
 	}
 
-	if len(packet) < 8 {
+	// The 8-byte header is followed by at least the 4-byte dictid
+	if len(packet) < 12 {
 		return errors.New("Packet is too small to be valid XRootD monitoring packet")
 	}
 	var header XrdXrootdMonHeader
@@ -569,6 +955,7 @@ func HandlePacket(packet []byte) error {
 	header.Pseq = packet[1]
 	header.Plen = binary.BigEndian.Uint16(packet[2:4])
 	header.Stod = int32(binary.BigEndian.Uint32(packet[4:8]))
+	instance := instanceLabel(int64(header.Stod))
 
 	// For =, p, and x record-types, this is always 0
 	// For i, T, u, and U , this is a connection ID
@@ -581,14 +968,15 @@ func HandlePacket(packet []byte) error {
 		if len(packet) < 12 {
 			return errors.New("Packet is too small to be valid file-open packet")
 		}
-		fileid := FileId{Id: dictid}
+		fileid := FileId{Id: dictid, Stod: header.Stod}
 		xrdUserId, rest, err := GetSIDRest(packet[12:])
 		if err != nil {
 			return errors.Wrapf(err, "Failed to parse XRootD monitoring packet")
 		}
+		xrdUserId.Stod = header.Stod
 		path := computePrefix(rest, monitorPaths)
 		if useridItem := userids.Get(xrdUserId); useridItem != nil {
-			transfers.Set(fileid, FileRecord{UserId: useridItem.Value(), Path: path}, ttlcache.DefaultTTL)
+			setOpenedFile(fileid, FileRecord{UserId: useridItem.Value(), Path: path, LFN: rest, OpenTime: time.Now()})
 		}
 	case 'f':
 		log.Debug("HandlePacket: Received a f-stream packet")
@@ -600,6 +988,10 @@ func HandlePacket(packet []byte) error {
 		if firstHeaderSize < 24 {
 			return fmt.Errorf("First entry in f-stream packet is %v bytes, smaller than the minimum XrdXrootdMonFileTOD size of 24 bytes", firstHeaderSize)
 		}
+		// The first entry is an isTime record with the window the packet's records
+		// fall in; later isTime records narrow it
+		windowBeg := int64(binary.BigEndian.Uint32(packet[16:20]))
+		windowEnd := int64(binary.BigEndian.Uint32(packet[20:24]))
 		offset := uint32(firstHeaderSize + 8)
 		bytesRemain := header.Plen - uint16(offset)
 		for bytesRemain > 0 {
@@ -611,16 +1003,19 @@ func HandlePacket(packet []byte) error {
 			case isClose: // XrdXrootdMonFileHdr::isClose
 				log.Debugln("Received a f-stream file-close packet of size ",
 					fileHdr.RecSize)
-				fileId := FileId{Id: fileHdr.FileId}
+				fileId := FileId{Id: fileHdr.FileId, Stod: header.Stod}
 				xferRecord := transfers.Get(fileId)
 				transfers.Delete(fileId)
 				labels := prometheus.Labels{
-					"path": "/",
-					"ap":   "",
-					"dn":   "",
-					"role": "",
-					"org":  "",
-					"proj": "",
+					"instance": instance,
+					"path":     "/",
+					"ap":       "",
+					"dn":       "",
+					"role":     "",
+					"org":      "",
+					"proj":     "",
+					"country":  "",
+					"asn":      "",
 				}
 				var oldReadvSegs uint64 = 0
 				var oldReadOps uint32 = 0
@@ -629,9 +1024,9 @@ func HandlePacket(packet []byte) error {
 				var oldReadBytes uint64 = 0
 				var oldReadvBytes uint64 = 0
 				var oldWriteBytes uint64 = 0
+				var userRecord *ttlcache.Item[UserId, UserRecord]
 				if xferRecord != nil {
-					userRecord := sessions.Get(xferRecord.Value().UserId)
-					sessions.Delete(xferRecord.Value().UserId)
+					userRecord = sessions.Get(xferRecord.Value().UserId)
 					labels["path"] = xferRecord.Value().Path
 					if userRecord != nil {
 						labels["ap"] = userRecord.Value().AuthenticationProtocol
@@ -639,6 +1034,8 @@ func HandlePacket(packet []byte) error {
 						labels["role"] = userRecord.Value().Role
 						labels["org"] = userRecord.Value().Org
 						labels["proj"] = userRecord.Value().Project
+						labels["country"] = userRecord.Value().Country
+						labels["asn"] = userRecord.Value().ASN
 					}
 					oldReadvSegs = xferRecord.Value().ReadvSegs
 					oldReadOps = xferRecord.Value().ReadOps
@@ -648,6 +1045,7 @@ func HandlePacket(packet []byte) error {
 					oldReadvBytes = xferRecord.Value().ReadvBytes
 					oldWriteBytes = xferRecord.Value().WriteBytes
 				}
+				relabelTransfer(labels)
 				if fileHdr.RecFlag&0x02 == 0x02 { // XrdXrootdMonFileHdr::hasOPS
 					// sizeof(XrdXrootdMonFileHdr) + sizeof(XrdXrootdMonStatXFR)
 					opsOffset := uint32(8 + 24)
@@ -687,31 +1085,78 @@ func HandlePacket(packet []byte) error {
 				counter.Add(float64(int64(binary.BigEndian.Uint64(
 					packet[offset+xfrOffset+16:offset+xfrOffset+24]) -
 					oldWriteBytes)))
+				if xferRecord != nil {
+					var duration time.Duration
+					if openTOD := xferRecord.Value().OpenTOD; openTOD > 0 && windowEnd >= openTOD {
+						duration = time.Duration(windowEnd-openTOD) * time.Second
+					} else if !xferRecord.Value().OpenTime.IsZero() {
+						duration = time.Since(xferRecord.Value().OpenTime)
+					}
+					observeTransfer(instance, labels["path"], labels["proj"], duration,
+						binary.BigEndian.Uint64(packet[offset+xfrOffset:offset+xfrOffset+8])+
+							binary.BigEndian.Uint64(packet[offset+xfrOffset+8:offset+xfrOffset+16]),
+						binary.BigEndian.Uint64(packet[offset+xfrOffset+16:offset+xfrOffset+24]))
+				}
+				if xferRecord != nil && xferRecord.Value().LFN != "" {
+					closedFile := ClosedFile{
+						LFN: xferRecord.Value().LFN,
+						ReadBytes: binary.BigEndian.Uint64(packet[offset+xfrOffset:offset+xfrOffset+8]) +
+							binary.BigEndian.Uint64(packet[offset+xfrOffset+8:offset+xfrOffset+16]),
+						WriteBytes: binary.BigEndian.Uint64(packet[offset+xfrOffset+16 : offset+xfrOffset+24]),
+					}
+					if handler := fileCloseHandler.Load(); handler != nil {
+						(*handler)(closedFile)
+					}
+					cacheNamespaceStats.recordClosedFile(closedFile)
+				}
+				if xferRecord != nil {
+					var user *UserRecord
+					if userRecord != nil {
+						userValue := userRecord.Value()
+						user = &userValue
+					}
+					readBytes := binary.BigEndian.Uint64(packet[offset+xfrOffset : offset+xfrOffset+8])
+					readvBytes := binary.BigEndian.Uint64(packet[offset+xfrOffset+8 : offset+xfrOffset+16])
+					writeBytes := binary.BigEndian.Uint64(packet[offset+xfrOffset+16 : offset+xfrOffset+24])
+					now := time.Now()
+					topUsers.record(user, readBytes+readvBytes, writeBytes, now)
+					if lfn := xferRecord.Value().LFN; lfn != "" {
+						namespaceBandwidth.record(lfn, bytesSince(readBytes+readvBytes, oldReadBytes+oldReadvBytes),
+							bytesSince(writeBytes, oldWriteBytes), now)
+					}
+					if transferRecordsEnabled() {
+						queueTransferRecord(newTransferRecord(xferRecord.Value(), user, readBytes, readvBytes, writeBytes, now))
+					}
+				}
 			case isOpen: // XrdXrootdMonFileHdr::isOpen
 				log.Debug("MonPacket: Received a f-stream file-open packet")
-				fileid := FileId{Id: fileHdr.FileId}
+				fileid := FileId{Id: fileHdr.FileId, Stod: header.Stod}
 				path := ""
-				userId := UserId{}
+				lfn := ""
+				userId := UserId{Stod: header.Stod}
 				if fileHdr.RecFlag&0x01 == 0x01 { // hasLFN
 					lfnSize := uint32(fileHdr.RecSize - 20)
-					lfn := NullTermToString(packet[offset+20 : offset+lfnSize+20])
+					lfn = NullTermToString(packet[offset+20 : offset+lfnSize+20])
 					// path has been difined
 					path = computePrefix(lfn, monitorPaths)
 					log.Debugf("MonPacket: User LFN %v matches prefix %v",
 						lfn, path)
 					// UserId is part of LFN
-					userId = UserId{Id: binary.BigEndian.Uint32(packet[offset+16 : offset+20])}
+					userId = UserId{Id: binary.BigEndian.Uint32(packet[offset+16 : offset+20]), Stod: header.Stod}
 				}
-				transfers.Set(fileid, FileRecord{UserId: userId, Path: path},
-					ttlcache.DefaultTTL)
+				setOpenedFile(fileid, FileRecord{UserId: userId, Path: path, LFN: lfn, OpenTime: time.Now(), OpenTOD: windowBeg})
 			case isTime: // XrdXrootdMonFileHdr::isTime
 				log.Debug("MonPacket: Received a f-stream time packet")
+				if fileHdr.RecSize >= 16 {
+					windowBeg = int64(binary.BigEndian.Uint32(packet[offset+8 : offset+12]))
+					windowEnd = int64(binary.BigEndian.Uint32(packet[offset+12 : offset+16]))
+				}
 			case isXfr: // XrdXrootdMonFileHdr::isXfr
 				log.Debug("MonPacket: Received a f-stream transfer packet")
 				// NOTE: There's a lot to do here.  These records would allow us to
 				// capture partial file transfers or emulate a close on timeout.
 				// For now, we'll record the data but don't use it.
-				fileid := FileId{Id: fileHdr.FileId}
+				fileid := FileId{Id: fileHdr.FileId, Stod: header.Stod}
 				item := transfers.Get(fileid)
 				var record FileRecord
 				readBytes := binary.BigEndian.Uint64(packet[offset+8 : offset+16])
@@ -719,12 +1164,15 @@ func HandlePacket(packet []byte) error {
 				writeBytes := binary.BigEndian.Uint64(packet[offset+24 : offset+32])
 
 				labels := prometheus.Labels{
-					"path": "/",
-					"ap":   "",
-					"dn":   "",
-					"role": "",
-					"org":  "",
-					"proj": "",
+					"instance": instance,
+					"path":     "/",
+					"ap":       "",
+					"dn":       "",
+					"role":     "",
+					"org":      "",
+					"proj":     "",
+					"country":  "",
+					"asn":      "",
 				}
 
 				if item != nil {
@@ -737,8 +1185,11 @@ func HandlePacket(packet []byte) error {
 						labels["role"] = userRecord.Value().Role
 						labels["org"] = userRecord.Value().Org
 						labels["proj"] = userRecord.Value().Project
+						labels["country"] = userRecord.Value().Country
+						labels["asn"] = userRecord.Value().ASN
 					}
 				}
+				relabelTransfer(labels)
 
 				// We record those metrics to make sure they are properly populated with initial
 				// values, or the file close handler will only populate them by the difference, not
@@ -767,6 +1218,10 @@ func HandlePacket(packet []byte) error {
 				} else {
 					log.Debug("File-transfer WriteByte is less than previous value")
 				}
+				if item != nil && record.LFN != "" {
+					namespaceBandwidth.record(record.LFN, bytesSince(readBytes+readvBytes, record.ReadBytes+record.ReadvBytes),
+						bytesSince(writeBytes, record.WriteBytes), time.Now())
+				}
 				record.ReadBytes = readBytes
 				record.ReadvBytes = readvBytes
 				record.WriteBytes = writeBytes
@@ -774,10 +1229,7 @@ func HandlePacket(packet []byte) error {
 
 			case isDisc: // XrdXrootdMonFileHdr::isDisc
 				log.Debug("MonPacket: Received a f-stream disconnect packet")
-				userId := UserId{Id: fileHdr.UserId}
-				if session := sessions.Get(userId); session != nil {
-					sessions.Delete(userId)
-				}
+				closeSession(header.Stod, XrdXrootdMonFileDSC{Hdr: fileHdr})
 			default:
 				log.Debug("MonPacket: Received an unhandled file monitoring packet "+
 					"of type ", fileHdr.RecType)
@@ -805,45 +1257,77 @@ func HandlePacket(packet []byte) error {
 			strJsons := strings.Split(detail, "\n")
 			aggCacheStat := make(map[string]*CacheAccessStat)
 			for _, js := range strJsons {
+				if strings.TrimSpace(js) == "" {
+					continue
+				}
+				event := struct {
+					Event string `json:"event"`
+				}{}
+				if err := json.Unmarshal([]byte(js), &event); err != nil {
+					return errors.Wrap(err, "failed to parse cache stat json. Raw data is "+string(js))
+				}
+				switch event.Event {
+				case "", "file_close":
+				case "cache_stats":
+					cacheStats := CacheStatsGS{}
+					if err := json.Unmarshal([]byte(js), &cacheStats); err != nil {
+						return errors.Wrap(err, "failed to parse cache_stats json. Raw data is "+string(js))
+					}
+					recordCacheStats(instance, cacheStats)
+					continue
+				default:
+					log.Debugln("HandlePacket: Ignoring an unknown cache g-stream event", event.Event)
+					continue
+				}
 				cacheStat := CacheGS{}
 				if err := json.Unmarshal([]byte(js), &cacheStat); err != nil {
 					return errors.Wrap(err, "failed to parse cache stat json. Raw data is "+string(js))
 				}
 
+				cacheNamespaceStats.recordAccess(cacheStat.Lfn, cacheStat.ByteHit, cacheStat.ByteMiss, cacheStat.ByteBypass)
+
 				prefix := computePrefix(cacheStat.Lfn, monitorPaths)
 				if aggCacheStat[prefix] == nil {
-					aggCacheStat[prefix] = &CacheAccessStat{
-						Hit:    cacheStat.ByteHit,
-						Miss:   cacheStat.ByteMiss,
-						Bypass: cacheStat.ByteBypass,
-					}
-				} else {
-					aggCacheStat[prefix].Hit += cacheStat.ByteHit
-					aggCacheStat[prefix].Miss += cacheStat.ByteMiss
-					aggCacheStat[prefix].Bypass += cacheStat.ByteBypass
+					aggCacheStat[prefix] = &CacheAccessStat{}
 				}
+				aggCacheStat[prefix].Hit += cacheStat.ByteHit
+				aggCacheStat[prefix].Miss += cacheStat.ByteMiss
+				aggCacheStat[prefix].Bypass += cacheStat.ByteBypass
+				aggCacheStat[prefix].ToDisk += cacheStat.ByteToDisk
+				aggCacheStat[prefix].Prefetch += cacheStat.BytePrefetch
+				aggCacheStat[prefix].CksErrs += int64(cacheStat.NCksErrs)
 			}
 			for prefix, stat := range aggCacheStat {
 				// For hit, miss, bypass, each packet only records the buffer
 				// between last sent and now, so we need to add them
-				CacheAccess.WithLabelValues(prefix, "hit").Add(float64(stat.Hit))
-				CacheAccess.WithLabelValues(prefix, "miss").Add(float64(stat.Miss))
-				CacheAccess.WithLabelValues(prefix, "bypass").Add(float64(stat.Bypass))
+				CacheAccess.WithLabelValues(instance, prefix, "hit").Add(float64(stat.Hit))
+				CacheAccess.WithLabelValues(instance, prefix, "miss").Add(float64(stat.Miss))
+				CacheAccess.WithLabelValues(instance, prefix, "bypass").Add(float64(stat.Bypass))
+				CacheDiskWriteBytes.WithLabelValues(instance, prefix).Add(float64(stat.ToDisk))
+				CachePrefetchBytes.WithLabelValues(instance, prefix).Add(float64(stat.Prefetch))
+				CachePgReadChecksumErrors.WithLabelValues(instance, prefix).Add(float64(stat.CksErrs))
 			}
 		}
 
+	case 'r':
+		log.Debug("HandlePacket: Received a r-stream packet")
+		return handleRedirectPacket(header, packet)
 	case 'i':
 		log.Debug("HandlePacket: Received an appinfo packet")
 		infoSize := uint32(header.Plen - 12)
 		if xrdUserId, appinfo, err := GetSIDRest(packet[12 : 12+infoSize]); err == nil {
+			xrdUserId.Stod = header.Stod
 			if userids.Has(xrdUserId) {
 				userId := userids.Get(xrdUserId).Value()
 				if sessions.Has(userId) {
 					existingRec := sessions.Get(userId).Value()
-					existingRec.Project = appinfo
+					// The project from the session's token takes precedence over the client's
+					if projectClaim == "" || existingRec.Project == "" {
+						existingRec.Project = appinfo
+					}
 					sessions.Set(userId, existingRec, ttlcache.DefaultTTL)
 				} else {
-					sessions.Set(userId, UserRecord{Project: appinfo}, ttlcache.DefaultTTL)
+					sessions.Set(userId, UserRecord{Project: appinfo, XrdUserId: xrdUserId}, ttlcache.DefaultTTL)
 				}
 			}
 		} else {
@@ -853,6 +1337,7 @@ func HandlePacket(packet []byte) error {
 		log.Debug("HandlePacket: Received a user login packet")
 		infoSize := uint32(header.Plen - 12)
 		if xrdUserId, auth, err := GetSIDRest(packet[12 : 12+infoSize]); err == nil {
+			xrdUserId.Stod = header.Stod
 			var record UserRecord
 			for _, pair := range strings.Split(auth, "&") {
 				keyVal := strings.SplitN(pair, "=", 2)
@@ -875,8 +1360,10 @@ func HandlePacket(packet []byte) error {
 			if len(record.AuthenticationProtocol) > 0 {
 				record.User = xrdUserId.User
 			}
-			sessions.Set(UserId{Id: dictid}, record, ttlcache.DefaultTTL)
-			userids.Set(xrdUserId, UserId{Id: dictid}, ttlcache.DefaultTTL)
+			record.Country, record.ASN = lookupClientGeo(xrdUserId.Host)
+			record.XrdUserId = xrdUserId
+			sessions.Set(UserId{Id: dictid, Stod: header.Stod}, record, ttlcache.DefaultTTL)
+			userids.Set(xrdUserId, UserId{Id: dictid, Stod: header.Stod}, ttlcache.DefaultTTL)
 		} else {
 			return err
 		}
@@ -888,6 +1375,16 @@ func HandlePacket(packet []byte) error {
 			if err != nil {
 				return err
 			}
+			userId.Stod = header.Stod
+			if existing := sessions.Get(userId); existing != nil {
+				// Keep the project the client reported if the token has none
+				if userRecord.Project == "" {
+					userRecord.Project = existing.Value().Project
+				}
+				userRecord.Country = existing.Value().Country
+				userRecord.ASN = existing.Value().ASN
+				userRecord.XrdUserId = existing.Value().XrdUserId
+			}
 			sessions.Set(userId, userRecord, ttlcache.DefaultTTL)
 		} else {
 			return err
@@ -900,6 +1397,66 @@ func HandlePacket(packet []byte) error {
 
 }
 
+// Handle an r-stream packet, which records the redirects XRootD issued.  After the
+// header and the 8-byte server ID, the packet holds XrdXrootdMonRedir entries; a
+// redirect entry is followed by its null-terminated "host:path" target.
+func handleRedirectPacket(header XrdXrootdMonHeader, packet []byte) error {
+	// sizeof(XrdXrootdMonHeader) + sizeof(sID)
+	if len(packet) < 8+8 {
+		return errors.New("Packet is too small to be a valid r-stream packet")
+	}
+	end := int(header.Plen)
+	if end > len(packet) {
+		end = len(packet)
+	}
+	for offset := 16; offset+8 <= end; {
+		redir := XrdXrootdMonRedir{
+			Type:   packet[offset],
+			Dent:   packet[offset+1],
+			Port:   binary.BigEndian.Uint16(packet[offset+2 : offset+4]),
+			Dictid: binary.BigEndian.Uint32(packet[offset+4 : offset+8]),
+		}
+		entryEnd := offset + 8 + int(redir.Dent)*8
+		if entryEnd > end {
+			return fmt.Errorf("r-stream entry at offset %d is %d bytes, past the end of the packet", offset, entryEnd-offset)
+		}
+		switch redir.Type & XROOTD_MON_REDTYPE {
+		case XROOTD_MON_REDIRECT, XROOTD_MON_REDLOCAL:
+			host, redirPath := parseRedirectTarget(NullTermToString(packet[offset+8 : entryEnd]))
+			if redir.Port != 0 {
+				host = net.JoinHostPort(host, strconv.Itoa(int(redir.Port)))
+			}
+			Redirects.WithLabelValues(instanceLabel(int64(header.Stod)), host, computePrefix(redirPath, monitorPaths)).Inc()
+		case XROOTD_MON_REDTIME, XROOTD_MON_REDSID:
+			// Nothing to record
+		default:
+			log.Debugf("HandlePacket: Received an unhandled r-stream entry of type %#x", redir.Type)
+		}
+		offset = entryEnd
+	}
+	return nil
+}
+
+// Split the "host:path" target of a redirect.  The host may be a bracketed IPv6 address,
+// and any CGI is removed from the path.
+func parseRedirectTarget(target string) (host string, redirPath string) {
+	sepIdx := strings.Index(target, ":")
+	if strings.HasPrefix(target, "[") {
+		if closeIdx := strings.Index(target, "]"); closeIdx >= 0 {
+			sepIdx = closeIdx + 1
+			if sepIdx >= len(target) || target[sepIdx] != ':' {
+				sepIdx = -1
+			}
+		}
+	}
+	if sepIdx < 0 {
+		return strings.Trim(target, "[]"), ""
+	}
+	host = strings.Trim(target[:sepIdx], "[]")
+	redirPath, _, _ = strings.Cut(target[sepIdx+1:], "?")
+	return
+}
+
 // Unlike the highly-compressed binary format that is the detailed monitoring, the summary monitoring
 // is a mostly-compliant chunk of XML.  I copy below the pretty-printed version of a sample packet:
 /*
@@ -1046,6 +1603,8 @@ func HandleSummaryPacket(packet []byte) error {
 		// We only care about the xrootd summary packets
 		return nil
 	}
+	instance := instanceLabel(summaryStats.StartTime)
+	totals := getInstanceTotals(instance)
 	for _, stat := range summaryStats.Stats {
 		switch stat.Id {
 
@@ -1057,46 +1616,104 @@ func HandleSummaryPacket(packet []byte) error {
 
 			// Note that stat.Total is the total connections since the start-up of the servcie
 			// So we just want to make sure here that no negative value is present
-			incBy := float64(stat.Total - lastStats.Total)
-			if stat.Total < lastStats.Total {
-				incBy = float64(stat.Total)
-			}
-			Connections.Add(incBy)
-			lastStats.Total = stat.Total
-
-			incBy = float64(stat.In - lastStats.In)
-			if stat.In < lastStats.In {
-				incBy = float64(stat.In)
-			}
-			BytesXfer.With(prometheus.Labels{"direction": "rx"}).Add(incBy)
-			lastStats.In = stat.In
-
-			incBy = float64(stat.Out - lastStats.Out)
-			if stat.Out < lastStats.Out {
-				incBy = float64(stat.Out)
-			}
-			BytesXfer.With(prometheus.Labels{"direction": "tx"}).Add(incBy)
-			lastStats.Out = stat.Out
+			Connections.WithLabelValues(instance).Add(summaryIncrement(stat.Total, totals.link.Total))
+			BytesXfer.WithLabelValues(instance, "rx").Add(summaryIncrement(stat.In, totals.link.In))
+			BytesXfer.WithLabelValues(instance, "tx").Add(summaryIncrement(stat.Out, totals.link.Out))
+			totals.link = stat
 		case SchedStat:
-			Threads.With(prometheus.Labels{"state": "idle"}).Set(float64(stat.Idle))
-			Threads.With(prometheus.Labels{"state": "running"}).Set(float64(stat.Threads -
-				stat.Idle))
+			Threads.WithLabelValues(instance, "idle").Set(float64(stat.Idle))
+			Threads.WithLabelValues(instance, "running").Set(float64(stat.Threads - stat.Idle))
 		case OssStat: // Oss stat should only appear on origin servers
 			for _, pathStat := range stat.Paths.Stats {
 				noQuoteLp := strings.Replace(pathStat.Lp, "\"", "", 2)
 				// pathStat.Total is in kilobytes but we want to standardize all data to bytes
-				StorageVolume.With(prometheus.Labels{"ns": noQuoteLp, "type": "total", "server_type": "origin"}).
-					Set(float64(pathStat.Total * 1024))
-				StorageVolume.With(prometheus.Labels{"ns": noQuoteLp, "type": "free", "server_type": "origin"}).
-					Set(float64(pathStat.Free * 1024))
+				StorageVolume.WithLabelValues(instance, noQuoteLp, "total", "origin").Set(float64(pathStat.Total * 1024))
+				StorageVolume.WithLabelValues(instance, noQuoteLp, "free", "origin").Set(float64(pathStat.Free * 1024))
+			}
+			// Like the paths, the spaces report their sizes in kilobytes
+			for _, spaceStat := range stat.Space.Stats {
+				if spaceStat.Name == "" {
+					continue
+				}
+				CacheDiskSpace.WithLabelValues(instance, spaceStat.Name, "total").Set(float64(spaceStat.Total * 1024))
+				CacheDiskSpace.WithLabelValues(instance, spaceStat.Name, "free").Set(float64(spaceStat.Free * 1024))
 			}
 		case CacheStat:
 			cacheStore := stat.Store
-			StorageVolume.With(prometheus.Labels{"ns": "/cache", "type": "total", "server_type": "cache"}).
-				Set(float64(cacheStore.Size))
-			StorageVolume.With(prometheus.Labels{"ns": "/cache", "type": "free", "server_type": "cache"}).
-				Set(float64(cacheStore.Size - cacheStore.Used))
+			StorageVolume.WithLabelValues(instance, "/cache", "total", "cache").Set(float64(cacheStore.Size))
+			StorageVolume.WithLabelValues(instance, "/cache", "free", "cache").Set(float64(cacheStore.Size - cacheStore.Used))
+			CacheDiskSpace.WithLabelValues(instance, "cache", "total").Set(float64(cacheStore.Size))
+			CacheDiskSpace.WithLabelValues(instance, "cache", "free").Set(float64(cacheStore.Size - cacheStore.Used))
+			// The cache purges files once its usage passes the high watermark (store.max),
+			// so the space above the watermark is reserved and never filled with objects
+			if cacheStore.Max > 0 && cacheStore.Max <= cacheStore.Size {
+				CacheDiskSpace.WithLabelValues(instance, "cache", "reserved").Set(float64(cacheStore.Size - cacheStore.Max))
+			}
+		case XrdStat:
+			last := totals.xrd
+			ServerErrors.WithLabelValues(instance, "xrootd").Add(summaryIncrement(stat.Errors, last.Errors))
+			ServerRedirects.WithLabelValues(instance, "xrootd").Add(summaryIncrement(stat.Redirects, last.Redirects))
+			ServerDelays.WithLabelValues(instance, "xrootd").Add(summaryIncrement(stat.Delays, last.Delays))
+			ServerLogins.WithLabelValues(instance, "authenticated").
+				Add(summaryIncrement(stat.Logins.Authenticated, last.Logins.Authenticated))
+			ServerLogins.WithLabelValues(instance, "unauthenticated").
+				Add(summaryIncrement(stat.Logins.Unauthenticated, last.Logins.Unauthenticated))
+			ServerLogins.WithLabelValues(instance, "auth_failed").
+				Add(summaryIncrement(stat.Logins.AuthFailures, last.Logins.AuthFailures))
+			totals.xrd = stat
+		case OfsStat:
+			last := totals.ofs
+			ServerErrors.WithLabelValues(instance, "ofs").Add(summaryIncrement(stat.Errors, last.Errors))
+			ServerRedirects.WithLabelValues(instance, "ofs").Add(summaryIncrement(stat.Redirects, last.Redirects))
+			ServerDelays.WithLabelValues(instance, "ofs").Add(summaryIncrement(stat.Delays, last.Delays))
+			OfsTpcRequests.WithLabelValues(instance, "granted").Add(summaryIncrement(stat.Tpc.Granted, last.Tpc.Granted))
+			OfsTpcRequests.WithLabelValues(instance, "denied").Add(summaryIncrement(stat.Tpc.Denied, last.Tpc.Denied))
+			OfsTpcRequests.WithLabelValues(instance, "error").Add(summaryIncrement(stat.Tpc.Errors, last.Tpc.Errors))
+			OfsTpcRequests.WithLabelValues(instance, "expired").Add(summaryIncrement(stat.Tpc.Expired, last.Tpc.Expired))
+			OfsOpenFiles.WithLabelValues(instance, "read").Set(float64(stat.OpenRead))
+			OfsOpenFiles.WithLabelValues(instance, "write").Set(float64(stat.OpenWrite))
+			OfsFileHandles.WithLabelValues(instance).Set(float64(stat.Handles))
+			totals.ofs = stat
 		}
 	}
 	return nil
 }
+
+// Record the totals of a cache_stats record of the cache's g-stream.  Like the summary
+// totals, they're counted since XRootD started.
+func recordCacheStats(instance string, stats CacheStatsGS) {
+	totals := getInstanceTotals(instance)
+	last := totals.cache
+	if stats.ByteHit < last.ByteHit || stats.ByteMiss < last.ByteMiss {
+		// XRootD restarted
+		last = CacheStatsGS{}
+	}
+	if hit, miss := stats.ByteHit-last.ByteHit, stats.ByteMiss-last.ByteMiss; hit+miss > 0 {
+		CacheHitRatio.WithLabelValues(instance).Set(float64(hit) / float64(hit+miss))
+	}
+	CacheServerPrefetchBytes.WithLabelValues(instance).Add(summaryIncrement64(stats.BytePrefetch, totals.cache.BytePrefetch))
+	CacheEvictions.WithLabelValues(instance).Add(summaryIncrement64(stats.NEvictions, totals.cache.NEvictions))
+	CacheEvictedBytes.WithLabelValues(instance).Add(summaryIncrement64(stats.ByteEvictions, totals.cache.ByteEvictions))
+	totals.cache = stats
+}
+
+// The increment of a summary total since the previous summary.  The totals are counted
+// since XRootD started, so a total smaller than the previous one means XRootD restarted.
+func summaryIncrement(total, last int) float64 {
+	return summaryIncrement64(int64(total), int64(last))
+}
+
+func summaryIncrement64(total, last int64) float64 {
+	if total < last {
+		return float64(total)
+	}
+	return float64(total - last)
+}
+
+// The bytes transferred since a previous total, or 0 if the total went down
+func bytesSince(total, previous uint64) uint64 {
+	if total < previous {
+		return 0
+	}
+	return total - previous
+}
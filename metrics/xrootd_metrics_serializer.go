@@ -293,3 +293,7 @@ func (cls *XrdXrootdMonFileCLS) Serialize() ([]byte, error) {
 
 	return buf.Bytes(), nil
 }
+
+func (dsc *XrdXrootdMonFileDSC) Serialize() ([]byte, error) {
+	return dsc.Hdr.Serialize()
+}
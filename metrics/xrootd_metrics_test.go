@@ -20,6 +20,7 @@ package metrics
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/xml"
 	"fmt"
 	"strings"
@@ -27,11 +28,16 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+// The start time of the XRootD daemon sending the mock packets
+var mockStod = int32(time.Now().Unix())
+
 func getAuthInfoString(user UserRecord) string {
 	return fmt.Sprintf("&p=%s&n=%s&h=[::ffff:172.17.0.2]&o=%s&r=%s&g=&m=&I=4", user.AuthenticationProtocol, user.DN, user.Org, user.Role)
 }
@@ -50,7 +56,7 @@ func mockFileOpenPacket(pseq int, fileId, userId uint32, SID int64, path string)
 		Code: 'f',
 		Pseq: byte(pseq),
 		Plen: uint16(8), // to change
-		Stod: int32(time.Now().Unix()),
+		Stod: mockStod,
 	}
 	mockMonFileTOD := XrdXrootdMonFileTOD{
 		Hdr: XrdXrootdMonFileHdr{ // 8B
@@ -111,7 +117,7 @@ func mockFileXfrPacket(pseq int, fileId uint32, SID int64, read, readv, wrtie in
 		Code: 'f',
 		Pseq: byte(pseq),
 		Plen: uint16(8), // to change
-		Stod: int32(time.Now().Unix()),
+		Stod: mockStod,
 	}
 	mockMonFileTOD := XrdXrootdMonFileTOD{
 		Hdr: XrdXrootdMonFileHdr{ // 8B
@@ -179,7 +185,7 @@ func mockFileClosePacket(pseq int, fileId uint32, SID int64, statOps *XrdXrootdM
 		Code: 'f',
 		Pseq: byte(pseq),
 		Plen: uint16(8), // to change
-		Stod: int32(time.Now().Unix()),
+		Stod: mockStod,
 	}
 	mockMonFileTOD := XrdXrootdMonFileTOD{
 		Hdr: XrdXrootdMonFileHdr{ // 8B
@@ -231,6 +237,58 @@ func mockFileClosePacket(pseq int, fileId uint32, SID int64, statOps *XrdXrootdM
 	return buf.Bytes(), nil
 }
 
+func mockFileDisconnectPacket(pseq int, userId uint32, SID int64, forced bool) ([]byte, error) {
+	// f-stream client disconnect event
+	mockMonHeader := XrdXrootdMonHeader{ // 8B
+		Code: 'f',
+		Pseq: byte(pseq),
+		Plen: uint16(8 + 24 + 8),
+		Stod: mockStod,
+	}
+	mockMonFileTOD := XrdXrootdMonFileTOD{
+		Hdr: XrdXrootdMonFileHdr{ // 8B
+			RecType: isTime,
+			RecFlag: 0x01, // hasSID
+			RecSize: int16(24),
+			NRecs0:  0,
+			NRecs1:  1,
+		},
+		TBeg: int32(time.Now().Unix()),
+		TEnd: int32(time.Now().Add(time.Second).Unix()),
+		SID:  SID,
+	}
+	mockFileDisc := XrdXrootdMonFileDSC{
+		Hdr: XrdXrootdMonFileHdr{ // 8B
+			RecType: isDisc,
+			RecSize: 8,
+			UserId:  userId,
+		},
+	}
+	if forced {
+		mockFileDisc.Hdr.RecFlag = 0x01 // forced
+	}
+
+	monHeader, err := mockMonHeader.Serialize()
+	if err != nil {
+		return nil, errors.Wrap(err, "Error serialize monitor header")
+	}
+	fileTod, err := mockMonFileTOD.Serialize()
+	if err != nil {
+		return nil, errors.Wrap(err, "Error serialize FileTOD")
+	}
+	fileDisc, err := mockFileDisc.Serialize()
+	if err != nil {
+		return nil, errors.Wrap(err, "Error serialize FileDSC")
+	}
+
+	buf := new(bytes.Buffer)
+	buf.Write(monHeader)
+	buf.Write(fileTod)
+	buf.Write(fileDisc)
+
+	return buf.Bytes(), nil
+}
+
 func TestHandlePacket(t *testing.T) {
 	mockFileID := uint32(999)
 	mockSID := int64(143152967831384)
@@ -265,8 +323,8 @@ func TestHandlePacket(t *testing.T) {
 		mockPromThreads := `
 		# HELP xrootd_sched_thread_count Number of scheduler threads
 		# TYPE xrootd_sched_thread_count gauge
-		xrootd_sched_thread_count{state="idle"} 8
-		xrootd_sched_thread_count{state="running"} 2
+		xrootd_sched_thread_count{instance="0",state="idle"} 8
+		xrootd_sched_thread_count{instance="0",state="running"} 2
 		`
 		expectedReader := strings.NewReader(mockPromThreads)
 
@@ -328,27 +386,27 @@ func TestHandlePacket(t *testing.T) {
 		mockPromLinkConnectBase := `
 		# HELP xrootd_server_connection_count Aggregate number of server connections
 		# TYPE xrootd_server_connection_count counter
-		xrootd_server_connection_count 9
+		xrootd_server_connection_count{instance="0"} 9
 		`
 
 		mockPromLinkByteXferBase := `
 		# HELP xrootd_server_bytes Number of bytes read into the server
 		# TYPE xrootd_server_bytes counter
-		xrootd_server_bytes{direction="rx"} 99
-		xrootd_server_bytes{direction="tx"} 999
+		xrootd_server_bytes{direction="rx",instance="0"} 99
+		xrootd_server_bytes{direction="tx",instance="0"} 999
 		`
 
 		mockPromLinkConnectInc := `
 		# HELP xrootd_server_connection_count Aggregate number of server connections
 		# TYPE xrootd_server_connection_count counter
-		xrootd_server_connection_count 10
+		xrootd_server_connection_count{instance="0"} 10
 		`
 
 		mockPromLinkByteXferInc := `
 		# HELP xrootd_server_bytes Number of bytes read into the server
 		# TYPE xrootd_server_bytes counter
-		xrootd_server_bytes{direction="rx"} 100
-		xrootd_server_bytes{direction="tx"} 1000
+		xrootd_server_bytes{direction="rx",instance="0"} 100
+		xrootd_server_bytes{direction="tx",instance="0"} 1000
 		`
 
 		expectedLinkConnectBase := strings.NewReader(mockPromLinkConnectBase)
@@ -400,6 +458,84 @@ func TestHandlePacket(t *testing.T) {
 		}
 	})
 
+	t.Run("record-cache-disk-space-from-summary-packet", func(t *testing.T) {
+		mockSpaceSummary := `<statistics tod="1687524138" ver="v5.6.0" src="localhost:1094" tos="1687524137" pgm="xrootd" ins="anon" pid="1" site="">` +
+			`<stats id="oss" v="2"><paths>0</paths><space>1<stats id="0"><name>public</name><tot>2048</tot><free>1024</free>` +
+			`<maxf>1024</maxf><fsn>1</fsn><usg>1024</usg><qta>-1</qta></stats></space></stats>` +
+			`<stats id="cache" type="pfc"><store><size>10000</size><used>4000</used><min>8000</min><max>9000</max></store>` +
+			`<mem><size>0</size><used>0</used><wq>0</wq></mem></stats></statistics>`
+
+		CacheDiskSpace.Reset()
+
+		expected := `
+		# HELP xrootd_cache_disk_space_bytes Disk space of the cache and of the server's oss spaces
+		# TYPE xrootd_cache_disk_space_bytes gauge
+		xrootd_cache_disk_space_bytes{instance="1687524137",space="cache",type="free"} 6000
+		xrootd_cache_disk_space_bytes{instance="1687524137",space="cache",type="reserved"} 1000
+		xrootd_cache_disk_space_bytes{instance="1687524137",space="cache",type="total"} 10000
+		xrootd_cache_disk_space_bytes{instance="1687524137",space="public",type="free"} 1.048576e+06
+		xrootd_cache_disk_space_bytes{instance="1687524137",space="public",type="total"} 2.097152e+06
+		`
+
+		err := HandlePacket([]byte(mockSpaceSummary))
+		require.NoError(t, err, "Error handling the packet")
+		if err := testutil.CollectAndCompare(CacheDiskSpace, strings.NewReader(expected), "xrootd_cache_disk_space_bytes"); err != nil {
+			require.NoError(t, err, "Collected metric is different from expected")
+		}
+	})
+
+	t.Run("record-xrootd-and-ofs-from-summary-packet", func(t *testing.T) {
+		summary := func(xrdErrs, delays, logins, authFailures, ofsErrs, tpcDenied, openRead int) []byte {
+			return []byte(fmt.Sprintf(`<statistics tod="1687524138" ver="v5.6.0" src="localhost:1094" tos="1687524137" pgm="xrootd" ins="anon" pid="1" site="">`+
+				`<stats id="xrootd"><num>1</num><ops><open>1</open></ops><aio><num>0</num><max>0</max><rej>0</rej></aio>`+
+				`<err>%d</err><rdr>1</rdr><dly>%d</dly><lgn><num>%d</num><af>%d</af><au>%d</au><ua>0</ua></lgn></stats>`+
+				`<stats id="ofs"><role>server</role><opr>%d</opr><opw>1</opw><opp>0</opp><ups>0</ups><han>3</han><rdr>0</rdr>`+
+				`<bxq>0</bxq><rep>0</rep><err>%d</err><dly>0</dly><sok>0</sok><ser>0</ser>`+
+				`<tpc><grnt>2</grnt><deny>%d</deny><err>0</err><exp>0</exp></tpc></stats></statistics>`,
+				xrdErrs, delays, logins+authFailures, authFailures, logins, openRead, ofsErrs, tpcDenied))
+		}
+		ServerErrors.Reset()
+		ServerRedirects.Reset()
+		ServerDelays.Reset()
+		ServerLogins.Reset()
+		OfsOpenFiles.Reset()
+		OfsTpcRequests.Reset()
+		delete(lastTotals, "1687524137")
+
+		require.NoError(t, HandlePacket(summary(2, 1, 5, 1, 4, 1, 2)))
+		require.NoError(t, HandlePacket(summary(3, 4, 8, 1, 4, 1, 0)))
+
+		expected := `
+		# HELP xrootd_server_errors_total Number of requests that ended with an error, by the XRootD layer reporting them
+		# TYPE xrootd_server_errors_total counter
+		xrootd_server_errors_total{instance="1687524137",layer="ofs"} 4
+		xrootd_server_errors_total{instance="1687524137",layer="xrootd"} 3
+		# HELP xrootd_server_delays_total Number of requests the client was told to retry later, e.g. when throttled, by the XRootD layer reporting them
+		# TYPE xrootd_server_delays_total counter
+		xrootd_server_delays_total{instance="1687524137",layer="ofs"} 0
+		xrootd_server_delays_total{instance="1687524137",layer="xrootd"} 4
+		# HELP xrootd_server_logins_total Number of client logins, by their result
+		# TYPE xrootd_server_logins_total counter
+		xrootd_server_logins_total{instance="1687524137",result="auth_failed"} 1
+		xrootd_server_logins_total{instance="1687524137",result="authenticated"} 8
+		xrootd_server_logins_total{instance="1687524137",result="unauthenticated"} 0
+		`
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(ServerErrors, ServerDelays, ServerLogins)
+		require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected)))
+		instance := "1687524137"
+		assert.Equal(t, 1.0, testutil.ToFloat64(ServerRedirects.WithLabelValues(instance, "xrootd")))
+		assert.Equal(t, 0.0, testutil.ToFloat64(OfsOpenFiles.WithLabelValues(instance, "read")))
+		assert.Equal(t, 1.0, testutil.ToFloat64(OfsOpenFiles.WithLabelValues(instance, "write")))
+		assert.Equal(t, 3.0, testutil.ToFloat64(OfsFileHandles.WithLabelValues(instance)))
+		assert.Equal(t, 2.0, testutil.ToFloat64(OfsTpcRequests.WithLabelValues(instance, "granted")))
+		assert.Equal(t, 1.0, testutil.ToFloat64(OfsTpcRequests.WithLabelValues(instance, "denied")))
+
+		// The totals restart from zero when XRootD restarts
+		require.NoError(t, HandlePacket(summary(1, 0, 0, 0, 0, 0, 0)))
+		assert.Equal(t, 4.0, testutil.ToFloat64(ServerErrors.WithLabelValues(instance, "xrootd")))
+	})
+
 	t.Run("auth-packet-u-should-register-correct-info", func(t *testing.T) {
 		mockUserRecord := UserRecord{
 			AuthenticationProtocol: "https",
@@ -421,7 +557,7 @@ func TestHandlePacket(t *testing.T) {
 				Code: 'u',
 				Pseq: 1,
 				Plen: uint16(12 + len(mockInfo)),
-				Stod: int32(time.Now().Unix()),
+				Stod: mockStod,
 			},
 			Dictid: uint32(0x12345678), // 4B
 			Info:   mockInfo,
@@ -463,7 +599,7 @@ func TestHandlePacket(t *testing.T) {
 				Code: 'd',
 				Pseq: 1,
 				Plen: uint16(12 + len(mockInfo)),
-				Stod: int32(time.Now().Unix()),
+				Stod: mockStod,
 			},
 			Dictid: uint32(10), // 4B
 			Info:   mockInfo,
@@ -591,37 +727,42 @@ func TestHandlePacket(t *testing.T) {
 		err = HandlePacket(xftPacket)
 		require.NoError(t, err, "Error handling the file transfer packet")
 
+		closedFiles := []ClosedFile{}
+		SetFileCloseHandler(func(file ClosedFile) { closedFiles = append(closedFiles, file) })
+		defer SetFileCloseHandler(nil)
+
 		err = HandlePacket(clsPacket)
 		require.NoError(t, err, "Error handling the file close packet")
 
 		// Transfer item should be deleted on file close
 		require.Equal(t, 0, len(transfers.Keys()), "Transfer cache didn't update")
+		assert.Equal(t, []ClosedFile{{LFN: "/full/path/to/file.txt", ReadBytes: uint64(mockRead + mockReadV), WriteBytes: uint64(mockWrite)}}, closedFiles)
 
 		expectedTransferReadvSegs := `
 		# HELP xrootd_transfer_readv_segments_count Number of segments in readv operations
 		# TYPE xrootd_transfer_readv_segments_count counter
-		xrootd_transfer_readv_segments_count{ap="",dn="",org="",path="/",proj="",role=""} 1000
+		xrootd_transfer_readv_segments_count{ap="",asn="",country="",dn="",instance="%[1]d",org="",path="/",proj="",role=""} 1000
 		`
 
 		expectedTransferOps := `
 		# HELP xrootd_transfer_operations_count Number of transfer operations performed
 		# TYPE xrootd_transfer_operations_count counter
-		xrootd_transfer_operations_count{ap="",dn="",org="",path="/",proj="",role="",type="read"} 120
-		xrootd_transfer_operations_count{ap="",dn="",org="",path="/",proj="",role="",type="readv"} 10
-		xrootd_transfer_operations_count{ap="",dn="",org="",path="/",proj="",role="",type="write"} 30
+		xrootd_transfer_operations_count{ap="",asn="",country="",dn="",instance="%[1]d",org="",path="/",proj="",role="",type="read"} 120
+		xrootd_transfer_operations_count{ap="",asn="",country="",dn="",instance="%[1]d",org="",path="/",proj="",role="",type="readv"} 10
+		xrootd_transfer_operations_count{ap="",asn="",country="",dn="",instance="%[1]d",org="",path="/",proj="",role="",type="write"} 30
 		`
 
 		expectedTransferBytes := `
 		# HELP xrootd_transfer_bytes Bytes of transfers
 		# TYPE xrootd_transfer_bytes counter
-		xrootd_transfer_bytes{ap="",dn="",org="",path="/",proj="",role="",type="read"} 10000
-		xrootd_transfer_bytes{ap="",dn="",org="",path="/",proj="",role="",type="readv"} 20000
-		xrootd_transfer_bytes{ap="",dn="",org="",path="/",proj="",role="",type="write"} 120
+		xrootd_transfer_bytes{ap="",asn="",country="",dn="",instance="%[1]d",org="",path="/",proj="",role="",type="read"} 10000
+		xrootd_transfer_bytes{ap="",asn="",country="",dn="",instance="%[1]d",org="",path="/",proj="",role="",type="readv"} 20000
+		xrootd_transfer_bytes{ap="",asn="",country="",dn="",instance="%[1]d",org="",path="/",proj="",role="",type="write"} 120
 		`
 
-		expectedTransferReadvSegsReader := strings.NewReader(expectedTransferReadvSegs)
-		expectedTransferOpsReader := strings.NewReader(expectedTransferOps)
-		expectedTransferBytesReader := strings.NewReader(expectedTransferBytes)
+		expectedTransferReadvSegsReader := strings.NewReader(fmt.Sprintf(expectedTransferReadvSegs, mockStod))
+		expectedTransferOpsReader := strings.NewReader(fmt.Sprintf(expectedTransferOps, mockStod))
+		expectedTransferBytesReader := strings.NewReader(fmt.Sprintf(expectedTransferBytes, mockStod))
 
 		if err := testutil.CollectAndCompare(TransferReadvSegs, expectedTransferReadvSegsReader, "xrootd_transfer_readv_segments_count"); err != nil {
 			require.NoError(t, err, "Collected metric is different from expected")
@@ -637,6 +778,58 @@ func TestHandlePacket(t *testing.T) {
 	})
 
 	// The token packet should update the user's session.
+	t.Run("f-stream-disconnect-event-should-close-session", func(t *testing.T) {
+		sessions.DeleteAll()
+		userids.DeleteAll()
+		transfers.DeleteAll()
+		t.Cleanup(func() {
+			sessions.DeleteAll()
+			userids.DeleteAll()
+			transfers.DeleteAll()
+		})
+
+		mockXrdUserId := XrdUserId{Prot: "https", User: "unknown", Pid: 0, Sid: 143152967831384, Host: "fae8c2865de4", Stod: mockStod}
+		mockInfo := []byte(getUserIdString(mockXrdUserId) + "\n" + getAuthInfoString(UserRecord{AuthenticationProtocol: "https", DN: "clientName"}))
+		mockMonMap := XrdXrootdMonMap{
+			Hdr: XrdXrootdMonHeader{
+				Code: 'u',
+				Pseq: 1,
+				Plen: uint16(12 + len(mockInfo)),
+				Stod: mockStod,
+			},
+			Dictid: uint32(0x12345678),
+			Info:   mockInfo,
+		}
+		buf, err := mockMonMap.Serialize()
+		require.NoError(t, err)
+		require.NoError(t, HandlePacket(buf))
+		require.True(t, userids.Has(mockXrdUserId))
+
+		// Closing a file leaves the session for the client's other files
+		buf, err = mockFileOpenPacket(2, 0x1111, 0x12345678, 143152967831384, "/full/path/to/file.txt")
+		require.NoError(t, err)
+		require.NoError(t, HandlePacket(buf))
+		buf, err = mockFileClosePacket(3, 0x1111, 143152967831384, mockStatOps(1, 0, 0, 0), 100, 0, 0)
+		require.NoError(t, err)
+		require.NoError(t, HandlePacket(buf))
+		require.True(t, sessions.Has(UserId{Id: 0x12345678, Stod: mockStod}))
+
+		closed := testutil.ToFloat64(SessionsClosed.WithLabelValues(instanceLabel(int64(mockStod)), "https"))
+		buf, err = mockFileDisconnectPacket(4, 0x12345678, 143152967831384, false)
+		require.NoError(t, err)
+		require.NoError(t, HandlePacket(buf))
+		assert.False(t, sessions.Has(UserId{Id: 0x12345678, Stod: mockStod}))
+		assert.False(t, userids.Has(mockXrdUserId))
+		assert.Equal(t, closed+1, testutil.ToFloat64(SessionsClosed.WithLabelValues(instanceLabel(int64(mockStod)), "https")))
+
+		// The disconnect of an unknown session is still counted
+		closed = testutil.ToFloat64(SessionsClosed.WithLabelValues(instanceLabel(int64(mockStod)), ""))
+		buf, err = mockFileDisconnectPacket(5, 0x87654321, 143152967831384, true)
+		require.NoError(t, err)
+		require.NoError(t, HandlePacket(buf))
+		assert.Equal(t, closed+1, testutil.ToFloat64(SessionsClosed.WithLabelValues(instanceLabel(int64(mockStod)), "")))
+	})
+
 	t.Run("token-packet-updates-session", func(t *testing.T) {
 		mockUserRecord := UserRecord{
 			AuthenticationProtocol: "https",
@@ -660,13 +853,12 @@ func TestHandlePacket(t *testing.T) {
 		}
 		mockUserInfo := []byte(getUserIdString(mockXrdUserId) + "\n" + getAuthInfoString(mockUserRecord))
 		mockTokenInfo := []byte(getUserIdString(mockXrdUserId) + "\n" + getTokenAuthString(0x12345678, mockTokenRecord))
-		unixtime := int32(time.Now().Unix())
 		mockMonMap1 := XrdXrootdMonMap{
 			Hdr: XrdXrootdMonHeader{
 				Code: 'u',
 				Pseq: 1,
 				Plen: uint16(12 + len(mockUserInfo)),
-				Stod: unixtime,
+				Stod: mockStod,
 			},
 			Dictid: uint32(0x12345678),
 			Info:   mockUserInfo,
@@ -678,7 +870,7 @@ func TestHandlePacket(t *testing.T) {
 				Code: 'T',
 				Pseq: 1,
 				Plen: uint16(12 + len(mockTokenInfo)),
-				Stod: int32(time.Now().Unix()),
+				Stod: mockStod,
 			},
 			Dictid: uint32(0x12345679), // 4B
 			Info:   mockTokenInfo,
@@ -716,6 +908,196 @@ func TestHandlePacket(t *testing.T) {
 
 		sessions.DeleteAll()
 	})
+
+	// With Monitoring.ProjectClaim, the project comes from the token record and is
+	// preferred over the one in the client's appinfo
+	t.Run("token-packet-sets-project", func(t *testing.T) {
+		projectClaim = "wlcg.groups"
+		t.Cleanup(func() {
+			projectClaim = ""
+			sessions.DeleteAll()
+			userids.DeleteAll()
+		})
+		sessions.DeleteAll()
+		userids.DeleteAll()
+
+		mockXrdUserId := XrdUserId{Prot: "https", User: "unknown", Sid: 143152967831384, Host: "fae8c2865de4"}
+		mapPacket := func(code byte, dictid uint32, info string) []byte {
+			mockMonMap := XrdXrootdMonMap{
+				Hdr:    XrdXrootdMonHeader{Code: code, Pseq: 1, Plen: uint16(12 + len(info)), Stod: mockStod},
+				Dictid: dictid,
+				Info:   []byte(info),
+			}
+			buf, err := mockMonMap.Serialize()
+			require.NoError(t, err)
+			return buf
+		}
+		userInfo := getUserIdString(mockXrdUserId) + "\n" + getAuthInfoString(UserRecord{AuthenticationProtocol: "https"})
+		require.NoError(t, HandlePacket(mapPacket('u', 0x12345678, userInfo)))
+		require.NoError(t, HandlePacket(mapPacket('i', 0x12345679, getUserIdString(mockXrdUserId)+"\nclient-project")))
+		assert.Equal(t, "client-project", sessions.Get(UserId{Id: 0x12345678, Stod: mockStod}).Value().Project)
+
+		tokenInfo := getUserIdString(mockXrdUserId) + "\n" + getTokenAuthString(0x12345678, UserRecord{DN: "subject", Groups: []string{"/osg/science", "/other"}})
+		require.NoError(t, HandlePacket(mapPacket('T', 0x1234567a, tokenInfo)))
+		assert.Equal(t, "osg/science", sessions.Get(UserId{Id: 0x12345678, Stod: mockStod}).Value().Project)
+
+		// Later appinfo doesn't override the token's project
+		require.NoError(t, HandlePacket(mapPacket('i', 0x1234567b, getUserIdString(mockXrdUserId)+"\nclient-project")))
+		assert.Equal(t, "osg/science", sessions.Get(UserId{Id: 0x12345678, Stod: mockStod}).Value().Project)
+
+		// A token without the claim keeps the client's project
+		otherXrdUserId := XrdUserId{Prot: "https", User: "unknown", Sid: 143152967831385, Host: "fae8c2865de4"}
+		userInfo = getUserIdString(otherXrdUserId) + "\n" + getAuthInfoString(UserRecord{AuthenticationProtocol: "https"})
+		require.NoError(t, HandlePacket(mapPacket('u', 0x22222222, userInfo)))
+		require.NoError(t, HandlePacket(mapPacket('i', 0x1234567c, getUserIdString(otherXrdUserId)+"\nclient-project")))
+		tokenInfo = getUserIdString(otherXrdUserId) + "\n" + getTokenAuthString(0x22222222, UserRecord{DN: "subject"})
+		require.NoError(t, HandlePacket(mapPacket('T', 0x1234567d, tokenInfo)))
+		assert.Equal(t, "client-project", sessions.Get(UserId{Id: 0x22222222, Stod: mockStod}).Value().Project)
+	})
+}
+
+// The packets of the XRootD daemons reporting to the same port are kept apart by their
+// start time
+func TestXrootdInstances(t *testing.T) {
+	transfers.DeleteAll()
+	sessions.DeleteAll()
+	t.Cleanup(func() {
+		transfers.DeleteAll()
+		sessions.DeleteAll()
+	})
+
+	// Change the start time in the header of a mock packet
+	fromDaemon := func(packet []byte, stod int32) []byte {
+		binary.BigEndian.PutUint32(packet[4:8], uint32(stod))
+		return packet
+	}
+	otherStod := mockStod + 1
+	instance, otherInstance := instanceLabel(int64(mockStod)), instanceLabel(int64(otherStod))
+
+	t.Run("summaries", func(t *testing.T) {
+		summary := func(tos int32, total, in int) []byte {
+			return []byte(fmt.Sprintf(`<statistics tod="1687524138" ver="v5.6.0" src="localhost:1094" tos="%d" pgm="xrootd" ins="anon" pid="1" site="">`+
+				`<stats id="link"><num>0</num><maxn>1</maxn><tot>%d</tot><in>%d</in><out>0</out></stats></statistics>`, tos, total, in))
+		}
+		require.NoError(t, HandlePacket(summary(mockStod, 10, 1000)))
+		require.NoError(t, HandlePacket(summary(otherStod, 2, 300)))
+		require.NoError(t, HandlePacket(summary(mockStod, 12, 1500)))
+		// The totals of one daemon aren't mistaken for a restart of the other
+		assert.Equal(t, 12.0, testutil.ToFloat64(Connections.WithLabelValues(instance)))
+		assert.Equal(t, 1500.0, testutil.ToFloat64(BytesXfer.WithLabelValues(instance, "rx")))
+		assert.Equal(t, 2.0, testutil.ToFloat64(Connections.WithLabelValues(otherInstance)))
+		assert.Equal(t, 300.0, testutil.ToFloat64(BytesXfer.WithLabelValues(otherInstance, "rx")))
+	})
+
+	t.Run("file-ids", func(t *testing.T) {
+		// Both daemons assign the same file ID
+		openPacket, err := mockFileOpenPacket(0, 3001, 10, 143152967831384, "/first/file.txt")
+		require.NoError(t, err)
+		require.NoError(t, HandlePacket(openPacket))
+		openPacket, err = mockFileOpenPacket(0, 3001, 10, 143152967831385, "/second/file.txt")
+		require.NoError(t, err)
+		require.NoError(t, HandlePacket(fromDaemon(openPacket, otherStod)))
+		require.Equal(t, 2, transfers.Len())
+
+		closedFiles := []ClosedFile{}
+		SetFileCloseHandler(func(file ClosedFile) { closedFiles = append(closedFiles, file) })
+		defer SetFileCloseHandler(nil)
+		clsPacket, err := mockFileClosePacket(1, 3001, 143152967831385, mockStatOps(0, 0, 1, 0), 0, 0, 500)
+		require.NoError(t, err)
+		require.NoError(t, HandlePacket(fromDaemon(clsPacket, otherStod)))
+		assert.Equal(t, []ClosedFile{{LFN: "/second/file.txt", WriteBytes: 500}}, closedFiles)
+		assert.True(t, transfers.Has(FileId{Id: 3001, Stod: mockStod}))
+		assert.False(t, transfers.Has(FileId{Id: 3001, Stod: otherStod}))
+	})
+}
+
+func TestTransferHistograms(t *testing.T) {
+	require.NoError(t, setTransferHistograms([]float64{10, 60}, []float64{100, 1000}))
+	t.Cleanup(func() {
+		require.NoError(t, setTransferHistograms(defaultTransferDurationBuckets, defaultTransferSizeBuckets))
+	})
+	transfers.DeleteAll()
+	sessions.DeleteAll()
+
+	openPacket, err := mockFileOpenPacket(0, 1001, 10, 143152967831384, "/full/path/to/file.txt")
+	require.NoError(t, err)
+	clsPacket, err := mockFileClosePacket(1, 1001, 143152967831384, mockStatOps(0, 0, 1, 0), 0, 0, 500)
+	require.NoError(t, err)
+	require.NoError(t, HandlePacket(openPacket))
+	require.NoError(t, HandlePacket(clsPacket))
+
+	expectedSize := `
+	# HELP xrootd_transfer_size_bytes The number of bytes read from or written to a file between its open and close
+	# TYPE xrootd_transfer_size_bytes histogram
+	xrootd_transfer_size_bytes_bucket{instance="%[1]d",path="/",proj="",type="write",le="100"} 0
+	xrootd_transfer_size_bytes_bucket{instance="%[1]d",path="/",proj="",type="write",le="1000"} 1
+	xrootd_transfer_size_bytes_bucket{instance="%[1]d",path="/",proj="",type="write",le="+Inf"} 1
+	xrootd_transfer_size_bytes_sum{instance="%[1]d",path="/",proj="",type="write"} 500
+	xrootd_transfer_size_bytes_count{instance="%[1]d",path="/",proj="",type="write"} 1
+	`
+	require.NoError(t, testutil.CollectAndCompare(currentTransferHistograms.size, strings.NewReader(fmt.Sprintf(expectedSize, mockStod))))
+
+	// The open and close packets' monitoring windows span one or two seconds
+	expectedDuration := `
+	# HELP xrootd_transfer_duration_seconds The time between a file's open and close, with the resolution of XRootD's monitoring windows
+	# TYPE xrootd_transfer_duration_seconds histogram
+	xrootd_transfer_duration_seconds_bucket{instance="%[1]d",path="/",proj="",type="write",le="10"} 1
+	xrootd_transfer_duration_seconds_bucket{instance="%[1]d",path="/",proj="",type="write",le="60"} 1
+	xrootd_transfer_duration_seconds_bucket{instance="%[1]d",path="/",proj="",type="write",le="+Inf"} 1
+	xrootd_transfer_duration_seconds_count{instance="%[1]d",path="/",proj="",type="write"} 1
+	`
+	require.NoError(t, testutil.CollectAndCompare(currentTransferHistograms.duration, strings.NewReader(fmt.Sprintf(expectedDuration, mockStod)),
+		"xrootd_transfer_duration_seconds_bucket", "xrootd_transfer_duration_seconds_count"))
+
+	t.Run("invalid-buckets", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		viper.Set("Monitoring.TransferDurationBuckets", []string{"1x"})
+		assert.Error(t, configureTransferHistograms())
+		viper.Set("Monitoring.TransferDurationBuckets", []string{"1s"})
+		viper.Set("Monitoring.TransferSizeBuckets", []string{"1MB"})
+		assert.Error(t, configureTransferHistograms())
+	})
+}
+
+func TestActiveTransfers(t *testing.T) {
+	oldMonitorPaths := monitorPaths
+	monitorPaths = []PathList{{Paths: []string{"", "*"}}}
+	t.Cleanup(func() { monitorPaths = oldMonitorPaths })
+
+	activeTransfers := func() float64 {
+		return testutil.ToFloat64(ActiveTransfers.WithLabelValues(instanceLabel(int64(mockStod)), "/active"))
+	}
+
+	for fileId := uint32(2001); fileId <= 2002; fileId++ {
+		openPacket, err := mockFileOpenPacket(0, fileId, 10, 143152967831384, "/active/file.txt")
+		require.NoError(t, err)
+		require.NoError(t, HandlePacket(openPacket))
+	}
+	assert.Eventually(t, func() bool { return activeTransfers() == 2 }, time.Second, 10*time.Millisecond)
+
+	// Reopening a file ID replaces its record
+	openPacket, err := mockFileOpenPacket(0, 2002, 10, 143152967831384, "/active/file.txt")
+	require.NoError(t, err)
+	require.NoError(t, HandlePacket(openPacket))
+
+	clsPacket, err := mockFileClosePacket(1, 2001, 143152967831384, mockStatOps(1, 0, 0, 0), 100, 0, 0)
+	require.NoError(t, err)
+	require.NoError(t, HandlePacket(clsPacket))
+	assert.Eventually(t, func() bool { return activeTransfers() == 1 }, time.Second, 10*time.Millisecond)
+
+	// Records that are never closed leave the gauge when they expire or are removed
+	transfers.Delete(FileId{Id: 2002, Stod: mockStod})
+	assert.Eventually(t, func() bool { return activeTransfers() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestGetTokenRecordProject(t *testing.T) {
+	fields := map[string]string{"s": "subject", "o": "https://issuer.example.com", "g": "/proj1 /proj2", "acct": "alloc-42"}
+	assert.Equal(t, "", getTokenRecordProject(fields, ""))
+	assert.Equal(t, "proj1", getTokenRecordProject(fields, "wlcg.groups"))
+	assert.Equal(t, "proj1", getTokenRecordProject(fields, "groups"))
+	assert.Equal(t, "subject", getTokenRecordProject(fields, "sub"))
+	assert.Equal(t, "alloc-42", getTokenRecordProject(fields, "acct"))
+	assert.Equal(t, "", getTokenRecordProject(fields, "missing"))
 }
 
 func TestComputePaths(t *testing.T) {
@@ -727,3 +1109,167 @@ func TestComputePaths(t *testing.T) {
 	assert.Equal(t, "/foo/bar/baz", computePrefix("/foo/bar/baz", []PathList{{Paths: []string{"", "1"}}, {Paths: []string{"", "foo", "*", "baz"}}}))
 	assert.Equal(t, "/foo/bar/baz", computePrefix("/foo/bar/baz", []PathList{{Paths: []string{"", "foo", "*", "*"}}}))
 }
+
+func TestHandleCacheGStream(t *testing.T) {
+	counter := func(vec *prometheus.CounterVec) float64 {
+		return testutil.ToFloat64(vec.WithLabelValues("0", "/"))
+	}
+	hitBefore := testutil.ToFloat64(CacheAccess.WithLabelValues("0", "/", "hit"))
+	diskBefore := counter(CacheDiskWriteBytes)
+	prefetchBefore := counter(CachePrefetchBytes)
+	cksBefore := counter(CachePgReadChecksumErrors)
+
+	// Records are newline-separated; a trailing newline is ignored
+	records := `{"event":"file_close","lfn":"/foo/a","b_hit":300,"b_miss":100,"b_bypass":0,"b_todisk":100,"b_prefetch":40,"n_cks_errs":0}` + "\n" +
+		`{"event":"file_close","lfn":"/foo/b","b_hit":0,"b_miss":50,"b_bypass":50,"b_todisk":50,"b_prefetch":0,"n_cks_errs":2}` + "\n"
+	require.NoError(t, HandlePacket(cacheGStreamPacket(records)))
+
+	assert.Equal(t, 300.0, testutil.ToFloat64(CacheAccess.WithLabelValues("0", "/", "hit"))-hitBefore)
+	assert.Equal(t, 150.0, counter(CacheDiskWriteBytes)-diskBefore)
+	assert.Equal(t, 40.0, counter(CachePrefetchBytes)-prefetchBefore)
+	assert.Equal(t, 2.0, counter(CachePgReadChecksumErrors)-cksBefore)
+
+	assert.Error(t, HandlePacket(cacheGStreamPacket(`{"lfn": `)))
+}
+
+// Build an r-stream redirect entry with the given target
+func redirectEntry(entryType byte, port uint16, target string) []byte {
+	targetBytes := []byte(target + "\x00")
+	dent := (len(targetBytes) + 7) / 8
+	entry := make([]byte, 8+dent*8)
+	entry[0] = entryType
+	entry[1] = byte(dent)
+	binary.BigEndian.PutUint16(entry[2:4], port)
+	binary.BigEndian.PutUint32(entry[4:8], 7)
+	copy(entry[8:], targetBytes)
+	return entry
+}
+
+func redirectPacket(entries ...[]byte) []byte {
+	packet := make([]byte, 16)
+	packet[0] = 'r'
+	packet[8] = XROOTD_MON_REDSID
+	for _, entry := range entries {
+		packet = append(packet, entry...)
+	}
+	binary.BigEndian.PutUint16(packet[2:4], uint16(len(packet)))
+	return packet
+}
+
+func TestHandleRedirectPacket(t *testing.T) {
+	oldPaths := monitorPaths
+	monitorPaths = []PathList{{Paths: []string{"", "*"}}}
+	t.Cleanup(func() { monitorPaths = oldPaths })
+
+	redirects := func(host, path string) float64 {
+		return testutil.ToFloat64(Redirects.WithLabelValues("0", host, path))
+	}
+	remoteBefore := redirects("origin.example.org:1094", "/foo")
+	localBefore := redirects("[::1]:1095", "/baz")
+	noPortBefore := redirects("origin.example.org", "/foo")
+
+	timeMark := make([]byte, 8)
+	unknown := []byte{0x30, 0, 0, 0, 0, 0, 0, 0}
+	packet := redirectPacket(
+		timeMark,
+		redirectEntry(XROOTD_MON_REDIRECT|0x01, 1094, "origin.example.org:/foo/bar?authz=secret"),
+		unknown,
+		redirectEntry(XROOTD_MON_REDLOCAL|0x02, 1095, "[::1]:/baz/file"),
+		redirectEntry(XROOTD_MON_REDIRECT, 1094, "origin.example.org:/foo/other"),
+		redirectEntry(XROOTD_MON_REDIRECT, 0, "origin.example.org:/foo"),
+	)
+	require.NoError(t, HandlePacket(packet))
+
+	assert.Equal(t, 2.0, redirects("origin.example.org:1094", "/foo")-remoteBefore)
+	assert.Equal(t, 1.0, redirects("[::1]:1095", "/baz")-localBefore)
+	assert.Equal(t, 1.0, redirects("origin.example.org", "/foo")-noPortBefore)
+
+	t.Run("truncated-entry", func(t *testing.T) {
+		entry := redirectEntry(XROOTD_MON_REDIRECT, 1094, "origin.example.org:/foo/bar")
+		assert.Error(t, HandlePacket(redirectPacket(entry[:len(entry)-8])))
+	})
+
+	t.Run("parse-target", func(t *testing.T) {
+		host, path := parseRedirectTarget("host.example.org:/a/b?x=y")
+		assert.Equal(t, "host.example.org", host)
+		assert.Equal(t, "/a/b", path)
+		host, path = parseRedirectTarget("[2001:db8::1]:/a")
+		assert.Equal(t, "2001:db8::1", host)
+		assert.Equal(t, "/a", path)
+		host, path = parseRedirectTarget("host.example.org")
+		assert.Equal(t, "host.example.org", host)
+		assert.Equal(t, "", path)
+	})
+}
+
+func TestCacheStatsEvent(t *testing.T) {
+	delete(lastTotals, "0")
+	t.Cleanup(func() { delete(lastTotals, "0") })
+	hitRatio := CacheHitRatio.WithLabelValues("0")
+	prefetchBytes := CacheServerPrefetchBytes.WithLabelValues("0")
+	evictionCount := CacheEvictions.WithLabelValues("0")
+	evictedBytes := CacheEvictedBytes.WithLabelValues("0")
+	prefetch := testutil.ToFloat64(prefetchBytes)
+	evictions := testutil.ToFloat64(evictionCount)
+	evicted := testutil.ToFloat64(evictedBytes)
+
+	records := `{"event":"cache_stats","b_hit":300,"b_miss":100,"b_bypass":5,"b_prefetch":1000,"n_evict":2,"b_evict":4096}`
+	require.NoError(t, HandlePacket(cacheGStreamPacket(records)))
+	assert.Equal(t, 0.75, testutil.ToFloat64(hitRatio))
+	assert.Equal(t, prefetch+1000, testutil.ToFloat64(prefetchBytes))
+	assert.Equal(t, evictions+2, testutil.ToFloat64(evictionCount))
+	assert.Equal(t, evicted+4096, testutil.ToFloat64(evictedBytes))
+
+	// The totals only count the increments since the previous record; the hit ratio is of
+	// the bytes read in between.  Unknown events are ignored.
+	records = `{"event":"cache_stats","b_hit":400,"b_miss":400,"b_prefetch":1500,"n_evict":3,"b_evict":5000}` + "\n" +
+		`{"event":"purge_stats","n_files":10}`
+	require.NoError(t, HandlePacket(cacheGStreamPacket(records)))
+	assert.Equal(t, 0.25, testutil.ToFloat64(hitRatio))
+	assert.Equal(t, prefetch+1500, testutil.ToFloat64(prefetchBytes))
+	assert.Equal(t, evictions+3, testutil.ToFloat64(evictionCount))
+	assert.Equal(t, evicted+5000, testutil.ToFloat64(evictedBytes))
+
+	// Without reads in between, the hit ratio is kept
+	records = `{"event":"cache_stats","b_hit":400,"b_miss":400,"b_prefetch":1500,"n_evict":3,"b_evict":5000}`
+	require.NoError(t, HandlePacket(cacheGStreamPacket(records)))
+	assert.Equal(t, 0.25, testutil.ToFloat64(hitRatio))
+
+	// After a restart, the totals start over
+	records = `{"event":"cache_stats","b_hit":90,"b_miss":10,"b_prefetch":100,"n_evict":1,"b_evict":10}`
+	require.NoError(t, HandlePacket(cacheGStreamPacket(records)))
+	assert.Equal(t, 0.9, testutil.ToFloat64(hitRatio))
+	assert.Equal(t, prefetch+1600, testutil.ToFloat64(prefetchBytes))
+	assert.Equal(t, evictions+4, testutil.ToFloat64(evictionCount))
+	assert.Equal(t, evicted+5010, testutil.ToFloat64(evictedBytes))
+}
+
+func TestTransferLabelActions(t *testing.T) {
+	t.Cleanup(func() {
+		viper.Reset()
+		transferLabelActions = nil
+	})
+
+	viper.Set("Monitoring.TransferLabelActions", map[string]string{"dn": "hash", "path": "drop"})
+	require.NoError(t, configureTransferLabels())
+	labels := prometheus.Labels{"path": "/foo/bar", "dn": "alice", "proj": "ligo", "org": "", "type": "read"}
+	relabelTransfer(labels)
+	assert.Equal(t, "", labels["path"])
+	assert.Equal(t, hashLabelValue("alice"), labels["dn"])
+	assert.Len(t, labels["dn"], 16)
+	assert.Equal(t, "ligo", labels["proj"])
+	assert.Equal(t, "read", labels["type"])
+
+	// Empty values aren't hashed
+	viper.Set("Monitoring.TransferLabelActions", map[string]string{"org": "hash"})
+	require.NoError(t, configureTransferLabels())
+	relabelTransfer(labels)
+	assert.Equal(t, "", labels["org"])
+
+	t.Run("invalid-actions", func(t *testing.T) {
+		viper.Set("Monitoring.TransferLabelActions", map[string]string{"type": "drop"})
+		assert.Error(t, configureTransferLabels())
+		viper.Set("Monitoring.TransferLabelActions", map[string]string{"dn": "encrypt"})
+		assert.Error(t, configureTransferLabels())
+	})
+}
@@ -88,7 +88,10 @@ type Namespace struct {
 	ReadHTTPS            bool                  `json:"readhttps"`
 	UseTokenOnRead       bool                  `json:"usetokenonread"`
 	WriteBackHost        string                `json:"writebackhost"`
+	UploadUrl            string                `json:"uploadurl,omitempty"` // The origin's resumable upload API, if it has one
 	DirListHost          string                `json:"dirlisthost"`
+	TapeBacked           bool                  `json:"tapebacked,omitempty"` // Objects may need to be staged from tape before reading
+	StageUrl             string                `json:"stageurl,omitempty"`   // The stage API of an origin of a tape-backed namespace
 }
 
 // GetCaches returns the list of caches for the namespace
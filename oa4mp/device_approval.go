@@ -0,0 +1,435 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package oa4mp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+type (
+	deviceRequestStatus string
+
+	// A device authorization request issued by OA4MP, as observed by the proxy
+	deviceRequest struct {
+		UserCode       string              `json:"userCode"`
+		ClientID       string              `json:"clientId"`
+		ClientIP       string              `json:"clientIp"`
+		Scope          string              `json:"scope"`
+		TrustedNetwork bool                `json:"trustedNetwork"`
+		Status         deviceRequestStatus `json:"status"`
+		User           string              `json:"user,omitempty"`     // User who last attempted to verify the code
+		Reviewer       string              `json:"reviewer,omitempty"` // Admin who approved or denied the code
+		CreatedAt      time.Time           `json:"createdAt"`
+		ExpiresAt      time.Time           `json:"expiresAt"`
+		DeviceCodeHash string              `json:"-"` // SHA-256 of the device code the client polls with
+	}
+
+	// A device request as saved in the state file, which keeps the hash of the device
+	// code hidden from the admin API
+	savedDeviceRequest struct {
+		deviceRequest
+		CodeHash string `json:"deviceCodeHash"`
+	}
+)
+
+const (
+	deviceAuthPath   = "/api/v1.0/issuer/device_authorization"
+	deviceVerifyPath = "/api/v1.0/issuer/device"
+	deviceTokenPath  = "/api/v1.0/issuer/token"
+
+	deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+	// The largest form body the proxy reads before forwarding a request to OA4MP
+	maxIssuerFormSize = 64 * 1024
+
+	// Used when OA4MP's response does not include the lifetime of the device code
+	defaultDeviceCodeLifetime = 15 * time.Minute
+
+	deviceStatusPending  deviceRequestStatus = "pending"  // Waiting on the user; no admin approval needed
+	deviceStatusHeld     deviceRequestStatus = "held"     // Waiting on an admin to approve
+	deviceStatusApproved deviceRequestStatus = "approved" // Approved by an admin
+	deviceStatusDenied   deviceRequestStatus = "denied"   // Denied by an admin
+)
+
+var (
+	// Outstanding device codes, keyed by the normalized user code
+	deviceRequests = ttlcache.New(
+		ttlcache.WithTTL[string, deviceRequest](defaultDeviceCodeLifetime),
+		ttlcache.WithDisableTouchOnHit[string, deviceRequest](),
+	)
+
+	// Per-address rate limiters for the device authorization endpoint; the
+	// limiters of addresses idle for the TTL are evicted
+	deviceLimiters = ttlcache.New(
+		ttlcache.WithTTL[string, *rate.Limiter](10 * time.Minute),
+	)
+
+	// Serializes the writes of the device request state file
+	deviceRequestsFileMutex sync.Mutex
+)
+
+// Normalize a user code as typed by a human: OA4MP ignores case and the
+// separator characters so we do the same.
+func normalizeUserCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '_', '-', ' ':
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(code)))
+}
+
+// Read the form values of a request without consuming its body so
+// the request can still be forwarded to OA4MP.  Fails if the body is
+// larger than maxIssuerFormSize.
+func peekForm(w http.ResponseWriter, req *http.Request) (url.Values, error) {
+	values := req.URL.Query()
+	if req.Body == nil || !strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		return values, nil
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxIssuerFormSize))
+	req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return values, errors.Wrap(err, "failed to read the body of the issuer request")
+	}
+	if formValues, err := url.ParseQuery(string(body)); err == nil {
+		for key, vals := range formValues {
+			values[key] = append(values[key], vals...)
+		}
+	}
+	return values, nil
+}
+
+// Peek at the form of the request, aborting it if the body can't be read
+func peekFormOrAbort(ctx *gin.Context) (url.Values, bool) {
+	form, err := peekForm(ctx.Writer, ctx.Request)
+	if err != nil {
+		log.Debugln("Rejecting issuer request:", err)
+		ctx.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "The request body is too large",
+		})
+		return nil, false
+	}
+	return form, true
+}
+
+func hashDeviceCode(deviceCode string) string {
+	digest := sha256.Sum256([]byte(deviceCode))
+	return hex.EncodeToString(digest[:])
+}
+
+// The file the outstanding device requests are saved to, next to OA4MP's own storage,
+// so the held codes stay held across restarts
+func deviceRequestsFile() string {
+	return filepath.Join(param.Issuer_ScitokensServerLocation.GetString(), "var", "device_requests.json")
+}
+
+// Save the outstanding device requests to the state file
+func saveDeviceRequests() {
+	deviceRequestsFileMutex.Lock()
+	defer deviceRequestsFileMutex.Unlock()
+
+	saved := make([]savedDeviceRequest, 0, deviceRequests.Len())
+	for _, item := range deviceRequests.Items() {
+		if !item.IsExpired() {
+			saved = append(saved, savedDeviceRequest{deviceRequest: item.Value(), CodeHash: item.Value().DeviceCodeHash})
+		}
+	}
+	contents, err := json.Marshal(saved)
+	if err != nil {
+		log.Warningln("Failed to serialize the device requests:", err)
+		return
+	}
+	filename := deviceRequestsFile()
+	if err = os.MkdirAll(filepath.Dir(filename), 0750); err != nil {
+		log.Warningln("Failed to create the directory of the device request state file:", err)
+		return
+	}
+	tmpFile := filename + ".tmp"
+	if err = os.WriteFile(tmpFile, contents, 0600); err == nil {
+		err = os.Rename(tmpFile, filename)
+	}
+	if err != nil {
+		log.Warningln("Failed to save the device requests:", err)
+	}
+}
+
+// Load the device requests saved before a restart, skipping the expired ones
+func loadDeviceRequests() error {
+	contents, err := os.ReadFile(deviceRequestsFile())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "failed to read the device request state file")
+	}
+	saved := []savedDeviceRequest{}
+	if err = json.Unmarshal(contents, &saved); err != nil {
+		return errors.Wrap(err, "failed to parse the device request state file")
+	}
+	for _, devReq := range saved {
+		lifetime := time.Until(devReq.ExpiresAt)
+		if lifetime <= 0 {
+			continue
+		}
+		devReq.deviceRequest.DeviceCodeHash = devReq.CodeHash
+		deviceRequests.Set(normalizeUserCode(devReq.UserCode), devReq.deviceRequest, lifetime)
+	}
+	return nil
+}
+
+// Returns true if the client address is within Issuer.DeviceTrustedNetworks
+func isTrustedDeviceNetwork(clientIP string) bool {
+	addr, err := netip.ParseAddr(clientIP)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, network := range param.Issuer_DeviceTrustedNetworks.GetStringSlice() {
+		network = strings.TrimSpace(network)
+		if prefix, err := netip.ParsePrefix(network); err == nil {
+			if prefix.Contains(addr) {
+				return true
+			}
+		} else if trusted, err := netip.ParseAddr(network); err == nil {
+			if trusted.Unmap() == addr {
+				return true
+			}
+		} else {
+			log.Warningf("Ignoring invalid entry %q in Issuer.DeviceTrustedNetworks", network)
+		}
+	}
+	return false
+}
+
+// Middleware limiting the rate of device authorization requests per client.
+//
+// A client is identified by its IP address alone; the client ID is chosen by
+// the requester, so limiting on it would let a client dodge the limit by
+// rotating IDs.  All other issuer requests are passed through untouched.
+func deviceRateLimitHandler(ctx *gin.Context) {
+	limit := param.Issuer_DeviceAuthRateLimit.GetInt()
+	if ctx.Request.URL.Path != deviceAuthPath || limit <= 0 {
+		ctx.Next()
+		return
+	}
+	clientIP := ctx.ClientIP()
+	item, _ := deviceLimiters.GetOrSet(clientIP, rate.NewLimiter(rate.Every(time.Minute/time.Duration(limit)), limit))
+	if !item.Value().Allow() {
+		log.Warningf("Rate limiting device authorization requests from %s", clientIP)
+		ctx.AbortWithStatusJSON(http.StatusTooManyRequests, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Too many device authorization requests; try again later",
+		})
+		return
+	}
+	ctx.Next()
+}
+
+// Record a device code issued by OA4MP so it is visible to admins and,
+// if needed, held for admin approval.
+func recordDeviceRequest(form url.Values, clientIP string, respBody []byte) {
+	var resp struct {
+		DeviceCode string `json:"device_code"`
+		UserCode   string `json:"user_code"`
+		ExpiresIn  int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil || resp.UserCode == "" {
+		log.Debugln("Unable to determine user code from device authorization response:", err)
+		return
+	}
+	lifetime := defaultDeviceCodeLifetime
+	if resp.ExpiresIn > 0 {
+		lifetime = time.Duration(resp.ExpiresIn) * time.Second
+	}
+	now := time.Now()
+	devReq := deviceRequest{
+		UserCode:       resp.UserCode,
+		ClientID:       form.Get("client_id"),
+		ClientIP:       clientIP,
+		Scope:          form.Get("scope"),
+		TrustedNetwork: isTrustedDeviceNetwork(clientIP),
+		Status:         deviceStatusPending,
+		CreatedAt:      now,
+		ExpiresAt:      now.Add(lifetime),
+		DeviceCodeHash: hashDeviceCode(resp.DeviceCode),
+	}
+	if param.Issuer_DeviceApprovalRequired.GetBool() && !devReq.TrustedNetwork {
+		devReq.Status = deviceStatusHeld
+		log.Infof("Holding device code %s requested by client %q from untrusted address %s for admin approval",
+			devReq.UserCode, devReq.ClientID, clientIP)
+	}
+	deviceRequests.Set(normalizeUserCode(resp.UserCode), devReq, lifetime)
+	saveDeviceRequests()
+}
+
+// Check whether the user code being verified may proceed to OA4MP.  Aborts
+// the request and returns false if the code is waiting on or was denied by an admin.
+// When approval is required, codes the proxy didn't see issued are rejected too.
+func checkDeviceApproval(ctx *gin.Context, user string) bool {
+	form, ok := peekFormOrAbort(ctx)
+	if !ok {
+		return false
+	}
+	approvalRequired := param.Issuer_DeviceApprovalRequired.GetBool()
+	userCode := normalizeUserCode(form.Get("user_code"))
+	if userCode == "" {
+		// Without a code, the request can only fetch OA4MP's verification form
+		if !approvalRequired || ctx.Request.Method == http.MethodGet || ctx.Request.Method == http.MethodHead {
+			return true
+		}
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "No device code was given",
+		})
+		return false
+	}
+	item := deviceRequests.Get(userCode)
+	if item == nil {
+		if !approvalRequired {
+			return true
+		}
+		log.Infof("User %s attempted to verify the unknown device code %s", user, userCode)
+		ctx.AbortWithStatusJSON(http.StatusForbidden, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "This device code is unknown or has expired",
+		})
+		return false
+	}
+	devReq := item.Value()
+	devReq.User = user
+	deviceRequests.Set(userCode, devReq, time.Until(devReq.ExpiresAt))
+	saveDeviceRequests()
+	switch devReq.Status {
+	case deviceStatusHeld:
+		log.Infof("User %s attempted to verify device code %s, which is awaiting admin approval", user, devReq.UserCode)
+		ctx.AbortWithStatusJSON(http.StatusForbidden, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "This device code was requested from an unrecognized network and is awaiting approval by a server administrator",
+		})
+		return false
+	case deviceStatusDenied:
+		ctx.AbortWithStatusJSON(http.StatusForbidden, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "This device code was denied by a server administrator",
+		})
+		return false
+	}
+	return true
+}
+
+// Check whether a client polling the token endpoint with a device code may be issued
+// a token, answering the poll with an OAuth2 error (RFC 8628) while the code is held
+// and once it's denied.  Other token requests are passed through.
+func checkDeviceTokenRequest(ctx *gin.Context) bool {
+	form, ok := peekFormOrAbort(ctx)
+	if !ok {
+		return false
+	}
+	if form.Get("grant_type") != deviceCodeGrantType {
+		return true
+	}
+	codeHash := hashDeviceCode(form.Get("device_code"))
+	var devReq *deviceRequest
+	for _, item := range deviceRequests.Items() {
+		if value := item.Value(); !item.IsExpired() && value.DeviceCodeHash == codeHash {
+			devReq = &value
+			break
+		}
+	}
+
+	oauthError := ""
+	switch {
+	case devReq == nil:
+		if param.Issuer_DeviceApprovalRequired.GetBool() {
+			oauthError = "expired_token"
+		}
+	case devReq.Status == deviceStatusHeld:
+		oauthError = "authorization_pending"
+	case devReq.Status == deviceStatusDenied:
+		oauthError = "access_denied"
+	}
+	if oauthError == "" {
+		return true
+	}
+	ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": oauthError})
+	return false
+}
+
+// List the outstanding device codes, newest first
+func listDeviceRequests(ctx *gin.Context) {
+	result := make([]deviceRequest, 0, deviceRequests.Len())
+	for _, item := range deviceRequests.Items() {
+		if item.IsExpired() {
+			continue
+		}
+		result = append(result, item.Value())
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	ctx.JSON(http.StatusOK, result)
+}
+
+func reviewDeviceRequest(status deviceRequestStatus) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userCode := normalizeUserCode(ctx.Param("code"))
+		item := deviceRequests.Get(userCode)
+		if item == nil {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "No outstanding device code matches " + ctx.Param("code"),
+			})
+			return
+		}
+		devReq := item.Value()
+		devReq.Status = status
+		devReq.Reviewer = ctx.GetString("User")
+		deviceRequests.Set(userCode, devReq, time.Until(devReq.ExpiresAt))
+		saveDeviceRequests()
+		log.Infof("Device code %s was %s by %s", devReq.UserCode, status, devReq.Reviewer)
+		ctx.JSON(http.StatusOK, server_structs.SimpleApiResp{
+			Status: server_structs.RespOK,
+			Msg:    "Device code " + devReq.UserCode + " " + string(status),
+		})
+	}
+}
@@ -0,0 +1,217 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package oa4mp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeUserCode(t *testing.T) {
+	assert.Equal(t, "ABC123DEF", normalizeUserCode("abc_123_def"))
+	assert.Equal(t, "ABC123DEF", normalizeUserCode(" ABC-123 DEF "))
+	assert.Equal(t, "", normalizeUserCode(""))
+}
+
+func TestIsTrustedDeviceNetwork(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	viper.Set("Issuer.DeviceTrustedNetworks", []string{"192.0.2.0/24", "2001:db8::1", "not-a-network"})
+
+	assert.True(t, isTrustedDeviceNetwork("192.0.2.15"))
+	assert.True(t, isTrustedDeviceNetwork("::ffff:192.0.2.15"))
+	assert.True(t, isTrustedDeviceNetwork("2001:db8::1"))
+	assert.False(t, isTrustedDeviceNetwork("2001:db8::2"))
+	assert.False(t, isTrustedDeviceNetwork("198.51.100.1"))
+	assert.False(t, isTrustedDeviceNetwork("garbage"))
+}
+
+func TestDeviceRateLimit(t *testing.T) {
+	t.Cleanup(func() {
+		viper.Reset()
+		deviceLimiters.DeleteAll()
+	})
+	viper.Set("Issuer.DeviceAuthRateLimit", 2)
+
+	engine := gin.New()
+	engine.Any("/api/v1.0/issuer/*path", deviceRateLimitHandler, func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	doRequest := func(path, clientID, remoteAddr string) int {
+		form := url.Values{"client_id": {clientID}}
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, doRequest(deviceAuthPath, "client1", "198.51.100.1:1234"))
+	assert.Equal(t, http.StatusOK, doRequest(deviceAuthPath, "client1", "198.51.100.1:1234"))
+	assert.Equal(t, http.StatusTooManyRequests, doRequest(deviceAuthPath, "client1", "198.51.100.1:1234"))
+
+	// Switching client IDs doesn't get around the limit
+	assert.Equal(t, http.StatusTooManyRequests, doRequest(deviceAuthPath, "client2", "198.51.100.1:1234"))
+	assert.Equal(t, 1, deviceLimiters.Len())
+
+	// Other addresses and other endpoints are unaffected
+	assert.Equal(t, http.StatusOK, doRequest(deviceAuthPath, "client1", "198.51.100.2:1234"))
+	assert.Equal(t, http.StatusOK, doRequest("/api/v1.0/issuer/token", "client1", "198.51.100.1:1234"))
+}
+
+func TestDeviceApproval(t *testing.T) {
+	t.Cleanup(func() {
+		viper.Reset()
+		deviceRequests.DeleteAll()
+	})
+	viper.Set("Issuer.DeviceApprovalRequired", true)
+	viper.Set("Issuer.DeviceTrustedNetworks", []string{"192.0.2.0/24"})
+	viper.Set("Issuer.ScitokensServerLocation", t.TempDir())
+
+	form := url.Values{"client_id": {"client1"}, "scope": {"openid"}}
+	recordDeviceRequest(form, "198.51.100.1", []byte(`{"device_code": "device1", "user_code": "ABC_123_DEF", "expires_in": 600}`))
+	recordDeviceRequest(form, "192.0.2.1", []byte(`{"device_code": "device2", "user_code": "XYZ_987_XYZ"}`))
+	recordDeviceRequest(form, "192.0.2.1", []byte(`not json`))
+	require.Equal(t, 2, deviceRequests.Len())
+
+	engine := gin.New()
+	engine.POST(deviceVerifyPath, func(ctx *gin.Context) {
+		if checkDeviceApproval(ctx, "user1") {
+			ctx.Status(http.StatusOK)
+		}
+	})
+	engine.POST(deviceTokenPath, func(ctx *gin.Context) {
+		if checkDeviceTokenRequest(ctx) {
+			ctx.Status(http.StatusOK)
+		}
+	})
+	engine.GET("/device_requests", listDeviceRequests)
+	engine.POST("/device_requests/:code/approve", reviewDeviceRequest(deviceStatusApproved))
+	engine.POST("/device_requests/:code/deny", reviewDeviceRequest(deviceStatusDenied))
+
+	verify := func(userCode string) int {
+		form := url.Values{"user_code": {userCode}}
+		req := httptest.NewRequest(http.MethodPost, deviceVerifyPath, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w.Code
+	}
+	poll := func(deviceCode string) (int, string) {
+		form := url.Values{"grant_type": {deviceCodeGrantType}, "device_code": {deviceCode}}
+		req := httptest.NewRequest(http.MethodPost, deviceTokenPath, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		var resp struct {
+			Error string `json:"error"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.Error
+	}
+	review := func(path string) int {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+		return w.Code
+	}
+
+	// Codes from trusted networks go straight through
+	assert.Equal(t, http.StatusOK, verify("xyz_987_xyz"))
+
+	// Codes unknown to the proxy, or missing, are rejected when approval is required
+	assert.Equal(t, http.StatusForbidden, verify("AAA_BBB_CCC"))
+	assert.Equal(t, http.StatusBadRequest, verify(""))
+
+	// Codes from untrusted networks are held until approved, and so are the polls for their tokens
+	assert.Equal(t, http.StatusForbidden, verify("abc_123_def"))
+	code, oauthErr := poll("device1")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "authorization_pending", oauthErr)
+	code, oauthErr = poll("unknown")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "expired_token", oauthErr)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/device_requests", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var listed []deviceRequest
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	require.Len(t, listed, 2)
+	statuses := map[string]deviceRequest{}
+	for _, devReq := range listed {
+		statuses[devReq.UserCode] = devReq
+	}
+	assert.Equal(t, deviceStatusHeld, statuses["ABC_123_DEF"].Status)
+	assert.Equal(t, "user1", statuses["ABC_123_DEF"].User)
+	assert.Equal(t, "client1", statuses["ABC_123_DEF"].ClientID)
+	assert.False(t, statuses["ABC_123_DEF"].TrustedNetwork)
+	assert.Equal(t, deviceStatusPending, statuses["XYZ_987_XYZ"].Status)
+	assert.True(t, statuses["XYZ_987_XYZ"].TrustedNetwork)
+
+	// Holds survive a restart of the proxy
+	deviceRequests.DeleteAll()
+	require.NoError(t, loadDeviceRequests())
+	require.Equal(t, 2, deviceRequests.Len())
+	assert.Equal(t, http.StatusForbidden, verify("abc_123_def"))
+
+	assert.Equal(t, http.StatusNotFound, review("/device_requests/AAA_BBB_CCC/approve"))
+	assert.Equal(t, http.StatusOK, review("/device_requests/ABC123DEF/approve"))
+	assert.Equal(t, http.StatusOK, verify("abc_123_def"))
+	code, _ = poll("device1")
+	assert.Equal(t, http.StatusOK, code)
+
+	assert.Equal(t, http.StatusOK, review("/device_requests/XYZ_987_XYZ/deny"))
+	assert.Equal(t, http.StatusForbidden, verify("xyz_987_xyz"))
+	code, oauthErr = poll("device2")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "access_denied", oauthErr)
+
+	// Oversized forms are refused rather than read into memory
+	req := httptest.NewRequest(http.MethodPost, deviceVerifyPath, strings.NewReader("user_code="+strings.Repeat("A", maxIssuerFormSize)))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestConfigureOA4MPProxyRoutes(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	viper.Set("Issuer.ScitokensServerLocation", t.TempDir())
+	engine := gin.New()
+	require.NoError(t, ConfigureOA4MPProxy(engine))
+	t.Cleanup(func() {
+		deviceRequests.Stop()
+		deviceLimiters.Stop()
+	})
+
+	// The admin APIs must not be swallowed by the issuer proxy
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1.0/issuer_ui/device_requests", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -19,12 +19,14 @@
 package oa4mp
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
 	"io"
 	"net"
 	"net/http"
+	"net/url"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -72,7 +74,17 @@ func oa4mpProxy(ctx *gin.Context) {
 	var userEncoded string
 	var user string
 	var groupsList []string
-	if ctx.Request.URL.Path == "/api/v1.0/issuer/device" {
+	var deviceAuthForm url.Values
+	if ctx.Request.URL.Path == deviceAuthPath {
+		var ok bool
+		if deviceAuthForm, ok = peekFormOrAbort(ctx); !ok {
+			return
+		}
+	}
+	if ctx.Request.URL.Path == deviceTokenPath && !checkDeviceTokenRequest(ctx) {
+		return
+	}
+	if ctx.Request.URL.Path == deviceVerifyPath {
 		web_ui.RequireAuthMiddleware(ctx)
 		if ctx.IsAborted() {
 			return
@@ -108,6 +120,10 @@ func oa4mpProxy(ctx *gin.Context) {
 			return
 		}
 		userEncoded = base64.StdEncoding.EncodeToString(userBytes)
+
+		if !checkDeviceApproval(ctx, user) {
+			return
+		}
 	}
 
 	origPath := ctx.Request.URL.Path
@@ -138,9 +154,21 @@ func oa4mpProxy(ctx *gin.Context) {
 	}
 	defer resp.Body.Close()
 
+	var body io.Reader = resp.Body
+	if deviceAuthForm != nil && resp.StatusCode == http.StatusOK {
+		// Buffer the (small) device authorization response so the issued
+		// user code can be recorded before passing it along to the client
+		respBytes, err := io.ReadAll(resp.Body)
+		if err != nil {
+			log.Warningln("Failed to read device authorization response from OA4MP:", err)
+		}
+		recordDeviceRequest(deviceAuthForm, ctx.ClientIP(), respBytes)
+		body = bytes.NewReader(respBytes)
+	}
+
 	utils.CopyHeader(ctx.Writer.Header(), resp.Header)
 	ctx.Writer.WriteHeader(resp.StatusCode)
-	if _, err = io.Copy(ctx.Writer, resp.Body); err != nil {
+	if _, err = io.Copy(ctx.Writer, body); err != nil {
 		log.Warningln("Failed to copy response body from OA4MP to client:", err)
 	}
 }
@@ -150,8 +178,21 @@ func ConfigureOA4MPProxy(router *gin.Engine) error {
 		return errors.New("Origin configuration passed a nil pointer")
 	}
 
+	if err := loadDeviceRequests(); err != nil {
+		return err
+	}
+	go deviceRequests.Start()
+	go deviceLimiters.Start()
+
 	router.Any("/api/v1.0/issuer", oa4mpProxy)
-	router.Any("/api/v1.0/issuer/*path", oa4mpProxy)
+	router.Any("/api/v1.0/issuer/*path", deviceRateLimitHandler, oa4mpProxy)
+
+	issuerWebAPI := router.Group("/api/v1.0/issuer_ui")
+	{
+		issuerWebAPI.GET("/device_requests", web_ui.AuthHandler, web_ui.AdminAuthHandler, listDeviceRequests)
+		issuerWebAPI.POST("/device_requests/:code/approve", web_ui.AuthHandler, web_ui.AdminAuthHandler, reviewDeviceRequest(deviceStatusApproved))
+		issuerWebAPI.POST("/device_requests/:code/deny", web_ui.AuthHandler, web_ui.AdminAuthHandler, reviewDeviceRequest(deviceStatusDenied))
+	}
 
 	return nil
 }
@@ -0,0 +1,435 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+// Package openapi generates OpenAPI 3 documents describing the gin routes of the
+// Pelican servers from typed route definitions, so that the routes and their
+// documentation are declared in one place.
+package openapi
+
+import (
+	"encoding"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+// The subset of the OpenAPI 3.0 specification needed to describe the Pelican APIs.
+// See https://spec.openapis.org/oas/v3.0.3
+type (
+	Document struct {
+		OpenAPI    string                           `json:"openapi"`
+		Info       Info                             `json:"info"`
+		Servers    []Server                         `json:"servers,omitempty"`
+		Paths      map[string]map[string]*Operation `json:"paths"`
+		Components Components                       `json:"components"`
+
+		builder *schemaBuilder
+	}
+
+	Info struct {
+		Title       string `json:"title"`
+		Description string `json:"description,omitempty"`
+		Version     string `json:"version"`
+	}
+
+	Server struct {
+		URL string `json:"url"`
+	}
+
+	Operation struct {
+		OperationID string               `json:"operationId"`
+		Summary     string               `json:"summary,omitempty"`
+		Tags        []string             `json:"tags,omitempty"`
+		Parameters  []Parameter          `json:"parameters,omitempty"`
+		RequestBody *RequestBody         `json:"requestBody,omitempty"`
+		Responses   map[string]*Response `json:"responses"`
+		// An entry with no schemes makes authentication optional
+		Security []map[string][]string `json:"security,omitempty"`
+	}
+
+	Parameter struct {
+		Name        string  `json:"name"`
+		In          string  `json:"in"` // "path" | "query"
+		Description string  `json:"description,omitempty"`
+		Required    bool    `json:"required,omitempty"`
+		Schema      *Schema `json:"schema"`
+	}
+
+	RequestBody struct {
+		Required bool                 `json:"required"`
+		Content  map[string]MediaType `json:"content"`
+	}
+
+	Response struct {
+		Description string               `json:"description"`
+		Content     map[string]MediaType `json:"content,omitempty"`
+	}
+
+	MediaType struct {
+		Schema *Schema `json:"schema"`
+	}
+
+	Components struct {
+		Schemas         map[string]*Schema        `json:"schemas"`
+		SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+	}
+
+	SecurityScheme struct {
+		Type         string `json:"type"`
+		Description  string `json:"description,omitempty"`
+		Scheme       string `json:"scheme,omitempty"`
+		BearerFormat string `json:"bearerFormat,omitempty"`
+		Name         string `json:"name,omitempty"`
+		In           string `json:"in,omitempty"`
+	}
+
+	Schema struct {
+		Ref                  string             `json:"$ref,omitempty"`
+		AllOf                []*Schema          `json:"allOf,omitempty"`
+		Type                 string             `json:"type,omitempty"`
+		Format               string             `json:"format,omitempty"`
+		Description          string             `json:"description,omitempty"`
+		ReadOnly             bool               `json:"readOnly,omitempty"`
+		Items                *Schema            `json:"items,omitempty"`
+		Properties           map[string]*Schema `json:"properties,omitempty"`
+		AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+		Required             []string           `json:"required,omitempty"`
+	}
+
+	// A gin route and the description of its operation.  The request and response
+	// models are Go values whose types are converted to JSON schemas.
+	Route struct {
+		Method       string
+		Path         string // The gin path of the route, relative to the router group it's registered on
+		OperationID  string
+		Summary      string
+		Tag          string
+		Security     []string // The security schemes, any of which authorizes a request; empty if the route is public
+		AuthOptional bool     // Unauthenticated requests are also accepted
+		PathParams   any      // A struct with `uri` tags describing the path parameters; others are strings
+		Query        any      // A struct with `form` tags describing the query parameters
+		Request      any      // The JSON request body
+		Response     any      // The response body on success
+		Status       int      // The status code on success; defaults to 200
+		ContentType  string   // The content type of the response on success; defaults to application/json
+		Handlers     []gin.HandlerFunc
+	}
+
+	// Builds schemas from Go types, collecting named struct types as reusable components
+	schemaBuilder struct {
+		schemas map[string]*Schema
+		names   map[reflect.Type]string
+	}
+)
+
+var ginPathParamRegex = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Create an empty document
+func NewDocument(info Info) *Document {
+	builder := &schemaBuilder{
+		schemas: map[string]*Schema{},
+		names:   map[reflect.Type]string{},
+	}
+	return &Document{
+		OpenAPI: "3.0.3",
+		Info:    info,
+		Paths:   map[string]map[string]*Operation{},
+		Components: Components{
+			Schemas:         builder.schemas,
+			SecuritySchemes: map[string]SecurityScheme{},
+		},
+		builder: builder,
+	}
+}
+
+// Register the routes with a gin router group
+func RegisterRoutes(router gin.IRoutes, routes []Route) {
+	for _, route := range routes {
+		router.Handle(route.Method, route.Path, route.Handlers...)
+	}
+}
+
+// A gin handler serving the document returned by getDoc.  The document is built for
+// each request so that it reflects the current configuration, such as the server's URL.
+func ServeDocument(getDoc func() *Document) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, getDoc())
+	}
+}
+
+// Add a server URL the document's paths are relative to
+func (doc *Document) AddServer(url string) {
+	doc.Servers = append(doc.Servers, Server{URL: url})
+}
+
+// Add a security scheme that routes may refer to by name in Route.Security
+func (doc *Document) AddSecurityScheme(name string, scheme SecurityScheme) {
+	doc.Components.SecuritySchemes[name] = scheme
+}
+
+// Describe routes registered under basePath in the document
+func (doc *Document) AddRoutes(basePath string, routes []Route) {
+	for _, route := range routes {
+		doc.AddRoute(basePath, route)
+	}
+}
+
+// Describe a route registered under basePath in the document
+func (doc *Document) AddRoute(basePath string, route Route) {
+	path, pathParams := ConvertPath(strings.TrimSuffix(basePath, "/") + route.Path)
+	op := &Operation{
+		OperationID: route.OperationID,
+		Summary:     route.Summary,
+		Responses: map[string]*Response{
+			"default": {
+				Description: "Error",
+				Content:     map[string]MediaType{"application/json": {Schema: doc.builder.schemaFor(reflect.TypeOf(server_structs.SimpleApiResp{}))}},
+			},
+		},
+	}
+	if route.Tag != "" {
+		op.Tags = []string{route.Tag}
+	}
+	op.Parameters = doc.builder.pathParameters(pathParams, route.PathParams)
+	if route.Query != nil {
+		op.Parameters = append(op.Parameters, doc.builder.queryParameters(reflect.TypeOf(route.Query))...)
+	}
+	if route.Request != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"application/json": {Schema: doc.builder.schemaFor(reflect.TypeOf(route.Request))}},
+		}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	successResp := &Response{Description: http.StatusText(status)}
+	if route.Response != nil || route.ContentType != "" {
+		contentType := route.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		schema := &Schema{Type: "string"}
+		if route.Response != nil {
+			schema = doc.builder.schemaFor(reflect.TypeOf(route.Response))
+		}
+		successResp.Content = map[string]MediaType{contentType: {Schema: schema}}
+	}
+	op.Responses[strconv.Itoa(status)] = successResp
+
+	if len(route.Security) > 0 {
+		if route.AuthOptional {
+			op.Security = append(op.Security, map[string][]string{})
+		}
+		for _, scheme := range route.Security {
+			op.Security = append(op.Security, map[string][]string{scheme: {}})
+		}
+	}
+
+	if doc.Paths[path] == nil {
+		doc.Paths[path] = map[string]*Operation{}
+	}
+	doc.Paths[path][strings.ToLower(route.Method)] = op
+}
+
+// Convert a gin route path (e.g. /namespaces/:id) to an OpenAPI path template
+// (e.g. /namespaces/{id}) and return the names of its path parameters
+func ConvertPath(ginPath string) (path string, params []string) {
+	for _, match := range ginPathParamRegex.FindAllStringSubmatch(ginPath, -1) {
+		params = append(params, match[1])
+	}
+	path = ginPathParamRegex.ReplaceAllString(ginPath, "{$1}")
+	return
+}
+
+// The component name of a struct type; unexported type names are capitalized so the
+// generated client bindings have usable names
+func (b *schemaBuilder) componentName(typ reflect.Type) string {
+	if name, ok := b.names[typ]; ok {
+		return name
+	}
+	runes := []rune(typ.Name())
+	runes[0] = unicode.ToUpper(runes[0])
+	base := string(runes)
+	name := base
+	// Disambiguate identically-named types from different packages
+	for idx := 2; b.schemas[name] != nil; idx++ {
+		name = fmt.Sprintf("%s%d", base, idx)
+	}
+	b.names[typ] = name
+	return name
+}
+
+// Get the schema of a Go type, registering any named struct it references as a component
+func (b *schemaBuilder) schemaFor(typ reflect.Type) *Schema {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	switch {
+	case typ == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case reflect.PointerTo(typ).Implements(textMarshalerType):
+		// Types such as netip.Prefix are encoded as JSON strings
+		return &Schema{Type: "string"}
+	case typ.Kind() == reflect.Struct && typ.Name() != "":
+		if _, ok := b.names[typ]; !ok {
+			name := b.componentName(typ)
+			// Reserve the name first so recursive types terminate
+			b.schemas[name] = &Schema{}
+			*b.schemas[name] = *b.structSchema(typ)
+		}
+		return &Schema{Ref: "#/components/schemas/" + b.names[typ]}
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: b.schemaFor(typ.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schemaFor(typ.Elem())}
+	case reflect.Struct:
+		return b.structSchema(typ)
+	default:
+		// interface{} and anything else that can hold arbitrary JSON
+		return &Schema{}
+	}
+}
+
+// Build the object schema of a struct from its json (or, for form-bound requests,
+// form), validate and description tags.  Fields tagged with post:"exclude" are set
+// by the server and marked read-only.
+func (b *schemaBuilder) structSchema(typ reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for idx := 0; idx < typ.NumField(); idx++ {
+		field := typ.Field(idx)
+		jsonTag, hasJsonTag := field.Tag.Lookup("json")
+		if !hasJsonTag {
+			jsonTag = field.Tag.Get("form")
+		}
+		if jsonTag == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+		name, _, _ := strings.Cut(jsonTag, ",")
+		if field.Anonymous && name == "" {
+			embedded := b.structSchema(field.Type)
+			for propName, prop := range embedded.Properties {
+				schema.Properties[propName] = prop
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		prop := b.schemaFor(field.Type)
+		description := field.Tag.Get("description")
+		readOnly := field.Tag.Get("post") == "exclude"
+		if prop.Ref != "" && (description != "" || readOnly) {
+			// Siblings of $ref are ignored in OpenAPI 3.0, so wrap the reference
+			prop = &Schema{AllOf: []*Schema{prop}}
+		}
+		prop.Description = description
+		prop.ReadOnly = readOnly
+		schema.Properties[name] = prop
+		if strings.Contains(field.Tag.Get("validate"), "required") || strings.Contains(field.Tag.Get("binding"), "required") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	sort.Strings(schema.Required)
+	return schema
+}
+
+// Describe the path parameters of a route, using the fields of the pathParams struct
+// with matching `uri` tags, as bound by gin's ShouldBindUri
+func (b *schemaBuilder) pathParameters(names []string, pathParams any) (params []Parameter) {
+	fields := map[string]reflect.StructField{}
+	if pathParams != nil {
+		typ := reflect.TypeOf(pathParams)
+		for typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		for idx := 0; idx < typ.NumField(); idx++ {
+			field := typ.Field(idx)
+			if name, _, _ := strings.Cut(field.Tag.Get("uri"), ","); name != "" {
+				fields[name] = field
+			}
+		}
+	}
+	for _, name := range names {
+		param := Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}}
+		if field, ok := fields[name]; ok {
+			param.Description = field.Tag.Get("description")
+			param.Schema = b.schemaFor(field.Type)
+		}
+		params = append(params, param)
+	}
+	return
+}
+
+// Describe the query parameters of a struct bound with gin's ShouldBindQuery
+func (b *schemaBuilder) queryParameters(typ reflect.Type) (params []Parameter) {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	for idx := 0; idx < typ.NumField(); idx++ {
+		field := typ.Field(idx)
+		name, _, _ := strings.Cut(field.Tag.Get("form"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		params = append(params, Parameter{
+			Name:        name,
+			In:          "query",
+			Description: field.Tag.Get("description"),
+			Required:    strings.Contains(field.Tag.Get("binding"), "required"),
+			Schema:      b.schemaFor(field.Type),
+		})
+	}
+	return
+}
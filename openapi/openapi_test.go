@@ -0,0 +1,157 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package openapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	testChild struct {
+		Name string `json:"name"`
+	}
+
+	testModel struct {
+		testEmbedded
+		ID         int               `json:"id" post:"exclude"`
+		Name       string            `json:"name" validate:"required" description:"The name"`
+		Created    time.Time         `json:"created"`
+		Network    netip.Prefix      `json:"network"`
+		Data       []byte            `json:"data"`
+		Labels     map[string]string `json:"labels"`
+		Child      *testChild        `json:"child" description:"The child"`
+		Children   []testChild       `json:"children"`
+		Parent     *testModel        `json:"parent,omitempty"`
+		Extra      interface{}       `json:"extra"`
+		Ignored    string            `json:"-"`
+		unexported string
+	}
+
+	testEmbedded struct {
+		Embedded bool `json:"embedded"`
+	}
+
+	testQuery struct {
+		Limit  int    `form:"limit" description:"The maximum number of results"`
+		Filter string `form:"filter" binding:"required"`
+	}
+
+	testPathParams struct {
+		ID int `uri:"id"`
+	}
+)
+
+func TestConvertPath(t *testing.T) {
+	path, params := ConvertPath("/api/v1.0/namespaces/:id/objects/*path")
+	assert.Equal(t, "/api/v1.0/namespaces/{id}/objects/{path}", path)
+	assert.Equal(t, []string{"id", "path"}, params)
+
+	path, params = ConvertPath("/api/v1.0/health")
+	assert.Equal(t, "/api/v1.0/health", path)
+	assert.Empty(t, params)
+}
+
+func TestAddRoute(t *testing.T) {
+	doc := NewDocument(Info{Title: "Test", Version: "1.0"})
+	doc.AddRoutes("/api/v1.0/test/", []Route{
+		{
+			Method: http.MethodPost, Path: "/models/:id/:name", OperationID: "updateModel",
+			Security: []string{"cookie"}, PathParams: testPathParams{}, Query: testQuery{},
+			Request: testModel{}, Response: []testModel{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodGet, Path: "/file", OperationID: "getFile", Tag: "files",
+			Security: []string{"cookie"}, AuthOptional: true, ContentType: "text/plain",
+		},
+	})
+
+	op := doc.Paths["/api/v1.0/test/models/{id}/{name}"]["post"]
+	require.NotNil(t, op)
+	require.Len(t, op.Parameters, 4)
+	assert.Equal(t, Parameter{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "integer", Format: "int32"}}, op.Parameters[0])
+	assert.Equal(t, Parameter{Name: "name", In: "path", Required: true, Schema: &Schema{Type: "string"}}, op.Parameters[1])
+	assert.Equal(t, Parameter{Name: "limit", In: "query", Description: "The maximum number of results", Schema: &Schema{Type: "integer", Format: "int32"}}, op.Parameters[2])
+	assert.True(t, op.Parameters[3].Required)
+	assert.Equal(t, []map[string][]string{{"cookie": {}}}, op.Security)
+	assert.Contains(t, op.Responses, "default")
+	require.Contains(t, op.Responses, "201")
+	assert.Equal(t, "#/components/schemas/TestModel", op.Responses["201"].Content["application/json"].Schema.Items.Ref)
+
+	op = doc.Paths["/api/v1.0/test/file"]["get"]
+	require.NotNil(t, op)
+	assert.Equal(t, []string{"files"}, op.Tags)
+	assert.Equal(t, []map[string][]string{{}, {"cookie": {}}}, op.Security)
+	assert.Equal(t, "string", op.Responses["200"].Content["text/plain"].Schema.Type)
+
+	schema := doc.Components.Schemas["TestModel"]
+	require.NotNil(t, schema)
+	assert.Equal(t, []string{"name"}, schema.Required)
+	assert.ElementsMatch(t, []string{"embedded", "id", "name", "created", "network", "data", "labels", "child", "children", "parent", "extra"},
+		func() (names []string) {
+			for name := range schema.Properties {
+				names = append(names, name)
+			}
+			return
+		}())
+	assert.True(t, schema.Properties["id"].ReadOnly)
+	assert.Equal(t, "The name", schema.Properties["name"].Description)
+	assert.Equal(t, &Schema{Type: "string", Format: "date-time"}, schema.Properties["created"])
+	assert.Equal(t, &Schema{Type: "string"}, schema.Properties["network"])
+	assert.Equal(t, &Schema{Type: "string", Format: "byte"}, schema.Properties["data"])
+	assert.Equal(t, &Schema{Type: "object", AdditionalProperties: &Schema{Type: "string"}}, schema.Properties["labels"])
+	assert.Equal(t, &Schema{AllOf: []*Schema{{Ref: "#/components/schemas/TestChild"}}, Description: "The child"}, schema.Properties["child"])
+	assert.Equal(t, "#/components/schemas/TestChild", schema.Properties["children"].Items.Ref)
+	assert.Equal(t, "#/components/schemas/TestModel", schema.Properties["parent"].Ref)
+	assert.Equal(t, &Schema{}, schema.Properties["extra"])
+}
+
+func TestRegisterRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	routes := []Route{
+		{
+			Method: http.MethodGet, Path: "/hello/:name", OperationID: "hello",
+			Handlers: []gin.HandlerFunc{func(ctx *gin.Context) { ctx.String(http.StatusOK, "hello "+ctx.Param("name")) }},
+		},
+	}
+	group := engine.Group("/api")
+	RegisterRoutes(group, routes)
+	group.GET("/openapi.json", ServeDocument(func() *Document {
+		doc := NewDocument(Info{Title: "Test", Version: "1.0"})
+		doc.AddRoutes("/api", routes)
+		return doc
+	}))
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/hello/world", nil))
+	assert.Equal(t, "hello world", w.Body.String())
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"/api/hello/{name}"`)
+}
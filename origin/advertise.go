@@ -80,6 +80,9 @@ func (server *OriginServer) CreateAdvertisement(name, originUrlStr, originWebUrl
 
 	var nsAds []server_structs.NamespaceAdV2
 	var prefixes []string
+	// Whether any export is advertised as writable, and whether any was flipped
+	// read-only because its storage rejects writes
+	writable, readOnly := false, false
 	originExports, err := server_utils.GetOriginExports()
 	if err != nil {
 		return nil, err
@@ -95,16 +98,31 @@ func (server *OriginServer) CreateAdvertisement(name, originUrlStr, originWebUrl
 		}
 		// PublicReads implies reads
 		reads := export.Capabilities.PublicReads || export.Capabilities.Reads
+		caps := server_structs.Capabilities{
+			PublicReads: export.Capabilities.PublicReads,
+			Reads:       reads,
+			Writes:      export.Capabilities.Writes,
+			Listings:    export.Capabilities.Listings,
+			DirectReads: export.Capabilities.DirectReads,
+			TapeBacked:  IsTapeBacked(),
+		}
+		// An S3 export over its request budget is advertised read-only, so clients
+		// are only served by caches that already hold the objects
+		if IsS3ExportDegraded(export.FederationPrefix) {
+			log.Debugf("Origin export %s is advertised as read-only: it exceeded its monthly S3 request budget", export.FederationPrefix)
+			caps.Writes = false
+			caps.DirectReads = false
+		}
+		if caps.Writes && IsExportReadOnly(export.FederationPrefix) {
+			log.Debugf("Origin export %s is advertised as read-only: its storage rejects writes", export.FederationPrefix)
+			caps.Writes = false
+			readOnly = true
+		}
+		writable = writable || caps.Writes
 		nsAds = append(nsAds, server_structs.NamespaceAdV2{
 			PublicRead: export.Capabilities.PublicReads,
-			Caps: server_structs.Capabilities{
-				PublicReads: export.Capabilities.PublicReads,
-				Reads:       reads,
-				Writes:      export.Capabilities.Writes,
-				Listings:    export.Capabilities.Listings,
-				DirectReads: export.Capabilities.DirectReads,
-			},
-			Path: export.FederationPrefix,
+			Caps:       caps,
+			Path:       export.FederationPrefix,
 			Generation: []server_structs.TokenGen{{
 				Strategy:         server_structs.StrategyType("OAuth2"),
 				MaxScopeDepth:    3,
@@ -114,10 +132,16 @@ func (server *OriginServer) CreateAdvertisement(name, originUrlStr, originWebUrl
 				BasePaths: []string{export.FederationPrefix},
 				IssuerUrl: *issuerUrl,
 			}},
+			MirrorGroup:    export.MirrorGroup,
+			MirrorPriority: export.MirrorPriority,
+			Catalog:        getExportCatalogStats(export.FederationPrefix),
 		})
 		prefixes = append(prefixes, export.FederationPrefix)
 	}
 
+	// The origin no longer accepts writes if the storage of all its writable exports rejects them
+	writes := param.Origin_EnableWrites.GetBool() && (writable || !readOnly)
+
 	// PublicReads implies reads
 	reads := param.Origin_EnableReads.GetBool() || param.Origin_EnablePublicReads.GetBool()
 	extUrlStr := param.Server_ExternalWebUrl.GetString()
@@ -133,14 +157,16 @@ func (server *OriginServer) CreateAdvertisement(name, originUrlStr, originWebUrl
 		Caps: server_structs.Capabilities{
 			PublicReads: param.Origin_EnablePublicReads.GetBool(),
 			Reads:       reads,
-			Writes:      param.Origin_EnableWrites.GetBool(),
+			Writes:      writes,
 			DirectReads: param.Origin_EnableDirectReads.GetBool(),
 			Listings:    param.Origin_EnableListings.GetBool(),
+			TapeBacked:  IsTapeBacked(),
 		},
 		Issuer: []server_structs.TokenIssuer{{
 			BasePaths: prefixes,
 			IssuerUrl: *issuerUrl,
 		}},
+		ResumableUploads: param.Origin_EnableResumableUploads.GetBool() && writes,
 	}
 
 	if len(prefixes) == 0 {
@@ -0,0 +1,676 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/netip"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
+	"golang.org/x/time/rate"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_utils"
+	"github.com/pelicanplatform/pelican/token"
+)
+
+type (
+	// The limits on the anonymous requests of each client against an export, as
+	// configured in Origin.AnonymousRateLimits
+	AnonymousRateLimit struct {
+		FederationPrefix string  `mapstructure:"FederationPrefix"`
+		RequestRate      float64 `mapstructure:"RequestRate"`  // Requests per second; 0 is unlimited
+		RequestBurst     int     `mapstructure:"RequestBurst"` // Defaults to the request rate, rounded up
+		Bandwidth        int     `mapstructure:"Bandwidth"`    // Bytes per second; 0 is unlimited
+	}
+
+	// The limiters of a single client against a single export; nil if unlimited
+	anonymousClientLimiters struct {
+		requests  *rate.Limiter
+		bandwidth *rate.Limiter
+	}
+
+	// HTTP middleware enforcing the anonymous request limits of the exports before
+	// passing the requests on to the next handler
+	anonymousLimiter struct {
+		limits      []AnonymousRateLimit // Sorted by prefix length, longest first
+		trusted     []netip.Prefix
+		clients     *ttlcache.Cache[string, *anonymousClientLimiters]
+		verifyToken func(tok string) bool
+		next        http.Handler
+	}
+
+	// Slows down the writes of a response body to the bandwidth of the client and
+	// counts the bytes written
+	anonymousResponseWriter struct {
+		http.ResponseWriter
+		ctx     context.Context
+		limiter *rate.Limiter // nil if the bandwidth is unlimited
+		bytes   prometheus.Counter
+	}
+
+	// Verifies tokens signed by a fixed set of issuers
+	issuerTokenVerifier struct {
+		issuers map[string]bool
+		keys    *ttlcache.Cache[string, jwk.Set]
+		loader  ttlcache.Loader[string, jwk.Set]
+	}
+
+	// A listener for the connections the gateway accepted and handed over
+	connListener struct {
+		addr   net.Addr
+		conns  chan net.Conn
+		closed chan struct{}
+		once   sync.Once
+	}
+
+	// A connection whose first bytes were peeked into a buffered reader
+	peekedConn struct {
+		net.Conn
+		reader *bufio.Reader
+	}
+
+	// The gateway listening on the origin's port in place of XRootD.  HTTP(S)
+	// connections are served by the anonymous limiter, which proxies the requests
+	// to XRootD, while connections of the xroot protocol are passed through as-is.
+	anonymousLimitGateway struct {
+		listener net.Listener
+		https    *connListener
+		http     *connListener
+	}
+)
+
+const (
+	// The first byte of a TLS handshake record
+	tlsHandshakeRecord = 0x16
+	// The first byte of the xroot protocol's client handshake
+	xrootHandshakeByte = 0x00
+
+	// How long a client may take to send the first bytes of a connection
+	gatewayPeekTimeout = 10 * time.Second
+	// How long the limiters of an idle client are kept
+	anonymousClientTTL = 10 * time.Minute
+	// How long the keys of a trusted issuer are cached
+	issuerKeysTTL = 15 * time.Minute
+	// How long a failure to get the keys of a trusted issuer is cached
+	issuerKeysFailureTTL = time.Minute
+)
+
+var (
+	// The port XRootD listens on behind the gateway; 0 if there's no gateway or
+	// XRootD hasn't started yet
+	xrootdInternalPort     atomic.Int32
+	anonymousGatewayActive atomic.Bool
+)
+
+// Returns true if the origin's port is served by the anonymous limit gateway rather
+// than by XRootD directly
+func IsAnonymousLimitGatewayActive() bool {
+	return anonymousGatewayActive.Load()
+}
+
+// Record the port XRootD listens on behind the anonymous limit gateway
+func SetXrootdInternalPort(port int) {
+	xrootdInternalPort.Store(int32(port))
+}
+
+// Returns the port XRootD itself listens on, which is Origin.Port unless the
+// anonymous limit gateway is in front of XRootD
+func GetXrootdPort() int {
+	if IsAnonymousLimitGatewayActive() {
+		return int(xrootdInternalPort.Load())
+	}
+	return param.Origin_Port.GetInt()
+}
+
+// Parse Origin.AnonymousRateLimits, checking each limit applies to a public export
+func getAnonymousRateLimits(exports []server_utils.OriginExport) ([]AnonymousRateLimit, error) {
+	limits := []AnonymousRateLimit{}
+	if err := param.Origin_AnonymousRateLimits.Unmarshal(&limits); err != nil {
+		return nil, errors.Wrap(err, "failed to parse Origin.AnonymousRateLimits")
+	}
+	seen := make(map[string]bool, len(limits))
+	for idx := range limits {
+		limit := &limits[idx]
+		limit.FederationPrefix = path.Clean(limit.FederationPrefix)
+		isPublic := false
+		for _, export := range exports {
+			if path.Clean(export.FederationPrefix) == limit.FederationPrefix {
+				isPublic = export.Capabilities.PublicReads
+				break
+			}
+		}
+		if !isPublic {
+			return nil, errors.Errorf("Origin.AnonymousRateLimits has a limit for %s, which is not an export with the PublicReads capability", limit.FederationPrefix)
+		}
+		if seen[limit.FederationPrefix] {
+			return nil, errors.Errorf("Origin.AnonymousRateLimits has more than one limit for %s", limit.FederationPrefix)
+		}
+		seen[limit.FederationPrefix] = true
+		if limit.RequestRate < 0 || limit.RequestBurst < 0 || limit.Bandwidth < 0 {
+			return nil, errors.Errorf("the anonymous rate limit of %s must not be negative", limit.FederationPrefix)
+		}
+		if limit.RequestRate == 0 && limit.Bandwidth == 0 {
+			return nil, errors.Errorf("the anonymous rate limit of %s sets neither a RequestRate nor a Bandwidth", limit.FederationPrefix)
+		}
+		if limit.RequestBurst == 0 {
+			limit.RequestBurst = int(math.Max(1, math.Ceil(limit.RequestRate)))
+		}
+	}
+	sort.SliceStable(limits, func(i, j int) bool {
+		return len(limits[i].FederationPrefix) > len(limits[j].FederationPrefix)
+	})
+	return limits, nil
+}
+
+// Parse Origin.AnonymousRateLimitTrustedNetworks
+func getAnonymousTrustedNetworks() ([]netip.Prefix, error) {
+	networks := []netip.Prefix{}
+	for _, network := range param.Origin_AnonymousRateLimitTrustedNetworks.GetStringSlice() {
+		network = strings.TrimSpace(network)
+		if prefix, err := netip.ParsePrefix(network); err == nil {
+			networks = append(networks, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(network); err == nil {
+			addr = addr.Unmap()
+			networks = append(networks, netip.PrefixFrom(addr, addr.BitLen()))
+		} else {
+			return nil, errors.Errorf("invalid entry %q in Origin.AnonymousRateLimitTrustedNetworks", network)
+		}
+	}
+	return networks, nil
+}
+
+func newAnonymousLimiter(limits []AnonymousRateLimit, trusted []netip.Prefix, verifyToken func(string) bool, next http.Handler) *anonymousLimiter {
+	return &anonymousLimiter{
+		limits:      limits,
+		trusted:     trusted,
+		clients:     ttlcache.New(ttlcache.WithTTL[string, *anonymousClientLimiters](anonymousClientTTL)),
+		verifyToken: verifyToken,
+		next:        next,
+	}
+}
+
+// Returns the limit of the export the object path belongs to, or nil if the export
+// isn't limited
+func (lim *anonymousLimiter) lookupLimit(objectPath string) *AnonymousRateLimit {
+	for idx := range lim.limits {
+		prefix := lim.limits[idx].FederationPrefix
+		if prefix == "/" || objectPath == prefix || strings.HasPrefix(objectPath, prefix+"/") {
+			return &lim.limits[idx]
+		}
+	}
+	return nil
+}
+
+func (lim *anonymousLimiter) isTrusted(addr netip.Addr) bool {
+	for _, network := range lim.trusted {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the key identifying a client.  IPv6 clients are identified by their /64
+// network, as a single host typically has the whole network to itself.
+func anonymousClientKey(addr netip.Addr) string {
+	if addr.Is6() {
+		if network, err := addr.Prefix(64); err == nil {
+			return network.String()
+		}
+	}
+	return addr.String()
+}
+
+// Returns the token a request carries, if any, from the places XRootD accepts it
+func getRequestToken(req *http.Request) string {
+	if authz := req.Header.Get("Authorization"); authz != "" {
+		return strings.TrimSpace(strings.TrimPrefix(authz, "Bearer "))
+	}
+	query := req.URL.Query()
+	if authz := query.Get("authz"); authz != "" {
+		return strings.TrimSpace(strings.TrimPrefix(authz, "Bearer "))
+	}
+	return query.Get("access_token")
+}
+
+func (lim *anonymousLimiter) getClientLimiters(key string, limit *AnonymousRateLimit) *anonymousClientLimiters {
+	if item := lim.clients.Get(key); item != nil {
+		return item.Value()
+	}
+	limiters := &anonymousClientLimiters{}
+	if limit.RequestRate > 0 {
+		limiters.requests = rate.NewLimiter(rate.Limit(limit.RequestRate), limit.RequestBurst)
+	}
+	if limit.Bandwidth > 0 {
+		// Allow a second's worth of data at once
+		limiters.bandwidth = rate.NewLimiter(rate.Limit(limit.Bandwidth), limit.Bandwidth)
+	}
+	item, _ := lim.clients.GetOrSet(key, limiters)
+	return item.Value()
+}
+
+func (lim *anonymousLimiter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	// XRootD resolves "//" and ".." in the path, so a limited object can't be reached
+	// through another spelling of its path
+	limit := lim.lookupLimit(path.Clean("/" + req.URL.Path))
+	if limit == nil {
+		lim.next.ServeHTTP(w, req)
+		return
+	}
+	addrPort, err := netip.ParseAddrPort(req.RemoteAddr)
+	if err != nil {
+		log.Debugf("Unable to parse the address %s of a client: %v", req.RemoteAddr, err)
+		lim.next.ServeHTTP(w, req)
+		return
+	}
+	addr := addrPort.Addr().Unmap()
+	if lim.isTrusted(addr) {
+		lim.next.ServeHTTP(w, req)
+		return
+	}
+	// Only tokens of a trusted issuer exempt a request; otherwise any bogus token would do
+	if tok := getRequestToken(req); tok != "" && lim.verifyToken(tok) {
+		lim.next.ServeHTTP(w, req)
+		return
+	}
+
+	client := anonymousClientKey(addr)
+	limiters := lim.getClientLimiters(limit.FederationPrefix+"|"+client, limit)
+	if limiters.requests != nil && !limiters.requests.Allow() {
+		metrics.PelicanOriginAnonymousRequests.WithLabelValues(limit.FederationPrefix, "throttled").Inc()
+		log.Debugf("Rate limiting anonymous requests from %s against export %s", client, limit.FederationPrefix)
+		retryAfter := int(math.Max(1, math.Ceil(1/limit.RequestRate)))
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		http.Error(w, "Too many anonymous requests; try again later or authenticate with a token", http.StatusTooManyRequests)
+		return
+	}
+	metrics.PelicanOriginAnonymousRequests.WithLabelValues(limit.FederationPrefix, "allowed").Inc()
+	lim.next.ServeHTTP(&anonymousResponseWriter{
+		ResponseWriter: w,
+		ctx:            req.Context(),
+		limiter:        limiters.bandwidth,
+		bytes:          metrics.PelicanOriginAnonymousBytes.WithLabelValues(limit.FederationPrefix),
+	}, req)
+}
+
+func (w *anonymousResponseWriter) Write(data []byte) (n int, err error) {
+	for len(data) > 0 {
+		chunk := data
+		if w.limiter != nil {
+			if burst := w.limiter.Burst(); len(chunk) > burst {
+				chunk = chunk[:burst]
+			}
+			if err = w.limiter.WaitN(w.ctx, len(chunk)); err != nil {
+				return
+			}
+		}
+		written, err := w.ResponseWriter.Write(chunk)
+		n += written
+		w.bytes.Add(float64(written))
+		if err != nil {
+			return n, err
+		}
+		data = data[len(chunk):]
+	}
+	return
+}
+
+// Allows http.ResponseController to reach the underlying writer, e.g. for flushing
+func (w *anonymousResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func newIssuerTokenVerifier(issuers []string) *issuerTokenVerifier {
+	v := &issuerTokenVerifier{
+		issuers: make(map[string]bool, len(issuers)),
+		keys: ttlcache.New(
+			ttlcache.WithTTL[string, jwk.Set](issuerKeysTTL),
+			ttlcache.WithDisableTouchOnHit[string, jwk.Set](),
+		),
+	}
+	for _, issuer := range issuers {
+		v.issuers[issuer] = true
+	}
+	v.loader = ttlcache.NewSuppressedLoader[string, jwk.Set](ttlcache.LoaderFunc[string, jwk.Set](
+		func(c *ttlcache.Cache[string, jwk.Set], issuer string) *ttlcache.Item[string, jwk.Set] {
+			keys, err := token.GetJWKSFromIssUrl(issuer)
+			if err != nil {
+				log.Warningf("Failed to get the keys of issuer %s; its tokens don't exempt requests from the anonymous rate limits: %v", issuer, err)
+				// Remember the failure for a while, so requests don't each retry
+				return c.Set(issuer, jwk.NewSet(), issuerKeysFailureTTL)
+			}
+			return c.Set(issuer, *keys, ttlcache.DefaultTTL)
+		}), new(singleflight.Group))
+	return v
+}
+
+// Parse the token, checking it's valid and signed by one of the trusted issuers
+func (v *issuerTokenVerifier) parse(tok string) (jwt.Token, error) {
+	unverified, err := jwt.ParseString(tok, jwt.WithVerify(false), jwt.WithValidate(false))
+	if err != nil {
+		return nil, err
+	}
+	if !v.issuers[unverified.Issuer()] {
+		return nil, errors.Errorf("the token issuer %s is not trusted", unverified.Issuer())
+	}
+	item := v.keys.Get(unverified.Issuer(), ttlcache.WithLoader[string, jwk.Set](v.loader))
+	if item == nil {
+		return nil, errors.Errorf("the keys of token issuer %s are unavailable", unverified.Issuer())
+	}
+	return jwt.ParseString(tok, jwt.WithKeySet(item.Value()), jwt.WithValidate(true))
+}
+
+// Returns true if the token is valid and signed by one of the trusted issuers
+func (v *issuerTokenVerifier) verify(tok string) bool {
+	_, err := v.parse(tok)
+	return err == nil
+}
+
+func newConnListener(addr net.Addr) *connListener {
+	return &connListener{
+		addr:   addr,
+		conns:  make(chan net.Conn),
+		closed: make(chan struct{}),
+	}
+}
+
+// Hand a connection to whoever is accepting from the listener; returns false if the
+// listener is closed
+func (l *connListener) deliver(conn net.Conn) bool {
+	select {
+	case l.conns <- conn:
+		return true
+	case <-l.closed:
+		return false
+	}
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *connListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return l.addr
+}
+
+func (c *peekedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func newAnonymousLimitGateway(listener net.Listener) *anonymousLimitGateway {
+	return &anonymousLimitGateway{
+		listener: listener,
+		https:    newConnListener(listener.Addr()),
+		http:     newConnListener(listener.Addr()),
+	}
+}
+
+// Accept connections until the gateway's listener is closed
+func (gw *anonymousLimitGateway) serve() error {
+	defer gw.https.Close()
+	defer gw.http.Close()
+	for {
+		conn, err := gw.listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		} else if err != nil {
+			log.Warningln("Anonymous limit gateway failed to accept a connection:", err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		go gw.handleConn(conn)
+	}
+}
+
+// Route a connection by its first byte: TLS handshakes and plain HTTP go to the
+// HTTP servers while anything else is assumed to be the xroot protocol
+func (gw *anonymousLimitGateway) handleConn(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	_ = conn.SetReadDeadline(time.Now().Add(gatewayPeekTimeout))
+	first, err := reader.Peek(1)
+	_ = conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		conn.Close()
+		return
+	}
+	peeked := &peekedConn{Conn: conn, reader: reader}
+	switch first[0] {
+	case tlsHandshakeRecord:
+		if !gw.https.deliver(peeked) {
+			conn.Close()
+		}
+	case xrootHandshakeByte:
+		gw.passThrough(peeked)
+	default:
+		if !gw.http.deliver(peeked) {
+			conn.Close()
+		}
+	}
+}
+
+// Copy a connection to and from XRootD until either side closes it
+func (gw *anonymousLimitGateway) passThrough(conn net.Conn) {
+	defer conn.Close()
+	port := GetXrootdPort()
+	if port == 0 {
+		log.Debugln("Dropping a connection to the origin as XRootD hasn't started yet")
+		return
+	}
+	upstream, err := net.DialTimeout("tcp", "localhost:"+strconv.Itoa(port), gatewayPeekTimeout)
+	if err != nil {
+		log.Warningln("Failed to connect to the local xrootd instance:", err)
+		return
+	}
+	defer upstream.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(upstream, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(conn, upstream)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// Returns a transport whose connections go to the local xrootd port regardless of
+// the requested address, like the broker's transport
+func newXrootdTransport() *http.Transport {
+	transport := config.GetTransport().Clone()
+	transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		dialer := net.Dialer{}
+		return dialer.DialContext(ctx, "tcp", "localhost:"+strconv.Itoa(GetXrootdPort()))
+	}
+	// Any custom TLS dialer would bypass the redirection to localhost above
+	transport.DialTLSContext = nil
+	return transport
+}
+
+// Returns a reverse proxy to XRootD
+func newXrootdProxy() *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL.Scheme = "https"
+			pr.Out.URL.Host = param.Server_Hostname.GetString() + ":" + strconv.Itoa(GetXrootdPort())
+			pr.Out.Host = pr.In.Host
+			pr.SetXForwarded()
+		},
+		Transport: newXrootdTransport(),
+		ErrorHandler: func(w http.ResponseWriter, req *http.Request, err error) {
+			log.Infoln("Failed to talk to xrootd service:", err)
+			http.Error(w, "Failed to connect to local xrootd instance", http.StatusBadGateway)
+		},
+	}
+}
+
+// Launch the gateway enforcing Origin.AnonymousRateLimits on the origin's port, if any
+// limits are configured or Origin.EnableContentTypes is set, in which case the gateway
+// also sets the content types of the objects.  XRootD is moved to a random port behind
+// the gateway, so this must be called before the XRootD configuration is generated.
+// Tokens signed by one of the trustedIssuers exempt a request from the limits.
+func LaunchAnonymousLimitGateway(ctx context.Context, egrp *errgroup.Group, exports []server_utils.OriginExport, trustedIssuers []string) error {
+	limits := []AnonymousRateLimit{}
+	if param.Origin_AnonymousRateLimits.IsSet() {
+		var err error
+		if limits, err = getAnonymousRateLimits(exports); err != nil {
+			return err
+		}
+	}
+	var contentTypes *contentTypeModifier
+	if param.Origin_EnableContentTypes.GetBool() {
+		var err error
+		if contentTypes, err = newContentTypeModifier(); err != nil {
+			return err
+		}
+	}
+	if len(limits) == 0 && contentTypes == nil {
+		return nil
+	}
+	if param.Origin_EnableVoms.GetBool() {
+		return errors.New("Origin.AnonymousRateLimits and Origin.EnableContentTypes can't be used with Origin.EnableVoms, as the gateway enforcing them terminates TLS")
+	}
+	trusted, err := getAnonymousTrustedNetworks()
+	if err != nil {
+		return err
+	}
+
+	certFile := param.Server_TLSCertificate.GetString()
+	keyFile := param.Server_TLSKey.GetString()
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return errors.Wrap(err, "failed to load the server's TLS certificate for the anonymous limit gateway")
+	}
+	var certPtr atomic.Pointer[tls.Certificate]
+	certPtr.Store(&cert)
+	server_utils.LaunchWatcherMaintenance(
+		ctx,
+		[]string{filepath.Dir(certFile)},
+		"anonymous limit gateway TLS maintenance",
+		2*time.Minute,
+		func(notifyEvent bool) error {
+			cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+			if err == nil {
+				certPtr.Store(&cert)
+			} else if notifyEvent {
+				log.Debugln("Failed to load new X509 key pair after filesystem event (may succeed eventually):", err)
+				return nil
+			}
+			return err
+		},
+	)
+	tlsConfig := &tls.Config{
+		GetCertificate: func(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return certPtr.Load(), nil
+		},
+		NextProtos: []string{"http/1.1"},
+	}
+
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(param.Origin_Port.GetInt()))
+	if err != nil {
+		return errors.Wrap(err, "failed to listen on the origin's port for the anonymous limit gateway")
+	}
+	if param.Origin_Port.GetInt() == 0 {
+		viper.Set("Origin.Port", listener.Addr().(*net.TCPAddr).Port)
+	}
+	// XRootD picks a port of its own, reported when it starts
+	viper.Set("Origin.CalculatedPort", "any")
+	anonymousGatewayActive.Store(true)
+	xrootdInternalPort.Store(0)
+
+	verifier := newIssuerTokenVerifier(trustedIssuers)
+	proxy := newXrootdProxy()
+	if contentTypes != nil {
+		proxy.ModifyResponse = contentTypes.modifyResponse
+		log.Infoln("Setting the content types of the objects served on port", param.Origin_Port.GetInt())
+	}
+	limiter := newAnonymousLimiter(limits, trusted, verifier.verify, proxy)
+	gw := newAnonymousLimitGateway(listener)
+	httpsServer := &http.Server{Handler: limiter, ReadHeaderTimeout: gatewayPeekTimeout}
+	httpServer := &http.Server{Handler: limiter, ReadHeaderTimeout: gatewayPeekTimeout}
+	if len(limits) > 0 {
+		log.Infof("Enforcing the anonymous rate limits of %d exports on port %d", len(limits), param.Origin_Port.GetInt())
+	}
+
+	go limiter.clients.Start()
+	egrp.Go(gw.serve)
+	egrp.Go(func() error {
+		if err := httpsServer.Serve(tls.NewListener(gw.https, tlsConfig)); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return errors.Wrap(err, "anonymous limit gateway failed")
+		}
+		return nil
+	})
+	egrp.Go(func() error {
+		if err := httpServer.Serve(gw.http); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return errors.Wrap(err, "anonymous limit gateway failed")
+		}
+		return nil
+	})
+	egrp.Go(func() error {
+		<-ctx.Done()
+		listener.Close()
+		limiter.clients.Stop()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err := httpsServer.Shutdown(shutdownCtx)
+		if httpErr := httpServer.Shutdown(shutdownCtx); err == nil {
+			err = httpErr
+		}
+		anonymousGatewayActive.Store(false)
+		return err
+	})
+	return nil
+}
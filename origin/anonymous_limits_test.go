@@ -0,0 +1,250 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+func TestGetAnonymousRateLimits(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	exports := []server_utils.OriginExport{
+		{FederationPrefix: "/public", Capabilities: server_structs.Capabilities{PublicReads: true, Reads: true}},
+		{FederationPrefix: "/public/nested", Capabilities: server_structs.Capabilities{PublicReads: true, Reads: true}},
+		{FederationPrefix: "/private", Capabilities: server_structs.Capabilities{Reads: true}},
+	}
+
+	viper.Set("Origin.AnonymousRateLimits", []map[string]interface{}{
+		{"FederationPrefix": "/public/", "RequestRate": 2.5},
+		{"FederationPrefix": "/public/nested", "RequestRate": 1, "RequestBurst": 5, "Bandwidth": 1024},
+	})
+	limits, err := getAnonymousRateLimits(exports)
+	require.NoError(t, err)
+	require.Len(t, limits, 2)
+	// The longest prefix comes first, and the burst defaults to the rounded up rate
+	assert.Equal(t, AnonymousRateLimit{FederationPrefix: "/public/nested", RequestRate: 1, RequestBurst: 5, Bandwidth: 1024}, limits[0])
+	assert.Equal(t, AnonymousRateLimit{FederationPrefix: "/public", RequestRate: 2.5, RequestBurst: 3}, limits[1])
+
+	for _, invalid := range []map[string]interface{}{
+		{"FederationPrefix": "/private", "RequestRate": 1},
+		{"FederationPrefix": "/unknown", "RequestRate": 1},
+		{"FederationPrefix": "/public"},
+		{"FederationPrefix": "/public", "RequestRate": -1},
+	} {
+		viper.Set("Origin.AnonymousRateLimits", []map[string]interface{}{invalid})
+		_, err := getAnonymousRateLimits(exports)
+		assert.Error(t, err, "limit %v should be rejected", invalid)
+	}
+}
+
+func TestAnonymousLimiter(t *testing.T) {
+	limits := []AnonymousRateLimit{
+		{FederationPrefix: "/public/slow", Bandwidth: 1000},
+		{FederationPrefix: "/public", RequestRate: 0.01, RequestBurst: 2},
+	}
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write(bytes.Repeat([]byte("a"), 1500))
+	})
+	limiter := newAnonymousLimiter(limits, trusted, func(tok string) bool { return tok == "good" }, next)
+
+	get := func(objectPath, remoteAddr, authz string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, objectPath, nil)
+		req.RemoteAddr = remoteAddr
+		if authz != "" {
+			req.Header.Set("Authorization", "Bearer "+authz)
+		}
+		w := httptest.NewRecorder()
+		limiter.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("request-rate", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, get("/public/foo", "192.0.2.1:1234", "").Code)
+		assert.Equal(t, http.StatusOK, get("/public/bar", "192.0.2.1:1235", "").Code)
+		w := get("/public/foo", "192.0.2.1:1234", "")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "100", w.Header().Get("Retry-After"))
+
+		// Other clients, other exports, and trusted networks are unaffected
+		assert.Equal(t, http.StatusOK, get("/public/foo", "192.0.2.2:1234", "").Code)
+		assert.Equal(t, http.StatusOK, get("/private/foo", "192.0.2.1:1234", "").Code)
+		assert.Equal(t, http.StatusOK, get("/publicity", "192.0.2.1:1234", "").Code)
+		assert.Equal(t, http.StatusOK, get("/public/foo", "10.1.2.3:1234", "").Code)
+	})
+
+	t.Run("tokens", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			require.Equal(t, http.StatusOK, get("/public/foo", "192.0.2.3:1234", "").Code)
+		}
+		assert.Equal(t, http.StatusOK, get("/public/foo", "192.0.2.3:1234", "good").Code)
+		assert.Equal(t, http.StatusTooManyRequests, get("/public/foo", "192.0.2.3:1234", "bogus").Code)
+	})
+
+	t.Run("unclean-paths", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, get("/public/foo", "192.0.2.5:1234", "").Code)
+		assert.Equal(t, http.StatusOK, get("//public/foo", "192.0.2.5:1234", "").Code)
+		assert.Equal(t, http.StatusTooManyRequests, get("/private/../public/foo", "192.0.2.5:1234", "").Code)
+	})
+
+	t.Run("ipv6-networks", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, get("/public/foo", "[2001:db8::1]:1234", "").Code)
+		assert.Equal(t, http.StatusOK, get("/public/foo", "[2001:db8::2]:1234", "").Code)
+		assert.Equal(t, http.StatusTooManyRequests, get("/public/foo", "[2001:db8::3]:1234", "").Code)
+		assert.Equal(t, http.StatusOK, get("/public/foo", "[2001:db8:0:1::1]:1234", "").Code)
+	})
+
+	t.Run("bandwidth", func(t *testing.T) {
+		// The first second's worth of data is sent at once; the rest waits its turn
+		start := time.Now()
+		w := get("/public/slow/foo", "192.0.2.4:1234", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 1500, w.Body.Len())
+		assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+	})
+}
+
+func TestIssuerTokenVerifier(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	key, err := jwk.FromRaw(privateKey)
+	require.NoError(t, err)
+	require.NoError(t, key.Set(jwk.KeyIDKey, "test"))
+	require.NoError(t, key.Set(jwk.AlgorithmKey, jwa.ES256))
+	publicKey, err := key.PublicKey()
+	require.NoError(t, err)
+	keys := jwk.NewSet()
+	require.NoError(t, keys.AddKey(publicKey))
+
+	issuer := "https://issuer.example.com"
+	verifier := newIssuerTokenVerifier([]string{issuer})
+	verifier.keys.Set(issuer, keys, ttlcache.DefaultTTL)
+
+	sign := func(iss string, expiry time.Time) string {
+		tok, err := jwt.NewBuilder().Issuer(iss).Expiration(expiry).Build()
+		require.NoError(t, err)
+		signed, err := jwt.Sign(tok, jwt.WithKey(jwa.ES256, key))
+		require.NoError(t, err)
+		return string(signed)
+	}
+
+	assert.True(t, verifier.verify(sign(issuer, time.Now().Add(time.Minute))))
+	assert.False(t, verifier.verify(sign(issuer, time.Now().Add(-time.Minute))))
+	assert.False(t, verifier.verify(sign("https://other.example.com", time.Now().Add(time.Minute))))
+	assert.False(t, verifier.verify(sign(issuer, time.Now().Add(time.Minute))+"a"))
+	assert.False(t, verifier.verify("not-a-token"))
+}
+
+func TestAnonymousLimitGateway(t *testing.T) {
+	// A stand-in for XRootD's xroot protocol which echoes the data it receives
+	xrootd, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer xrootd.Close()
+	go func() {
+		for {
+			conn, err := xrootd.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	anonymousGatewayActive.Store(true)
+	SetXrootdInternalPort(xrootd.Addr().(*net.TCPAddr).Port)
+	t.Cleanup(func() {
+		anonymousGatewayActive.Store(false)
+		SetXrootdInternalPort(0)
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	gw := newAnonymousLimitGateway(listener)
+	go func() { _ = gw.serve() }()
+	defer listener.Close()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte("via gateway"))
+	})
+	ts := httptest.NewUnstartedServer(handler)
+	ts.StartTLS()
+	defer ts.Close()
+	httpsServer := &http.Server{Handler: handler}
+	go func() { _ = httpsServer.Serve(tls.NewListener(gw.https, ts.TLS)) }()
+	defer httpsServer.Close()
+	httpServer := &http.Server{Handler: handler}
+	go func() { _ = httpServer.Serve(gw.http) }()
+	defer httpServer.Close()
+
+	t.Run("xroot-passes-through", func(t *testing.T) {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+		handshake := []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 4, 0, 0, 7, 220}
+		_, err = conn.Write(handshake)
+		require.NoError(t, err)
+		echo := make([]byte, len(handshake))
+		_, err = io.ReadFull(conn, echo)
+		require.NoError(t, err)
+		assert.Equal(t, handshake, echo)
+	})
+
+	t.Run("https-is-served", func(t *testing.T) {
+		resp, err := ts.Client().Get("https://" + listener.Addr().String() + "/public/foo")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "via gateway", string(body))
+	})
+
+	t.Run("http-is-served", func(t *testing.T) {
+		resp, err := http.Get("http://" + listener.Addr().String() + "/public/foo")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "via gateway", string(body))
+	})
+}
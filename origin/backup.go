@@ -0,0 +1,561 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+// Backups snapshot the metadata of the origin's POSIX exports, the listing and checksum of
+// each object, into a manifest under Origin.BackupLocation.  If Origin.BackupDestination
+// is set, the objects that changed since the previous snapshot are also copied there and
+// verified, so a snapshot can later be restored from the destination with
+// `pelican origin backup restore`.
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/studio-b12/gowebdav"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+type (
+	// An object of an export, as recorded in a backup snapshot
+	BackupObject struct {
+		Path     string    `json:"path"` // Relative to the export, with slashes
+		Size     int64     `json:"size"`
+		ModTime  time.Time `json:"modTime"`
+		Checksum string    `json:"sha256"`
+		// Whether the object was copied to, and verified at, the snapshot's destination
+		Copied bool `json:"copied,omitempty"`
+	}
+
+	// The objects of an export, as recorded in a backup snapshot
+	BackupExport struct {
+		FederationPrefix string         `json:"federationPrefix"`
+		StoragePrefix    string         `json:"storagePrefix"`
+		Objects          []BackupObject `json:"objects"`
+	}
+
+	// A snapshot of the origin's exports, saved as a manifest under Origin.BackupLocation
+	BackupSnapshot struct {
+		CreatedAt time.Time `json:"createdAt"`
+		// The destination the objects were copied to, without credentials; empty if
+		// only the metadata was snapshotted
+		Destination string         `json:"destination,omitempty"`
+		Exports     []BackupExport `json:"exports"`
+	}
+
+	// Where the objects of backups are copied to
+	backupStore interface {
+		// Copy a local file to the store as the object at the given path
+		put(objectPath, localPath string) error
+		// Check that the object at the given path matches what was copied
+		verify(objectPath string, object BackupObject) error
+		// Copy the object at the given path from the store to a local file
+		get(objectPath, localPath string) error
+	}
+
+	// A backup destination that is a local directory, e.g. a mounted cold storage volume
+	fileBackupStore struct {
+		root string
+	}
+
+	// A backup destination that is a WebDAV server, like a bucket behind an HTTPS gateway
+	webDAVBackupStore struct {
+		client *gowebdav.Client
+		root   string
+	}
+)
+
+const backupSnapshotPrefix = "snapshot-"
+
+// Open the store of a backup destination, a file:// URL or local path of a directory or
+// an http(s) URL of a WebDAV server.  Credentials in the user info of a WebDAV URL are
+// sent with HTTP basic authentication.
+func openBackupStore(destination string) (backupStore, error) {
+	destUrl, err := url.Parse(destination)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid backup destination")
+	}
+	switch destUrl.Scheme {
+	case "", "file":
+		root := destUrl.Path
+		if destUrl.Scheme == "" {
+			root = destination
+		}
+		if root == "" {
+			return nil, errors.Errorf("the backup destination %s has no path", destination)
+		}
+		return &fileBackupStore{root: root}, nil
+	case "http", "https":
+		root := url.URL{Scheme: destUrl.Scheme, Host: destUrl.Host}
+		var client *gowebdav.Client
+		if destUrl.User != nil {
+			password, _ := destUrl.User.Password()
+			client = gowebdav.NewClient(root.String(), destUrl.User.Username(), password)
+		} else {
+			client = gowebdav.NewClient(root.String(), "", "")
+		}
+		client.SetTransport(config.GetTransport())
+		return &webDAVBackupStore{client: client, root: path.Clean("/" + destUrl.Path)}, nil
+	default:
+		return nil, errors.Errorf("unsupported scheme %q of the backup destination; must be file, http or https", destUrl.Scheme)
+	}
+}
+
+// The backup destination without its credentials, as recorded in snapshots
+func redactBackupDestination(destination string) string {
+	if destUrl, err := url.Parse(destination); err == nil && destUrl.User != nil {
+		return destUrl.Redacted()
+	}
+	return destination
+}
+
+func (store *fileBackupStore) localPath(objectPath string) string {
+	return filepath.Join(store.root, filepath.FromSlash(path.Clean("/"+objectPath)))
+}
+
+func (store *fileBackupStore) put(objectPath, localPath string) error {
+	return copyLocalFile(localPath, store.localPath(objectPath))
+}
+
+func (store *fileBackupStore) verify(objectPath string, object BackupObject) error {
+	size, checksum, err := checksumFile(store.localPath(objectPath))
+	if err != nil {
+		return err
+	}
+	if size != object.Size || checksum != object.Checksum {
+		return errors.Errorf("the copy of %s doesn't match the original", objectPath)
+	}
+	return nil
+}
+
+func (store *fileBackupStore) get(objectPath, localPath string) error {
+	return copyLocalFile(store.localPath(objectPath), localPath)
+}
+
+func (store *webDAVBackupStore) remotePath(objectPath string) string {
+	return path.Join(store.root, path.Clean("/"+objectPath))
+}
+
+func (store *webDAVBackupStore) put(objectPath, localPath string) error {
+	remotePath := store.remotePath(objectPath)
+	if err := store.client.MkdirAll(path.Dir(remotePath), 0755); err != nil {
+		return errors.Wrapf(err, "failed to create the directory of %s", remotePath)
+	}
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return errors.Wrapf(store.client.WriteStream(remotePath, file, 0644), "failed to upload %s", remotePath)
+}
+
+// WebDAV servers don't generally report checksums, so copies are verified by size
+func (store *webDAVBackupStore) verify(objectPath string, object BackupObject) error {
+	info, err := store.client.Stat(store.remotePath(objectPath))
+	if err != nil {
+		return errors.Wrapf(err, "failed to stat the copy of %s", objectPath)
+	}
+	if info.Size() != object.Size {
+		return errors.Errorf("the copy of %s has %d bytes rather than %d", objectPath, info.Size(), object.Size)
+	}
+	return nil
+}
+
+func (store *webDAVBackupStore) get(objectPath, localPath string) error {
+	remotePath := store.remotePath(objectPath)
+	reader, err := store.client.ReadStream(remotePath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to download %s", remotePath)
+	}
+	defer reader.Close()
+	return writeLocalFile(reader, localPath)
+}
+
+// Copy a local file, creating the directory of the copy
+func copyLocalFile(srcPath, dstPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	return writeLocalFile(src, dstPath)
+}
+
+// Write a file from a reader, through a temporary file so a failed write doesn't leave a
+// partial file behind
+func writeLocalFile(reader io.Reader, dstPath string) error {
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+		return errors.Wrapf(err, "failed to create the directory of %s", dstPath)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dstPath), "."+filepath.Base(dstPath)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(tmp, reader); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return errors.Wrapf(err, "failed to write %s", dstPath)
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), dstPath)
+}
+
+// Get the size and SHA-256 checksum of a file
+func checksumFile(filePath string) (int64, string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return 0, "", errors.Wrapf(err, "failed to checksum %s", filePath)
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// List the objects of a POSIX export with their checksums.  The checksums of the objects
+// whose size and modification time are unchanged since the previous snapshot are reused.
+func snapshotPosixExport(ctx context.Context, storagePrefix string, previous map[string]BackupObject) ([]BackupObject, error) {
+	objects := []BackupObject{}
+	err := filepath.WalkDir(storagePrefix, func(filePath string, entry fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			if filePath == storagePrefix {
+				return err
+			}
+			log.Warningf("Skipping %s while snapshotting the export: %v", filePath, err)
+			if entry != nil && entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			log.Warningf("Skipping %s while snapshotting the export: %v", filePath, err)
+			return nil
+		}
+		relPath, err := filepath.Rel(storagePrefix, filePath)
+		if err != nil {
+			return err
+		}
+		object := BackupObject{Path: filepath.ToSlash(relPath), Size: info.Size(), ModTime: info.ModTime().UTC()}
+		if prev, ok := previous[object.Path]; ok && prev.Size == object.Size && prev.ModTime.Equal(object.ModTime) {
+			object.Checksum = prev.Checksum
+		} else if _, object.Checksum, err = checksumFile(filePath); err != nil {
+			log.Warningf("Skipping %s while snapshotting the export: %v", filePath, err)
+			return nil
+		}
+		objects = append(objects, object)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to walk the storage prefix %s", storagePrefix)
+	}
+	return objects, nil
+}
+
+// List the snapshot manifests under a backup location, oldest first
+func listBackupSnapshots(location string) ([]string, error) {
+	entries, err := os.ReadDir(location)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to list the backup snapshots")
+	}
+	snapshots := []string{}
+	for _, entry := range entries {
+		if name := entry.Name(); strings.HasPrefix(name, backupSnapshotPrefix) && strings.HasSuffix(name, ".json") {
+			snapshots = append(snapshots, filepath.Join(location, name))
+		}
+	}
+	// The names of the snapshots sort by their creation times
+	sort.Strings(snapshots)
+	return snapshots, nil
+}
+
+// Read a snapshot manifest
+func ReadBackupSnapshot(snapshotFile string) (*BackupSnapshot, error) {
+	contents, err := os.ReadFile(snapshotFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the backup snapshot")
+	}
+	snapshot := &BackupSnapshot{}
+	if err = json.Unmarshal(contents, snapshot); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the backup snapshot %s", snapshotFile)
+	}
+	return snapshot, nil
+}
+
+func writeBackupSnapshot(location string, snapshot *BackupSnapshot) (string, error) {
+	contents, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err = os.MkdirAll(location, 0750); err != nil {
+		return "", errors.Wrap(err, "failed to create the backup location")
+	}
+	snapshotFile := filepath.Join(location, backupSnapshotPrefix+snapshot.CreatedAt.UTC().Format("20060102T150405.000000000Z")+".json")
+	if err = writeLocalFile(strings.NewReader(string(contents)), snapshotFile); err != nil {
+		return "", err
+	}
+	return snapshotFile, nil
+}
+
+// Remove the oldest snapshots beyond Origin.BackupRetention.  The copied objects are kept,
+// as later snapshots may still refer to them.
+func pruneBackupSnapshots(location string, retention int) {
+	if retention <= 0 {
+		return
+	}
+	snapshots, err := listBackupSnapshots(location)
+	if err != nil {
+		log.Warningln("Failed to prune the backup snapshots:", err)
+		return
+	}
+	for len(snapshots) > retention {
+		if err := os.Remove(snapshots[0]); err != nil {
+			log.Warningf("Failed to remove the backup snapshot %s: %v", snapshots[0], err)
+		}
+		snapshots = snapshots[1:]
+	}
+}
+
+// The objects of each export in the latest snapshot, keyed by federation prefix and then
+// object path, with the destination they were copied to
+func readLatestBackupSnapshot(location string) (map[string]map[string]BackupObject, string) {
+	latest := map[string]map[string]BackupObject{}
+	snapshots, err := listBackupSnapshots(location)
+	if err != nil || len(snapshots) == 0 {
+		return latest, ""
+	}
+	snapshot, err := ReadBackupSnapshot(snapshots[len(snapshots)-1])
+	if err != nil {
+		log.Warningln("Failed to read the previous backup snapshot; all objects will be checksummed again:", err)
+		return latest, ""
+	}
+	for _, export := range snapshot.Exports {
+		objects := make(map[string]BackupObject, len(export.Objects))
+		for _, object := range export.Objects {
+			objects[object.Path] = object
+		}
+		latest[path.Clean("/"+export.FederationPrefix)] = objects
+	}
+	return latest, snapshot.Destination
+}
+
+// Snapshot the origin's POSIX exports into Origin.BackupLocation, copying the objects that
+// changed since the previous snapshot to Origin.BackupDestination if it's set.  Returns
+// the path of the new snapshot.
+func TakeBackupSnapshot(ctx context.Context) (string, error) {
+	if server_utils.OriginStorageType(param.Origin_StorageType.GetString()) != server_utils.OriginStoragePosix {
+		return "", errors.New("only the exports of POSIX origins can be backed up")
+	}
+	location := param.Origin_BackupLocation.GetString()
+	if location == "" {
+		return "", errors.New("Origin.BackupLocation must be set to back up the origin's exports")
+	}
+	exports, err := server_utils.GetOriginExports()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get the origin's exports")
+	}
+
+	destination := param.Origin_BackupDestination.GetString()
+	var store backupStore
+	if destination != "" {
+		if store, err = openBackupStore(destination); err != nil {
+			return "", err
+		}
+	}
+	previous, previousDestination := readLatestBackupSnapshot(location)
+	snapshot := &BackupSnapshot{CreatedAt: time.Now().UTC(), Destination: redactBackupDestination(destination)}
+
+	for _, export := range exports {
+		prefix := path.Clean("/" + export.FederationPrefix)
+		objects, err := snapshotPosixExport(ctx, export.StoragePrefix, previous[prefix])
+		if err != nil {
+			return "", err
+		}
+		copied := 0
+		for idx := range objects {
+			if store == nil {
+				break
+			}
+			if err := ctx.Err(); err != nil {
+				return "", err
+			}
+			object := &objects[idx]
+			prev, ok := previous[prefix][object.Path]
+			if ok && prev.Copied && prev.Checksum == object.Checksum && previousDestination == snapshot.Destination {
+				object.Copied = true
+				continue
+			}
+			objectPath := path.Join(prefix, object.Path)
+			localPath := filepath.Join(export.StoragePrefix, filepath.FromSlash(object.Path))
+			if err := store.put(objectPath, localPath); err != nil {
+				log.Warningf("Failed to copy %s to the backup destination: %v", objectPath, err)
+				continue
+			}
+			if err := store.verify(objectPath, *object); err != nil {
+				log.Warningf("Failed to verify the backup of %s: %v", objectPath, err)
+				continue
+			}
+			object.Copied = true
+			copied++
+		}
+		log.Debugf("Snapshotted %d objects of export %s, copying %d", len(objects), prefix, copied)
+		snapshot.Exports = append(snapshot.Exports, BackupExport{
+			FederationPrefix: prefix,
+			StoragePrefix:    export.StoragePrefix,
+			Objects:          objects,
+		})
+	}
+
+	snapshotFile, err := writeBackupSnapshot(location, snapshot)
+	if err != nil {
+		return "", err
+	}
+	pruneBackupSnapshots(location, param.Origin_BackupRetention.GetInt())
+	return snapshotFile, nil
+}
+
+// Restore the objects of a snapshot from its destination, or from source if it's set, into
+// the target directory.  The credentials of the destination aren't saved in snapshots, so
+// they're taken from Origin.BackupDestination if it's still the snapshot's destination.  If export is set, only the objects of that export are restored,
+// directly under the target; otherwise each export is restored under the target at its
+// federation prefix.  The objects already in the target with the right checksum are
+// skipped.  Returns the number of objects restored.
+func RestoreBackupSnapshot(ctx context.Context, snapshot *BackupSnapshot, export, source, target string) (int, error) {
+	if source == "" {
+		source = snapshot.Destination
+		if configured := param.Origin_BackupDestination.GetString(); configured != "" && redactBackupDestination(configured) == source {
+			source = configured
+		}
+	}
+	if source == "" {
+		return 0, errors.New("the snapshot's objects weren't copied anywhere; a source to restore from must be given")
+	}
+	if target == "" {
+		return 0, errors.New("a target directory to restore into must be given")
+	}
+	store, err := openBackupStore(source)
+	if err != nil {
+		return 0, err
+	}
+
+	restored := 0
+	found := false
+	for _, snapExport := range snapshot.Exports {
+		prefix := path.Clean("/" + snapExport.FederationPrefix)
+		exportTarget := filepath.Join(target, filepath.FromSlash(prefix))
+		if export != "" {
+			if prefix != path.Clean("/"+export) {
+				continue
+			}
+			exportTarget = target
+		}
+		found = true
+		for _, object := range snapExport.Objects {
+			if err := ctx.Err(); err != nil {
+				return restored, err
+			}
+			objectPath := path.Join(prefix, object.Path)
+			if !object.Copied {
+				log.Warningf("Not restoring %s: it wasn't copied to the backup destination", objectPath)
+				continue
+			}
+			localPath := filepath.Join(exportTarget, filepath.FromSlash(path.Clean("/"+object.Path)))
+			if size, checksum, err := checksumFile(localPath); err == nil && size == object.Size && checksum == object.Checksum {
+				continue
+			}
+			if err := store.get(objectPath, localPath); err != nil {
+				return restored, err
+			}
+			size, checksum, err := checksumFile(localPath)
+			if err != nil {
+				return restored, err
+			}
+			if size != object.Size || checksum != object.Checksum {
+				return restored, errors.Errorf("the restored copy of %s doesn't match its checksum in the snapshot", objectPath)
+			}
+			if err := os.Chtimes(localPath, object.ModTime, object.ModTime); err != nil {
+				log.Debugf("Failed to restore the modification time of %s: %v", localPath, err)
+			}
+			restored++
+		}
+	}
+	if !found {
+		return restored, errors.Errorf("the snapshot has no export %s", export)
+	}
+	return restored, nil
+}
+
+// Periodically snapshot the origin's exports, see Origin.EnableBackups
+func PeriodicBackups(ctx context.Context) error {
+	interval := param.Origin_BackupInterval.GetDuration()
+	if interval <= 0 {
+		interval = 24 * time.Hour
+		log.Errorf("Invalid config value: Origin.BackupInterval is %s. Fallback to 24h.", param.Origin_BackupInterval.GetDuration())
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			start := time.Now()
+			snapshotFile, err := TakeBackupSnapshot(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				log.Warningln("Failed to back up the origin's exports:", err)
+				continue
+			}
+			log.Infof("Backed up the origin's exports to %s in %s", snapshotFile, time.Since(start).Round(time.Millisecond))
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,118 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+func TestBackupSnapshots(t *testing.T) {
+	storage := t.TempDir()
+	location := filepath.Join(t.TempDir(), "snapshots")
+	destination := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(storage, "run1"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(storage, "readme.txt"), []byte("hello"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(storage, "run1", "a.root"), make([]byte, 100), 0644))
+
+	server_utils.ResetOriginExports()
+	t.Cleanup(func() {
+		server_utils.ResetOriginExports()
+		viper.Reset()
+	})
+	viper.Set(param.Origin_StorageType.GetName(), "posix")
+	viper.Set("Origin.ExportVolumes", []string{storage + ":/test"})
+	viper.Set(param.Origin_BackupLocation.GetName(), location)
+	viper.Set(param.Origin_BackupDestination.GetName(), "file://"+destination)
+	viper.Set("Origin.BackupRetention", 2)
+
+	snapshotFile, err := TakeBackupSnapshot(context.Background())
+	require.NoError(t, err)
+	snapshot, err := ReadBackupSnapshot(snapshotFile)
+	require.NoError(t, err)
+	require.Len(t, snapshot.Exports, 1)
+	assert.Equal(t, "/test", snapshot.Exports[0].FederationPrefix)
+	require.Len(t, snapshot.Exports[0].Objects, 2)
+	for _, object := range snapshot.Exports[0].Objects {
+		assert.True(t, object.Copied, object.Path)
+		assert.Len(t, object.Checksum, 64)
+	}
+	contents, err := os.ReadFile(filepath.Join(destination, "test", "readme.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(contents))
+
+	t.Run("unchanged-objects-are-not-copied-again", func(t *testing.T) {
+		require.NoError(t, os.Remove(filepath.Join(destination, "test", "readme.txt")))
+		require.NoError(t, os.WriteFile(filepath.Join(storage, "run1", "b.root"), []byte("new"), 0644))
+		snapshotFile, err := TakeBackupSnapshot(context.Background())
+		require.NoError(t, err)
+		snapshot, err := ReadBackupSnapshot(snapshotFile)
+		require.NoError(t, err)
+		assert.Len(t, snapshot.Exports[0].Objects, 3)
+		assert.NoFileExists(t, filepath.Join(destination, "test", "readme.txt"))
+		assert.FileExists(t, filepath.Join(destination, "test", "run1", "b.root"))
+	})
+
+	t.Run("old-snapshots-are-pruned", func(t *testing.T) {
+		_, err := TakeBackupSnapshot(context.Background())
+		require.NoError(t, err)
+		snapshots, err := listBackupSnapshots(location)
+		require.NoError(t, err)
+		assert.Len(t, snapshots, 2)
+		assert.NoFileExists(t, snapshotFile)
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		// Put back the copy removed above so the whole snapshot can be restored
+		require.NoError(t, os.WriteFile(filepath.Join(destination, "test", "readme.txt"), []byte("hello"), 0644))
+		target := t.TempDir()
+		restored, err := RestoreBackupSnapshot(context.Background(), snapshot, "/test", "", target)
+		require.NoError(t, err)
+		assert.Equal(t, 2, restored)
+		contents, err := os.ReadFile(filepath.Join(target, "run1", "a.root"))
+		require.NoError(t, err)
+		assert.Len(t, contents, 100)
+
+		// Objects already restored are skipped
+		restored, err = RestoreBackupSnapshot(context.Background(), snapshot, "", "file://"+destination, filepath.Join(target, "all"))
+		require.NoError(t, err)
+		assert.Equal(t, 2, restored)
+		restored, err = RestoreBackupSnapshot(context.Background(), snapshot, "/test", "", target)
+		require.NoError(t, err)
+		assert.Equal(t, 0, restored)
+
+		_, err = RestoreBackupSnapshot(context.Background(), snapshot, "/missing", "", target)
+		assert.Error(t, err)
+	})
+
+	t.Run("corrupt-copies-are-not-restored", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(destination, "test", "readme.txt"), []byte("HELLO"), 0644))
+		_, err := RestoreBackupSnapshot(context.Background(), snapshot, "/test", "", t.TempDir())
+		assert.ErrorContains(t, err, "doesn't match its checksum")
+	})
+}
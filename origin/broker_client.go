@@ -62,8 +62,10 @@ func getTransport() *http.Transport {
 				err = errors.Wrap(err, "Failed to rate-limit local connection")
 				return nil, err
 			}
-			return dialer.DialContext(ctx, "tcp", "localhost:"+strconv.Itoa(param.Origin_Port.GetInt()))
+			return dialer.DialContext(ctx, "tcp", "localhost:"+strconv.Itoa(GetXrootdPort()))
 		}
+		// Any custom TLS dialer would bypass the redirection to localhost above
+		proxyTransport.DialTLSContext = nil
 	})
 	return proxyTransport
 }
@@ -0,0 +1,206 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"bufio"
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+var (
+	exportCatalogStats     = make(map[string]server_structs.CatalogStats)
+	exportCatalogStatsLock sync.RWMutex
+)
+
+// Get the most recent counts of an export's objects, keyed by its federation prefix;
+// nil if they were never computed
+func getExportCatalogStats(federationPrefix string) *server_structs.CatalogStats {
+	exportCatalogStatsLock.RLock()
+	defer exportCatalogStatsLock.RUnlock()
+	stats, ok := exportCatalogStats[path.Clean("/"+federationPrefix)]
+	if !ok {
+		return nil
+	}
+	return &stats
+}
+
+func setExportCatalogStats(federationPrefix string, stats server_structs.CatalogStats) {
+	exportCatalogStatsLock.Lock()
+	defer exportCatalogStatsLock.Unlock()
+	exportCatalogStats[path.Clean("/"+federationPrefix)] = stats
+}
+
+// Count the regular files under the storage prefix of a POSIX export.  The
+// directories that can't be read are skipped, so the counts are a lower bound.
+func walkPosixCatalog(ctx context.Context, storagePrefix string) (stats server_structs.CatalogStats, err error) {
+	err = filepath.WalkDir(storagePrefix, func(filePath string, entry fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			if filePath == storagePrefix {
+				return err
+			}
+			log.Debugf("Skipping %s while counting the objects of the export: %v", filePath, err)
+			if entry != nil && entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			log.Debugf("Skipping %s while counting the objects of the export: %v", filePath, err)
+			return nil
+		}
+		stats.Objects++
+		stats.Bytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		return server_structs.CatalogStats{}, errors.Wrapf(err, "failed to walk the storage prefix %s", storagePrefix)
+	}
+	stats.UpdatedAt = time.Now()
+	return
+}
+
+// Read the counts of the exports' objects from an inventory file, keyed by federation prefix.
+// Each line is a federation prefix, its number of objects and their total size in bytes.
+func readCatalogInventory(inventoryFile string) (map[string]server_structs.CatalogStats, error) {
+	file, err := os.Open(inventoryFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open the catalog inventory file")
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to stat the catalog inventory file")
+	}
+
+	result := make(map[string]server_structs.CatalogStats)
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, errors.Errorf("line %d of the catalog inventory file %s doesn't have a federation prefix, an object count and a size", lineNum, inventoryFile)
+		}
+		objects, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil || objects < 0 {
+			return nil, errors.Errorf("invalid object count %q on line %d of the catalog inventory file %s", fields[1], lineNum, inventoryFile)
+		}
+		bytes, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil || bytes < 0 {
+			return nil, errors.Errorf("invalid size %q on line %d of the catalog inventory file %s", fields[2], lineNum, inventoryFile)
+		}
+		// The counts are as recent as the inventory
+		result[path.Clean("/"+fields[0])] = server_structs.CatalogStats{Objects: objects, Bytes: bytes, UpdatedAt: info.ModTime()}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read the catalog inventory file")
+	}
+	return result, nil
+}
+
+// Count the objects of every export, from Origin.CatalogInventoryFile if set or
+// by walking the storage of POSIX exports otherwise
+func updateCatalogStats(ctx context.Context) {
+	exports, err := server_utils.GetOriginExports()
+	if err != nil {
+		log.Errorln("Failed to get the origin exports to count their objects:", err)
+		return
+	}
+
+	if inventoryFile := param.Origin_CatalogInventoryFile.GetString(); inventoryFile != "" {
+		inventory, err := readCatalogInventory(inventoryFile)
+		if err != nil {
+			log.Warningln("Failed to count the objects of the origin's exports:", err)
+			return
+		}
+		for _, export := range exports {
+			if stats, ok := inventory[path.Clean("/"+export.FederationPrefix)]; ok {
+				setExportCatalogStats(export.FederationPrefix, stats)
+			} else {
+				log.Debugf("The catalog inventory file %s has no counts for export %s", inventoryFile, export.FederationPrefix)
+			}
+		}
+		return
+	}
+
+	if server_utils.OriginStorageType(param.Origin_StorageType.GetString()) != server_utils.OriginStoragePosix {
+		log.Debugln("Not counting the objects of the origin's exports: only POSIX storage can be walked; set Origin.CatalogInventoryFile instead")
+		return
+	}
+	for _, export := range exports {
+		start := time.Now()
+		stats, err := walkPosixCatalog(ctx, export.StoragePrefix)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Warningf("Failed to count the objects of export %s: %v", export.FederationPrefix, err)
+			continue
+		}
+		log.Debugf("Counted %d objects (%d bytes) in export %s in %s", stats.Objects, stats.Bytes, export.FederationPrefix, time.Since(start).Round(time.Millisecond))
+		setExportCatalogStats(export.FederationPrefix, stats)
+	}
+}
+
+// Periodically count the objects of the origin's exports, which are included in the
+// origin's advertisements to the director
+func PeriodicCatalogStats(ctx context.Context) error {
+	interval := param.Origin_CatalogStatsInterval.GetDuration()
+	if interval <= 0 {
+		interval = 6 * time.Hour
+		log.Errorf("Invalid config value: Origin.CatalogStatsInterval is %s. Fallback to 6h.", param.Origin_CatalogStatsInterval.GetDuration())
+	}
+	updateCatalogStats(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			updateCatalogStats(ctx)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
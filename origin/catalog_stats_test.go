@@ -0,0 +1,99 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+func TestWalkPosixCatalog(t *testing.T) {
+	storage := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(storage, "run1", "sub"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(storage, "readme.txt"), []byte("hello"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(storage, "run1", "a.root"), make([]byte, 100), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(storage, "run1", "sub", "b.root"), make([]byte, 1000), 0644))
+	require.NoError(t, os.Symlink(filepath.Join(storage, "readme.txt"), filepath.Join(storage, "link.txt")))
+
+	start := time.Now()
+	stats, err := walkPosixCatalog(context.Background(), storage)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stats.Objects)
+	assert.Equal(t, int64(1105), stats.Bytes)
+	assert.False(t, stats.UpdatedAt.Before(start))
+
+	_, err = walkPosixCatalog(context.Background(), filepath.Join(storage, "missing"))
+	assert.Error(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = walkPosixCatalog(ctx, storage)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestReadCatalogInventory(t *testing.T) {
+	inventoryFile := filepath.Join(t.TempDir(), "inventory")
+
+	t.Run("valid", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(inventoryFile, []byte("# prefix objects bytes\n/foo 10 2048\n\n  /foo/bar/  3\t9  \n"), 0644))
+		inventory, err := readCatalogInventory(inventoryFile)
+		require.NoError(t, err)
+		require.Len(t, inventory, 2)
+		assert.Equal(t, int64(10), inventory["/foo"].Objects)
+		assert.Equal(t, int64(2048), inventory["/foo"].Bytes)
+		assert.Equal(t, int64(3), inventory["/foo/bar"].Objects)
+		assert.Equal(t, int64(9), inventory["/foo/bar"].Bytes)
+		assert.False(t, inventory["/foo"].UpdatedAt.IsZero())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, contents := range []string{"/foo 10\n", "/foo ten 2048\n", "/foo 10 -1\n"} {
+			require.NoError(t, os.WriteFile(inventoryFile, []byte(contents), 0644))
+			_, err := readCatalogInventory(inventoryFile)
+			assert.Error(t, err, contents)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		_, err := readCatalogInventory(filepath.Join(t.TempDir(), "missing"))
+		assert.Error(t, err)
+	})
+}
+
+func TestExportCatalogStats(t *testing.T) {
+	t.Cleanup(func() {
+		exportCatalogStatsLock.Lock()
+		defer exportCatalogStatsLock.Unlock()
+		delete(exportCatalogStats, "/catalog/test")
+	})
+	assert.Nil(t, getExportCatalogStats("/catalog/test"))
+	expected := server_structs.CatalogStats{Objects: 5, Bytes: 500, UpdatedAt: time.Now()}
+	setExportCatalogStats("/catalog/test/", expected)
+	stats := getExportCatalogStats("/catalog/test")
+	require.NotNil(t, stats)
+	assert.Equal(t, expected, *stats)
+}
@@ -0,0 +1,143 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/pelicanplatform/pelican/param"
+)
+
+// Sets the Content-Type and Content-Disposition headers of the objects XRootD
+// serves, as XRootD serves every object as application/octet-stream
+type contentTypeModifier struct {
+	types map[string]string // MIME types by lower-case extension, including the '.'
+	sniff bool
+}
+
+const (
+	defaultContentType = "application/octet-stream"
+	sniffLen           = 512 // The most bytes http.DetectContentType considers
+)
+
+// Returns the content type modifier configured by Origin.ContentTypes and
+// Origin.SniffContentTypes
+func newContentTypeModifier() (*contentTypeModifier, error) {
+	configured := map[string]string{}
+	if err := param.Origin_ContentTypes.Unmarshal(&configured); err != nil {
+		return nil, errors.Wrap(err, "failed to parse Origin.ContentTypes")
+	}
+	modifier := &contentTypeModifier{
+		types: make(map[string]string, len(configured)),
+		sniff: param.Origin_SniffContentTypes.GetBool(),
+	}
+	for ext, contentType := range configured {
+		if _, _, err := mime.ParseMediaType(contentType); err != nil {
+			return nil, errors.Wrapf(err, "invalid MIME type %q for the extension %q in Origin.ContentTypes", contentType, ext)
+		}
+		modifier.types["."+strings.ToLower(strings.TrimPrefix(ext, "."))] = contentType
+	}
+	return modifier, nil
+}
+
+// The content type of an object by its extension; empty if it's unknown
+func (m *contentTypeModifier) typeByExtension(objectPath string) string {
+	ext := strings.ToLower(path.Ext(objectPath))
+	if ext == "" {
+		return ""
+	}
+	if contentType, ok := m.types[ext]; ok {
+		return contentType
+	}
+	return mime.TypeByExtension(ext)
+}
+
+// Guess the content type of a response from the start of its body, which is
+// buffered so it's still sent to the client; empty if it can't be guessed
+func sniffContentType(resp *http.Response) string {
+	reader := bufio.NewReaderSize(resp.Body, sniffLen)
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{reader, resp.Body}
+	start, _ := reader.Peek(sniffLen)
+	if len(start) == 0 {
+		return ""
+	}
+	if contentType := http.DetectContentType(start); contentType != defaultContentType {
+		return contentType
+	}
+	return ""
+}
+
+// The Content-Disposition of a response to a request with the download query
+// parameter, which gives the name to save the object as, if any
+func downloadDisposition(req *http.Request) string {
+	name := req.URL.Query().Get("download")
+	// Only the file name of the parameter is used, never a path
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" || name == ".." {
+		name = path.Base(req.URL.Path)
+	}
+	if name == "." || name == "/" {
+		return "attachment"
+	}
+	if disposition := mime.FormatMediaType("attachment", map[string]string{"filename": name}); disposition != "" {
+		return disposition
+	}
+	return "attachment"
+}
+
+// Modify a response from XRootD, for the ModifyResponse hook of a reverse proxy
+func (m *contentTypeModifier) modifyResponse(resp *http.Response) error {
+	req := resp.Request
+	if req == nil || (req.Method != http.MethodGet && req.Method != http.MethodHead) {
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return nil
+	}
+
+	// Directory listings and the like already have a type of their own
+	if current := resp.Header.Get("Content-Type"); current == "" || current == defaultContentType {
+		contentType := m.typeByExtension(req.URL.Path)
+		// A partial response doesn't necessarily hold the start of the object
+		if contentType == "" && m.sniff && req.Method == http.MethodGet && resp.StatusCode == http.StatusOK {
+			contentType = sniffContentType(resp)
+		}
+		if contentType != "" {
+			resp.Header.Set("Content-Type", contentType)
+			resp.Header.Set("X-Content-Type-Options", "nosniff")
+			// Objects are written by the export's users, so they mustn't run scripts on the origin's host
+			resp.Header.Set("Content-Security-Policy", "sandbox")
+		}
+	}
+
+	if req.URL.Query().Has("download") {
+		resp.Header.Set("Content-Disposition", downloadDisposition(req))
+	}
+	return nil
+}
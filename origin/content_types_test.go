@@ -0,0 +1,155 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentTypeModifier(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("Origin.ContentTypes", map[string]string{"root": "application/x-root", ".FITS": "image/fits"})
+	viper.Set("Origin.SniffContentTypes", true)
+
+	// A stand-in for XRootD, which serves everything as application/octet-stream
+	objects := map[string]string{
+		"/public/data.root":  "root file",
+		"/public/image.fits": "fits file",
+		"/public/notes.txt":  "some notes",
+		"/public/page":       "<html><body>hello</body></html>",
+		"/public/blob":       "\x00\x01\x02\x03",
+	}
+	xrootd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/public/" {
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte("<html>listing</html>"))
+			return
+		}
+		body, ok := objects[req.URL.Path]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if req.Header.Get("Range") != "" {
+			w.WriteHeader(http.StatusPartialContent)
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(xrootd.Close)
+	xrootdUrl, err := url.Parse(xrootd.URL)
+	require.NoError(t, err)
+
+	modifier, err := newContentTypeModifier()
+	require.NoError(t, err)
+	proxy := httputil.NewSingleHostReverseProxy(xrootdUrl)
+	proxy.ModifyResponse = modifier.modifyResponse
+	gateway := httptest.NewServer(proxy)
+	t.Cleanup(gateway.Close)
+
+	get := func(t *testing.T, objectPath string, header http.Header) (*http.Response, string) {
+		req, err := http.NewRequest(http.MethodGet, gateway.URL+objectPath, nil)
+		require.NoError(t, err)
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, string(body)
+	}
+
+	t.Run("by-extension", func(t *testing.T) {
+		for objectPath, contentType := range map[string]string{
+			"/public/data.root":  "application/x-root",
+			"/public/image.fits": "image/fits",
+			"/public/notes.txt":  "text/plain; charset=utf-8",
+		} {
+			resp, body := get(t, objectPath, nil)
+			assert.Equal(t, contentType, resp.Header.Get("Content-Type"), objectPath)
+			assert.Equal(t, "sandbox", resp.Header.Get("Content-Security-Policy"))
+			assert.Equal(t, "nosniff", resp.Header.Get("X-Content-Type-Options"))
+			assert.Equal(t, objects[objectPath], body)
+		}
+	})
+
+	t.Run("sniffed", func(t *testing.T) {
+		resp, body := get(t, "/public/page", nil)
+		assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+		assert.Equal(t, "sandbox", resp.Header.Get("Content-Security-Policy"))
+		assert.Equal(t, objects["/public/page"], body)
+
+		resp, body = get(t, "/public/blob", nil)
+		assert.Equal(t, "application/octet-stream", resp.Header.Get("Content-Type"))
+		assert.Empty(t, resp.Header.Get("Content-Security-Policy"))
+		assert.Equal(t, objects["/public/blob"], body)
+
+		// A partial response isn't sniffed
+		resp, _ = get(t, "/public/page", http.Header{"Range": {"bytes=10-20"}})
+		assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+		assert.Equal(t, "application/octet-stream", resp.Header.Get("Content-Type"))
+	})
+
+	t.Run("directory-listing-untouched", func(t *testing.T) {
+		resp, _ := get(t, "/public/", nil)
+		assert.Equal(t, "text/html", resp.Header.Get("Content-Type"))
+		assert.Empty(t, resp.Header.Get("Content-Security-Policy"))
+	})
+
+	t.Run("download", func(t *testing.T) {
+		resp, body := get(t, "/public/notes.txt?download=my%20notes.txt", nil)
+		assert.Equal(t, `attachment; filename="my notes.txt"`, resp.Header.Get("Content-Disposition"))
+		assert.Equal(t, objects["/public/notes.txt"], body)
+
+		resp, _ = get(t, "/public/notes.txt?download", nil)
+		assert.Equal(t, "attachment; filename=notes.txt", resp.Header.Get("Content-Disposition"))
+
+		// Only the name of the file is used
+		resp, _ = get(t, "/public/notes.txt?download=../../etc/passwd", nil)
+		assert.Equal(t, "attachment; filename=passwd", resp.Header.Get("Content-Disposition"))
+
+		resp, _ = get(t, "/public/notes.txt?download=r%C3%A9sum%C3%A9.txt", nil)
+		assert.Equal(t, "attachment; filename*=utf-8''r%C3%A9sum%C3%A9.txt", resp.Header.Get("Content-Disposition"))
+
+		resp, _ = get(t, "/public/notes.txt", nil)
+		assert.Empty(t, resp.Header.Get("Content-Disposition"))
+
+		resp, _ = get(t, "/public/missing.txt?download", nil)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("Content-Disposition"))
+	})
+
+	t.Run("invalid-type", func(t *testing.T) {
+		viper.Set("Origin.ContentTypes", map[string]string{".bad": "not a type"})
+		_, err := newContentTypeModifier()
+		assert.Error(t, err)
+	})
+}
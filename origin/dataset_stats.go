@@ -0,0 +1,303 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+type (
+	// The reads of a dataset, the top-level directory of an export, during a week
+	DatasetAccessStat struct {
+		FederationPrefix string `gorm:"primaryKey"`
+		Dataset          string `gorm:"primaryKey"` // The federation path of the dataset's directory
+		Week             string `gorm:"primaryKey"` // The Monday starting the week, "2006-01-02" in UTC
+		Reads            int64  `gorm:"not null;default:0"`
+		BytesRead        int64  `gorm:"not null;default:0"`
+		UpdatedAt        time.Time
+	}
+
+	datasetStatKey struct {
+		federationPrefix string
+		dataset          string
+		week             string
+	}
+
+	datasetStatCounts struct {
+		reads     int64
+		bytesRead int64
+	}
+
+	// Accumulates the reads reported by the monitoring stream until they're saved
+	// to the origin database
+	datasetStatsTracker struct {
+		prefixes []string // Federation prefixes of the exports, longest first
+
+		lock    sync.Mutex
+		pending map[datasetStatKey]datasetStatCounts
+	}
+
+	datasetWeekStats struct {
+		Week      string `json:"week"`
+		Reads     int64  `json:"reads"`
+		BytesRead int64  `json:"bytesRead"`
+	}
+
+	datasetStats struct {
+		FederationPrefix string             `json:"federationPrefix"`
+		Dataset          string             `json:"dataset"`
+		Reads            int64              `json:"reads"`     // Total over the returned weeks
+		BytesRead        int64              `json:"bytesRead"` // Total over the returned weeks
+		Weeks            []datasetWeekStats `json:"weeks"`     // Newest first
+	}
+
+	datasetStatsRes struct {
+		Datasets []datasetStats `json:"datasets"`
+	}
+)
+
+const defaultDatasetStatsWeeks = 12
+
+// Returns the week a time falls in, as the date of its Monday in UTC
+func datasetStatsWeek(t time.Time) string {
+	t = t.UTC()
+	sinceMonday := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-sinceMonday, 0, 0, 0, 0, time.UTC).Format("2006-01-02")
+}
+
+func newDatasetStatsTracker(exports []server_utils.OriginExport) *datasetStatsTracker {
+	tracker := &datasetStatsTracker{pending: make(map[datasetStatKey]datasetStatCounts)}
+	for _, export := range exports {
+		tracker.prefixes = append(tracker.prefixes, path.Clean("/"+export.FederationPrefix))
+	}
+	sort.Slice(tracker.prefixes, func(i, j int) bool { return len(tracker.prefixes[i]) > len(tracker.prefixes[j]) })
+	return tracker
+}
+
+// Map the path of an object to its export and dataset.  Objects at the top of an
+// export belong to a dataset named after the export itself.
+func (tracker *datasetStatsTracker) lookupDataset(objectPath string) (federationPrefix, dataset string, ok bool) {
+	objectPath, _, _ = strings.Cut(objectPath, "?")
+	objectPath = path.Clean("/" + objectPath)
+	for _, prefix := range tracker.prefixes {
+		var rest string
+		if prefix == "/" {
+			rest = strings.TrimPrefix(objectPath, "/")
+		} else if strings.HasPrefix(objectPath, prefix+"/") {
+			rest = objectPath[len(prefix)+1:]
+		} else {
+			continue
+		}
+		topLevel, _, found := strings.Cut(rest, "/")
+		if !found {
+			return prefix, prefix, true
+		}
+		return prefix, path.Join(prefix, topLevel), true
+	}
+	return "", "", false
+}
+
+// Count a file closed by XRootD; files that were written to are uploads, not reads
+func (tracker *datasetStatsTracker) recordClosedFile(file metrics.ClosedFile, now time.Time) {
+	if file.WriteBytes > 0 {
+		return
+	}
+	federationPrefix, dataset, ok := tracker.lookupDataset(file.LFN)
+	if !ok {
+		return
+	}
+	key := datasetStatKey{federationPrefix: federationPrefix, dataset: dataset, week: datasetStatsWeek(now)}
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	counts := tracker.pending[key]
+	counts.reads++
+	counts.bytesRead += int64(file.ReadBytes)
+	tracker.pending[key] = counts
+}
+
+// Add the reads accumulated since the last save to the origin database
+func (tracker *datasetStatsTracker) save() error {
+	tracker.lock.Lock()
+	pending := tracker.pending
+	tracker.pending = make(map[datasetStatKey]datasetStatCounts)
+	tracker.lock.Unlock()
+
+	for key, counts := range pending {
+		stat := DatasetAccessStat{
+			FederationPrefix: key.federationPrefix,
+			Dataset:          key.dataset,
+			Week:             key.week,
+			Reads:            counts.reads,
+			BytesRead:        counts.bytesRead,
+		}
+		err := db.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "federation_prefix"}, {Name: "dataset"}, {Name: "week"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"reads":      gorm.Expr("reads + ?", counts.reads),
+				"bytes_read": gorm.Expr("bytes_read + ?", counts.bytesRead),
+				"updated_at": time.Now(),
+			}),
+		}).Create(&stat).Error
+		if err != nil {
+			// Keep the unsaved counts for the next attempt
+			tracker.lock.Lock()
+			for key, counts := range pending {
+				merged := tracker.pending[key]
+				merged.reads += counts.reads
+				merged.bytesRead += counts.bytesRead
+				tracker.pending[key] = merged
+			}
+			tracker.lock.Unlock()
+			return errors.Wrapf(err, "failed to save the access statistics of dataset %s", key.dataset)
+		}
+		delete(pending, key)
+	}
+	return nil
+}
+
+// Remove the weeks older than Origin.DatasetStatsRetention
+func pruneDatasetStats(now time.Time) error {
+	retention := param.Origin_DatasetStatsRetention.GetDuration()
+	if retention <= 0 {
+		return nil
+	}
+	err := db.Where("week < ?", datasetStatsWeek(now.Add(-retention))).Delete(&DatasetAccessStat{}).Error
+	return errors.Wrap(err, "failed to prune the dataset access statistics")
+}
+
+// Return the access statistics of the datasets over the most recent weeks, busiest first
+func getDatasetStats(federationPrefix string, weeks int, now time.Time) ([]datasetStats, error) {
+	query := db.Order("week DESC")
+	if federationPrefix != "" {
+		query = query.Where("federation_prefix = ?", path.Clean("/"+federationPrefix))
+	}
+	if weeks > 0 {
+		query = query.Where("week >= ?", datasetStatsWeek(now.AddDate(0, 0, -7*(weeks-1))))
+	}
+	rows := []DatasetAccessStat{}
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to query the dataset access statistics")
+	}
+
+	byDataset := make(map[[2]string]int) // Index of each dataset in the result
+	result := []datasetStats{}
+	for _, row := range rows {
+		key := [2]string{row.FederationPrefix, row.Dataset}
+		idx, ok := byDataset[key]
+		if !ok {
+			idx = len(result)
+			byDataset[key] = idx
+			result = append(result, datasetStats{FederationPrefix: row.FederationPrefix, Dataset: row.Dataset})
+		}
+		stats := &result[idx]
+		stats.Reads += row.Reads
+		stats.BytesRead += row.BytesRead
+		stats.Weeks = append(stats.Weeks, datasetWeekStats{Week: row.Week, Reads: row.Reads, BytesRead: row.BytesRead})
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].BytesRead != result[j].BytesRead {
+			return result[i].BytesRead > result[j].BytesRead
+		}
+		return result[i].Dataset < result[j].Dataset
+	})
+	return result, nil
+}
+
+// Handle the request for the dataset access statistics.  The optional "export" query
+// parameter limits them to one export; "weeks" sets how many recent weeks are included
+// (12 by default; 0 includes every retained week).
+func handleDatasetStats(ctx *gin.Context) {
+	weeks := defaultDatasetStatsWeeks
+	if weeksStr := ctx.Query("weeks"); weeksStr != "" {
+		var err error
+		if weeks, err = strconv.Atoi(weeksStr); err != nil || weeks < 0 {
+			ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Invalid weeks parameter: must be a non-negative integer",
+			})
+			return
+		}
+	}
+	datasets, err := getDatasetStats(ctx.Query("export"), weeks, time.Now())
+	if err != nil {
+		log.Errorln("Failed to get the dataset access statistics:", err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Failed to get the dataset access statistics",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, datasetStatsRes{Datasets: datasets})
+}
+
+// Start counting the reads of each export's datasets from the XRootD monitoring
+// stream, saving them to the origin database every minute
+func LaunchDatasetStats(ctx context.Context, egrp *errgroup.Group, exports []server_utils.OriginExport) {
+	tracker := newDatasetStatsTracker(exports)
+	metrics.SetFileCloseHandler(func(file metrics.ClosedFile) {
+		tracker.recordClosedFile(file, time.Now())
+	})
+	if err := pruneDatasetStats(time.Now()); err != nil {
+		log.Warningln(err)
+	}
+
+	egrp.Go(func() error {
+		saveTicker := time.NewTicker(time.Minute)
+		defer saveTicker.Stop()
+		pruneTicker := time.NewTicker(time.Hour)
+		defer pruneTicker.Stop()
+		for {
+			select {
+			case <-saveTicker.C:
+				if err := tracker.save(); err != nil {
+					log.Warningln("Failed to save the dataset access statistics:", err)
+				}
+			case <-pruneTicker.C:
+				if err := pruneDatasetStats(time.Now()); err != nil {
+					log.Warningln(err)
+				}
+			case <-ctx.Done():
+				metrics.SetFileCloseHandler(nil)
+				if err := tracker.save(); err != nil {
+					log.Warningln("Failed to save the dataset access statistics:", err)
+				}
+				return nil
+			}
+		}
+	})
+}
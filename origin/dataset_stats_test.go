@@ -0,0 +1,148 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+func TestDatasetStatsWeek(t *testing.T) {
+	// 2024-10-14 is a Monday
+	assert.Equal(t, "2024-10-14", datasetStatsWeek(time.Date(2024, 10, 14, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "2024-10-14", datasetStatsWeek(time.Date(2024, 10, 20, 23, 59, 0, 0, time.UTC)))
+	assert.Equal(t, "2024-10-21", datasetStatsWeek(time.Date(2024, 10, 21, 1, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "2024-12-30", datasetStatsWeek(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)))
+}
+
+func TestDatasetStatsLookup(t *testing.T) {
+	tracker := newDatasetStatsTracker([]server_utils.OriginExport{
+		{FederationPrefix: "/foo"},
+		{FederationPrefix: "/foo/bar"},
+	})
+	for objectPath, expected := range map[string][2]string{
+		"/foo/run1/data.root":        {"/foo", "/foo/run1"},
+		"/foo/readme.txt":            {"/foo", "/foo"},
+		"/foo/bar/run2/a/b.root":     {"/foo/bar", "/foo/bar/run2"},
+		"/foo/bar/run3/c.root?x=y":   {"/foo/bar", "/foo/bar/run3"},
+		"//foo//run1/../run4/d.root": {"/foo", "/foo/run4"},
+	} {
+		prefix, dataset, ok := tracker.lookupDataset(objectPath)
+		require.True(t, ok, objectPath)
+		assert.Equal(t, expected, [2]string{prefix, dataset}, objectPath)
+	}
+	_, _, ok := tracker.lookupDataset("/foobar/run1/data.root")
+	assert.False(t, ok)
+}
+
+func TestDatasetStatsPersistence(t *testing.T) {
+	mockDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, mockDB.AutoMigrate(&DatasetAccessStat{}))
+	db = mockDB
+	t.Cleanup(func() {
+		db = nil
+		viper.Reset()
+	})
+
+	tracker := newDatasetStatsTracker([]server_utils.OriginExport{{FederationPrefix: "/foo"}})
+	now := time.Date(2024, 10, 16, 12, 0, 0, 0, time.UTC)
+	lastWeek := now.AddDate(0, 0, -7)
+
+	tracker.recordClosedFile(metrics.ClosedFile{LFN: "/foo/run1/a", ReadBytes: 100}, lastWeek)
+	tracker.recordClosedFile(metrics.ClosedFile{LFN: "/foo/run1/b", ReadBytes: 50}, now)
+	tracker.recordClosedFile(metrics.ClosedFile{LFN: "/foo/run2/a", ReadBytes: 10}, now)
+	// Uploads and objects outside the exports aren't counted
+	tracker.recordClosedFile(metrics.ClosedFile{LFN: "/foo/run2/b", WriteBytes: 1000}, now)
+	tracker.recordClosedFile(metrics.ClosedFile{LFN: "/other/run1/a", ReadBytes: 1000}, now)
+	require.NoError(t, tracker.save())
+
+	// Saving again adds to the stored counts
+	tracker.recordClosedFile(metrics.ClosedFile{LFN: "/foo/run1/c", ReadBytes: 25}, now)
+	require.NoError(t, tracker.save())
+
+	datasets, err := getDatasetStats("", 0, now)
+	require.NoError(t, err)
+	require.Len(t, datasets, 2)
+	assert.Equal(t, datasetStats{
+		FederationPrefix: "/foo",
+		Dataset:          "/foo/run1",
+		Reads:            3,
+		BytesRead:        175,
+		Weeks: []datasetWeekStats{
+			{Week: "2024-10-14", Reads: 2, BytesRead: 75},
+			{Week: "2024-10-07", Reads: 1, BytesRead: 100},
+		},
+	}, datasets[0])
+	assert.Equal(t, "/foo/run2", datasets[1].Dataset)
+	assert.Equal(t, int64(1), datasets[1].Reads)
+
+	// Only the current week
+	datasets, err = getDatasetStats("/foo", 1, now)
+	require.NoError(t, err)
+	require.Len(t, datasets, 2)
+	assert.Equal(t, int64(75), datasets[0].BytesRead)
+	assert.Len(t, datasets[0].Weeks, 1)
+
+	t.Run("api", func(t *testing.T) {
+		router := gin.New()
+		router.GET("/dataset_stats", handleDatasetStats)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/dataset_stats?weeks=0&export=/foo", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		res := datasetStatsRes{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		require.Len(t, res.Datasets, 2)
+		assert.Equal(t, int64(175), res.Datasets[0].BytesRead)
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/dataset_stats?weeks=-1", nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("prune", func(t *testing.T) {
+		// A week is kept as long as any of it is within the retention
+		viper.Set("Origin.DatasetStatsRetention", "72h")
+		require.NoError(t, pruneDatasetStats(now))
+		datasets, err := getDatasetStats("", 0, now)
+		require.NoError(t, err)
+		assert.Equal(t, int64(175), datasets[0].BytesRead)
+
+		viper.Set("Origin.DatasetStatsRetention", "48h")
+		require.NoError(t, pruneDatasetStats(now))
+		datasets, err = getDatasetStats("", 0, now)
+		require.NoError(t, err)
+		require.Len(t, datasets, 2)
+		assert.Equal(t, int64(75), datasets[0].BytesRead)
+	})
+}
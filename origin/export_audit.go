@@ -0,0 +1,366 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/studio-b12/gowebdav"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_utils"
+	"github.com/pelicanplatform/pelican/token"
+)
+
+type (
+	// The result of the most recent consistency audit for a single export
+	ExportAuditStatus struct {
+		Status      string    `json:"status"` // "ok" | "warning" | "critical" | "unknown"
+		Message     string    `json:"message,omitempty"`
+		LastChecked time.Time `json:"lastChecked"`
+	}
+
+	// A problem found while auditing an export.  Critical problems indicate
+	// the export cannot serve any data as advertised; otherwise, only some
+	// of the sampled objects were affected.
+	auditProblem struct {
+		critical bool
+		msg      string
+	}
+)
+
+var (
+	exportAuditStatus     = make(map[string]ExportAuditStatus)
+	exportAuditStatusLock sync.RWMutex
+)
+
+func (p *auditProblem) Error() string {
+	return p.msg
+}
+
+func newCriticalProblem(format string, args ...any) *auditProblem {
+	return &auditProblem{critical: true, msg: fmt.Sprintf(format, args...)}
+}
+
+func newWarningProblem(format string, args ...any) *auditProblem {
+	return &auditProblem{msg: fmt.Sprintf(format, args...)}
+}
+
+// Get the most recent audit status of an export, keyed by its federation prefix
+func GetExportAuditStatus(federationPrefix string) (status ExportAuditStatus, ok bool) {
+	exportAuditStatusLock.RLock()
+	defer exportAuditStatusLock.RUnlock()
+	status, ok = exportAuditStatus[federationPrefix]
+	return
+}
+
+func setExportAuditStatus(federationPrefix string, status ExportAuditStatus) {
+	exportAuditStatusLock.Lock()
+	defer exportAuditStatusLock.Unlock()
+	exportAuditStatus[federationPrefix] = status
+}
+
+// Randomly select up to `count` entries from the list of candidates
+func sampleAuditPaths(candidates []string, count int) []string {
+	if count <= 0 || len(candidates) <= count {
+		return candidates
+	}
+	shuffled := make([]string, len(candidates))
+	copy(shuffled, candidates)
+	rand.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+	return shuffled[:count]
+}
+
+// Audit an export backed by the POSIX filesystem by inspecting the storage directly.
+//
+// Checks that the storage prefix exists and is accessible to the daemon user
+// with the permissions implied by the export capabilities, then attempts to
+// read a sample of the objects in the top level of the export.
+func auditPosixExport(export server_utils.OriginExport, sampleSize int) error {
+	info, err := os.Stat(export.StoragePrefix)
+	if errors.Is(err, os.ErrNotExist) {
+		return newCriticalProblem("storage prefix %s for export %s does not exist; create it or update Origin.Exports", export.StoragePrefix, export.FederationPrefix)
+	} else if errors.Is(err, os.ErrPermission) {
+		return newCriticalProblem("permission denied when accessing storage prefix %s for export %s; check the permissions of its parent directories", export.StoragePrefix, export.FederationPrefix)
+	} else if err != nil {
+		return newCriticalProblem("unable to access storage prefix %s for export %s: %v", export.StoragePrefix, export.FederationPrefix, err)
+	}
+	if !info.IsDir() {
+		return newCriticalProblem("storage prefix %s for export %s is not a directory", export.StoragePrefix, export.FederationPrefix)
+	}
+	if err := checkDaemonAccess(info, export.StoragePrefix, accessRead|accessExecute); err != nil {
+		return newCriticalProblem("export %s is advertised as readable but %v", export.FederationPrefix, err)
+	}
+	if export.Capabilities.Writes {
+		if err := checkDaemonAccess(info, export.StoragePrefix, accessWrite|accessExecute); err != nil {
+			return newCriticalProblem("export %s is advertised as writable but %v", export.FederationPrefix, err)
+		}
+	}
+
+	candidates := []string{}
+	if export.SentinelLocation != "" {
+		candidates = append(candidates, export.SentinelLocation)
+	}
+	entries, err := os.ReadDir(export.StoragePrefix)
+	if err != nil {
+		return newCriticalProblem("unable to list storage prefix %s for export %s: %v", export.StoragePrefix, export.FederationPrefix, err)
+	}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && entry.Name() != export.SentinelLocation {
+			candidates = append(candidates, entry.Name())
+		}
+	}
+
+	failures := []string{}
+	for _, name := range sampleAuditPaths(candidates, sampleSize) {
+		objPath := filepath.Join(export.StoragePrefix, name)
+		if err := auditPosixObject(objPath); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	if len(failures) > 0 {
+		sort.Strings(failures)
+		return newWarningProblem("%d of the sampled objects in export %s are not readable: %s", len(failures), export.FederationPrefix, strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// Check that a POSIX export's storage is accessible to the daemon user, as the consistency
+// audit does, without starting the origin.  Returns whether a problem is critical.
+func CheckPosixExport(export server_utils.OriginExport) (critical bool, err error) {
+	err = auditPosixExport(export, param.Origin_ExportAuditSampleSize.GetInt())
+	var problem *auditProblem
+	if errors.As(err, &problem) {
+		critical = problem.critical
+	}
+	return
+}
+
+// Verify a single object is readable by the daemon user
+func auditPosixObject(objPath string) error {
+	info, err := os.Stat(objPath)
+	if err != nil {
+		return errors.Wrapf(err, "unable to stat %s", objPath)
+	}
+	if err := checkDaemonAccess(info, objPath, accessRead); err != nil {
+		return err
+	}
+	fp, err := os.Open(objPath)
+	if err != nil {
+		return errors.Wrapf(err, "unable to open %s", objPath)
+	}
+	defer fp.Close()
+	buf := make([]byte, 1)
+	if _, err := fp.Read(buf); err != nil && err != io.EOF {
+		return errors.Wrapf(err, "unable to read %s", objPath)
+	}
+	return nil
+}
+
+// Generate a token permitting the audit to read from the export via XRootD
+func generateAuditToken() (string, error) {
+	issuerUrl, err := config.GetServerIssuerURL()
+	if err != nil {
+		return "", err
+	}
+	tokenCfg := token.NewWLCGToken()
+	tokenCfg.Lifetime = time.Minute
+	tokenCfg.Issuer = issuerUrl
+	tokenCfg.Subject = "origin"
+	// Scopes are relative to the issuer's base paths (the exported prefixes)
+	tokenCfg.Claims = map[string]string{"scope": "storage.read:/"}
+	tokenCfg.AddAudiences(config.GetServerAudience())
+	return tokenCfg.CreateToken()
+}
+
+// Audit an export whose storage is not locally accessible (S3, HTTPS, Globus, ...).
+//
+// The backend is exercised through the origin's own XRootD HTTP interface, so this
+// verifies the complete path a client request would take.  Objects are sampled from
+// a directory listing (when the export advertises listings) and the sentinel file.
+func auditRemoteExport(ctx context.Context, export server_utils.OriginExport, sampleSize int) error {
+	if !export.Capabilities.PublicReads && !param.Origin_EnableIssuer.GetBool() {
+		return newWarningProblem("export %s is neither public nor readable with the origin's issuer; its backend can only be audited for public exports or when Origin.EnableIssuer is set", export.FederationPrefix)
+	}
+	originUrl, err := url.Parse(param.Origin_Url.GetString())
+	if err != nil {
+		return newCriticalProblem("unable to parse Origin.Url: %v", err)
+	}
+	authHeader := ""
+	if !export.Capabilities.PublicReads {
+		tok, err := generateAuditToken()
+		if err != nil {
+			return newWarningProblem("unable to generate a token to audit export %s: %v", export.FederationPrefix, err)
+		}
+		authHeader = "Bearer " + tok
+	}
+
+	candidates := []string{}
+	sentinelPath := ""
+	if export.SentinelLocation != "" {
+		sentinelPath = path.Join(export.FederationPrefix, export.SentinelLocation)
+		candidates = append(candidates, sentinelPath)
+	}
+	if export.Capabilities.Listings {
+		davClient := gowebdav.NewClient(originUrl.String(), "", "")
+		davClient.SetTransport(config.GetTransport())
+		if authHeader != "" {
+			davClient.SetHeader("Authorization", authHeader)
+		}
+		infos, err := davClient.ReadDir(export.FederationPrefix)
+		if err != nil {
+			return newCriticalProblem("export %s is advertised as listable but listing it via the origin failed: %v", export.FederationPrefix, err)
+		}
+		for _, info := range infos {
+			if objPath := path.Join(export.FederationPrefix, info.Name()); !info.IsDir() && objPath != sentinelPath {
+				candidates = append(candidates, objPath)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		log.Debugf("No objects to sample for export %s; configure a sentinel location or enable listings to audit it", export.FederationPrefix)
+		return nil
+	}
+
+	client := http.Client{Transport: config.GetTransport()}
+	failures := []string{}
+	sentinelFailed := false
+	sampled := sampleAuditPaths(candidates, sampleSize)
+	for _, objPath := range sampled {
+		failureCount := len(failures)
+		objUrl := *originUrl
+		objUrl.Path = objPath
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, objUrl.String(), nil)
+		if err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+		req.Header.Set("Range", "bytes=0-0")
+		if authHeader != "" {
+			req.Header.Set("Authorization", authHeader)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("request for %s failed: %v", objPath, err))
+			continue
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		switch {
+		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+			failures = append(failures, fmt.Sprintf("%s was denied with status %d; check the credentials and access policy (e.g., bucket policy) of the backend", objPath, resp.StatusCode))
+		case resp.StatusCode >= 300:
+			failures = append(failures, fmt.Sprintf("%s returned status %d", objPath, resp.StatusCode))
+		}
+		if objPath == sentinelPath && len(failures) > failureCount {
+			sentinelFailed = true
+		}
+	}
+	if len(failures) == len(sampled) || sentinelFailed {
+		return newCriticalProblem("none of the sampled objects in export %s could be read through the origin: %s", export.FederationPrefix, strings.Join(failures, "; "))
+	} else if len(failures) > 0 {
+		return newWarningProblem("%d of the sampled objects in export %s could not be read through the origin: %s", len(failures), export.FederationPrefix, strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// Run a single consistency audit across all the exports, updating the per-export
+// status and the overall export-audit component health.
+func doExportAudit(ctx context.Context) {
+	exports, err := server_utils.GetOriginExports()
+	if err != nil {
+		log.Errorln("Failed to get origin exports for consistency audit:", err)
+		return
+	}
+	storageType := server_utils.OriginStorageType(param.Origin_StorageType.GetString())
+	sampleSize := param.Origin_ExportAuditSampleSize.GetInt()
+
+	overall := metrics.StatusOK
+	problems := []string{}
+	for _, export := range exports {
+		var auditErr error
+		if storageType == server_utils.OriginStoragePosix {
+			auditErr = auditPosixExport(export, sampleSize)
+		} else {
+			auditErr = auditRemoteExport(ctx, export, sampleSize)
+		}
+		status := ExportAuditStatus{Status: metrics.StatusOK.String(), LastChecked: time.Now()}
+		if auditErr != nil {
+			level := metrics.StatusWarning
+			var problem *auditProblem
+			if errors.As(auditErr, &problem) && problem.critical {
+				level = metrics.StatusCritical
+			}
+			if level < overall {
+				overall = level
+			}
+			status.Status = level.String()
+			status.Message = auditErr.Error()
+			problems = append(problems, auditErr.Error())
+			log.Warningf("Consistency audit of export %s found a problem: %v", export.FederationPrefix, auditErr)
+		}
+		setExportAuditStatus(export.FederationPrefix, status)
+	}
+
+	if len(problems) == 0 {
+		metrics.SetComponentHealthStatus(metrics.Origin_ExportAudit, metrics.StatusOK, "Export consistency audit succeeded at "+time.Now().Format(time.RFC3339))
+	} else {
+		metrics.SetComponentHealthStatus(metrics.Origin_ExportAudit, overall, strings.Join(problems, "\n"))
+	}
+}
+
+// Periodically audit the origin's exports against the storage backend.  The
+// exports advertised to the federation are compared to what the backend
+// actually permits, flipping the per-export status when they drift.
+func PeriodicExportAudit(ctx context.Context) error {
+	interval := param.Origin_ExportAuditInterval.GetDuration()
+	if interval <= 0 {
+		interval = 10 * time.Minute
+		log.Errorf("Invalid config value: Origin.ExportAuditInterval is %s. Fallback to 10m.", param.Origin_ExportAuditInterval.GetDuration())
+	}
+	firstRound := time.After(10 * time.Second)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-firstRound:
+			doExportAudit(ctx)
+		case <-ticker.C:
+			doExportAudit(ctx)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
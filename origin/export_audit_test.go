@@ -0,0 +1,110 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+func TestSampleAuditPaths(t *testing.T) {
+	candidates := []string{"a", "b", "c", "d", "e"}
+	assert.Equal(t, candidates, sampleAuditPaths(candidates, 0))
+	assert.Equal(t, candidates, sampleAuditPaths(candidates, 10))
+
+	sampled := sampleAuditPaths(candidates, 3)
+	assert.Len(t, sampled, 3)
+	for _, name := range sampled {
+		assert.Contains(t, candidates, name)
+	}
+	// The input must not be reordered
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, candidates)
+}
+
+func TestAuditPosixExport(t *testing.T) {
+	isCritical := func(t *testing.T, err error) bool {
+		var problem *auditProblem
+		require.True(t, errors.As(err, &problem))
+		return problem.critical
+	}
+
+	t.Run("missing-storage-prefix", func(t *testing.T) {
+		export := server_utils.OriginExport{
+			FederationPrefix: "/test",
+			StoragePrefix:    filepath.Join(t.TempDir(), "missing"),
+		}
+		err := auditPosixExport(export, 5)
+		require.Error(t, err)
+		assert.True(t, isCritical(t, err))
+		assert.Contains(t, err.Error(), "does not exist")
+	})
+
+	t.Run("storage-prefix-not-directory", func(t *testing.T) {
+		storage := filepath.Join(t.TempDir(), "file")
+		require.NoError(t, os.WriteFile(storage, []byte("data"), 0644))
+		export := server_utils.OriginExport{FederationPrefix: "/test", StoragePrefix: storage}
+		err := auditPosixExport(export, 5)
+		require.Error(t, err)
+		assert.True(t, isCritical(t, err))
+		assert.Contains(t, err.Error(), "not a directory")
+	})
+
+	t.Run("readable-export", func(t *testing.T) {
+		storage := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(storage, "sentinel"), []byte("ok"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(storage, "empty"), []byte{}, 0644))
+		require.NoError(t, os.Mkdir(filepath.Join(storage, "subdir"), 0755))
+		export := server_utils.OriginExport{
+			FederationPrefix: "/test",
+			StoragePrefix:    storage,
+			SentinelLocation: "sentinel",
+		}
+		assert.NoError(t, auditPosixExport(export, 5))
+	})
+
+	t.Run("missing-sentinel", func(t *testing.T) {
+		export := server_utils.OriginExport{
+			FederationPrefix: "/test",
+			StoragePrefix:    t.TempDir(),
+			SentinelLocation: "sentinel",
+		}
+		err := auditPosixExport(export, 5)
+		require.Error(t, err)
+		assert.False(t, isCritical(t, err))
+		assert.Contains(t, err.Error(), "sentinel")
+	})
+}
+
+func TestExportAuditStatus(t *testing.T) {
+	_, ok := GetExportAuditStatus("/no-such-export")
+	assert.False(t, ok)
+
+	setExportAuditStatus("/audit-test", ExportAuditStatus{Status: "warning", Message: "problem"})
+	status, ok := GetExportAuditStatus("/audit-test")
+	require.True(t, ok)
+	assert.Equal(t, "warning", status.Status)
+	assert.Equal(t, "problem", status.Message)
+}
@@ -0,0 +1,73 @@
+//go:build !windows
+
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+
+	"github.com/pelicanplatform/pelican/config"
+)
+
+const (
+	accessRead    uint32 = 4
+	accessWrite   uint32 = 2
+	accessExecute uint32 = 1
+)
+
+// Check the permission bits of a file to determine whether the daemon user
+// (which XRootD runs as) has the requested access.  The origin process itself
+// often runs as root, so simply attempting the operation is not sufficient.
+func checkDaemonAccess(info os.FileInfo, filePath string, access uint32) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	uid, err := config.GetDaemonUID()
+	if err != nil {
+		return nil
+	}
+	gid, err := config.GetDaemonGID()
+	if err != nil {
+		return nil
+	}
+	// Root can access everything; a negative UID indicates the daemon user is unknown
+	if uid <= 0 {
+		return nil
+	}
+	perm := uint32(info.Mode().Perm())
+	var granted uint32
+	if int(stat.Uid) == uid {
+		granted = (perm >> 6) & 7
+	} else if int(stat.Gid) == gid {
+		granted = (perm >> 3) & 7
+	} else {
+		granted = perm & 7
+	}
+	if granted&access != access {
+		user, _ := config.GetDaemonUser()
+		return errors.Errorf("%s (mode %s, owner %d:%d) is not accessible to the daemon user %s (uid %d, gid %d)",
+			filePath, info.Mode().Perm().String(), stat.Uid, stat.Gid, user, uid, gid)
+	}
+	return nil
+}
@@ -0,0 +1,494 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/token"
+)
+
+type (
+	// Adds the credentials of the origin to a request to the HTTPS backend
+	httpsUpstreamAuth interface {
+		authorize(req *http.Request) error
+	}
+
+	// A secret kept in a file, reloaded whenever the file changes so that
+	// credentials can be rotated without restarting the origin
+	fileSecret struct {
+		path    string
+		lock    sync.Mutex
+		value   string
+		modTime time.Time
+	}
+
+	staticBearerAuth struct {
+		token *fileSecret
+	}
+
+	basicAuth struct {
+		username string
+		password *fileSecret
+	}
+
+	// Exchanges a token issued by the origin for a token of the backend's
+	// authorization server, following RFC 8693
+	tokenExchangeAuth struct {
+		endpoint     string
+		clientID     string
+		clientSecret *fileSecret // nil for public clients
+		audience     string
+		scope        string
+		client       *http.Client
+
+		lock   sync.Mutex
+		token  string
+		expiry time.Time
+	}
+
+	tokenExchangeRes struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+
+	// A gateway between XRootD's HTTP server plugin and the HTTPS backend.  XRootD is
+	// configured to send requests to the gateway, authenticated only by the gateway's
+	// secret (see loopbackAuthHandler), which forwards them
+	// to Origin.HttpServiceUrl with the origin's credentials and the configured header
+	// rewrites, so an existing web server can be exported without changes on its side.
+	httpsGateway struct {
+		upstream        *url.URL
+		client          *http.Client
+		auth            httpsUpstreamAuth // nil if the backend needs no authentication
+		requestHeaders  map[string]string
+		responseHeaders map[string]string
+		tape            *tapeBackend // nil unless the backend is tape-backed
+	}
+)
+
+const (
+	HttpAuthNone          = "none"
+	HttpAuthBearer        = "bearer"
+	HttpAuthBasic         = "basic"
+	HttpAuthClientCert    = "clientcert"
+	HttpAuthTokenExchange = "tokenexchange"
+
+	// Exchanged tokens are renewed this long before they expire
+	tokenExchangeRefreshMargin = time.Minute
+	// The lifetime assumed for exchanged tokens whose response has no expires_in
+	tokenExchangeDefaultLifetime = 5 * time.Minute
+)
+
+var (
+	httpsGatewayLock sync.RWMutex
+	httpsGatewayUrl  string
+
+	// Headers the gateway does not forward: the credentials are the gateway's to add,
+	// and the others are hop-by-hop
+	httpsDroppedHeaders = map[string]bool{
+		"Authorization":     true,
+		"Connection":        true,
+		"Keep-Alive":        true,
+		"Proxy-Connection":  true,
+		"Te":                true,
+		"Trailer":           true,
+		"Transfer-Encoding": true,
+		"Upgrade":           true,
+	}
+)
+
+// Get the URL of the HTTPS backend gateway that XRootD should use as the HTTP
+// service URL, or an empty string if the gateway isn't running
+func GetHttpsGatewayUrl() string {
+	httpsGatewayLock.RLock()
+	defer httpsGatewayLock.RUnlock()
+	return httpsGatewayUrl
+}
+
+// Returns true if the origin needs the gateway in front of its HTTPS backend
+func HttpsGatewayNeeded() bool {
+	method := param.Origin_HttpAuthMethod.GetString()
+	return (method != "" && method != HttpAuthNone) ||
+		param.Origin_HttpRequestHeaders.IsSet() || param.Origin_HttpResponseHeaders.IsSet() ||
+		param.Origin_HttpTapeRestApiUrl.GetString() != ""
+}
+
+func newFileSecret(path, paramName string) (*fileSecret, error) {
+	if path == "" {
+		return nil, errors.Errorf("%s must be set for Origin.HttpAuthMethod %q", paramName, param.Origin_HttpAuthMethod.GetString())
+	}
+	secret := &fileSecret{path: path}
+	if _, err := secret.get(); err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", paramName)
+	}
+	return secret, nil
+}
+
+func (fs *fileSecret) get() (string, error) {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+	info, err := os.Stat(fs.path)
+	if err != nil {
+		return "", err
+	}
+	if fs.value != "" && info.ModTime().Equal(fs.modTime) {
+		return fs.value, nil
+	}
+	contents, err := os.ReadFile(fs.path)
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimSpace(string(contents))
+	if value == "" {
+		return "", errors.Errorf("the file %s is empty", fs.path)
+	}
+	fs.value = value
+	fs.modTime = info.ModTime()
+	return value, nil
+}
+
+func (auth *staticBearerAuth) authorize(req *http.Request) error {
+	tok, err := auth.token.get()
+	if err != nil {
+		return errors.Wrap(err, "failed to read the backend bearer token")
+	}
+	req.Header.Set("Authorization", "Bearer "+tok)
+	return nil
+}
+
+func (auth *basicAuth) authorize(req *http.Request) error {
+	password, err := auth.password.get()
+	if err != nil {
+		return errors.Wrap(err, "failed to read the backend password")
+	}
+	req.SetBasicAuth(auth.username, password)
+	return nil
+}
+
+// Create the token the origin exchanges for a backend token, issued by the
+// origin itself so the backend's authorization server can verify it against the
+// origin's public keys
+func createSubjectToken(audience string) (string, error) {
+	issuerUrl, err := config.GetServerIssuerURL()
+	if err != nil {
+		return "", err
+	}
+	tokenCfg := token.NewWLCGToken()
+	tokenCfg.Lifetime = time.Minute
+	tokenCfg.Issuer = issuerUrl
+	tokenCfg.Subject = "origin"
+	tokenCfg.AddAudiences(audience)
+	return tokenCfg.CreateToken()
+}
+
+func (auth *tokenExchangeAuth) exchange(ctx context.Context) (tok string, expiry time.Time, err error) {
+	audience := auth.audience
+	if audience == "" {
+		audience = auth.endpoint
+	}
+	subjectToken, err := createSubjectToken(audience)
+	if err != nil {
+		return "", expiry, errors.Wrap(err, "failed to create the token to exchange")
+	}
+	form := url.Values{
+		"grant_type":           {"urn:ietf:params:oauth:grant-type:token-exchange"},
+		"subject_token":        {subjectToken},
+		"subject_token_type":   {"urn:ietf:params:oauth:token-type:jwt"},
+		"requested_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+	}
+	if auth.audience != "" {
+		form.Set("audience", auth.audience)
+	}
+	if auth.scope != "" {
+		form.Set("scope", auth.scope)
+	}
+	if auth.clientSecret == nil && auth.clientID != "" {
+		// Public clients identify themselves in the form instead of authenticating
+		form.Set("client_id", auth.clientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, auth.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", expiry, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if auth.clientSecret != nil {
+		secret, err := auth.clientSecret.get()
+		if err != nil {
+			return "", expiry, errors.Wrap(err, "failed to read the token exchange client secret")
+		}
+		req.SetBasicAuth(url.QueryEscape(auth.clientID), url.QueryEscape(secret))
+	}
+
+	now := time.Now()
+	resp, err := auth.client.Do(req)
+	if err != nil {
+		return "", expiry, errors.Wrap(err, "failed to contact the token exchange endpoint")
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return "", expiry, errors.Wrap(err, "failed to read the token exchange response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", expiry, errors.Errorf("the token exchange endpoint responded with status code %d: %s", resp.StatusCode, string(body))
+	}
+	exchangeRes := tokenExchangeRes{}
+	if err = json.Unmarshal(body, &exchangeRes); err != nil {
+		return "", expiry, errors.Wrap(err, "failed to parse the token exchange response")
+	}
+	if exchangeRes.AccessToken == "" {
+		return "", expiry, errors.New("the token exchange response has no access token")
+	}
+	lifetime := tokenExchangeDefaultLifetime
+	if exchangeRes.ExpiresIn > 0 {
+		lifetime = time.Duration(exchangeRes.ExpiresIn) * time.Second
+	}
+	return exchangeRes.AccessToken, now.Add(lifetime), nil
+}
+
+func (auth *tokenExchangeAuth) authorize(req *http.Request) error {
+	auth.lock.Lock()
+	defer auth.lock.Unlock()
+	if auth.token == "" || time.Now().After(auth.expiry.Add(-tokenExchangeRefreshMargin)) {
+		tok, expiry, err := auth.exchange(req.Context())
+		if err != nil {
+			return err
+		}
+		log.Debugf("Exchanged the origin's token for a backend token valid until %s", expiry.Format(time.RFC3339))
+		auth.token, auth.expiry = tok, expiry
+	}
+	req.Header.Set("Authorization", "Bearer "+auth.token)
+	return nil
+}
+
+// Load one of the header rewrite maps, canonicalizing the header names
+func loadHeaderRewrites(headerParam param.ObjectParam, paramName string) (map[string]string, error) {
+	raw := map[string]string{}
+	if err := headerParam.Unmarshal(&raw); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", paramName)
+	}
+	headers := make(map[string]string, len(raw))
+	for name, value := range raw {
+		headers[http.CanonicalHeaderKey(name)] = value
+	}
+	return headers, nil
+}
+
+// Apply header rewrites: each header is replaced by the configured value, or
+// removed if the value is empty
+func rewriteHeaders(header http.Header, rewrites map[string]string) {
+	for name, value := range rewrites {
+		if value == "" {
+			header.Del(name)
+		} else {
+			header.Set(name, value)
+		}
+	}
+}
+
+func newHttpsGateway() (*httpsGateway, error) {
+	upstream, err := url.Parse(param.Origin_HttpServiceUrl.GetString())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse Origin.HttpServiceUrl")
+	}
+	if upstream.Scheme == "" || upstream.Host == "" {
+		return nil, errors.Errorf("Origin.HttpServiceUrl %q must be an absolute URL", param.Origin_HttpServiceUrl.GetString())
+	}
+	transport := config.GetTransport().Clone()
+	gw := &httpsGateway{
+		upstream: upstream,
+		client:   &http.Client{Transport: transport},
+	}
+	if gw.requestHeaders, err = loadHeaderRewrites(param.Origin_HttpRequestHeaders, "Origin.HttpRequestHeaders"); err != nil {
+		return nil, err
+	}
+	if gw.responseHeaders, err = loadHeaderRewrites(param.Origin_HttpResponseHeaders, "Origin.HttpResponseHeaders"); err != nil {
+		return nil, err
+	}
+
+	switch method := param.Origin_HttpAuthMethod.GetString(); method {
+	case "", HttpAuthNone:
+	case HttpAuthBearer:
+		secret, err := newFileSecret(param.Origin_HttpBearerTokenFile.GetString(), "Origin.HttpBearerTokenFile")
+		if err != nil {
+			return nil, err
+		}
+		gw.auth = &staticBearerAuth{token: secret}
+	case HttpAuthBasic:
+		username := param.Origin_HttpBasicAuthUsername.GetString()
+		if username == "" {
+			return nil, errors.New("Origin.HttpBasicAuthUsername must be set for Origin.HttpAuthMethod \"basic\"")
+		}
+		secret, err := newFileSecret(param.Origin_HttpBasicAuthPasswordFile.GetString(), "Origin.HttpBasicAuthPasswordFile")
+		if err != nil {
+			return nil, err
+		}
+		gw.auth = &basicAuth{username: username, password: secret}
+	case HttpAuthClientCert:
+		certFile, keyFile := param.Origin_HttpClientCertFile.GetString(), param.Origin_HttpClientKeyFile.GetString()
+		if certFile == "" || keyFile == "" {
+			return nil, errors.New("Origin.HttpClientCertFile and Origin.HttpClientKeyFile must be set for Origin.HttpAuthMethod \"clientcert\"")
+		}
+		// Loading the certificate on each handshake picks up renewed certificates
+		if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+			return nil, errors.Wrap(err, "failed to load the backend client certificate")
+		}
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		} else {
+			transport.TLSClientConfig = transport.TLSClientConfig.Clone()
+		}
+		transport.TLSClientConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+			if err != nil {
+				log.Errorln("Failed to load the backend client certificate:", err)
+				return nil, err
+			}
+			return &cert, nil
+		}
+	case HttpAuthTokenExchange:
+		endpoint := param.Origin_HttpTokenExchangeUrl.GetString()
+		if endpoint == "" {
+			return nil, errors.New("Origin.HttpTokenExchangeUrl must be set for Origin.HttpAuthMethod \"tokenexchange\"")
+		}
+		auth := &tokenExchangeAuth{
+			endpoint: endpoint,
+			clientID: param.Origin_HttpTokenExchangeClientID.GetString(),
+			audience: param.Origin_HttpTokenExchangeAudience.GetString(),
+			scope:    param.Origin_HttpTokenExchangeScope.GetString(),
+			client:   &http.Client{Transport: config.GetTransport()},
+		}
+		if secretFile := param.Origin_HttpTokenExchangeClientSecretFile.GetString(); secretFile != "" {
+			if auth.clientSecret, err = newFileSecret(secretFile, "Origin.HttpTokenExchangeClientSecretFile"); err != nil {
+				return nil, err
+			}
+		}
+		gw.auth = auth
+	default:
+		return nil, errors.Errorf("unknown Origin.HttpAuthMethod %q; must be one of %s, %s, %s, %s, or %s",
+			method, HttpAuthNone, HttpAuthBearer, HttpAuthBasic, HttpAuthClientCert, HttpAuthTokenExchange)
+	}
+	if param.Origin_HttpTapeRestApiUrl.GetString() != "" {
+		if gw.tape, err = newTapeBackend(gw.client, gw.auth); err != nil {
+			return nil, err
+		}
+	}
+	return gw, nil
+}
+
+// Build the URL of the backend for the path XRootD requested
+func (gw *httpsGateway) getUpstreamUrl(reqUrl *url.URL) *url.URL {
+	result := *gw.upstream
+	result.Path = strings.TrimSuffix(result.Path, "/") + "/" + strings.TrimPrefix(reqUrl.Path, "/")
+	result.RawPath = ""
+	result.RawQuery = reqUrl.RawQuery
+	return &result
+}
+
+func (gw *httpsGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	upstreamUrl := gw.getUpstreamUrl(r.URL)
+	if gw.tape != nil && r.Method == http.MethodGet && !gw.tape.checkOnline(w, r, upstreamUrl.Path) {
+		return
+	}
+	upstreamReq, err := http.NewRequestWithContext(r.Context(), r.Method, upstreamUrl.String(), r.Body)
+	if err != nil {
+		http.Error(w, "Failed to create the request to the HTTPS backend", http.StatusInternalServerError)
+		return
+	}
+	upstreamReq.ContentLength = r.ContentLength
+	for key, values := range r.Header {
+		if !httpsDroppedHeaders[http.CanonicalHeaderKey(key)] {
+			upstreamReq.Header[key] = values
+		}
+	}
+	rewriteHeaders(upstreamReq.Header, gw.requestHeaders)
+	if gw.auth != nil {
+		if err := gw.auth.authorize(upstreamReq); err != nil {
+			log.Errorln("Failed to authorize the request to the HTTPS backend:", err)
+			http.Error(w, "Failed to authorize the request to the HTTPS backend", http.StatusBadGateway)
+			return
+		}
+	}
+
+	resp, err := gw.client.Do(upstreamReq)
+	if err != nil {
+		log.Warningln("Failed to contact the HTTPS backend:", err)
+		http.Error(w, "Failed to contact the HTTPS backend", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		log.Warningf("The HTTPS backend rejected the origin's credentials for %s with status code %d", upstreamReq.URL.Path, resp.StatusCode)
+	}
+
+	for key, values := range resp.Header {
+		if !httpsDroppedHeaders[http.CanonicalHeaderKey(key)] {
+			w.Header()[key] = values
+		}
+	}
+	rewriteHeaders(w.Header(), gw.responseHeaders)
+	w.WriteHeader(resp.StatusCode)
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		log.Debugln("Failed to forward the response of the HTTPS backend:", err)
+	}
+}
+
+// Launch the gateway adding the configured authentication and header rewrites to
+// the requests the origin makes to its HTTPS backend.  Must be called before the
+// XRootD configuration is generated, which points XRootD at the gateway.
+func LaunchHttpsGateway(ctx context.Context, egrp *errgroup.Group) error {
+	gw, err := newHttpsGateway()
+	if err != nil {
+		return err
+	}
+
+	gatewayUrl, err := launchLoopbackGateway(ctx, egrp, "HTTPS backend gateway", gw, func() {
+		httpsGatewayLock.Lock()
+		httpsGatewayUrl = ""
+		httpsGatewayLock.Unlock()
+		setActiveTape(nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	httpsGatewayLock.Lock()
+	httpsGatewayUrl = gatewayUrl
+	httpsGatewayLock.Unlock()
+	setActiveTape(gw.tape)
+	return nil
+}
@@ -0,0 +1,198 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/config"
+)
+
+// Start a fake HTTPS backend echoing the request it received in its response headers,
+// and a gateway in front of it configured by `setup`
+func setupHttpsGateway(t *testing.T, setup func()) *httptest.Server {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Path", r.URL.RequestURI())
+		w.Header().Set("X-Request-Authorization", r.Header.Get("Authorization"))
+		w.Header().Set("X-Request-Service", r.Header.Get("X-Forwarded-Service"))
+		w.Header().Set("X-Request-Agent", r.Header.Get("User-Agent"))
+		w.Header().Set("Cache-Control", "no-store")
+		_, _ = io.WriteString(w, "object contents")
+	}))
+	t.Cleanup(backend.Close)
+
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("Origin.HttpServiceUrl", backend.URL+"/testfiles/")
+	setup()
+	gw, err := newHttpsGateway()
+	require.NoError(t, err)
+	server := httptest.NewServer(gw)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func writeSecret(t *testing.T, path, contents string) {
+	require.NoError(t, os.WriteFile(path, []byte(contents+"\n"), 0600))
+}
+
+func TestHttpsGateway(t *testing.T) {
+	get := func(t *testing.T, server *httptest.Server, path string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer not-for-the-backend")
+		req.Header.Set("User-Agent", "xrootd")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	t.Run("no-auth", func(t *testing.T) {
+		server := setupHttpsGateway(t, func() {})
+		resp := get(t, server, "/foo/bar.txt?x=1")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "/testfiles/foo/bar.txt?x=1", resp.Header.Get("X-Request-Path"))
+		// The credentials of the client are never forwarded to the backend
+		assert.Empty(t, resp.Header.Get("X-Request-Authorization"))
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "object contents", string(body))
+	})
+
+	t.Run("bearer-token-is-reloaded", func(t *testing.T) {
+		tokenFile := filepath.Join(t.TempDir(), "token")
+		writeSecret(t, tokenFile, "first-token")
+		server := setupHttpsGateway(t, func() {
+			viper.Set("Origin.HttpAuthMethod", "bearer")
+			viper.Set("Origin.HttpBearerTokenFile", tokenFile)
+		})
+		assert.Equal(t, "Bearer first-token", get(t, server, "/foo").Header.Get("X-Request-Authorization"))
+
+		writeSecret(t, tokenFile, "second-token")
+		require.NoError(t, os.Chtimes(tokenFile, time.Now(), time.Now().Add(time.Minute)))
+		assert.Equal(t, "Bearer second-token", get(t, server, "/foo").Header.Get("X-Request-Authorization"))
+	})
+
+	t.Run("basic-auth", func(t *testing.T) {
+		passwordFile := filepath.Join(t.TempDir(), "password")
+		writeSecret(t, passwordFile, "hunter2")
+		server := setupHttpsGateway(t, func() {
+			viper.Set("Origin.HttpAuthMethod", "basic")
+			viper.Set("Origin.HttpBasicAuthUsername", "pelican")
+			viper.Set("Origin.HttpBasicAuthPasswordFile", passwordFile)
+		})
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.SetBasicAuth("pelican", "hunter2")
+		assert.Equal(t, req.Header.Get("Authorization"), get(t, server, "/foo").Header.Get("X-Request-Authorization"))
+	})
+
+	t.Run("header-rewrites", func(t *testing.T) {
+		server := setupHttpsGateway(t, func() {
+			viper.Set("Origin.HttpRequestHeaders", map[string]string{"x-forwarded-service": "pelican", "User-Agent": ""})
+			viper.Set("Origin.HttpResponseHeaders", map[string]string{"Cache-Control": "", "X-Served-By": "pelican"})
+		})
+		resp := get(t, server, "/foo")
+		assert.Equal(t, "pelican", resp.Header.Get("X-Request-Service"))
+		// Go's client adds its own user agent when the header is removed
+		assert.NotEqual(t, "xrootd", resp.Header.Get("X-Request-Agent"))
+		assert.Empty(t, resp.Header.Get("Cache-Control"))
+		assert.Equal(t, "pelican", resp.Header.Get("X-Served-By"))
+	})
+
+	t.Run("token-exchange", func(t *testing.T) {
+		keyFile := filepath.Join(t.TempDir(), "issuer.jwk")
+		viper.Reset()
+		viper.Set("IssuerKey", keyFile)
+		viper.Set("Server.ExternalWebUrl", "https://origin.example.com:8443")
+		keys, err := config.GetIssuerPublicJWKS()
+		require.NoError(t, err)
+
+		var exchanges atomic.Int32
+		authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			exchanges.Add(1)
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, "urn:ietf:params:oauth:grant-type:token-exchange", r.Form.Get("grant_type"))
+			assert.Equal(t, "https://backend.example.com", r.Form.Get("audience"))
+			user, password, ok := r.BasicAuth()
+			assert.True(t, ok)
+			assert.Equal(t, "origin-client", user)
+			assert.Equal(t, "client-secret", password)
+
+			subject, err := jwt.Parse([]byte(r.Form.Get("subject_token")), jwt.WithKeySet(keys), jwt.WithValidate(true))
+			if !assert.NoError(t, err) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			assert.Equal(t, "https://origin.example.com:8443", subject.Issuer())
+			assert.Equal(t, []string{"https://backend.example.com"}, subject.Audience())
+			_ = json.NewEncoder(w).Encode(tokenExchangeRes{AccessToken: "backend-token", TokenType: "Bearer", ExpiresIn: 3600})
+		}))
+		t.Cleanup(authServer.Close)
+
+		secretFile := filepath.Join(t.TempDir(), "secret")
+		writeSecret(t, secretFile, "client-secret")
+		server := setupHttpsGateway(t, func() {
+			viper.Set("IssuerKey", keyFile)
+			viper.Set("Server.ExternalWebUrl", "https://origin.example.com:8443")
+			viper.Set("Origin.HttpAuthMethod", "tokenexchange")
+			viper.Set("Origin.HttpTokenExchangeUrl", authServer.URL)
+			viper.Set("Origin.HttpTokenExchangeClientID", "origin-client")
+			viper.Set("Origin.HttpTokenExchangeClientSecretFile", secretFile)
+			viper.Set("Origin.HttpTokenExchangeAudience", "https://backend.example.com")
+		})
+		assert.Equal(t, "Bearer backend-token", get(t, server, "/foo").Header.Get("X-Request-Authorization"))
+		assert.Equal(t, "Bearer backend-token", get(t, server, "/bar").Header.Get("X-Request-Authorization"))
+		// The backend token is reused until it's about to expire
+		assert.Equal(t, int32(1), exchanges.Load())
+	})
+
+	t.Run("invalid-configuration", func(t *testing.T) {
+		viper.Reset()
+		t.Cleanup(viper.Reset)
+		viper.Set("Origin.HttpServiceUrl", "https://backend.example.com")
+		for method, settings := range map[string]map[string]string{
+			"kerberos":      {},
+			"bearer":        {},
+			"basic":         {"Origin.HttpBasicAuthPasswordFile": "/dev/null"},
+			"clientcert":    {"Origin.HttpClientCertFile": "/does/not/exist.pem"},
+			"tokenexchange": {},
+		} {
+			viper.Set("Origin.HttpAuthMethod", method)
+			for key, value := range settings {
+				viper.Set(key, value)
+			}
+			_, err := newHttpsGateway()
+			assert.Error(t, err, method)
+		}
+	})
+}
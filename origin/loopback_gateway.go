@@ -0,0 +1,98 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+// Only lets through the requests whose path starts with the gateway's secret, stripping it.
+//
+// The gateways between XRootD and the storage backends listen on the loopback interface and
+// add the origin's credentials to the requests they forward.  XRootD's S3 and HTTP plugins
+// can't add headers to their requests, so the secret, generated on each launch, is part of
+// the service URL in the XRootD configuration instead; other local processes don't know it
+// and can't use the gateway to borrow the origin's credentials.
+type loopbackAuthHandler struct {
+	secret string
+	next   http.Handler
+}
+
+func newLoopbackSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", errors.Wrap(err, "failed to generate the gateway secret")
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+func (h *loopbackAuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	secret, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(h.secret)) != 1 {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	r.URL.Path = "/" + rest
+	r.URL.RawPath = ""
+	h.next.ServeHTTP(w, r)
+}
+
+// Serve the handler of a gateway on the loopback interface until ctx is cancelled, then call
+// onShutdown.  Returns the URL, secret included, that XRootD should send its requests to.
+func launchLoopbackGateway(ctx context.Context, egrp *errgroup.Group, name string, handler http.Handler, onShutdown func()) (string, error) {
+	secret, err := newLoopbackSecret()
+	if err != nil {
+		return "", err
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to listen for the %s", name)
+	}
+	server := &http.Server{Handler: &loopbackAuthHandler{secret: secret, next: handler}}
+	log.Infof("The %s is listening at %s", name, listener.Addr().String())
+
+	egrp.Go(func() error {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return errors.Wrapf(err, "%s failed", name)
+		}
+		return nil
+	})
+	egrp.Go(func() error {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err := server.Shutdown(shutdownCtx)
+		if onShutdown != nil {
+			onShutdown()
+		}
+		return err
+	})
+	return "http://" + listener.Addr().String() + "/" + secret, nil
+}
@@ -0,0 +1,70 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestLoopbackGatewaySecret(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	egrp := &errgroup.Group{}
+	shutdown := false
+	gatewayUrl, err := launchLoopbackGateway(ctx, egrp, "test gateway", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.URL.Path)
+	}), func() { shutdown = true })
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, egrp.Wait())
+		assert.True(t, shutdown)
+	})
+
+	get := func(reqUrl string) (int, string) {
+		resp, err := http.Get(reqUrl)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	status, body := get(gatewayUrl + "/bucket/object.txt")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "/bucket/object.txt", body)
+
+	parsed, err := url.Parse(gatewayUrl)
+	require.NoError(t, err)
+	for _, reqUrl := range []string{
+		"http://" + parsed.Host + "/bucket/object.txt",
+		"http://" + parsed.Host + "/wrong-secret/bucket/object.txt",
+		gatewayUrl + "x/bucket/object.txt",
+	} {
+		status, _ := get(reqUrl)
+		assert.Equal(t, http.StatusForbidden, status, reqUrl)
+	}
+}
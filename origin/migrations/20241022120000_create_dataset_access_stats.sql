@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE dataset_access_stats (
+    federation_prefix TEXT NOT NULL,
+    dataset TEXT NOT NULL,
+    week TEXT NOT NULL,
+    reads INTEGER NOT NULL DEFAULT 0,
+    bytes_read INTEGER NOT NULL DEFAULT 0,
+    updated_at DATETIME NOT NULL,
+    PRIMARY KEY (federation_prefix, dataset, week)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS dataset_access_stats;
+-- +goose StatementEnd
@@ -134,6 +134,9 @@ func handleExports(ctx *gin.Context) {
 	}
 
 	for idx, export := range wrappedExports {
+		if auditStatus, ok := GetExportAuditStatus(export.FederationPrefix); ok {
+			wrappedExports[idx].Audit = &auditStatus
+		}
 		if export.EditUrl != "" {
 			parsed, err := url.Parse(export.EditUrl)
 			if err != nil {
@@ -165,6 +168,7 @@ func RegisterOriginWebAPI(engine *gin.Engine) error {
 	originWebAPI := engine.Group("/api/v1.0/origin_ui")
 	{
 		originWebAPI.GET("/exports", web_ui.AuthHandler, web_ui.AdminAuthHandler, handleExports)
+		originWebAPI.GET("/dataset_stats", web_ui.AuthHandler, web_ui.AdminAuthHandler, handleDatasetStats)
 	}
 
 	// Globus backend specific. Config other origin routes above this line
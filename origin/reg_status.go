@@ -52,6 +52,8 @@ type (
 		Status            regStatusEnum `json:"status"`
 		StatusDescription string        `json:"statusDescription"` // detailed description of the current status
 		EditUrl           string        `json:"editUrl"`
+		// Result of the most recent consistency audit between the export and the storage backend
+		Audit *ExportAuditStatus `json:"audit,omitempty"`
 		server_utils.OriginExport
 	}
 )
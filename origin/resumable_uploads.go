@@ -0,0 +1,492 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+	"github.com/pelicanplatform/pelican/token_scopes"
+)
+
+type (
+	// A resumable upload staged at the origin; its data is in <ID>.data next to the
+	// <ID>.json holding this struct, so uploads survive restarts of the origin
+	uploadSession struct {
+		ID      string    `json:"id"`
+		Path    string    `json:"path"`
+		Length  int64     `json:"length"`
+		Created time.Time `json:"created"`
+	}
+
+	// The origin's resumable upload API.  Chunks are appended to a staging file; once
+	// the last one arrives, the file is written to the storage through XRootD with the
+	// client's token, so XRootD remains the authority on who may write where.
+	resumableUploads struct {
+		dir        string
+		exports    []server_utils.OriginExport
+		parseToken func(string) (jwt.Token, error)
+		// Held while a chunk is written or the upload finalized
+		locks sync.Map
+		// Where the completed objects are written
+		client    *http.Client
+		objectUrl func(objectPath string) string
+	}
+)
+
+const uploadStagingCleanupInterval = 10 * time.Minute
+
+var uploadIdRegex = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+func newResumableUploads(dir string, exports []server_utils.OriginExport, parseToken func(string) (jwt.Token, error)) (*resumableUploads, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "failed to create the upload staging directory")
+	}
+	return &resumableUploads{
+		dir:        dir,
+		exports:    exports,
+		parseToken: parseToken,
+		client:     &http.Client{Transport: newXrootdTransport()},
+		objectUrl: func(objectPath string) string {
+			objectUrl := url.URL{
+				Scheme: "https",
+				Host:   param.Server_Hostname.GetString() + ":" + strconv.Itoa(GetXrootdPort()),
+				Path:   objectPath,
+			}
+			return objectUrl.String()
+		},
+	}, nil
+}
+
+func (ru *resumableUploads) sessionFile(id string) string {
+	return filepath.Join(ru.dir, id+".json")
+}
+
+func (ru *resumableUploads) dataFile(id string) string {
+	return filepath.Join(ru.dir, id+".data")
+}
+
+func (ru *resumableUploads) lock(id string) *sync.Mutex {
+	mutex, _ := ru.locks.LoadOrStore(id, &sync.Mutex{})
+	return mutex.(*sync.Mutex)
+}
+
+// Load an upload and the number of bytes received so far; a nil session means the
+// upload doesn't exist
+func (ru *resumableUploads) load(id string) (session *uploadSession, offset int64, err error) {
+	if !uploadIdRegex.MatchString(id) {
+		return nil, 0, nil
+	}
+	contents, err := os.ReadFile(ru.sessionFile(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, nil
+	} else if err != nil {
+		return nil, 0, err
+	}
+	session = &uploadSession{}
+	if err = json.Unmarshal(contents, session); err != nil {
+		return nil, 0, errors.Wrapf(err, "failed to parse upload %s", id)
+	}
+	info, err := os.Stat(ru.dataFile(id))
+	if err != nil {
+		return nil, 0, err
+	}
+	return session, info.Size(), nil
+}
+
+func (ru *resumableUploads) remove(id string) {
+	if err := os.Remove(ru.dataFile(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warningf("Failed to remove the staged data of upload %s: %v", id, err)
+	}
+	if err := os.Remove(ru.sessionFile(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warningf("Failed to remove upload %s: %v", id, err)
+	}
+	ru.locks.Delete(id)
+}
+
+// Find the export holding the object, preferring the longest matching prefix
+func (ru *resumableUploads) findExport(objectPath string) *server_utils.OriginExport {
+	return server_utils.FindOriginExport(ru.exports, objectPath)
+}
+
+// Check the request's token allows writing the object.  Token scopes are relative
+// to the issuer's base path, which for Pelican issuers is the export's prefix; as
+// the origin may trust issuers with other base paths, scopes for the full object
+// path are accepted too.  XRootD checks the token again when the upload completes.
+func (ru *resumableUploads) authorize(req *http.Request, objectPath string) (int, error) {
+	export := ru.findExport(objectPath)
+	if export == nil {
+		return http.StatusNotFound, errors.Errorf("no export of the origin holds %s", objectPath)
+	}
+	if !export.Capabilities.Writes {
+		return http.StatusForbidden, errors.Errorf("the export %s does not allow writes", export.FederationPrefix)
+	}
+	tokStr := getRequestToken(req)
+	if tokStr == "" {
+		return http.StatusUnauthorized, errors.New("uploads require a token")
+	}
+	tok, err := ru.parseToken(tokStr)
+	if err != nil {
+		return http.StatusForbidden, errors.Wrap(err, "the token is not valid")
+	}
+	relPath := strings.TrimPrefix(objectPath, strings.TrimSuffix(export.FederationPrefix, "/"))
+	for _, scope := range token_scopes.ParseResourceScopeString(tok) {
+		for _, authz := range []token_scopes.TokenScope{token_scopes.Storage_Create, token_scopes.Storage_Modify} {
+			if scope.Contains(token_scopes.NewResourceScope(authz, relPath)) || scope.Contains(token_scopes.NewResourceScope(authz, objectPath)) {
+				return http.StatusOK, nil
+			}
+		}
+	}
+	return http.StatusForbidden, errors.Errorf("the token does not allow writing %s", objectPath)
+}
+
+// Parse the object path from the Upload-Metadata header, a comma-separated list of
+// keys and base64-encoded values
+func parseUploadPath(metadata string) (string, error) {
+	for _, pair := range strings.Split(metadata, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key != "path" {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return "", errors.Wrap(err, "the path in the upload metadata is not base64-encoded")
+		}
+		objectPath := string(decoded)
+		if !strings.HasPrefix(objectPath, "/") {
+			return "", errors.Errorf("the object path %q is not absolute", objectPath)
+		}
+		return path.Clean(objectPath), nil
+	}
+	return "", errors.New("the upload metadata has no object path")
+}
+
+func uploadError(ctx *gin.Context, status int, msg string) {
+	if ctx.Request.Method == http.MethodHead {
+		ctx.Status(status)
+		return
+	}
+	ctx.JSON(status, server_structs.SimpleApiResp{Status: server_structs.RespFailed, Msg: msg})
+}
+
+// Every request other than OPTIONS must speak the same protocol version
+func (ru *resumableUploads) checkVersion(ctx *gin.Context) {
+	ctx.Header(server_structs.TusResumableHeader, server_structs.TusVersion)
+	if ctx.Request.Method == http.MethodOptions {
+		return
+	}
+	if version := ctx.GetHeader(server_structs.TusResumableHeader); version != server_structs.TusVersion {
+		ctx.Header(server_structs.TusVersionHeader, server_structs.TusVersion)
+		uploadError(ctx, http.StatusPreconditionFailed, "Unsupported resumable upload protocol version "+version)
+		ctx.Abort()
+	}
+}
+
+func (ru *resumableUploads) options(ctx *gin.Context) {
+	ctx.Header(server_structs.TusVersionHeader, server_structs.TusVersion)
+	ctx.Header(server_structs.TusExtensionHeader, server_structs.TusExtensions)
+	ctx.Status(http.StatusNoContent)
+}
+
+// Start an upload, given its length and the object path in its metadata
+func (ru *resumableUploads) create(ctx *gin.Context) {
+	length, err := strconv.ParseInt(ctx.GetHeader(server_structs.TusUploadLength), 10, 64)
+	if err != nil || length <= 0 {
+		uploadError(ctx, http.StatusBadRequest, "Upload-Length must be a positive number of bytes")
+		return
+	}
+	objectPath, err := parseUploadPath(ctx.GetHeader(server_structs.TusUploadMetadata))
+	if err != nil {
+		uploadError(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+	if status, err := ru.authorize(ctx.Request, objectPath); err != nil {
+		uploadError(ctx, status, err.Error())
+		return
+	}
+
+	idBytes := make([]byte, 16)
+	if _, err = rand.Read(idBytes); err != nil {
+		log.Errorln("Failed to generate an upload ID:", err)
+		uploadError(ctx, http.StatusInternalServerError, "Failed to create the upload")
+		return
+	}
+	session := uploadSession{ID: hex.EncodeToString(idBytes), Path: objectPath, Length: length, Created: time.Now()}
+	contents, err := json.Marshal(session)
+	if err == nil {
+		err = os.WriteFile(ru.dataFile(session.ID), nil, 0600)
+	}
+	if err == nil {
+		err = os.WriteFile(ru.sessionFile(session.ID), contents, 0600)
+	}
+	if err != nil {
+		log.Errorln("Failed to stage a new upload:", err)
+		ru.remove(session.ID)
+		uploadError(ctx, http.StatusInternalServerError, "Failed to create the upload")
+		return
+	}
+	log.Debugf("Started upload %s of %d bytes to %s", session.ID, length, objectPath)
+
+	location := param.Server_ExternalWebUrl.GetString() + server_structs.ResumableUploadPath + "/" + session.ID
+	ctx.Header("Location", location)
+	ctx.Header(server_structs.TusUploadOffset, "0")
+	ctx.Status(http.StatusCreated)
+}
+
+// Report how many bytes of the upload the origin holds
+func (ru *resumableUploads) head(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "no-store")
+	session, offset, err := ru.load(ctx.Param("id"))
+	if err != nil {
+		log.Errorln("Failed to load upload:", err)
+		ctx.Status(http.StatusInternalServerError)
+		return
+	} else if session == nil {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+	if status, err := ru.authorize(ctx.Request, session.Path); err != nil {
+		ctx.Status(status)
+		return
+	}
+	ctx.Header(server_structs.TusUploadOffset, strconv.FormatInt(offset, 10))
+	ctx.Header(server_structs.TusUploadLength, strconv.FormatInt(session.Length, 10))
+	ctx.Status(http.StatusOK)
+}
+
+// Append a chunk at the given offset.  When the upload is complete, the object is
+// written to the storage; if that fails, the client may retry it with an empty chunk
+// at the final offset.
+func (ru *resumableUploads) patch(ctx *gin.Context) {
+	if ctx.ContentType() != server_structs.TusPatchContentType {
+		uploadError(ctx, http.StatusUnsupportedMediaType, "Chunks must have the content type "+server_structs.TusPatchContentType)
+		return
+	}
+	offset, err := strconv.ParseInt(ctx.GetHeader(server_structs.TusUploadOffset), 10, 64)
+	if err != nil || offset < 0 {
+		uploadError(ctx, http.StatusBadRequest, "Upload-Offset must be a non-negative number of bytes")
+		return
+	}
+	id := ctx.Param("id")
+	mutex := ru.lock(id)
+	if !mutex.TryLock() {
+		uploadError(ctx, http.StatusLocked, "Another chunk of the upload is being written")
+		return
+	}
+	defer mutex.Unlock()
+
+	session, current, err := ru.load(id)
+	if err != nil {
+		log.Errorln("Failed to load upload:", err)
+		uploadError(ctx, http.StatusInternalServerError, "Failed to load the upload")
+		return
+	} else if session == nil {
+		uploadError(ctx, http.StatusNotFound, "No such upload")
+		return
+	}
+	if status, err := ru.authorize(ctx.Request, session.Path); err != nil {
+		uploadError(ctx, status, err.Error())
+		return
+	}
+	if offset != current {
+		ctx.Header(server_structs.TusUploadOffset, strconv.FormatInt(current, 10))
+		uploadError(ctx, http.StatusConflict, "The chunk's offset does not match the upload's; the origin holds "+strconv.FormatInt(current, 10)+" bytes")
+		return
+	}
+	remaining := session.Length - current
+	if ctx.Request.ContentLength > remaining {
+		uploadError(ctx, http.StatusRequestEntityTooLarge, "The chunk extends past the upload's length")
+		return
+	}
+
+	if remaining > 0 {
+		file, err := os.OpenFile(ru.dataFile(id), os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			log.Errorln("Failed to open the staged upload:", err)
+			uploadError(ctx, http.StatusInternalServerError, "Failed to write the chunk")
+			return
+		}
+		// Whatever arrived before an interruption is kept; the client resumes after it
+		written, copyErr := io.Copy(file, io.LimitReader(ctx.Request.Body, remaining))
+		if err = file.Close(); copyErr == nil {
+			copyErr = err
+		}
+		current += written
+		if copyErr != nil {
+			log.Debugf("Upload %s was interrupted after %d bytes: %v", id, current, copyErr)
+			ctx.Header(server_structs.TusUploadOffset, strconv.FormatInt(current, 10))
+			uploadError(ctx, http.StatusInternalServerError, "Failed to receive the chunk")
+			return
+		}
+	}
+
+	ctx.Header(server_structs.TusUploadOffset, strconv.FormatInt(current, 10))
+	if current < session.Length {
+		ctx.Status(http.StatusNoContent)
+		return
+	}
+	if status, err := ru.finalize(ctx, session, getRequestToken(ctx.Request)); err != nil {
+		log.Warningf("Failed to write the completed upload %s to %s: %v", id, session.Path, err)
+		uploadError(ctx, status, err.Error())
+		return
+	}
+	log.Debugf("Completed upload %s of %d bytes to %s", id, session.Length, session.Path)
+	ru.remove(id)
+	ctx.Status(http.StatusNoContent)
+}
+
+// Write the completed upload to the storage through XRootD with the client's token
+func (ru *resumableUploads) finalize(ctx context.Context, session *uploadSession, token string) (int, error) {
+	file, err := os.Open(ru.dataFile(session.ID))
+	if err != nil {
+		return http.StatusInternalServerError, errors.Wrap(err, "failed to open the staged upload")
+	}
+	defer file.Close()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, ru.objectUrl(session.Path), file)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	req.ContentLength = session.Length
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := ru.client.Do(req)
+	if err != nil {
+		return http.StatusBadGateway, errors.Wrap(err, "failed to write the object to the storage")
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return http.StatusOK, nil
+	}
+	status := http.StatusBadGateway
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+		status = resp.StatusCode
+	}
+	return status, errors.Errorf("the storage rejected the object with status code %d", resp.StatusCode)
+}
+
+// Abandon an upload, removing its staged data
+func (ru *resumableUploads) terminate(ctx *gin.Context) {
+	id := ctx.Param("id")
+	mutex := ru.lock(id)
+	if !mutex.TryLock() {
+		uploadError(ctx, http.StatusLocked, "A chunk of the upload is being written")
+		return
+	}
+	defer mutex.Unlock()
+	session, _, err := ru.load(id)
+	if err != nil {
+		log.Errorln("Failed to load upload:", err)
+		uploadError(ctx, http.StatusInternalServerError, "Failed to load the upload")
+		return
+	} else if session == nil {
+		uploadError(ctx, http.StatusNotFound, "No such upload")
+		return
+	}
+	if status, err := ru.authorize(ctx.Request, session.Path); err != nil {
+		uploadError(ctx, status, err.Error())
+		return
+	}
+	ru.remove(id)
+	ctx.Status(http.StatusNoContent)
+}
+
+// Remove the uploads that received no data for longer than ttl
+func (ru *resumableUploads) removeAbandoned(ttl time.Duration) {
+	entries, err := os.ReadDir(ru.dir)
+	if err != nil {
+		log.Warningln("Failed to list the upload staging directory:", err)
+		return
+	}
+	for _, entry := range entries {
+		id, isSession := strings.CutSuffix(entry.Name(), ".json")
+		if !isSession || !uploadIdRegex.MatchString(id) {
+			continue
+		}
+		info, err := os.Stat(ru.dataFile(id))
+		if err == nil && time.Since(info.ModTime()) < ttl {
+			continue
+		}
+		mutex := ru.lock(id)
+		if !mutex.TryLock() {
+			continue
+		}
+		log.Debugf("Removing upload %s, abandoned for over %s", id, ttl)
+		ru.remove(id)
+		mutex.Unlock()
+	}
+}
+
+func (ru *resumableUploads) register(engine *gin.Engine) {
+	group := engine.Group(server_structs.ResumableUploadPath, ru.checkVersion)
+	group.OPTIONS("", ru.options)
+	group.POST("", ru.create)
+	group.HEAD("/:id", ru.head)
+	group.PATCH("/:id", ru.patch)
+	group.DELETE("/:id", ru.terminate)
+}
+
+// Register the resumable upload API and launch the removal of abandoned uploads.
+// Tokens signed by one of the trustedIssuers may upload to the exports allowing writes.
+func RegisterResumableUploads(ctx context.Context, egrp *errgroup.Group, engine *gin.Engine, exports []server_utils.OriginExport, trustedIssuers []string) error {
+	verifier := newIssuerTokenVerifier(trustedIssuers)
+	ru, err := newResumableUploads(param.Origin_UploadStagingLocation.GetString(), exports, verifier.parse)
+	if err != nil {
+		return err
+	}
+	ru.register(engine)
+
+	ttl := param.Origin_UploadStagingTTL.GetDuration()
+	egrp.Go(func() error {
+		ticker := time.NewTicker(uploadStagingCleanupInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ru.removeAbandoned(ttl)
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	})
+	log.Infoln("Accepting resumable uploads staged in", param.Origin_UploadStagingLocation.GetString())
+	return nil
+}
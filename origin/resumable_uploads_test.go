@@ -0,0 +1,239 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+	"github.com/pelicanplatform/pelican/token"
+	"github.com/pelicanplatform/pelican/token_scopes"
+)
+
+func TestResumableUploads(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("IssuerKey", filepath.Join(t.TempDir(), "issuer.jwk"))
+	viper.Set("Server.ExternalWebUrl", "https://origin.example.com:8444")
+	keys, err := config.GetIssuerPublicJWKS()
+	require.NoError(t, err)
+
+	newToken := func(t *testing.T, scopes ...token_scopes.ResourceScope) string {
+		tokenCfg := token.NewWLCGToken()
+		tokenCfg.Lifetime = time.Minute
+		tokenCfg.Issuer = "https://origin.example.com:8444"
+		tokenCfg.Subject = "uploader"
+		tokenCfg.AddAudienceAny()
+		tokenCfg.AddResourceScopes(scopes...)
+		tok, err := tokenCfg.CreateToken()
+		require.NoError(t, err)
+		return tok
+	}
+	writeToken := newToken(t, token_scopes.NewResourceScope(token_scopes.Storage_Create, "/data"))
+	readToken := newToken(t, token_scopes.NewResourceScope(token_scopes.Storage_Read, "/"))
+
+	// The storage behind XRootD; it records the objects written to it
+	var storageLock sync.Mutex
+	stored := map[string][]byte{}
+	storageStatus := http.StatusCreated
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		storageLock.Lock()
+		defer storageLock.Unlock()
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "Bearer "+writeToken, r.Header.Get("Authorization"))
+		if storageStatus == http.StatusCreated {
+			stored[r.URL.Path], _ = io.ReadAll(r.Body)
+		}
+		w.WriteHeader(storageStatus)
+	}))
+	t.Cleanup(storage.Close)
+
+	stagingDir := t.TempDir()
+	exports := []server_utils.OriginExport{
+		{FederationPrefix: "/first", Capabilities: server_structs.Capabilities{Writes: true}},
+		{FederationPrefix: "/readonly", Capabilities: server_structs.Capabilities{Reads: true}},
+	}
+	ru, err := newResumableUploads(stagingDir, exports, func(tok string) (jwt.Token, error) {
+		return jwt.ParseString(tok, jwt.WithKeySet(keys), jwt.WithValidate(true))
+	})
+	require.NoError(t, err)
+	ru.client = http.DefaultClient
+	ru.objectUrl = func(objectPath string) string { return storage.URL + objectPath }
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	ru.register(engine)
+	server := httptest.NewServer(engine)
+	t.Cleanup(server.Close)
+
+	do := func(t *testing.T, method, url, tok string, headers map[string]string, body []byte) *http.Response {
+		req, err := http.NewRequest(method, url, bytes.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set(server_structs.TusResumableHeader, server_structs.TusVersion)
+		if tok != "" {
+			req.Header.Set("Authorization", "Bearer "+tok)
+		}
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+	create := func(t *testing.T, tok, objectPath string, length int) *http.Response {
+		return do(t, http.MethodPost, server.URL+server_structs.ResumableUploadPath, tok, map[string]string{
+			server_structs.TusUploadLength:   strconv.Itoa(length),
+			server_structs.TusUploadMetadata: "filename Zm9v,path " + base64.StdEncoding.EncodeToString([]byte(objectPath)),
+		}, nil)
+	}
+	// The upload's URL at the test server
+	uploadUrl := func(resp *http.Response) string {
+		location := resp.Header.Get("Location")
+		require.True(t, strings.HasPrefix(location, "https://origin.example.com:8444"+server_structs.ResumableUploadPath+"/"))
+		return server.URL + strings.TrimPrefix(location, "https://origin.example.com:8444")
+	}
+	patch := func(t *testing.T, url string, offset int, chunk []byte) *http.Response {
+		return do(t, http.MethodPatch, url, writeToken, map[string]string{
+			"Content-Type":                 server_structs.TusPatchContentType,
+			server_structs.TusUploadOffset: strconv.Itoa(offset),
+		}, chunk)
+	}
+	contents := []byte("0123456789abcdefghij")
+
+	t.Run("chunked-upload", func(t *testing.T) {
+		resp := create(t, writeToken, "/first/data/object.txt", len(contents))
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		url := uploadUrl(resp)
+
+		resp = patch(t, url, 0, contents[:8])
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, "8", resp.Header.Get(server_structs.TusUploadOffset))
+
+		// A chunk at the wrong offset is rejected with the offset the origin holds
+		resp = patch(t, url, 4, contents[4:12])
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+		assert.Equal(t, "8", resp.Header.Get(server_structs.TusUploadOffset))
+
+		resp = do(t, http.MethodHead, url, writeToken, nil, nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "8", resp.Header.Get(server_structs.TusUploadOffset))
+		assert.Equal(t, strconv.Itoa(len(contents)), resp.Header.Get(server_structs.TusUploadLength))
+
+		resp = patch(t, url, 8, contents[8:])
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		storageLock.Lock()
+		assert.Equal(t, contents, stored["/first/data/object.txt"])
+		storageLock.Unlock()
+
+		// The staged upload is removed once it's written
+		resp = do(t, http.MethodHead, url, writeToken, nil, nil)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("retry-failed-completion", func(t *testing.T) {
+		storageLock.Lock()
+		storageStatus = http.StatusServiceUnavailable
+		storageLock.Unlock()
+		resp := create(t, writeToken, "/first/data/retried.txt", len(contents))
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		url := uploadUrl(resp)
+		resp = patch(t, url, 0, contents)
+		assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+
+		storageLock.Lock()
+		storageStatus = http.StatusCreated
+		storageLock.Unlock()
+		resp = patch(t, url, len(contents), nil)
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		storageLock.Lock()
+		assert.Equal(t, contents, stored["/first/data/retried.txt"])
+		storageLock.Unlock()
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, create(t, "", "/first/data/object.txt", 10).StatusCode)
+		assert.Equal(t, http.StatusForbidden, create(t, readToken, "/first/data/object.txt", 10).StatusCode)
+		assert.Equal(t, http.StatusForbidden, create(t, writeToken, "/first/other/object.txt", 10).StatusCode)
+		assert.Equal(t, http.StatusForbidden, create(t, writeToken, "/readonly/data/object.txt", 10).StatusCode)
+		assert.Equal(t, http.StatusNotFound, create(t, writeToken, "/unknown/data/object.txt", 10).StatusCode)
+
+		resp := create(t, writeToken, "/first/data/object.txt", 10)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		resp = do(t, http.MethodPatch, uploadUrl(resp), readToken, map[string]string{
+			"Content-Type":                 server_structs.TusPatchContentType,
+			server_structs.TusUploadOffset: "0",
+		}, contents[:10])
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("protocol-errors", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, server.URL+server_structs.ResumableUploadPath, nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+		assert.Equal(t, http.StatusBadRequest, create(t, writeToken, "relative/path", 10).StatusCode)
+		assert.Equal(t, http.StatusBadRequest, create(t, writeToken, "/first/data/object.txt", 0).StatusCode)
+
+		resp = create(t, writeToken, "/first/data/object.txt", 4)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, patch(t, uploadUrl(resp), 0, contents).StatusCode)
+	})
+
+	t.Run("terminate-and-expire", func(t *testing.T) {
+		resp := create(t, writeToken, "/first/data/object.txt", 10)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		url := uploadUrl(resp)
+		assert.Equal(t, http.StatusNoContent, do(t, http.MethodDelete, url, writeToken, nil, nil).StatusCode)
+		assert.Equal(t, http.StatusNotFound, do(t, http.MethodHead, url, writeToken, nil, nil).StatusCode)
+
+		resp = create(t, writeToken, "/first/data/object.txt", 10)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		ru.removeAbandoned(time.Hour)
+		entries, err := os.ReadDir(stagingDir)
+		require.NoError(t, err)
+		assert.NotEmpty(t, entries)
+		ru.removeAbandoned(0)
+		entries, err = os.ReadDir(stagingDir)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
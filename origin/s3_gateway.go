@@ -0,0 +1,597 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+	"gorm.io/gorm/clause"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+type (
+	// The number of S3 API calls an export made in a calendar month, persisted
+	// so the monthly request budget survives restarts
+	S3RequestCount struct {
+		FederationPrefix string `gorm:"primaryKey"`
+		Month            string `gorm:"primaryKey"` // "2006-01" in UTC
+		Requests         int64  `gorm:"not null;default:0"`
+		Throttled        int64  `gorm:"not null;default:0"`
+		UpdatedAt        time.Time
+	}
+
+	// Request accounting and throttling state of a single S3 export
+	s3ExportTracker struct {
+		federationPrefix string
+		bucket           string
+		signer           *v4.Signer // nil if the export has no credentials
+		writable         bool       // Whether the export has the Writes capability
+
+		lock      sync.Mutex
+		month     string
+		requests  int64
+		throttled int64
+		backoff   time.Duration
+		degraded  bool
+		dirty     bool
+	}
+
+	// A gateway between XRootD's S3 plugin and the S3 service.  XRootD is configured
+	// to send path-style, unsigned requests to the gateway, which forwards them to
+	// the S3 service (re-signing them with the export's credentials) while tracking
+	// the requests made by each export.
+	s3Gateway struct {
+		upstream *url.URL
+		urlStyle string
+		region   string
+		client   *http.Client
+
+		buckets  map[string]*s3ExportTracker // Exports keyed by bucket name
+		noBucket *s3ExportTracker            // The export whose bucket is part of the object path, if any
+		exports  []*s3ExportTracker
+	}
+)
+
+const (
+	s3MinThrottleBackoff = 100 * time.Millisecond
+)
+
+var (
+	s3GatewayLock sync.RWMutex
+	s3GatewayUrl  string
+	activeGateway *s3Gateway
+
+	// Federation prefixes of the exports that exceeded their monthly request budget
+	s3DegradedExports = make(map[string]bool)
+	s3DegradedLock    sync.Mutex
+
+	// Headers that are recomputed when the gateway signs the request, or are hop-by-hop
+	s3DroppedRequestHeaders = map[string]bool{
+		"Authorization":        true,
+		"X-Amz-Date":           true,
+		"X-Amz-Content-Sha256": true,
+		"X-Amz-Security-Token": true,
+		"Connection":           true,
+		"Keep-Alive":           true,
+		"Proxy-Connection":     true,
+		"Te":                   true,
+		"Trailer":              true,
+		"Transfer-Encoding":    true,
+		"Upgrade":              true,
+	}
+)
+
+func s3Month(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// Get the URL of the S3 request-tracking gateway that XRootD should use as the S3
+// service URL, or an empty string if the gateway isn't running
+func GetS3GatewayUrl() string {
+	s3GatewayLock.RLock()
+	defer s3GatewayLock.RUnlock()
+	return s3GatewayUrl
+}
+
+// Returns true if the export, keyed by its federation prefix, has exceeded its
+// monthly S3 request budget and is only served from caches
+func IsS3ExportDegraded(federationPrefix string) bool {
+	s3GatewayLock.RLock()
+	gw := activeGateway
+	s3GatewayLock.RUnlock()
+	if gw == nil {
+		return false
+	}
+	for _, exp := range gw.exports {
+		if exp.federationPrefix == federationPrefix {
+			exp.lock.Lock()
+			defer exp.lock.Unlock()
+			exp.rollover(time.Now())
+			return exp.degraded
+		}
+	}
+	return false
+}
+
+func readS3Keyfile(filename string) (string, error) {
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(contents)), nil
+}
+
+func newS3ExportTracker(export server_utils.OriginExport) (*s3ExportTracker, error) {
+	exp := &s3ExportTracker{
+		federationPrefix: export.FederationPrefix,
+		bucket:           export.S3Bucket,
+		writable:         export.Capabilities.Writes,
+		month:            s3Month(time.Now()),
+	}
+	if export.S3AccessKeyfile != "" && export.S3SecretKeyfile != "" {
+		accessKey, err := readS3Keyfile(export.S3AccessKeyfile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read the S3 access key file for export %s", export.FederationPrefix)
+		}
+		secretKey, err := readS3Keyfile(export.S3SecretKeyfile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read the S3 secret key file for export %s", export.FederationPrefix)
+		}
+		exp.signer = v4.NewSigner(credentials.NewStaticCredentials(accessKey, secretKey, ""), func(s *v4.Signer) {
+			// The body is streamed to S3 without being hashed, and S3 object keys
+			// must not be escaped a second time
+			s.UnsignedPayload = true
+			s.DisableURIPathEscaping = true
+			s.DisableRequestBodyOverwrite = true
+		})
+	}
+	return exp, nil
+}
+
+// Reset the counters at the start of a new month.  Must be called with the lock held.
+func (exp *s3ExportTracker) rollover(now time.Time) {
+	if month := s3Month(now); month != exp.month {
+		exp.month = month
+		exp.requests = 0
+		exp.throttled = 0
+		exp.dirty = true
+		if exp.degraded {
+			exp.degraded = false
+			log.Infof("S3 export %s is no longer degraded: its monthly request budget was reset", exp.federationPrefix)
+			setS3ExportDegraded(exp.federationPrefix, false)
+		}
+		metrics.PelicanOriginS3MonthlyRequests.WithLabelValues(exp.federationPrefix).Set(0)
+	}
+}
+
+// Count a request against the export's monthly budget.  Returns false, without
+// counting the request, if the export is over its budget.
+func (exp *s3ExportTracker) reserve(now time.Time, method string) bool {
+	exp.lock.Lock()
+	defer exp.lock.Unlock()
+	exp.rollover(now)
+	if exp.degraded {
+		return false
+	}
+	exp.requests++
+	exp.dirty = true
+	metrics.PelicanOriginS3Requests.WithLabelValues(exp.federationPrefix, method).Inc()
+	metrics.PelicanOriginS3MonthlyRequests.WithLabelValues(exp.federationPrefix).Set(float64(exp.requests))
+
+	if budget := int64(param.Origin_S3MonthlyRequestBudget.GetInt()); budget > 0 && exp.requests >= budget {
+		exp.degraded = true
+		log.Warningf("S3 export %s reached its monthly budget of %d requests; it is degraded until the start of next month", exp.federationPrefix, budget)
+		setS3ExportDegraded(exp.federationPrefix, true)
+	}
+	return true
+}
+
+// Get the delay to wait before sending the next request to the S3 service
+func (exp *s3ExportTracker) getBackoff() time.Duration {
+	exp.lock.Lock()
+	defer exp.lock.Unlock()
+	return exp.backoff
+}
+
+// Adapt the backoff to the status of a response from the S3 service: throttling
+// responses double the backoff and successful responses halve it.
+func (exp *s3ExportTracker) recordResponse(statusCode int) {
+	exp.lock.Lock()
+	defer exp.lock.Unlock()
+	if statusCode == http.StatusServiceUnavailable || statusCode == http.StatusTooManyRequests {
+		exp.throttled++
+		exp.dirty = true
+		metrics.PelicanOriginS3Throttled.WithLabelValues(exp.federationPrefix).Inc()
+		maxBackoff := param.Origin_S3MaxThrottleBackoff.GetDuration()
+		if exp.backoff < s3MinThrottleBackoff {
+			exp.backoff = s3MinThrottleBackoff
+		} else {
+			exp.backoff *= 2
+		}
+		if maxBackoff > 0 && exp.backoff > maxBackoff {
+			exp.backoff = maxBackoff
+		}
+		log.Debugf("S3 service throttled export %s; delaying its requests by %s", exp.federationPrefix, exp.backoff.String())
+	} else if statusCode < 500 && exp.backoff > 0 {
+		exp.backoff /= 2
+		if exp.backoff < s3MinThrottleBackoff {
+			exp.backoff = 0
+		}
+	}
+}
+
+// Flip a writable export read-only when the S3 service denies a write forwarded to it,
+// and back once a write succeeds
+func (exp *s3ExportTracker) recordWriteResponse(statusCode int) {
+	if !exp.writable || !param.Origin_StorageWriteCheck.GetBool() {
+		return
+	}
+	switch {
+	case statusCode >= 200 && statusCode < 300:
+		if IsExportReadOnly(exp.federationPrefix) {
+			setExportReadOnly(exp.federationPrefix, "")
+		}
+	case statusCode == http.StatusForbidden:
+		setExportReadOnly(exp.federationPrefix, "the S3 service denied a write (HTTP 403); check the bucket policy and the export's credentials")
+	case statusCode == http.StatusInsufficientStorage:
+		setExportReadOnly(exp.federationPrefix, "the S3 service has no storage left for writes (HTTP 507)")
+	}
+}
+
+// Check that the S3 service accepts writes of the export by creating and removing a
+// small object in its bucket.  The requests count against the export's request budget.
+func (gw *s3Gateway) checkExportWritable(ctx context.Context, exp *s3ExportTracker) error {
+	if exp.bucket == "" {
+		return errors.New("the export has no configured bucket to write to")
+	}
+	for _, method := range []string{http.MethodPut, http.MethodDelete} {
+		if !exp.reserve(time.Now(), method) {
+			return errors.New("the export exceeded its monthly S3 request budget")
+		}
+		req, err := http.NewRequestWithContext(ctx, method, gw.getUpstreamUrl(exp.bucket, storageWriteCheckName, "").String(), nil)
+		if err != nil {
+			return err
+		}
+		if exp.signer != nil {
+			if _, err := exp.signer.Sign(req, nil, "s3", gw.region, time.Now()); err != nil {
+				return errors.Wrap(err, "failed to sign the request to the S3 service")
+			}
+		}
+		resp, err := gw.client.Do(req)
+		if err != nil {
+			return errors.Wrap(err, "failed to contact the S3 service")
+		}
+		resp.Body.Close()
+		exp.recordResponse(resp.StatusCode)
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return errors.Errorf("the S3 service responded to the %s of %s with HTTP %d", method, storageWriteCheckName, resp.StatusCode)
+		}
+	}
+	return nil
+}
+
+// Flip the degraded state of an export and update the S3 backend health accordingly
+func setS3ExportDegraded(federationPrefix string, degraded bool) {
+	s3DegradedLock.Lock()
+	if degraded {
+		s3DegradedExports[federationPrefix] = true
+		metrics.PelicanOriginS3ExportDegraded.WithLabelValues(federationPrefix).Set(1)
+	} else {
+		delete(s3DegradedExports, federationPrefix)
+		metrics.PelicanOriginS3ExportDegraded.WithLabelValues(federationPrefix).Set(0)
+	}
+	s3DegradedLock.Unlock()
+	updateS3HealthStatus()
+}
+
+// Set the health of the S3 backend from the degraded exports
+func updateS3HealthStatus() {
+	s3DegradedLock.Lock()
+	prefixes := make([]string, 0, len(s3DegradedExports))
+	for prefix := range s3DegradedExports {
+		prefixes = append(prefixes, prefix)
+	}
+	s3DegradedLock.Unlock()
+
+	if len(prefixes) > 0 {
+		sort.Strings(prefixes)
+		metrics.SetComponentHealthStatus(metrics.Origin_S3Backend, metrics.StatusWarning,
+			"Exports exceeded their monthly S3 request budget and are only served from caches: "+strings.Join(prefixes, ", "))
+	} else {
+		metrics.SetComponentHealthStatus(metrics.Origin_S3Backend, metrics.StatusOK, "All S3 exports are within their request budget")
+	}
+}
+
+func newS3Gateway(exports []server_utils.OriginExport) (*s3Gateway, error) {
+	upstream, err := url.Parse(param.Origin_S3ServiceUrl.GetString())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse Origin.S3ServiceUrl")
+	}
+	if upstream.Scheme == "" || upstream.Host == "" {
+		return nil, errors.Errorf("Origin.S3ServiceUrl %q must be an absolute URL", param.Origin_S3ServiceUrl.GetString())
+	}
+	gw := &s3Gateway{
+		upstream: upstream,
+		urlStyle: param.Origin_S3UrlStyle.GetString(),
+		region:   param.Origin_S3Region.GetString(),
+		client:   &http.Client{Transport: config.GetTransport()},
+		buckets:  make(map[string]*s3ExportTracker),
+	}
+	for _, export := range exports {
+		exp, err := newS3ExportTracker(export)
+		if err != nil {
+			return nil, err
+		}
+		if exp.bucket != "" {
+			gw.buckets[exp.bucket] = exp
+		} else if gw.noBucket != nil {
+			// XRootD's requests don't say which export they're for, only which bucket
+			return nil, errors.Errorf("the S3 exports %s and %s both have no bucket; the requests of their objects can't be told apart",
+				gw.noBucket.federationPrefix, exp.federationPrefix)
+		} else {
+			gw.noBucket = exp
+		}
+		gw.exports = append(gw.exports, exp)
+	}
+	return gw, nil
+}
+
+// Find the export responsible for a request on the bucket
+func (gw *s3Gateway) lookupExport(bucket string) *s3ExportTracker {
+	if exp, ok := gw.buckets[bucket]; ok {
+		return exp
+	}
+	// The bucket of an export without a configured bucket is part of the object path
+	return gw.noBucket
+}
+
+// Returns true if the export allows requests with the method: reads for all exports,
+// and writes, including multipart uploads and their cleanup, for writable exports
+func (exp *s3ExportTracker) allowsMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return true
+	case http.MethodPut, http.MethodPost, http.MethodDelete:
+		return exp.writable
+	}
+	return false
+}
+
+// Build the URL of the S3 service for an object of a bucket
+func (gw *s3Gateway) getUpstreamUrl(bucket, object, rawQuery string) *url.URL {
+	result := *gw.upstream
+	basePath := strings.TrimSuffix(result.Path, "/")
+	if gw.urlStyle == "virtual" && bucket != "" {
+		result.Host = bucket + "." + result.Host
+		result.Path = basePath + "/" + object
+	} else if bucket != "" {
+		result.Path = basePath + "/" + bucket + "/" + object
+	} else {
+		result.Path = basePath + "/" + object
+	}
+	result.RawPath = ""
+	result.RawQuery = rawQuery
+	return &result
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<Error><Code>%s</Code><Message>%s</Message></Error>", code, msg)
+}
+
+func (gw *s3Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// XRootD sends path-style requests: /<bucket>/<object>
+	bucket, object, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	exp := gw.lookupExport(bucket)
+	if exp == nil {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket", "The bucket is not exported by this origin")
+		return
+	}
+	if exp.bucket == "" {
+		// The "bucket" was the first component of the object path
+		bucket, object = "", strings.TrimPrefix(r.URL.Path, "/")
+	}
+	if !exp.allowsMethod(r.Method) {
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "The export does not allow this method")
+		return
+	}
+
+	if !exp.reserve(time.Now(), r.Method) {
+		writeS3Error(w, http.StatusServiceUnavailable, "SlowDown", "The export exceeded its monthly S3 request budget")
+		return
+	}
+	if backoff := exp.getBackoff(); backoff > 0 {
+		select {
+		case <-time.After(backoff):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	upstreamReq, err := http.NewRequestWithContext(r.Context(), r.Method, gw.getUpstreamUrl(bucket, object, r.URL.RawQuery).String(), r.Body)
+	if err != nil {
+		writeS3Error(w, http.StatusInternalServerError, "InternalError", "Failed to create the request to the S3 service")
+		return
+	}
+	upstreamReq.ContentLength = r.ContentLength
+	for key, values := range r.Header {
+		if !s3DroppedRequestHeaders[http.CanonicalHeaderKey(key)] {
+			upstreamReq.Header[key] = values
+		}
+	}
+	if exp.signer != nil {
+		if _, err := exp.signer.Sign(upstreamReq, nil, "s3", gw.region, time.Now()); err != nil {
+			log.Errorf("Failed to sign the S3 request for export %s: %v", exp.federationPrefix, err)
+			writeS3Error(w, http.StatusInternalServerError, "InternalError", "Failed to sign the request to the S3 service")
+			return
+		}
+	}
+
+	resp, err := gw.client.Do(upstreamReq)
+	if err != nil {
+		log.Warningf("Failed to contact the S3 service for export %s: %v", exp.federationPrefix, err)
+		writeS3Error(w, http.StatusBadGateway, "InternalError", "Failed to contact the S3 service")
+		return
+	}
+	defer resp.Body.Close()
+	exp.recordResponse(resp.StatusCode)
+	if r.Method == http.MethodPut || r.Method == http.MethodPost {
+		exp.recordWriteResponse(resp.StatusCode)
+	}
+
+	for key, values := range resp.Header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		log.Debugf("Failed to forward the S3 response for export %s: %v", exp.federationPrefix, err)
+	}
+}
+
+// Load the request counts of the current month from the origin database
+func (gw *s3Gateway) loadRequestCounts(now time.Time) error {
+	if db == nil {
+		return nil
+	}
+	month := s3Month(now)
+	for _, exp := range gw.exports {
+		count := S3RequestCount{}
+		result := db.Where("federation_prefix = ? AND month = ?", exp.federationPrefix, month).Limit(1).Find(&count)
+		if result.Error != nil {
+			return errors.Wrapf(result.Error, "failed to load the S3 request count of export %s", exp.federationPrefix)
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		exp.lock.Lock()
+		exp.month = month
+		exp.requests = count.Requests
+		exp.throttled = count.Throttled
+		budget := int64(param.Origin_S3MonthlyRequestBudget.GetInt())
+		exp.degraded = budget > 0 && exp.requests >= budget
+		exp.lock.Unlock()
+		metrics.PelicanOriginS3MonthlyRequests.WithLabelValues(exp.federationPrefix).Set(float64(count.Requests))
+		if exp.degraded {
+			log.Warningf("S3 export %s already exceeded its monthly request budget; it is degraded until the start of next month", exp.federationPrefix)
+			setS3ExportDegraded(exp.federationPrefix, true)
+		}
+	}
+	return nil
+}
+
+// Write the request counts that changed since the last save to the origin database
+func (gw *s3Gateway) saveRequestCounts() error {
+	if db == nil {
+		return nil
+	}
+	for _, exp := range gw.exports {
+		exp.lock.Lock()
+		if !exp.dirty {
+			exp.lock.Unlock()
+			continue
+		}
+		count := S3RequestCount{
+			FederationPrefix: exp.federationPrefix,
+			Month:            exp.month,
+			Requests:         exp.requests,
+			Throttled:        exp.throttled,
+		}
+		exp.dirty = false
+		exp.lock.Unlock()
+
+		err := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "federation_prefix"}, {Name: "month"}},
+			DoUpdates: clause.AssignmentColumns([]string{"requests", "throttled", "updated_at"}),
+		}).Create(&count).Error
+		if err != nil {
+			exp.lock.Lock()
+			exp.dirty = true
+			exp.lock.Unlock()
+			return errors.Wrapf(err, "failed to save the S3 request count of export %s", exp.federationPrefix)
+		}
+	}
+	return nil
+}
+
+// Launch the gateway tracking the requests the origin's S3 exports make to the S3
+// service.  Must be called before the XRootD configuration is generated, which
+// points XRootD at the gateway.
+func LaunchS3Gateway(ctx context.Context, egrp *errgroup.Group, exports []server_utils.OriginExport) error {
+	gw, err := newS3Gateway(exports)
+	if err != nil {
+		return err
+	}
+	if err := gw.loadRequestCounts(time.Now()); err != nil {
+		return err
+	}
+
+	gatewayUrl, err := launchLoopbackGateway(ctx, egrp, "S3 gateway", gw, func() {
+		if err := gw.saveRequestCounts(); err != nil {
+			log.Warningln("Failed to save the S3 request counts:", err)
+		}
+		s3GatewayLock.Lock()
+		activeGateway = nil
+		s3GatewayUrl = ""
+		s3GatewayLock.Unlock()
+	})
+	if err != nil {
+		return err
+	}
+
+	s3GatewayLock.Lock()
+	activeGateway = gw
+	s3GatewayUrl = gatewayUrl
+	s3GatewayLock.Unlock()
+	updateS3HealthStatus()
+
+	egrp.Go(func() error {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := gw.saveRequestCounts(); err != nil {
+					log.Warningln("Failed to save the S3 request counts:", err)
+				}
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	})
+	return nil
+}
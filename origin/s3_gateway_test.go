@@ -0,0 +1,297 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+// A fake S3 service recording the requests it receives
+type fakeS3Service struct {
+	lock       sync.Mutex
+	requests   []*http.Request
+	throttled  bool
+	denyWrites bool
+}
+
+func (f *fakeS3Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	f.requests = append(f.requests, r.Clone(r.Context()))
+	throttled := f.throttled
+	denyWrites := f.denyWrites
+	f.lock.Unlock()
+	if denyWrites && (r.Method == http.MethodPut || r.Method == http.MethodPost) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = io.WriteString(w, "<Error><Code>AccessDenied</Code></Error>")
+		return
+	}
+	if throttled {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = io.WriteString(w, "<Error><Code>SlowDown</Code></Error>")
+		return
+	}
+	_, _ = io.WriteString(w, "object contents")
+}
+
+func (f *fakeS3Service) lastRequest() *http.Request {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if len(f.requests) == 0 {
+		return nil
+	}
+	return f.requests[len(f.requests)-1]
+}
+
+func setupS3Gateway(t *testing.T, exports []server_utils.OriginExport) (*s3Gateway, *fakeS3Service, *httptest.Server) {
+	fake := &fakeS3Service{}
+	upstream := httptest.NewServer(fake)
+	t.Cleanup(upstream.Close)
+
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("Origin.S3ServiceUrl", upstream.URL)
+	viper.Set("Origin.S3Region", "us-east-1")
+	viper.Set("Origin.S3UrlStyle", "path")
+	viper.Set("Origin.S3MaxThrottleBackoff", "400ms")
+
+	gw, err := newS3Gateway(exports)
+	require.NoError(t, err)
+	s3GatewayLock.Lock()
+	activeGateway = gw
+	s3GatewayLock.Unlock()
+	t.Cleanup(func() {
+		s3GatewayLock.Lock()
+		activeGateway = nil
+		s3GatewayLock.Unlock()
+		s3DegradedLock.Lock()
+		s3DegradedExports = make(map[string]bool)
+		s3DegradedLock.Unlock()
+	})
+
+	gwServer := httptest.NewServer(gw)
+	t.Cleanup(gwServer.Close)
+	return gw, fake, gwServer
+}
+
+func TestS3GatewayForwarding(t *testing.T) {
+	keyDir := t.TempDir()
+	accessKeyfile := filepath.Join(keyDir, "access.key")
+	secretKeyfile := filepath.Join(keyDir, "secret.key")
+	require.NoError(t, os.WriteFile(accessKeyfile, []byte("AKIAEXAMPLE\n"), 0600))
+	require.NoError(t, os.WriteFile(secretKeyfile, []byte("secret\n"), 0600))
+
+	_, fake, gwServer := setupS3Gateway(t, []server_utils.OriginExport{
+		{FederationPrefix: "/signed", S3Bucket: "signed-bucket", S3AccessKeyfile: accessKeyfile, S3SecretKeyfile: secretKeyfile},
+		{FederationPrefix: "/public", S3Bucket: "public-bucket"},
+	})
+
+	t.Run("signed-request", func(t *testing.T) {
+		req, err := http.NewRequest("GET", gwServer.URL+"/signed-bucket/dir/object.txt", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "AWS4-HMAC-SHA256 signed-for-the-gateway")
+		req.Header.Set("Range", "bytes=0-3")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "object contents", string(body))
+
+		upstreamReq := fake.lastRequest()
+		require.NotNil(t, upstreamReq)
+		assert.Equal(t, "/signed-bucket/dir/object.txt", upstreamReq.URL.Path)
+		assert.Equal(t, "bytes=0-3", upstreamReq.Header.Get("Range"))
+		assert.True(t, strings.HasPrefix(upstreamReq.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIAEXAMPLE/"))
+		assert.Equal(t, "UNSIGNED-PAYLOAD", upstreamReq.Header.Get("X-Amz-Content-Sha256"))
+	})
+
+	t.Run("unsigned-request", func(t *testing.T) {
+		resp, err := http.Get(gwServer.URL + "/public-bucket/object.txt?list-type=2")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		upstreamReq := fake.lastRequest()
+		require.NotNil(t, upstreamReq)
+		assert.Equal(t, "list-type=2", upstreamReq.URL.RawQuery)
+		assert.Empty(t, upstreamReq.Header.Get("Authorization"))
+	})
+
+	t.Run("unknown-bucket", func(t *testing.T) {
+		resp, err := http.Get(gwServer.URL + "/other-bucket/object.txt")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("read-only-export-rejects-writes", func(t *testing.T) {
+		before := fake.lastRequest()
+		for _, method := range []string{http.MethodPut, http.MethodDelete, http.MethodPost} {
+			req, err := http.NewRequest(method, gwServer.URL+"/public-bucket/object.txt", strings.NewReader("data"))
+			require.NoError(t, err)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode, method)
+		}
+		assert.Equal(t, before, fake.lastRequest())
+	})
+}
+
+func TestS3GatewayBucketlessExports(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("Origin.S3ServiceUrl", "https://s3.example.org")
+
+	gw, err := newS3Gateway([]server_utils.OriginExport{
+		{FederationPrefix: "/bucket", S3Bucket: "bucket"},
+		{FederationPrefix: "/no-bucket"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "/bucket", gw.lookupExport("bucket").federationPrefix)
+	assert.Equal(t, "/no-bucket", gw.lookupExport("other").federationPrefix)
+
+	_, err = newS3Gateway([]server_utils.OriginExport{
+		{FederationPrefix: "/first"},
+		{FederationPrefix: "/second"},
+	})
+	assert.ErrorContains(t, err, "both have no bucket")
+}
+
+func TestS3GatewayUpstreamUrl(t *testing.T) {
+	gw, _, _ := setupS3Gateway(t, []server_utils.OriginExport{{FederationPrefix: "/test", S3Bucket: "bucket"}})
+	gw.upstream.Host = "s3.example.org"
+	gw.upstream.Path = "/base/"
+
+	assert.Equal(t, "/base/bucket/a/b.txt", gw.getUpstreamUrl("bucket", "a/b.txt", "").Path)
+
+	gw.urlStyle = "virtual"
+	result := gw.getUpstreamUrl("bucket", "a/b.txt", "x=1")
+	assert.Equal(t, "bucket.s3.example.org", result.Host)
+	assert.Equal(t, "/base/a/b.txt", result.Path)
+	assert.Equal(t, "x=1", result.RawQuery)
+}
+
+func TestS3GatewayThrottling(t *testing.T) {
+	gw, fake, gwServer := setupS3Gateway(t, []server_utils.OriginExport{{FederationPrefix: "/test", S3Bucket: "bucket"}})
+	exp := gw.lookupExport("bucket")
+	require.NotNil(t, exp)
+
+	fake.lock.Lock()
+	fake.throttled = true
+	fake.lock.Unlock()
+	for i := 0; i < 4; i++ {
+		resp, err := http.Get(gwServer.URL + "/bucket/object.txt")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	}
+	// 100ms, 200ms, 400ms, then capped at Origin.S3MaxThrottleBackoff
+	assert.Equal(t, 400*time.Millisecond, exp.getBackoff())
+	exp.lock.Lock()
+	assert.Equal(t, int64(4), exp.throttled)
+	exp.lock.Unlock()
+
+	fake.lock.Lock()
+	fake.throttled = false
+	fake.lock.Unlock()
+	for i := 0; i < 3; i++ {
+		resp, err := http.Get(gwServer.URL + "/bucket/object.txt")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	assert.Equal(t, time.Duration(0), exp.getBackoff())
+}
+
+func TestS3GatewayBudget(t *testing.T) {
+	gw, fake, gwServer := setupS3Gateway(t, []server_utils.OriginExport{{FederationPrefix: "/test", S3Bucket: "bucket"}})
+	viper.Set("Origin.S3MonthlyRequestBudget", 3)
+	exp := gw.lookupExport("bucket")
+
+	for i := 0; i < 3; i++ {
+		resp, err := http.Get(gwServer.URL + "/bucket/object.txt")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	assert.True(t, IsS3ExportDegraded("/test"))
+
+	// Requests over the budget never reach the S3 service
+	resp, err := http.Get(gwServer.URL + "/bucket/object.txt")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	fake.lock.Lock()
+	assert.Len(t, fake.requests, 3)
+	fake.lock.Unlock()
+
+	// The budget is reset at the start of the month
+	exp.lock.Lock()
+	exp.month = "2000-01"
+	exp.lock.Unlock()
+	assert.False(t, IsS3ExportDegraded("/test"))
+	resp, err = http.Get(gwServer.URL + "/bucket/object.txt")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestS3RequestCountPersistence(t *testing.T) {
+	mockDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, mockDB.AutoMigrate(&S3RequestCount{}))
+	db = mockDB
+	t.Cleanup(func() { db = nil })
+
+	exports := []server_utils.OriginExport{{FederationPrefix: "/test", S3Bucket: "bucket"}}
+	gw, _, _ := setupS3Gateway(t, exports)
+	viper.Set("Origin.S3MonthlyRequestBudget", 5)
+	now := time.Now()
+	exp := gw.lookupExport("bucket")
+	for i := 0; i < 5; i++ {
+		assert.True(t, exp.reserve(now, "GET"))
+	}
+	require.NoError(t, gw.saveRequestCounts())
+
+	// A restarted gateway picks up the count and stays degraded
+	restarted, err := newS3Gateway(exports)
+	require.NoError(t, err)
+	require.NoError(t, restarted.loadRequestCounts(now))
+	restartedExp := restarted.lookupExport("bucket")
+	assert.Equal(t, int64(5), restartedExp.requests)
+	assert.False(t, restartedExp.reserve(now, "GET"))
+}
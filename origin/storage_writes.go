@@ -0,0 +1,185 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+const (
+	// The name of the file or object created to check that the storage accepts writes
+	storageWriteCheckName = ".pelican-write-check"
+)
+
+var (
+	// Why the storage of each writable export rejects writes, keyed by its federation prefix
+	readOnlyExports     = make(map[string]string)
+	readOnlyExportsLock sync.RWMutex
+)
+
+// Returns true if the storage backend of the export, keyed by its federation prefix,
+// currently rejects writes; the export is then advertised without the Writes capability
+func IsExportReadOnly(federationPrefix string) bool {
+	readOnlyExportsLock.RLock()
+	defer readOnlyExportsLock.RUnlock()
+	_, ok := readOnlyExports[federationPrefix]
+	return ok
+}
+
+// Flip an export read-only, with the reason its storage rejects writes, or back to
+// writable if the reason is empty, and update the storage-writes health accordingly
+func setExportReadOnly(federationPrefix, reason string) {
+	readOnlyExportsLock.Lock()
+	_, wasReadOnly := readOnlyExports[federationPrefix]
+	if reason != "" {
+		readOnlyExports[federationPrefix] = reason
+		metrics.PelicanOriginExportReadOnly.WithLabelValues(federationPrefix).Set(1)
+	} else {
+		delete(readOnlyExports, federationPrefix)
+		metrics.PelicanOriginExportReadOnly.WithLabelValues(federationPrefix).Set(0)
+	}
+	readOnlyExportsLock.Unlock()
+
+	if reason != "" && !wasReadOnly {
+		log.Warningf("Export %s is advertised read-only until its storage accepts writes again: %s", federationPrefix, reason)
+	} else if reason == "" && wasReadOnly {
+		log.Infof("The storage of export %s accepts writes again; it is advertised as writable", federationPrefix)
+	}
+	updateStorageWritesHealthStatus()
+}
+
+// Set the health of the storage writes from the read-only exports
+func updateStorageWritesHealthStatus() {
+	readOnlyExportsLock.RLock()
+	problems := make([]string, 0, len(readOnlyExports))
+	for prefix, reason := range readOnlyExports {
+		problems = append(problems, prefix+": "+reason)
+	}
+	readOnlyExportsLock.RUnlock()
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		metrics.SetComponentHealthStatus(metrics.Origin_StorageWrites, metrics.StatusWarning,
+			"The storage of writable exports rejects writes; they are advertised read-only:\n"+strings.Join(problems, "\n"))
+	} else {
+		metrics.SetComponentHealthStatus(metrics.Origin_StorageWrites, metrics.StatusOK, "The storage of all writable exports accepts writes")
+	}
+}
+
+// Check that the storage of a POSIX export accepts writes by creating, syncing and
+// removing a small file in its storage prefix.  Only the failures meaning the storage
+// itself is read-only (read-only filesystem, out of space or over quota) are returned
+// as the reason; other failures are logged, as the consistency audit reports them.
+func checkPosixExportWritable(export server_utils.OriginExport) (reason string) {
+	fp, err := os.CreateTemp(export.StoragePrefix, storageWriteCheckName+"-*")
+	if err == nil {
+		defer os.Remove(fp.Name())
+		if _, err = fp.Write([]byte{0}); err == nil {
+			err = fp.Sync()
+		}
+		if closeErr := fp.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, syscall.EROFS):
+		return "the filesystem of " + export.StoragePrefix + " is read-only"
+	case errors.Is(err, syscall.ENOSPC):
+		return "no space is left on the filesystem of " + export.StoragePrefix
+	case errors.Is(err, syscall.EDQUOT):
+		return "the disk quota of " + export.StoragePrefix + " is exceeded"
+	default:
+		log.Warningf("Failed to check that the storage of export %s accepts writes: %v", export.FederationPrefix, err)
+		return ""
+	}
+}
+
+// Check the storage of each writable export, flipping the exports read-only or
+// writable as needed
+func doStorageWriteCheck(ctx context.Context) {
+	exports, err := server_utils.GetOriginExports()
+	if err != nil {
+		log.Errorln("Failed to get origin exports for the storage write check:", err)
+		return
+	}
+	switch server_utils.OriginStorageType(param.Origin_StorageType.GetString()) {
+	case server_utils.OriginStoragePosix:
+		for _, export := range exports {
+			if export.Capabilities.Writes {
+				setExportReadOnly(export.FederationPrefix, checkPosixExportWritable(export))
+			}
+		}
+	case server_utils.OriginStorageS3:
+		// The exports are flipped read-only by the responses to the writes forwarded
+		// through the S3 gateway; only those are checked, to restore them
+		s3GatewayLock.RLock()
+		gw := activeGateway
+		s3GatewayLock.RUnlock()
+		if gw == nil {
+			return
+		}
+		for _, exp := range gw.exports {
+			if !exp.writable || !IsExportReadOnly(exp.federationPrefix) {
+				continue
+			}
+			if err := gw.checkExportWritable(ctx, exp); err != nil {
+				log.Debugf("The storage of S3 export %s still rejects writes: %v", exp.federationPrefix, err)
+			} else {
+				setExportReadOnly(exp.federationPrefix, "")
+			}
+		}
+	}
+}
+
+// Periodically check that the storage of the origin's writable exports accepts writes,
+// advertising the exports read-only while it doesn't
+func PeriodicStorageWriteCheck(ctx context.Context) error {
+	interval := param.Origin_StorageWriteCheckInterval.GetDuration()
+	if interval <= 0 {
+		interval = time.Minute
+		log.Errorf("Invalid config value: Origin.StorageWriteCheckInterval is %s. Fallback to 1m.", param.Origin_StorageWriteCheckInterval.GetDuration())
+	}
+	updateStorageWritesHealthStatus()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	doStorageWriteCheck(ctx)
+	for {
+		select {
+		case <-ticker.C:
+			doStorageWriteCheck(ctx)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
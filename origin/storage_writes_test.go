@@ -0,0 +1,111 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+func resetReadOnlyExports(t *testing.T) {
+	t.Cleanup(func() {
+		readOnlyExportsLock.Lock()
+		readOnlyExports = make(map[string]string)
+		readOnlyExportsLock.Unlock()
+	})
+}
+
+func TestCheckPosixExportWritable(t *testing.T) {
+	storage := t.TempDir()
+	export := server_utils.OriginExport{FederationPrefix: "/test", StoragePrefix: storage, Capabilities: server_structs.Capabilities{Writes: true}}
+	assert.Empty(t, checkPosixExportWritable(export))
+	entries, err := os.ReadDir(storage)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "the write check should remove its file")
+
+	// Failures other than the storage being read-only are left to the consistency audit
+	export.StoragePrefix = filepath.Join(storage, "missing")
+	assert.Empty(t, checkPosixExportWritable(export))
+}
+
+func TestSetExportReadOnly(t *testing.T) {
+	resetReadOnlyExports(t)
+
+	assert.False(t, IsExportReadOnly("/test"))
+	setExportReadOnly("/test", "the filesystem is read-only")
+	assert.True(t, IsExportReadOnly("/test"))
+	assert.False(t, IsExportReadOnly("/other"))
+	setExportReadOnly("/test", "")
+	assert.False(t, IsExportReadOnly("/test"))
+}
+
+func TestS3ExportReadOnly(t *testing.T) {
+	resetReadOnlyExports(t)
+	gw, fake, gwServer := setupS3Gateway(t, []server_utils.OriginExport{
+		{FederationPrefix: "/test", S3Bucket: "bucket", Capabilities: server_structs.Capabilities{Writes: true}},
+	})
+	viper.Set("Origin.StorageWriteCheck", true)
+	exp := gw.lookupExport("bucket")
+
+	put := func() int {
+		req, err := http.NewRequest(http.MethodPut, gwServer.URL+"/bucket/object.txt", strings.NewReader("contents"))
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	fake.lock.Lock()
+	fake.denyWrites = true
+	fake.lock.Unlock()
+	assert.Equal(t, http.StatusForbidden, put())
+	assert.True(t, IsExportReadOnly("/test"))
+
+	// Reads don't restore the export
+	resp, err := http.Get(gwServer.URL + "/bucket/object.txt")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.True(t, IsExportReadOnly("/test"))
+
+	assert.Error(t, gw.checkExportWritable(context.Background(), exp))
+
+	fake.lock.Lock()
+	fake.denyWrites = false
+	fake.lock.Unlock()
+	require.NoError(t, gw.checkExportWritable(context.Background(), exp))
+	upstreamReq := fake.lastRequest()
+	require.NotNil(t, upstreamReq)
+	assert.Equal(t, http.MethodDelete, upstreamReq.Method)
+	assert.Equal(t, "/bucket/"+storageWriteCheckName, upstreamReq.URL.Path)
+
+	assert.Equal(t, http.StatusOK, put())
+	assert.False(t, IsExportReadOnly("/test"))
+}
@@ -0,0 +1,399 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+	"github.com/pelicanplatform/pelican/token_scopes"
+)
+
+type (
+	// A client of the WLCG Tape REST API of a tape-backed HTTPS backend (e.g. dCache or CTA),
+	// used to find out whether objects are on disk and to bring them online
+	tapeBackend struct {
+		api        *url.URL
+		client     *http.Client
+		auth       httpsUpstreamAuth // nil if the backend needs no authentication
+		retryAfter time.Duration
+
+		// The stage requests submitted for objects requested while on tape, by backend path,
+		// so each object is only staged once at a time
+		lock    sync.Mutex
+		staging map[string]tapeStageRequest
+	}
+
+	tapeStageRequest struct {
+		id        string
+		submitted time.Time
+	}
+
+	tapeArchiveInfo struct {
+		Path     string `json:"path"`
+		Locality string `json:"locality,omitempty"`
+		Error    string `json:"error,omitempty"`
+	}
+
+	// The body of a request to the origin's stage API, with the federation paths of the objects
+	stageApiRequest struct {
+		Paths []string `json:"paths" binding:"required"`
+	}
+
+	// The origin's stage API, letting clients ask for objects to be brought online ahead of time
+	tapeStageApi struct {
+		tape       *tapeBackend
+		exports    []server_utils.OriginExport
+		parseToken func(string) (jwt.Token, error)
+		// Maps the federation path of an object to its backend path
+		backendPath func(export server_utils.OriginExport, objectPath string) string
+	}
+)
+
+const (
+	// Localities of the Tape REST API for objects that can be read right away
+	tapeLocalityDisk        = "DISK"
+	tapeLocalityDiskAndTape = "DISK_AND_TAPE"
+	// Locality of objects that are only on tape
+	tapeLocalityTape = "TAPE"
+
+	// A stage request is resubmitted if the object still isn't on disk after this long,
+	// in case the backend dropped the request
+	tapeStageResubmitAfter = 6 * time.Hour
+)
+
+var (
+	tapeBackendLock sync.RWMutex
+	activeTape      *tapeBackend
+)
+
+// Returns true if the origin's HTTPS backend is tape-backed
+func IsTapeBacked() bool {
+	return param.Origin_StorageType.GetString() == string(server_utils.OriginStorageHTTPS) &&
+		param.Origin_HttpTapeRestApiUrl.GetString() != ""
+}
+
+func newTapeBackend(client *http.Client, auth httpsUpstreamAuth) (*tapeBackend, error) {
+	api, err := url.Parse(param.Origin_HttpTapeRestApiUrl.GetString())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse Origin.HttpTapeRestApiUrl")
+	}
+	if api.Scheme == "" || api.Host == "" {
+		return nil, errors.Errorf("Origin.HttpTapeRestApiUrl %q must be an absolute URL", param.Origin_HttpTapeRestApiUrl.GetString())
+	}
+	retryAfter := param.Origin_TapeStageRetryAfter.GetDuration()
+	if retryAfter < time.Second {
+		retryAfter = time.Second
+	}
+	return &tapeBackend{
+		api:        api,
+		client:     client,
+		auth:       auth,
+		retryAfter: retryAfter,
+		staging:    map[string]tapeStageRequest{},
+	}, nil
+}
+
+// Send a request to the Tape REST API, decoding its JSON response into `result`
+func (tb *tapeBackend) do(ctx context.Context, method, endpoint string, body interface{}, result interface{}) (int, error) {
+	var reqBody io.Reader
+	if body != nil {
+		bodyJson, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reqBody = bytes.NewReader(bodyJson)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, tb.api.JoinPath(endpoint).String(), reqBody)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if tb.auth != nil {
+		if err = tb.auth.authorize(req); err != nil {
+			return 0, err
+		}
+	}
+	resp, err := tb.client.Do(req)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to contact the tape REST API")
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 4*1024*1024))
+	if err != nil {
+		return resp.StatusCode, errors.Wrap(err, "failed to read the response of the tape REST API")
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, errors.Errorf("the tape REST API responded to %s %s with status code %d: %s", method, endpoint, resp.StatusCode, string(respBody))
+	}
+	if result != nil {
+		if err = json.Unmarshal(respBody, result); err != nil {
+			return resp.StatusCode, errors.Wrap(err, "failed to parse the response of the tape REST API")
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// Check whether an object, by its backend path, can be read without staging it first
+func (tb *tapeBackend) isOnline(ctx context.Context, backendPath string) (bool, error) {
+	infos := []tapeArchiveInfo{}
+	if _, err := tb.do(ctx, http.MethodPost, "archiveinfo", map[string][]string{"paths": {backendPath}}, &infos); err != nil {
+		return false, err
+	}
+	for _, info := range infos {
+		if info.Path != backendPath {
+			continue
+		}
+		if info.Error != "" {
+			return false, errors.Errorf("the tape REST API has no archive information for %s: %s", backendPath, info.Error)
+		}
+		return info.Locality != tapeLocalityTape, nil
+	}
+	return false, errors.Errorf("the tape REST API has no archive information for %s", backendPath)
+}
+
+// Submit a stage request for the objects, by their backend paths, returning its ID
+func (tb *tapeBackend) submitStage(ctx context.Context, backendPaths []string) (string, error) {
+	files := make([]map[string]string, 0, len(backendPaths))
+	for _, backendPath := range backendPaths {
+		files = append(files, map[string]string{"path": backendPath})
+	}
+	result := struct {
+		RequestID string `json:"requestId"`
+	}{}
+	if _, err := tb.do(ctx, http.MethodPost, "stage", map[string]interface{}{"files": files}, &result); err != nil {
+		return "", err
+	}
+	if result.RequestID == "" {
+		return "", errors.New("the tape REST API accepted the stage request without returning its ID")
+	}
+	now := time.Now()
+	tb.lock.Lock()
+	for _, backendPath := range backendPaths {
+		tb.staging[backendPath] = tapeStageRequest{id: result.RequestID, submitted: now}
+	}
+	tb.lock.Unlock()
+	return result.RequestID, nil
+}
+
+// Get the status of a stage request
+func (tb *tapeBackend) getStageStatus(ctx context.Context, id string) (*server_structs.TapeStageStatus, int, error) {
+	status := &server_structs.TapeStageStatus{}
+	code, err := tb.do(ctx, http.MethodGet, "stage/"+url.PathEscape(id), nil, status)
+	if err != nil {
+		return nil, code, err
+	}
+	return status, code, nil
+}
+
+// Bring an object that's only on tape online, unless a stage request for it is already pending
+func (tb *tapeBackend) stage(ctx context.Context, backendPath string) (string, error) {
+	tb.lock.Lock()
+	pending, ok := tb.staging[backendPath]
+	tb.lock.Unlock()
+	if ok && time.Since(pending.submitted) < tapeStageResubmitAfter {
+		return pending.id, nil
+	}
+	return tb.submitStage(ctx, []string{backendPath})
+}
+
+// Forget the stage request of an object once it's on disk
+func (tb *tapeBackend) staged(backendPath string) {
+	tb.lock.Lock()
+	delete(tb.staging, backendPath)
+	tb.lock.Unlock()
+}
+
+// Check that a read of the object, by its backend path, can be served.  If the object is
+// only on tape, it's staged and the client is told to come back later with a 202; returns
+// false if the response was written.  Errors of the tape REST API are logged and the read
+// is attempted anyway.
+func (tb *tapeBackend) checkOnline(w http.ResponseWriter, r *http.Request, backendPath string) bool {
+	online, err := tb.isOnline(r.Context(), backendPath)
+	if err != nil {
+		log.Warningln("Failed to check whether the object is on disk:", err)
+		return true
+	}
+	if online {
+		tb.staged(backendPath)
+		return true
+	}
+	id, err := tb.stage(r.Context(), backendPath)
+	if err != nil {
+		log.Errorf("Failed to stage %s from tape: %v", backendPath, err)
+		http.Error(w, "The object is on tape and could not be staged", http.StatusServiceUnavailable)
+		return false
+	}
+	log.Debugf("%s is on tape; staging it with request %s", backendPath, id)
+	w.Header().Set("Retry-After", fmt.Sprint(int(tb.retryAfter.Seconds())))
+	w.Header().Set(server_structs.TapeStageRequestHeader, id)
+	http.Error(w, "The object is being staged from tape; retry later", http.StatusAccepted)
+	return false
+}
+
+// Set the tape backend the stage API uses; nil when the origin isn't tape-backed
+func setActiveTape(tb *tapeBackend) {
+	tapeBackendLock.Lock()
+	defer tapeBackendLock.Unlock()
+	activeTape = tb
+}
+
+func getActiveTape() *tapeBackend {
+	tapeBackendLock.RLock()
+	defer tapeBackendLock.RUnlock()
+	return activeTape
+}
+
+// Find the export holding the object, preferring the longest matching prefix
+func (api *tapeStageApi) findExport(objectPath string) *server_utils.OriginExport {
+	return server_utils.FindOriginExport(api.exports, objectPath)
+}
+
+// Check the request's token allows staging or reading each object, returning their backend paths
+func (api *tapeStageApi) authorize(req *http.Request, objectPaths []string) ([]string, int, error) {
+	tokStr := getRequestToken(req)
+	if tokStr == "" {
+		return nil, http.StatusUnauthorized, errors.New("staging objects requires a token")
+	}
+	tok, err := api.parseToken(tokStr)
+	if err != nil {
+		return nil, http.StatusForbidden, errors.Wrap(err, "the token is not valid")
+	}
+	scopes := token_scopes.ParseResourceScopeString(tok)
+	backendPaths := make([]string, 0, len(objectPaths))
+	for _, objectPath := range objectPaths {
+		objectPath = path.Clean("/" + objectPath)
+		export := api.findExport(objectPath)
+		if export == nil {
+			return nil, http.StatusNotFound, errors.Errorf("no export of the origin holds %s", objectPath)
+		}
+		relPath := strings.TrimPrefix(objectPath, strings.TrimSuffix(export.FederationPrefix, "/"))
+		allowed := false
+		for _, scope := range scopes {
+			for _, authz := range []token_scopes.TokenScope{token_scopes.Storage_Stage, token_scopes.Storage_Read} {
+				if scope.Contains(token_scopes.NewResourceScope(authz, relPath)) || scope.Contains(token_scopes.NewResourceScope(authz, objectPath)) {
+					allowed = true
+				}
+			}
+		}
+		if !allowed {
+			return nil, http.StatusForbidden, errors.Errorf("the token does not allow staging %s", objectPath)
+		}
+		backendPaths = append(backendPaths, api.backendPath(*export, objectPath))
+	}
+	return backendPaths, http.StatusOK, nil
+}
+
+func stageApiError(ctx *gin.Context, status int, err error) {
+	ctx.JSON(status, server_structs.SimpleApiResp{Status: server_structs.RespFailed, Msg: err.Error()})
+}
+
+// Submit a stage request for the objects in the body
+func (api *tapeStageApi) submit(ctx *gin.Context) {
+	req := stageApiRequest{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		stageApiError(ctx, http.StatusBadRequest, errors.Wrap(err, "invalid request body"))
+		return
+	}
+	if len(req.Paths) == 0 {
+		stageApiError(ctx, http.StatusBadRequest, errors.New("no paths to stage"))
+		return
+	}
+	backendPaths, status, err := api.authorize(ctx.Request, req.Paths)
+	if err != nil {
+		stageApiError(ctx, status, err)
+		return
+	}
+	id, err := api.tape.submitStage(ctx.Request.Context(), backendPaths)
+	if err != nil {
+		log.Errorln("Failed to submit the stage request:", err)
+		stageApiError(ctx, http.StatusBadGateway, errors.New("failed to submit the stage request to the tape backend"))
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"id": id})
+}
+
+// Report the status of a stage request, as reported by the tape backend
+func (api *tapeStageApi) status(ctx *gin.Context) {
+	if getRequestToken(ctx.Request) == "" {
+		stageApiError(ctx, http.StatusUnauthorized, errors.New("checking stage requests requires a token"))
+		return
+	}
+	if _, err := api.parseToken(getRequestToken(ctx.Request)); err != nil {
+		stageApiError(ctx, http.StatusForbidden, errors.Wrap(err, "the token is not valid"))
+		return
+	}
+	status, code, err := api.tape.getStageStatus(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		if code == http.StatusNotFound {
+			stageApiError(ctx, http.StatusNotFound, errors.Errorf("no stage request %s", ctx.Param("id")))
+			return
+		}
+		log.Errorln("Failed to get the status of the stage request:", err)
+		stageApiError(ctx, http.StatusBadGateway, errors.New("failed to get the status of the stage request from the tape backend"))
+		return
+	}
+	ctx.JSON(http.StatusOK, status)
+}
+
+// Register the origin's stage API for tape-backed storage.  Tokens signed by one of the
+// trustedIssuers with storage.stage or storage.read for an object may stage it.
+func RegisterTapeStageApi(engine *gin.Engine, exports []server_utils.OriginExport, trustedIssuers []string) error {
+	tape := getActiveTape()
+	if tape == nil {
+		return errors.New("the HTTPS backend gateway must be running to stage objects from tape")
+	}
+	gw, err := url.Parse(param.Origin_HttpServiceUrl.GetString())
+	if err != nil {
+		return errors.Wrap(err, "failed to parse Origin.HttpServiceUrl")
+	}
+	api := &tapeStageApi{
+		tape:       tape,
+		exports:    exports,
+		parseToken: newIssuerTokenVerifier(trustedIssuers).parse,
+		backendPath: func(export server_utils.OriginExport, objectPath string) string {
+			relPath := strings.TrimPrefix(objectPath, strings.TrimSuffix(export.FederationPrefix, "/"))
+			return path.Join("/", gw.Path, export.StoragePrefix, relPath)
+		},
+	}
+	group := engine.Group(server_structs.TapeStagePath)
+	group.POST("", api.submit)
+	group.GET("/:id", api.status)
+	return nil
+}
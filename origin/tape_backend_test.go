@@ -0,0 +1,195 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+// A fake WLCG Tape REST API; objects are on tape until staged
+type fakeTapeApi struct {
+	mutex    sync.Mutex
+	onDisk   map[string]bool
+	staged   []string
+	requests int
+}
+
+func (f *fakeTapeApi) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	switch {
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/archiveinfo"):
+		body := struct {
+			Paths []string `json:"paths"`
+		}{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		infos := []tapeArchiveInfo{}
+		for _, objPath := range body.Paths {
+			locality := tapeLocalityTape
+			if f.onDisk[objPath] {
+				locality = tapeLocalityDiskAndTape
+			}
+			infos = append(infos, tapeArchiveInfo{Path: objPath, Locality: locality})
+		}
+		_ = json.NewEncoder(w).Encode(infos)
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/stage"):
+		body := struct {
+			Files []server_structs.TapeStageFile `json:"files"`
+		}{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		for _, file := range body.Files {
+			f.staged = append(f.staged, file.Path)
+		}
+		f.requests++
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]string{"requestId": "req-1"})
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/stage/req-1"):
+		files := []server_structs.TapeStageFile{}
+		for _, objPath := range f.staged {
+			files = append(files, server_structs.TapeStageFile{Path: objPath, OnDisk: f.onDisk[objPath], State: "STARTED"})
+		}
+		_ = json.NewEncoder(w).Encode(server_structs.TapeStageStatus{ID: "req-1", Files: files})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestTapeBackedGateway(t *testing.T) {
+	tapeApi := &fakeTapeApi{onDisk: map[string]bool{}}
+	tapeServer := httptest.NewServer(tapeApi)
+	t.Cleanup(tapeServer.Close)
+
+	server := setupHttpsGateway(t, func() {
+		viper.Set("Origin.HttpTapeRestApiUrl", tapeServer.URL+"/api/v1")
+		viper.Set("Origin.TapeStageRetryAfter", "30s")
+	})
+	get := func(t *testing.T) *http.Response {
+		resp, err := http.Get(server.URL + "/foo/bar.txt")
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	// The object is on tape: it's staged once and the client asked to retry
+	for i := 0; i < 2; i++ {
+		resp := get(t)
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+		assert.Equal(t, "30", resp.Header.Get("Retry-After"))
+		assert.Equal(t, "req-1", resp.Header.Get(server_structs.TapeStageRequestHeader))
+	}
+	tapeApi.mutex.Lock()
+	assert.Equal(t, []string{"/testfiles/foo/bar.txt"}, tapeApi.staged)
+	assert.Equal(t, 1, tapeApi.requests)
+	tapeApi.onDisk["/testfiles/foo/bar.txt"] = true
+	tapeApi.mutex.Unlock()
+
+	// Once on disk, the object is read from the backend
+	resp := get(t)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "/testfiles/foo/bar.txt", resp.Header.Get("X-Request-Path"))
+}
+
+func TestTapeStageApi(t *testing.T) {
+	tapeApi := &fakeTapeApi{onDisk: map[string]bool{}}
+	tapeServer := httptest.NewServer(tapeApi)
+	t.Cleanup(tapeServer.Close)
+
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("Origin.HttpTapeRestApiUrl", tapeServer.URL+"/api/v1")
+	tape, err := newTapeBackend(http.DefaultClient, nil)
+	require.NoError(t, err)
+
+	api := &tapeStageApi{
+		tape:    tape,
+		exports: []server_utils.OriginExport{{FederationPrefix: "/foo", StoragePrefix: "/data"}},
+		// The test tokens are unsigned; their scope is the token string
+		parseToken: func(tok string) (jwt.Token, error) {
+			return jwt.NewBuilder().Claim("scope", tok).Build()
+		},
+		backendPath: func(export server_utils.OriginExport, objectPath string) string {
+			return "/testfiles" + export.StoragePrefix + strings.TrimPrefix(objectPath, export.FederationPrefix)
+		},
+	}
+	engine := gin.New()
+	group := engine.Group(server_structs.TapeStagePath)
+	group.POST("", api.submit)
+	group.GET("/:id", api.status)
+
+	do := func(method, target, tok, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if tok != "" {
+			req.Header.Set("Authorization", "Bearer "+tok)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("requires-token", func(t *testing.T) {
+		w := do(http.MethodPost, server_structs.TapeStagePath, "", `{"paths": ["/foo/a"]}`)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("requires-scope", func(t *testing.T) {
+		w := do(http.MethodPost, server_structs.TapeStagePath, "storage.read:/other", `{"paths": ["/foo/a"]}`)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("unknown-export", func(t *testing.T) {
+		w := do(http.MethodPost, server_structs.TapeStagePath, "storage.stage:/", `{"paths": ["/bar/a"]}`)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("stage-and-status", func(t *testing.T) {
+		w := do(http.MethodPost, server_structs.TapeStagePath, "storage.stage:/", `{"paths": ["/foo/a", "/foo/dir/b"]}`)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		assert.JSONEq(t, `{"id": "req-1"}`, w.Body.String())
+		tapeApi.mutex.Lock()
+		assert.Equal(t, []string{"/testfiles/data/a", "/testfiles/data/dir/b"}, tapeApi.staged)
+		tapeApi.onDisk["/testfiles/data/a"] = true
+		tapeApi.mutex.Unlock()
+
+		w = do(http.MethodGet, server_structs.TapeStagePath+"/req-1", "storage.stage:/", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		status := server_structs.TapeStageStatus{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+		require.Len(t, status.Files, 2)
+		assert.True(t, status.Files[0].OnDisk)
+		assert.False(t, status.Files[1].OnDisk)
+
+		w = do(http.MethodGet, server_structs.TapeStagePath+"/req-2", "storage.stage:/", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
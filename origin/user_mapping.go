@@ -0,0 +1,239 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pkg/errors"
+
+	"github.com/pelicanplatform/pelican/param"
+)
+
+type (
+	// A rule mapping the tokens that match all of its conditions to a local user.
+	// Rules are configured in Origin.UserMapping and read from the name mapfile at
+	// Origin.ScitokensNameMapFile, which uses the JSON format of XRootD's SciTokens
+	// plugin; the generated mapfile given to XRootD uses the same format.
+	UserMappingRule struct {
+		// The token's subject ("sub" claim)
+		Subject string `mapstructure:"Subject" json:"sub,omitempty"`
+		// The token's username, from the claim set in Origin.ScitokensUsernameClaim
+		Username string `mapstructure:"Username" json:"username,omitempty"`
+		// One of the token's groups ("wlcg.groups" claim), e.g. "/cms/production"
+		Group string `mapstructure:"Group" json:"group,omitempty"`
+		// A federation path prefix the request must be under
+		Path string `mapstructure:"Path" json:"path,omitempty"`
+		// The local user the matching tokens are mapped to
+		User string `mapstructure:"User" json:"result"`
+		// Set in a mapfile to skip the rule
+		Ignore  bool   `mapstructure:"Ignore" json:"ignore,omitempty"`
+		Comment string `mapstructure:"Comment" json:"comment,omitempty"`
+
+		// Where the rule is configured, for reporting which rule matched
+		source string
+	}
+
+	// The local identity a token is mapped to for a request
+	UserMapping struct {
+		User string
+		// How the user was chosen, e.g. "rule 2 of Origin.UserMapping" or "Origin.ScitokensDefaultUser"
+		Source string
+	}
+
+	// The local ownership a file written through the origin gets
+	FileOwnership struct {
+		Uid int
+		Gid int
+		// The setgid directory the group is inherited from, if any
+		SetgidDir string
+	}
+)
+
+func (rule UserMappingRule) validate() error {
+	if rule.User == "" {
+		return errors.New("the rule has no user to map to")
+	}
+	if rule.Path != "" && !strings.HasPrefix(rule.Path, "/") {
+		return errors.Errorf("the path %q of the rule is not absolute", rule.Path)
+	}
+	return nil
+}
+
+// Check whether the token and requested federation path match all of the rule's conditions
+func (rule UserMappingRule) matches(subject, username string, groups []string, objectPath string) bool {
+	if rule.Ignore {
+		return false
+	}
+	if rule.Subject != "" && rule.Subject != subject {
+		return false
+	}
+	if rule.Username != "" && rule.Username != username {
+		return false
+	}
+	if rule.Group != "" {
+		found := false
+		for _, group := range groups {
+			if group == rule.Group {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if rule.Path != "" {
+		prefix := path.Clean(rule.Path)
+		objectPath = path.Clean(objectPath)
+		if objectPath != prefix && !strings.HasPrefix(objectPath, strings.TrimSuffix(prefix, "/")+"/") {
+			return false
+		}
+	}
+	return true
+}
+
+// Get the user mapping rules of the origin: those in Origin.UserMapping followed by
+// those in the mapfile at Origin.ScitokensNameMapFile.  The first matching rule wins.
+func GetUserMappingRules() (rules []UserMappingRule, err error) {
+	if err = param.Origin_UserMapping.Unmarshal(&rules); err != nil {
+		return nil, errors.Wrap(err, "failed to parse Origin.UserMapping")
+	}
+	for idx := range rules {
+		if err := rules[idx].validate(); err != nil {
+			return nil, errors.Wrapf(err, "invalid rule %d of Origin.UserMapping", idx+1)
+		}
+		rules[idx].source = fmt.Sprintf("rule %d of Origin.UserMapping", idx+1)
+	}
+
+	if mapfile := param.Origin_ScitokensNameMapFile.GetString(); mapfile != "" {
+		contents, err := os.ReadFile(mapfile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read the name mapfile set in Origin.ScitokensNameMapFile")
+		}
+		fileRules := []UserMappingRule{}
+		if err := json.Unmarshal(contents, &fileRules); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse the name mapfile %s", mapfile)
+		}
+		for idx := range fileRules {
+			fileRules[idx].source = fmt.Sprintf("rule %d of the name mapfile %s", idx+1, mapfile)
+			if fileRules[idx].Ignore {
+				continue
+			}
+			if err := fileRules[idx].validate(); err != nil {
+				return nil, errors.Wrapf(err, "invalid rule %d of the name mapfile %s", idx+1, mapfile)
+			}
+		}
+		rules = append(rules, fileRules...)
+	}
+	return rules, nil
+}
+
+// Determine the local user a token is mapped to when accessing objectPath, in the
+// same order as XRootD: the first matching rule, then the username claim
+// (Origin.ScitokensUsernameClaim), the token subject (Origin.ScitokensMapSubject),
+// and finally Origin.ScitokensDefaultUser.  The token isn't verified.
+func MapTokenToUser(tok jwt.Token, objectPath string, rules []UserMappingRule) (UserMapping, error) {
+	groups := []string{}
+	if rawGroups, ok := tok.Get("wlcg.groups"); ok {
+		if groupList, ok := rawGroups.([]interface{}); ok {
+			for _, group := range groupList {
+				if groupStr, ok := group.(string); ok {
+					groups = append(groups, groupStr)
+				}
+			}
+		}
+	}
+
+	username := ""
+	claim := param.Origin_ScitokensUsernameClaim.GetString()
+	if claim != "" {
+		if value, ok := tok.Get(claim); ok {
+			username, _ = value.(string)
+		}
+	}
+
+	for _, rule := range rules {
+		if rule.matches(tok.Subject(), username, groups, objectPath) {
+			return UserMapping{User: rule.User, Source: rule.source}, nil
+		}
+	}
+
+	if username != "" {
+		return UserMapping{User: username, Source: fmt.Sprintf("the %q claim (Origin.ScitokensUsernameClaim)", claim)}, nil
+	}
+	if param.Origin_ScitokensMapSubject.GetBool() && tok.Subject() != "" {
+		return UserMapping{User: tok.Subject(), Source: "the token subject (Origin.ScitokensMapSubject)"}, nil
+	}
+	if defaultUser := param.Origin_ScitokensDefaultUser.GetString(); defaultUser != "" {
+		return UserMapping{User: defaultUser, Source: "Origin.ScitokensDefaultUser"}, nil
+	}
+	return UserMapping{}, errors.New("no rule matches the token and no default user is configured")
+}
+
+// Determine the ownership of a new file written by the local user at localPath.
+// The file belongs to the user and its primary group unless the closest existing
+// parent directory has the setgid bit, in which case it inherits the directory's group.
+func GetNewFileOwnership(username string, localPath string) (ownership FileOwnership, err error) {
+	localUser, err := user.Lookup(username)
+	if err != nil {
+		return ownership, errors.Wrapf(err, "failed to look up the local user %s", username)
+	}
+	if ownership.Uid, err = strconv.Atoi(localUser.Uid); err != nil {
+		return ownership, errors.Wrapf(err, "invalid uid of the local user %s", username)
+	}
+	if ownership.Gid, err = strconv.Atoi(localUser.Gid); err != nil {
+		return ownership, errors.Wrapf(err, "invalid gid of the local user %s", username)
+	}
+	if localPath == "" {
+		return ownership, nil
+	}
+
+	dir := filepath.Dir(filepath.Clean(localPath))
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return ownership, errors.Errorf("%s is not a directory", dir)
+			}
+			if info.Mode()&os.ModeSetgid != 0 {
+				if gid, ok := getFileGid(info); ok {
+					ownership.Gid = gid
+					ownership.SetgidDir = dir
+				}
+			}
+			return ownership, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return ownership, errors.Wrapf(err, "failed to check the directory %s", dir)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ownership, nil
+		}
+		dir = parent
+	}
+}
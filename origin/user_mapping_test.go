@@ -0,0 +1,152 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package origin
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapTokenToUser(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	mapfile := filepath.Join(t.TempDir(), "mapfile.json")
+	require.NoError(t, os.WriteFile(mapfile, []byte(`[
+		{"sub": "ignored", "result": "nobody", "ignore": true},
+		{"group": "/atlas", "result": "atlasprod", "comment": "ATLAS production"}
+	]`), 0644))
+	viper.Set("Origin.ScitokensNameMapFile", mapfile)
+	viper.Set("Origin.UserMapping", []map[string]interface{}{
+		{"Subject": "alice", "User": "alice"},
+		{"Group": "/cms", "Path": "/data/cms", "User": "cmsprod"},
+		{"Group": "/cms", "User": "cmsuser"},
+	})
+
+	rules, err := GetUserMappingRules()
+	require.NoError(t, err)
+	require.Len(t, rules, 5)
+
+	newToken := func(t *testing.T, subject string, groups ...string) jwt.Token {
+		tok := jwt.New()
+		require.NoError(t, tok.Set(jwt.SubjectKey, subject))
+		if len(groups) > 0 {
+			groupList := []interface{}{}
+			for _, group := range groups {
+				groupList = append(groupList, group)
+			}
+			require.NoError(t, tok.Set("wlcg.groups", groupList))
+		}
+		return tok
+	}
+
+	t.Run("first-matching-rule-wins", func(t *testing.T) {
+		mapping, err := MapTokenToUser(newToken(t, "alice", "/cms"), "/data/cms/file", rules)
+		require.NoError(t, err)
+		assert.Equal(t, UserMapping{User: "alice", Source: "rule 1 of Origin.UserMapping"}, mapping)
+
+		mapping, err = MapTokenToUser(newToken(t, "bob", "/cms"), "/data/cms/file", rules)
+		require.NoError(t, err)
+		assert.Equal(t, "cmsprod", mapping.User)
+
+		// The path must match on a component boundary
+		mapping, err = MapTokenToUser(newToken(t, "bob", "/cms"), "/data/cmsfoo/file", rules)
+		require.NoError(t, err)
+		assert.Equal(t, UserMapping{User: "cmsuser", Source: "rule 3 of Origin.UserMapping"}, mapping)
+	})
+
+	t.Run("mapfile-rules", func(t *testing.T) {
+		mapping, err := MapTokenToUser(newToken(t, "carol", "/atlas"), "/data/atlas/file", rules)
+		require.NoError(t, err)
+		assert.Equal(t, "atlasprod", mapping.User)
+		assert.Equal(t, "rule 2 of the name mapfile "+mapfile, mapping.Source)
+
+		// Ignored rules never match
+		_, err = MapTokenToUser(newToken(t, "ignored"), "/data/file", rules)
+		assert.Error(t, err)
+	})
+
+	t.Run("fallbacks", func(t *testing.T) {
+		tok := newToken(t, "dave")
+		require.NoError(t, tok.Set("uid", "dave-local"))
+		viper.Set("Origin.ScitokensUsernameClaim", "uid")
+		mapping, err := MapTokenToUser(tok, "/data/file", rules)
+		require.NoError(t, err)
+		assert.Equal(t, "dave-local", mapping.User)
+		viper.Set("Origin.ScitokensUsernameClaim", "")
+
+		viper.Set("Origin.ScitokensMapSubject", true)
+		mapping, err = MapTokenToUser(tok, "/data/file", rules)
+		require.NoError(t, err)
+		assert.Equal(t, "dave", mapping.User)
+		viper.Set("Origin.ScitokensMapSubject", false)
+
+		viper.Set("Origin.ScitokensDefaultUser", "guest")
+		mapping, err = MapTokenToUser(tok, "/data/file", rules)
+		require.NoError(t, err)
+		assert.Equal(t, UserMapping{User: "guest", Source: "Origin.ScitokensDefaultUser"}, mapping)
+	})
+
+	t.Run("invalid-rules", func(t *testing.T) {
+		viper.Set("Origin.UserMapping", []map[string]interface{}{{"Subject": "alice"}})
+		_, err := GetUserMappingRules()
+		assert.ErrorContains(t, err, "no user to map to")
+
+		viper.Set("Origin.UserMapping", []map[string]interface{}{{"Path": "data", "User": "alice"}})
+		_, err = GetUserMappingRules()
+		assert.ErrorContains(t, err, "not absolute")
+	})
+}
+
+func TestGetNewFileOwnership(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("setgid directories are not supported on Windows")
+	}
+	current, err := user.Current()
+	require.NoError(t, err)
+	uid, err := strconv.Atoi(current.Uid)
+	require.NoError(t, err)
+	gid, err := strconv.Atoi(current.Gid)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	ownership, err := GetNewFileOwnership(current.Username, filepath.Join(dir, "missing", "file"))
+	require.NoError(t, err)
+	assert.Equal(t, FileOwnership{Uid: uid, Gid: gid}, ownership)
+
+	shared := filepath.Join(dir, "shared")
+	require.NoError(t, os.Mkdir(shared, 0755))
+	require.NoError(t, os.Chmod(shared, 0755|os.ModeSetgid))
+	ownership, err = GetNewFileOwnership(current.Username, filepath.Join(shared, "subdir", "file"))
+	require.NoError(t, err)
+	assert.Equal(t, shared, ownership.SetgidDir)
+	assert.Equal(t, uid, ownership.Uid)
+
+	_, err = GetNewFileOwnership("no-such-user-for-pelican", "")
+	assert.Error(t, err)
+}
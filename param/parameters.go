@@ -126,16 +126,31 @@ var (
 	Cache_RunLocation = StringParam{"Cache.RunLocation"}
 	Cache_SentinelLocation = StringParam{"Cache.SentinelLocation"}
 	Cache_Url = StringParam{"Cache.Url"}
+	Cache_WriteBackLocation = StringParam{"Cache.WriteBackLocation"}
 	Cache_XRootDPrefix = StringParam{"Cache.XRootDPrefix"}
+	Client_AnnouncementsLocation = StringParam{"Client.AnnouncementsLocation"}
+	Client_ManagedConfigKeys = StringParam{"Client.ManagedConfigKeys"}
+	Client_ManagedConfigLocation = StringParam{"Client.ManagedConfigLocation"}
+	Client_ManagedConfigUrl = StringParam{"Client.ManagedConfigUrl"}
+	Client_PostTransferWebhook = StringParam{"Client.PostTransferWebhook"}
+	Client_PreTransferWebhook = StringParam{"Client.PreTransferWebhook"}
+	Client_TransferJournalLocation = StringParam{"Client.TransferJournalLocation"}
+	Client_UploadJournalLocation = StringParam{"Client.UploadJournalLocation"}
 	Director_CacheSortMethod = StringParam{"Director.CacheSortMethod"}
+	Director_DbLocation = StringParam{"Director.DbLocation"}
 	Director_DefaultResponse = StringParam{"Director.DefaultResponse"}
 	Director_GeoIPLocation = StringParam{"Director.GeoIPLocation"}
 	Director_MaxMindKeyFile = StringParam{"Director.MaxMindKeyFile"}
+	Director_OriginWritePolicy = StringParam{"Director.OriginWritePolicy"}
+	Director_RTTProbeUrl = StringParam{"Director.RTTProbeUrl"}
 	Director_SupportContactEmail = StringParam{"Director.SupportContactEmail"}
 	Director_SupportContactUrl = StringParam{"Director.SupportContactUrl"}
+	Director_TopologyPrecedence = StringParam{"Director.TopologyPrecedence"}
+	Director_TrustBundleCAFile = StringParam{"Director.TrustBundleCAFile"}
 	Federation_DiscoveryUrl = StringParam{"Federation.DiscoveryUrl"}
 	Federation_TopologyNamespaceUrl = StringParam{"Federation.TopologyNamespaceUrl"}
 	Federation_TopologyUrl = StringParam{"Federation.TopologyUrl"}
+	Federation_TrustBundleLocation = StringParam{"Federation.TrustBundleLocation"}
 	IssuerKey = StringParam{"IssuerKey"}
 	Issuer_AuthenticationSource = StringParam{"Issuer.AuthenticationSource"}
 	Issuer_GroupFile = StringParam{"Issuer.GroupFile"}
@@ -169,6 +184,15 @@ var (
 	Lotman_DbLocation = StringParam{"Lotman.DbLocation"}
 	Lotman_LibLocation = StringParam{"Lotman.LibLocation"}
 	Monitoring_DataLocation = StringParam{"Monitoring.DataLocation"}
+	Monitoring_GeoIPASNLocation = StringParam{"Monitoring.GeoIPASNLocation"}
+	Monitoring_HistoryDbLocation = StringParam{"Monitoring.HistoryDbLocation"}
+	Monitoring_MessageBusExchange = StringParam{"Monitoring.MessageBusExchange"}
+	Monitoring_MessageBusPasswordFile = StringParam{"Monitoring.MessageBusPasswordFile"}
+	Monitoring_MessageBusTopic = StringParam{"Monitoring.MessageBusTopic"}
+	Monitoring_MessageBusUrl = StringParam{"Monitoring.MessageBusUrl"}
+	Monitoring_OTLP_Endpoint = StringParam{"Monitoring.OTLP.Endpoint"}
+	Monitoring_PacketSpoolLocation = StringParam{"Monitoring.PacketSpoolLocation"}
+	Monitoring_ProjectClaim = StringParam{"Monitoring.ProjectClaim"}
 	OIDC_AuthorizationEndpoint = StringParam{"OIDC.AuthorizationEndpoint"}
 	OIDC_ClientID = StringParam{"OIDC.ClientID"}
 	OIDC_ClientIDFile = StringParam{"OIDC.ClientIDFile"}
@@ -176,8 +200,14 @@ var (
 	OIDC_ClientSecretFile = StringParam{"OIDC.ClientSecretFile"}
 	OIDC_DeviceAuthEndpoint = StringParam{"OIDC.DeviceAuthEndpoint"}
 	OIDC_Issuer = StringParam{"OIDC.Issuer"}
+	OIDC_TLSCACertificateFile = StringParam{"OIDC.TLSCACertificateFile"}
+	OIDC_TLSClientCertificateFile = StringParam{"OIDC.TLSClientCertificateFile"}
+	OIDC_TLSClientKeyFile = StringParam{"OIDC.TLSClientKeyFile"}
 	OIDC_TokenEndpoint = StringParam{"OIDC.TokenEndpoint"}
 	OIDC_UserInfoEndpoint = StringParam{"OIDC.UserInfoEndpoint"}
+	Origin_BackupDestination = StringParam{"Origin.BackupDestination"}
+	Origin_BackupLocation = StringParam{"Origin.BackupLocation"}
+	Origin_CatalogInventoryFile = StringParam{"Origin.CatalogInventoryFile"}
 	Origin_DbLocation = StringParam{"Origin.DbLocation"}
 	Origin_ExportVolume = StringParam{"Origin.ExportVolume"}
 	Origin_FederationPrefix = StringParam{"Origin.FederationPrefix"}
@@ -186,7 +216,19 @@ var (
 	Origin_GlobusCollectionID = StringParam{"Origin.GlobusCollectionID"}
 	Origin_GlobusCollectionName = StringParam{"Origin.GlobusCollectionName"}
 	Origin_GlobusConfigLocation = StringParam{"Origin.GlobusConfigLocation"}
+	Origin_HttpAuthMethod = StringParam{"Origin.HttpAuthMethod"}
+	Origin_HttpBasicAuthPasswordFile = StringParam{"Origin.HttpBasicAuthPasswordFile"}
+	Origin_HttpBasicAuthUsername = StringParam{"Origin.HttpBasicAuthUsername"}
+	Origin_HttpBearerTokenFile = StringParam{"Origin.HttpBearerTokenFile"}
+	Origin_HttpClientCertFile = StringParam{"Origin.HttpClientCertFile"}
+	Origin_HttpClientKeyFile = StringParam{"Origin.HttpClientKeyFile"}
 	Origin_HttpServiceUrl = StringParam{"Origin.HttpServiceUrl"}
+	Origin_HttpTapeRestApiUrl = StringParam{"Origin.HttpTapeRestApiUrl"}
+	Origin_HttpTokenExchangeAudience = StringParam{"Origin.HttpTokenExchangeAudience"}
+	Origin_HttpTokenExchangeClientID = StringParam{"Origin.HttpTokenExchangeClientID"}
+	Origin_HttpTokenExchangeClientSecretFile = StringParam{"Origin.HttpTokenExchangeClientSecretFile"}
+	Origin_HttpTokenExchangeScope = StringParam{"Origin.HttpTokenExchangeScope"}
+	Origin_HttpTokenExchangeUrl = StringParam{"Origin.HttpTokenExchangeUrl"}
 	Origin_Mode = StringParam{"Origin.Mode"}
 	Origin_NamespacePrefix = StringParam{"Origin.NamespacePrefix"}
 	Origin_RunLocation = StringParam{"Origin.RunLocation"}
@@ -202,17 +244,25 @@ var (
 	Origin_ScitokensUsernameClaim = StringParam{"Origin.ScitokensUsernameClaim"}
 	Origin_StoragePrefix = StringParam{"Origin.StoragePrefix"}
 	Origin_StorageType = StringParam{"Origin.StorageType"}
+	Origin_UploadStagingLocation = StringParam{"Origin.UploadStagingLocation"}
 	Origin_Url = StringParam{"Origin.Url"}
 	Origin_XRootDPrefix = StringParam{"Origin.XRootDPrefix"}
 	Origin_XRootServiceUrl = StringParam{"Origin.XRootServiceUrl"}
+	Plugin_JournalLocation = StringParam{"Plugin.JournalLocation"}
 	Plugin_Token = StringParam{"Plugin.Token"}
 	Registry_DbLocation = StringParam{"Registry.DbLocation"}
 	Registry_InstitutionsUrl = StringParam{"Registry.InstitutionsUrl"}
+	Registry_MirrorOf = StringParam{"Registry.MirrorOf"}
 	Server_ExternalWebUrl = StringParam{"Server.ExternalWebUrl"}
 	Server_Hostname = StringParam{"Server.Hostname"}
 	Server_IssuerHostname = StringParam{"Server.IssuerHostname"}
 	Server_IssuerJwks = StringParam{"Server.IssuerJwks"}
 	Server_IssuerUrl = StringParam{"Server.IssuerUrl"}
+	Server_NotificationDbLocation = StringParam{"Server.NotificationDbLocation"}
+	Server_NotificationSmtpFrom = StringParam{"Server.NotificationSmtpFrom"}
+	Server_NotificationSmtpPasswordFile = StringParam{"Server.NotificationSmtpPasswordFile"}
+	Server_NotificationSmtpServer = StringParam{"Server.NotificationSmtpServer"}
+	Server_NotificationSmtpUsername = StringParam{"Server.NotificationSmtpUsername"}
 	Server_SessionSecretFile = StringParam{"Server.SessionSecretFile"}
 	Server_TLSCACertificateDirectory = StringParam{"Server.TLSCACertificateDirectory"}
 	Server_TLSCACertificateFile = StringParam{"Server.TLSCACertificateFile"}
@@ -220,6 +270,7 @@ var (
 	Server_TLSCertificate = StringParam{"Server.TLSCertificate"}
 	Server_TLSKey = StringParam{"Server.TLSKey"}
 	Server_UIActivationCodeFile = StringParam{"Server.UIActivationCodeFile"}
+	Server_UIAuthDbLocation = StringParam{"Server.UIAuthDbLocation"}
 	Server_UIPasswordFile = StringParam{"Server.UIPasswordFile"}
 	Server_WebConfigFile = StringParam{"Server.WebConfigFile"}
 	Server_WebHost = StringParam{"Server.WebHost"}
@@ -254,16 +305,27 @@ var (
 	Cache_DataLocations = StringSliceParam{"Cache.DataLocations"}
 	Cache_MetaLocations = StringSliceParam{"Cache.MetaLocations"}
 	Cache_PermittedNamespaces = StringSliceParam{"Cache.PermittedNamespaces"}
+	Client_PostTransferCommand = StringSliceParam{"Client.PostTransferCommand"}
+	Client_PreTransferCommand = StringSliceParam{"Client.PreTransferCommand"}
 	ConfigLocations = StringSliceParam{"ConfigLocations"}
 	Director_CacheResponseHostnames = StringSliceParam{"Director.CacheResponseHostnames"}
 	Director_FilteredServers = StringSliceParam{"Director.FilteredServers"}
 	Director_OriginResponseHostnames = StringSliceParam{"Director.OriginResponseHostnames"}
+	Director_PrimaryWriteOrigins = StringSliceParam{"Director.PrimaryWriteOrigins"}
+	Director_TopologyNamespaceUrls = StringSliceParam{"Director.TopologyNamespaceUrls"}
+	Issuer_DeviceTrustedNetworks = StringSliceParam{"Issuer.DeviceTrustedNetworks"}
 	Issuer_GroupRequirements = StringSliceParam{"Issuer.GroupRequirements"}
 	Monitoring_AggregatePrefixes = StringSliceParam{"Monitoring.AggregatePrefixes"}
+	Monitoring_ForwardDestinations = StringSliceParam{"Monitoring.ForwardDestinations"}
+	Monitoring_TransferDurationBuckets = StringSliceParam{"Monitoring.TransferDurationBuckets"}
+	Monitoring_TransferSizeBuckets = StringSliceParam{"Monitoring.TransferSizeBuckets"}
+	Origin_AnonymousRateLimitTrustedNetworks = StringSliceParam{"Origin.AnonymousRateLimitTrustedNetworks"}
 	Origin_ExportVolumes = StringSliceParam{"Origin.ExportVolumes"}
 	Origin_ScitokensRestrictedPaths = StringSliceParam{"Origin.ScitokensRestrictedPaths"}
 	Registry_AdminUsers = StringSliceParam{"Registry.AdminUsers"}
 	Server_Modules = StringSliceParam{"Server.Modules"}
+	Server_NotificationDigestRecipients = StringSliceParam{"Server.NotificationDigestRecipients"}
+	Server_RequiredClientFeatures = StringSliceParam{"Server.RequiredClientFeatures"}
 	Server_UIAdminUsers = StringSliceParam{"Server.UIAdminUsers"}
 	Shoveler_OutputDestinations = StringSliceParam{"Shoveler.OutputDestinations"}
 )
@@ -271,24 +333,49 @@ var (
 var (
 	Cache_Concurrency = IntParam{"Cache.Concurrency"}
 	Cache_Port = IntParam{"Cache.Port"}
+	Cache_WriteBackMaxUploadSize = IntParam{"Cache.WriteBackMaxUploadSize"}
 	Client_MaximumDownloadSpeed = IntParam{"Client.MaximumDownloadSpeed"}
 	Client_MinimumDownloadSpeed = IntParam{"Client.MinimumDownloadSpeed"}
+	Client_MultiSourceChunkSize = IntParam{"Client.MultiSourceChunkSize"}
+	Client_MultiSourceMaxSources = IntParam{"Client.MultiSourceMaxSources"}
+	Client_MultiSourceMinimumSize = IntParam{"Client.MultiSourceMinimumSize"}
+	Client_SmallFileThreshold = IntParam{"Client.SmallFileThreshold"}
+	Client_TransferJournalMaxEntries = IntParam{"Client.TransferJournalMaxEntries"}
+	Client_UploadChunkSize = IntParam{"Client.UploadChunkSize"}
 	Client_WorkerCount = IntParam{"Client.WorkerCount"}
+	Director_AdValidationRateLimit = IntParam{"Director.AdValidationRateLimit"}
+	Director_CacheRegionCount = IntParam{"Director.CacheRegionCount"}
+	Director_CacheRegionRadius = IntParam{"Director.CacheRegionRadius"}
+	Director_GeoIPMaxAccuracyRadius = IntParam{"Director.GeoIPMaxAccuracyRadius"}
+	Director_MaxBatchResolvePaths = IntParam{"Director.MaxBatchResolvePaths"}
 	Director_MaxStatResponse = IntParam{"Director.MaxStatResponse"}
 	Director_MinStatResponse = IntParam{"Director.MinStatResponse"}
+	Director_MirrorCheckSampleSize = IntParam{"Director.MirrorCheckSampleSize"}
+	Director_RedirectLogSize = IntParam{"Director.RedirectLogSize"}
+	Director_SortDistanceWeight = IntParam{"Director.SortDistanceWeight"}
+	Director_SortLoadWeight = IntParam{"Director.SortLoadWeight"}
 	Director_StatConcurrencyLimit = IntParam{"Director.StatConcurrencyLimit"}
+	Issuer_DeviceAuthRateLimit = IntParam{"Issuer.DeviceAuthRateLimit"}
 	LocalCache_HighWaterMarkPercentage = IntParam{"LocalCache.HighWaterMarkPercentage"}
 	LocalCache_LowWaterMarkPercentage = IntParam{"LocalCache.LowWaterMarkPercentage"}
 	MinimumDownloadSpeed = IntParam{"MinimumDownloadSpeed"}
+	Monitoring_AuthFailureLogSize = IntParam{"Monitoring.AuthFailureLogSize"}
+	Monitoring_MessageBusQueueSize = IntParam{"Monitoring.MessageBusQueueSize"}
+	Monitoring_PacketSpoolSize = IntParam{"Monitoring.PacketSpoolSize"}
 	Monitoring_PortHigher = IntParam{"Monitoring.PortHigher"}
 	Monitoring_PortLower = IntParam{"Monitoring.PortLower"}
+	Origin_BackupRetention = IntParam{"Origin.BackupRetention"}
+	Origin_ExportAuditSampleSize = IntParam{"Origin.ExportAuditSampleSize"}
 	Origin_Port = IntParam{"Origin.Port"}
+	Origin_S3MonthlyRequestBudget = IntParam{"Origin.S3MonthlyRequestBudget"}
+	Server_IOCapacity = IntParam{"Server.IOCapacity"}
 	Server_IssuerPort = IntParam{"Server.IssuerPort"}
 	Server_UILoginRateLimit = IntParam{"Server.UILoginRateLimit"}
 	Server_WebPort = IntParam{"Server.WebPort"}
 	Shoveler_PortHigher = IntParam{"Shoveler.PortHigher"}
 	Shoveler_PortLower = IntParam{"Shoveler.PortLower"}
 	Transport_MaxIdleConns = IntParam{"Transport.MaxIdleConns"}
+	Transport_MaxIdleConnsPerHost = IntParam{"Transport.MaxIdleConnsPerHost"}
 	Xrootd_DetailedMonitoringPort = IntParam{"Xrootd.DetailedMonitoringPort"}
 	Xrootd_ManagerPort = IntParam{"Xrootd.ManagerPort"}
 	Xrootd_Port = IntParam{"Xrootd.Port"}
@@ -299,21 +386,37 @@ var (
 	Cache_EnableLotman = BoolParam{"Cache.EnableLotman"}
 	Cache_EnableOIDC = BoolParam{"Cache.EnableOIDC"}
 	Cache_EnableVoms = BoolParam{"Cache.EnableVoms"}
+	Cache_EnableWriteBack = BoolParam{"Cache.EnableWriteBack"}
 	Cache_SelfTest = BoolParam{"Cache.SelfTest"}
+	Client_DisableAnnouncements = BoolParam{"Client.DisableAnnouncements"}
 	Client_DisableHttpProxy = BoolParam{"Client.DisableHttpProxy"}
 	Client_DisableProxyFallback = BoolParam{"Client.DisableProxyFallback"}
+	Client_DisableTransferJournal = BoolParam{"Client.DisableTransferJournal"}
+	Client_EnableMultiSourceDownload = BoolParam{"Client.EnableMultiSourceDownload"}
+	Client_VerifyServerIdentity = BoolParam{"Client.VerifyServerIdentity"}
+	Client_WaitForTapeStaging = BoolParam{"Client.WaitForTapeStaging"}
 	Debug = BoolParam{"Debug"}
+	Director_AdValidationAllowPrivateAddresses = BoolParam{"Director.AdValidationAllowPrivateAddresses"}
+	Director_DeprioritizeExpiringCerts = BoolParam{"Director.DeprioritizeExpiringCerts"}
 	Director_EnableBroker = BoolParam{"Director.EnableBroker"}
 	Director_EnableOIDC = BoolParam{"Director.EnableOIDC"}
+	Director_EstimateObjectAvailability = BoolParam{"Director.EstimateObjectAvailability"}
 	DisableHttpProxy = BoolParam{"DisableHttpProxy"}
 	DisableProxyFallback = BoolParam{"DisableProxyFallback"}
+	Federation_EnableDNSDiscovery = BoolParam{"Federation.EnableDNSDiscovery"}
+	Issuer_DeviceApprovalRequired = BoolParam{"Issuer.DeviceApprovalRequired"}
 	Issuer_UserStripDomain = BoolParam{"Issuer.UserStripDomain"}
 	Logging_DisableProgressBars = BoolParam{"Logging.DisableProgressBars"}
 	Lotman_EnableAPI = BoolParam{"Lotman.EnableAPI"}
+	Monitoring_GeoIPLabels = BoolParam{"Monitoring.GeoIPLabels"}
 	Monitoring_MetricAuthorization = BoolParam{"Monitoring.MetricAuthorization"}
 	Monitoring_PromQLAuthorization = BoolParam{"Monitoring.PromQLAuthorization"}
+	Origin_EnableBackups = BoolParam{"Origin.EnableBackups"}
 	Origin_EnableBroker = BoolParam{"Origin.EnableBroker"}
+	Origin_EnableCatalogStats = BoolParam{"Origin.EnableCatalogStats"}
 	Origin_EnableCmsd = BoolParam{"Origin.EnableCmsd"}
+	Origin_EnableContentTypes = BoolParam{"Origin.EnableContentTypes"}
+	Origin_EnableDatasetStats = BoolParam{"Origin.EnableDatasetStats"}
 	Origin_EnableDirListing = BoolParam{"Origin.EnableDirListing"}
 	Origin_EnableDirectReads = BoolParam{"Origin.EnableDirectReads"}
 	Origin_EnableFallbackRead = BoolParam{"Origin.EnableFallbackRead"}
@@ -323,37 +426,74 @@ var (
 	Origin_EnableOIDC = BoolParam{"Origin.EnableOIDC"}
 	Origin_EnablePublicReads = BoolParam{"Origin.EnablePublicReads"}
 	Origin_EnableReads = BoolParam{"Origin.EnableReads"}
+	Origin_EnableResumableUploads = BoolParam{"Origin.EnableResumableUploads"}
 	Origin_EnableUI = BoolParam{"Origin.EnableUI"}
 	Origin_EnableVoms = BoolParam{"Origin.EnableVoms"}
 	Origin_EnableWrite = BoolParam{"Origin.EnableWrite"}
 	Origin_EnableWrites = BoolParam{"Origin.EnableWrites"}
+	Origin_ExportAudit = BoolParam{"Origin.ExportAudit"}
 	Origin_Multiuser = BoolParam{"Origin.Multiuser"}
+	Origin_S3EnableRequestTracking = BoolParam{"Origin.S3EnableRequestTracking"}
 	Origin_ScitokensMapSubject = BoolParam{"Origin.ScitokensMapSubject"}
 	Origin_SelfTest = BoolParam{"Origin.SelfTest"}
+	Origin_SniffContentTypes = BoolParam{"Origin.SniffContentTypes"}
+	Origin_StorageWriteCheck = BoolParam{"Origin.StorageWriteCheck"}
 	Registry_RequireCacheApproval = BoolParam{"Registry.RequireCacheApproval"}
 	Registry_RequireKeyChaining = BoolParam{"Registry.RequireKeyChaining"}
 	Registry_RequireOriginApproval = BoolParam{"Registry.RequireOriginApproval"}
+	Server_EnablePublicStatus = BoolParam{"Server.EnablePublicStatus"}
 	Server_EnableUI = BoolParam{"Server.EnableUI"}
+	Server_UIEnableWebAuthn = BoolParam{"Server.UIEnableWebAuthn"}
 	Shoveler_Enable = BoolParam{"Shoveler.Enable"}
 	Shoveler_VerifyHeader = BoolParam{"Shoveler.VerifyHeader"}
 	StagePlugin_Hook = BoolParam{"StagePlugin.Hook"}
 	TLSSkipVerify = BoolParam{"TLSSkipVerify"}
+	Transport_EnableHTTP2 = BoolParam{"Transport.EnableHTTP2"}
 )
 
 var (
 	Cache_SelfTestInterval = DurationParam{"Cache.SelfTestInterval"}
+	Cache_WriteBackRetryLimit = DurationParam{"Cache.WriteBackRetryLimit"}
 	Client_SlowTransferRampupTime = DurationParam{"Client.SlowTransferRampupTime"}
 	Client_SlowTransferWindow = DurationParam{"Client.SlowTransferWindow"}
 	Client_StoppedTransferTimeout = DurationParam{"Client.StoppedTransferTimeout"}
+	Client_TapeStageTimeout = DurationParam{"Client.TapeStageTimeout"}
+	Client_TransferHookTimeout = DurationParam{"Client.TransferHookTimeout"}
+	Client_TransferJournalRetention = DurationParam{"Client.TransferJournalRetention"}
 	Director_AdvertisementTTL = DurationParam{"Director.AdvertisementTTL"}
+	Director_CertExpiryWarningWindow = DurationParam{"Director.CertExpiryWarningWindow"}
+	Director_DowntimePreDrainDuration = DurationParam{"Director.DowntimePreDrainDuration"}
+	Director_HealthTestMaxInterval = DurationParam{"Director.HealthTestMaxInterval"}
+	Director_HealthTestMinInterval = DurationParam{"Director.HealthTestMinInterval"}
+	Director_IOLoadQueryInterval = DurationParam{"Director.IOLoadQueryInterval"}
+	Director_MirrorCheckInterval = DurationParam{"Director.MirrorCheckInterval"}
+	Director_ObjectAvailabilityTTL = DurationParam{"Director.ObjectAvailabilityTTL"}
 	Director_OriginCacheHealthTestInterval = DurationParam{"Director.OriginCacheHealthTestInterval"}
+	Director_RTTProbeTimeout = DurationParam{"Director.RTTProbeTimeout"}
 	Director_StatTimeout = DurationParam{"Director.StatTimeout"}
+	Director_TrustBundleLifetime = DurationParam{"Director.TrustBundleLifetime"}
 	Federation_TopologyReloadInterval = DurationParam{"Federation.TopologyReloadInterval"}
+	LocalCache_MaxStaleness = DurationParam{"LocalCache.MaxStaleness"}
+	LocalCache_RevalidateInterval = DurationParam{"LocalCache.RevalidateInterval"}
+	Monitoring_HistoryInterval = DurationParam{"Monitoring.HistoryInterval"}
+	Monitoring_HistoryRetention = DurationParam{"Monitoring.HistoryRetention"}
+	Monitoring_OTLP_Interval = DurationParam{"Monitoring.OTLP.Interval"}
 	Monitoring_TokenExpiresIn = DurationParam{"Monitoring.TokenExpiresIn"}
 	Monitoring_TokenRefreshInterval = DurationParam{"Monitoring.TokenRefreshInterval"}
+	Origin_BackupInterval = DurationParam{"Origin.BackupInterval"}
+	Origin_CatalogStatsInterval = DurationParam{"Origin.CatalogStatsInterval"}
+	Origin_DatasetStatsRetention = DurationParam{"Origin.DatasetStatsRetention"}
+	Origin_ExportAuditInterval = DurationParam{"Origin.ExportAuditInterval"}
+	Origin_S3MaxThrottleBackoff = DurationParam{"Origin.S3MaxThrottleBackoff"}
 	Origin_SelfTestInterval = DurationParam{"Origin.SelfTestInterval"}
+	Origin_StorageWriteCheckInterval = DurationParam{"Origin.StorageWriteCheckInterval"}
+	Origin_TapeStageRetryAfter = DurationParam{"Origin.TapeStageRetryAfter"}
+	Origin_UploadStagingTTL = DurationParam{"Origin.UploadStagingTTL"}
 	Registry_InstitutionsUrlReloadMinutes = DurationParam{"Registry.InstitutionsUrlReloadMinutes"}
+	Registry_MirrorInterval = DurationParam{"Registry.MirrorInterval"}
+	Server_NotificationDigestInterval = DurationParam{"Server.NotificationDigestInterval"}
 	Server_RegistrationRetryInterval = DurationParam{"Server.RegistrationRetryInterval"}
+	Server_UIBootstrapTokenLifetime = DurationParam{"Server.UIBootstrapTokenLifetime"}
 	Transport_DialerKeepAlive = DurationParam{"Transport.DialerKeepAlive"}
 	Transport_DialerTimeout = DurationParam{"Transport.DialerTimeout"}
 	Transport_ExpectContinueTimeout = DurationParam{"Transport.ExpectContinueTimeout"}
@@ -363,12 +503,26 @@ var (
 )
 
 var (
+	Director_Announcements = ObjectParam{"Director.Announcements"}
+	Director_CacheRegions = ObjectParam{"Director.CacheRegions"}
+	Director_ClientLocationMap = ObjectParam{"Director.ClientLocationMap"}
+	Director_RedirectRules = ObjectParam{"Director.RedirectRules"}
 	GeoIPOverrides = ObjectParam{"GeoIPOverrides"}
 	Issuer_AuthorizationTemplates = ObjectParam{"Issuer.AuthorizationTemplates"}
 	Issuer_OIDCAuthenticationRequirements = ObjectParam{"Issuer.OIDCAuthenticationRequirements"}
 	Lotman_Lots = ObjectParam{"Lotman.Lots"}
+	Monitoring_HistoryQueries = ObjectParam{"Monitoring.HistoryQueries"}
+	Monitoring_MetricRelabelRules = ObjectParam{"Monitoring.MetricRelabelRules"}
+	Monitoring_OTLP_Headers = ObjectParam{"Monitoring.OTLP.Headers"}
+	Monitoring_TransferLabelActions = ObjectParam{"Monitoring.TransferLabelActions"}
+	Origin_AnonymousRateLimits = ObjectParam{"Origin.AnonymousRateLimits"}
+	Origin_ContentTypes = ObjectParam{"Origin.ContentTypes"}
 	Origin_Exports = ObjectParam{"Origin.Exports"}
+	Origin_HttpRequestHeaders = ObjectParam{"Origin.HttpRequestHeaders"}
+	Origin_HttpResponseHeaders = ObjectParam{"Origin.HttpResponseHeaders"}
+	Origin_UserMapping = ObjectParam{"Origin.UserMapping"}
 	Registry_CustomRegistrationFields = ObjectParam{"Registry.CustomRegistrationFields"}
 	Registry_Institutions = ObjectParam{"Registry.Institutions"}
 	Shoveler_IPMapping = ObjectParam{"Shoveler.IPMapping"}
+	Transport_TLSDestinations = ObjectParam{"Transport.TLSDestinations"}
 )
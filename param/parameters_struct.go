@@ -31,6 +31,7 @@ type Config struct {
 		EnableLotman bool `mapstructure:"enablelotman"`
 		EnableOIDC bool `mapstructure:"enableoidc"`
 		EnableVoms bool `mapstructure:"enablevoms"`
+		EnableWriteBack bool `mapstructure:"enablewriteback"`
 		ExportLocation string `mapstructure:"exportlocation"`
 		HighWaterMark string `mapstructure:"highwatermark"`
 		LocalRoot string `mapstructure:"localroot"`
@@ -43,39 +44,98 @@ type Config struct {
 		SelfTestInterval time.Duration `mapstructure:"selftestinterval"`
 		SentinelLocation string `mapstructure:"sentinellocation"`
 		Url string `mapstructure:"url"`
+		WriteBackLocation string `mapstructure:"writebacklocation"`
+		WriteBackMaxUploadSize int `mapstructure:"writebackmaxuploadsize"`
+		WriteBackRetryLimit time.Duration `mapstructure:"writebackretrylimit"`
 		XRootDPrefix string `mapstructure:"xrootdprefix"`
 	} `mapstructure:"cache"`
 	Client struct {
+		AnnouncementsLocation string `mapstructure:"announcementslocation"`
+		DisableAnnouncements bool `mapstructure:"disableannouncements"`
 		DisableHttpProxy bool `mapstructure:"disablehttpproxy"`
 		DisableProxyFallback bool `mapstructure:"disableproxyfallback"`
+		DisableTransferJournal bool `mapstructure:"disabletransferjournal"`
+		EnableMultiSourceDownload bool `mapstructure:"enablemultisourcedownload"`
+		ManagedConfigKeys string `mapstructure:"managedconfigkeys"`
+		ManagedConfigLocation string `mapstructure:"managedconfiglocation"`
+		ManagedConfigUrl string `mapstructure:"managedconfigurl"`
 		MaximumDownloadSpeed int `mapstructure:"maximumdownloadspeed"`
 		MinimumDownloadSpeed int `mapstructure:"minimumdownloadspeed"`
+		MultiSourceChunkSize int `mapstructure:"multisourcechunksize"`
+		MultiSourceMaxSources int `mapstructure:"multisourcemaxsources"`
+		MultiSourceMinimumSize int `mapstructure:"multisourceminimumsize"`
+		PostTransferCommand []string `mapstructure:"posttransfercommand"`
+		PostTransferWebhook string `mapstructure:"posttransferwebhook"`
+		PreTransferCommand []string `mapstructure:"pretransfercommand"`
+		PreTransferWebhook string `mapstructure:"pretransferwebhook"`
 		SlowTransferRampupTime time.Duration `mapstructure:"slowtransferrampuptime"`
 		SlowTransferWindow time.Duration `mapstructure:"slowtransferwindow"`
+		SmallFileThreshold int `mapstructure:"smallfilethreshold"`
 		StoppedTransferTimeout time.Duration `mapstructure:"stoppedtransfertimeout"`
+		TapeStageTimeout time.Duration `mapstructure:"tapestagetimeout"`
+		TransferHookTimeout time.Duration `mapstructure:"transferhooktimeout"`
+		TransferJournalLocation string `mapstructure:"transferjournallocation"`
+		TransferJournalMaxEntries int `mapstructure:"transferjournalmaxentries"`
+		TransferJournalRetention time.Duration `mapstructure:"transferjournalretention"`
+		UploadChunkSize int `mapstructure:"uploadchunksize"`
+		UploadJournalLocation string `mapstructure:"uploadjournallocation"`
+		VerifyServerIdentity bool `mapstructure:"verifyserveridentity"`
+		WaitForTapeStaging bool `mapstructure:"waitfortapestaging"`
 		WorkerCount int `mapstructure:"workercount"`
 	} `mapstructure:"client"`
 	ConfigDir string `mapstructure:"configdir"`
 	ConfigLocations []string `mapstructure:"configlocations"`
 	Debug bool `mapstructure:"debug"`
 	Director struct {
+		AdValidationAllowPrivateAddresses bool `mapstructure:"advalidationallowprivateaddresses"`
+		AdValidationRateLimit int `mapstructure:"advalidationratelimit"`
 		AdvertisementTTL time.Duration `mapstructure:"advertisementttl"`
+		Announcements interface{} `mapstructure:"announcements"`
+		CacheRegionCount int `mapstructure:"cacheregioncount"`
+		CacheRegionRadius int `mapstructure:"cacheregionradius"`
+		CacheRegions interface{} `mapstructure:"cacheregions"`
 		CacheResponseHostnames []string `mapstructure:"cacheresponsehostnames"`
 		CacheSortMethod string `mapstructure:"cachesortmethod"`
+		CertExpiryWarningWindow time.Duration `mapstructure:"certexpirywarningwindow"`
+		ClientLocationMap interface{} `mapstructure:"clientlocationmap"`
+		DbLocation string `mapstructure:"dblocation"`
 		DefaultResponse string `mapstructure:"defaultresponse"`
+		DeprioritizeExpiringCerts bool `mapstructure:"deprioritizeexpiringcerts"`
+		DowntimePreDrainDuration time.Duration `mapstructure:"downtimepredrainduration"`
 		EnableBroker bool `mapstructure:"enablebroker"`
 		EnableOIDC bool `mapstructure:"enableoidc"`
+		EstimateObjectAvailability bool `mapstructure:"estimateobjectavailability"`
 		FilteredServers []string `mapstructure:"filteredservers"`
 		GeoIPLocation string `mapstructure:"geoiplocation"`
+		GeoIPMaxAccuracyRadius int `mapstructure:"geoipmaxaccuracyradius"`
+		HealthTestMaxInterval time.Duration `mapstructure:"healthtestmaxinterval"`
+		HealthTestMinInterval time.Duration `mapstructure:"healthtestmininterval"`
+		IOLoadQueryInterval time.Duration `mapstructure:"ioloadqueryinterval"`
+		MaxBatchResolvePaths int `mapstructure:"maxbatchresolvepaths"`
 		MaxMindKeyFile string `mapstructure:"maxmindkeyfile"`
 		MaxStatResponse int `mapstructure:"maxstatresponse"`
 		MinStatResponse int `mapstructure:"minstatresponse"`
+		MirrorCheckInterval time.Duration `mapstructure:"mirrorcheckinterval"`
+		MirrorCheckSampleSize int `mapstructure:"mirrorchecksamplesize"`
+		ObjectAvailabilityTTL time.Duration `mapstructure:"objectavailabilityttl"`
 		OriginCacheHealthTestInterval time.Duration `mapstructure:"origincachehealthtestinterval"`
 		OriginResponseHostnames []string `mapstructure:"originresponsehostnames"`
+		OriginWritePolicy string `mapstructure:"originwritepolicy"`
+		PrimaryWriteOrigins []string `mapstructure:"primarywriteorigins"`
+		RTTProbeTimeout time.Duration `mapstructure:"rttprobetimeout"`
+		RTTProbeUrl string `mapstructure:"rttprobeurl"`
+		RedirectLogSize int `mapstructure:"redirectlogsize"`
+		RedirectRules interface{} `mapstructure:"redirectrules"`
+		SortDistanceWeight int `mapstructure:"sortdistanceweight"`
+		SortLoadWeight int `mapstructure:"sortloadweight"`
 		StatConcurrencyLimit int `mapstructure:"statconcurrencylimit"`
 		StatTimeout time.Duration `mapstructure:"stattimeout"`
 		SupportContactEmail string `mapstructure:"supportcontactemail"`
 		SupportContactUrl string `mapstructure:"supportcontacturl"`
+		TopologyNamespaceUrls []string `mapstructure:"topologynamespaceurls"`
+		TopologyPrecedence string `mapstructure:"topologyprecedence"`
+		TrustBundleCAFile string `mapstructure:"trustbundlecafile"`
+		TrustBundleLifetime time.Duration `mapstructure:"trustbundlelifetime"`
 	} `mapstructure:"director"`
 	DisableHttpProxy bool `mapstructure:"disablehttpproxy"`
 	DisableProxyFallback bool `mapstructure:"disableproxyfallback"`
@@ -83,16 +143,21 @@ type Config struct {
 		BrokerUrl string `mapstructure:"brokerurl"`
 		DirectorUrl string `mapstructure:"directorurl"`
 		DiscoveryUrl string `mapstructure:"discoveryurl"`
+		EnableDNSDiscovery bool `mapstructure:"enablednsdiscovery"`
 		JwkUrl string `mapstructure:"jwkurl"`
 		RegistryUrl string `mapstructure:"registryurl"`
 		TopologyNamespaceUrl string `mapstructure:"topologynamespaceurl"`
 		TopologyReloadInterval time.Duration `mapstructure:"topologyreloadinterval"`
 		TopologyUrl string `mapstructure:"topologyurl"`
+		TrustBundleLocation string `mapstructure:"trustbundlelocation"`
 	} `mapstructure:"federation"`
 	GeoIPOverrides interface{} `mapstructure:"geoipoverrides"`
 	Issuer struct {
 		AuthenticationSource string `mapstructure:"authenticationsource"`
 		AuthorizationTemplates interface{} `mapstructure:"authorizationtemplates"`
+		DeviceApprovalRequired bool `mapstructure:"deviceapprovalrequired"`
+		DeviceAuthRateLimit int `mapstructure:"deviceauthratelimit"`
+		DeviceTrustedNetworks []string `mapstructure:"devicetrustednetworks"`
 		GroupFile string `mapstructure:"groupfile"`
 		GroupRequirements []string `mapstructure:"grouprequirements"`
 		GroupSource string `mapstructure:"groupsource"`
@@ -110,6 +175,8 @@ type Config struct {
 		DataLocation string `mapstructure:"datalocation"`
 		HighWaterMarkPercentage int `mapstructure:"highwatermarkpercentage"`
 		LowWaterMarkPercentage int `mapstructure:"lowwatermarkpercentage"`
+		MaxStaleness time.Duration `mapstructure:"maxstaleness"`
+		RevalidateInterval time.Duration `mapstructure:"revalidateinterval"`
 		RunLocation string `mapstructure:"runlocation"`
 		Size string `mapstructure:"size"`
 		Socket string `mapstructure:"socket"`
@@ -146,13 +213,38 @@ type Config struct {
 	MinimumDownloadSpeed int `mapstructure:"minimumdownloadspeed"`
 	Monitoring struct {
 		AggregatePrefixes []string `mapstructure:"aggregateprefixes"`
+		AuthFailureLogSize int `mapstructure:"authfailurelogsize"`
 		DataLocation string `mapstructure:"datalocation"`
+		ForwardDestinations []string `mapstructure:"forwarddestinations"`
+		GeoIPASNLocation string `mapstructure:"geoipasnlocation"`
+		GeoIPLabels bool `mapstructure:"geoiplabels"`
+		HistoryDbLocation string `mapstructure:"historydblocation"`
+		HistoryInterval time.Duration `mapstructure:"historyinterval"`
+		HistoryQueries interface{} `mapstructure:"historyqueries"`
+		HistoryRetention time.Duration `mapstructure:"historyretention"`
+		MessageBusExchange string `mapstructure:"messagebusexchange"`
+		MessageBusPasswordFile string `mapstructure:"messagebuspasswordfile"`
+		MessageBusQueueSize int `mapstructure:"messagebusqueuesize"`
+		MessageBusTopic string `mapstructure:"messagebustopic"`
+		MessageBusUrl string `mapstructure:"messagebusurl"`
 		MetricAuthorization bool `mapstructure:"metricauthorization"`
+		MetricRelabelRules interface{} `mapstructure:"metricrelabelrules"`
+		OTLP struct {
+			Endpoint string `mapstructure:"endpoint"`
+			Headers interface{} `mapstructure:"headers"`
+			Interval time.Duration `mapstructure:"interval"`
+		} `mapstructure:"otlp"`
+		PacketSpoolLocation string `mapstructure:"packetspoollocation"`
+		PacketSpoolSize int `mapstructure:"packetspoolsize"`
 		PortHigher int `mapstructure:"porthigher"`
 		PortLower int `mapstructure:"portlower"`
+		ProjectClaim string `mapstructure:"projectclaim"`
 		PromQLAuthorization bool `mapstructure:"promqlauthorization"`
 		TokenExpiresIn time.Duration `mapstructure:"tokenexpiresin"`
 		TokenRefreshInterval time.Duration `mapstructure:"tokenrefreshinterval"`
+		TransferDurationBuckets []string `mapstructure:"transferdurationbuckets"`
+		TransferLabelActions interface{} `mapstructure:"transferlabelactions"`
+		TransferSizeBuckets []string `mapstructure:"transfersizebuckets"`
 	} `mapstructure:"monitoring"`
 	OIDC struct {
 		AuthorizationEndpoint string `mapstructure:"authorizationendpoint"`
@@ -162,13 +254,30 @@ type Config struct {
 		ClientSecretFile string `mapstructure:"clientsecretfile"`
 		DeviceAuthEndpoint string `mapstructure:"deviceauthendpoint"`
 		Issuer string `mapstructure:"issuer"`
+		TLSCACertificateFile string `mapstructure:"tlscacertificatefile"`
+		TLSClientCertificateFile string `mapstructure:"tlsclientcertificatefile"`
+		TLSClientKeyFile string `mapstructure:"tlsclientkeyfile"`
 		TokenEndpoint string `mapstructure:"tokenendpoint"`
 		UserInfoEndpoint string `mapstructure:"userinfoendpoint"`
 	} `mapstructure:"oidc"`
 	Origin struct {
+		AnonymousRateLimitTrustedNetworks []string `mapstructure:"anonymousratelimittrustednetworks"`
+		AnonymousRateLimits interface{} `mapstructure:"anonymousratelimits"`
+		BackupDestination string `mapstructure:"backupdestination"`
+		BackupInterval time.Duration `mapstructure:"backupinterval"`
+		BackupLocation string `mapstructure:"backuplocation"`
+		BackupRetention int `mapstructure:"backupretention"`
+		CatalogInventoryFile string `mapstructure:"cataloginventoryfile"`
+		CatalogStatsInterval time.Duration `mapstructure:"catalogstatsinterval"`
+		ContentTypes interface{} `mapstructure:"contenttypes"`
+		DatasetStatsRetention time.Duration `mapstructure:"datasetstatsretention"`
 		DbLocation string `mapstructure:"dblocation"`
+		EnableBackups bool `mapstructure:"enablebackups"`
 		EnableBroker bool `mapstructure:"enablebroker"`
+		EnableCatalogStats bool `mapstructure:"enablecatalogstats"`
 		EnableCmsd bool `mapstructure:"enablecmsd"`
+		EnableContentTypes bool `mapstructure:"enablecontenttypes"`
+		EnableDatasetStats bool `mapstructure:"enabledatasetstats"`
 		EnableDirListing bool `mapstructure:"enabledirlisting"`
 		EnableDirectReads bool `mapstructure:"enabledirectreads"`
 		EnableFallbackRead bool `mapstructure:"enablefallbackread"`
@@ -178,10 +287,14 @@ type Config struct {
 		EnableOIDC bool `mapstructure:"enableoidc"`
 		EnablePublicReads bool `mapstructure:"enablepublicreads"`
 		EnableReads bool `mapstructure:"enablereads"`
+		EnableResumableUploads bool `mapstructure:"enableresumableuploads"`
 		EnableUI bool `mapstructure:"enableui"`
 		EnableVoms bool `mapstructure:"enablevoms"`
 		EnableWrite bool `mapstructure:"enablewrite"`
 		EnableWrites bool `mapstructure:"enablewrites"`
+		ExportAudit bool `mapstructure:"exportaudit"`
+		ExportAuditInterval time.Duration `mapstructure:"exportauditinterval"`
+		ExportAuditSampleSize int `mapstructure:"exportauditsamplesize"`
 		ExportVolume string `mapstructure:"exportvolume"`
 		ExportVolumes []string `mapstructure:"exportvolumes"`
 		Exports interface{} `mapstructure:"exports"`
@@ -191,7 +304,21 @@ type Config struct {
 		GlobusCollectionID string `mapstructure:"globuscollectionid"`
 		GlobusCollectionName string `mapstructure:"globuscollectionname"`
 		GlobusConfigLocation string `mapstructure:"globusconfiglocation"`
+		HttpAuthMethod string `mapstructure:"httpauthmethod"`
+		HttpBasicAuthPasswordFile string `mapstructure:"httpbasicauthpasswordfile"`
+		HttpBasicAuthUsername string `mapstructure:"httpbasicauthusername"`
+		HttpBearerTokenFile string `mapstructure:"httpbearertokenfile"`
+		HttpClientCertFile string `mapstructure:"httpclientcertfile"`
+		HttpClientKeyFile string `mapstructure:"httpclientkeyfile"`
+		HttpRequestHeaders interface{} `mapstructure:"httprequestheaders"`
+		HttpResponseHeaders interface{} `mapstructure:"httpresponseheaders"`
 		HttpServiceUrl string `mapstructure:"httpserviceurl"`
+		HttpTapeRestApiUrl string `mapstructure:"httptaperestapiurl"`
+		HttpTokenExchangeAudience string `mapstructure:"httptokenexchangeaudience"`
+		HttpTokenExchangeClientID string `mapstructure:"httptokenexchangeclientid"`
+		HttpTokenExchangeClientSecretFile string `mapstructure:"httptokenexchangeclientsecretfile"`
+		HttpTokenExchangeScope string `mapstructure:"httptokenexchangescope"`
+		HttpTokenExchangeUrl string `mapstructure:"httptokenexchangeurl"`
 		Mode string `mapstructure:"mode"`
 		Multiuser bool `mapstructure:"multiuser"`
 		NamespacePrefix string `mapstructure:"namespaceprefix"`
@@ -199,6 +326,9 @@ type Config struct {
 		RunLocation string `mapstructure:"runlocation"`
 		S3AccessKeyfile string `mapstructure:"s3accesskeyfile"`
 		S3Bucket string `mapstructure:"s3bucket"`
+		S3EnableRequestTracking bool `mapstructure:"s3enablerequesttracking"`
+		S3MaxThrottleBackoff time.Duration `mapstructure:"s3maxthrottlebackoff"`
+		S3MonthlyRequestBudget int `mapstructure:"s3monthlyrequestbudget"`
 		S3Region string `mapstructure:"s3region"`
 		S3SecretKeyfile string `mapstructure:"s3secretkeyfile"`
 		S3ServiceName string `mapstructure:"s3servicename"`
@@ -211,13 +341,21 @@ type Config struct {
 		ScitokensUsernameClaim string `mapstructure:"scitokensusernameclaim"`
 		SelfTest bool `mapstructure:"selftest"`
 		SelfTestInterval time.Duration `mapstructure:"selftestinterval"`
+		SniffContentTypes bool `mapstructure:"sniffcontenttypes"`
 		StoragePrefix string `mapstructure:"storageprefix"`
 		StorageType string `mapstructure:"storagetype"`
+		StorageWriteCheck bool `mapstructure:"storagewritecheck"`
+		StorageWriteCheckInterval time.Duration `mapstructure:"storagewritecheckinterval"`
+		TapeStageRetryAfter time.Duration `mapstructure:"tapestageretryafter"`
+		UploadStagingLocation string `mapstructure:"uploadstaginglocation"`
+		UploadStagingTTL time.Duration `mapstructure:"uploadstagingttl"`
 		Url string `mapstructure:"url"`
+		UserMapping interface{} `mapstructure:"usermapping"`
 		XRootDPrefix string `mapstructure:"xrootdprefix"`
 		XRootServiceUrl string `mapstructure:"xrootserviceurl"`
 	} `mapstructure:"origin"`
 	Plugin struct {
+		JournalLocation string `mapstructure:"journallocation"`
 		Token string `mapstructure:"token"`
 	} `mapstructure:"plugin"`
 	Registry struct {
@@ -227,20 +365,32 @@ type Config struct {
 		Institutions interface{} `mapstructure:"institutions"`
 		InstitutionsUrl string `mapstructure:"institutionsurl"`
 		InstitutionsUrlReloadMinutes time.Duration `mapstructure:"institutionsurlreloadminutes"`
+		MirrorInterval time.Duration `mapstructure:"mirrorinterval"`
+		MirrorOf string `mapstructure:"mirrorof"`
 		RequireCacheApproval bool `mapstructure:"requirecacheapproval"`
 		RequireKeyChaining bool `mapstructure:"requirekeychaining"`
 		RequireOriginApproval bool `mapstructure:"requireoriginapproval"`
 	} `mapstructure:"registry"`
 	Server struct {
+		EnablePublicStatus bool `mapstructure:"enablepublicstatus"`
 		EnableUI bool `mapstructure:"enableui"`
 		ExternalWebUrl string `mapstructure:"externalweburl"`
 		Hostname string `mapstructure:"hostname"`
+		IOCapacity int `mapstructure:"iocapacity"`
 		IssuerHostname string `mapstructure:"issuerhostname"`
 		IssuerJwks string `mapstructure:"issuerjwks"`
 		IssuerPort int `mapstructure:"issuerport"`
 		IssuerUrl string `mapstructure:"issuerurl"`
 		Modules []string `mapstructure:"modules"`
+		NotificationDbLocation string `mapstructure:"notificationdblocation"`
+		NotificationDigestInterval time.Duration `mapstructure:"notificationdigestinterval"`
+		NotificationDigestRecipients []string `mapstructure:"notificationdigestrecipients"`
+		NotificationSmtpFrom string `mapstructure:"notificationsmtpfrom"`
+		NotificationSmtpPasswordFile string `mapstructure:"notificationsmtppasswordfile"`
+		NotificationSmtpServer string `mapstructure:"notificationsmtpserver"`
+		NotificationSmtpUsername string `mapstructure:"notificationsmtpusername"`
 		RegistrationRetryInterval time.Duration `mapstructure:"registrationretryinterval"`
+		RequiredClientFeatures []string `mapstructure:"requiredclientfeatures"`
 		SessionSecretFile string `mapstructure:"sessionsecretfile"`
 		TLSCACertificateDirectory string `mapstructure:"tlscacertificatedirectory"`
 		TLSCACertificateFile string `mapstructure:"tlscacertificatefile"`
@@ -249,6 +399,9 @@ type Config struct {
 		TLSKey string `mapstructure:"tlskey"`
 		UIActivationCodeFile string `mapstructure:"uiactivationcodefile"`
 		UIAdminUsers []string `mapstructure:"uiadminusers"`
+		UIAuthDbLocation string `mapstructure:"uiauthdblocation"`
+		UIBootstrapTokenLifetime time.Duration `mapstructure:"uibootstraptokenlifetime"`
+		UIEnableWebAuthn bool `mapstructure:"uienablewebauthn"`
 		UILoginRateLimit int `mapstructure:"uiloginratelimit"`
 		UIPasswordFile string `mapstructure:"uipasswordfile"`
 		WebConfigFile string `mapstructure:"webconfigfile"`
@@ -283,10 +436,13 @@ type Config struct {
 	Transport struct {
 		DialerKeepAlive time.Duration `mapstructure:"dialerkeepalive"`
 		DialerTimeout time.Duration `mapstructure:"dialertimeout"`
+		EnableHTTP2 bool `mapstructure:"enablehttp2"`
 		ExpectContinueTimeout time.Duration `mapstructure:"expectcontinuetimeout"`
 		IdleConnTimeout time.Duration `mapstructure:"idleconntimeout"`
 		MaxIdleConns int `mapstructure:"maxidleconns"`
+		MaxIdleConnsPerHost int `mapstructure:"maxidleconnsperhost"`
 		ResponseHeaderTimeout time.Duration `mapstructure:"responseheadertimeout"`
+		TLSDestinations interface{} `mapstructure:"tlsdestinations"`
 		TLSHandshakeTimeout time.Duration `mapstructure:"tlshandshaketimeout"`
 	} `mapstructure:"transport"`
 	Xrootd struct {
@@ -318,6 +474,7 @@ type configWithType struct {
 		EnableLotman struct { Type string; Value bool }
 		EnableOIDC struct { Type string; Value bool }
 		EnableVoms struct { Type string; Value bool }
+		EnableWriteBack struct { Type string; Value bool }
 		ExportLocation struct { Type string; Value string }
 		HighWaterMark struct { Type string; Value string }
 		LocalRoot struct { Type string; Value string }
@@ -330,39 +487,98 @@ type configWithType struct {
 		SelfTestInterval struct { Type string; Value time.Duration }
 		SentinelLocation struct { Type string; Value string }
 		Url struct { Type string; Value string }
+		WriteBackLocation struct { Type string; Value string }
+		WriteBackMaxUploadSize struct { Type string; Value int }
+		WriteBackRetryLimit struct { Type string; Value time.Duration }
 		XRootDPrefix struct { Type string; Value string }
 	}
 	Client struct {
+		AnnouncementsLocation struct { Type string; Value string }
+		DisableAnnouncements struct { Type string; Value bool }
 		DisableHttpProxy struct { Type string; Value bool }
 		DisableProxyFallback struct { Type string; Value bool }
+		DisableTransferJournal struct { Type string; Value bool }
+		EnableMultiSourceDownload struct { Type string; Value bool }
+		ManagedConfigKeys struct { Type string; Value string }
+		ManagedConfigLocation struct { Type string; Value string }
+		ManagedConfigUrl struct { Type string; Value string }
 		MaximumDownloadSpeed struct { Type string; Value int }
 		MinimumDownloadSpeed struct { Type string; Value int }
+		MultiSourceChunkSize struct { Type string; Value int }
+		MultiSourceMaxSources struct { Type string; Value int }
+		MultiSourceMinimumSize struct { Type string; Value int }
+		PostTransferCommand struct { Type string; Value []string }
+		PostTransferWebhook struct { Type string; Value string }
+		PreTransferCommand struct { Type string; Value []string }
+		PreTransferWebhook struct { Type string; Value string }
 		SlowTransferRampupTime struct { Type string; Value time.Duration }
 		SlowTransferWindow struct { Type string; Value time.Duration }
+		SmallFileThreshold struct { Type string; Value int }
 		StoppedTransferTimeout struct { Type string; Value time.Duration }
+		TapeStageTimeout struct { Type string; Value time.Duration }
+		TransferHookTimeout struct { Type string; Value time.Duration }
+		TransferJournalLocation struct { Type string; Value string }
+		TransferJournalMaxEntries struct { Type string; Value int }
+		TransferJournalRetention struct { Type string; Value time.Duration }
+		UploadChunkSize struct { Type string; Value int }
+		UploadJournalLocation struct { Type string; Value string }
+		VerifyServerIdentity struct { Type string; Value bool }
+		WaitForTapeStaging struct { Type string; Value bool }
 		WorkerCount struct { Type string; Value int }
 	}
 	ConfigDir struct { Type string; Value string }
 	ConfigLocations struct { Type string; Value []string }
 	Debug struct { Type string; Value bool }
 	Director struct {
+		AdValidationAllowPrivateAddresses struct { Type string; Value bool }
+		AdValidationRateLimit struct { Type string; Value int }
 		AdvertisementTTL struct { Type string; Value time.Duration }
+		Announcements struct { Type string; Value interface{} }
+		CacheRegionCount struct { Type string; Value int }
+		CacheRegionRadius struct { Type string; Value int }
+		CacheRegions struct { Type string; Value interface{} }
 		CacheResponseHostnames struct { Type string; Value []string }
 		CacheSortMethod struct { Type string; Value string }
+		CertExpiryWarningWindow struct { Type string; Value time.Duration }
+		ClientLocationMap struct { Type string; Value interface{} }
+		DbLocation struct { Type string; Value string }
 		DefaultResponse struct { Type string; Value string }
+		DeprioritizeExpiringCerts struct { Type string; Value bool }
+		DowntimePreDrainDuration struct { Type string; Value time.Duration }
 		EnableBroker struct { Type string; Value bool }
 		EnableOIDC struct { Type string; Value bool }
+		EstimateObjectAvailability struct { Type string; Value bool }
 		FilteredServers struct { Type string; Value []string }
 		GeoIPLocation struct { Type string; Value string }
+		GeoIPMaxAccuracyRadius struct { Type string; Value int }
+		HealthTestMaxInterval struct { Type string; Value time.Duration }
+		HealthTestMinInterval struct { Type string; Value time.Duration }
+		IOLoadQueryInterval struct { Type string; Value time.Duration }
+		MaxBatchResolvePaths struct { Type string; Value int }
 		MaxMindKeyFile struct { Type string; Value string }
 		MaxStatResponse struct { Type string; Value int }
 		MinStatResponse struct { Type string; Value int }
+		MirrorCheckInterval struct { Type string; Value time.Duration }
+		MirrorCheckSampleSize struct { Type string; Value int }
+		ObjectAvailabilityTTL struct { Type string; Value time.Duration }
 		OriginCacheHealthTestInterval struct { Type string; Value time.Duration }
 		OriginResponseHostnames struct { Type string; Value []string }
+		OriginWritePolicy struct { Type string; Value string }
+		PrimaryWriteOrigins struct { Type string; Value []string }
+		RTTProbeTimeout struct { Type string; Value time.Duration }
+		RTTProbeUrl struct { Type string; Value string }
+		RedirectLogSize struct { Type string; Value int }
+		RedirectRules struct { Type string; Value interface{} }
+		SortDistanceWeight struct { Type string; Value int }
+		SortLoadWeight struct { Type string; Value int }
 		StatConcurrencyLimit struct { Type string; Value int }
 		StatTimeout struct { Type string; Value time.Duration }
 		SupportContactEmail struct { Type string; Value string }
 		SupportContactUrl struct { Type string; Value string }
+		TopologyNamespaceUrls struct { Type string; Value []string }
+		TopologyPrecedence struct { Type string; Value string }
+		TrustBundleCAFile struct { Type string; Value string }
+		TrustBundleLifetime struct { Type string; Value time.Duration }
 	}
 	DisableHttpProxy struct { Type string; Value bool }
 	DisableProxyFallback struct { Type string; Value bool }
@@ -370,16 +586,21 @@ type configWithType struct {
 		BrokerUrl struct { Type string; Value string }
 		DirectorUrl struct { Type string; Value string }
 		DiscoveryUrl struct { Type string; Value string }
+		EnableDNSDiscovery struct { Type string; Value bool }
 		JwkUrl struct { Type string; Value string }
 		RegistryUrl struct { Type string; Value string }
 		TopologyNamespaceUrl struct { Type string; Value string }
 		TopologyReloadInterval struct { Type string; Value time.Duration }
 		TopologyUrl struct { Type string; Value string }
+		TrustBundleLocation struct { Type string; Value string }
 	}
 	GeoIPOverrides struct { Type string; Value interface{} }
 	Issuer struct {
 		AuthenticationSource struct { Type string; Value string }
 		AuthorizationTemplates struct { Type string; Value interface{} }
+		DeviceApprovalRequired struct { Type string; Value bool }
+		DeviceAuthRateLimit struct { Type string; Value int }
+		DeviceTrustedNetworks struct { Type string; Value []string }
 		GroupFile struct { Type string; Value string }
 		GroupRequirements struct { Type string; Value []string }
 		GroupSource struct { Type string; Value string }
@@ -397,6 +618,8 @@ type configWithType struct {
 		DataLocation struct { Type string; Value string }
 		HighWaterMarkPercentage struct { Type string; Value int }
 		LowWaterMarkPercentage struct { Type string; Value int }
+		MaxStaleness struct { Type string; Value time.Duration }
+		RevalidateInterval struct { Type string; Value time.Duration }
 		RunLocation struct { Type string; Value string }
 		Size struct { Type string; Value string }
 		Socket struct { Type string; Value string }
@@ -433,13 +656,38 @@ type configWithType struct {
 	MinimumDownloadSpeed struct { Type string; Value int }
 	Monitoring struct {
 		AggregatePrefixes struct { Type string; Value []string }
+		AuthFailureLogSize struct { Type string; Value int }
 		DataLocation struct { Type string; Value string }
+		ForwardDestinations struct { Type string; Value []string }
+		GeoIPASNLocation struct { Type string; Value string }
+		GeoIPLabels struct { Type string; Value bool }
+		HistoryDbLocation struct { Type string; Value string }
+		HistoryInterval struct { Type string; Value time.Duration }
+		HistoryQueries struct { Type string; Value interface{} }
+		HistoryRetention struct { Type string; Value time.Duration }
+		MessageBusExchange struct { Type string; Value string }
+		MessageBusPasswordFile struct { Type string; Value string }
+		MessageBusQueueSize struct { Type string; Value int }
+		MessageBusTopic struct { Type string; Value string }
+		MessageBusUrl struct { Type string; Value string }
 		MetricAuthorization struct { Type string; Value bool }
+		MetricRelabelRules struct { Type string; Value interface{} }
+		OTLP struct {
+			Endpoint struct { Type string; Value string }
+			Headers struct { Type string; Value interface{} }
+			Interval struct { Type string; Value time.Duration }
+		}
+		PacketSpoolLocation struct { Type string; Value string }
+		PacketSpoolSize struct { Type string; Value int }
 		PortHigher struct { Type string; Value int }
 		PortLower struct { Type string; Value int }
+		ProjectClaim struct { Type string; Value string }
 		PromQLAuthorization struct { Type string; Value bool }
 		TokenExpiresIn struct { Type string; Value time.Duration }
 		TokenRefreshInterval struct { Type string; Value time.Duration }
+		TransferDurationBuckets struct { Type string; Value []string }
+		TransferLabelActions struct { Type string; Value interface{} }
+		TransferSizeBuckets struct { Type string; Value []string }
 	}
 	OIDC struct {
 		AuthorizationEndpoint struct { Type string; Value string }
@@ -449,13 +697,30 @@ type configWithType struct {
 		ClientSecretFile struct { Type string; Value string }
 		DeviceAuthEndpoint struct { Type string; Value string }
 		Issuer struct { Type string; Value string }
+		TLSCACertificateFile struct { Type string; Value string }
+		TLSClientCertificateFile struct { Type string; Value string }
+		TLSClientKeyFile struct { Type string; Value string }
 		TokenEndpoint struct { Type string; Value string }
 		UserInfoEndpoint struct { Type string; Value string }
 	}
 	Origin struct {
+		AnonymousRateLimitTrustedNetworks struct { Type string; Value []string }
+		AnonymousRateLimits struct { Type string; Value interface{} }
+		BackupDestination struct { Type string; Value string }
+		BackupInterval struct { Type string; Value time.Duration }
+		BackupLocation struct { Type string; Value string }
+		BackupRetention struct { Type string; Value int }
+		CatalogInventoryFile struct { Type string; Value string }
+		CatalogStatsInterval struct { Type string; Value time.Duration }
+		ContentTypes struct { Type string; Value interface{} }
+		DatasetStatsRetention struct { Type string; Value time.Duration }
 		DbLocation struct { Type string; Value string }
+		EnableBackups struct { Type string; Value bool }
 		EnableBroker struct { Type string; Value bool }
+		EnableCatalogStats struct { Type string; Value bool }
 		EnableCmsd struct { Type string; Value bool }
+		EnableContentTypes struct { Type string; Value bool }
+		EnableDatasetStats struct { Type string; Value bool }
 		EnableDirListing struct { Type string; Value bool }
 		EnableDirectReads struct { Type string; Value bool }
 		EnableFallbackRead struct { Type string; Value bool }
@@ -465,10 +730,14 @@ type configWithType struct {
 		EnableOIDC struct { Type string; Value bool }
 		EnablePublicReads struct { Type string; Value bool }
 		EnableReads struct { Type string; Value bool }
+		EnableResumableUploads struct { Type string; Value bool }
 		EnableUI struct { Type string; Value bool }
 		EnableVoms struct { Type string; Value bool }
 		EnableWrite struct { Type string; Value bool }
 		EnableWrites struct { Type string; Value bool }
+		ExportAudit struct { Type string; Value bool }
+		ExportAuditInterval struct { Type string; Value time.Duration }
+		ExportAuditSampleSize struct { Type string; Value int }
 		ExportVolume struct { Type string; Value string }
 		ExportVolumes struct { Type string; Value []string }
 		Exports struct { Type string; Value interface{} }
@@ -478,7 +747,21 @@ type configWithType struct {
 		GlobusCollectionID struct { Type string; Value string }
 		GlobusCollectionName struct { Type string; Value string }
 		GlobusConfigLocation struct { Type string; Value string }
+		HttpAuthMethod struct { Type string; Value string }
+		HttpBasicAuthPasswordFile struct { Type string; Value string }
+		HttpBasicAuthUsername struct { Type string; Value string }
+		HttpBearerTokenFile struct { Type string; Value string }
+		HttpClientCertFile struct { Type string; Value string }
+		HttpClientKeyFile struct { Type string; Value string }
+		HttpRequestHeaders struct { Type string; Value interface{} }
+		HttpResponseHeaders struct { Type string; Value interface{} }
 		HttpServiceUrl struct { Type string; Value string }
+		HttpTapeRestApiUrl struct { Type string; Value string }
+		HttpTokenExchangeAudience struct { Type string; Value string }
+		HttpTokenExchangeClientID struct { Type string; Value string }
+		HttpTokenExchangeClientSecretFile struct { Type string; Value string }
+		HttpTokenExchangeScope struct { Type string; Value string }
+		HttpTokenExchangeUrl struct { Type string; Value string }
 		Mode struct { Type string; Value string }
 		Multiuser struct { Type string; Value bool }
 		NamespacePrefix struct { Type string; Value string }
@@ -486,6 +769,9 @@ type configWithType struct {
 		RunLocation struct { Type string; Value string }
 		S3AccessKeyfile struct { Type string; Value string }
 		S3Bucket struct { Type string; Value string }
+		S3EnableRequestTracking struct { Type string; Value bool }
+		S3MaxThrottleBackoff struct { Type string; Value time.Duration }
+		S3MonthlyRequestBudget struct { Type string; Value int }
 		S3Region struct { Type string; Value string }
 		S3SecretKeyfile struct { Type string; Value string }
 		S3ServiceName struct { Type string; Value string }
@@ -498,13 +784,21 @@ type configWithType struct {
 		ScitokensUsernameClaim struct { Type string; Value string }
 		SelfTest struct { Type string; Value bool }
 		SelfTestInterval struct { Type string; Value time.Duration }
+		SniffContentTypes struct { Type string; Value bool }
 		StoragePrefix struct { Type string; Value string }
 		StorageType struct { Type string; Value string }
+		StorageWriteCheck struct { Type string; Value bool }
+		StorageWriteCheckInterval struct { Type string; Value time.Duration }
+		TapeStageRetryAfter struct { Type string; Value time.Duration }
+		UploadStagingLocation struct { Type string; Value string }
+		UploadStagingTTL struct { Type string; Value time.Duration }
 		Url struct { Type string; Value string }
+		UserMapping struct { Type string; Value interface{} }
 		XRootDPrefix struct { Type string; Value string }
 		XRootServiceUrl struct { Type string; Value string }
 	}
 	Plugin struct {
+		JournalLocation struct { Type string; Value string }
 		Token struct { Type string; Value string }
 	}
 	Registry struct {
@@ -514,20 +808,32 @@ type configWithType struct {
 		Institutions struct { Type string; Value interface{} }
 		InstitutionsUrl struct { Type string; Value string }
 		InstitutionsUrlReloadMinutes struct { Type string; Value time.Duration }
+		MirrorInterval struct { Type string; Value time.Duration }
+		MirrorOf struct { Type string; Value string }
 		RequireCacheApproval struct { Type string; Value bool }
 		RequireKeyChaining struct { Type string; Value bool }
 		RequireOriginApproval struct { Type string; Value bool }
 	}
 	Server struct {
+		EnablePublicStatus struct { Type string; Value bool }
 		EnableUI struct { Type string; Value bool }
 		ExternalWebUrl struct { Type string; Value string }
 		Hostname struct { Type string; Value string }
+		IOCapacity struct { Type string; Value int }
 		IssuerHostname struct { Type string; Value string }
 		IssuerJwks struct { Type string; Value string }
 		IssuerPort struct { Type string; Value int }
 		IssuerUrl struct { Type string; Value string }
 		Modules struct { Type string; Value []string }
+		NotificationDbLocation struct { Type string; Value string }
+		NotificationDigestInterval struct { Type string; Value time.Duration }
+		NotificationDigestRecipients struct { Type string; Value []string }
+		NotificationSmtpFrom struct { Type string; Value string }
+		NotificationSmtpPasswordFile struct { Type string; Value string }
+		NotificationSmtpServer struct { Type string; Value string }
+		NotificationSmtpUsername struct { Type string; Value string }
 		RegistrationRetryInterval struct { Type string; Value time.Duration }
+		RequiredClientFeatures struct { Type string; Value []string }
 		SessionSecretFile struct { Type string; Value string }
 		TLSCACertificateDirectory struct { Type string; Value string }
 		TLSCACertificateFile struct { Type string; Value string }
@@ -536,6 +842,9 @@ type configWithType struct {
 		TLSKey struct { Type string; Value string }
 		UIActivationCodeFile struct { Type string; Value string }
 		UIAdminUsers struct { Type string; Value []string }
+		UIAuthDbLocation struct { Type string; Value string }
+		UIBootstrapTokenLifetime struct { Type string; Value time.Duration }
+		UIEnableWebAuthn struct { Type string; Value bool }
 		UILoginRateLimit struct { Type string; Value int }
 		UIPasswordFile struct { Type string; Value string }
 		WebConfigFile struct { Type string; Value string }
@@ -570,10 +879,13 @@ type configWithType struct {
 	Transport struct {
 		DialerKeepAlive struct { Type string; Value time.Duration }
 		DialerTimeout struct { Type string; Value time.Duration }
+		EnableHTTP2 struct { Type string; Value bool }
 		ExpectContinueTimeout struct { Type string; Value time.Duration }
 		IdleConnTimeout struct { Type string; Value time.Duration }
 		MaxIdleConns struct { Type string; Value int }
+		MaxIdleConnsPerHost struct { Type string; Value int }
 		ResponseHeaderTimeout struct { Type string; Value time.Duration }
+		TLSDestinations struct { Type string; Value interface{} }
 		TLSHandshakeTimeout struct { Type string; Value time.Duration }
 	}
 	Xrootd struct {
@@ -26,6 +26,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"os/user"
 	"time"
 
 	"github.com/lestrrat-go/jwx/v2/jwk"
@@ -274,3 +275,62 @@ func NamespaceDelete(endpoint string, prefix string) error {
 	fmt.Println(string(respData))
 	return nil
 }
+
+// Send a namespace administration request (transfer or rename) to the registry.
+//
+// The request is authorized by a token signed with the local issuer key, so this must be
+// invoked with the registry's own key (e.g., on the registry host).  The registryUrl is
+// the registry's external web URL, which the registry expects as the token issuer.
+func namespaceAdminRequest(registryUrl string, endpoint string, payload map[string]interface{}) error {
+	adminTokenCfg := token.NewWLCGToken()
+	adminTokenCfg.Lifetime = time.Minute
+	adminTokenCfg.Issuer = registryUrl
+	adminTokenCfg.AddAudiences(registryUrl)
+	adminTokenCfg.Subject = "cli"
+	if currentUser, err := user.Current(); err == nil {
+		adminTokenCfg.Subject = "cli:" + currentUser.Username
+	}
+	adminTokenCfg.AddScopes(token_scopes.Registry_ManageNamespace)
+
+	tok, err := adminTokenCfg.CreateToken()
+	if err != nil {
+		return errors.Wrap(err, "failed to create namespace administration token")
+	}
+	authHeader := map[string]string{
+		"Authorization": "Bearer " + tok,
+	}
+
+	respData, err := utils.MakeRequest(context.Background(), endpoint, "POST", payload, authHeader)
+	var respErr clientResponseData
+	if err != nil {
+		if unmarshalErr := json.Unmarshal(respData, &respErr); unmarshalErr == nil { // Error creating json
+			return errors.Wrapf(err, "Server responded with an error: %s", respErr.Message)
+		}
+		return errors.Wrap(err, "Failed to make request")
+	}
+	fmt.Println(string(respData))
+	return nil
+}
+
+// Transfer the ownership of a namespace to a new public key and/or owner
+func NamespaceTransfer(registryUrl string, endpoint string, prefix string, pubkey string, userId string, includeSubspaces bool, dryRun bool) error {
+	payload := map[string]interface{}{
+		"prefix":            prefix,
+		"pubkey":            pubkey,
+		"user_id":           userId,
+		"include_subspaces": includeSubspaces,
+		"dry_run":           dryRun,
+	}
+	return namespaceAdminRequest(registryUrl, endpoint, payload)
+}
+
+// Rename a namespace, or merge it into an existing one
+func NamespaceRename(registryUrl string, endpoint string, prefix string, newPrefix string, alias bool, dryRun bool) error {
+	payload := map[string]interface{}{
+		"prefix":     prefix,
+		"new_prefix": newPrefix,
+		"alias":      alias,
+		"dry_run":    dryRun,
+	}
+	return namespaceAdminRequest(registryUrl, endpoint, payload)
+}
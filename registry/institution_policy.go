@@ -0,0 +1,257 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package registry
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// The registration policy of an institution.  Policies apply to the namespaces
+	// registered through the registry website with the institution; server registrations
+	// and registrations without an institution aren't affected.
+	//
+	// Prefix patterns are matched against the registered prefix and each of its parents
+	// with path.Match, so "/ucsd" allows /ucsd and everything beneath it and "/ucsd-*"
+	// allows /ucsd-physics/data.
+	InstitutionPolicy struct {
+		ID            int    `json:"id" gorm:"primaryKey;autoIncrement"`
+		InstitutionID string `json:"institution_id" gorm:"unique;not null"`
+		// The prefixes the institution may register; any prefix if empty
+		AllowedPrefixes []string `json:"allowed_prefixes" gorm:"serializer:json"`
+		// The prefixes approved as soon as they're registered
+		AutoApprovePrefixes []string `json:"auto_approve_prefixes" gorm:"serializer:json"`
+		// The maximum number of namespaces registered with the institution; unlimited if 0
+		MaxNamespaces int       `json:"max_namespaces"`
+		UpdatedBy     string    `json:"updated_by"`
+		CreatedAt     time.Time `json:"created_at"`
+		UpdatedAt     time.Time `json:"updated_at"`
+	}
+
+	institutionPolicyReq struct {
+		InstitutionID       string   `json:"institution_id" binding:"required"`
+		AllowedPrefixes     []string `json:"allowed_prefixes"`
+		AutoApprovePrefixes []string `json:"auto_approve_prefixes"`
+		MaxNamespaces       int      `json:"max_namespaces"`
+	}
+)
+
+func (InstitutionPolicy) TableName() string {
+	return "institution_policy"
+}
+
+// Get the policy of an institution, or nil if it doesn't have one
+func getInstitutionPolicy(institutionID string) (*InstitutionPolicy, error) {
+	policies := []InstitutionPolicy{}
+	if err := db.Where("institution_id = ?", institutionID).Limit(1).Find(&policies).Error; err != nil {
+		return nil, err
+	}
+	if len(policies) == 0 {
+		return nil, nil
+	}
+	return &policies[0], nil
+}
+
+func getInstitutionPolicies() (policies []InstitutionPolicy, err error) {
+	err = db.Order("institution_id ASC").Find(&policies).Error
+	return
+}
+
+// Create or replace the policy of an institution
+func setInstitutionPolicy(policy *InstitutionPolicy) error {
+	existing, err := getInstitutionPolicy(policy.InstitutionID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		policy.ID = existing.ID
+		policy.CreatedAt = existing.CreatedAt
+	}
+	return db.Save(policy).Error
+}
+
+func deleteInstitutionPolicy(institutionID string) (found bool, err error) {
+	res := db.Where("institution_id = ?", institutionID).Delete(&InstitutionPolicy{})
+	return res.RowsAffected > 0, res.Error
+}
+
+// Check the prefix patterns of a policy are valid
+func validatePrefixPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if !strings.HasPrefix(pattern, "/") {
+			return errors.Errorf("prefix pattern %q must start with '/'", pattern)
+		}
+		if _, err := path.Match(pattern, "/"); err != nil {
+			return errors.Wrapf(err, "invalid prefix pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// Returns true if any of the patterns matches the prefix or one of its parents
+func matchPrefixPatterns(patterns []string, prefix string) bool {
+	for current := path.Clean(prefix); ; current = path.Dir(current) {
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, current); matched {
+				return true
+			}
+		}
+		if current == "/" {
+			return false
+		}
+	}
+}
+
+// Evaluate the policy of the namespace's institution for a new registration.  Returns a
+// badRequestError if the policy doesn't allow the registration and whether the
+// namespace is approved automatically.
+func evaluateInstitutionPolicy(ns *server_structs.Namespace) (autoApprove bool, err error) {
+	institution := ns.AdminMetadata.Institution
+	if institution == "" || server_structs.IsCacheNS(ns.Prefix) || server_structs.IsOriginNS(ns.Prefix) {
+		return false, nil
+	}
+	policy, err := getInstitutionPolicy(institution)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get the registration policy of institution %s", institution)
+	}
+	if policy == nil {
+		return false, nil
+	}
+	if len(policy.AllowedPrefixes) > 0 && !matchPrefixPatterns(policy.AllowedPrefixes, ns.Prefix) {
+		return false, badRequestError{Message: fmt.Sprintf("The prefix %s is not allowed for institution %s. Allowed prefixes: %s",
+			ns.Prefix, institution, strings.Join(policy.AllowedPrefixes, ", "))}
+	}
+	if policy.MaxNamespaces > 0 {
+		filterNs := server_structs.Namespace{AdminMetadata: server_structs.AdminMetadata{Institution: institution}}
+		registered, err := getNamespacesByFilter(filterNs, prefixForNamespace, false)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to count the namespaces of institution %s", institution)
+		}
+		if len(registered) >= policy.MaxNamespaces {
+			return false, badRequestError{Message: fmt.Sprintf("Institution %s has reached its limit of %d registered namespaces", institution, policy.MaxNamespaces)}
+		}
+	}
+	return matchPrefixPatterns(policy.AutoApprovePrefixes, ns.Prefix), nil
+}
+
+// GET /institutions/policies
+func listInstitutionPolicies(ctx *gin.Context) {
+	policies, err := getInstitutionPolicies()
+	if err != nil {
+		log.Errorf("Failed to get institution policies: %v", err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Failed to get institution policies"})
+		return
+	}
+	ctx.JSON(http.StatusOK, policies)
+}
+
+// Institution IDs are often URLs, so the institution is identified by the request
+// body or the "institution" query parameter rather than the path
+//
+// PUT /institutions/policies
+func setInstitutionPolicyHandler(ctx *gin.Context) {
+	req := institutionPolicyReq{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("Invalid institution policy: %v", err)})
+		return
+	}
+	institutionID := req.InstitutionID
+	if valid, err := validateInstitution(institutionID); !valid {
+		msg := fmt.Sprintf("Institution %q is not in the list of available institutions", institutionID)
+		if err != nil {
+			msg = fmt.Sprintf("Validation for institution failed: %v", err)
+		}
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    msg})
+		return
+	}
+	if req.MaxNamespaces < 0 {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "max_namespaces can't be negative"})
+		return
+	}
+	for _, patterns := range [][]string{req.AllowedPrefixes, req.AutoApprovePrefixes} {
+		if err := validatePrefixPatterns(patterns); err != nil {
+			ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    fmt.Sprintf("Invalid institution policy: %v", err)})
+			return
+		}
+	}
+
+	policy := InstitutionPolicy{
+		InstitutionID:       institutionID,
+		AllowedPrefixes:     req.AllowedPrefixes,
+		AutoApprovePrefixes: req.AutoApprovePrefixes,
+		MaxNamespaces:       req.MaxNamespaces,
+		UpdatedBy:           ctx.GetString("User"),
+	}
+	if err := setInstitutionPolicy(&policy); err != nil {
+		log.Errorf("Failed to save the policy of institution %s: %v", institutionID, err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Failed to save institution policy"})
+		return
+	}
+	log.Infof("Registration policy of institution %s updated by %s", institutionID, policy.UpdatedBy)
+	ctx.JSON(http.StatusOK, policy)
+}
+
+// DELETE /institutions/policies?institution=<institution ID>
+func deleteInstitutionPolicyHandler(ctx *gin.Context) {
+	institutionID := ctx.Query("institution")
+	if institutionID == "" {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "institution is required"})
+		return
+	}
+	found, err := deleteInstitutionPolicy(institutionID)
+	if err != nil {
+		log.Errorf("Failed to delete the policy of institution %s: %v", institutionID, err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Failed to delete institution policy"})
+		return
+	}
+	if !found {
+		ctx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("Institution %s has no registration policy", institutionID)})
+		return
+	}
+	log.Infof("Registration policy of institution %s deleted by %s", institutionID, ctx.GetString("User"))
+	ctx.JSON(http.StatusOK, server_structs.SimpleApiResp{Status: server_structs.RespOK, Msg: "success"})
+}
@@ -0,0 +1,172 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+func TestMatchPrefixPatterns(t *testing.T) {
+	assert.True(t, matchPrefixPatterns([]string{"/ucsd"}, "/ucsd"))
+	assert.True(t, matchPrefixPatterns([]string{"/ucsd"}, "/ucsd/physics/data"))
+	assert.False(t, matchPrefixPatterns([]string{"/ucsd"}, "/ucsd-physics"))
+	assert.True(t, matchPrefixPatterns([]string{"/other", "/ucsd-*"}, "/ucsd-physics/data"))
+	assert.True(t, matchPrefixPatterns([]string{"/"}, "/anything"))
+	assert.False(t, matchPrefixPatterns(nil, "/ucsd"))
+
+	assert.NoError(t, validatePrefixPatterns([]string{"/ucsd", "/ucsd-*"}))
+	assert.Error(t, validatePrefixPatterns([]string{"ucsd"}))
+	assert.Error(t, validatePrefixPatterns([]string{"/ucsd["}))
+}
+
+func TestEvaluateInstitutionPolicy(t *testing.T) {
+	setupMockRegistryDB(t)
+	t.Cleanup(func() { teardownMockNamespaceDB(t) })
+
+	require.NoError(t, setInstitutionPolicy(&InstitutionPolicy{
+		InstitutionID:       "inst-a",
+		AllowedPrefixes:     []string{"/a"},
+		AutoApprovePrefixes: []string{"/a/public"},
+		MaxNamespaces:       2,
+	}))
+	require.NoError(t, insertMockDBData([]server_structs.Namespace{
+		mockNamespace("/a/first", "", "", server_structs.AdminMetadata{Institution: "inst-a", Status: server_structs.RegApproved}),
+		mockNamespace("/b/first", "", "", server_structs.AdminMetadata{Institution: "inst-b", Status: server_structs.RegApproved}),
+	}))
+	newNs := func(prefix, institution string) *server_structs.Namespace {
+		return &server_structs.Namespace{Prefix: prefix, AdminMetadata: server_structs.AdminMetadata{Institution: institution}}
+	}
+
+	t.Run("allowed-prefix", func(t *testing.T) {
+		autoApprove, err := evaluateInstitutionPolicy(newNs("/a/second", "inst-a"))
+		require.NoError(t, err)
+		assert.False(t, autoApprove)
+	})
+
+	t.Run("auto-approved-prefix", func(t *testing.T) {
+		autoApprove, err := evaluateInstitutionPolicy(newNs("/a/public/data", "inst-a"))
+		require.NoError(t, err)
+		assert.True(t, autoApprove)
+	})
+
+	t.Run("disallowed-prefix", func(t *testing.T) {
+		_, err := evaluateInstitutionPolicy(newNs("/b/second", "inst-a"))
+		assert.ErrorAs(t, err, &badRequestError{})
+	})
+
+	t.Run("server-registrations-are-exempt", func(t *testing.T) {
+		_, err := evaluateInstitutionPolicy(newNs("/caches/cache.example.org", "inst-a"))
+		assert.NoError(t, err)
+	})
+
+	t.Run("institution-without-policy", func(t *testing.T) {
+		autoApprove, err := evaluateInstitutionPolicy(newNs("/anything", "inst-b"))
+		require.NoError(t, err)
+		assert.False(t, autoApprove)
+	})
+
+	t.Run("max-namespaces", func(t *testing.T) {
+		require.NoError(t, insertMockDBData([]server_structs.Namespace{
+			mockNamespace("/a/second", "", "", server_structs.AdminMetadata{Institution: "inst-a", Status: server_structs.RegPending}),
+		}))
+		_, err := evaluateInstitutionPolicy(newNs("/a/third", "inst-a"))
+		var badReq badRequestError
+		require.ErrorAs(t, err, &badReq)
+		assert.Contains(t, badReq.Message, "limit of 2")
+	})
+}
+
+func TestInstitutionPolicyHandlers(t *testing.T) {
+	setupMockRegistryDB(t)
+	t.Cleanup(func() {
+		teardownMockNamespaceDB(t)
+		viper.Reset()
+	})
+	viper.Set("Registry.Institutions", []map[string]string{{"name": "Mock School", "id": "https://example.org/iid/1"}})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	setUser := func(ctx *gin.Context) { ctx.Set("User", "admin") }
+	router.GET("/institutions/policies", setUser, listInstitutionPolicies)
+	router.PUT("/institutions/policies", setUser, setInstitutionPolicyHandler)
+	router.DELETE("/institutions/policies", setUser, deleteInstitutionPolicyHandler)
+
+	do := func(method, target string, body any) *httptest.ResponseRecorder {
+		var reqBody bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&reqBody).Encode(body))
+		}
+		req := httptest.NewRequest(method, target, &reqBody)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("set-and-list", func(t *testing.T) {
+		w := do(http.MethodPut, "/institutions/policies", institutionPolicyReq{
+			InstitutionID:   "https://example.org/iid/1",
+			AllowedPrefixes: []string{"/school"},
+			MaxNamespaces:   5,
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		// A second PUT replaces the policy
+		w = do(http.MethodPut, "/institutions/policies", institutionPolicyReq{
+			InstitutionID:   "https://example.org/iid/1",
+			AllowedPrefixes: []string{"/school", "/school-*"},
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = do(http.MethodGet, "/institutions/policies", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		policies := []InstitutionPolicy{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &policies))
+		require.Len(t, policies, 1)
+		assert.Equal(t, []string{"/school", "/school-*"}, policies[0].AllowedPrefixes)
+		assert.Zero(t, policies[0].MaxNamespaces)
+		assert.Equal(t, "admin", policies[0].UpdatedBy)
+	})
+
+	t.Run("invalid-policies", func(t *testing.T) {
+		w := do(http.MethodPut, "/institutions/policies", institutionPolicyReq{InstitutionID: "unknown"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = do(http.MethodPut, "/institutions/policies", institutionPolicyReq{InstitutionID: "https://example.org/iid/1", AllowedPrefixes: []string{"school"}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = do(http.MethodPut, "/institutions/policies", institutionPolicyReq{InstitutionID: "https://example.org/iid/1", MaxNamespaces: -1})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		w := do(http.MethodDelete, "/institutions/policies?institution=https://example.org/iid/1", nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		w = do(http.MethodDelete, "/institutions/policies?institution=https://example.org/iid/1", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS namespace_alias (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  prefix TEXT NOT NULL UNIQUE,
+  target_prefix TEXT NOT NULL,
+  created_at DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS namespace_audit (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  action TEXT NOT NULL,
+  prefix TEXT NOT NULL,
+  new_prefix TEXT,
+  actor TEXT,
+  details TEXT,
+  created_at DATETIME
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS namespace_audit;
+DROP TABLE IF EXISTS namespace_alias;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS institution_policy (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  institution_id TEXT NOT NULL UNIQUE,
+  allowed_prefixes TEXT,
+  auto_approve_prefixes TEXT,
+  max_namespaces INTEGER NOT NULL DEFAULT 0,
+  updated_by TEXT,
+  created_at DATETIME,
+  updated_at DATETIME
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS institution_policy;
+-- +goose StatementEnd
@@ -0,0 +1,193 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+// The path, relative to the registry API, of the bulk namespace key listing
+const namespaceKeysPath = "/.well-known/namespace-keys"
+
+// How long clients and proxies may reuse the bulk key listing without revalidating it
+const namespaceKeysMaxAge = "60"
+
+// Returns the URL the keys of the namespace are served at, as advertised in its
+// openid-configuration
+func getNamespaceJwksUri(prefix string) (string, error) {
+	configUrl, err := url.Parse(param.Server_ExternalWebUrl.GetString())
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse the external web URL")
+	}
+	configUrl.Path, err = url.JoinPath("api", "v1.0", "registry", prefix, ".well-known", "issuer.jwks")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to construct the namespace jwks URL")
+	}
+	return configUrl.String(), nil
+}
+
+// Reports whether the registry withholds the keys of a namespace until it's approved
+func keysRequireApproval(prefix string) bool {
+	if server_structs.IsCacheNS(prefix) {
+		return param.Registry_RequireCacheApproval.GetBool()
+	}
+	return param.Registry_RequireOriginApproval.GetBool()
+}
+
+// Reports whether prefix is one of the filter prefixes or beneath one of them; an
+// empty filter matches everything
+func matchesPrefixFilter(prefix string, filter []string) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	for _, parent := range filter {
+		if parent == "/" || prefix == parent || strings.HasPrefix(prefix, parent+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns a strong entity tag for the given content
+func computeETag(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// Reports whether an If-None-Match header matches the entity tag, ignoring weakness
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the keys of the namespaces (and aliases) matching the prefix filter whose keys
+// the registry serves, sorted by prefix
+func getNamespaceKeysList(filter []string) ([]server_structs.NamespaceKeys, error) {
+	namespaces := []server_structs.Namespace{}
+	if err := db.Select("prefix", "pubkey", "admin_metadata").Order("prefix ASC").Find(&namespaces).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to get the namespace keys")
+	}
+	aliases := []NamespaceAlias{}
+	if err := db.Order("prefix ASC").Find(&aliases).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to get the namespace aliases")
+	}
+
+	served := make(map[string]server_structs.NamespaceKeys, len(namespaces))
+	for _, ns := range namespaces {
+		if ns.AdminMetadata.Status != server_structs.RegApproved && keysRequireApproval(ns.Prefix) {
+			continue
+		}
+		if !json.Valid([]byte(ns.Pubkey)) {
+			log.Warningf("Omitting the invalid public key of namespace %s from the key listing", ns.Prefix)
+			continue
+		}
+		served[ns.Prefix] = server_structs.NamespaceKeys{
+			Prefix: ns.Prefix,
+			Jwks:   json.RawMessage(ns.Pubkey),
+			ETag:   computeETag(ns.Pubkey),
+		}
+	}
+
+	result := []server_structs.NamespaceKeys{}
+	add := func(prefix string, keys server_structs.NamespaceKeys) error {
+		if !matchesPrefixFilter(prefix, filter) {
+			return nil
+		}
+		jwksUri, err := getNamespaceJwksUri(prefix)
+		if err != nil {
+			return err
+		}
+		keys.Prefix = prefix
+		keys.JwksUri = jwksUri
+		result = append(result, keys)
+		return nil
+	}
+	for _, ns := range namespaces {
+		if keys, ok := served[ns.Prefix]; ok {
+			if err := add(ns.Prefix, keys); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// Aliases carry the keys of the namespace they point to
+	for _, alias := range aliases {
+		if keys, ok := served[alias.TargetPrefix]; ok {
+			if err := add(alias.Prefix, keys); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// List the public keys of all the namespaces, or of those beneath the prefixes given
+// by the "prefix" query parameters.  The response carries an ETag so directors can
+// cheaply revalidate their cached keys with If-None-Match.
+func listNamespaceKeysHandler(ctx *gin.Context) {
+	filter := []string{}
+	for _, prefix := range ctx.QueryArray("prefix") {
+		if !strings.HasPrefix(prefix, "/") {
+			ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Invalid prefix " + prefix + "; prefixes must start with '/'"})
+			return
+		}
+		filter = append(filter, path.Clean(prefix))
+	}
+
+	keys, err := getNamespaceKeysList(filter)
+	if err != nil {
+		log.Errorln("Failed to list the namespace keys:", err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "server encountered an error trying to list the namespace keys"})
+		return
+	}
+
+	tags := strings.Builder{}
+	for _, entry := range keys {
+		tags.WriteString(entry.Prefix + " " + entry.ETag + "\n")
+	}
+	etag := computeETag(tags.String())
+	ctx.Header("ETag", etag)
+	ctx.Header("Cache-Control", "public, max-age="+namespaceKeysMaxAge)
+	if etagMatches(ctx.GetHeader("If-None-Match"), etag) {
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+	ctx.JSON(http.StatusOK, server_structs.NamespaceKeysRes{Namespaces: keys})
+}
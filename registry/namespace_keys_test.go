@@ -0,0 +1,114 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package registry
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+func TestListNamespaceKeys(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("Server.ExternalWebUrl", "https://registry.example.com")
+	viper.Set("Registry.RequireCacheApproval", true)
+	setupMockRegistryDB(t)
+	t.Cleanup(func() { teardownMockNamespaceDB(t) })
+
+	approved := server_structs.AdminMetadata{Status: server_structs.RegApproved}
+	pending := server_structs.AdminMetadata{Status: server_structs.RegPending}
+	require.NoError(t, insertMockDBData([]server_structs.Namespace{
+		mockNamespace("/foo", `{"keys":[{"kid":"foo"}]}`, "", pending),
+		mockNamespace("/foo/bar", `{"keys":[{"kid":"bar"}]}`, "", approved),
+		mockNamespace("/foobar", `{"keys":[{"kid":"foobar"}]}`, "", approved),
+		mockNamespace("/caches/approved", `{"keys":[{"kid":"cache1"}]}`, "", approved),
+		mockNamespace("/caches/pending", `{"keys":[{"kid":"cache2"}]}`, "", pending),
+	}))
+	require.NoError(t, db.Create(&NamespaceAlias{Prefix: "/foo/old", TargetPrefix: "/foo/bar"}).Error)
+
+	r := gin.New()
+	r.GET("/api/v1.0/registry/*wildcard", wildcardHandler)
+	get := func(query, ifNoneMatch string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, "/api/v1.0/registry/.well-known/namespace-keys"+query, nil)
+		require.NoError(t, err)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	prefixes := func(w *httptest.ResponseRecorder) []string {
+		res := server_structs.NamespaceKeysRes{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		result := []string{}
+		for _, ns := range res.Namespaces {
+			result = append(result, ns.Prefix)
+		}
+		return result
+	}
+
+	t.Run("all", func(t *testing.T) {
+		w := get("", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotEmpty(t, w.Header().Get("ETag"))
+		assert.Equal(t, "public, max-age=60", w.Header().Get("Cache-Control"))
+
+		res := server_structs.NamespaceKeysRes{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		// Unapproved caches are withheld, and the alias carries its target's keys
+		require.Len(t, res.Namespaces, 5)
+		assert.Equal(t, []string{"/caches/approved", "/foo", "/foo/bar", "/foobar", "/foo/old"}, prefixes(w))
+		assert.Equal(t, "https://registry.example.com/api/v1.0/registry/foo/bar/.well-known/issuer.jwks", res.Namespaces[2].JwksUri)
+		assert.JSONEq(t, `{"keys":[{"kid":"bar"}]}`, string(res.Namespaces[2].Jwks))
+		assert.Equal(t, res.Namespaces[2].ETag, res.Namespaces[4].ETag)
+		assert.NotEqual(t, res.Namespaces[1].ETag, res.Namespaces[2].ETag)
+	})
+
+	t.Run("filtered", func(t *testing.T) {
+		w := get("?prefix=/foo&prefix=/caches/", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []string{"/caches/approved", "/foo", "/foo/bar", "/foo/old"}, prefixes(w))
+
+		assert.Equal(t, http.StatusBadRequest, get("?prefix=foo", "").Code)
+	})
+
+	t.Run("conditional", func(t *testing.T) {
+		etag := get("", "").Header().Get("ETag")
+		w := get("", etag)
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.Bytes())
+		assert.Equal(t, http.StatusNotModified, get("", `"other", W/`+etag).Code)
+
+		// Changing a key changes the listing's ETag
+		require.NoError(t, db.Model(&server_structs.Namespace{}).Where("prefix = ?", "/foobar").Update("pubkey", `{"keys":[{"kid":"new"}]}`).Error)
+		w = get("", etag)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEqual(t, etag, w.Header().Get("ETag"))
+	})
+}
@@ -0,0 +1,307 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+	"gorm.io/gorm"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/utils"
+)
+
+type (
+	// The response of the namespace snapshot endpoint.  The token is a JWT signed
+	// by the registry's issuer key whose claims hold the registry's namespaces and
+	// aliases, for registries mirroring this one.
+	namespaceSnapshotRes struct {
+		Token string `json:"token"`
+	}
+)
+
+const (
+	// The path, relative to the registry API, of the signed namespace snapshot
+	namespaceSnapshotPath = "/.well-known/namespace-snapshot"
+
+	// How long a namespace snapshot is valid for.  Mirrors keep serving the last
+	// snapshot they applied after it expires; this only bounds how long a snapshot
+	// can be replayed to a mirror.
+	namespaceSnapshotLifetime = time.Hour
+
+	namespacesClaim       = "pelican_namespaces"
+	namespaceAliasesClaim = "pelican_namespace_aliases"
+)
+
+var (
+	// When the last snapshot the mirror applied was issued; older snapshots are ignored
+	lastSnapshotIssued      time.Time
+	lastSnapshotIssuedMutex sync.Mutex
+)
+
+// Reports whether the registry is a read-only mirror of another registry
+func isMirror() bool {
+	return param.Registry_MirrorOf.GetString() != ""
+}
+
+// A gin middleware rejecting the requests that would modify a mirror's namespaces.
+// The requests that only query the registry with a POST are allowed.
+func mirrorReadOnlyHandler(ctx *gin.Context) {
+	if !isMirror() {
+		ctx.Next()
+		return
+	}
+	switch ctx.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		ctx.Next()
+		return
+	}
+	switch strings.TrimSuffix(ctx.Request.URL.Path, "/") {
+	case "/api/v1.0/registry/checkNamespaceExists", "/api/v1.0/registry/checkNamespaceStatus",
+		"/api/v1.0/registry/namespaces/check/status", "/api/v1.0/registry/namespaces/check/approval":
+		ctx.Next()
+		return
+	}
+	ctx.AbortWithStatusJSON(http.StatusForbidden, server_structs.SimpleApiResp{
+		Status: server_structs.RespFailed,
+		Msg:    "This registry is a read-only mirror of " + param.Registry_MirrorOf.GetString() + "; make changes there instead",
+	})
+}
+
+// Create a JWT, signed with the registry's issuer key, holding all of the registry's
+// namespaces and aliases
+func createNamespaceSnapshot() (string, error) {
+	namespaces := []server_structs.Namespace{}
+	if err := db.Order("id ASC").Find(&namespaces).Error; err != nil {
+		return "", errors.Wrap(err, "failed to get the namespaces")
+	}
+	aliases := []NamespaceAlias{}
+	if err := db.Order("id ASC").Find(&aliases).Error; err != nil {
+		return "", errors.Wrap(err, "failed to get the namespace aliases")
+	}
+
+	key, err := config.GetIssuerPrivateJWK()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to load the registry's private key")
+	}
+	if err = jwk.AssignKeyID(key); err != nil {
+		return "", errors.Wrap(err, "failed to assign kid to the namespace snapshot")
+	}
+
+	now := time.Now()
+	tok, err := jwt.NewBuilder().
+		Issuer(param.Server_ExternalWebUrl.GetString()).
+		IssuedAt(now).
+		NotBefore(now).
+		Expiration(now.Add(namespaceSnapshotLifetime)).
+		Claim(namespacesClaim, namespaces).
+		Claim(namespaceAliasesClaim, aliases).
+		Build()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to build the namespace snapshot")
+	}
+	signed, err := jwt.Sign(tok, jwt.WithKey(jwa.ES256, key))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to sign the namespace snapshot")
+	}
+	return string(signed), nil
+}
+
+// Serve the signed snapshot of the registry's namespaces to its mirrors
+func getNamespaceSnapshotHandler(ctx *gin.Context) {
+	signed, err := createNamespaceSnapshot()
+	if err != nil {
+		log.Errorln("Failed to create the namespace snapshot:", err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Failed to create the namespace snapshot",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, namespaceSnapshotRes{Token: signed})
+}
+
+// Decode a claim of a verified snapshot into its type
+func getSnapshotClaim(tok jwt.Token, name string, value interface{}) error {
+	claim, ok := tok.Get(name)
+	if !ok {
+		return errors.Errorf("the namespace snapshot has no %s claim", name)
+	}
+	claimJson, err := json.Marshal(claim)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read the %s claim of the namespace snapshot", name)
+	}
+	return errors.Wrapf(json.Unmarshal(claimJson, value), "invalid %s claim in the namespace snapshot", name)
+}
+
+// Fetch the signed namespace snapshot from the primary registry, verifying it was
+// signed by one of the keys the primary publishes
+func fetchNamespaceSnapshot(ctx context.Context, primaryUrl string) (namespaces []server_structs.Namespace, aliases []NamespaceAlias, issued time.Time, err error) {
+	snapshotUrl, err := url.JoinPath(primaryUrl, "api", "v1.0", "registry", namespaceSnapshotPath)
+	if err != nil {
+		err = errors.Wrap(err, "failed to generate the URL of the namespace snapshot")
+		return
+	}
+	client := &http.Client{Transport: config.GetTransport()}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, snapshotUrl, nil)
+	if err != nil {
+		return
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		err = errors.Wrap(err, "failed to fetch the namespace snapshot")
+		return
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024*1024))
+	if err != nil {
+		err = errors.Wrap(err, "failed to read the namespace snapshot")
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		err = errors.Errorf("the primary registry responded to the snapshot request with status code %d", resp.StatusCode)
+		return
+	}
+	snapshotRes := namespaceSnapshotRes{}
+	if err = json.Unmarshal(body, &snapshotRes); err != nil {
+		err = errors.Wrap(err, "failed to parse the namespace snapshot")
+		return
+	}
+
+	jwksUrl, err := url.JoinPath(primaryUrl, ".well-known", "issuer.jwks")
+	if err != nil {
+		err = errors.Wrap(err, "failed to generate the URL of the primary registry's public keys")
+		return
+	}
+	keys, err := utils.GetJwks(ctx, jwksUrl)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to fetch the primary registry's public keys from %s", jwksUrl)
+		return
+	}
+	tok, err := jwt.Parse([]byte(snapshotRes.Token), jwt.WithKeySet(keys), jwt.WithValidate(true), jwt.WithIssuer(primaryUrl))
+	if err != nil {
+		err = errors.Wrap(err, "failed to verify the namespace snapshot")
+		return
+	}
+	if err = getSnapshotClaim(tok, namespacesClaim, &namespaces); err != nil {
+		return
+	}
+	if err = getSnapshotClaim(tok, namespaceAliasesClaim, &aliases); err != nil {
+		return
+	}
+	issued = tok.IssuedAt()
+	return
+}
+
+// Replace the registry's namespaces and aliases with those of a snapshot
+func applyNamespaceSnapshot(namespaces []server_structs.Namespace, aliases []NamespaceAlias) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&server_structs.Namespace{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("1 = 1").Delete(&NamespaceAlias{}).Error; err != nil {
+			return err
+		}
+		if len(namespaces) > 0 {
+			if err := tx.Create(&namespaces).Error; err != nil {
+				return err
+			}
+		}
+		if len(aliases) > 0 {
+			if err := tx.Create(&aliases).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Fetch the primary registry's namespace snapshot and apply it, unless it's older
+// than the last one applied
+func mirrorNamespaces(ctx context.Context) error {
+	primaryUrl := param.Registry_MirrorOf.GetString()
+	namespaces, aliases, issued, err := fetchNamespaceSnapshot(ctx, primaryUrl)
+	if err != nil {
+		return err
+	}
+
+	lastSnapshotIssuedMutex.Lock()
+	defer lastSnapshotIssuedMutex.Unlock()
+	if issued.Before(lastSnapshotIssued) {
+		log.Warningf("Ignoring a namespace snapshot from %s issued at %s, before the one already applied", primaryUrl, issued.Format(time.RFC3339))
+		return nil
+	}
+	if err := applyNamespaceSnapshot(namespaces, aliases); err != nil {
+		return errors.Wrap(err, "failed to apply the namespace snapshot")
+	}
+	lastSnapshotIssued = issued
+	log.Debugf("Mirrored %d namespaces and %d aliases from %s", len(namespaces), len(aliases), primaryUrl)
+	return nil
+}
+
+// Mirror the namespaces of the primary registry, Registry.MirrorOf, every
+// Registry.MirrorInterval.  If the primary is unreachable, the namespaces of the
+// last snapshot keep being served.
+func LaunchNamespaceMirror(ctx context.Context, egrp *errgroup.Group) {
+	interval := param.Registry_MirrorInterval.GetDuration()
+	if interval <= 0 {
+		log.Warningf("Invalid Registry.MirrorInterval %s; falling back to 5m", interval)
+		interval = 5 * time.Minute
+	}
+	mirror := func() {
+		if err := mirrorNamespaces(ctx); err != nil {
+			log.Warningf("Failed to mirror the namespaces of %s; serving the last snapshot: %v", param.Registry_MirrorOf.GetString(), err)
+			metrics.SetComponentHealthStatus(metrics.Registry_Mirror, metrics.StatusWarning, "Failed to mirror the primary registry: "+err.Error())
+			return
+		}
+		metrics.SetComponentHealthStatus(metrics.Registry_Mirror, metrics.StatusOK, "")
+	}
+
+	egrp.Go(func() error {
+		defer metrics.TrackGoroutine("registry-mirror")()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		mirror()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+				mirror()
+			}
+		}
+	})
+}
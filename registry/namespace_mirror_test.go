@@ -0,0 +1,158 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package registry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+func TestNamespaceMirror(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("IssuerKey", filepath.Join(t.TempDir(), "testKey"))
+	setupMockRegistryDB(t)
+	primaryDB := db
+	t.Cleanup(func() {
+		db = primaryDB
+		teardownMockNamespaceDB(t)
+	})
+
+	approved := server_structs.AdminMetadata{Status: server_structs.RegApproved}
+	require.NoError(t, insertMockDBData([]server_structs.Namespace{
+		mockNamespace("/foo", `{"keys":[{"kid":"foo"}]}`, "", approved),
+		mockNamespace("/caches/cache.example.com", `{"keys":[{"kid":"cache"}]}`, "", approved),
+	}))
+	require.NoError(t, db.Create(&NamespaceAlias{Prefix: "/foo/old", TargetPrefix: "/foo"}).Error)
+
+	// The primary registry serves its snapshot and its public keys
+	r := gin.New()
+	r.GET("/api/v1.0/registry/*wildcard", wildcardHandler)
+	r.GET("/.well-known/issuer.jwks", func(ctx *gin.Context) {
+		keys, err := config.GetIssuerPublicJWKS()
+		require.NoError(t, err)
+		ctx.JSON(http.StatusOK, keys)
+	})
+	primary := httptest.NewTLSServer(r)
+	t.Cleanup(primary.Close)
+	transport := config.GetTransport()
+	oldConfig := transport.TLSClientConfig
+	transport.TLSClientConfig = primary.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	t.Cleanup(func() {
+		transport.TLSClientConfig = oldConfig
+	})
+	viper.Set("Server.ExternalWebUrl", primary.URL)
+	viper.Set("Registry.MirrorOf", primary.URL)
+
+	namespaces, aliases, issued, err := fetchNamespaceSnapshot(context.Background(), primary.URL)
+	require.NoError(t, err)
+	require.Len(t, namespaces, 2)
+	assert.Equal(t, "/foo", namespaces[0].Prefix)
+	assert.Equal(t, `{"keys":[{"kid":"foo"}]}`, namespaces[0].Pubkey)
+	assert.Equal(t, server_structs.RegApproved, namespaces[0].AdminMetadata.Status)
+	require.Len(t, aliases, 1)
+	assert.Equal(t, "/foo", aliases[0].TargetPrefix)
+	assert.WithinDuration(t, time.Now(), issued, time.Minute)
+
+	t.Run("apply-snapshot", func(t *testing.T) {
+		// The mirror's own namespaces are replaced by the primary's
+		setupMockRegistryDB(t)
+		t.Cleanup(func() {
+			teardownMockNamespaceDB(t)
+			db = primaryDB
+		})
+		require.NoError(t, insertMockDBData([]server_structs.Namespace{
+			mockNamespace("/stale", `{"keys":[{"kid":"stale"}]}`, "", approved),
+		}))
+		require.NoError(t, applyNamespaceSnapshot(namespaces, aliases))
+
+		exists, err := namespaceExistsByPrefix("/stale")
+		require.NoError(t, err)
+		assert.False(t, exists)
+		ns, err := getNamespaceByPrefix("/foo")
+		require.NoError(t, err)
+		assert.Equal(t, namespaces[0].ID, ns.ID)
+		target, isAlias, err := getNamespaceAliasTarget("/foo/old")
+		require.NoError(t, err)
+		assert.True(t, isAlias)
+		assert.Equal(t, "/foo", target)
+	})
+
+	t.Run("wrong-issuer", func(t *testing.T) {
+		viper.Set("Server.ExternalWebUrl", "https://other-registry.example.com")
+		t.Cleanup(func() { viper.Set("Server.ExternalWebUrl", primary.URL) })
+		_, _, _, err := fetchNamespaceSnapshot(context.Background(), primary.URL)
+		assert.Error(t, err)
+	})
+
+	t.Run("older-snapshot-ignored", func(t *testing.T) {
+		lastSnapshotIssuedMutex.Lock()
+		lastSnapshotIssued = time.Now().Add(time.Hour)
+		lastSnapshotIssuedMutex.Unlock()
+		t.Cleanup(func() {
+			lastSnapshotIssuedMutex.Lock()
+			lastSnapshotIssued = time.Time{}
+			lastSnapshotIssuedMutex.Unlock()
+		})
+		require.NoError(t, db.Where("prefix = ?", "/foo/old").Delete(&NamespaceAlias{}).Error)
+
+		require.NoError(t, mirrorNamespaces(context.Background()))
+		_, isAlias, err := getNamespaceAliasTarget("/foo/old")
+		require.NoError(t, err)
+		assert.False(t, isAlias)
+	})
+}
+
+func TestMirrorReadOnlyHandler(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	r := gin.New()
+	registryAPI := r.Group("/api/v1.0/registry", mirrorReadOnlyHandler)
+	ok := func(ctx *gin.Context) { ctx.Status(http.StatusOK) }
+	registryAPI.POST("", ok)
+	registryAPI.GET("/*wildcard", ok)
+	registryAPI.POST("/checkNamespaceExists", ok)
+	do := func(method, path string) int {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(method, path, nil)
+		require.NoError(t, err)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/api/v1.0/registry"))
+
+	viper.Set("Registry.MirrorOf", "https://primary-registry.example.com")
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/api/v1.0/registry"))
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/v1.0/registry/foo"))
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/api/v1.0/registry/checkNamespaceExists"))
+}
@@ -0,0 +1,516 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package registry
+
+import (
+	"crypto"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/token"
+	"github.com/pelicanplatform/pelican/token_scopes"
+)
+
+type (
+	// An alias from a prefix that no longer exists in the registry to the prefix
+	// it was renamed or merged into.  The registry serves the target's public key
+	// for the alias so tokens issued for the old prefix remain verifiable.
+	NamespaceAlias struct {
+		ID           int       `json:"id" gorm:"primaryKey;autoIncrement"`
+		Prefix       string    `json:"prefix" gorm:"unique;not null"`
+		TargetPrefix string    `json:"target_prefix" gorm:"not null"`
+		CreatedAt    time.Time `json:"created_at"`
+	}
+
+	// A record of an administrative change to namespace registrations
+	NamespaceAudit struct {
+		ID        int               `json:"id" gorm:"primaryKey;autoIncrement"`
+		Action    string            `json:"action"` // "transfer" | "rename" | "merge"
+		Prefix    string            `json:"prefix"`
+		NewPrefix string            `json:"new_prefix"`
+		Actor     string            `json:"actor"`
+		Details   []namespaceChange `json:"details" gorm:"serializer:json"`
+		CreatedAt time.Time         `json:"created_at"`
+	}
+
+	// A single change made (or, for a dry run, to be made) to the registry
+	namespaceChange struct {
+		Action    string `json:"action"` // "update_pubkey" | "update_owner" | "update_identity" | "rename" | "delete" | "add_alias" | "update_alias" | "delete_alias"
+		ID        int    `json:"id,omitempty"`
+		Prefix    string `json:"prefix"`
+		NewPrefix string `json:"new_prefix,omitempty"`
+	}
+
+	namespaceChangeRes struct {
+		DryRun   bool              `json:"dry_run"`
+		Changes  []namespaceChange `json:"changes"`
+		Warnings []string          `json:"warnings,omitempty"`
+	}
+
+	// Request to transfer the ownership of a namespace to a different key and/or identity.
+	// Prefix is only used when the namespace is not identified by the request path.
+	namespaceTransferReq struct {
+		Prefix           string `json:"prefix"`
+		Pubkey           string `json:"pubkey"`   // The JWKS of the new owner
+		UserID           string `json:"user_id"`  // The new owner's user ID in the registry UI
+		Identity         string `json:"identity"` // The new owner's identity
+		IncludeSubspaces bool   `json:"include_subspaces"`
+		DryRun           bool   `json:"dry_run"`
+	}
+
+	// Request to rename a namespace, or merge it into an existing one.
+	// Prefix is only used when the namespace is not identified by the request path.
+	namespaceRenameReq struct {
+		Prefix    string `json:"prefix"`
+		NewPrefix string `json:"new_prefix"`
+		Alias     bool   `json:"alias"` // Keep the old prefix as an alias of the new one
+		DryRun    bool   `json:"dry_run"`
+	}
+)
+
+func (NamespaceAlias) TableName() string {
+	return "namespace_alias"
+}
+
+func (NamespaceAudit) TableName() string {
+	return "namespace_audit"
+}
+
+// Get the prefix the given prefix is an alias of, if any
+func getNamespaceAliasTarget(prefix string) (target string, found bool, err error) {
+	aliases := []NamespaceAlias{}
+	if err = db.Where("prefix = ?", prefix).Limit(1).Find(&aliases).Error; err != nil {
+		return "", false, err
+	}
+	if len(aliases) == 0 {
+		return "", false, nil
+	}
+	return aliases[0].TargetPrefix, true, nil
+}
+
+// Get the audit records, newest first, optionally restricted to a prefix (and the
+// namespaces beneath it)
+func getNamespaceAudits(prefix string) (audits []NamespaceAudit, err error) {
+	query := db.Order("id DESC")
+	if prefix != "" {
+		query = query.Where(`prefix = ? OR new_prefix = ? OR prefix LIKE ? ESCAPE '\' OR new_prefix LIKE ? ESCAPE '\'`,
+			prefix, prefix, subspacePattern(prefix), subspacePattern(prefix))
+	}
+	err = query.Find(&audits).Error
+	return
+}
+
+// Returns true if the two JWKS strings contain the same first key
+func samePubkey(jwksA, jwksB string) bool {
+	keyA, errA := validateJwks(jwksA)
+	keyB, errB := validateJwks(jwksB)
+	if errA != nil || errB != nil {
+		return false
+	}
+	thumbA, errA := keyA.Thumbprint(crypto.SHA256)
+	thumbB, errB := keyB.Thumbprint(crypto.SHA256)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return string(thumbA) == string(thumbB)
+}
+
+// The LIKE pattern matching the prefixes beneath the given prefix, escaping the
+// wildcards in it with backslashes; use with `ESCAPE '\'`
+func subspacePattern(prefix string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return escaper.Replace(prefix) + "/%"
+}
+
+// Get the namespaces registered beneath the prefix
+func getSubspaces(tx *gorm.DB, prefix string) (subspaces []server_structs.Namespace, err error) {
+	err = tx.Where(`prefix LIKE ? ESCAPE '\'`, subspacePattern(prefix)).Order("prefix ASC").Find(&subspaces).Error
+	return
+}
+
+// Transfer the ownership of a namespace (and, optionally, the subspaces registered with
+// the same key) to a new key and/or identity.  Unless it's a dry run, the changes are
+// applied in a single transaction and recorded in the audit table.
+func transferNamespace(ns *server_structs.Namespace, req namespaceTransferReq, actor string) (res namespaceChangeRes, err error) {
+	res = namespaceChangeRes{DryRun: req.DryRun, Changes: []namespaceChange{}}
+	if req.Pubkey == "" && req.UserID == "" && req.Identity == "" {
+		err = badRequestError{Message: "At least one of the new public key, user ID, or identity is required for a transfer"}
+		return
+	}
+	if req.Pubkey != "" {
+		if _, err = validateJwks(req.Pubkey); err != nil {
+			err = badRequestError{Message: fmt.Sprintf("Invalid public key: %v", err)}
+			return
+		}
+	}
+
+	targets := []server_structs.Namespace{*ns}
+	if req.IncludeSubspaces {
+		var subspaces []server_structs.Namespace
+		if subspaces, err = getSubspaces(db, ns.Prefix); err != nil {
+			err = errors.Wrapf(err, "failed to get subspaces of %s", ns.Prefix)
+			return
+		}
+		for _, sub := range subspaces {
+			if samePubkey(sub.Pubkey, ns.Pubkey) {
+				targets = append(targets, sub)
+			} else {
+				res.Warnings = append(res.Warnings, fmt.Sprintf("Subspace %s is registered with a different key and will not be transferred", sub.Prefix))
+			}
+		}
+	}
+
+	for idx := range targets {
+		target := &targets[idx]
+		if req.Pubkey != "" && !samePubkey(target.Pubkey, req.Pubkey) {
+			target.Pubkey = req.Pubkey
+			res.Changes = append(res.Changes, namespaceChange{Action: "update_pubkey", ID: target.ID, Prefix: target.Prefix})
+		}
+		if req.UserID != "" && target.AdminMetadata.UserID != req.UserID {
+			target.AdminMetadata.UserID = req.UserID
+			res.Changes = append(res.Changes, namespaceChange{Action: "update_owner", ID: target.ID, Prefix: target.Prefix})
+		}
+		if req.Identity != "" && target.Identity != req.Identity {
+			target.Identity = req.Identity
+			res.Changes = append(res.Changes, namespaceChange{Action: "update_identity", ID: target.ID, Prefix: target.Prefix})
+		}
+		target.AdminMetadata.UpdatedAt = time.Now()
+	}
+
+	if req.DryRun || len(res.Changes) == 0 {
+		return
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for idx := range targets {
+			if err := tx.Save(&targets[idx]).Error; err != nil {
+				return errors.Wrapf(err, "failed to update namespace %s", targets[idx].Prefix)
+			}
+		}
+		audit := NamespaceAudit{Action: "transfer", Prefix: ns.Prefix, Actor: actor, Details: res.Changes}
+		return errors.Wrap(tx.Create(&audit).Error, "failed to record the transfer in the audit table")
+	})
+	if err == nil {
+		log.Infof("Namespace %s transferred by %s: %d change(s)", ns.Prefix, actor, len(res.Changes))
+	}
+	return
+}
+
+// Rename a namespace to a new prefix, moving all the namespaces registered beneath it.
+// If the new prefix is already registered, the namespace is instead merged into it: the
+// old registration is removed and its subspaces are moved beneath the new prefix.
+//
+// Existing aliases pointing at the moved prefixes are updated and, if requested, an alias
+// from the old prefix to the new one is created.  Unless it's a dry run, the changes are
+// applied in a single transaction and recorded in the audit table.
+func renameNamespace(ns *server_structs.Namespace, req namespaceRenameReq, actor string) (res namespaceChangeRes, err error) {
+	res = namespaceChangeRes{DryRun: req.DryRun, Changes: []namespaceChange{}}
+	oldPrefix := ns.Prefix
+	var newPrefix string
+	if newPrefix, err = validatePrefix(req.NewPrefix); err != nil {
+		err = badRequestError{Message: fmt.Sprintf("Invalid new prefix: %v", err)}
+		return
+	}
+	if server_structs.IsCacheNS(oldPrefix) || server_structs.IsOriginNS(oldPrefix) ||
+		server_structs.IsCacheNS(newPrefix) || server_structs.IsOriginNS(newPrefix) {
+		err = badRequestError{Message: "Cache and origin server registrations cannot be renamed or merged"}
+		return
+	}
+	if newPrefix == oldPrefix {
+		err = badRequestError{Message: "The new prefix is the same as the existing prefix"}
+		return
+	}
+	if strings.HasPrefix(newPrefix, oldPrefix+"/") {
+		err = badRequestError{Message: fmt.Sprintf("Cannot move %s beneath itself", oldPrefix)}
+		return
+	}
+
+	action := "rename"
+	var targetExists bool
+	if targetExists, err = namespaceExistsByPrefix(newPrefix); err != nil {
+		err = errors.Wrapf(err, "failed to check if %s is registered", newPrefix)
+		return
+	}
+	if targetExists {
+		var target *server_structs.Namespace
+		if target, err = getNamespaceByPrefix(newPrefix); err != nil {
+			return
+		}
+		action = "merge"
+		if !samePubkey(target.Pubkey, ns.Pubkey) {
+			res.Warnings = append(res.Warnings, fmt.Sprintf("%s is registered with a different key than %s; the key of %s will be kept", newPrefix, oldPrefix, newPrefix))
+		}
+		res.Changes = append(res.Changes, namespaceChange{Action: "delete", ID: ns.ID, Prefix: oldPrefix})
+	} else {
+		res.Changes = append(res.Changes, namespaceChange{Action: "rename", ID: ns.ID, Prefix: oldPrefix, NewPrefix: newPrefix})
+		superspaces, _, _, _, supErr := namespaceSupSubChecks(newPrefix)
+		if supErr != nil {
+			err = errors.Wrapf(supErr, "failed to check the namespaces above %s", newPrefix)
+			return
+		}
+		for _, superspace := range superspaces {
+			if superspace != oldPrefix && !strings.HasPrefix(superspace, oldPrefix+"/") {
+				res.Warnings = append(res.Warnings, fmt.Sprintf("%s will be beneath the existing namespace %s", newPrefix, superspace))
+			}
+		}
+	}
+
+	var subspaces []server_structs.Namespace
+	if subspaces, err = getSubspaces(db, oldPrefix); err != nil {
+		err = errors.Wrapf(err, "failed to get subspaces of %s", oldPrefix)
+		return
+	}
+	movedPrefix := func(prefix string) string {
+		return newPrefix + strings.TrimPrefix(prefix, oldPrefix)
+	}
+	for _, sub := range subspaces {
+		subNewPrefix := movedPrefix(sub.Prefix)
+		var exists bool
+		if exists, err = namespaceExistsByPrefix(subNewPrefix); err != nil {
+			err = errors.Wrapf(err, "failed to check if %s is registered", subNewPrefix)
+			return
+		} else if exists {
+			err = badRequestError{Message: fmt.Sprintf("Cannot move %s to %s because %s is already registered", sub.Prefix, subNewPrefix, subNewPrefix)}
+			return
+		}
+		res.Changes = append(res.Changes, namespaceChange{Action: "rename", ID: sub.ID, Prefix: sub.Prefix, NewPrefix: subNewPrefix})
+	}
+
+	aliases := []NamespaceAlias{}
+	if err = db.Where(`target_prefix = ? OR target_prefix LIKE ? ESCAPE '\' OR prefix = ?`, oldPrefix, subspacePattern(oldPrefix), newPrefix).Find(&aliases).Error; err != nil {
+		err = errors.Wrap(err, "failed to get the existing namespace aliases")
+		return
+	}
+	for _, alias := range aliases {
+		if alias.Prefix == newPrefix {
+			res.Changes = append(res.Changes, namespaceChange{Action: "delete_alias", Prefix: alias.Prefix})
+		} else {
+			res.Changes = append(res.Changes, namespaceChange{Action: "update_alias", Prefix: alias.Prefix, NewPrefix: movedPrefix(alias.TargetPrefix)})
+		}
+	}
+	if req.Alias {
+		res.Changes = append(res.Changes, namespaceChange{Action: "add_alias", Prefix: oldPrefix, NewPrefix: newPrefix})
+	}
+
+	if req.DryRun {
+		return
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, change := range res.Changes {
+			var err error
+			switch change.Action {
+			case "delete":
+				err = tx.Delete(&server_structs.Namespace{}, change.ID).Error
+			case "rename":
+				err = tx.Model(&server_structs.Namespace{}).Where("id = ?", change.ID).Update("prefix", change.NewPrefix).Error
+			case "delete_alias":
+				err = tx.Where("prefix = ?", change.Prefix).Delete(&NamespaceAlias{}).Error
+			case "update_alias":
+				err = tx.Model(&NamespaceAlias{}).Where("prefix = ?", change.Prefix).Update("target_prefix", change.NewPrefix).Error
+			case "add_alias":
+				err = tx.Create(&NamespaceAlias{Prefix: change.Prefix, TargetPrefix: change.NewPrefix}).Error
+			}
+			if err != nil {
+				return errors.Wrapf(err, "failed to %s %s", strings.ReplaceAll(change.Action, "_", " "), change.Prefix)
+			}
+		}
+		audit := NamespaceAudit{Action: action, Prefix: oldPrefix, NewPrefix: newPrefix, Actor: actor, Details: res.Changes}
+		return errors.Wrapf(tx.Create(&audit).Error, "failed to record the %s in the audit table", action)
+	})
+	if err == nil {
+		if action == "merge" {
+			log.Infof("Namespace %s merged into %s by %s: %d change(s)", oldPrefix, newPrefix, actor, len(res.Changes))
+		} else {
+			log.Infof("Namespace %s renamed to %s by %s: %d change(s)", oldPrefix, newPrefix, actor, len(res.Changes))
+		}
+	}
+	return
+}
+
+// A gin middleware for the CLI namespace administration APIs.  Requires a token
+// signed by the registry's own key with the registry.manage_namespace scope, such
+// as one generated on the registry host by `pelican namespace transfer`.
+func namespaceAdminTokenHandler(ctx *gin.Context) {
+	if verifyNamespaceAdminToken(ctx) {
+		ctx.Next()
+	}
+}
+
+// Verify the namespace administration token in the Authorization header and record its
+// subject as the request's user.  Aborts the request and returns false if the token
+// is missing or invalid.
+func verifyNamespaceAdminToken(ctx *gin.Context) bool {
+	status, verified, err := token.Verify(ctx, token.AuthOption{
+		Sources: []token.TokenSource{token.Header},
+		Issuers: []token.TokenIssuer{token.LocalIssuer},
+		Scopes:  []token_scopes.TokenScope{token_scopes.Registry_ManageNamespace},
+	})
+	if err != nil || !verified {
+		if status == http.StatusOK {
+			status = http.StatusInternalServerError
+		}
+		msg := "Unknown verification error"
+		if err != nil {
+			msg = err.Error()
+		}
+		ctx.AbortWithStatusJSON(status, server_structs.SimpleApiResp{Status: server_structs.RespFailed, Msg: msg})
+		return false
+	}
+	// The token has been verified; record its subject as the actor for the audit table
+	actor := "registry-admin-token"
+	tokStr := strings.TrimPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+	if tok, err := jwt.Parse([]byte(tokStr), jwt.WithVerify(false)); err == nil && tok.Subject() != "" {
+		actor = tok.Subject()
+	}
+	ctx.Set("User", actor)
+	ctx.Set("RegistryAdmin", true)
+	return true
+}
+
+// Get the namespace targeted by an administration request, either by the "id" path
+// parameter (registry UI) or by the prefix in the request body (CLI)
+func getAdminTargetNamespace(ctx *gin.Context, prefix string) (ns *server_structs.Namespace, ok bool) {
+	var exists bool
+	var err error
+	id := 0
+	if idStr := ctx.Param("id"); idStr != "" {
+		if id, err = strconv.Atoi(idStr); err != nil || id <= 0 {
+			ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Invalid ID format. ID must a positive integer"})
+			return
+		}
+		exists, err = namespaceExistsById(id)
+	} else if prefix == "" {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "prefix is required"})
+		return
+	} else {
+		prefix = path.Clean(prefix)
+		exists, err = namespaceExistsByPrefix(prefix)
+	}
+	if err != nil {
+		log.Error("Error checking if namespace exists: ", err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Error checking if namespace exists"})
+		return
+	}
+	if !exists {
+		ctx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Namespace not found"})
+		return
+	}
+	if id > 0 {
+		ns, err = getNamespaceById(id)
+	} else {
+		ns, err = getNamespaceByPrefix(prefix)
+	}
+	if err != nil {
+		log.Error("Error getting namespace: ", err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Error getting namespace"})
+		return
+	}
+	return ns, true
+}
+
+// Respond to a namespace administration request given the result of the operation
+func respondNamespaceChange(ctx *gin.Context, res namespaceChangeRes, err error) {
+	var badReq badRequestError
+	if errors.As(err, &badReq) {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    badReq.Message})
+		return
+	} else if err != nil {
+		log.Errorf("Failed to update namespaces: %v", err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("Failed to update namespaces: %v", err)})
+		return
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// POST /namespaces/:id/transfer
+// POST /namespaces/transfer
+func handleTransferNamespace(ctx *gin.Context) {
+	req := namespaceTransferReq{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("Invalid transfer request: %v", err)})
+		return
+	}
+	ns, ok := getAdminTargetNamespace(ctx, req.Prefix)
+	if !ok {
+		return
+	}
+	res, err := transferNamespace(ns, req, ctx.GetString("User"))
+	respondNamespaceChange(ctx, res, err)
+}
+
+// POST /namespaces/:id/rename
+// POST /namespaces/rename
+func handleRenameNamespace(ctx *gin.Context) {
+	req := namespaceRenameReq{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("Invalid rename request: %v", err)})
+		return
+	}
+	ns, ok := getAdminTargetNamespace(ctx, req.Prefix)
+	if !ok {
+		return
+	}
+	res, err := renameNamespace(ns, req, ctx.GetString("User"))
+	respondNamespaceChange(ctx, res, err)
+}
+
+// GET /namespaces/audit
+func listNamespaceAudits(ctx *gin.Context) {
+	prefix := ctx.Query("prefix")
+	if prefix != "" {
+		prefix = path.Clean(prefix)
+	}
+	audits, err := getNamespaceAudits(prefix)
+	if err != nil {
+		log.Errorf("Failed to get namespace audit records: %v", err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Failed to get namespace audit records"})
+		return
+	}
+	ctx.JSON(http.StatusOK, audits)
+}
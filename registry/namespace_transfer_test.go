@@ -0,0 +1,333 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package registry
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+// Generate a JWKS string with a single, newly-generated public key
+func generateTestJwks(t *testing.T) string {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	pub, err := jwk.FromRaw(priv.Public())
+	require.NoError(t, err)
+	require.NoError(t, jwk.AssignKeyID(pub))
+	keySet := jwk.NewSet()
+	require.NoError(t, keySet.AddKey(pub))
+	jwksBytes, err := json.Marshal(keySet)
+	require.NoError(t, err)
+	return string(jwksBytes)
+}
+
+func setupNamespaceTransferDB(t *testing.T) {
+	setupMockRegistryDB(t)
+	t.Cleanup(func() { teardownMockNamespaceDB(t) })
+}
+
+func resetNamespaceTransferDB(t *testing.T) {
+	resetNamespaceDB(t)
+	require.NoError(t, db.Where("1 = 1").Delete(&NamespaceAlias{}).Error)
+	require.NoError(t, db.Where("1 = 1").Delete(&NamespaceAudit{}).Error)
+}
+
+func getTestNamespacePrefixes(t *testing.T) []string {
+	nss, err := getAllNamespaces()
+	require.NoError(t, err)
+	prefixes := []string{}
+	for _, ns := range nss {
+		prefixes = append(prefixes, ns.Prefix)
+	}
+	return prefixes
+}
+
+func TestTransferNamespace(t *testing.T) {
+	setupNamespaceTransferDB(t)
+	oldKey := generateTestJwks(t)
+	otherKey := generateTestJwks(t)
+	newKey := generateTestJwks(t)
+
+	insertNamespaces := func(t *testing.T) {
+		resetNamespaceTransferDB(t)
+		require.NoError(t, insertMockDBData([]server_structs.Namespace{
+			mockNamespace("/foo", oldKey, "", server_structs.AdminMetadata{UserID: "alice"}),
+			mockNamespace("/foo/same", oldKey, "", server_structs.AdminMetadata{UserID: "alice"}),
+			mockNamespace("/foo/other", otherKey, "", server_structs.AdminMetadata{UserID: "carol"}),
+		}))
+	}
+
+	t.Run("requires-new-owner", func(t *testing.T) {
+		insertNamespaces(t)
+		ns, err := getNamespaceByPrefix("/foo")
+		require.NoError(t, err)
+		_, err = transferNamespace(ns, namespaceTransferReq{}, "admin")
+		assert.ErrorAs(t, err, &badRequestError{})
+		_, err = transferNamespace(ns, namespaceTransferReq{Pubkey: "not a key"}, "admin")
+		assert.ErrorAs(t, err, &badRequestError{})
+	})
+
+	t.Run("dry-run", func(t *testing.T) {
+		insertNamespaces(t)
+		ns, err := getNamespaceByPrefix("/foo")
+		require.NoError(t, err)
+		res, err := transferNamespace(ns, namespaceTransferReq{Pubkey: newKey, UserID: "bob", DryRun: true}, "admin")
+		require.NoError(t, err)
+		assert.True(t, res.DryRun)
+		assert.Equal(t, []namespaceChange{
+			{Action: "update_pubkey", ID: ns.ID, Prefix: "/foo"},
+			{Action: "update_owner", ID: ns.ID, Prefix: "/foo"},
+		}, res.Changes)
+
+		ns, err = getNamespaceByPrefix("/foo")
+		require.NoError(t, err)
+		assert.Equal(t, oldKey, ns.Pubkey)
+		assert.Equal(t, "alice", ns.AdminMetadata.UserID)
+		audits, err := getNamespaceAudits("")
+		require.NoError(t, err)
+		assert.Empty(t, audits)
+	})
+
+	t.Run("transfer-with-subspaces", func(t *testing.T) {
+		insertNamespaces(t)
+		ns, err := getNamespaceByPrefix("/foo")
+		require.NoError(t, err)
+		res, err := transferNamespace(ns, namespaceTransferReq{Pubkey: newKey, UserID: "bob", IncludeSubspaces: true}, "admin")
+		require.NoError(t, err)
+		assert.Len(t, res.Changes, 4)
+		require.Len(t, res.Warnings, 1)
+		assert.Contains(t, res.Warnings[0], "/foo/other")
+
+		for _, prefix := range []string{"/foo", "/foo/same"} {
+			ns, err := getNamespaceByPrefix(prefix)
+			require.NoError(t, err)
+			assert.True(t, samePubkey(newKey, ns.Pubkey))
+			assert.Equal(t, "bob", ns.AdminMetadata.UserID)
+		}
+		ns, err = getNamespaceByPrefix("/foo/other")
+		require.NoError(t, err)
+		assert.Equal(t, otherKey, ns.Pubkey)
+		assert.Equal(t, "carol", ns.AdminMetadata.UserID)
+
+		audits, err := getNamespaceAudits("/foo")
+		require.NoError(t, err)
+		require.Len(t, audits, 1)
+		assert.Equal(t, "transfer", audits[0].Action)
+		assert.Equal(t, "admin", audits[0].Actor)
+		assert.Equal(t, res.Changes, audits[0].Details)
+	})
+}
+
+func TestRenameNamespace(t *testing.T) {
+	setupNamespaceTransferDB(t)
+	fooKey := generateTestJwks(t)
+	barKey := generateTestJwks(t)
+
+	insertNamespaces := func(t *testing.T, prefixes ...string) {
+		resetNamespaceTransferDB(t)
+		for _, prefix := range prefixes {
+			key := fooKey
+			if prefix == "/bar" {
+				key = barKey
+			}
+			require.NoError(t, insertMockDBData([]server_structs.Namespace{mockNamespace(prefix, key, "", server_structs.AdminMetadata{})}))
+		}
+	}
+
+	t.Run("invalid-requests", func(t *testing.T) {
+		insertNamespaces(t, "/foo", "/foo/a", "/bar", "/bar/a")
+		ns, err := getNamespaceByPrefix("/foo")
+		require.NoError(t, err)
+		for _, newPrefix := range []string{"", "/foo", "/foo/sub", "/api/foo", "/caches/host"} {
+			_, err = renameNamespace(ns, namespaceRenameReq{NewPrefix: newPrefix}, "admin")
+			assert.ErrorAs(t, err, &badRequestError{}, "new prefix %q", newPrefix)
+		}
+		// /foo/a can't be moved to /bar/a since it's already registered
+		_, err = renameNamespace(ns, namespaceRenameReq{NewPrefix: "/bar"}, "admin")
+		assert.ErrorAs(t, err, &badRequestError{})
+		assert.Equal(t, []string{"/foo", "/foo/a", "/bar", "/bar/a"}, getTestNamespacePrefixes(t))
+	})
+
+	t.Run("dry-run", func(t *testing.T) {
+		insertNamespaces(t, "/foo", "/foo/a")
+		ns, err := getNamespaceByPrefix("/foo")
+		require.NoError(t, err)
+		res, err := renameNamespace(ns, namespaceRenameReq{NewPrefix: "/baz", Alias: true, DryRun: true}, "admin")
+		require.NoError(t, err)
+		assert.Len(t, res.Changes, 3)
+		assert.Equal(t, []string{"/foo", "/foo/a"}, getTestNamespacePrefixes(t))
+		_, isAlias, err := getNamespaceAliasTarget("/foo")
+		require.NoError(t, err)
+		assert.False(t, isAlias)
+	})
+
+	t.Run("rename-with-alias", func(t *testing.T) {
+		insertNamespaces(t, "/foo", "/foo/a", "/foobar")
+		ns, err := getNamespaceByPrefix("/foo")
+		require.NoError(t, err)
+		res, err := renameNamespace(ns, namespaceRenameReq{NewPrefix: "/baz/", Alias: true}, "admin")
+		require.NoError(t, err)
+		assert.Equal(t, []namespaceChange{
+			{Action: "rename", ID: ns.ID, Prefix: "/foo", NewPrefix: "/baz"},
+			{Action: "rename", ID: ns.ID + 1, Prefix: "/foo/a", NewPrefix: "/baz/a"},
+			{Action: "add_alias", Prefix: "/foo", NewPrefix: "/baz"},
+		}, res.Changes)
+		assert.Equal(t, []string{"/baz", "/baz/a", "/foobar"}, getTestNamespacePrefixes(t))
+
+		target, isAlias, err := getNamespaceAliasTarget("/foo")
+		require.NoError(t, err)
+		assert.True(t, isAlias)
+		assert.Equal(t, "/baz", target)
+
+		// Renaming again updates the existing alias
+		ns, err = getNamespaceByPrefix("/baz")
+		require.NoError(t, err)
+		_, err = renameNamespace(ns, namespaceRenameReq{NewPrefix: "/qux"}, "admin")
+		require.NoError(t, err)
+		target, _, err = getNamespaceAliasTarget("/foo")
+		require.NoError(t, err)
+		assert.Equal(t, "/qux", target)
+
+		audits, err := getNamespaceAudits("/foo")
+		require.NoError(t, err)
+		require.Len(t, audits, 1)
+		assert.Equal(t, "rename", audits[0].Action)
+		assert.Equal(t, "/baz", audits[0].NewPrefix)
+		audits, err = getNamespaceAudits("")
+		require.NoError(t, err)
+		assert.Len(t, audits, 2)
+	})
+
+	t.Run("escapes-wildcards", func(t *testing.T) {
+		insertNamespaces(t, "/ligo_data", "/ligo_data/a", "/ligo-data", "/ligo-data/b")
+		ns, err := getNamespaceByPrefix("/ligo_data")
+		require.NoError(t, err)
+		res, err := renameNamespace(ns, namespaceRenameReq{NewPrefix: "/ligo"}, "admin")
+		require.NoError(t, err)
+		assert.Len(t, res.Changes, 2)
+		assert.ElementsMatch(t, []string{"/ligo", "/ligo/a", "/ligo-data", "/ligo-data/b"}, getTestNamespacePrefixes(t))
+
+		audits, err := getNamespaceAudits("/ligo_data")
+		require.NoError(t, err)
+		assert.Len(t, audits, 1)
+		audits, err = getNamespaceAudits("/ligo-data")
+		require.NoError(t, err)
+		assert.Empty(t, audits)
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		insertNamespaces(t, "/foo", "/foo/a", "/bar")
+		ns, err := getNamespaceByPrefix("/foo")
+		require.NoError(t, err)
+		res, err := renameNamespace(ns, namespaceRenameReq{NewPrefix: "/bar"}, "admin")
+		require.NoError(t, err)
+		require.Len(t, res.Warnings, 1)
+		assert.Contains(t, res.Warnings[0], "different key")
+		assert.ElementsMatch(t, []string{"/bar/a", "/bar"}, getTestNamespacePrefixes(t))
+
+		bar, err := getNamespaceByPrefix("/bar")
+		require.NoError(t, err)
+		assert.Equal(t, barKey, bar.Pubkey)
+
+		audits, err := getNamespaceAudits("/bar")
+		require.NoError(t, err)
+		require.Len(t, audits, 1)
+		assert.Equal(t, "merge", audits[0].Action)
+	})
+}
+
+func TestNamespaceAdminHandlers(t *testing.T) {
+	setupNamespaceTransferDB(t)
+	fooKey := generateTestJwks(t)
+
+	router := gin.New()
+	router.GET("/api/v1.0/registry/*wildcard", wildcardHandler)
+	router.POST("/namespaces/:id/rename", handleRenameNamespace)
+	router.POST("/namespaces/rename", handleRenameNamespace)
+	router.POST("/namespaces/transfer", handleTransferNamespace)
+
+	doPost := func(t *testing.T, url string, body any) *httptest.ResponseRecorder {
+		bodyBytes, err := json.Marshal(body)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, url, bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	resetNamespaceTransferDB(t)
+	require.NoError(t, insertMockDBData([]server_structs.Namespace{mockNamespace("/foo", fooKey, "", server_structs.AdminMetadata{})}))
+	ns, err := getNamespaceByPrefix("/foo")
+	require.NoError(t, err)
+
+	t.Run("not-found", func(t *testing.T) {
+		w := doPost(t, "/namespaces/rename", namespaceRenameReq{Prefix: "/missing", NewPrefix: "/bar"})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = doPost(t, "/namespaces/9999/rename", namespaceRenameReq{NewPrefix: "/bar"})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = doPost(t, "/namespaces/transfer", namespaceTransferReq{UserID: "bob"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("bad-request", func(t *testing.T) {
+		w := doPost(t, "/namespaces/transfer", namespaceTransferReq{Prefix: "/foo"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("rename-by-id", func(t *testing.T) {
+		w := doPost(t, "/namespaces/"+jsonNumber(ns.ID)+"/rename", namespaceRenameReq{NewPrefix: "/bar", Alias: true})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		res := namespaceChangeRes{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		assert.False(t, res.DryRun)
+		assert.Len(t, res.Changes, 2)
+	})
+
+	t.Run("alias-serves-target-key", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1.0/registry/foo/.well-known/issuer.jwks", nil)
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, samePubkey(fooKey, w.Body.String()))
+
+		w = httptest.NewRecorder()
+		req = httptest.NewRequest(http.MethodGet, "/api/v1.0/registry/missing/.well-known/issuer.jwks", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func jsonNumber(num int) string {
+	numBytes, _ := json.Marshal(num)
+	return string(numBytes)
+}
@@ -296,6 +296,12 @@ func keySignChallengeCommit(ctx *gin.Context, data *registrationData) (bool, map
 		}
 		data.Prefix = reqPrefix
 
+		if _, isAlias, err := getNamespaceAliasTarget(reqPrefix); err != nil {
+			return false, nil, errors.Wrap(err, "Server encountered an error checking if the namespace is an alias")
+		} else if isAlias {
+			return false, nil, badRequestError{Message: fmt.Sprintf("The prefix %s is reserved as an alias of a renamed namespace", reqPrefix)}
+		}
+
 		inTopo, topoNss, valErr, sysErr := validateKeyChaining(reqPrefix, key)
 		if valErr != nil {
 			log.Errorln(err)
@@ -800,6 +806,18 @@ func wildcardHandler(ctx *gin.Context) {
 	// new / here!
 	path := ctx.Param("wildcard")
 
+	// List the keys of all the namespaces
+	if path == namespaceKeysPath {
+		listNamespaceKeysHandler(ctx)
+		return
+	}
+
+	// The signed snapshot of all the namespaces, for mirrors of the registry
+	if path == namespaceSnapshotPath {
+		getNamespaceSnapshotHandler(ctx)
+		return
+	}
+
 	// Get the prefix's JWKS
 	// Avoid using filepath.Base for path matching, as filepath format depends on OS
 	// while HTTP path is always slash (/)
@@ -813,6 +831,28 @@ func wildcardHandler(ctx *gin.Context) {
 				Msg:    "server encountered an error trying to check if the namespace exists"})
 			return
 		}
+		if !found {
+			// The prefix may have been renamed or merged into another namespace,
+			// in which case we serve the key of the namespace it's an alias of
+			target, isAlias, err := getNamespaceAliasTarget(prefix)
+			if err != nil {
+				log.Error("Error checking if prefix ", prefix, " is an alias: ", err)
+				ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+					Status: server_structs.RespFailed,
+					Msg:    "server encountered an error trying to check if the namespace exists"})
+				return
+			}
+			if isAlias {
+				if found, err = namespaceExistsByPrefix(target); err != nil {
+					log.Error("Error checking if prefix ", target, " exists: ", err)
+					ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+						Status: server_structs.RespFailed,
+						Msg:    "server encountered an error trying to check if the namespace exists"})
+					return
+				}
+				prefix = target
+			}
+		}
 		if !found {
 			ctx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{
 				Status: server_structs.RespFailed,
@@ -874,21 +914,14 @@ func wildcardHandler(ctx *gin.Context) {
 		}
 		// Construct the openid-configuration JSON and return to the requester
 		// For a given namespace "foo", the jwks should be located at <registry url>/api/v1.0/registry/foo/.well-known/issuer.jwks
-		configUrl, err := url.Parse(param.Server_ExternalWebUrl.GetString())
-		if err != nil {
-			log.Errorf("Failed to parse configured external web URL while constructing namespace jwks location: %v", err)
-			return
-		}
-
-		path := strings.TrimSuffix(path, "/openid-configuration")
-		configUrl.Path, err = url.JoinPath("api", "v1.0", "registry", path, "issuer.jwks")
+		jwksUri, err := getNamespaceJwksUri(prefix)
 		if err != nil {
 			log.Errorf("Failed to construct namespace jwks URL: %v", err)
 			return
 		}
 
 		nsCfg := NamespaceConfig{
-			JwksUri: configUrl.String(),
+			JwksUri: jwksUri,
 		}
 
 		ctx.JSON(http.StatusOK, nsCfg)
@@ -1153,7 +1186,7 @@ func checkStatusHandler(ctx *gin.Context) {
 }
 
 func RegisterRegistryAPI(router *gin.RouterGroup) {
-	registryAPI := router.Group("/api/v1.0/registry")
+	registryAPI := router.Group("/api/v1.0/registry", mirrorReadOnlyHandler)
 
 	// DO NOT add any other GET route with path starts with "/" to registryAPI
 	// It will cause duplicated route error. Use wildcardHandler to handle such
@@ -1177,4 +1210,11 @@ func RegisterRegistryAPI(router *gin.RouterGroup) {
 		checkApis.POST("/status", checkStatusHandler)     // registration completeness status
 		checkApis.POST("/approval", checkApprovalHandler) // approval status
 	}
+
+	// Namespace administration APIs for the CLI; see registry_ui.go for the web UI equivalents
+	adminApis := registryAPI.Group("/namespaces", namespaceAdminTokenHandler)
+	{
+		adminApis.POST("/transfer", handleTransferNamespace)
+		adminApis.POST("/rename", handleRenameNamespace)
+	}
 }
@@ -32,10 +32,12 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/metrics"
 	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/server_structs"
 	"github.com/pelicanplatform/pelican/server_utils"
 	"github.com/pelicanplatform/pelican/utils"
+	"github.com/pelicanplatform/pelican/web_ui"
 )
 
 type NamespaceWOPubkey struct {
@@ -384,7 +386,23 @@ func AddNamespace(ns *server_structs.Namespace) error {
 		ns.AdminMetadata.Status = server_structs.RegPending
 	}
 
-	return db.Save(&ns).Error
+	if err := db.Save(&ns).Error; err != nil {
+		return err
+	}
+	if ns.AdminMetadata.Status == server_structs.RegPending {
+		msg := fmt.Sprintf("Namespace %s waits for an admin to approve or deny its registration", ns.Prefix)
+		if ns.AdminMetadata.UserID != "" {
+			msg = fmt.Sprintf("Namespace %s was registered by %s and waits for an admin to approve or deny its registration", ns.Prefix, ns.AdminMetadata.UserID)
+		}
+		web_ui.NotifyAdmins("registry", registrationNotificationKey(ns.ID), web_ui.NotificationInfo,
+			fmt.Sprintf("The registration of %s is pending approval", ns.Prefix), msg)
+	}
+	return nil
+}
+
+// The key of the notification of a registration pending approval
+func registrationNotificationKey(id int) string {
+	return fmt.Sprintf("registration/%d", id)
 }
 
 func updateNamespace(ns *server_structs.Namespace) error {
@@ -437,11 +455,21 @@ func updateNamespaceStatusById(id int, status server_structs.RegistrationStatus,
 		return errors.Wrap(err, "Error marshaling admin metadata")
 	}
 
-	return db.Model(ns).Where("id = ?", id).Update("admin_metadata", string(adminMetadataByte)).Error
+	if err := db.Model(ns).Where("id = ?", id).Update("admin_metadata", string(adminMetadataByte)).Error; err != nil {
+		return err
+	}
+	if status != server_structs.RegPending {
+		web_ui.ResolveAdminNotification(registrationNotificationKey(id))
+	}
+	return nil
 }
 
 func deleteNamespaceByID(id int) error {
-	return db.Delete(&server_structs.Namespace{}, id).Error
+	if err := db.Delete(&server_structs.Namespace{}, id).Error; err != nil {
+		return err
+	}
+	web_ui.ResolveAdminNotification(registrationNotificationKey(id))
+	return nil
 }
 
 func deleteNamespaceByPrefix(prefix string) error {
@@ -523,8 +551,10 @@ func PopulateTopology(ctx context.Context) error {
 	// Next, get the values from topology
 	namespaces, err := utils.GetTopologyJSON(ctx, false)
 	if err != nil {
+		metrics.SetComponentHealthStatus(metrics.DirectorRegistry_Topology, metrics.StatusCritical, "Failed to get topology JSON: "+err.Error())
 		return errors.Wrapf(err, "Failed to get topology JSON")
 	}
+	metrics.SetComponentHealthStatus(metrics.DirectorRegistry_Topology, metrics.StatusOK, "")
 
 	// Be careful here, the ns object we iterate over is from topology,
 	// and it's not the same ns object we use elsewhere in this file.
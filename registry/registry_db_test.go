@@ -56,6 +56,10 @@ func setupMockRegistryDB(t *testing.T) {
 	require.NoError(t, err, "Error setting up mock namespace DB")
 	err = db.AutoMigrate(&server_structs.Namespace{})
 	require.NoError(t, err, "Failed to migrate DB for namespace table")
+	err = db.AutoMigrate(&NamespaceAlias{}, &NamespaceAudit{})
+	require.NoError(t, err, "Failed to migrate DB for namespace alias and audit tables")
+	err = db.AutoMigrate(&InstitutionPolicy{})
+	require.NoError(t, err, "Failed to migrate DB for institution policy table")
 	err = migrateTopologyTestTable()
 	require.NoError(t, err, "Error creating topology table")
 }
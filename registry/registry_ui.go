@@ -32,6 +32,7 @@ import (
 	"github.com/jellydator/ttlcache/v3"
 	"github.com/lestrrat-go/jwx/v2/jwk"
 	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/pelicanplatform/pelican/config"
@@ -182,16 +183,21 @@ func populateRegistrationFields(prefix string, data interface{}) []registrationF
 //
 // GET /namespaces
 func listNamespaces(ctx *gin.Context) {
-	// Directly call GetUser as we want this endpoint to also be able to serve unauthed users
-	user, _, err := web_ui.GetUserGroups(ctx)
-	if err != nil {
-		log.Error("Failed to check user login status: ", err)
-		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
-			Status: server_structs.RespFailed,
-			Msg:    "Failed to check user login status"})
-		return
+	// The v2 API authenticates the user upstream. Otherwise, directly call GetUser as
+	// we want this endpoint to also be able to serve unauthed users
+	user := ctx.GetString("User")
+	if user == "" {
+		var err error
+		user, _, err = web_ui.GetUserGroups(ctx)
+		if err != nil {
+			log.Error("Failed to check user login status: ", err)
+			ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Failed to check user login status"})
+			return
+		}
+		ctx.Set("User", user)
 	}
-	ctx.Set("User", user)
 	isAuthed := user != ""
 	queryParams := listNamespaceRequest{}
 	if err := ctx.ShouldBindQuery(&queryParams); err != nil {
@@ -331,7 +337,7 @@ func getNamespaceRegFields(ctx *gin.Context) {
 func createUpdateNamespace(ctx *gin.Context, isUpdate bool) {
 	user := ctx.GetString("User")
 	accessToken := ctx.Query("access_token")
-	isAdmin, _ := web_ui.CheckAdmin(user)
+	isAdmin := isRegistryAdmin(ctx)
 
 	id := 0 // namespace ID when doing update, will be populated later
 	if user == "" {
@@ -392,6 +398,20 @@ func createUpdateNamespace(ctx *gin.Context, isUpdate bool) {
 				Msg:    fmt.Sprintf("The prefix %s is already registered", ns.Prefix)})
 			return
 		}
+		_, isAlias, err := getNamespaceAliasTarget(ns.Prefix)
+		if err != nil {
+			log.Errorf("Failed to check if namespace is an alias: %v", err)
+			ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Server encountered an error checking if namespace is an alias"})
+			return
+		}
+		if isAlias {
+			ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    fmt.Sprintf("The prefix %s is reserved as an alias of a renamed namespace", ns.Prefix)})
+			return
+		}
 	}
 
 	// Check if pubKey is a valid JWK
@@ -435,6 +455,24 @@ func createUpdateNamespace(ctx *gin.Context, isUpdate bool) {
 		return
 	}
 
+	autoApprove := false
+	if !isUpdate {
+		if autoApprove, err = evaluateInstitutionPolicy(&ns); err != nil {
+			var badReq badRequestError
+			if errors.As(err, &badReq) {
+				ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+					Status: server_structs.RespFailed,
+					Msg:    badReq.Message})
+				return
+			}
+			log.Errorf("Failed to evaluate the institution policy for %s: %v", ns.Prefix, err)
+			ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Server encountered an error evaluating the institution's registration policy"})
+			return
+		}
+	}
+
 	formatCustomFields(ns.CustomFields)
 
 	if validCF, err := validateCustomFields(ns.CustomFields); !validCF {
@@ -456,6 +494,11 @@ func createUpdateNamespace(ctx *gin.Context, isUpdate bool) {
 		ns.AdminMetadata.Status = server_structs.RegPending
 		if inTopo {
 			ns.AdminMetadata.Description = fmt.Sprintf("[ Attention: A superspace or subspace of this prefix exists in OSDF topology: %s ] ", GetTopoPrefixString(topoNss))
+		} else if autoApprove {
+			// Conflicts with the topology are always left for the admins to review
+			ns.AdminMetadata.Status = server_structs.RegApproved
+			ns.AdminMetadata.ApproverID = "institution-policy"
+			ns.AdminMetadata.ApprovedAt = time.Now()
 		}
 		// Basic validation (type, required, etc)
 		errs := config.GetValidate().Struct(ns)
@@ -659,7 +702,7 @@ func getNamespace(ctx *gin.Context) {
 		return
 	}
 
-	isAdmin, _ := web_ui.CheckAdmin(user)
+	isAdmin := isRegistryAdmin(ctx)
 	belongsTo := false
 
 	if !isAdmin { // Not admin, need to check if the namespace belongs to the user
@@ -853,6 +896,7 @@ func deleteNamespace(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
 			Status: server_structs.RespFailed,
 			Msg:    "Error deleting the namespace"})
+		return
 	}
 	ctx.JSON(http.StatusOK,
 		server_structs.SimpleApiResp{
@@ -913,7 +957,7 @@ func listTopologyNamespaces(ctx *gin.Context) {
 
 // Define Gin APIs for registry Web UI. All endpoints are user-facing
 func RegisterRegistryWebAPI(router *gin.RouterGroup) error {
-	registryWebAPI := router.Group("/api/v1.0/registry_ui")
+	registryWebAPI := router.Group("/api/v1.0/registry_ui", mirrorReadOnlyHandler)
 	csrfHandler, err := config.GetCSRFHandler()
 	if err != nil {
 		return err
@@ -931,6 +975,7 @@ func RegisterRegistryWebAPI(router *gin.RouterGroup) error {
 		})
 
 		registryWebAPI.GET("/namespaces/user", web_ui.AuthHandler, listNamespacesForUser)
+		registryWebAPI.GET("/namespaces/audit", web_ui.AuthHandler, web_ui.AdminAuthHandler, listNamespaceAudits)
 
 		registryWebAPI.GET("/namespaces/:id", web_ui.AuthHandler, getNamespace)
 		registryWebAPI.PUT("/namespaces/:id", web_ui.AuthHandler, func(ctx *gin.Context) {
@@ -944,12 +989,17 @@ func RegisterRegistryWebAPI(router *gin.RouterGroup) error {
 		registryWebAPI.PATCH("/namespaces/:id/deny", web_ui.AuthHandler, web_ui.AdminAuthHandler, func(ctx *gin.Context) {
 			updateNamespaceStatus(ctx, server_structs.RegDenied)
 		})
+		registryWebAPI.POST("/namespaces/:id/transfer", web_ui.AuthHandler, web_ui.AdminAuthHandler, handleTransferNamespace)
+		registryWebAPI.POST("/namespaces/:id/rename", web_ui.AuthHandler, web_ui.AdminAuthHandler, handleRenameNamespace)
 	}
 	{
 		registryWebAPI.GET("/topology", listTopologyNamespaces)
 	}
 	{
 		registryWebAPI.GET("/institutions", web_ui.AuthHandler, listInstitutions)
+		registryWebAPI.GET("/institutions/policies", web_ui.AuthHandler, web_ui.AdminAuthHandler, listInstitutionPolicies)
+		registryWebAPI.PUT("/institutions/policies", web_ui.AuthHandler, web_ui.AdminAuthHandler, setInstitutionPolicyHandler)
+		registryWebAPI.DELETE("/institutions/policies", web_ui.AuthHandler, web_ui.AdminAuthHandler, deleteInstitutionPolicyHandler)
 	}
 	return nil
 }
@@ -0,0 +1,294 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package registry
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/openapi"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/web_ui"
+)
+
+type (
+	// Who may call a v2 API route
+	apiAccess int
+
+	// A route of the v2 registry API.  The same definition is used to register the
+	// route with gin and to describe it in the OpenAPI document, so the two can't drift.
+	apiRoute struct {
+		Method      string
+		Path        string // gin path, relative to the API base path
+		OperationID string
+		Summary     string
+		Tag         string
+		Access      apiAccess
+		Query       any // A struct with `form` tags describing the query parameters
+		Request     any // The JSON request body
+		Response    any // The JSON response body on success
+		Handler     gin.HandlerFunc
+	}
+)
+
+const (
+	accessPublic   apiAccess = iota // No authentication
+	accessOptional                  // Anyone, but authenticated users may see more
+	accessUser                      // Any authenticated user
+	accessAdmin                     // Registry admins only
+)
+
+const registryV2BasePath = "/api/v2.0/registry"
+
+type (
+	listNamespaceAuditsRequest struct {
+		Prefix string `form:"prefix" description:"Only list the changes to this prefix and the namespaces beneath it"`
+	}
+
+	// The public key set of a namespace
+	jsonWebKeySet struct {
+		Keys []map[string]interface{} `json:"keys"`
+	}
+
+	// The path parameter of the routes operating on a single namespace
+	namespaceIdParam struct {
+		ID int `uri:"id" description:"The ID of the namespace registration"`
+	}
+)
+
+var registryV2Routes = []apiRoute{
+	{
+		Method: http.MethodGet, Path: "/namespaces", OperationID: "listNamespaces", Tag: "namespaces",
+		Summary: "List namespace registrations. Unauthenticated callers only see approved registrations",
+		Access:  accessOptional, Query: listNamespaceRequest{}, Response: []server_structs.Namespace{},
+		Handler: listNamespaces,
+	},
+	{
+		Method: http.MethodPost, Path: "/namespaces", OperationID: "createNamespace", Tag: "namespaces",
+		Summary: "Register a namespace",
+		Access:  accessUser, Request: server_structs.Namespace{}, Response: server_structs.SimpleApiResp{},
+		Handler: func(ctx *gin.Context) { createUpdateNamespace(ctx, false) },
+	},
+	{
+		Method: http.MethodGet, Path: "/namespaces/fields", OperationID: "listRegistrationFields", Tag: "namespaces",
+		Summary: "List the fields of a namespace registration, including the registry's custom fields",
+		Access:  accessUser, Response: []registrationField{},
+		Handler: getNamespaceRegFields,
+	},
+	{
+		Method: http.MethodGet, Path: "/namespaces/user", OperationID: "listUserNamespaces", Tag: "namespaces",
+		Summary: "List the namespace registrations owned by the authenticated user",
+		Access:  accessUser, Query: listNamespacesForUserRequest{}, Response: []server_structs.Namespace{},
+		Handler: listNamespacesForUser,
+	},
+	{
+		Method: http.MethodGet, Path: "/namespaces/audit", OperationID: "listNamespaceAudits", Tag: "administration",
+		Summary: "List the administrative changes made to namespace registrations, newest first",
+		Access:  accessAdmin, Query: listNamespaceAuditsRequest{}, Response: []NamespaceAudit{},
+		Handler: listNamespaceAudits,
+	},
+	{
+		Method: http.MethodGet, Path: "/namespaces/:id", OperationID: "getNamespace", Tag: "namespaces",
+		Summary: "Get a namespace registration. Non-admin users may only get their own registrations",
+		Access:  accessUser, Response: server_structs.Namespace{},
+		Handler: getNamespace,
+	},
+	{
+		Method: http.MethodPut, Path: "/namespaces/:id", OperationID: "updateNamespace", Tag: "namespaces",
+		Summary: "Update a namespace registration. Non-admin users may only update their own pending registrations",
+		Access:  accessUser, Request: server_structs.Namespace{}, Response: server_structs.SimpleApiResp{},
+		Handler: func(ctx *gin.Context) { createUpdateNamespace(ctx, true) },
+	},
+	{
+		Method: http.MethodDelete, Path: "/namespaces/:id", OperationID: "deleteNamespace", Tag: "administration",
+		Summary: "Delete a namespace registration",
+		Access:  accessAdmin, Response: server_structs.SimpleApiResp{},
+		Handler: deleteNamespace,
+	},
+	{
+		Method: http.MethodGet, Path: "/namespaces/:id/pubkey", OperationID: "getNamespacePubkey", Tag: "namespaces",
+		Summary: "Get the public key set of a namespace",
+		Access:  accessPublic, Response: jsonWebKeySet{},
+		Handler: getNamespaceJWKS,
+	},
+	{
+		Method: http.MethodPatch, Path: "/namespaces/:id/approve", OperationID: "approveNamespace", Tag: "administration",
+		Summary: "Approve a namespace registration",
+		Access:  accessAdmin, Response: server_structs.SimpleApiResp{},
+		Handler: func(ctx *gin.Context) { updateNamespaceStatus(ctx, server_structs.RegApproved) },
+	},
+	{
+		Method: http.MethodPatch, Path: "/namespaces/:id/deny", OperationID: "denyNamespace", Tag: "administration",
+		Summary: "Deny a namespace registration",
+		Access:  accessAdmin, Response: server_structs.SimpleApiResp{},
+		Handler: func(ctx *gin.Context) { updateNamespaceStatus(ctx, server_structs.RegDenied) },
+	},
+	{
+		Method: http.MethodPost, Path: "/namespaces/:id/transfer", OperationID: "transferNamespace", Tag: "administration",
+		Summary: "Transfer the ownership of a namespace, and optionally its subspaces, to a new key and/or owner",
+		Access:  accessAdmin, Request: namespaceTransferReq{}, Response: namespaceChangeRes{},
+		Handler: handleTransferNamespace,
+	},
+	{
+		Method: http.MethodPost, Path: "/namespaces/:id/rename", OperationID: "renameNamespace", Tag: "administration",
+		Summary: "Rename a namespace, or merge it into an existing one",
+		Access:  accessAdmin, Request: namespaceRenameReq{}, Response: namespaceChangeRes{},
+		Handler: handleRenameNamespace,
+	},
+	{
+		Method: http.MethodGet, Path: "/institutions", OperationID: "listInstitutions", Tag: "institutions",
+		Summary: "List the institutions a namespace may be registered under",
+		Access:  accessUser, Response: []registrationFieldOption{},
+		Handler: listInstitutions,
+	},
+	{
+		Method: http.MethodGet, Path: "/topology", OperationID: "listTopologyNamespaces", Tag: "namespaces",
+		Summary: "List the namespaces imported from the OSDF topology",
+		Access:  accessPublic, Response: []Topology{},
+		Handler: listTopologyNamespaces,
+	},
+}
+
+// Returns true if the request was made by a registry admin: either a web UI admin
+// or the holder of a namespace administration token
+func isRegistryAdmin(ctx *gin.Context) bool {
+	if ctx.GetBool("RegistryAdmin") {
+		return true
+	}
+	isAdmin, _ := web_ui.CheckAdmin(ctx.GetString("User"))
+	return isAdmin
+}
+
+// Get the gin middleware authenticating requests to a v2 API route.
+//
+// A request is authenticated either by a namespace administration token in the
+// Authorization header, which grants admin access, or by the web UI login cookie.
+// Cookie-authenticated requests are additionally protected against CSRF like the
+// web UI API.
+func v2AuthHandler(access apiAccess, csrfHandler gin.HandlerFunc) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if access == accessPublic {
+			ctx.Next()
+			return
+		}
+		if strings.HasPrefix(ctx.GetHeader("Authorization"), "Bearer ") {
+			if verifyNamespaceAdminToken(ctx) {
+				ctx.Next()
+			}
+			return
+		}
+		user, groups, err := web_ui.GetUserGroups(ctx)
+		if user == "" {
+			// Let the handler deal with (and report) an invalid cookie for optional auth
+			if access == accessOptional {
+				ctx.Next()
+				return
+			}
+			msg := "Authentication required to perform this operation"
+			if err != nil {
+				msg = "Invalid login cookie"
+			}
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    msg})
+			return
+		}
+		ctx.Set("User", user)
+		ctx.Set("Groups", groups)
+		if access == accessAdmin && !isRegistryAdmin(ctx) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "You don't have permission to perform this action"})
+			return
+		}
+		// The CSRF handler calls the next handler if the request passes its check
+		csrfHandler(ctx)
+	}
+}
+
+// Describe a v2 API route in the OpenAPI document
+func (route apiRoute) openAPIRoute() openapi.Route {
+	oaRoute := openapi.Route{
+		Method:      route.Method,
+		Path:        route.Path,
+		OperationID: route.OperationID,
+		Summary:     route.Summary,
+		Tag:         route.Tag,
+		PathParams:  namespaceIdParam{},
+		Query:       route.Query,
+		Request:     route.Request,
+		Response:    route.Response,
+	}
+	if route.Access != accessPublic {
+		oaRoute.Security = []string{"loginCookie", "adminToken"}
+		oaRoute.AuthOptional = route.Access == accessOptional
+	}
+	return oaRoute
+}
+
+// Build the OpenAPI document describing the v2 registry API
+func getRegistryOpenAPIDoc() *openapi.Document {
+	doc := openapi.NewDocument(openapi.Info{
+		Title:       "Pelican Registry API",
+		Description: "Namespace registration and public key distribution for a Pelican federation",
+		Version:     "2.0",
+	})
+	doc.AddSecurityScheme("adminToken", openapi.SecurityScheme{
+		Type:         "http",
+		Scheme:       "bearer",
+		BearerFormat: "JWT",
+		Description:  "A token signed by the registry's issuer key with the registry.manage_namespace scope. Grants admin access",
+	})
+	doc.AddSecurityScheme("loginCookie", openapi.SecurityScheme{
+		Type:        "apiKey",
+		In:          "cookie",
+		Name:        "login",
+		Description: "The registry web UI login cookie. Unsafe methods also require the X-CSRF-Token header",
+	})
+	if webUrl := param.Server_ExternalWebUrl.GetString(); webUrl != "" {
+		if serverUrl, err := url.JoinPath(webUrl, registryV2BasePath); err == nil {
+			doc.AddServer(serverUrl)
+		}
+	}
+	for _, route := range registryV2Routes {
+		doc.AddRoute("", route.openAPIRoute())
+	}
+	return doc
+}
+
+// Define the v2 registry API, a versioned REST API for the registry web UI as well
+// as external portals integrating with namespace registration.  The API is
+// described by the OpenAPI document served at /api/v2.0/registry/openapi.json
+func RegisterRegistryV2API(router *gin.RouterGroup) error {
+	csrfHandler, err := config.GetCSRFHandler()
+	if err != nil {
+		return err
+	}
+	registryV2API := router.Group(registryV2BasePath, mirrorReadOnlyHandler)
+	for _, route := range registryV2Routes {
+		registryV2API.Handle(route.Method, route.Path, v2AuthHandler(route.Access, csrfHandler), route.Handler)
+	}
+	registryV2API.GET("/openapi.json", openapi.ServeDocument(getRegistryOpenAPIDoc))
+	return nil
+}
@@ -0,0 +1,235 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package registry
+
+import (
+	"context"
+	"crypto/elliptic"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/openapi"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/test_utils"
+	"github.com/pelicanplatform/pelican/token"
+	"github.com/pelicanplatform/pelican/token_scopes"
+)
+
+func TestRegistryOpenAPIDoc(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("Server.ExternalWebUrl", "https://mock-server.com")
+
+	docBytes, err := json.Marshal(getRegistryOpenAPIDoc())
+	require.NoError(t, err)
+	doc := openapi.Document{}
+	require.NoError(t, json.Unmarshal(docBytes, &doc))
+
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+	require.Len(t, doc.Servers, 1)
+	assert.Equal(t, "https://mock-server.com/api/v2.0/registry", doc.Servers[0].URL)
+
+	t.Run("every-route-is-described", func(t *testing.T) {
+		opIds := map[string]bool{}
+		for _, route := range registryV2Routes {
+			path, _ := openapi.ConvertPath(route.Path)
+			require.Contains(t, doc.Paths, path)
+			op := doc.Paths[path][map[string]string{
+				http.MethodGet:    "get",
+				http.MethodPost:   "post",
+				http.MethodPut:    "put",
+				http.MethodPatch:  "patch",
+				http.MethodDelete: "delete",
+			}[route.Method]]
+			require.NotNil(t, op, "%s %s is missing from the document", route.Method, path)
+			assert.Equal(t, route.OperationID, op.OperationID)
+			assert.False(t, opIds[op.OperationID], "duplicated operation ID %s", op.OperationID)
+			opIds[op.OperationID] = true
+			assert.Contains(t, op.Responses, "200")
+			assert.Contains(t, op.Responses, "default")
+			assert.Equal(t, route.Access != accessPublic, len(op.Security) > 0)
+		}
+	})
+
+	t.Run("path-and-query-parameters", func(t *testing.T) {
+		op := doc.Paths["/namespaces/{id}"]["get"]
+		require.NotNil(t, op)
+		require.Len(t, op.Parameters, 1)
+		assert.Equal(t, "id", op.Parameters[0].Name)
+		assert.Equal(t, "path", op.Parameters[0].In)
+		assert.True(t, op.Parameters[0].Required)
+		assert.Equal(t, "integer", op.Parameters[0].Schema.Type)
+
+		op = doc.Paths["/namespaces"]["get"]
+		require.NotNil(t, op)
+		names := []string{}
+		for _, param := range op.Parameters {
+			assert.Equal(t, "query", param.In)
+			names = append(names, param.Name)
+		}
+		assert.ElementsMatch(t, []string{"prefixType", "status", "legacy"}, names)
+	})
+
+	t.Run("namespace-schema", func(t *testing.T) {
+		op := doc.Paths["/namespaces"]["post"]
+		require.NotNil(t, op)
+		require.NotNil(t, op.RequestBody)
+		assert.Equal(t, "#/components/schemas/Namespace", op.RequestBody.Content["application/json"].Schema.Ref)
+
+		nsSchema := doc.Components.Schemas["Namespace"]
+		require.NotNil(t, nsSchema)
+		assert.Equal(t, []string{"prefix", "pubkey"}, nsSchema.Required)
+		assert.True(t, nsSchema.Properties["id"].ReadOnly)
+		assert.False(t, nsSchema.Properties["prefix"].ReadOnly)
+		assert.NotEmpty(t, nsSchema.Properties["pubkey"].Description)
+		assert.Equal(t, "object", nsSchema.Properties["custom_fields"].Type)
+		assert.Equal(t, "#/components/schemas/AdminMetadata", nsSchema.Properties["admin_metadata"].Ref)
+
+		metaSchema := doc.Components.Schemas["AdminMetadata"]
+		require.NotNil(t, metaSchema)
+		assert.Equal(t, "date-time", metaSchema.Properties["approved_at"].Format)
+		assert.True(t, metaSchema.Properties["status"].ReadOnly)
+
+		// Unexported types get capitalized component names
+		assert.Contains(t, doc.Components.Schemas, "NamespaceChangeRes")
+	})
+}
+
+func TestRegistryV2API(t *testing.T) {
+	viper.Reset()
+	ctx, cancel, egrp := test_utils.TestContext(context.Background(), t)
+	defer func() { require.NoError(t, egrp.Wait()) }()
+	defer cancel()
+
+	setupMockRegistryDB(t)
+	defer teardownMockNamespaceDB(t)
+
+	viper.Set("Server.WebPort", 0)
+	viper.Set("Server.ExternalWebUrl", "https://mock-server.com")
+	viper.Set("ConfigDir", t.TempDir())
+	viper.Set("Origin.Port", 0)
+	require.NoError(t, config.InitServer(ctx, config.OriginType))
+	require.NoError(t, config.GeneratePrivateKey(param.IssuerKey.GetString(), elliptic.P256(), false))
+
+	router := gin.New()
+	require.NoError(t, RegisterRegistryV2API(router.Group("/")))
+
+	approvedNs := mockNamespace("/approved", generateTestJwks(t), "", server_structs.AdminMetadata{Status: server_structs.RegApproved})
+	pendingNs := mockNamespace("/pending", generateTestJwks(t), "", server_structs.AdminMetadata{Status: server_structs.RegPending})
+	require.NoError(t, insertMockDBData([]server_structs.Namespace{approvedNs, pendingNs}))
+	pending, err := getNamespaceByPrefix("/pending")
+	require.NoError(t, err)
+	pendingPath := "/api/v2.0/registry/namespaces/" + strconv.Itoa(pending.ID)
+
+	adminTokenCfg := token.NewWLCGToken()
+	adminTokenCfg.Lifetime = time.Minute
+	adminTokenCfg.Issuer = "https://mock-server.com"
+	adminTokenCfg.Subject = "portal"
+	adminTokenCfg.AddAudienceAny()
+	adminTokenCfg.AddScopes(token_scopes.Registry_ManageNamespace)
+	adminTok, err := adminTokenCfg.CreateToken()
+	require.NoError(t, err)
+
+	loginCookie, err := mockAdminToken()
+	require.NoError(t, err)
+
+	doRequest := func(method, path string, header map[string]string, cookie string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		for key, val := range header {
+			req.Header.Set(key, val)
+		}
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "login", Value: cookie})
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	adminAuth := map[string]string{"Authorization": "Bearer " + adminTok}
+
+	t.Run("openapi-doc", func(t *testing.T) {
+		w := doRequest(http.MethodGet, "/api/v2.0/registry/openapi.json", nil, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		doc := openapi.Document{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+		assert.Contains(t, doc.Paths, "/namespaces/{id}/approve")
+	})
+
+	t.Run("anonymous-list-only-approved", func(t *testing.T) {
+		w := doRequest(http.MethodGet, "/api/v2.0/registry/namespaces", nil, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		nss := []server_structs.Namespace{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &nss))
+		require.Len(t, nss, 1)
+		assert.Equal(t, "/approved", nss[0].Prefix)
+	})
+
+	t.Run("token-list-all", func(t *testing.T) {
+		w := doRequest(http.MethodGet, "/api/v2.0/registry/namespaces", adminAuth, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		nss := []server_structs.Namespace{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &nss))
+		assert.Len(t, nss, 2)
+	})
+
+	t.Run("anonymous-rejected", func(t *testing.T) {
+		w := doRequest(http.MethodGet, pendingPath, nil, "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		w = doRequest(http.MethodPatch, pendingPath+"/approve", nil, "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("invalid-token-rejected", func(t *testing.T) {
+		w := doRequest(http.MethodPatch, pendingPath+"/approve", map[string]string{"Authorization": "Bearer " + loginCookie}, "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("cookie-requires-csrf", func(t *testing.T) {
+		w := doRequest(http.MethodPatch, pendingPath+"/approve", nil, loginCookie)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "CSRF")
+
+		// Safe methods don't need a CSRF token
+		w = doRequest(http.MethodGet, pendingPath, nil, loginCookie)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("token-approves-namespace", func(t *testing.T) {
+		w := doRequest(http.MethodGet, pendingPath, adminAuth, "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = doRequest(http.MethodPatch, pendingPath+"/approve", adminAuth, "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		ns, err := getNamespaceById(pending.ID)
+		require.NoError(t, err)
+		assert.Equal(t, server_structs.RegApproved, ns.AdminMetadata.Status)
+		assert.Equal(t, "portal", ns.AdminMetadata.ApproverID)
+	})
+}
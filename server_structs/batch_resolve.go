@@ -0,0 +1,42 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package server_structs
+
+type (
+	// A request to the director's batch resolve API, listing the objects to resolve
+	BatchResolveRequest struct {
+		Paths []string `json:"paths" binding:"required"`
+	}
+
+	// The servers the director would redirect a client to for an object, in order of preference
+	ResolvedObject struct {
+		Path         string             `json:"path"`
+		Namespace    string             `json:"namespace,omitempty"`
+		RequireToken bool               `json:"require_token"`
+		TapeBacked   bool               `json:"tape_backed,omitempty"` // Reads may wait for the object to be staged from tape
+		Servers      []string           `json:"servers"`
+		Availability ObjectAvailability `json:"availability"`
+		Error        string             `json:"error,omitempty"` // Set when the object can't be resolved
+	}
+
+	// The response of the batch resolve API, with the objects in the order they were requested
+	BatchResolveResponse struct {
+		Objects []ResolvedObject `json:"objects"`
+	}
+)
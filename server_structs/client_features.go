@@ -0,0 +1,144 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package server_structs
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type (
+	// A set of capabilities a client may or may not have.  Servers list the features
+	// a client needs in order to use them, and the director only redirects clients to
+	// the servers whose required features they have.
+	//
+	// The set is kept as a bitmask so that ServerAd stays comparable; it's marshaled
+	// to JSON as a list of feature names.
+	ClientFeatures uint
+
+	// The capabilities a client sends to the director in the X-Pelican-Client-Capabilities
+	// header, in the form "version=7.11.0; features=pack,http3"
+	ClientCapabilities struct {
+		Version  string
+		Features ClientFeatures
+	}
+)
+
+const (
+	// The client can unpack objects served as packed archives
+	FeaturePack ClientFeatures = 1 << iota
+	// The client can speak HTTP/3
+	FeatureHTTP3
+
+	ClientCapabilitiesHeader = "X-Pelican-Client-Capabilities"
+)
+
+var clientFeatureNames = []struct {
+	feature ClientFeatures
+	name    string
+}{
+	{FeaturePack, "pack"},
+	{FeatureHTTP3, "http3"},
+}
+
+func lookupClientFeature(name string) (ClientFeatures, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, entry := range clientFeatureNames {
+		if entry.name == name {
+			return entry.feature, true
+		}
+	}
+	return 0, false
+}
+
+// Convert a list of feature names, such as Server.RequiredClientFeatures, to a set of
+// features, returning an error for unknown ones
+func ParseClientFeatures(names []string) (features ClientFeatures, err error) {
+	for _, name := range names {
+		feature, ok := lookupClientFeature(name)
+		if !ok {
+			return 0, errors.Errorf("unknown client feature %q; the known features are %s", name, strings.Join(ClientFeatures(^uint(0)).Names(), ", "))
+		}
+		features |= feature
+	}
+	return
+}
+
+// Returns the names of the features in the set
+func (features ClientFeatures) Names() []string {
+	names := []string{}
+	for _, entry := range clientFeatureNames {
+		if features&entry.feature != 0 {
+			names = append(names, entry.name)
+		}
+	}
+	return names
+}
+
+func (features ClientFeatures) MarshalJSON() ([]byte, error) {
+	return json.Marshal(features.Names())
+}
+
+// Unknown feature names are ignored so that newer servers can advertise to older directors
+func (features *ClientFeatures) UnmarshalJSON(data []byte) error {
+	names := []string{}
+	if err := json.Unmarshal(data, &names); err != nil {
+		return err
+	}
+	*features = 0
+	for _, name := range names {
+		if feature, ok := lookupClientFeature(name); ok {
+			*features |= feature
+		}
+	}
+	return nil
+}
+
+// Parse the value of the X-Pelican-Client-Capabilities header.  Unknown keys and
+// features are ignored so that newer clients can talk to older directors.
+func ParseClientCapabilities(value string) (capabilities ClientCapabilities) {
+	for _, pair := range strings.Split(value, ";") {
+		key, val, found := strings.Cut(pair, "=")
+		if !found {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "version":
+			capabilities.Version = strings.TrimSpace(val)
+		case "features":
+			for _, name := range strings.Split(val, ",") {
+				if feature, ok := lookupClientFeature(name); ok {
+					capabilities.Features |= feature
+				}
+			}
+		}
+	}
+	return
+}
+
+func (capabilities ClientCapabilities) String() string {
+	return "version=" + capabilities.Version + "; features=" + strings.Join(capabilities.Features.Names(), ",")
+}
+
+// Returns true if the client has every one of the required features
+func (capabilities ClientCapabilities) Supports(required ClientFeatures) bool {
+	return required&^capabilities.Features == 0
+}
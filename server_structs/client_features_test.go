@@ -0,0 +1,65 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package server_structs
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseClientCapabilities(t *testing.T) {
+	capabilities := ParseClientCapabilities("version=7.11.0; features=pack, HTTP3,teleport; color=blue")
+	assert.Equal(t, "7.11.0", capabilities.Version)
+	assert.Equal(t, FeaturePack|FeatureHTTP3, capabilities.Features)
+	assert.Equal(t, "version=7.11.0; features=pack,http3", capabilities.String())
+
+	assert.Equal(t, ClientCapabilities{}, ParseClientCapabilities("garbage"))
+
+	assert.True(t, capabilities.Supports(0))
+	assert.True(t, capabilities.Supports(FeatureHTTP3))
+	assert.False(t, ClientCapabilities{Features: FeaturePack}.Supports(FeaturePack|FeatureHTTP3))
+}
+
+func TestClientFeaturesJSON(t *testing.T) {
+	features, err := ParseClientFeatures([]string{"http3", " Pack "})
+	require.NoError(t, err)
+	assert.Equal(t, FeaturePack|FeatureHTTP3, features)
+	_, err = ParseClientFeatures([]string{"teleport"})
+	assert.ErrorContains(t, err, "the known features are pack, http3")
+
+	ad := OriginAdvertiseV2{Name: "origin", RequiredFeatures: features}
+	data, err := json.Marshal(ad)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"required-features":["pack","http3"]`)
+	decoded := OriginAdvertiseV2{}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, features, decoded.RequiredFeatures)
+
+	// Servers that don't require anything don't send the field at all
+	data, err = json.Marshal(OriginAdvertiseV2{Name: "origin"})
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "required-features")
+
+	// Features unknown to this version are dropped
+	require.NoError(t, json.Unmarshal([]byte(`{"required-features":["http3","teleport"]}`), &decoded))
+	assert.Equal(t, FeatureHTTP3, decoded.RequiredFeatures)
+}
@@ -21,6 +21,7 @@ package server_structs
 import (
 	"encoding/json"
 	"net/url"
+	"time"
 )
 
 type (
@@ -44,6 +45,10 @@ type (
 		Writes      bool `json:"Write"`
 		Listings    bool `json:"Listing"`
 		DirectReads bool `json:"FallBackRead"`
+		// The objects are on tape and reads may have to wait for them to be staged
+		TapeBacked bool `json:"TapeBacked,omitempty"`
+		// The cache accepts uploads and writes them back to the origin asynchronously
+		WriteBack bool `json:"WriteBack,omitempty"`
 	}
 
 	NamespaceAdV2 struct {
@@ -55,6 +60,19 @@ type (
 		Generation   []TokenGen    `json:"token-generation"`
 		Issuer       []TokenIssuer `json:"token-issuer"`
 		FromTopology bool          `json:"from-topology"`
+		// The mirror group of the origin's copy of the namespace, if it's replicated
+		// by other origins, and the priority of the copy within the group
+		MirrorGroup    string `json:"mirror-group,omitempty"`
+		MirrorPriority int    `json:"mirror-priority,omitempty"`
+		// The size of the origin's copy of the namespace; nil if the origin doesn't compute it
+		Catalog *CatalogStats `json:"catalog,omitempty"`
+	}
+
+	// The number of objects in a namespace and their total size, as of UpdatedAt
+	CatalogStats struct {
+		Objects   int64     `json:"objects"`
+		Bytes     int64     `json:"bytes"`
+		UpdatedAt time.Time `json:"updated-at"`
 	}
 
 	NamespaceAdV1 struct {
@@ -82,6 +100,16 @@ type (
 		Listings     bool         `json:"enable_listing"`       // True if the origin allows directory listings
 		DirectReads  bool         `json:"enable_fallback_read"` // True if reads from the origin are permitted when no cache is available
 		FromTopology bool         `json:"from_topology"`
+		// The features a client needs to use this server; the director only redirects clients with all of them
+		RequiredFeatures ClientFeatures `json:"required_features,omitempty"`
+		// True if the origin accepts resumable uploads at ResumableUploadPath of its WebURL
+		ResumableUploads bool `json:"resumable_uploads,omitempty"`
+		// How the cache handles uploads at WriteBackPath of its WebURL; nil unless Caps.WriteBack
+		WriteBack *WriteBackPolicy `json:"write_back,omitempty"`
+		// The software and protocol versions the server reported in its advertisement
+		Versions ProtocolVersions `json:"versions"`
+		// The network bandwidth of the server in megabits per second; 0 if unknown
+		IOCapacity int64 `json:"io_capacity,omitempty"`
 	}
 
 	// The struct holding a server's advertisement (including ServerAd and NamespaceAd)
@@ -106,6 +134,16 @@ type (
 		Caps           Capabilities    `json:"capabilities"`
 		Namespaces     []NamespaceAdV2 `json:"namespaces"`
 		Issuer         []TokenIssuer   `json:"token-issuer"`
+		// The features a client needs to use the server, from Server.RequiredClientFeatures
+		RequiredFeatures ClientFeatures `json:"required-features,omitempty"`
+		// True if the origin accepts resumable uploads, from Origin.EnableResumableUploads
+		ResumableUploads bool `json:"resumable-uploads,omitempty"`
+		// How the cache handles write-back uploads, from Cache.EnableWriteBack
+		WriteBack *WriteBackPolicy `json:"write-back,omitempty"`
+		// The software and protocol versions of the server; unset by older servers
+		Versions *ProtocolVersions `json:"versions,omitempty"`
+		// The network bandwidth of the server in megabits per second, from Server.IOCapacity
+		IOCapacity int64 `json:"io-capacity,omitempty"`
 	}
 
 	OriginAdvertiseV1 struct {
@@ -126,6 +164,43 @@ type (
 		Prefix string `json:"prefix"`
 	}
 
+	// The response of the director's /api/v1.0/director/serverList endpoint.  The token
+	// is a JWT signed by the director whose ServerListClaim lists the hosts of the
+	// federation's origins and caches.
+	SignedServerListRes struct {
+		Token string `json:"token"`
+	}
+
+	// An announcement the director publishes to the federation's clients and web UIs,
+	// like a maintenance notice, as returned by the /api/v1.0/director/announcements
+	// endpoint.  Only the announcements in effect are returned.
+	Announcement struct {
+		ID       string               `json:"id"`
+		Message  string               `json:"message"`
+		Severity AnnouncementSeverity `json:"severity"`
+		Start    *time.Time           `json:"start,omitempty"`
+		End      *time.Time           `json:"end,omitempty"`
+	}
+
+	AnnouncementSeverity string
+
+	// The result of one check of an advertisement validated by the director
+	AdCheckStatus string
+
+	AdCheck struct {
+		Name    string        `json:"name"`
+		Target  string        `json:"target,omitempty"` // The namespace or URL checked, if the check is about one
+		Status  AdCheckStatus `json:"status"`
+		Message string        `json:"message,omitempty"`
+	}
+
+	// The response of the director's validateOrigin and validateCache endpoints, which check
+	// an advertisement without registering the server.  Valid is true if no check failed.
+	AdValidationReport struct {
+		Valid  bool      `json:"valid"`
+		Checks []AdCheck `json:"checks"`
+	}
+
 	OpenIdDiscoveryResponse struct {
 		Issuer               string   `json:"issuer"`
 		JwksUri              string   `json:"jwks_uri"`
@@ -150,6 +225,21 @@ const (
 	VaultStrategy StrategyType = "Vault"
 )
 
+const (
+	AdCheckOK      AdCheckStatus = "ok"
+	AdCheckWarning AdCheckStatus = "warning"
+	AdCheckFailed  AdCheckStatus = "failed"
+)
+
+const (
+	AnnouncementInfo     AnnouncementSeverity = "info"
+	AnnouncementWarning  AnnouncementSeverity = "warning"
+	AnnouncementCritical AnnouncementSeverity = "critical"
+)
+
+// The claim of the director's signed server list holding the server hosts
+const ServerListClaim = "pelican_servers"
+
 func (ad *ServerAd) MarshalJSON() ([]byte, error) {
 	type Alias ServerAd
 	return json.Marshal(&struct {
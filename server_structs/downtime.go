@@ -0,0 +1,52 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package server_structs
+
+import "time"
+
+type (
+	// A scheduled window during which the director stops redirecting clients to a server
+	Downtime struct {
+		ID          string    `json:"id" yaml:"id"`
+		ServerName  string    `json:"serverName" yaml:"server"`
+		Description string    `json:"description" yaml:"description"`
+		StartTime   time.Time `json:"startTime" yaml:"start"`
+		EndTime     time.Time `json:"endTime" yaml:"end"`
+		CreatedBy   string    `json:"createdBy" yaml:"-"`
+		CreatedAt   time.Time `json:"createdAt" yaml:"-"`
+	}
+
+	DowntimeStatus string
+)
+
+const (
+	DowntimeActive   DowntimeStatus = "active"
+	DowntimeUpcoming DowntimeStatus = "upcoming"
+	DowntimeEnded    DowntimeStatus = "ended"
+)
+
+// Get the status of the downtime at the given time
+func (d Downtime) Status(now time.Time) DowntimeStatus {
+	if now.Before(d.StartTime) {
+		return DowntimeUpcoming
+	} else if now.Before(d.EndTime) {
+		return DowntimeActive
+	}
+	return DowntimeEnded
+}
@@ -0,0 +1,34 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package server_structs
+
+// The director's estimate of where an object requested through it can be found
+type ObjectAvailability string
+
+const (
+	// Set by the director on redirects when Director.EstimateObjectAvailability is enabled
+	ObjectAvailabilityHeader = "X-Pelican-Object-Availability"
+
+	// The object is at one of the caches near the client
+	AvailabilityCached ObjectAvailability = "cached"
+	// The object exists at the origin but none of the nearby caches has it
+	AvailabilityOrigin ObjectAvailability = "origin"
+	// The director has no estimate for the object (yet)
+	AvailabilityUnknown ObjectAvailability = "unknown"
+)
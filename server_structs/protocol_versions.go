@@ -0,0 +1,115 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package server_structs
+
+import (
+	"github.com/hashicorp/go-version"
+	"github.com/pkg/errors"
+
+	"github.com/pelicanplatform/pelican/config"
+)
+
+type (
+	// An inclusive range of versions of a protocol
+	VersionRange struct {
+		Min string `json:"min"`
+		Max string `json:"max"`
+	}
+
+	// The software version of a server and the range of versions it supports for
+	// each of the protocols spoken between Pelican services.  Servers report it at
+	// /api/v1.0/health and origins and caches include it in their advertisements,
+	// so compatibility can be checked before two services talk to each other.
+	ProtocolVersions struct {
+		Version string `json:"version"`
+		// The server advertisement format: 1 is OriginAdvertiseV1, 2 is OriginAdvertiseV2
+		Advertisement VersionRange `json:"advertisement"`
+		// The WLCG token profile used for the tokens exchanged between services
+		Token VersionRange `json:"token"`
+		// The director API under /api/v1.0/director
+		DirectorAPI VersionRange `json:"directorApi"`
+	}
+)
+
+var supportedProtocolVersions = ProtocolVersions{
+	Advertisement: VersionRange{Min: "1", Max: "2"},
+	Token:         VersionRange{Min: "1.0", Max: "1.0"},
+	DirectorAPI:   VersionRange{Min: "1.0", Max: "1.0"},
+}
+
+// Get the protocol versions supported by this binary
+func GetProtocolVersions() ProtocolVersions {
+	result := supportedProtocolVersions
+	result.Version = config.GetVersion()
+	return result
+}
+
+func (vr VersionRange) parse() (minVer *version.Version, maxVer *version.Version, err error) {
+	if minVer, err = version.NewVersion(vr.Min); err != nil {
+		return
+	}
+	if maxVer, err = version.NewVersion(vr.Max); err != nil {
+		return
+	}
+	if maxVer.LessThan(minVer) {
+		err = errors.Errorf("the maximum version %s is lower than the minimum version %s", vr.Max, vr.Min)
+	}
+	return
+}
+
+// Check whether the two ranges have a version in common
+func (vr VersionRange) Overlaps(other VersionRange) (bool, error) {
+	minVer, maxVer, err := vr.parse()
+	if err != nil {
+		return false, errors.Wrapf(err, "invalid version range %s-%s", vr.Min, vr.Max)
+	}
+	otherMin, otherMax, err := other.parse()
+	if err != nil {
+		return false, errors.Wrapf(err, "invalid version range %s-%s", other.Min, other.Max)
+	}
+	return !maxVer.LessThan(otherMin) && !otherMax.LessThan(minVer), nil
+}
+
+// Return an error naming the first protocol for which the two sets of versions
+// have no version in common.  A range that's missing entirely (e.g. from an older
+// server) is assumed to be compatible.
+func (pv ProtocolVersions) CheckCompatible(other ProtocolVersions) error {
+	protocols := []struct {
+		name         string
+		ours, theirs VersionRange
+	}{
+		{"advertisement", pv.Advertisement, other.Advertisement},
+		{"token", pv.Token, other.Token},
+		{"director API", pv.DirectorAPI, other.DirectorAPI},
+	}
+	for _, protocol := range protocols {
+		if protocol.ours == (VersionRange{}) || protocol.theirs == (VersionRange{}) {
+			continue
+		}
+		overlaps, err := protocol.ours.Overlaps(protocol.theirs)
+		if err != nil {
+			return errors.Wrapf(err, "failed to compare the %s versions", protocol.name)
+		}
+		if !overlaps {
+			return errors.Errorf("no common %s version: versions %s-%s are supported locally but the remote server (version %s) supports %s-%s",
+				protocol.name, protocol.ours.Min, protocol.ours.Max, other.Version, protocol.theirs.Min, protocol.theirs.Max)
+		}
+	}
+	return nil
+}
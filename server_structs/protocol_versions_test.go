@@ -0,0 +1,65 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package server_structs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionRangeOverlaps(t *testing.T) {
+	tests := []struct {
+		a, b     VersionRange
+		overlaps bool
+	}{
+		{VersionRange{"1", "2"}, VersionRange{"2", "3"}, true},
+		{VersionRange{"1", "2"}, VersionRange{"1.5", "1.5"}, true},
+		{VersionRange{"1.0", "1.0"}, VersionRange{"1", "1"}, true},
+		{VersionRange{"1", "2"}, VersionRange{"2.1", "3"}, false},
+		{VersionRange{"3", "4"}, VersionRange{"1", "2"}, false},
+	}
+	for _, test := range tests {
+		overlaps, err := test.a.Overlaps(test.b)
+		require.NoError(t, err)
+		assert.Equal(t, test.overlaps, overlaps, "%v and %v", test.a, test.b)
+	}
+
+	_, err := VersionRange{"2", "1"}.Overlaps(VersionRange{"1", "2"})
+	assert.Error(t, err)
+	_, err = VersionRange{"1", "2"}.Overlaps(VersionRange{"x", "2"})
+	assert.Error(t, err)
+}
+
+func TestCheckCompatible(t *testing.T) {
+	local := GetProtocolVersions()
+	assert.NoError(t, local.CheckCompatible(local))
+
+	// Ranges missing from older servers are ignored
+	assert.NoError(t, local.CheckCompatible(ProtocolVersions{Version: "7.0.0"}))
+
+	remote := local
+	remote.Version = "9.0.0"
+	remote.DirectorAPI = VersionRange{Min: "2.0", Max: "2.0"}
+	err := local.CheckCompatible(remote)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no common director API version")
+	assert.Contains(t, err.Error(), "9.0.0")
+}
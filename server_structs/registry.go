@@ -19,6 +19,7 @@
 package server_structs
 
 import (
+	"encoding/json"
 	"strings"
 	"time"
 )
@@ -96,6 +97,18 @@ type (
 	CheckNamespaceCompleteRes struct {
 		Results map[string]NamespaceCompletenessResult `json:"results"`
 	}
+
+	// The public keys of one namespace in the registry's bulk key listing
+	NamespaceKeys struct {
+		Prefix  string          `json:"prefix"`
+		JwksUri string          `json:"jwks_uri"` // Where the keys are served individually
+		Jwks    json.RawMessage `json:"jwks"`
+		ETag    string          `json:"etag"` // Changes whenever the namespace's keys change
+	}
+
+	NamespaceKeysRes struct {
+		Namespaces []NamespaceKeys `json:"namespaces"`
+	}
 )
 
 const (
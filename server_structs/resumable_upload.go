@@ -0,0 +1,39 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package server_structs
+
+// Resumable uploads follow the core and creation/termination extensions of the
+// TUS 1.0.0 protocol (https://tus.io/protocols/resumable-upload).  The object's
+// federation path is passed as the "path" key of the Upload-Metadata header.
+const (
+	// The origin API for resumable uploads, relative to the origin's web URL
+	ResumableUploadPath = "/api/v1.0/origin/uploads"
+	// Set by the director on upload redirects to an origin supporting resumable uploads
+	ResumableUploadUrlHeader = "X-Pelican-Upload-Url"
+
+	TusVersion          = "1.0.0"
+	TusResumableHeader  = "Tus-Resumable"
+	TusVersionHeader    = "Tus-Version"
+	TusExtensionHeader  = "Tus-Extension"
+	TusUploadOffset     = "Upload-Offset"
+	TusUploadLength     = "Upload-Length"
+	TusUploadMetadata   = "Upload-Metadata"
+	TusPatchContentType = "application/offset+octet-stream"
+	TusExtensions       = "creation,termination"
+)
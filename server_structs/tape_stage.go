@@ -0,0 +1,46 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package server_structs
+
+// Origins exporting tape-backed storage answer reads of objects that are only on tape
+// with a 202 and a Retry-After header while the objects are staged to disk.
+const (
+	// The origin API for staging objects from tape, relative to the origin's web URL
+	TapeStagePath = "/api/v1.0/origin/stage"
+	// Set by the origin on 202 responses to the ID of the stage request bringing the object online
+	TapeStageRequestHeader = "X-Pelican-Stage-Request"
+	// Set by the director on reads of tape-backed namespaces to the stage API of an origin
+	TapeStageUrlHeader = "X-Pelican-Stage-Url"
+)
+
+type (
+	// An object of a stage request, in the format of the WLCG Tape REST API
+	TapeStageFile struct {
+		Path   string `json:"path"`
+		OnDisk bool   `json:"onDisk,omitempty"`
+		State  string `json:"state,omitempty"`
+		Error  string `json:"error,omitempty"`
+	}
+
+	// The status of a stage request, returned by the origin's stage API
+	TapeStageStatus struct {
+		ID    string          `json:"id"`
+		Files []TapeStageFile `json:"files"`
+	}
+)
@@ -0,0 +1,68 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package server_structs
+
+import "time"
+
+type (
+	// How a cache accepting write-back uploads handles them, advertised so clients and
+	// operators know what an acknowledged upload guarantees
+	WriteBackPolicy struct {
+		// Always WriteBackConsistencyEventual: an upload is acknowledged once the cache
+		// has journaled it to disk and reaches the origin later, so until then reads
+		// via the origin or other caches may return the previous version of the object
+		// or none at all
+		Consistency string `json:"consistency"`
+		// How long, in seconds, the cache retries writing an upload to the origin
+		// before giving up on it; rejections by the origin aren't retried
+		RetryLimit int64 `json:"retry-limit"`
+	}
+
+	// The state of an upload a write-back cache accepted, served at WriteBackStatusPath
+	// until a while after the cache is done with the upload
+	WriteBackStatus struct {
+		ID        string    `json:"id"`
+		Path      string    `json:"path"`
+		Status    string    `json:"status"` // WriteBackStatusPending, WriteBackStatusWritten, or WriteBackStatusFailed
+		Attempts  int       `json:"attempts"`
+		LastError string    `json:"last_error,omitempty"`
+		Created   time.Time `json:"created"`
+		Finished  time.Time `json:"finished,omitempty"`
+	}
+)
+
+const (
+	// The cache API accepting write-back uploads, relative to the cache's web URL; the
+	// object's federation path follows it
+	WriteBackPath = "/api/v1.0/cache/writeback"
+	// The cache API reporting the state of an accepted upload; the upload's ID follows it
+	WriteBackStatusPath = "/api/v1.0/cache/writeback-status"
+	// Set by the cache on the response to an accepted upload, holding the path of the
+	// upload's state relative to the cache's web URL
+	WriteBackStatusHeader = "X-Pelican-Write-Back-Status"
+	// Set by the director on upload redirects to a write-back cache, holding the
+	// cache's consistency semantics
+	WriteBackHeader = "X-Pelican-Write-Back"
+
+	WriteBackConsistencyEventual = "eventual"
+
+	WriteBackStatusPending = "pending"
+	WriteBackStatusWritten = "written"
+	WriteBackStatusFailed  = "failed"
+)
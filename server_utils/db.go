@@ -85,13 +85,19 @@ func InitSQLiteDB(dbPath string) (*gorm.DB, error) {
 //
 // The embedded migration files need to be under "/migrations" folder
 func MigrateDB(sqldb *sql.DB, migrationFS embed.FS) error {
+	return MigrateDBFromDir(sqldb, migrationFS, "migrations")
+}
+
+// Update database schema with the embedded migration files under the given folder,
+// for packages that keep more than one database
+func MigrateDBFromDir(sqldb *sql.DB, migrationFS embed.FS, dir string) error {
 	goose.SetBaseFS(migrationFS)
 
 	if err := goose.SetDialect("sqlite3"); err != nil {
 		return err
 	}
 
-	if err := goose.Up(sqldb, "migrations"); err != nil {
+	if err := goose.Up(sqldb, dir); err != nil {
 		return err
 	}
 	return nil
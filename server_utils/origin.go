@@ -56,6 +56,11 @@ type (
 		// Capabilities for the export
 		Capabilities     server_structs.Capabilities `json:"capabilities"`
 		SentinelLocation string                      `json:"sentinelLocation"`
+
+		// Origins exporting replicated copies of a namespace declare the same mirror
+		// group; the director tries the mirrors in increasing order of priority
+		MirrorGroup    string `json:"mirrorGroup,omitempty"`
+		MirrorPriority int    `json:"mirrorPriority,omitempty"`
 	}
 
 	OriginStorageType string
@@ -699,6 +704,12 @@ func CheckOriginSentinelLocations(exports []OriginExport) (ok bool, err error) {
 	return true, nil
 }
 
+// Find the export holding the object at objectPath, i.e. the one with the longest
+// federation prefix of the path; nil if no export holds it
+func FindOriginExport(exports []OriginExport, objectPath string) *OriginExport {
+	return FindLongestPrefix(exports, func(export OriginExport) string { return export.FederationPrefix }, objectPath)
+}
+
 func ResetOriginExports() {
 	originExports = nil
 }
@@ -521,3 +521,24 @@ func TestFederationPrefixValidation(t *testing.T) {
 	runFedPrefixTest(t, "/caches/example.org", false)
 	runFedPrefixTest(t, "/valid/prefix", true) // Test valid prefix
 }
+
+func TestFindOriginExport(t *testing.T) {
+	exports := []OriginExport{
+		{FederationPrefix: "/first"},
+		{FederationPrefix: "/first/nested/"},
+		{FederationPrefix: "/second"},
+	}
+	assert.Equal(t, &exports[0], FindOriginExport(exports, "/first/object"))
+	assert.Equal(t, &exports[0], FindOriginExport(exports, "/first"))
+	assert.Equal(t, &exports[1], FindOriginExport(exports, "/first/nested/object"))
+	assert.Equal(t, &exports[2], FindOriginExport(exports, "/second/object"))
+	// Prefixes only match whole path components
+	assert.Nil(t, FindOriginExport(exports, "/firstly/object"))
+	assert.Nil(t, FindOriginExport(exports, "/third/object"))
+	assert.Nil(t, FindOriginExport(nil, "/first/object"))
+
+	// An export at the root holds everything the others don't
+	exports = append(exports, OriginExport{FederationPrefix: "/"})
+	assert.Equal(t, &exports[3], FindOriginExport(exports, "/third/object"))
+	assert.Equal(t, &exports[1], FindOriginExport(exports, "/first/nested/object"))
+}
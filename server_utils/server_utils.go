@@ -29,6 +29,7 @@ import (
 	"io"
 	"net/http"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -216,3 +217,21 @@ func LaunchWatcherMaintenance(ctx context.Context, dirPaths []string, descriptio
 		}
 	})
 }
+
+// Find the item whose prefix, as returned by prefixOf, is the longest prefix of
+// objectPath; prefixes only match whole path components.  Returns nil if none match.
+func FindLongestPrefix[T any](items []T, prefixOf func(T) string, objectPath string) *T {
+	var found *T
+	foundLen := -1
+	for idx := range items {
+		prefix := strings.TrimSuffix(prefixOf(items[idx]), "/")
+		if prefix != "" && objectPath != prefix && !strings.HasPrefix(objectPath, prefix+"/") {
+			continue
+		}
+		if len(prefix) > foundLen {
+			found = &items[idx]
+			foundLen = len(prefix)
+		}
+	}
+	return found
+}
@@ -0,0 +1,302 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package server_utils
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/metrics"
+)
+
+type (
+	// A unit of work run by a WorkQueue.  The context is cancelled when the context
+	// the task was submitted with is done, the attempt times out, or the queue stops.
+	WorkTask func(ctx context.Context) error
+
+	// How a WorkQueue retries a failed task
+	RetryPolicy struct {
+		// The total number of attempts, including the first; values below 2 disable retries
+		MaxAttempts int
+		// The delay before the first retry, doubled for each further retry
+		InitialBackoff time.Duration
+		// The upper bound of the delay between retries; unbounded if zero
+		MaxBackoff time.Duration
+		// Reports whether a failure should be retried; all failures are retried if nil
+		Retryable func(err error) bool
+	}
+
+	WorkQueueConfig struct {
+		// The name of the queue, used as the "queue" label of its metrics.  Queues
+		// sharing a name are reported together.
+		Name string
+		// The number of tasks run concurrently.  If zero or negative, each task
+		// runs in its own goroutine as soon as it's submitted.
+		Workers int
+		// The number of tasks that may wait for a worker; defaults to Workers
+		Capacity int
+		// The deadline of each attempt at a task; no deadline if zero
+		AttemptTimeout time.Duration
+		Retry          RetryPolicy
+	}
+
+	queuedTask struct {
+		ctx  context.Context
+		task WorkTask
+		done chan error // Receives the result, if non-nil
+	}
+
+	// A bounded pool of workers running tasks with retries, deadlines, and
+	// Prometheus instrumentation.  Create one with NewWorkQueue.
+	WorkQueue struct {
+		config  WorkQueueConfig
+		tasks   chan queuedTask
+		ctx     context.Context
+		cancel  context.CancelFunc
+		lock    sync.RWMutex
+		stopped bool
+		wg      sync.WaitGroup
+	}
+)
+
+var (
+	ErrWorkQueueFull    = errors.New("work queue is full")
+	ErrWorkQueueStopped = errors.New("work queue is stopped")
+)
+
+// Create a work queue and start its workers.  The workers stop, and running tasks
+// are cancelled, when ctx is cancelled or Stop is called.
+func NewWorkQueue(ctx context.Context, config WorkQueueConfig) *WorkQueue {
+	if config.Capacity <= 0 {
+		config.Capacity = config.Workers
+	}
+	if config.Capacity < 0 {
+		config.Capacity = 0
+	}
+	q := &WorkQueue{config: config}
+	q.ctx, q.cancel = context.WithCancel(ctx)
+	if config.Workers > 0 {
+		q.tasks = make(chan queuedTask, config.Capacity)
+		q.wg.Add(config.Workers)
+		for i := 0; i < config.Workers; i++ {
+			go q.worker()
+		}
+	}
+	return q
+}
+
+func (q *WorkQueue) worker() {
+	defer q.wg.Done()
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case qt := <-q.tasks:
+			q.run(qt)
+		}
+	}
+}
+
+func (q *WorkQueue) submit(qt queuedTask, wait bool) error {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+	if q.stopped || q.ctx.Err() != nil {
+		return ErrWorkQueueStopped
+	}
+	if q.config.Workers <= 0 {
+		metrics.PelicanWorkQueueDepth.WithLabelValues(q.config.Name).Inc()
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			q.run(qt)
+		}()
+		return nil
+	}
+
+	// Count the task before it's queued since a worker may pick it up right away
+	depth := metrics.PelicanWorkQueueDepth.WithLabelValues(q.config.Name)
+	depth.Inc()
+	if !wait {
+		select {
+		case q.tasks <- qt:
+		default:
+			depth.Dec()
+			metrics.PelicanWorkQueueTasks.WithLabelValues(q.config.Name, "rejected").Inc()
+			return ErrWorkQueueFull
+		}
+	} else {
+		select {
+		case q.tasks <- qt:
+		case <-qt.ctx.Done():
+			depth.Dec()
+			return qt.ctx.Err()
+		case <-q.ctx.Done():
+			depth.Dec()
+			return ErrWorkQueueStopped
+		}
+	}
+	return nil
+}
+
+// Queue a task to run with the given context, blocking while the queue is full
+// until there's room, ctx is done, or the queue stops
+func (q *WorkQueue) Submit(ctx context.Context, task WorkTask) error {
+	return q.submit(queuedTask{ctx: ctx, task: task}, true)
+}
+
+// Queue a task to run with the given context; returns ErrWorkQueueFull rather than
+// blocking if the queue is full
+func (q *WorkQueue) TrySubmit(ctx context.Context, task WorkTask) error {
+	return q.submit(queuedTask{ctx: ctx, task: task}, false)
+}
+
+// Queue a task and wait for it to finish, returning its result
+func (q *WorkQueue) Do(ctx context.Context, task WorkTask) error {
+	done := make(chan error, 1)
+	if err := q.submit(queuedTask{ctx: ctx, task: task, done: done}, true); err != nil {
+		return err
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stop accepting tasks, cancel the running tasks, and wait for the workers to exit.
+// Tasks still waiting in the queue are dropped.
+func (q *WorkQueue) Stop() {
+	q.cancel()
+	q.lock.Lock()
+	q.stopped = true
+	q.lock.Unlock()
+	q.wg.Wait()
+	for {
+		select {
+		case qt := <-q.tasks:
+			metrics.PelicanWorkQueueDepth.WithLabelValues(q.config.Name).Dec()
+			metrics.PelicanWorkQueueTasks.WithLabelValues(q.config.Name, "expired").Inc()
+			if qt.done != nil {
+				qt.done <- ErrWorkQueueStopped
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (q *WorkQueue) run(qt queuedTask) {
+	name := q.config.Name
+	metrics.PelicanWorkQueueDepth.WithLabelValues(name).Dec()
+	// Don't start tasks whose deadline passed while they were waiting
+	if err := qt.ctx.Err(); err != nil || q.ctx.Err() != nil {
+		if err == nil {
+			err = ErrWorkQueueStopped
+		}
+		metrics.PelicanWorkQueueTasks.WithLabelValues(name, "expired").Inc()
+		if qt.done != nil {
+			qt.done <- err
+		}
+		return
+	}
+
+	metrics.PelicanWorkQueueActiveWorkers.WithLabelValues(name).Inc()
+	defer metrics.PelicanWorkQueueActiveWorkers.WithLabelValues(name).Dec()
+	start := time.Now()
+
+	taskCtx, cancel := context.WithCancel(qt.ctx)
+	defer cancel()
+	stopCancel := context.AfterFunc(q.ctx, cancel)
+	defer stopCancel()
+
+	err := q.runWithRetries(taskCtx, qt.task)
+	metrics.PelicanWorkQueueTaskDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.PelicanWorkQueueTasks.WithLabelValues(name, "failure").Inc()
+	} else {
+		metrics.PelicanWorkQueueTasks.WithLabelValues(name, "success").Inc()
+	}
+	if qt.done != nil {
+		qt.done <- err
+	}
+}
+
+func (q *WorkQueue) runWithRetries(ctx context.Context, task WorkTask) error {
+	policy := q.config.Retry
+	backoff := policy.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := q.attempt(ctx, task)
+		if err == nil {
+			return nil
+		}
+		if attempt >= policy.MaxAttempts || ctx.Err() != nil || (policy.Retryable != nil && !policy.Retryable(err)) {
+			return err
+		}
+		log.Debugf("Attempt %d of a task in work queue %s failed; retrying in %s: %v", attempt, q.config.Name, backoff.String(), err)
+		metrics.PelicanWorkQueueRetries.WithLabelValues(q.config.Name).Inc()
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+		backoff *= 2
+		if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+}
+
+func (q *WorkQueue) attempt(ctx context.Context, task WorkTask) error {
+	if q.config.AttemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, q.config.AttemptTimeout)
+		defer cancel()
+	}
+	return task(ctx)
+}
+
+// Submit the task to the queue every interval until ctx is cancelled.  If the queue
+// is still full from a previous run, that run is skipped rather than piling up
+// behind it.
+func LaunchPeriodicTask(ctx context.Context, egrp *errgroup.Group, q *WorkQueue, interval time.Duration, task WorkTask) {
+	egrp.Go(func() error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+			if err := q.TrySubmit(ctx, task); errors.Is(err, ErrWorkQueueFull) {
+				log.Debugf("Skipping a periodic run in work queue %s because the previous run hasn't finished", q.config.Name)
+			} else if err != nil {
+				return nil
+			}
+		}
+	})
+}
@@ -0,0 +1,220 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package server_utils
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pelicanplatform/pelican/metrics"
+)
+
+func TestWorkQueueConcurrency(t *testing.T) {
+	q := NewWorkQueue(context.Background(), WorkQueueConfig{Name: "test-concurrency", Workers: 2, Capacity: 10})
+	t.Cleanup(q.Stop)
+	successes := metrics.PelicanWorkQueueTasks.WithLabelValues("test-concurrency", "success")
+	initialSuccesses := testutil.ToFloat64(successes)
+
+	var running, maxRunning atomic.Int32
+	wg := sync.WaitGroup{}
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		require.NoError(t, q.Submit(context.Background(), func(ctx context.Context) error {
+			defer wg.Done()
+			now := running.Add(1)
+			defer running.Add(-1)
+			for {
+				prev := maxRunning.Load()
+				if now <= prev || maxRunning.CompareAndSwap(prev, now) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		}))
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), maxRunning.Load())
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(successes)-initialSuccesses == 6
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, float64(0), testutil.ToFloat64(metrics.PelicanWorkQueueDepth.WithLabelValues("test-concurrency")))
+}
+
+func TestWorkQueueFull(t *testing.T) {
+	q := NewWorkQueue(context.Background(), WorkQueueConfig{Name: "test-full", Workers: 1})
+	t.Cleanup(q.Stop)
+	rejected := metrics.PelicanWorkQueueTasks.WithLabelValues("test-full", "rejected")
+	initialRejected := testutil.ToFloat64(rejected)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	require.NoError(t, q.TrySubmit(context.Background(), func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}))
+	<-started
+	// One task may wait while the worker is busy
+	require.NoError(t, q.TrySubmit(context.Background(), func(ctx context.Context) error { return nil }))
+	assert.ErrorIs(t, q.TrySubmit(context.Background(), func(ctx context.Context) error { return nil }), ErrWorkQueueFull)
+	assert.Equal(t, float64(1), testutil.ToFloat64(rejected)-initialRejected)
+
+	// A blocking submission gives up when its context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.Submit(ctx, func(ctx context.Context) error { return nil }), context.DeadlineExceeded)
+	close(release)
+}
+
+func TestWorkQueueRetry(t *testing.T) {
+	errTransient := errors.New("transient failure")
+	errPermanent := errors.New("permanent failure")
+	q := NewWorkQueue(context.Background(), WorkQueueConfig{
+		Name:    "test-retry",
+		Workers: 1,
+		Retry: RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     2 * time.Millisecond,
+			Retryable:      func(err error) bool { return !errors.Is(err, errPermanent) },
+		},
+	})
+	t.Cleanup(q.Stop)
+
+	t.Run("succeeds-after-retries", func(t *testing.T) {
+		retries := metrics.PelicanWorkQueueRetries.WithLabelValues("test-retry")
+		initialRetries := testutil.ToFloat64(retries)
+		attempts := 0
+		err := q.Do(context.Background(), func(ctx context.Context) error {
+			attempts++
+			if attempts < 3 {
+				return errTransient
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, attempts)
+		assert.Equal(t, float64(2), testutil.ToFloat64(retries)-initialRetries)
+	})
+
+	t.Run("gives-up-after-max-attempts", func(t *testing.T) {
+		attempts := 0
+		err := q.Do(context.Background(), func(ctx context.Context) error {
+			attempts++
+			return errTransient
+		})
+		assert.ErrorIs(t, err, errTransient)
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("non-retryable", func(t *testing.T) {
+		attempts := 0
+		err := q.Do(context.Background(), func(ctx context.Context) error {
+			attempts++
+			return errPermanent
+		})
+		assert.ErrorIs(t, err, errPermanent)
+		assert.Equal(t, 1, attempts)
+	})
+}
+
+func TestWorkQueueDeadlines(t *testing.T) {
+	q := NewWorkQueue(context.Background(), WorkQueueConfig{Name: "test-deadline", Workers: 1, AttemptTimeout: 20 * time.Millisecond})
+	t.Cleanup(q.Stop)
+
+	t.Run("attempt-timeout", func(t *testing.T) {
+		err := q.Do(context.Background(), func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("expired-while-queued", func(t *testing.T) {
+		expired := metrics.PelicanWorkQueueTasks.WithLabelValues("test-deadline", "expired")
+		initialExpired := testutil.ToFloat64(expired)
+		release := make(chan struct{})
+		started := make(chan struct{})
+		require.NoError(t, q.Submit(context.Background(), func(ctx context.Context) error {
+			close(started)
+			<-release
+			return nil
+		}))
+		<-started
+
+		ctx, cancel := context.WithCancel(context.Background())
+		ran := atomic.Bool{}
+		require.NoError(t, q.Submit(ctx, func(ctx context.Context) error {
+			ran.Store(true)
+			return nil
+		}))
+		cancel()
+		close(release)
+		assert.Eventually(t, func() bool {
+			return testutil.ToFloat64(expired)-initialExpired == 1
+		}, time.Second, 10*time.Millisecond)
+		assert.False(t, ran.Load())
+	})
+}
+
+func TestWorkQueueStop(t *testing.T) {
+	for _, workers := range []int{1, 0} {
+		q := NewWorkQueue(context.Background(), WorkQueueConfig{Name: "test-stop", Workers: workers})
+		started := make(chan struct{})
+		cancelled := atomic.Bool{}
+		require.NoError(t, q.Submit(context.Background(), func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			cancelled.Store(true)
+			return ctx.Err()
+		}))
+		<-started
+
+		// Stop cancels the running task and waits for it
+		q.Stop()
+		assert.True(t, cancelled.Load())
+		assert.ErrorIs(t, q.Submit(context.Background(), func(ctx context.Context) error { return nil }), ErrWorkQueueStopped)
+	}
+}
+
+func TestLaunchPeriodicTask(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	egrp := &errgroup.Group{}
+	q := NewWorkQueue(ctx, WorkQueueConfig{Name: "test-periodic", Workers: 1})
+
+	runs := atomic.Int32{}
+	LaunchPeriodicTask(ctx, egrp, q, 10*time.Millisecond, func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	})
+	assert.Eventually(t, func() bool { return runs.Load() >= 3 }, time.Second, 5*time.Millisecond)
+	cancel()
+	require.NoError(t, egrp.Wait())
+	q.Stop()
+}
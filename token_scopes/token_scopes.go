@@ -33,6 +33,9 @@ const (
 	Pelican_NamespaceDelete TokenScope = "pelican.namespace_delete"
 	WebUi_Access TokenScope = "web_ui.access"
 	Registry_EditRegistration TokenScope = "registry.edit_registration"
+	Registry_ManageNamespace TokenScope = "registry.manage_namespace"
+	Director_ManageDowntime TokenScope = "director.manage_downtime"
+	Director_ReadSupportInfo TokenScope = "director.read_support_info"
 	Monitoring_Scrape TokenScope = "monitoring.scrape"
 	Monitoring_Query TokenScope = "monitoring.query"
 	Broker_Reverse TokenScope = "broker.reverse"
@@ -51,6 +51,11 @@ func WriteCABundle(filename string) (int, error) {
 		roots = append(roots, getCertsFromPEM(pemContents)...)
 	}
 
+	// And the server CAs approved by the federation's trust bundle
+	if bundle := config.GetCachedTrustBundle(); bundle != nil {
+		roots = append(roots, bundle.GetServerCAs()...)
+	}
+
 	if len(roots) == 0 {
 		return 0, nil
 	}
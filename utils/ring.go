@@ -0,0 +1,88 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package utils
+
+import "sync"
+
+// A fixed-size, in-memory ring buffer of the most recent entries of type T,
+// safe for concurrent use.  The zero value is an empty ring.
+type Ring[T any] struct {
+	mutex   sync.RWMutex
+	entries []T
+	next    int
+	full    bool
+}
+
+// Add an entry to the ring, replacing the oldest entry if the ring is full.
+// The ring is (re)sized to `size` entries, discarding its contents if the size changed.
+func (r *Ring[T]) Add(entry T, size int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if size <= 0 {
+		r.entries = nil
+		r.next = 0
+		r.full = false
+		return
+	}
+	if len(r.entries) != size {
+		r.entries = make([]T, size)
+		r.next = 0
+		r.full = false
+	}
+	r.entries[r.next] = entry
+	r.next = (r.next + 1) % size
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Return the entries in the ring, newest first
+func (r *Ring[T]) Snapshot() []T {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	count := r.next
+	if r.full {
+		count = len(r.entries)
+	}
+	result := make([]T, 0, count)
+	for idx := 0; idx < count; idx++ {
+		pos := (r.next - 1 - idx + len(r.entries)) % len(r.entries)
+		result = append(result, r.entries[pos])
+	}
+	return result
+}
+
+// Return the number of entries in the ring
+func (r *Ring[T]) Len() int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if r.full {
+		return len(r.entries)
+	}
+	return r.next
+}
+
+// Remove all the entries from the ring
+func (r *Ring[T]) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.entries = nil
+	r.next = 0
+	r.full = false
+}
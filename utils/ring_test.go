@@ -0,0 +1,58 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRing(t *testing.T) {
+	ring := &Ring[int]{}
+	assert.Empty(t, ring.Snapshot())
+	assert.Equal(t, 0, ring.Len())
+
+	for idx := 0; idx < 3; idx++ {
+		ring.Add(idx, 5)
+	}
+	assert.Equal(t, []int{2, 1, 0}, ring.Snapshot())
+	assert.Equal(t, 3, ring.Len())
+
+	// Wrap around; only the newest 5 entries remain
+	for idx := 3; idx < 8; idx++ {
+		ring.Add(idx, 5)
+	}
+	assert.Equal(t, []int{7, 6, 5, 4, 3}, ring.Snapshot())
+	assert.Equal(t, 5, ring.Len())
+
+	// Resizing the ring discards its contents
+	ring.Add(100, 2)
+	require.Len(t, ring.Snapshot(), 1)
+	assert.Equal(t, 100, ring.Snapshot()[0])
+
+	// A size of zero disables the ring
+	ring.Add(101, 0)
+	assert.Empty(t, ring.Snapshot())
+
+	ring.Add(102, 2)
+	ring.Reset()
+	assert.Equal(t, 0, ring.Len())
+}
@@ -77,12 +77,22 @@ func CheckValidQuery(transferUrl *url.URL) (err error) {
 	recursive, hasRecursive := query["recursive"]
 	_, hasPack := query["pack"]
 	directRead, hasDirectRead := query["directread"]
+	_, hasWriteBack := query["writeback"]
+	issuer, hasIssuer := query["issuer"]
 
 	// If we have both recursive and pack, we should return a failure
 	if hasRecursive && hasPack {
 		return errors.New("cannot have both recursive and pack query parameters")
 	}
 
+	// The issuer hint must be an issuer's URL
+	if hasIssuer {
+		issuerUrl, err := url.Parse(issuer[0])
+		if err != nil || issuerUrl.Scheme != "https" || issuerUrl.Host == "" {
+			return errors.Errorf("the issuer query parameter must be the https URL of an issuer, not %q", issuer[0])
+		}
+	}
+
 	// If there is an argument in the directread query param, inform the user this is deprecated and their argument will be ignored
 	if hasDirectRead && directRead[0] != "" {
 		log.Warnln("Arguments (true/false) for the ?directread query have been deprecated and will be disallowed in a future release. The argument provided will be ignored")
@@ -96,7 +106,7 @@ func CheckValidQuery(transferUrl *url.URL) (err error) {
 	}
 
 	// If we have no query, or we have recursive or pack, we are good
-	if len(query) == 0 || hasRecursive || hasPack || hasDirectRead {
+	if len(query) == 0 || hasRecursive || hasPack || hasDirectRead || hasWriteBack || hasIssuer {
 		return nil
 	}
 
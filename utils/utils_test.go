@@ -47,6 +47,16 @@ func TestValidQuery(t *testing.T) {
 		assert.NoError(t, err)
 	})
 
+	// Test writeback query passes
+	t.Run("testValidWriteBack", func(t *testing.T) {
+		transferStr := "pelican://something/here?writeback"
+		transferUrl, err := url.Parse(transferStr)
+		assert.NoError(t, err)
+
+		err = CheckValidQuery(transferUrl)
+		assert.NoError(t, err)
+	})
+
 	// Test pack query passes
 	t.Run("testValidPack", func(t *testing.T) {
 		transferStr := "pelican://something/here?pack=tar.gz"
@@ -108,4 +118,21 @@ func TestValidQuery(t *testing.T) {
 		err = CheckValidQuery(transferUrl)
 		assert.NoError(t, err)
 	})
+
+	// Test the issuer hint passes if it's an issuer's URL
+	t.Run("testValidIssuer", func(t *testing.T) {
+		transferUrl, err := url.Parse("pelican://something/here?issuer=https://idp.example")
+		assert.NoError(t, err)
+
+		err = CheckValidQuery(transferUrl)
+		assert.NoError(t, err)
+	})
+
+	t.Run("testInvalidIssuer", func(t *testing.T) {
+		transferUrl, err := url.Parse("pelican://something/here?issuer=idp.example")
+		assert.NoError(t, err)
+
+		err = CheckValidQuery(transferUrl)
+		assert.Error(t, err)
+	})
 }
@@ -31,7 +31,6 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/pelicanplatform/pelican/config"
-	"github.com/pelicanplatform/pelican/metrics"
 	"github.com/pelicanplatform/pelican/param"
 )
 
@@ -112,13 +111,15 @@ func MakeRequest(ctx context.Context, url string, method string, data map[string
 func GetTopologyJSON(ctx context.Context, includeDowned bool) (*TopologyNamespacesJSON, error) {
 	topoNamespaceUrl := param.Federation_TopologyNamespaceUrl.GetString()
 	if topoNamespaceUrl == "" {
-		metrics.SetComponentHealthStatus(metrics.DirectorRegistry_Topology, metrics.StatusCritical, "Topology namespaces.json configuration option (`Federation.TopologyNamespaceURL`) not set")
 		return nil, errors.New("Topology namespaces.json configuration option (`Federation.TopologyNamespaceURL`) not set")
 	}
+	return GetTopologyJSONFromUrl(ctx, topoNamespaceUrl, includeDowned)
+}
 
+// GetTopologyJSONFromUrl returns the namespaces and caches from the topology namespaces.json at topoNamespaceUrl
+func GetTopologyJSONFromUrl(ctx context.Context, topoNamespaceUrl string, includeDowned bool) (*TopologyNamespacesJSON, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, topoNamespaceUrl, nil)
 	if err != nil {
-		metrics.SetComponentHealthStatus(metrics.DirectorRegistry_Topology, metrics.StatusCritical, "Failure when getting OSDF namespace data from topology")
 		return nil, errors.Wrap(err, "Failure when getting OSDF namespace data from topology")
 	}
 
@@ -134,30 +135,24 @@ func GetTopologyJSON(ctx context.Context, includeDowned bool) (*TopologyNamespac
 	client := http.Client{Transport: config.GetTransport()}
 	resp, err := client.Do(req)
 	if err != nil {
-		metrics.SetComponentHealthStatus(metrics.DirectorRegistry_Topology, metrics.StatusCritical, "Failure when getting response for OSDF namespace data")
 		return nil, errors.Wrap(err, "Failure when getting response for OSDF namespace data")
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode > 299 {
-		metrics.SetComponentHealthStatus(metrics.DirectorRegistry_Topology, metrics.StatusCritical, fmt.Sprintf("Error response %v from OSDF namespace endpoint: %v", resp.StatusCode, resp.Status))
 		return nil, fmt.Errorf("error response %v from OSDF namespace endpoint: %v", resp.StatusCode, resp.Status)
 	}
 
 	respBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		metrics.SetComponentHealthStatus(metrics.DirectorRegistry_Topology, metrics.StatusCritical, "Failure when reading OSDF namespace response")
 		return nil, errors.Wrap(err, "Failure when reading OSDF namespace response")
 	}
 
 	var namespaces TopologyNamespacesJSON
 	if err = json.Unmarshal(respBytes, &namespaces); err != nil {
-		metrics.SetComponentHealthStatus(metrics.DirectorRegistry_Topology, metrics.StatusCritical, fmt.Sprintf("Failure when parsing JSON response from topology URL %v", topoNamespaceUrl))
 		return nil, errors.Wrapf(err, "Failure when parsing JSON response from topology URL %v", topoNamespaceUrl)
 	}
 
-	metrics.SetComponentHealthStatus(metrics.DirectorRegistry_Topology, metrics.StatusOK, "")
-
 	return &namespaces, nil
 }
 
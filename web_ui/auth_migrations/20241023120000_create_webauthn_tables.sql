@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE webauthn_credentials (
+    id TEXT PRIMARY KEY,
+    user TEXT NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    public_key BLOB NOT NULL,
+    sign_count INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL,
+    last_used_at DATETIME
+);
+CREATE INDEX idx_webauthn_credentials_user ON webauthn_credentials (user);
+
+CREATE TABLE webauthn_recovery_codes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user TEXT NOT NULL,
+    code_hash TEXT NOT NULL,
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX idx_webauthn_recovery_codes_user ON webauthn_recovery_codes (user);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS webauthn_recovery_codes;
+DROP TABLE IF EXISTS webauthn_credentials;
+-- +goose StatementEnd
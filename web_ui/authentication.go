@@ -39,6 +39,7 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/openapi"
 	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/server_structs"
 	"github.com/pelicanplatform/pelican/token"
@@ -69,12 +70,14 @@ type (
 	OIDCEnabledServerRes struct {
 		ODICEnabledServers []string `json:"oidc_enabled_servers"`
 	}
+
+	loginInitializedRes struct {
+		Initialized bool `json:"initialized"`
+	}
 )
 
 var (
-	authDB       atomic.Pointer[htpasswd.File]
-	currentCode  atomic.Pointer[string]
-	previousCode atomic.Pointer[string]
+	authDB atomic.Pointer[htpasswd.File]
 )
 
 const (
@@ -82,6 +85,8 @@ const (
 	NonAdminRole UserRole = "user"
 )
 
+const authBasePath = "/api/v1.0/auth"
+
 // Periodically re-read the htpasswd file used for password-based authentication
 func periodicAuthDBReload(ctx context.Context) error {
 	ticker := time.NewTicker(30 * time.Second)
@@ -335,6 +340,25 @@ func loginHandler(ctx *gin.Context) {
 		log.Errorf("Failed to generate group info for user %s: %s", login.User, err)
 		groups = nil
 	}
+	if required, err := startSecondFactor(ctx, login.User, groups); err != nil {
+		log.Errorln("Failed to start the second factor of the login:", err)
+		ctx.JSON(http.StatusInternalServerError,
+			server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Failed to start the second factor of the login",
+			})
+		return
+	} else if required {
+		ctx.JSON(http.StatusOK,
+			SecondFactorRequiredRes{
+				SimpleApiResp: server_structs.SimpleApiResp{
+					Status: server_structs.RespOK,
+					Msg:    "A second factor is required to complete the login",
+				},
+				SecondFactorRequired: true,
+			})
+		return
+	}
 	setLoginCookie(ctx, login.User, groups)
 	ctx.JSON(http.StatusOK,
 		server_structs.SimpleApiResp{
@@ -343,27 +367,13 @@ func loginHandler(ctx *gin.Context) {
 		})
 }
 
-// Handle initial code-based login for admin
+// Handle initial login for admin using the one-time bootstrap token.
+//
+// The token is consumed on success; the admin is then expected to set a
+// password via the reset endpoint, which completes the setup.
 func initLoginHandler(ctx *gin.Context) {
-	db := authDB.Load()
-	if db != nil {
-		ctx.JSON(400,
-			server_structs.SimpleApiResp{
-				Status: server_structs.RespFailed,
-				Msg:    "Authentication is already initialized",
-			})
-		return
-	}
-	curCode := currentCode.Load()
-	if curCode == nil {
-		ctx.JSON(400,
-			server_structs.SimpleApiResp{
-				Status: server_structs.RespFailed,
-				Msg:    "Code-based login is not available",
-			})
-		return
-	}
-	prevCode := previousCode.Load()
+	setupMutex.Lock()
+	defer setupMutex.Unlock()
 
 	code := InitLogin{}
 	if ctx.ShouldBind(&code) != nil {
@@ -375,7 +385,8 @@ func initLoginHandler(ctx *gin.Context) {
 		return
 	}
 
-	if code.Code != *curCode && (prevCode == nil || code.Code != *prevCode) {
+	tok, err := checkBootstrapToken(code.Code)
+	if err != nil || !consumeBootstrapToken(tok) {
 		ctx.JSON(401,
 			server_structs.SimpleApiResp{
 				Status: server_structs.RespFailed,
@@ -475,9 +486,68 @@ func listOIDCEnabledServersHandler(ctx *gin.Context) {
 }
 
 // Configure the authentication endpoints for the server web UI
-func configureAuthEndpoints(ctx context.Context, router *gin.Engine, egrp *errgroup.Group) error {
+// The routes of the web UI authentication API.  Login attempts are rate limited
+// by rateLimiter.
+func authRoutes(rateLimiter, csrfHandler gin.HandlerFunc) []openapi.Route {
+	return []openapi.Route{
+		{
+			Method: http.MethodPost, Path: "/login", OperationID: "login", Tag: "auth",
+			Summary: "Log in with a password, setting the login cookie",
+			Request: Login{}, Response: server_structs.SimpleApiResp{},
+			Handlers: []gin.HandlerFunc{rateLimiter, loginHandler},
+		},
+		{
+			Method: http.MethodPost, Path: "/logout", OperationID: "logout", Tag: "auth",
+			Summary:  "Log out, clearing the login cookie",
+			Security: []string{"loginCookie"}, Response: server_structs.SimpleApiResp{},
+			Handlers: []gin.HandlerFunc{AuthHandler, logoutHandler},
+		},
+		{
+			Method: http.MethodPost, Path: "/initLogin", OperationID: "initLogin", Tag: "auth",
+			Summary: "Log in with the one-time activation code to set the initial password",
+			Request: InitLogin{}, Response: server_structs.SimpleApiResp{},
+			Handlers: []gin.HandlerFunc{rateLimiter, setupLockoutHandler, initLoginHandler},
+		},
+		{
+			Method: http.MethodPost, Path: "/setup", OperationID: "setup", Tag: "auth",
+			Summary: "Complete the initial setup of the web UI with the one-time bootstrap token",
+			Request: SetupReq{}, Response: SetupRes{},
+			Handlers: []gin.HandlerFunc{rateLimiter, setupLockoutHandler, setupHandler},
+		},
+		{
+			Method: http.MethodPost, Path: "/resetLogin", OperationID: "resetLogin", Tag: "auth",
+			Summary:  "Reset the admin password",
+			Security: []string{"loginCookie"}, Request: PasswordReset{}, Response: server_structs.SimpleApiResp{},
+			Handlers: []gin.HandlerFunc{AuthHandler, AdminAuthHandler, resetLoginHandler},
+		},
+		// Pass csrfhanlder only to the whoami route to generate CSRF token
+		// while leaving other routes free of CSRF check (we might want to do it some time in the future)
+		{
+			Method: http.MethodGet, Path: "/whoami", OperationID: "whoami", Tag: "auth",
+			Summary:  "Get the authenticated user and a CSRF token in the X-CSRF-Token header",
+			Security: []string{"loginCookie"}, AuthOptional: true, Response: WhoAmIRes{},
+			Handlers: []gin.HandlerFunc{csrfHandler, whoamiHandler},
+		},
+		{
+			Method: http.MethodGet, Path: "/loginInitialized", OperationID: "loginInitialized", Tag: "auth",
+			Summary:  "Check whether the admin password has been set",
+			Response: loginInitializedRes{},
+			Handlers: []gin.HandlerFunc{func(ctx *gin.Context) {
+				ctx.JSON(http.StatusOK, loginInitializedRes{Initialized: authDB.Load() != nil})
+			}},
+		},
+		{
+			Method: http.MethodGet, Path: "/oauth", OperationID: "listOIDCEnabledServers", Tag: "auth",
+			Summary:  "List the server modules whose web UI supports logging in through OIDC",
+			Response: OIDCEnabledServerRes{},
+			Handlers: []gin.HandlerFunc{listOIDCEnabledServersHandler},
+		},
+	}
+}
+
+func configureAuthEndpoints(ctx context.Context, router *gin.Engine, egrp *errgroup.Group) ([]openapi.Route, error) {
 	if router == nil {
-		return errors.New("Web engine configuration passed a nil pointer")
+		return nil, errors.New("Web engine configuration passed a nil pointer")
 	}
 
 	if err := configureAuthDB(); err != nil {
@@ -486,7 +556,7 @@ func configureAuthEndpoints(ctx context.Context, router *gin.Engine, egrp *errgr
 
 	csrfHandler, err := config.GetCSRFHandler()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	limit := param.Server_UILoginRateLimit.GetInt()
 	if limit <= 0 {
@@ -509,25 +579,21 @@ func configureAuthEndpoints(ctx context.Context, router *gin.Engine, egrp *errgr
 		KeyFunc: func(ctx *gin.Context) string { return ctx.ClientIP() },
 	})
 
-	group := router.Group("/api/v1.0/auth")
-	group.POST("/login", mw, loginHandler)
-	group.POST("/logout", AuthHandler, logoutHandler)
-	group.POST("/initLogin", initLoginHandler)
-	group.POST("/resetLogin", AuthHandler, AdminAuthHandler, resetLoginHandler)
-	// Pass csrfhanlder only to the whoami route to generate CSRF token
-	// while leaving other routes free of CSRF check (we might want to do it some time in the future)
-	group.GET("/whoami", csrfHandler, whoamiHandler)
-	group.GET("/loginInitialized", func(ctx *gin.Context) {
-		db := authDB.Load()
-		if db == nil {
-			ctx.JSON(200, gin.H{"initialized": false})
-		} else {
-			ctx.JSON(200, gin.H{"initialized": true})
+	group := router.Group(authBasePath)
+	routes := authRoutes(mw, csrfHandler)
+	openapi.RegisterRoutes(group, routes)
+
+	if param.Server_UIEnableWebAuthn.GetBool() {
+		if err := configureWebAuthn(ctx, group, mw, egrp); err != nil {
+			return nil, err
 		}
-	})
-	group.GET("/oauth", listOIDCEnabledServersHandler)
+	}
 
 	egrp.Go(func() error { return periodicAuthDBReload(ctx) })
 
-	return nil
+	// Describe the routes by their full path, like the rest of the server's web API
+	for idx := range routes {
+		routes[idx].Path = authBasePath + routes[idx].Path
+	}
+	return routes, nil
 }
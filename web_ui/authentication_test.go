@@ -26,10 +26,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"math/rand"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -74,7 +74,10 @@ func TestWaitUntilLogin(t *testing.T) {
 			require.NoError(t, err)
 		}
 		contentsStr := string(contents[:len(contents)-1])
-		require.Equal(t, *currentCode.Load(), contentsStr)
+		tok := currentBootstrapToken.Load()
+		require.NotNil(t, tok)
+		require.Equal(t, tok.value, contentsStr)
+		assert.True(t, tok.expiry.After(time.Now()))
 		break
 	}
 	cancel()
@@ -110,9 +113,9 @@ func TestCodeBasedLogin(t *testing.T) {
 
 	//Invoke the code login API with the correct code, ensure we get a valid code back
 	t.Run("With valid code", func(t *testing.T) {
-		newCode := fmt.Sprintf("%06v", rand.Intn(1000000))
-		currentCode.Store(&newCode)
-		req, err := http.NewRequest("POST", "/api/v1.0/auth/initLogin", strings.NewReader(fmt.Sprintf(`{"code": "%s"}`, newCode)))
+		tok, err := newBootstrapToken(time.Minute)
+		require.NoError(t, err)
+		req, err := http.NewRequest("POST", "/api/v1.0/auth/initLogin", strings.NewReader(fmt.Sprintf(`{"code": "%s"}`, tok.value)))
 		assert.NoError(t, err)
 
 		req.Header.Set("Content-Type", "application/json")
@@ -131,6 +134,26 @@ func TestCodeBasedLogin(t *testing.T) {
 			}
 		}
 		assert.True(t, foundCookie)
+
+		// The token can only be used once
+		req, err = http.NewRequest("POST", "/api/v1.0/auth/initLogin", strings.NewReader(fmt.Sprintf(`{"code": "%s"}`, tok.value)))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		assert.Equal(t, 401, recorder.Code)
+	})
+
+	t.Run("With expired code", func(t *testing.T) {
+		tok, err := newBootstrapToken(-time.Minute)
+		require.NoError(t, err)
+		req, err := http.NewRequest("POST", "/api/v1.0/auth/initLogin", strings.NewReader(fmt.Sprintf(`{"code": "%s"}`, tok.value)))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		assert.Equal(t, 401, recorder.Code)
 	})
 
 	//Invoke the code login with the wrong code, ensure we get a 401
@@ -150,6 +173,93 @@ func TestCodeBasedLogin(t *testing.T) {
 	})
 }
 
+func TestSetupAPI(t *testing.T) {
+	ctx, cancel, egrp := test_utils.TestContext(context.Background(), t)
+	defer func() { require.NoError(t, egrp.Wait()) }()
+	defer cancel()
+
+	dirName := t.TempDir()
+	viper.Reset()
+	viper.Set("ConfigDir", dirName)
+	config.InitConfig()
+	err := config.InitServer(ctx, config.OriginType)
+	require.NoError(t, err)
+	viper.Set("Server.UIPasswordFile", filepath.Join(dirName, "setup-passwd"))
+	t.Cleanup(func() {
+		cleanupAuthDB()
+		setupCompleted.Store(false)
+		currentBootstrapToken.Store(nil)
+	})
+
+	doSetup := func(body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("POST", "/api/v1.0/auth/setup", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	tok, err := newBootstrapToken(time.Minute)
+	require.NoError(t, err)
+
+	t.Run("invalid-token", func(t *testing.T) {
+		recorder := doSetup(`{"token": "foo", "password": "password"}`)
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+		assert.False(t, isSetupCompleted())
+	})
+
+	t.Run("missing-password", func(t *testing.T) {
+		recorder := doSetup(fmt.Sprintf(`{"token": "%s"}`, tok.value))
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		// A failed setup doesn't consume the token
+		assert.Equal(t, tok, currentBootstrapToken.Load())
+	})
+
+	t.Run("oidc-test-failure", func(t *testing.T) {
+		svr := httptest.NewServer(http.NotFoundHandler())
+		defer svr.Close()
+		viper.Set("OIDC.Issuer", svr.URL)
+
+		recorder := doSetup(fmt.Sprintf(`{"token": "%s", "password": "password", "testOIDC": true}`, tok.value))
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "OIDC configuration test failed")
+		assert.False(t, isSetupCompleted())
+	})
+
+	t.Run("success", func(t *testing.T) {
+		recorder := doSetup(fmt.Sprintf(`{"token": "%s", "password": "password", "adminUsers": ["alice"]}`, tok.value))
+		require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+		res := SetupRes{}
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+		assert.NotEmpty(t, res.IssuerKeyID)
+
+		assert.True(t, isSetupCompleted())
+		assert.Nil(t, currentBootstrapToken.Load())
+		assert.True(t, authDB.Load().Match("admin", "password"))
+		isAdmin, _ := CheckAdmin("alice")
+		assert.True(t, isAdmin)
+
+		webCfg, err := os.ReadFile(param.Server_WebConfigFile.GetString())
+		require.NoError(t, err)
+		assert.Contains(t, string(webCfg), "alice")
+	})
+
+	t.Run("locked-after-setup", func(t *testing.T) {
+		tok, err := newBootstrapToken(time.Minute)
+		require.NoError(t, err)
+		recorder := doSetup(fmt.Sprintf(`{"token": "%s", "password": "password"}`, tok.value))
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+
+		req, err := http.NewRequest("POST", "/api/v1.0/auth/initLogin", strings.NewReader(fmt.Sprintf(`{"code": "%s"}`, tok.value)))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+	})
+}
+
 func TestPasswordResetAPI(t *testing.T) {
 	ctx, cancel, egrp := test_utils.TestContext(context.Background(), t)
 	defer func() { require.NoError(t, egrp.Wait()) }()
@@ -108,3 +108,27 @@ func promQueryEngineAuthHandler(av1 *route.Router) gin.HandlerFunc {
 		}
 	}
 }
+
+// Handle the authorization of the metric history API, which is available to the same
+// web users and external services (e.g. Grafana) as the Prometheus query engine APIs
+func metricHistoryAuthHandler(ctx *gin.Context) {
+	if !param.Monitoring_PromQLAuthorization.GetBool() {
+		ctx.Next()
+		return
+	}
+	authOption := token.AuthOption{
+		Sources: []token.TokenSource{token.Cookie, token.Header},
+		Issuers: []token.TokenIssuer{token.LocalIssuer},
+		Scopes:  []token_scopes.TokenScope{token_scopes.Monitoring_Query}}
+
+	status, ok, err := token.Verify(ctx, authOption)
+	if !ok {
+		ctx.AbortWithStatusJSON(status,
+			server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Correct authorization required to access the metric history. " + err.Error(),
+			})
+		return
+	}
+	ctx.Next()
+}
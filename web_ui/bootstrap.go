@@ -0,0 +1,274 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package web_ui
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.uber.org/atomic"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+)
+
+type (
+	// A one-time token allowing the holder to complete the initial setup
+	// of the web UI.  The token is printed to the console and written to
+	// Server.UIActivationCodeFile when the server starts without an admin password.
+	bootstrapToken struct {
+		value  string
+		expiry time.Time
+	}
+
+	SetupReq struct {
+		Token      string   `json:"token"`
+		Password   string   `json:"password"`
+		AdminUsers []string `json:"adminUsers"`
+		TestOIDC   bool     `json:"testOIDC"`
+	}
+
+	SetupRes struct {
+		Status      server_structs.SimpleRespStatus `json:"status"`
+		Msg         string                          `json:"msg,omitempty"`
+		IssuerKeyID string                          `json:"issuerKeyId"`
+		OIDCIssuer  string                          `json:"oidcIssuer,omitempty"`
+	}
+)
+
+var (
+	currentBootstrapToken atomic.Pointer[bootstrapToken]
+
+	// Set once the initial setup has been completed; all setup endpoints are
+	// locked out afterward, even if the password file is later removed.
+	setupCompleted atomic.Bool
+
+	// Serializes the setup process so a token can't be used twice concurrently
+	setupMutex sync.Mutex
+
+	errInvalidBootstrapToken = errors.New("Invalid or expired bootstrap token")
+)
+
+// Generate a new bootstrap token, replacing any existing one
+func newBootstrapToken(lifetime time.Duration) (*bootstrapToken, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, errors.Wrap(err, "failed to generate bootstrap token")
+	}
+	tok := &bootstrapToken{
+		value:  hex.EncodeToString(buf),
+		expiry: time.Now().Add(lifetime),
+	}
+	currentBootstrapToken.Store(tok)
+	return tok, nil
+}
+
+// Check the provided value against the current bootstrap token without consuming it
+func checkBootstrapToken(value string) (*bootstrapToken, error) {
+	tok := currentBootstrapToken.Load()
+	if tok == nil || time.Now().After(tok.expiry) {
+		return nil, errInvalidBootstrapToken
+	}
+	if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(value)), []byte(tok.value)) != 1 {
+		return nil, errInvalidBootstrapToken
+	}
+	return tok, nil
+}
+
+// Consume the bootstrap token; it can't be used again afterward
+func consumeBootstrapToken(tok *bootstrapToken) bool {
+	return currentBootstrapToken.CompareAndSwap(tok, nil)
+}
+
+// Returns true if the initial web UI setup has been completed
+func isSetupCompleted() bool {
+	return setupCompleted.Load() || authDB.Load() != nil
+}
+
+// Reject requests to setup endpoints once the setup has been completed
+func setupLockoutHandler(ctx *gin.Context) {
+	if isSetupCompleted() {
+		ctx.AbortWithStatusJSON(http.StatusForbidden,
+			server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Server setup has already been completed",
+			})
+		return
+	}
+	ctx.Next()
+}
+
+// Persist the list of admin users to the web config and apply it to the running server
+func writeAdminUsers(adminUsers []string) error {
+	webConfigPath := param.Server_WebConfigFile.GetString()
+	if webConfigPath == "" {
+		return errors.New("Server.WebConfigFile value is empty")
+	}
+	webCfgViper := viper.New()
+	webCfgViper.SetConfigFile(webConfigPath)
+	if err := webCfgViper.ReadInConfig(); err != nil {
+		return errors.Wrap(err, "failed to read existing web-based config")
+	}
+	webCfgViper.Set("Server.UIAdminUsers", adminUsers)
+	if err := webCfgViper.WriteConfig(); err != nil {
+		return errors.Wrap(err, "failed to write back the updated web-based config")
+	}
+	viper.Set("Server.UIAdminUsers", adminUsers)
+	return nil
+}
+
+// Complete the initial setup of the web UI in a single request.
+//
+// The request must carry the bootstrap token.  The handler generates the issuer key
+// if needed, optionally tests the OIDC configuration, records additional admin
+// identities, and sets the password for the "admin" user.  The token is consumed
+// and the setup endpoints are locked once the setup succeeds.
+func setupHandler(ctx *gin.Context) {
+	setupMutex.Lock()
+	defer setupMutex.Unlock()
+
+	req := SetupReq{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest,
+			server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Invalid setup request: " + err.Error(),
+			})
+		return
+	}
+	// Re-check under the lock; a concurrent request may have finished the setup
+	if isSetupCompleted() {
+		ctx.JSON(http.StatusForbidden,
+			server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Server setup has already been completed",
+			})
+		return
+	}
+	tok, err := checkBootstrapToken(req.Token)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized,
+			server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    err.Error(),
+			})
+		return
+	}
+	if strings.TrimSpace(req.Password) == "" {
+		ctx.JSON(http.StatusBadRequest,
+			server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Admin password is required",
+			})
+		return
+	}
+
+	res := SetupRes{Status: server_structs.RespOK, Msg: "success"}
+
+	key, err := config.GetIssuerPrivateJWK()
+	if err != nil {
+		log.Errorln("Failed to generate the issuer key during setup:", err)
+		ctx.JSON(http.StatusInternalServerError,
+			server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Failed to generate the issuer key: " + err.Error(),
+			})
+		return
+	}
+	res.IssuerKeyID = key.KeyID()
+
+	if req.TestOIDC {
+		issuerUrl := param.OIDC_Issuer.GetString()
+		if issuerUrl == "" {
+			ctx.JSON(http.StatusBadRequest,
+				server_structs.SimpleApiResp{
+					Status: server_structs.RespFailed,
+					Msg:    "OIDC test requested but OIDC.Issuer is not set",
+				})
+			return
+		}
+		if !strings.HasPrefix(issuerUrl, "https://") && !strings.HasPrefix(issuerUrl, "http://") {
+			issuerUrl = "https://" + issuerUrl
+		}
+		metadata, err := config.GetIssuerMetadata(issuerUrl)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest,
+				server_structs.SimpleApiResp{
+					Status: server_structs.RespFailed,
+					Msg:    "OIDC configuration test failed: " + err.Error(),
+				})
+			return
+		}
+		if _, err := config.GetOIDCClientID(); err != nil {
+			ctx.JSON(http.StatusBadRequest,
+				server_structs.SimpleApiResp{
+					Status: server_structs.RespFailed,
+					Msg:    "OIDC configuration test failed: " + err.Error(),
+				})
+			return
+		}
+		res.OIDCIssuer = metadata.Issuer
+	}
+
+	if len(req.AdminUsers) > 0 {
+		if err := writeAdminUsers(req.AdminUsers); err != nil {
+			log.Errorln("Failed to record admin users during setup:", err)
+			ctx.JSON(http.StatusInternalServerError,
+				server_structs.SimpleApiResp{
+					Status: server_structs.RespFailed,
+					Msg:    "Failed to record admin users: " + err.Error(),
+				})
+			return
+		}
+	}
+
+	if err := WritePasswordEntry("admin", req.Password); err != nil {
+		log.Errorln("Failed to set the admin password during setup:", err)
+		ctx.JSON(http.StatusInternalServerError,
+			server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Failed to set the admin password: " + err.Error(),
+			})
+		return
+	}
+	if err := configureAuthDB(); err != nil {
+		log.Errorln("Error in loading authDB after setup:", err)
+		ctx.JSON(http.StatusInternalServerError,
+			server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Failed to load the password file after setup",
+			})
+		return
+	}
+
+	consumeBootstrapToken(tok)
+	setupCompleted.Store(true)
+	log.Infoln("Initial web UI setup completed")
+	ctx.JSON(http.StatusOK, res)
+}
@@ -18,31 +18,20 @@
 
 "use client"
 
-import {Box, Typography, Grow} from "@mui/material";
+import {Box, Typography, Grow, TextField} from "@mui/material";
 import { useRouter } from 'next/navigation'
 import { useState } from "react";
 
-import CodeInput, {Code} from "../../components/CodeInput";
 import LoadingButton from "../../components/LoadingButton";
 import {getErrorMessage} from "@/helpers/util";
 
 export default function Home() {
 
     const router = useRouter()
-    let [code, _setCode] = useState<Code>([undefined, undefined, undefined, undefined, undefined, undefined])
+    let [code, setCode] = useState<string>("")
     let [loading, setLoading] = useState(false);
     let [error, setError] = useState<string | undefined>(undefined);
 
-    const setCode = (code: Code ) => {
-
-        _setCode(code)
-        setError(undefined)
-
-        if(!code.includes(undefined)) {
-            submit(code.map(x => x!.toString()).join(""))
-        }
-    }
-
     async function submit(code: string) {
 
         setLoading(true)
@@ -75,8 +64,8 @@ export default function Home() {
 
         e.preventDefault()
 
-        if(!code.includes(undefined)) {
-            submit(code.map(x => x!.toString()).join(""))
+        if(code.trim() !== "") {
+            submit(code.trim())
         }
     }
 
@@ -88,12 +77,22 @@ export default function Home() {
                         Activate Website
                     </Typography>
                     <Typography textAlign={"center"} variant={"h6"} component={"p"}>
-                        Enter the activation code displayed on the command line
+                        Enter the one-time token displayed on the command line
                     </Typography>
                 </Box>
                 <Box pt={3} mx={"auto"}>
                     <form onSubmit={onSubmit} action="#">
-                        <CodeInput setCode={setCode} length={6}/>
+                        <TextField
+                            fullWidth
+                            size={"small"}
+                            label={"Token"}
+                            value={code}
+                            onChange={(e) => {
+                                setCode(e.target.value)
+                                setError(undefined)
+                            }}
+                            inputProps={{spellCheck: false, autoComplete: "off"}}
+                        />
                         <Box mt={2} display={"flex"} flexDirection={"column"}>
                             <Grow in={error !== undefined}>
                                 <Typography
@@ -66,10 +66,18 @@ const AdminLogin = () => {
             })
 
             if(response.ok){
-                await mutate(getUser)
-
                 const url = new URL(window.location.href)
                 let returnUrl = url.searchParams.get("returnURL") || ""
+
+                // Admins with a registered security key complete their login with it
+                const json = await response.json()
+                if(json["second_factor_required"]) {
+                    router.push(`/login/webauthn/?returnURL=${encodeURIComponent(returnUrl)}`)
+                    return
+                }
+
+                await mutate(getUser)
+
                 returnUrl = returnUrl.replace(`/view`, "")
                 router.push(returnUrl ? returnUrl : "../")
             } else {
@@ -0,0 +1,112 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+"use client"
+
+import {Box, Button, Collapse, Grow, TextField, Typography} from "@mui/material";
+import {useRouter} from "next/navigation";
+import {useState} from "react";
+import useSWR from "swr";
+
+import LoadingButton from "../../components/LoadingButton";
+import {getUser} from "@/helpers/login";
+import {isWebAuthnSupported, loginWithRecoveryCode, verifyAuthenticator} from "@/helpers/webauthn";
+
+export default function SecondFactor() {
+
+    const router = useRouter()
+    const {mutate} = useSWR("getUser", getUser)
+
+    const [recoveryCode, setRecoveryCode] = useState<string>("")
+    const [showRecovery, setShowRecovery] = useState(false)
+    const [loading, setLoading] = useState(false)
+    const [error, setError] = useState<string | undefined>(undefined)
+
+    async function complete(verify: () => Promise<void>) {
+        setLoading(true)
+        setError(undefined)
+        try {
+            await verify()
+            await mutate(getUser)
+
+            const url = new URL(window.location.href)
+            let returnUrl = url.searchParams.get("returnURL") || ""
+            returnUrl = returnUrl.replace(`/view`, "")
+            router.push(returnUrl ? returnUrl : "../../")
+        } catch (e) {
+            console.error(e)
+            setLoading(false)
+            setError(e instanceof Error ? e.message : "Could not connect to server")
+        }
+    }
+
+    function onSubmitRecoveryCode(e: React.FormEvent<HTMLFormElement>) {
+        e.preventDefault()
+        complete(() => loginWithRecoveryCode(recoveryCode))
+    }
+
+    return (
+        <Box m={"auto"} mt={"20vh"} display={"flex"} flexDirection={"column"}>
+            <Typography textAlign={"center"} variant={"h3"} component={"h3"}>
+                Verify Your Login
+            </Typography>
+            <Box color={"grey"} mt={1} mb={2}>
+                <Typography textAlign={"center"} variant={"h6"} component={"p"}>
+                    Use one of your registered security keys or passkeys
+                </Typography>
+            </Box>
+            <Box display={"flex"} justifyContent={"center"} mb={1}>
+                <LoadingButton
+                    size={"large"}
+                    variant={"contained"}
+                    loading={loading}
+                    disabled={!isWebAuthnSupported()}
+                    onClick={() => complete(verifyAuthenticator)}
+                >
+                    <span>Use Security Key</span>
+                </LoadingButton>
+            </Box>
+            <Grow in={error !== undefined}>
+                <Typography textAlign={"center"} variant={"subtitle2"} color={"error.main"} mb={1}>
+                    {error}
+                </Typography>
+            </Grow>
+            <Box m={"auto"}>
+                <Button size={"small"} variant={"text"} onClick={() => setShowRecovery(!showRecovery)}>
+                    Use a Recovery Code
+                </Button>
+            </Box>
+            <Collapse in={showRecovery}>
+                <form onSubmit={onSubmitRecoveryCode} action="#">
+                    <Box display={"flex"} flexDirection={"column"} alignItems={"center"}>
+                        <TextField
+                            size={"small"}
+                            label={"Recovery Code"}
+                            value={recoveryCode}
+                            onChange={(e) => setRecoveryCode(e.target.value)}
+                            sx={{mb: 1}}
+                        />
+                        <LoadingButton variant="outlined" type={"submit"} loading={loading}>
+                            <span>Verify</span>
+                        </LoadingButton>
+                    </Box>
+                </form>
+            </Collapse>
+        </Box>
+    )
+}
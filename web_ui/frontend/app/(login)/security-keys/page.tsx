@@ -0,0 +1,134 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+"use client"
+
+import {Alert, Box, Button, IconButton, List, ListItem, ListItemText, TextField, Typography} from "@mui/material";
+import {Delete} from "@mui/icons-material";
+import {useState} from "react";
+import useSWR from "swr";
+
+import LoadingButton from "../components/LoadingButton";
+import {secureFetch} from "@/helpers/login";
+import {getErrorMessage} from "@/helpers/util";
+import {isWebAuthnSupported, registerAuthenticator, WebAuthnCredential} from "@/helpers/webauthn";
+
+const getCredentials = async (): Promise<WebAuthnCredential[]> => {
+    const response = await secureFetch("/api/v1.0/auth/webauthn/credentials")
+    if(!response.ok) {
+        throw new Error(await getErrorMessage(response))
+    }
+    return await response.json()
+}
+
+export default function SecurityKeys() {
+
+    const {data: credentials, error: loadError, mutate} = useSWR<WebAuthnCredential[]>("getWebAuthnCredentials", getCredentials)
+
+    const [name, setName] = useState<string>("")
+    const [loading, setLoading] = useState(false)
+    const [error, setError] = useState<string | undefined>(undefined)
+    const [recoveryCodes, setRecoveryCodes] = useState<string[] | undefined>(undefined)
+
+    async function run(action: () => Promise<void>) {
+        setLoading(true)
+        setError(undefined)
+        try {
+            await action()
+            await mutate()
+        } catch (e) {
+            console.error(e)
+            setError(e instanceof Error ? e.message : "Could not connect to server")
+        }
+        setLoading(false)
+    }
+
+    const register = () => run(async () => {
+        const codes = await registerAuthenticator(name)
+        if(codes) {
+            setRecoveryCodes(codes)
+        }
+        setName("")
+    })
+
+    const remove = (id: string) => run(async () => {
+        const response = await secureFetch(`/api/v1.0/auth/webauthn/credentials/${id}`, {method: "DELETE"})
+        if(!response.ok) {
+            throw new Error(await getErrorMessage(response))
+        }
+    })
+
+    const regenerateCodes = () => run(async () => {
+        const response = await secureFetch("/api/v1.0/auth/webauthn/recoveryCodes", {method: "POST"})
+        if(!response.ok) {
+            throw new Error(await getErrorMessage(response))
+        }
+        setRecoveryCodes((await response.json())["recovery_codes"])
+    })
+
+    return (
+        <Box m={"auto"} mt={"10vh"} width={"600px"} display={"flex"} flexDirection={"column"}>
+            <Typography variant={"h4"} component={"h4"} mb={2}>
+                Security Keys
+            </Typography>
+            <Typography variant={"body1"} mb={2}>
+                Once a security key or passkey is registered, logging in as an admin requires it in addition to your
+                password or OAuth login.
+            </Typography>
+            {(loadError || error) && <Alert severity={"error"} sx={{mb: 2}}>{error || loadError?.message}</Alert>}
+            {recoveryCodes &&
+                <Alert severity={"warning"} sx={{mb: 2}}>
+                    Save these recovery codes somewhere safe; they will not be shown again. Each code completes one
+                    login if you lose your security keys.
+                    <Box component={"pre"} mt={1}>{recoveryCodes.join("\n")}</Box>
+                </Alert>
+            }
+            <List>
+                {credentials?.map((credential) => (
+                    <ListItem
+                        key={credential.id}
+                        secondaryAction={
+                            <IconButton edge={"end"} aria-label={"delete"} disabled={loading} onClick={() => remove(credential.id)}>
+                                <Delete/>
+                            </IconButton>
+                        }
+                    >
+                        <ListItemText
+                            primary={credential.name}
+                            secondary={`Registered ${new Date(credential.createdAt).toLocaleString()}` +
+                                (credential.lastUsedAt ? `, last used ${new Date(credential.lastUsedAt).toLocaleString()}` : "")}
+                        />
+                    </ListItem>
+                ))}
+            </List>
+            <Box display={"flex"} alignItems={"center"} mt={1}>
+                <TextField size={"small"} label={"Key Name"} value={name} onChange={(e) => setName(e.target.value)} sx={{mr: 1}}/>
+                <LoadingButton variant={"contained"} loading={loading} disabled={!isWebAuthnSupported()} onClick={register}>
+                    <span>Register Security Key</span>
+                </LoadingButton>
+            </Box>
+            {credentials && credentials.length > 0 &&
+                <Box mt={2}>
+                    <Button variant={"text"} disabled={loading} onClick={regenerateCodes}>
+                        Regenerate Recovery Codes
+                    </Button>
+                </Box>
+            }
+        </Box>
+    )
+}
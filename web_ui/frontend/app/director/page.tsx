@@ -28,6 +28,7 @@ import DirectorCard, {DirectorCardProps} from "@/components/Namespace/DirectorCa
 import {getUser} from "@/helpers/login";
 import FederationOverview from "@/components/FederationOverview";
 import AuthenticatedContent from "@/components/layout/AuthenticatedContent";
+import Announcements from "@/components/Announcements";
 
 
 const getServers = async () => {
@@ -59,6 +60,7 @@ export default function Home() {
 
     return (
         <Box width={"100%"}>
+            <Announcements/>
             <Grid container spacing={2}>
                 <Grid item xs={12} lg={8} xl={6}>
                     <Typography variant={"h4"} pb={2}>Origins</Typography>
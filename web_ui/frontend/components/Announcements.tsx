@@ -0,0 +1,64 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+'use client'
+
+import {Alert, Box} from "@mui/material";
+import useSWR from "swr";
+
+export interface Announcement {
+    id: string
+    message: string
+    severity: "info" | "warning" | "critical"
+    start?: string
+    end?: string
+}
+
+const getAnnouncements = async (): Promise<Announcement[]> => {
+    const response = await fetch("/api/v1.0/director/announcements")
+    if (response.ok) {
+        return await response.json()
+    }
+    return []
+}
+
+// The announcements the director publishes to the federation, from Director.Announcements
+const Announcements = () => {
+
+    const {data: announcements} = useSWR<Announcement[]>("getAnnouncements", getAnnouncements, {fallbackData: []})
+
+    if (!announcements || announcements.length === 0) {
+        return null
+    }
+
+    return (
+        <Box pb={2}>
+            {announcements.map((announcement) =>
+                <Alert
+                    key={announcement.id}
+                    severity={announcement.severity === "critical" ? "error" : announcement.severity}
+                    sx={{mb: 1}}
+                >
+                    {announcement.message}
+                </Alert>
+            )}
+        </Box>
+    )
+}
+
+export default Announcements;
@@ -0,0 +1,113 @@
+import {secureFetch} from "@/helpers/login";
+import {getErrorMessage} from "@/helpers/util";
+
+export interface WebAuthnCredential {
+    id: string
+    name: string
+    createdAt: string
+    lastUsedAt: string | null
+}
+
+function fromBase64Url(value: string): ArrayBuffer {
+    const base64 = value.replace(/-/g, "+").replace(/_/g, "/")
+    const padded = base64 + "=".repeat((4 - base64.length % 4) % 4)
+    return Uint8Array.from(atob(padded), c => c.charCodeAt(0)).buffer
+}
+
+function toBase64Url(value: ArrayBuffer): string {
+    const binary = String.fromCharCode(...Array.from(new Uint8Array(value)))
+    return btoa(binary).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "")
+}
+
+export function isWebAuthnSupported(): boolean {
+    return typeof window !== "undefined" && window.PublicKeyCredential !== undefined
+}
+
+/**
+ * Register a new authenticator for the logged-in admin
+ * @param name A name to tell the authenticator apart from the others
+ * @returns The recovery codes if this is the admin's first authenticator
+ */
+export async function registerAuthenticator(name: string): Promise<string[] | undefined> {
+    let response = await secureFetch("/api/v1.0/auth/webauthn/register/begin", {method: "POST"})
+    if(!response.ok) {
+        throw new Error(await getErrorMessage(response))
+    }
+    const {publicKey} = await response.json()
+    const credential = await navigator.credentials.create({
+        publicKey: {
+            ...publicKey,
+            challenge: fromBase64Url(publicKey.challenge),
+            user: {...publicKey.user, id: fromBase64Url(publicKey.user.id)},
+            excludeCredentials: publicKey.excludeCredentials.map((c: {type: string, id: string}) => ({...c, id: fromBase64Url(c.id)}))
+        }
+    }) as PublicKeyCredential | null
+    if(credential === null) {
+        throw new Error("The authenticator registration was cancelled")
+    }
+    const attestation = credential.response as AuthenticatorAttestationResponse
+
+    response = await secureFetch("/api/v1.0/auth/webauthn/register/finish", {
+        method: "POST",
+        headers: {"Content-Type": "application/json"},
+        body: JSON.stringify({
+            name: name,
+            clientDataJSON: toBase64Url(attestation.clientDataJSON),
+            attestationObject: toBase64Url(attestation.attestationObject)
+        })
+    })
+    if(!response.ok) {
+        throw new Error(await getErrorMessage(response))
+    }
+    return (await response.json())["recovery_codes"]
+}
+
+/**
+ * Complete a login waiting for its second factor with one of the user's authenticators
+ */
+export async function verifyAuthenticator(): Promise<void> {
+    let response = await fetch("/api/v1.0/auth/webauthn/login/begin", {method: "POST"})
+    if(!response.ok) {
+        throw new Error(await getErrorMessage(response))
+    }
+    const {publicKey} = await response.json()
+    const credential = await navigator.credentials.get({
+        publicKey: {
+            ...publicKey,
+            challenge: fromBase64Url(publicKey.challenge),
+            allowCredentials: publicKey.allowCredentials.map((c: {type: string, id: string}) => ({...c, id: fromBase64Url(c.id)}))
+        }
+    }) as PublicKeyCredential | null
+    if(credential === null) {
+        throw new Error("The authenticator verification was cancelled")
+    }
+    const assertion = credential.response as AuthenticatorAssertionResponse
+
+    response = await fetch("/api/v1.0/auth/webauthn/login/finish", {
+        method: "POST",
+        headers: {"Content-Type": "application/json"},
+        body: JSON.stringify({
+            id: credential.id,
+            clientDataJSON: toBase64Url(assertion.clientDataJSON),
+            authenticatorData: toBase64Url(assertion.authenticatorData),
+            signature: toBase64Url(assertion.signature)
+        })
+    })
+    if(!response.ok) {
+        throw new Error(await getErrorMessage(response))
+    }
+}
+
+/**
+ * Complete a login waiting for its second factor with a recovery code
+ */
+export async function loginWithRecoveryCode(code: string): Promise<void> {
+    const response = await fetch("/api/v1.0/auth/webauthn/login/recovery", {
+        method: "POST",
+        headers: {"Content-Type": "application/json"},
+        body: JSON.stringify({code: code})
+    })
+    if(!response.ok) {
+        throw new Error(await getErrorMessage(response))
+    }
+}
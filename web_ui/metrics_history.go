@@ -0,0 +1,337 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package web_ui
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+type (
+	// A named PromQL query whose results are recorded into the metric history
+	HistoryQuery struct {
+		Name  string `mapstructure:"Name" json:"name"`
+		Query string `mapstructure:"Query" json:"query"`
+	}
+
+	// A single downsampled sample of one series returned by a history query
+	MetricSample struct {
+		ID        uint      `gorm:"primaryKey"`
+		Name      string    `gorm:"not null"`
+		Labels    string    `gorm:"not null;default:'{}'"` // JSON-encoded series labels
+		Timestamp time.Time `gorm:"not null"`
+		Value     float64   `gorm:"not null"`
+	}
+
+	MetricHistoryPoint struct {
+		Timestamp time.Time `json:"timestamp"`
+		Value     float64   `json:"value"`
+	}
+
+	// The recorded history of one series, as returned by the metric history API
+	MetricHistorySeries struct {
+		Name   string               `json:"name"`
+		Labels map[string]string    `json:"labels"`
+		Values []MetricHistoryPoint `json:"values"`
+	}
+
+	// Evaluates an instant PromQL query at the given time
+	promQueryFunc func(ctx context.Context, query string, ts time.Time) (promql.Vector, error)
+
+	metricHistoryRecorder struct {
+		queries   []HistoryQuery
+		interval  time.Duration
+		retention time.Duration
+		evaluate  promQueryFunc
+	}
+)
+
+// The database handle for the metric history; nil if the history is disabled
+var historyDB *gorm.DB
+
+//go:embed migrations/*.sql
+var embedMigrations embed.FS
+
+// Queries recorded when Monitoring.HistoryQueries is unset
+var defaultHistoryQueries = []HistoryQuery{
+	{Name: "bytes_served", Query: `sum(increase(xrootd_server_bytes{direction="tx"}[$interval]))`},
+	{Name: "bytes_received", Query: `sum(increase(xrootd_server_bytes{direction="rx"}[$interval]))`},
+	{Name: "transfer_count", Query: `sum by (type) (increase(xrootd_transfer_operations_count[$interval]))`},
+	{Name: "active_servers", Query: `count(up{job="origin_cache_servers"} == 1)`},
+}
+
+func initHistoryDB() error {
+	dbPath := param.Monitoring_HistoryDbLocation.GetString()
+	tdb, err := server_utils.InitSQLiteDB(dbPath)
+	if err != nil {
+		return err
+	}
+	sqldb, err := tdb.DB()
+	if err != nil {
+		return errors.Wrapf(err, "Failed to get sql.DB from gorm DB: %s", dbPath)
+	}
+	if err := server_utils.MigrateDB(sqldb, embedMigrations); err != nil {
+		return err
+	}
+	historyDB = tdb
+	return nil
+}
+
+// Read and validate the history queries, substituting the recording interval
+func getHistoryQueries(interval time.Duration) ([]HistoryQuery, error) {
+	queries := []HistoryQuery{}
+	if param.Monitoring_HistoryQueries.IsSet() {
+		if err := param.Monitoring_HistoryQueries.Unmarshal(&queries); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %s", "Monitoring.HistoryQueries")
+		}
+	} else {
+		queries = append(queries, defaultHistoryQueries...)
+	}
+
+	names := make(map[string]bool, len(queries))
+	for idx, query := range queries {
+		if query.Name == "" || query.Query == "" {
+			return nil, errors.Errorf("entry %d of %s must have both a Name and a Query", idx, "Monitoring.HistoryQueries")
+		}
+		if names[query.Name] {
+			return nil, errors.Errorf("duplicate name %s in %s", query.Name, "Monitoring.HistoryQueries")
+		}
+		names[query.Name] = true
+		queries[idx].Query = strings.ReplaceAll(query.Query, "$interval", model.Duration(interval).String())
+		if _, err := parser.ParseExpr(queries[idx].Query); err != nil {
+			return nil, errors.Wrapf(err, "invalid PromQL for %s in %s", query.Name, "Monitoring.HistoryQueries")
+		}
+	}
+	return queries, nil
+}
+
+// Evaluate all the history queries at the given time and store the results
+func (r *metricHistoryRecorder) record(ctx context.Context, now time.Time) error {
+	samples := []MetricSample{}
+	for _, query := range r.queries {
+		vector, err := r.evaluate(ctx, query.Query, now)
+		if err != nil {
+			log.Warningf("Failed to evaluate metric history query %s: %v", query.Name, err)
+			continue
+		}
+		for _, sample := range vector {
+			labels, err := json.Marshal(sample.Metric.Map())
+			if err != nil {
+				return err
+			}
+			samples = append(samples, MetricSample{
+				Name:      query.Name,
+				Labels:    string(labels),
+				Timestamp: now.UTC(),
+				Value:     sample.F,
+			})
+		}
+	}
+	if len(samples) > 0 {
+		if err := historyDB.Create(&samples).Error; err != nil {
+			return errors.Wrap(err, "failed to store the metric history")
+		}
+	}
+	return nil
+}
+
+// Remove the samples older than the retention period; a non-positive retention keeps everything
+func (r *metricHistoryRecorder) prune(now time.Time) error {
+	if r.retention <= 0 {
+		return nil
+	}
+	cutoff := now.Add(-r.retention).UTC()
+	result := historyDB.Where("timestamp < ?", cutoff).Delete(&MetricSample{})
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "failed to prune the metric history")
+	}
+	if result.RowsAffected > 0 {
+		log.Debugf("Pruned %d metric history samples older than %s", result.RowsAffected, cutoff.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Record the metric history every interval until the context is cancelled.  Samples
+// are aligned to multiples of the interval so the history has a regular resolution
+// across restarts.
+func (r *metricHistoryRecorder) run(ctx context.Context) {
+	log.Infof("Recording the metric history every %s into %s", r.interval.String(), param.Monitoring_HistoryDbLocation.GetString())
+	defer func() {
+		_ = server_utils.ShutdownDB(historyDB)
+	}()
+	for {
+		now := time.Now()
+		next := now.Truncate(r.interval).Add(r.interval)
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if err := r.record(ctx, next); err != nil {
+			log.Warningln("Failed to record the metric history:", err)
+		}
+		if err := r.prune(next); err != nil {
+			log.Warningln(err)
+		}
+	}
+}
+
+// Returns the history of the series matching name (all series if empty) in [start, end]
+func getMetricHistory(name string, start, end time.Time) ([]MetricHistorySeries, error) {
+	samples := []MetricSample{}
+	query := historyDB.Where("timestamp >= ? AND timestamp <= ?", start.UTC(), end.UTC())
+	if name != "" {
+		query = query.Where("name = ?", name)
+	}
+	if err := query.Order("timestamp").Find(&samples).Error; err != nil {
+		return nil, err
+	}
+
+	seriesMap := make(map[string]*MetricHistorySeries)
+	for _, sample := range samples {
+		key := sample.Name + sample.Labels
+		series, ok := seriesMap[key]
+		if !ok {
+			series = &MetricHistorySeries{Name: sample.Name, Labels: map[string]string{}, Values: []MetricHistoryPoint{}}
+			if err := json.Unmarshal([]byte(sample.Labels), &series.Labels); err != nil {
+				return nil, errors.Wrapf(err, "invalid labels recorded for %s", sample.Name)
+			}
+			seriesMap[key] = series
+		}
+		series.Values = append(series.Values, MetricHistoryPoint{Timestamp: sample.Timestamp, Value: sample.Value})
+	}
+
+	keys := make([]string, 0, len(seriesMap))
+	for key := range seriesMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	result := make([]MetricHistorySeries, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, *seriesMap[key])
+	}
+	return result, nil
+}
+
+// Parse a time given either in RFC3339 or as Unix seconds
+func parseHistoryTime(value string) (time.Time, error) {
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func handleGetMetricHistory(ctx *gin.Context) {
+	if historyDB == nil {
+		ctx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "The metric history is not enabled on this server",
+		})
+		return
+	}
+
+	end := time.Now()
+	if endStr := ctx.Query("end"); endStr != "" {
+		parsed, err := parseHistoryTime(endStr)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Invalid end time " + endStr + "; must be RFC3339 or Unix seconds",
+			})
+			return
+		}
+		end = parsed
+	}
+	start := end.Add(-30 * 24 * time.Hour)
+	if startStr := ctx.Query("start"); startStr != "" {
+		parsed, err := parseHistoryTime(startStr)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Invalid start time " + startStr + "; must be RFC3339 or Unix seconds",
+			})
+			return
+		}
+		start = parsed
+	}
+	if start.After(end) {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "The start time must not be after the end time",
+		})
+		return
+	}
+
+	series, err := getMetricHistory(ctx.Query("name"), start, end)
+	if err != nil {
+		log.Errorln("Failed to read the metric history:", err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Failed to read the metric history",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, series)
+}
+
+// Set up the metric history database, register its API, and return the recorder
+// to be run once Prometheus is ready.  Returns a nil recorder if the history is
+// disabled by a non-positive Monitoring.HistoryInterval.
+func configureMetricHistory(engine *gin.Engine, evaluate promQueryFunc) (*metricHistoryRecorder, error) {
+	engine.GET("/api/v1.0/metrics/history", metricHistoryAuthHandler, handleGetMetricHistory)
+
+	interval := param.Monitoring_HistoryInterval.GetDuration()
+	if interval <= 0 {
+		log.Infof("The metric history is disabled because %s is %s", "Monitoring.HistoryInterval", interval.String())
+		return nil, nil
+	}
+	queries, err := getHistoryQueries(interval)
+	if err != nil {
+		return nil, err
+	}
+	if err := initHistoryDB(); err != nil {
+		return nil, errors.Wrap(err, "failed to initialize the metric history database")
+	}
+	return &metricHistoryRecorder{
+		queries:   queries,
+		interval:  interval,
+		retention: param.Monitoring_HistoryRetention.GetDuration(),
+		evaluate:  evaluate,
+	}, nil
+}
@@ -0,0 +1,205 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package web_ui
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+func setupHistoryDB(t *testing.T) {
+	viper.Set("Monitoring.HistoryDbLocation", filepath.Join(t.TempDir(), "history.sqlite"))
+	require.NoError(t, initHistoryDB())
+	t.Cleanup(func() {
+		require.NoError(t, server_utils.ShutdownDB(historyDB))
+		historyDB = nil
+	})
+}
+
+func TestGetHistoryQueries(t *testing.T) {
+	t.Cleanup(func() { viper.Set("Monitoring.HistoryQueries", nil) })
+
+	t.Run("defaults", func(t *testing.T) {
+		viper.Set("Monitoring.HistoryQueries", nil)
+		queries, err := getHistoryQueries(time.Hour)
+		require.NoError(t, err)
+		require.Len(t, queries, len(defaultHistoryQueries))
+		assert.Equal(t, "bytes_served", queries[0].Name)
+		assert.Equal(t, `sum(increase(xrootd_server_bytes{direction="tx"}[1h]))`, queries[0].Query)
+		// The defaults themselves are left untouched
+		assert.Contains(t, defaultHistoryQueries[0].Query, "$interval")
+	})
+
+	t.Run("configured", func(t *testing.T) {
+		viper.Set("Monitoring.HistoryQueries", []map[string]string{
+			{"Name": "reads", "Query": "sum(rate(xrootd_transfer_bytes{type=\"read\"}[$interval]))"},
+		})
+		queries, err := getHistoryQueries(5 * time.Minute)
+		require.NoError(t, err)
+		require.Len(t, queries, 1)
+		assert.Equal(t, "sum(rate(xrootd_transfer_bytes{type=\"read\"}[5m]))", queries[0].Query)
+	})
+
+	t.Run("duplicate-name", func(t *testing.T) {
+		viper.Set("Monitoring.HistoryQueries", []map[string]string{
+			{"Name": "reads", "Query": "up"},
+			{"Name": "reads", "Query": "up"},
+		})
+		_, err := getHistoryQueries(time.Hour)
+		assert.ErrorContains(t, err, "duplicate name reads")
+	})
+
+	t.Run("invalid-promql", func(t *testing.T) {
+		viper.Set("Monitoring.HistoryQueries", []map[string]string{{"Name": "broken", "Query": "sum(("}})
+		_, err := getHistoryQueries(time.Hour)
+		assert.ErrorContains(t, err, "invalid PromQL for broken")
+	})
+}
+
+func TestMetricHistoryRecorder(t *testing.T) {
+	setupHistoryDB(t)
+
+	recorder := &metricHistoryRecorder{
+		queries: []HistoryQuery{
+			{Name: "bytes_served", Query: "bytes"},
+			{Name: "transfer_count", Query: "transfers"},
+			{Name: "broken", Query: "broken"},
+		},
+		interval:  time.Hour,
+		retention: 48 * time.Hour,
+		evaluate: func(ctx context.Context, query string, ts time.Time) (promql.Vector, error) {
+			hours := float64(ts.Unix() / 3600)
+			switch query {
+			case "bytes":
+				return promql.Vector{{F: hours, Metric: labels.EmptyLabels()}}, nil
+			case "transfers":
+				return promql.Vector{
+					{F: 1, Metric: labels.FromStrings("type", "read")},
+					{F: 2, Metric: labels.FromStrings("type", "write")},
+				}, nil
+			}
+			return nil, errors.New("query failed")
+		},
+	}
+
+	base := time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 72; i++ {
+		now := base.Add(time.Duration(i) * time.Hour)
+		// A failing query doesn't prevent the others from being recorded
+		require.NoError(t, recorder.record(context.Background(), now))
+		require.NoError(t, recorder.prune(now))
+	}
+	last := base.Add(71 * time.Hour)
+
+	series, err := getMetricHistory("bytes_served", base, last)
+	require.NoError(t, err)
+	require.Len(t, series, 1)
+	assert.Empty(t, series[0].Labels)
+	// Samples older than the retention were pruned
+	require.Len(t, series[0].Values, 49)
+	assert.True(t, series[0].Values[0].Timestamp.Equal(last.Add(-48*time.Hour)))
+	assert.Equal(t, float64(last.Unix()/3600), series[0].Values[48].Value)
+
+	series, err = getMetricHistory("transfer_count", last.Add(-2*time.Hour), last)
+	require.NoError(t, err)
+	require.Len(t, series, 2)
+	assert.Equal(t, map[string]string{"type": "read"}, series[0].Labels)
+	assert.Equal(t, map[string]string{"type": "write"}, series[1].Labels)
+	assert.Len(t, series[1].Values, 3)
+	assert.Equal(t, float64(2), series[1].Values[0].Value)
+
+	series, err = getMetricHistory("", last, last)
+	require.NoError(t, err)
+	assert.Len(t, series, 3)
+}
+
+func TestHandleGetMetricHistory(t *testing.T) {
+	viper.Set("Monitoring.PromQLAuthorization", false)
+	t.Cleanup(func() { viper.Set("Monitoring.PromQLAuthorization", true) })
+
+	engine := gin.New()
+	engine.GET("/api/v1.0/metrics/history", metricHistoryAuthHandler, handleGetMetricHistory)
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/api/v1.0/metrics/history?"+query, nil)
+		require.NoError(t, err)
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("history-disabled", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get("").Code)
+	})
+
+	setupHistoryDB(t)
+	now := time.Now().Truncate(time.Second).UTC()
+	require.NoError(t, historyDB.Create(&[]MetricSample{
+		{Name: "bytes_served", Labels: "{}", Timestamp: now.Add(-40 * 24 * time.Hour), Value: 1},
+		{Name: "bytes_served", Labels: "{}", Timestamp: now.Add(-2 * time.Hour), Value: 2},
+		{Name: "bytes_served", Labels: "{}", Timestamp: now.Add(-time.Hour), Value: 3},
+		{Name: "active_servers", Labels: "{}", Timestamp: now.Add(-time.Hour), Value: 10},
+	}).Error)
+
+	t.Run("default-range", func(t *testing.T) {
+		w := get("name=bytes_served")
+		require.Equal(t, http.StatusOK, w.Code)
+		series := []MetricHistorySeries{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &series))
+		require.Len(t, series, 1)
+		// The sample from 40 days ago is outside the default 30 day window
+		require.Len(t, series[0].Values, 2)
+		assert.Equal(t, float64(2), series[0].Values[0].Value)
+		assert.Equal(t, float64(3), series[0].Values[1].Value)
+	})
+
+	t.Run("explicit-range", func(t *testing.T) {
+		start := strconv.FormatInt(now.Add(-90*time.Minute).Unix(), 10)
+		w := get("start=" + start + "&end=" + now.Format(time.RFC3339))
+		require.Equal(t, http.StatusOK, w.Code)
+		series := []MetricHistorySeries{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &series))
+		require.Len(t, series, 2)
+		assert.Equal(t, "active_servers", series[0].Name)
+		assert.Equal(t, "bytes_served", series[1].Name)
+		require.Len(t, series[1].Values, 1)
+		assert.Equal(t, float64(3), series[1].Values[0].Value)
+	})
+
+	t.Run("bad-requests", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, get("start=yesterday").Code)
+		assert.Equal(t, http.StatusBadRequest, get("end=tomorrow").Code)
+		assert.Equal(t, http.StatusBadRequest, get("start=200&end=100").Code)
+	})
+}
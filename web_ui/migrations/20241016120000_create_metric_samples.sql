@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE metric_samples (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    labels TEXT NOT NULL DEFAULT '{}',
+    timestamp DATETIME NOT NULL,
+    value REAL NOT NULL
+);
+CREATE INDEX idx_metric_samples_name_timestamp ON metric_samples (name, timestamp);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS metric_samples;
+-- +goose StatementEnd
@@ -0,0 +1,476 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package web_ui
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+	"gorm.io/gorm"
+
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/openapi"
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+type (
+	NotificationSeverity string
+
+	// A server event that needs the attention of the web UI admins.  Events raised
+	// again before an admin acknowledges them update the existing notification.
+	Notification struct {
+		ID             int                  `json:"id" gorm:"primaryKey"`
+		Key            string               `json:"key" gorm:"not null"` // Identifies the condition the notification is about
+		Source         string               `json:"source"`
+		Severity       NotificationSeverity `json:"severity" gorm:"not null"`
+		Title          string               `json:"title" gorm:"not null"`
+		Message        string               `json:"message"`
+		Count          int                  `json:"count"` // The number of times the event was raised
+		CreatedAt      time.Time            `json:"createdAt"`
+		UpdatedAt      time.Time            `json:"updatedAt"`
+		AcknowledgedAt *time.Time           `json:"acknowledgedAt"`
+		AcknowledgedBy string               `json:"acknowledgedBy,omitempty"`
+	}
+
+	createNotificationReq struct {
+		Severity NotificationSeverity `json:"severity" binding:"required,oneof=critical warning info"`
+		Title    string               `json:"title" binding:"required"`
+		Message  string               `json:"message"`
+	}
+
+	listNotificationsReq struct {
+		// "false" (the default) for the notifications waiting for an acknowledgement,
+		// "true" for the acknowledged ones, or "all"
+		Acknowledged string `form:"acknowledged"`
+	}
+
+	notificationIdParam struct {
+		ID int `uri:"id"`
+	}
+)
+
+const (
+	NotificationCritical NotificationSeverity = "critical"
+	NotificationWarning  NotificationSeverity = "warning"
+	NotificationInfo     NotificationSeverity = "info"
+
+	// Who acknowledges the notifications whose condition cleared by itself
+	notificationSystemUser = "system"
+)
+
+var (
+	// The database of the notifications; nil until the web API is configured
+	notificationDB atomic.Pointer[gorm.DB]
+
+	//go:embed notification_migrations/*.sql
+	embedNotificationMigrations embed.FS
+
+	// Sends an email; replaced in tests
+	sendMail = smtp.SendMail
+
+	healthListenerOnce sync.Once
+)
+
+func (Notification) TableName() string {
+	return "notifications"
+}
+
+func initNotificationDB() error {
+	dbPath := param.Server_NotificationDbLocation.GetString()
+	tdb, err := server_utils.InitSQLiteDB(dbPath)
+	if err != nil {
+		return err
+	}
+	sqldb, err := tdb.DB()
+	if err != nil {
+		return errors.Wrapf(err, "Failed to get sql.DB from gorm DB: %s", dbPath)
+	}
+	if err := server_utils.MigrateDBFromDir(sqldb, embedNotificationMigrations, "notification_migrations"); err != nil {
+		return err
+	}
+	notificationDB.Store(tdb)
+	return nil
+}
+
+// Raise a notification for the web UI admins.  If a notification with the same key is
+// still waiting for an acknowledgement, it's updated instead of adding another one.
+// Notifications raised before the web API is configured are only logged.
+func NotifyAdmins(source, key string, severity NotificationSeverity, title, message string) {
+	db := notificationDB.Load()
+	if db == nil {
+		log.Debugf("Dropping the notification %q raised before the notifications were configured: %s", key, title)
+		return
+	}
+	now := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		existing := Notification{}
+		result := tx.Where("key = ? AND acknowledged_at IS NULL", key).Limit(1).Find(&existing)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			return tx.Model(&existing).Updates(map[string]interface{}{
+				"severity":   severity,
+				"title":      title,
+				"message":    message,
+				"count":      gorm.Expr("count + 1"),
+				"updated_at": now,
+			}).Error
+		}
+		return tx.Create(&Notification{
+			Key:       key,
+			Source:    source,
+			Severity:  severity,
+			Title:     title,
+			Message:   message,
+			Count:     1,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}).Error
+	})
+	if err != nil {
+		log.Errorf("Failed to save the notification %q: %v", key, err)
+	}
+}
+
+// Acknowledge the notification with the given key on behalf of the admins, once the
+// condition it's about has cleared
+func ResolveAdminNotification(key string) {
+	db := notificationDB.Load()
+	if db == nil {
+		return
+	}
+	now := time.Now()
+	err := db.Model(&Notification{}).Where("key = ? AND acknowledged_at IS NULL", key).
+		Updates(map[string]interface{}{"acknowledged_at": now, "acknowledged_by": notificationSystemUser}).Error
+	if err != nil {
+		log.Errorf("Failed to resolve the notification %q: %v", key, err)
+	}
+}
+
+// Raise a notification when a component of the server becomes critical, and resolve it
+// once the component is healthy again
+func notifyHealthStatusChange(component metrics.HealthStatusComponent, previous, current metrics.HealthStatusEnum, msg string) {
+	key := "health/" + component.String()
+	switch current {
+	case metrics.StatusCritical:
+		NotifyAdmins("health", key, NotificationCritical, fmt.Sprintf("The %s component of the server is critical", component.String()), msg)
+	case metrics.StatusOK:
+		if previous == metrics.StatusCritical {
+			ResolveAdminNotification(key)
+		}
+	}
+}
+
+func listNotifications(acknowledged string) ([]Notification, error) {
+	db := notificationDB.Load()
+	if db == nil {
+		return nil, errors.New("the notifications aren't configured")
+	}
+	query := db.Order("updated_at DESC")
+	switch acknowledged {
+	case "", "false":
+		query = query.Where("acknowledged_at IS NULL")
+	case "true":
+		query = query.Where("acknowledged_at IS NOT NULL")
+	case "all":
+	default:
+		return nil, errors.Errorf("invalid value %q of acknowledged; it must be true, false, or all", acknowledged)
+	}
+	notifications := []Notification{}
+	if err := query.Find(&notifications).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to list the notifications")
+	}
+	return notifications, nil
+}
+
+// Acknowledge a notification, or all the notifications waiting for an acknowledgement
+// if id is 0.  Returns the number of notifications acknowledged.
+func acknowledgeNotifications(id int, user string) (int64, error) {
+	db := notificationDB.Load()
+	if db == nil {
+		return 0, errors.New("the notifications aren't configured")
+	}
+	query := db.Model(&Notification{}).Where("acknowledged_at IS NULL")
+	if id != 0 {
+		query = query.Where("id = ?", id)
+	}
+	result := query.Updates(map[string]interface{}{"acknowledged_at": time.Now(), "acknowledged_by": user})
+	if result.Error != nil {
+		return 0, errors.Wrap(result.Error, "failed to acknowledge the notifications")
+	}
+	return result.RowsAffected, nil
+}
+
+func handleListNotifications(ctx *gin.Context) {
+	req := listNotificationsReq{}
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Invalid query parameters: " + err.Error(),
+		})
+		return
+	}
+	notifications, err := listNotifications(req.Acknowledged)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, notifications)
+}
+
+func handleCreateNotification(ctx *gin.Context) {
+	req := createNotificationReq{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Invalid notification: " + err.Error(),
+		})
+		return
+	}
+	user := ctx.GetString("User")
+	// Notifications added by the admins are never merged with other ones
+	key := fmt.Sprintf("admin/%s/%d", user, time.Now().UnixNano())
+	NotifyAdmins("admin:"+user, key, req.Severity, req.Title, req.Message)
+	ctx.JSON(http.StatusOK, server_structs.SimpleApiResp{Status: server_structs.RespOK})
+}
+
+func handleAcknowledgeNotification(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil || id <= 0 {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Invalid notification ID; it must be a positive integer",
+		})
+		return
+	}
+	count, err := acknowledgeNotifications(id, ctx.GetString("User"))
+	if err != nil {
+		log.Errorln("Failed to acknowledge a notification:", err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Failed to acknowledge the notification",
+		})
+		return
+	}
+	if count == 0 {
+		ctx.JSON(http.StatusNotFound, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("No notification with ID %d waits for an acknowledgement", id),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, server_structs.SimpleApiResp{Status: server_structs.RespOK})
+}
+
+func handleAcknowledgeAllNotifications(ctx *gin.Context) {
+	count, err := acknowledgeNotifications(0, ctx.GetString("User"))
+	if err != nil {
+		log.Errorln("Failed to acknowledge the notifications:", err)
+		ctx.JSON(http.StatusInternalServerError, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Failed to acknowledge the notifications",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, server_structs.SimpleApiResp{
+		Status: server_structs.RespOK,
+		Msg:    fmt.Sprintf("Acknowledged %d notifications", count),
+	})
+}
+
+var notificationRoutes = []openapi.Route{
+	{
+		Method: http.MethodGet, Path: "/api/v1.0/notifications", OperationID: "listNotifications", Tag: "notifications",
+		Summary:  "List the notifications raised for the server's admins",
+		Security: []string{"loginCookie"}, Query: listNotificationsReq{}, Response: []Notification{},
+		Handlers: []gin.HandlerFunc{AuthHandler, AdminAuthHandler, handleListNotifications},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/v1.0/notifications", OperationID: "createNotification", Tag: "notifications",
+		Summary:  "Add a notification for the server's admins",
+		Security: []string{"loginCookie"}, Request: createNotificationReq{}, Response: server_structs.SimpleApiResp{},
+		Handlers: []gin.HandlerFunc{AuthHandler, AdminAuthHandler, handleCreateNotification},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/v1.0/notifications/acknowledge", OperationID: "acknowledgeAllNotifications", Tag: "notifications",
+		Summary:  "Acknowledge all the notifications waiting for an acknowledgement",
+		Security: []string{"loginCookie"}, Response: server_structs.SimpleApiResp{},
+		Handlers: []gin.HandlerFunc{AuthHandler, AdminAuthHandler, handleAcknowledgeAllNotifications},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/v1.0/notifications/:id/acknowledge", OperationID: "acknowledgeNotification", Tag: "notifications",
+		Summary:  "Acknowledge a notification",
+		Security: []string{"loginCookie"}, PathParams: notificationIdParam{}, Response: server_structs.SimpleApiResp{},
+		Handlers: []gin.HandlerFunc{AuthHandler, AdminAuthHandler, handleAcknowledgeNotification},
+	},
+}
+
+// Build the digest email of the notifications raised or updated since the given time,
+// or return an empty message if there are none
+func buildNotificationDigest(since time.Time, from string, recipients []string) ([]byte, error) {
+	db := notificationDB.Load()
+	if db == nil {
+		return nil, errors.New("the notifications aren't configured")
+	}
+	var updated int64
+	if err := db.Model(&Notification{}).Where("acknowledged_at IS NULL AND updated_at > ?", since).Count(&updated).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to count the updated notifications")
+	}
+	if updated == 0 {
+		return nil, nil
+	}
+	notifications, err := listNotifications("false")
+	if err != nil {
+		return nil, err
+	}
+
+	server := param.Server_ExternalWebUrl.GetString()
+	body := strings.Builder{}
+	fmt.Fprintf(&body, "%d notifications of the Pelican server at %s are waiting for an acknowledgement:\r\n\r\n", len(notifications), server)
+	for _, notification := range notifications {
+		fmt.Fprintf(&body, "- [%s] %s", notification.Severity, notification.Title)
+		if notification.Count > 1 {
+			fmt.Fprintf(&body, " (raised %d times)", notification.Count)
+		}
+		fmt.Fprintf(&body, ", last at %s\r\n", notification.UpdatedAt.UTC().Format(time.RFC1123))
+		if notification.Message != "" {
+			fmt.Fprintf(&body, "  %s\r\n", strings.ReplaceAll(notification.Message, "\n", "\r\n  "))
+		}
+	}
+	fmt.Fprintf(&body, "\r\nAcknowledge them in the web UI at %s\r\n", server)
+
+	msg := strings.Builder{}
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&msg, "Subject: [Pelican] %d notifications need attention on %s\r\n", len(notifications), server)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(body.String())
+	return []byte(msg.String()), nil
+}
+
+// Email the digest of the notifications raised or updated since the given time
+func sendNotificationDigest(since time.Time) error {
+	recipients := param.Server_NotificationDigestRecipients.GetStringSlice()
+	smtpServer := param.Server_NotificationSmtpServer.GetString()
+	from := param.Server_NotificationSmtpFrom.GetString()
+	msg, err := buildNotificationDigest(since, from, recipients)
+	if err != nil || msg == nil {
+		return err
+	}
+	var auth smtp.Auth
+	if username := param.Server_NotificationSmtpUsername.GetString(); username != "" {
+		password, err := os.ReadFile(param.Server_NotificationSmtpPasswordFile.GetString())
+		if err != nil {
+			return errors.Wrap(err, "failed to read the SMTP password file")
+		}
+		host := smtpServer
+		if idx := strings.LastIndex(host, ":"); idx >= 0 {
+			host = host[:idx]
+		}
+		auth = smtp.PlainAuth("", username, strings.TrimSpace(string(password)), host)
+	}
+	if err := sendMail(smtpServer, auth, from, recipients, msg); err != nil {
+		return errors.Wrapf(err, "failed to send the notification digest through %s", smtpServer)
+	}
+	log.Infof("Sent the digest of notifications to %s", strings.Join(recipients, ", "))
+	return nil
+}
+
+// Periodically email the digest of notifications, if Server.NotificationDigestRecipients is set
+func launchNotificationDigest(ctx context.Context, egrp *errgroup.Group) error {
+	if len(param.Server_NotificationDigestRecipients.GetStringSlice()) == 0 {
+		return nil
+	}
+	if param.Server_NotificationSmtpServer.GetString() == "" || param.Server_NotificationSmtpFrom.GetString() == "" {
+		return errors.New("Server.NotificationSmtpServer and Server.NotificationSmtpFrom must be set to send the digest of notifications")
+	}
+	interval := param.Server_NotificationDigestInterval.GetDuration()
+	if interval <= 0 {
+		return errors.Errorf("invalid Server.NotificationDigestInterval of %s; it must be positive", interval.String())
+	}
+	egrp.Go(func() error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		since := time.Now()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case now := <-ticker.C:
+				if err := sendNotificationDigest(since); err != nil {
+					log.Errorln("Failed to send the digest of notifications:", err)
+					continue
+				}
+				since = now
+			}
+		}
+	})
+	return nil
+}
+
+// Set up the notification database, raise notifications on the server's health, and
+// register the notification API
+func configureNotifications(ctx context.Context, engine *gin.Engine, egrp *errgroup.Group) ([]openapi.Route, error) {
+	if err := initNotificationDB(); err != nil {
+		return nil, errors.Wrap(err, "failed to initialize the notification database")
+	}
+	egrp.Go(func() error {
+		<-ctx.Done()
+		if db := notificationDB.Swap(nil); db != nil {
+			if err := server_utils.ShutdownDB(db); err != nil {
+				log.Errorln("Failed to shut down the notification database:", err)
+			}
+		}
+		return nil
+	})
+
+	healthListenerOnce.Do(func() { metrics.AddHealthStatusListener(notifyHealthStatusChange) })
+	// Components that became critical before the notifications were configured
+	for component, status := range metrics.GetHealthStatus().ComponentStatus {
+		if status.Status == metrics.StatusCritical.String() {
+			notifyHealthStatusChange(component, metrics.StatusUnknown, metrics.StatusCritical, status.Message)
+		}
+	}
+
+	if err := launchNotificationDigest(ctx, egrp); err != nil {
+		return nil, err
+	}
+	openapi.RegisterRoutes(engine, notificationRoutes)
+	return notificationRoutes, nil
+}
@@ -0,0 +1,195 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package web_ui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+func setupNotificationDB(t *testing.T) {
+	viper.Set("Server.NotificationDbLocation", filepath.Join(t.TempDir(), "notifications.sqlite"))
+	require.NoError(t, initNotificationDB())
+	t.Cleanup(func() {
+		require.NoError(t, server_utils.ShutdownDB(notificationDB.Swap(nil)))
+	})
+}
+
+func TestNotifyAdmins(t *testing.T) {
+	setupNotificationDB(t)
+
+	NotifyAdmins("health", "health/xrootd", NotificationCritical, "XRootD is down", "first")
+	NotifyAdmins("health", "health/xrootd", NotificationCritical, "XRootD is down", "second")
+	NotifyAdmins("registry", "registration/1", NotificationInfo, "Pending", "")
+
+	notifications, err := listNotifications("false")
+	require.NoError(t, err)
+	require.Len(t, notifications, 2)
+	byKey := map[string]Notification{}
+	for _, notification := range notifications {
+		byKey[notification.Key] = notification
+	}
+	// Raising an event again updates the notification waiting for an acknowledgement
+	assert.Equal(t, 2, byKey["health/xrootd"].Count)
+	assert.Equal(t, "second", byKey["health/xrootd"].Message)
+	assert.Equal(t, 1, byKey["registration/1"].Count)
+
+	ResolveAdminNotification("health/xrootd")
+	notifications, err = listNotifications("true")
+	require.NoError(t, err)
+	require.Len(t, notifications, 1)
+	assert.Equal(t, notificationSystemUser, notifications[0].AcknowledgedBy)
+	assert.NotNil(t, notifications[0].AcknowledgedAt)
+
+	// Once acknowledged, the event raises a new notification
+	NotifyAdmins("health", "health/xrootd", NotificationCritical, "XRootD is down", "third")
+	notifications, err = listNotifications("all")
+	require.NoError(t, err)
+	assert.Len(t, notifications, 3)
+
+	_, err = listNotifications("maybe")
+	assert.Error(t, err)
+}
+
+func TestNotifyHealthStatusChange(t *testing.T) {
+	setupNotificationDB(t)
+
+	notifyHealthStatusChange(metrics.OriginCache_Federation, metrics.StatusUnknown, metrics.StatusWarning, "First attempt")
+	notifications, err := listNotifications("false")
+	require.NoError(t, err)
+	assert.Empty(t, notifications)
+
+	notifyHealthStatusChange(metrics.OriginCache_Federation, metrics.StatusWarning, metrics.StatusCritical, "Failed to advertise")
+	notifications, err = listNotifications("false")
+	require.NoError(t, err)
+	require.Len(t, notifications, 1)
+	assert.Equal(t, "health/federation", notifications[0].Key)
+	assert.Equal(t, NotificationCritical, notifications[0].Severity)
+	assert.Equal(t, "Failed to advertise", notifications[0].Message)
+
+	notifyHealthStatusChange(metrics.OriginCache_Federation, metrics.StatusCritical, metrics.StatusOK, "")
+	notifications, err = listNotifications("false")
+	require.NoError(t, err)
+	assert.Empty(t, notifications)
+}
+
+func TestNotificationHandlers(t *testing.T) {
+	setupNotificationDB(t)
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	setUser := func(ctx *gin.Context) { ctx.Set("User", "admin") }
+	engine.GET("/api/v1.0/notifications", setUser, handleListNotifications)
+	engine.POST("/api/v1.0/notifications", setUser, handleCreateNotification)
+	engine.POST("/api/v1.0/notifications/acknowledge", setUser, handleAcknowledgeAllNotifications)
+	engine.POST("/api/v1.0/notifications/:id/acknowledge", setUser, handleAcknowledgeNotification)
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		engine.ServeHTTP(recorder, req)
+		return recorder
+	}
+	list := func(acknowledged string) []Notification {
+		recorder := serve(http.MethodGet, "/api/v1.0/notifications?acknowledged="+acknowledged, "")
+		require.Equal(t, http.StatusOK, recorder.Code)
+		notifications := []Notification{}
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &notifications))
+		return notifications
+	}
+
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/api/v1.0/notifications", `{"severity": "loud", "title": "Hi"}`).Code)
+	require.Equal(t, http.StatusOK, serve(http.MethodPost, "/api/v1.0/notifications", `{"severity": "info", "title": "Maintenance", "message": "Tomorrow"}`).Code)
+	require.Equal(t, http.StatusOK, serve(http.MethodPost, "/api/v1.0/notifications", `{"severity": "warning", "title": "Disk"}`).Code)
+
+	pending := list("false")
+	require.Len(t, pending, 2)
+	assert.Equal(t, "admin:admin", pending[0].Source)
+
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/api/v1.0/notifications/abc/acknowledge", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/api/v1.0/notifications/100/acknowledge", "").Code)
+	require.Equal(t, http.StatusOK, serve(http.MethodPost, "/api/v1.0/notifications/"+strconv.Itoa(pending[0].ID)+"/acknowledge", "").Code)
+	// Acknowledging twice finds nothing waiting
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/api/v1.0/notifications/"+strconv.Itoa(pending[0].ID)+"/acknowledge", "").Code)
+
+	acknowledged := list("true")
+	require.Len(t, acknowledged, 1)
+	assert.Equal(t, "admin", acknowledged[0].AcknowledgedBy)
+
+	require.Equal(t, http.StatusOK, serve(http.MethodPost, "/api/v1.0/notifications/acknowledge", "").Code)
+	assert.Empty(t, list("false"))
+	assert.Len(t, list("all"), 2)
+}
+
+func TestSendNotificationDigest(t *testing.T) {
+	setupNotificationDB(t)
+	viper.Set("Server.ExternalWebUrl", "https://origin.example.org:8444")
+	viper.Set("Server.NotificationDigestRecipients", []string{"ops@example.org", "admin@example.org"})
+	viper.Set("Server.NotificationSmtpServer", "smtp.example.org:587")
+	viper.Set("Server.NotificationSmtpFrom", "pelican@example.org")
+	t.Cleanup(func() {
+		viper.Set("Server.ExternalWebUrl", "")
+		viper.Set("Server.NotificationDigestRecipients", nil)
+		viper.Set("Server.NotificationSmtpServer", "")
+		viper.Set("Server.NotificationSmtpFrom", "")
+		sendMail = smtp.SendMail
+	})
+
+	var sent []string
+	sendMail = func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+		assert.Equal(t, "smtp.example.org:587", addr)
+		assert.Nil(t, auth)
+		assert.Equal(t, "pelican@example.org", from)
+		assert.Equal(t, []string{"ops@example.org", "admin@example.org"}, to)
+		sent = append(sent, string(msg))
+		return nil
+	}
+
+	start := time.Now()
+	// Nothing to send
+	require.NoError(t, sendNotificationDigest(start))
+	assert.Empty(t, sent)
+
+	NotifyAdmins("health", "health/xrootd", NotificationCritical, "XRootD is down", "It crashed")
+	NotifyAdmins("health", "health/xrootd", NotificationCritical, "XRootD is down", "It crashed")
+	require.NoError(t, sendNotificationDigest(start))
+	require.Len(t, sent, 1)
+	assert.Contains(t, sent[0], "Subject: [Pelican] 1 notifications need attention on https://origin.example.org:8444\r\n")
+	assert.Contains(t, sent[0], "- [critical] XRootD is down (raised 2 times)")
+	assert.Contains(t, sent[0], "  It crashed\r\n")
+
+	// Notifications that weren't updated since the previous digest aren't sent again
+	require.NoError(t, sendNotificationDigest(time.Now()))
+	assert.Len(t, sent, 1)
+}
@@ -384,6 +384,20 @@ func handleOAuthCallback(ctx *gin.Context) {
 		redirectLocation = nextURL
 	}
 
+	// Admins with a registered authenticator complete their login on the second-factor page
+	if required, err := startSecondFactor(ctx, user, groups); err != nil {
+		log.Errorln("Failed to start the second factor of the login:", err)
+		ctx.JSON(http.StatusInternalServerError,
+			server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "Failed to start the second factor of the login",
+			})
+		return
+	} else if required {
+		ctx.Redirect(http.StatusTemporaryRedirect, "/view/login/webauthn/?returnURL="+url.QueryEscape(redirectLocation))
+		return
+	}
+
 	// Issue our own JWT for web UI access
 	setLoginCookie(ctx, user, groups)
 
@@ -79,6 +79,9 @@ var (
 	globalConfigMtx sync.RWMutex
 
 	onceCABundle = sync.Once{}
+
+	// Evaluates instant queries against the embedded Prometheus once it's running
+	embeddedPromQuery atomic.Pointer[promQueryFunc]
 )
 
 func init() {
@@ -484,6 +487,32 @@ func ConfigureEmbeddedPrometheus(ctx context.Context, engine *gin.Engine) error
 	}
 	scraper.Set(scrapeManager)
 
+	var evaluate promQueryFunc = func(ctx context.Context, query string, ts time.Time) (promql.Vector, error) {
+		q, err := queryEngine.NewInstantQuery(ctx, fanoutStorage, nil, query, ts)
+		if err != nil {
+			return nil, err
+		}
+		defer q.Close()
+		res := q.Exec(ctx)
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		switch value := res.Value.(type) {
+		case promql.Vector:
+			return value, nil
+		case promql.Scalar:
+			return promql.Vector{{T: value.T, F: value.V}}, nil
+		default:
+			return nil, errors.Errorf("query returned a %s rather than an instant vector", res.Value.Type())
+		}
+	}
+	embeddedPromQuery.Store(&evaluate)
+	historyRecorder, err := configureMetricHistory(engine, evaluate)
+	if err != nil {
+		cancelScrape()
+		return err
+	}
+
 	TSDBDir := localStoragePath
 
 	Version := &web.PrometheusVersion{
@@ -839,6 +868,21 @@ func ConfigureEmbeddedPrometheus(ctx context.Context, engine *gin.Engine) error
 		)
 
 	}
+	if historyRecorder != nil {
+		// Long-term metric history recorder.
+		historyCtx, cancelHistory := context.WithCancel(ctx)
+		g.Add(
+			func() error {
+				// Queries need the TSDB to be open and the config loaded
+				<-reloadReady.C
+				historyRecorder.run(historyCtx)
+				return nil
+			},
+			func(err error) {
+				cancelHistory()
+			},
+		)
+	}
 	{
 		// TSDB.
 		opts := cfg.tsdb.ToTSDBOptions()
@@ -1200,6 +1244,15 @@ func (s *readyStorage) WALReplayStatus() (tsdb.WALReplayStatus, error) {
 // ErrNotReady is returned if the underlying scrape manager is not ready yet.
 var ErrNotReady = errors.New("Scrape manager not ready")
 
+// Evaluate an instant PromQL query against the server's embedded Prometheus
+func QueryPrometheus(ctx context.Context, query string, ts time.Time) (promql.Vector, error) {
+	evaluate := embeddedPromQuery.Load()
+	if evaluate == nil {
+		return nil, errors.New("the embedded Prometheus is not running")
+	}
+	return (*evaluate)(ctx, query, ts)
+}
+
 // ReadyScrapeManager allows a scrape manager to be retrieved. Even if it's set at a later point in time.
 type readyScrapeManager struct {
 	mtx sync.RWMutex
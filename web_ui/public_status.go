@@ -0,0 +1,100 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package web_ui
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/openapi"
+	"github.com/pelicanplatform/pelican/param"
+)
+
+type (
+	// The status of a server that's safe to show to anyone, served at /api/v1.0/status
+	// when Server.EnablePublicStatus is set
+	PublicStatus struct {
+		Version string   `json:"version"`
+		Servers []string `json:"servers"`
+		Health  string   `json:"health"`
+		// The status of each health component, without its message
+		Components map[metrics.HealthStatusComponent]string `json:"components"`
+		// Added by servers with a view of the federation, i.e. the director
+		Federation interface{} `json:"federation,omitempty"`
+		Time       time.Time   `json:"time"`
+	}
+)
+
+var federationStatusProvider atomic.Pointer[func() interface{}]
+
+// Set the function adding a summary of the federation to the public status; nil removes it.
+// The summary is shown without authentication, so it must not include anything sensitive.
+func SetFederationStatusProvider(provider func() interface{}) {
+	if provider == nil {
+		federationStatusProvider.Store(nil)
+		return
+	}
+	federationStatusProvider.Store(&provider)
+}
+
+func getPublicStatus(now time.Time) PublicStatus {
+	health := metrics.GetHealthStatus()
+	status := PublicStatus{
+		Version:    config.GetVersion(),
+		Servers:    config.GetEnabledServerString(true),
+		Health:     health.OverallStatus,
+		Components: make(map[metrics.HealthStatusComponent]string, len(health.ComponentStatus)),
+		Time:       now.UTC(),
+	}
+	for component, componentStatus := range health.ComponentStatus {
+		status.Components[component] = componentStatus.Status
+	}
+	if provider := federationStatusProvider.Load(); provider != nil {
+		status.Federation = (*provider)()
+	}
+	return status
+}
+
+// GET /api/v1.0/status
+func handlePublicStatus(ctx *gin.Context) {
+	// Status pages are often hosted elsewhere and poll the server from the browser
+	ctx.Header("Access-Control-Allow-Origin", "*")
+	ctx.JSON(http.StatusOK, getPublicStatus(time.Now()))
+}
+
+var publicStatusRoute = openapi.Route{
+	Method: http.MethodGet, Path: "/api/v1.0/status", OperationID: "getPublicStatus", Tag: "server",
+	Summary:  "Get the public status of the server",
+	Response: PublicStatus{},
+	Handlers: []gin.HandlerFunc{handlePublicStatus},
+}
+
+// Register the public status endpoint if it's enabled.  Returns whether the endpoint was registered
+func configurePublicStatus(engine *gin.Engine) bool {
+	if !param.Server_EnablePublicStatus.GetBool() {
+		return false
+	}
+	openapi.RegisterRoutes(engine, []openapi.Route{publicStatusRoute})
+	return true
+}
@@ -0,0 +1,70 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package web_ui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pelicanplatform/pelican/metrics"
+)
+
+func TestPublicStatus(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	metrics.SetComponentHealthStatus(metrics.OriginCache_XRootD, metrics.StatusWarning, "xrootd is listening on a secret port")
+	t.Cleanup(func() { metrics.DeleteComponentHealthStatus(metrics.OriginCache_XRootD) })
+
+	t.Run("disabled-by-default", func(t *testing.T) {
+		engine := gin.New()
+		configurePublicStatus(engine)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1.0/status", nil))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		viper.Set("Server.EnablePublicStatus", true)
+		SetFederationStatusProvider(func() interface{} { return map[string]int{"caches": 3} })
+		t.Cleanup(func() { SetFederationStatusProvider(nil) })
+
+		engine := gin.New()
+		configurePublicStatus(engine)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1.0/status", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+		// Component messages may include details of the server's setup and aren't shown
+		assert.NotContains(t, w.Body.String(), "secret port")
+
+		status := PublicStatus{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+		assert.Equal(t, metrics.StatusWarning.String(), status.Components[metrics.OriginCache_XRootD])
+		assert.NotEmpty(t, status.Health)
+		assert.Equal(t, map[string]interface{}{"caches": float64(3)}, status.Federation)
+	})
+}
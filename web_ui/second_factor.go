@@ -0,0 +1,713 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package web_ui
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"embed"
+	"encoding/base32"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+	"gorm.io/gorm"
+
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_structs"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+type (
+	// A WebAuthn authenticator registered by a web UI admin as their second factor
+	WebAuthnCredential struct {
+		ID         string     `json:"id" gorm:"primaryKey"` // The base64url-encoded credential ID
+		User       string     `json:"-" gorm:"not null"`
+		Name       string     `json:"name"`
+		PublicKey  []byte     `json:"-" gorm:"not null"` // The COSE_Key of the credential
+		SignCount  uint32     `json:"-"`
+		CreatedAt  time.Time  `json:"createdAt"`
+		LastUsedAt *time.Time `json:"lastUsedAt"`
+	}
+
+	// A single-use code that completes the login of an admin who lost their authenticators
+	WebAuthnRecoveryCode struct {
+		ID        int    `gorm:"primaryKey"`
+		User      string `gorm:"not null"`
+		CodeHash  string `gorm:"not null"` // Hex-encoded SHA-256 of the normalized code
+		CreatedAt time.Time
+	}
+
+	// A login that passed the first factor and waits for the second one
+	pendingLogin struct {
+		user   string
+		groups []string
+
+		lock      sync.Mutex
+		challenge []byte
+		failures  int
+	}
+
+	webauthnCredentialDescriptor struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+	}
+
+	webauthnRelyingParty struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+
+	webauthnUser struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		DisplayName string `json:"displayName"`
+	}
+
+	webauthnCredentialParameter struct {
+		Type string `json:"type"`
+		Alg  int    `json:"alg"`
+	}
+
+	webauthnAuthenticatorSelection struct {
+		ResidentKey      string `json:"residentKey"`
+		UserVerification string `json:"userVerification"`
+	}
+
+	// The options passed to navigator.credentials.create(); binary values are base64url-encoded
+	webauthnCreationOptions struct {
+		Challenge              string                         `json:"challenge"`
+		RP                     webauthnRelyingParty           `json:"rp"`
+		User                   webauthnUser                   `json:"user"`
+		PubKeyCredParams       []webauthnCredentialParameter  `json:"pubKeyCredParams"`
+		Timeout                int                            `json:"timeout"`
+		Attestation            string                         `json:"attestation"`
+		ExcludeCredentials     []webauthnCredentialDescriptor `json:"excludeCredentials"`
+		AuthenticatorSelection webauthnAuthenticatorSelection `json:"authenticatorSelection"`
+	}
+
+	// The options passed to navigator.credentials.get(); binary values are base64url-encoded
+	webauthnRequestOptions struct {
+		Challenge        string                         `json:"challenge"`
+		RPID             string                         `json:"rpId"`
+		Timeout          int                            `json:"timeout"`
+		UserVerification string                         `json:"userVerification"`
+		AllowCredentials []webauthnCredentialDescriptor `json:"allowCredentials"`
+	}
+
+	webauthnRegistrationReq struct {
+		Name              string `json:"name"`
+		ClientDataJSON    string `json:"clientDataJSON" binding:"required"`
+		AttestationObject string `json:"attestationObject" binding:"required"`
+	}
+
+	webauthnAssertionReq struct {
+		ID                string `json:"id" binding:"required"`
+		ClientDataJSON    string `json:"clientDataJSON" binding:"required"`
+		AuthenticatorData string `json:"authenticatorData" binding:"required"`
+		Signature         string `json:"signature" binding:"required"`
+	}
+
+	recoveryCodeReq struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	// The response of a login whose second factor is still required
+	SecondFactorRequiredRes struct {
+		server_structs.SimpleApiResp
+		SecondFactorRequired bool `json:"second_factor_required"`
+	}
+
+	recoveryCodesRes struct {
+		server_structs.SimpleApiResp
+		RecoveryCodes []string `json:"recovery_codes,omitempty"`
+	}
+)
+
+const (
+	secondFactorCookie  = "login-2fa"
+	secondFactorTimeout = 5 * time.Minute
+	// The failed verifications allowed before a pending login must start over
+	secondFactorMaxFailures = 5
+	recoveryCodeCount       = 10
+	webauthnTimeout         = time.Minute
+)
+
+var (
+	// The database of the WebAuthn credentials and recovery codes; nil if WebAuthn is disabled
+	authStoreDB *gorm.DB
+
+	// Pending logins, keyed by the value of the login-2fa cookie
+	pendingLogins = ttlcache.New[string, *pendingLogin](
+		ttlcache.WithTTL[string, *pendingLogin](secondFactorTimeout),
+		ttlcache.WithDisableTouchOnHit[string, *pendingLogin](),
+	)
+	// Outstanding registration challenges, keyed by user
+	registrationChallenges = ttlcache.New[string, []byte](
+		ttlcache.WithTTL[string, []byte](secondFactorTimeout),
+		ttlcache.WithDisableTouchOnHit[string, []byte](),
+	)
+
+	//go:embed auth_migrations/*.sql
+	embedAuthMigrations embed.FS
+)
+
+func (WebAuthnCredential) TableName() string {
+	return "webauthn_credentials"
+}
+
+func (WebAuthnRecoveryCode) TableName() string {
+	return "webauthn_recovery_codes"
+}
+
+func initAuthStoreDB() error {
+	dbPath := param.Server_UIAuthDbLocation.GetString()
+	tdb, err := server_utils.InitSQLiteDB(dbPath)
+	if err != nil {
+		return err
+	}
+	sqldb, err := tdb.DB()
+	if err != nil {
+		return errors.Wrapf(err, "Failed to get sql.DB from gorm DB: %s", dbPath)
+	}
+	if err := server_utils.MigrateDBFromDir(sqldb, embedAuthMigrations, "auth_migrations"); err != nil {
+		return err
+	}
+	authStoreDB = tdb
+	return nil
+}
+
+func randomBytes(size int) ([]byte, error) {
+	value := make([]byte, size)
+	if _, err := rand.Read(value); err != nil {
+		return nil, errors.Wrap(err, "failed to generate random bytes")
+	}
+	return value, nil
+}
+
+// Return the WebAuthn relying party ID and origin from Server.ExternalWebUrl
+func getWebAuthnRelyingParty() (rpID, origin string, err error) {
+	extUrl, err := url.Parse(param.Server_ExternalWebUrl.GetString())
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to parse Server.ExternalWebUrl")
+	}
+	if extUrl.Hostname() == "" {
+		return "", "", errors.New("Server.ExternalWebUrl has no hostname")
+	}
+	host := extUrl.Host
+	if extUrl.Scheme == "https" && extUrl.Port() == "443" {
+		// Browsers leave the default port out of the origin
+		host = extUrl.Hostname()
+	}
+	return extUrl.Hostname(), extUrl.Scheme + "://" + host, nil
+}
+
+func getWebAuthnCredentials(user string) ([]WebAuthnCredential, error) {
+	creds := []WebAuthnCredential{}
+	if err := authStoreDB.Where("user = ?", user).Order("created_at").Find(&creds).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed to get the WebAuthn credentials of user %s", user)
+	}
+	return creds, nil
+}
+
+func toCredentialDescriptors(creds []WebAuthnCredential) []webauthnCredentialDescriptor {
+	descriptors := make([]webauthnCredentialDescriptor, 0, len(creds))
+	for _, cred := range creds {
+		descriptors = append(descriptors, webauthnCredentialDescriptor{Type: "public-key", ID: cred.ID})
+	}
+	return descriptors
+}
+
+// Normalize a recovery code as typed by a user and hash it
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	hash := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(hash[:])
+}
+
+// Replace the recovery codes of a user, returning the new codes.  Only their
+// hashes are kept in the database.
+func generateRecoveryCodes(user string) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	rows := make([]WebAuthnRecoveryCode, 0, recoveryCodeCount)
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	for i := 0; i < recoveryCodeCount; i++ {
+		value, err := randomBytes(10)
+		if err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(encoding.EncodeToString(value))
+		code = code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16]
+		codes = append(codes, code)
+		rows = append(rows, WebAuthnRecoveryCode{User: user, CodeHash: hashRecoveryCode(code), CreatedAt: time.Now()})
+	}
+	err := authStoreDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user = ?", user).Delete(&WebAuthnRecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&rows).Error
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to save the recovery codes of user %s", user)
+	}
+	return codes, nil
+}
+
+// Start the second factor of a login if the user has registered an authenticator.
+// Returns true if the login waits for the second factor, in which case the login
+// cookie must not be set.
+func startSecondFactor(ctx *gin.Context, user string, groups []string) (bool, error) {
+	if authStoreDB == nil {
+		return false, nil
+	}
+	var count int64
+	if err := authStoreDB.Model(&WebAuthnCredential{}).Where("user = ?", user).Count(&count).Error; err != nil {
+		return false, errors.Wrapf(err, "failed to check the WebAuthn credentials of user %s", user)
+	}
+	if count == 0 {
+		return false, nil
+	}
+	id, err := randomBytes(32)
+	if err != nil {
+		return false, err
+	}
+	pendingID := hex.EncodeToString(id)
+	pendingLogins.Set(pendingID, &pendingLogin{user: user, groups: groups}, ttlcache.DefaultTTL)
+	ctx.SetCookie(secondFactorCookie, pendingID, int(secondFactorTimeout.Seconds()), "/api/v1.0/auth/webauthn", ctx.Request.URL.Host, true, true)
+	ctx.SetSameSite(http.SameSiteStrictMode)
+	return true, nil
+}
+
+// Return the pending login of the request, or nil after responding with an error
+func getPendingLogin(ctx *gin.Context) (string, *pendingLogin) {
+	if pendingID, err := ctx.Cookie(secondFactorCookie); err == nil && pendingID != "" {
+		if item := pendingLogins.Get(pendingID); item != nil {
+			return pendingID, item.Value()
+		}
+	}
+	ctx.JSON(http.StatusUnauthorized,
+		server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "No login is waiting for a second factor; please log in again",
+		})
+	return "", nil
+}
+
+// Count a failed second factor, abandoning the pending login after too many of them
+func failSecondFactor(ctx *gin.Context, pendingID string, pending *pendingLogin, msg string) {
+	pending.lock.Lock()
+	pending.failures++
+	failures := pending.failures
+	pending.lock.Unlock()
+	if failures >= secondFactorMaxFailures {
+		pendingLogins.Delete(pendingID)
+		msg += "; too many failed attempts, please log in again"
+	}
+	log.Warningf("Failed second factor for user %s: %s", pending.user, msg)
+	ctx.JSON(http.StatusUnauthorized,
+		server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    msg,
+		})
+}
+
+// Complete a pending login whose second factor was verified
+func finishSecondFactor(ctx *gin.Context, pendingID string, pending *pendingLogin) {
+	pendingLogins.Delete(pendingID)
+	ctx.SetCookie(secondFactorCookie, "", -1, "/api/v1.0/auth/webauthn", ctx.Request.URL.Host, true, true)
+	setLoginCookie(ctx, pending.user, pending.groups)
+	if ctx.Writer.Written() {
+		return
+	}
+	ctx.JSON(http.StatusOK,
+		server_structs.SimpleApiResp{
+			Status: server_structs.RespOK,
+			Msg:    "success",
+		})
+}
+
+func webauthnInternalError(ctx *gin.Context, msg string, err error) {
+	log.Errorf("%s: %v", msg, err)
+	ctx.JSON(http.StatusInternalServerError,
+		server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    msg,
+		})
+}
+
+func webauthnBadRequest(ctx *gin.Context, msg string) {
+	ctx.JSON(http.StatusBadRequest,
+		server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    msg,
+		})
+}
+
+// Start the registration of an authenticator for the logged-in admin
+func handleWebAuthnRegisterBegin(ctx *gin.Context) {
+	user := ctx.GetString("User")
+	rpID, _, err := getWebAuthnRelyingParty()
+	if err != nil {
+		webauthnInternalError(ctx, "Failed to get the WebAuthn relying party", err)
+		return
+	}
+	creds, err := getWebAuthnCredentials(user)
+	if err != nil {
+		webauthnInternalError(ctx, "Failed to get the registered authenticators", err)
+		return
+	}
+	challenge, err := randomBytes(32)
+	if err != nil {
+		webauthnInternalError(ctx, "Failed to generate a challenge", err)
+		return
+	}
+	registrationChallenges.Set(user, challenge, ttlcache.DefaultTTL)
+
+	// The user handle must not reveal the user, so it's a hash of the name
+	userHandle := sha256.Sum256([]byte(user))
+	ctx.JSON(http.StatusOK, gin.H{"publicKey": webauthnCreationOptions{
+		Challenge: encodeWebAuthnBase64(challenge),
+		RP:        webauthnRelyingParty{ID: rpID, Name: "Pelican " + rpID},
+		User:      webauthnUser{ID: encodeWebAuthnBase64(userHandle[:]), Name: user, DisplayName: user},
+		PubKeyCredParams: []webauthnCredentialParameter{
+			{Type: "public-key", Alg: int(webauthncose.AlgES256)},
+			{Type: "public-key", Alg: int(webauthncose.AlgRS256)},
+		},
+		Timeout:                int(webauthnTimeout.Milliseconds()),
+		Attestation:            "none",
+		ExcludeCredentials:     toCredentialDescriptors(creds),
+		AuthenticatorSelection: webauthnAuthenticatorSelection{ResidentKey: "discouraged", UserVerification: "preferred"},
+	}})
+}
+
+// Finish the registration of an authenticator.  The recovery codes are generated
+// and returned with the first authenticator of the admin.
+func handleWebAuthnRegisterFinish(ctx *gin.Context) {
+	user := ctx.GetString("User")
+	req := webauthnRegistrationReq{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		webauthnBadRequest(ctx, "Invalid WebAuthn registration: "+err.Error())
+		return
+	}
+	item := registrationChallenges.Get(user)
+	if item == nil {
+		webauthnBadRequest(ctx, "No WebAuthn registration was started or it has expired")
+		return
+	}
+	registrationChallenges.Delete(user)
+	clientDataJSON, err := decodeWebAuthnBase64(req.ClientDataJSON)
+	if err != nil {
+		webauthnBadRequest(ctx, "Invalid clientDataJSON encoding")
+		return
+	}
+	attestationObject, err := decodeWebAuthnBase64(req.AttestationObject)
+	if err != nil {
+		webauthnBadRequest(ctx, "Invalid attestationObject encoding")
+		return
+	}
+	rpID, origin, err := getWebAuthnRelyingParty()
+	if err != nil {
+		webauthnInternalError(ctx, "Failed to get the WebAuthn relying party", err)
+		return
+	}
+	authData, err := verifyWebAuthnRegistration(clientDataJSON, attestationObject, item.Value(), rpID, origin)
+	if err != nil {
+		webauthnBadRequest(ctx, "Failed to verify the WebAuthn registration: "+err.Error())
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = "Authenticator registered on " + time.Now().UTC().Format("2006-01-02")
+	}
+	cred := WebAuthnCredential{
+		ID:        encodeWebAuthnBase64(authData.AttData.CredentialID),
+		User:      user,
+		Name:      name,
+		PublicKey: authData.AttData.CredentialPublicKey,
+		SignCount: authData.Counter,
+		CreatedAt: time.Now(),
+	}
+	var existing int64
+	if err := authStoreDB.Model(&WebAuthnCredential{}).Where("id = ?", cred.ID).Count(&existing).Error; err != nil {
+		webauthnInternalError(ctx, "Failed to save the authenticator", err)
+		return
+	}
+	if existing > 0 {
+		webauthnBadRequest(ctx, "The authenticator is already registered")
+		return
+	}
+	if err := authStoreDB.Create(&cred).Error; err != nil {
+		webauthnInternalError(ctx, "Failed to save the authenticator", err)
+		return
+	}
+	log.Infof("User %s registered the WebAuthn authenticator %q", user, name)
+
+	res := recoveryCodesRes{SimpleApiResp: server_structs.SimpleApiResp{Status: server_structs.RespOK, Msg: "success"}}
+	var codeCount int64
+	if err := authStoreDB.Model(&WebAuthnRecoveryCode{}).Where("user = ?", user).Count(&codeCount).Error; err != nil {
+		webauthnInternalError(ctx, "Failed to check the recovery codes", err)
+		return
+	}
+	if codeCount == 0 {
+		if res.RecoveryCodes, err = generateRecoveryCodes(user); err != nil {
+			webauthnInternalError(ctx, "Failed to generate the recovery codes", err)
+			return
+		}
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// List the authenticators of the logged-in admin
+func handleListWebAuthnCredentials(ctx *gin.Context) {
+	creds, err := getWebAuthnCredentials(ctx.GetString("User"))
+	if err != nil {
+		webauthnInternalError(ctx, "Failed to get the registered authenticators", err)
+		return
+	}
+	ctx.JSON(http.StatusOK, creds)
+}
+
+// Remove an authenticator of the logged-in admin
+func handleDeleteWebAuthnCredential(ctx *gin.Context) {
+	user := ctx.GetString("User")
+	result := authStoreDB.Where("id = ? AND user = ?", ctx.Param("id"), user).Delete(&WebAuthnCredential{})
+	if result.Error != nil {
+		webauthnInternalError(ctx, "Failed to remove the authenticator", result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		ctx.JSON(http.StatusNotFound,
+			server_structs.SimpleApiResp{
+				Status: server_structs.RespFailed,
+				Msg:    "No such authenticator",
+			})
+		return
+	}
+	log.Infof("User %s removed the WebAuthn authenticator %s", user, ctx.Param("id"))
+	ctx.JSON(http.StatusOK,
+		server_structs.SimpleApiResp{
+			Status: server_structs.RespOK,
+			Msg:    "success",
+		})
+}
+
+// Replace the recovery codes of the logged-in admin
+func handleRegenerateRecoveryCodes(ctx *gin.Context) {
+	user := ctx.GetString("User")
+	creds, err := getWebAuthnCredentials(user)
+	if err != nil {
+		webauthnInternalError(ctx, "Failed to get the registered authenticators", err)
+		return
+	}
+	if len(creds) == 0 {
+		webauthnBadRequest(ctx, "Recovery codes are only issued once an authenticator is registered")
+		return
+	}
+	codes, err := generateRecoveryCodes(user)
+	if err != nil {
+		webauthnInternalError(ctx, "Failed to generate the recovery codes", err)
+		return
+	}
+	log.Infof("User %s regenerated their recovery codes", user)
+	ctx.JSON(http.StatusOK, recoveryCodesRes{
+		SimpleApiResp: server_structs.SimpleApiResp{Status: server_structs.RespOK, Msg: "success"},
+		RecoveryCodes: codes,
+	})
+}
+
+// Start the second factor of a pending login
+func handleWebAuthnLoginBegin(ctx *gin.Context) {
+	_, pending := getPendingLogin(ctx)
+	if pending == nil {
+		return
+	}
+	rpID, _, err := getWebAuthnRelyingParty()
+	if err != nil {
+		webauthnInternalError(ctx, "Failed to get the WebAuthn relying party", err)
+		return
+	}
+	creds, err := getWebAuthnCredentials(pending.user)
+	if err != nil {
+		webauthnInternalError(ctx, "Failed to get the registered authenticators", err)
+		return
+	}
+	challenge, err := randomBytes(32)
+	if err != nil {
+		webauthnInternalError(ctx, "Failed to generate a challenge", err)
+		return
+	}
+	pending.lock.Lock()
+	pending.challenge = challenge
+	pending.lock.Unlock()
+
+	ctx.JSON(http.StatusOK, gin.H{"publicKey": webauthnRequestOptions{
+		Challenge:        encodeWebAuthnBase64(challenge),
+		RPID:             rpID,
+		Timeout:          int(webauthnTimeout.Milliseconds()),
+		UserVerification: "preferred",
+		AllowCredentials: toCredentialDescriptors(creds),
+	}})
+}
+
+// Finish the second factor of a pending login with an assertion from one of the
+// user's authenticators
+func handleWebAuthnLoginFinish(ctx *gin.Context) {
+	pendingID, pending := getPendingLogin(ctx)
+	if pending == nil {
+		return
+	}
+	req := webauthnAssertionReq{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		webauthnBadRequest(ctx, "Invalid WebAuthn assertion: "+err.Error())
+		return
+	}
+
+	// A challenge is only good for one attempt
+	pending.lock.Lock()
+	challenge := pending.challenge
+	pending.challenge = nil
+	pending.lock.Unlock()
+	if challenge == nil {
+		webauthnBadRequest(ctx, "No WebAuthn login was started")
+		return
+	}
+
+	clientDataJSON, err := decodeWebAuthnBase64(req.ClientDataJSON)
+	if err != nil {
+		webauthnBadRequest(ctx, "Invalid clientDataJSON encoding")
+		return
+	}
+	rawAuthData, err := decodeWebAuthnBase64(req.AuthenticatorData)
+	if err != nil {
+		webauthnBadRequest(ctx, "Invalid authenticatorData encoding")
+		return
+	}
+	signature, err := decodeWebAuthnBase64(req.Signature)
+	if err != nil {
+		webauthnBadRequest(ctx, "Invalid signature encoding")
+		return
+	}
+
+	cred := WebAuthnCredential{}
+	if err := authStoreDB.Where("id = ? AND user = ?", req.ID, pending.user).First(&cred).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			failSecondFactor(ctx, pendingID, pending, "Unknown authenticator")
+		} else {
+			webauthnInternalError(ctx, "Failed to get the authenticator", err)
+		}
+		return
+	}
+	rpID, origin, err := getWebAuthnRelyingParty()
+	if err != nil {
+		webauthnInternalError(ctx, "Failed to get the WebAuthn relying party", err)
+		return
+	}
+	authData, err := verifyWebAuthnAssertion(req.ID, clientDataJSON, rawAuthData, signature, cred.PublicKey, challenge, rpID, origin)
+	if err != nil {
+		failSecondFactor(ctx, pendingID, pending, "Failed to verify the WebAuthn assertion: "+err.Error())
+		return
+	}
+	// Authenticators with a signature counter must increase it on every use; a
+	// counter going backwards is a sign of a cloned authenticator
+	if (authData.Counter != 0 || cred.SignCount != 0) && authData.Counter <= cred.SignCount {
+		failSecondFactor(ctx, pendingID, pending, "The authenticator's signature counter did not increase")
+		return
+	}
+	now := time.Now()
+	if err := authStoreDB.Model(&cred).Updates(map[string]interface{}{"sign_count": authData.Counter, "last_used_at": now}).Error; err != nil {
+		webauthnInternalError(ctx, "Failed to update the authenticator", err)
+		return
+	}
+	finishSecondFactor(ctx, pendingID, pending)
+}
+
+// Finish the second factor of a pending login with a recovery code, which is used up
+func handleRecoveryCodeLogin(ctx *gin.Context) {
+	pendingID, pending := getPendingLogin(ctx)
+	if pending == nil {
+		return
+	}
+	req := recoveryCodeReq{}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		webauthnBadRequest(ctx, "Recovery code not provided")
+		return
+	}
+	result := authStoreDB.Where("user = ? AND code_hash = ?", pending.user, hashRecoveryCode(req.Code)).Delete(&WebAuthnRecoveryCode{})
+	if result.Error != nil {
+		webauthnInternalError(ctx, "Failed to check the recovery code", result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		failSecondFactor(ctx, pendingID, pending, "Invalid recovery code")
+		return
+	}
+	var remaining int64
+	if err := authStoreDB.Model(&WebAuthnRecoveryCode{}).Where("user = ?", pending.user).Count(&remaining).Error; err != nil {
+		log.Errorf("Failed to count the remaining recovery codes of user %s: %v", pending.user, err)
+	}
+	log.Warningf("User %s logged in with a recovery code; %d recovery codes remain", pending.user, remaining)
+	finishSecondFactor(ctx, pendingID, pending)
+}
+
+// Set up the WebAuthn database and register the second-factor endpoints under the
+// authentication group.  The login endpoints share the rate limit of the password login.
+func configureWebAuthn(ctx context.Context, group *gin.RouterGroup, rateLimit gin.HandlerFunc, egrp *errgroup.Group) error {
+	if _, _, err := getWebAuthnRelyingParty(); err != nil {
+		return errors.Wrap(err, "WebAuthn requires a valid Server.ExternalWebUrl")
+	}
+	if err := initAuthStoreDB(); err != nil {
+		return errors.Wrap(err, "failed to initialize the web UI authentication database")
+	}
+
+	go pendingLogins.Start()
+	go registrationChallenges.Start()
+	egrp.Go(func() error {
+		<-ctx.Done()
+		pendingLogins.DeleteAll()
+		pendingLogins.Stop()
+		registrationChallenges.DeleteAll()
+		registrationChallenges.Stop()
+		if err := server_utils.ShutdownDB(authStoreDB); err != nil {
+			log.Errorln("Failed to shut down the web UI authentication database:", err)
+		}
+		return nil
+	})
+
+	webauthnGroup := group.Group("/webauthn")
+	webauthnGroup.POST("/register/begin", AuthHandler, AdminAuthHandler, handleWebAuthnRegisterBegin)
+	webauthnGroup.POST("/register/finish", AuthHandler, AdminAuthHandler, handleWebAuthnRegisterFinish)
+	webauthnGroup.GET("/credentials", AuthHandler, AdminAuthHandler, handleListWebAuthnCredentials)
+	webauthnGroup.DELETE("/credentials/:id", AuthHandler, AdminAuthHandler, handleDeleteWebAuthnCredential)
+	webauthnGroup.POST("/recoveryCodes", AuthHandler, AdminAuthHandler, handleRegenerateRecoveryCodes)
+	webauthnGroup.POST("/login/begin", rateLimit, handleWebAuthnLoginBegin)
+	webauthnGroup.POST("/login/finish", rateLimit, handleWebAuthnLoginFinish)
+	webauthnGroup.POST("/login/recovery", rateLimit, handleRecoveryCodeLogin)
+	return nil
+}
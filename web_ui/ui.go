@@ -23,7 +23,6 @@ import (
 	"crypto/tls"
 	"embed"
 	"fmt"
-	"math/rand"
 	"mime"
 	"net"
 	"net/http"
@@ -39,6 +38,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	ginprometheus "github.com/zsais/go-gin-prometheus"
@@ -48,6 +49,7 @@ import (
 
 	"github.com/pelicanplatform/pelican/config"
 	"github.com/pelicanplatform/pelican/metrics"
+	"github.com/pelicanplatform/pelican/openapi"
 	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/server_structs"
 	"github.com/pelicanplatform/pelican/server_utils"
@@ -62,6 +64,29 @@ var (
 	adminAccessPages  = []string{"config", "origin", "cache"} // UI pages that allow non-admin users to access. Note that this is different from "publicView" where unauthenticated users can access the page
 )
 
+type (
+	enabledServersRes struct {
+		Servers []string `json:"servers"`
+	}
+
+	webHealthRes struct {
+		Message  string                          `json:"message"`
+		Versions server_structs.ProtocolVersions `json:"versions"`
+	}
+
+	topUsersReq struct {
+		// "hour" (the default) or "day"
+		Window string `form:"window"`
+		// The number of users to return; defaults to 10
+		Limit int `form:"limit"`
+	}
+)
+
+const (
+	defaultTopUsersLimit = 10
+	maxTopUsersLimit     = 1000
+)
+
 const notFoundFilePath = "frontend/out/404/index.html"
 
 func getConfigValues(ctx *gin.Context) {
@@ -164,7 +189,7 @@ func getEnabledServers(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(200, gin.H{"servers": enabledServers})
+	ctx.JSON(200, enabledServersRes{Servers: enabledServers})
 }
 
 func handleGlobusPages(ctx *gin.Context) {
@@ -382,16 +407,50 @@ func configureWebResource(engine *gin.Engine) {
 	})
 }
 
+// The routes of the common endpoints available to all server web UI which are located at /api/v1.0/*
+func commonRoutes() []openapi.Route {
+	return []openapi.Route{
+		{
+			Method: http.MethodGet, Path: "/api/v1.0/config", OperationID: "getConfig", Tag: "config",
+			Summary:  "Get the server's configuration, with the type of each parameter",
+			Security: []string{"loginCookie"}, Response: param.ConvertToConfigWithType(&param.Config{}),
+			Handlers: []gin.HandlerFunc{AuthHandler, AdminAuthHandler, getConfigValues},
+		},
+		{
+			Method: http.MethodPatch, Path: "/api/v1.0/config", OperationID: "updateConfig", Tag: "config",
+			Summary:  "Update the server's configuration. The server restarts to apply the change",
+			Security: []string{"loginCookie"}, Request: param.Config{}, Response: server_structs.SimpleApiResp{},
+			Handlers: []gin.HandlerFunc{AuthHandler, AdminAuthHandler, updateConfigValues},
+		},
+		{
+			Method: http.MethodGet, Path: "/api/v1.0/servers", OperationID: "listEnabledServers", Tag: "server",
+			Summary:  "List the server modules enabled in this process",
+			Response: enabledServersRes{},
+			Handlers: []gin.HandlerFunc{getEnabledServers},
+		},
+		// Health check endpoint for web engine; also reports the supported protocol versions
+		{
+			Method: http.MethodGet, Path: "/api/v1.0/health", OperationID: "getWebHealth", Tag: "server",
+			Summary:  "Check that the web engine is running",
+			Response: webHealthRes{},
+			Handlers: []gin.HandlerFunc{func(ctx *gin.Context) {
+				ctx.JSON(http.StatusOK, webHealthRes{
+					Message:  fmt.Sprintf("Web Engine Running. Time: %s", time.Now().String()),
+					Versions: server_structs.GetProtocolVersions(),
+				})
+			}},
+		},
+	}
+}
+
 // Configure common endpoint available to all server web UI which are located at /api/v1.0/*
-func configureCommonEndpoints(engine *gin.Engine) error {
-	engine.GET("/api/v1.0/config", AuthHandler, AdminAuthHandler, getConfigValues)
-	engine.PATCH("/api/v1.0/config", AuthHandler, AdminAuthHandler, updateConfigValues)
-	engine.GET("/api/v1.0/servers", getEnabledServers)
-	// Health check endpoint for web engine
-	engine.GET("/api/v1.0/health", func(ctx *gin.Context) {
-		ctx.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Web Engine Running. Time: %s", time.Now().String())})
-	})
-	return nil
+func configureCommonEndpoints(engine *gin.Engine) ([]openapi.Route, error) {
+	routes := commonRoutes()
+	openapi.RegisterRoutes(engine, routes)
+	if configurePublicStatus(engine) {
+		routes = append(routes, publicStatusRoute)
+	}
+	return routes, nil
 }
 
 // Map gin routes for Prometheus metrics to reduce metric cardinality
@@ -433,26 +492,105 @@ func mapPrometheusPath(c *gin.Context) string {
 	return url
 }
 
+var metricsRoutes = []openapi.Route{
+	{
+		Method: http.MethodGet, Path: "/api/v1.0/metrics/health", OperationID: "getHealth", Tag: "metrics",
+		Summary:  "Get the health of the server's components",
+		Security: []string{"loginCookie"}, Response: metrics.HealthStatus{},
+		Handlers: []gin.HandlerFunc{AuthHandler, AdminAuthHandler, func(ctx *gin.Context) {
+			healthStatus := metrics.GetHealthStatus()
+			ctx.JSON(http.StatusOK, healthStatus)
+		}},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/v1.0/metrics/auth_failures", OperationID: "listAuthFailures", Tag: "metrics",
+		Summary:  "List the recent authorization failures of the server",
+		Security: []string{"loginCookie"}, Response: []metrics.AuthFailure{},
+		Handlers: []gin.HandlerFunc{AuthHandler, AdminAuthHandler, func(ctx *gin.Context) {
+			ctx.JSON(http.StatusOK, metrics.GetRecentAuthFailures())
+		}},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/v1.0/monitoring/sessions", OperationID: "listMonitoringSessions", Tag: "metrics",
+		Summary:  "List the client sessions cached from the XRootD monitoring packets",
+		Security: []string{"loginCookie"}, Response: []metrics.MonitoringSession{},
+		Handlers: []gin.HandlerFunc{AuthHandler, AdminAuthHandler, func(ctx *gin.Context) {
+			ctx.JSON(http.StatusOK, metrics.GetMonitoringSessions())
+		}},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/v1.0/monitoring/transfers", OperationID: "listMonitoringTransfers", Tag: "metrics",
+		Summary:  "List the open files cached from the XRootD monitoring packets",
+		Security: []string{"loginCookie"}, Response: []metrics.MonitoringTransfer{},
+		Handlers: []gin.HandlerFunc{AuthHandler, AdminAuthHandler, func(ctx *gin.Context) {
+			ctx.JSON(http.StatusOK, metrics.GetMonitoringTransfers())
+		}},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/v1.0/monitoring/top_users", OperationID: "listTopUsers", Tag: "metrics",
+		Summary:  "List the users with the most bytes transferred over the past hour or day",
+		Security: []string{"loginCookie"}, Query: topUsersReq{}, Response: []metrics.TopUserUsage{},
+		Handlers: []gin.HandlerFunc{AuthHandler, AdminAuthHandler, handleListTopUsers},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/v1.0/monitoring/namespace_bandwidth", OperationID: "listNamespaceBandwidth", Tag: "metrics",
+		Summary:  "List the bandwidth of each namespace averaged over the past minute",
+		Security: []string{"loginCookie"}, Response: []metrics.NamespaceBandwidth{},
+		Handlers: []gin.HandlerFunc{AuthHandler, AdminAuthHandler, func(ctx *gin.Context) {
+			ctx.JSON(http.StatusOK, metrics.GetNamespaceBandwidth())
+		}},
+	},
+}
+
+func handleListTopUsers(ctx *gin.Context) {
+	req := topUsersReq{Window: metrics.TopUsersHour, Limit: defaultTopUsersLimit}
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    "Invalid query parameters: " + err.Error(),
+		})
+		return
+	}
+	if req.Limit <= 0 || req.Limit > maxTopUsersLimit {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("Invalid limit %d; it must be between 1 and %d", req.Limit, maxTopUsersLimit),
+		})
+		return
+	}
+	users, ok := metrics.GetTopUsers(req.Window, req.Limit)
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, server_structs.SimpleApiResp{
+			Status: server_structs.RespFailed,
+			Msg:    fmt.Sprintf("Invalid window %q; it must be %s or %s", req.Window, metrics.TopUsersHour, metrics.TopUsersDay),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, users)
+}
+
 // Configure metrics related endpoints, including Prometheus and /health API
-func configureMetrics(engine *gin.Engine) error {
+func configureMetrics(engine *gin.Engine) ([]openapi.Route, error) {
 	// Add authorization to /metric endpoint
 	engine.Use(promMetricAuthHandler)
 
 	prometheusMonitor := ginprometheus.NewPrometheus("gin")
 	prometheusMonitor.ReqCntURLLabelMappingFn = mapPrometheusPath
-	prometheusMonitor.Use(engine)
-
-	engine.GET("/api/v1.0/metrics/health", AuthHandler, AdminAuthHandler, func(ctx *gin.Context) {
-		healthStatus := metrics.GetHealthStatus()
-		ctx.JSON(http.StatusOK, healthStatus)
-	})
-	return nil
+	engine.Use(prometheusMonitor.HandlerFunc())
+	// Serve the metrics relabeled by Monitoring.MetricRelabelRules rather than the default
+	// handler of the middleware
+	metricsHandler := promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(metrics.RelabelingGatherer(prometheus.DefaultGatherer), promhttp.HandlerOpts{}))
+	engine.GET(prometheusMonitor.MetricsPath, gin.WrapH(metricsHandler))
+
+	openapi.RegisterRoutes(engine, metricsRoutes)
+	return metricsRoutes, nil
 }
 
-// Send the one-time code for initial web UI login to stdout and periodically
-// re-generate one-time code if user hasn't finished setup
+// Send the one-time bootstrap token for initial web UI setup to stdout and
+// re-generate the token whenever it expires if user hasn't finished setup
 func waitUntilLogin(ctx context.Context) error {
-	if authDB.Load() != nil {
+	if isSetupCompleted() {
 		return nil
 	}
 	sigs := make(chan os.Signal, 1)
@@ -463,37 +601,45 @@ func waitUntilLogin(ctx context.Context) error {
 	isTTY := false
 	if term.IsTerminal(int(os.Stdout.Fd())) {
 		isTTY = true
-		fmt.Printf("\n\n\n\n")
+		fmt.Printf("\n\n\n\n\n")
 	}
 	activationFile := param.Server_UIActivationCodeFile.GetString()
+	lifetime := param.Server_UIBootstrapTokenLifetime.GetDuration()
+	if lifetime <= 0 {
+		log.Warningf("Invalid %s of %s; falling back to 1h", "Server.UIBootstrapTokenLifetime", lifetime.String())
+		lifetime = time.Hour
+	}
 
 	defer func() {
+		currentBootstrapToken.Store(nil)
 		if err := os.Remove(activationFile); err != nil {
 			log.Warningf("Failed to remove activation code file (%v): %v\n", activationFile, err)
 		}
 	}()
 	for {
-		previousCode.Store(currentCode.Load())
-		newCode := fmt.Sprintf("%06v", rand.Intn(1000000))
-		currentCode.Store(&newCode)
-		newCodeWithNewline := fmt.Sprintf("%v\n", newCode)
-		if err := os.WriteFile(activationFile, []byte(newCodeWithNewline), 0600); err != nil {
+		tok, err := newBootstrapToken(lifetime)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(activationFile, []byte(tok.value+"\n"), 0600); err != nil {
 			log.Errorf("Failed to write activation code to file (%v): %v\n", activationFile, err)
 		}
 
+		expiry := tok.expiry.Format(time.RFC3339)
 		if isTTY {
-			fmt.Printf("\033[A\033[A\033[A\033[A")
+			fmt.Printf("\033[A\033[A\033[A\033[A\033[A")
 			fmt.Printf("\033[2K\n")
 			fmt.Printf("\033[2K\rPelican admin interface is not initialized\n\033[2KTo initialize, "+
-				"login at \033[1;34mhttps://%v:%v/view/initialization/code/\033[0m with the following code:\n",
+				"login at \033[1;34mhttps://%v:%v/view/initialization/code/\033[0m with the following one-time token:\n",
 				hostname, port)
-			fmt.Printf("\033[2K\r\033[1;34m%v\033[0m\n", *currentCode.Load())
+			fmt.Printf("\033[2K\r\033[1;34m%v\033[0m\n", tok.value)
+			fmt.Printf("\033[2K\rThe token expires at %v\n", expiry)
 		} else {
-			fmt.Printf("Pelican admin interface is not initialized\n To initialize, login at https://%v:%v/view/initialization/code/ with the following code:\n", hostname, port)
-			fmt.Println(*currentCode.Load())
+			fmt.Printf("Pelican admin interface is not initialized\n To initialize, login at https://%v:%v/view/initialization/code/ with the following one-time token:\n", hostname, port)
+			fmt.Println(tok.value)
+			fmt.Printf("The token expires at %v\n", expiry)
 		}
-		start := time.Now()
-		for time.Since(start) < 30*time.Second {
+		for time.Now().Before(tok.expiry) {
 			select {
 			case <-sigs:
 				return errors.New("Process terminated...")
@@ -502,7 +648,7 @@ func waitUntilLogin(ctx context.Context) error {
 			default:
 				time.Sleep(100 * time.Millisecond)
 			}
-			if authDB.Load() != nil {
+			if isSetupCompleted() {
 				return nil
 			}
 		}
@@ -514,18 +660,29 @@ func waitUntilLogin(ctx context.Context) error {
 //
 // You need to mount the static resources for UI in a separate function
 func ConfigureServerWebAPI(ctx context.Context, engine *gin.Engine, egrp *errgroup.Group) error {
-	if err := configureCommonEndpoints(engine); err != nil {
+	routes, err := configureCommonEndpoints(engine)
+	if err != nil {
 		return err
 	}
-	if err := configureMetrics(engine); err != nil {
+	metricsRoutes, err := configureMetrics(engine)
+	if err != nil {
+		return err
+	}
+	routes = append(routes, metricsRoutes...)
+	notificationRoutes, err := configureNotifications(ctx, engine, egrp)
+	if err != nil {
 		return err
 	}
+	routes = append(routes, notificationRoutes...)
 	if param.Server_EnableUI.GetBool() {
-		if err := configureAuthEndpoints(ctx, engine, egrp); err != nil {
+		authRoutes, err := configureAuthEndpoints(ctx, engine, egrp)
+		if err != nil {
 			return err
 		}
+		routes = append(routes, authRoutes...)
 		configureWebResource(engine)
 	}
+	engine.GET("/api/v1.0/openapi.json", openapi.ServeDocument(func() *openapi.Document { return getServerOpenAPIDoc(routes) }))
 
 	// Redirect root to /view for web UI
 	engine.GET("/", func(c *gin.Context) {
@@ -534,6 +691,26 @@ func ConfigureServerWebAPI(ctx context.Context, engine *gin.Engine, egrp *errgro
 	return nil
 }
 
+// Build the OpenAPI document describing the web API common to all servers
+func getServerOpenAPIDoc(routes []openapi.Route) *openapi.Document {
+	doc := openapi.NewDocument(openapi.Info{
+		Title:       "Pelican Server Web API",
+		Description: "Configuration, health and authentication of a Pelican server's web UI",
+		Version:     "1.0",
+	})
+	doc.AddSecurityScheme("loginCookie", openapi.SecurityScheme{
+		Type:        "apiKey",
+		In:          "cookie",
+		Name:        "login",
+		Description: "The web UI login cookie",
+	})
+	if webUrl := param.Server_ExternalWebUrl.GetString(); webUrl != "" {
+		doc.AddServer(webUrl)
+	}
+	doc.AddRoutes("", routes)
+	return doc
+}
+
 // Setup the initial server web login by sending the one-time code to stdout
 // and record health status of the WebUI based on the success of the initialization
 func InitServerWebLogin(ctx context.Context) error {
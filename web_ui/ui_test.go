@@ -22,17 +22,20 @@ package web_ui
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/pelicanplatform/pelican/config"
+	"github.com/pelicanplatform/pelican/openapi"
 	"github.com/pelicanplatform/pelican/param"
 	"github.com/pelicanplatform/pelican/token"
 	"github.com/pelicanplatform/pelican/token_scopes"
@@ -396,3 +399,44 @@ func TestMapPrometheusPath(t *testing.T) {
 		assert.Equal(t, "/api/v1.0/director/origin/foo/bar/:path", get)
 	})
 }
+
+func TestServerOpenAPIDoc(t *testing.T) {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1.0/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	doc := openapi.Document{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+
+	t.Run("every-api-route-is-described", func(t *testing.T) {
+		undocumented := map[string]bool{"/api/v1.0/docs": true, "/api/v1.0/openapi.json": true}
+		for _, route := range router.Routes() {
+			if !strings.HasPrefix(route.Path, "/api/v1.0/") || undocumented[route.Path] {
+				continue
+			}
+			path, _ := openapi.ConvertPath(route.Path)
+			require.Contains(t, doc.Paths, path)
+			assert.Contains(t, doc.Paths[path], strings.ToLower(route.Method), "%s %s is missing from the document", route.Method, route.Path)
+		}
+	})
+
+	t.Run("auth-routes", func(t *testing.T) {
+		op := doc.Paths["/api/v1.0/auth/login"]["post"]
+		require.NotNil(t, op)
+		require.NotNil(t, op.RequestBody)
+		assert.Equal(t, "#/components/schemas/Login", op.RequestBody.Content["application/json"].Schema.Ref)
+		loginSchema := doc.Components.Schemas["Login"]
+		require.NotNil(t, loginSchema)
+		assert.Contains(t, loginSchema.Properties, "user")
+		assert.Contains(t, loginSchema.Properties, "password")
+
+		// Anyone may call whoami
+		op = doc.Paths["/api/v1.0/auth/whoami"]["get"]
+		require.NotNil(t, op)
+		require.NotEmpty(t, op.Security)
+		assert.Empty(t, op.Security[0])
+
+		op = doc.Paths["/api/v1.0/config"]["patch"]
+		require.NotNil(t, op)
+		assert.Equal(t, []map[string][]string{{"loginCookie": {}}}, op.Security)
+	})
+}
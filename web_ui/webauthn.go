@@ -0,0 +1,151 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package web_ui
+
+// The server side of the WebAuthn ceremonies (https://www.w3.org/TR/webauthn-2/) used
+// for the second factor of the web UI admins, verified with go-webauthn.  The server
+// asks for "none" attestation and only accepts credentials whose keys use one of the
+// algorithms it offers, ES256 (ECDSA P-256) and RS256 (RSASSA-PKCS1-v1_5 with SHA-256).
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Decode the base64url values sent by the browser, with or without padding
+func decodeWebAuthnBase64(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+func encodeWebAuthnBase64(value []byte) string {
+	return base64.RawURLEncoding.EncodeToString(value)
+}
+
+// Convert the errors of go-webauthn, whose details are safe to return to the client,
+// logging the developer information that may not be
+func webauthnVerifyError(err error) error {
+	var protoErr *protocol.Error
+	if errors.As(err, &protoErr) {
+		log.Debugf("WebAuthn verification failed: %s (%s)", protoErr.Details, protoErr.DevInfo)
+		return errors.New(protoErr.Details)
+	}
+	return err
+}
+
+// Check that the COSE_Key of a credential is a well-formed key for one of the algorithms
+// offered to authenticators.  go-webauthn doesn't restrict the algorithms, nor check the
+// keys before using them.
+func checkCredentialPublicKey(publicKey []byte) error {
+	key, err := webauthncose.ParsePublicKey(publicKey)
+	if err != nil {
+		return errors.Wrap(err, "invalid COSE key")
+	}
+	switch key := key.(type) {
+	case webauthncose.EC2PublicKeyData:
+		if webauthncose.COSEAlgorithmIdentifier(key.Algorithm) != webauthncose.AlgES256 {
+			break
+		}
+		if key.Curve != 1 || len(key.XCoord) != 32 || len(key.YCoord) != 32 {
+			return errors.New("invalid ES256 COSE key")
+		}
+		point := append(append([]byte{4}, key.XCoord...), key.YCoord...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return errors.Wrap(err, "invalid ES256 COSE key")
+		}
+		return nil
+	case webauthncose.RSAPublicKeyData:
+		if webauthncose.COSEAlgorithmIdentifier(key.Algorithm) != webauthncose.AlgRS256 {
+			break
+		}
+		if len(key.Exponent) != 3 || bytes.Equal(key.Exponent, []byte{0, 0, 0}) {
+			return errors.New("invalid RS256 COSE key exponent")
+		}
+		if len(bytes.TrimLeft(key.Modulus, "\x00"))*8 < 2048 {
+			return errors.New("RS256 COSE keys must be at least 2048 bits")
+		}
+		return nil
+	}
+	return errors.New("unsupported COSE key; only ES256 and RS256 are supported")
+}
+
+// Verify a registration and return the new credential's authenticator data.  The
+// attestation statement is checked by go-webauthn, though the server asks for "none".
+func verifyWebAuthnRegistration(clientDataJSON, attestationObject, challenge []byte, rpID, origin string) (protocol.AuthenticatorData, error) {
+	response := protocol.AuthenticatorAttestationResponse{
+		AuthenticatorResponse: protocol.AuthenticatorResponse{ClientDataJSON: clientDataJSON},
+		AttestationObject:     attestationObject,
+	}
+	parsed, err := response.Parse()
+	if err != nil {
+		return protocol.AuthenticatorData{}, webauthnVerifyError(err)
+	}
+	creation := protocol.ParsedCredentialCreationData{
+		Response: *parsed,
+		Raw:      protocol.CredentialCreationResponse{AttestationResponse: response},
+	}
+	if err := creation.Verify(encodeWebAuthnBase64(challenge), false, rpID, []string{origin}); err != nil {
+		return protocol.AuthenticatorData{}, webauthnVerifyError(err)
+	}
+	authData := parsed.AttestationObject.AuthData
+	if len(authData.AttData.CredentialID) == 0 {
+		return protocol.AuthenticatorData{}, errors.New("the authenticator data has no attested credential")
+	}
+	if err := checkCredentialPublicKey(authData.AttData.CredentialPublicKey); err != nil {
+		return protocol.AuthenticatorData{}, err
+	}
+	return authData, nil
+}
+
+// Verify an assertion made with a registered credential and return its authenticator data
+func verifyWebAuthnAssertion(credentialID string, clientDataJSON, rawAuthData, signature, publicKey, challenge []byte, rpID, origin string) (protocol.AuthenticatorData, error) {
+	response := protocol.CredentialAssertionResponse{
+		PublicKeyCredential: protocol.PublicKeyCredential{
+			Credential: protocol.Credential{ID: credentialID, Type: string(protocol.PublicKeyCredentialType)},
+		},
+		AssertionResponse: protocol.AuthenticatorAssertionResponse{
+			AuthenticatorResponse: protocol.AuthenticatorResponse{ClientDataJSON: clientDataJSON},
+			AuthenticatorData:     rawAuthData,
+			Signature:             signature,
+		},
+	}
+	parsed, err := response.Parse()
+	if err != nil {
+		return protocol.AuthenticatorData{}, webauthnVerifyError(err)
+	}
+	// Without an AppID, go-webauthn also accepts an all-zero RP ID hash, so check it first
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	if !bytes.Equal(parsed.Response.AuthenticatorData.RPIDHash, rpIDHash[:]) {
+		return protocol.AuthenticatorData{}, errors.New("the relying party ID doesn't match")
+	}
+	if err := checkCredentialPublicKey(publicKey); err != nil {
+		return protocol.AuthenticatorData{}, err
+	}
+	if err := parsed.Verify(encodeWebAuthnBase64(challenge), rpID, []string{origin}, "", false, publicKey); err != nil {
+		return protocol.AuthenticatorData{}, webauthnVerifyError(err)
+	}
+	return parsed.Response.AuthenticatorData, nil
+}
@@ -0,0 +1,454 @@
+/***************************************************************
+ *
+ * Copyright (C) 2024, Pelican Project, Morgridge Institute for Research
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you
+ * may not use this file except in compliance with the License.  You may
+ * obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ ***************************************************************/
+
+package web_ui
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/pelicanplatform/pelican/param"
+	"github.com/pelicanplatform/pelican/server_utils"
+)
+
+// A key-value pair of a CBOR map, kept in order for the encoder
+type cborPair struct {
+	key   interface{}
+	value interface{}
+}
+
+func cborHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n < 1<<8:
+		return []byte{major<<5 | 24, byte(n)}
+	case n < 1<<16:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+	}
+}
+
+// Encode the few types the WebAuthn structures need
+func cborEncode(t *testing.T, value interface{}) []byte {
+	switch v := value.(type) {
+	case int:
+		if v < 0 {
+			return cborHead(1, uint64(-1-v))
+		}
+		return cborHead(0, uint64(v))
+	case []byte:
+		return append(cborHead(2, uint64(len(v))), v...)
+	case string:
+		return append(cborHead(3, uint64(len(v))), v...)
+	case []cborPair:
+		result := cborHead(5, uint64(len(v)))
+		for _, pair := range v {
+			result = append(result, cborEncode(t, pair.key)...)
+			result = append(result, cborEncode(t, pair.value)...)
+		}
+		return result
+	default:
+		require.Failf(t, "unsupported CBOR type", "%T", value)
+		return nil
+	}
+}
+
+func coseKeyOf(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	return cborEncode(t, []cborPair{
+		{1, 2},
+		{3, int(webauthncose.AlgES256)},
+		{-1, 1},
+		{-2, key.X.FillBytes(make([]byte, 32))},
+		{-3, key.Y.FillBytes(make([]byte, 32))},
+	})
+}
+
+// Captured from real authenticators registering with and signing in to webauthn.io:
+// a registration with "none" attestation and an assertion made with macOS Touch ID,
+// whose authenticator data carries the credential's public key
+const (
+	realRegistrationChallenge   = "W8GzFU8pGjhoRbWrLDlamAfq_y4S1CZG1VuoeRLARrE"
+	realRegistrationClientData  = "eyJjaGFsbGVuZ2UiOiJXOEd6RlU4cEdqaG9SYldyTERsYW1BZnFfeTRTMUNaRzFWdW9lUkxBUnJFIiwib3JpZ2luIjoiaHR0cHM6Ly93ZWJhdXRobi5pbyIsInR5cGUiOiJ3ZWJhdXRobi5jcmVhdGUifQ"
+	realRegistrationAttestation = "o2NmbXRkbm9uZWdhdHRTdG10oGhhdXRoRGF0YVjEdKbqkhPJnC90siSSsyDPQCYqlMGpUKA5fyklC2CEHvBBAAAAAAAAAAAAAAAAAAAAAAAAAAAAQOsa7QYSUFukFOLTmgeK6x2ktirNMgwy_6vIwwtegxI2flS1X-JAkZL5dsadg-9bEz2J7PnsbB0B08txvsyUSvKlAQIDJiABIVggLKF5xS0_BntttUIrm2Z2tgZ4uQDwllbdIfrrBMABCNciWCDHwin8Zdkr56iSIh0MrB5qZiEzYLQpEOREhMUkY6q4Vw"
+
+	realAssertionID         = "AI7D5q2P0LS-Fal9ZT7CHM2N5BLbUunF92T8b6iYC199bO2kagSuU05-5dZGqb1SP0A0lyTWng"
+	realAssertionChallenge  = "E4PTcIH_HfX1pC6Sigk1SC9NAlgeztN0439vi8z_c9k"
+	realAssertionClientData = "eyJjaGFsbGVuZ2UiOiJFNFBUY0lIX0hmWDFwQzZTaWdrMVNDOU5BbGdlenROMDQzOXZpOHpfYzlrIiwibmV3X2tleXNfbWF5X2JlX2FkZGVkX2hlcmUiOiJkbyBub3QgY29tcGFyZSBjbGllbnREYXRhSlNPTiBhZ2FpbnN0IGEgdGVtcGxhdGUuIFNlZSBodHRwczovL2dvby5nbC95YWJQZXgiLCJvcmlnaW4iOiJodHRwczovL3dlYmF1dGhuLmlvIiwidHlwZSI6IndlYmF1dGhuLmdldCJ9"
+	realAssertionAuthData   = "dKbqkhPJnC90siSSsyDPQCYqlMGpUKA5fyklC2CEHvBFXJJiGa3OAAI1vMYKZIsLJfHwVQMANwCOw-atj9C0vhWpfWU-whzNjeQS21Lpxfdk_G-omAtffWztpGoErlNOfuXWRqm9Uj9ANJck1p6lAQIDJiABIVggKAhfsdHcBIc0KPgAcRyAIK_-Vi-nCXHkRHPNaCMBZ-4iWCBxB8fGYQSBONi9uvq0gv95dGWlhJrBwCsj_a4LJQKVHQ"
+	realAssertionSignature  = "MEUCIBtIVOQxzFYdyWQyxaLR0tik1TnuPhGVhXVSNgFwLmN5AiEAnxXdCq0UeAVGWxOaFcjBZ_mEZoXqNboY5IkQDdlWZYc"
+
+	realRPID   = "webauthn.io"
+	realOrigin = "https://webauthn.io"
+)
+
+func mustDecodeWebAuthnBase64(t *testing.T, value string) []byte {
+	decoded, err := decodeWebAuthnBase64(value)
+	require.NoError(t, err)
+	return decoded
+}
+
+// The authenticator data of the real assertion, with its flags replaced
+func realAssertionAuthDataWithFlags(t *testing.T, flags protocol.AuthenticatorFlags) []byte {
+	authData := mustDecodeWebAuthnBase64(t, realAssertionAuthData)
+	authData[32] = byte(flags)
+	return authData
+}
+
+// The public key of the credential of the real assertion
+func realAssertionPublicKey(t *testing.T) []byte {
+	authData := protocol.AuthenticatorData{}
+	require.NoError(t, authData.Unmarshal(mustDecodeWebAuthnBase64(t, realAssertionAuthData)))
+	return authData.AttData.CredentialPublicKey
+}
+
+func TestCheckCredentialPublicKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	assert.NoError(t, checkCredentialPublicKey(coseKeyOf(t, key)))
+	assert.NoError(t, checkCredentialPublicKey(realAssertionPublicKey(t)))
+
+	x, y := key.X.FillBytes(make([]byte, 32)), key.Y.FillBytes(make([]byte, 32))
+	for name, coseKey := range map[string][]byte{
+		// A point that's not on the curve
+		"off-curve": cborEncode(t, []cborPair{{1, 2}, {3, int(webauthncose.AlgES256)}, {-1, 1}, {-2, make([]byte, 32)}, {-3, make([]byte, 32)}}),
+		// Algorithms the server didn't offer
+		"ES384":  cborEncode(t, []cborPair{{1, 2}, {3, int(webauthncose.AlgES384)}, {-1, 1}, {-2, x}, {-3, y}}),
+		"EdDSA":  cborEncode(t, []cborPair{{1, 1}, {3, int(webauthncose.AlgEdDSA)}, {-1, 6}, {-2, make([]byte, 32)}}),
+		"RS1":    cborEncode(t, []cborPair{{1, 3}, {3, int(webauthncose.AlgRS1)}, {-1, make([]byte, 256)}, {-2, []byte{1, 0, 1}}}),
+		"no alg": cborEncode(t, []cborPair{{1, 2}, {-1, 1}, {-2, x}, {-3, y}}),
+		// RSA keys that are too short or malformed
+		"RS256-1024":   cborEncode(t, []cborPair{{1, 3}, {3, int(webauthncose.AlgRS256)}, {-1, append([]byte{0x80}, make([]byte, 127)...)}, {-2, []byte{1, 0, 1}}}),
+		"RS256-no-exp": cborEncode(t, []cborPair{{1, 3}, {3, int(webauthncose.AlgRS256)}, {-1, append([]byte{0x80}, make([]byte, 255)...)}}),
+		"garbage":      {0xff, 0x00},
+	} {
+		assert.Error(t, checkCredentialPublicKey(coseKey), name)
+	}
+}
+
+func TestVerifyRealWebAuthnRegistration(t *testing.T) {
+	clientData := mustDecodeWebAuthnBase64(t, realRegistrationClientData)
+	attestation := mustDecodeWebAuthnBase64(t, realRegistrationAttestation)
+	challenge := mustDecodeWebAuthnBase64(t, realRegistrationChallenge)
+
+	authData, err := verifyWebAuthnRegistration(clientData, attestation, challenge, realRPID, realOrigin)
+	require.NoError(t, err)
+	assert.Equal(t, "6xrtBhJQW6QU4tOaB4rrHaS2Ks0yDDL_q8jDC16DEjZ-VLVf4kCRkvl2xp2D71sTPYns-exsHQHTy3G-zJRK8g", encodeWebAuthnBase64(authData.AttData.CredentialID))
+
+	_, err = verifyWebAuthnRegistration(clientData, attestation, challenge, "example.com", realOrigin)
+	assert.Error(t, err, "wrong rpIdHash")
+	_, err = verifyWebAuthnRegistration(clientData, attestation, challenge, realRPID, "https://example.com")
+	assert.Error(t, err, "wrong origin")
+	_, err = verifyWebAuthnRegistration(clientData, attestation, []byte("another challenge"), realRPID, realOrigin)
+	assert.Error(t, err, "wrong challenge")
+
+	// The flags follow the RP ID hash in the authenticator data
+	rpIDHash := sha256.Sum256([]byte(realRPID))
+	flagsIdx := bytes.Index(attestation, rpIDHash[:]) + len(rpIDHash)
+	require.Equal(t, byte(protocol.FlagUserPresent|protocol.FlagAttestedCredentialData), attestation[flagsIdx])
+	notPresent := append([]byte{}, attestation...)
+	notPresent[flagsIdx] = byte(protocol.FlagAttestedCredentialData)
+	_, err = verifyWebAuthnRegistration(clientData, notPresent, challenge, realRPID, realOrigin)
+	assert.Error(t, err, "UP flag not set")
+
+	// A credential whose key uses an algorithm the server didn't offer
+	coseKeyIdx := bytes.Index(attestation, []byte{0xa5, 0x01, 0x02, 0x03, 0x26})
+	require.Positive(t, coseKeyIdx)
+	wrongAlg := append([]byte{}, attestation...)
+	wrongAlg[coseKeyIdx+4] = 0x27 // -8, EdDSA, with an EC2 key
+	_, err = verifyWebAuthnRegistration(clientData, wrongAlg, challenge, realRPID, realOrigin)
+	assert.Error(t, err, "wrong COSE alg")
+}
+
+func TestVerifyRealWebAuthnAssertion(t *testing.T) {
+	clientData := mustDecodeWebAuthnBase64(t, realAssertionClientData)
+	authData := mustDecodeWebAuthnBase64(t, realAssertionAuthData)
+	signature := mustDecodeWebAuthnBase64(t, realAssertionSignature)
+	challenge := mustDecodeWebAuthnBase64(t, realAssertionChallenge)
+	publicKey := realAssertionPublicKey(t)
+
+	verified, err := verifyWebAuthnAssertion(realAssertionID, clientData, authData, signature, publicKey, challenge, realRPID, realOrigin)
+	require.NoError(t, err)
+	assert.True(t, verified.Flags.UserPresent())
+
+	_, err = verifyWebAuthnAssertion(realAssertionID, clientData, authData, signature, publicKey, challenge, "example.com", realOrigin)
+	assert.Error(t, err, "wrong rpIdHash")
+	zeroHash := append(make([]byte, 32), authData[32:]...)
+	_, err = verifyWebAuthnAssertion(realAssertionID, clientData, zeroHash, signature, publicKey, challenge, realRPID, realOrigin)
+	assert.Error(t, err, "zero rpIdHash")
+	_, err = verifyWebAuthnAssertion(realAssertionID, clientData, authData, signature, publicKey, challenge, realRPID, "https://example.com")
+	assert.Error(t, err, "wrong origin")
+	_, err = verifyWebAuthnAssertion(realAssertionID, clientData, authData, signature, publicKey, []byte("another challenge"), realRPID, realOrigin)
+	assert.Error(t, err, "wrong challenge")
+
+	notPresent := realAssertionAuthDataWithFlags(t, protocol.FlagUserVerified|protocol.FlagAttestedCredentialData)
+	_, err = verifyWebAuthnAssertion(realAssertionID, clientData, notPresent, signature, publicKey, challenge, realRPID, realOrigin)
+	assert.Error(t, err, "UP flag not set")
+
+	badSignature := append([]byte{}, signature...)
+	badSignature[len(badSignature)-1] ^= 0x01
+	_, err = verifyWebAuthnAssertion(realAssertionID, clientData, authData, badSignature, publicKey, challenge, realRPID, realOrigin)
+	assert.Error(t, err, "bad signature")
+
+	// The same key, registered as using an algorithm the server didn't offer
+	key, err := webauthncose.ParsePublicKey(publicKey)
+	require.NoError(t, err)
+	ec2Key := key.(webauthncose.EC2PublicKeyData)
+	wrongAlg := cborEncode(t, []cborPair{{1, 2}, {3, int(webauthncose.AlgES384)}, {-1, 1}, {-2, ec2Key.XCoord}, {-3, ec2Key.YCoord}})
+	_, err = verifyWebAuthnAssertion(realAssertionID, clientData, authData, signature, wrongAlg, challenge, realRPID, realOrigin)
+	assert.Error(t, err, "wrong COSE alg")
+}
+
+func TestInitAuthStoreDB(t *testing.T) {
+	oldLocation := param.Server_UIAuthDbLocation.GetString()
+	viper.Set(param.Server_UIAuthDbLocation.GetName(), filepath.Join(t.TempDir(), "web-ui-auth.sqlite"))
+	t.Cleanup(func() {
+		if authStoreDB != nil {
+			assert.NoError(t, server_utils.ShutdownDB(authStoreDB))
+		}
+		authStoreDB = nil
+		viper.Set(param.Server_UIAuthDbLocation.GetName(), oldLocation)
+	})
+	require.NoError(t, initAuthStoreDB())
+
+	// The migrations match the models
+	require.NoError(t, authStoreDB.Create(&WebAuthnCredential{ID: "abc", User: "admin", PublicKey: []byte{1}, CreatedAt: time.Now()}).Error)
+	codes, err := generateRecoveryCodes("admin")
+	require.NoError(t, err)
+	assert.Len(t, codes, recoveryCodeCount)
+	creds, err := getWebAuthnCredentials("admin")
+	require.NoError(t, err)
+	require.Len(t, creds, 1)
+	assert.Nil(t, creds[0].LastUsedAt)
+}
+
+func TestWebAuthnSecondFactor(t *testing.T) {
+	mockDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, mockDB.AutoMigrate(&WebAuthnCredential{}, &WebAuthnRecoveryCode{}))
+	authStoreDB = mockDB
+	oldExternalWebUrl := param.Server_ExternalWebUrl.GetString()
+	viper.Set(param.Server_ExternalWebUrl.GetName(), "https://example.com:8444")
+	t.Cleanup(func() {
+		authStoreDB = nil
+		pendingLogins.DeleteAll()
+		registrationChallenges.DeleteAll()
+		viper.Set(param.Server_ExternalWebUrl.GetName(), oldExternalWebUrl)
+	})
+	rpIDHash := sha256.Sum256([]byte("example.com"))
+	origin := "https://example.com:8444"
+
+	engine := gin.New()
+	admin := func(ctx *gin.Context) { ctx.Set("User", "admin") }
+	engine.POST("/register/begin", admin, handleWebAuthnRegisterBegin)
+	engine.POST("/register/finish", admin, handleWebAuthnRegisterFinish)
+	engine.GET("/credentials", admin, handleListWebAuthnCredentials)
+	engine.POST("/recoveryCodes", admin, handleRegenerateRecoveryCodes)
+	engine.POST("/start", func(ctx *gin.Context) {
+		required, err := startSecondFactor(ctx, "admin", nil)
+		require.NoError(t, err)
+		ctx.JSON(http.StatusOK, gin.H{"required": required})
+	})
+	engine.POST("/login/begin", handleWebAuthnLoginBegin)
+	engine.POST("/login/finish", handleWebAuthnLoginFinish)
+	engine.POST("/login/recovery", handleRecoveryCodeLogin)
+
+	do := func(method, target string, body interface{}, cookies []*http.Cookie) *httptest.ResponseRecorder {
+		var reader *bytes.Reader
+		if body != nil {
+			data, err := json.Marshal(body)
+			require.NoError(t, err)
+			reader = bytes.NewReader(data)
+		} else {
+			reader = bytes.NewReader(nil)
+		}
+		req := httptest.NewRequest(method, target, reader)
+		req.Header.Set("Content-Type", "application/json")
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		recorder := httptest.NewRecorder()
+		engine.ServeHTTP(recorder, req)
+		return recorder
+	}
+	getChallenge := func(recorder *httptest.ResponseRecorder) string {
+		require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+		res := struct {
+			PublicKey struct {
+				Challenge string `json:"challenge"`
+			} `json:"publicKey"`
+		}{}
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+		return res.PublicKey.Challenge
+	}
+	startLogin := func() []*http.Cookie {
+		recorder := do(http.MethodPost, "/start", nil, nil)
+		require.JSONEq(t, `{"required": true}`, recorder.Body.String())
+		return recorder.Result().Cookies()
+	}
+	hasLoginCookie := func(recorder *httptest.ResponseRecorder) bool {
+		for _, cookie := range recorder.Result().Cookies() {
+			if cookie.Name == "login" && cookie.Value != "" {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Without an authenticator, the login completes with the first factor
+	recorder := do(http.MethodPost, "/start", nil, nil)
+	assert.JSONEq(t, `{"required": false}`, recorder.Body.String())
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	credentialID := []byte("test-credential")
+
+	register := func(challenge, origin string) *httptest.ResponseRecorder {
+		clientData, err := json.Marshal(protocol.CollectedClientData{Type: protocol.CreateCeremony, Challenge: challenge, Origin: origin})
+		require.NoError(t, err)
+		authData := append(append([]byte{}, rpIDHash[:]...), byte(protocol.FlagUserPresent|protocol.FlagAttestedCredentialData), 0, 0, 0, 0)
+		authData = append(authData, make([]byte, 16)...)
+		authData = binary.BigEndian.AppendUint16(authData, uint16(len(credentialID)))
+		authData = append(authData, credentialID...)
+		authData = append(authData, coseKeyOf(t, key)...)
+		attestation := cborEncode(t, []cborPair{{"fmt", "none"}, {"attStmt", []cborPair{}}, {"authData", authData}})
+		return do(http.MethodPost, "/register/finish", webauthnRegistrationReq{
+			Name:              "test key",
+			ClientDataJSON:    encodeWebAuthnBase64(clientData),
+			AttestationObject: encodeWebAuthnBase64(attestation),
+		}, nil)
+	}
+
+	t.Run("register", func(t *testing.T) {
+		// The origin must match Server.ExternalWebUrl
+		recorder := register(getChallenge(do(http.MethodPost, "/register/begin", nil, nil)), "https://evil.example.com")
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+		challenge := getChallenge(do(http.MethodPost, "/register/begin", nil, nil))
+		recorder = register(challenge, origin)
+		require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+		res := recoveryCodesRes{}
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+		assert.Len(t, res.RecoveryCodes, recoveryCodeCount)
+
+		// A challenge can't be used twice
+		recorder = register(challenge, origin)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+		recorder = do(http.MethodGet, "/credentials", nil, nil)
+		creds := []WebAuthnCredential{}
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &creds))
+		require.Len(t, creds, 1)
+		assert.Equal(t, encodeWebAuthnBase64(credentialID), creds[0].ID)
+		assert.Equal(t, "test key", creds[0].Name)
+	})
+
+	signCount := uint32(0)
+	signIn := func(cookies []*http.Cookie, challenge string, count uint32) *httptest.ResponseRecorder {
+		clientData, err := json.Marshal(protocol.CollectedClientData{Type: protocol.AssertCeremony, Challenge: challenge, Origin: origin})
+		require.NoError(t, err)
+		authData := binary.BigEndian.AppendUint32(append(append([]byte{}, rpIDHash[:]...), byte(protocol.FlagUserPresent)), count)
+		clientDataHash := sha256.Sum256(clientData)
+		digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+		signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+		require.NoError(t, err)
+		return do(http.MethodPost, "/login/finish", webauthnAssertionReq{
+			ID:                encodeWebAuthnBase64(credentialID),
+			ClientDataJSON:    encodeWebAuthnBase64(clientData),
+			AuthenticatorData: encodeWebAuthnBase64(authData),
+			Signature:         encodeWebAuthnBase64(signature),
+		}, cookies)
+	}
+
+	t.Run("login", func(t *testing.T) {
+		// Nothing is pending without the cookie
+		recorder := do(http.MethodPost, "/login/begin", nil, nil)
+		require.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+		cookies := startLogin()
+		challenge := getChallenge(do(http.MethodPost, "/login/begin", nil, cookies))
+		signCount++
+		recorder = signIn(cookies, challenge, signCount)
+		require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+		require.True(t, hasLoginCookie(recorder))
+
+		// The pending login is gone once completed
+		recorder = do(http.MethodPost, "/login/begin", nil, cookies)
+		require.Equal(t, http.StatusUnauthorized, recorder.Code)
+	})
+
+	t.Run("replayed-counter", func(t *testing.T) {
+		cookies := startLogin()
+		challenge := getChallenge(do(http.MethodPost, "/login/begin", nil, cookies))
+		recorder := signIn(cookies, challenge, signCount)
+		require.Equal(t, http.StatusUnauthorized, recorder.Code)
+		require.False(t, hasLoginCookie(recorder))
+	})
+
+	t.Run("recovery-code", func(t *testing.T) {
+		recorder := do(http.MethodPost, "/recoveryCodes", nil, nil)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		res := recoveryCodesRes{}
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+		require.Len(t, res.RecoveryCodes, recoveryCodeCount)
+
+		cookies := startLogin()
+		recorder = do(http.MethodPost, "/login/recovery", recoveryCodeReq{Code: "not-a-code"}, cookies)
+		require.Equal(t, http.StatusUnauthorized, recorder.Code)
+		// Codes are accepted regardless of case and dashes
+		code := bytes.ToUpper(bytes.ReplaceAll([]byte(res.RecoveryCodes[0]), []byte("-"), nil))
+		recorder = do(http.MethodPost, "/login/recovery", recoveryCodeReq{Code: string(code)}, cookies)
+		require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+		require.True(t, hasLoginCookie(recorder))
+
+		// Each code is only good once
+		cookies = startLogin()
+		recorder = do(http.MethodPost, "/login/recovery", recoveryCodeReq{Code: res.RecoveryCodes[0]}, cookies)
+		require.Equal(t, http.StatusUnauthorized, recorder.Code)
+	})
+
+	t.Run("too-many-failures", func(t *testing.T) {
+		cookies := startLogin()
+		for i := 0; i < secondFactorMaxFailures; i++ {
+			recorder := do(http.MethodPost, "/login/recovery", recoveryCodeReq{Code: "not-a-code"}, cookies)
+			require.Equal(t, http.StatusUnauthorized, recorder.Code)
+		}
+		recorder := do(http.MethodPost, "/login/begin", nil, cookies)
+		require.Equal(t, http.StatusUnauthorized, recorder.Code)
+	})
+}
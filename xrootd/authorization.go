@@ -479,9 +479,46 @@ func GenerateOriginIssuer(exportedPaths []string) (issuer Issuer, err error) {
 	issuer.DefaultUser = param.Origin_ScitokensDefaultUser.GetString()
 	issuer.UsernameClaim = param.Origin_ScitokensUsernameClaim.GetString()
 
+	rules, err := origin.GetUserMappingRules()
+	if err != nil {
+		return
+	}
+	if len(rules) > 0 {
+		if !param.Origin_Multiuser.GetBool() {
+			log.Warningln("Tokens are mapped to local users by Origin.UserMapping or Origin.ScitokensNameMapFile, but the files" +
+				" written through the origin are owned by the XRootD user because Origin.Multiuser is not set")
+		}
+		issuer.NameMapfile, err = writeNameMapfile(rules)
+	}
+
 	return
 }
 
+// Write the user mapping rules to a name mapfile for XRootD's SciTokens plugin,
+// returning the file's location
+func writeNameMapfile(rules []origin.UserMappingRule) (string, error) {
+	gid, err := config.GetDaemonGID()
+	if err != nil {
+		return "", err
+	}
+	contents, err := json.MarshalIndent(rules, "", "  ")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode the user mapping rules")
+	}
+	mapfile := filepath.Join(param.Origin_RunLocation.GetString(), "name-mapfile-generated.json")
+	tmpfile := mapfile + ".tmp"
+	if err := os.WriteFile(tmpfile, contents, 0640); err != nil {
+		return "", errors.Wrapf(err, "failed to write the name mapfile %s", tmpfile)
+	}
+	if err := os.Chown(tmpfile, -1, gid); err != nil {
+		return "", errors.Wrapf(err, "unable to change ownership of the name mapfile %s to the daemon gid %d", tmpfile, gid)
+	}
+	if err := os.Rename(tmpfile, mapfile); err != nil {
+		return "", errors.Wrap(err, "failed to move the name mapfile to its final location")
+	}
+	return mapfile, nil
+}
+
 // We have a special issuer just for director-based monitoring of the origin.
 func GenerateDirectorMonitoringIssuer() (issuer Issuer, err error) {
 	fedInfo, err := config.GetFederation(context.Background())
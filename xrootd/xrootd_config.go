@@ -644,10 +644,22 @@ func ConfigXrootd(ctx context.Context, isOrigin bool) (string, error) {
 	}
 
 	switch xrdConfig.Origin.StorageType {
+	case "s3":
+		// Send the S3 requests through the origin's request-tracking gateway, if it's running.
+		// The gateway expects path-style requests and forwards them using the configured style.
+		if gatewayUrl := origin.GetS3GatewayUrl(); gatewayUrl != "" {
+			xrdConfig.Origin.S3ServiceUrl = gatewayUrl
+			xrdConfig.Origin.S3UrlStyle = "path"
+		}
 	case "https":
 		if xrdConfig.Origin.HttpServiceUrl == "" {
 			xrdConfig.Origin.HttpServiceUrl = param.Origin_HttpServiceUrl.GetString()
 		}
+		// Send the backend requests through the origin's gateway, if it's running, which
+		// adds the configured authentication and header rewrites
+		if gatewayUrl := origin.GetHttpsGatewayUrl(); gatewayUrl != "" {
+			xrdConfig.Origin.HttpServiceUrl = gatewayUrl
+		}
 		if xrdConfig.Origin.FederationPrefix == "" {
 			xrdConfig.Origin.FederationPrefix = param.Origin_FederationPrefix.GetString()
 		}